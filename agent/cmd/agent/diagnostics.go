@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/diagnostics"
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-agent/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+// diagnosticsTimeout bounds a CLI diagnostics collection
+const diagnosticsTimeout = 2 * time.Minute
+
+// runDiagnosticsCommand handles `dockmon-agent diagnostics [-output path]`:
+// it collects a diagnostics bundle for the agent configured in the
+// environment, with the service manager's logs in place of the in-memory
+// log buffer, and writes it to DataPath/diagnostics, the given path, or
+// stdout for "-". Returns the process exit code.
+func runDiagnosticsCommand(args []string) int {
+	fs := flag.NewFlagSet("diagnostics", flag.ContinueOnError)
+	output := fs.String("output", "", "bundle path, or - for stdout (default DATA_PATH/diagnostics/<name>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: dockmon-agent diagnostics [-output path]")
+		return 2
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	setBuildInfo(cfg)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// Still collect the rest when Docker is unreachable; the bundle records why
+	dockerClient, err := docker.NewClient(cfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Docker client unavailable: %v\n", err)
+		dockerClient = nil
+	} else {
+		defer dockerClient.Close()
+	}
+
+	collector := diagnostics.NewCollector(cfg, dockerClient)
+	collector.SetServiceLogs(service.RecentLogs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	if *output == "-" {
+		if _, err := collector.Write(ctx, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write diagnostics bundle: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	path := *output
+	if path == "" {
+		dir := filepath.Join(cfg.DataPath, "diagnostics")
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", dir, err)
+			return 1
+		}
+		path = filepath.Join(dir, diagnostics.FileName(time.Now()))
+	}
+
+	// 0600: logs and Docker details are not for other users
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- path chosen by the operator
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", path, err)
+		return 1
+	}
+	manifest, err := collector.Write(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Failed to write diagnostics bundle: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Diagnostics bundle written to %s\n", path)
+	for section, reason := range manifest.Errors {
+		fmt.Printf("  skipped %s: %s\n", section, reason)
+	}
+	return 0
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/client"
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-agent/internal/service"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// agentImage is the published agent container image
+	agentImage = "ghcr.io/darthnorse/dockmon-agent"
+	// linuxServiceDataPath matches the data directory scripts/install-agent.sh uses
+	linuxServiceDataPath = "/var/lib/dockmon-agent"
+	// installRegisterTimeout bounds the one-shot registration
+	installRegisterTimeout = 60 * time.Second
+)
+
+// installFlagEnv maps `dockmon-agent install` flags to the environment
+// variables they set; anything else is read from the environment as usual
+var installFlagEnv = map[string]string{
+	"url":          "DOCKMON_URL",
+	"token":        "REGISTRATION_TOKEN",
+	"name":         "AGENT_NAME",
+	"data-path":    "DATA_PATH",
+	"insecure":     "INSECURE_SKIP_VERIFY",
+	"ca-file":      "DOCKMON_CA_FILE",
+	"cert-sha256":  "DOCKMON_CERT_SHA256",
+	"force-unique": "FORCE_UNIQUE_REGISTRATION",
+}
+
+// containerCAFile is where the docker run command mounts DOCKMON_CA_FILE
+const containerCAFile = "/etc/dockmon-agent/ca.pem"
+
+// dockerHostOnlyEnv are settings that describe this host's filesystem or
+// daemon address and don't carry over into the agent container
+var dockerHostOnlyEnv = []string{
+	"DATA_PATH",
+	"DOCKER_HOST",
+	"DOCKER_CERT_PATH",
+	"DOCKER_TLS_VERIFY",
+	"XDG_RUNTIME_DIR",
+	"REGISTRATION_TOKEN",
+}
+
+// runInstallCommand handles `dockmon-agent install`: it registers this host
+// with DockMon (exchanging the one-time registration token for the permanent
+// token, which also proves the URL, token and Docker access work), then
+// installs the agent as a native service (systemd unit on Linux) or prints
+// the docker run command for the agent container. Returns the process exit
+// code.
+func runInstallCommand(args []string) int {
+	fs := flag.NewFlagSet("install", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dockmon-agent install --url URL --token TOKEN [flags]")
+		fs.PrintDefaults()
+	}
+	fs.String("url", "", "DockMon URL (DOCKMON_URL)")
+	fs.String("token", "", "one-time registration token (REGISTRATION_TOKEN)")
+	fs.String("name", "", "display name in DockMon (AGENT_NAME)")
+	fs.String("data-path", "", "data directory for the native service (DATA_PATH)")
+	fs.Bool("insecure", false, "skip TLS certificate verification (INSECURE_SKIP_VERIFY)")
+	fs.String("ca-file", "", "PEM bundle of CAs to trust for DockMon's certificate (DOCKMON_CA_FILE)")
+	fs.String("cert-sha256", "", "SHA-256 fingerprint(s) DockMon's certificate must match (DOCKMON_CERT_SHA256)")
+	fs.Bool("force-unique", false, "register cloned hosts separately, requires --name (FORCE_UNIQUE_REGISTRATION)")
+	mode := fs.String("mode", "service", "install as a native \"service\" or print a \"docker\" run command")
+	image := fs.String("image", defaultAgentImage(), "agent image for --mode docker")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || (*mode != "service" && *mode != "docker") {
+		fs.Usage()
+		return 2
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := installFlagEnv[f.Name]; ok {
+			_ = os.Setenv(key, f.Value.String())
+		}
+	})
+	// The service and the container don't run from this directory
+	if caFile := os.Getenv("DOCKMON_CA_FILE"); caFile != "" {
+		if abs, err := filepath.Abs(caFile); err == nil {
+			_ = os.Setenv("DOCKMON_CA_FILE", abs)
+		}
+	}
+	if *mode == "service" && runtime.GOOS == "linux" && os.Getenv("DATA_PATH") == "" {
+		_ = os.Setenv("DATA_PATH", linuxServiceDataPath)
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	setBuildInfo(cfg)
+
+	// The container keeps its permanent token in its own volume, so the
+	// host copy is only needed for the duration of the registration
+	if *mode == "docker" {
+		tmp, err := os.MkdirTemp("", "dockmon-agent-install-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create temporary directory: %v\n", err)
+			return 1
+		}
+		defer os.RemoveAll(tmp)
+		cfg.DataPath = tmp
+	} else if err := os.MkdirAll(cfg.DataPath, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create data directory %s: %v\n", cfg.DataPath, err)
+		return 1
+	}
+
+	fmt.Printf("Registering with %s...\n", cfg.DockMonURL)
+	agentID, hostID, err := registerHost(cfg, installLogger(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Registration failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Registered (agent: %s, host: %s)\n", agentID, hostID)
+
+	// The registration token is used up; the agent authenticates with the
+	// permanent token from now on
+	env := service.Environment(cfg.DataPath)
+	delete(env, "REGISTRATION_TOKEN")
+
+	if *mode == "docker" {
+		for _, key := range dockerHostOnlyEnv {
+			delete(env, key)
+		}
+		env["PERMANENT_TOKEN"] = cfg.PermanentToken
+		fmt.Println("\nStart the agent container with:")
+		fmt.Println()
+		fmt.Println(dockerRunCommand(*image, hostSocket(cfg.DockerHost), env))
+		return 0
+	}
+
+	binaryPath, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to locate agent binary: %v\n", err)
+		return 1
+	}
+	if err := service.Install(binaryPath, env); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to install service: %v\n", err)
+		return 1
+	}
+	fmt.Printf("DockMon agent service installed and started (binary: %s, data: %s)\n", binaryPath, cfg.DataPath)
+	return 0
+}
+
+// registerHost connects to Docker and DockMon and registers once. The
+// permanent token from the response is persisted to cfg.DataPath and set
+// on cfg.
+func registerHost(cfg *config.Config, log *logrus.Logger) (agentID, hostID string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), installRegisterTimeout)
+	defer cancel()
+
+	dockerClient, err := docker.NewClient(cfg, log)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer dockerClient.Close()
+
+	engineID, err := dockerClient.GetEngineID(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to reach Docker daemon: %w", err)
+	}
+
+	wsClient, err := client.NewWebSocketClient(ctx, cfg, dockerClient, engineID, "", log)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create WebSocket client: %w", err)
+	}
+	return wsClient.Register(ctx)
+}
+
+// installLogger keeps the agent's startup logging out of the installer's
+// output unless LOG_LEVEL asks for it
+func installLogger(cfg *config.Config) *logrus.Logger {
+	log := setupLogging(cfg)
+	if os.Getenv("LOG_LEVEL") == "" {
+		log.SetLevel(logrus.WarnLevel)
+	}
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	return log
+}
+
+// defaultAgentImage is the image matching this binary's release, or latest
+// for development builds
+func defaultAgentImage() string {
+	if version == "" || strings.HasPrefix(version, "dev") {
+		return agentImage + ":latest"
+	}
+	return agentImage + ":" + version
+}
+
+// hostSocket returns the socket path of a unix:// Docker host, or "" for
+// other addresses
+func hostSocket(dockerHost string) string {
+	if socket, ok := strings.CutPrefix(dockerHost, "unix://"); ok {
+		return socket
+	}
+	return ""
+}
+
+// dockerRunCommand renders the docker run command for the agent container,
+// with the same mounts as the README's quick start plus /proc for host stats
+// and the CA bundle, if one is configured. socket is the host's daemon
+// socket, e.g. a rootless one under /run/user; default /var/run/docker.sock.
+func dockerRunCommand(image, socket string, env map[string]string) string {
+	if socket == "" {
+		socket = "/var/run/docker.sock"
+	}
+	lines := []string{
+		"docker run -d",
+		"--name " + service.Name,
+		"--restart unless-stopped",
+		"-v " + shellQuote(socket+":/var/run/docker.sock"),
+		"-v /proc:/host/proc:ro",
+		"-v dockmon-agent-data:/data",
+	}
+	if caFile := env["DOCKMON_CA_FILE"]; caFile != "" {
+		lines = append(lines, "-v "+shellQuote(caFile+":"+containerCAFile+":ro"))
+	}
+	for _, key := range sortedEnvKeys(env) {
+		value := env[key]
+		if key == "DOCKMON_CA_FILE" {
+			value = containerCAFile
+		}
+		lines = append(lines, "-e "+shellQuote(key+"="+value))
+	}
+	lines = append(lines, shellQuote(image))
+	return strings.Join(lines, " \\\n  ")
+}
+
+// sortedEnvKeys returns env's keys in a stable order
+func sortedEnvKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// shellSafe matches words that need no quoting in a POSIX shell
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote single-quotes s for a POSIX shell when it isn't a safe word
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"DOCKMON_URL=https://dockmon.example:8443": "DOCKMON_URL=https://dockmon.example:8443",
+		"AGENT_NAME=my host":                       "'AGENT_NAME=my host'",
+		"AGENT_NAME=Bob's NAS":                     `'AGENT_NAME=Bob'\''s NAS'`,
+		"X=$(reboot)":                              "'X=$(reboot)'",
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDockerRunCommand(t *testing.T) {
+	cmd := dockerRunCommand("ghcr.io/darthnorse/dockmon-agent:2.2.0", "", map[string]string{
+		"PERMANENT_TOKEN": "abc-123",
+		"DOCKMON_URL":     "https://dockmon.example",
+	})
+
+	for _, want := range []string{
+		"docker run -d \\\n",
+		"-v /var/run/docker.sock:/var/run/docker.sock",
+		"-v dockmon-agent-data:/data",
+		"-e DOCKMON_URL=https://dockmon.example \\\n  -e PERMANENT_TOKEN=abc-123",
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command missing %q:\n%s", want, cmd)
+		}
+	}
+	if !strings.HasSuffix(cmd, "ghcr.io/darthnorse/dockmon-agent:2.2.0") {
+		t.Errorf("image should come last:\n%s", cmd)
+	}
+	if strings.Contains(cmd, "DOCKMON_CA_FILE") {
+		t.Errorf("unexpected CA bundle:\n%s", cmd)
+	}
+}
+
+func TestDockerRunCommandMountsCAFile(t *testing.T) {
+	cmd := dockerRunCommand("dockmon-agent", "", map[string]string{
+		"DOCKMON_URL":     "https://dockmon.lan",
+		"DOCKMON_CA_FILE": "/opt/my ca/root.pem",
+	})
+	for _, want := range []string{
+		"-v '/opt/my ca/root.pem:" + containerCAFile + ":ro'",
+		"-e DOCKMON_CA_FILE=" + containerCAFile,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("command missing %q:\n%s", want, cmd)
+		}
+	}
+}
+
+func TestDockerRunCommandMountsRootlessSocket(t *testing.T) {
+	socket := hostSocket("unix:///run/user/1000/docker.sock")
+	cmd := dockerRunCommand("dockmon-agent", socket, map[string]string{"DOCKMON_URL": "https://dockmon.lan"})
+	if !strings.Contains(cmd, "-v /run/user/1000/docker.sock:/var/run/docker.sock") {
+		t.Errorf("command should mount the rootless socket:\n%s", cmd)
+	}
+	if hostSocket("tcp://10.0.0.5:2375") != "" {
+		t.Error("a TCP host has no socket to mount")
+	}
+}
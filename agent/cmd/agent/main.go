@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -11,7 +12,14 @@ import (
 
 	"github.com/darthnorse/dockmon-agent/internal/client"
 	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/diagnostics"
 	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-agent/internal/handlers"
+	"github.com/darthnorse/dockmon-agent/internal/notify"
+	"github.com/darthnorse/dockmon-agent/internal/service"
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/debugserver"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,11 +28,25 @@ var (
 	// time. The "dev" fallback applies only to local `go build` / `go run`
 	// invocations. Do NOT bump this per release - the tag is the single
 	// source of truth.
-	version = "dev"
-	commit  = "dev"
+	version   = "dev"
+	commit    = "dev"
+	buildDate = ""
 )
 
 func main() {
+	// `dockmon-agent service install|uninstall` manages the native service
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		os.Exit(runServiceCommand(os.Args[2:]))
+	}
+	// `dockmon-agent install` registers the host and sets up the agent
+	if len(os.Args) > 1 && os.Args[1] == "install" {
+		os.Exit(runInstallCommand(os.Args[2:]))
+	}
+	// `dockmon-agent diagnostics` writes a support bundle and exits
+	if len(os.Args) > 1 && os.Args[1] == "diagnostics" {
+		os.Exit(runDiagnosticsCommand(os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
@@ -33,15 +55,40 @@ func main() {
 	}
 
 	// Set agent version from build
-	cfg.AgentVersion = version
+	setBuildInfo(cfg)
 
 	// Setup logging
 	log := setupLogging(cfg)
+	// Recent log lines are kept in memory for diagnostics bundles
+	logs := diagnostics.NewLogBuffer(diagnostics.DefaultLogLines)
+	log.AddHook(logs)
 	log.WithFields(logrus.Fields{
 		"version": version,
-		"commit":  commit,
+		"commit":  cfg.AgentCommit,
 	}).Info("DockMon Agent starting")
 
+	// The Windows service manager controls the lifecycle itself; everywhere
+	// else stop on SIGINT/SIGTERM
+	if service.IsService() {
+		err = service.Run(func(ctx context.Context) error {
+			return runAgent(ctx, cfg, log, logs)
+		})
+	} else {
+		err = runWithSignals(cfg, log, logs)
+	}
+
+	if err != nil {
+		if errors.Is(err, handlers.ErrRestartRequired) {
+			log.Info("Exiting so the service manager starts the updated binary")
+		} else {
+			log.WithError(err).Error("Agent stopped with error")
+		}
+		os.Exit(1)
+	}
+}
+
+// runWithSignals runs the agent until SIGINT or SIGTERM.
+func runWithSignals(cfg *config.Config, log *logrus.Logger, logs *diagnostics.LogBuffer) error {
 	// Create context that cancels on signal
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -49,22 +96,66 @@ func main() {
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	defer func() {
-		signal.Stop(sigChan)
-		close(sigChan)
+	defer signal.Stop(sigChan)
+
+	go func() {
+		select {
+		case sig := <-sigChan:
+			log.WithField("signal", sig).Info("Received shutdown signal")
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
+	return runAgent(ctx, cfg, log, logs)
+}
+
+// setBuildInfo copies the -ldflags build metadata into cfg. Local builds
+// without -ldflags fall back to the VCS metadata Go embeds.
+func setBuildInfo(cfg *config.Config) {
+	cfg.AgentVersion = version
+	cfg.AgentCommit = commit
+	cfg.AgentBuildDate = buildDate
+	if build := diagnostics.ReadBuild(); commit == "dev" && build.Revision != "" {
+		cfg.AgentCommit = build.Revision
+		if cfg.AgentBuildDate == "" {
+			cfg.AgentBuildDate = build.Time
+		}
+	}
+}
+
+// runAgent connects to Docker and DockMon and runs until ctx is cancelled.
+func runAgent(ctx context.Context, cfg *config.Config, log *logrus.Logger, logs *diagnostics.LogBuffer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Resource limits; a priority the agent isn't allowed to set isn't fatal
+	throttler := throttle.New(log)
+	if err := throttler.Apply(cfg.Throttle); err != nil {
+		log.WithError(err).Warn("Failed to apply process priority")
+	}
+
 	// Initialize Docker client
-	dockerClient, err := docker.NewClient(cfg, log)
+	dockerClient, err := docker.NewClient(cfg, log, throttler.ClientOption())
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create Docker client")
+		return fmt.Errorf("failed to create Docker client: %w", err)
 	}
 	defer dockerClient.Close()
+	go dockerClient.Pool().Run(ctx)
+
+	// Optional pprof/expvar listener for profiling leaks on large hosts
+	if cfg.PprofAddr != "" {
+		if addr, err := debugserver.Start(ctx, cfg.PprofAddr, cfg.PprofAllowRemote); err != nil {
+			log.WithError(err).Warn("Profiling endpoints disabled")
+		} else {
+			log.WithField("addr", addr.String()).Info("Profiling endpoints (pprof, expvar) listening")
+		}
+	}
 
 	// Get Docker engine ID
 	engineID, err := dockerClient.GetEngineID(ctx)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to get Docker engine ID")
+		return fmt.Errorf("failed to get Docker engine ID: %w", err)
 	}
 
 	log.WithField("engine_id", engineID).Info("Connected to Docker daemon")
@@ -100,12 +191,37 @@ func main() {
 	// Initialize WebSocket client
 	wsClient, err := client.NewWebSocketClient(ctx, cfg, dockerClient, engineID, myContainerID, log)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create WebSocket client")
+		return fmt.Errorf("failed to create WebSocket client: %w", err)
+	}
+	wsClient.SetLogBuffer(logs)
+	wsClient.SetThrottler(throttler)
+
+	// Local notifications for critical events while DockMon is unreachable
+	localNotifier, err := notify.New(cfg, localNotifyHost(cfg), log)
+	if err != nil {
+		return fmt.Errorf("invalid local notification settings: %w", err)
+	}
+	if localNotifier != nil {
+		wsClient.SetLocalNotifier(localNotifier)
+		go localNotifier.Run(ctx, dockerClient.WatchEvents)
+		log.WithField("type", cfg.LocalNotifyType).Info("Local notifications enabled")
 	}
 
 	// Check for pending self-update on startup
 	if err := wsClient.CheckPendingUpdate(); err != nil {
+		if errors.Is(err, handlers.ErrRestartRequired) {
+			return err
+		}
 		log.WithError(err).Warn("Failed to check/apply pending update")
+		localNotifier.Notify(notify.KindUpdateFailed, "update_failed",
+			"DockMon agent self-update failed",
+			fmt.Sprintf("Applying the pending agent update failed: %v", err))
+	}
+
+	// A different version than last start means a self-update was applied
+	previousVersion, versionChanged, err := handlers.RecordAgentVersion(cfg.DataPath, cfg.AgentVersion)
+	if err != nil {
+		log.WithError(err).Warn("Failed to record agent version")
 	}
 
 	// Stats service dual-send: open a separate WebSocket to stats-service for
@@ -120,10 +236,24 @@ func main() {
 	// load the persisted token from DataPath/permanent_token and dual-send
 	// activates immediately. This matches the spec's gating intent.
 	if cfg.PermanentToken != "" && cfg.DockMonURL != "" {
-		statsClient := client.NewStatsServiceClient(cfg.DockMonURL, cfg.PermanentToken, cfg.InsecureSkipVerify, log)
+		tlsConfig, _ := cfg.DockMonTLSConfig() // Validated by LoadFromEnv
+		statsClient := client.NewStatsServiceClient(cfg.ServerURL(), cfg.PermanentToken, tlsConfig, log)
+		statsClient.SetHeaders(cfg.ExtraHeaders)
 		if statsHandler := wsClient.StatsHandler(); statsHandler != nil {
 			statsHandler.SetStatsServiceClient(statsClient)
 		}
+		// The same connection carries update and deployment activity events
+		wsClient.SetActivityPublisher(statsClient)
+		if versionChanged {
+			statsClient.Publish(activity.Event{
+				Action:  activity.ActionSelfUpdateApplied,
+				Message: fmt.Sprintf("Agent updated from %s to %s", previousVersion, cfg.AgentVersion),
+				Attributes: map[string]string{
+					"previous_version": previousVersion,
+					"version":          cfg.AgentVersion,
+				},
+			})
+		}
 		go statsClient.Run(ctx)
 		log.Info("Stats service dual-send enabled")
 	} else {
@@ -134,19 +264,21 @@ func main() {
 	}
 
 	// Start client in background
+	clientErr := make(chan error, 1)
 	go func() {
-		if err := wsClient.Run(ctx); err != nil {
-			log.WithError(err).Error("WebSocket client stopped with error")
-			cancel()
-		}
+		clientErr <- wsClient.Run(ctx)
 	}()
 
-	// Wait for shutdown signal
+	// Wait for shutdown
+	var runErr error
 	select {
-	case sig := <-sigChan:
-		log.WithField("signal", sig).Info("Received shutdown signal")
 	case <-ctx.Done():
 		log.Info("Context cancelled")
+	case err := <-clientErr:
+		if err != nil {
+			log.WithError(err).Error("WebSocket client stopped with error")
+			runErr = err
+		}
 	}
 
 	log.Info("Shutting down gracefully...")
@@ -155,6 +287,62 @@ func main() {
 	// Wait a moment for graceful shutdown
 	// (WebSocket client will close connection properly)
 	// TODO: Add proper shutdown coordination
+	return runErr
+}
+
+// localNotifyHost names this host in local notifications
+func localNotifyHost(cfg *config.Config) string {
+	if cfg.AgentName != "" {
+		return cfg.AgentName
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "unknown host"
+}
+
+// runServiceCommand handles `dockmon-agent service install|uninstall`,
+// registering the agent with the platform's service manager (systemd unit,
+// Windows service or launchd daemon). Settings are taken from the environment of
+// the installing shell. Returns the process exit code.
+func runServiceCommand(args []string) int {
+	if len(args) != 1 || (args[0] != "install" && args[0] != "uninstall") {
+		fmt.Fprintln(os.Stderr, "Usage: dockmon-agent service install|uninstall")
+		return 2
+	}
+
+	if args[0] == "uninstall" {
+		if err := service.Uninstall(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to uninstall service: %v\n", err)
+			return 1
+		}
+		fmt.Println("DockMon agent service uninstalled")
+		return 0
+	}
+
+	// Validate now rather than have the service fail on first start
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(cfg.DataPath, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create data directory %s: %v\n", cfg.DataPath, err)
+		return 1
+	}
+
+	binaryPath, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to locate agent binary: %v\n", err)
+		return 1
+	}
+
+	if err := service.Install(binaryPath, service.Environment(cfg.DataPath)); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to install service: %v\n", err)
+		return 1
+	}
+	fmt.Printf("DockMon agent service installed (binary: %s, data: %s)\n", binaryPath, cfg.DataPath)
+	return 0
 }
 
 // setupLogging configures the logger based on config
@@ -0,0 +1,301 @@
+// Package annotations keeps user notes about containers ("don't
+// auto-update", "owned by Alice") in the agent's data directory. Notes are
+// keyed by compose service or container name rather than container ID, so
+// they survive a container being recreated and outlive a DockMon database
+// reset.
+package annotations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Limits on what one store holds, so a runaway client can't fill the disk
+const (
+	MaxTargets      = 1000
+	MaxPerTarget    = 64
+	MaxKeyLength    = 128
+	MaxValueLength  = 4096
+	maxTargetLength = 512
+)
+
+// Store key prefixes for the two kinds of target
+const (
+	composeKeyPrefix = "service:"
+	nameKeyPrefix    = "container:"
+)
+
+// Target identifies what an annotation belongs to: a compose service
+// (Project and Service) or, for standalone containers, a container name.
+type Target struct {
+	ContainerName string `json:"container_name,omitempty"`
+	Project       string `json:"project,omitempty"`
+	Service       string `json:"service,omitempty"`
+}
+
+// Key returns the store key for the target. A compose service wins over the
+// container name since compose names containers project-service-N.
+func (t Target) Key() (string, error) {
+	var key string
+	switch {
+	case t.Project != "" && t.Service != "":
+		key = composeKeyPrefix + t.Project + "/" + t.Service
+	case t.Project != "" || t.Service != "":
+		return "", fmt.Errorf("project and service must be given together")
+	case t.ContainerName != "":
+		key = nameKeyPrefix + strings.TrimPrefix(t.ContainerName, "/")
+	default:
+		return "", fmt.Errorf("container_name or project and service is required")
+	}
+	if len(key) > maxTargetLength {
+		return "", fmt.Errorf("target must be at most %d bytes", maxTargetLength)
+	}
+	return key, nil
+}
+
+// canonical drops the fields Key ignores, so stored targets are unambiguous
+func (t Target) canonical() Target {
+	if t.Project != "" && t.Service != "" {
+		return Target{Project: t.Project, Service: t.Service}
+	}
+	return Target{ContainerName: strings.TrimPrefix(t.ContainerName, "/")}
+}
+
+// TargetFromLabels returns the target for a container, preferring its
+// compose service labels over its name.
+func TargetFromLabels(name string, labels map[string]string) Target {
+	project := labels["com.docker.compose.project"]
+	service := labels["com.docker.compose.service"]
+	if project != "" && service != "" {
+		return Target{Project: project, Service: service}
+	}
+	return Target{ContainerName: strings.TrimPrefix(name, "/")}
+}
+
+// Annotation is one stored value
+type Annotation struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Entry is a target with its annotations
+type Entry struct {
+	Target      Target                `json:"target"`
+	Annotations map[string]Annotation `json:"annotations"`
+}
+
+// state is the persisted file
+type state struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Store holds annotations and persists every change. All methods are safe
+// for concurrent use.
+type Store struct {
+	path string
+	log  *logrus.Logger
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// New loads the annotations persisted at path
+func New(path string, log *logrus.Logger) *Store {
+	s := &Store{
+		path:    path,
+		log:     log,
+		now:     time.Now,
+		entries: make(map[string]*Entry),
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from the agent's DataPath
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).Warn("Failed to read annotations file; starting empty")
+		}
+		return s
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.WithError(err).Warn("Annotations file is corrupt; starting empty")
+		return s
+	}
+	for i := range st.Entries {
+		e := st.Entries[i]
+		key, err := e.Target.Key()
+		if err != nil || len(e.Annotations) == 0 {
+			continue
+		}
+		s.entries[key] = &e
+	}
+	return s
+}
+
+// Set merges values into the target's annotations and returns the result.
+// Nothing changes if any key or value is invalid.
+func (s *Store) Set(target Target, values map[string]string) (Entry, error) {
+	key, err := target.Key()
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(values) == 0 {
+		return Entry{}, fmt.Errorf("annotations are required")
+	}
+	for k, v := range values {
+		if k == "" || len(k) > MaxKeyLength {
+			return Entry{}, fmt.Errorf("annotation keys must be 1 to %d bytes", MaxKeyLength)
+		}
+		if len(v) > MaxValueLength {
+			return Entry{}, fmt.Errorf("annotation %q must be at most %d bytes", k, MaxValueLength)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		if len(s.entries) >= MaxTargets {
+			return Entry{}, fmt.Errorf("at most %d targets can be annotated", MaxTargets)
+		}
+		e = &Entry{Target: target.canonical(), Annotations: make(map[string]Annotation)}
+	}
+	added := 0
+	for k := range values {
+		if _, exists := e.Annotations[k]; !exists {
+			added++
+		}
+	}
+	if len(e.Annotations)+added > MaxPerTarget {
+		return Entry{}, fmt.Errorf("at most %d annotations per target", MaxPerTarget)
+	}
+
+	now := s.now().UTC()
+	for k, v := range values {
+		e.Annotations[k] = Annotation{Value: v, UpdatedAt: now}
+	}
+	s.entries[key] = e
+	s.saveLocked()
+	return copyEntry(e), nil
+}
+
+// Get returns the target's annotations, if any
+func (s *Store) Get(target Target) (Entry, bool, error) {
+	key, err := target.Key()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	return copyEntry(e), true, nil
+}
+
+// List returns every annotated target, ordered by key
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, copyEntry(s.entries[key]))
+	}
+	return result
+}
+
+// Delete removes the given keys from the target, or all of its annotations
+// when keys is empty. Returns the number of annotations removed.
+func (s *Store) Delete(target Target, keys []string) (int, error) {
+	key, err := target.Key()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return 0, nil
+	}
+
+	removed := 0
+	if len(keys) == 0 {
+		removed = len(e.Annotations)
+		delete(s.entries, key)
+	} else {
+		for _, k := range keys {
+			if _, exists := e.Annotations[k]; exists {
+				delete(e.Annotations, k)
+				removed++
+			}
+		}
+		if len(e.Annotations) == 0 {
+			delete(s.entries, key)
+		}
+	}
+	if removed > 0 {
+		s.saveLocked()
+	}
+	return removed, nil
+}
+
+func copyEntry(e *Entry) Entry {
+	annotations := make(map[string]Annotation, len(e.Annotations))
+	for k, v := range e.Annotations {
+		annotations[k] = v
+	}
+	return Entry{Target: e.Target, Annotations: annotations}
+}
+
+// saveLocked writes the store atomically via a temp file and rename.
+// Failures are logged; the in-memory state stays authoritative.
+func (s *Store) saveLocked() {
+	if err := s.writeLocked(); err != nil {
+		s.log.WithError(err).Warn("Failed to persist annotations")
+	}
+}
+
+func (s *Store) writeLocked() error {
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	st := state{Entries: make([]Entry, 0, len(keys))}
+	for _, key := range keys {
+		st.Entries = append(st.Entries, *s.entries[key])
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode annotations: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write annotations: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace annotations: %w", err)
+	}
+	return nil
+}
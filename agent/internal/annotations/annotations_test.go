@@ -0,0 +1,100 @@
+package annotations
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestStore_PersistsAcrossReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	s := New(path, quietLogger())
+
+	web := Target{Project: "shop", Service: "web"}
+	if _, err := s.Set(web, map[string]string{"note": "owned by Alice", "auto_update": "false"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Set(Target{ContainerName: "/db"}, map[string]string{"note": "prod"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A recreated container of the same compose service finds its notes
+	reloaded := New(path, quietLogger())
+	target := TargetFromLabels("/shop-web-2", map[string]string{
+		"com.docker.compose.project": "shop",
+		"com.docker.compose.service": "web",
+	})
+	entry, found, err := reloaded.Get(target)
+	if err != nil || !found {
+		t.Fatalf("Get after reload: found=%v err=%v", found, err)
+	}
+	if entry.Annotations["note"].Value != "owned by Alice" || entry.Annotations["auto_update"].Value != "false" {
+		t.Errorf("annotations = %+v", entry.Annotations)
+	}
+
+	entries := reloaded.List()
+	if len(entries) != 2 || entries[0].Target.ContainerName != "db" || entries[1].Target != web {
+		t.Errorf("List() = %+v, want db then shop/web", entries)
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	s := New(path, quietLogger())
+	target := Target{ContainerName: "cache"}
+	if _, err := s.Set(target, map[string]string{"a": "1", "b": "2"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if removed, err := s.Delete(target, []string{"a", "missing"}); err != nil || removed != 1 {
+		t.Errorf("Delete(a) = %d, %v, want 1", removed, err)
+	}
+	if removed, err := s.Delete(target, nil); err != nil || removed != 1 {
+		t.Errorf("Delete(all) = %d, %v, want 1", removed, err)
+	}
+	if _, found, _ := New(path, quietLogger()).Get(target); found {
+		t.Error("target still annotated after deleting everything")
+	}
+}
+
+func TestStore_RejectsInvalid(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "annotations.json"), quietLogger())
+	target := Target{ContainerName: "web"}
+
+	cases := []struct {
+		name   string
+		target Target
+		values map[string]string
+	}{
+		{"no target", Target{}, map[string]string{"a": "1"}},
+		{"half compose target", Target{Project: "shop"}, map[string]string{"a": "1"}},
+		{"no values", target, nil},
+		{"empty key", target, map[string]string{"": "1"}},
+		{"long value", target, map[string]string{"a": strings.Repeat("x", MaxValueLength+1)}},
+	}
+	for _, tc := range cases {
+		if _, err := s.Set(tc.target, tc.values); err == nil {
+			t.Errorf("%s: Set accepted invalid input", tc.name)
+		}
+	}
+
+	full := make(map[string]string, MaxPerTarget)
+	for i := 0; i < MaxPerTarget; i++ {
+		full[strings.Repeat("k", i+1)] = "v"
+	}
+	if _, err := s.Set(target, full); err != nil {
+		t.Fatalf("Set(%d keys): %v", MaxPerTarget, err)
+	}
+	if _, err := s.Set(target, map[string]string{"one_more": "v"}); err == nil {
+		t.Error("Set accepted more than MaxPerTarget annotations")
+	}
+}
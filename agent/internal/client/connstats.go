@@ -0,0 +1,136 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pingRTTSmoothing is the weight of each new ping round trip in the
+// smoothed average (as for TCP's SRTT)
+const pingRTTSmoothing = 0.125
+
+// ConnectionStats is reported in heartbeats so the backend can diagnose
+// flaky connections: how often the agent reconnects and why, how slow the
+// link is, and how much is waiting to be sent or handled. The counters are
+// cumulative for the process.
+type ConnectionStats struct {
+	ConnectedSince       *time.Time `json:"connected_since,omitempty"`
+	Connects             uint64     `json:"connects"`
+	Reconnects           uint64     `json:"reconnects"`      // Connects after the first
+	FailedAttempts       uint64     `json:"failed_attempts"` // Connects or registrations that failed
+	LastDisconnectReason string     `json:"last_disconnect_reason,omitempty"`
+	LastDisconnectAt     *time.Time `json:"last_disconnect_at,omitempty"`
+	PingRTTMs            float64    `json:"ping_rtt_ms,omitempty"`     // Last ping to pong
+	PingRTTAvgMs         float64    `json:"ping_rtt_avg_ms,omitempty"` // Smoothed
+	BytesSent            uint64     `json:"bytes_sent"`
+	BytesReceived        uint64     `json:"bytes_received"`
+	OutboundQueued       int        `json:"outbound_queued"`
+	PendingCommands      int64      `json:"pending_commands"` // Received, not yet answered
+}
+
+// connectionStats tracks the connection's lifecycle, ping latency and
+// traffic. Byte and command counters are atomic since they're updated on
+// every frame.
+type connectionStats struct {
+	bytesSent       atomic.Uint64
+	bytesReceived   atomic.Uint64
+	pendingCommands atomic.Int64
+
+	mu                   sync.Mutex
+	connectedSince       time.Time
+	connects             uint64
+	failedAttempts       uint64
+	lastDisconnectReason string
+	lastDisconnectAt     time.Time
+	pingSentAt           time.Time
+	pingRTT              time.Duration
+	pingRTTAvg           time.Duration
+}
+
+// connected records a successful connection and registration
+func (s *connectionStats) connected(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connects++
+	s.connectedSince = now
+	s.pingSentAt = time.Time{}
+}
+
+// connectFailed records a failed connection attempt
+func (s *connectionStats) connectFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedAttempts++
+}
+
+// disconnected records why the connection ended
+func (s *connectionStats) disconnected(now time.Time, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connectedSince = time.Time{}
+	s.lastDisconnectReason = reason
+	s.lastDisconnectAt = now
+}
+
+// pingSent records when a ping was written, unless one is still unanswered
+func (s *connectionStats) pingSent(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pingSentAt.IsZero() {
+		s.pingSentAt = now
+	}
+}
+
+// pongReceived measures the round trip of the outstanding ping
+func (s *connectionStats) pongReceived(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pingSentAt.IsZero() {
+		return
+	}
+	rtt := now.Sub(s.pingSentAt)
+	s.pingSentAt = time.Time{}
+	s.pingRTT = rtt
+	if s.pingRTTAvg == 0 {
+		s.pingRTTAvg = rtt
+	} else {
+		s.pingRTTAvg += time.Duration(pingRTTSmoothing * float64(rtt-s.pingRTTAvg))
+	}
+}
+
+// Stats returns the current statistics; outboundQueued is the outbound
+// queue's depth
+func (s *connectionStats) Stats(outboundQueued int) ConnectionStats {
+	stats := ConnectionStats{
+		BytesSent:       s.bytesSent.Load(),
+		BytesReceived:   s.bytesReceived.Load(),
+		OutboundQueued:  outboundQueued,
+		PendingCommands: s.pendingCommands.Load(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats.Connects = s.connects
+	if s.connects > 1 {
+		stats.Reconnects = s.connects - 1
+	}
+	stats.FailedAttempts = s.failedAttempts
+	stats.LastDisconnectReason = s.lastDisconnectReason
+	if !s.connectedSince.IsZero() {
+		since := s.connectedSince.UTC()
+		stats.ConnectedSince = &since
+	}
+	if !s.lastDisconnectAt.IsZero() {
+		at := s.lastDisconnectAt.UTC()
+		stats.LastDisconnectAt = &at
+	}
+	stats.PingRTTMs = durationMs(s.pingRTT)
+	stats.PingRTTAvgMs = durationMs(s.pingRTTAvg)
+	return stats
+}
+
+// durationMs converts d to milliseconds with microsecond precision
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
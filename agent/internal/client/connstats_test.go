@@ -0,0 +1,48 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnectionStats(t *testing.T) {
+	var s connectionStats
+	start := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	s.connectFailed()
+	s.connected(start)
+	s.bytesSent.Add(100)
+	s.bytesReceived.Add(40)
+
+	// Only the first of overlapping pings is timed
+	s.pingSent(start)
+	s.pingSent(start.Add(5 * time.Millisecond))
+	s.pongReceived(start.Add(20 * time.Millisecond))
+	s.pongReceived(start.Add(time.Second)) // Unsolicited
+	s.pingSent(start.Add(30 * time.Second))
+	s.pongReceived(start.Add(30*time.Second + 100*time.Millisecond))
+
+	stats := s.Stats(3)
+	if stats.Connects != 1 || stats.Reconnects != 0 || stats.FailedAttempts != 1 {
+		t.Errorf("connect counts = %+v", stats)
+	}
+	if stats.ConnectedSince == nil || !stats.ConnectedSince.Equal(start) {
+		t.Errorf("connected since = %v", stats.ConnectedSince)
+	}
+	if stats.PingRTTMs != 100 || stats.PingRTTAvgMs != 30 {
+		t.Errorf("ping rtt = %v, avg %v", stats.PingRTTMs, stats.PingRTTAvgMs)
+	}
+	if stats.BytesSent != 100 || stats.BytesReceived != 40 || stats.OutboundQueued != 3 {
+		t.Errorf("traffic = %+v", stats)
+	}
+
+	s.disconnected(start.Add(time.Minute), "read error: i/o timeout")
+	s.connected(start.Add(2 * time.Minute))
+	stats = s.Stats(0)
+	if stats.Reconnects != 1 || stats.LastDisconnectReason != "read error: i/o timeout" {
+		t.Errorf("after reconnect = %+v", stats)
+	}
+	if stats.LastDisconnectAt == nil || !stats.LastDisconnectAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("last disconnect at = %v", stats.LastDisconnectAt)
+	}
+}
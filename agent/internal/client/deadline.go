@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/protocol"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+)
+
+const (
+	// defaultCommandTimeout bounds commands without their own entry in
+	// commandTimeouts, so a hung Docker daemon can't block a handler forever
+	defaultCommandTimeout = time.Minute
+	// maxCommandTimeout caps deadline_seconds overrides
+	maxCommandTimeout = time.Hour
+	// stopGraceTimeout is the stop timeout Docker uses when none is given
+	stopGraceTimeout = 10 * time.Second
+	// stopInspectTimeout bounds the inspect that finds a container's own
+	// StopTimeout before a stop
+	stopInspectTimeout = 10 * time.Second
+)
+
+// commandTimeouts are the deadlines of commands and container operations
+// that legitimately take longer than defaultCommandTimeout. Commands that
+// run detached (deploy_compose, backup_volume, ...) respond immediately
+// and aren't bounded by these.
+var commandTimeouts = map[string]time.Duration{
+	"check_updates":           10 * time.Minute,
+	"check_container_updates": 5 * time.Minute,
+	"container_disk_usage":    5 * time.Minute,
+	"diff_container":          5 * time.Minute,
+	"volume_size":             10 * time.Minute,
+	"remove_image":            5 * time.Minute,
+	"prune_images":            10 * time.Minute,
+	"prune_networks":          5 * time.Minute,
+	"prune_volumes":           10 * time.Minute,
+	"scan_compose_dirs":       5 * time.Minute,
+	"docker_api":              5 * time.Minute,
+	"net_test":                15 * time.Minute,
+	"get_logs":                2 * time.Minute,
+}
+
+// commandTimeout returns the deadline for an operation: deadline_seconds
+// from the payload if given, else its commandTimeouts entry, else the
+// default. extra is added to the default (e.g. a stop's grace period).
+func commandTimeout(op string, payload interface{}, extra time.Duration) time.Duration {
+	if fields, ok := payload.(map[string]interface{}); ok {
+		if seconds, ok := fields["deadline_seconds"].(float64); ok && seconds > 0 {
+			timeout := time.Duration(seconds * float64(time.Second))
+			if timeout > maxCommandTimeout {
+				timeout = maxCommandTimeout
+			}
+			return timeout
+		}
+	}
+	timeout, ok := commandTimeouts[op]
+	if !ok {
+		timeout = defaultCommandTimeout
+	}
+	return timeout + extra
+}
+
+// stopGrace returns how long a stop or restart may take on top of the
+// operation's deadline, resolving the stop timeout as the operation does: a
+// stop uses its stop_strategy, then the payload timeout, then the
+// container's StopTimeout, including any escalation waits; a restart passes
+// its timeout to the daemon.
+func (c *WebSocketClient) stopGrace(ctx context.Context, action, containerID string, payload map[string]interface{}) time.Duration {
+	defaultTimeout := int(stopGraceTimeout / time.Second)
+	var strategy *sharedDocker.StopStrategy
+	if action == "stop" {
+		var err error
+		if strategy, err = stopStrategyFromPayload(payload); err != nil {
+			return stopGraceTimeout // The stop fails validation right away
+		}
+	}
+	if t, ok := payload["timeout"].(float64); ok {
+		strategy = strategy.WithTimeout(int(t))
+	} else if action == "restart" {
+		strategy = strategy.WithTimeout(defaultTimeout)
+	}
+
+	var stopTimeout *int
+	if strategy == nil || strategy.TimeoutSeconds == nil {
+		inspectCtx, cancel := context.WithTimeout(ctx, stopInspectTimeout)
+		if inspect, err := c.docker.InspectContainer(inspectCtx, containerID); err == nil && inspect.Config != nil {
+			stopTimeout = inspect.Config.StopTimeout
+		}
+		cancel()
+	}
+
+	grace := strategy.MaxStopDuration(stopTimeout, defaultTimeout)
+	if grace < 0 {
+		return maxCommandTimeout // Waits for the container indefinitely
+	}
+	return grace
+}
+
+// OperationStats is reported in heartbeats so the backend can see
+// operations the Docker daemon never answered
+type OperationStats struct {
+	Hung     int64  `json:"hung"`      // Past their deadline and still running
+	TimedOut uint64 `json:"timed_out"` // Cumulative for the process
+}
+
+// commandDeadlines runs operations under a deadline. An operation that
+// misses it is answered with a TIMEOUT error right away; its goroutine is
+// left to finish on its own and counted as hung until it does.
+type commandDeadlines struct {
+	hung     atomic.Int64
+	timedOut atomic.Uint64
+}
+
+// Stats returns the hung and timed-out operation counts
+func (d *commandDeadlines) Stats() OperationStats {
+	return OperationStats{Hung: d.hung.Load(), TimedOut: d.timedOut.Load()}
+}
+
+// run calls fn with a context that expires after timeout. If ctx itself
+// ends first (the connection closed), run waits for fn as before.
+func (d *commandDeadlines) run(ctx context.Context, op string, timeout time.Duration, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	opCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := fn(opCtx)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case out := <-done:
+		cancel()
+		// fn gave up on its own because the deadline passed
+		if out.err != nil && ctx.Err() == nil && errors.Is(opCtx.Err(), context.DeadlineExceeded) {
+			d.timedOut.Add(1)
+			return nil, timeoutError(op, timeout)
+		}
+		return out.result, out.err
+	case <-opCtx.Done():
+	}
+
+	if ctx.Err() != nil {
+		out := <-done
+		cancel()
+		return out.result, out.err
+	}
+
+	d.timedOut.Add(1)
+	d.hung.Add(1)
+	go func() {
+		<-done
+		cancel()
+		d.hung.Add(-1)
+	}()
+	return nil, timeoutError(op, timeout)
+}
+
+func timeoutError(op string, timeout time.Duration) error {
+	return fmt.Errorf("%w: %s did not finish within %s", protocol.ErrTimeout, op, timeout)
+}
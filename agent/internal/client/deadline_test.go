@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/protocol"
+)
+
+func TestCommandTimeout(t *testing.T) {
+	if got := commandTimeout("list_containers", nil, 0); got != defaultCommandTimeout {
+		t.Errorf("default = %s", got)
+	}
+	if got := commandTimeout("prune_images", map[string]interface{}{}, 0); got != commandTimeouts["prune_images"] {
+		t.Errorf("per-command = %s", got)
+	}
+	if got := commandTimeout("stop", nil, 30*time.Second); got != defaultCommandTimeout+30*time.Second {
+		t.Errorf("with grace period = %s", got)
+	}
+	if got := commandTimeout("prune_images", map[string]interface{}{"deadline_seconds": 2.5}, time.Minute); got != 2500*time.Millisecond {
+		t.Errorf("override = %s", got)
+	}
+	if got := commandTimeout("prune_images", map[string]interface{}{"deadline_seconds": 1e9}, 0); got != maxCommandTimeout {
+		t.Errorf("capped override = %s", got)
+	}
+}
+
+func TestStopGrace(t *testing.T) {
+	c := &WebSocketClient{}
+	ctx := context.Background()
+	// Explicit timeouts need no inspect of the container
+	stop := map[string]interface{}{
+		"timeout": 30.0,
+		"stop_strategy": map[string]interface{}{
+			"escalation": []interface{}{map[string]interface{}{"signal": "SIGINT", "wait_seconds": 60.0}},
+		},
+	}
+	if got := c.stopGrace(ctx, "stop", "abc", stop); got < 90*time.Second {
+		t.Errorf("stop with escalation = %s, want at least the 30s timeout plus the 60s SIGINT wait", got)
+	}
+	if got := c.stopGrace(ctx, "restart", "abc", map[string]interface{}{"timeout": 300.0}); got < 300*time.Second {
+		t.Errorf("restart = %s, want at least its 300s timeout", got)
+	}
+	if got := c.stopGrace(ctx, "restart", "abc", map[string]interface{}{}); got < stopGraceTimeout || got > time.Minute {
+		t.Errorf("restart without timeout = %s, want the 10s default plus the kill wait", got)
+	}
+}
+
+func TestCommandDeadlines_Run(t *testing.T) {
+	var d commandDeadlines
+	ctx := context.Background()
+
+	result, err := d.run(ctx, "fast", time.Second, func(context.Context) (interface{}, error) {
+		return "ok", nil
+	})
+	if result != "ok" || err != nil {
+		t.Fatalf("fast op = %v, %v", result, err)
+	}
+
+	// An operation that ignores its context is abandoned and counted as hung
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	_, err = d.run(ctx, "hang", 10*time.Millisecond, func(context.Context) (interface{}, error) {
+		defer close(finished)
+		<-release
+		return nil, nil
+	})
+	if !errors.Is(err, protocol.ErrTimeout) || protocol.ErrorCode(err) != protocol.ErrorCodeTimeout {
+		t.Fatalf("hung op error = %v", err)
+	}
+	if stats := d.Stats(); stats.Hung != 1 || stats.TimedOut != 1 {
+		t.Errorf("stats while hung = %+v", stats)
+	}
+	close(release)
+	<-finished
+	waitFor(t, func() bool { return d.Stats().Hung == 0 })
+
+	// One that gives up when its context expires is a timeout, not a hang
+	_, err = d.run(ctx, "docker", 10*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, protocol.ErrTimeout) {
+		t.Errorf("expired op error = %v", err)
+	}
+	if stats := d.Stats(); stats.TimedOut != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Closing the connection isn't a timeout
+	connCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = d.run(connCtx, "closed", time.Second, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || d.Stats().TimedOut != 2 {
+		t.Errorf("canceled op error = %v, stats = %+v", err, d.Stats())
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
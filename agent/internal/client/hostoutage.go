@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+)
+
+const (
+	// daemonPingTimeout bounds the ping that tells a daemon that went away
+	// (host shutting down or rebooting) from an event stream that broke
+	daemonPingTimeout = 5 * time.Second
+	// daemonPollInterval is how often a down daemon is pinged
+	daemonPollInterval = 5 * time.Second
+	// eventStreamRetryDelay is the pause before reopening a broken event
+	// stream while the daemon is up
+	eventStreamRetryDelay = time.Second
+)
+
+// recoverEventStream handles the event stream ending with err. If the
+// daemon still answers, the stream is reopened after a short delay.
+// Otherwise the host is reported offline, stats streams are stopped, and
+// the daemon is polled until it's back; the host is then reported online
+// with the downtime and stats collection restarts. Returns false if the
+// connection closed or the agent is stopping meanwhile.
+func (c *WebSocketClient) recoverEventStream(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if c.pingDaemon(ctx) == nil {
+		c.log.WithError(err).Warn("Event stream error, reopening")
+		return c.sleep(ctx, eventStreamRetryDelay)
+	}
+
+	offlineSince := time.Now()
+	c.log.WithError(err).Warn("Docker daemon is unreachable; host is offline until it answers again")
+	c.statsHandler.SetDaemonOffline(true)
+	c.publishActivity(activity.HostOffline(err.Error(), offlineSince))
+
+	for c.pingDaemon(ctx) != nil {
+		if !c.sleep(ctx, daemonPollInterval) {
+			return false
+		}
+	}
+
+	now := time.Now()
+	c.log.Infof("Docker daemon is reachable again after %s", now.Sub(offlineSince).Round(time.Second))
+	c.publishActivity(activity.HostOnline(offlineSince, now))
+	c.statsHandler.SetDaemonOffline(false)
+	if err := c.statsHandler.StartStatsCollection(ctx); err != nil {
+		c.log.WithError(err).Warn("Failed to restart stats collection")
+	}
+	// Containers may have stopped, restarted or gone during the outage
+	c.inventoryHandler.Notify()
+	return true
+}
+
+// pingDaemon checks that the Docker daemon is answering
+func (c *WebSocketClient) pingDaemon(ctx context.Context) error {
+	pingCtx, cancel := context.WithTimeout(ctx, daemonPingTimeout)
+	defer cancel()
+	return c.docker.Ping(pingCtx)
+}
+
+// sleep waits for d; false if the connection closed or the agent is
+// stopping first
+func (c *WebSocketClient) sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.stopChan:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// publishActivity sends an event to the stats-service activity stream, if
+// one is configured
+func (c *WebSocketClient) publishActivity(event activity.Event) {
+	if c.activity != nil {
+		c.activity.Publish(event)
+	}
+}
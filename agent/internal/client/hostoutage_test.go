@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-agent/internal/handlers"
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []activity.Event
+}
+
+func (p *recordingPublisher) Publish(event activity.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+func TestRecoverEventStream_DaemonOutage(t *testing.T) {
+	// The daemon fails the first ping (a HEAD and its GET fallback), then
+	// is back
+	var pings atomic.Int32
+	var listed atomic.Bool
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			if pings.Add(1) <= 2 {
+				http.Error(w, "shutting down", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("API-Version", "1.45")
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			listed.Store(true)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("[]"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+
+	log := logrus.New()
+	cfg := &config.Config{DockerHost: "tcp://" + strings.TrimPrefix(daemon.URL, "http://")}
+	dockerClient, err := docker.NewClient(cfg, log, client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &recordingPublisher{}
+	c := &WebSocketClient{
+		docker:           dockerClient,
+		log:              log,
+		statsHandler:     handlers.NewStatsHandler(dockerClient, log, func(string, interface{}) error { return nil }),
+		inventoryHandler: handlers.NewInventoryHandler(dockerClient, log, nil),
+		activity:         publisher,
+		stopChan:         make(chan struct{}),
+	}
+
+	if !c.recoverEventStream(context.Background(), errors.New("unexpected EOF")) {
+		t.Fatal("recoverEventStream gave up")
+	}
+	if len(publisher.events) != 2 || publisher.events[0].Action != activity.ActionHostOffline || publisher.events[1].Action != activity.ActionHostOnline {
+		t.Fatalf("events = %+v", publisher.events)
+	}
+	if publisher.events[0].Attributes[activity.AttrReason] != "unexpected EOF" {
+		t.Errorf("offline attributes = %v", publisher.events[0].Attributes)
+	}
+	if _, ok := publisher.events[1].Attributes[activity.AttrDowntimeSeconds]; !ok {
+		t.Errorf("online attributes = %v", publisher.events[1].Attributes)
+	}
+	if !listed.Load() {
+		t.Error("stats collection wasn't restarted")
+	}
+
+	// Nothing is reported once the connection has closed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.recoverEventStream(ctx, context.Canceled) {
+		t.Error("recovered after the connection closed")
+	}
+	if len(publisher.events) != 2 {
+		t.Errorf("unexpected events %+v", publisher.events[2:])
+	}
+}
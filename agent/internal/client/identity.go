@@ -0,0 +1,84 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// identityFile holds the IDs last assigned by the backend, next to the
+// permanent token in DataPath
+const identityFile = "identity.json"
+
+// agentIdentity is the agent and host ID the backend assigned at the last
+// successful registration. It is sent back as previous_identity when
+// registering, so a backend whose database was restored or migrated can
+// hand out the same IDs again instead of orphaning the host's history.
+// Proof is the backend's signature over the IDs; without it they aren't
+// given back.
+type agentIdentity struct {
+	AgentID      string    `json:"agent_id"`
+	HostID       string    `json:"host_id"`
+	Proof        string    `json:"proof,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// loadIdentity reads the persisted identity; a missing file is not an error
+func loadIdentity(dataPath string) (*agentIdentity, error) {
+	data, err := os.ReadFile(filepath.Join(dataPath, identityFile)) // #nosec G304 -- path is the agent's DataPath
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+	var identity agentIdentity
+	if err := json.Unmarshal(data, &identity); err != nil {
+		return nil, fmt.Errorf("failed to decode identity: %w", err)
+	}
+	if identity.AgentID == "" && identity.HostID == "" {
+		return nil, nil
+	}
+	return &identity, nil
+}
+
+// saveIdentity writes the identity atomically with owner-only permissions
+func saveIdentity(dataPath string, identity agentIdentity) error {
+	data, err := json.MarshalIndent(identity, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode identity: %w", err)
+	}
+	path := filepath.Join(dataPath, identityFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace identity: %w", err)
+	}
+	return nil
+}
+
+// previousIdentityPayload is the previous_identity field of the
+// registration message
+func (i *agentIdentity) previousIdentityPayload() map[string]string {
+	payload := map[string]string{
+		"agent_id": i.AgentID,
+		"host_id":  i.HostID,
+	}
+	if i.Proof != "" {
+		payload["proof"] = i.Proof
+	}
+	return payload
+}
+
+// previousIdentityRejected reports whether a registration error is an older
+// backend refusing the previous_identity field it doesn't know
+func previousIdentityRejected(errMsg string) bool {
+	return strings.Contains(errMsg, "previous_identity")
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestIdentityRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := loadIdentity(dir)
+	if err != nil || identity != nil {
+		t.Fatalf("loadIdentity(empty dir) = %+v, %v; want nil, nil", identity, err)
+	}
+
+	if err := saveIdentity(dir, agentIdentity{AgentID: "agent-1", HostID: "host-1"}); err != nil {
+		t.Fatal(err)
+	}
+	identity, err = loadIdentity(dir)
+	if err != nil || identity == nil || identity.AgentID != "agent-1" || identity.HostID != "host-1" {
+		t.Errorf("loadIdentity() = %+v, %v", identity, err)
+	}
+}
+
+func TestRememberIdentity(t *testing.T) {
+	dir := t.TempDir()
+	c := &WebSocketClient{
+		cfg:      &config.Config{DataPath: dir},
+		log:      logrus.New(),
+		identity: &agentIdentity{AgentID: "agent-1", HostID: "host-1"},
+	}
+
+	c.rememberIdentity(map[string]interface{}{
+		"identity_reassigned": map[string]interface{}{"previous_host_id": "host-1", "reason": "host ID in use"},
+	}, "agent-1", "host-2")
+
+	identity, err := loadIdentity(dir)
+	if err != nil || identity == nil || identity.HostID != "host-2" || c.identity.HostID != "host-2" {
+		t.Errorf("identity after reassignment = %+v (client %+v), %v", identity, c.identity, err)
+	}
+
+	// The proof is stored once and sent back with the IDs
+	c.rememberIdentity(map[string]interface{}{"identity_proof": "proof-1"}, "agent-1", "host-2")
+	c.rememberIdentity(map[string]interface{}{"identity_proof": "proof-2"}, "agent-1", "host-2")
+	identity, err = loadIdentity(dir)
+	if err != nil || identity == nil || identity.Proof != "proof-1" {
+		t.Errorf("identity after proof = %+v, %v; want proof-1 kept", identity, err)
+	}
+	if got := c.identity.previousIdentityPayload()["proof"]; got != "proof-1" {
+		t.Errorf("previous_identity proof = %q, want proof-1", got)
+	}
+}
+
+func TestPreviousIdentityRejected(t *testing.T) {
+	if !previousIdentityRejected("Invalid registration data: Extra inputs are not permitted (field: previous_identity)") {
+		t.Error("older backend rejection not detected")
+	}
+	if previousIdentityRejected("Invalid registration token") {
+		t.Error("unrelated error treated as a previous_identity rejection")
+	}
+}
@@ -0,0 +1,19 @@
+package client
+
+import (
+	"context"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// pruneImagesWithPolicy runs an on-demand policy-based prune and announces
+// the result, as scheduled prune_images jobs do
+func (c *WebSocketClient) pruneImagesWithPolicy(ctx context.Context, policy sharedDocker.ImagePrunePolicy) (*sharedDocker.ImagePruneReport, error) {
+	report, err := c.docker.PruneImagesWithPolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	c.publishActivity(activity.ImagesPruned(report.RemovedCount, len(report.Failed), report.SpaceReclaimed, report.DryRun))
+	return report, nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/maintenance"
+	"github.com/sirupsen/logrus"
+)
+
+// maintenanceRequest is the body of set_maintenance
+type maintenanceRequest struct {
+	Enabled         bool   `json:"enabled"`
+	Reason          string `json:"reason,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"` // 0 = maintenance.DefaultDuration
+}
+
+// maintenanceStatus is the reply to set_maintenance and get_maintenance
+type maintenanceStatus struct {
+	Enabled bool                `json:"enabled"`
+	Window  *maintenance.Window `json:"window,omitempty"`
+}
+
+// maintenanceFile returns where the active window is persisted. It has to
+// survive the agent restarting, since a host reboot is the usual reason for
+// the window.
+func (c *WebSocketClient) maintenanceFile() string {
+	return filepath.Join(c.cfg.DataPath, "maintenance.json")
+}
+
+// loadMaintenance restores a window persisted before the agent restarted
+func (c *WebSocketClient) loadMaintenance() {
+	data, err := os.ReadFile(c.maintenanceFile()) // #nosec G304 -- path is derived from the agent's DataPath
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.log.WithError(err).Warn("Failed to read maintenance file")
+		}
+		return
+	}
+	var w maintenance.Window
+	if err := json.Unmarshal(data, &w); err != nil {
+		c.log.WithError(err).Warn("Maintenance file is corrupt; ignoring")
+		return
+	}
+	if c.maintenance.Restore(w) {
+		c.log.WithField("until", w.Until).Info("Host is in maintenance mode")
+	} else {
+		_ = os.Remove(c.maintenanceFile())
+	}
+}
+
+// setMaintenance starts, extends or ends the host's maintenance window
+func (c *WebSocketClient) setMaintenance(req maintenanceRequest) (maintenanceStatus, error) {
+	duration := time.Duration(req.DurationSeconds) * time.Second
+	if err := maintenance.ValidateDuration(duration); err != nil {
+		return maintenanceStatus{}, err
+	}
+
+	if !req.Enabled {
+		if w, ok := c.maintenance.Disable(); ok {
+			c.log.WithField("muted_events", w.Muted).Info("Maintenance mode ended")
+			// Container state changed without events reaching DockMon
+			c.inventoryHandler.Notify()
+		}
+		if err := os.Remove(c.maintenanceFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.log.WithError(err).Warn("Failed to remove maintenance file")
+		}
+		return maintenanceStatus{}, nil
+	}
+
+	w := c.maintenance.Enable(req.Reason, duration)
+	c.log.WithFields(logrus.Fields{
+		"reason": w.Reason,
+		"until":  w.Until,
+	}).Info("Maintenance mode started")
+	if err := c.saveMaintenance(w); err != nil {
+		// The window still applies until the agent restarts
+		c.log.WithError(err).Warn("Failed to persist maintenance window")
+	}
+	return maintenanceStatus{Enabled: true, Window: &w}, nil
+}
+
+// maintenanceState returns the active window, if any
+func (c *WebSocketClient) maintenanceState() maintenanceStatus {
+	if w, ok := c.maintenance.Active(); ok {
+		return maintenanceStatus{Enabled: true, Window: &w}
+	}
+	return maintenanceStatus{}
+}
+
+func (c *WebSocketClient) saveMaintenance(w maintenance.Window) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance window: %w", err)
+	}
+	path := c.maintenanceFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write maintenance file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace maintenance file: %w", err)
+	}
+	return nil
+}
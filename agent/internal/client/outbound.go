@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// outboundQueueSize bounds the messages waiting for the writer
+	outboundQueueSize = 1024
+	// outboundSendTimeout is how long a sender waits for room in a full
+	// queue before its message is dropped (the old per-write deadline)
+	outboundSendTimeout = 10 * time.Second
+	// outboundWriteTimeout is the write deadline for each frame
+	outboundWriteTimeout = 10 * time.Second
+)
+
+var (
+	errNotConnected      = errors.New("connection not established")
+	errOutboundQueueFull = errors.New("outbound queue full, message dropped")
+	// errFeatureNotNegotiated rejects events the backend cannot parse
+	errFeatureNotNegotiated = errors.New("feature not negotiated with DockMon")
+)
+
+// outboundFrame is one WebSocket frame waiting to be written
+type outboundFrame struct {
+	messageType int
+	data        []byte
+	// key coalesces frames: a queued frame with the same key is replaced
+	// instead of queuing another. Empty for frames that must all be sent.
+	key string
+}
+
+// OutboundStats is reported in heartbeats so the backend can see when the
+// agent is shedding messages
+type OutboundStats struct {
+	Queued    int    `json:"queued"`
+	Dropped   uint64 `json:"dropped"`
+	Coalesced uint64 `json:"coalesced"`
+}
+
+// outboundQueue decouples senders from the connection. A single writer
+// drains it: pings and heartbeats go first, so a burst of events cannot
+// delay them past the server's read deadline; stats replace their own
+// unsent predecessor; everything else waits for room, then is dropped.
+//
+// The queue is opened for each connection and closed when it ends; the
+// counters are cumulative for the process.
+type outboundQueue struct {
+	mu       sync.Mutex
+	open     bool
+	priority []*outboundFrame
+	normal   []*outboundFrame
+	pending  map[string]*outboundFrame // key -> unsent frame in normal
+	size     int
+	timeout  time.Duration
+
+	ready   chan struct{} // signalled when a frame is queued
+	space   chan struct{} // closed when room frees up or the queue closes
+	waiters int
+
+	dropped   uint64
+	coalesced uint64
+}
+
+func newOutboundQueue(size int, timeout time.Duration) *outboundQueue {
+	return &outboundQueue{
+		size:    size,
+		timeout: timeout,
+		pending: make(map[string]*outboundFrame),
+		ready:   make(chan struct{}, 1),
+		space:   make(chan struct{}),
+	}
+}
+
+// Open discards anything left from a previous connection and accepts frames
+func (q *outboundQueue) Open() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.open = true
+	q.reset()
+}
+
+// Close rejects further frames and wakes blocked senders. Unsent normal
+// frames count as dropped.
+func (q *outboundQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.open {
+		return
+	}
+	q.open = false
+	q.dropped += uint64(len(q.normal))
+	q.reset()
+	q.wakeSenders()
+}
+
+func (q *outboundQueue) reset() {
+	q.priority = nil
+	q.normal = nil
+	q.pending = make(map[string]*outboundFrame)
+	select {
+	case <-q.ready:
+	default:
+	}
+}
+
+// Push queues a frame. Priority frames are never dropped or coalesced.
+// A full queue drops a keyed frame at once and makes others wait up to the
+// queue's timeout.
+func (q *outboundQueue) Push(frame *outboundFrame, priority bool) error {
+	var timer *time.Timer
+	for {
+		q.mu.Lock()
+		if !q.open {
+			q.mu.Unlock()
+			return errNotConnected
+		}
+		if priority {
+			q.priority = append(q.priority, frame)
+			q.signal()
+			q.mu.Unlock()
+			return nil
+		}
+		if frame.key != "" {
+			if queued, ok := q.pending[frame.key]; ok {
+				queued.data = frame.data
+				q.coalesced++
+				q.mu.Unlock()
+				return nil
+			}
+		}
+		if len(q.normal) < q.size {
+			q.normal = append(q.normal, frame)
+			if frame.key != "" {
+				q.pending[frame.key] = frame
+			}
+			q.signal()
+			q.mu.Unlock()
+			return nil
+		}
+		if frame.key != "" {
+			q.dropped++
+			q.mu.Unlock()
+			return errOutboundQueueFull
+		}
+
+		space := q.space
+		q.waiters++
+		q.mu.Unlock()
+
+		if timer == nil {
+			timer = time.NewTimer(q.timeout)
+			defer timer.Stop()
+		}
+		select {
+		case <-space:
+			q.mu.Lock()
+			q.waiters--
+			q.mu.Unlock()
+		case <-timer.C:
+			q.mu.Lock()
+			q.waiters--
+			q.dropped++
+			q.mu.Unlock()
+			return errOutboundQueueFull
+		}
+	}
+}
+
+// Next blocks until a frame is queued or ctx is done
+func (q *outboundQueue) Next(ctx context.Context) (*outboundFrame, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.priority) > 0 {
+			frame := q.priority[0]
+			q.priority[0] = nil
+			q.priority = q.priority[1:]
+			q.mu.Unlock()
+			return frame, true
+		}
+		if len(q.normal) > 0 {
+			frame := q.normal[0]
+			q.normal[0] = nil
+			q.normal = q.normal[1:]
+			if frame.key != "" {
+				delete(q.pending, frame.key)
+			}
+			if q.waiters > 0 {
+				q.wakeSenders()
+			}
+			q.mu.Unlock()
+			return frame, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-q.ready:
+		}
+	}
+}
+
+// Stats returns the queue depth and cumulative counters
+func (q *outboundQueue) Stats() OutboundStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return OutboundStats{
+		Queued:    len(q.priority) + len(q.normal),
+		Dropped:   q.dropped,
+		Coalesced: q.coalesced,
+	}
+}
+
+// signal wakes the writer; must hold mu
+func (q *outboundQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// wakeSenders releases senders waiting for room; must hold mu
+func (q *outboundQueue) wakeSenders() {
+	close(q.space)
+	q.space = make(chan struct{})
+}
+
+// coalesceKey returns the key under which an unsent message may be replaced
+// by a newer one: per-container stats and host stats are snapshots, so only
+// the latest matters.
+func coalesceKey(msgType string, payload interface{}) string {
+	switch msgType {
+	case "container_stats":
+		if m, ok := payload.(map[string]interface{}); ok {
+			if id, ok := m["container_id"].(string); ok && id != "" {
+				return "container_stats:" + id
+			}
+		}
+	case "stats":
+		return "host_stats"
+	}
+	return ""
+}
+
+// writeLoop is the connection's only writer. It stops when ctx is done or a
+// write fails; on failure it closes the connection so the read loop returns
+// and the client reconnects.
+func (c *WebSocketClient) writeLoop(ctx context.Context) {
+	for {
+		frame, ok := c.outbound.Next(ctx)
+		if !ok {
+			return
+		}
+		if err := c.writeFrame(frame); err != nil {
+			c.log.WithError(err).Warn("Failed to write to DockMon, closing connection")
+			c.outbound.Close()
+			c.connMu.Lock()
+			if c.conn != nil {
+				if err := c.conn.Close(); err != nil {
+					c.log.WithError(err).Debug("Failed to close connection")
+				}
+			}
+			c.connMu.Unlock()
+			return
+		}
+	}
+}
+
+// writeFrame writes one frame under the connection lock
+func (c *WebSocketClient) writeFrame(frame *outboundFrame) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.conn == nil {
+		return errNotConnected
+	}
+
+	// Set write deadline to prevent blocking indefinitely on slow/congested networks
+	if err := c.conn.SetWriteDeadline(time.Now().Add(outboundWriteTimeout)); err != nil {
+		c.log.WithError(err).Debug("Failed to set write deadline")
+	}
+	err := c.conn.WriteMessage(frame.messageType, frame.data)
+	if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
+		c.log.WithError(err).Debug("Failed to clear write deadline")
+	}
+	if err == nil {
+		c.connStats.bytesSent.Add(uint64(len(frame.data)))
+		if frame.messageType == websocket.PingMessage {
+			c.connStats.pingSent(time.Now())
+			c.log.Debug("Sent ping to server")
+		}
+	}
+	return err
+}
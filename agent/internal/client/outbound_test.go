@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/protocol"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+func textFrame(data, key string) *outboundFrame {
+	return &outboundFrame{messageType: websocket.TextMessage, data: []byte(data), key: key}
+}
+
+func nextData(t *testing.T, q *outboundQueue) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	frame, ok := q.Next(ctx)
+	if !ok {
+		t.Fatal("Next returned no frame")
+	}
+	if frame.messageType == websocket.PingMessage {
+		return "ping"
+	}
+	return string(frame.data)
+}
+
+func TestOutboundQueue_PriorityFirst(t *testing.T) {
+	q := newOutboundQueue(10, time.Second)
+	q.Open()
+
+	for _, f := range []*outboundFrame{textFrame("a", ""), textFrame("b", "")} {
+		if err := q.Push(f, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Push(&outboundFrame{messageType: websocket.PingMessage}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"ping", "a", "b"} {
+		if got := nextData(t, q); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestOutboundQueue_CoalescesKeyedFrames(t *testing.T) {
+	q := newOutboundQueue(10, time.Second)
+	q.Open()
+
+	_ = q.Push(textFrame("stats-1", "container_stats:abc"), false)
+	_ = q.Push(textFrame("event", ""), false)
+	_ = q.Push(textFrame("stats-2", "container_stats:abc"), false)
+	_ = q.Push(textFrame("other", "container_stats:def"), false)
+
+	// The newer snapshot takes the older one's place in line
+	for _, want := range []string{"stats-2", "event", "other"} {
+		if got := nextData(t, q); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if s := q.Stats(); s.Coalesced != 1 || s.Queued != 0 {
+		t.Errorf("stats = %+v, want 1 coalesced and none queued", s)
+	}
+
+	// Once sent, the key queues a fresh frame
+	_ = q.Push(textFrame("stats-3", "container_stats:abc"), false)
+	if got := nextData(t, q); got != "stats-3" {
+		t.Errorf("got %q, want stats-3", got)
+	}
+}
+
+func TestOutboundQueue_DropsWhenFull(t *testing.T) {
+	q := newOutboundQueue(1, 20*time.Millisecond)
+	q.Open()
+
+	if err := q.Push(textFrame("first", ""), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Push(textFrame("stats", "host_stats"), false); !errors.Is(err, errOutboundQueueFull) {
+		t.Errorf("keyed push to full queue: err = %v, want errOutboundQueueFull", err)
+	}
+	start := time.Now()
+	if err := q.Push(textFrame("second", ""), false); !errors.Is(err, errOutboundQueueFull) {
+		t.Errorf("push to full queue: err = %v, want errOutboundQueueFull", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("unkeyed push should wait for room before dropping")
+	}
+	// Priority frames bypass the limit
+	if err := q.Push(&outboundFrame{messageType: websocket.PingMessage}, true); err != nil {
+		t.Errorf("priority push: %v", err)
+	}
+	if s := q.Stats(); s.Dropped != 2 || s.Queued != 2 {
+		t.Errorf("stats = %+v, want 2 dropped and 2 queued", s)
+	}
+}
+
+func TestOutboundQueue_BlockedSenderResumes(t *testing.T) {
+	q := newOutboundQueue(1, 5*time.Second)
+	q.Open()
+	_ = q.Push(textFrame("first", ""), false)
+
+	done := make(chan error, 1)
+	go func() { done <- q.Push(textFrame("second", ""), false) }()
+
+	time.Sleep(10 * time.Millisecond)
+	if got := nextData(t, q); got != "first" {
+		t.Fatalf("got %q, want first", got)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("blocked push: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked sender was not released when room freed up")
+	}
+	if got := nextData(t, q); got != "second" {
+		t.Errorf("got %q, want second", got)
+	}
+}
+
+func TestOutboundQueue_CloseReleasesSenders(t *testing.T) {
+	q := newOutboundQueue(1, 5*time.Second)
+	q.Open()
+	_ = q.Push(textFrame("first", ""), false)
+
+	done := make(chan error, 1)
+	go func() { done <- q.Push(textFrame("second", ""), false) }()
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errNotConnected) {
+			t.Errorf("err = %v, want errNotConnected", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not release blocked sender")
+	}
+	if err := q.Push(textFrame("late", ""), true); !errors.Is(err, errNotConnected) {
+		t.Errorf("push after close: err = %v, want errNotConnected", err)
+	}
+	if s := q.Stats(); s.Dropped != 1 {
+		t.Errorf("dropped = %d, want 1 (unsent frame at close)", s.Dropped)
+	}
+
+	// Reopening starts empty
+	q.Open()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, ok := q.Next(ctx); ok {
+		t.Error("reopened queue should be empty")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	tests := []struct {
+		msgType string
+		payload interface{}
+		want    string
+	}{
+		{"container_stats", map[string]interface{}{"container_id": "abc"}, "container_stats:abc"},
+		{"container_stats", map[string]interface{}{}, ""},
+		{"stats", nil, "host_stats"},
+		{"container_event", map[string]interface{}{"container_id": "abc"}, ""},
+	}
+	for _, tt := range tests {
+		if got := coalesceKey(tt.msgType, tt.payload); got != tt.want {
+			t.Errorf("coalesceKey(%q) = %q, want %q", tt.msgType, got, tt.want)
+		}
+	}
+}
+
+func TestSendResponse_Chunking(t *testing.T) {
+	c := &WebSocketClient{log: logrus.New(), outbound: newOutboundQueue(100, time.Second)}
+	c.outbound.Open()
+	payload := strings.Repeat("x", protocol.ChunkThreshold)
+
+	// Without the feature, a large response still goes out whole
+	c.negotiated.Store(protocol.Negotiate(map[string]interface{}{"proto_version": "1.2"}))
+	if err := c.sendResponse(protocol.NewCommandResponse("cmd-1", payload, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.outbound.Stats(); s.Queued != 1 {
+		t.Fatalf("queued %d frames, want 1", s.Queued)
+	}
+	nextData(t, c.outbound)
+
+	c.negotiated.Store(protocol.Negotiate(map[string]interface{}{
+		"proto_version": "1.2",
+		"features":      []interface{}{protocol.FeatureResponseChunks},
+	}))
+	if err := c.sendResponse(protocol.NewCommandResponse("cmd-2", payload, nil)); err != nil {
+		t.Fatal(err)
+	}
+	queued := c.outbound.Stats().Queued
+	if queued < 2 {
+		t.Fatalf("queued %d frames, want several chunks", queued)
+	}
+
+	var joined strings.Builder
+	for i := 0; i < queued; i++ {
+		var frame struct {
+			Type    string                 `json:"type"`
+			ID      string                 `json:"id"`
+			Payload protocol.ResponseChunk `json:"payload"`
+		}
+		if err := json.Unmarshal([]byte(nextData(t, c.outbound)), &frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != "response_chunk" || frame.ID != "cmd-2" || frame.Payload.Seq != i || frame.Payload.Final != (i == queued-1) {
+			t.Fatalf("frame %d = %+v", i, frame)
+		}
+		joined.WriteString(frame.Payload.Data)
+	}
+	var resp struct {
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Payload string `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(joined.String()), &resp); err != nil {
+		t.Fatalf("reassembled response: %v", err)
+	}
+	if resp.Type != "response" || resp.ID != "cmd-2" || resp.Payload != payload {
+		t.Errorf("reassembled response %s/%s with %d payload bytes", resp.Type, resp.ID, len(resp.Payload))
+	}
+}
+
+func TestSendResponse_TooLarge(t *testing.T) {
+	c := &WebSocketClient{log: logrus.New(), outbound: newOutboundQueue(10, time.Second)}
+	c.outbound.Open()
+
+	if err := c.sendResponse(protocol.NewCommandResponse("cmd-1", strings.Repeat("x", protocol.MaxResponseSize), nil)); err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		ID        string      `json:"id"`
+		Payload   interface{} `json:"payload"`
+		Error     string      `json:"error"`
+		ErrorCode string      `json:"error_code"`
+	}
+	if err := json.Unmarshal([]byte(nextData(t, c.outbound)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "cmd-1" || resp.Payload != nil || resp.ErrorCode != protocol.ErrorCodeResponseTooLarge || resp.Error == "" {
+		t.Errorf("response = %+v", resp)
+	}
+}
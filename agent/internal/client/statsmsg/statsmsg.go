@@ -6,6 +6,12 @@
 // WebSocket client, so a direct reverse import would create a cycle.
 package statsmsg
 
+import (
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+)
+
 // AgentStatsMsg is the wire format for stats-service ingestion.
 // Deliberately does NOT include a host_id field — the stats-service
 // binds host_id from the agent token at upgrade time, so a compromised
@@ -13,6 +19,7 @@ package statsmsg
 type AgentStatsMsg struct {
 	ContainerID   string  `json:"container_id"`
 	ContainerName string  `json:"container_name"`
+	Group         string  `json:"group,omitempty"` // dockmon.group label
 	CPUPercent    float64 `json:"cpu_percent"`
 	MemoryUsage   uint64  `json:"memory_usage"`
 	MemoryLimit   uint64  `json:"memory_limit"`
@@ -22,4 +29,35 @@ type AgentStatsMsg struct {
 	DiskRead      uint64  `json:"disk_read"`
 	DiskWrite     uint64  `json:"disk_write"`
 	Timestamp     string  `json:"timestamp"`
+
+	MemoryRawUsage   uint64 `json:"memory_raw_usage,omitempty"`
+	MemoryWorkingSet uint64 `json:"memory_working_set,omitempty"`
+
+	// State is set only when the container is paused ("paused", no
+	// metrics) or unpaused ("running"); samples leave it empty
+	State string `json:"state,omitempty"`
+}
+
+// ClockMsg is a clock heartbeat: the agent's current time, sent so the
+// stats-service can measure how far the agent's clock is from its own.
+type ClockMsg struct {
+	Type      string `json:"type"` // Always "clock"
+	AgentTime string `json:"agent_time"`
+}
+
+// NewClockMsg builds a clock heartbeat for the given instant.
+func NewClockMsg(now time.Time) ClockMsg {
+	return ClockMsg{Type: "clock", AgentTime: now.UTC().Format(time.RFC3339Nano)}
+}
+
+// ActivityMsg carries a DockMon activity event (an update, a deployed
+// stack). The stats-service sets the event's host from the agent token.
+type ActivityMsg struct {
+	Type     string         `json:"type"` // Always "activity"
+	Activity activity.Event `json:"activity"`
+}
+
+// NewActivityMsg wraps an activity event for the ingest connection.
+func NewActivityMsg(event activity.Event) ActivityMsg {
+	return ActivityMsg{Type: "activity", Activity: event}
 }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/darthnorse/dockmon-agent/internal/client/statsmsg"
+	"github.com/darthnorse/dockmon-shared/activity"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -20,6 +21,10 @@ import (
 // as the stable public name for callers inside this package.
 type AgentStatsMsg = statsmsg.AgentStatsMsg
 
+// clockHeartbeatInterval is how often the agent reports its clock to the
+// stats-service (and the backend) for drift detection.
+const clockHeartbeatInterval = time.Minute
+
 // StatsServiceClient maintains a WebSocket connection to stats-service's
 // /api/stats/ws/ingest endpoint and ships AgentStatsMsg from a buffered
 // channel. Drops on backpressure rather than blocking the producer.
@@ -29,14 +34,20 @@ type StatsServiceClient struct {
 	log    *logrus.Logger
 	sendCh chan AgentStatsMsg
 	dialer *websocket.Dialer
+	header http.Header // Extra handshake headers, e.g. for an access proxy
+
+	// Activity events get their own queue so a burst of stats can't crowd
+	// them out
+	activityCh chan statsmsg.ActivityMsg
 }
 
 // NewStatsServiceClient builds a client from a base backend URL (http/https)
 // and the agent's permanent token (its agents.id row). The base URL scheme is
 // rewritten to ws/wss and "/api/stats/ws/ingest" is appended.
-// insecureSkipVerify configures TLS verification on this client's OWN dialer,
-// so it never depends on (or races with) a mutated websocket.DefaultDialer.
-func NewStatsServiceClient(backendURL, token string, insecureSkipVerify bool, log *logrus.Logger) *StatsServiceClient {
+// tlsConfig (nil for the defaults) configures TLS verification on this
+// client's OWN dialer, so it never depends on (or races with) a mutated
+// websocket.DefaultDialer.
+func NewStatsServiceClient(backendURL, token string, tlsConfig *tls.Config, log *logrus.Logger) *StatsServiceClient {
 	wsURL := backendURL
 	switch {
 	case strings.HasPrefix(wsURL, "https://"):
@@ -47,19 +58,24 @@ func NewStatsServiceClient(backendURL, token string, insecureSkipVerify bool, lo
 	wsURL = strings.TrimRight(wsURL, "/") + "/api/stats/ws/ingest"
 
 	d := *websocket.DefaultDialer
-	if insecureSkipVerify {
-		d.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402
-	}
+	d.TLSClientConfig = tlsConfig
 
 	return &StatsServiceClient{
-		url:    wsURL,
-		token:  token,
-		log:    log,
-		sendCh: make(chan AgentStatsMsg, 256),
-		dialer: &d,
+		url:        wsURL,
+		token:      token,
+		log:        log,
+		sendCh:     make(chan AgentStatsMsg, 256),
+		activityCh: make(chan statsmsg.ActivityMsg, 32),
+		dialer:     &d,
 	}
 }
 
+// SetHeaders sets extra headers sent on the WebSocket handshake. The
+// Authorization header is always the agent's token. Call before Run.
+func (c *StatsServiceClient) SetHeaders(header http.Header) {
+	c.header = header.Clone()
+}
+
 // Send enqueues a stats message; drops if the channel is full. Non-blocking.
 func (c *StatsServiceClient) Send(msg AgentStatsMsg) {
 	select {
@@ -69,6 +85,20 @@ func (c *StatsServiceClient) Send(msg AgentStatsMsg) {
 	}
 }
 
+// Publish enqueues an activity event; drops if the queue is full.
+// Non-blocking. Events queued before the connection is up are sent once it
+// is.
+func (c *StatsServiceClient) Publish(event activity.Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	select {
+	case c.activityCh <- statsmsg.NewActivityMsg(event):
+	default:
+		c.log.Warnf("Stats service activity queue full, dropping %s event", event.Action)
+	}
+}
+
 // Run dials and pumps the channel until ctx is done. Reconnects with
 // exponential backoff (1s → 30s cap) on connection errors.
 func (c *StatsServiceClient) Run(ctx context.Context) {
@@ -101,7 +131,11 @@ func (c *StatsServiceClient) Run(ctx context.Context) {
 // A background reader detects server-initiated closes even when the producer
 // is idle, so reconnection can fire promptly.
 func (c *StatsServiceClient) connectAndPump(ctx context.Context) error {
-	header := http.Header{"Authorization": {"Bearer " + c.token}}
+	header := c.header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Authorization", "Bearer "+c.token)
 	conn, _, err := c.dialer.DialContext(ctx, c.url, header)
 	if err != nil {
 		return err
@@ -122,16 +156,32 @@ func (c *StatsServiceClient) connectAndPump(ctx context.Context) error {
 		}
 	}()
 
+	// Report our clock right away, then periodically, so the stats-service
+	// can measure drift
+	if err := conn.WriteJSON(statsmsg.NewClockMsg(time.Now())); err != nil {
+		return err
+	}
+	clockTicker := time.NewTicker(clockHeartbeatInterval)
+	defer clockTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case err := <-readErr:
 			return err
+		case <-clockTicker.C:
+			if err := conn.WriteJSON(statsmsg.NewClockMsg(time.Now())); err != nil {
+				return err
+			}
 		case msg := <-c.sendCh:
 			if err := conn.WriteJSON(msg); err != nil {
 				return err
 			}
+		case msg := <-c.activityCh:
+			if err := conn.WriteJSON(msg); err != nil {
+				return err
+			}
 		}
 	}
 }
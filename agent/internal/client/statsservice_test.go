@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
@@ -32,6 +33,9 @@ func TestStatsServiceClient_SendsMessages(t *testing.T) {
 			if err := conn.ReadJSON(&msg); err != nil {
 				return
 			}
+			if msg["type"] == "clock" {
+				continue // Clock heartbeats are covered separately
+			}
 			mu.Lock()
 			got = append(got, msg)
 			mu.Unlock()
@@ -41,7 +45,7 @@ func TestStatsServiceClient_SendsMessages(t *testing.T) {
 
 	log := logrus.New()
 	log.SetOutput(&testLogWriter{t})
-	c := NewStatsServiceClient(srv.URL, "test-token", false, log)
+	c := NewStatsServiceClient(srv.URL, "test-token", nil, log)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -67,10 +71,96 @@ func TestStatsServiceClient_SendsMessages(t *testing.T) {
 	t.Errorf("got %d messages after 500ms, want 1", len(got))
 }
 
+func TestStatsServiceClient_SendsClockOnConnect(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	first := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg map[string]interface{}
+		if err := conn.ReadJSON(&msg); err == nil {
+			first <- msg
+		}
+	}))
+	defer srv.Close()
+
+	log := logrus.New()
+	log.SetOutput(&testLogWriter{t})
+	c := NewStatsServiceClient(srv.URL, "test-token", nil, log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := time.Now().Add(-time.Second)
+	go c.Run(ctx)
+
+	select {
+	case msg := <-first:
+		if msg["type"] != "clock" {
+			t.Fatalf("first message type=%v, want clock", msg["type"])
+		}
+		agentTime, err := time.Parse(time.RFC3339Nano, msg["agent_time"].(string))
+		if err != nil {
+			t.Fatalf("agent_time not RFC3339: %v", err)
+		}
+		if agentTime.Before(before) {
+			t.Errorf("agent_time=%v, want current time", agentTime)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no clock heartbeat received on connect")
+	}
+}
+
+func TestStatsServiceClient_PublishesActivity(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	received := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg["type"] == "activity" {
+				received <- msg
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	log := logrus.New()
+	log.SetOutput(&testLogWriter{t})
+	c := NewStatsServiceClient(srv.URL, "test-token", nil, log)
+
+	// Queued before the connection is up
+	c.Publish(activity.Event{Action: activity.ActionSelfUpdateApplied, Message: "updated"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	select {
+	case msg := <-received:
+		event, _ := msg["activity"].(map[string]interface{})
+		if event["action"] != "self_update_applied" || event["message"] != "updated" || event["timestamp"] == nil {
+			t.Errorf("activity message = %v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("activity event was not sent")
+	}
+}
+
 func TestStatsServiceClient_DropsWhenChannelFull(t *testing.T) {
 	log := logrus.New()
 	log.SetOutput(&testLogWriter{t})
-	c := NewStatsServiceClient("ws://localhost:0/never", "tok", false, log)
+	c := NewStatsServiceClient("ws://localhost:0/never", "tok", nil, log)
 	// Don't run the client; spam the send channel.
 	for i := 0; i < 10000; i++ {
 		c.Send(AgentStatsMsg{ContainerID: "abc123abc123"})
@@ -91,7 +181,7 @@ func TestNewStatsServiceClient_BuildsWsURL(t *testing.T) {
 		{"https://dockmon.example.com/", "wss://"}, // trailing slash trimmed
 	}
 	for _, tc := range cases {
-		c := NewStatsServiceClient(tc.input, "tok", false, log)
+		c := NewStatsServiceClient(tc.input, "tok", nil, log)
 		if !strings.HasPrefix(c.url, tc.prefix) {
 			t.Errorf("%s -> url=%q, want %s prefix", tc.input, c.url, tc.prefix)
 		}
@@ -101,6 +191,41 @@ func TestNewStatsServiceClient_BuildsWsURL(t *testing.T) {
 	}
 }
 
+func TestStatsServiceClient_SendsExtraHeaders(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	got := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Clone()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	log := logrus.New()
+	log.SetOutput(&testLogWriter{t})
+	c := NewStatsServiceClient(srv.URL, "test-token", nil, log)
+	c.SetHeaders(http.Header{"Cf-Access-Client-Id": {"id"}, "Authorization": {"Basic abc"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	select {
+	case h := <-got:
+		if h.Get("Cf-Access-Client-Id") != "id" {
+			t.Errorf("Cf-Access-Client-Id = %q, want id", h.Get("Cf-Access-Client-Id"))
+		}
+		if h.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("Authorization = %q, want the agent token", h.Get("Authorization"))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no connection attempt")
+	}
+}
+
 func TestStatsServiceClient_ReconnectsAfterServerDisconnect(t *testing.T) {
 	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
 	var connectCount int
@@ -128,7 +253,7 @@ func TestStatsServiceClient_ReconnectsAfterServerDisconnect(t *testing.T) {
 
 	log := logrus.New()
 	log.SetOutput(&testLogWriter{t})
-	c := NewStatsServiceClient(srv.URL, "test-token", false, log)
+	c := NewStatsServiceClient(srv.URL, "test-token", nil, log)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
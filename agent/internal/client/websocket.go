@@ -2,24 +2,42 @@ package client
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/darthnorse/dockmon-agent/internal/annotations"
 	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/crashloop"
+	"github.com/darthnorse/dockmon-agent/internal/diagnostics"
 	"github.com/darthnorse/dockmon-agent/internal/docker"
 	"github.com/darthnorse/dockmon-agent/internal/handlers"
+	"github.com/darthnorse/dockmon-agent/internal/notify"
 	"github.com/darthnorse/dockmon-agent/internal/protocol"
+	"github.com/darthnorse/dockmon-agent/internal/scheduler"
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
 	"github.com/darthnorse/dockmon-agent/pkg/types"
+	"github.com/darthnorse/dockmon-shared/admin"
+	"github.com/darthnorse/dockmon-shared/clock"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
+	"github.com/darthnorse/dockmon-shared/updatecheck"
+	"github.com/docker/docker/api/types/events"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 )
 
+// crashLoopLogLines is the log tail sent with a crash_loop event, per stream
+const crashLoopLogLines = 20
+
 // WebSocketClient manages the WebSocket connection to DockMon
 type WebSocketClient struct {
 	cfg           *config.Config
@@ -30,9 +48,23 @@ type WebSocketClient struct {
 
 	conn          *websocket.Conn
 	connMu        sync.RWMutex
+	outbound      *outboundQueue
+	deadlines     commandDeadlines
+	connStats     connectionStats
+	// Protocol version and features agreed at registration, and the
+	// features whose events were already reported as suppressed
+	negotiated    atomic.Pointer[protocol.Negotiated]
+	suppressed    sync.Map
 	registered    bool
 	agentID       string
 	hostID        string
+	// IDs from the last registration, offered back as previous_identity
+	// unless the backend is too old to accept the field
+	identity             *agentIdentity
+	omitPreviousIdentity bool
+	// Set when the backend rejects the permanent token, so the next attempt
+	// registers with the registration token instead
+	permanentTokenRejected bool
 
 	statsHandler       *handlers.StatsHandler
 	hostStatsHandler   *handlers.HostStatsHandler
@@ -42,6 +74,24 @@ type WebSocketClient struct {
 	deployHandler      *handlers.DeployHandler
 	scanHandler        *handlers.ScanHandler
 	shellHandler       *handlers.ShellHandler
+	inventoryHandler   *handlers.InventoryHandler
+	volumeHandler      *handlers.VolumeBackupHandler
+	exportHandler      *handlers.ContainerExportHandler
+	logDownloadHandler *handlers.LogDownloadHandler
+	projectLogsHandler *handlers.ProjectLogsHandler
+	diskUsageHandler   *handlers.DiskUsageHandler
+	imageCheckHandler  *handlers.ImageCheckHandler
+	dockerAPIHandler   *handlers.DockerAPIHandler
+	diagnostics        *diagnostics.Collector
+	diagnosticsHandler *handlers.DiagnosticsHandler
+	localNotifier      *notify.LocalNotifier
+	scheduler          *scheduler.Scheduler
+	throttler          *throttle.Throttler
+	annotations        *annotations.Store
+	maintenance        *maintenance.Mode
+	crashLoops         *crashloop.Detector
+	logLevel           *admin.LogLevel
+	activity           handlers.ActivityPublisher // Optional, see SetActivityPublisher
 
 	stopChan      chan struct{}
 	doneChan      chan struct{}
@@ -74,16 +124,24 @@ func NewWebSocketClient(
 		engineID:      engineID,
 		myContainerID: myContainerID,
 		log:           log,
+		outbound:      newOutboundQueue(outboundQueueSize, outboundSendTimeout),
 		stopChan:      make(chan struct{}),
 		doneChan:      make(chan struct{}),
 	}
 
+	identity, err := loadIdentity(cfg.DataPath)
+	if err != nil {
+		log.WithError(err).Warn("Ignoring unreadable agent identity file")
+	}
+	client.identity = identity
+
 	// Initialize stats handler with sendEvent callback
 	client.statsHandler = handlers.NewStatsHandler(
 		dockerClient,
 		log,
 		client.sendEvent,
 	)
+	client.statsHandler.SetMemoryMode(sharedDocker.MemoryMode(cfg.StatsMemoryMode))
 
 	// Initialize host stats handler for:
 	// - Systemd agents: read directly from /proc
@@ -105,11 +163,15 @@ func NewWebSocketClient(
 		log.Info("Host stats handler initialized (container mode with /host/proc mount)")
 	}
 
-	// Initialize update handler with sendEvent callback
+	// Initialize update handler with sendEvent callback; the latest state of
+	// each update is persisted so the backend can reconcile after a reconnect
 	client.updateHandler = handlers.NewUpdateHandler(
 		dockerClient,
 		log,
 		client.sendEvent,
+		cfg.UpdateMinFreeSpace,
+		cfg.VolumeHelperImage,
+		handlers.NewUpdateStateStore(filepath.Join(cfg.DataPath, "updates.json"), log),
 	)
 
 	// Initialize self-update handler with sendEvent callback
@@ -131,7 +193,6 @@ func NewWebSocketClient(
 
 	// Initialize deploy handler with sendEvent callback
 	// Note: This may fail if Docker Compose is not installed, which is OK
-	var err error
 	client.deployHandler, err = handlers.NewDeployHandler(
 		ctx,
 		dockerClient,
@@ -155,15 +216,177 @@ func NewWebSocketClient(
 	client.shellHandler = handlers.NewShellHandler(dockerClient, log, client.sendEvent)
 	log.Info("Shell handler initialized")
 
+	// Initialize inventory handler for delta-based container list sync
+	client.inventoryHandler = handlers.NewInventoryHandler(dockerClient, log, client.sendEvent)
+
+	// Initialize volume handler for named volume backup and restore
+	client.volumeHandler = handlers.NewVolumeBackupHandler(dockerClient, log, client.sendEvent, cfg.VolumeBackupDir, cfg.VolumeHelperImage)
+
+	// Initialize export handler for container commit and filesystem export
+	client.exportHandler = handlers.NewContainerExportHandler(dockerClient, log, client.sendEvent, cfg.ContainerExportDir)
+
+	// Initialize log download handler for full container logs as gzip
+	client.logDownloadHandler = handlers.NewLogDownloadHandler(dockerClient.RawClient(), log, client.sendEvent)
+
+	// Initialize project log handler for interleaved compose project logs
+	client.projectLogsHandler = handlers.NewProjectLogsHandler(dockerClient.RawClient(), log, client.sendEvent)
+
+	// Initialize disk usage handler for per-container writable layer and
+	// volume sizes
+	client.diskUsageHandler = handlers.NewDiskUsageHandler(dockerClient.RawClient(), log)
+
+	// Initialize image check handler for registry tag/digest lookups
+	client.imageCheckHandler = handlers.NewImageCheckHandler(dockerClient, log)
+
+	// Initialize read-only Docker API passthrough
+	client.dockerAPIHandler = handlers.NewDockerAPIHandler(dockerClient, log)
+
+	// Initialize diagnostics bundle collection; local bundles go to DataPath
+	client.diagnostics = diagnostics.NewCollector(cfg, dockerClient)
+	client.diagnosticsHandler = handlers.NewDiagnosticsHandler(client.diagnostics, log, client.sendEvent, filepath.Join(cfg.DataPath, "diagnostics"))
+
+	// Initialize the job scheduler; schedules and run history are persisted
+	// so jobs keep running while DockMon is unreachable
+	client.scheduler = scheduler.New(filepath.Join(cfg.DataPath, "schedules.json"), dockerClient, log, client.sendEvent)
+
+	// User notes about containers, kept by compose service or name so they
+	// survive recreation and DockMon database resets
+	client.annotations = annotations.New(filepath.Join(cfg.DataPath, "annotations.json"), log)
+
+	// Log level changes at runtime (set_log_level), so debug logs of an
+	// intermittent issue can be captured without a restart
+	client.logLevel = admin.NewLogLevel(admin.Logrus(log), log.Warnf)
+
+	// Maintenance mode pauses scheduled restarts and health checks, and
+	// mutes expected lifecycle events, during a planned window
+	client.maintenance = &maintenance.Mode{}
+	client.scheduler.SetMaintenance(client.maintenance)
+	client.healthCheckHandler.SetMaintenance(client.maintenance)
+	client.loadMaintenance()
+
+	// Containers dying over and over are reported once and flagged in
+	// container listings until they settle
+	client.crashLoops = crashloop.New(cfg.CrashLoopThreshold, cfg.CrashLoopWindow)
+	dockerClient.SetCrashLoops(client.crashLoops)
+
 	return client, nil
 }
 
+// SetLogBuffer includes the agent's recent log lines in diagnostics bundles
+func (c *WebSocketClient) SetLogBuffer(logs *diagnostics.LogBuffer) {
+	c.diagnostics.SetLogBuffer(logs)
+}
+
 // StatsHandler returns the internal StatsHandler so main.go can wire the
 // stats-service dual-send path into it at startup.
 func (c *WebSocketClient) StatsHandler() *handlers.StatsHandler {
 	return c.statsHandler
 }
 
+// SetActivityPublisher sends update, deployment and image prune events to
+// the stats-service activity stream. Call before Run.
+func (c *WebSocketClient) SetActivityPublisher(p handlers.ActivityPublisher) {
+	c.activity = p
+	c.updateHandler.SetActivityPublisher(p)
+	c.scheduler.SetActivityPublisher(p)
+	if c.deployHandler != nil {
+		c.deployHandler.SetActivityPublisher(p)
+	}
+}
+
+// capabilities lists the operations this agent supports, sent at
+// registration and in get_agent_info
+func (c *WebSocketClient) capabilities() map[string]bool {
+	return map[string]bool{
+		"container_operations": true,
+		"container_updates":    true,
+		"event_streaming":      true,
+		"stats_collection":     true,
+		"self_update":          c.myContainerID != "",
+		"compose_deployments":  c.deployHandler != nil,
+		"shell_access":         true,
+		"multi_env_files":      true,
+		"healthcheck_override": true,
+		"update_groups":        true,
+		"volume_backup":        true,
+		"container_export":     true,
+		"log_download":         true,
+		"project_logs":         true,
+		"check_updates":        true,
+		"update_policies":      true,
+		"update_status":        true,
+		"docker_api":           true,
+		"diagnostics":          true,
+		"test_connection":      true,
+		"net_test":             true,
+		"resource_limits":      c.throttler != nil,
+		"agent_info":           true,
+		"container_diff":       true,
+		"annotations":          true,
+		"maintenance_mode":     true,
+		"crash_loop_detection": true,
+		"container_disk_usage": true,
+		"image_prune_policies": true,
+		"stack_adoption":       true,
+		"runtime_log_level":    true,
+	}
+}
+
+// deploymentMode reports whether the agent runs in a container or natively
+func (c *WebSocketClient) deploymentMode() string {
+	if c.myContainerID != "" {
+		return diagnostics.DeploymentContainer
+	}
+	return diagnostics.DeploymentNative
+}
+
+// annotationRequest is the body of the annotation commands. The target is
+// given directly or resolved from a container_id.
+type annotationRequest struct {
+	annotations.Target
+	ContainerID string            `json:"container_id,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Keys        []string          `json:"keys,omitempty"`
+}
+
+// annotationTarget resolves a container_id to its compose service or name,
+// since IDs change when a container is recreated
+func (c *WebSocketClient) annotationTarget(ctx context.Context, req annotationRequest) (annotations.Target, error) {
+	if req.ContainerID == "" {
+		return req.Target, nil
+	}
+	inspect, err := c.docker.InspectContainer(ctx, req.ContainerID)
+	if err != nil {
+		return annotations.Target{}, err
+	}
+	var labels map[string]string
+	if inspect.Config != nil {
+		labels = inspect.Config.Labels
+	}
+	return annotations.TargetFromLabels(inspect.Name, labels), nil
+}
+
+// SetThrottler enables the get_throttle/set_throttle commands and limits
+// self-update downloads with t
+func (c *WebSocketClient) SetThrottler(t *throttle.Throttler) {
+	c.throttler = t
+	c.selfUpdateHandler.SetThrottler(t)
+}
+
+// SetLocalNotifier wires the local notifier: it tracks the connection state
+// and is told about failed self-updates.
+func (c *WebSocketClient) SetLocalNotifier(n *notify.LocalNotifier) {
+	c.localNotifier = n
+	if n == nil {
+		return
+	}
+	c.selfUpdateHandler.SetFailureCallback(func(stage string, err error) {
+		n.Notify(notify.KindUpdateFailed, "update_failed",
+			"DockMon agent self-update failed",
+			fmt.Sprintf("Agent self-update failed at stage %q: %v", stage, err))
+	})
+}
+
 // Run starts the WebSocket client with automatic reconnection
 func (c *WebSocketClient) Run(ctx context.Context) error {
 	defer close(c.doneChan)
@@ -172,6 +395,9 @@ func (c *WebSocketClient) Run(ctx context.Context) error {
 	// on these; only Run exit does.
 	defer c.waitLongRunning(30 * time.Second)
 
+	// Scheduled jobs run independently of the connection
+	go c.scheduler.Run(ctx)
+
 	backoff := c.cfg.ReconnectInitial
 	isReconnect := false
 
@@ -193,6 +419,7 @@ func (c *WebSocketClient) Run(ctx context.Context) error {
 
 		// Attempt connection
 		if err := c.connect(ctx); err != nil {
+			c.connStats.connectFailed()
 			c.log.WithField("error", err.Error()).Errorf("Connection failed, retrying in %v", backoff)
 
 			// Wait before retry with exponential backoff
@@ -215,18 +442,36 @@ func (c *WebSocketClient) Run(ctx context.Context) error {
 		// Connection successful, reset backoff
 		backoff = c.cfg.ReconnectInitial
 		isReconnect = false
+		c.localNotifier.SetConnected(true)
+		c.connStats.connected(time.Now())
 
 		// Handle connection (blocks until disconnect)
+		reason := "closed"
 		if err := c.handleConnection(ctx); err != nil {
 			c.log.WithError(err).Warn("Connection lost, will attempt to reconnect")
+			reason = err.Error()
 		}
+		c.connStats.disconnected(time.Now(), reason)
 
 		// Close connection and prepare for reconnect
 		c.closeConnection()
+		c.localNotifier.SetConnected(false)
 		isReconnect = true
 	}
 }
 
+// Register connects to DockMon, registers once and disconnects. Like a
+// normal start, a permanent token in the response is persisted to DataPath.
+// Used by `dockmon-agent install` to exchange the registration token and
+// verify connectivity before the agent is installed.
+func (c *WebSocketClient) Register(ctx context.Context) (agentID, hostID string, err error) {
+	if err := c.connect(ctx); err != nil {
+		return "", "", err
+	}
+	defer c.closeConnection()
+	return c.agentID, c.hostID, nil
+}
+
 // Stop stops the WebSocket client
 func (c *WebSocketClient) Stop() {
 	c.signalStop()
@@ -245,7 +490,7 @@ func (c *WebSocketClient) connect(ctx context.Context) error {
 	c.log.WithField("url", c.cfg.DockMonURL).Info("Connecting to DockMon")
 
 	// Build WebSocket URL (convert http:// to ws:// and https:// to wss://)
-	wsURL := c.cfg.DockMonURL
+	wsURL := c.cfg.ServerURL()
 	if len(wsURL) > 7 && wsURL[:7] == "http://" {
 		wsURL = "ws://" + wsURL[7:]
 	} else if len(wsURL) > 8 && wsURL[:8] == "https://" {
@@ -258,15 +503,18 @@ func (c *WebSocketClient) connect(ctx context.Context) error {
 	// (e.g. StatsServiceClient).
 	d := *websocket.DefaultDialer
 	dialer := &d
+	tlsConfig, err := c.cfg.DockMonTLSConfig()
+	if err != nil {
+		return err
+	}
+	dialer.TLSClientConfig = tlsConfig
 	if c.cfg.InsecureSkipVerify {
-		dialer.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true, // #nosec G402
-		}
 		c.log.Warn("TLS certificate verification disabled (INSECURE_SKIP_VERIFY=true)")
 	}
 
 	// Connect
-	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	// Extra headers (DOCKMON_HEADERS) for access proxies in front of DockMon
+	conn, _, err := dialer.DialContext(ctx, wsURL, c.cfg.ExtraHeaders.Clone())
 	if err != nil {
 		return fmt.Errorf("failed to dial: %w", err)
 	}
@@ -296,9 +544,11 @@ func (c *WebSocketClient) connect(ctx context.Context) error {
 
 // register sends registration message and waits for response
 func (c *WebSocketClient) register(ctx context.Context) error {
-	// Determine which token to use
+	// Determine which token to use. A permanent token the backend no longer
+	// knows (e.g. its database was restored) gives way to a configured
+	// registration token, and previous_identity asks for the same IDs back.
 	token := c.cfg.PermanentToken
-	if token == "" {
+	if token == "" || (c.permanentTokenRejected && c.cfg.RegistrationToken != "") {
 		token = c.cfg.RegistrationToken
 	}
 
@@ -317,6 +567,8 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 			"docker_version": systemInfo.DockerVersion,
 			"total_memory":   systemInfo.TotalMemory,
 			"num_cpus":       systemInfo.NumCPUs,
+			"docker_host":    systemInfo.DockerHost + " (" + systemInfo.DockerHostSource + ")",
+			"rootless":       systemInfo.Rootless,
 		}).Info("System information collected successfully")
 	} else {
 		c.log.Warn("GetSystemInfo returned nil without error")
@@ -354,17 +606,18 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 		"hostname_source": hostnameSource,
 		"version": c.cfg.AgentVersion,
 		"proto_version": c.cfg.ProtoVersion,
+		"features": protocol.Features(c.cfg.ProtoVersion),
 		"force_unique_registration": c.cfg.ForceUniqueRegistration,
-		"capabilities": map[string]bool{
-			"container_operations": true,
-			"container_updates":    true,
-			"event_streaming":      true,
-			"stats_collection":     true,
-			"self_update":          c.myContainerID != "",
-			"compose_deployments":  c.deployHandler != nil,
-			"shell_access":         true,
-			"multi_env_files":      true,
-		},
+		"capabilities": c.capabilities(),
+		// Clock handshake: the backend compares this to its own clock
+		// (and replies with server_time) to detect drift
+		"agent_time": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	// Ask for the IDs we had before, in case the backend lost them (e.g. a
+	// database restore) and would otherwise register us as a new host
+	if c.identity != nil && !c.omitPreviousIdentity {
+		regMsg["previous_identity"] = c.identity.previousIdentityPayload()
 	}
 
 	// Add agent runtime info (GOOS/GOARCH) - needed for binary downloads
@@ -407,6 +660,7 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 	}
 
 	c.log.Debug("Sending registration message to backend")
+	sentAt := time.Now()
 
 	// Set write deadline for registration message
 	if err := c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
@@ -445,11 +699,24 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 	// Check for error response
 	if respType, ok := respMap["type"].(string); ok && respType == "auth_error" {
 		if errMsg, ok := respMap["error"].(string); ok {
+			if _, sent := regMsg["previous_identity"]; sent && previousIdentityRejected(errMsg) {
+				c.omitPreviousIdentity = true
+				c.log.Warn("DockMon does not support previous_identity; retrying registration without it")
+			}
+			if token == c.cfg.PermanentToken && errMsg == "Invalid registration token" && c.cfg.RegistrationToken != "" {
+				c.permanentTokenRejected = true
+				c.log.Warn("DockMon no longer recognizes the permanent token; re-registering with REGISTRATION_TOKEN")
+			}
 			return fmt.Errorf("registration rejected: %s", errMsg)
 		}
 		return fmt.Errorf("registration rejected: unknown error")
 	}
 
+	// Report clock drift measured against the backend's reply, if it sent one
+	if serverTime, ok := respMap["server_time"].(string); ok {
+		c.checkClockDrift(sentAt, serverTime, time.Now())
+	}
+
 	// Extract agent_id and host_id from flat response
 	agentID, ok1 := respMap["agent_id"].(string)
 	hostID, ok2 := respMap["host_id"].(string)
@@ -461,10 +728,30 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 	c.agentID = agentID
 	c.hostID = hostID
 	c.registered = true
+	c.rememberIdentity(respMap, agentID, hostID)
+
+	// Older backends send no features and get only the core event types
+	negotiated := protocol.Negotiate(respMap)
+	c.negotiated.Store(negotiated)
+	c.suppressed.Clear()
+	c.log.WithFields(logrus.Fields{
+		"proto_version":  negotiated.Version,
+		"server_version": negotiated.ServerVersion,
+		"features":       negotiated.Features(),
+		"disabled":       negotiated.Disabled(),
+	}).Info("Negotiated protocol with DockMon")
+	if negotiated.Outdated(c.cfg.ProtoVersion) {
+		c.log.WithFields(logrus.Fields{
+			"agent_proto_version":  c.cfg.ProtoVersion,
+			"server_proto_version": negotiated.Version,
+			"agent_version":        c.cfg.AgentVersion,
+		}).Warn("DockMon is older than this agent; please update DockMon to use all agent features")
+	}
 
 	// Check for permanent token and persist it
 	if permanentToken, ok := respMap["permanent_token"].(string); ok && permanentToken != "" {
 		c.cfg.PermanentToken = permanentToken
+		c.permanentTokenRejected = false
 
 		// Persist token to disk with restricted permissions (0600 = owner read/write only)
 		tokenPath := filepath.Join(c.cfg.DataPath, "permanent_token")
@@ -477,6 +764,60 @@ func (c *WebSocketClient) register(ctx context.Context) error {
 	return nil
 }
 
+// rememberIdentity persists the IDs assigned at registration, logging when
+// they differ from the ones we asked for
+func (c *WebSocketClient) rememberIdentity(respMap map[string]interface{}, agentID, hostID string) {
+	previous := c.identity
+	if reassigned, ok := respMap["identity_reassigned"].(map[string]interface{}); ok {
+		c.log.WithFields(logrus.Fields{
+			"previous_agent_id": reassigned["previous_agent_id"],
+			"previous_host_id":  reassigned["previous_host_id"],
+			"agent_id":          agentID,
+			"host_id":           hostID,
+			"reason":            reassigned["reason"],
+		}).Warn("DockMon assigned a new identity; history recorded under the previous host ID is not carried over")
+	} else if previous != nil && previous.HostID != "" && previous.HostID != hostID {
+		c.log.WithFields(logrus.Fields{
+			"previous_host_id": previous.HostID,
+			"host_id":          hostID,
+		}).Warn("DockMon registered this agent under a different host ID")
+	}
+
+	// A fresh proof is issued on every connect; keep the stored one unless
+	// the IDs changed or there is none yet
+	proof, _ := respMap["identity_proof"].(string)
+	if previous != nil && previous.AgentID == agentID && previous.HostID == hostID && (proof == "" || previous.Proof != "") {
+		return
+	}
+	identity := agentIdentity{AgentID: agentID, HostID: hostID, Proof: proof, RegisteredAt: time.Now().UTC()}
+	if err := saveIdentity(c.cfg.DataPath, identity); err != nil {
+		c.log.WithError(err).Warn("Failed to persist agent identity")
+		return
+	}
+	c.identity = &identity
+}
+
+// checkClockDrift logs a warning when the agent's clock is far from the
+// backend's. Timestamps are corrected server-side; this just surfaces it.
+func (c *WebSocketClient) checkClockDrift(sentAt time.Time, serverTime string, receivedAt time.Time) {
+	remote, err := time.Parse(time.RFC3339Nano, serverTime)
+	if err != nil {
+		c.log.WithField("server_time", serverTime).Debug("Ignoring unparseable server_time")
+		return
+	}
+	sample := clock.FromExchange(sentAt, remote, receivedAt)
+	drift := -sample.Offset // How far ahead of the backend we are
+	fields := logrus.Fields{
+		"drift_ms": drift.Milliseconds(),
+		"rtt_ms":   sample.RTT.Milliseconds(),
+	}
+	if drift > clock.DriftWarnThreshold || drift < -clock.DriftWarnThreshold {
+		c.log.WithFields(fields).Warn("Agent clock differs from DockMon server; check NTP on this host")
+	} else {
+		c.log.WithFields(fields).Debug("Agent clock in sync with DockMon server")
+	}
+}
+
 // handleConnection handles an active connection
 func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 	// Create connection-scoped context that we cancel when disconnecting
@@ -502,6 +843,7 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 	// Set up pong handler - resets read deadline when pong received
 	conn.SetPongHandler(func(appData string) error {
 		c.log.Debug("Received pong from server")
+		c.connStats.pongReceived(time.Now())
 		// Extend read deadline on pong
 		return conn.SetReadDeadline(time.Now().Add(pingInterval + pongTimeout))
 	})
@@ -535,7 +877,20 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		}
 	}()
 
-	// Start ping goroutine to keep connection alive and detect stale connections
+	// Start the writer; from here on every frame goes through c.outbound
+	c.outbound.Open()
+	c.backgroundWg.Add(1)
+	go func() {
+		defer func() {
+			c.log.Info("Goroutine exit: writer")
+			c.backgroundWg.Done()
+		}()
+		c.writeLoop(connCtx)
+	}()
+
+	// Start ping goroutine to keep connection alive and detect stale connections.
+	// Pings and heartbeats are queued ahead of other messages, so a burst of
+	// events can't hold them back past the server's read deadline.
 	c.backgroundWg.Add(1)
 	go func() {
 		defer func() {
@@ -544,6 +899,8 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		}()
 		ticker := time.NewTicker(pingInterval)
 		defer ticker.Stop()
+		heartbeatTicker := time.NewTicker(clockHeartbeatInterval)
+		defer heartbeatTicker.Stop()
 
 		for {
 			select {
@@ -551,29 +908,28 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 				return
 			case <-c.stopChan:
 				return
-			case <-ticker.C:
-				// Must hold WRITE lock for WebSocket writes (gorilla allows only 1 concurrent writer)
-				c.connMu.Lock()
-				if c.conn == nil {
-					c.connMu.Unlock()
-					return
-				}
-
-				// Send ping with write deadline
-				if err := c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
-					c.log.WithError(err).Debug("Failed to set write deadline")
-				}
-				err := c.conn.WriteMessage(websocket.PingMessage, nil)
-				if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
-					c.log.WithError(err).Debug("Failed to clear write deadline")
+			case <-heartbeatTicker.C:
+				// Application-level heartbeat carrying our clock for drift
+				// detection, the outbound queue's drop counters and the
+				// operations that missed their deadline
+				data, err := json.Marshal(map[string]interface{}{
+					"type":       "heartbeat",
+					"agent_time": time.Now().UTC().Format(time.RFC3339Nano),
+					"outbound":   c.outbound.Stats(),
+					"operations": c.deadlines.Stats(),
+					"connection": c.connStats.Stats(c.outbound.Stats().Queued),
+				})
+				if err == nil {
+					err = c.outbound.Push(&outboundFrame{messageType: websocket.TextMessage, data: data}, true)
 				}
-				c.connMu.Unlock()
-
 				if err != nil {
+					c.log.WithError(err).Debug("Failed to send heartbeat")
+				}
+			case <-ticker.C:
+				if err := c.outbound.Push(&outboundFrame{messageType: websocket.PingMessage}, true); err != nil {
 					c.log.WithError(err).Warn("Failed to send ping")
 					return
 				}
-				c.log.Debug("Sent ping to server")
 			}
 		}
 	}()
@@ -608,9 +964,12 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 	// Start health check handler (Start() logs "Health check handler started")
 	c.healthCheckHandler.Start(connCtx)
 
+	// Start inventory sync; deltas flow once the backend subscribes
+	c.inventoryHandler.Start(connCtx)
+
 	// Ensure cleanup when we exit
 	// IMPORTANT: Order matters here to prevent deadlocks and races:
-	// 1. Cancel context to signal goroutines to stop
+	// 1. Cancel context to signal goroutines to stop and close the outbound queue
 	// 2. Wait for message handlers (which may call backgroundWg.Add)
 	// 3. Wait for background goroutines (ping, events, updates)
 	defer func() {
@@ -618,6 +977,9 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		c.log.Info("Connection cleanup: cancelling context")
 		connCancel()
 
+		// Reject further sends and release senders blocked on a full queue
+		c.outbound.Close()
+
 		c.statsHandler.StopAll()
 		c.log.Info("Connection cleanup: stats stopped")
 
@@ -627,6 +989,12 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		c.shellHandler.CloseAll()
 		c.log.Info("Connection cleanup: shell sessions closed")
 
+		c.projectLogsHandler.StopAll()
+		c.log.Info("Connection cleanup: project log streams stopped")
+
+		c.inventoryHandler.Stop()
+		c.log.Info("Connection cleanup: inventory subscription dropped")
+
 		// Wait for message handlers first - they may call backgroundWg.Add()
 		// This prevents the race: backgroundWg.Add() called after Wait() returns
 		c.log.Info("Connection cleanup: waiting for message handlers")
@@ -662,6 +1030,7 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("read error: %w", err)
 		}
+		c.connStats.bytesReceived.Add(uint64(len(data)))
 
 		// Reset read deadline after successful read
 		if err := conn.SetReadDeadline(time.Now().Add(pingInterval + pongTimeout)); err != nil {
@@ -678,8 +1047,10 @@ func (c *WebSocketClient) handleConnection(ctx context.Context) error {
 		// Handle message in goroutine, tracked by messageWg
 		// This ensures all Add() calls to backgroundWg happen before backgroundWg.Wait()
 		c.messageWg.Add(1)
+		c.connStats.pendingCommands.Add(1)
 		go func(m *types.Message) {
 			defer c.messageWg.Done()
+			defer c.connStats.pendingCommands.Add(-1)
 			c.handleMessage(ctx, m)
 		}(msg)
 	}
@@ -726,13 +1097,43 @@ func (c *WebSocketClient) handleMessage(ctx context.Context, msg *types.Message)
 		return
 	}
 
-	// Dispatch command
-	var result interface{}
-	var err error
+	// Dispatch command under its deadline
+	timeout := commandTimeout(msg.Command, msg.Payload, 0)
+	result, err := c.deadlines.run(ctx, msg.Command, timeout, func(ctx context.Context) (interface{}, error) {
+		return c.dispatchCommand(ctx, msg)
+	})
+	if errors.Is(err, protocol.ErrTimeout) {
+		c.log.WithError(err).WithField("id", msg.ID).Warn("Command timed out")
+	}
+
+	// Send response
+	resp := protocol.NewCommandResponse(msg.ID, result, err)
+	if sendErr := c.sendResponse(resp); sendErr != nil {
+		c.log.WithError(sendErr).Error("Failed to send response")
+	}
+}
 
+// dispatchCommand executes a command message. It runs under the command's
+// deadline, so ctx must not be kept by anything that outlives the call.
+func (c *WebSocketClient) dispatchCommand(ctx context.Context, msg *types.Message) (result interface{}, err error) {
 	switch msg.Command {
 	case "list_containers":
-		result, err = c.docker.ListContainers(ctx)
+		// fast skips RepoDigests enrichment for callers that only need state;
+		// subscribe returns a revisioned snapshot and enables inventory deltas
+		var listReq struct {
+			Fast      bool `json:"fast"`
+			Subscribe bool `json:"subscribe"`
+		}
+		if err = protocol.ParseCommand(msg, &listReq); err == nil {
+			switch {
+			case listReq.Subscribe:
+				result, err = c.inventoryHandler.Subscribe(ctx)
+			case listReq.Fast:
+				result, err = c.docker.ListContainersFast(ctx)
+			default:
+				result, err = c.docker.ListContainers(ctx)
+			}
+		}
 
 	case "update_container":
 		var updateReq handlers.UpdateRequest
@@ -759,6 +1160,171 @@ func (c *WebSocketClient) handleMessage(ctx context.Context, msg *types.Message)
 			result = map[string]string{"status": "update_started"}
 		}
 
+	case "set_healthcheck":
+		var hcReq handlers.SetHealthcheckRequest
+		if err = protocol.ParseCommand(msg, &hcReq); err == nil {
+			if err = hcReq.Healthcheck.Validate(); err == nil {
+				// Recreates the container, so run detached like update_container
+				c.longRunningWg.Add(1)
+				go func() { // #nosec G118
+					defer c.longRunningWg.Done()
+					hcResult, hcErr := c.updateHandler.SetHealthcheck(context.Background(), hcReq)
+					if hcErr != nil {
+						c.log.WithError(hcErr).Error("Set healthcheck failed")
+					} else {
+						c.log.WithFields(logrus.Fields{
+							"old_container": hcResult.OldContainerID,
+							"new_container": hcResult.NewContainerID,
+							"name":          hcResult.ContainerName,
+						}).Info("Container healthcheck updated")
+					}
+				}()
+				result = map[string]string{"status": "set_healthcheck_started"}
+			}
+		}
+
+	case "adopt_into_stack":
+		var adoptReq handlers.AdoptIntoStackRequest
+		if err = protocol.ParseCommand(msg, &adoptReq); err == nil {
+			if err = adoptReq.StackAdoption.Validate(); err == nil {
+				// Recreates the container, so run detached like update_container
+				c.longRunningWg.Add(1)
+				go func() { // #nosec G118
+					defer c.longRunningWg.Done()
+					adoptResult, adoptErr := c.updateHandler.AdoptIntoStack(context.Background(), adoptReq)
+					if adoptErr != nil {
+						c.log.WithError(adoptErr).Error("Adopting container into stack failed")
+					} else {
+						c.log.WithFields(logrus.Fields{
+							"old_container": adoptResult.OldContainerID,
+							"new_container": adoptResult.NewContainerID,
+							"name":          adoptResult.ContainerName,
+							"project":       adoptReq.Project,
+						}).Info("Container adopted into stack")
+					}
+				}()
+				result = map[string]string{"status": "adopt_into_stack_started"}
+			}
+		}
+
+	case "update_group":
+		var groupReq handlers.UpdateGroupRequest
+		if err = protocol.ParseCommand(msg, &groupReq); err == nil {
+			if len(groupReq.Members) == 0 {
+				err = fmt.Errorf("no containers in group")
+			} else {
+				// Updates several containers sequentially, so run detached like update_container
+				c.longRunningWg.Add(1)
+				go func() { // #nosec G118
+					defer c.longRunningWg.Done()
+					if _, groupErr := c.updateHandler.UpdateGroup(context.Background(), groupReq); groupErr != nil {
+						c.log.WithError(groupErr).Error("Group update failed")
+					}
+				}()
+				result = map[string]string{"status": "update_group_started"}
+			}
+		}
+
+	case "get_update_status":
+		// Latest persisted state per container, including updates that
+		// finished while the backend was disconnected
+		var statusReq struct {
+			ContainerIDs []string  `json:"container_ids,omitempty"`
+			Since        time.Time `json:"since,omitempty"`
+		}
+		if err = protocol.ParseCommand(msg, &statusReq); err == nil {
+			result = map[string]interface{}{
+				"updates":    c.updateHandler.UpdateStatus(statusReq.ContainerIDs, statusReq.Since),
+				"agent_time": time.Now().UTC().Format(time.RFC3339Nano),
+			}
+		}
+
+	case "sync_schedules":
+		// Replaces every schedule, e.g. after (re)connecting
+		var syncReq struct {
+			Schedules []scheduler.Schedule `json:"schedules"`
+		}
+		if err = protocol.ParseCommand(msg, &syncReq); err == nil {
+			if err = c.scheduler.Sync(syncReq.Schedules); err == nil {
+				result = map[string]int{"count": len(syncReq.Schedules)}
+			}
+		}
+
+	case "set_schedule":
+		var sched scheduler.Schedule
+		if err = protocol.ParseCommand(msg, &sched); err == nil {
+			if err = c.scheduler.Set(sched); err == nil {
+				result = map[string]bool{"success": true}
+			}
+		}
+
+	case "remove_schedule":
+		var removeReq struct {
+			ScheduleID string `json:"schedule_id"`
+		}
+		if err = protocol.ParseCommand(msg, &removeReq); err == nil {
+			result = map[string]bool{"removed": c.scheduler.Remove(removeReq.ScheduleID)}
+		}
+
+	case "list_schedules":
+		result = c.scheduler.List()
+
+	case "get_schedule_runs":
+		// Run history, including runs while the backend was disconnected
+		var runsReq struct {
+			ScheduleID string    `json:"schedule_id,omitempty"`
+			Since      time.Time `json:"since,omitempty"`
+		}
+		if err = protocol.ParseCommand(msg, &runsReq); err == nil {
+			result = map[string]interface{}{
+				"runs":       c.scheduler.Runs(runsReq.ScheduleID, runsReq.Since),
+				"agent_time": time.Now().UTC().Format(time.RFC3339Nano),
+			}
+		}
+
+	case "get_agent_info":
+		result = c.diagnostics.AgentInfo(c.deploymentMode(), c.capabilities())
+
+	case "get_throttle":
+		if c.throttler == nil {
+			err = fmt.Errorf("resource limits are not available")
+		} else {
+			result = map[string]interface{}{
+				"settings":            c.throttler.Settings(),
+				"active_docker_calls": c.throttler.ActiveDockerCalls(),
+			}
+		}
+
+	case "set_throttle":
+		// Replaces all limits. The call and bandwidth limits apply even if
+		// the priority can't be changed; get_throttle shows what took effect.
+		var settings throttle.Settings
+		if c.throttler == nil {
+			err = fmt.Errorf("resource limits are not available")
+		} else if err = protocol.ParseCommand(msg, &settings); err == nil {
+			err = c.throttler.Apply(settings)
+			if err == nil {
+				result = map[string]interface{}{"success": true, "settings": c.throttler.Settings()}
+			}
+		}
+
+	case "get_log_level":
+		result = c.logLevel.State()
+
+	case "set_log_level":
+		// Optionally timed, e.g. debug for 30 minutes and back to the
+		// previous level after
+		var req admin.LevelRequest
+		if err = protocol.ParseCommand(msg, &req); err == nil {
+			var state admin.LevelState
+			if state, err = c.logLevel.Set(req); err == nil {
+				result = state
+			}
+		}
+
+	case "get_config":
+		result = diagnostics.RedactConfig(c.cfg)
+
 	case "self_update":
 		var updateReq handlers.SelfUpdateRequest
 		if err = protocol.ParseCommand(msg, &updateReq); err == nil {
@@ -826,6 +1392,83 @@ func (c *WebSocketClient) handleMessage(ctx context.Context, msg *types.Message)
 		// List all images with usage information
 		result, err = c.docker.ListImages(ctx)
 
+	case "diff_container":
+		// Filesystem changes and config drift from the image, for
+		// troubleshooting
+		var diffReq struct {
+			ContainerID string `json:"container_id"`
+		}
+		if err = protocol.ParseCommand(msg, &diffReq); err == nil {
+			if diffReq.ContainerID == "" {
+				err = fmt.Errorf("container_id is required")
+			} else {
+				result, err = c.docker.DiffContainer(ctx, diffReq.ContainerID)
+			}
+		}
+
+	case "container_disk_usage":
+		// Writable layer and volume sizes, to find what's filling the disk
+		var usageReq handlers.ContainerDiskUsageRequest
+		if err = protocol.ParseCommand(msg, &usageReq); err == nil {
+			result, err = c.diskUsageHandler.ContainerUsage(ctx, usageReq)
+		}
+
+	case "set_annotations":
+		// Merge user notes into a container's or compose service's annotations
+		var annReq annotationRequest
+		if err = protocol.ParseCommand(msg, &annReq); err == nil {
+			var target annotations.Target
+			if target, err = c.annotationTarget(ctx, annReq); err == nil {
+				result, err = c.annotations.Set(target, annReq.Annotations)
+			}
+		}
+
+	case "get_annotations":
+		// Without a target, returns every annotation so the backend can
+		// resync after a database reset
+		var annReq annotationRequest
+		if err = protocol.ParseCommand(msg, &annReq); err == nil {
+			if annReq.ContainerID == "" && annReq.Target == (annotations.Target{}) {
+				result = map[string]interface{}{"entries": c.annotations.List()}
+			} else {
+				var target annotations.Target
+				if target, err = c.annotationTarget(ctx, annReq); err == nil {
+					var entry annotations.Entry
+					var found bool
+					if entry, found, err = c.annotations.Get(target); err == nil {
+						if !found {
+							entry = annotations.Entry{Target: target, Annotations: map[string]annotations.Annotation{}}
+						}
+						result = entry
+					}
+				}
+			}
+		}
+
+	case "delete_annotations":
+		// Removes the given keys, or every annotation of the target
+		var annReq annotationRequest
+		if err = protocol.ParseCommand(msg, &annReq); err == nil {
+			var target annotations.Target
+			if target, err = c.annotationTarget(ctx, annReq); err == nil {
+				var removed int
+				if removed, err = c.annotations.Delete(target, annReq.Keys); err == nil {
+					result = map[string]int{"removed": removed}
+				}
+			}
+		}
+
+	case "set_maintenance":
+		// Planned work on the host: pause restart jobs and health checks,
+		// and mute expected stop/start events until the window ends
+		var maintReq maintenanceRequest
+		if err = protocol.ParseCommand(msg, &maintReq); err == nil {
+			result, err = c.setMaintenance(maintReq)
+		}
+
+	case "get_maintenance":
+		result = c.maintenanceState()
+
 	case "remove_image":
 		// Remove a Docker image
 		var removeReq struct {
@@ -839,9 +1482,62 @@ func (c *WebSocketClient) handleMessage(ctx context.Context, msg *types.Message)
 			}
 		}
 
+	case "check_updates":
+		// Compare local images with their registries without pulling
+		var checkReq handlers.CheckUpdatesRequest
+		if err = protocol.ParseCommand(msg, &checkReq); err == nil {
+			var results []handlers.ImageCheckResult
+			if results, err = c.imageCheckHandler.CheckUpdates(ctx, checkReq); err == nil {
+				result = map[string]interface{}{"images": results}
+			}
+		}
+
+	case "check_container_updates":
+		// Report containers with an update allowed by their update policy
+		var checkReq handlers.CheckContainerUpdatesRequest
+		if err = protocol.ParseCommand(msg, &checkReq); err == nil {
+			var results []updatecheck.Result
+			if results, err = c.imageCheckHandler.CheckContainers(ctx, checkReq); err == nil {
+				result = map[string]interface{}{"containers": results}
+			}
+		}
+
+	case "test_connection":
+		// Probe a Docker daemon from the agent's network (default: its own)
+		var testReq handlers.TestConnectionRequest
+		if err = protocol.ParseCommand(msg, &testReq); err == nil {
+			result, err = handlers.TestConnection(ctx, c.docker.RawClient(), testReq)
+		}
+
+	case "net_test":
+		// DNS, TCP and HTTP checks from inside a container's network namespace
+		var netReq sharedDocker.NetTestRequest
+		if err = protocol.ParseCommand(msg, &netReq); err == nil {
+			if netReq.HelperImage == "" {
+				netReq.HelperImage = c.cfg.NetTestImage
+			}
+			result, err = sharedDocker.RunNetTest(ctx, c.docker.RawClient(), netReq)
+		}
+
+	case "docker_api":
+		// Allow-listed read-only Docker API GET (version, info, df, inspect)
+		var apiReq handlers.DockerAPIRequest
+		if err = protocol.ParseCommand(msg, &apiReq); err == nil {
+			result, err = c.dockerAPIHandler.Do(ctx, apiReq)
+		}
+
 	case "prune_images":
-		// Prune all unused images
-		result, err = c.docker.PruneImages(ctx)
+		// Prune all unused images, or apply a policy's safety rules
+		var pruneReq struct {
+			Policy *sharedDocker.ImagePrunePolicy `json:"policy"`
+		}
+		if err = protocol.ParseCommand(msg, &pruneReq); err == nil {
+			if pruneReq.Policy == nil {
+				result, err = c.docker.PruneImages(ctx)
+			} else if err = pruneReq.Policy.Validate(); err == nil {
+				result, err = c.pruneImagesWithPolicy(ctx, *pruneReq.Policy)
+			}
+		}
 
 	case "list_networks":
 		// List all networks with connected container info
@@ -898,15 +1594,150 @@ func (c *WebSocketClient) handleMessage(ctx context.Context, msg *types.Message)
 		// Prune all unused volumes (including named volumes)
 		result, err = c.docker.PruneVolumes(ctx)
 
+	case "commit_container":
+		var commitReq handlers.ContainerCommitRequest
+		if err = protocol.ParseCommand(msg, &commitReq); err == nil {
+			// Committing a large filesystem can take minutes, so run detached;
+			// the result arrives as a container_commit_complete event
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.exportHandler.Commit(context.Background(), commitReq)
+			}()
+			result = map[string]string{"status": "commit_started"}
+		}
+
+	case "export_container":
+		var exportReq handlers.ContainerExportRequest
+		if err = protocol.ParseCommand(msg, &exportReq); err == nil {
+			// Progress, chunks and the result arrive as container_export_* events
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.exportHandler.Export(context.Background(), exportReq)
+			}()
+			result = map[string]string{"status": "export_started"}
+		}
+
+	case "download_logs":
+		var logReq handlers.LogDownloadRequest
+		if err = protocol.ParseCommand(msg, &logReq); err == nil {
+			// Chunks and the result arrive as container_logs_* events
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.logDownloadHandler.Download(context.Background(), logReq)
+			}()
+			result = map[string]string{"status": "log_download_started"}
+		}
+
+	case "follow_project_logs":
+		var logsReq handlers.ProjectLogsRequest
+		if err = protocol.ParseCommand(msg, &logsReq); err == nil {
+			// Lines arrive as project_log_lines events until the logs end
+			// or stop_project_logs is sent
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.projectLogsHandler.Follow(context.Background(), logsReq)
+			}()
+			result = map[string]string{"status": "project_logs_started"}
+		}
+
+	case "stop_project_logs":
+		var stopReq struct {
+			StreamID string `json:"stream_id"`
+		}
+		if err = protocol.ParseCommand(msg, &stopReq); err == nil {
+			result = map[string]bool{"stopped": c.projectLogsHandler.Stop(stopReq.StreamID)}
+		}
+
+	case "collect_diagnostics":
+		var diagReq handlers.DiagnosticsRequest
+		if err = protocol.ParseCommand(msg, &diagReq); err == nil {
+			// Chunks and the result arrive as diagnostics_* events
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.diagnosticsHandler.Collect(context.Background(), diagReq)
+			}()
+			result = map[string]string{"status": "diagnostics_started"}
+		}
+
+	case "volume_size":
+		// Estimate volume disk usage before a backup
+		var sizeReq struct {
+			VolumeName string `json:"volume_name"`
+		}
+		if err = protocol.ParseCommand(msg, &sizeReq); err == nil {
+			result, err = c.volumeHandler.EstimateSize(ctx, sizeReq.VolumeName)
+		}
+
+	case "backup_volume":
+		var backupReq handlers.VolumeBackupRequest
+		if err = protocol.ParseCommand(msg, &backupReq); err == nil {
+			// Archives can be large, so run detached; chunks and the result
+			// arrive as volume_backup_chunk / volume_backup_complete events
+			c.longRunningWg.Add(1)
+			go func() { // #nosec G118
+				defer c.longRunningWg.Done()
+				c.volumeHandler.Backup(context.Background(), backupReq)
+			}()
+			result = map[string]string{"status": "backup_started"}
+		}
+
+	case "restore_volume":
+		var restoreReq handlers.VolumeRestoreRequest
+		if err = protocol.ParseCommand(msg, &restoreReq); err == nil {
+			if restoreReq.Source == "local" {
+				// Reads the whole archive from disk, so run detached like backup_volume
+				c.longRunningWg.Add(1)
+				go func() { // #nosec G118
+					defer c.longRunningWg.Done()
+					if restoreErr := c.volumeHandler.StartRestore(context.Background(), restoreReq); restoreErr != nil {
+						c.log.WithError(restoreErr).Error("Volume restore failed")
+					}
+				}()
+				result = map[string]string{"status": "restore_started"}
+			} else if err = c.volumeHandler.StartRestore(ctx, restoreReq); err == nil {
+				// Helper is waiting on stdin; the backend now sends restore_volume_chunk
+				result = map[string]string{"status": "restore_ready"}
+			}
+		}
+
+	case "restore_volume_chunk":
+		var chunk handlers.VolumeRestoreChunk
+		if err = protocol.ParseCommand(msg, &chunk); err == nil {
+			if err = c.volumeHandler.WriteRestoreChunk(chunk); err == nil {
+				result = map[string]interface{}{"restore_id": chunk.RestoreID, "seq": chunk.Seq}
+			}
+		}
+
 	default:
 		err = fmt.Errorf("unknown command: %s", msg.Command)
 	}
+	return result, err
+}
 
-	// Send response
-	resp := protocol.NewCommandResponse(msg.ID, result, err)
-	if sendErr := c.sendMessage(resp); sendErr != nil {
-		c.log.WithError(sendErr).Error("Failed to send response")
+// stopStrategyFromPayload decodes the optional stop_strategy of a container
+// operation
+func stopStrategyFromPayload(payload map[string]interface{}) (*sharedDocker.StopStrategy, error) {
+	raw, ok := payload["stop_strategy"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop_strategy: %w", err)
+	}
+	var strategy sharedDocker.StopStrategy
+	if err := json.Unmarshal(data, &strategy); err != nil {
+		return nil, fmt.Errorf("invalid stop_strategy: %w", err)
+	}
+	if err := strategy.Validate(); err != nil {
+		return nil, err
 	}
+	return &strategy, nil
 }
 
 // handleContainerOperation handles container operation messages (v2.2.0)
@@ -929,18 +1760,49 @@ func (c *WebSocketClient) handleContainerOperation(ctx context.Context, msg *typ
 		"container_id":   containerID,
 		"correlation_id": correlationID,
 	})
-	if action == "get_logs" || action == "inspect" {
+	if action == "get_logs" || action == "inspect" || action == "stats" {
 		logEntry.Debug("Handling container operation")
 	} else {
 		logEntry.Info("Handling container operation")
 	}
 
-	// Execute operation
-	var err error
-	response := map[string]interface{}{
-		"correlation_id": correlationID,
+	// Execute operation under its deadline; stop and restart get their
+	// grace period on top
+	var extra time.Duration
+	if action == "stop" || action == "restart" {
+		extra = c.stopGrace(ctx, action, containerID, payload)
+	}
+	result, err := c.deadlines.run(ctx, action, commandTimeout(action, payload, extra), func(ctx context.Context) (interface{}, error) {
+		return c.containerOperation(ctx, action, containerID, payload)
+	})
+	response, _ := result.(map[string]interface{})
+	if response == nil {
+		response = map[string]interface{}{}
+	}
+	response["correlation_id"] = correlationID
+
+	// Add error to response if operation failed
+	if err != nil {
+		response["success"] = false
+		response["error"] = err.Error()
+		if code := protocol.ErrorCode(err); code != "" {
+			response["error_code"] = code
+		}
+		c.log.WithError(err).WithField("action", action).Error("Container operation failed")
 	}
 
+	// Send response with correlation_id
+	if sendErr := c.sendJSON(response); sendErr != nil {
+		c.log.WithError(sendErr).Error("Failed to send container operation response")
+	}
+}
+
+// containerOperation executes a container operation, returning the
+// response fields
+func (c *WebSocketClient) containerOperation(ctx context.Context, action, containerID string, payload map[string]interface{}) (map[string]interface{}, error) {
+	var err error
+	response := map[string]interface{}{}
+
 	switch action {
 	case "start":
 		err = c.docker.StartContainer(ctx, containerID)
@@ -951,11 +1813,15 @@ func (c *WebSocketClient) handleContainerOperation(ctx context.Context, msg *typ
 		}
 
 	case "stop":
-		timeout := 10 // default
-		if t, ok := payload["timeout"].(float64); ok {
-			timeout = int(t)
+		// An explicit timeout wins over the container's StopTimeout; the
+		// default doesn't
+		var strategy *sharedDocker.StopStrategy
+		if strategy, err = stopStrategyFromPayload(payload); err == nil {
+			if t, ok := payload["timeout"].(float64); ok {
+				strategy = strategy.WithTimeout(int(t))
+			}
+			err = c.docker.StopContainer(ctx, containerID, 10, strategy)
 		}
-		err = c.docker.StopContainer(ctx, containerID, timeout)
 		if err == nil {
 			response["success"] = true
 			response["container_id"] = containerID
@@ -991,8 +1857,15 @@ func (c *WebSocketClient) handleContainerOperation(ctx context.Context, msg *typ
 		if t, ok := payload["tail"].(float64); ok {
 			tail = fmt.Sprintf("%.0f", t)
 		}
+		// timestamps ("utc", "none", "local" or "relative") and tz (IANA
+		// name, for local) render the line timestamps for display
+		var ts sharedDocker.LogTimestamps
+		mode, _ := payload["timestamps"].(string)
+		tz, _ := payload["tz"].(string)
 		var logs string
-		logs, err = c.docker.GetContainerLogs(ctx, containerID, tail)
+		if ts, err = sharedDocker.ParseLogTimestamps(mode, tz); err == nil {
+			logs, err = c.docker.GetContainerLogs(ctx, containerID, tail, ts)
+		}
 		if err == nil {
 			response["success"] = true
 			response["logs"] = logs
@@ -1006,6 +1879,22 @@ func (c *WebSocketClient) handleContainerOperation(ctx context.Context, msg *typ
 			response["container"] = containerJSON
 		}
 
+	case "stats":
+		// One-shot sample; memory_mode ("working_set" or "raw") overrides
+		// STATS_MEMORY_MODE for this call
+		var mode sharedDocker.MemoryMode
+		if m, _ := payload["memory_mode"].(string); m != "" {
+			mode, err = sharedDocker.ParseMemoryMode(m)
+		}
+		if err == nil {
+			var stats map[string]interface{}
+			stats, err = c.statsHandler.ContainerStatsSnapshot(ctx, containerID, mode)
+			if err == nil {
+				response["success"] = true
+				response["stats"] = stats
+			}
+		}
+
 	case "kill":
 		err = c.docker.KillContainer(ctx, containerID)
 		if err == nil {
@@ -1030,18 +1919,7 @@ func (c *WebSocketClient) handleContainerOperation(ctx context.Context, msg *typ
 	default:
 		err = fmt.Errorf("unknown action: %s", action)
 	}
-
-	// Add error to response if operation failed
-	if err != nil {
-		response["success"] = false
-		response["error"] = err.Error()
-		c.log.WithError(err).WithField("action", action).Error("Container operation failed")
-	}
-
-	// Send response with correlation_id
-	if sendErr := c.sendJSON(response); sendErr != nil {
-		c.log.WithError(sendErr).Error("Failed to send container operation response")
-	}
+	return response, err
 }
 
 // streamEvents streams Docker events to DockMon
@@ -1063,16 +1941,33 @@ func (c *WebSocketClient) streamEvents(ctx context.Context) {
 			c.log.Info("Event streaming: stop signal received")
 			return
 		case err := <-errChan:
-			c.log.WithError(err).Error("Event stream error")
-			return
+			if !c.recoverEventStream(ctx, err) {
+				return
+			}
+			c.docker.ResetStartedAtCache()
+			eventChan, errChan = c.docker.WatchEvents(ctx)
 		case event := <-eventChan:
-			// Filter for container events
+			// Image pulls/tags/deletes change RepoDigests
+			if event.Type == "image" {
+				c.docker.InvalidateImageDigests()
+			}
+
+			// Forward image/volume/network changes for the host audit trail
 			if event.Type != "container" {
+				if sharedDocker.IsAuditedResourceEvent(event) {
+					c.sendResourceEvent(event)
+				}
 				continue
 			}
 
-			// Convert to our event type
 			action := string(event.Action) // Convert typed Action to string
+
+			// Healthcheck execs fire constantly and don't change the inventory
+			if !strings.HasPrefix(action, "exec_") && !strings.HasPrefix(action, "health_status") {
+				c.inventoryHandler.Notify()
+			}
+
+			// Convert to our event type
 			containerEvent := types.ContainerEvent{
 				ContainerID:   event.Actor.ID,
 				ContainerName: event.Actor.Attributes["name"],
@@ -1095,6 +1990,8 @@ func (c *WebSocketClient) streamEvents(ctx context.Context) {
 				}
 				c.docker.RecordStartedAt(event.Actor.ID, startedAt)
 
+				// Docker includes the container's labels in the event attributes
+				c.statsHandler.SetContainerGroup(event.Actor.ID, sharedDocker.ContainerGroup(event.Actor.Attributes))
 				if err := c.statsHandler.StartContainerStats(ctx, event.Actor.ID, event.Actor.Attributes["name"]); err != nil {
 					shortID := event.Actor.ID
 					if len(shortID) > 12 {
@@ -1105,9 +2002,33 @@ func (c *WebSocketClient) streamEvents(ctx context.Context) {
 			case "die", "stop", "kill":
 				// Cache retained: last-started is still useful while stopped.
 				c.statsHandler.StopContainerStats(event.Actor.ID)
+				// Deaths during planned maintenance aren't instability
+				if _, inMaintenance := c.maintenance.Active(); action == "die" && !inMaintenance {
+					if loop, started := c.crashLoops.RecordDeath(event.Actor.ID, containerEvent.ContainerName, event.Actor.Attributes["exitCode"]); started {
+						c.backgroundWg.Add(1)
+						go c.reportCrashLoop(ctx, loop)
+					}
+				}
+			case "pause":
+				// A paused container's stream would only repeat its last
+				// figures; stop it and report the container as paused
+				c.statsHandler.PauseContainerStats(event.Actor.ID, event.Actor.Attributes["name"])
+			case "unpause":
+				if err := c.statsHandler.ResumeContainerStats(ctx, event.Actor.ID, event.Actor.Attributes["name"]); err != nil {
+					c.log.WithError(err).WithField("container", event.Actor.ID).Warn("Failed to resume stats for container")
+				}
 			case "destroy":
 				c.docker.EvictContainerCache(event.Actor.ID)
+				c.crashLoops.Forget(event.Actor.ID)
+			}
+
+			// During maintenance, expected stop/start noise isn't forwarded
+			// and everything else is tagged
+			tag, forward := c.maintenance.Filter(action)
+			if !forward {
+				continue
 			}
+			containerEvent.Maintenance = tag
 
 			// Send event
 			eventMsg := protocol.NewEvent("container_event", containerEvent)
@@ -1118,39 +2039,124 @@ func (c *WebSocketClient) streamEvents(ctx context.Context) {
 	}
 }
 
-// sendMessage sends a message over WebSocket
+// reportCrashLoop sends a crash_loop event with the container's last log
+// lines, and resyncs the inventory so its CrashLoop flag goes out
+func (c *WebSocketClient) reportCrashLoop(ctx context.Context, loop crashloop.Loop) {
+	defer c.backgroundWg.Done()
+
+	c.log.WithFields(logrus.Fields{
+		"container":  loop.ContainerName,
+		"deaths":     loop.Deaths,
+		"exit_codes": loop.ExitCodes,
+	}).Warn("Container is crash looping")
+
+	logCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	logs, err := c.docker.GetContainerLogs(logCtx, loop.ContainerID, strconv.Itoa(crashLoopLogLines), sharedDocker.LogTimestamps{})
+	cancel()
+	if err != nil {
+		c.log.WithError(err).Debug("Failed to read crash looping container's logs")
+	} else {
+		loop.LogLines = strings.Split(strings.TrimRight(logs, "\n"), "\n")
+	}
+
+	if err := c.sendMessage(protocol.NewEvent("crash_loop", loop)); err != nil {
+		c.log.WithError(err).Warn("Failed to send crash loop event")
+	}
+	c.inventoryHandler.Notify()
+}
+
+// sendResourceEvent forwards an image, volume or network event
+func (c *WebSocketClient) sendResourceEvent(event events.Message) {
+	resourceEvent := types.ResourceEvent{
+		Type:       string(event.Type),
+		Action:     string(event.Action),
+		ActorID:    event.Actor.ID,
+		Name:       sharedDocker.ResourceEventName(event),
+		Timestamp:  time.Unix(event.Time, 0),
+		Attributes: event.Actor.Attributes,
+	}
+	resourceEvent.Maintenance, _ = c.maintenance.Filter("")
+
+	if err := c.sendMessage(protocol.NewEvent("resource_event", resourceEvent)); err != nil {
+		c.log.WithError(err).Warn("Failed to send resource event")
+	}
+}
+
+// sendMessage queues a message for the connection's writer (see outbound.go)
 func (c *WebSocketClient) sendMessage(msg *types.Message) error {
 	data, err := protocol.EncodeMessage(msg)
 	if err != nil {
 		return fmt.Errorf("failed to encode message: %w", err)
 	}
 
-	c.connMu.Lock()
-	defer c.connMu.Unlock()
-
-	if c.conn == nil {
-		return fmt.Errorf("connection not established")
+	frame := &outboundFrame{messageType: websocket.TextMessage, data: data}
+	if msg.Type == "event" {
+		if allowed, feature := c.negotiated.Load().AllowsEvent(msg.Command); !allowed {
+			if _, logged := c.suppressed.LoadOrStore(feature, true); !logged {
+				c.log.WithFields(logrus.Fields{
+					"event":   msg.Command,
+					"feature": feature,
+				}).Warn("DockMon does not support this feature; not sending its events")
+			}
+			return fmt.Errorf("%w: %s", errFeatureNotNegotiated, feature)
+		}
+		frame.key = coalesceKey(msg.Command, msg.Payload)
+	}
+	if err := c.outbound.Push(frame, false); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
 	}
+	return nil
+}
 
-	// Set write deadline to prevent blocking indefinitely on slow/congested networks
-	if err := c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
-		c.log.WithError(err).Debug("Failed to set write deadline")
+// sendResponse sends a command response, split into response_chunk messages
+// when it is larger than protocol.ChunkThreshold and DockMon reassembles
+// them. A response over protocol.MaxResponseSize is replaced by an error so
+// neither side buffers it.
+func (c *WebSocketClient) sendResponse(resp *types.Message) error {
+	data, err := protocol.EncodeMessage(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
 	}
-	err = c.conn.WriteMessage(websocket.TextMessage, data)
-	if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
-		c.log.WithError(err).Debug("Failed to clear write deadline")
+
+	if len(data) > protocol.MaxResponseSize {
+		c.log.WithFields(logrus.Fields{
+			"id":    resp.ID,
+			"bytes": len(data),
+			"limit": protocol.MaxResponseSize,
+		}).Warn("Command response too large, sending an error instead")
+		tooLarge := fmt.Errorf("%w: %d bytes exceeds the %d byte limit", protocol.ErrResponseTooLarge, len(data), protocol.MaxResponseSize)
+		return c.sendMessage(protocol.NewCommandResponse(resp.ID, nil, tooLarge))
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed to write message: %w", err)
+	if len(data) <= protocol.ChunkThreshold || !c.negotiated.Load().Has(protocol.FeatureResponseChunks) {
+		if err := c.outbound.Push(&outboundFrame{messageType: websocket.TextMessage, data: data}, false); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		return nil
 	}
 
+	chunks := protocol.ChunkResponse(resp.ID, data, protocol.ChunkSize)
+	c.log.WithFields(logrus.Fields{
+		"id":     resp.ID,
+		"bytes":  len(data),
+		"chunks": len(chunks),
+	}).Debug("Sending chunked command response")
+	for _, chunk := range chunks {
+		// A missing chunk spoils the rest, so stop at the first failure
+		if err := c.sendMessage(chunk); err != nil {
+			return fmt.Errorf("failed to send response chunk %d of %d: %w", chunk.Payload.(protocol.ResponseChunk).Seq+1, len(chunks), err)
+		}
+	}
 	return nil
 }
 
 // sendEvent is a helper that wraps sendMessage for event-style messages
-// Used by handlers (e.g., stats handler) to send events
+// Used by handlers (e.g., stats handler) to send events. Progress events go
+// out in the legacy format unless DockMon negotiated the progress schema.
 func (c *WebSocketClient) sendEvent(eventType string, payload interface{}) error {
+	if !c.negotiated.Load().Has(protocol.FeatureProgressSchema) {
+		payload = protocol.LegacyProgress(eventType, payload)
+	}
 	msg := protocol.NewEvent(eventType, payload)
 	return c.sendMessage(msg)
 }
@@ -1163,26 +2169,15 @@ func (c *WebSocketClient) sendJSON(data interface{}) error {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
-	c.connMu.Lock()
-	defer c.connMu.Unlock()
-
-	if c.conn == nil {
-		return fmt.Errorf("connection not established")
-	}
-
-	// Set write deadline to prevent blocking indefinitely on slow/congested networks
-	if err := c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second)); err != nil {
-		c.log.WithError(err).Debug("Failed to set write deadline")
-	}
-	err = c.conn.WriteMessage(websocket.TextMessage, jsonData)
-	if err := c.conn.SetWriteDeadline(time.Time{}); err != nil {
-		c.log.WithError(err).Debug("Failed to clear write deadline")
+	frame := &outboundFrame{messageType: websocket.TextMessage, data: jsonData}
+	if m, ok := data.(map[string]interface{}); ok {
+		if msgType, ok := m["type"].(string); ok {
+			frame.key = coalesceKey(msgType, m)
+		}
 	}
-
-	if err != nil {
-		return fmt.Errorf("failed to write JSON message: %w", err)
+	if err := c.outbound.Push(frame, false); err != nil {
+		return fmt.Errorf("failed to send JSON message: %w", err)
 	}
-
 	return nil
 }
 
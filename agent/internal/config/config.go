@@ -1,12 +1,20 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
+	"github.com/docker/go-units"
 )
 
 // Config holds all agent configuration
@@ -16,14 +24,34 @@ type Config struct {
 	RegistrationToken  string
 	PermanentToken     string
 	InsecureSkipVerify bool
+	// DockMonCAFile is a PEM bundle of CAs trusted for DockMon's certificate
+	// on top of the system roots; DockMonCertPins are SHA-256 fingerprints
+	// its certificate must match. See DockMonTLSConfig.
+	DockMonCAFile   string
+	DockMonCertPins [][sha256.Size]byte
+	// PathPrefix is prepended to DockMon's API paths when it is served under
+	// a subpath, e.g. "/dockmon"; empty when served at the root
+	PathPrefix string
+	// ExtraHeaders are sent on every connection to DockMon, e.g. Cloudflare
+	// Access service tokens or credentials for an intermediary proxy
+	ExtraHeaders http.Header
 
 	// Docker connection
 	DockerHost       string
+	// DockerHostSource is how DockerHost was chosen: "env" (DOCKER_HOST),
+	// "detected" (a socket found among DockerSocketCandidates) or "default"
+	DockerHostSource string
+	// DockerSocketCandidates are the sockets tried when DOCKER_HOST is
+	// unset, in detection order
+	DockerSocketCandidates []string
 	DockerCertPath   string
 	DockerTLSVerify  bool
 
 	// Agent identity
 	AgentVersion     string
+	// Build metadata, set from -ldflags at startup (see diagnostics.ReadBuild)
+	AgentCommit      string
+	AgentBuildDate   string
 	ProtoVersion     string
 	AgentName        string
 	// ForceUniqueRegistration tells the backend to skip its engine_id
@@ -40,11 +68,55 @@ type Config struct {
 	UpdateLockPath   string
 	UpdateTimeout    time.Duration
 
+	// Headroom the update preflight keeps free on the Docker root
+	// filesystem; negative disables the check
+	UpdateMinFreeSpace int64
+
 	// Stack storage - persistent directory for compose deployments
 	StacksDir        string
 	// Host-side stacks path for resolving relative bind mounts in containerized agents
 	HostStacksDir    string
 
+	// Local notifications, sent straight from the host for critical events
+	// while DockMon has been unreachable for LocalNotifyAfter. Disabled when
+	// LocalNotifyURL is empty.
+	LocalNotifyURL    string
+	LocalNotifyType   string   // webhook, ntfy or gotify
+	LocalNotifyToken  string   // ntfy access token or Gotify application token
+	LocalNotifyEvents []string // oom, crash_loop, update_failed
+	LocalNotifyAfter  time.Duration
+
+	// Crash loop detection: a container dying more than CrashLoopThreshold
+	// times within CrashLoopWindow is reported as crash looping
+	CrashLoopThreshold int
+	CrashLoopWindow    time.Duration
+
+	// Named volume backups: local destination directory and the helper
+	// image that mounts the volume and runs tar
+	VolumeBackupDir   string
+	VolumeHelperImage string
+
+	// Image for net_test helpers; empty uses the shared default (Alpine,
+	// whose wget can check https URLs)
+	NetTestImage string
+
+	// Local destination for container filesystem exports
+	ContainerExportDir string
+
+	// Memory figure reported as container memory usage: "working_set"
+	// (excludes reclaimable page cache) or "raw"
+	StatsMemoryMode string
+
+	// Optional pprof/expvar listener for profiling; empty disables it.
+	// Loopback only unless PprofAllowRemote is set.
+	PprofAddr        string
+	PprofAllowRemote bool
+
+	// Resource limits for small hosts: concurrent Docker API calls, pull
+	// bandwidth and the agent's CPU/I/O priority. Changeable at runtime
+	// with the set_throttle command.
+	Throttle throttle.Settings
+
 	// Logging
 	LogLevel         string
 	LogJSON          bool
@@ -59,8 +131,6 @@ func LoadFromEnv() (*Config, error) {
 		PermanentToken:     os.Getenv("PERMANENT_TOKEN"),
 		InsecureSkipVerify: getEnvBool("INSECURE_SKIP_VERIFY", false),
 
-		// Docker/Podman (auto-detects socket if DOCKER_HOST not set)
-		DockerHost:       getEnvOrDefault("DOCKER_HOST", detectContainerSocket()),
 		DockerCertPath:   os.Getenv("DOCKER_CERT_PATH"),
 		DockerTLSVerify:  getEnvBool("DOCKER_TLS_VERIFY", false),
 
@@ -68,7 +138,9 @@ func LoadFromEnv() (*Config, error) {
 		// 1.1: agent dual-sends container_stats to stats-service /api/stats/ws/ingest
 		// for historical persistence (spec §10). Older agents (1.0) continue to feed
 		// Python's in-memory buffer only — live sparklines still work but no history.
-		ProtoVersion:     getEnvOrDefault("PROTO_VERSION", "1.1"),
+		// 1.2: agent and backend negotiate optional features at registration;
+		// events the backend did not accept are not sent (protocol.Negotiate).
+		ProtoVersion:     getEnvOrDefault("PROTO_VERSION", "1.2"),
 
 		// Optional display-name override sent during registration. If empty, agent
 		// falls back to Docker daemon hostname -> OS hostname -> engine_id.
@@ -84,26 +156,91 @@ func LoadFromEnv() (*Config, error) {
 		ReconnectMax:     getEnvDuration("RECONNECT_MAX", 60*time.Second),
 
 		// Update
-		DataPath:         getEnvOrDefault("DATA_PATH", "/data"),
+		DataPath:         getEnvOrDefault("DATA_PATH", defaultDataPath(runtime.GOOS)),
 		UpdateTimeout:    getEnvDuration("UPDATE_TIMEOUT", 120*time.Second),
 
+		// Local notifications
+		LocalNotifyURL:    strings.TrimSpace(os.Getenv("LOCAL_NOTIFY_URL")),
+		LocalNotifyType:   getEnvOrDefault("LOCAL_NOTIFY_TYPE", "webhook"),
+		LocalNotifyToken:  os.Getenv("LOCAL_NOTIFY_TOKEN"),
+		LocalNotifyEvents: getEnvList("LOCAL_NOTIFY_EVENTS", []string{"oom", "crash_loop", "update_failed"}),
+		LocalNotifyAfter:  getEnvDuration("LOCAL_NOTIFY_AFTER", 5*time.Minute),
+
+		// Crash loop detection
+		CrashLoopThreshold: getEnvInt("CRASH_LOOP_THRESHOLD", 5),
+		CrashLoopWindow:    getEnvDuration("CRASH_LOOP_WINDOW", 10*time.Minute),
+
 		// Logging
 		LogLevel:         getEnvOrDefault("LOG_LEVEL", "info"),
 		LogJSON:          getEnvBool("LOG_JSON", true),
 	}
 
+	// Docker/Podman, rootful or rootless (auto-detects socket if DOCKER_HOST not set)
+	cfg.DockerSocketCandidates = socketCandidates(userRuntimeDir(), os.Geteuid() == 0)
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		cfg.DockerHost, cfg.DockerHostSource = host, "env"
+	} else {
+		cfg.DockerHost, cfg.DockerHostSource = detectContainerSocket(cfg.DockerSocketCandidates)
+	}
+
 	// Derived paths
 	cfg.UpdateLockPath = filepath.Join(cfg.DataPath, "update.lock")
 
+	// Disk space preflight before update pulls; UPDATE_MIN_FREE_SPACE=0 disables it
+	cfg.UpdateMinFreeSpace = getEnvSize("UPDATE_MIN_FREE_SPACE", 1<<30)
+	if cfg.UpdateMinFreeSpace == 0 {
+		cfg.UpdateMinFreeSpace = -1
+	}
+
 	// Stack storage directory - default to $DATA_PATH/stacks, allow override with AGENT_STACKS_DIR
 	cfg.StacksDir = getEnvOrDefault("AGENT_STACKS_DIR", filepath.Join(cfg.DataPath, "stacks"))
 	cfg.HostStacksDir = os.Getenv("HOST_STACKS_DIR")
 
+	// Volume backups default to $DATA_PATH/backups
+	cfg.VolumeBackupDir = getEnvOrDefault("VOLUME_BACKUP_DIR", filepath.Join(cfg.DataPath, "backups"))
+	cfg.VolumeHelperImage = getEnvOrDefault("VOLUME_HELPER_IMAGE", "busybox:stable")
+	cfg.NetTestImage = os.Getenv("NET_TEST_IMAGE")
+	cfg.ContainerExportDir = getEnvOrDefault("CONTAINER_EXPORT_DIR", filepath.Join(cfg.DataPath, "exports"))
+	cfg.StatsMemoryMode = strings.ToLower(getEnvOrDefault("STATS_MEMORY_MODE", "working_set"))
+	cfg.PprofAddr = strings.TrimSpace(os.Getenv("PPROF_ADDR"))
+	cfg.PprofAllowRemote = getEnvBool("PPROF_ALLOW_REMOTE", false)
+
+	// Resource limits, all off by default. THROTTLE_PULL_BANDWIDTH is a size
+	// per second ("5MB").
+	cfg.Throttle = throttle.Settings{
+		MaxDockerCalls: getEnvInt("THROTTLE_MAX_DOCKER_CALLS", 0),
+		PullBandwidth:  getEnvSize("THROTTLE_PULL_BANDWIDTH", 0),
+		Nice:           getEnvInt("AGENT_NICE", 0),
+		IOClass:        strings.ToLower(strings.TrimSpace(os.Getenv("AGENT_IO_CLASS"))),
+	}
+	// The level only means something in the best-effort class
+	if cfg.Throttle.IOClass == throttle.IOClassBestEffort {
+		cfg.Throttle.IOLevel = getEnvInt("AGENT_IO_LEVEL", 4)
+	}
+
 	// Validation
 	if cfg.DockMonURL == "" {
 		return nil, fmt.Errorf("DOCKMON_URL is required")
 	}
 
+	cfg.PathPrefix = normalizePathPrefix(os.Getenv("DOCKMON_PATH_PREFIX"))
+	headers, err := parseHeaders(os.Getenv("DOCKMON_HEADERS"))
+	if err != nil {
+		return nil, fmt.Errorf("DOCKMON_HEADERS: %w", err)
+	}
+	cfg.ExtraHeaders = headers
+
+	cfg.DockMonCAFile = strings.TrimSpace(os.Getenv("DOCKMON_CA_FILE"))
+	pins, err := parseCertPins(os.Getenv("DOCKMON_CERT_SHA256"))
+	if err != nil {
+		return nil, fmt.Errorf("DOCKMON_CERT_SHA256: %w", err)
+	}
+	cfg.DockMonCertPins = pins
+	// Fail at startup on an unreadable CA bundle rather than on every dial
+	if _, err := cfg.DockMonTLSConfig(); err != nil {
+		return nil, fmt.Errorf("DOCKMON_CA_FILE: %w", err)
+	}
+
 	// Backend caps host name at 255 chars; reject early with a clear error
 	// instead of letting registration fail silently in the reconnect loop.
 	if len(cfg.AgentName) > 255 {
@@ -117,6 +254,18 @@ func LoadFromEnv() (*Config, error) {
 		return nil, fmt.Errorf("FORCE_UNIQUE_REGISTRATION=true requires AGENT_NAME to also be set")
 	}
 
+	if cfg.StatsMemoryMode != "working_set" && cfg.StatsMemoryMode != "raw" {
+		return nil, fmt.Errorf("STATS_MEMORY_MODE must be working_set or raw (got %q)", cfg.StatsMemoryMode)
+	}
+
+	if cfg.CrashLoopThreshold < 1 || cfg.CrashLoopWindow <= 0 {
+		return nil, fmt.Errorf("CRASH_LOOP_THRESHOLD must be at least 1 and CRASH_LOOP_WINDOW positive")
+	}
+
+	if err := cfg.Throttle.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid resource limits: %w", err)
+	}
+
 	// Try to load permanent token from persisted file
 	if cfg.PermanentToken == "" {
 		tokenPath := filepath.Join(cfg.DataPath, "permanent_token")
@@ -132,6 +281,82 @@ func LoadFromEnv() (*Config, error) {
 	return cfg, nil
 }
 
+// ServerURL returns DOCKMON_URL with the path prefix applied and no trailing
+// slash; API paths such as "/api/agent/ws" are appended to it
+func (c *Config) ServerURL() string {
+	return strings.TrimRight(c.DockMonURL, "/") + c.PathPrefix
+}
+
+// normalizePathPrefix returns the prefix with a leading slash and without a
+// trailing one ("dockmon/" becomes "/dockmon", "/" becomes "")
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// reservedHeaders are set by the agent or the WebSocket handshake itself
+var reservedHeaders = map[string]bool{
+	"Host":                     true,
+	"Upgrade":                  true,
+	"Connection":               true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Protocol":   true,
+}
+
+// parseHeaders parses either a JSON object of header names to values, or
+// "Name: value" lines. Values may contain any character, including ';'.
+// Returns nil when value is empty.
+func parseHeaders(value string) (http.Header, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var pairs [][2]string
+	if strings.HasPrefix(value, "{") {
+		var object map[string]string
+		if err := json.Unmarshal([]byte(value), &object); err != nil {
+			return nil, fmt.Errorf("invalid JSON header object: %w", err)
+		}
+		for name, val := range object {
+			pairs = append(pairs, [2]string{name, val})
+		}
+	} else {
+		for _, line := range strings.Split(value, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			name, val, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", strings.TrimSpace(line))
+			}
+			pairs = append(pairs, [2]string{name, val})
+		}
+	}
+
+	headers := http.Header{}
+	for _, pair := range pairs {
+		name := strings.TrimSpace(pair[0])
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if reservedHeaders[name] {
+			return nil, fmt.Errorf("header %s cannot be overridden", name)
+		}
+		if strings.ContainsAny(pair[1], "\r\n") {
+			return nil, fmt.Errorf("header %s has a line break in its value", name)
+		}
+		headers.Add(name, strings.TrimSpace(pair[1]))
+	}
+	return headers, nil
+}
+
 // getEnvOrDefault returns environment variable value or default
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -150,6 +375,16 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvInt returns environment variable as integer
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 // getEnvDuration returns environment variable as duration
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
@@ -160,22 +395,101 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
-// detectContainerSocket finds the first available container runtime socket.
-// Checks common locations for Docker and Podman in order of preference.
-func detectContainerSocket() string {
-	// Common socket paths in order of preference
-	sockets := []string{
-		"/var/run/docker.sock",     // Docker (most common)
-		"/run/docker.sock",         // Docker (alternative location)
-		"/run/podman/podman.sock",  // Podman rootful
+// getEnvSize returns environment variable as a byte size ("512MB", "2GB";
+// binary units, as in docker run --memory)
+func getEnvSize(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := units.RAMInBytes(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList returns a comma-separated environment variable as a list,
+// skipping empty entries
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
+	return list
+}
 
-	for _, sock := range sockets {
+// userRuntimeDir returns the directory rootless Docker and Podman put their
+// sockets in: $XDG_RUNTIME_DIR, else /run/user/<uid> for non-root users.
+// Empty for root without XDG_RUNTIME_DIR and on Windows.
+func userRuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	if uid := os.Geteuid(); uid > 0 {
+		return "/run/user/" + strconv.Itoa(uid)
+	}
+	return ""
+}
+
+// socketCandidates lists the container runtime sockets to try, in order.
+// Rootless sockets in runtimeDir come first for a non-root agent, since a
+// rootless daemon is the one it can use; root tries them last.
+func socketCandidates(runtimeDir string, root bool) []string {
+	system := []string{
+		"/var/run/docker.sock",    // Docker (most common)
+		"/run/docker.sock",        // Docker (alternative location)
+		"/run/podman/podman.sock", // Podman rootful
+	}
+	if runtimeDir == "" {
+		return system
+	}
+	rootless := []string{
+		path.Join(runtimeDir, "docker.sock"),          // Docker rootless
+		path.Join(runtimeDir, "podman", "podman.sock"), // Podman rootless
+	}
+	if root {
+		return append(system, rootless...)
+	}
+	return append(rootless, system...)
+}
+
+// detectContainerSocket finds the first available container runtime socket
+// among candidates, returning its DOCKER_HOST form and "detected", or the
+// platform default and "default" when none exists.
+func detectContainerSocket(candidates []string) (string, string) {
+	// Docker Desktop on Windows only exposes the engine on a named pipe
+	if runtime.GOOS == "windows" {
+		return "npipe:////./pipe/docker_engine", "default"
+	}
+
+	for _, sock := range candidates {
 		if info, err := os.Stat(sock); err == nil && (info.Mode()&os.ModeSocket) != 0 {
-			return "unix://" + sock
+			return "unix://" + sock, "detected"
 		}
 	}
 
 	// Fallback to Docker default (will error if not available, but that's expected)
-	return "unix:///var/run/docker.sock"
+	return "unix:///var/run/docker.sock", "default"
+}
+
+// defaultDataPath returns the data directory used when DATA_PATH is unset.
+// Containers and Linux installs mount /data; native Windows and macOS
+// services use the platform's system-wide application data location.
+func defaultDataPath(goos string) string {
+	switch goos {
+	case "windows":
+		programData := os.Getenv("ProgramData")
+		if programData == "" {
+			programData = `C:\ProgramData`
+		}
+		return filepath.Join(programData, "DockMon", "agent")
+	case "darwin":
+		return "/Library/Application Support/DockMon/agent"
+	default:
+		return "/data"
+	}
 }
@@ -1,8 +1,15 @@
 package config
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
 )
 
 func TestLoadFromEnv_AgentName_Unset(t *testing.T) {
@@ -121,3 +128,287 @@ func TestLoadFromEnv_ForceUniqueRegistration_RequiresAgentName(t *testing.T) {
 		t.Errorf("error message = %q, want it to mention AGENT_NAME", err.Error())
 	}
 }
+
+func TestDefaultDataPath(t *testing.T) {
+	if got := defaultDataPath("linux"); got != "/data" {
+		t.Errorf("linux: got %q, want /data", got)
+	}
+	if got := defaultDataPath("darwin"); got != "/Library/Application Support/DockMon/agent" {
+		t.Errorf("darwin: got %q", got)
+	}
+
+	t.Setenv("ProgramData", "/programdata")
+	if got := defaultDataPath("windows"); got != filepath.Join("/programdata", "DockMon", "agent") {
+		t.Errorf("windows: got %q", got)
+	}
+}
+
+func TestLoadFromEnv_LocalNotifyDefaults(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("LOCAL_NOTIFY_URL", "")
+	t.Setenv("LOCAL_NOTIFY_EVENTS", "")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if cfg.LocalNotifyURL != "" {
+		t.Errorf("LocalNotifyURL = %q, want empty (disabled)", cfg.LocalNotifyURL)
+	}
+	if strings.Join(cfg.LocalNotifyEvents, ",") != "oom,crash_loop,update_failed" {
+		t.Errorf("LocalNotifyEvents = %v, want all critical events", cfg.LocalNotifyEvents)
+	}
+}
+
+func TestLoadFromEnv_LocalNotifyEventsList(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("LOCAL_NOTIFY_EVENTS", " oom, ,update_failed ")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if strings.Join(cfg.LocalNotifyEvents, ",") != "oom,update_failed" {
+		t.Errorf("LocalNotifyEvents = %v, want [oom update_failed]", cfg.LocalNotifyEvents)
+	}
+}
+
+func TestLoadFromEnv_UpdateMinFreeSpace(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"", 1 << 30},
+		{"2GB", 2 << 30},
+		{"512m", 512 << 20},
+		{"0", -1},
+		{"lots", 1 << 30},
+	}
+	for _, tt := range tests {
+		t.Setenv("UPDATE_MIN_FREE_SPACE", tt.value)
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv returned error: %v", err)
+		}
+		if cfg.UpdateMinFreeSpace != tt.want {
+			t.Errorf("UPDATE_MIN_FREE_SPACE=%q: got %d, want %d", tt.value, cfg.UpdateMinFreeSpace, tt.want)
+		}
+	}
+}
+
+func TestLoadFromEnv_StatsMemoryMode(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+
+	for value, want := range map[string]string{"": "working_set", "raw": "raw", "Working_Set": "working_set"} {
+		t.Setenv("STATS_MEMORY_MODE", value)
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("STATS_MEMORY_MODE=%q: %v", value, err)
+		}
+		if cfg.StatsMemoryMode != want {
+			t.Errorf("STATS_MEMORY_MODE=%q: got %q, want %q", value, cfg.StatsMemoryMode, want)
+		}
+	}
+
+	t.Setenv("STATS_MEMORY_MODE", "rss")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("expected error for unknown STATS_MEMORY_MODE")
+	}
+}
+
+func TestLoadFromEnv_PathPrefixAndHeaders(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "https://example.com/")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("DOCKMON_PATH_PREFIX", "dockmon/")
+	t.Setenv("DOCKMON_HEADERS", "CF-Access-Client-Id: abc.access\ncf-access-client-secret: s3cr:et;v=2\nX-Empty:")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if got := cfg.ServerURL(); got != "https://example.com/dockmon" {
+		t.Errorf("ServerURL() = %q, want https://example.com/dockmon", got)
+	}
+	if got := cfg.ExtraHeaders.Get("Cf-Access-Client-Id"); got != "abc.access" {
+		t.Errorf("Cf-Access-Client-Id = %q", got)
+	}
+	if got := cfg.ExtraHeaders.Get("Cf-Access-Client-Secret"); got != "s3cr:et;v=2" {
+		t.Errorf("Cf-Access-Client-Secret = %q, want the whole value after the first colon", got)
+	}
+	if _, ok := cfg.ExtraHeaders["X-Empty"]; !ok {
+		t.Error("X-Empty header missing")
+	}
+}
+
+func TestLoadFromEnv_JSONHeaders(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "https://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("DOCKMON_HEADERS", `{"Cookie": "a=1; b=2", "x-token": "t"}`)
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if got := cfg.ExtraHeaders.Get("Cookie"); got != "a=1; b=2" {
+		t.Errorf("Cookie = %q, want a=1; b=2", got)
+	}
+	if got := cfg.ExtraHeaders.Get("X-Token"); got != "t" {
+		t.Errorf("X-Token = %q", got)
+	}
+}
+
+func TestLoadFromEnv_InvalidHeaders(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "https://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	for _, value := range []string{"no-colon", ": value", "Bad Name: x", "Upgrade: h2c", "sec-websocket-key: x", `{"X-A": 1}`, `{"X-A": "a\r\nHost: evil"}`, `{"Bad Name": "x"}`} {
+		t.Setenv("DOCKMON_HEADERS", value)
+		if _, err := LoadFromEnv(); err == nil {
+			t.Errorf("DOCKMON_HEADERS=%q: expected error", value)
+		}
+	}
+}
+
+func TestLoadFromEnv_NoPathPrefix(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("DOCKMON_PATH_PREFIX", "/")
+	t.Setenv("DOCKMON_HEADERS", "")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if cfg.ServerURL() != "wss://example.com" || cfg.ExtraHeaders != nil {
+		t.Errorf("ServerURL() = %q, ExtraHeaders = %v", cfg.ServerURL(), cfg.ExtraHeaders)
+	}
+}
+
+func TestLoadFromEnv_Throttle(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("THROTTLE_MAX_DOCKER_CALLS", "2")
+	t.Setenv("THROTTLE_PULL_BANDWIDTH", "5MB")
+	t.Setenv("AGENT_NICE", "10")
+	t.Setenv("AGENT_IO_CLASS", "Idle")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	want := throttle.Settings{MaxDockerCalls: 2, PullBandwidth: 5 << 20, Nice: 10, IOClass: "idle"}
+	if cfg.Throttle != want {
+		t.Errorf("Throttle = %+v, want %+v", cfg.Throttle, want)
+	}
+
+	t.Setenv("AGENT_NICE", "40")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("AGENT_NICE=40: expected error")
+	}
+}
+
+func TestLoadFromEnv_CrashLoop(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if cfg.CrashLoopThreshold != 5 || cfg.CrashLoopWindow != 10*time.Minute {
+		t.Errorf("defaults = %d in %s, want 5 in 10m", cfg.CrashLoopThreshold, cfg.CrashLoopWindow)
+	}
+
+	t.Setenv("CRASH_LOOP_THRESHOLD", "0")
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("CRASH_LOOP_THRESHOLD=0: expected error")
+	}
+}
+
+func TestSocketCandidates(t *testing.T) {
+	got := socketCandidates("/run/user/1000", false)
+	if got[0] != "/run/user/1000/docker.sock" || got[1] != "/run/user/1000/podman/podman.sock" || got[2] != "/var/run/docker.sock" {
+		t.Errorf("non-root candidates = %v, want the rootless sockets first", got)
+	}
+	got = socketCandidates("/run/user/0", true)
+	if got[0] != "/var/run/docker.sock" || got[len(got)-1] != "/run/user/0/podman/podman.sock" {
+		t.Errorf("root candidates = %v, want the rootless sockets last", got)
+	}
+	if got := socketCandidates("", false); len(got) != 3 {
+		t.Errorf("candidates without a runtime dir = %v, want only the system sockets", got)
+	}
+}
+
+func TestDetectContainerSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows always uses the named pipe")
+	}
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "podman.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer l.Close()
+	// A regular file at a candidate path is not a socket
+	notSocket := filepath.Join(dir, "docker.sock")
+	if err := os.WriteFile(notSocket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	host, source := detectContainerSocket([]string{filepath.Join(dir, "missing.sock"), notSocket, sock})
+	if host != "unix://"+sock || source != "detected" {
+		t.Errorf("detected %q (%s), want the podman socket", host, source)
+	}
+	host, source = detectContainerSocket([]string{notSocket})
+	if host != "unix:///var/run/docker.sock" || source != "default" {
+		t.Errorf("fallback %q (%s), want the Docker default", host, source)
+	}
+}
+
+func TestLoadFromEnv_DockerHostSource(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.5:2375")
+
+	cfg, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("LoadFromEnv returned error: %v", err)
+	}
+	if cfg.DockerHost != "tcp://10.0.0.5:2375" || cfg.DockerHostSource != "env" {
+		t.Errorf("DockerHost = %q (%s), want DOCKER_HOST", cfg.DockerHost, cfg.DockerHostSource)
+	}
+	if len(cfg.DockerSocketCandidates) == 0 {
+		t.Error("DockerSocketCandidates is empty, want the detection order reported")
+	}
+}
+
+func TestLoadFromEnv_IOLevel(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "wss://example.com")
+	t.Setenv("REGISTRATION_TOKEN", "test-token")
+	for _, tt := range []struct {
+		class, level string
+		want         int
+	}{
+		{"", "", 0},
+		{"", "2", 0},
+		{"idle", "", 0},
+		{"best-effort", "", 4},
+		{"best-effort", "2", 2},
+	} {
+		t.Setenv("AGENT_IO_CLASS", tt.class)
+		t.Setenv("AGENT_IO_LEVEL", tt.level)
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv returned error: %v", err)
+		}
+		if cfg.Throttle.IOLevel != tt.want {
+			t.Errorf("AGENT_IO_CLASS=%q AGENT_IO_LEVEL=%q: IOLevel = %d, want %d", tt.class, tt.level, cfg.Throttle.IOLevel, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DockMonTLSConfig returns the TLS settings for connections to DockMon, or
+// nil to use the system defaults. DockMonCAFile adds a private CA to the
+// system roots; DockMonCertPins additionally requires the server's leaf
+// certificate to match one of the pinned SHA-256 fingerprints. With pins
+// and no CA file, chain verification is skipped so a self-signed
+// certificate can be pinned directly.
+func (c *Config) DockMonTLSConfig() (*tls.Config, error) {
+	if !c.InsecureSkipVerify && c.DockMonCAFile == "" && len(c.DockMonCertPins) == 0 {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402
+	}
+
+	if c.DockMonCAFile != "" {
+		pem, err := os.ReadFile(c.DockMonCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", c.DockMonCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.DockMonCertPins) > 0 {
+		if c.DockMonCAFile == "" {
+			// The pin replaces chain verification for self-signed certificates
+			tlsConfig.InsecureSkipVerify = true // #nosec G402
+		}
+		pins := make(map[[sha256.Size]byte]bool, len(c.DockMonCertPins))
+		for _, pin := range c.DockMonCertPins {
+			pins[pin] = true
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("server presented no certificate")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if !pins[sum] {
+				return fmt.Errorf("server certificate fingerprint %s matches none of DOCKMON_CERT_SHA256", hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+	return tlsConfig, nil
+}
+
+// parseCertPins parses comma-separated SHA-256 certificate fingerprints in
+// hex, with or without colons (as printed by openssl x509 -fingerprint)
+func parseCertPins(value string) ([][sha256.Size]byte, error) {
+	var pins [][sha256.Size]byte
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		raw, err := hex.DecodeString(strings.ReplaceAll(item, ":", ""))
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", item)
+		}
+		var pin [sha256.Size]byte
+		copy(pin[:], raw)
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
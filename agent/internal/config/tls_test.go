@@ -0,0 +1,90 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// handshake connects to srv with cfg's DockMon TLS settings
+func handshake(t *testing.T, srv *httptest.Server, cfg *Config) error {
+	t.Helper()
+	tlsConfig, err := cfg.DockMonTLSConfig()
+	if err != nil {
+		t.Fatalf("DockMonTLSConfig: %v", err)
+	}
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), tlsConfig)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestDockMonTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	sum := sha256.Sum256(srv.Certificate().Raw)
+
+	if tlsConfig, err := (&Config{}).DockMonTLSConfig(); tlsConfig != nil || err != nil {
+		t.Errorf("default config = %v, %v; want nil", tlsConfig, err)
+	}
+	if err := handshake(t, srv, &Config{DockMonCertPins: [][sha256.Size]byte{sum}}); err != nil {
+		t.Errorf("pinned self-signed certificate rejected: %v", err)
+	}
+	wrong := sum
+	wrong[0] ^= 0xff
+	if err := handshake(t, srv, &Config{DockMonCertPins: [][sha256.Size]byte{wrong}}); err == nil || !strings.Contains(err.Error(), hex.EncodeToString(sum[:])) {
+		t.Errorf("wrong pin: err = %v, want a fingerprint mismatch", err)
+	}
+	// The pin still applies when verification is disabled
+	if err := handshake(t, srv, &Config{InsecureSkipVerify: true, DockMonCertPins: [][sha256.Size]byte{wrong}}); err == nil {
+		t.Error("wrong pin accepted with INSECURE_SKIP_VERIFY")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(t, srv, &Config{DockMonCAFile: caFile}); err != nil {
+		t.Errorf("certificate signed by the CA bundle rejected: %v", err)
+	}
+	if err := handshake(t, srv, &Config{DockMonCAFile: caFile, DockMonCertPins: [][sha256.Size]byte{wrong}}); err == nil {
+		t.Error("wrong pin accepted with a CA bundle")
+	}
+}
+
+func TestDockMonTLSConfig_InvalidCAFile(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(bad, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{bad, filepath.Join(t.TempDir(), "missing.pem")} {
+		if _, err := (&Config{DockMonCAFile: path}).DockMonTLSConfig(); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestParseCertPins(t *testing.T) {
+	hexPin := strings.Repeat("ab", sha256.Size)
+	colonPin := strings.TrimSuffix(strings.Repeat("AB:", sha256.Size), ":")
+	pins, err := parseCertPins(hexPin + ", " + colonPin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || pins[0] != pins[1] || pins[0][0] != 0xab {
+		t.Errorf("pins = %x", pins)
+	}
+	for _, bad := range []string{"abcd", strings.Repeat("zz", sha256.Size)} {
+		if _, err := parseCertPins(bad); err == nil {
+			t.Errorf("parseCertPins(%q): expected error", bad)
+		}
+	}
+}
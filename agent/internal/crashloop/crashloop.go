@@ -0,0 +1,150 @@
+// Package crashloop spots containers that keep dying and being restarted.
+// A container that dies more than the threshold number of times within the
+// window is in a crash loop until its deaths in the window drop back to the
+// threshold.
+package crashloop
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultThreshold is how many deaths within the window are tolerated
+	DefaultThreshold = 5
+	// DefaultWindow is how far back deaths are counted
+	DefaultWindow = 10 * time.Minute
+)
+
+// Loop describes a container that entered a crash loop. LogLines is filled
+// in by the caller, since reading logs needs the Docker client.
+type Loop struct {
+	ContainerID   string    `json:"container_id"`
+	ContainerName string    `json:"container_name"`
+	Deaths        int       `json:"deaths"`
+	WindowSeconds int       `json:"window_seconds"`
+	ExitCodes     []int     `json:"exit_codes"` // Oldest first; -1 if unknown
+	LogLines      []string  `json:"log_lines,omitempty"`
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+type death struct {
+	at       time.Time
+	exitCode int
+}
+
+type history struct {
+	deaths  []death
+	looping bool
+}
+
+// Detector counts container deaths. All methods are safe for concurrent use
+// and on a nil *Detector (detection disabled).
+type Detector struct {
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu         sync.Mutex
+	containers map[string]*history // key: container ID
+}
+
+// New creates a detector; a threshold or window of 0 uses the default
+func New(threshold int, window time.Duration) *Detector {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Detector{
+		threshold:  threshold,
+		window:     window,
+		now:        time.Now,
+		containers: make(map[string]*history),
+	}
+}
+
+// pruneLocked drops deaths older than the window and ends a loop once the
+// container has calmed down. Caller must hold d.mu.
+func (d *Detector) pruneLocked(h *history, now time.Time) {
+	cutoff := now.Add(-d.window)
+	i := 0
+	for i < len(h.deaths) && !h.deaths[i].at.After(cutoff) {
+		i++
+	}
+	h.deaths = h.deaths[i:]
+	if len(h.deaths) <= d.threshold {
+		h.looping = false
+	}
+}
+
+// RecordDeath counts a container's "die" event. exitCode is the event's
+// exitCode attribute. Returns the loop when this death starts one; deaths
+// while already looping return false.
+func (d *Detector) RecordDeath(containerID, name, exitCode string) (Loop, bool) {
+	if d == nil || containerID == "" {
+		return Loop{}, false
+	}
+	code, err := strconv.Atoi(exitCode)
+	if err != nil {
+		code = -1
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	h, ok := d.containers[containerID]
+	if !ok {
+		h = &history{}
+		d.containers[containerID] = h
+	}
+	d.pruneLocked(h, now)
+	h.deaths = append(h.deaths, death{at: now, exitCode: code})
+	if h.looping || len(h.deaths) <= d.threshold {
+		return Loop{}, false
+	}
+
+	h.looping = true
+	codes := make([]int, len(h.deaths))
+	for i, death := range h.deaths {
+		codes[i] = death.exitCode
+	}
+	return Loop{
+		ContainerID:   containerID,
+		ContainerName: name,
+		Deaths:        len(h.deaths),
+		WindowSeconds: int(d.window / time.Second),
+		ExitCodes:     codes,
+		DetectedAt:    now.UTC(),
+	}, true
+}
+
+// InLoop reports whether a container is crash looping
+func (d *Detector) InLoop(containerID string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.containers[containerID]
+	if !ok {
+		return false
+	}
+	d.pruneLocked(h, d.now())
+	if len(h.deaths) == 0 {
+		delete(d.containers, containerID)
+	}
+	return h.looping
+}
+
+// Forget drops a removed container's history
+func (d *Detector) Forget(containerID string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	delete(d.containers, containerID)
+	d.mu.Unlock()
+}
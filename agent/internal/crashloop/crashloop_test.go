@@ -0,0 +1,78 @@
+package crashloop
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestDetector(threshold int, window time.Duration) (*Detector, *time.Time) {
+	d := New(threshold, window)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+func TestDetector_FiresOnceWhenThresholdExceeded(t *testing.T) {
+	d, now := newTestDetector(3, 5*time.Minute)
+
+	for i, code := range []string{"1", "1", "137"} {
+		if _, fired := d.RecordDeath("abc", "web", code); fired {
+			t.Fatalf("fired on death %d, at the threshold", i+1)
+		}
+		*now = now.Add(30 * time.Second)
+	}
+	loop, fired := d.RecordDeath("abc", "web", "")
+	if !fired {
+		t.Fatal("no crash loop after 4 deaths in the window")
+	}
+	if loop.Deaths != 4 || loop.WindowSeconds != 300 || !reflect.DeepEqual(loop.ExitCodes, []int{1, 1, 137, -1}) {
+		t.Errorf("loop = %+v", loop)
+	}
+	if !d.InLoop("abc") || d.InLoop("other") {
+		t.Error("InLoop doesn't match the loop")
+	}
+
+	// Still looping: no repeat event
+	if _, fired := d.RecordDeath("abc", "web", "1"); fired {
+		t.Error("fired again while already looping")
+	}
+}
+
+func TestDetector_LoopEndsWhenDeathsAgeOut(t *testing.T) {
+	d, now := newTestDetector(2, time.Minute)
+	for i := 0; i < 3; i++ {
+		d.RecordDeath("abc", "web", "1")
+	}
+	if !d.InLoop("abc") {
+		t.Fatal("not looping after 3 deaths")
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if d.InLoop("abc") {
+		t.Error("still looping after the window passed")
+	}
+
+	// Deaths spread wider than the window never loop
+	for i := 0; i < 5; i++ {
+		*now = now.Add(40 * time.Second)
+		if _, fired := d.RecordDeath("abc", "web", "1"); fired {
+			t.Fatalf("fired on spread out death %d", i+1)
+		}
+	}
+}
+
+func TestDetector_Forget(t *testing.T) {
+	d, _ := newTestDetector(1, time.Minute)
+	d.RecordDeath("abc", "web", "1")
+	d.RecordDeath("abc", "web", "1")
+	d.Forget("abc")
+	if d.InLoop("abc") {
+		t.Error("forgotten container still looping")
+	}
+
+	var disabled *Detector
+	if _, fired := disabled.RecordDeath("abc", "web", "1"); fired || disabled.InLoop("abc") {
+		t.Error("nil detector detected a loop")
+	}
+}
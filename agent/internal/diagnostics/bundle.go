@@ -0,0 +1,324 @@
+// Package diagnostics collects a support bundle for the agent: recent logs,
+// redacted configuration, Docker daemon details and recent events, runtime
+// profiles and connectivity checks, packed as a tar.gz.
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/docker/docker/api/types/events"
+)
+
+const (
+	// eventsWindow is how far back Docker events are collected
+	eventsWindow = time.Hour
+	// maxEvents caps the events included in a bundle
+	maxEvents = 1000
+	// sectionTimeout bounds each Docker or network section
+	sectionTimeout = 15 * time.Second
+	// redacted replaces secret values
+	redacted = "[REDACTED]"
+)
+
+// errNoDocker is recorded for Docker sections when no client is available
+var errNoDocker = errors.New("no Docker client available")
+
+// Collector gathers diagnostics bundles. The Docker client and log buffer
+// are optional; sections that need them record why they are missing.
+type Collector struct {
+	cfg          *config.Config
+	dockerClient *docker.Client
+	logs         *LogBuffer
+	serviceLogs  func() ([]byte, error)
+	started      time.Time
+}
+
+// NewCollector creates a collector for the agent configured by cfg
+func NewCollector(cfg *config.Config, dockerClient *docker.Client) *Collector {
+	return &Collector{
+		cfg:          cfg,
+		dockerClient: dockerClient,
+		started:      time.Now(),
+	}
+}
+
+// SetLogBuffer sets the in-memory log buffer included as logs/agent.log
+func (c *Collector) SetLogBuffer(logs *LogBuffer) {
+	c.logs = logs
+}
+
+// SetServiceLogs sets a source for logs/service.log, used when the bundle
+// is collected outside the running agent (e.g. the journal on Linux)
+func (c *Collector) SetServiceLogs(fn func() ([]byte, error)) {
+	c.serviceLogs = fn
+}
+
+// FileName is the bundle's file name for a collection at now
+func FileName(now time.Time) string {
+	return "dockmon-agent-diagnostics-" + now.UTC().Format("20060102-150405") + ".tar.gz"
+}
+
+// section is one file in the bundle
+type section struct {
+	name    string
+	collect func(ctx context.Context) ([]byte, error)
+}
+
+// Manifest lists what a bundle contains; it is the bundle's last file
+type Manifest struct {
+	CollectedAt time.Time         `json:"collected_at"`
+	Files       []string          `json:"files"`
+	Errors      map[string]string `json:"errors,omitempty"` // section -> why it is missing
+}
+
+// Write collects every section and writes the tar.gz to w. A section that
+// fails is left out and its error recorded in manifest.json; only write
+// errors fail the bundle.
+func (c *Collector) Write(ctx context.Context, w io.Writer) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifest := &Manifest{CollectedAt: time.Now().UTC()}
+
+	for _, s := range c.sections() {
+		data, err := s.collect(ctx)
+		if err != nil {
+			if manifest.Errors == nil {
+				manifest.Errors = make(map[string]string)
+			}
+			manifest.Errors[s.name] = err.Error()
+			continue
+		}
+		if err := writeTarFile(tw, s.name, data, manifest.CollectedAt); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, s.name)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, "manifest.json", data, manifest.CollectedAt); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish diagnostics archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish diagnostics archive: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *Collector) sections() []section {
+	sections := []section{
+		{"agent.json", c.agentInfo},
+		{"config.json", func(context.Context) ([]byte, error) {
+			return json.MarshalIndent(RedactConfig(c.cfg), "", "  ")
+		}},
+	}
+	if c.logs != nil {
+		sections = append(sections, section{"logs/agent.log", func(context.Context) ([]byte, error) {
+			return []byte(strings.Join(c.logs.Lines(), "\n") + "\n"), nil
+		}})
+	}
+	if c.serviceLogs != nil {
+		sections = append(sections, section{"logs/service.log", func(context.Context) ([]byte, error) {
+			return c.serviceLogs()
+		}})
+	}
+	return append(sections,
+		section{"docker/version.json", c.dockerVersion},
+		section{"docker/info.json", c.dockerInfo},
+		section{"docker/events.json", c.dockerEvents},
+		section{"connectivity.json", func(ctx context.Context) ([]byte, error) {
+			return json.MarshalIndent(c.checkConnectivity(ctx), "", "  ")
+		}},
+		section{"profiles/goroutine.txt", profile("goroutine", 2)},
+		section{"profiles/heap.pprof", profile("heap", 0)},
+	)
+}
+
+// agentInfo describes the agent process and build
+func (c *Collector) agentInfo(context.Context) ([]byte, error) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	hostname, _ := os.Hostname()
+	return json.MarshalIndent(map[string]interface{}{
+		"version":       c.cfg.AgentVersion,
+		"commit":        c.cfg.AgentCommit,
+		"build_date":    c.cfg.AgentBuildDate,
+		"go_version":    runtime.Version(),
+		"os":            runtime.GOOS,
+		"arch":          runtime.GOARCH,
+		"hostname":      hostname,
+		"pid":           os.Getpid(),
+		"uptime":        time.Since(c.started).Round(time.Second).String(),
+		"num_cpu":       runtime.NumCPU(),
+		"num_goroutine": runtime.NumGoroutine(),
+		"memory": map[string]uint64{
+			"heap_alloc":  mem.HeapAlloc,
+			"heap_inuse":  mem.HeapInuse,
+			"sys":         mem.Sys,
+			"num_gc":      uint64(mem.NumGC),
+			"stack_inuse": mem.StackInuse,
+		},
+	}, "", "  ")
+}
+
+func (c *Collector) dockerVersion(ctx context.Context) ([]byte, error) {
+	if c.dockerClient == nil {
+		return nil, errNoDocker
+	}
+	ctx, cancel := context.WithTimeout(ctx, sectionTimeout)
+	defer cancel()
+	v, err := c.dockerClient.RawClient().ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func (c *Collector) dockerInfo(ctx context.Context) ([]byte, error) {
+	if c.dockerClient == nil {
+		return nil, errNoDocker
+	}
+	ctx, cancel := context.WithTimeout(ctx, sectionTimeout)
+	defer cancel()
+	info, err := c.dockerClient.RawClient().Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(info, "", "  ")
+}
+
+// dockerEvents returns the daemon's events from the last eventsWindow. With
+// Until set the daemon replays its history and closes the stream.
+func (c *Collector) dockerEvents(ctx context.Context) ([]byte, error) {
+	if c.dockerClient == nil {
+		return nil, errNoDocker
+	}
+	ctx, cancel := context.WithTimeout(ctx, sectionTimeout)
+	defer cancel()
+
+	now := time.Now()
+	msgs, errs := c.dockerClient.RawClient().Events(ctx, events.ListOptions{
+		Since: now.Add(-eventsWindow).Format(time.RFC3339),
+		Until: now.Format(time.RFC3339),
+	})
+	var collected []events.Message
+	for {
+		select {
+		case msg := <-msgs:
+			// Keep the newest events if the window holds more than maxEvents
+			if len(collected) == maxEvents {
+				collected = collected[1:]
+			}
+			collected = append(collected, msg)
+		case err := <-errs:
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return json.MarshalIndent(collected, "", "  ")
+		}
+	}
+}
+
+// profile writes the named runtime profile; debug 2 gives full goroutine
+// stacks as text, 0 the binary pprof format
+func profile(name string, debug int) func(context.Context) ([]byte, error) {
+	return func(context.Context) ([]byte, error) {
+		p := pprof.Lookup(name)
+		if p == nil {
+			return nil, fmt.Errorf("profile %s not available", name)
+		}
+		var buf bytes.Buffer
+		if err := p.WriteTo(&buf, debug); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// RedactConfig returns cfg's settings with secrets removed: token fields
+// are replaced and URLs keep only their scheme and host, since webhook and
+// notification URLs often embed credentials in the path or query.
+func RedactConfig(cfg *config.Config) map[string]interface{} {
+	out := make(map[string]interface{})
+	v := reflect.ValueOf(*cfg)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		value := v.Field(i).Interface()
+		switch typed := value.(type) {
+		case string:
+			switch {
+			case strings.HasSuffix(name, "Token") && typed != "":
+				value = redacted
+			case strings.HasSuffix(name, "URL"):
+				value = redactURL(typed)
+			}
+		case time.Duration:
+			value = typed.String()
+		case http.Header:
+			// Extra headers typically carry credentials; keep only the names
+			names := make(map[string]string, len(typed))
+			for header := range typed {
+				names[header] = redacted
+			}
+			value = names
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// redactURL keeps the scheme and host of raw and drops user info, path and
+// query
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redacted
+	}
+	out := u.Scheme + "://" + u.Host
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		out += "/" + redacted
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package diagnostics
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConnectivityCheck is the outcome of one connectivity test
+type ConnectivityCheck struct {
+	Name       string `json:"name"`
+	Target     string `json:"target,omitempty"`
+	OK         bool   `json:"ok"`
+	DurationMS int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// checkConnectivity tests the Docker daemon and each step of reaching
+// DockMon (DNS, TCP, HTTP), so a bundle shows where a connection breaks
+func (c *Collector) checkConnectivity(ctx context.Context) []ConnectivityCheck {
+	var checks []ConnectivityCheck
+
+	if c.dockerClient != nil {
+		checks = append(checks, runCheck(ctx, "docker_ping", c.cfg.DockerHost, func(ctx context.Context) (string, error) {
+			ping, err := c.dockerClient.RawClient().Ping(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "API version " + ping.APIVersion, nil
+		}))
+	}
+
+	base, err := httpBaseURL(c.cfg.ServerURL())
+	if err != nil {
+		return append(checks, ConnectivityCheck{Name: "dockmon_url", Target: redactURL(c.cfg.DockMonURL), Error: err.Error()})
+	}
+	healthURL := base.Scheme + "://" + base.Host + strings.TrimRight(base.Path, "/") + "/health"
+	host, port := base.Hostname(), base.Port()
+	if port == "" {
+		port = "80"
+		if base.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	checks = append(checks,
+		runCheck(ctx, "dockmon_dns", host, func(ctx context.Context) (string, error) {
+			addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+			if err != nil {
+				return "", err
+			}
+			return strings.Join(addrs, ", "), nil
+		}),
+		runCheck(ctx, "dockmon_tcp", net.JoinHostPort(host, port), func(ctx context.Context) (string, error) {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+			if err != nil {
+				return "", err
+			}
+			defer conn.Close()
+			return "connected from " + conn.LocalAddr().String(), nil
+		}),
+		runCheck(ctx, "dockmon_http", healthURL, func(ctx context.Context) (string, error) {
+			return c.checkHealthEndpoint(ctx, healthURL)
+		}),
+	)
+	return checks
+}
+
+// checkHealthEndpoint GETs DockMon's /health with the agent's TLS settings
+func (c *Collector) checkHealthEndpoint(ctx context.Context, healthURL string) (string, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := c.cfg.DockMonTLSConfig()
+	if err != nil {
+		return "", err
+	}
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport}
+	defer transport.CloseIdleConnections()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, values := range c.cfg.ExtraHeaders {
+		req.Header[name] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	detail := fmt.Sprintf("HTTP %d", resp.StatusCode)
+	if resp.TLS != nil {
+		detail += ", " + tls.VersionName(resp.TLS.Version)
+	}
+	return detail, nil
+}
+
+// runCheck times one check under sectionTimeout
+func runCheck(ctx context.Context, name, target string, check func(ctx context.Context) (string, error)) ConnectivityCheck {
+	ctx, cancel := context.WithTimeout(ctx, sectionTimeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+	result := ConnectivityCheck{
+		Name:       name,
+		Target:     target,
+		OK:         err == nil,
+		DurationMS: time.Since(start).Milliseconds(),
+		Detail:     detail,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// httpBaseURL turns the configured DockMon URL (http, https, ws or wss)
+// into the HTTP base URL the backend serves
+func httpBaseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DockMon URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported DockMon URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("DockMon URL has no host")
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u, nil
+}
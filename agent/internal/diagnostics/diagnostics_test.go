@@ -0,0 +1,187 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogBuffer_KeepsNewestLines(t *testing.T) {
+	b := NewLogBuffer(3)
+	for _, line := range []string{"one", "two", "three", "four"} {
+		b.add(line)
+	}
+	if got := strings.Join(b.Lines(), ","); got != "two,three,four" {
+		t.Errorf("lines = %q, want two,three,four", got)
+	}
+
+	var nilBuf *LogBuffer
+	if nilBuf.Lines() != nil {
+		t.Error("nil buffer should have no lines")
+	}
+}
+
+func TestLogBuffer_HookFormatsEntries(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	b := NewLogBuffer(10)
+	log.AddHook(b)
+
+	log.WithField("container_id", "abc").Warn("stats stalled")
+	lines := b.Lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "stats stalled") || !strings.Contains(lines[0], "container_id=abc") {
+		t.Errorf("lines = %q", lines)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	got := RedactConfig(&config.Config{
+		DockMonURL:        "https://user:pw@dockmon.example:8443",
+		RegistrationToken: "reg-secret",
+		PermanentToken:    "",
+		LocalNotifyURL:    "https://discord.com/api/webhooks/123/secret",
+		LocalNotifyToken:  "ntfy-secret",
+		AgentName:         "nas",
+		ReconnectMax:      time.Minute,
+		ExtraHeaders:      http.Header{"Cf-Access-Client-Secret": {"cf-secret"}},
+	})
+
+	want := map[string]interface{}{
+		"DockMonURL":        "https://dockmon.example:8443/[REDACTED]",
+		"RegistrationToken": "[REDACTED]",
+		"PermanentToken":    "",
+		"LocalNotifyURL":    "https://discord.com/[REDACTED]",
+		"LocalNotifyToken":  "[REDACTED]",
+		"AgentName":         "nas",
+		"ReconnectMax":      "1m0s",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %v, want %v", key, got[key], value)
+		}
+	}
+	if headers, _ := got["ExtraHeaders"].(map[string]string); headers["Cf-Access-Client-Secret"] != "[REDACTED]" {
+		t.Errorf("ExtraHeaders = %v, want the value redacted", got["ExtraHeaders"])
+	}
+}
+
+func TestCollector_AgentInfo(t *testing.T) {
+	c := NewCollector(&config.Config{
+		AgentVersion:   "2.3.0",
+		AgentCommit:    "abc1234",
+		AgentBuildDate: "2026-03-04T10:00:00Z",
+		ProtoVersion:   "1.2",
+		PermanentToken: "secret",
+	}, nil)
+
+	info := c.AgentInfo(DeploymentNative, map[string]bool{"agent_info": true})
+	if info.Version != "2.3.0" || info.Commit != "abc1234" || info.BuildDate != "2026-03-04T10:00:00Z" {
+		t.Errorf("build metadata = %q %q %q", info.Version, info.Commit, info.BuildDate)
+	}
+	if info.DeploymentMode != DeploymentNative || !info.Capabilities["agent_info"] || info.GoVersion == "" {
+		t.Errorf("info = %+v", info)
+	}
+	if info.Config["PermanentToken"] != redacted {
+		t.Errorf("config summary leaks the token: %v", info.Config["PermanentToken"])
+	}
+}
+
+func TestHTTPBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"wss://dockmon.example":          "https://dockmon.example",
+		"ws://10.0.0.5:8080/dockmon":     "http://10.0.0.5:8080/dockmon",
+		"https://u:p@dockmon.example/?x": "https://dockmon.example/",
+	}
+	for in, want := range tests {
+		u, err := httpBaseURL(in)
+		if err != nil {
+			t.Errorf("httpBaseURL(%q): %v", in, err)
+			continue
+		}
+		if u.String() != want {
+			t.Errorf("httpBaseURL(%q) = %q, want %q", in, u.String(), want)
+		}
+	}
+	if _, err := httpBaseURL("ftp://dockmon.example"); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
+
+// TestCollector_WriteWithoutDocker verifies a bundle is still produced when
+// Docker is unavailable, with the missing sections listed in the manifest
+func TestCollector_WriteWithoutDocker(t *testing.T) {
+	logs := NewLogBuffer(10)
+	logs.add("agent started")
+	c := NewCollector(&config.Config{
+		DockMonURL:     "http://127.0.0.1:1",
+		PermanentToken: "perm-secret",
+	}, nil)
+	c.SetLogBuffer(logs)
+
+	var buf bytes.Buffer
+	manifest, err := c.Write(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	files := readBundle(t, &buf)
+	for _, name := range []string{"agent.json", "config.json", "logs/agent.log", "connectivity.json", "profiles/goroutine.txt", "profiles/heap.pprof", "manifest.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle missing %s", name)
+		}
+	}
+	if _, ok := manifest.Errors["docker/info.json"]; !ok {
+		t.Errorf("manifest errors = %v, want docker/info.json listed", manifest.Errors)
+	}
+	if strings.Contains(files["config.json"], "perm-secret") {
+		t.Error("config.json leaks the permanent token")
+	}
+	if !strings.Contains(files["logs/agent.log"], "agent started") {
+		t.Errorf("agent.log = %q", files["logs/agent.log"])
+	}
+
+	var checks []ConnectivityCheck
+	if err := json.Unmarshal([]byte(files["connectivity.json"]), &checks); err != nil {
+		t.Fatalf("connectivity.json: %v", err)
+	}
+	for _, check := range checks {
+		if check.Name == "dockmon_tcp" && check.OK {
+			t.Error("dockmon_tcp to a closed port should fail")
+		}
+	}
+}
+
+func readBundle(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("read %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = string(data)
+	}
+}
@@ -0,0 +1,79 @@
+package diagnostics
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Deployment modes
+const (
+	DeploymentContainer = "container"
+	DeploymentNative    = "native"
+)
+
+// AgentInfo is the agent's build and runtime summary, returned by the
+// get_agent_info command so DockMon can keep an accurate inventory and gate
+// features by version
+type AgentInfo struct {
+	Version        string                 `json:"version"`
+	Commit         string                 `json:"commit"`
+	BuildDate      string                 `json:"build_date,omitempty"`
+	GoVersion      string                 `json:"go_version"`
+	OS             string                 `json:"os"`
+	Arch           string                 `json:"arch"`
+	Static         bool                   `json:"static"` // Built without cgo
+	ProtoVersion   string                 `json:"proto_version"`
+	DeploymentMode string                 `json:"deployment_mode"`
+	StartedAt      time.Time              `json:"started_at"`
+	Capabilities   map[string]bool        `json:"capabilities"`
+	Config         map[string]interface{} `json:"config"` // Redacted, see RedactConfig
+}
+
+// AgentInfo summarises the agent for the get_agent_info command
+func (c *Collector) AgentInfo(deploymentMode string, capabilities map[string]bool) AgentInfo {
+	build := ReadBuild()
+	return AgentInfo{
+		Version:        c.cfg.AgentVersion,
+		Commit:         c.cfg.AgentCommit,
+		BuildDate:      c.cfg.AgentBuildDate,
+		GoVersion:      runtime.Version(),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		Static:         build.Static,
+		ProtoVersion:   c.cfg.ProtoVersion,
+		DeploymentMode: deploymentMode,
+		StartedAt:      c.started.UTC(),
+		Capabilities:   capabilities,
+		Config:         RedactConfig(c.cfg),
+	}
+}
+
+// Build is the metadata the Go toolchain embeds in the binary
+type Build struct {
+	Revision string // VCS commit, when built from a checkout
+	Time     string // VCS commit time, RFC 3339
+	Static   bool   // CGO_ENABLED=0
+}
+
+// ReadBuild returns the binary's embedded build metadata. Release builds set
+// the commit and date with -ldflags instead, since Docker builds have no
+// VCS checkout to stamp.
+func ReadBuild() Build {
+	var b Build
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			b.Revision = setting.Value
+		case "vcs.time":
+			b.Time = setting.Value
+		case "CGO_ENABLED":
+			b.Static = setting.Value == "0"
+		}
+	}
+	return b
+}
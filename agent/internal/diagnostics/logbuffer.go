@@ -0,0 +1,74 @@
+package diagnostics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultLogLines is how many recent log lines the agent keeps for bundles
+const DefaultLogLines = 2000
+
+// LogBuffer is a logrus hook that keeps the most recent formatted log lines
+// in memory, so a diagnostics bundle can include logs even when stdout goes
+// nowhere the user can reach. All methods are safe to call on a nil
+// *LogBuffer.
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int  // index the next line is written to
+	full  bool // lines has wrapped around
+}
+
+// NewLogBuffer creates a buffer holding up to size lines
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = DefaultLogLines
+	}
+	return &LogBuffer{lines: make([]string, size)}
+}
+
+// Levels captures every level; the logger's own level still filters what
+// reaches the hook
+func (b *LogBuffer) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire stores the entry formatted the way the logger prints it
+func (b *LogBuffer) Fire(entry *logrus.Entry) error {
+	if b == nil {
+		return nil
+	}
+	line, err := entry.String()
+	if err != nil {
+		line = entry.Time.Format("2006-01-02T15:04:05.000Z07:00") + " " + entry.Level.String() + " " + entry.Message + "\n"
+	}
+	b.add(strings.TrimRight(line, "\n"))
+	return nil
+}
+
+func (b *LogBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines returns the buffered lines, oldest first
+func (b *LogBuffer) Lines() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	out := make([]string, 0, len(b.lines))
+	out = append(out, b.lines[b.next:]...)
+	return append(out, b.lines[:b.next]...)
+}
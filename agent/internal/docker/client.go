@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -30,6 +32,7 @@ import (
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/crashloop"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/singleflight"
@@ -37,8 +40,9 @@ import (
 
 // Client wraps the Docker client with agent-specific functionality
 type Client struct {
-	cli *client.Client
-	log *logrus.Logger
+	pool  *sharedDocker.Pool  // Health-checked clients, shared with deployments
+	lease *sharedDocker.Lease // The agent's own Docker client
+	log   *logrus.Logger
 
 	// Cached values for efficiency - detected once, reused
 	isPodmanCache   *bool  // Podman detection result
@@ -56,38 +60,81 @@ type Client struct {
 	// first-sight and cache forever — same model as startedAt above.
 	envMu sync.RWMutex
 	env   map[string]map[string]string
+
+	// digests: image ID → RepoDigests. Pulls, tags and deletes change an
+	// image's digests, so the event watcher invalidates on image events.
+	digestsMu sync.RWMutex
+	digests   map[string][]string
+
+	// crashLoops flags crash looping containers in listings; set once at
+	// startup, nil disables the flag
+	crashLoops *crashloop.Detector
+
+	// How the daemon was found, reported by GetSystemInfo
+	dockerHost       string
+	dockerHostSource string
+	socketCandidates []string
 }
 
-// NewClient creates a new Docker client using shared package
-func NewClient(cfg *config.Config, log *logrus.Logger) (*Client, error) {
-	var cli *client.Client
-	var err error
+// NewClient creates a new Docker client using shared package. The client is
+// pooled: call Pool().Run to health-check it and recreate it on failure.
+// Extra options (e.g. the throttle's transport) apply to every client the
+// pool creates, including replacements.
+func NewClient(cfg *config.Config, log *logrus.Logger, opts ...client.Opt) (*Client, error) {
+	var hostAddress string
 
 	// Use shared package for client creation
 	if cfg.DockerHost == "" || cfg.DockerHost == "unix:///var/run/docker.sock" {
 		// Local Docker socket
-		cli, err = sharedDocker.CreateLocalClient()
+		hostAddress = ""
 	} else if cfg.DockerTLSVerify && cfg.DockerCertPath != "" {
 		// Remote with TLS - need to read cert files
 		// For now, this is simplified - in production we'd read the PEM files
 		return nil, fmt.Errorf("TLS configuration not yet implemented for agent")
 	} else {
 		// Remote without TLS (or basic connection)
-		cli, err = sharedDocker.CreateRemoteClient(cfg.DockerHost, "", "", "")
+		hostAddress = cfg.DockerHost
 	}
 
+	pool := sharedDocker.NewPool(0, 0)
+	pool.SetClientOptions(opts...)
+	lease, err := pool.AcquireHost(hostAddress, "", "", "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
 	return &Client{
-		cli:       cli,
+		pool:      pool,
+		lease:     lease,
 		log:       log,
 		startedAt: make(map[string]string),
 		env:       make(map[string]map[string]string),
+		digests:   make(map[string][]string),
+
+		dockerHost:       cfg.DockerHost,
+		dockerHostSource: cfg.DockerHostSource,
+		socketCandidates: cfg.DockerSocketCandidates,
 	}, nil
 }
 
+// api returns the current Docker SDK client, which is replaced if the pool's
+// health check finds it broken
+func (c *Client) api() *client.Client {
+	return c.lease.Client()
+}
+
+// Pool returns the client pool, for other short-lived local clients and for
+// running its health checks
+func (c *Client) Pool() *sharedDocker.Pool {
+	return c.pool
+}
+
+// SetCrashLoops sets the detector whose crash loops are flagged in
+// ListContainers. Call before the client is used.
+func (c *Client) SetCrashLoops(d *crashloop.Detector) {
+	c.crashLoops = d
+}
+
 // LookupStartedAt returns the cached timestamp, or "", false on miss.
 func (c *Client) LookupStartedAt(id string) (string, bool) {
 	c.startedAtMu.RLock()
@@ -120,7 +167,8 @@ func (c *Client) EvictContainerCache(id string) {
 // Docker streams events from "now"; entries from before the gap may be stale.
 // Also clears the env cache; env is immutable per container ID but a missed
 // destroy event during disconnect could leave stale entries for IDs that no
-// longer exist, so we re-derive on reconnect alongside startedAt.
+// longer exist, so we re-derive on reconnect alongside startedAt. The image
+// digest cache is cleared for the same reason (missed image events).
 func (c *Client) ResetStartedAtCache() {
 	c.startedAtMu.Lock()
 	c.startedAt = make(map[string]string)
@@ -128,6 +176,39 @@ func (c *Client) ResetStartedAtCache() {
 	c.envMu.Lock()
 	c.env = make(map[string]map[string]string)
 	c.envMu.Unlock()
+	c.InvalidateImageDigests()
+}
+
+// LookupImageDigests returns the cached RepoDigests for an image ID, or
+// nil/false on miss.
+func (c *Client) LookupImageDigests(imageID string) ([]string, bool) {
+	c.digestsMu.RLock()
+	defer c.digestsMu.RUnlock()
+	d, ok := c.digests[imageID]
+	return d, ok
+}
+
+// RecordImageDigests caches an image's RepoDigests. A nil slice is recorded
+// as empty (locally built images have no digests) so it isn't re-inspected.
+func (c *Client) RecordImageDigests(imageID string, digests []string) {
+	if imageID == "" {
+		return
+	}
+	if digests == nil {
+		digests = []string{}
+	}
+	c.digestsMu.Lock()
+	c.digests[imageID] = digests
+	c.digestsMu.Unlock()
+}
+
+// InvalidateImageDigests clears the image digest cache. Called on any image
+// event: pull events carry a reference rather than an image ID, and image
+// events are rare enough that a full re-derive is cheap.
+func (c *Client) InvalidateImageDigests() {
+	c.digestsMu.Lock()
+	c.digests = make(map[string][]string)
+	c.digestsMu.Unlock()
 }
 
 // LookupEnv returns the cached env map for a container, or nil/false on miss.
@@ -167,21 +248,23 @@ func parseEnvList(envList []string) map[string]string {
 	return env
 }
 
-// Close closes the Docker client
+// Close releases the Docker client and closes the pool
 func (c *Client) Close() error {
-	return c.cli.Close()
+	c.lease.Release()
+	c.pool.Close()
+	return nil
 }
 
 // RawClient returns the underlying Docker SDK client.
 // This is used by the shared update package which requires the raw client.
 func (c *Client) RawClient() *client.Client {
-	return c.cli
+	return c.api()
 }
 
 // SystemInfo contains Docker host system information
 type SystemInfo struct {
 	Hostname        string   // Docker host's hostname (not container hostname)
-	HostIPs         []string // All non-loopback host IPs
+	HostIPs         []string // All non-loopback host IPs, IPv4 before IPv6
 	OSType          string
 	OSVersion       string
 	KernelVersion   string
@@ -189,11 +272,58 @@ type SystemInfo struct {
 	DaemonStartedAt string
 	TotalMemory     int64
 	NumCPUs         int
+
+	// DockerHost is the daemon address in use and DockerHostSource how it
+	// was chosen ("env", "detected" or "default"); SocketCandidates is the
+	// order sockets are tried in when DOCKER_HOST is unset
+	DockerHost       string
+	DockerHostSource string
+	SocketCandidates []string
+	// Rootless is set for rootless Docker and Podman daemons
+	Rootless bool
+}
+
+// isVirtualInterface reports whether an interface belongs to Docker, a
+// container runtime or an overlay network rather than the host itself
+func isVirtualInterface(name string) bool {
+	return name == "docker0" || name == "docker_gwbridge" || name == "cni0" ||
+		strings.HasPrefix(name, "veth") ||
+		strings.HasPrefix(name, "br-") ||
+		strings.HasPrefix(name, "virbr") ||
+		strings.HasPrefix(name, "flannel") ||
+		strings.HasPrefix(name, "cali") ||
+		strings.HasPrefix(name, "cni-") ||
+		strings.HasPrefix(name, "weave") ||
+		strings.HasPrefix(name, "podman") ||
+		strings.HasPrefix(name, "vxlan") ||
+		strings.HasPrefix(name, "tunl")
+}
+
+// isHostIP reports whether ip can reach the host from elsewhere: not
+// loopback, link-local (169.254.0.0/16, fe80::/10), multicast or unspecified
+func isHostIP(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// sortHostIPs orders IPv4 addresses before IPv6 ones, keeping detection order
+// within each family. The first IP is sent as host_ip to backends without
+// host_ips support, so dual-stack hosts keep reporting IPv4 there while
+// IPv6-only hosts still report an address.
+func sortHostIPs(ips []string) []string {
+	isIPv4 := func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	}
+	sort.SliceStable(ips, func(i, j int) bool {
+		return isIPv4(ips[i]) && !isIPv4(ips[j])
+	})
+	return ips
 }
 
-// GetHostIPs detects all non-loopback IPv4 addresses of the host.
-// Filters out Docker/container/overlay network interfaces.
-// Returns nil if no suitable IPs are found.
+// GetHostIPs detects all non-loopback, non-link-local IPv4 and IPv6 addresses
+// of the host, IPv4 first. Filters out Docker/container/overlay network
+// interfaces. Returns nil if no suitable IPs are found.
 func GetHostIPs() []string {
 	interfaces, err := net.Interfaces()
 	if err != nil {
@@ -211,19 +341,7 @@ func GetHostIPs() []string {
 		if iface.Flags&net.FlagUp == 0 {
 			continue
 		}
-
-		name := iface.Name
-		if name == "docker0" || name == "docker_gwbridge" || name == "cni0" ||
-			strings.HasPrefix(name, "veth") ||
-			strings.HasPrefix(name, "br-") ||
-			strings.HasPrefix(name, "virbr") ||
-			strings.HasPrefix(name, "flannel") ||
-			strings.HasPrefix(name, "cali") ||
-			strings.HasPrefix(name, "cni-") ||
-			strings.HasPrefix(name, "weave") ||
-			strings.HasPrefix(name, "podman") ||
-			strings.HasPrefix(name, "vxlan") ||
-			strings.HasPrefix(name, "tunl") {
+		if isVirtualInterface(iface.Name) {
 			continue
 		}
 
@@ -241,7 +359,7 @@ func GetHostIPs() []string {
 				ip = v.IP
 			}
 
-			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.To4() == nil {
+			if !isHostIP(ip) {
 				continue
 			}
 
@@ -253,24 +371,34 @@ func GetHostIPs() []string {
 		}
 	}
 
-	return ips
+	return sortHostIPs(ips)
 }
 
-// GetHostIPsFromProc parses /proc/net/fib_trie for /32 host LOCAL entries
-// to detect host IP addresses. Filters out 127.x (loopback) and 169.254.x
-// (link-local).
+// GetHostIPsFromProc detects host IP addresses from the host's procfs when
+// the agent runs in a container: IPv4 from /proc/net/fib_trie and IPv6 from
+// /proc/net/if_inet6. IPv4 addresses come first.
 func GetHostIPsFromProc(procPath string) []string {
-	var fibPath string
+	netPath := procPath + "/net"
 	if procPath != "/proc" {
-		fibPath = procPath + "/1/net/fib_trie"
-	} else {
-		fibPath = procPath + "/net/fib_trie"
+		netPath = procPath + "/1/net"
 	}
-	data, err := os.ReadFile(fibPath)
-	if err != nil {
+
+	var ips []string
+	if data, err := os.ReadFile(netPath + "/fib_trie"); err == nil {
+		ips = append(ips, parseFibTrie(data)...)
+	}
+	if data, err := os.ReadFile(netPath + "/if_inet6"); err == nil {
+		ips = append(ips, parseIfInet6(data)...)
+	}
+	if len(ips) == 0 {
 		return nil
 	}
+	return sortHostIPs(ips)
+}
 
+// parseFibTrie extracts /32 host LOCAL entries from /proc/net/fib_trie.
+// Filters out 127.x (loopback) and 169.254.x (link-local).
+func parseFibTrie(data []byte) []string {
 	seen := make(map[string]bool)
 	var ips []string
 	var lastIP string
@@ -322,6 +450,56 @@ func GetHostIPsFromProc(procPath string) []string {
 	return ips
 }
 
+// if_inet6 address flags (IFA_F_*) of addresses that shouldn't be reported:
+// rotating privacy addresses and ones that aren't (or are no longer) usable
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDADFailed  = 0x08
+	ifaFlagDeprecated = 0x20
+	ifaFlagTentative  = 0x40
+)
+
+// parseIfInet6 extracts global IPv6 addresses from /proc/net/if_inet6, whose
+// lines are "<32 hex address> <ifindex> <prefix len> <scope> <flags> <name>".
+// Link-local and loopback scopes, Docker interfaces and temporary,
+// deprecated or tentative addresses are skipped.
+func parseIfInet6(data []byte) []string {
+	seen := make(map[string]bool)
+	var ips []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 || len(fields[0]) != 32 {
+			continue
+		}
+		if isVirtualInterface(fields[5]) {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil || flags&(ifaFlagTemporary|ifaFlagDADFailed|ifaFlagDeprecated|ifaFlagTentative) != 0 {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil {
+			continue
+		}
+		ip := net.IP(raw)
+		if !isHostIP(ip) {
+			continue
+		}
+
+		ipStr := ip.String()
+		if !seen[ipStr] {
+			seen[ipStr] = true
+			ips = append(ips, ipStr)
+		}
+	}
+
+	return ips
+}
+
 // FilterDockerNetworkIPs removes IPs that fall within Docker/Podman network subnets.
 // Queries the Docker daemon for all network subnets and filters out any detected IPs
 // that belong to them (bridge gateways, container IPs, etc.).
@@ -331,7 +509,7 @@ func (c *Client) FilterDockerNetworkIPs(ctx context.Context, ips []string) []str
 		return ips
 	}
 
-	networks, err := c.cli.NetworkList(ctx, network.ListOptions{})
+	networks, err := c.api().NetworkList(ctx, network.ListOptions{})
 	if err != nil {
 		c.log.WithError(err).Debug("Failed to list networks for IP filtering, returning all IPs")
 		return ips
@@ -378,7 +556,7 @@ func (c *Client) FilterDockerNetworkIPs(ctx context.Context, ips []string) []str
 
 // GetEngineID returns the unique Docker engine ID
 func (c *Client) GetEngineID(ctx context.Context) (string, error) {
-	info, err := c.cli.Info(ctx)
+	info, err := c.api().Info(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get Docker info: %w", err)
 	}
@@ -389,13 +567,13 @@ func (c *Client) GetEngineID(ctx context.Context) (string, error) {
 // Matches the data collected by legacy hosts in monitor.py
 func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
 	// Get system info from Docker
-	info, err := c.cli.Info(ctx)
+	info, err := c.api().Info(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Docker info: %w", err)
 	}
 
 	// Get version info
-	version, err := c.cli.ServerVersion(ctx)
+	version, err := c.api().ServerVersion(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Docker version: %w", err)
 	}
@@ -409,11 +587,16 @@ func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
 		DockerVersion: version.Version,
 		TotalMemory:   info.MemTotal,
 		NumCPUs:       info.NCPU,
+
+		DockerHost:       c.dockerHost,
+		DockerHostSource: c.dockerHostSource,
+		SocketCandidates: c.socketCandidates,
+		Rootless:         isRootless(info.SecurityOptions),
 	}
 
 	// Get daemon start time from bridge network creation time
 	// This matches the approach in monitor.py
-	networks, err := c.cli.NetworkList(ctx, network.ListOptions{})
+	networks, err := c.api().NetworkList(ctx, network.ListOptions{})
 	if err == nil {
 		for _, network := range networks {
 			if network.Name == "bridge" {
@@ -427,24 +610,41 @@ func (c *Client) GetSystemInfo(ctx context.Context) (*SystemInfo, error) {
 	return sysInfo, nil
 }
 
+// isRootless reports whether the daemon's security options mark it as
+// rootless, as both Docker and Podman do
+func isRootless(securityOptions []string) bool {
+	for _, opt := range securityOptions {
+		if opt == "name=rootless" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetMyContainerID attempts to determine the agent's own container ID
-// by reading /proc/self/cgroup
+// by reading /proc/self/cgroup, then /proc/self/mountinfo. Under rootless
+// Docker and Podman on cgroup v2 the container has a private cgroup
+// namespace, so its cgroup reads as "0::/" and only the mounts name it.
 func (c *Client) GetMyContainerID(ctx context.Context) (string, error) {
+	return readMyContainerID("/proc/self/cgroup", "/proc/self/mountinfo")
+}
+
+func readMyContainerID(cgroupPath, mountinfoPath string) (string, error) {
 	// Read cgroup file to get container ID
-	data, err := os.ReadFile("/proc/self/cgroup")
+	data, err := os.ReadFile(cgroupPath) // #nosec G304 -- fixed /proc paths, parameterized for tests
 	if err != nil {
 		return "", fmt.Errorf("failed to read cgroup: %w", err)
 	}
-
-	// Parse container ID from cgroup
-	// Format: 0::/docker/<container_id>
-	// or: 12:cpu,cpuacct:/docker/<container_id>
-	containerID := parseContainerIDFromCgroup(string(data))
-	if containerID == "" {
-		return "", fmt.Errorf("could not parse container ID from cgroup")
+	if containerID := parseContainerIDFromCgroup(string(data)); containerID != "" {
+		return containerID, nil
 	}
 
-	return containerID, nil
+	if data, err := os.ReadFile(mountinfoPath); err == nil { // #nosec G304 -- as above
+		if containerID := parseContainerIDFromMountinfo(string(data)); containerID != "" {
+			return containerID, nil
+		}
+	}
+	return "", fmt.Errorf("could not parse container ID from cgroup or mountinfo")
 }
 
 // ContainerWithDigest extends types.Container with additional inspect data
@@ -455,13 +655,28 @@ type ContainerWithDigest struct {
 	RepoDigests []string          `json:"RepoDigests"`
 	StartedAt   string            `json:"StartedAt,omitempty"`
 	Env         map[string]string `json:"Env,omitempty"`
+	// CrashLoop is set while the container keeps dying and restarting
+	CrashLoop bool `json:"CrashLoop,omitempty"`
 }
 
 // ListContainers lists all containers with RepoDigests and StartedAt.
+// RepoDigests are cached per image ID across calls (see digests).
 // On cancellation it returns a partial result with ctx.Err(); unfilled
 // entries are stripped so callers don't see phantom containers.
 func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, error) {
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	return c.listContainers(ctx, true)
+}
+
+// ListContainersFast lists containers without RepoDigests enrichment, for
+// callers that only need IDs, names and state. No image is inspected.
+func (c *Client) ListContainersFast(ctx context.Context) ([]ContainerWithDigest, error) {
+	return c.listContainers(ctx, false)
+}
+
+// listContainers lists all containers, enriching them on a bounded worker
+// pool. withDigests controls the image inspect for RepoDigests.
+func (c *Client) listContainers(ctx context.Context, withDigests bool) ([]ContainerWithDigest, error) {
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -471,6 +686,9 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, err
 	var imageCacheMu sync.Mutex
 	imageCache := make(map[string][]string)
 	inspectImage := func(ctx context.Context, imageID string) []string {
+		if cached, ok := c.LookupImageDigests(imageID); ok {
+			return cached
+		}
 		imageCacheMu.Lock()
 		if cached, ok := imageCache[imageID]; ok {
 			imageCacheMu.Unlock()
@@ -480,8 +698,9 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, err
 
 		val, _, _ := imageGroup.Do(imageID, func() (any, error) {
 			var digests []string
-			if info, _, err := c.cli.ImageInspectWithRaw(ctx, imageID); err == nil {
+			if info, _, err := c.api().ImageInspectWithRaw(ctx, imageID); err == nil {
 				digests = info.RepoDigests
+				c.RecordImageDigests(imageID, digests)
 			}
 			imageCacheMu.Lock()
 			imageCache[imageID] = digests
@@ -509,8 +728,9 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, err
 			enhanced := ContainerWithDigest{
 				Container:   ctr,
 				RepoDigests: []string{},
+				CrashLoop:   c.crashLoops.InLoop(ctr.ID),
 			}
-			if ctr.ImageID != "" {
+			if withDigests && ctr.ImageID != "" {
 				if digests := inspectImage(gctx, ctr.ImageID); digests != nil {
 					enhanced.RepoDigests = digests
 				}
@@ -528,7 +748,7 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, err
 			}
 
 			if !hasStartedAt || !hasEnv {
-				if inspect, err := c.cli.ContainerInspect(gctx, ctr.ID); err == nil {
+				if inspect, err := c.api().ContainerInspect(gctx, ctr.ID); err == nil {
 					if !hasStartedAt && inspect.State != nil {
 						// Docker uses "0001-01-01T00:00:00Z" for never-started
 						// containers; don't surface that as a real timestamp.
@@ -566,25 +786,31 @@ func (c *Client) ListContainers(ctx context.Context) ([]ContainerWithDigest, err
 
 // InspectContainer inspects a container
 func (c *Client) InspectContainer(ctx context.Context, containerID string) (types.ContainerJSON, error) {
-	inspect, err := c.cli.ContainerInspect(ctx, containerID)
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
 	if err != nil {
 		return types.ContainerJSON{}, fmt.Errorf("failed to inspect container: %w", err)
 	}
 	return inspect, nil
 }
 
+// DiffContainer returns a container's filesystem changes and config drift
+// from its image
+func (c *Client) DiffContainer(ctx context.Context, containerID string) (*sharedDocker.ContainerDiff, error) {
+	return sharedDocker.DiffContainer(ctx, c.api(), containerID)
+}
+
 // StartContainer starts a container
 func (c *Client) StartContainer(ctx context.Context, containerID string) error {
-	if err := c.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+	if err := c.api().ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
 		return fmt.Errorf("failed to start container: %w", err)
 	}
 	return nil
 }
 
-// StopContainer stops a container
-func (c *Client) StopContainer(ctx context.Context, containerID string, timeout int) error {
-	stopTimeout := timeout
-	if err := c.cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
+// StopContainer stops a container. strategy may be nil to use the container's
+// StopSignal/StopTimeout, with timeout as the fallback.
+func (c *Client) StopContainer(ctx context.Context, containerID string, timeout int, strategy *sharedDocker.StopStrategy) error {
+	if err := sharedDocker.StopContainer(ctx, c.api(), containerID, strategy, timeout); err != nil {
 		return fmt.Errorf("failed to stop container: %w", err)
 	}
 	return nil
@@ -593,7 +819,7 @@ func (c *Client) StopContainer(ctx context.Context, containerID string, timeout
 // RestartContainer restarts a container
 func (c *Client) RestartContainer(ctx context.Context, containerID string, timeout int) error {
 	stopTimeout := timeout
-	if err := c.cli.ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
+	if err := c.api().ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
 		return fmt.Errorf("failed to restart container: %w", err)
 	}
 	return nil
@@ -601,7 +827,7 @@ func (c *Client) RestartContainer(ctx context.Context, containerID string, timeo
 
 // RemoveContainer removes a container
 func (c *Client) RemoveContainer(ctx context.Context, containerID string, force bool) error {
-	if err := c.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: force}); err != nil {
+	if err := c.api().ContainerRemove(ctx, containerID, container.RemoveOptions{Force: force}); err != nil {
 		return fmt.Errorf("failed to remove container: %w", err)
 	}
 	return nil
@@ -609,17 +835,18 @@ func (c *Client) RemoveContainer(ctx context.Context, containerID string, force
 
 // KillContainer sends SIGKILL to a container
 func (c *Client) KillContainer(ctx context.Context, containerID string) error {
-	if err := c.cli.ContainerKill(ctx, containerID, "SIGKILL"); err != nil {
+	if err := c.api().ContainerKill(ctx, containerID, "SIGKILL"); err != nil {
 		return fmt.Errorf("failed to kill container: %w", err)
 	}
 	return nil
 }
 
-// GetContainerLogs retrieves container logs
-func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail string) (string, error) {
+// GetContainerLogs retrieves container logs, with timestamps rendered as ts
+// selects
+func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail string, ts sharedDocker.LogTimestamps) (string, error) {
 	// First, inspect the container to check if it's running with TTY
 	// TTY containers return raw logs without multiplexing headers
-	inspect, err := c.cli.ContainerInspect(ctx, containerID)
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
 	if err != nil {
 		return "", fmt.Errorf("failed to inspect container: %w", err)
 	}
@@ -627,11 +854,11 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Timestamps: true,
+		Timestamps: ts.Docker(),
 		Tail:       tail,
 	}
 
-	logs, err := c.cli.ContainerLogs(ctx, containerID, options)
+	logs, err := c.api().ContainerLogs(ctx, containerID, options)
 	if err != nil {
 		return "", fmt.Errorf("failed to get logs: %w", err)
 	}
@@ -645,7 +872,7 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail
 		if _, err := io.Copy(&buf, logs); err != nil {
 			return "", fmt.Errorf("failed to read logs: %w", err)
 		}
-		return buf.String(), nil
+		return ts.Format(buf.String(), time.Now()), nil
 	}
 
 	// Non-TTY mode: demultiplex stdout/stderr streams
@@ -656,23 +883,29 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail
 
 	// Combine stdout and stderr
 	result := stdout.String() + stderr.String()
-	return result, nil
+	return ts.Format(result, time.Now()), nil
 }
 
 // ContainerStats gets a stats stream for a container
 func (c *Client) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
-	return c.cli.ContainerStats(ctx, containerID, stream)
+	return c.api().ContainerStats(ctx, containerID, stream)
 }
 
 // WatchEvents watches Docker events
 func (c *Client) WatchEvents(ctx context.Context) (<-chan events.Message, <-chan error) {
-	eventChan, errChan := c.cli.Events(ctx, events.ListOptions{})
+	eventChan, errChan := c.api().Events(ctx, events.ListOptions{})
 	return eventChan, errChan
 }
 
+// Ping checks that the Docker daemon is answering
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.api().Ping(ctx)
+	return err
+}
+
 // PullImage pulls a Docker image
 func (c *Client) PullImage(ctx context.Context, imageName string) error {
-	reader, err := c.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	reader, err := c.api().ImagePull(ctx, imageName, image.PullOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to pull image: %w", err)
 	}
@@ -748,7 +981,7 @@ func (c *Client) PullImageWithProgress(ctx context.Context, imageName string, au
 		c.log.Debug("Using registry authentication for image pull")
 	}
 
-	reader, err := c.cli.ImagePull(ctx, imageName, pullOpts)
+	reader, err := c.api().ImagePull(ctx, imageName, pullOpts)
 	if err != nil {
 		return fmt.Errorf("failed to pull image: %w", err)
 	}
@@ -805,7 +1038,7 @@ func (c *Client) PullImageWithProgress(ctx context.Context, imageName string, au
 
 // CreateContainer creates a new container
 func (c *Client) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
-	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, name)
+	resp, err := c.api().ContainerCreate(ctx, config, hostConfig, nil, nil, name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
@@ -814,7 +1047,7 @@ func (c *Client) CreateContainer(ctx context.Context, config *container.Config,
 
 // RenameContainer renames a container
 func (c *Client) RenameContainer(ctx context.Context, containerID, newName string) error {
-	if err := c.cli.ContainerRename(ctx, containerID, newName); err != nil {
+	if err := c.api().ContainerRename(ctx, containerID, newName); err != nil {
 		return fmt.Errorf("failed to rename container: %w", err)
 	}
 	return nil
@@ -828,7 +1061,7 @@ func (c *Client) ConnectNetwork(
 	networkID string,
 	endpointConfig *network.EndpointSettings,
 ) error {
-	return c.cli.NetworkConnect(ctx, networkID, containerID, endpointConfig)
+	return c.api().NetworkConnect(ctx, networkID, containerID, endpointConfig)
 }
 
 // IsPodman returns true if connected to Podman instead of Docker.
@@ -842,7 +1075,7 @@ func (c *Client) IsPodman(ctx context.Context) (bool, error) {
 		return *c.isPodmanCache, nil
 	}
 
-	info, err := c.cli.Info(ctx)
+	info, err := c.api().Info(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to get Docker info: %w", err)
 	}
@@ -858,7 +1091,7 @@ func (c *Client) IsPodman(ctx context.Context) (bool, error) {
 
 	// 2. Server version components contain "podman"
 	if !isPodman {
-		version, err := c.cli.ServerVersion(ctx)
+		version, err := c.api().ServerVersion(ctx)
 		if err == nil {
 			for _, comp := range version.Components {
 				if strings.ToLower(comp.Name) == "podman" {
@@ -880,7 +1113,7 @@ func (c *Client) GetContainerByName(ctx context.Context, name string) (string, e
 	// Remove leading slash if present
 	name = stripContainerNamePrefix(name)
 
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{
 		All:     true,
 		Filters: filters.NewArgs(filters.Arg("name", "^/"+regexp.QuoteMeta(name)+"$")),
 	})
@@ -898,7 +1131,7 @@ func (c *Client) GetContainerByName(ctx context.Context, name string) (string, e
 // ListAllContainers returns all containers (running and stopped).
 // This is the typed version that returns types.Container slice.
 func (c *Client) ListAllContainers(ctx context.Context) ([]types.Container, error) {
-	return c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	return c.api().ContainerList(ctx, container.ListOptions{All: true})
 }
 
 // CreateContainerWithNetwork creates a new container with full network configuration.
@@ -910,7 +1143,7 @@ func (c *Client) CreateContainerWithNetwork(
 	networkConfig *network.NetworkingConfig,
 	name string,
 ) (string, error) {
-	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, name)
+	resp, err := c.api().ContainerCreate(ctx, config, hostConfig, networkConfig, nil, name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
@@ -928,7 +1161,7 @@ func (c *Client) GetAPIVersion(ctx context.Context) (string, error) {
 		return c.apiVersionCache, nil
 	}
 
-	version, err := c.cli.ServerVersion(ctx)
+	version, err := c.api().ServerVersion(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get server version: %w", err)
 	}
@@ -971,6 +1204,32 @@ func (c *Client) SupportsNetworkingConfig(ctx context.Context) (bool, error) {
 	return false, nil
 }
 
+// mountinfoContainerID matches the host-side path of a file the runtime
+// bind-mounts into each container: .../containers/<id>/hostname for Docker
+// (rootful or rootless) and .../overlay-containers/<id>/userdata/hostname
+// for Podman
+var mountinfoContainerID = regexp.MustCompile(`containers/([0-9a-f]{64})/`)
+
+// parseContainerIDFromMountinfo extracts the container ID from
+// /proc/self/mountinfo via the mount of /etc/hostname, /etc/hosts or
+// /etc/resolv.conf, whose source (the 4th field) lies in the container's
+// directory on the host
+func parseContainerIDFromMountinfo(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+		switch fields[4] {
+		case "/etc/hostname", "/etc/hosts", "/etc/resolv.conf":
+			if m := mountinfoContainerID.FindStringSubmatch(fields[3]); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
 // parseContainerIDFromCgroup extracts container ID from /proc/self/cgroup
 func parseContainerIDFromCgroup(data string) string {
 	// Handles multiple cgroup formats (v1 and v2)
@@ -979,6 +1238,9 @@ func parseContainerIDFromCgroup(data string) string {
 	// cgroup v2: 0::/docker/abc123...
 	// systemd:   0::/system.slice/docker-abc123.scope
 	// podman:    0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-abc123.scope
+	// rootless:  0::/user.slice/user-1000.slice/user@1000.service/app.slice/docker-abc123.scope
+	// A private cgroup namespace (the cgroup v2 default) reads "0::/" and
+	// has no ID; GetMyContainerID falls back to mountinfo then.
 
 	lines := strings.Split(data, "\n")
 	for _, line := range lines {
@@ -1080,7 +1342,7 @@ func (c *Client) ExecCreate(ctx context.Context, containerID string, config Exec
 		Env:          config.Env,
 	}
 
-	resp, err := c.cli.ContainerExecCreate(ctx, containerID, execConfig)
+	resp, err := c.api().ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create exec: %w", err)
 	}
@@ -1090,17 +1352,86 @@ func (c *Client) ExecCreate(ctx context.Context, containerID string, config Exec
 
 // ExecAttach attaches to an exec instance and returns a hijacked connection
 func (c *Client) ExecAttach(ctx context.Context, execID string, tty bool) (types.HijackedResponse, error) {
-	return c.cli.ContainerExecAttach(ctx, execID, container.ExecStartOptions{Tty: tty})
+	return c.api().ContainerExecAttach(ctx, execID, container.ExecStartOptions{Tty: tty})
 }
 
 // ExecResize resizes the TTY of an exec instance
 func (c *Client) ExecResize(ctx context.Context, execID string, height, width uint) error {
-	return c.cli.ContainerExecResize(ctx, execID, container.ResizeOptions{
+	return c.api().ContainerExecResize(ctx, execID, container.ResizeOptions{
 		Height: height,
 		Width:  width,
 	})
 }
 
+// maxExecOutput bounds the output kept by ExecRun
+const maxExecOutput = 16 * 1024
+
+// ExecRun runs a command in a container without a TTY and waits for it to
+// exit. Returns the end of its combined stdout/stderr and its exit code.
+func (c *Client) ExecRun(ctx context.Context, containerID string, cmd []string) (string, int, error) {
+	resp, err := c.api().ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	attach, err := c.api().ContainerExecAttach(ctx, resp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to start exec: %w", err)
+	}
+	defer attach.Close()
+
+	out := &tailBuffer{max: maxExecOutput}
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(out, out, attach.Reader)
+		done <- err
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Unblocks the copy; the process keeps running in the container
+		attach.Close()
+		<-done
+		return out.String(), 0, ctx.Err()
+	}
+	if err != nil {
+		return out.String(), 0, fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspect, err := c.api().ContainerExecInspect(ctx, resp.ID)
+	if err != nil {
+		return out.String(), 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return out.String(), inspect.ExitCode, nil
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
 // ContainerRef represents a minimal container reference for linking
 type ContainerRef struct {
 	ID   string `json:"id"`   // 12-char short ID
@@ -1128,13 +1459,13 @@ type ImagePruneResult struct {
 // ListImages returns all images with usage information
 func (c *Client) ListImages(ctx context.Context) ([]ImageInfo, error) {
 	// Get all images
-	images, err := c.cli.ImageList(ctx, image.ListOptions{All: true})
+	images, err := c.api().ImageList(ctx, image.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list images: %w", err)
 	}
 
 	// Get all containers to determine image usage
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -1184,7 +1515,7 @@ func (c *Client) ListImages(ctx context.Context) ([]ImageInfo, error) {
 
 // RemoveImage removes a Docker image
 func (c *Client) RemoveImage(ctx context.Context, imageID string, force bool) error {
-	_, err := c.cli.ImageRemove(ctx, imageID, image.RemoveOptions{
+	_, err := c.api().ImageRemove(ctx, imageID, image.RemoveOptions{
 		Force:         force,
 		PruneChildren: true,
 	})
@@ -1197,7 +1528,7 @@ func (c *Client) RemoveImage(ctx context.Context, imageID string, force bool) er
 // PruneImages removes all unused images
 func (c *Client) PruneImages(ctx context.Context) (*ImagePruneResult, error) {
 	// Use filters to prune ALL unused images (not just dangling)
-	report, err := c.cli.ImagesPrune(ctx, filters.NewArgs(
+	report, err := c.api().ImagesPrune(ctx, filters.NewArgs(
 		filters.Arg("dangling", "false"),
 	))
 	if err != nil {
@@ -1218,6 +1549,12 @@ func (c *Client) PruneImages(ctx context.Context) (*ImagePruneResult, error) {
 	}, nil
 }
 
+// PruneImagesWithPolicy removes unused images under the policy's safety
+// rules, or only reports what it would remove in a dry run
+func (c *Client) PruneImagesWithPolicy(ctx context.Context, policy sharedDocker.ImagePrunePolicy) (*sharedDocker.ImagePruneReport, error) {
+	return sharedDocker.PruneImagesWithPolicy(ctx, c.api(), policy)
+}
+
 // safeUint64ToInt64 converts uint64 to int64, clamping at math.MaxInt64 to prevent overflow.
 func safeUint64ToInt64(v uint64) int64 {
 	if v > uint64(math.MaxInt64) {
@@ -1330,7 +1667,7 @@ func buildCreateNetworkOptions(driver, subnet, gateway string, internal bool) ne
 
 // ListNetworks returns all networks with connected container info
 func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
-	networks, err := c.cli.NetworkList(ctx, network.ListOptions{})
+	networks, err := c.api().NetworkList(ctx, network.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list networks: %w", err)
 	}
@@ -1339,7 +1676,7 @@ func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
 	for _, net := range networks {
 		// NetworkList doesn't populate Containers - inspect to get them.
 		// On inspect failure, fall back to the (container-less) summary data.
-		inspected, err := c.cli.NetworkInspect(ctx, net.ID, network.InspectOptions{})
+		inspected, err := c.api().NetworkInspect(ctx, net.ID, network.InspectOptions{})
 		if err != nil {
 			c.log.WithError(err).Warnf("Failed to inspect network %s, using summary data", net.Name)
 			result = append(result, networkInfoFromInspect(net))
@@ -1354,7 +1691,7 @@ func (c *Client) ListNetworks(ctx context.Context) ([]NetworkInfo, error) {
 // DeleteNetwork removes a Docker network
 func (c *Client) DeleteNetwork(ctx context.Context, networkID string, force bool) error {
 	// Get network to check if it's built-in and get name for error messages
-	net, err := c.cli.NetworkInspect(ctx, networkID, network.InspectOptions{})
+	net, err := c.api().NetworkInspect(ctx, networkID, network.InspectOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to inspect network: %w", err)
 	}
@@ -1372,14 +1709,14 @@ func (c *Client) DeleteNetwork(ctx context.Context, networkID string, force bool
 	// If force is true and there are connected containers, disconnect them first
 	if len(net.Containers) > 0 && force {
 		for containerID := range net.Containers {
-			if err := c.cli.NetworkDisconnect(ctx, networkID, containerID, true); err != nil {
+			if err := c.api().NetworkDisconnect(ctx, networkID, containerID, true); err != nil {
 				c.log.WithError(err).Warnf("Failed to disconnect container %s from network %s", truncateID(containerID), net.Name)
 			}
 		}
 	}
 
 	// Remove the network
-	if err := c.cli.NetworkRemove(ctx, networkID); err != nil {
+	if err := c.api().NetworkRemove(ctx, networkID); err != nil {
 		return fmt.Errorf("failed to remove network: %w", err)
 	}
 
@@ -1394,13 +1731,13 @@ func (c *Client) CreateNetwork(ctx context.Context, name, driver, subnet, gatewa
 
 	opts := buildCreateNetworkOptions(driver, subnet, gateway, internal)
 
-	resp, err := c.cli.NetworkCreate(ctx, name, opts)
+	resp, err := c.api().NetworkCreate(ctx, name, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create network: %w", err)
 	}
 
 	// NetworkCreate returns only an ID; inspect for the full shape.
-	inspected, err := c.cli.NetworkInspect(ctx, resp.ID, network.InspectOptions{})
+	inspected, err := c.api().NetworkInspect(ctx, resp.ID, network.InspectOptions{})
 	if err != nil {
 		// Created successfully; don't fail the operation on a late inspect error.
 		c.log.WithError(err).Warnf("Created network %s but failed to inspect it", name)
@@ -1425,7 +1762,7 @@ type NetworkPruneResult struct {
 
 // PruneNetworks removes all unused networks
 func (c *Client) PruneNetworks(ctx context.Context) (*NetworkPruneResult, error) {
-	report, err := c.cli.NetworksPrune(ctx, filters.Args{})
+	report, err := c.api().NetworksPrune(ctx, filters.Args{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to prune networks: %w", err)
 	}
@@ -1463,13 +1800,13 @@ type VolumeInfo struct {
 // ListVolumes returns all volumes with usage information
 func (c *Client) ListVolumes(ctx context.Context) ([]VolumeInfo, error) {
 	// Get all volumes
-	volumeListBody, err := c.cli.VolumeList(ctx, volume.ListOptions{})
+	volumeListBody, err := c.api().VolumeList(ctx, volume.ListOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list volumes: %w", err)
 	}
 
 	// Get all containers to determine volume usage
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
@@ -1524,7 +1861,7 @@ func (c *Client) DeleteVolume(ctx context.Context, volumeName string, force bool
 	if volumeName == "" {
 		return fmt.Errorf("volume name cannot be empty")
 	}
-	err := c.cli.VolumeRemove(ctx, volumeName, force)
+	err := c.api().VolumeRemove(ctx, volumeName, force)
 	if err != nil {
 		return fmt.Errorf("failed to delete volume: %w", err)
 	}
@@ -1544,7 +1881,7 @@ func (c *Client) PruneVolumes(ctx context.Context) (*VolumePruneResult, error) {
 	pruneFilters := filters.NewArgs()
 	pruneFilters.Add("all", "true")
 
-	report, err := c.cli.VolumesPrune(ctx, pruneFilters)
+	report, err := c.api().VolumesPrune(ctx, pruneFilters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prune volumes: %w", err)
 	}
@@ -3,6 +3,10 @@ package docker
 import (
 	"encoding/base64"
 	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 
@@ -10,12 +14,14 @@ import (
 )
 
 // newCacheClient returns a Client with just the fields needed to exercise the
-// inspect caches (startedAt + env) — no Docker socket, no logger. The other
-// fields stay zero. Both maps must be initialized; writing to a nil map panics.
+// inspect caches (startedAt + env + digests) — no Docker socket, no logger.
+// The other fields stay zero. The maps must be initialized; writing to a nil
+// map panics.
 func newCacheClient() *Client {
 	return &Client{
 		startedAt: make(map[string]string),
 		env:       make(map[string]map[string]string),
+		digests:   make(map[string][]string),
 	}
 }
 
@@ -118,6 +124,46 @@ func TestStartedAtCache_ConcurrentReadWrite(t *testing.T) {
 	wg.Wait()
 }
 
+func TestImageDigestCache_RecordLookupInvalidate(t *testing.T) {
+	c := newCacheClient()
+
+	if _, ok := c.LookupImageDigests("sha256:abc"); ok {
+		t.Fatal("empty lookup: got hit, want miss")
+	}
+
+	c.RecordImageDigests("sha256:abc", []string{"nginx@sha256:111"})
+	if got, ok := c.LookupImageDigests("sha256:abc"); !ok || len(got) != 1 || got[0] != "nginx@sha256:111" {
+		t.Fatalf("after record: got (%v, %v)", got, ok)
+	}
+
+	// Locally built images have no digests; cache that as a hit
+	c.RecordImageDigests("sha256:local", nil)
+	if got, ok := c.LookupImageDigests("sha256:local"); !ok || got == nil || len(got) != 0 {
+		t.Fatalf("nil digests: got (%v, %v), want non-nil empty hit", got, ok)
+	}
+
+	c.RecordImageDigests("", []string{"x"})
+	if _, ok := c.LookupImageDigests(""); ok {
+		t.Fatal("empty image ID should not be recorded")
+	}
+
+	c.InvalidateImageDigests()
+	if _, ok := c.LookupImageDigests("sha256:abc"); ok {
+		t.Fatal("after invalidate: entry still present")
+	}
+}
+
+func TestStartedAtCache_ResetClearsImageDigests(t *testing.T) {
+	c := newCacheClient()
+
+	c.RecordImageDigests("sha256:abc", []string{"nginx@sha256:111"})
+	c.ResetStartedAtCache()
+
+	if _, ok := c.LookupImageDigests("sha256:abc"); ok {
+		t.Fatal("reset should drop digests that may have gone stale while disconnected")
+	}
+}
+
 func TestEncodeRegistryAuth(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -219,3 +265,177 @@ func TestRegistryAuthStructOptionalFields(t *testing.T) {
 		t.Errorf("expected empty password, got %q", auth.Password)
 	}
 }
+
+func TestParseFibTrie(t *testing.T) {
+	data := []byte(`Main:
+  +-- 0.0.0.0/0 3 0 5
+     |-- 0.0.0.0
+        /0 universe UNICAST
+     +-- 127.0.0.0/8 2 0 2
+        |-- 127.0.0.1
+           /32 host LOCAL
+     |-- 169.254.3.4
+        /32 host LOCAL
+     +-- 192.168.1.0/24 2 0 2
+        |-- 192.168.1.0
+           /24 link UNICAST
+        |-- 192.168.1.50
+           /32 host LOCAL
+Local:
+     |-- 192.168.1.50
+        /32 host LOCAL
+`)
+	got := parseFibTrie(data)
+	if len(got) != 1 || got[0] != "192.168.1.50" {
+		t.Errorf("parseFibTrie = %v, want [192.168.1.50]", got)
+	}
+}
+
+func TestParseIfInet6(t *testing.T) {
+	data := []byte(`00000000000000000000000000000001 01 80 10 80       lo
+fe80000000000000021122fffe334455 02 40 20 80     eth0
+20010db8000000000000000000000010 02 40 00 80     eth0
+20010db80000000012345678abcdef01 02 40 00 01     eth0
+20010db8000000000000000000000020 02 40 00 20     eth0
+20010db8000000000000000000000030 02 40 00 c0     eth0
+fd000000000000000000000000000001 05 40 00 80  docker0
+fd0000000000000000000000000000aa 03 40 00 80     eth1
+20010db8000000000000000000000010 02 40 00 80     eth0
+`)
+	got := parseIfInet6(data)
+	want := []string{"2001:db8::10", "fd00::aa"}
+	if len(got) != len(want) {
+		t.Fatalf("parseIfInet6 = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseIfInet6[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetHostIPsFromProc_DualStackAndIPv6Only(t *testing.T) {
+	dir := t.TempDir()
+	netDir := filepath.Join(dir, "1", "net")
+	if err := os.MkdirAll(netDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inet6 := []byte("20010db8000000000000000000000010 02 40 00 80     eth0\n")
+	if err := os.WriteFile(filepath.Join(netDir, "if_inet6"), inet6, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// IPv6-only: no fib_trie entries
+	if got := GetHostIPsFromProc(dir); len(got) != 1 || got[0] != "2001:db8::10" {
+		t.Errorf("IPv6-only host: got %v", got)
+	}
+
+	fib := []byte("Local:\n     |-- 10.0.0.5\n        /32 host LOCAL\n")
+	if err := os.WriteFile(filepath.Join(netDir, "fib_trie"), fib, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetHostIPsFromProc(dir); len(got) != 2 || got[0] != "10.0.0.5" || got[1] != "2001:db8::10" {
+		t.Errorf("dual-stack host: got %v, want IPv4 first", got)
+	}
+
+	if got := GetHostIPsFromProc(t.TempDir()); got != nil {
+		t.Errorf("missing procfs: got %v, want nil", got)
+	}
+}
+
+func TestSortHostIPs(t *testing.T) {
+	got := sortHostIPs([]string{"2001:db8::1", "192.168.1.2", "fd00::2", "10.0.0.1"})
+	want := []string{"192.168.1.2", "10.0.0.1", "2001:db8::1", "fd00::2"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sortHostIPs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestIsHostIP(t *testing.T) {
+	cases := map[string]bool{
+		"192.168.1.2": true,
+		"2001:db8::1": true,
+		"fd00::1":     true,
+		"127.0.0.1":   false,
+		"::1":         false,
+		"169.254.1.1": false,
+		"fe80::1":     false,
+		"ff02::1":     false,
+		"::":          false,
+	}
+	for s, want := range cases {
+		if got := isHostIP(net.ParseIP(s)); got != want {
+			t.Errorf("isHostIP(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestParseContainerIDFromCgroup_Rootless(t *testing.T) {
+	id := strings.Repeat("ab12", 16)
+	tests := []struct {
+		name, cgroup, want string
+	}{
+		{"rootless docker", "0::/user.slice/user-1000.slice/user@1000.service/app.slice/docker-" + id + ".scope\n", id},
+		{"rootless podman", "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + id + ".scope/container\n", id},
+		{"private namespace", "0::/\n", ""},
+	}
+	for _, tt := range tests {
+		if got := parseContainerIDFromCgroup(tt.cgroup); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseContainerIDFromMountinfo(t *testing.T) {
+	id := strings.Repeat("cd34", 16)
+	other := strings.Repeat("ef56", 16)
+	tests := []struct {
+		name, mountinfo, want string
+	}{
+		{"rootless docker",
+			"1200 1190 0:45 / / rw,relatime - overlay overlay rw\n" +
+				"1210 1200 259:2 /home/me/.local/share/docker/containers/" + id + "/hostname /etc/hostname rw - ext4 /dev/nvme0n1p2 rw\n",
+			id},
+		{"podman",
+			"700 650 0:50 /containers/storage/overlay-containers/" + id + "/userdata/resolv.conf /etc/resolv.conf rw - tmpfs tmpfs rw\n",
+			id},
+		{"other mounts ignored",
+			"800 650 259:2 /var/lib/docker/containers/" + other + "/hostname /backup/hostname rw - ext4 /dev/sda1 rw\n",
+			""},
+	}
+	for _, tt := range tests {
+		if got := parseContainerIDFromMountinfo(tt.mountinfo); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadMyContainerID_FallsBackToMountinfo(t *testing.T) {
+	dir := t.TempDir()
+	id := strings.Repeat("0f", 32)
+	cgroup := filepath.Join(dir, "cgroup")
+	mountinfo := filepath.Join(dir, "mountinfo")
+	os.WriteFile(cgroup, []byte("0::/\n"), 0o600)
+	os.WriteFile(mountinfo, []byte("1 0 0:1 /var/lib/docker/containers/"+id+"/hosts /etc/hosts rw - ext4 /dev/sda1 rw\n"), 0o600)
+
+	got, err := readMyContainerID(cgroup, mountinfo)
+	if err != nil || got != id {
+		t.Errorf("got %q, %v; want the ID from mountinfo", got, err)
+	}
+
+	os.WriteFile(mountinfo, nil, 0o600)
+	if _, err := readMyContainerID(cgroup, mountinfo); err == nil {
+		t.Error("expected an error when neither file names the container")
+	}
+}
+
+func TestIsRootless(t *testing.T) {
+	if !isRootless([]string{"name=seccomp,profile=builtin", "name=rootless", "name=cgroupns"}) {
+		t.Error("rootless daemon not detected")
+	}
+	if isRootless([]string{"name=seccomp,profile=builtin"}) {
+		t.Error("rootful daemon reported as rootless")
+	}
+}
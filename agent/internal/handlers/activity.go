@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+)
+
+// agentVersionFile records the version the agent last started as, so a
+// completed self-update can be detected on the next start
+const agentVersionFile = "agent_version"
+
+// ActivityPublisher sends DockMon activity events to the stats-service event
+// stream. *client.StatsServiceClient satisfies it structurally, like
+// StatsServiceSender.
+type ActivityPublisher interface {
+	Publish(event activity.Event)
+}
+
+// RecordAgentVersion stores version in dataDir and returns the version that
+// was recorded before. changed is false on first start (nothing recorded)
+// and when the version is the same.
+func RecordAgentVersion(dataDir, version string) (previous string, changed bool, err error) {
+	path := filepath.Join(dataDir, agentVersionFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	previous = strings.TrimSpace(string(data))
+	if previous == version {
+		return previous, false, nil
+	}
+	if err := os.WriteFile(path, []byte(version+"\n"), 0600); err != nil {
+		return previous, false, err
+	}
+	return previous, previous != "", nil
+}
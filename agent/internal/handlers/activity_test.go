@@ -0,0 +1,22 @@
+package handlers
+
+import "testing"
+
+func TestRecordAgentVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	// First start: nothing to compare against
+	if prev, changed, err := RecordAgentVersion(dir, "1.3.0"); err != nil || changed || prev != "" {
+		t.Fatalf("first start: prev=%q changed=%v err=%v", prev, changed, err)
+	}
+	if _, changed, _ := RecordAgentVersion(dir, "1.3.0"); changed {
+		t.Error("restart on the same version reported a change")
+	}
+	prev, changed, err := RecordAgentVersion(dir, "1.4.0")
+	if err != nil || !changed || prev != "1.3.0" {
+		t.Errorf("after update: prev=%q changed=%v err=%v", prev, changed, err)
+	}
+	if _, changed, _ := RecordAgentVersion(dir, "1.4.0"); changed {
+		t.Error("the new version should have been recorded")
+	}
+}
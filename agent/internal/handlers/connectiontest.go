@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/docker/docker/client"
+)
+
+// TestConnectionRequest asks the agent to probe a Docker daemon from its
+// own network. An empty address probes the agent's local daemon.
+type TestConnectionRequest struct {
+	HostAddress    string `json:"host_address,omitempty"`
+	TLSCACert      string `json:"tls_ca_cert,omitempty"`
+	TLSCert        string `json:"tls_cert,omitempty"`
+	TLSKey         string `json:"tls_key,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Default 5, at most 30
+}
+
+// TestConnection pings the requested daemon and reports latency, API
+// version and engine ID. An unreachable daemon is a result, not an error;
+// only an invalid request fails.
+func TestConnection(ctx context.Context, local client.APIClient, req TestConnectionRequest) (*sharedDocker.ProbeResult, error) {
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout < 0 || timeout > sharedDocker.MaxProbeTimeout {
+		return nil, fmt.Errorf("timeout_seconds must be between 0 and %d", int(sharedDocker.MaxProbeTimeout/time.Second))
+	}
+	if req.HostAddress == "" {
+		return sharedDocker.Probe(ctx, local, timeout), nil
+	}
+	return sharedDocker.ProbeHost(ctx, req.HostAddress, req.TLSCACert, req.TLSCert, req.TLSKey, timeout), nil
+}
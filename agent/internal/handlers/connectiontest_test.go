@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestTestConnection(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Header().Set("Api-Version", "1.45")
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			w.Write([]byte(`{"ID":"ENGINE-1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+	addr := "tcp://" + strings.TrimPrefix(daemon.URL, "http://")
+
+	local, err := client.NewClientWithOpts(client.WithHost(addr), client.WithAPIVersionNegotiation())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+
+	// Empty address probes the local daemon
+	result, err := TestConnection(context.Background(), local, TestConnectionRequest{})
+	if err != nil || !result.Reachable || result.EngineID != "ENGINE-1" {
+		t.Errorf("local probe: result=%+v err=%v", result, err)
+	}
+
+	result, err = TestConnection(context.Background(), nil, TestConnectionRequest{HostAddress: addr, TimeoutSeconds: 2})
+	if err != nil || !result.Reachable || result.APIVersion != "1.45" {
+		t.Errorf("remote probe: result=%+v err=%v", result, err)
+	}
+
+	if _, err := TestConnection(context.Background(), local, TestConnectionRequest{TimeoutSeconds: 60}); err == nil {
+		t.Error("timeout above the maximum should be rejected")
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+// exportProgressInterval throttles container_export_progress events
+const exportProgressInterval = 2 * time.Second
+
+// ContainerExportRequest exports a container's filesystem as a tarball
+type ContainerExportRequest struct {
+	ExportID    string `json:"export_id"`
+	ContainerID string `json:"container_id"`
+	Destination string `json:"destination,omitempty"` // "websocket" (default) or "local"
+}
+
+// ContainerExportResult is sent as the container_export_complete event
+type ContainerExportResult struct {
+	ExportID      string `json:"export_id"`
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+	Destination   string `json:"destination"`
+	Success       bool   `json:"success"`
+	Path          string `json:"path,omitempty"`
+	Size          int64  `json:"size"`
+	SHA256        string `json:"sha256,omitempty"`
+	Chunks        int    `json:"chunks,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// ContainerCommitRequest commits a container's filesystem to a new image
+type ContainerCommitRequest struct {
+	CommitID    string   `json:"commit_id"`
+	ContainerID string   `json:"container_id"`
+	Repository  string   `json:"repository,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	Comment     string   `json:"comment,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	Changes     []string `json:"changes,omitempty"` // Dockerfile instructions, e.g. "ENTRYPOINT [\"sh\"]"
+	Pause       *bool    `json:"pause,omitempty"`   // pause during commit (default true)
+}
+
+// ContainerCommitResult is sent as the container_commit_complete event
+type ContainerCommitResult struct {
+	CommitID    string `json:"commit_id"`
+	ContainerID string `json:"container_id"`
+	Success     bool   `json:"success"`
+	ImageID     string `json:"image_id,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ContainerExportHandler commits or exports container filesystems so a
+// broken container can be inspected offline
+type ContainerExportHandler struct {
+	dockerClient *docker.Client
+	log          *logrus.Logger
+	sendEvent    func(string, interface{}) error
+	exportDir    string
+}
+
+// NewContainerExportHandler creates a new container export handler
+func NewContainerExportHandler(dockerClient *docker.Client, log *logrus.Logger, sendEvent func(string, interface{}) error, exportDir string) *ContainerExportHandler {
+	return &ContainerExportHandler{
+		dockerClient: dockerClient,
+		log:          log,
+		sendEvent:    sendEvent,
+		exportDir:    exportDir,
+	}
+}
+
+// Commit creates an image from the container and reports the outcome as a
+// container_commit_complete event
+func (h *ContainerExportHandler) Commit(ctx context.Context, req ContainerCommitRequest) *ContainerCommitResult {
+	result := &ContainerCommitResult{
+		CommitID:    req.CommitID,
+		ContainerID: req.ContainerID,
+	}
+
+	if req.Repository != "" {
+		result.Reference = req.Repository
+		if req.Tag != "" {
+			result.Reference += ":" + req.Tag
+		}
+	}
+	pause := true
+	if req.Pause != nil {
+		pause = *req.Pause
+	}
+
+	resp, err := h.dockerClient.RawClient().ContainerCommit(ctx, req.ContainerID, container.CommitOptions{
+		Reference: result.Reference,
+		Comment:   req.Comment,
+		Author:    req.Author,
+		Changes:   req.Changes,
+		Pause:     pause,
+	})
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to commit container: %v", err)
+		h.log.WithError(err).WithField("container_id", req.ContainerID).Error("Container commit failed")
+	} else {
+		result.Success = true
+		result.ImageID = resp.ID
+		h.log.WithFields(logrus.Fields{
+			"container_id": req.ContainerID,
+			"image_id":     resp.ID,
+			"reference":    result.Reference,
+		}).Info("Container committed")
+	}
+
+	if sendErr := h.sendEvent("container_commit_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send container commit result")
+	}
+	return result
+}
+
+// Export streams the container filesystem as a tar to the requested
+// destination, sending container_export_progress events along the way and
+// a container_export_complete event at the end
+func (h *ContainerExportHandler) Export(ctx context.Context, req ContainerExportRequest) *ContainerExportResult {
+	if req.Destination == "" {
+		req.Destination = "websocket"
+	}
+	result := &ContainerExportResult{
+		ExportID:    req.ExportID,
+		ContainerID: req.ContainerID,
+		Destination: req.Destination,
+	}
+
+	err := h.export(ctx, req, result)
+	if err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).WithField("container_id", req.ContainerID).Error("Container export failed")
+	} else {
+		result.Success = true
+		h.log.WithFields(logrus.Fields{
+			"container_id": req.ContainerID,
+			"size":         result.Size,
+		}).Info("Container export completed")
+	}
+
+	if sendErr := h.sendEvent("container_export_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send container export result")
+	}
+	return result
+}
+
+func (h *ContainerExportHandler) export(ctx context.Context, req ContainerExportRequest, result *ContainerExportResult) error {
+	if req.ExportID == "" {
+		return fmt.Errorf("export_id is required")
+	}
+	cli := h.dockerClient.RawClient()
+
+	// Inspect with size so progress can be reported against the rootfs size
+	info, _, err := cli.ContainerInspectWithRaw(ctx, req.ContainerID, true)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	result.ContainerID = info.ID
+	result.ContainerName = strings.TrimPrefix(info.Name, "/")
+	var total int64
+	if info.SizeRootFs != nil {
+		total = *info.SizeRootFs
+	}
+
+	var out io.Writer
+	var chunks *chunkEventWriter
+	var path, partial string
+	var file *os.File
+
+	switch req.Destination {
+	case "websocket":
+		chunks = &chunkEventWriter{
+			eventType: "container_export_chunk",
+			idField:   "export_id",
+			id:        req.ExportID,
+			sendEvent: h.sendEvent,
+		}
+		out = chunks
+
+	case "local":
+		if err := os.MkdirAll(h.exportDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create export directory: %w", err)
+		}
+		name := result.ContainerName
+		if !volumeNamePattern.MatchString(name) {
+			name = info.ID[:12]
+		}
+		path = filepath.Join(h.exportDir, fmt.Sprintf("%s-%s.tar", name, time.Now().UTC().Format("20060102-150405")))
+		partial = path + ".partial"
+		file, err = os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- name built from a validated container name or ID
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		out = file
+
+	default:
+		return fmt.Errorf("unknown export destination: %s", req.Destination)
+	}
+
+	hasher := sha256.New()
+	progress := &exportProgressWriter{
+		exportID:  req.ExportID,
+		total:     total,
+		sendEvent: h.sendEvent,
+	}
+
+	copyErr := func() error {
+		reader, err := cli.ContainerExport(ctx, req.ContainerID)
+		if err != nil {
+			return fmt.Errorf("failed to export container: %w", err)
+		}
+		defer reader.Close()
+		if _, err := io.Copy(io.MultiWriter(out, hasher, progress), reader); err != nil {
+			return fmt.Errorf("failed to stream container export: %w", err)
+		}
+		if chunks != nil {
+			return chunks.Flush()
+		}
+		return nil
+	}()
+
+	if file != nil {
+		closeErr := file.Close()
+		if copyErr == nil && closeErr != nil {
+			copyErr = fmt.Errorf("failed to write export file: %w", closeErr)
+		}
+		if copyErr == nil {
+			if err := os.Rename(partial, path); err != nil {
+				copyErr = fmt.Errorf("failed to finalize export file: %w", err)
+			}
+		}
+		if copyErr != nil {
+			_ = os.Remove(partial)
+		}
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+
+	progress.send()
+	result.Path = path
+	result.Size = progress.written
+	result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	if chunks != nil {
+		result.Chunks = chunks.seq
+	}
+	return nil
+}
+
+// exportProgressWriter counts exported bytes and reports them at most every
+// exportProgressInterval. total is the container's rootfs size, which the
+// uncompressed tar roughly matches, so percentages are estimates.
+type exportProgressWriter struct {
+	exportID  string
+	total     int64
+	written   int64
+	lastSent  time.Time
+	sendEvent func(string, interface{}) error
+}
+
+func (p *exportProgressWriter) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if time.Since(p.lastSent) >= exportProgressInterval {
+		p.send()
+	}
+	return len(b), nil
+}
+
+func (p *exportProgressWriter) send() {
+	p.lastSent = time.Now()
+	payload := map[string]interface{}{
+		"export_id": p.exportID,
+		"bytes":     p.written,
+	}
+	if p.total > 0 {
+		payload["total"] = p.total
+		payload["percent"] = exportPercent(p.written, p.total)
+	}
+	// Progress is best-effort; a dropped event must not fail the export
+	_ = p.sendEvent("container_export_progress", payload)
+}
+
+// exportPercent caps at 99 until the export finishes, since the rootfs size
+// is only an estimate of the tar size
+func exportPercent(written, total int64) int {
+	if total <= 0 {
+		return 0
+	}
+	pct := int(written * 100 / total)
+	if pct > 99 {
+		pct = 99
+	}
+	return pct
+}
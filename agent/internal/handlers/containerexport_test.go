@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"testing"
+)
+
+func TestExportPercent(t *testing.T) {
+	tests := []struct {
+		written, total int64
+		want           int
+	}{
+		{0, 100, 0},
+		{50, 100, 50},
+		{100, 100, 99},
+		{250, 100, 99},
+		{10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := exportPercent(tt.written, tt.total); got != tt.want {
+			t.Errorf("exportPercent(%d, %d) = %d, want %d", tt.written, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestExportProgressWriter_Throttles(t *testing.T) {
+	var events []map[string]interface{}
+	p := &exportProgressWriter{
+		exportID: "e1",
+		total:    1000,
+		sendEvent: func(eventType string, payload interface{}) error {
+			if eventType != "container_export_progress" {
+				t.Errorf("event type = %s", eventType)
+			}
+			events = append(events, payload.(map[string]interface{}))
+			return nil
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := p.Write(make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// First write reports immediately, the rest fall inside the interval
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+	if p.written != 100 {
+		t.Errorf("written = %d, want 100", p.written)
+	}
+
+	p.send()
+	last := events[len(events)-1]
+	if last["bytes"] != int64(100) || last["total"] != int64(1000) || last["percent"] != 10 {
+		t.Errorf("progress payload = %v", last)
+	}
+}
+
+func TestExportProgressWriter_UnknownTotal(t *testing.T) {
+	var payload map[string]interface{}
+	p := &exportProgressWriter{
+		exportID: "e1",
+		sendEvent: func(_ string, v interface{}) error {
+			payload = v.(map[string]interface{})
+			return nil
+		},
+	}
+	p.send()
+	if _, ok := payload["percent"]; ok {
+		t.Error("percent reported without a known total")
+	}
+}
@@ -5,8 +5,9 @@ import (
 	"fmt"
 
 	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-shared/activity"
 	"github.com/darthnorse/dockmon-shared/compose"
-	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/progress"
 	"github.com/sirupsen/logrus"
 )
 
@@ -15,8 +16,9 @@ type DeployHandler struct {
 	dockerClient  *docker.Client
 	log           *logrus.Logger
 	sendEvent     func(msgType string, payload interface{}) error
-	stacksDir     string // Persistent stack directory for compose deployments
-	hostStacksDir string // Host-side stacks path for resolving relative bind mounts
+	stacksDir     string            // Persistent stack directory for compose deployments
+	hostStacksDir string            // Host-side stacks path for resolving relative bind mounts
+	activity      ActivityPublisher // Optional: announces deployed stacks to stats-service
 }
 
 // DeployComposeRequest is sent from backend to agent
@@ -35,6 +37,15 @@ type DeployComposeRequest struct {
 	WaitForHealthy      bool                         `json:"wait_for_healthy,omitempty"`
 	HealthTimeout       int                          `json:"health_timeout,omitempty"`
 	RegistryCredentials []compose.RegistryCredential `json:"registry_credentials,omitempty"`
+	// Included/override compose files (path -> content) and the override
+	// merge order, see compose.DeployRequest
+	ComposeFiles     map[string]string `json:"compose_files,omitempty"`
+	ComposeOverrides []string          `json:"compose_overrides,omitempty"`
+	// BindPaths enables the pre-deploy bind mount check (nil skips it)
+	BindPaths *compose.BindPathOptions `json:"bind_paths,omitempty"`
+	// Template renders the compose files with values and this host's facts
+	// (nil deploys them as they are)
+	Template *compose.TemplateOptions `json:"template,omitempty"`
 }
 
 // DeployComposeResult is sent from agent to backend on completion
@@ -47,6 +58,8 @@ type DeployComposeResult struct {
 	Services       map[string]compose.ServiceResult `json:"services,omitempty"`
 	FailedServices []string                        `json:"failed_services,omitempty"`
 	Error          string                          `json:"error,omitempty"`
+	// BindPaths holds the pre-deploy bind mount check results
+	BindPaths []compose.BindPathResult `json:"bind_paths,omitempty"`
 }
 
 // NewDeployHandler creates a new deploy handler using the Docker Compose Go library
@@ -74,6 +87,12 @@ func NewDeployHandler(
 	}, nil
 }
 
+// SetActivityPublisher enables stack events on the stats-service activity
+// stream. Call before any deployment runs.
+func (h *DeployHandler) SetActivityPublisher(p ActivityPublisher) {
+	h.activity = p
+}
+
 // DeployCompose handles the deploy_compose command
 func (h *DeployHandler) DeployCompose(ctx context.Context, req DeployComposeRequest) (result *DeployComposeResult) {
 	// Ensure Action is set on every return path
@@ -91,18 +110,18 @@ func (h *DeployHandler) DeployCompose(ctx context.Context, req DeployComposeRequ
 
 	h.sendProgress(req.DeploymentID, compose.DeployStageStarting, "Starting deployment...")
 
-	// Create a Docker SDK client from the agent's internal client
+	// Take a Docker SDK client from the agent's client pool
 	// We use local socket since the agent always runs on the target host
-	dockerClient, err := sharedDocker.CreateLocalClient()
+	dockerClient, releaseClient, err := h.dockerClient.Pool().Get("", "", "", "")
 	if err != nil {
 		return h.failResult(req.DeploymentID, fmt.Sprintf("Failed to create Docker client: %v", err))
 	}
-	defer dockerClient.Close()
+	defer releaseClient()
 
 	// Create shared compose service with progress callback
 	svc := compose.NewService(dockerClient, h.log, compose.WithProgressCallback(func(event compose.ProgressEvent) {
 		// Forward progress to WebSocket
-		h.sendProgress(req.DeploymentID, string(event.Stage), event.Message)
+		h.sendProgressEvent(event.ToProgress(req.DeploymentID))
 	}))
 
 	// Convert request to shared type
@@ -112,6 +131,10 @@ func (h *DeployHandler) DeployCompose(ctx context.Context, req DeployComposeRequ
 		ComposeYAML:         req.ComposeContent,
 		EnvFileContent:      req.EnvFileContent,
 		EnvFiles:            req.EnvFiles,
+		ComposeFiles:        req.ComposeFiles,
+		ComposeOverrides:    req.ComposeOverrides,
+		BindPaths:           req.BindPaths,
+		Template:            req.Template,
 		Profiles:            req.Profiles,
 		Action:              req.Action,
 		RemoveVolumes:       req.RemoveVolumes,
@@ -126,6 +149,15 @@ func (h *DeployHandler) DeployCompose(ctx context.Context, req DeployComposeRequ
 
 	// Execute deployment using shared package
 	sharedResult := svc.Deploy(ctx, sharedReq)
+	if h.activity != nil && req.Action == "up" && (sharedResult.Success || sharedResult.PartialSuccess) {
+		h.activity.Publish(activity.Event{
+			Action: activity.ActionStackDeployed,
+			Attributes: map[string]string{
+				"project_name":  req.ProjectName,
+				"deployment_id": req.DeploymentID,
+			},
+		})
+	}
 
 	// Convert result back to agent format
 	return h.convertResult(sharedResult)
@@ -140,6 +172,7 @@ func (h *DeployHandler) convertResult(result *compose.DeployResult) *DeployCompo
 		PartialSuccess: result.PartialSuccess,
 		Services:       result.Services,
 		FailedServices: result.FailedServices,
+		BindPaths:      result.BindPaths,
 	}
 
 	if result.Error != nil {
@@ -151,13 +184,17 @@ func (h *DeployHandler) convertResult(result *compose.DeployResult) *DeployCompo
 
 // sendProgress sends a deploy progress event
 func (h *DeployHandler) sendProgress(deploymentID, stage, message string) {
-	progress := map[string]interface{}{
-		"deployment_id": deploymentID,
-		"stage":         stage,
-		"message":       message,
+	event := progress.New(progress.OperationDeploy, progress.Stage(stage), message)
+	event.DeploymentID = deploymentID
+	if event.Stage == progress.StageFailed {
+		event.Error = message
 	}
+	h.sendProgressEvent(event)
+}
 
-	if err := h.sendEvent("deploy_progress", progress); err != nil {
+// sendProgressEvent sends a deploy progress event in the shared schema
+func (h *DeployHandler) sendProgressEvent(event progress.Event) {
+	if err := h.sendEvent("deploy_progress", event); err != nil {
 		h.log.WithField("error", err.Error()).Warn("Failed to send deploy progress")
 	}
 }
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/diagnostics"
+	"github.com/sirupsen/logrus"
+)
+
+// DiagnosticsRequest asks for a diagnostics bundle
+type DiagnosticsRequest struct {
+	DiagnosticsID string `json:"diagnostics_id"`
+	Destination   string `json:"destination,omitempty"` // "websocket" (default) or "local"
+}
+
+// DiagnosticsResult is sent as the diagnostics_complete event
+type DiagnosticsResult struct {
+	DiagnosticsID string            `json:"diagnostics_id"`
+	Destination   string            `json:"destination"`
+	Success       bool              `json:"success"`
+	Path          string            `json:"path,omitempty"`
+	Size          int64             `json:"size"`
+	SHA256        string            `json:"sha256,omitempty"`
+	Chunks        int               `json:"chunks,omitempty"`
+	Files         []string          `json:"files,omitempty"`
+	Missing       map[string]string `json:"missing,omitempty"` // sections left out -> why
+	Error         string            `json:"error,omitempty"`
+}
+
+// DiagnosticsHandler collects diagnostics bundles and delivers them over
+// the WebSocket or to a local directory
+type DiagnosticsHandler struct {
+	collector *diagnostics.Collector
+	log       *logrus.Logger
+	sendEvent func(string, interface{}) error
+	outputDir string
+}
+
+// NewDiagnosticsHandler creates a new diagnostics handler
+func NewDiagnosticsHandler(collector *diagnostics.Collector, log *logrus.Logger, sendEvent func(string, interface{}) error, outputDir string) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		collector: collector,
+		log:       log,
+		sendEvent: sendEvent,
+		outputDir: outputDir,
+	}
+}
+
+// Collect builds a bundle and sends it as diagnostics_chunk events or writes
+// it to the output directory, then reports the outcome as a
+// diagnostics_complete event
+func (h *DiagnosticsHandler) Collect(ctx context.Context, req DiagnosticsRequest) *DiagnosticsResult {
+	if req.Destination == "" {
+		req.Destination = "websocket"
+	}
+	result := &DiagnosticsResult{
+		DiagnosticsID: req.DiagnosticsID,
+		Destination:   req.Destination,
+	}
+
+	if err := h.collect(ctx, req, result); err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).Error("Diagnostics collection failed")
+	} else {
+		result.Success = true
+		h.log.WithFields(logrus.Fields{
+			"destination": req.Destination,
+			"size":        result.Size,
+			"missing":     len(result.Missing),
+		}).Info("Diagnostics bundle collected")
+	}
+
+	if sendErr := h.sendEvent("diagnostics_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send diagnostics result")
+	}
+	return result
+}
+
+func (h *DiagnosticsHandler) collect(ctx context.Context, req DiagnosticsRequest, result *DiagnosticsResult) error {
+	if req.DiagnosticsID == "" {
+		return fmt.Errorf("diagnostics_id is required")
+	}
+
+	var out io.Writer
+	var chunks *chunkEventWriter
+	var path, partial string
+	var file *os.File
+
+	switch req.Destination {
+	case "websocket":
+		chunks = &chunkEventWriter{
+			eventType: "diagnostics_chunk",
+			idField:   "diagnostics_id",
+			id:        req.DiagnosticsID,
+			sendEvent: h.sendEvent,
+		}
+		out = chunks
+
+	case "local":
+		if err := os.MkdirAll(h.outputDir, 0o700); err != nil {
+			return fmt.Errorf("failed to create diagnostics directory: %w", err)
+		}
+		path = filepath.Join(h.outputDir, diagnostics.FileName(time.Now()))
+		partial = path + ".partial"
+		var err error
+		file, err = os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- name built from a timestamp
+		if err != nil {
+			return fmt.Errorf("failed to create diagnostics file: %w", err)
+		}
+		out = file
+
+	default:
+		return fmt.Errorf("unknown diagnostics destination: %s", req.Destination)
+	}
+
+	hasher := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(out, hasher)}
+	manifest, writeErr := h.collector.Write(ctx, counter)
+	if writeErr == nil && chunks != nil {
+		writeErr = chunks.Flush()
+	}
+
+	if file != nil {
+		closeErr := file.Close()
+		if writeErr == nil && closeErr != nil {
+			writeErr = fmt.Errorf("failed to write diagnostics file: %w", closeErr)
+		}
+		if writeErr == nil {
+			if err := os.Rename(partial, path); err != nil {
+				writeErr = fmt.Errorf("failed to finalize diagnostics file: %w", err)
+			}
+		}
+		if writeErr != nil {
+			_ = os.Remove(partial)
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	result.Path = path
+	result.Size = counter.n
+	result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	result.Files = manifest.Files
+	result.Missing = manifest.Errors
+	if chunks != nil {
+		result.Chunks = chunks.seq
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/diagnostics"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestDiagnosticsHandler(t *testing.T, events map[string][]interface{}) *DiagnosticsHandler {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	collector := diagnostics.NewCollector(&config.Config{DockMonURL: "http://127.0.0.1:1"}, nil)
+	return NewDiagnosticsHandler(collector, log, func(eventType string, payload interface{}) error {
+		events[eventType] = append(events[eventType], payload)
+		return nil
+	}, t.TempDir())
+}
+
+func TestDiagnosticsHandler_Local(t *testing.T) {
+	events := make(map[string][]interface{})
+	h := newTestDiagnosticsHandler(t, events)
+
+	result := h.Collect(context.Background(), DiagnosticsRequest{DiagnosticsID: "d1", Destination: "local"})
+	if !result.Success {
+		t.Fatalf("collect failed: %s", result.Error)
+	}
+	info, err := os.Stat(result.Path)
+	if err != nil {
+		t.Fatalf("bundle not written: %v", err)
+	}
+	if info.Size() != result.Size || filepath.Dir(result.Path) != h.outputDir {
+		t.Errorf("path=%s size=%d, file size %d", result.Path, result.Size, info.Size())
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("bundle mode = %v, want 0600", info.Mode().Perm())
+	}
+	if len(events["diagnostics_chunk"]) != 0 || len(events["diagnostics_complete"]) != 1 {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestDiagnosticsHandler_WebSocket(t *testing.T) {
+	events := make(map[string][]interface{})
+	h := newTestDiagnosticsHandler(t, events)
+
+	result := h.Collect(context.Background(), DiagnosticsRequest{DiagnosticsID: "d2"})
+	if !result.Success || result.Destination != "websocket" {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Chunks == 0 || len(events["diagnostics_chunk"]) != result.Chunks {
+		t.Errorf("chunks = %d, chunk events = %d", result.Chunks, len(events["diagnostics_chunk"]))
+	}
+	if _, ok := result.Missing["docker/version.json"]; !ok {
+		t.Errorf("missing = %v, want docker sections listed", result.Missing)
+	}
+}
+
+func TestDiagnosticsHandler_RequiresID(t *testing.T) {
+	events := make(map[string][]interface{})
+	h := newTestDiagnosticsHandler(t, events)
+
+	if result := h.Collect(context.Background(), DiagnosticsRequest{}); result.Success {
+		t.Error("collect without diagnostics_id should fail")
+	}
+}
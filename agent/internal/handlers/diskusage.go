@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// diskUsageTTL is how long a container's measured usage is served from
+	// cache. Sizing the writable layer walks its files, so repeated views of
+	// the same container shouldn't repeat the walk.
+	diskUsageTTL = 5 * time.Minute
+	// diskUsageRefreshInterval is the minimum age of a measurement before a
+	// refresh walks the layer again; younger ones are served from cache.
+	diskUsageRefreshInterval = 10 * time.Second
+	// volumeScanInterval is the minimum time between volume scans. Docker
+	// sizes every volume on the host in one call, so a refresh never runs
+	// it more often than this.
+	volumeScanInterval = time.Minute
+)
+
+// ContainerDiskUsageRequest asks for a container's disk usage
+type ContainerDiskUsageRequest struct {
+	ContainerID    string `json:"container_id"`
+	IncludeVolumes bool   `json:"include_volumes,omitempty"`
+	Refresh        bool   `json:"refresh,omitempty"` // Bypass the cache; measurements and volume scans stay rate limited
+}
+
+// MountUsage is the disk usage of one of a container's mounts
+type MountUsage struct {
+	Type        string `json:"type"` // volume or bind
+	Name        string `json:"name,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination"`
+	SizeBytes   int64  `json:"size_bytes"` // -1 if not measured
+}
+
+// ContainerDiskUsage is a container's disk usage. Bind mounts are listed
+// but never measured: the host path may not be visible to the agent.
+type ContainerDiskUsage struct {
+	ContainerID   string       `json:"container_id"`
+	ContainerName string       `json:"container_name"`
+	WritableBytes int64        `json:"writable_bytes"` // Writable layer (SizeRw)
+	RootFSBytes   int64        `json:"rootfs_bytes"`   // Writable layer plus image (SizeRootFs)
+	VolumeBytes   int64        `json:"volume_bytes,omitempty"`
+	Mounts        []MountUsage `json:"mounts,omitempty"`
+	MeasuredAt    time.Time    `json:"measured_at"`
+	Cached        bool         `json:"cached,omitempty"`
+}
+
+type diskUsageKey struct {
+	containerID    string
+	includeVolumes bool
+}
+
+// DiskUsageHandler measures per-container disk usage, so DockMon can show
+// which container is filling the disk
+type DiskUsageHandler struct {
+	docker client.APIClient
+	log    *logrus.Logger
+	now    func() time.Time
+
+	// measureMu serializes measurements, so a burst of requests doesn't
+	// start several layer walks at once
+	measureMu sync.Mutex
+
+	mu          sync.Mutex
+	cache       map[diskUsageKey]*ContainerDiskUsage
+	volumeSizes map[string]int64 // Volume name -> bytes, -1 if unavailable
+	volumesAt   time.Time
+}
+
+// NewDiskUsageHandler creates a new disk usage handler
+func NewDiskUsageHandler(docker client.APIClient, log *logrus.Logger) *DiskUsageHandler {
+	return &DiskUsageHandler{
+		docker: docker,
+		log:    log,
+		now:    time.Now,
+		cache:  make(map[diskUsageKey]*ContainerDiskUsage),
+	}
+}
+
+// ContainerUsage reports the container's writable layer size and, when
+// asked, the sizes of its named volumes
+func (h *DiskUsageHandler) ContainerUsage(ctx context.Context, req ContainerDiskUsageRequest) (*ContainerDiskUsage, error) {
+	if req.ContainerID == "" {
+		return nil, fmt.Errorf("container_id is required")
+	}
+	key := diskUsageKey{containerID: req.ContainerID, includeVolumes: req.IncludeVolumes}
+	maxAge := diskUsageTTL
+	if req.Refresh {
+		maxAge = diskUsageRefreshInterval
+	}
+	if usage := h.cached(key, maxAge); usage != nil {
+		return usage, nil
+	}
+
+	h.measureMu.Lock()
+	defer h.measureMu.Unlock()
+	// Another request may have measured it while this one waited
+	if usage := h.cached(key, maxAge); usage != nil {
+		return usage, nil
+	}
+
+	inspect, _, err := h.docker.ContainerInspectWithRaw(ctx, req.ContainerID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	usage := &ContainerDiskUsage{
+		ContainerID:   inspect.ID,
+		ContainerName: strings.TrimPrefix(inspect.Name, "/"),
+		MeasuredAt:    h.now().UTC(),
+	}
+	if inspect.ContainerJSONBase != nil {
+		if inspect.SizeRw != nil {
+			usage.WritableBytes = *inspect.SizeRw
+		}
+		if inspect.SizeRootFs != nil {
+			usage.RootFSBytes = *inspect.SizeRootFs
+		}
+	}
+
+	var volumeSizes map[string]int64
+	if req.IncludeVolumes {
+		if volumeSizes, err = h.scanVolumes(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range inspect.Mounts {
+		if m.Type != mount.TypeVolume && m.Type != mount.TypeBind {
+			continue
+		}
+		mountUsage := MountUsage{
+			Type:        string(m.Type),
+			Name:        m.Name,
+			Source:      m.Source,
+			Destination: m.Destination,
+			SizeBytes:   -1,
+		}
+		if size, ok := volumeSizes[m.Name]; ok && m.Type == mount.TypeVolume {
+			mountUsage.SizeBytes = size
+			if size > 0 {
+				usage.VolumeBytes += size
+			}
+		}
+		usage.Mounts = append(usage.Mounts, mountUsage)
+	}
+
+	h.mu.Lock()
+	h.cache[key] = usage
+	h.pruneLocked()
+	h.mu.Unlock()
+
+	copied := *usage
+	return &copied, nil
+}
+
+// cached returns a copy of a cached measurement younger than maxAge, or nil
+func (h *DiskUsageHandler) cached(key diskUsageKey, maxAge time.Duration) *ContainerDiskUsage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	usage, ok := h.cache[key]
+	if !ok || h.now().Sub(usage.MeasuredAt) >= maxAge {
+		return nil
+	}
+	copied := *usage
+	copied.Cached = true
+	return &copied
+}
+
+// pruneLocked drops expired measurements, so removed containers don't
+// pile up. Caller must hold h.mu.
+func (h *DiskUsageHandler) pruneLocked() {
+	now := h.now()
+	for key, usage := range h.cache {
+		if now.Sub(usage.MeasuredAt) >= diskUsageTTL {
+			delete(h.cache, key)
+		}
+	}
+}
+
+// scanVolumes returns the size of every volume on the host, reusing the
+// last scan if it's younger than volumeScanInterval. Caller must hold
+// h.measureMu.
+func (h *DiskUsageHandler) scanVolumes(ctx context.Context) (map[string]int64, error) {
+	h.mu.Lock()
+	if h.volumeSizes != nil && h.now().Sub(h.volumesAt) < volumeScanInterval {
+		sizes := h.volumeSizes
+		h.mu.Unlock()
+		return sizes, nil
+	}
+	h.mu.Unlock()
+
+	du, err := h.docker.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.VolumeObject},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query volume disk usage: %w", err)
+	}
+	sizes := make(map[string]int64, len(du.Volumes))
+	for _, vol := range du.Volumes {
+		if vol == nil {
+			continue
+		}
+		size := int64(-1)
+		if vol.UsageData != nil {
+			size = vol.UsageData.Size
+		}
+		sizes[vol.Name] = size
+	}
+	h.log.WithField("volumes", len(sizes)).Debug("Scanned volume disk usage")
+
+	h.mu.Lock()
+	h.volumeSizes, h.volumesAt = sizes, h.now()
+	h.mu.Unlock()
+	return sizes, nil
+}
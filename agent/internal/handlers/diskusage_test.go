@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func TestDiskUsageHandler_ContainerUsage(t *testing.T) {
+	var inspects, scans atomic.Int32
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/web1/json"):
+			inspects.Add(1)
+			if r.URL.Query().Get("size") != "1" {
+				t.Errorf("inspect without size: %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"Id":"web1","Name":"/web","SizeRw":4096,"SizeRootFs":104857600,"Mounts":[
+				{"Type":"volume","Name":"data","Source":"/var/lib/docker/volumes/data/_data","Destination":"/data"},
+				{"Type":"bind","Source":"/srv/conf","Destination":"/etc/app"},
+				{"Type":"tmpfs","Destination":"/tmp"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/system/df"):
+			scans.Add(1)
+			w.Write([]byte(`{"Volumes":[{"Name":"data","UsageData":{"Size":2048,"RefCount":1}},{"Name":"other","UsageData":{"Size":-1,"RefCount":0}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(daemon.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	h := NewDiskUsageHandler(cli, logrus.New())
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	ctx := context.Background()
+	req := ContainerDiskUsageRequest{ContainerID: "web1", IncludeVolumes: true}
+
+	usage, err := h.ContainerUsage(ctx, req)
+	if err != nil {
+		t.Fatalf("ContainerUsage: %v", err)
+	}
+	if usage.ContainerName != "web" || usage.WritableBytes != 4096 || usage.RootFSBytes != 104857600 || usage.VolumeBytes != 2048 || usage.Cached {
+		t.Errorf("usage = %+v", usage)
+	}
+	if len(usage.Mounts) != 2 || usage.Mounts[0].SizeBytes != 2048 || usage.Mounts[1].Type != "bind" || usage.Mounts[1].SizeBytes != -1 {
+		t.Errorf("mounts = %+v", usage.Mounts)
+	}
+
+	// Served from cache until the TTL passes
+	if usage, _ := h.ContainerUsage(ctx, req); !usage.Cached || inspects.Load() != 1 {
+		t.Errorf("second request: cached=%v inspects=%d", usage.Cached, inspects.Load())
+	}
+
+	// A refresh right after a measurement is served from cache too
+	req.Refresh = true
+	if usage, _ := h.ContainerUsage(ctx, req); !usage.Cached || inspects.Load() != 1 {
+		t.Errorf("early refresh: cached=%v inspects=%d", usage.Cached, inspects.Load())
+	}
+
+	// A later refresh measures again but doesn't rescan volumes within the interval
+	now = now.Add(diskUsageRefreshInterval)
+	if usage, _ := h.ContainerUsage(ctx, req); usage.Cached || inspects.Load() != 2 || scans.Load() != 1 {
+		t.Errorf("refresh: cached=%v inspects=%d scans=%d", usage.Cached, inspects.Load(), scans.Load())
+	}
+	now = now.Add(volumeScanInterval)
+	if _, err := h.ContainerUsage(ctx, req); err != nil || scans.Load() != 2 {
+		t.Errorf("refresh after the interval: err=%v scans=%d", err, scans.Load())
+	}
+
+	if _, err := h.ContainerUsage(ctx, ContainerDiskUsageRequest{}); err == nil {
+		t.Error("missing container_id accepted")
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultDockerAPIMaxBytes caps a passthrough response when the request
+	// doesn't set a limit
+	defaultDockerAPIMaxBytes = 1 << 20
+	// maxDockerAPIMaxBytes is the largest limit a request may ask for
+	maxDockerAPIMaxBytes = 8 << 20
+	// dockerAPITimeout bounds a single passthrough call
+	dockerAPITimeout = 30 * time.Second
+)
+
+// dockerAPIRoute is one allow-listed read-only Docker API endpoint
+type dockerAPIRoute struct {
+	pattern *regexp.Regexp
+	query   []string // query parameters passed through; others are rejected
+}
+
+// dockerAPIRoutes lists the endpoints the passthrough serves. Only GETs of
+// version, info, disk usage and single-object inspect endpoints are allowed:
+// nothing that streams, changes state or reads container contents.
+var dockerAPIRoutes = []dockerAPIRoute{
+	{pattern: regexp.MustCompile(`^/_ping$`)},
+	{pattern: regexp.MustCompile(`^/version$`)},
+	{pattern: regexp.MustCompile(`^/info$`)},
+	{pattern: regexp.MustCompile(`^/system/df$`), query: []string{"type"}},
+	{pattern: regexp.MustCompile(`^/containers/[\w.-]+/json$`), query: []string{"size"}},
+	{pattern: regexp.MustCompile(`^/images/[\w.:@/-]+/json$`), query: []string{"manifests"}},
+	{pattern: regexp.MustCompile(`^/images/[\w.:@/-]+/history$`)},
+	{pattern: regexp.MustCompile(`^/networks/[\w.-]+$`), query: []string{"verbose", "scope"}},
+	{pattern: regexp.MustCompile(`^/volumes/[\w.-]+$`)},
+	{pattern: regexp.MustCompile(`^/plugins/[\w.:@/-]+/json$`)},
+}
+
+// DockerAPIRequest is a raw Docker API call made through the agent
+type DockerAPIRequest struct {
+	Method   string            `json:"method,omitempty"` // Only GET; default GET
+	Path     string            `json:"path"`             // Unversioned API path, e.g. "/info"
+	Query    map[string]string `json:"query,omitempty"`
+	MaxBytes int64             `json:"max_bytes,omitempty"` // Default 1MB, at most 8MB
+}
+
+// DockerAPIResponse carries the Docker API's answer back to the backend
+type DockerAPIResponse struct {
+	StatusCode  int             `json:"status_code"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"` // JSON bodies verbatim, anything else as a JSON string
+}
+
+// DockerAPIHandler proxies an allow-listed subset of read-only Docker API
+// endpoints, so new read-only backend features don't need an agent release
+// for every endpoint
+type DockerAPIHandler struct {
+	dockerClient *docker.Client
+	log          *logrus.Logger
+}
+
+// NewDockerAPIHandler creates a new Docker API passthrough handler
+func NewDockerAPIHandler(dockerClient *docker.Client, log *logrus.Logger) *DockerAPIHandler {
+	return &DockerAPIHandler{
+		dockerClient: dockerClient,
+		log:          log,
+	}
+}
+
+// Do validates req against the allow-list and performs it. Docker API
+// errors (404 etc.) are returned as a response with their status code;
+// only rejected requests and transport failures return an error.
+func (h *DockerAPIHandler) Do(ctx context.Context, req DockerAPIRequest) (*DockerAPIResponse, error) {
+	apiPath, query, maxBytes, err := validateDockerAPIRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dockerAPITimeout)
+	defer cancel()
+
+	cli := h.dockerClient.RawClient()
+	cli.NegotiateAPIVersion(ctx)
+	httpReq, err := newDockerAPIRequest(ctx, cli, apiPath, query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cli.HTTPClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("docker API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readLimited(resp.Body, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"path":   apiPath,
+		"status": resp.StatusCode,
+		"bytes":  len(body),
+	}).Debug("Docker API passthrough")
+
+	return &DockerAPIResponse{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        encodeDockerAPIBody(body),
+	}, nil
+}
+
+// validateDockerAPIRequest checks the method, path and query against the
+// allow-list and returns the cleaned path, query and size limit
+func validateDockerAPIRequest(req DockerAPIRequest) (string, url.Values, int64, error) {
+	if req.Method != "" && !strings.EqualFold(req.Method, http.MethodGet) {
+		return "", nil, 0, fmt.Errorf("method %s not allowed: only GET is supported", req.Method)
+	}
+
+	// Reject anything that isn't already a clean absolute path so
+	// "/info/../containers/x/export" style tricks never reach the matcher
+	p := req.Path
+	if p == "" || !strings.HasPrefix(p, "/") || path.Clean(p) != p || strings.ContainsAny(p, "?#%\\") {
+		return "", nil, 0, fmt.Errorf("invalid path %q", req.Path)
+	}
+
+	var route *dockerAPIRoute
+	for i := range dockerAPIRoutes {
+		if dockerAPIRoutes[i].pattern.MatchString(p) {
+			route = &dockerAPIRoutes[i]
+			break
+		}
+	}
+	if route == nil {
+		return "", nil, 0, fmt.Errorf("path %q is not allowed", p)
+	}
+
+	query := url.Values{}
+	for key, value := range req.Query {
+		allowed := false
+		for _, q := range route.query {
+			if key == q {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", nil, 0, fmt.Errorf("query parameter %q not allowed for %s", key, p)
+		}
+		query.Set(key, value)
+	}
+
+	maxBytes := req.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultDockerAPIMaxBytes
+	}
+	if maxBytes > maxDockerAPIMaxBytes {
+		maxBytes = maxDockerAPIMaxBytes
+	}
+	return p, query, maxBytes, nil
+}
+
+// newDockerAPIRequest builds a GET for the daemon the client talks to,
+// mirroring how the Docker client addresses unix/npipe and TCP hosts
+func newDockerAPIRequest(ctx context.Context, cli *client.Client, apiPath string, query url.Values) (*http.Request, error) {
+	hostURL, err := client.ParseHostURL(cli.DaemonHost())
+	if err != nil {
+		return nil, err
+	}
+
+	u := url.URL{
+		Scheme:   "http",
+		Host:     hostURL.Host,
+		Path:     path.Join(hostURL.Path, "/v"+strings.TrimPrefix(cli.ClientVersion(), "v"), apiPath),
+		RawQuery: query.Encode(),
+	}
+	switch hostURL.Scheme {
+	case "unix", "npipe":
+		// The transport dials the socket; the host only has to be valid
+		u.Host = client.DummyHost
+	default:
+		if t, ok := cli.HTTPClient().Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+			u.Scheme = "https"
+		}
+	}
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// readLimited reads the whole body, failing rather than truncating when it
+// exceeds maxBytes (a truncated JSON document is useless to the caller)
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker API response: %w", err)
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("docker API response exceeds %d bytes", maxBytes)
+	}
+	return body, nil
+}
+
+// encodeDockerAPIBody passes JSON through untouched and wraps anything else
+// (e.g. the plain-text "OK" from /_ping) as a JSON string
+func encodeDockerAPIBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestValidateDockerAPIRequest(t *testing.T) {
+	allowed := []DockerAPIRequest{
+		{Path: "/version"},
+		{Path: "/info", Method: "get"},
+		{Path: "/system/df", Query: map[string]string{"type": "volume"}},
+		{Path: "/containers/3f4e1a2b9c8d/json", Query: map[string]string{"size": "1"}},
+		{Path: "/images/ghcr.io/team/app:1.2/json"},
+		{Path: "/images/nginx@sha256:0123abcd/json"},
+		{Path: "/networks/bridge"},
+		{Path: "/volumes/pg_data"},
+	}
+	for _, req := range allowed {
+		if _, _, _, err := validateDockerAPIRequest(req); err != nil {
+			t.Errorf("%+v: unexpected error: %v", req, err)
+		}
+	}
+
+	rejected := []DockerAPIRequest{
+		{Path: "/info", Method: "POST"},
+		{Path: ""},
+		{Path: "info"},
+		{Path: "/containers/json"},
+		{Path: "/containers/abc/export"},
+		{Path: "/containers/abc/logs"},
+		{Path: "/info/../containers/abc/export"},
+		{Path: "/containers/abc/json/"},
+		{Path: "/containers/abc%2F..%2Fx/json"},
+		{Path: "/images/../containers/x/json"},
+		{Path: "/info?x=1"},
+		{Path: "/info", Query: map[string]string{"all": "1"}},
+		{Path: "/containers/abc/json", Query: map[string]string{"type": "x"}},
+		{Path: "/events"},
+		{Path: "/exec/abc/json"},
+	}
+	for _, req := range rejected {
+		if _, _, _, err := validateDockerAPIRequest(req); err == nil {
+			t.Errorf("%+v: expected rejection", req)
+		}
+	}
+}
+
+func TestValidateDockerAPIRequest_MaxBytes(t *testing.T) {
+	tests := map[int64]int64{
+		0:       defaultDockerAPIMaxBytes,
+		-5:      defaultDockerAPIMaxBytes,
+		4096:    4096,
+		1 << 30: maxDockerAPIMaxBytes,
+	}
+	for in, want := range tests {
+		_, _, got, err := validateDockerAPIRequest(DockerAPIRequest{Path: "/info", MaxBytes: in})
+		if err != nil || got != want {
+			t.Errorf("MaxBytes %d: got %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	if body, err := readLimited(strings.NewReader("12345"), 5); err != nil || string(body) != "12345" {
+		t.Errorf("at limit: %q, %v", body, err)
+	}
+	if _, err := readLimited(strings.NewReader("123456"), 5); err == nil {
+		t.Error("expected error over limit")
+	}
+}
+
+func TestEncodeDockerAPIBody(t *testing.T) {
+	if got := string(encodeDockerAPIBody([]byte(`{"ID":"x"}`))); got != `{"ID":"x"}` {
+		t.Errorf("json body = %s", got)
+	}
+	if got := string(encodeDockerAPIBody([]byte("OK"))); got != `"OK"` {
+		t.Errorf("text body = %s", got)
+	}
+	if got := encodeDockerAPIBody(nil); got != nil {
+		t.Errorf("empty body = %s", got)
+	}
+}
+
+func TestNewDockerAPIRequest(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"unix:///var/run/docker.sock", "http://" + client.DummyHost + "/v1.45/info?type=x"},
+		{"tcp://10.0.0.5:2375", "http://10.0.0.5:2375/v1.45/info?type=x"},
+	}
+	for _, tt := range tests {
+		cli, err := client.NewClientWithOpts(client.WithHost(tt.host), client.WithVersion("1.45"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := newDockerAPIRequest(context.Background(), cli, "/info", map[string][]string{"type": {"x"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.URL.String() != tt.want || req.Method != "GET" {
+			t.Errorf("%s: %s %s, want GET %s", tt.host, req.Method, req.URL, tt.want)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/maintenance"
 	"github.com/sirupsen/logrus"
 )
 
@@ -70,6 +71,10 @@ type HealthCheckHandler struct {
 	// avoid per-check allocation. One verifies TLS, one skips it.
 	transportVerify *http.Transport
 	transportSkip   *http.Transport
+
+	// maintenance pauses checks while the host is in maintenance, so a
+	// planned reboot doesn't report every container unhealthy
+	maintenance *maintenance.Mode
 }
 
 // newHealthCheckTransport builds a reusable transport with the given TLS policy.
@@ -92,6 +97,11 @@ func NewHealthCheckHandler(log *logrus.Logger, sendEvent func(string, interface{
 	}
 }
 
+// SetMaintenance pauses checks while m has an active window. Call before Start.
+func (h *HealthCheckHandler) SetMaintenance(m *maintenance.Mode) {
+	h.maintenance = m
+}
+
 // Start starts the health check loop. It may be called again after Stop()
 // (e.g. when the agent's WebSocket reconnects); each call runs an independent
 // loop, so health checks resume across reconnects.
@@ -265,6 +275,12 @@ func (h *HealthCheckHandler) healthCheckLoop(ctx context.Context) {
 
 // runDueChecks runs health checks that are due
 func (h *HealthCheckHandler) runDueChecks(ctx context.Context, lastCheck map[string]time.Time) {
+	if h.maintenance != nil {
+		if _, active := h.maintenance.Active(); active {
+			return
+		}
+	}
+
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/cpustat"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/sirupsen/logrus"
 )
 
@@ -23,10 +25,11 @@ type HostStatsHandler struct {
 	sysPath  string // /sys or /host/sys
 
 	// Previous values for calculating deltas
-	prevCPU  cpuStats
-	prevNet  map[string]netStats
-	prevTime time.Time
-	mu       sync.Mutex
+	prevCPU   cpuStats
+	prevCores []cpustat.Times
+	prevNet   map[string]netStats
+	prevTime  time.Time
+	mu        sync.Mutex
 }
 
 type cpuStats struct {
@@ -101,6 +104,7 @@ func (h *HostStatsHandler) collect() {
 
 	// Calculate CPU percentage
 	cpuPercent := h.calculateCPUPercent()
+	perCore := h.calculatePerCorePercent()
 
 	// Calculate memory percentage
 	memPercent := h.calculateMemPercent()
@@ -111,13 +115,17 @@ func (h *HostStatsHandler) collect() {
 	h.prevTime = now
 
 	// Send to backend (format expected by _handle_system_stats)
+	stats := map[string]interface{}{
+		"cpu_percent":       cpuPercent,
+		"mem_percent":       memPercent,
+		"net_bytes_per_sec": netBytesPerSec,
+	}
+	if perCore != nil {
+		stats["cpu_per_core"] = perCore
+	}
 	msg := map[string]interface{}{
-		"type": "stats",
-		"stats": map[string]interface{}{
-			"cpu_percent":       cpuPercent,
-			"mem_percent":       memPercent,
-			"net_bytes_per_sec": netBytesPerSec,
-		},
+		"type":  "stats",
+		"stats": stats,
 	}
 
 	if err := h.sendJSON(msg); err != nil {
@@ -191,6 +199,23 @@ func (h *HostStatsHandler) calculateCPUPercent() float64 {
 	return 0
 }
 
+// calculatePerCorePercent returns each core's usage since the last
+// collection, rounded to 1 decimal. nil on the first collection or when
+// the core count changed.
+func (h *HostStatsHandler) calculatePerCorePercent() []float64 {
+	cores, err := cpustat.ReadPerCore(filepath.Join(h.procPath, "stat"))
+	if err != nil {
+		h.log.Debugf("Failed to read per-core CPU stats: %v", err)
+		return nil
+	}
+	perCore := cpustat.PerCore(h.prevCores, cores)
+	h.prevCores = cores
+	for i, pct := range perCore {
+		perCore[i] = sharedDocker.RoundToDecimal(pct, 1)
+	}
+	return perCore
+}
+
 // calculateMemPercent reads /proc/meminfo (or /host/proc/meminfo) and calculates memory usage percentage
 func (h *HostStatsHandler) calculateMemPercent() float64 {
 	meminfoPath := filepath.Join(h.procPath, "meminfo")
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/darthnorse/dockmon-shared/updatecheck"
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentImageChecks bounds parallel registry lookups so a host with
+// many images doesn't trip registry rate limits in one burst
+const maxConcurrentImageChecks = 4
+
+// CheckUpdatesRequest asks the agent to compare local images with their
+// registries. With no images listed, every image used by a container on the
+// host is checked anonymously.
+type CheckUpdatesRequest struct {
+	Images []ImageCheckRequest `json:"images,omitempty"`
+}
+
+// ImageCheckRequest is one image reference to look up
+type ImageCheckRequest struct {
+	Image        string        `json:"image"`
+	RegistryAuth *RegistryAuth `json:"registry_auth,omitempty"`
+}
+
+// CheckContainerUpdatesRequest asks which containers have an update allowed
+// by their update policy (see updatecheck.Policy)
+type CheckContainerUpdatesRequest struct {
+	// Policies overrides the com.dockmon.update.policy label, keyed by
+	// container ID, short ID or name
+	Policies map[string]updatecheck.Policy `json:"policies,omitempty"`
+	// RegistryAuth holds credentials keyed by registry host ("docker.io", "ghcr.io")
+	RegistryAuth map[string]RegistryAuth `json:"registry_auth,omitempty"`
+}
+
+// ImageCheckResult compares a local image with what its registry serves
+type ImageCheckResult struct {
+	Image           string            `json:"image"`
+	CurrentTag      string            `json:"current_tag,omitempty"`
+	LocalDigest     string            `json:"local_digest,omitempty"`
+	RemoteDigest    string            `json:"remote_digest,omitempty"`
+	UpdateAvailable bool              `json:"update_available"`
+	LatestVersion   string            `json:"latest_version,omitempty"` // newest tag in the same release line
+	Created         string            `json:"created,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Error           string            `json:"error,omitempty"`
+
+	// UpdateLabels holds the opt-out label policy of containers using this
+	// image that restrict updates, keyed by container name
+	UpdateLabels map[string]update.LabelPolicy `json:"update_labels,omitempty"`
+}
+
+// ImageCheckHandler looks up image tags and digests in registries without
+// pulling
+type ImageCheckHandler struct {
+	dockerClient *docker.Client
+	log          *logrus.Logger
+}
+
+// NewImageCheckHandler creates a new image check handler
+func NewImageCheckHandler(dockerClient *docker.Client, log *logrus.Logger) *ImageCheckHandler {
+	return &ImageCheckHandler{
+		dockerClient: dockerClient,
+		log:          log,
+	}
+}
+
+// CheckUpdates looks up each image in its registry. Per-image failures are
+// reported in the result's Error field rather than failing the whole check.
+func (h *ImageCheckHandler) CheckUpdates(ctx context.Context, req CheckUpdatesRequest) ([]ImageCheckResult, error) {
+	refs, labelPolicies, err := h.containerImages(ctx)
+	images := req.Images
+	if len(images) == 0 {
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			images = append(images, ImageCheckRequest{Image: ref})
+		}
+	} else if err != nil {
+		h.log.WithError(err).Debug("Failed to list containers for update label policies")
+	}
+
+	platform := h.hostPlatform(ctx)
+	results := make([]ImageCheckResult, len(images))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentImageChecks)
+	for i, img := range images {
+		g.Go(func() error {
+			results[i] = h.checkImage(gctx, img, platform)
+			results[i].UpdateLabels = labelPolicies[img.Image]
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	h.log.WithField("images", len(results)).Debug("Image update check completed")
+	return results, nil
+}
+
+// CheckContainers evaluates every container's update policy and reports
+// which ones have an eligible update, rather than any digest difference.
+func (h *ImageCheckHandler) CheckContainers(ctx context.Context, req CheckContainerUpdatesRequest) ([]updatecheck.Result, error) {
+	opts := updatecheck.Options{
+		Policies: req.Policies,
+		Platform: h.hostPlatform(ctx),
+	}
+	if len(req.RegistryAuth) > 0 {
+		opts.Auth = make(map[string]registry.Credentials, len(req.RegistryAuth))
+		for host, auth := range req.RegistryAuth {
+			opts.Auth[host] = registry.Credentials{Username: auth.Username, Password: auth.Password}
+		}
+	}
+	return updatecheck.NewChecker(h.dockerClient.RawClient(), h.log).Check(ctx, opts)
+}
+
+// hostPlatform returns the Docker host's platform for picking manifests
+func (h *ImageCheckHandler) hostPlatform(ctx context.Context) registry.Platform {
+	info, err := h.dockerClient.RawClient().Info(ctx)
+	if err != nil {
+		h.log.WithError(err).Warn("Failed to query Docker info, assuming linux/amd64 for image checks")
+		return registry.Platform{OS: "linux", Architecture: "amd64"}
+	}
+	return registry.Platform{OS: info.OSType, Architecture: registry.NormalizeArch(info.Architecture)}
+}
+
+func (h *ImageCheckHandler) checkImage(ctx context.Context, req ImageCheckRequest, platform registry.Platform) ImageCheckResult {
+	result := ImageCheckResult{
+		Image:      req.Image,
+		CurrentTag: registry.Tag(req.Image),
+	}
+
+	if local, err := h.dockerClient.RawClient().ImageInspect(ctx, req.Image); err == nil {
+		result.LocalDigest = registry.LocalDigest(local.RepoDigests, req.Image)
+	}
+
+	var creds *registry.Credentials
+	if req.RegistryAuth != nil {
+		creds = &registry.Credentials{Username: req.RegistryAuth.Username, Password: req.RegistryAuth.Password}
+	}
+	client := registry.NewClient(creds)
+
+	remote, err := client.Inspect(ctx, req.Image, platform)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.RemoteDigest = remote.Digest
+	result.Created = remote.Created
+	result.Labels = remote.Labels
+	// Images built locally have no repo digest to compare against
+	result.UpdateAvailable = result.LocalDigest != "" && result.LocalDigest != remote.Digest
+
+	// Only version tags have a "newer" tag; skip the listing for latest etc.
+	if registry.IsVersionTag(result.CurrentTag) {
+		tags, err := client.ListTags(ctx, req.Image)
+		if err != nil {
+			h.log.WithError(err).WithField("image", req.Image).Debug("Failed to list image tags")
+		} else {
+			result.LatestVersion = registry.LatestVersion(result.CurrentTag, tags)
+		}
+	}
+	return result
+}
+
+// containerImages returns the distinct image references used by containers,
+// skipping containers created from a bare image ID, and the opt-out label
+// policies of those containers keyed by image and container name
+func (h *ImageCheckHandler) containerImages(ctx context.Context) ([]string, map[string]map[string]update.LabelPolicy, error) {
+	containers, err := h.dockerClient.RawClient().ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool)
+	var refs []string
+	policies := make(map[string]map[string]update.LabelPolicy)
+	for _, c := range containers {
+		if c.Image == "" || strings.HasPrefix(c.Image, "sha256:") {
+			continue
+		}
+		if policy, err := update.ParseLabelPolicy(c.Labels); err == nil && !policy.IsZero() && len(c.Names) > 0 {
+			if policies[c.Image] == nil {
+				policies[c.Image] = make(map[string]update.LabelPolicy)
+			}
+			policies[c.Image][strings.TrimPrefix(c.Names[0], "/")] = policy
+		}
+		if seen[c.Image] {
+			continue
+		}
+		seen[c.Image] = true
+		refs = append(refs, c.Image)
+	}
+	sort.Strings(refs)
+	return refs, policies, nil
+}
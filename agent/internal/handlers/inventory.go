@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// inventoryDebounce batches bursts of container events (compose up,
+	// restarts) into a single delta
+	inventoryDebounce = 500 * time.Millisecond
+	// inventoryReconcileInterval re-lists periodically to catch anything the
+	// event stream missed
+	inventoryReconcileInterval = 60 * time.Second
+)
+
+// InventorySnapshot is the full container list returned when the backend
+// subscribes. Deltas continue from Revision.
+type InventorySnapshot struct {
+	Revision   uint64                       `json:"revision"`
+	Containers []docker.ContainerWithDigest `json:"containers"`
+}
+
+// InventoryDelta carries the changes between two inventory revisions. The
+// backend applies it only if BaseRevision matches the revision it holds,
+// and resubscribes otherwise.
+type InventoryDelta struct {
+	Revision     uint64                       `json:"revision"`
+	BaseRevision uint64                       `json:"base_revision"`
+	Added        []docker.ContainerWithDigest `json:"added,omitempty"`
+	Updated      []docker.ContainerWithDigest `json:"updated,omitempty"`
+	Removed      []string                     `json:"removed,omitempty"`
+}
+
+// empty reports whether the delta carries no changes.
+func (d *InventoryDelta) empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// InventoryHandler maintains the container inventory for a subscribed
+// backend and pushes incremental deltas instead of full lists. Deltas are
+// driven by the Docker event stream (Notify) plus periodic reconciliation.
+// The subscription is connection-scoped: Stop clears it, and the backend
+// resubscribes after a reconnect.
+type InventoryHandler struct {
+	dockerClient *docker.Client
+	log          *logrus.Logger
+	sendEvent    func(msgType string, payload interface{}) error
+
+	// syncMu serializes list+diff so revisions are applied in order
+	syncMu       sync.Mutex
+	subscribed   bool
+	revision     uint64
+	fingerprints map[string]string // container ID → fingerprint
+
+	trigger chan struct{}
+	mu      sync.Mutex // guards cancel
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewInventoryHandler creates a new inventory handler
+func NewInventoryHandler(dockerClient *docker.Client, log *logrus.Logger, sendEvent func(string, interface{}) error) *InventoryHandler {
+	return &InventoryHandler{
+		dockerClient: dockerClient,
+		log:          log,
+		sendEvent:    sendEvent,
+		fingerprints: make(map[string]string),
+		trigger:      make(chan struct{}, 1),
+	}
+}
+
+// Subscribe lists all containers, resets the inventory to that list and
+// enables delta pushes. Calling it again (e.g. after the backend detected a
+// revision gap) starts over from a fresh snapshot.
+func (h *InventoryHandler) Subscribe(ctx context.Context) (*InventorySnapshot, error) {
+	h.syncMu.Lock()
+	defer h.syncMu.Unlock()
+
+	containers, err := h.dockerClient.ListContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	h.fingerprints = make(map[string]string, len(containers))
+	for _, c := range containers {
+		h.fingerprints[c.ID] = inventoryFingerprint(c)
+	}
+	h.revision++
+	h.subscribed = true
+
+	h.log.WithFields(logrus.Fields{
+		"revision":   h.revision,
+		"containers": len(containers),
+	}).Info("Container inventory subscribed")
+
+	return &InventorySnapshot{Revision: h.revision, Containers: containers}, nil
+}
+
+// Notify schedules a sync after a container event. Non-blocking; events
+// arriving while a sync is pending are coalesced.
+func (h *InventoryHandler) Notify() {
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the sync loop until ctx is cancelled or Stop is called.
+func (h *InventoryHandler) Start(ctx context.Context) {
+	loopCtx, cancel := context.WithCancel(ctx)
+	h.mu.Lock()
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.cancel = cancel
+	h.mu.Unlock()
+
+	h.wg.Add(1)
+	go h.loop(loopCtx)
+}
+
+// Stop stops the sync loop and drops the subscription.
+func (h *InventoryHandler) Stop() {
+	h.mu.Lock()
+	cancel := h.cancel
+	h.cancel = nil
+	h.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	h.wg.Wait()
+
+	h.syncMu.Lock()
+	h.subscribed = false
+	h.fingerprints = make(map[string]string)
+	h.syncMu.Unlock()
+}
+
+func (h *InventoryHandler) loop(ctx context.Context) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(inventoryReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.trigger:
+			// Let the rest of an event burst arrive before listing
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(inventoryDebounce):
+			}
+		case <-ticker.C:
+		}
+		h.sync(ctx)
+	}
+}
+
+// sync re-lists containers and pushes the delta, if subscribed.
+func (h *InventoryHandler) sync(ctx context.Context) {
+	h.syncMu.Lock()
+	defer h.syncMu.Unlock()
+
+	if !h.subscribed {
+		return
+	}
+
+	containers, err := h.dockerClient.ListContainers(ctx)
+	if err != nil {
+		h.log.WithError(err).Warn("Container inventory sync failed")
+		return
+	}
+
+	delta, fingerprints := diffInventory(h.fingerprints, containers)
+	if delta.empty() {
+		return
+	}
+
+	delta.BaseRevision = h.revision
+	delta.Revision = h.revision + 1
+	if err := h.sendEvent("container_inventory_delta", delta); err != nil {
+		// Keep the old state so the next sync resends these changes
+		h.log.WithError(err).Debug("Failed to send container inventory delta")
+		return
+	}
+
+	h.revision = delta.Revision
+	h.fingerprints = fingerprints
+
+	h.log.WithFields(logrus.Fields{
+		"revision": delta.Revision,
+		"added":    len(delta.Added),
+		"updated":  len(delta.Updated),
+		"removed":  len(delta.Removed),
+	}).Debug("Sent container inventory delta")
+}
+
+// diffInventory compares the current container list against the previous
+// fingerprints. Returns the delta (without revisions) and the fingerprints
+// for the new state.
+func diffInventory(previous map[string]string, current []docker.ContainerWithDigest) (*InventoryDelta, map[string]string) {
+	delta := &InventoryDelta{}
+	fingerprints := make(map[string]string, len(current))
+
+	for _, c := range current {
+		fp := inventoryFingerprint(c)
+		fingerprints[c.ID] = fp
+
+		old, seen := previous[c.ID]
+		switch {
+		case !seen:
+			delta.Added = append(delta.Added, c)
+		case old != fp:
+			delta.Updated = append(delta.Updated, c)
+		}
+	}
+
+	for id := range previous {
+		if _, ok := fingerprints[id]; !ok {
+			delta.Removed = append(delta.Removed, id)
+		}
+	}
+	sort.Strings(delta.Removed)
+
+	return delta, fingerprints
+}
+
+// inventoryFingerprint hashes the fields the backend consumes. The Status
+// text ("Up 5 minutes") is excluded: it changes every minute without any
+// real change, and State/StartedAt already capture transitions.
+func inventoryFingerprint(c docker.ContainerWithDigest) string {
+	c.Status = ""
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
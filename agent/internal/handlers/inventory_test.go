@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	dockerTypes "github.com/docker/docker/api/types"
+)
+
+func inventoryContainer(id, state, status string) docker.ContainerWithDigest {
+	return docker.ContainerWithDigest{
+		Container: dockerTypes.Container{ID: id, State: state, Status: status},
+	}
+}
+
+func TestDiffInventory_AddedUpdatedRemoved(t *testing.T) {
+	previous := map[string]string{
+		"keep":    inventoryFingerprint(inventoryContainer("keep", "running", "Up 1 minute")),
+		"stopped": inventoryFingerprint(inventoryContainer("stopped", "running", "Up 1 minute")),
+		"gone":    inventoryFingerprint(inventoryContainer("gone", "running", "Up 1 minute")),
+	}
+	current := []docker.ContainerWithDigest{
+		inventoryContainer("keep", "running", "Up 2 minutes"), // Status text only
+		inventoryContainer("stopped", "exited", "Exited (0) 1 second ago"),
+		inventoryContainer("new", "running", "Up 1 second"),
+	}
+
+	delta, fingerprints := diffInventory(previous, current)
+
+	if len(delta.Added) != 1 || delta.Added[0].ID != "new" {
+		t.Errorf("Added = %v, want [new]", delta.Added)
+	}
+	if len(delta.Updated) != 1 || delta.Updated[0].ID != "stopped" {
+		t.Errorf("Updated = %v, want [stopped]", delta.Updated)
+	}
+	if !reflect.DeepEqual(delta.Removed, []string{"gone"}) {
+		t.Errorf("Removed = %v, want [gone]", delta.Removed)
+	}
+	if len(fingerprints) != 3 || fingerprints["gone"] != "" {
+		t.Errorf("fingerprints should track exactly the current containers, got %v", fingerprints)
+	}
+}
+
+func TestDiffInventory_NoChangesIsEmpty(t *testing.T) {
+	c := inventoryContainer("abc", "running", "Up 1 minute")
+	c.RepoDigests = []string{"nginx@sha256:111"}
+	previous := map[string]string{"abc": inventoryFingerprint(c)}
+
+	delta, _ := diffInventory(previous, []docker.ContainerWithDigest{c})
+	if !delta.empty() {
+		t.Errorf("expected empty delta, got %+v", delta)
+	}
+
+	// A new digest after an image pull is a real change
+	c.RepoDigests = []string{"nginx@sha256:222"}
+	delta, _ = diffInventory(previous, []docker.ContainerWithDigest{c})
+	if len(delta.Updated) != 1 {
+		t.Errorf("expected digest change to be an update, got %+v", delta)
+	}
+}
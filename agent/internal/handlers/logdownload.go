@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// LogDownloadRequest downloads a container's full log, or the part between
+// Since and Until, as a gzip file
+type LogDownloadRequest struct {
+	DownloadID  string `json:"download_id"`
+	ContainerID string `json:"container_id"`
+	Since       string `json:"since,omitempty"` // RFC 3339, Unix timestamp or duration such as "2h"
+	Until       string `json:"until,omitempty"`
+	Timestamps  bool   `json:"timestamps,omitempty"`
+	MaxBytes    int64  `json:"max_bytes,omitempty"` // Uncompressed limit; default 50 MiB, at most 500 MiB
+}
+
+// LogDownloadResult is sent as the container_logs_complete event
+type LogDownloadResult struct {
+	DownloadID    string `json:"download_id"`
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+	Success       bool   `json:"success"`
+	Size          int64  `json:"size"`      // Compressed size
+	LogBytes      int64  `json:"log_bytes"` // Uncompressed size
+	Truncated     bool   `json:"truncated"`
+	SHA256        string `json:"sha256,omitempty"`
+	Chunks        int    `json:"chunks,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// LogDownloadHandler streams container logs to the backend as gzip chunks
+// so they can be attached to bug reports without docker CLI access
+type LogDownloadHandler struct {
+	docker    client.APIClient
+	log       *logrus.Logger
+	sendEvent func(string, interface{}) error
+}
+
+// NewLogDownloadHandler creates a new log download handler
+func NewLogDownloadHandler(docker client.APIClient, log *logrus.Logger, sendEvent func(string, interface{}) error) *LogDownloadHandler {
+	return &LogDownloadHandler{
+		docker:    docker,
+		log:       log,
+		sendEvent: sendEvent,
+	}
+}
+
+// Download streams the log as container_logs_chunk events followed by a
+// container_logs_complete event
+func (h *LogDownloadHandler) Download(ctx context.Context, req LogDownloadRequest) *LogDownloadResult {
+	result := &LogDownloadResult{
+		DownloadID:  req.DownloadID,
+		ContainerID: req.ContainerID,
+	}
+
+	err := h.download(ctx, req, result)
+	if err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).WithField("container_id", req.ContainerID).Error("Container log download failed")
+	} else {
+		result.Success = true
+		h.log.WithFields(logrus.Fields{
+			"container_id": req.ContainerID,
+			"size":         result.Size,
+			"log_bytes":    result.LogBytes,
+			"truncated":    result.Truncated,
+		}).Info("Container log download completed")
+	}
+
+	if sendErr := h.sendEvent("container_logs_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send container log download result")
+	}
+	return result
+}
+
+func (h *LogDownloadHandler) download(ctx context.Context, req LogDownloadRequest, result *LogDownloadResult) error {
+	if req.DownloadID == "" {
+		return fmt.Errorf("download_id is required")
+	}
+	if req.ContainerID == "" {
+		return fmt.Errorf("container_id is required")
+	}
+
+	chunks := &chunkEventWriter{
+		eventType: "container_logs_chunk",
+		idField:   "download_id",
+		id:        req.DownloadID,
+		sendEvent: h.sendEvent,
+	}
+	hasher := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(chunks, hasher)}
+
+	logs, err := sharedDocker.WriteContainerLogs(ctx, h.docker, req.ContainerID, sharedDocker.LogDownloadOptions{
+		Since:      req.Since,
+		Until:      req.Until,
+		Timestamps: req.Timestamps,
+		MaxBytes:   req.MaxBytes,
+	}, counter)
+	if err != nil {
+		return err
+	}
+	if err := chunks.Flush(); err != nil {
+		return err
+	}
+
+	result.ContainerID = logs.ContainerID
+	result.ContainerName = logs.ContainerName
+	result.LogBytes = logs.Bytes
+	result.Truncated = logs.Truncated
+	result.Size = counter.n
+	result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	result.Chunks = chunks.seq
+	return nil
+}
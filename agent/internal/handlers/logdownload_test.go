@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogDownloadHandler_Download(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/web/json"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"abc123","Name":"/web","Config":{"Tty":true}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/web/logs"):
+			w.Write([]byte(strings.Repeat("line\n", 20)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(daemon.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	var compressed bytes.Buffer
+	var complete *LogDownloadResult
+	h := NewLogDownloadHandler(cli, logrus.New(), func(eventType string, payload interface{}) error {
+		switch eventType {
+		case "container_logs_chunk":
+			data, _ := base64.StdEncoding.DecodeString(payload.(map[string]interface{})["data"].(string))
+			compressed.Write(data)
+		case "container_logs_complete":
+			complete = payload.(*LogDownloadResult)
+		default:
+			t.Errorf("unexpected event %s", eventType)
+		}
+		return nil
+	})
+
+	result := h.Download(context.Background(), LogDownloadRequest{DownloadID: "d1", ContainerID: "web", MaxBytes: 50})
+	if complete != result {
+		t.Fatal("container_logs_complete not sent")
+	}
+	if !result.Success || !result.Truncated || result.LogBytes != 50 || result.ContainerName != "web" || result.Chunks != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	sum := sha256.Sum256(compressed.Bytes())
+	if result.Size != int64(compressed.Len()) || result.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("size/sha256 do not match the streamed chunks: %+v", result)
+	}
+
+	gz, err := gzip.NewReader(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs, _ := io.ReadAll(gz)
+	if !strings.HasPrefix(string(logs), strings.Repeat("line\n", 10)) || !strings.Contains(string(logs), "[log truncated at 50 bytes]") {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestLogDownloadHandler_RequiresIDs(t *testing.T) {
+	h := NewLogDownloadHandler(nil, logrus.New(), func(string, interface{}) error { return nil })
+	if result := h.Download(context.Background(), LogDownloadRequest{ContainerID: "web"}); result.Success || result.Error == "" {
+		t.Errorf("missing download_id accepted: %+v", result)
+	}
+	if result := h.Download(context.Background(), LogDownloadRequest{DownloadID: "d1"}); result.Success || result.Error == "" {
+		t.Errorf("missing container_id accepted: %+v", result)
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// Project log lines are batched into one event per interval, or sooner
+// once a batch is full
+const (
+	projectLogBatchInterval = 250 * time.Millisecond
+	projectLogBatchSize     = 200
+	maxProjectLogStreams    = 8
+)
+
+// ProjectLogsRequest follows the logs of every container in a compose
+// project, like docker compose logs -f
+type ProjectLogsRequest struct {
+	StreamID    string   `json:"stream_id"`
+	ProjectName string   `json:"project_name"`
+	Services    []string `json:"services,omitempty"` // All services when empty
+	Tail        string   `json:"tail,omitempty"`     // Lines per container to start from; default "all"
+	Since       string   `json:"since,omitempty"`
+	Follow      bool     `json:"follow,omitempty"`
+}
+
+// ProjectLogsResult is sent as the project_logs_complete event
+type ProjectLogsResult struct {
+	StreamID    string `json:"stream_id"`
+	ProjectName string `json:"project_name"`
+	Lines       int    `json:"lines"`
+	Stopped     bool   `json:"stopped"` // Ended by stop_project_logs
+	Error       string `json:"error,omitempty"`
+}
+
+// ProjectLogsHandler streams interleaved compose project logs to the
+// backend as project_log_lines events
+type ProjectLogsHandler struct {
+	docker    client.APIClient
+	log       *logrus.Logger
+	sendEvent func(string, interface{}) error
+
+	mu      sync.Mutex
+	streams map[string]context.CancelFunc
+}
+
+// NewProjectLogsHandler creates a new project logs handler
+func NewProjectLogsHandler(docker client.APIClient, log *logrus.Logger, sendEvent func(string, interface{}) error) *ProjectLogsHandler {
+	return &ProjectLogsHandler{
+		docker:    docker,
+		log:       log,
+		sendEvent: sendEvent,
+		streams:   make(map[string]context.CancelFunc),
+	}
+}
+
+// Follow streams the project's logs until they end, the stream is stopped
+// or ctx is cancelled, then sends a project_logs_complete event
+func (h *ProjectLogsHandler) Follow(ctx context.Context, req ProjectLogsRequest) *ProjectLogsResult {
+	result := &ProjectLogsResult{
+		StreamID:    req.StreamID,
+		ProjectName: req.ProjectName,
+	}
+
+	err := h.follow(ctx, req, result)
+	if err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).WithField("project_name", req.ProjectName).Warn("Project log stream failed")
+	} else {
+		h.log.WithFields(logrus.Fields{
+			"project_name": req.ProjectName,
+			"lines":        result.Lines,
+			"stopped":      result.Stopped,
+		}).Debug("Project log stream ended")
+	}
+
+	if sendErr := h.sendEvent("project_logs_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send project log stream result")
+	}
+	return result
+}
+
+// Stop ends a running stream; it reports whether the stream existed
+func (h *ProjectLogsHandler) Stop(streamID string) bool {
+	h.mu.Lock()
+	cancel, ok := h.streams[streamID]
+	h.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// StopAll ends every running stream, e.g. when the connection drops
+func (h *ProjectLogsHandler) StopAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, cancel := range h.streams {
+		cancel()
+	}
+}
+
+func (h *ProjectLogsHandler) follow(ctx context.Context, req ProjectLogsRequest, result *ProjectLogsResult) error {
+	if req.StreamID == "" {
+		return fmt.Errorf("stream_id is required")
+	}
+	if req.ProjectName == "" {
+		return fmt.Errorf("project_name is required")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h.mu.Lock()
+	if _, exists := h.streams[req.StreamID]; exists {
+		h.mu.Unlock()
+		return fmt.Errorf("stream %s is already running", req.StreamID)
+	}
+	if len(h.streams) >= maxProjectLogStreams {
+		h.mu.Unlock()
+		return fmt.Errorf("too many project log streams (max %d)", maxProjectLogStreams)
+	}
+	h.streams[req.StreamID] = cancel
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.streams, req.StreamID)
+		h.mu.Unlock()
+	}()
+
+	// Flush on a timer so a quiet stream's last lines aren't held back;
+	// a failed send ends the stream
+	batch := &projectLogBatcher{streamID: req.StreamID, sendEvent: h.sendEvent}
+	flusherDone := make(chan struct{})
+	defer close(flusherDone)
+	go func() {
+		ticker := time.NewTicker(projectLogBatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if batch.flush() != nil {
+					cancel()
+					return
+				}
+			case <-flusherDone:
+				return
+			}
+		}
+	}()
+
+	err := compose.FollowProjectLogs(ctx, h.docker, req.ProjectName, compose.ProjectLogOptions{
+		Services: req.Services,
+		Tail:     req.Tail,
+		Since:    req.Since,
+		Follow:   req.Follow,
+	}, func(line compose.ProjectLogLine) error {
+		result.Lines++
+		return batch.add(line)
+	})
+	if flushErr := batch.flush(); flushErr != nil {
+		return flushErr
+	}
+
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		result.Stopped = true
+		return nil
+	}
+	return err
+}
+
+// projectLogBatcher collects lines into project_log_lines events. The
+// first send error sticks, so later calls fail fast.
+type projectLogBatcher struct {
+	streamID  string
+	sendEvent func(string, interface{}) error
+
+	mu    sync.Mutex
+	lines []compose.ProjectLogLine
+	seq   int
+	err   error
+}
+
+func (b *projectLogBatcher) add(line compose.ProjectLogLine) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.lines = append(b.lines, line)
+	if len(b.lines) >= projectLogBatchSize {
+		b.flushLocked()
+	}
+	return b.err
+}
+
+func (b *projectLogBatcher) flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.flushLocked()
+	}
+	return b.err
+}
+
+func (b *projectLogBatcher) flushLocked() {
+	if len(b.lines) == 0 {
+		return
+	}
+	err := b.sendEvent("project_log_lines", map[string]interface{}{
+		"stream_id": b.streamID,
+		"seq":       b.seq,
+		"lines":     b.lines,
+	})
+	if err != nil {
+		b.err = fmt.Errorf("failed to send log lines: %w", err)
+		return
+	}
+	b.seq++
+	b.lines = nil
+}
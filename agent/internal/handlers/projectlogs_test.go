@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func TestProjectLogsHandler_Follow(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"web1","Labels":{"com.docker.compose.project":"app","com.docker.compose.service":"web","com.docker.compose.container-number":"1"}}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/web1/json"):
+			w.Write([]byte(`{"Id":"web1","Config":{"Tty":true}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/web1/logs"):
+			w.Write([]byte(strings.Repeat("2024-05-01T10:00:00Z hello\n", projectLogBatchSize+5)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(daemon.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	var mu sync.Mutex
+	var batches [][]compose.ProjectLogLine
+	var complete *ProjectLogsResult
+	h := NewProjectLogsHandler(cli, logrus.New(), func(eventType string, payload interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch eventType {
+		case "project_log_lines":
+			batches = append(batches, payload.(map[string]interface{})["lines"].([]compose.ProjectLogLine))
+		case "project_logs_complete":
+			complete = payload.(*ProjectLogsResult)
+		}
+		return nil
+	})
+
+	result := h.Follow(context.Background(), ProjectLogsRequest{StreamID: "s1", ProjectName: "app"})
+	if result.Error != "" || result.Stopped || result.Lines != projectLogBatchSize+5 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if complete != result {
+		t.Error("project_logs_complete not sent")
+	}
+	total := 0
+	for _, batch := range batches {
+		if len(batch) > projectLogBatchSize {
+			t.Errorf("batch of %d lines exceeds %d", len(batch), projectLogBatchSize)
+		}
+		total += len(batch)
+	}
+	if total != result.Lines || batches[0][0].Container != "web-1" || batches[0][0].Line != "hello" {
+		t.Errorf("sent %d lines in %d batches, first %+v", total, len(batches), batches[0][0])
+	}
+}
+
+func TestProjectLogsHandler_RequiresFields(t *testing.T) {
+	h := NewProjectLogsHandler(nil, logrus.New(), func(string, interface{}) error { return nil })
+	if result := h.Follow(context.Background(), ProjectLogsRequest{ProjectName: "app"}); result.Error == "" {
+		t.Error("missing stream_id accepted")
+	}
+	if result := h.Follow(context.Background(), ProjectLogsRequest{StreamID: "s1"}); result.Error == "" {
+		t.Error("missing project_name accepted")
+	}
+	if h.Stop("s1") {
+		t.Error("Stop reported an unknown stream")
+	}
+}
@@ -8,13 +8,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
 	"syscall"
 	"time"
 
 	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
+	"github.com/darthnorse/dockmon-shared/progress"
 	"github.com/darthnorse/dockmon-shared/update"
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -32,6 +34,10 @@ type SelfUpdateHandler struct {
 	sendEvent     func(msgType string, payload interface{}) error
 	dockerClient  *docker.Client
 	stopSignal    func() // Signal to stop the agent gracefully
+	onFailure     func(stage string, err error)
+	throttler     *throttle.Throttler // Limits binary download bandwidth
+
+	retryDelay time.Duration // First download retry delay; 0 uses the default
 }
 
 // NewSelfUpdateHandler creates a new self-update handler
@@ -52,6 +58,18 @@ func NewSelfUpdateHandler(
 	}
 }
 
+// SetFailureCallback registers a function called when a self-update fails,
+// in addition to the progress event sent to DockMon (which is lost while
+// disconnected).
+func (h *SelfUpdateHandler) SetFailureCallback(fn func(stage string, err error)) {
+	h.onFailure = fn
+}
+
+// SetThrottler limits native-mode binary downloads to the pull bandwidth
+func (h *SelfUpdateHandler) SetThrottler(t *throttle.Throttler) {
+	h.throttler = t
+}
+
 // SelfUpdateRequest contains parameters for self-update
 // Backend sends both image and binary_url, agent picks based on deployment mode
 type SelfUpdateRequest struct {
@@ -59,14 +77,15 @@ type SelfUpdateRequest struct {
 	Image     string `json:"image"`                // For container mode
 	BinaryURL string `json:"binary_url,omitempty"` // For native mode
 	Checksum  string `json:"checksum,omitempty"`
+	// BandwidthLimit caps the binary download in bytes per second,
+	// overriding the agent's pull bandwidth limit for this update
+	BandwidthLimit int64 `json:"bandwidth_limit,omitempty"`
 }
 
-// SelfUpdateProgress represents self-update progress events
-type SelfUpdateProgress struct {
-	Stage   string `json:"stage"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
-}
+// ErrRestartRequired is returned when a native update has been applied but
+// the platform cannot exec into the new binary (Windows); the process must
+// exit so the service manager starts the updated binary.
+var ErrRestartRequired = errors.New("agent restart required to run updated binary")
 
 // UpdateLockFile represents the coordination file for native mode updates
 type UpdateLockFile struct {
@@ -74,6 +93,11 @@ type UpdateLockFile struct {
 	NewBinaryPath string    `json:"new_binary_path"`
 	OldBinaryPath string    `json:"old_binary_path"`
 	Timestamp     time.Time `json:"timestamp"`
+	// Platform is the GOOS that wrote the lock file
+	Platform string `json:"platform,omitempty"`
+	// SwapAsidePath is where the running binary is moved before the swap on
+	// platforms that can't overwrite a running executable (Windows)
+	SwapAsidePath string `json:"swap_aside_path,omitempty"`
 }
 
 // PerformSelfUpdate performs self-update based on deployment mode
@@ -115,17 +139,17 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 	}).Info("Performing container-based self-update")
 
 	// Pull new image
-	h.sendProgress("pull", fmt.Sprintf("Pulling image %s", req.Image))
+	h.sendProgress(progress.StagePull, fmt.Sprintf("Pulling image %s", req.Image))
 	if err := h.dockerClient.PullImage(ctx, req.Image); err != nil {
-		h.sendProgressError("pull", err)
+		h.sendProgressError(progress.StagePull, err)
 		return fmt.Errorf("failed to pull image: %w", err)
 	}
 
 	// Inspect own container to get configuration
-	h.sendProgress("inspect", "Inspecting current container")
+	h.sendProgress(progress.StageInspect, "Inspecting current container")
 	oldContainer, err := h.dockerClient.InspectContainer(ctx, h.myContainerID)
 	if err != nil {
-		h.sendProgressError("inspect", err)
+		h.sendProgressError(progress.StageInspect, err)
 		return fmt.Errorf("failed to inspect own container: %w", err)
 	}
 
@@ -164,7 +188,7 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 	}
 
 	// Create new container with same config but new image
-	h.sendProgress("create", "Creating new container")
+	h.sendProgress(progress.StageCreate, "Creating new container")
 	newConfig := h.cloneContainerConfig(&oldContainer, req.Image, oldImageLabels, oldImageEnv)
 	newHostConfig := h.cloneHostConfig(oldContainer.HostConfig)
 
@@ -173,7 +197,7 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 
 	newContainerID, err := h.dockerClient.CreateContainer(ctx, newConfig, newHostConfig, tempName)
 	if err != nil {
-		h.sendProgressError("create", err)
+		h.sendProgressError(progress.StageCreate, err)
 		return fmt.Errorf("failed to create new container: %w", err)
 	}
 
@@ -197,7 +221,7 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 	}
 
 	// Start new container
-	h.sendProgress("start", "Starting new container")
+	h.sendProgress(progress.StageStart, "Starting new container")
 	if err := h.dockerClient.StartContainer(ctx, newContainerID); err != nil {
 		// Cleanup: remove the failed container and cleanup file
 		if rmErr := h.dockerClient.RemoveContainer(ctx, newContainerID, true); rmErr != nil {
@@ -206,16 +230,16 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 		if rmErr := os.Remove(cleanupFile); rmErr != nil {
 			h.log.WithError(rmErr).Warn("Failed to remove cleanup file during start rollback")
 		}
-		h.sendProgressError("start", err)
+		h.sendProgressError(progress.StageStart, err)
 		return fmt.Errorf("failed to start new container: %w", err)
 	}
 
 	// Wait for new container to be healthy
-	h.sendProgress("health", "Waiting for new container to be healthy")
+	h.sendProgress(progress.StageHealth, "Waiting for new container to be healthy")
 	if err := h.waitForHealthy(ctx, newContainerID, 60); err != nil {
 		h.log.WithError(err).Warn("New container failed health check, rolling back")
 		// Rollback: stop and remove new container, remove cleanup file
-		if stopErr := h.dockerClient.StopContainer(ctx, newContainerID, 10); stopErr != nil {
+		if stopErr := h.dockerClient.StopContainer(ctx, newContainerID, 10, nil); stopErr != nil {
 			h.log.WithError(stopErr).Warn("Failed to stop container during health rollback")
 		}
 		if rmErr := h.dockerClient.RemoveContainer(ctx, newContainerID, true); rmErr != nil {
@@ -224,14 +248,14 @@ func (h *SelfUpdateHandler) performContainerSelfUpdate(ctx context.Context, req
 		if rmErr := os.Remove(cleanupFile); rmErr != nil {
 			h.log.WithError(rmErr).Warn("Failed to remove cleanup file during health rollback")
 		}
-		h.sendProgressError("health", err)
+		h.sendProgressError(progress.StageHealth, err)
 		return fmt.Errorf("new container health check failed: %w", err)
 	}
 
 	h.log.Info("New container is healthy")
 
 	// Signal completion and stop ourselves
-	h.sendProgress("complete", "Self-update complete, stopping old container")
+	h.sendProgress(progress.StageComplete, "Self-update complete, stopping old container")
 
 	h.log.Info("Self-update successful, stopping old container")
 
@@ -262,7 +286,7 @@ func (h *SelfUpdateHandler) performNativeSelfUpdate(ctx context.Context, req Sel
 	// in-filesystem rename (cross-filesystem rename fails with EXDEV).
 	currentBinaryPath, err := os.Executable()
 	if err != nil {
-		h.sendProgressError("prepare", err)
+		h.sendProgressError(progress.StagePrepare, err)
 		return fmt.Errorf("failed to detect current binary path: %w", err)
 	}
 	newBinaryPath := filepath.Join(filepath.Dir(currentBinaryPath), ".dockmon-agent.new")
@@ -278,10 +302,10 @@ func (h *SelfUpdateHandler) performNativeSelfUpdate(ctx context.Context, req Sel
 	}()
 
 	// Step 2: Download new binary
-	h.sendProgress("download", fmt.Sprintf("Downloading version %s", req.Version))
+	h.sendProgress(progress.StageDownload, fmt.Sprintf("Downloading version %s", req.Version))
 
-	if err := h.downloadBinary(ctx, req.BinaryURL, newBinaryPath); err != nil {
-		h.sendProgressError("download", err)
+	if err := h.downloadBinary(ctx, req.BinaryURL, newBinaryPath, req.BandwidthLimit); err != nil {
+		h.sendProgressError(progress.StageDownload, err)
 		return fmt.Errorf("failed to download binary: %w", err)
 	}
 
@@ -289,17 +313,17 @@ func (h *SelfUpdateHandler) performNativeSelfUpdate(ctx context.Context, req Sel
 	// available (e.g. a release without a published checksum asset), so a missing
 	// checksum is logged and the update proceeds rather than failing.
 	if req.Checksum != "" {
-		h.sendProgress("verify", "Verifying checksum")
+		h.sendProgress(progress.StageVerify, "Verifying checksum")
 
 		actualChecksum, err := h.computeFileChecksum(newBinaryPath)
 		if err != nil {
-			h.sendProgressError("verify", err)
+			h.sendProgressError(progress.StageVerify, err)
 			return fmt.Errorf("failed to compute checksum: %w", err)
 		}
 
 		if actualChecksum != req.Checksum {
 			err := fmt.Errorf("checksum mismatch: expected %s, got %s", req.Checksum, actualChecksum)
-			h.sendProgressError("verify", err)
+			h.sendProgressError(progress.StageVerify, err)
 			return err
 		}
 
@@ -310,42 +334,45 @@ func (h *SelfUpdateHandler) performNativeSelfUpdate(ctx context.Context, req Sel
 
 	// Step 4: Make binary executable
 	if err := os.Chmod(newBinaryPath, 0755); err != nil {
-		h.sendProgressError("chmod", err)
+		h.sendProgressError(progress.StageChmod, err)
 		return fmt.Errorf("failed to make binary executable: %w", err)
 	}
 
 	// Step 5: Write update lock file
-	h.sendProgress("prepare", "Preparing update coordination")
+	h.sendProgress(progress.StagePrepare, "Preparing update coordination")
 
 	lockFile := UpdateLockFile{
 		Version:       req.Version,
 		NewBinaryPath: newBinaryPath,
 		OldBinaryPath: currentBinaryPath,
 		Timestamp:     time.Now(),
+		Platform:      runtime.GOOS,
+		SwapAsidePath: swapAsidePath(currentBinaryPath),
 	}
 
 	lockFilePath := filepath.Join(h.dataDir, "update.lock")
 	if err := h.writeLockFile(lockFilePath, &lockFile); err != nil {
-		h.sendProgressError("prepare", err)
+		h.sendProgressError(progress.StagePrepare, err)
 		return fmt.Errorf("failed to write lock file: %w", err)
 	}
 	updateCommitted = true
 
-	h.sendProgress("complete", "Update prepared, agent will restart")
+	h.sendProgress(progress.StageComplete, "Update prepared, agent will restart")
 
 	h.log.Info("Native self-update prepared, signaling shutdown")
 
-	// Signal shutdown so systemd can restart us
+	// Signal shutdown so the service manager can restart us
 	if h.stopSignal != nil {
 		h.stopSignal()
 	}
 
-	// For native mode, we need to actually exit the process so systemd restarts us
-	// The stopSignal only closes the WebSocket connection, but main.go waits on sigChan
-	// Send SIGTERM to ourselves to trigger proper shutdown
-	h.log.Info("Sending SIGTERM to self for native restart")
-	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
-		h.log.WithError(err).Error("Failed to send SIGTERM to self")
+	// For native mode, we need to actually exit the process so the service
+	// manager restarts us. The stopSignal only closes the WebSocket connection,
+	// but main.go waits on sigChan: SIGTERM ourselves on Unix, exit with a
+	// failure code on Windows so the service recovery actions kick in.
+	h.log.Info("Requesting restart for native update")
+	if err := requestSelfRestart(); err != nil {
+		h.log.WithError(err).Error("Failed to request restart")
 	}
 
 	return nil
@@ -355,6 +382,8 @@ func (h *SelfUpdateHandler) performNativeSelfUpdate(ctx context.Context, req Sel
 // For native mode: applies binary swap from lock file
 // For container mode: cleans up old container from cleanup file
 func (h *SelfUpdateHandler) CheckAndApplyUpdate() error {
+	h.removeSwappedAsideBinary()
+
 	// Check for native mode update lock
 	lockFilePath := filepath.Join(h.dataDir, "update.lock")
 	if _, err := os.Stat(lockFilePath); err == nil {
@@ -384,6 +413,15 @@ func (h *SelfUpdateHandler) applyNativeUpdate(lockFilePath string) error {
 		return fmt.Errorf("failed to read lock file: %w", err)
 	}
 
+	// A lock file from another platform (e.g. a data dir copied between hosts)
+	// points at a binary this host can't run
+	if lockFile.Platform != "" && lockFile.Platform != runtime.GOOS {
+		if rmErr := os.Remove(lockFilePath); rmErr != nil {
+			h.log.WithError(rmErr).Warn("Failed to remove lock file from another platform")
+		}
+		return fmt.Errorf("update lock file was written on %s, not %s", lockFile.Platform, runtime.GOOS)
+	}
+
 	// Check if new binary exists
 	if _, err := os.Stat(lockFile.NewBinaryPath); os.IsNotExist(err) {
 		h.log.Error("New binary not found, aborting update")
@@ -403,11 +441,29 @@ func (h *SelfUpdateHandler) applyNativeUpdate(lockFilePath string) error {
 		backupCreated = true
 	}
 
+	// Move the running binary out of the way where it can't be overwritten
+	if lockFile.SwapAsidePath != "" {
+		if err := os.Rename(lockFile.OldBinaryPath, lockFile.SwapAsidePath); err != nil {
+			h.log.WithError(err).Error("Failed to move running binary aside; keeping existing binary")
+			if rmErr := os.Remove(lockFilePath); rmErr != nil {
+				h.log.WithError(rmErr).Warn("Failed to remove lock file after move error")
+			}
+			_ = os.Remove(backupPath)
+			return fmt.Errorf("failed to move running binary aside: %w", err)
+		}
+	}
+
 	// Replace old binary with new binary
 	if err := replaceBinaryAtomic(lockFile.NewBinaryPath, lockFile.OldBinaryPath); err != nil {
 		// The original binary is untouched on failure, so keep running it; restore
 		// the backup only if one was made, and never exit fatally here.
 		h.log.WithError(err).Error("Failed to replace binary; keeping existing binary")
+		if lockFile.SwapAsidePath != "" {
+			if undoErr := os.Rename(lockFile.SwapAsidePath, lockFile.OldBinaryPath); undoErr == nil {
+				backupCreated = false // Original is back in place
+				_ = os.Remove(backupPath)
+			}
+		}
 		if backupCreated {
 			if backupErr := os.Rename(backupPath, lockFile.OldBinaryPath); backupErr != nil {
 				h.log.WithError(backupErr).Error("Failed to restore backup binary")
@@ -448,7 +504,10 @@ func (h *SelfUpdateHandler) applyNativeUpdate(lockFilePath string) error {
 		return fmt.Errorf("binary not found at %s: %w", absPath, err)
 	}
 	h.log.Info("Restarting with new binary...")
-	if err := syscall.Exec(absPath, os.Args, os.Environ()); err != nil { // #nosec G702
+	if err := execBinary(absPath); err != nil {
+		if errors.Is(err, ErrRestartRequired) {
+			return err
+		}
 		h.log.WithError(err).Error("Failed to exec into new binary, will continue with old version")
 		return fmt.Errorf("failed to exec new binary: %w", err)
 	}
@@ -457,6 +516,22 @@ func (h *SelfUpdateHandler) applyNativeUpdate(lockFilePath string) error {
 	return nil
 }
 
+// removeSwappedAsideBinary deletes the previous binary left behind by a
+// native update on Windows, once it is no longer the running executable.
+func (h *SelfUpdateHandler) removeSwappedAsideBinary() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	asidePath := swapAsidePath(exe)
+	if asidePath == "" {
+		return
+	}
+	if err := os.Remove(asidePath); err != nil && !os.IsNotExist(err) {
+		h.log.WithError(err).Debug("Failed to remove previous agent binary")
+	}
+}
+
 // performContainerCleanup cleans up after container self-update
 func (h *SelfUpdateHandler) performContainerCleanup(cleanupFilePath string) error {
 	h.log.Info("Found container cleanup file, performing cleanup...")
@@ -497,7 +572,7 @@ func (h *SelfUpdateHandler) performContainerCleanup(cleanupFilePath string) erro
 	// Stop and remove old container
 	if oldContainerID != "" {
 		h.log.WithField("container_id", safeShortID(oldContainerID)).Info("Removing old container")
-		if stopErr := h.dockerClient.StopContainer(ctx, oldContainerID, 10); stopErr != nil {
+		if stopErr := h.dockerClient.StopContainer(ctx, oldContainerID, 10, nil); stopErr != nil {
 			h.log.WithError(stopErr).Warn("Failed to stop old container during cleanup")
 		}
 		if err := h.dockerClient.RemoveContainer(ctx, oldContainerID, true); err != nil {
@@ -686,41 +761,6 @@ func (h *SelfUpdateHandler) waitForHealthy(ctx context.Context, containerID stri
 	}
 }
 
-// downloadBinary downloads a binary from URL to destination
-func (h *SelfUpdateHandler) downloadBinary(ctx context.Context, url, dest string) error {
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
-	}
-
-	out, err := os.Create(dest)
-	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
-}
-
 // replaceBinaryAtomic replaces dst with src via an atomic rename, falling back
 // to a copy when src and dst are on different filesystems (rename returns EXDEV).
 func replaceBinaryAtomic(src, dst string) error {
@@ -835,28 +875,22 @@ func (h *SelfUpdateHandler) readLockFile(path string) (*UpdateLockFile, error) {
 }
 
 // sendProgress sends a self-update progress event
-func (h *SelfUpdateHandler) sendProgress(stage, message string) {
-	progress := SelfUpdateProgress{
-		Stage:   stage,
-		Message: message,
-	}
-
-	if err := h.sendEvent("selfupdate_progress", progress); err != nil {
+func (h *SelfUpdateHandler) sendProgress(stage progress.Stage, message string) {
+	event := progress.New(progress.OperationSelfUpdate, stage, message)
+	if err := h.sendEvent("selfupdate_progress", event); err != nil {
 		h.log.WithError(err).Warn("Failed to send self-update progress")
 	}
 }
 
 // sendProgressError sends a self-update progress error event
-func (h *SelfUpdateHandler) sendProgressError(stage string, err error) {
-	progress := SelfUpdateProgress{
-		Stage:   stage,
-		Message: "Error occurred",
-		Error:   err.Error(),
-	}
-
-	if sendErr := h.sendEvent("selfupdate_progress", progress); sendErr != nil {
+func (h *SelfUpdateHandler) sendProgressError(stage progress.Stage, err error) {
+	event := progress.Failed(progress.OperationSelfUpdate, stage, err)
+	if sendErr := h.sendEvent("selfupdate_progress", event); sendErr != nil {
 		h.log.WithError(sendErr).Warn("Failed to send self-update progress error")
 	}
+	if h.onFailure != nil {
+		h.onFailure(string(stage), err)
+	}
 }
 
 // computeFileChecksum computes SHA256 checksum of a file
@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/throttle"
+	"github.com/darthnorse/dockmon-shared/progress"
+	"github.com/sirupsen/logrus"
+)
+
+// Binary download retry and progress settings
+const (
+	downloadAttempts      = 6
+	downloadRetryDelay    = 2 * time.Second
+	downloadRetryMaxDelay = 30 * time.Second
+	// downloadStallTimeout aborts an attempt that receives nothing for this
+	// long. There is no overall timeout, so bandwidth-limited downloads of
+	// large binaries can take as long as they need.
+	downloadStallTimeout = 60 * time.Second
+	// Connecting and the TLS handshake get their own, shorter, limits so an
+	// unreachable server fails the attempt quickly
+	downloadDialTimeout         = 30 * time.Second
+	downloadTLSHandshakeTimeout = 10 * time.Second
+	// Progress events are sent at most this often, or on a 5% change
+	downloadProgressInterval = 500 * time.Millisecond
+)
+
+// errDownloadPermanent marks failures that retrying won't fix, such as a 404
+var errDownloadPermanent = errors.New("permanent download failure")
+
+// downloadBinary downloads a binary from URL to destination. Failed attempts
+// are retried with backoff, resuming with a Range request from what was
+// already written when the server supports it. bandwidth caps the transfer in
+// bytes per second; 0 uses the agent's pull bandwidth limit.
+func (h *SelfUpdateHandler) downloadBinary(ctx context.Context, url, dest string, bandwidth int64) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	limit := h.throttler.LimitReader
+	if bandwidth > 0 {
+		// One bucket across attempts, so a retry doesn't start with a burst
+		bucket := throttle.NewBucket(bandwidth)
+		limit = bucket.Reader
+	}
+	progress := newDownloadProgress(h)
+
+	delay := h.retryDelay
+	if delay <= 0 {
+		delay = downloadRetryDelay
+	}
+	for attempt := 1; ; attempt++ {
+		err = h.downloadAttempt(ctx, url, out, limit, progress)
+		if err == nil {
+			progress.finish()
+			return nil
+		}
+		if errors.Is(err, errDownloadPermanent) || ctx.Err() != nil || attempt == downloadAttempts {
+			return err
+		}
+
+		h.log.WithError(err).WithFields(logrus.Fields{
+			"attempt":    attempt,
+			"downloaded": progress.downloaded,
+			"retry_in":   delay.String(),
+		}).Warn("Binary download interrupted, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > downloadRetryMaxDelay {
+			delay = downloadRetryMaxDelay
+		}
+	}
+}
+
+// downloadAttempt fetches what is missing from out: the whole file, or the
+// rest of it when earlier attempts got part way and the server honours the
+// Range request.
+func (h *SelfUpdateHandler) downloadAttempt(
+	ctx context.Context,
+	url string,
+	out *os.File,
+	limit func(context.Context, io.Reader) io.Reader,
+	progress *downloadProgress,
+) error {
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w: %w", errDownloadPermanent, err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   downloadDialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   downloadTLSHandshakeTimeout,
+			ResponseHeaderTimeout: downloadStallTimeout,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return restartDownload(out, progress, "server resumed at the wrong offset")
+		}
+		progress.start(offset, total)
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Nothing left to fetch if the file is exactly the size we have
+		if _, total, ok := parseContentRange(resp.Header.Get("Content-Range")); ok && total == offset {
+			progress.start(offset, total)
+			return nil
+		}
+		return restartDownload(out, progress, "server rejected the resume range")
+	case resp.StatusCode == http.StatusOK:
+		// A full response, either first time or because the server doesn't
+		// do ranges: start the file over
+		if err := truncate(out); err != nil {
+			return err
+		}
+		progress.start(0, resp.ContentLength)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("download failed with status: %d: %w", resp.StatusCode, errDownloadPermanent)
+	}
+
+	// Give up on a connection that stops delivering data
+	stall := time.AfterFunc(downloadStallTimeout, cancel)
+	defer stall.Stop()
+	body := &stallReader{r: resp.Body, timer: stall}
+
+	written, err := io.Copy(out, progress.reader(limit(ctx, body)))
+	if err != nil {
+		if ctx.Err() != nil && !stall.Stop() {
+			return fmt.Errorf("download stalled for %s", downloadStallTimeout)
+		}
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	if resp.ContentLength >= 0 && written < resp.ContentLength {
+		return fmt.Errorf("download ended early: got %d of %d bytes", written, resp.ContentLength)
+	}
+	return nil
+}
+
+// restartDownload empties a partial file so the next attempt starts over
+func restartDownload(out *os.File, progress *downloadProgress, reason string) error {
+	if err := truncate(out); err != nil {
+		return err
+	}
+	progress.start(0, 0)
+	return fmt.Errorf("cannot resume download (%s), starting over", reason)
+}
+
+func truncate(out *os.File) error {
+	if err := out.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// parseContentRange parses "bytes start-end/total" (or "bytes */total" on a
+// 416), returning a total of -1 when the server doesn't know it
+func parseContentRange(value string) (start, total int64, ok bool) {
+	rangePart, totalPart, found := strings.Cut(strings.TrimPrefix(value, "bytes "), "/")
+	if !found {
+		return 0, 0, false
+	}
+	total = -1
+	if totalPart != "*" {
+		t, err := strconv.ParseInt(totalPart, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total = t
+	}
+	if rangePart == "*" {
+		return 0, total, true
+	}
+	startPart, _, found := strings.Cut(rangePart, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// stallReader pushes back the stall timer whenever data arrives
+type stallReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(downloadStallTimeout)
+	}
+	return n, err
+}
+
+// downloadProgress tracks a binary download across attempts and sends
+// selfupdate_progress events with the percentage and speed, like image pull
+// progress.
+type downloadProgress struct {
+	h *SelfUpdateHandler
+
+	mu           sync.Mutex
+	downloaded   int64
+	total        int64 // -1 or 0 when unknown
+	lastSent     time.Time
+	lastPercent  int
+	lastSample   time.Time
+	sampleBytes  int64
+	speedSamples []float64
+	speedMbps    float64
+}
+
+func newDownloadProgress(h *SelfUpdateHandler) *downloadProgress {
+	return &downloadProgress{h: h, lastPercent: -1}
+}
+
+// start sets the position and size at the beginning of an attempt
+func (p *downloadProgress) start(offset, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.downloaded = offset
+	if total > 0 {
+		p.total = total
+	}
+	p.lastSample = time.Now()
+	p.sampleBytes = offset
+}
+
+// reader counts bytes read from r
+func (p *downloadProgress) reader(r io.Reader) io.Reader {
+	return &progressReader{r: r, progress: p}
+}
+
+func (p *downloadProgress) add(n int) {
+	p.mu.Lock()
+	p.downloaded += int64(n)
+	now := time.Now()
+
+	// Speed in MB/s, averaged over the last three one-second samples
+	if elapsed := now.Sub(p.lastSample).Seconds(); elapsed >= 1.0 {
+		p.speedSamples = append(p.speedSamples, float64(p.downloaded-p.sampleBytes)/elapsed/(1024*1024))
+		if len(p.speedSamples) > 3 {
+			p.speedSamples = p.speedSamples[1:]
+		}
+		var sum float64
+		for _, s := range p.speedSamples {
+			sum += s
+		}
+		p.speedMbps = sum / float64(len(p.speedSamples))
+		p.lastSample = now
+		p.sampleBytes = p.downloaded
+	}
+
+	percent := p.percentLocked()
+	if now.Sub(p.lastSent) < downloadProgressInterval && percent-p.lastPercent < 5 {
+		p.mu.Unlock()
+		return
+	}
+	event := p.eventLocked(percent)
+	p.lastSent = now
+	p.lastPercent = percent
+	p.mu.Unlock()
+
+	p.send(event)
+}
+
+// finish sends the final 100% event
+func (p *downloadProgress) finish() {
+	p.mu.Lock()
+	if p.total <= 0 {
+		p.total = p.downloaded
+	}
+	event := p.eventLocked(100)
+	p.mu.Unlock()
+
+	p.send(event)
+}
+
+func (p *downloadProgress) percentLocked() int {
+	return progress.Percentage(p.downloaded, p.total)
+}
+
+func (p *downloadProgress) eventLocked(percent int) progress.Event {
+	message := fmt.Sprintf("Downloaded %.1f MB", float64(p.downloaded)/(1024*1024))
+	if p.total > 0 {
+		message = fmt.Sprintf("Downloaded %.1f of %.1f MB", float64(p.downloaded)/(1024*1024), float64(p.total)/(1024*1024))
+	}
+	event := progress.New(progress.OperationSelfUpdate, progress.StageDownload, message)
+	event.StagePercent = percent
+	event.CurrentBytes = p.downloaded
+	event.SpeedMbps = p.speedMbps
+	if p.total > 0 {
+		event.TotalBytes = p.total
+	}
+	return event
+}
+
+func (p *downloadProgress) send(event progress.Event) {
+	if err := p.h.sendEvent("selfupdate_progress", event); err != nil {
+		p.h.log.WithError(err).Debug("Failed to send download progress")
+	}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *downloadProgress
+}
+
+func (r *progressReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	if n > 0 {
+		r.progress.add(n)
+	}
+	return n, err
+}
@@ -1,17 +1,23 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/progress"
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/sirupsen/logrus"
@@ -438,3 +444,135 @@ func TestApplyNativeUpdate_NoFatalWhenBackupMissingAndDstIntact(t *testing.T) {
 		t.Error("applyNativeUpdate escalated to log.Fatal despite the original binary being intact")
 	}
 }
+
+// TestApplyNativeUpdate_RejectsLockFromOtherPlatform: a lock file written on a
+// different OS (e.g. a copied data dir) must not swap in a foreign binary.
+func TestApplyNativeUpdate_RejectsLockFromOtherPlatform(t *testing.T) {
+	tmp := t.TempDir()
+
+	newBin := filepath.Join(tmp, "agent-new")
+	if err := os.WriteFile(newBin, []byte("NEW"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldBin := filepath.Join(tmp, "dockmon-agent")
+	if err := os.WriteFile(oldBin, []byte("OLD"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	otherOS := "windows"
+	if runtime.GOOS == "windows" {
+		otherOS = "linux"
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	h := &SelfUpdateHandler{log: log}
+
+	lockPath := filepath.Join(tmp, "update.lock")
+	if err := h.writeLockFile(lockPath, &UpdateLockFile{
+		Version:       "9.9.9",
+		NewBinaryPath: newBin,
+		OldBinaryPath: oldBin,
+		Timestamp:     time.Now(),
+		Platform:      otherOS,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.applyNativeUpdate(lockPath); err == nil {
+		t.Fatal("expected applyNativeUpdate to reject a lock file from another platform")
+	}
+	if got, _ := os.ReadFile(oldBin); string(got) != "OLD" {
+		t.Errorf("binary was replaced: got %q", got)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("lock file from another platform should be removed")
+	}
+}
+
+// TestDownloadBinary_ResumesAfterDrop: a connection dropped mid-download is
+// retried with a Range request, and the file is completed rather than
+// restarted.
+func TestDownloadBinary_ResumesAfterDrop(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "agent", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	var events []progress.Event
+	h := newTestSelfUpdateHandler()
+	h.retryDelay = time.Millisecond
+	h.sendEvent = func(_ string, payload interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, payload.(progress.Event))
+		return nil
+	}
+
+	dest := filepath.Join(t.TempDir(), "agent.new")
+	if err := h.downloadBinary(context.Background(), srv.URL, dest, 0); err != nil {
+		t.Fatalf("downloadBinary: %v", err)
+	}
+	got, _ := os.ReadFile(dest)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("downloaded %d bytes, want %d matching bytes", len(got), len(content))
+	}
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != fmt.Sprintf("bytes=%d-", len(content)/2) {
+		t.Errorf("requests sent ranges %q, want a resume from the midpoint", ranges)
+	}
+	last := events[len(events)-1]
+	if last.Stage != progress.StageDownload || last.StagePercent != 100 || last.TotalBytes != int64(len(content)) {
+		t.Errorf("final progress = %+v", last)
+	}
+}
+
+// TestDownloadBinary_NoRetryOnNotFound: client errors fail at once
+func TestDownloadBinary_NoRetryOnNotFound(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	h := newTestSelfUpdateHandler()
+	h.retryDelay = time.Millisecond
+	h.sendEvent = func(string, interface{}) error { return nil }
+	err := h.downloadBinary(context.Background(), srv.URL, filepath.Join(t.TempDir(), "agent.new"), 0)
+	if err == nil || requests != 1 {
+		t.Errorf("err = %v after %d requests, want a failure after one", err, requests)
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		value        string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 500-999/1000", 500, 1000, true},
+		{"bytes 500-999/*", 500, -1, true},
+		{"bytes */1000", 0, 1000, true},
+		{"bytes 500-999", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.value)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v", tt.value, start, total, ok)
+		}
+	}
+}
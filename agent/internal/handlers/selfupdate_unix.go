@@ -0,0 +1,26 @@
+//go:build !windows
+
+package handlers
+
+import (
+	"os"
+	"syscall"
+)
+
+// swapAsidePath returns where the running binary must be moved before the
+// new one is renamed over it. Unix can replace a running executable in
+// place, so no move is needed.
+func swapAsidePath(binaryPath string) string {
+	return ""
+}
+
+// requestSelfRestart triggers a graceful shutdown so the supervisor
+// (systemd or launchd) restarts the agent and applies the staged update.
+func requestSelfRestart() error {
+	return syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+}
+
+// execBinary replaces the current process with the updated binary.
+func execBinary(path string) error {
+	return syscall.Exec(path, os.Args, os.Environ()) // #nosec G702
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package handlers
+
+import "os"
+
+// swapAsidePath returns where the running binary must be moved before the
+// new one is renamed over it. Windows refuses to overwrite a running
+// executable but does allow renaming it.
+func swapAsidePath(binaryPath string) string {
+	return binaryPath + ".old"
+}
+
+// requestSelfRestart exits with a failure code so the service manager's
+// recovery actions restart the agent and apply the staged update. Windows
+// has no signal to deliver to ourselves.
+func requestSelfRestart() error {
+	os.Exit(1)
+	return nil
+}
+
+// execBinary cannot replace the running process on Windows; the caller
+// exits and the service manager starts the updated binary instead.
+func execBinary(path string) error {
+	return ErrRestartRequired
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
@@ -28,10 +30,17 @@ type StatsHandler struct {
 	dockerClient *docker.Client
 	log          *logrus.Logger
 
-	// Active stats streams
-	streams   map[string]context.CancelFunc
+	// Active stats streams, paused containers whose stream is stopped
+	// until they're unpaused, and containers' dockmon.group labels. All
+	// protected by streamsMu.
+	streams   map[string]*statsStream
+	paused    map[string]bool
+	groups    map[string]string
 	streamsMu sync.RWMutex
 
+	// Set while the Docker daemon is down, see SetDaemonOffline
+	daemonOffline atomic.Bool
+
 	// Callback to send stats to backend
 	sendMessage func(msgType string, payload interface{}) error
 
@@ -41,15 +50,40 @@ type StatsHandler struct {
 	// concurrently with SetStatsServiceClient writes.
 	statsService   StatsServiceSender
 	statsServiceMu sync.RWMutex
+
+	// memoryMode picks the memory figure reported as memory_usage; set via
+	// SetMemoryMode before collection starts
+	memoryMode sharedDocker.MemoryMode
+
+	// ioReader reads disk I/O from cgroup v2 io.stat when Docker reports
+	// none; nil when no cgroup v2 hierarchy is visible
+	ioReader *sharedDocker.CgroupIOReader
+}
+
+// statsStream is a container's running stats stream
+type statsStream struct {
+	cancel context.CancelFunc
 }
 
+// Container states sent with a stats message when a container is paused or
+// unpaused. The paused message carries no metrics: the last sample stands
+// until the container runs again.
+const (
+	StatsStatePaused  = "paused"
+	StatsStateRunning = "running"
+)
+
 // NewStatsHandler creates a new stats handler
 func NewStatsHandler(dockerClient *docker.Client, log *logrus.Logger, sendMessage func(string, interface{}) error) *StatsHandler {
 	return &StatsHandler{
 		dockerClient: dockerClient,
 		log:          log,
-		streams:      make(map[string]context.CancelFunc),
+		streams:      make(map[string]*statsStream),
+		paused:       make(map[string]bool),
+		groups:       make(map[string]string),
 		sendMessage:  sendMessage,
+		// Containerized agents see the host's cgroups only via /host/sys
+		ioReader: sharedDocker.NewCgroupIOReader("/host/sys", "/sys"),
 	}
 }
 
@@ -70,6 +104,13 @@ func (h *StatsHandler) SetStatsServiceClient(c StatsServiceSender) {
 	h.statsService = c
 }
 
+// SetMemoryMode selects whether memory_usage reports working-set or raw
+// memory. Both figures are sent either way. Must be called before
+// StartStatsCollection, as collection goroutines read it unlocked.
+func (h *StatsHandler) SetMemoryMode(mode sharedDocker.MemoryMode) {
+	h.memoryMode = mode
+}
+
 // isNilPointer reports whether v is an interface value wrapping a nil
 // pointer (the "typed nil" footgun). It returns false for non-pointer
 // concrete types, for non-nil pointers, and for an already-nil interface
@@ -82,15 +123,22 @@ func isNilPointer(v interface{}) bool {
 // StartStatsCollection begins stats collection for all running containers
 func (h *StatsHandler) StartStatsCollection(ctx context.Context) error {
 	// List all containers
-	containers, err := h.dockerClient.ListContainers(ctx)
+	// Only IDs, names and state are needed; skip digest enrichment
+	containers, err := h.dockerClient.ListContainersFast(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list containers: %w", err)
 	}
 
 	h.log.Infof("Starting stats collection for %d containers", len(containers))
 
-	// Start stats stream for each running container
+	// Start stats stream for each running container. Paused containers
+	// report no stats until they're unpaused.
 	for _, container := range containers {
+		h.SetContainerGroup(container.ID, sharedDocker.ContainerGroup(container.Labels))
+		if container.State == "paused" {
+			h.PauseContainerStats(container.ID, container.Names[0])
+			continue
+		}
 		if container.State == "running" {
 			if err := h.StartContainerStats(ctx, container.ID, container.Names[0]); err != nil {
 				h.log.Errorf("Failed to start stats for container %s: %v", container.ID, err)
@@ -113,12 +161,16 @@ func (h *StatsHandler) StartContainerStats(parentCtx context.Context, containerI
 		return nil
 	}
 
+	// A started container isn't paused, even if the unpause was missed
+	delete(h.paused, containerID)
+
 	// Create cancellable context for this stream
 	ctx, cancel := context.WithCancel(parentCtx) // #nosec G118
-	h.streams[containerID] = cancel
+	stream := &statsStream{cancel: cancel}
+	h.streams[containerID] = stream
 
 	// Start stats collection in goroutine
-	go h.collectStats(ctx, containerID, containerName)
+	go h.collectStats(ctx, stream, containerID, containerName)
 
 	h.log.Infof("Started stats collection for container %s (%s)", containerName, safeShortID(containerID))
 	return nil
@@ -129,43 +181,158 @@ func (h *StatsHandler) StopContainerStats(containerID string) {
 	h.streamsMu.Lock()
 	defer h.streamsMu.Unlock()
 
-	if cancel, exists := h.streams[containerID]; exists {
-		cancel()
-		delete(h.streams, containerID)
+	delete(h.paused, containerID)
+	delete(h.groups, containerID)
+	if h.stopStreamLocked(containerID) {
 		h.log.Infof("Stopped stats collection for container %s", safeShortID(containerID))
 	}
 }
 
+// stopStreamLocked cancels a container's stream, reporting whether it had
+// one. Caller must hold streamsMu.
+func (h *StatsHandler) stopStreamLocked(containerID string) bool {
+	stream, exists := h.streams[containerID]
+	if !exists {
+		return false
+	}
+	stream.cancel()
+	delete(h.streams, containerID)
+	h.ioReader.Forget(containerID)
+	return true
+}
+
+// PauseContainerStats stops a paused container's stats stream, which would
+// otherwise keep reporting stale figures, and tells the backend and
+// stats-service that it's paused so its metrics are shown as frozen rather
+// than as zero CPU
+func (h *StatsHandler) PauseContainerStats(containerID, containerName string) {
+	h.streamsMu.Lock()
+	h.stopStreamLocked(containerID)
+	h.paused[containerID] = true
+	h.streamsMu.Unlock()
+
+	h.log.Infof("Paused stats collection for container %s", safeShortID(containerID))
+	h.sendState(containerID, containerName, StatsStatePaused)
+}
+
+// ResumeContainerStats restarts stats collection for an unpaused container
+func (h *StatsHandler) ResumeContainerStats(ctx context.Context, containerID, containerName string) error {
+	h.streamsMu.Lock()
+	delete(h.paused, containerID)
+	h.streamsMu.Unlock()
+
+	h.sendState(containerID, containerName, StatsStateRunning)
+	return h.StartContainerStats(ctx, containerID, containerName)
+}
+
+// SetContainerGroup records a container's stats group (its dockmon.group
+// label), sent with its samples so the stats-service can sum the group's
+// usage; "" clears it. Call before starting the container's stats.
+func (h *StatsHandler) SetContainerGroup(containerID, group string) {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	if group == "" {
+		delete(h.groups, containerID)
+	} else {
+		h.groups[containerID] = group
+	}
+}
+
+// containerGroup returns a container's stats group
+func (h *StatsHandler) containerGroup(containerID string) string {
+	h.streamsMu.RLock()
+	defer h.streamsMu.RUnlock()
+	return h.groups[containerID]
+}
+
+// isPaused reports whether a container is paused. Samples decoded just
+// before its stream was stopped are dropped.
+func (h *StatsHandler) isPaused(containerID string) bool {
+	h.streamsMu.RLock()
+	defer h.streamsMu.RUnlock()
+	return h.paused[containerID]
+}
+
+// sendState reports a container's pause state change to the backend and
+// stats-service
+func (h *StatsHandler) sendState(containerID, containerName, state string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if err := h.sendMessage("container_stats", map[string]interface{}{
+		"container_id":   containerID,
+		"container_name": containerName,
+		"state":          state,
+		"timestamp":      now,
+	}); err != nil {
+		h.log.Errorf("Failed to send stats state for %s: %v", safeShortID(containerID), err)
+	}
+
+	h.statsServiceMu.RLock()
+	ss := h.statsService
+	h.statsServiceMu.RUnlock()
+	if ss != nil {
+		ss.Send(statsmsg.AgentStatsMsg{
+			ContainerID:   containerID,
+			ContainerName: containerName,
+			State:         state,
+			Timestamp:     now,
+		})
+	}
+}
+
+// SetDaemonOffline records whether the Docker daemon is down. Going offline
+// stops all streams, and errors from streams ending with the daemon are
+// logged at debug level instead of once per container; once it's back,
+// StartStatsCollection restarts them.
+func (h *StatsHandler) SetDaemonOffline(offline bool) {
+	h.daemonOffline.Store(offline)
+	if offline {
+		h.StopAll()
+	}
+}
+
+// streamError logs a stats stream failure, quietly while the daemon is down
+func (h *StatsHandler) streamError(format string, args ...interface{}) {
+	if h.daemonOffline.Load() {
+		h.log.Debugf(format, args...)
+		return
+	}
+	h.log.Errorf(format, args...)
+}
+
 // StopAll stops all stats collection
 func (h *StatsHandler) StopAll() {
 	h.streamsMu.Lock()
 	defer h.streamsMu.Unlock()
 
-	for containerID, cancel := range h.streams {
-		cancel()
+	for containerID, stream := range h.streams {
+		stream.cancel()
 		h.log.Debugf("Stopped stats stream for %s", safeShortID(containerID))
 	}
-	h.streams = make(map[string]context.CancelFunc)
+	h.streams = make(map[string]*statsStream)
+	h.paused = make(map[string]bool)
 	h.log.Info("Stopped all stats collection")
 }
 
 // collectStats collects stats for a single container
-func (h *StatsHandler) collectStats(ctx context.Context, containerID, containerName string) {
+func (h *StatsHandler) collectStats(ctx context.Context, stream *statsStream, containerID, containerName string) {
 	defer func() {
+		// A pause and unpause may already have replaced this stream
 		h.streamsMu.Lock()
-		delete(h.streams, containerID)
+		if h.streams[containerID] == stream {
+			delete(h.streams, containerID)
+		}
 		h.streamsMu.Unlock()
 	}()
 
 	// Docker stats stream (stream = true)
-	stream, err := h.dockerClient.ContainerStats(ctx, containerID, true)
+	resp, err := h.dockerClient.ContainerStats(ctx, containerID, true)
 	if err != nil {
-		h.log.Errorf("Failed to open stats stream for %s: %v", safeShortID(containerID), err)
+		h.streamError("Failed to open stats stream for %s: %v", safeShortID(containerID), err)
 		return
 	}
-	defer stream.Body.Close()
+	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(stream.Body)
+	decoder := json.NewDecoder(resp.Body)
 
 	for {
 		select {
@@ -175,7 +342,7 @@ func (h *StatsHandler) collectStats(ctx context.Context, containerID, containerN
 		default:
 			var stats container.StatsResponse
 			if err := decoder.Decode(&stats); err != nil {
-				h.log.Errorf("Failed to decode stats for %s: %v", safeShortID(containerID), err)
+				h.streamError("Failed to decode stats for %s: %v", safeShortID(containerID), err)
 				return
 			}
 
@@ -185,27 +352,59 @@ func (h *StatsHandler) collectStats(ctx context.Context, containerID, containerN
 	}
 }
 
+// ContainerStatsSnapshot takes a single stats sample for a container.
+// mode overrides the configured memory mode for this call when non-empty.
+func (h *StatsHandler) ContainerStatsSnapshot(ctx context.Context, containerID string, mode sharedDocker.MemoryMode) (map[string]interface{}, error) {
+	if mode == "" {
+		mode = h.memoryMode
+	}
+	resp, err := h.dockerClient.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stat container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stat); err != nil {
+		return nil, fmt.Errorf("failed to decode stats: %w", err)
+	}
+	result := sharedDocker.CalculateStatsWithMode(&stat, mode)
+	h.ioReader.FillDiskIO(result, &stat, containerID)
+	return statsPayload(result, containerID, strings.TrimPrefix(stat.Name, "/"), time.Now().UTC().Format(time.RFC3339)), nil
+}
+
+// statsPayload builds the container_stats message sent to the backend
+func statsPayload(result *sharedDocker.StatsResult, containerID, containerName, timestamp string) map[string]interface{} {
+	return map[string]interface{}{
+		"container_id":       containerID,
+		"container_name":     containerName,
+		"cpu_percent":        sharedDocker.RoundToDecimal(result.CPUPercent, 1),
+		"memory_usage":       result.MemoryUsage,
+		"memory_limit":       result.MemoryLimit,
+		"memory_percent":     sharedDocker.RoundToDecimal(result.MemoryPercent, 1),
+		"memory_raw_usage":   result.MemoryRawUsage,
+		"memory_working_set": result.MemoryWorkingSet,
+		"network_rx":         result.NetworkRx,
+		"network_tx":         result.NetworkTx,
+		"disk_read":          result.DiskRead,
+		"disk_write":         result.DiskWrite,
+		"timestamp":          timestamp,
+	}
+}
+
 // processStats processes raw Docker stats and sends to backend
 func (h *StatsHandler) processStats(stat *container.StatsResponse, containerID, containerName string) {
-	result := sharedDocker.CalculateStats(stat)
+	if h.isPaused(containerID) {
+		return
+	}
+	result := sharedDocker.CalculateStatsWithMode(stat, h.memoryMode)
+	h.ioReader.FillDiskIO(result, stat, containerID)
 
 	now := time.Now().UTC().Format(time.RFC3339)
 	cpuPct := sharedDocker.RoundToDecimal(result.CPUPercent, 1)
 	memPct := sharedDocker.RoundToDecimal(result.MemoryPercent, 1)
 
-	statsMsg := map[string]interface{}{
-		"container_id":   containerID,
-		"container_name": containerName,
-		"cpu_percent":    cpuPct,
-		"memory_usage":   result.MemoryUsage,
-		"memory_limit":   result.MemoryLimit,
-		"memory_percent": memPct,
-		"network_rx":     result.NetworkRx,
-		"network_tx":     result.NetworkTx,
-		"disk_read":      result.DiskRead,
-		"disk_write":     result.DiskWrite,
-		"timestamp":      now,
-	}
+	statsMsg := statsPayload(result, containerID, containerName, now)
 
 	if err := h.sendMessage("container_stats", statsMsg); err != nil {
 		h.log.Errorf("Failed to send stats for %s: %v", safeShortID(containerID), err)
@@ -218,6 +417,7 @@ func (h *StatsHandler) processStats(stat *container.StatsResponse, containerID,
 		ss.Send(statsmsg.AgentStatsMsg{
 			ContainerID:   containerID,
 			ContainerName: containerName,
+			Group:         h.containerGroup(containerID),
 			CPUPercent:    cpuPct,
 			MemoryUsage:   result.MemoryUsage,
 			MemoryLimit:   result.MemoryLimit,
@@ -227,6 +427,9 @@ func (h *StatsHandler) processStats(stat *container.StatsResponse, containerID,
 			DiskRead:      result.DiskRead,
 			DiskWrite:     result.DiskWrite,
 			Timestamp:     now,
+
+			MemoryRawUsage:   result.MemoryRawUsage,
+			MemoryWorkingSet: result.MemoryWorkingSet,
 		})
 	}
 }
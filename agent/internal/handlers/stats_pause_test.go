@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/client/statsmsg"
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	msgs []statsmsg.AgentStatsMsg
+}
+
+func (s *recordingSender) Send(msg statsmsg.AgentStatsMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+}
+
+// states returns the State of each message sent, "" for samples
+func (s *recordingSender) states() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]string, len(s.msgs))
+	for i, msg := range s.msgs {
+		states[i] = msg.State
+	}
+	return states
+}
+
+func TestStatsHandler_PauseAndResume(t *testing.T) {
+	// Each stats stream sends one sample, then stays open until closed
+	var opened sync.WaitGroup
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/web1/stats") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"read":"2026-06-01T12:00:00Z","name":"/web"}` + "\n"))
+		w.(http.Flusher).Flush()
+		opened.Done()
+		<-r.Context().Done()
+	}))
+	defer daemon.Close()
+
+	cfg := &config.Config{DockerHost: "tcp://" + strings.TrimPrefix(daemon.URL, "http://")}
+	dockerClient, err := docker.NewClient(cfg, logrus.New(), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var backendMu sync.Mutex
+	var backendStates []string
+	h := NewStatsHandler(dockerClient, logrus.New(), func(msgType string, payload interface{}) error {
+		state, _ := payload.(map[string]interface{})["state"].(string)
+		backendMu.Lock()
+		backendStates = append(backendStates, state)
+		backendMu.Unlock()
+		return nil
+	})
+	sender := &recordingSender{}
+	h.SetStatsServiceClient(sender)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opened.Add(1)
+	if err := h.StartContainerStats(ctx, "web1", "web"); err != nil {
+		t.Fatal(err)
+	}
+	opened.Wait()
+	waitForSends(t, sender, 1)
+
+	// Pausing stops the stream and reports the state; a late sample is dropped
+	h.PauseContainerStats("web1", "web")
+	h.streamsMu.RLock()
+	_, streaming := h.streams["web1"]
+	h.streamsMu.RUnlock()
+	if streaming {
+		t.Error("stream still running while paused")
+	}
+	h.processStats(&container.StatsResponse{}, "web1", "web")
+	if got := sender.states(); len(got) != 2 || got[1] != StatsStatePaused {
+		t.Fatalf("after pause: states = %q", got)
+	}
+
+	opened.Add(1)
+	if err := h.ResumeContainerStats(ctx, "web1", "web"); err != nil {
+		t.Fatal(err)
+	}
+	opened.Wait()
+	waitForSends(t, sender, 4)
+	if got := sender.states(); got[2] != StatsStateRunning || got[3] != "" {
+		t.Errorf("after resume: states = %q", got)
+	}
+
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if len(backendStates) != 4 || backendStates[1] != StatsStatePaused || backendStates[2] != StatsStateRunning {
+		t.Errorf("backend states = %q", backendStates)
+	}
+}
+
+func waitForSends(t *testing.T, s *recordingSender, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.states()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d stats messages, want %d", len(s.states()), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStatsHandler_ContainerGroupSentWithSamples(t *testing.T) {
+	h := NewStatsHandler(nil, logrus.New(), func(string, interface{}) error { return nil })
+	sender := &recordingSender{}
+	h.SetStatsServiceClient(sender)
+
+	h.SetContainerGroup("web1", "media")
+	h.processStats(&container.StatsResponse{}, "web1", "web")
+	h.processStats(&container.StatsResponse{}, "db1", "db")
+	// A stopped container's group is forgotten; its next start sets it again
+	h.StopContainerStats("web1")
+	h.processStats(&container.StatsResponse{}, "web1", "web")
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	var groups []string
+	for _, msg := range sender.msgs {
+		groups = append(groups, msg.Group)
+	}
+	if len(groups) != 3 || groups[0] != "media" || groups[1] != "" || groups[2] != "" {
+		t.Errorf("groups = %q, want media then none", groups)
+	}
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"time"
 
 	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-shared/activity"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/darthnorse/dockmon-shared/update"
 	"github.com/sirupsen/logrus"
 )
@@ -19,18 +22,38 @@ func safeShortID(id string) string {
 
 // UpdateHandler manages container updates using the shared update package.
 type UpdateHandler struct {
-	dockerClient *docker.Client
-	log          *logrus.Logger
-	sendEvent    func(msgType string, payload interface{}) error
+	dockerClient   *docker.Client
+	log            *logrus.Logger
+	sendEvent      func(msgType string, payload interface{}) error
+	minFreeSpace   int64  // disk space preflight headroom (negative disables)
+	preflightImage string // helper image for the preflight df
+	states         *UpdateStateStore
+	activity       ActivityPublisher // Optional: announces updates to stats-service
 }
 
 // UpdateRequest contains the parameters for a container update
 type UpdateRequest struct {
-	ContainerID   string        `json:"container_id"`
-	NewImage      string        `json:"new_image"`
-	StopTimeout   int           `json:"stop_timeout,omitempty"`   // Default: 30s
-	HealthTimeout int           `json:"health_timeout,omitempty"` // Default: 120s (match Python default)
-	RegistryAuth  *RegistryAuth `json:"registry_auth,omitempty"`  // Optional registry credentials
+	ContainerID   string              `json:"container_id"`
+	NewImage      string              `json:"new_image"`
+	StopTimeout   int                 `json:"stop_timeout,omitempty"`   // Default: 30s
+	HealthTimeout int                 `json:"health_timeout,omitempty"` // Default: 120s (match Python default)
+	RegistryAuth  *RegistryAuth       `json:"registry_auth,omitempty"`  // Optional registry credentials
+	Hooks         *update.UpdateHooks `json:"hooks,omitempty"`          // Optional pre/post-update commands
+
+	// Signal/escalation for stopping the old container; see update.UpdateRequest
+	StopStrategy *sharedDocker.StopStrategy `json:"stop_strategy,omitempty"`
+
+	// Health check tuning; see update.UpdateRequest
+	GracePeriodSeconds *int                   `json:"grace_period_seconds,omitempty"`
+	ReadinessProbe     *update.ReadinessProbe `json:"readiness_probe,omitempty"`
+	SkipHealthCheck    bool                   `json:"skip_health_check,omitempty"`
+
+	// Failure forensics for a new container that fails; see update.UpdateRequest
+	FailureLogLines int `json:"failure_log_lines,omitempty"`
+	QuarantineHours int `json:"quarantine_hours,omitempty"`
+
+	// Force ignores the container's com.dockmon.update.* opt-out labels
+	Force bool `json:"force,omitempty"`
 }
 
 // RegistryAuth contains credentials for authenticating with a Docker registry.
@@ -40,12 +63,37 @@ type RegistryAuth struct {
 	Password string `json:"password"`
 }
 
+// SetHealthcheckRequest injects or overrides a container's HEALTHCHECK.
+// The container is recreated from its current image via the shared update path.
+type SetHealthcheckRequest struct {
+	ContainerID   string                     `json:"container_id"`
+	Healthcheck   update.HealthcheckOverride `json:"healthcheck"`
+	StopTimeout   int                        `json:"stop_timeout,omitempty"`
+	HealthTimeout int                        `json:"health_timeout,omitempty"`
+}
+
+// AdoptIntoStackRequest folds a standalone container into a compose stack by
+// giving it the stack's compose labels. The container is recreated from its
+// current image via the shared update path.
+type AdoptIntoStackRequest struct {
+	ContainerID string `json:"container_id"`
+	update.StackAdoption
+	StopTimeout   int `json:"stop_timeout,omitempty"`
+	HealthTimeout int `json:"health_timeout,omitempty"`
+}
+
+// UpdateGroupRequest updates several containers as one dependency-ordered unit.
+// The wire format is defined by the shared update package.
+type UpdateGroupRequest = update.GroupUpdateRequest
+
 // UpdateResult contains the result of an update operation
 type UpdateResult struct {
-	OldContainerID   string   `json:"old_container_id"`
-	NewContainerID   string   `json:"new_container_id"`
-	ContainerName    string   `json:"container_name"`
-	FailedDependents []string `json:"failed_dependents,omitempty"`
+	OldContainerID      string   `json:"old_container_id"`
+	NewContainerID      string   `json:"new_container_id"`
+	ContainerName       string   `json:"container_name"`
+	FailedDependents    []string `json:"failed_dependents,omitempty"`
+	ImageDigest         string   `json:"image_digest,omitempty"`
+	PreviousImageDigest string   `json:"previous_image_digest,omitempty"`
 }
 
 // NewUpdateHandler creates a new update handler using the shared update package.
@@ -53,14 +101,31 @@ func NewUpdateHandler(
 	dockerClient *docker.Client,
 	log *logrus.Logger,
 	sendEvent func(string, interface{}) error,
+	minFreeSpace int64,
+	preflightImage string,
+	states *UpdateStateStore,
 ) *UpdateHandler {
 	return &UpdateHandler{
-		dockerClient: dockerClient,
-		log:          log,
-		sendEvent:    sendEvent,
+		dockerClient:   dockerClient,
+		log:            log,
+		sendEvent:      sendEvent,
+		minFreeSpace:   minFreeSpace,
+		preflightImage: preflightImage,
+		states:         states,
 	}
 }
 
+// SetActivityPublisher enables update events on the stats-service activity
+// stream. Call before any update runs.
+func (h *UpdateHandler) SetActivityPublisher(p ActivityPublisher) {
+	h.activity = p
+}
+
+// UpdateStatus returns the persisted update states matching the request
+func (h *UpdateHandler) UpdateStatus(containerIDs []string, since time.Time) []UpdateState {
+	return h.states.Query(containerIDs, since)
+}
+
 // UpdateContainer performs a rolling update of a container using the shared update package.
 // Returns the update result with old/new container IDs.
 func (h *UpdateHandler) UpdateContainer(ctx context.Context, req UpdateRequest) (*UpdateResult, error) {
@@ -88,12 +153,119 @@ func (h *UpdateHandler) UpdateContainer(ctx context.Context, req UpdateRequest)
 		StopTimeout:   req.StopTimeout,
 		HealthTimeout: req.HealthTimeout,
 		RegistryAuth:  registryAuth,
+		Hooks:         req.Hooks,
+		StopStrategy:  req.StopStrategy,
+
+		GracePeriodSeconds: req.GracePeriodSeconds,
+		ReadinessProbe:     req.ReadinessProbe,
+		SkipHealthCheck:    req.SkipHealthCheck,
+		FailureLogLines:    req.FailureLogLines,
+		QuarantineHours:    req.QuarantineHours,
+		Force:              req.Force,
+	}
+
+	h.states.Start(containerID, "update", "", newImage)
+	h.publishActivity(activity.Event{
+		Action:      activity.ActionUpdateStarted,
+		ContainerID: containerID,
+		Image:       newImage,
+	})
+	result, err := h.runUpdate(ctx, updateReq, "update_complete")
+	if err != nil {
+		h.publishActivity(activity.Event{
+			Action:      activity.ActionUpdateFailed,
+			ContainerID: containerID,
+			Image:       newImage,
+			Message:     err.Error(),
+		})
+		return nil, err
+	}
+	h.publishActivity(activity.Event{
+		Action:        activity.ActionUpdateCompleted,
+		ContainerID:   containerID,
+		ContainerName: result.ContainerName,
+		Image:         newImage,
+		Attributes:    map[string]string{"new_container_id": safeShortID(result.NewContainerID)},
+	})
+	return result, nil
+}
+
+// publishActivity sends an activity event if a publisher is set
+func (h *UpdateHandler) publishActivity(event activity.Event) {
+	if h.activity != nil {
+		h.activity.Publish(event)
+	}
+}
+
+// SetHealthcheck recreates a container from its current image with the
+// requested HEALTHCHECK, reusing the update engine's backup/rollback flow.
+func (h *UpdateHandler) SetHealthcheck(ctx context.Context, req SetHealthcheckRequest) (*UpdateResult, error) {
+	if req.ContainerID == "" {
+		return nil, &UpdateError{Message: "container_id is required"}
 	}
+	if err := req.Healthcheck.Validate(); err != nil {
+		return nil, &UpdateError{Message: err.Error()}
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"container_id": safeShortID(req.ContainerID),
+		"test":         req.Healthcheck.Test,
+	}).Info("Setting container healthcheck")
+
+	healthcheck := req.Healthcheck
+	updateReq := update.UpdateRequest{
+		ContainerID:   req.ContainerID,
+		StopTimeout:   req.StopTimeout,
+		HealthTimeout: req.HealthTimeout,
+		Healthcheck:   &healthcheck,
+	}
+
+	h.states.Start(req.ContainerID, "healthcheck", "", "")
+	return h.runUpdate(ctx, updateReq, "healthcheck_complete")
+}
+
+// AdoptIntoStack recreates a container from its current image with the
+// requested stack's compose labels, reusing the update engine's
+// backup/rollback flow. Adoption doesn't change the image, so the
+// com.dockmon.update.* opt-out labels don't apply.
+func (h *UpdateHandler) AdoptIntoStack(ctx context.Context, req AdoptIntoStackRequest) (*UpdateResult, error) {
+	if req.ContainerID == "" {
+		return nil, &UpdateError{Message: "container_id is required"}
+	}
+	if err := req.StackAdoption.Validate(); err != nil {
+		return nil, &UpdateError{Message: err.Error()}
+	}
+
+	h.log.WithFields(logrus.Fields{
+		"container_id": safeShortID(req.ContainerID),
+		"project":      req.Project,
+		"service":      req.Service,
+	}).Info("Adopting container into stack")
+
+	adoption := req.StackAdoption
+	updateReq := update.UpdateRequest{
+		ContainerID:   req.ContainerID,
+		StopTimeout:   req.StopTimeout,
+		HealthTimeout: req.HealthTimeout,
+		AdoptInto:     &adoption,
+		Force:         true,
+	}
+
+	h.states.Start(req.ContainerID, "adopt", "", "")
+	return h.runUpdate(ctx, updateReq, "adopt_into_stack_complete")
+}
+
+// runUpdate executes a shared-package update, streaming progress to the
+// backend and sending completionEvent on success.
+func (h *UpdateHandler) runUpdate(ctx context.Context, updateReq update.UpdateRequest, completionEvent string) (*UpdateResult, error) {
+	containerID := updateReq.ContainerID
 
 	// Re-detect options with callbacks for this specific update
 	options := update.DetectOptions(ctx, h.dockerClient.RawClient(), h.log)
+	options.MinFreeSpace = h.minFreeSpace
+	options.PreflightImage = h.preflightImage
 	options.OnProgress = func(event update.ProgressEvent) {
-		h.sendProgress(containerID, event.Stage, event.Message)
+		h.sendProgressEvent(containerID, event)
 	}
 	options.OnPullProgress = func(event update.PullProgressEvent) {
 		h.sendLayerProgress(event)
@@ -107,11 +279,20 @@ func (h *UpdateHandler) UpdateContainer(ctx context.Context, req UpdateRequest)
 
 	if !result.Success {
 		// Send error event
+		status := UpdateStatusFailed
 		if result.RolledBack {
+			status = UpdateStatusRolledBack
 			h.sendProgress(containerID, update.StageRollback, result.Error)
 		} else {
 			h.sendProgress(containerID, update.StageFailed, result.Error)
 		}
+		h.states.Finish(containerID, status, func(s *UpdateState) {
+			s.Error = result.Error
+			s.Failure = stateFailure(result.Failure)
+		})
+		if completionEvent == "update_complete" {
+			h.sendFailure(containerID, result)
+		}
 		return nil, &UpdateError{Message: result.Error}
 	}
 
@@ -124,7 +305,19 @@ func (h *UpdateHandler) UpdateContainer(ctx context.Context, req UpdateRequest)
 	if len(result.FailedDependents) > 0 {
 		completionPayload["failed_dependents"] = result.FailedDependents
 	}
-	h.sendEvent("update_complete", completionPayload)
+	if result.ImageDigest != "" {
+		completionPayload["image_digest"] = result.ImageDigest
+	}
+	if result.PreviousImageDigest != "" {
+		completionPayload["previous_image_digest"] = result.PreviousImageDigest
+	}
+	h.states.Finish(containerID, UpdateStatusCompleted, func(s *UpdateState) {
+		s.Stage = update.StageCompleted
+		s.NewContainerID = safeShortID(result.NewContainerID)
+		s.ContainerName = result.ContainerName
+		s.ImageDigest = result.ImageDigest
+	})
+	h.sendEvent(completionEvent, completionPayload)
 
 	h.log.WithFields(logrus.Fields{
 		"old_container": result.OldContainerID,
@@ -133,13 +326,124 @@ func (h *UpdateHandler) UpdateContainer(ctx context.Context, req UpdateRequest)
 	}).Info("Container update completed successfully")
 
 	return &UpdateResult{
-		OldContainerID:   result.OldContainerID,
-		NewContainerID:   result.NewContainerID,
-		ContainerName:    result.ContainerName,
-		FailedDependents: result.FailedDependents,
+		OldContainerID:      result.OldContainerID,
+		NewContainerID:      result.NewContainerID,
+		ContainerName:       result.ContainerName,
+		FailedDependents:    result.FailedDependents,
+		ImageDigest:         result.ImageDigest,
+		PreviousImageDigest: result.PreviousImageDigest,
 	}, nil
 }
 
+// sendFailure reports a failed container update to the backend with the
+// forensics captured from the failed new container, so the waiting update
+// doesn't have to time out. Backends that didn't negotiate update_failures
+// don't get it; the outcome stays in the update state.
+func (h *UpdateHandler) sendFailure(containerID string, result *update.UpdateResult) {
+	payload := map[string]interface{}{
+		"container_id": safeShortID(containerID),
+		"error":        result.Error,
+		"rolled_back":  result.RolledBack,
+	}
+	if result.Failure != nil {
+		payload["failure"] = stateFailure(result.Failure)
+	}
+	if err := h.sendEvent("update_failed", payload); err != nil {
+		h.log.WithError(err).Debug("Failed to send update failure")
+	}
+}
+
+// stateFailure is the forensics kept in the update state file: the inspect
+// output is left out so the file stays small
+func stateFailure(f *update.FailureForensics) *update.FailureForensics {
+	if f == nil {
+		return nil
+	}
+	kept := *f
+	kept.Inspect = nil
+	return &kept
+}
+
+// UpdateGroup updates several containers in dependency order, rolling all of
+// them back if any member fails. Progress is streamed as update_group_progress
+// (with the full plan) and as per-container update_progress.
+func (h *UpdateHandler) UpdateGroup(ctx context.Context, req UpdateGroupRequest) (*update.GroupUpdateResult, error) {
+	h.log.WithFields(logrus.Fields{
+		"group_id":   req.GroupID,
+		"containers": len(req.Members),
+	}).Info("Starting group update")
+	for _, member := range req.Members {
+		h.states.Start(member.ContainerID, "group", req.GroupID, member.NewImage)
+	}
+
+	options := update.DetectOptions(ctx, h.dockerClient.RawClient(), h.log)
+	options.MinFreeSpace = h.minFreeSpace
+	options.PreflightImage = h.preflightImage
+	options.OnPullProgress = func(event update.PullProgressEvent) {
+		h.sendLayerProgress(event)
+	}
+	options.OnGroupProgress = func(event update.GroupProgressEvent) {
+		if event.ContainerID != "" {
+			h.sendProgressEvent(event.ContainerID, update.ProgressEvent{
+				Stage:   string(event.Stage),
+				Message: event.Message,
+				Output:  event.Output,
+			})
+		}
+		if err := h.sendEvent("update_group_progress", event); err != nil {
+			h.log.WithError(err).Warn("Failed to send group update progress")
+		}
+	}
+
+	updater := update.NewUpdater(h.dockerClient.RawClient(), h.log, options)
+	result := updater.UpdateGroup(ctx, req)
+
+	h.finishGroupStates(req, result)
+	h.sendEvent("update_group_complete", result)
+
+	if !result.Success {
+		return result, &UpdateError{Message: result.Error}
+	}
+
+	h.log.WithField("group_id", req.GroupID).Info("Group update completed successfully")
+	return result, nil
+}
+
+// finishGroupStates records each member's outcome from the group plan.
+// Members missing from the plan (e.g. planning failed) take the group error.
+func (h *UpdateHandler) finishGroupStates(req UpdateGroupRequest, result *update.GroupUpdateResult) {
+	plan := make(map[string]update.GroupPlanEntry, len(result.Plan))
+	for _, entry := range result.Plan {
+		plan[safeShortID(entry.ContainerID)] = entry
+	}
+	for _, member := range req.Members {
+		entry, ok := plan[safeShortID(member.ContainerID)]
+		status := UpdateStatusFailed
+		errMsg := result.Error
+		switch {
+		case !ok:
+		case entry.Status == update.GroupStatusUpdated:
+			status, errMsg = UpdateStatusCompleted, ""
+		case entry.Status == update.GroupStatusRolledBack:
+			status = UpdateStatusRolledBack
+		case entry.Status == update.GroupStatusSkipped, entry.Status == update.GroupStatusPending:
+			status = UpdateStatusSkipped
+		case entry.Error != "":
+			errMsg = entry.Error
+		}
+		h.states.Finish(member.ContainerID, status, func(s *UpdateState) {
+			s.Error = errMsg
+			if status == UpdateStatusCompleted {
+				s.Stage = update.StageCompleted
+			}
+			if ok {
+				s.ContainerName = entry.ContainerName
+				s.NewContainerID = safeShortID(entry.NewContainerID)
+			}
+		})
+	}
+}
+
 // UpdateError is returned when an update fails
 type UpdateError struct {
 	Message string
@@ -151,11 +455,17 @@ func (e *UpdateError) Error() string {
 
 // sendProgress sends an update progress event to the backend.
 func (h *UpdateHandler) sendProgress(containerID, stage, message string) {
-	progress := map[string]interface{}{
-		"container_id": safeShortID(containerID),
-		"stage":        stage,
-		"message":      message,
+	h.sendProgressEvent(containerID, update.ProgressEvent{Stage: stage, Message: message})
+}
+
+// sendProgressEvent sends a shared-package progress event to the backend
+// in the shared progress schema. Failure messages are reported as the error.
+func (h *UpdateHandler) sendProgressEvent(containerID string, event update.ProgressEvent) {
+	progress := event.ToProgress(safeShortID(containerID))
+	if event.Stage == update.StageFailed || event.Stage == update.StageRollback {
+		progress.Error = event.Message
 	}
+	h.states.Progress(containerID, event.Stage, event.Message)
 
 	if err := h.sendEvent("update_progress", progress); err != nil {
 		h.log.WithError(err).Warn("Failed to send update progress")
@@ -164,29 +474,8 @@ func (h *UpdateHandler) sendProgress(containerID, stage, message string) {
 
 // sendLayerProgress sends layer-by-layer pull progress to the backend.
 func (h *UpdateHandler) sendLayerProgress(event update.PullProgressEvent) {
-	// Build layer list for frontend (match Python format)
-	layerList := make([]map[string]interface{}, 0, len(event.Layers))
-	for _, layer := range event.Layers {
-		layerList = append(layerList, map[string]interface{}{
-			"id":      layer.ID,
-			"status":  layer.Status,
-			"current": layer.Current,
-			"total":   layer.Total,
-			"percent": layer.Percent,
-		})
-	}
-
-	progress := map[string]interface{}{
-		"container_id":     safeShortID(event.ContainerID),
-		"overall_progress": event.OverallProgress,
-		"layers":           layerList,
-		"total_layers":     event.TotalLayers,
-		"remaining_layers": event.RemainingLayers,
-		"summary":          event.Summary,
-		"speed_mbps":       event.SpeedMbps,
-	}
-
-	if err := h.sendEvent("update_layer_progress", progress); err != nil {
+	event.ContainerID = safeShortID(event.ContainerID)
+	if err := h.sendEvent("update_layer_progress", event.ToProgress()); err != nil {
 		h.log.WithError(err).Debug("Failed to send layer progress")
 	}
 }
@@ -89,6 +89,34 @@ func TestUpdateRequestWithNullRegistryAuth(t *testing.T) {
 	}
 }
 
+func TestUpdateRequestWithHealthOptions(t *testing.T) {
+	// Test request with health check tuning
+	jsonData := `{
+		"container_id": "abc123def456",
+		"new_image": "myapp:v2",
+		"grace_period_seconds": 0,
+		"readiness_probe": {"type": "http", "url": "http://localhost:8080/ready"},
+		"skip_health_check": false
+	}`
+
+	var req UpdateRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if req.GracePeriodSeconds == nil || *req.GracePeriodSeconds != 0 {
+		t.Errorf("expected grace_period_seconds 0 to be kept, got %v", req.GracePeriodSeconds)
+	}
+
+	if req.ReadinessProbe == nil || req.ReadinessProbe.Type != "http" || req.ReadinessProbe.URL != "http://localhost:8080/ready" {
+		t.Errorf("unexpected readiness_probe %+v", req.ReadinessProbe)
+	}
+
+	if req.SkipHealthCheck {
+		t.Error("expected skip_health_check false")
+	}
+}
+
 func TestRegistryAuthConversionToDockerType(t *testing.T) {
 	// Test conversion from handlers.RegistryAuth to docker.RegistryAuth
 	handlerAuth := &RegistryAuth{
@@ -157,3 +185,100 @@ func TestUpdateRequestDefaults(t *testing.T) {
 		t.Errorf("expected health_timeout 0 from unmarshal, got %d", req.HealthTimeout)
 	}
 }
+
+func TestSetHealthcheckRequestUnmarshal(t *testing.T) {
+	jsonData := `{
+		"container_id": "abc123def456",
+		"healthcheck": {
+			"test": ["CMD-SHELL", "curl -f http://localhost:8080/ || exit 1"],
+			"interval": 30,
+			"retries": 3
+		}
+	}`
+
+	var req SetHealthcheckRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if req.ContainerID != "abc123def456" {
+		t.Errorf("expected container_id 'abc123def456', got %q", req.ContainerID)
+	}
+
+	if len(req.Healthcheck.Test) != 2 || req.Healthcheck.Test[0] != "CMD-SHELL" {
+		t.Errorf("expected CMD-SHELL test, got %v", req.Healthcheck.Test)
+	}
+
+	if req.Healthcheck.Interval != 30 || req.Healthcheck.Retries != 3 {
+		t.Errorf("expected interval 30 / retries 3, got %d / %d", req.Healthcheck.Interval, req.Healthcheck.Retries)
+	}
+
+	if err := req.Healthcheck.Validate(); err != nil {
+		t.Errorf("expected valid healthcheck, got %v", err)
+	}
+}
+
+func TestAdoptIntoStackRequestUnmarshal(t *testing.T) {
+	jsonData := `{
+		"container_id": "abc123def456",
+		"project": "media",
+		"service": "plex",
+		"working_dir": "/opt/media",
+		"health_timeout": 60
+	}`
+
+	var req AdoptIntoStackRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if req.ContainerID != "abc123def456" || req.Project != "media" || req.Service != "plex" || req.WorkingDir != "/opt/media" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if req.HealthTimeout != 60 {
+		t.Errorf("expected health_timeout 60, got %d", req.HealthTimeout)
+	}
+	if err := req.StackAdoption.Validate(); err != nil {
+		t.Errorf("expected valid adoption, got %v", err)
+	}
+}
+
+func TestUpdateGroupRequestUnmarshal(t *testing.T) {
+	jsonData := `{
+		"group_id": "media-stack",
+		"containers": [
+			{"container_id": "abc123def456", "new_image": "app:2.0", "depends_on": ["db"]},
+			{"container_id": "def456abc123", "new_image": "postgres:16", "registry_auth": {"username": "u", "password": "p"}}
+		],
+		"health_timeout": 60
+	}`
+
+	var req UpdateGroupRequest
+	if err := json.Unmarshal([]byte(jsonData), &req); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if req.GroupID != "media-stack" {
+		t.Errorf("expected group_id 'media-stack', got %q", req.GroupID)
+	}
+
+	if len(req.Members) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(req.Members))
+	}
+
+	if len(req.Members[0].DependsOn) != 1 || req.Members[0].DependsOn[0] != "db" {
+		t.Errorf("expected depends_on [db], got %v", req.Members[0].DependsOn)
+	}
+
+	if req.Members[1].RegistryAuth == nil || req.Members[1].RegistryAuth.Username != "u" {
+		t.Errorf("expected registry auth on second container, got %+v", req.Members[1].RegistryAuth)
+	}
+
+	if req.ExplicitOrder {
+		t.Error("expected explicit_order to default to false")
+	}
+
+	if req.HealthTimeout != 60 {
+		t.Errorf("expected health_timeout 60, got %d", req.HealthTimeout)
+	}
+}
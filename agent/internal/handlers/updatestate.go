@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/sirupsen/logrus"
+)
+
+// Update statuses recorded in the update state file
+const (
+	UpdateStatusRunning     = "running"
+	UpdateStatusCompleted   = "completed"
+	UpdateStatusFailed      = "failed"
+	UpdateStatusRolledBack  = "rolled_back"
+	UpdateStatusSkipped     = "skipped"     // Group member not reached after an earlier failure
+	UpdateStatusInterrupted = "interrupted" // The agent stopped mid-update
+)
+
+// Finished updates are kept for a week, and at most maxUpdateStates overall
+const (
+	updateStateRetention = 7 * 24 * time.Hour
+	maxUpdateStates      = 500
+	// updateStateSaveInterval throttles writes for progress messages within
+	// a stage; stage changes and outcomes are always written at once
+	updateStateSaveInterval = 5 * time.Second
+)
+
+// UpdateState is the latest known state of an update, keyed by the short ID
+// of the container being updated
+type UpdateState struct {
+	ContainerID    string     `json:"container_id"`
+	Operation      string     `json:"operation"` // "update", "healthcheck", "adopt" or "group"
+	GroupID        string     `json:"group_id,omitempty"`
+	NewImage       string     `json:"new_image,omitempty"`
+	Status         string     `json:"status"`
+	Stage          string     `json:"stage"`
+	Message        string     `json:"message,omitempty"`
+	NewContainerID string     `json:"new_container_id,omitempty"`
+	ContainerName  string     `json:"container_name,omitempty"`
+	ImageDigest    string     `json:"image_digest,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+
+	// Failure is what was captured from a new container that failed and
+	// was rolled back (without its inspect output)
+	Failure *update.FailureForensics `json:"failure,omitempty"`
+}
+
+// UpdateStateStore persists update states to a JSON file so the backend can
+// reconcile updates after a reconnect, including ones that finished while
+// it was away. Persistence is best effort: a write failure is logged and
+// never fails the update. A nil store records nothing.
+type UpdateStateStore struct {
+	path string
+	log  *logrus.Logger
+	now  func() time.Time
+
+	mu       sync.Mutex
+	states   map[string]*UpdateState
+	lastSave time.Time
+}
+
+// NewUpdateStateStore loads the state file at path. Updates still running
+// when the agent stopped are marked interrupted.
+func NewUpdateStateStore(path string, log *logrus.Logger) *UpdateStateStore {
+	s := &UpdateStateStore{
+		path:   path,
+		log:    log,
+		now:    time.Now,
+		states: make(map[string]*UpdateState),
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from the agent's DataPath
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).Warn("Failed to read update state file; starting empty")
+		}
+		return s
+	}
+	var states []*UpdateState
+	if err := json.Unmarshal(data, &states); err != nil {
+		log.WithError(err).Warn("Update state file is corrupt; starting empty")
+		return s
+	}
+
+	now := s.now().UTC()
+	interrupted := 0
+	for _, state := range states {
+		if state == nil || state.ContainerID == "" {
+			continue
+		}
+		if state.Status == UpdateStatusRunning {
+			state.Status = UpdateStatusInterrupted
+			state.Error = "agent stopped before the update finished"
+			state.UpdatedAt = now
+			state.FinishedAt = &now
+			interrupted++
+		}
+		s.states[state.ContainerID] = state
+	}
+	if interrupted > 0 {
+		log.WithField("count", interrupted).Warn("Marked updates interrupted by an agent restart")
+	}
+	s.mu.Lock()
+	s.pruneLocked()
+	s.saveLocked()
+	s.mu.Unlock()
+	return s
+}
+
+// Start records a new update, replacing any earlier state for the container
+func (s *UpdateStateStore) Start(containerID, operation, groupID, newImage string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	s.states[safeShortID(containerID)] = &UpdateState{
+		ContainerID: safeShortID(containerID),
+		Operation:   operation,
+		GroupID:     groupID,
+		NewImage:    newImage,
+		Status:      UpdateStatusRunning,
+		Stage:       "queued",
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.pruneLocked()
+	s.saveLocked()
+}
+
+// Progress records the stage a running update has reached. Message-only
+// changes are written at most every updateStateSaveInterval.
+func (s *UpdateStateStore) Progress(containerID, stage, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[safeShortID(containerID)]
+	if !ok || state.Status != UpdateStatusRunning || (state.Stage == stage && state.Message == message) {
+		return
+	}
+	stageChanged := state.Stage != stage
+	state.Stage = stage
+	state.Message = message
+	state.UpdatedAt = s.now().UTC()
+	if stageChanged || state.UpdatedAt.Sub(s.lastSave) >= updateStateSaveInterval {
+		s.saveLocked()
+	}
+}
+
+// Finish records the outcome of an update; fill may set result fields
+func (s *UpdateStateStore) Finish(containerID, status string, fill func(*UpdateState)) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[safeShortID(containerID)]
+	if !ok {
+		return
+	}
+	now := s.now().UTC()
+	state.Status = status
+	state.UpdatedAt = now
+	state.FinishedAt = &now
+	if fill != nil {
+		fill(state)
+	}
+	s.saveLocked()
+}
+
+// Query returns the states of the given containers (all when empty) that
+// changed at or after since, oldest first
+func (s *UpdateStateStore) Query(containerIDs []string, since time.Time) []UpdateState {
+	if s == nil {
+		return []UpdateState{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[string]bool, len(containerIDs))
+	for _, id := range containerIDs {
+		wanted[safeShortID(id)] = true
+	}
+	result := make([]UpdateState, 0, len(s.states))
+	for id, state := range s.states {
+		if len(wanted) > 0 && !wanted[id] {
+			continue
+		}
+		if state.UpdatedAt.Before(since) {
+			continue
+		}
+		result = append(result, *state)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.Before(result[j].UpdatedAt) })
+	return result
+}
+
+// pruneLocked drops finished states past the retention period, then the
+// oldest finished states beyond maxUpdateStates. Running updates are kept.
+func (s *UpdateStateStore) pruneLocked() {
+	cutoff := s.now().Add(-updateStateRetention)
+	var finished []*UpdateState
+	for id, state := range s.states {
+		if state.Status == UpdateStatusRunning {
+			continue
+		}
+		if state.UpdatedAt.Before(cutoff) {
+			delete(s.states, id)
+			continue
+		}
+		finished = append(finished, state)
+	}
+	if excess := len(s.states) - maxUpdateStates; excess > 0 {
+		sort.Slice(finished, func(i, j int) bool { return finished[i].UpdatedAt.Before(finished[j].UpdatedAt) })
+		for _, state := range finished[:min(excess, len(finished))] {
+			delete(s.states, state.ContainerID)
+		}
+	}
+}
+
+// saveLocked writes the states atomically via a temp file and rename
+func (s *UpdateStateStore) saveLocked() {
+	s.lastSave = s.now().UTC()
+	if err := s.writeLocked(); err != nil {
+		s.log.WithError(err).Warn("Failed to persist update state")
+	}
+}
+
+func (s *UpdateStateStore) writeLocked() error {
+	states := make([]*UpdateState, 0, len(s.states))
+	for _, state := range s.states {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ContainerID < states[j].ContainerID })
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode update state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write update state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace update state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/docker/docker/api/types/container"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUpdateStateStore_Lifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.json")
+	s := NewUpdateStateStore(path, logrus.New())
+
+	s.Start("abcdef0123456789", "update", "", "nginx:1.27")
+	s.Progress("abcdef0123456789", update.StagePulling, "Pulling nginx:1.27")
+
+	states := s.Query(nil, time.Time{})
+	if len(states) != 1 || states[0].ContainerID != "abcdef012345" || states[0].Status != UpdateStatusRunning || states[0].Stage != update.StagePulling {
+		t.Fatalf("states = %+v", states)
+	}
+
+	s.Finish("abcdef012345", UpdateStatusCompleted, func(st *UpdateState) {
+		st.NewContainerID = "fedcba987654"
+	})
+
+	// Reloading keeps finished states as they were
+	states = NewUpdateStateStore(path, logrus.New()).Query([]string{"abcdef0123456789"}, time.Time{})
+	if len(states) != 1 || states[0].Status != UpdateStatusCompleted || states[0].NewContainerID != "fedcba987654" || states[0].FinishedAt == nil {
+		t.Errorf("reloaded states = %+v", states)
+	}
+}
+
+func TestUpdateStateStore_InterruptedOnReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.json")
+	s := NewUpdateStateStore(path, logrus.New())
+	s.Start("c1", "update", "", "redis:7")
+	s.Progress("c1", update.StagePulling, "Pulling")
+
+	states := NewUpdateStateStore(path, logrus.New()).Query(nil, time.Time{})
+	if len(states) != 1 || states[0].Status != UpdateStatusInterrupted || states[0].Stage != update.StagePulling || states[0].Error == "" {
+		t.Errorf("states = %+v, want interrupted at pulling", states)
+	}
+}
+
+func TestUpdateStateStore_ThrottlesProgressWrites(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "updates.json")
+	s := NewUpdateStateStore(path, logrus.New())
+	s.now = func() time.Time { return now }
+	persisted := func() UpdateState {
+		t.Helper()
+		states := NewUpdateStateStore(path, logrus.New()).Query(nil, time.Time{})
+		if len(states) != 1 {
+			t.Fatalf("persisted states = %+v", states)
+		}
+		return states[0]
+	}
+
+	s.Start("c1", "update", "", "redis:7")
+	s.Progress("c1", update.StagePulling, "Pulling 10%")
+	now = now.Add(time.Second)
+	s.Progress("c1", update.StagePulling, "Pulling 50%")
+	if got := persisted().Message; got != "Pulling 10%" {
+		t.Errorf("persisted message = %q, want the throttled write skipped", got)
+	}
+	if got := s.Query(nil, time.Time{})[0].Message; got != "Pulling 50%" {
+		t.Errorf("queried message = %q, want the latest", got)
+	}
+
+	now = now.Add(updateStateSaveInterval)
+	s.Progress("c1", update.StagePulling, "Pulling 90%")
+	if got := persisted().Message; got != "Pulling 90%" {
+		t.Errorf("persisted message = %q after the save interval", got)
+	}
+
+	now = now.Add(time.Second)
+	s.Progress("c1", update.StageBackup, "Backing up")
+	if got := persisted().Stage; got != update.StageBackup {
+		t.Errorf("persisted stage = %q, want stage changes written at once", got)
+	}
+}
+
+func TestUpdateStateStore_QueryAndPrune(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := NewUpdateStateStore(filepath.Join(t.TempDir(), "updates.json"), logrus.New())
+	s.now = func() time.Time { return now }
+
+	s.Start("old", "update", "", "")
+	s.Finish("old", UpdateStatusFailed, nil)
+	s.Start("stuck", "update", "", "")
+
+	now = now.Add(updateStateRetention + time.Hour)
+	s.Start("new", "update", "", "")
+
+	states := s.Query(nil, time.Time{})
+	if len(states) != 2 || states[0].ContainerID != "stuck" || states[1].ContainerID != "new" {
+		t.Fatalf("states = %+v, want the expired finished state pruned", states)
+	}
+	if states := s.Query(nil, now.Add(-time.Minute)); len(states) != 1 || states[0].ContainerID != "new" {
+		t.Errorf("since filter: states = %+v", states)
+	}
+
+	var none *UpdateStateStore
+	none.Start("x", "update", "", "")
+	if states := none.Query(nil, time.Time{}); len(states) != 0 {
+		t.Errorf("nil store returned %+v", states)
+	}
+}
+
+func TestFinishGroupStates(t *testing.T) {
+	h := &UpdateHandler{
+		log:    logrus.New(),
+		states: NewUpdateStateStore(filepath.Join(t.TempDir(), "updates.json"), logrus.New()),
+	}
+	req := UpdateGroupRequest{
+		GroupID: "g1",
+		Members: []update.GroupMember{{ContainerID: "db"}, {ContainerID: "web"}, {ContainerID: "worker"}, {ContainerID: "ghost"}},
+	}
+	for _, m := range req.Members {
+		h.states.Start(m.ContainerID, "group", req.GroupID, "")
+	}
+
+	h.finishGroupStates(req, &update.GroupUpdateResult{
+		GroupID: "g1",
+		Error:   "web failed: unhealthy",
+		Plan: []update.GroupPlanEntry{
+			{ContainerID: "db", Status: update.GroupStatusRolledBack},
+			{ContainerID: "web", Status: update.GroupStatusFailed, Error: "unhealthy"},
+			{ContainerID: "worker", Status: update.GroupStatusSkipped},
+		},
+	})
+
+	want := map[string]string{
+		"db":     UpdateStatusRolledBack,
+		"web":    UpdateStatusFailed,
+		"worker": UpdateStatusSkipped,
+		"ghost":  UpdateStatusFailed,
+	}
+	for _, state := range h.states.Query(nil, time.Time{}) {
+		if state.Status != want[state.ContainerID] {
+			t.Errorf("%s: status = %s, want %s", state.ContainerID, state.Status, want[state.ContainerID])
+		}
+		if state.ContainerID == "web" && state.Error != "unhealthy" {
+			t.Errorf("web: error = %q, want the member error", state.Error)
+		}
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	var gotType string
+	var got map[string]interface{}
+	h := &UpdateHandler{
+		log: logrus.New(),
+		sendEvent: func(msgType string, payload interface{}) error {
+			gotType, got = msgType, payload.(map[string]interface{})
+			return nil
+		},
+	}
+
+	h.sendFailure("abcdef0123456789", &update.UpdateResult{
+		Error:      "new container unhealthy",
+		RolledBack: true,
+		Failure: &update.FailureForensics{
+			ExitCode: 1,
+			Logs:     "fatal: missing config\n",
+			Inspect:  &container.InspectResponse{},
+		},
+	})
+
+	if gotType != "update_failed" || got["container_id"] != "abcdef012345" || got["rolled_back"] != true || got["error"] != "new container unhealthy" {
+		t.Fatalf("sent %s %+v", gotType, got)
+	}
+	f, ok := got["failure"].(*update.FailureForensics)
+	if !ok || f.Logs != "fatal: missing config\n" || f.Inspect != nil {
+		t.Errorf("failure = %+v, want the forensics without the inspect output", got["failure"])
+	}
+}
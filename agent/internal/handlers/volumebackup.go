@@ -0,0 +1,758 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// volumeChunkSize is the raw size of each chunk streamed over the
+	// WebSocket (base64 adds a third on top)
+	volumeChunkSize = 256 * 1024
+
+	// maxPendingRestoreChunks bounds how many out-of-order chunks are held
+	// while waiting for a gap to fill
+	maxPendingRestoreChunks = 64
+
+	// volumeRestoreIdleTimeout aborts a WebSocket restore when the backend
+	// stops sending chunks
+	volumeRestoreIdleTimeout = 5 * time.Minute
+
+	volumeHelperLabel     = "com.dockmon.helper"
+	volumeMountPoint      = "/volume"
+	volumeBackupExtension = ".tar.gz"
+)
+
+// volumeNamePattern matches Docker's own rule for named volumes
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// VolumeBackupRequest starts a backup of a named volume
+type VolumeBackupRequest struct {
+	BackupID    string `json:"backup_id"`
+	VolumeName  string `json:"volume_name"`
+	Destination string `json:"destination,omitempty"` // "websocket" (default) or "local"
+}
+
+// VolumeBackupResult is sent as the volume_backup_complete event
+type VolumeBackupResult struct {
+	BackupID    string   `json:"backup_id"`
+	VolumeName  string   `json:"volume_name"`
+	Destination string   `json:"destination"`
+	Success     bool     `json:"success"`
+	Path        string   `json:"path,omitempty"`
+	Size        int64    `json:"size"`
+	SHA256      string   `json:"sha256,omitempty"`
+	Chunks      int      `json:"chunks,omitempty"`
+	InUseBy     []string `json:"in_use_by,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// VolumeRestoreRequest starts a restore into a named volume. With the
+// "websocket" source the archive follows as restore_volume_chunk commands;
+// with "local" it is read from a file in the backup directory.
+type VolumeRestoreRequest struct {
+	RestoreID  string `json:"restore_id"`
+	VolumeName string `json:"volume_name"`
+	Source     string `json:"source,omitempty"`    // "websocket" (default) or "local"
+	FileName   string `json:"file_name,omitempty"` // local source only
+	SHA256     string `json:"sha256,omitempty"`    // verified before extracting a local file
+	Clear      bool   `json:"clear,omitempty"`     // empty the volume first
+	Force      bool   `json:"force,omitempty"`     // restore even if running containers use it
+}
+
+// VolumeRestoreChunk carries one piece of a WebSocket restore
+type VolumeRestoreChunk struct {
+	RestoreID string `json:"restore_id"`
+	Seq       int    `json:"seq"`
+	Data      string `json:"data"` // base64
+	Last      bool   `json:"last,omitempty"`
+}
+
+// VolumeRestoreResult is sent as the volume_restore_complete event
+type VolumeRestoreResult struct {
+	RestoreID  string `json:"restore_id"`
+	VolumeName string `json:"volume_name"`
+	Success    bool   `json:"success"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// VolumeSizeResult is the estimated size of a named volume
+type VolumeSizeResult struct {
+	VolumeName string   `json:"volume_name"`
+	SizeBytes  int64    `json:"size_bytes"`
+	InUseBy    []string `json:"in_use_by,omitempty"`
+}
+
+// volumeRestoreSession is a WebSocket restore waiting for chunks
+type volumeRestoreSession struct {
+	req    VolumeRestoreRequest
+	pw     *io.PipeWriter
+	hasher hash.Hash
+	size   int64
+	seq    *chunkSequencer
+	idle   *time.Timer
+	mu     sync.Mutex
+}
+
+// VolumeBackupHandler backs up and restores named volumes through a
+// short-lived helper container that mounts the volume and runs tar
+type VolumeBackupHandler struct {
+	dockerClient *docker.Client
+	log          *logrus.Logger
+	sendEvent    func(string, interface{}) error
+	backupDir    string
+	helperImage  string
+
+	restores   map[string]*volumeRestoreSession
+	restoresMu sync.Mutex
+}
+
+// NewVolumeBackupHandler creates a new volume backup handler
+func NewVolumeBackupHandler(dockerClient *docker.Client, log *logrus.Logger, sendEvent func(string, interface{}) error, backupDir, helperImage string) *VolumeBackupHandler {
+	return &VolumeBackupHandler{
+		dockerClient: dockerClient,
+		log:          log,
+		sendEvent:    sendEvent,
+		backupDir:    backupDir,
+		helperImage:  helperImage,
+		restores:     make(map[string]*volumeRestoreSession),
+	}
+}
+
+// Backup streams a gzipped tar of the volume to the requested destination
+// and reports the outcome as a volume_backup_complete event
+func (h *VolumeBackupHandler) Backup(ctx context.Context, req VolumeBackupRequest) *VolumeBackupResult {
+	if req.Destination == "" {
+		req.Destination = "websocket"
+	}
+	result := &VolumeBackupResult{
+		BackupID:    req.BackupID,
+		VolumeName:  req.VolumeName,
+		Destination: req.Destination,
+	}
+
+	err := h.backup(ctx, req, result)
+	if err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).WithField("volume", req.VolumeName).Error("Volume backup failed")
+	} else {
+		result.Success = true
+		h.log.WithFields(logrus.Fields{
+			"volume": req.VolumeName,
+			"size":   result.Size,
+		}).Info("Volume backup completed")
+	}
+
+	if sendErr := h.sendEvent("volume_backup_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send volume backup result")
+	}
+	return result
+}
+
+func (h *VolumeBackupHandler) backup(ctx context.Context, req VolumeBackupRequest, result *VolumeBackupResult) error {
+	if err := validateVolumeName(req.VolumeName); err != nil {
+		return err
+	}
+	if req.BackupID == "" {
+		return fmt.Errorf("backup_id is required")
+	}
+	inUse, err := h.volumeUsers(ctx, req.VolumeName)
+	if err != nil {
+		return err
+	}
+	result.InUseBy = inUse
+
+	hasher := sha256.New()
+	cmd := []string{"tar", "-czf", "-", "-C", volumeMountPoint, "."}
+
+	switch req.Destination {
+	case "websocket":
+		w := &chunkEventWriter{
+			eventType: "volume_backup_chunk",
+			idField:   "backup_id",
+			id:        req.BackupID,
+			sendEvent: h.sendEvent,
+		}
+		if err := h.runHelper(ctx, req.VolumeName, true, cmd, nil, io.MultiWriter(w, hasher)); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		result.Size = w.size
+		result.Chunks = w.seq
+
+	case "local":
+		if err := os.MkdirAll(h.backupDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		name := fmt.Sprintf("%s-%s%s", req.VolumeName, time.Now().UTC().Format("20060102-150405"), volumeBackupExtension)
+		path := filepath.Join(h.backupDir, name)
+		partial := path + ".partial"
+
+		f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // #nosec G304 -- name built from a validated volume name
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %w", err)
+		}
+		counter := &countingWriter{w: io.MultiWriter(f, hasher)}
+		runErr := h.runHelper(ctx, req.VolumeName, true, cmd, nil, counter)
+		closeErr := f.Close()
+		if runErr == nil {
+			runErr = closeErr
+		}
+		if runErr != nil {
+			_ = os.Remove(partial)
+			return runErr
+		}
+		if err := os.Rename(partial, path); err != nil {
+			_ = os.Remove(partial)
+			return fmt.Errorf("failed to finalize backup file: %w", err)
+		}
+		result.Path = path
+		result.Size = counter.n
+
+	default:
+		return fmt.Errorf("unknown backup destination: %s", req.Destination)
+	}
+
+	result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	return nil
+}
+
+// StartRestore validates a restore request. Local restores run to completion
+// before returning and always report a volume_restore_complete event;
+// WebSocket restores start a helper container and return once it is ready
+// for restore_volume_chunk commands.
+func (h *VolumeBackupHandler) StartRestore(ctx context.Context, req VolumeRestoreRequest) error {
+	if req.Source == "" {
+		req.Source = "websocket"
+	}
+
+	switch req.Source {
+	case "local":
+		hasher := sha256.New()
+		counter := &countingWriter{w: hasher}
+		err := h.restoreLocal(ctx, req, counter)
+		h.finishRestore(req, counter.n, hasher, err)
+		return err
+	case "websocket":
+		if err := h.checkRestoreTarget(ctx, req); err != nil {
+			return err
+		}
+		return h.startStreamRestore(req)
+	default:
+		return fmt.Errorf("unknown restore source: %s", req.Source)
+	}
+}
+
+// checkRestoreTarget refuses restores into missing volumes, and into volumes
+// that running containers are using unless forced
+func (h *VolumeBackupHandler) checkRestoreTarget(ctx context.Context, req VolumeRestoreRequest) error {
+	if err := validateVolumeName(req.VolumeName); err != nil {
+		return err
+	}
+	if req.RestoreID == "" {
+		return fmt.Errorf("restore_id is required")
+	}
+	if _, err := h.dockerClient.RawClient().VolumeInspect(ctx, req.VolumeName); err != nil {
+		return fmt.Errorf("volume %s not found: %w", req.VolumeName, err)
+	}
+	if req.Force {
+		return nil
+	}
+	inUse, err := h.volumeUsers(ctx, req.VolumeName)
+	if err != nil {
+		return err
+	}
+	if len(inUse) > 0 {
+		return fmt.Errorf("volume %s is in use by running containers: %s", req.VolumeName, strings.Join(inUse, ", "))
+	}
+	return nil
+}
+
+func (h *VolumeBackupHandler) restoreLocal(ctx context.Context, req VolumeRestoreRequest, counter *countingWriter) error {
+	if err := h.checkRestoreTarget(ctx, req); err != nil {
+		return err
+	}
+	path, err := h.localBackupPath(req.FileName)
+	if err != nil {
+		return err
+	}
+
+	if req.SHA256 != "" {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, req.SHA256) {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", req.FileName, req.SHA256, sum)
+		}
+	}
+
+	f, err := os.Open(path) // #nosec G304 -- confined to the backup directory by localBackupPath
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+
+	return h.runHelper(ctx, req.VolumeName, false, restoreCommand(req.Clear), io.TeeReader(f, counter), io.Discard)
+}
+
+func (h *VolumeBackupHandler) startStreamRestore(req VolumeRestoreRequest) error {
+	h.restoresMu.Lock()
+	if _, exists := h.restores[req.RestoreID]; exists {
+		h.restoresMu.Unlock()
+		return fmt.Errorf("restore %s already in progress", req.RestoreID)
+	}
+
+	pr, pw := io.Pipe()
+	session := &volumeRestoreSession{
+		req:    req,
+		pw:     pw,
+		hasher: sha256.New(),
+		seq:    newChunkSequencer(),
+	}
+	session.idle = time.AfterFunc(volumeRestoreIdleTimeout, func() {
+		h.abortRestore(req.RestoreID, fmt.Errorf("no restore chunk received for %s", volumeRestoreIdleTimeout))
+	})
+	h.restores[req.RestoreID] = session
+	h.restoresMu.Unlock()
+
+	// The helper consumes the pipe until the last chunk closes it; it runs on
+	// a background context so a reconnect doesn't kill a restore mid-stream
+	go func() {
+		err := h.runHelper(context.Background(), req.VolumeName, false, restoreCommand(req.Clear), pr, io.Discard)
+		_ = pr.CloseWithError(err)
+
+		h.restoresMu.Lock()
+		delete(h.restores, req.RestoreID)
+		h.restoresMu.Unlock()
+		session.idle.Stop()
+
+		session.mu.Lock()
+		size := session.size
+		session.mu.Unlock()
+		h.finishRestore(req, size, session.hasher, err)
+	}()
+
+	h.log.WithFields(logrus.Fields{
+		"restore_id": req.RestoreID,
+		"volume":     req.VolumeName,
+	}).Info("Volume restore started")
+	return nil
+}
+
+// WriteRestoreChunk feeds one chunk of a WebSocket restore to its helper.
+// Chunks may arrive out of order and are written by sequence number.
+func (h *VolumeBackupHandler) WriteRestoreChunk(chunk VolumeRestoreChunk) error {
+	h.restoresMu.Lock()
+	session, ok := h.restores[chunk.RestoreID]
+	h.restoresMu.Unlock()
+	if !ok {
+		return fmt.Errorf("restore %s not found", chunk.RestoreID)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(chunk.Data)
+	if err != nil {
+		h.abortRestore(chunk.RestoreID, fmt.Errorf("invalid chunk %d: %w", chunk.Seq, err))
+		return err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.idle.Reset(volumeRestoreIdleTimeout)
+
+	ready, complete, err := session.seq.Add(chunk.Seq, data, chunk.Last)
+	if err != nil {
+		_ = session.pw.CloseWithError(err)
+		return err
+	}
+	for _, part := range ready {
+		if _, err := session.pw.Write(part); err != nil {
+			return fmt.Errorf("failed to write restore data: %w", err)
+		}
+		session.hasher.Write(part)
+		session.size += int64(len(part))
+	}
+	if complete {
+		return session.pw.Close()
+	}
+	return nil
+}
+
+// abortRestore cancels a WebSocket restore. The helper sees the pipe error,
+// exits, and the completion event reports the reason.
+func (h *VolumeBackupHandler) abortRestore(restoreID string, reason error) {
+	h.restoresMu.Lock()
+	session, ok := h.restores[restoreID]
+	h.restoresMu.Unlock()
+	if ok {
+		_ = session.pw.CloseWithError(reason)
+	}
+}
+
+func (h *VolumeBackupHandler) finishRestore(req VolumeRestoreRequest, size int64, hasher hash.Hash, err error) {
+	result := &VolumeRestoreResult{
+		RestoreID:  req.RestoreID,
+		VolumeName: req.VolumeName,
+		Size:       size,
+	}
+	if size > 0 {
+		result.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	}
+	if err != nil {
+		result.Error = err.Error()
+		h.log.WithError(err).WithField("volume", req.VolumeName).Error("Volume restore failed")
+	} else {
+		result.Success = true
+		h.log.WithFields(logrus.Fields{
+			"volume": req.VolumeName,
+			"size":   size,
+		}).Info("Volume restore completed")
+	}
+	if sendErr := h.sendEvent("volume_restore_complete", result); sendErr != nil {
+		h.log.WithError(sendErr).Warn("Failed to send volume restore result")
+	}
+}
+
+// EstimateSize reports the disk usage of a volume, used to warn about large
+// backups before starting one
+func (h *VolumeBackupHandler) EstimateSize(ctx context.Context, volumeName string) (*VolumeSizeResult, error) {
+	if err := validateVolumeName(volumeName); err != nil {
+		return nil, err
+	}
+	inUse, err := h.volumeUsers(ctx, volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := h.runHelper(ctx, volumeName, true, []string{"du", "-sk", volumeMountPoint}, nil, &out); err != nil {
+		return nil, err
+	}
+	size, err := parseDuOutput(out.String())
+	if err != nil {
+		return nil, err
+	}
+	return &VolumeSizeResult{VolumeName: volumeName, SizeBytes: size, InUseBy: inUse}, nil
+}
+
+// volumeUsers returns the names of running containers that mount the volume
+func (h *VolumeBackupHandler) volumeUsers(ctx context.Context, volumeName string) ([]string, error) {
+	containers, err := h.dockerClient.RawClient().ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("volume", volumeName),
+			filters.Arg("status", "running"),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers using volume: %w", err)
+	}
+	var names []string
+	for _, c := range containers {
+		if c.Labels[volumeHelperLabel] != "" {
+			continue
+		}
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		} else if len(c.ID) >= 12 {
+			names = append(names, c.ID[:12])
+		}
+	}
+	return names, nil
+}
+
+// runHelper runs cmd in a throwaway container with the volume mounted at
+// /volume, streaming stdin in and stdout out through an attach connection.
+// Logging is disabled so archives never land in the daemon's log files.
+func (h *VolumeBackupHandler) runHelper(ctx context.Context, volumeName string, readOnly bool, cmd []string, stdin io.Reader, stdout io.Writer) error {
+	cli := h.dockerClient.RawClient()
+	helperImage := h.helperImage
+	if helperImage == "" {
+		helperImage = "busybox:stable"
+	}
+	if _, err := cli.ImageInspect(ctx, helperImage); err != nil {
+		reader, err := cli.ImagePull(ctx, helperImage, image.PullOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to pull volume helper image %s: %w", helperImage, err)
+		}
+		_, _ = io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+	withStdin := stdin != nil
+	resp, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:        helperImage,
+			Cmd:          cmd,
+			User:         "0:0",
+			Labels:       map[string]string{volumeHelperLabel: "volume-backup"},
+			AttachStdin:  withStdin,
+			AttachStdout: true,
+			AttachStderr: true,
+			OpenStdin:    withStdin,
+			StdinOnce:    withStdin,
+		},
+		&container.HostConfig{
+			Mounts: []mount.Mount{{
+				Type:     mount.TypeVolume,
+				Source:   volumeName,
+				Target:   volumeMountPoint,
+				ReadOnly: readOnly,
+			}},
+			NetworkMode: "none",
+			LogConfig:   container.LogConfig{Type: "none"},
+		},
+		nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create volume helper container: %w", err)
+	}
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = cli.ContainerRemove(removeCtx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	// Attach before starting so no output is lost
+	attach, err := cli.ContainerAttach(ctx, resp.ID, container.AttachOptions{
+		Stream: true,
+		Stdin:  withStdin,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach to volume helper container: %w", err)
+	}
+	defer attach.Close()
+
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start volume helper container: %w", err)
+	}
+
+	stdinErr := make(chan error, 1)
+	if withStdin {
+		go func() {
+			_, err := io.Copy(attach.Conn, stdin)
+			_ = attach.CloseWrite()
+			stdinErr <- err
+		}()
+	} else {
+		stdinErr <- nil
+	}
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(stdout, &stderr, attach.Reader); err != nil {
+		return fmt.Errorf("failed to read volume helper output: %w", err)
+	}
+
+	waitCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("volume helper container failed: %w", err)
+	case status := <-waitCh:
+		exitCode = status.StatusCode
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("volume helper exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	// Don't block on input the helper no longer reads; once tar exits
+	// cleanly the archive was complete
+	select {
+	case err := <-stdinErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+// localBackupPath resolves a backup file name inside the backup directory
+func (h *VolumeBackupHandler) localBackupPath(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid backup file name: %q", name)
+	}
+	if !strings.HasSuffix(name, volumeBackupExtension) {
+		return "", fmt.Errorf("backup file must end in %s", volumeBackupExtension)
+	}
+	return filepath.Join(h.backupDir, name), nil
+}
+
+// restoreCommand extracts the archive from stdin, optionally emptying the
+// volume first (hidden files included)
+func restoreCommand(clear bool) []string {
+	extract := "tar -xzf - -C " + volumeMountPoint
+	if !clear {
+		return strings.Fields(extract)
+	}
+	return []string{"sh", "-c", "rm -rf /volume/..?* /volume/.[!.]* /volume/* && exec " + extract}
+}
+
+func validateVolumeName(name string) error {
+	if !volumeNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid volume name: %q", name)
+	}
+	return nil
+}
+
+// parseDuOutput converts `du -sk` output into bytes
+func parseDuOutput(out string) (int64, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty du output")
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected du output: %q", strings.TrimSpace(out))
+	}
+	return kb * 1024, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path) // #nosec G304 -- caller confines path to the backup directory
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", fmt.Errorf("failed to read backup file: %w", err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// chunkEventWriter buffers a stream into fixed-size base64 chunk events,
+// keyed by idField so the backend can match them to the request
+type chunkEventWriter struct {
+	eventType string
+	idField   string
+	id        string
+	sendEvent func(string, interface{}) error
+	buf       []byte
+	seq       int
+	size      int64
+}
+
+func (w *chunkEventWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := volumeChunkSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		if len(w.buf) == volumeChunkSize {
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush sends any buffered data as a chunk
+func (w *chunkEventWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.sendEvent(w.eventType, map[string]interface{}{
+		w.idField: w.id,
+		"seq":     w.seq,
+		"data":    base64.StdEncoding.EncodeToString(w.buf),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send chunk %d: %w", w.seq, err)
+	}
+	w.size += int64(len(w.buf))
+	w.seq++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// chunkSequencer puts restore chunks back in order. Commands are handled
+// concurrently, so chunk N+1 can arrive before chunk N.
+type chunkSequencer struct {
+	next    int
+	last    int // sequence number of the final chunk, -1 until seen
+	pending map[int][]byte
+}
+
+func newChunkSequencer() *chunkSequencer {
+	return &chunkSequencer{last: -1, pending: make(map[int][]byte)}
+}
+
+// Add records a chunk and returns the data that can now be written in order,
+// and whether the stream is complete
+func (s *chunkSequencer) Add(seq int, data []byte, last bool) ([][]byte, bool, error) {
+	if seq < s.next {
+		return nil, false, fmt.Errorf("duplicate chunk %d", seq)
+	}
+	if _, dup := s.pending[seq]; dup {
+		return nil, false, fmt.Errorf("duplicate chunk %d", seq)
+	}
+	if s.last >= 0 && seq > s.last {
+		return nil, false, fmt.Errorf("chunk %d after final chunk %d", seq, s.last)
+	}
+	if last {
+		if s.last >= 0 {
+			return nil, false, fmt.Errorf("final chunk sent twice (%d and %d)", s.last, seq)
+		}
+		for pending := range s.pending {
+			if pending > seq {
+				return nil, false, fmt.Errorf("chunk %d after final chunk %d", pending, seq)
+			}
+		}
+		s.last = seq
+	}
+	if seq != s.next && len(s.pending) >= maxPendingRestoreChunks {
+		return nil, false, fmt.Errorf("too many out-of-order chunks waiting for %d", s.next)
+	}
+	s.pending[seq] = data
+
+	var ready [][]byte
+	for {
+		part, ok := s.pending[s.next]
+		if !ok {
+			break
+		}
+		delete(s.pending, s.next)
+		ready = append(ready, part)
+		s.next++
+	}
+	return ready, s.last >= 0 && s.next > s.last, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestChunkSequencer_InOrder(t *testing.T) {
+	s := newChunkSequencer()
+
+	ready, complete, err := s.Add(0, []byte("a"), false)
+	if err != nil || complete || len(ready) != 1 {
+		t.Fatalf("chunk 0: ready=%d complete=%v err=%v", len(ready), complete, err)
+	}
+	ready, complete, err = s.Add(1, []byte("b"), true)
+	if err != nil || !complete || len(ready) != 1 {
+		t.Fatalf("chunk 1: ready=%d complete=%v err=%v", len(ready), complete, err)
+	}
+}
+
+func TestChunkSequencer_OutOfOrder(t *testing.T) {
+	s := newChunkSequencer()
+
+	var got []byte
+	add := func(seq int, data string, last bool) bool {
+		t.Helper()
+		ready, complete, err := s.Add(seq, []byte(data), last)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", seq, err)
+		}
+		for _, part := range ready {
+			got = append(got, part...)
+		}
+		return complete
+	}
+
+	if add(2, "c", true) {
+		t.Fatal("complete before chunks 0 and 1")
+	}
+	if add(1, "b", false) {
+		t.Fatal("complete before chunk 0")
+	}
+	if len(got) != 0 {
+		t.Fatalf("wrote %q before chunk 0 arrived", got)
+	}
+	if !add(0, "a", false) {
+		t.Fatal("expected complete after filling the gap")
+	}
+	if string(got) != "abc" {
+		t.Errorf("got %q, want %q", got, "abc")
+	}
+}
+
+func TestChunkSequencer_Rejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *chunkSequencer)
+		seq   int
+		last  bool
+	}{
+		{
+			name:  "already written",
+			setup: func(s *chunkSequencer) { _, _, _ = s.Add(0, nil, false) },
+			seq:   0,
+		},
+		{
+			name:  "duplicate pending",
+			setup: func(s *chunkSequencer) { _, _, _ = s.Add(3, nil, false) },
+			seq:   3,
+		},
+		{
+			name:  "after final chunk",
+			setup: func(s *chunkSequencer) { _, _, _ = s.Add(2, nil, true) },
+			seq:   3,
+		},
+		{
+			name:  "final chunk before pending",
+			setup: func(s *chunkSequencer) { _, _, _ = s.Add(5, nil, false) },
+			seq:   4,
+			last:  true,
+		},
+		{
+			name: "too many pending",
+			setup: func(s *chunkSequencer) {
+				for i := 1; i <= maxPendingRestoreChunks; i++ {
+					_, _, _ = s.Add(i, nil, false)
+				}
+			},
+			seq: maxPendingRestoreChunks + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newChunkSequencer()
+			tt.setup(s)
+			if _, _, err := s.Add(tt.seq, nil, tt.last); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestChunkSequencer_GapFillWhenFull(t *testing.T) {
+	s := newChunkSequencer()
+	for i := 1; i <= maxPendingRestoreChunks; i++ {
+		if _, _, err := s.Add(i, nil, false); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+	ready, _, err := s.Add(0, nil, false)
+	if err != nil {
+		t.Fatalf("chunk 0 must always be accepted: %v", err)
+	}
+	if len(ready) != maxPendingRestoreChunks+1 {
+		t.Errorf("ready = %d, want %d", len(ready), maxPendingRestoreChunks+1)
+	}
+}
+
+func TestChunkEventWriter(t *testing.T) {
+	var chunks []map[string]interface{}
+	w := &chunkEventWriter{
+		eventType: "volume_backup_chunk",
+		idField:   "backup_id",
+		id:        "b1",
+		sendEvent: func(eventType string, payload interface{}) error {
+			if eventType != "volume_backup_chunk" {
+				t.Errorf("event type = %s", eventType)
+			}
+			chunks = append(chunks, payload.(map[string]interface{}))
+			return nil
+		},
+	}
+
+	data := bytes.Repeat([]byte("x"), volumeChunkSize*2+10)
+	if _, err := w.Write(data[:100]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data[100:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("chunks = %d, want 3", len(chunks))
+	}
+	var joined []byte
+	for i, c := range chunks {
+		if c["seq"] != i || c["backup_id"] != "b1" {
+			t.Errorf("chunk %d: seq=%v backup_id=%v", i, c["seq"], c["backup_id"])
+		}
+		decoded, err := base64.StdEncoding.DecodeString(c["data"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		joined = append(joined, decoded...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Error("reassembled chunks differ from input")
+	}
+	if w.size != int64(len(data)) || w.seq != 3 {
+		t.Errorf("size=%d seq=%d", w.size, w.seq)
+	}
+}
+
+func TestChunkEventWriter_SendError(t *testing.T) {
+	w := &chunkEventWriter{
+		eventType: "volume_backup_chunk",
+		idField:   "backup_id",
+		id:        "b1",
+		sendEvent: func(string, interface{}) error { return fmt.Errorf("disconnected") },
+	}
+	if _, err := w.Write(make([]byte, volumeChunkSize)); err == nil {
+		t.Error("expected send error to surface from Write")
+	}
+}
+
+func TestValidateVolumeName(t *testing.T) {
+	for _, name := range []string{"data", "my_app-db.1", "0abc"} {
+		if err := validateVolumeName(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a", "../etc", "vol/sub", "-flag", ".hidden"} {
+		if err := validateVolumeName(name); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func TestLocalBackupPath(t *testing.T) {
+	h := &VolumeBackupHandler{backupDir: "/data/backups"}
+
+	path, err := h.localBackupPath("db-20260101-000000.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/data/backups", "db-20260101-000000.tar.gz"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	for _, name := range []string{"", "..", "../secret.tar.gz", "sub/db.tar.gz", "db.tar"} {
+		if _, err := h.localBackupPath(name); err == nil {
+			t.Errorf("%q: expected error", name)
+		}
+	}
+}
+
+func TestParseDuOutput(t *testing.T) {
+	size, err := parseDuOutput("1234\t/volume\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 1234*1024 {
+		t.Errorf("size = %d", size)
+	}
+	if _, err := parseDuOutput(""); err == nil {
+		t.Error("expected error for empty output")
+	}
+	if _, err := parseDuOutput("du: can't open"); err == nil {
+		t.Error("expected error for non-numeric output")
+	}
+}
+
+func TestRestoreCommand(t *testing.T) {
+	if got := restoreCommand(false); len(got) != 5 || got[0] != "tar" {
+		t.Errorf("restoreCommand(false) = %v", got)
+	}
+	got := restoreCommand(true)
+	if len(got) != 3 || got[0] != "sh" {
+		t.Fatalf("restoreCommand(true) = %v", got)
+	}
+}
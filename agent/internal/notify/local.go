@@ -0,0 +1,252 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/crashloop"
+	"github.com/docker/docker/api/types/events"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cooldown suppresses repeats of the same event (same container and
+	// kind) so a flapping container doesn't flood the channel
+	cooldown = 30 * time.Minute
+	// flushInterval is how often held notifications are checked against the
+	// outage threshold
+	flushInterval = 15 * time.Second
+	// maxPending bounds the notifications held during an outage
+	maxPending = 50
+)
+
+// WatchFunc opens a Docker event stream (docker.Client.WatchEvents)
+type WatchFunc func(ctx context.Context) (<-chan events.Message, <-chan error)
+
+// LocalNotifier sends critical events directly from the host when the
+// WebSocket to DockMon has been down longer than the configured threshold.
+// Events that happen during a shorter outage are held and dropped on
+// reconnect, since DockMon is reachable again. All methods are safe to call
+// on a nil *LocalNotifier (local notifications disabled).
+type LocalNotifier struct {
+	sender Sender
+	kinds  map[Kind]bool
+	after  time.Duration
+	host   string
+	log    *logrus.Logger
+	now    func() time.Time
+
+	mu        sync.Mutex
+	connected bool
+	downSince time.Time
+	pending   []Notification
+	lastSent  map[string]time.Time // dedup key → when last queued
+
+	// crashLoops counts deaths on the notifier's own event stream, since
+	// the WebSocket client's stops with the connection. It uses the same
+	// CRASH_LOOP_THRESHOLD and CRASH_LOOP_WINDOW.
+	crashLoops *crashloop.Detector
+}
+
+// New creates the local notifier configured in cfg. Returns nil (disabled)
+// if no notification URL is set.
+func New(cfg *config.Config, host string, log *logrus.Logger) (*LocalNotifier, error) {
+	if cfg.LocalNotifyURL == "" {
+		return nil, nil
+	}
+
+	sender, err := NewSender(cfg.LocalNotifyType, cfg.LocalNotifyURL, cfg.LocalNotifyToken)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[Kind]bool, len(cfg.LocalNotifyEvents))
+	for _, name := range cfg.LocalNotifyEvents {
+		kind := Kind(strings.ToLower(name))
+		switch kind {
+		case KindOOM, KindCrashLoop, KindUpdateFailed:
+			kinds[kind] = true
+		default:
+			return nil, fmt.Errorf("unknown LOCAL_NOTIFY_EVENTS entry %q (want oom, crash_loop or update_failed)", name)
+		}
+	}
+
+	crashLoops := crashloop.New(cfg.CrashLoopThreshold, cfg.CrashLoopWindow)
+	return newLocalNotifier(sender, kinds, cfg.LocalNotifyAfter, host, log, time.Now, crashLoops), nil
+}
+
+func newLocalNotifier(sender Sender, kinds map[Kind]bool, after time.Duration, host string, log *logrus.Logger, now func() time.Time, crashLoops *crashloop.Detector) *LocalNotifier {
+	return &LocalNotifier{
+		sender:     sender,
+		kinds:      kinds,
+		after:      after,
+		host:       host,
+		log:        log,
+		now:        now,
+		downSince:  now(), // Not connected until the first registration
+		lastSent:   make(map[string]time.Time),
+		crashLoops: crashLoops,
+	}
+}
+
+// SetConnected records the WebSocket state. Reconnecting drops held
+// notifications.
+func (n *LocalNotifier) SetConnected(connected bool) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if connected {
+		n.pending = nil
+	} else if n.connected {
+		n.downSince = n.now()
+	}
+	n.connected = connected
+}
+
+// Notify queues a critical event while disconnected. key identifies the
+// event's subject for deduplication (e.g. "oom:<container id>").
+func (n *LocalNotifier) Notify(kind Kind, key, title, message string) {
+	if n == nil || !n.kinds[kind] {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.connected {
+		return // DockMon sees the event itself
+	}
+
+	now := n.now()
+	if last, ok := n.lastSent[key]; ok && now.Sub(last) < cooldown {
+		return
+	}
+	if len(n.pending) >= maxPending {
+		return
+	}
+	n.lastSent[key] = now
+	n.pending = append(n.pending, Notification{
+		Kind:      kind,
+		Host:      n.host,
+		Title:     title,
+		Message:   message,
+		Timestamp: now,
+	})
+}
+
+// Run watches Docker events for OOM kills and crash loops and delivers held
+// notifications once the outage exceeds the threshold. Blocks until ctx is
+// cancelled.
+func (n *LocalNotifier) Run(ctx context.Context, watch WatchFunc) {
+	if n == nil {
+		return
+	}
+
+	go n.watchEvents(ctx, watch)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.flush(ctx)
+		}
+	}
+}
+
+// flush sends the held notifications if the WebSocket has been down long
+// enough.
+func (n *LocalNotifier) flush(ctx context.Context) {
+	n.mu.Lock()
+	if n.connected || len(n.pending) == 0 || n.now().Sub(n.downSince) < n.after {
+		n.mu.Unlock()
+		return
+	}
+	batch := n.pending
+	n.pending = nil
+	n.mu.Unlock()
+
+	for _, notification := range batch {
+		sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		err := n.sender.Send(sendCtx, notification)
+		cancel()
+		if err != nil {
+			n.log.WithError(err).WithField("kind", notification.Kind).Warn("Failed to send local notification")
+			continue
+		}
+		n.log.WithField("kind", notification.Kind).Info("Sent local notification")
+	}
+}
+
+// watchEvents follows the Docker event stream, reopening it after errors.
+func (n *LocalNotifier) watchEvents(ctx context.Context, watch WatchFunc) {
+	for {
+		eventChan, errChan := watch(ctx)
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errChan:
+				if ctx.Err() == nil {
+					n.log.WithError(err).Debug("Local notifier event stream error, reconnecting")
+				}
+				break stream
+			case event := <-eventChan:
+				n.HandleEvent(event)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// HandleEvent turns container OOM kills and repeated crashes into
+// notifications.
+func (n *LocalNotifier) HandleEvent(event events.Message) {
+	if n == nil || event.Type != events.ContainerEventType {
+		return
+	}
+
+	id := event.Actor.ID
+	shortID := id
+	if len(shortID) > 12 {
+		shortID = shortID[:12]
+	}
+	name := event.Actor.Attributes["name"]
+	if name == "" {
+		name = shortID
+	}
+
+	switch event.Action {
+	case events.ActionOOM:
+		n.Notify(KindOOM, "oom:"+id,
+			fmt.Sprintf("Container %s OOM killed on %s", name, n.host),
+			fmt.Sprintf("Container %s (%s) on %s was killed by the kernel OOM killer.", name, shortID, n.host))
+
+	case events.ActionDie:
+		exitCode := event.Actor.Attributes["exitCode"]
+		if loop, started := n.crashLoops.RecordDeath(id, name, exitCode); started {
+			n.Notify(KindCrashLoop, "crash_loop:"+id,
+				fmt.Sprintf("Container %s is crash looping on %s", name, n.host),
+				fmt.Sprintf("Container %s (%s) on %s died %d times within %s (last exit code %s).",
+					name, shortID, n.host, loop.Deaths, time.Duration(loop.WindowSeconds)*time.Second, exitCode))
+		}
+
+	case events.ActionDestroy:
+		n.crashLoops.Forget(id)
+	}
+}
@@ -0,0 +1,269 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/config"
+	"github.com/darthnorse/dockmon-agent/internal/crashloop"
+	"github.com/docker/docker/api/types/events"
+	"github.com/sirupsen/logrus"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (s *recordingSender) Send(ctx context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, n)
+	return nil
+}
+
+// Crash loop settings of the test notifier
+const (
+	testCrashLoopThreshold = 3
+	testCrashLoopWindow    = 5 * time.Minute
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestNotifier(after time.Duration) (*LocalNotifier, *recordingSender, *fakeClock) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+	sender := &recordingSender{}
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	kinds := map[Kind]bool{KindOOM: true, KindCrashLoop: true, KindUpdateFailed: true}
+	crashLoops := crashloop.New(testCrashLoopThreshold, testCrashLoopWindow)
+	return newLocalNotifier(sender, kinds, after, "host-a", log, clock.now, crashLoops), sender, clock
+}
+
+func oomEvent(id string) events.Message {
+	return events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionOOM,
+		Actor:  events.Actor{ID: id, Attributes: map[string]string{"name": "web"}},
+	}
+}
+
+func dieEvent(id, exitCode string) events.Message {
+	return events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionDie,
+		Actor:  events.Actor{ID: id, Attributes: map[string]string{"name": "web", "exitCode": exitCode}},
+	}
+}
+
+func TestLocalNotifier_SendsAfterThreshold(t *testing.T) {
+	n, sender, clock := newTestNotifier(5 * time.Minute)
+	n.SetConnected(true)
+	n.SetConnected(false)
+
+	n.HandleEvent(oomEvent("abc"))
+
+	// Outage still shorter than the threshold: held
+	clock.t = clock.t.Add(time.Minute)
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Fatalf("sent %d notifications before threshold, want 0", len(sender.sent))
+	}
+
+	clock.t = clock.t.Add(5 * time.Minute)
+	n.flush(context.Background())
+	if len(sender.sent) != 1 || sender.sent[0].Kind != KindOOM {
+		t.Fatalf("sent %+v, want one OOM notification", sender.sent)
+	}
+	if sender.sent[0].Host != "host-a" {
+		t.Errorf("Host = %q, want host-a", sender.sent[0].Host)
+	}
+}
+
+func TestLocalNotifier_ReconnectDropsHeld(t *testing.T) {
+	n, sender, clock := newTestNotifier(time.Minute)
+
+	n.HandleEvent(oomEvent("abc"))
+	n.SetConnected(true)
+
+	clock.t = clock.t.Add(time.Hour)
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications after reconnect, want 0", len(sender.sent))
+	}
+
+	// Events while connected are left to DockMon
+	n.HandleEvent(oomEvent("def"))
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications while connected, want 0", len(sender.sent))
+	}
+}
+
+func TestLocalNotifier_CrashLoop(t *testing.T) {
+	n, sender, _ := newTestNotifier(0)
+
+	// Like the crash_loop event, every death counts, clean exits included
+	n.HandleEvent(dieEvent("abc", "0"))
+	for i := 0; i < testCrashLoopThreshold-1; i++ {
+		n.HandleEvent(dieEvent("abc", "1"))
+	}
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Fatalf("sent %d notifications at the crash threshold, want 0", len(sender.sent))
+	}
+
+	n.HandleEvent(dieEvent("abc", "137"))
+	n.flush(context.Background())
+	if len(sender.sent) != 1 || sender.sent[0].Kind != KindCrashLoop {
+		t.Fatalf("sent %+v, want one crash loop notification", sender.sent)
+	}
+	if want := "died 4 times within 5m0s (last exit code 137)"; !strings.Contains(sender.sent[0].Message, want) {
+		t.Errorf("Message = %q, want it to contain %q", sender.sent[0].Message, want)
+	}
+}
+
+func TestLocalNotifier_CrashesOutsideWindowDontCount(t *testing.T) {
+	n, sender, _ := newTestNotifier(0)
+	n.crashLoops = crashloop.New(1, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		n.HandleEvent(dieEvent("abc", "1"))
+		time.Sleep(20 * time.Millisecond)
+	}
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications for spread-out crashes, want 0", len(sender.sent))
+	}
+}
+
+func TestLocalNotifier_DestroyForgetsCrashes(t *testing.T) {
+	n, sender, _ := newTestNotifier(0)
+
+	for i := 0; i < testCrashLoopThreshold; i++ {
+		n.HandleEvent(dieEvent("abc", "1"))
+	}
+	n.HandleEvent(events.Message{Type: events.ContainerEventType, Action: events.ActionDestroy, Actor: events.Actor{ID: "abc"}})
+	n.HandleEvent(dieEvent("abc", "1"))
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications after the container was removed, want 0", len(sender.sent))
+	}
+}
+
+func TestLocalNotifier_Cooldown(t *testing.T) {
+	n, sender, clock := newTestNotifier(0)
+
+	n.HandleEvent(oomEvent("abc"))
+	n.HandleEvent(oomEvent("abc"))
+	n.flush(context.Background())
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1 (repeat suppressed)", len(sender.sent))
+	}
+
+	clock.t = clock.t.Add(cooldown)
+	n.HandleEvent(oomEvent("abc"))
+	n.flush(context.Background())
+	if len(sender.sent) != 2 {
+		t.Errorf("sent %d notifications, want 2 after cooldown", len(sender.sent))
+	}
+}
+
+func TestLocalNotifier_DisabledKind(t *testing.T) {
+	n, sender, _ := newTestNotifier(0)
+	n.kinds = map[Kind]bool{KindUpdateFailed: true}
+
+	n.HandleEvent(oomEvent("abc"))
+	n.flush(context.Background())
+	if len(sender.sent) != 0 {
+		t.Errorf("sent %d notifications for a disabled kind, want 0", len(sender.sent))
+	}
+}
+
+func TestLocalNotifier_NilIsDisabled(t *testing.T) {
+	var n *LocalNotifier
+	n.SetConnected(true)
+	n.Notify(KindUpdateFailed, "k", "title", "message")
+	n.HandleEvent(oomEvent("abc"))
+}
+
+func TestNew(t *testing.T) {
+	log := logrus.New()
+
+	if n, err := New(&config.Config{}, "h", log); n != nil || err != nil {
+		t.Errorf("New() without URL = %v, %v; want disabled", n, err)
+	}
+
+	cfg := &config.Config{LocalNotifyURL: "https://ntfy.sh/t", LocalNotifyType: "ntfy", LocalNotifyEvents: []string{"oom", "bogus"}}
+	if _, err := New(cfg, "h", log); err == nil {
+		t.Error("Expected error for unknown event kind")
+	}
+
+	cfg = &config.Config{LocalNotifyURL: "https://gotify.example", LocalNotifyType: "gotify"}
+	if _, err := New(cfg, "h", log); err == nil {
+		t.Error("Expected error for gotify without token")
+	}
+
+	cfg = &config.Config{LocalNotifyURL: "https://example.com", LocalNotifyType: "pager"}
+	if _, err := New(cfg, "h", log); err == nil {
+		t.Error("Expected error for unknown notification type")
+	}
+}
+
+func TestSenders(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	n := Notification{Kind: KindOOM, Host: "h", Title: "Container OOM", Message: "details"}
+
+	webhook, _ := NewSender("webhook", server.URL, "")
+	if err := webhook.Send(context.Background(), n); err != nil {
+		t.Fatalf("webhook Send: %v", err)
+	}
+	var decoded Notification
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Title != "Container OOM" {
+		t.Errorf("webhook body = %s", body)
+	}
+
+	ntfy, _ := NewSender("ntfy", server.URL, "tk")
+	if err := ntfy.Send(context.Background(), n); err != nil {
+		t.Fatalf("ntfy Send: %v", err)
+	}
+	if got.Header.Get("Title") != "Container OOM" || string(body) != "details" || got.Header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("ntfy request: title=%q body=%q auth=%q", got.Header.Get("Title"), body, got.Header.Get("Authorization"))
+	}
+
+	gotify, _ := NewSender("gotify", server.URL+"/", "app-token")
+	if err := gotify.Send(context.Background(), n); err != nil {
+		t.Fatalf("gotify Send: %v", err)
+	}
+	if got.URL.Path != "/message" || got.Header.Get("X-Gotify-Key") != "app-token" {
+		t.Errorf("gotify request: path=%q key=%q", got.URL.Path, got.Header.Get("X-Gotify-Key"))
+	}
+}
+
+func TestSenderReportsHTTPErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	sender, _ := NewSender("webhook", server.URL, "")
+	if err := sender.Send(context.Background(), Notification{}); err == nil {
+		t.Error("Expected error for 403 response")
+	}
+}
@@ -0,0 +1,142 @@
+// Package notify sends critical host events straight from the agent to a
+// notification service while DockMon is unreachable.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kind identifies a class of critical event
+type Kind string
+
+const (
+	KindOOM          Kind = "oom"
+	KindCrashLoop    Kind = "crash_loop"
+	KindUpdateFailed Kind = "update_failed"
+)
+
+// Notification is a single message to deliver
+type Notification struct {
+	Kind      Kind      `json:"kind"`
+	Host      string    `json:"host"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Sender delivers notifications to a notification service
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// NewSender returns the sender for senderType: "webhook" (JSON POST of the
+// Notification), "ntfy" (topic URL) or "gotify" (server URL). token is the
+// ntfy access token or Gotify application token.
+func NewSender(senderType, url, token string) (Sender, error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	switch strings.ToLower(senderType) {
+	case "webhook", "":
+		return &webhookSender{client: httpClient, url: url, token: token}, nil
+	case "ntfy":
+		return &ntfySender{client: httpClient, url: url, token: token}, nil
+	case "gotify":
+		if token == "" {
+			return nil, fmt.Errorf("gotify requires an application token")
+		}
+		return &gotifySender{client: httpClient, url: strings.TrimRight(url, "/"), token: token}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification type %q (want webhook, ntfy or gotify)", senderType)
+	}
+}
+
+// webhookSender posts the notification as JSON
+type webhookSender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *webhookSender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return do(s.client, req)
+}
+
+// ntfySender publishes to an ntfy topic URL
+type ntfySender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *ntfySender) Send(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", n.Title)
+	req.Header.Set("Priority", "urgent")
+	req.Header.Set("Tags", "warning,"+string(n.Kind))
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return do(s.client, req)
+}
+
+// gotifySender posts to a Gotify server's message endpoint
+type gotifySender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *gotifySender) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Message,
+		"priority": 8,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/message", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", s.token)
+	return do(s.client, req)
+}
+
+// do sends req and treats any non-2xx status as an error
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification service returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/darthnorse/dockmon-agent/pkg/types"
+)
+
+// Chunked command responses
+//
+// A response whose encoded JSON exceeds ChunkThreshold is sent as
+// "response_chunk" messages when DockMon negotiated FeatureResponseChunks:
+//
+//	{"type": "response_chunk", "id": "<command id>",
+//	 "payload": {"seq": 0, "data": "{\"type\":\"response\",\"id\":..."}}
+//	...
+//	{"type": "response_chunk", "id": "<command id>",
+//	 "payload": {"seq": 7, "data": "...}", "final": true, "chunks": 8, "size": 1843200}}
+//
+// To reassemble, buffer the data of each command ID's chunks in seq order
+// (seq starts at 0 and increases by one; chunks arrive in order on the one
+// connection). The final chunk terminates the sequence: check its chunks
+// count and size (bytes of the joined data) against what was received, then
+// decode the joined data as the response message. Receivers should cap the
+// bytes buffered per response at MaxResponseSize and give up on sequences
+// that stop arriving, since a dropped frame is never resent.
+//
+// Responses larger than MaxResponseSize are not sent at all; the command
+// fails with ErrorCodeResponseTooLarge instead.
+
+const (
+	// FeatureResponseChunks is negotiated by backends that reassemble
+	// response_chunk messages. It adds no event types.
+	FeatureResponseChunks = "response_chunks"
+
+	// ChunkThreshold is the encoded size above which a response is chunked
+	ChunkThreshold = 1 << 20
+	// ChunkSize is the most response bytes one chunk carries
+	ChunkSize = 256 << 10
+	// MaxResponseSize is the largest encoded response sent, chunked or not
+	MaxResponseSize = 64 << 20
+
+	// ErrorCodeResponseTooLarge marks a response replaced because it
+	// exceeded MaxResponseSize
+	ErrorCodeResponseTooLarge = "RESPONSE_TOO_LARGE"
+)
+
+// ErrResponseTooLarge is wrapped by the error replacing an oversized response
+var ErrResponseTooLarge = errors.New("response too large")
+
+// ResponseChunk is the payload of a response_chunk message
+type ResponseChunk struct {
+	Seq  int    `json:"seq"`
+	Data string `json:"data"` // A slice of the response JSON
+	// Set on the last chunk only
+	Final  bool `json:"final,omitempty"`
+	Chunks int  `json:"chunks,omitempty"` // Number of chunks sent
+	Size   int  `json:"size,omitempty"`   // Bytes of the response JSON
+}
+
+// ChunkResponse splits an encoded response into response_chunk messages
+// carrying at most size bytes each. Splits fall on UTF-8 character
+// boundaries, so every chunk's data is valid text.
+func ChunkResponse(commandID string, data []byte, size int) []*types.Message {
+	if size < utf8.UTFMax {
+		size = utf8.UTFMax
+	}
+	var chunks []*types.Message
+	for offset := 0; offset < len(data) || len(chunks) == 0; {
+		end := offset + size
+		if end >= len(data) {
+			end = len(data)
+		} else {
+			for end > offset && !utf8.RuneStart(data[end]) {
+				end--
+			}
+			if end == offset { // Not UTF-8; split anywhere
+				end = offset + size
+			}
+		}
+		chunks = append(chunks, &types.Message{
+			Type:    "response_chunk",
+			ID:      commandID,
+			Payload: ResponseChunk{Seq: len(chunks), Data: string(data[offset:end])},
+		})
+		offset = end
+	}
+
+	last := chunks[len(chunks)-1].Payload.(ResponseChunk)
+	last.Final = true
+	last.Chunks = len(chunks)
+	last.Size = len(data)
+	chunks[len(chunks)-1].Payload = last
+	return chunks
+}
@@ -0,0 +1,75 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkResponse(t *testing.T) {
+	// Multi-byte characters straddle every chunk boundary
+	data := []byte(`{"type":"response","id":"cmd-1","payload":"` + strings.Repeat("é€", 100) + `"}`)
+	chunks := ChunkResponse("cmd-1", data, 16)
+
+	var joined strings.Builder
+	for i, msg := range chunks {
+		chunk := msg.Payload.(ResponseChunk)
+		if msg.Type != "response_chunk" || msg.ID != "cmd-1" || chunk.Seq != i {
+			t.Fatalf("chunk %d = %+v", i, msg)
+		}
+		if len(chunk.Data) > 16 || !utf8.ValidString(chunk.Data) {
+			t.Errorf("chunk %d data %q: too long or split inside a character", i, chunk.Data)
+		}
+		if chunk.Final != (i == len(chunks)-1) {
+			t.Errorf("chunk %d Final = %v", i, chunk.Final)
+		}
+		joined.WriteString(chunk.Data)
+	}
+	last := chunks[len(chunks)-1].Payload.(ResponseChunk)
+	if last.Chunks != len(chunks) || last.Size != len(data) {
+		t.Errorf("final chunk = %+v, want %d chunks of %d bytes", last, len(chunks), len(data))
+	}
+	if joined.String() != string(data) {
+		t.Errorf("reassembled = %q", joined.String())
+	}
+}
+
+func TestChunkResponse_JSONRoundTrip(t *testing.T) {
+	data := []byte(`{"payload":"` + strings.Repeat("a", 40) + `"}`)
+	var joined string
+	for _, msg := range ChunkResponse("cmd-2", data, 10) {
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded struct {
+			Payload ResponseChunk `json:"payload"`
+		}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		joined += decoded.Payload.Data
+	}
+	if joined != string(data) {
+		t.Errorf("reassembled = %q", joined)
+	}
+}
+
+func TestChunkResponse_Empty(t *testing.T) {
+	chunks := ChunkResponse("cmd-3", nil, ChunkSize)
+	if len(chunks) != 1 {
+		t.Fatalf("got %d chunks, want 1", len(chunks))
+	}
+	if chunk := chunks[0].Payload.(ResponseChunk); !chunk.Final || chunk.Chunks != 1 || chunk.Size != 0 {
+		t.Errorf("chunk = %+v", chunk)
+	}
+}
+
+func TestErrorCode_ResponseTooLarge(t *testing.T) {
+	err := fmt.Errorf("%w: 70000000 bytes", ErrResponseTooLarge)
+	if got := ErrorCode(err); got != ErrorCodeResponseTooLarge {
+		t.Errorf("ErrorCode = %q, want %q", got, ErrorCodeResponseTooLarge)
+	}
+}
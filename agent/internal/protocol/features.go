@@ -0,0 +1,163 @@
+package protocol
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the protocol version this agent speaks
+	Version = "1.2"
+	// LegacyVersion is assumed for backends that do not negotiate: they
+	// understand only the core event types
+	LegacyVersion = "1.1"
+	// negotiationVersion is the first version that negotiates features
+	negotiationVersion = "1.2"
+)
+
+// featureEvents maps each optional feature to the event types it adds.
+// Event types not listed here are core and always sent.
+var featureEvents = map[string][]string{
+	"resource_events":  {"resource_event"},
+	"inventory_deltas": {"container_inventory_delta"},
+	"update_groups":    {"update_group_progress", "update_group_complete"},
+	"volume_backup":    {"volume_backup_chunk", "volume_backup_complete", "volume_restore_complete"},
+	"container_export": {"container_export_chunk", "container_export_progress", "container_export_complete", "container_commit_complete"},
+	"diagnostics":      {"diagnostics_chunk", "diagnostics_complete"},
+	"log_download":     {"container_logs_chunk", "container_logs_complete"},
+	"project_logs":     {"project_log_lines", "project_logs_complete"},
+	"scheduler":        {"schedule_run"},
+	"crash_loop":       {"crash_loop"},
+	"update_failures":  {"update_failed"},
+	// Not an event: large command responses are split into response_chunk
+	// messages (see chunks.go)
+	FeatureResponseChunks: nil,
+	// Not an event: progress events use the shared schema (see progress.go)
+	FeatureProgressSchema: nil,
+}
+
+// eventFeature is featureEvents inverted
+var eventFeature = func() map[string]string {
+	m := make(map[string]string)
+	for feature, events := range featureEvents {
+		for _, event := range events {
+			m[event] = feature
+		}
+	}
+	return m
+}()
+
+// Features lists the optional features this agent offers at registration
+// when speaking version (none before negotiation existed)
+func Features(version string) []string {
+	if CompareVersions(version, negotiationVersion) < 0 {
+		return nil
+	}
+	features := make([]string, 0, len(featureEvents))
+	for feature := range featureEvents {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// Negotiated is the protocol version and feature set agreed with the backend
+type Negotiated struct {
+	Version       string
+	ServerVersion string // DockMon release; "" from backends that don't report it
+	features      map[string]bool
+}
+
+// Negotiate reads the backend's registration reply. A backend that sends no
+// features predates negotiation and gets the legacy protocol; features it
+// names that this agent doesn't know are ignored.
+func Negotiate(resp map[string]interface{}) *Negotiated {
+	n := &Negotiated{Version: LegacyVersion, features: make(map[string]bool)}
+	if v, ok := resp["proto_version"].(string); ok && v != "" {
+		n.Version = v
+	}
+	if v, ok := resp["server_version"].(string); ok {
+		n.ServerVersion = v
+	}
+	list, ok := resp["features"].([]interface{})
+	if !ok {
+		return n
+	}
+	for _, item := range list {
+		if feature, ok := item.(string); ok {
+			if _, known := featureEvents[feature]; known {
+				n.features[feature] = true
+			}
+		}
+	}
+	return n
+}
+
+// Has reports whether feature was negotiated
+func (n *Negotiated) Has(feature string) bool {
+	return n != nil && n.features[feature]
+}
+
+// Features lists the negotiated features
+func (n *Negotiated) Features() []string {
+	if n == nil {
+		return nil
+	}
+	features := make([]string, 0, len(n.features))
+	for feature := range n.features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// Disabled lists the features this agent offers that were not negotiated
+func (n *Negotiated) Disabled() []string {
+	var disabled []string
+	for _, feature := range Features(Version) {
+		if !n.Has(feature) {
+			disabled = append(disabled, feature)
+		}
+	}
+	return disabled
+}
+
+// Outdated reports whether the backend settled on an older protocol than
+// the agent offered, so some of the agent's features are unavailable until
+// DockMon is updated
+func (n *Negotiated) Outdated(offered string) bool {
+	return n != nil && CompareVersions(n.Version, offered) < 0
+}
+
+// AllowsEvent reports whether the backend can parse eventType, and if not
+// which feature it belongs to
+func (n *Negotiated) AllowsEvent(eventType string) (bool, string) {
+	feature, optional := eventFeature[eventType]
+	if !optional {
+		return true, ""
+	}
+	return n.Has(feature), feature
+}
+
+// CompareVersions compares dotted numeric versions ("1.10" > "1.9"),
+// treating missing or non-numeric parts as 0
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	n := Negotiate(map[string]interface{}{
+		"proto_version":  "1.2",
+		"features":       []interface{}{"resource_events", "diagnostics", "from_the_future"},
+		"server_version": "2.3.0",
+	})
+	if n.Version != "1.2" || n.ServerVersion != "2.3.0" || n.Outdated(Version) {
+		t.Errorf("Version = %q, ServerVersion = %q, Outdated = %v; want 1.2, 2.3.0, false", n.Version, n.ServerVersion, n.Outdated(Version))
+	}
+	if got, want := n.Features(), []string{"diagnostics", "resource_events"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Features() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		event       string
+		wantAllowed bool
+		wantFeature string
+	}{
+		{"container_event", true, ""},
+		{"container_stats", true, ""},
+		{"resource_event", true, "resource_events"},
+		{"diagnostics_chunk", true, "diagnostics"},
+		{"volume_backup_chunk", false, "volume_backup"},
+		{"update_group_complete", false, "update_groups"},
+		{"update_failed", false, "update_failures"},
+	}
+	for _, tt := range tests {
+		allowed, feature := n.AllowsEvent(tt.event)
+		if allowed != tt.wantAllowed || feature != tt.wantFeature {
+			t.Errorf("AllowsEvent(%q) = %v, %q; want %v, %q", tt.event, allowed, feature, tt.wantAllowed, tt.wantFeature)
+		}
+	}
+	for _, feature := range n.Disabled() {
+		if n.Has(feature) {
+			t.Errorf("Disabled() includes negotiated feature %q", feature)
+		}
+	}
+}
+
+func TestNegotiate_LegacyBackend(t *testing.T) {
+	n := Negotiate(map[string]interface{}{"type": "auth_success"})
+	if n.Version != LegacyVersion || n.ServerVersion != "" || !n.Outdated(Version) {
+		t.Errorf("Version = %q, ServerVersion = %q, Outdated = %v; want %q, empty, true", n.Version, n.ServerVersion, n.Outdated(Version), LegacyVersion)
+	}
+	if len(n.Features()) != 0 {
+		t.Errorf("legacy backend should negotiate no features, got %v", n.Features())
+	}
+	if allowed, _ := n.AllowsEvent("container_inventory_delta"); allowed {
+		t.Error("optional events must not be sent to a legacy backend")
+	}
+	if allowed, _ := n.AllowsEvent("container_event"); !allowed {
+		t.Error("core events must always be sent")
+	}
+
+	// Before registration there is nothing negotiated
+	var none *Negotiated
+	if allowed, _ := none.AllowsEvent("diagnostics_complete"); allowed {
+		t.Error("nil Negotiated should allow only core events")
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	if got := Features(LegacyVersion); got != nil {
+		t.Errorf("Features(%q) = %v, want none", LegacyVersion, got)
+	}
+	if got := Features(Version); len(got) != len(featureEvents) {
+		t.Errorf("Features(%q) = %v, want all %d", Version, got, len(featureEvents))
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2", "1.2", 0},
+		{"1.1", "1.2", -1},
+		{"1.10", "1.9", 1},
+		{"2", "1.9", 1},
+		{"1.2.0", "1.2", 0},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
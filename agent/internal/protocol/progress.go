@@ -0,0 +1,60 @@
+package protocol
+
+import "github.com/darthnorse/dockmon-shared/progress"
+
+// FeatureProgressSchema is negotiated by backends that read progress events
+// in the shared progress schema (see shared/progress). Older backends get
+// the payloads they were written for.
+const FeatureProgressSchema = "progress_schema"
+
+// LegacyProgress converts a progress event to the payload backends without
+// FeatureProgressSchema expect for eventType. Only update_layer_progress and
+// selfupdate_progress renamed fields; the other progress events only gained
+// some, so they and non-progress payloads are returned as they are.
+func LegacyProgress(eventType string, payload interface{}) interface{} {
+	event, ok := payload.(progress.Event)
+	if !ok {
+		return payload
+	}
+
+	switch eventType {
+	case "update_layer_progress":
+		layers := event.Layers
+		if layers == nil {
+			layers = []progress.Layer{}
+		}
+		return map[string]interface{}{
+			"container_id":     event.ContainerID,
+			"overall_progress": event.StagePercent,
+			"layers":           layers,
+			"total_layers":     event.TotalLayers,
+			"remaining_layers": event.RemainingLayers,
+			"summary":          event.Message,
+			"speed_mbps":       event.SpeedMbps,
+		}
+
+	case "selfupdate_progress":
+		legacy := map[string]interface{}{
+			"stage":   event.Stage,
+			"message": event.Message,
+		}
+		if event.Error != "" {
+			legacy["error"] = event.Error
+		}
+		// Download stage only: percent was the download's, not the update's
+		if event.StagePercent != 0 {
+			legacy["percent"] = event.StagePercent
+		}
+		if event.CurrentBytes != 0 {
+			legacy["downloaded"] = event.CurrentBytes
+		}
+		if event.TotalBytes != 0 {
+			legacy["total"] = event.TotalBytes
+		}
+		if event.SpeedMbps != 0 {
+			legacy["speed_mbps"] = event.SpeedMbps
+		}
+		return legacy
+	}
+	return payload
+}
@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/progress"
+)
+
+func TestLegacyProgress_LayerProgress(t *testing.T) {
+	event := progress.New(progress.OperationUpdate, progress.StagePulling, "2 of 3 layers")
+	event.ContainerID = "abc123def456"
+	event.StagePercent = 40
+	event.SpeedMbps = 1.5
+	event.TotalLayers = 3
+
+	got := LegacyProgress("update_layer_progress", event)
+	want := map[string]interface{}{
+		"container_id":     "abc123def456",
+		"overall_progress": 40,
+		"layers":           []progress.Layer{},
+		"total_layers":     3,
+		"remaining_layers": 0,
+		"summary":          "2 of 3 layers",
+		"speed_mbps":       1.5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LegacyProgress() = %#v, want %#v", got, want)
+	}
+}
+
+func TestLegacyProgress_SelfUpdate(t *testing.T) {
+	event := progress.New(progress.OperationSelfUpdate, progress.StageDownload, "Downloaded 1.0 of 2.0 MB")
+	event.Percent = 30
+	event.StagePercent = 50
+	event.CurrentBytes = 1 << 20
+	event.TotalBytes = 2 << 20
+
+	got := LegacyProgress("selfupdate_progress", event)
+	want := map[string]interface{}{
+		"stage":      progress.StageDownload,
+		"message":    "Downloaded 1.0 of 2.0 MB",
+		"percent":    50,
+		"downloaded": int64(1 << 20),
+		"total":      int64(2 << 20),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LegacyProgress() = %#v, want %#v", got, want)
+	}
+
+	failed := progress.Failed(progress.OperationSelfUpdate, progress.StageVerify, errors.New("checksum mismatch"))
+	if got := LegacyProgress("selfupdate_progress", failed).(map[string]interface{}); got["error"] != "checksum mismatch" {
+		t.Errorf("error = %v, want checksum mismatch", got["error"])
+	}
+}
+
+func TestLegacyProgress_Unchanged(t *testing.T) {
+	event := progress.New(progress.OperationUpdate, progress.StageBackup, "Backing up")
+	if got := LegacyProgress("update_progress", event); !reflect.DeepEqual(got, event) {
+		t.Errorf("update_progress payload = %#v, want it unchanged", got)
+	}
+	payload := map[string]interface{}{"container_id": "abc"}
+	if got := LegacyProgress("update_layer_progress", payload); !reflect.DeepEqual(got, payload) {
+		t.Errorf("non-progress payload = %#v, want it unchanged", got)
+	}
+}
@@ -2,11 +2,29 @@ package protocol
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/darthnorse/dockmon-agent/pkg/types"
 )
 
+// ErrorCodeTimeout marks a response to a command that missed its deadline
+const ErrorCodeTimeout = "TIMEOUT"
+
+// ErrTimeout is wrapped by errors of commands that missed their deadline
+var ErrTimeout = errors.New("operation timed out")
+
+// ErrorCode returns the machine-readable code for err, or "" if it has none
+func ErrorCode(err error) string {
+	if errors.Is(err, ErrTimeout) {
+		return ErrorCodeTimeout
+	}
+	if errors.Is(err, ErrResponseTooLarge) {
+		return ErrorCodeResponseTooLarge
+	}
+	return ""
+}
+
 // EncodeMessage encodes a message to JSON bytes
 func EncodeMessage(msg *types.Message) ([]byte, error) {
 	msg.Timestamp = time.Now().UTC()
@@ -32,6 +50,7 @@ func NewCommandResponse(commandID string, payload interface{}, err error) *types
 
 	if err != nil {
 		msg.Error = err.Error()
+		msg.ErrorCode = ErrorCode(err)
 	}
 
 	return msg
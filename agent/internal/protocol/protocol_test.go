@@ -0,0 +1,19 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewCommandResponse_ErrorCode(t *testing.T) {
+	resp := NewCommandResponse("cmd-1", nil, fmt.Errorf("%w: prune_images did not finish within 10m0s", ErrTimeout))
+	if resp.ErrorCode != ErrorCodeTimeout || resp.Error == "" {
+		t.Errorf("timeout response = %+v", resp)
+	}
+
+	resp = NewCommandResponse("cmd-2", nil, errors.New("no such container"))
+	if resp.ErrorCode != "" || resp.Error != "no such container" {
+		t.Errorf("error response = %+v", resp)
+	}
+}
@@ -0,0 +1,602 @@
+// Package scheduler runs container jobs (restart, exec, image prune) on cron
+// schedules defined by DockMon. Schedules and run history are persisted to
+// the agent's data directory, so jobs keep running while DockMon is
+// unreachable and the backend can collect their results after a reconnect.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-shared/activity"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+)
+
+// Actions a schedule can run
+const (
+	ActionRestart     = "restart"
+	ActionExec        = "exec"
+	ActionPruneImages = "prune_images"
+)
+
+// Run statuses
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped" // The previous run was still going, or the host is in maintenance
+)
+
+const (
+	// maxRunsPerSchedule bounds the history kept for each schedule
+	maxRunsPerSchedule = 20
+	// defaultTimeout bounds exec and prune runs; for restarts the timeout is
+	// the stop timeout instead
+	defaultTimeout        = 10 * time.Minute
+	defaultRestartTimeout = 10
+	// runEvent reports each finished run to DockMon
+	runEvent = "schedule_run"
+)
+
+// Docker is the subset of the Docker client the scheduler uses
+type Docker interface {
+	RestartContainer(ctx context.Context, containerID string, timeout int) error
+	ExecRun(ctx context.Context, containerID string, cmd []string) (string, int, error)
+	PruneImages(ctx context.Context) (*docker.ImagePruneResult, error)
+	PruneImagesWithPolicy(ctx context.Context, policy sharedDocker.ImagePrunePolicy) (*sharedDocker.ImagePruneReport, error)
+}
+
+// ActivityPublisher sends prune results to the stats-service activity
+// stream; handlers.ActivityPublisher satisfies it
+type ActivityPublisher interface {
+	Publish(event activity.Event)
+}
+
+// Schedule is a job definition sent by DockMon
+type Schedule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Cron     string `json:"cron"`               // Five fields or @daily etc.
+	Timezone string `json:"timezone,omitempty"` // IANA name; default agent local time
+	Action   string `json:"action"`
+	Enabled  bool   `json:"enabled"`
+	// ContainerID is the target of restart and exec (ID or name)
+	ContainerID string   `json:"container_id,omitempty"`
+	Command     []string `json:"command,omitempty"` // exec only
+	// TimeoutSeconds is the stop timeout for restart, the deadline otherwise
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// Prune applies safety rules to prune_images (kept tags, dry run).
+	// Without it the daemon's own prune removes all unused images.
+	Prune *sharedDocker.ImagePrunePolicy `json:"prune,omitempty"`
+}
+
+// Validate checks the schedule can run.
+func (s *Schedule) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("schedule id is required")
+	}
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", s.Timezone)
+	}
+	if s.TimeoutSeconds < 0 {
+		return fmt.Errorf("timeout_seconds must not be negative")
+	}
+	switch s.Action {
+	case ActionRestart:
+		if s.ContainerID == "" {
+			return fmt.Errorf("restart requires container_id")
+		}
+	case ActionExec:
+		if s.ContainerID == "" || len(s.Command) == 0 {
+			return fmt.Errorf("exec requires container_id and command")
+		}
+	case ActionPruneImages:
+		if err := s.Prune.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown action %q (want restart, exec or prune_images)", s.Action)
+	}
+	return nil
+}
+
+// Run is the record of one execution of a schedule
+type Run struct {
+	ScheduleID string    `json:"schedule_id"`
+	Action     string    `json:"action"`
+	Status     string    `json:"status"`
+	Message    string    `json:"message,omitempty"`
+	Output     string    `json:"output,omitempty"` // exec output (tail)
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Prune is the report of a policy-based prune: what was (or in a dry
+	// run, would be) removed and why the rest was kept
+	Prune *sharedDocker.ImagePruneReport `json:"prune,omitempty"`
+}
+
+// ScheduleStatus is a schedule with its next due time
+type ScheduleStatus struct {
+	Schedule
+	NextRun *time.Time `json:"next_run,omitempty"`
+	Running bool       `json:"running,omitempty"`
+}
+
+// entry is a loaded schedule
+type entry struct {
+	Schedule
+	cron    *Cron
+	loc     *time.Location
+	next    time.Time
+	running bool
+}
+
+// state is the persisted file
+type state struct {
+	Schedules []Schedule `json:"schedules"`
+	Runs      []Run      `json:"runs"`
+}
+
+// Scheduler runs schedules and records their history. All methods are safe
+// for concurrent use.
+type Scheduler struct {
+	path      string
+	docker    Docker
+	log       *logrus.Logger
+	sendEvent func(string, interface{}) error
+	now       func() time.Time
+	// maintenance pauses scheduled restarts while the host is in maintenance
+	maintenance *maintenance.Mode
+	activity    ActivityPublisher // Optional, see SetActivityPublisher
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	runs    []Run
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New loads the schedules persisted at path. sendEvent reports finished
+// runs; failures to send are fine since history is kept on disk.
+func New(path string, dockerClient Docker, log *logrus.Logger, sendEvent func(string, interface{}) error) *Scheduler {
+	s := &Scheduler{
+		path:      path,
+		docker:    dockerClient,
+		log:       log,
+		sendEvent: sendEvent,
+		now:       time.Now,
+		entries:   make(map[string]*entry),
+		wake:      make(chan struct{}, 1),
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- path is derived from the agent's DataPath
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).Warn("Failed to read schedules file; starting empty")
+		}
+		return s
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		log.WithError(err).Warn("Schedules file is corrupt; starting empty")
+		return s
+	}
+	for _, sched := range st.Schedules {
+		e, err := s.newEntry(sched)
+		if err != nil {
+			log.WithError(err).WithField("schedule_id", sched.ID).Warn("Dropping invalid persisted schedule")
+			continue
+		}
+		s.entries[sched.ID] = e
+	}
+	s.runs = st.Runs
+	if len(s.entries) > 0 {
+		log.WithField("count", len(s.entries)).Info("Loaded persisted schedules")
+	}
+	return s
+}
+
+// SetMaintenance skips scheduled restarts while m has an active window, so
+// they don't fight a planned reboot. Call before Run.
+func (s *Scheduler) SetMaintenance(m *maintenance.Mode) {
+	s.maintenance = m
+}
+
+// SetActivityPublisher announces the results of policy-based image prunes
+// on the stats-service activity stream. Call before Run.
+func (s *Scheduler) SetActivityPublisher(p ActivityPublisher) {
+	s.activity = p
+}
+
+func (s *Scheduler) newEntry(sched Schedule) (*entry, error) {
+	if err := sched.Validate(); err != nil {
+		return nil, err
+	}
+	cron, _ := ParseCron(sched.Cron)
+	loc, _ := time.LoadLocation(sched.Timezone)
+	e := &entry{Schedule: sched, cron: cron, loc: loc}
+	e.next = cron.Next(s.now().In(loc))
+	return e, nil
+}
+
+// Sync replaces all schedules. Nothing changes if any of them is invalid.
+func (s *Scheduler) Sync(schedules []Schedule) error {
+	entries := make(map[string]*entry, len(schedules))
+	for _, sched := range schedules {
+		e, err := s.newEntry(sched)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", sched.ID, err)
+		}
+		entries[sched.ID] = e
+	}
+
+	s.mu.Lock()
+	for id, e := range entries {
+		if old, ok := s.entries[id]; ok {
+			e.running = old.running
+		}
+	}
+	s.entries = entries
+	s.pruneRunsLocked()
+	s.saveLocked()
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// Set adds or replaces one schedule.
+func (s *Scheduler) Set(sched Schedule) error {
+	e, err := s.newEntry(sched)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if old, ok := s.entries[sched.ID]; ok {
+		e.running = old.running
+	}
+	s.entries[sched.ID] = e
+	s.saveLocked()
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// Remove deletes a schedule and its history. Returns false if it didn't exist.
+func (s *Scheduler) Remove(id string) bool {
+	s.mu.Lock()
+	_, ok := s.entries[id]
+	if ok {
+		delete(s.entries, id)
+		s.pruneRunsLocked()
+		s.saveLocked()
+	}
+	s.mu.Unlock()
+	if ok {
+		s.notify()
+	}
+	return ok
+}
+
+// List returns all schedules by ID with their next due time
+func (s *Scheduler) List() []ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]ScheduleStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		status := ScheduleStatus{Schedule: e.Schedule, Running: e.running}
+		if e.Enabled && !e.next.IsZero() {
+			next := e.next.UTC()
+			status.NextRun = &next
+		}
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Runs returns the runs of the given schedule (all when empty) that finished
+// at or after since, oldest first
+func (s *Scheduler) Runs(scheduleID string, since time.Time) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		if scheduleID != "" && run.ScheduleID != scheduleID {
+			continue
+		}
+		if run.FinishedAt.Before(since) {
+			continue
+		}
+		result = append(result, run)
+	}
+	return result
+}
+
+// notify wakes the Run loop to recompute the next due time
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run fires schedules as they come due until ctx is cancelled, then waits
+// for running jobs. Runs missed while the agent was stopped are not caught
+// up, like cron.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.wg.Wait()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		wait := time.Hour
+		if next := s.nextDue(); !next.IsZero() {
+			wait = max(next.Sub(s.now()), 0)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.fireDue(ctx)
+		}
+	}
+}
+
+// nextDue is the earliest due time of the enabled schedules
+func (s *Scheduler) nextDue() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, e := range s.entries {
+		if e.Enabled && !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// fireDue starts every enabled schedule that is due
+func (s *Scheduler) fireDue(ctx context.Context) {
+	now := s.now()
+	inMaintenance := false
+	if s.maintenance != nil {
+		_, inMaintenance = s.maintenance.Active()
+	}
+	var skipped []Run
+	s.mu.Lock()
+	for _, e := range s.entries {
+		if !e.Enabled || e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		e.next = e.cron.Next(now.In(e.loc))
+		var reason string
+		switch {
+		case e.running:
+			reason = "previous run still in progress"
+		case inMaintenance && e.Action == ActionRestart:
+			reason = "host in maintenance mode"
+		}
+		if reason != "" {
+			run := Run{
+				ScheduleID: e.ID,
+				Action:     e.Action,
+				Status:     RunSkipped,
+				Message:    reason,
+				StartedAt:  now.UTC(),
+				FinishedAt: now.UTC(),
+			}
+			s.recordLocked(run)
+			skipped = append(skipped, run)
+			continue
+		}
+		e.running = true
+		s.wg.Add(1)
+		go s.execute(ctx, e.Schedule)
+	}
+	s.mu.Unlock()
+
+	for _, run := range skipped {
+		s.report(run)
+	}
+}
+
+// execute runs one schedule and records the result
+func (s *Scheduler) execute(ctx context.Context, sched Schedule) {
+	defer s.wg.Done()
+
+	run := Run{ScheduleID: sched.ID, Action: sched.Action, StartedAt: s.now().UTC()}
+	logEntry := s.log.WithFields(logrus.Fields{
+		"schedule_id": sched.ID,
+		"action":      sched.Action,
+	})
+	logEntry.Info("Running scheduled job")
+
+	err := s.perform(ctx, sched, &run)
+	run.FinishedAt = s.now().UTC()
+	if err != nil {
+		run.Status = RunFailed
+		run.Message = err.Error()
+		logEntry.WithError(err).Warn("Scheduled job failed")
+	} else {
+		run.Status = RunSucceeded
+		logEntry.Info("Scheduled job completed")
+	}
+
+	s.mu.Lock()
+	if e, ok := s.entries[sched.ID]; ok {
+		e.running = false
+	}
+	s.recordLocked(run)
+	s.mu.Unlock()
+	s.report(run)
+}
+
+// perform carries out the schedule's action, filling in run details
+func (s *Scheduler) perform(ctx context.Context, sched Schedule, run *Run) error {
+	if sched.Action == ActionRestart {
+		timeout := defaultRestartTimeout
+		if sched.TimeoutSeconds > 0 {
+			timeout = sched.TimeoutSeconds
+		}
+		if err := s.docker.RestartContainer(ctx, sched.ContainerID, timeout); err != nil {
+			return err
+		}
+		run.Message = fmt.Sprintf("restarted %s", sched.ContainerID)
+		return nil
+	}
+
+	timeout := defaultTimeout
+	if sched.TimeoutSeconds > 0 {
+		timeout = time.Duration(sched.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch sched.Action {
+	case ActionExec:
+		output, exitCode, err := s.docker.ExecRun(ctx, sched.ContainerID, sched.Command)
+		run.Output = output
+		if err != nil {
+			return err
+		}
+		run.ExitCode = &exitCode
+		if exitCode != 0 {
+			return fmt.Errorf("command exited with code %d", exitCode)
+		}
+	case ActionPruneImages:
+		if sched.Prune != nil {
+			return s.prune(ctx, sched, run)
+		}
+		result, err := s.docker.PruneImages(ctx)
+		if err != nil {
+			return err
+		}
+		run.Message = fmt.Sprintf("removed %d image(s), reclaimed %s",
+			result.RemovedCount, units.HumanSize(float64(result.SpaceReclaimed)))
+	}
+	return nil
+}
+
+// prune runs a policy-based image prune, keeping its report in the run and
+// announcing the result
+func (s *Scheduler) prune(ctx context.Context, sched Schedule, run *Run) error {
+	report, err := s.docker.PruneImagesWithPolicy(ctx, *sched.Prune)
+	if err != nil {
+		return err
+	}
+	run.Prune = report
+	size := units.HumanSize(float64(report.SpaceReclaimed))
+	if report.DryRun {
+		run.Message = fmt.Sprintf("dry run: would remove %d image(s), reclaiming %s", report.RemovedCount, size)
+	} else {
+		run.Message = fmt.Sprintf("removed %d image(s), reclaimed %s", report.RemovedCount, size)
+	}
+	if len(report.Failed) > 0 {
+		run.Message += fmt.Sprintf("; %d could not be removed", len(report.Failed))
+	}
+
+	if s.activity != nil {
+		event := activity.ImagesPruned(report.RemovedCount, len(report.Failed), report.SpaceReclaimed, report.DryRun)
+		event.Attributes[activity.AttrScheduleID] = sched.ID
+		s.activity.Publish(event)
+	}
+	return nil
+}
+
+// recordLocked appends a run to the history and persists it
+func (s *Scheduler) recordLocked(run Run) {
+	s.runs = append(s.runs, run)
+	s.pruneRunsLocked()
+	s.saveLocked()
+}
+
+// report sends a finished run to DockMon. While disconnected it only stays
+// in the history, which the backend reads after reconnecting.
+func (s *Scheduler) report(run Run) {
+	if s.sendEvent == nil {
+		return
+	}
+	if err := s.sendEvent(runEvent, run); err != nil {
+		s.log.WithError(err).Debug("Schedule run not reported; kept in history")
+	}
+}
+
+// pruneRunsLocked drops runs of removed schedules and all but the last
+// maxRunsPerSchedule runs of each schedule
+func (s *Scheduler) pruneRunsLocked() {
+	counts := make(map[string]int)
+	keep := make([]bool, len(s.runs))
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		id := s.runs[i].ScheduleID
+		if _, ok := s.entries[id]; !ok || counts[id] >= maxRunsPerSchedule {
+			continue
+		}
+		counts[id]++
+		keep[i] = true
+	}
+	runs := s.runs[:0]
+	for i, run := range s.runs {
+		if keep[i] {
+			runs = append(runs, run)
+		}
+	}
+	s.runs = runs
+}
+
+// saveLocked writes the schedules and history atomically via a temp file
+// and rename. Failures are logged; the in-memory state stays authoritative.
+func (s *Scheduler) saveLocked() {
+	if err := s.writeLocked(); err != nil {
+		s.log.WithError(err).Warn("Failed to persist schedules")
+	}
+}
+
+func (s *Scheduler) writeLocked() error {
+	st := state{Schedules: make([]Schedule, 0, len(s.entries)), Runs: s.runs}
+	for _, e := range s.entries {
+		st.Schedules = append(st.Schedules, e.Schedule)
+	}
+	sort.Slice(st.Schedules, func(i, j int) bool { return st.Schedules[i].ID < st.Schedules[j].ID })
+	if st.Runs == nil {
+		st.Runs = []Run{}
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedules: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write schedules: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace schedules: %w", err)
+	}
+	return nil
+}
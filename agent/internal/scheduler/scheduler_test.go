@@ -0,0 +1,337 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-agent/internal/docker"
+	"github.com/darthnorse/dockmon-shared/activity"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeDocker struct {
+	mu       sync.Mutex
+	restarts []string
+	execs    [][]string
+	exitCode int
+	block    chan struct{} // Blocks exec until closed, when set
+	policies []sharedDocker.ImagePrunePolicy
+}
+
+func (f *fakeDocker) RestartContainer(ctx context.Context, containerID string, timeout int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restarts = append(f.restarts, containerID)
+	if containerID == "missing" {
+		return errors.New("no such container")
+	}
+	return nil
+}
+
+func (f *fakeDocker) ExecRun(ctx context.Context, containerID string, cmd []string) (string, int, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.execs = append(f.execs, cmd)
+	return "done\n", f.exitCode, nil
+}
+
+func (f *fakeDocker) PruneImages(ctx context.Context) (*docker.ImagePruneResult, error) {
+	return &docker.ImagePruneResult{RemovedCount: 3, SpaceReclaimed: 2048}, nil
+}
+
+func (f *fakeDocker) PruneImagesWithPolicy(ctx context.Context, policy sharedDocker.ImagePrunePolicy) (*sharedDocker.ImagePruneReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policies = append(f.policies, policy)
+	return &sharedDocker.ImagePruneReport{
+		DryRun:         policy.DryRun,
+		Removed:        []sharedDocker.PrunedImage{{ID: "sha256:old", Size: 4096}},
+		Kept:           []sharedDocker.PrunedImage{{ID: "sha256:stopped", Reason: sharedDocker.KeepReasonInUse}},
+		Failed:         []sharedDocker.PrunedImage{{ID: "sha256:busy", Error: "conflict"}},
+		RemovedCount:   1,
+		SpaceReclaimed: 4096,
+	}, nil
+}
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	events []activity.Event
+}
+
+func (p *fakePublisher) Publish(event activity.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+}
+
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// newTestScheduler returns a scheduler whose clock is set by the returned func
+func newTestScheduler(t *testing.T, d Docker, path string) (*Scheduler, func(time.Time), *[]Run) {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []Run
+	s := New(path, d, quietLogger(), func(eventType string, payload interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if eventType == runEvent {
+			sent = append(sent, payload.(Run))
+		}
+		return nil
+	})
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	var clockMu sync.Mutex
+	s.now = func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+	set := func(t time.Time) {
+		clockMu.Lock()
+		now = t
+		clockMu.Unlock()
+	}
+	return s, set, &sent
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Schedule
+		wantErr bool
+	}{
+		{"restart", Schedule{ID: "a", Cron: "@daily", Action: ActionRestart, ContainerID: "web"}, false},
+		{"exec", Schedule{ID: "a", Cron: "0 3 * * *", Action: ActionExec, ContainerID: "db", Command: []string{"vacuum"}}, false},
+		{"prune", Schedule{ID: "a", Cron: "@weekly", Action: ActionPruneImages, Timezone: "UTC"}, false},
+		{"missing id", Schedule{Cron: "@daily", Action: ActionPruneImages}, true},
+		{"bad cron", Schedule{ID: "a", Cron: "daily", Action: ActionPruneImages}, true},
+		{"bad timezone", Schedule{ID: "a", Cron: "@daily", Action: ActionPruneImages, Timezone: "Mars/Olympus"}, true},
+		{"restart without container", Schedule{ID: "a", Cron: "@daily", Action: ActionRestart}, true},
+		{"exec without command", Schedule{ID: "a", Cron: "@daily", Action: ActionExec, ContainerID: "db"}, true},
+		{"unknown action", Schedule{ID: "a", Cron: "@daily", Action: "reboot"}, true},
+		{"prune policy", Schedule{ID: "a", Cron: "@daily", Action: ActionPruneImages,
+			Prune: &sharedDocker.ImagePrunePolicy{KeepLastTags: map[string]int{"nginx": 2}}}, false},
+		{"prune policy keeping no tags", Schedule{ID: "a", Cron: "@daily", Action: ActionPruneImages,
+			Prune: &sharedDocker.ImagePrunePolicy{KeepLastTags: map[string]int{"nginx": 0}}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.s.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSchedulerFiresDueSchedules(t *testing.T) {
+	d := &fakeDocker{}
+	s, setNow, sent := newTestScheduler(t, d, filepath.Join(t.TempDir(), "schedules.json"))
+
+	err := s.Sync([]Schedule{
+		{ID: "nightly", Cron: "0 3 * * *", Action: ActionRestart, ContainerID: "flaky", Enabled: true},
+		{ID: "broken", Cron: "0 3 * * *", Action: ActionRestart, ContainerID: "missing", Enabled: true},
+		{ID: "off", Cron: "0 3 * * *", Action: ActionPruneImages},
+		{ID: "later", Cron: "0 4 * * *", Action: ActionPruneImages, Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.nextDue(); !next.Equal(time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC)) {
+		t.Fatalf("nextDue = %v", next)
+	}
+
+	setNow(time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	s.wg.Wait()
+
+	if len(d.restarts) != 2 {
+		t.Errorf("restarts = %v, want flaky and missing", d.restarts)
+	}
+	runs := s.Runs("", time.Time{})
+	if len(runs) != 2 || len(*sent) != 2 {
+		t.Fatalf("runs = %+v, sent %d", runs, len(*sent))
+	}
+	if r := s.Runs("broken", time.Time{}); len(r) != 1 || r[0].Status != RunFailed || r[0].Message != "no such container" {
+		t.Errorf("broken run = %+v", r)
+	}
+	if r := s.Runs("nightly", time.Time{}); len(r) != 1 || r[0].Status != RunSucceeded {
+		t.Errorf("nightly run = %+v", r)
+	}
+	if next := s.nextDue(); !next.Equal(time.Date(2026, 3, 5, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("nextDue after firing = %v, want the 4:00 schedule", next)
+	}
+}
+
+func TestSchedulerExecAndOverlap(t *testing.T) {
+	d := &fakeDocker{exitCode: 1, block: make(chan struct{})}
+	s, setNow, _ := newTestScheduler(t, d, filepath.Join(t.TempDir(), "schedules.json"))
+	if err := s.Set(Schedule{ID: "job", Cron: "* * * * *", Action: ActionExec, ContainerID: "db", Command: []string{"backup"}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	setNow(time.Date(2026, 3, 4, 10, 1, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	// Still running a minute later: the next run is skipped
+	setNow(time.Date(2026, 3, 4, 10, 2, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	close(d.block)
+	s.wg.Wait()
+
+	runs := s.Runs("job", time.Time{})
+	if len(runs) != 2 || runs[0].Status != RunSkipped || runs[1].Status != RunFailed {
+		t.Fatalf("runs = %+v, want skipped then failed", runs)
+	}
+	if runs[1].ExitCode == nil || *runs[1].ExitCode != 1 || runs[1].Output != "done\n" {
+		t.Errorf("exec run = %+v", runs[1])
+	}
+}
+
+func TestSchedulerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	s, setNow, _ := newTestScheduler(t, &fakeDocker{}, path)
+	if err := s.Set(Schedule{ID: "prune", Cron: "@hourly", Action: ActionPruneImages, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	setNow(time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	s.wg.Wait()
+
+	reloaded, _, _ := newTestScheduler(t, &fakeDocker{}, path)
+	if list := reloaded.List(); len(list) != 1 || list[0].ID != "prune" || list[0].NextRun == nil {
+		t.Errorf("reloaded schedules = %+v", list)
+	}
+	runs := reloaded.Runs("prune", time.Time{})
+	if len(runs) != 1 || runs[0].Message != "removed 3 image(s), reclaimed 2.048kB" {
+		t.Errorf("reloaded runs = %+v", runs)
+	}
+
+	// Removing the schedule drops its history
+	if !reloaded.Remove("prune") || len(reloaded.Runs("", time.Time{})) != 0 {
+		t.Error("Remove kept the schedule's history")
+	}
+}
+
+func TestSchedulerSyncIsAtomic(t *testing.T) {
+	s, _, _ := newTestScheduler(t, &fakeDocker{}, filepath.Join(t.TempDir(), "schedules.json"))
+	if err := s.Set(Schedule{ID: "keep", Cron: "@daily", Action: ActionPruneImages}); err != nil {
+		t.Fatal(err)
+	}
+	err := s.Sync([]Schedule{
+		{ID: "new", Cron: "@daily", Action: ActionPruneImages},
+		{ID: "bad", Cron: "@sometimes", Action: ActionPruneImages},
+	})
+	if err == nil {
+		t.Fatal("expected an error for the invalid schedule")
+	}
+	if list := s.List(); len(list) != 1 || list[0].ID != "keep" {
+		t.Errorf("schedules after failed sync = %+v", list)
+	}
+}
+
+func TestPruneRunsKeepsLatest(t *testing.T) {
+	s, _, _ := newTestScheduler(t, &fakeDocker{}, filepath.Join(t.TempDir(), "schedules.json"))
+	if err := s.Set(Schedule{ID: "a", Cron: "@daily", Action: ActionPruneImages}); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	for i := 0; i < maxRunsPerSchedule+5; i++ {
+		s.runs = append(s.runs, Run{ScheduleID: "a", Message: string(rune('A' + i))})
+	}
+	s.runs = append(s.runs, Run{ScheduleID: "gone"})
+	s.pruneRunsLocked()
+	s.mu.Unlock()
+
+	runs := s.Runs("", time.Time{})
+	if len(runs) != maxRunsPerSchedule || runs[0].Message != "F" {
+		t.Errorf("kept %d runs starting with %q, want the last %d", len(runs), runs[0].Message, maxRunsPerSchedule)
+	}
+}
+
+func TestSchedulerSkipsRestartsDuringMaintenance(t *testing.T) {
+	d := &fakeDocker{}
+	s, setNow, _ := newTestScheduler(t, d, filepath.Join(t.TempDir(), "schedules.json"))
+	m := &maintenance.Mode{}
+	s.SetMaintenance(m)
+	m.Enable("reboot", time.Hour)
+
+	err := s.Sync([]Schedule{
+		{ID: "nightly", Cron: "0 3 * * *", Action: ActionRestart, ContainerID: "flaky", Enabled: true},
+		{ID: "prune", Cron: "0 3 * * *", Action: ActionPruneImages, Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setNow(time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	s.wg.Wait()
+
+	if len(d.restarts) != 0 {
+		t.Errorf("restarts = %v during maintenance", d.restarts)
+	}
+	if runs := s.Runs("nightly", time.Time{}); len(runs) != 1 || runs[0].Status != RunSkipped {
+		t.Errorf("nightly runs = %+v, want skipped", runs)
+	}
+	if runs := s.Runs("prune", time.Time{}); len(runs) != 1 || runs[0].Status != RunSucceeded {
+		t.Errorf("prune runs = %+v, want succeeded", runs)
+	}
+}
+
+func TestSchedulerPolicyPrune(t *testing.T) {
+	d := &fakeDocker{}
+	s, setNow, sent := newTestScheduler(t, d, filepath.Join(t.TempDir(), "schedules.json"))
+	publisher := &fakePublisher{}
+	s.SetActivityPublisher(publisher)
+
+	err := s.Sync([]Schedule{
+		{ID: "preview", Cron: "0 3 * * *", Action: ActionPruneImages, Enabled: true,
+			Prune: &sharedDocker.ImagePrunePolicy{KeepLastTags: map[string]int{"nginx": 2}, DryRun: true}},
+		{ID: "weekly", Cron: "0 4 * * *", Action: ActionPruneImages, Enabled: true,
+			Prune: &sharedDocker.ImagePrunePolicy{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setNow(time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	s.wg.Wait()
+	setNow(time.Date(2026, 3, 5, 4, 0, 0, 0, time.UTC))
+	s.fireDue(context.Background())
+	s.wg.Wait()
+
+	if len(d.policies) != 2 || !d.policies[0].DryRun || d.policies[0].KeepLastTags["nginx"] != 2 {
+		t.Fatalf("policies = %+v, want the dry run then the real prune", d.policies)
+	}
+	preview := s.Runs("preview", time.Time{})
+	if len(preview) != 1 || preview[0].Prune == nil || !preview[0].Prune.DryRun ||
+		preview[0].Message != "dry run: would remove 1 image(s), reclaiming 4.096kB; 1 could not be removed" {
+		t.Errorf("preview run = %+v", preview)
+	}
+	weekly := s.Runs("weekly", time.Time{})
+	if len(weekly) != 1 || weekly[0].Status != RunSucceeded || len(weekly[0].Prune.Kept) != 1 {
+		t.Errorf("weekly run = %+v", weekly)
+	}
+	if len(*sent) != 2 || (*sent)[1].Prune == nil {
+		t.Errorf("sent = %+v, want both runs with their reports", *sent)
+	}
+
+	if len(publisher.events) != 2 {
+		t.Fatalf("published %d events, want one per prune", len(publisher.events))
+	}
+	e := publisher.events[1]
+	if e.Action != activity.ActionImagesPruned || e.Attributes[activity.AttrScheduleID] != "weekly" ||
+		e.Attributes[activity.AttrDryRun] != "false" || e.Attributes[activity.AttrFailedCount] != "1" {
+		t.Errorf("event = %+v", e)
+	}
+}
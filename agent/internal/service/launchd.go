@@ -0,0 +1,54 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+const (
+	// LaunchdLabel identifies the agent's launchd job
+	LaunchdLabel = "com.dockmon.agent"
+	// LaunchdPlistPath is where the daemon definition is installed
+	LaunchdPlistPath = "/Library/LaunchDaemons/" + LaunchdLabel + ".plist"
+	// LaunchdLogPath receives the agent's stdout/stderr under launchd
+	LaunchdLogPath = "/Library/Logs/" + Name + ".log"
+)
+
+// renderLaunchdPlist builds the launchd daemon definition. KeepAlive makes
+// launchd restart the agent whenever it exits, which native self-update
+// relies on to start the new binary.
+func renderLaunchdPlist(binaryPath string, env map[string]string) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+
+	writePlistString(&b, "Label", LaunchdLabel)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n\t\t<string>")
+	xml.EscapeText(&b, []byte(binaryPath))
+	b.WriteString("</string>\n\t</array>\n")
+
+	b.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, key := range sortedKeys(env) {
+		b.WriteString("\t")
+		writePlistString(&b, key, env[key])
+	}
+	b.WriteString("\t</dict>\n")
+
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	b.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	writePlistString(&b, "StandardOutPath", LaunchdLogPath)
+	writePlistString(&b, "StandardErrorPath", LaunchdLogPath)
+
+	b.WriteString("</dict>\n</plist>\n")
+	return b.Bytes()
+}
+
+// writePlistString writes an escaped key/string pair.
+func writePlistString(b *bytes.Buffer, key, value string) {
+	b.WriteString("\t<key>")
+	xml.EscapeText(b, []byte(key))
+	b.WriteString("</key>\n\t<string>")
+	xml.EscapeText(b, []byte(value))
+	b.WriteString("</string>\n")
+}
@@ -0,0 +1,88 @@
+// Package service installs and runs the agent as a native OS service: a
+// systemd unit on Linux (the same unit scripts/install-agent.sh writes), a
+// Windows service (Docker Desktop on Windows) or a launchd daemon (Docker
+// Desktop on macOS).
+package service
+
+import (
+	"os"
+	"sort"
+)
+
+const (
+	// Name is the service name (Windows) and binary name used everywhere
+	Name = "dockmon-agent"
+	// DisplayName is shown in the Windows Services console
+	DisplayName = "DockMon Agent"
+	// Description is shown in the Windows Services console
+	Description = "Connects this Docker host to DockMon for monitoring and management"
+)
+
+// envKeys are the agent settings (see config.LoadFromEnv) copied from the
+// installing shell into the service definition, since services don't
+// inherit the user's environment.
+var envKeys = []string{
+	"DOCKMON_URL",
+	"REGISTRATION_TOKEN",
+	"PERMANENT_TOKEN",
+	"INSECURE_SKIP_VERIFY",
+	"DOCKMON_PATH_PREFIX",
+	"DOCKMON_HEADERS",
+	"DOCKMON_CA_FILE",
+	"DOCKMON_CERT_SHA256",
+	"DOCKER_HOST",
+	"DOCKER_CERT_PATH",
+	"DOCKER_TLS_VERIFY",
+	"XDG_RUNTIME_DIR", // Locates rootless Docker and Podman sockets
+	"AGENT_NAME",
+	"FORCE_UNIQUE_REGISTRATION",
+	"DATA_PATH",
+	"AGENT_STACKS_DIR",
+	"HOST_STACKS_DIR",
+	"COMPOSE_SECRETS_DIR",
+	"RECONNECT_INITIAL",
+	"RECONNECT_MAX",
+	"UPDATE_TIMEOUT",
+	"UPDATE_MIN_FREE_SPACE",
+	"LOCAL_NOTIFY_URL",
+	"LOCAL_NOTIFY_TYPE",
+	"LOCAL_NOTIFY_TOKEN",
+	"LOCAL_NOTIFY_EVENTS",
+	"LOCAL_NOTIFY_AFTER",
+	"VOLUME_BACKUP_DIR",
+	"VOLUME_HELPER_IMAGE",
+	"NET_TEST_IMAGE",
+	"CONTAINER_EXPORT_DIR",
+	"STATS_MEMORY_MODE",
+	"PPROF_ADDR",
+	"PPROF_ALLOW_REMOTE",
+	"LOG_LEVEL",
+	"LOG_JSON",
+}
+
+// Environment returns the agent settings set in the current environment.
+// dataPath is always included so the service and the installing shell agree
+// on where the permanent token and update lock live.
+func Environment(dataPath string) map[string]string {
+	env := make(map[string]string)
+	for _, key := range envKeys {
+		if value, ok := os.LookupEnv(key); ok && value != "" {
+			env[key] = value
+		}
+	}
+	env["DATA_PATH"] = dataPath
+	return env
+}
+
+// sortedKeys returns env's keys in a stable order for service definitions.
+func sortedKeys(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// maxServiceLogBytes caps the service manager log returned by RecentLogs
+const maxServiceLogBytes = 1 << 20
@@ -0,0 +1,79 @@
+//go:build darwin
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Install writes the launchd daemon definition and loads it. An existing
+// definition is replaced. Must be run as root.
+func Install(binaryPath string, env map[string]string) error {
+	// Replace rather than fail if already installed
+	_ = launchctl("bootout", "system/"+LaunchdLabel)
+
+	// 0600: the environment includes the registration token
+	if err := os.WriteFile(LaunchdPlistPath, renderLaunchdPlist(binaryPath, env), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", LaunchdPlistPath, err)
+	}
+	if err := launchctl("bootstrap", "system", LaunchdPlistPath); err != nil {
+		return fmt.Errorf("failed to load launchd daemon: %w", err)
+	}
+	return nil
+}
+
+// Uninstall unloads the launchd daemon and removes its definition.
+func Uninstall() error {
+	if err := launchctl("bootout", "system/"+LaunchdLabel); err != nil {
+		// Not loaded is fine as long as the plist goes away
+		if _, statErr := os.Stat(LaunchdPlistPath); os.IsNotExist(statErr) {
+			return fmt.Errorf("service is not installed")
+		}
+	}
+	if err := os.Remove(LaunchdPlistPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", LaunchdPlistPath, err)
+	}
+	return nil
+}
+
+// IsService reports whether the process was started by a service manager
+// that needs a special run loop. launchd delivers SIGTERM like any other
+// supervisor, so the regular signal handling suffices.
+func IsService() bool {
+	return false
+}
+
+// Run is only needed on Windows; elsewhere it just runs the agent.
+func Run(run func(ctx context.Context) error) error {
+	return run(context.Background())
+}
+
+// launchctl runs a launchctl subcommand, returning its output on failure.
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput() // #nosec G204
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RecentLogs returns the tail of the launchd log file, for diagnostics
+// bundles.
+func RecentLogs() ([]byte, error) {
+	f, err := os.Open(LaunchdLogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > maxServiceLogBytes {
+		if _, err := f.Seek(-maxServiceLogBytes, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Install writes the systemd unit and its root-only environment file, then
+// enables and (re)starts the unit. An existing installation is replaced.
+// Must be run as root.
+func Install(binaryPath string, env map[string]string) error {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return fmt.Errorf("systemd not found; run the agent container instead")
+	}
+
+	envFile, err := renderSystemdEnvFile(env)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(SystemdEnvDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", SystemdEnvDir, err)
+	}
+	// 0600: the environment includes the agent's tokens
+	if err := os.WriteFile(SystemdEnvPath, envFile, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", SystemdEnvPath, err)
+	}
+	// #nosec G306 -- unit files are world-readable by convention; secrets live in the env file
+	if err := os.WriteFile(SystemdUnitPath, renderSystemdUnit(binaryPath, env["DATA_PATH"]), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", SystemdUnitPath, err)
+	}
+
+	for _, args := range [][]string{
+		{"daemon-reload"},
+		{"enable", Name},
+		{"restart", Name},
+	} {
+		if err := systemctl(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Uninstall stops and disables the unit and removes its files.
+func Uninstall() error {
+	if _, err := os.Stat(SystemdUnitPath); os.IsNotExist(err) {
+		return fmt.Errorf("service is not installed")
+	}
+	if err := systemctl("disable", "--now", Name); err != nil {
+		return err
+	}
+	for _, path := range []string{SystemdUnitPath, SystemdEnvPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return systemctl("daemon-reload")
+}
+
+// IsService reports whether the process needs a service manager run loop.
+// systemd delivers SIGTERM, so the regular signal handling suffices.
+func IsService() bool {
+	return false
+}
+
+// Run is only needed on Windows; elsewhere it just runs the agent.
+func Run(run func(ctx context.Context) error) error {
+	return run(context.Background())
+}
+
+// systemctl runs a systemctl subcommand, returning its output on failure.
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput() // #nosec G204
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RecentLogs returns the unit's recent journal, for diagnostics bundles.
+func RecentLogs() ([]byte, error) {
+	out, err := exec.Command("journalctl", "-u", Name, "-n", "2000", "--no-pager", "-o", "short-iso").Output() // #nosec G204
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	if len(out) > maxServiceLogBytes {
+		out = out[len(out)-maxServiceLogBytes:]
+	}
+	return out, nil
+}
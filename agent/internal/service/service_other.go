@@ -0,0 +1,34 @@
+//go:build !windows && !darwin && !linux
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// Install is not supported here; run the agent as a container instead.
+func Install(binaryPath string, env map[string]string) error {
+	return fmt.Errorf("service install is not supported on %s; run the agent container instead", runtime.GOOS)
+}
+
+// Uninstall is not supported here; see Install.
+func Uninstall() error {
+	return fmt.Errorf("service uninstall is not supported on %s", runtime.GOOS)
+}
+
+// IsService reports whether the process needs a service manager run loop.
+func IsService() bool {
+	return false
+}
+
+// Run is only needed on Windows; elsewhere it just runs the agent.
+func Run(run func(ctx context.Context) error) error {
+	return run(context.Background())
+}
+
+// RecentLogs is not available; see Install.
+func RecentLogs() ([]byte, error) {
+	return nil, fmt.Errorf("service logs are not available on %s", runtime.GOOS)
+}
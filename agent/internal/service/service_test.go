@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnvironment_IncludesSetKeysAndDataPath(t *testing.T) {
+	t.Setenv("DOCKMON_URL", "https://dockmon.example")
+	t.Setenv("AGENT_NAME", "")
+	t.Setenv("DATA_PATH", "/ignored")
+
+	env := Environment("/var/lib/dockmon")
+
+	if env["DOCKMON_URL"] != "https://dockmon.example" {
+		t.Errorf("DOCKMON_URL = %q", env["DOCKMON_URL"])
+	}
+	if _, ok := env["AGENT_NAME"]; ok {
+		t.Error("empty AGENT_NAME should be omitted")
+	}
+	if env["DATA_PATH"] != "/var/lib/dockmon" {
+		t.Errorf("DATA_PATH = %q, want resolved data path", env["DATA_PATH"])
+	}
+}
+
+func TestRenderLaunchdPlist(t *testing.T) {
+	plist := string(renderLaunchdPlist("/usr/local/bin/dockmon-agent", map[string]string{
+		"REGISTRATION_TOKEN": "a<b&c",
+		"DOCKMON_URL":        "https://dockmon.example",
+	}))
+
+	for _, want := range []string{
+		"<string>" + LaunchdLabel + "</string>",
+		"<string>/usr/local/bin/dockmon-agent</string>",
+		"<string>a&lt;b&amp;c</string>",
+		"<key>KeepAlive</key>\n\t<true/>",
+		"<string>" + LaunchdLogPath + "</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+
+	// Keys are sorted so reinstalling produces an identical file
+	if strings.Index(plist, "DOCKMON_URL") > strings.Index(plist, "REGISTRATION_TOKEN") {
+		t.Error("environment keys not sorted")
+	}
+}
+
+func TestRenderSystemdUnit(t *testing.T) {
+	unit := string(renderSystemdUnit("/opt/dock mon/dockmon-agent", "/var/lib/dockmon-agent"))
+
+	for _, want := range []string{
+		"EnvironmentFile=" + SystemdEnvPath + "\n",
+		`ExecStart="/opt/dock mon/dockmon-agent"` + "\n",
+		"Restart=always\n",
+		"Requires=docker.service\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestRenderSystemdEnvFile(t *testing.T) {
+	env, err := renderSystemdEnvFile(map[string]string{
+		"DOCKMON_URL": "https://dockmon.example",
+		"AGENT_NAME":  "Bob's NAS",
+	})
+	if err != nil {
+		t.Fatalf("renderSystemdEnvFile: %v", err)
+	}
+	if want := "AGENT_NAME=Bob's NAS\nDOCKMON_URL=https://dockmon.example\n"; string(env) != want {
+		t.Errorf("env file = %q, want %q", env, want)
+	}
+
+	for _, bad := range []string{"a\nREGISTRATION_TOKEN=x", `quo"te`, `back\slash`} {
+		if _, err := renderSystemdEnvFile(map[string]string{"AGENT_NAME": bad}); err == nil {
+			t.Errorf("value %q should be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+//go:build windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout bounds how long a service stop waits for graceful shutdown
+const stopTimeout = 20 * time.Second
+
+// Install registers the agent as an auto-start Windows service and starts
+// it. The service manager restarts it whenever it exits with an error,
+// which native self-update relies on to start the new binary. Must be run
+// from an elevated prompt.
+func Install(binaryPath string, env map[string]string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(Name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists; uninstall it first", Name)
+	}
+
+	s, err := m.CreateService(Name, binaryPath, mgr.Config{
+		DisplayName: DisplayName,
+		Description: Description,
+		StartType:   mgr.StartAutomatic,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	if err := setServiceEnvironment(env); err != nil {
+		_ = s.Delete()
+		return err
+	}
+
+	// Restart after any failure, including a clean exit with a non-zero code
+	actions := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 15 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 60 * time.Second},
+	}
+	if err := s.SetRecoveryActions(actions, uint32((24 * time.Hour).Seconds())); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+	if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("failed to set recovery actions: %w", err)
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("service installed but failed to start: %w", err)
+	}
+	return nil
+}
+
+// Uninstall stops and removes the Windows service.
+func Uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(Name)
+	if err != nil {
+		return fmt.Errorf("service is not installed")
+	}
+	defer s.Close()
+
+	// Best-effort stop; deletion completes once the service has stopped
+	_, _ = s.Control(svc.Stop)
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to delete service: %w", err)
+	}
+	return nil
+}
+
+// IsService reports whether the process was started by the Windows
+// service manager.
+func IsService() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
+
+// Run runs the agent under the Windows service manager. run's context is
+// cancelled when the service is stopped; a run error is reported as a
+// service failure so the recovery actions restart it.
+func Run(run func(ctx context.Context) error) error {
+	return svc.Run(Name, &handler{run: run})
+}
+
+// handler adapts run to the service control protocol.
+type handler struct {
+	run func(ctx context.Context) error
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return true, 1 // Service-specific failure: triggers recovery
+			}
+			return false, 0
+
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				select {
+				case <-done:
+				case <-time.After(stopTimeout):
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// setServiceEnvironment stores the agent settings in the service's registry
+// key, which the service manager passes to the process as its environment.
+func setServiceEnvironment(env map[string]string) error {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		`SYSTEM\CurrentControlSet\Services\`+Name, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("failed to open service registry key: %w", err)
+	}
+	defer key.Close()
+
+	values := make([]string, 0, len(env))
+	for _, k := range sortedKeys(env) {
+		values = append(values, k+"="+env[k])
+	}
+	if err := key.SetStringsValue("Environment", values); err != nil {
+		return fmt.Errorf("failed to set service environment: %w", err)
+	}
+	return nil
+}
+
+// RecentLogs is not available: the Windows service's output is not kept.
+func RecentLogs() ([]byte, error) {
+	return nil, fmt.Errorf("service logs are not kept on windows")
+}
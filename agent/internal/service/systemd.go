@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	// SystemdUnitPath is where the agent's systemd unit is installed
+	SystemdUnitPath = "/etc/systemd/system/" + Name + ".service"
+	// SystemdEnvDir holds the root-only environment file
+	SystemdEnvDir = "/etc/" + Name
+	// SystemdEnvPath is the unit's EnvironmentFile; it holds the settings
+	// (tokens included), so it is kept out of the world-readable unit
+	SystemdEnvPath = SystemdEnvDir + "/agent.env"
+)
+
+// renderSystemdUnit builds the unit written by scripts/install-agent.sh.
+// Restart=always makes systemd start the new binary after native
+// self-update exits the agent.
+func renderSystemdUnit(binaryPath, dataPath string) []byte {
+	execStart := binaryPath
+	if strings.ContainsAny(binaryPath, " \t") {
+		execStart = `"` + binaryPath + `"`
+	}
+
+	var b bytes.Buffer
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=" + DisplayName + "\n")
+	b.WriteString("Documentation=https://github.com/darthnorse/dockmon\n")
+	b.WriteString("After=network-online.target docker.service\n")
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("Requires=docker.service\n\n")
+
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	b.WriteString("EnvironmentFile=" + SystemdEnvPath + "\n")
+	b.WriteString("ExecStart=" + execStart + "\n")
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=10\n\n")
+
+	b.WriteString("# Hardening (conservative subset - verified safe with Docker socket access).\n")
+	b.WriteString("NoNewPrivileges=true\n")
+	b.WriteString("ProtectHome=true\n")
+	b.WriteString("PrivateTmp=true\n")
+	b.WriteString("# Stricter confinement must be validated per host before enabling:\n")
+	b.WriteString("#ProtectSystem=strict\n")
+	b.WriteString("#ReadWritePaths=" + dataPath + "\n\n")
+
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.Bytes()
+}
+
+// renderSystemdEnvFile builds the EnvironmentFile as plain KEY=value lines.
+// Values that systemd would split or unquote (control characters, double
+// quotes, backslashes) are rejected rather than escaped, like the install script.
+func renderSystemdEnvFile(env map[string]string) ([]byte, error) {
+	var b bytes.Buffer
+	for _, key := range sortedKeys(env) {
+		value := env[key]
+		if strings.ContainsAny(value, `"\`) || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("%s contains characters not allowed in a systemd environment file (double quotes, backslashes or control characters)", key)
+		}
+		b.WriteString(key + "=" + value + "\n")
+	}
+	return b.Bytes(), nil
+}
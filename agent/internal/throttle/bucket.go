@@ -0,0 +1,103 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxReadChunk bounds a single limited read, so a large buffer doesn't turn
+// into one long burst followed by a long wait
+const maxReadChunk = 32 * 1024
+
+// Bucket is a token bucket limiting a byte rate, with a burst of one second
+// worth of bytes. Callers that overdraw it wait off the debt, so the
+// long-run rate holds however the bytes are chunked. A rate of 0 means
+// unlimited.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64 // Bytes per second
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time                                 // Injectable clock for tests
+	sleep func(ctx context.Context, d time.Duration) error // Injectable for tests
+}
+
+// NewBucket creates a bucket for rate bytes per second
+func NewBucket(rate int64) *Bucket {
+	b := &Bucket{now: time.Now, sleep: sleepContext}
+	b.SetRate(rate)
+	return b
+}
+
+// SetRate changes the rate; the bucket starts full at the new rate
+func (b *Bucket) SetRate(rate int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rate = float64(rate)
+	b.tokens = b.rate
+	b.last = b.now()
+}
+
+// Wait takes n bytes from the bucket, blocking until the rate allows them
+func (b *Bucket) Wait(ctx context.Context, n int64) error {
+	b.mu.Lock()
+	if b.rate <= 0 || n <= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	return b.sleep(ctx, wait)
+}
+
+// Reader returns r with reads limited to the bucket's rate
+func (b *Bucket) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &bucketReader{ctx: ctx, r: r, bucket: b}
+}
+
+type bucketReader struct {
+	ctx    context.Context
+	r      io.Reader
+	bucket *Bucket
+}
+
+func (br *bucketReader) Read(p []byte) (int, error) {
+	if len(p) > maxReadChunk {
+		p = p[:maxReadChunk]
+	}
+	n, err := br.r.Read(p)
+	if n > 0 {
+		if waitErr := br.bucket.Wait(br.ctx, int64(n)); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+)
+
+// Limiter caps how many operations run at once. The limit can be changed
+// while operations are running; 0 means unlimited.
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	changed chan struct{} // Closed, and replaced, whenever a slot may be free
+}
+
+// NewLimiter creates a limiter allowing limit concurrent operations
+func NewLimiter(limit int) *Limiter {
+	return &Limiter{limit: limit, changed: make(chan struct{})}
+}
+
+// SetLimit changes the limit. Raising it lets waiting callers through right
+// away; lowering it doesn't interrupt operations already running.
+func (l *Limiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notifyLocked()
+}
+
+// Acquire waits for a free slot. The caller must Release it.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.limit <= 0 || l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notifyLocked()
+}
+
+// Active returns the number of slots in use
+func (l *Limiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+func (l *Limiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package throttle
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ioprio_set arguments, from linux/ioprio.h
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+	ioprioClassNone  = 0
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// setPriority sets the niceness of every thread of the agent process
+func setPriority(nice int) error {
+	return forEachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice)
+	})
+}
+
+// setIOPriority sets the I/O scheduling class of every thread of the agent
+// process. The default class lets I/O priority follow niceness again.
+func setIOPriority(class string, level int) error {
+	prio := ioprioClassNone << ioprioClassShift
+	switch class {
+	case IOClassBestEffort:
+		prio = ioprioClassBE<<ioprioClassShift | level
+	case IOClassIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	}
+	return forEachThread(func(tid int) error {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
+
+// forEachThread calls fn for each thread of the process. Linux keeps both
+// priorities per thread; threads started later inherit them from the thread
+// that creates them.
+func forEachThread(fn func(tid int) error) error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Threads can exit between listing and the call
+		if err := fn(tid); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
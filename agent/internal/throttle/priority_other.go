@@ -0,0 +1,11 @@
+//go:build !linux
+
+package throttle
+
+func setPriority(nice int) error {
+	return errUnsupported
+}
+
+func setIOPriority(class string, level int) error {
+	return errUnsupported
+}
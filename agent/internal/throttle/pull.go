@@ -0,0 +1,148 @@
+package throttle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// ClientOption returns a Docker client option that routes the client's API
+// calls through the throttler: each call holds a slot for the
+// MaxDockerCalls limit until its response headers arrive, and image pulls
+// are paced to the pull bandwidth.
+func (t *Throttler) ClientOption() client.Opt {
+	return func(c *client.Client) error {
+		hc := c.HTTPClient()
+		base := hc.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		// The client only knows to speak TLS while it can see its own
+		// transport, so carry the scheme over before wrapping it
+		if tr, ok := base.(*http.Transport); ok && tr.TLSClientConfig != nil {
+			if err := client.WithScheme("https")(c); err != nil {
+				return err
+			}
+		}
+		hc.Transport = &transport{base: base, throttler: t}
+		return client.WithHTTPClient(hc)(c)
+	}
+}
+
+// transport applies the throttler's limits to Docker API requests
+type transport struct {
+	base      http.RoundTripper
+	throttler *Throttler
+}
+
+func (tr *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := tr.throttler.calls.Acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := tr.base.RoundTrip(req)
+	tr.throttler.calls.Release()
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/images/create") {
+		resp.Body = newPullReader(req.Context(), resp.Body, tr.throttler.pulls)
+	}
+	return resp, nil
+}
+
+// CloseIdleConnections lets the client's Close reach the wrapped transport
+func (tr *transport) CloseIdleConnections() {
+	if c, ok := tr.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// pullReader paces an image pull. The daemon does the download itself, so
+// the agent can only limit it indirectly: the progress stream reports bytes
+// fetched per layer, and the reader charges those to the bucket before
+// handing the line on. Once the bucket runs dry the agent stops reading, and
+// the daemon, which can't write progress, slows its download. The limit is
+// therefore approximate, and bursts of up to the daemon's buffering get
+// through.
+type pullReader struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	lines   *bufio.Reader
+	bucket  *Bucket
+	fetched map[string]int64 // Bytes charged so far, by layer ID
+	pending []byte
+}
+
+// pullProgress is the subset of a pull progress message the reader uses
+type pullProgress struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+func newPullReader(ctx context.Context, body io.ReadCloser, bucket *Bucket) *pullReader {
+	return &pullReader{
+		ctx:     ctx,
+		body:    body,
+		lines:   bufio.NewReader(body),
+		bucket:  bucket,
+		fetched: make(map[string]int64),
+	}
+}
+
+func (r *pullReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		line, err := r.lines.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+		if waitErr := r.bucket.Wait(r.ctx, r.charge(line)); waitErr != nil {
+			return 0, waitErr
+		}
+		r.pending = line
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// charge returns the bytes fetched since the last progress line for the
+// same layer
+func (r *pullReader) charge(line []byte) int64 {
+	var msg pullProgress
+	if err := json.Unmarshal(line, &msg); err != nil || msg.ID == "" {
+		return 0
+	}
+	var fetched int64
+	switch msg.Status {
+	case "Downloading":
+		fetched = msg.ProgressDetail.Current
+	case "Download complete":
+		// The final progress line before completion is often skipped, so
+		// charge whatever of the layer hasn't been yet
+		fetched = r.fetched[msg.ID]
+		if msg.ProgressDetail.Total > fetched {
+			fetched = msg.ProgressDetail.Total
+		}
+	default:
+		return 0
+	}
+	delta := fetched - r.fetched[msg.ID]
+	if delta <= 0 {
+		return 0
+	}
+	r.fetched[msg.ID] = fetched
+	return delta
+}
+
+func (r *pullReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,146 @@
+// Package throttle keeps the agent from starving the workloads it manages on
+// small hosts: it caps concurrent Docker API calls, limits the bandwidth of
+// image pulls and self-update downloads, and can lower the agent's CPU and
+// I/O priority.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// I/O scheduling classes
+const (
+	IOClassDefault    = ""
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// errUnsupported is returned when process priorities can't be changed on
+// this platform
+var errUnsupported = errors.New("process priority is not supported on this platform")
+
+// Settings are the agent's resource limits. Zero values leave the matching
+// limit off.
+type Settings struct {
+	MaxDockerCalls int    `json:"max_docker_calls"` // Concurrent Docker API calls
+	PullBandwidth  int64  `json:"pull_bandwidth"`   // Bytes per second
+	Nice           int    `json:"nice"`             // CPU niceness, -20 to 19
+	IOClass        string `json:"io_class,omitempty"`
+	IOLevel        int    `json:"io_level,omitempty"` // best-effort level, 0 (highest) to 7; ignored in other classes
+}
+
+// Validate checks that the settings are in range
+func (s Settings) Validate() error {
+	if s.MaxDockerCalls < 0 {
+		return fmt.Errorf("max_docker_calls must not be negative")
+	}
+	if s.PullBandwidth < 0 {
+		return fmt.Errorf("pull_bandwidth must not be negative")
+	}
+	if s.Nice < -20 || s.Nice > 19 {
+		return fmt.Errorf("nice must be between -20 and 19 (got %d)", s.Nice)
+	}
+	switch s.IOClass {
+	case IOClassDefault, IOClassIdle:
+	case IOClassBestEffort:
+		if s.IOLevel < 0 || s.IOLevel > 7 {
+			return fmt.Errorf("io_level must be between 0 and 7 (got %d)", s.IOLevel)
+		}
+	default:
+		return fmt.Errorf("io_class must be %q or %q (got %q)", IOClassBestEffort, IOClassIdle, s.IOClass)
+	}
+	return nil
+}
+
+// Throttler holds the agent's current limits. They can be changed at any
+// time with Apply; calls and transfers in progress pick up the new limits.
+type Throttler struct {
+	mu       sync.Mutex
+	settings Settings
+	calls    *Limiter
+	pulls    *Bucket
+	log      *logrus.Logger
+}
+
+// New creates a throttler with every limit off
+func New(log *logrus.Logger) *Throttler {
+	return &Throttler{
+		calls: NewLimiter(0),
+		pulls: NewBucket(0),
+		log:   log,
+	}
+}
+
+// Apply switches to new settings. The call and bandwidth limits always take
+// effect; if the process priority can't be changed (e.g. lowering niceness
+// without CAP_SYS_NICE) the previous priority is kept and the error returned.
+func (t *Throttler) Apply(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.calls.SetLimit(s.MaxDockerCalls)
+	t.pulls.SetRate(s.PullBandwidth)
+	t.settings.MaxDockerCalls = s.MaxDockerCalls
+	t.settings.PullBandwidth = s.PullBandwidth
+
+	// Only a configured or changed I/O class touches the I/O priority
+	if s.IOClass != IOClassBestEffort {
+		s.IOLevel = 0
+	}
+
+	var errs []error
+	if s.Nice != t.settings.Nice {
+		if err := setPriority(s.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("set nice %d: %w", s.Nice, err))
+		} else {
+			t.settings.Nice = s.Nice
+		}
+	}
+	if s.IOClass != t.settings.IOClass || s.IOLevel != t.settings.IOLevel {
+		if err := setIOPriority(s.IOClass, s.IOLevel); err != nil {
+			errs = append(errs, fmt.Errorf("set I/O class %q: %w", s.IOClass, err))
+		} else {
+			t.settings.IOClass = s.IOClass
+			t.settings.IOLevel = s.IOLevel
+		}
+	}
+
+	t.log.WithFields(logrus.Fields{
+		"max_docker_calls": t.settings.MaxDockerCalls,
+		"pull_bandwidth":   t.settings.PullBandwidth,
+		"nice":             t.settings.Nice,
+		"io_class":         t.settings.IOClass,
+	}).Info("Resource limits applied")
+	return errors.Join(errs...)
+}
+
+// Settings returns the settings in effect
+func (t *Throttler) Settings() Settings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.settings
+}
+
+// ActiveDockerCalls returns how many Docker API calls hold a slot
+func (t *Throttler) ActiveDockerCalls() int {
+	return t.calls.Active()
+}
+
+// LimitReader limits reads from r to the pull bandwidth. Safe to call on a
+// nil Throttler, which returns r unchanged.
+func (t *Throttler) LimitReader(ctx context.Context, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return t.pulls.Reader(ctx, r)
+}
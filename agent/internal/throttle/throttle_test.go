@@ -0,0 +1,199 @@
+package throttle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// newTestBucket returns a bucket on a fake clock that records its sleeps
+// instead of waiting
+func newTestBucket(rate int64) (*Bucket, *[]time.Duration, func(time.Duration)) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	var slept []time.Duration
+	b := &Bucket{now: func() time.Time { return now }}
+	b.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	b.SetRate(rate)
+	return b, &slept, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Settings
+		wantErr bool
+	}{
+		{"off", Settings{}, false},
+		{"all set", Settings{MaxDockerCalls: 2, PullBandwidth: 1 << 20, Nice: 10, IOClass: IOClassBestEffort, IOLevel: 7}, false},
+		{"idle", Settings{IOClass: IOClassIdle}, false},
+		{"negative calls", Settings{MaxDockerCalls: -1}, true},
+		{"negative bandwidth", Settings{PullBandwidth: -1}, true},
+		{"nice too high", Settings{Nice: 20}, true},
+		{"level out of range", Settings{IOClass: IOClassBestEffort, IOLevel: 8}, true},
+		{"unknown class", Settings{IOClass: "realtime"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.s.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestApplyIgnoresLevelWithoutBestEffort verifies an I/O level on its own
+// leaves the I/O priority alone
+func TestApplyIgnoresLevelWithoutBestEffort(t *testing.T) {
+	th := New(quietLogger())
+	if err := th.Apply(Settings{IOLevel: 4}); err != nil {
+		t.Fatalf("Apply() = %v, want no priority change attempted", err)
+	}
+	if got := th.Settings(); got.IOClass != IOClassDefault || got.IOLevel != 0 {
+		t.Errorf("Settings() = %+v, want the I/O priority unset", got)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Acquire over the limit: err = %v", err)
+	}
+
+	// Raising the limit lets a waiting caller through
+	done := make(chan error, 1)
+	go func() { done <- l.Acquire(context.Background()) }()
+	l.SetLimit(2)
+	if err := <-done; err != nil || l.Active() != 2 {
+		t.Fatalf("after raising the limit: err = %v, active = %d", err, l.Active())
+	}
+
+	l.SetLimit(1)
+	go func() { done <- l.Acquire(context.Background()) }()
+	l.Release()
+	select {
+	case <-done:
+		t.Fatal("Acquire should wait until active calls drop below the lowered limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.Release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	b, slept, advance := newTestBucket(1000)
+
+	// The first second's worth passes, then the debt has to be waited off
+	b.Wait(context.Background(), 1000)
+	b.Wait(context.Background(), 500)
+	if len(*slept) != 1 || (*slept)[0] != 500*time.Millisecond {
+		t.Fatalf("slept %v, want 500ms", *slept)
+	}
+
+	advance(2 * time.Second)
+	b.Wait(context.Background(), 1000)
+	if len(*slept) != 1 {
+		t.Errorf("refilled bucket should not wait, slept %v", *slept)
+	}
+
+	b.SetRate(0)
+	b.Wait(context.Background(), 1<<30)
+	if len(*slept) != 1 {
+		t.Errorf("unlimited bucket should not wait, slept %v", *slept)
+	}
+}
+
+func TestPullReaderCharges(t *testing.T) {
+	b, _, _ := newTestBucket(0)
+	r := newPullReader(context.Background(), io.NopCloser(strings.NewReader("")), b)
+
+	lines := []struct {
+		line string
+		want int64
+	}{
+		{`{"status":"Pulling from library/alpine","id":"latest"}`, 0},
+		{`{"status":"Downloading","progressDetail":{"current":100,"total":300},"id":"a"}`, 100},
+		{`{"status":"Downloading","progressDetail":{"current":250,"total":300},"id":"a"}`, 150},
+		{`{"status":"Downloading","progressDetail":{"current":40,"total":90},"id":"b"}`, 40},
+		{`{"status":"Download complete","progressDetail":{},"id":"a"}`, 0},
+		{`{"status":"Download complete","progressDetail":{"total":90},"id":"b"}`, 50},
+		{`{"status":"Extracting","progressDetail":{"current":300,"total":300},"id":"a"}`, 0},
+		{`not json`, 0},
+	}
+	for _, tt := range lines {
+		if got := r.charge([]byte(tt.line)); got != tt.want {
+			t.Errorf("charge(%s) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestClientOptionLimitsCalls(t *testing.T) {
+	var mu sync.Mutex
+	active, peak := 0, 0
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		if active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		active--
+		mu.Unlock()
+		w.Header().Set("Api-Version", "1.41")
+		w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+
+	th := New(quietLogger())
+	if err := th.Apply(Settings{MaxDockerCalls: 1}); err != nil {
+		t.Fatal(err)
+	}
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.41"), th.ClientOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli.Ping(context.Background())
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := th.ActiveDockerCalls(); got != 1 {
+		t.Errorf("active calls = %d, want 1", got)
+	}
+	close(release)
+	wg.Wait()
+	if peak != 1 {
+		t.Errorf("daemon saw %d concurrent calls, want 1", peak)
+	}
+}
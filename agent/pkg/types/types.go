@@ -21,6 +21,7 @@ type Message struct {
 	Command       string            `json:"command,omitempty"`
 	Payload       interface{}       `json:"payload,omitempty"`
 	Error         string            `json:"error,omitempty"`
+	ErrorCode     string            `json:"error_code,omitempty"` // e.g. "TIMEOUT"
 	Timestamp     time.Time         `json:"timestamp"`
 }
 
@@ -97,6 +98,18 @@ type ContainerEvent struct {
 	Status        string            `json:"status,omitempty"`
 	Timestamp     time.Time         `json:"timestamp"`
 	Attributes    map[string]string `json:"attributes,omitempty"`
+	Maintenance   bool              `json:"maintenance,omitempty"` // Host was in maintenance mode
+}
+
+// ResourceEvent represents a Docker image, volume or network event
+type ResourceEvent struct {
+	Type        string            `json:"type"`   // image, volume, network
+	Action      string            `json:"action"` // pull, delete, create, destroy, ...
+	ActorID     string            `json:"actor_id"`
+	Name        string            `json:"name"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Maintenance bool              `json:"maintenance,omitempty"`
 }
 
 // ShellSessionCommand represents a shell session command from the backend
@@ -598,7 +598,10 @@ class AgentCommandExecutor:
         """
         Classify error code from agent response.
 
-        Agent can provide 'error_type' field to indicate specific error types.
+        Agent can provide 'error_type' field to indicate specific error types,
+        and sets 'error_code' to "TIMEOUT" when a command missed its deadline
+        or "RESPONSE_TOO_LARGE" when its response exceeded the agent's size
+        limit (retrying won't shrink it).
 
         Args:
             response: Response dict from agent
@@ -606,6 +609,11 @@ class AgentCommandExecutor:
         Returns:
             CommandErrorCode: Classified error code
         """
+        if response.get("error_code") == "TIMEOUT":
+            return CommandErrorCode.TIMEOUT
+        if response.get("error_code") == "RESPONSE_TOO_LARGE":
+            return CommandErrorCode.INVALID_RESPONSE
+
         # Check if agent provided error_type
         error_type = response.get("error_type")
 
@@ -45,6 +45,9 @@ class AgentConnectionManager:
             return
 
         self.connections: Dict[str, WebSocket] = {}  # agent_id -> WebSocket
+        # agent_id -> last connection stats from the agent's heartbeat, kept
+        # after it disconnects so the reason can be looked up while it's offline
+        self.connection_stats: Dict[str, dict] = {}
         self._connection_lock = asyncio.Lock()
         self.db_manager = DatabaseManager()  # For creating short-lived sessions
         self._initialized = True
@@ -169,6 +172,21 @@ class AgentConnectionManager:
         """Get number of connected agents"""
         return len(self.connections)
 
+    def record_connection_stats(self, agent_id: str, stats: dict):
+        """Store the connection stats from an agent's heartbeat"""
+        self.connection_stats[agent_id] = {
+            **stats,
+            "reported_at": datetime.now(timezone.utc).isoformat(),
+        }
+
+    def get_connection_stats(self, agent_id: str) -> Optional[dict]:
+        """Get the last connection stats an agent reported, if any"""
+        return self.connection_stats.get(agent_id)
+
+    def forget_connection_stats(self, agent_id: str):
+        """Drop an agent's connection stats once its host is removed"""
+        self.connection_stats.pop(agent_id, None)
+
 
 # Global singleton instance
 agent_connection_manager = AgentConnectionManager()
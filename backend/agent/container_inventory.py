@@ -0,0 +1,122 @@
+"""
+Agent Container Inventory for DockMon
+
+Caches each agent's container list so discovery doesn't request a full
+list_containers every cycle.
+
+Architecture:
+- Discovery subscribes with list_containers {"subscribe": true}; the agent
+  replies with a revisioned snapshot
+- The agent then pushes container_inventory_delta events (added/updated/
+  removed) driven by its Docker event stream plus periodic reconciliation
+- A delta whose base_revision doesn't match the cached revision means one was
+  lost; the cache is dropped and the next discovery cycle resubscribes
+- The cache is also dropped when the agent (re)connects, since the agent's
+  subscription is per connection, and refreshed after MAX_SNAPSHOT_AGE as a
+  safety net
+"""
+
+import logging
+import threading
+import time
+from dataclasses import dataclass
+from typing import Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Resubscribe (full snapshot) at least this often even if deltas keep flowing
+MAX_SNAPSHOT_AGE_SECONDS = 600
+
+
+@dataclass
+class _AgentInventory:
+    revision: int
+    containers: Dict[str, dict]  # full container ID -> Docker API container dict
+    snapshot_at: float
+
+
+class ContainerInventory:
+    """Per-agent container inventories maintained from snapshots and deltas."""
+
+    def __init__(self):
+        self._inventories: Dict[str, _AgentInventory] = {}
+        self._lock = threading.Lock()
+
+    def apply_snapshot(self, agent_id: str, revision: int, containers: List[dict]) -> None:
+        """Replace an agent's inventory with a subscription snapshot."""
+        by_id = {c.get("Id", ""): c for c in containers if c.get("Id")}
+        with self._lock:
+            self._inventories[agent_id] = _AgentInventory(
+                revision=revision, containers=by_id, snapshot_at=time.monotonic()
+            )
+        logger.debug(f"Container inventory snapshot for agent {agent_id[:8]}...: rev {revision}, {len(by_id)} containers")
+
+    def apply_delta(self, agent_id: str, delta: dict) -> bool:
+        """
+        Apply an incremental delta.
+
+        Returns:
+            False if the delta doesn't continue the cached revision (the
+            inventory is dropped so discovery resubscribes), True otherwise.
+        """
+        with self._lock:
+            inventory = self._inventories.get(agent_id)
+            if inventory is None:
+                # Not subscribed (yet); nothing to keep in sync
+                return False
+
+            base_revision = delta.get("base_revision")
+            if base_revision != inventory.revision:
+                logger.info(
+                    f"Container inventory gap for agent {agent_id[:8]}...: "
+                    f"have rev {inventory.revision}, delta based on {base_revision}; resubscribing"
+                )
+                del self._inventories[agent_id]
+                return False
+
+            for container in (delta.get("added") or []) + (delta.get("updated") or []):
+                container_id = container.get("Id")
+                if container_id:
+                    inventory.containers[container_id] = container
+            for container_id in delta.get("removed") or []:
+                inventory.containers.pop(container_id, None)
+
+            inventory.revision = delta.get("revision", inventory.revision)
+            return True
+
+    def get(self, agent_id: str) -> Optional[List[dict]]:
+        """
+        Return the agent's containers, or None if there is no usable
+        subscription (never subscribed, dropped, or snapshot too old).
+        """
+        with self._lock:
+            inventory = self._inventories.get(agent_id)
+            if inventory is None:
+                return None
+            if time.monotonic() - inventory.snapshot_at > MAX_SNAPSHOT_AGE_SECONDS:
+                del self._inventories[agent_id]
+                return None
+            return list(inventory.containers.values())
+
+    def drop(self, agent_id: str) -> None:
+        """Forget an agent's inventory (agent connected or disconnected)."""
+        with self._lock:
+            self._inventories.pop(agent_id, None)
+
+
+_container_inventory_instance: Optional[ContainerInventory] = None
+
+
+def get_container_inventory() -> ContainerInventory:
+    """
+    Get the global ContainerInventory singleton instance.
+
+    Returns:
+        ContainerInventory: Global instance
+    """
+    global _container_inventory_instance
+
+    if _container_inventory_instance is None:
+        _container_inventory_instance = ContainerInventory()
+
+    return _container_inventory_instance
@@ -374,7 +374,81 @@ class AgentContainerOperations:
 
         return self._handle_operation_result(result, "remove", host_id, container_id)
 
-    async def get_container_logs(self, host_id: str, container_id: str, tail: int = 100) -> str:
+    async def adopt_into_stack(
+        self,
+        host_id: str,
+        container_id: str,
+        project: str,
+        service: str,
+        working_dir: Optional[str] = None,
+        config_files: Optional[str] = None
+    ) -> bool:
+        """
+        Adopt a standalone container into a compose stack via agent.
+
+        The agent recreates the container from its current image with the
+        stack's compose labels, in the background.
+
+        Args:
+            host_id: Docker host ID
+            container_id: Container ID
+            project: Compose project to adopt into
+            service: Service name the container takes in the project
+            working_dir: Where the stack's compose file lives (optional)
+            config_files: Comma-separated compose file paths (optional)
+
+        Returns:
+            True if the agent started the adoption
+
+        Raises:
+            HTTPException: If safety check fails, agent not found, or command fails
+        """
+        # Safety check: recreating DockMon would take it down
+        if await self._is_dockmon_container(host_id, container_id):
+            raise HTTPException(
+                status_code=403,
+                detail="Cannot adopt DockMon itself into a stack."
+            )
+
+        agent_id = self._get_agent_for_host(host_id)
+        if not agent_id:
+            raise HTTPException(
+                status_code=404,
+                detail=f"No agent registered for host {host_id}"
+            )
+
+        payload = {
+            "container_id": container_id,
+            "project": project,
+            "service": service,
+        }
+        if working_dir:
+            payload["working_dir"] = working_dir
+        if config_files:
+            payload["config_files"] = config_files
+
+        command = {
+            "type": "command",
+            "command": "adopt_into_stack",
+            "payload": payload
+        }
+
+        result = await self.command_executor.execute_command(
+            agent_id,
+            command,
+            timeout=30.0
+        )
+
+        return self._handle_operation_result(result, "adopt", host_id, container_id)
+
+    async def get_container_logs(
+        self,
+        host_id: str,
+        container_id: str,
+        tail: int = 100,
+        timestamps: Optional[str] = None,
+        tz: Optional[str] = None,
+    ) -> str:
         """
         Get container logs via agent.
 
@@ -382,6 +456,9 @@ class AgentContainerOperations:
             host_id: Docker host ID
             container_id: Container ID
             tail: Number of lines to retrieve (default: 100)
+            timestamps: How the agent renders line timestamps (utc, none,
+                local or relative); omitted means utc
+            tz: IANA time zone for local timestamps
 
         Returns:
             Container logs as string
@@ -398,13 +475,18 @@ class AgentContainerOperations:
             )
 
         # Send get_logs command
+        payload = {
+            "action": "get_logs",
+            "container_id": container_id,
+            "tail": tail
+        }
+        if timestamps:
+            payload["timestamps"] = timestamps
+        if tz:
+            payload["tz"] = tz
         command = {
             "type": "container_operation",
-            "payload": {
-                "action": "get_logs",
-                "container_id": container_id,
-                "tail": tail
-            }
+            "payload": payload
         }
 
         result = await self.command_executor.execute_command(
@@ -970,12 +1052,14 @@ class AgentContainerOperations:
                 detail=f"Failed to remove image: {result.error}"
             )
 
-    async def prune_images(self, host_id: str) -> Dict[str, Any]:
+    async def prune_images(self, host_id: str, policy: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
         """
         Prune unused images via agent.
 
         Args:
             host_id: Docker host ID
+            policy: Optional safety rules (keep_last_tags, dry_run); the
+                agent then returns its full prune report
 
         Returns:
             Dict with removed_count and space_reclaimed
@@ -990,10 +1074,18 @@ class AgentContainerOperations:
                 detail=f"No agent registered for host {host_id}"
             )
 
+        # Agents that predate prune policies ignore the payload and run the
+        # daemon's full prune, so a dry run or kept tags would delete images.
+        if policy is not None and not self.agent_manager.agent_has_capability(host_id, "image_prune_policies"):
+            raise HTTPException(
+                status_code=501,
+                detail="This host's agent is too old for policy-based image prunes. Update the agent to the latest version."
+            )
+
         command = {
             "type": "command",
             "command": "prune_images",
-            "payload": {}
+            "payload": {"policy": policy} if policy is not None else {}
         }
 
         result = await self.command_executor.execute_command(
@@ -1007,6 +1099,8 @@ class AgentContainerOperations:
             removed_count = data.get('removed_count', 0)
             space_reclaimed = data.get('space_reclaimed', 0)
             logger.info(f"Pruned {removed_count} images from agent host {host_id}, reclaimed {space_reclaimed} bytes")
+            if policy is not None:
+                return data
             return {
                 'removed_count': removed_count,
                 'space_reclaimed': space_reclaimed
@@ -17,11 +17,51 @@ from sqlalchemy.orm import Session
 from sqlalchemy.exc import IntegrityError
 
 from database import RegistrationToken, Agent, DockerHostDB, DatabaseManager
+from utils.encryption import decrypt_password, encrypt_password
 from utils.host_ips import serialize_registration_host_ip
 
 logger = logging.getLogger(__name__)
 
 
+def issue_identity_proof(agent_id: str, host_id: str, engine_id: str):
+    """
+    Sign the IDs assigned to an agent so it can claim them back later.
+
+    The agent stores the proof with its identity and sends it in
+    previous_identity; only this backend (its encryption key) can produce
+    one, and it is bound to the engine_id it was issued for.
+
+    Returns:
+        The proof, or None if the encryption key is unavailable
+    """
+    try:
+        return encrypt_password(json.dumps(
+            {"agent_id": agent_id, "host_id": host_id, "engine_id": engine_id},
+            sort_keys=True,
+        ))
+    except (ValueError, IOError) as e:
+        logger.warning(f"Could not issue identity proof: {e}")
+        return None
+
+
+def verify_identity_proof(previous: dict, engine_id: str) -> bool:
+    """
+    Check that previous_identity carries a proof this backend issued for
+    the same agent ID, host ID and engine_id.
+    """
+    proof = previous.get("proof")
+    if not proof:
+        return False
+    try:
+        claims = json.loads(decrypt_password(proof))
+    except (ValueError, IOError):
+        return False
+    return (isinstance(claims, dict)
+            and claims.get("engine_id") == engine_id
+            and claims.get("agent_id") == previous.get("agent_id")
+            and claims.get("host_id") == previous.get("host_id"))
+
+
 class AgentManager:
     """Manages agent registration and lifecycle"""
 
@@ -302,7 +342,8 @@ class AgentManager:
                         "success": True,
                         "agent_id": agent_id,
                         "host_id": host_id,
-                        "permanent_token": agent_id
+                        "permanent_token": agent_id,
+                        "identity_proof": issue_identity_proof(agent_id, host_id, engine_id)
                     }
                 else:
                     return {"success": False, "error": "Permanent token does not match engine_id"}
@@ -406,9 +447,11 @@ class AgentManager:
                 if already_migrated:
                     logger.debug(f"Found {len(already_migrated)} already-migrated host(s) with engine_id {engine_id[:12]}...")
 
-        # Generate IDs
-        agent_id = str(uuid.uuid4())
-        host_id = str(uuid.uuid4())
+        # Generate IDs, reusing the agent's previous ones if this backend lost
+        # them (e.g. after restoring an older database backup)
+        agent_id, host_id, reattach_host, identity_reassigned = self._resolve_previous_identity(
+            registration_data.get("previous_identity"), engine_id
+        )
         now = datetime.now(timezone.utc)  # Naive UTC datetime
 
         logger.info(f"Registering new agent {agent_id[:8]}... with engine_id {engine_id[:12]}...")
@@ -422,27 +465,40 @@ class AgentManager:
             try:
                 # Create host record with hostname (fallback to engine_id if not provided)
                 agent_name = hostname if hostname else f"Agent-{engine_id[:12]}"
-                host = DockerHostDB(
-                    id=host_id,
-                    name=agent_name,
-                    url="agent://",  # Placeholder URL for agent connections (not used for WebSocket)
-                    connection_type="agent",
-                    engine_id=engine_id,  # Required for migration detection
-                    created_at=now,
-                    updated_at=now,
-                    # System information (aligned with legacy host schema)
-                    os_type=registration_data.get("os_type"),
-                    os_version=registration_data.get("os_version"),
-                    kernel_version=registration_data.get("kernel_version"),
-                    docker_version=registration_data.get("docker_version"),
-                    daemon_started_at=registration_data.get("daemon_started_at"),
-                    total_memory=registration_data.get("total_memory"),
-                    num_cpus=registration_data.get("num_cpus"),
-                    host_ip=serialize_registration_host_ip(registration_data),
-                )
-                reg_session.add(host)
+                host = reg_session.query(DockerHostDB).filter_by(id=host_id).first() if reattach_host else None
+                if host:
+                    # The agent's previous host survived without its agent:
+                    # take it over so its history stays attached
+                    for field in ("os_type", "os_version", "kernel_version", "docker_version",
+                                  "daemon_started_at", "total_memory", "num_cpus"):
+                        if registration_data.get(field):
+                            setattr(host, field, registration_data.get(field))
+                    host.host_ip = serialize_registration_host_ip(registration_data) or host.host_ip
+                    host.updated_at = now
+                    agent_name = host.name
+                    logger.info(f"Reattaching agent to existing host record: {agent_name} ({host_id[:8]}...)")
+                else:
+                    host = DockerHostDB(
+                        id=host_id,
+                        name=agent_name,
+                        url="agent://",  # Placeholder URL for agent connections (not used for WebSocket)
+                        connection_type="agent",
+                        engine_id=engine_id,  # Required for migration detection
+                        created_at=now,
+                        updated_at=now,
+                        # System information (aligned with legacy host schema)
+                        os_type=registration_data.get("os_type"),
+                        os_version=registration_data.get("os_version"),
+                        kernel_version=registration_data.get("kernel_version"),
+                        docker_version=registration_data.get("docker_version"),
+                        daemon_started_at=registration_data.get("daemon_started_at"),
+                        total_memory=registration_data.get("total_memory"),
+                        num_cpus=registration_data.get("num_cpus"),
+                        host_ip=serialize_registration_host_ip(registration_data),
+                    )
+                    reg_session.add(host)
+                    logger.info(f"Created host record: {agent_name} ({host_id[:8]}...)")
                 reg_session.flush()  # Ensure host exists before creating agent
-                logger.info(f"Created host record: {agent_name} ({host_id[:8]}...)")
 
                 # Create agent record
                 agent = Agent(
@@ -494,9 +550,13 @@ class AgentManager:
                     "success": True,
                     "agent_id": agent_id,
                     "host_id": host_id,
-                    "permanent_token": agent_id  # Use agent_id as permanent token for reconnection
+                    "permanent_token": agent_id,  # Use agent_id as permanent token for reconnection
+                    "identity_proof": issue_identity_proof(agent_id, host_id, engine_id)
                 }
 
+                if identity_reassigned:
+                    result["identity_reassigned"] = identity_reassigned
+
                 # Include migration candidates if user needs to choose
                 if migration_candidates:
                     result["migration_candidates"] = migration_candidates
@@ -552,6 +612,78 @@ class AgentManager:
                     "error": "Registration failed due to an internal error. Check server logs for details.",
                 }
 
+    def _resolve_previous_identity(self, previous, engine_id: str):
+        """
+        Pick IDs for a newly registering agent, reusing those from its
+        previous identity that this backend no longer has in use.
+
+        A previous host ID that still names an agent host with the same
+        engine_id but no agent (its agent row was lost) is reattached rather
+        than replaced, so the host keeps its history.
+
+        Previous IDs are only reused with a proof this backend issued for
+        them and the same engine_id (see issue_identity_proof), so an agent
+        can't claim another host's ID or history.
+
+        Args:
+            previous: previous_identity from the registration message, or None
+            engine_id: Docker engine ID of the registering agent
+
+        Returns:
+            Tuple of (agent_id, host_id, reattach_host, identity_reassigned),
+            where identity_reassigned describes previous IDs that could not be
+            reused (None if all were, or none were offered)
+        """
+        agent_id = str(uuid.uuid4())
+        host_id = str(uuid.uuid4())
+        if not previous:
+            return agent_id, host_id, False, None
+
+        previous_agent_id = previous.get("agent_id")
+        previous_host_id = previous.get("host_id")
+        reattach_host = False
+        reasons = []
+
+        if not verify_identity_proof(previous, engine_id):
+            logger.warning(f"Could not restore previous identity for engine_id {engine_id[:12]}...: "
+                           "missing or invalid identity proof")
+            return agent_id, host_id, False, {
+                "previous_agent_id": previous_agent_id,
+                "previous_host_id": previous_host_id,
+                "reason": "previous identity could not be verified",
+            }
+
+        with self.db_manager.get_session() as session:
+            if previous_agent_id:
+                if session.query(Agent).filter_by(id=previous_agent_id).first() is None:
+                    agent_id = previous_agent_id
+                else:
+                    reasons.append("previous agent ID belongs to another agent")
+
+            if previous_host_id:
+                host = session.query(DockerHostDB).filter_by(id=previous_host_id).first()
+                if host is None:
+                    host_id = previous_host_id
+                elif (host.connection_type == "agent" and host.engine_id == engine_id
+                        and session.query(Agent).filter_by(host_id=previous_host_id).first() is None):
+                    host_id = previous_host_id
+                    reattach_host = True
+                else:
+                    reasons.append("previous host ID belongs to another host")
+
+        if agent_id == previous_agent_id or host_id == previous_host_id:
+            logger.info(f"Restoring previous identity for engine_id {engine_id[:12]}... "
+                        f"(agent: {agent_id == previous_agent_id}, host: {host_id == previous_host_id})")
+        if not reasons:
+            return agent_id, host_id, reattach_host, None
+
+        logger.warning(f"Could not restore previous identity for engine_id {engine_id[:12]}...: {'; '.join(reasons)}")
+        return agent_id, host_id, reattach_host, {
+            "previous_agent_id": previous_agent_id,
+            "previous_host_id": previous_host_id,
+            "reason": "; ".join(reasons),
+        }
+
     def get_agent_for_host(self, host_id: str) -> str:
         """
         Get the agent ID for a given host ID.
@@ -566,6 +698,29 @@ class AgentManager:
             agent = session.query(Agent).filter_by(host_id=host_id).first()
             return agent.id if agent else None
 
+    def agent_has_capability(self, host_id: str, capability: str) -> bool:
+        """
+        Check whether the agent for a host advertised a capability at registration.
+
+        Args:
+            host_id: Docker host ID
+            capability: Capability name (e.g. "image_prune_policies")
+
+        Returns:
+            True if the host's agent reported the capability, False otherwise
+        """
+        with self.db_manager.get_session() as session:
+            agent = session.query(Agent).filter_by(host_id=host_id).first()
+            if not agent or not agent.capabilities:
+                return False
+            caps = agent.capabilities
+            if isinstance(caps, str):
+                try:
+                    caps = json.loads(caps)
+                except (ValueError, TypeError):
+                    return False
+            return bool(isinstance(caps, dict) and caps.get(capability))
+
     def _migrate_host_to_agent(
         self,
         existing_host: DockerHostDB,
@@ -972,6 +1127,7 @@ class AgentManager:
                     "agent_id": agent_id,
                     "host_id": new_host_id,
                     "permanent_token": agent_id,
+                    "identity_proof": issue_identity_proof(agent_id, new_host_id, engine_id),
                     "migration_detected": True,
                     "migrated_from": {
                         "host_id": old_host_id,
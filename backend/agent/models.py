@@ -13,6 +13,22 @@ from typing import Literal, Optional, Dict, List
 from pydantic import BaseModel, Field, field_validator, ConfigDict
 
 
+class AgentPreviousIdentity(BaseModel):
+    """
+    IDs the backend assigned to the agent at its last registration.
+
+    Sent on re-registration so a backend that lost them (database restore or
+    migration) can assign the same IDs again. Only UUIDs are accepted, and
+    only IDs with a valid proof (the identity_proof the backend returned at
+    that registration) are reused.
+    """
+    agent_id: Optional[str] = Field(None, max_length=36, pattern=r"^[0-9a-fA-F-]{36}$")
+    host_id: Optional[str] = Field(None, max_length=36, pattern=r"^[0-9a-fA-F-]{36}$")
+    proof: Optional[str] = Field(None, max_length=1024, pattern=r"^[A-Za-z0-9_=-]+$")
+
+    model_config = ConfigDict(extra='forbid')
+
+
 class AgentRegistrationRequest(BaseModel):
     """
     Validated agent registration request.
@@ -34,6 +50,8 @@ class AgentRegistrationRequest(BaseModel):
     version: str = Field(max_length=50, description="Agent version")
     proto_version: str = Field(max_length=20, description="Protocol version")
     capabilities: Dict[str, bool] = Field(description="Agent capabilities")
+    # Optional protocol features offered for negotiation (proto_version 1.2+)
+    features: Optional[List[str]] = Field(None, max_length=50, description="Optional protocol features offered by the agent")
 
     # Optional host identification
     hostname: Optional[str] = Field(None, max_length=255, description="System hostname")
@@ -71,6 +89,14 @@ class AgentRegistrationRequest(BaseModel):
     host_ip: Optional[str] = Field(None, max_length=45, description="Host IP address (IPv4 or IPv6)")
     host_ips: Optional[List[str]] = Field(None, max_length=50, description="All host IP addresses (max 50 items)")
 
+    # Clock handshake: agent's current time (RFC3339) for drift detection
+    agent_time: Optional[str] = Field(None, max_length=64, description="Agent's current time (RFC3339)")
+
+    # IDs from the agent's last registration, for continuity across backend restores
+    previous_identity: Optional[AgentPreviousIdentity] = Field(
+        None, description="Agent and host ID assigned at the agent's last registration"
+    )
+
     @field_validator('hostname', 'os_version', 'kernel_version', 'docker_version', 'os_type', 'agent_os', 'agent_arch', 'host_ip')
     @classmethod
     def sanitize_html(cls, v: Optional[str]) -> Optional[str]:
@@ -0,0 +1,175 @@
+"""
+Reassembly of chunked agent command responses.
+
+Agents that negotiated the "response_chunks" feature split command responses
+too large for one WebSocket frame (e.g. inspecting containers with huge
+environments, or listing thousands of containers) into "response_chunk"
+messages under the command's id:
+
+    {"type": "response_chunk", "id": "<correlation id>",
+     "payload": {"seq": 0, "data": "<part of the response JSON>"}}
+    ...
+    {"type": "response_chunk", "id": "<correlation id>",
+     "payload": {"seq": 7, "data": "...", "final": true, "chunks": 8, "size": 1843200}}
+
+The data of a response's chunks, joined in seq order, is the JSON of the
+response message; the final chunk carries the count and byte size to check
+it against. Chunks arrive in order over the one connection, so a gap means
+a frame was dropped and the response can't be rebuilt. See the agent's
+internal/protocol/chunks.go.
+"""
+
+import json
+import logging
+import time
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+# Bytes buffered for one response (the agent's MaxResponseSize)
+MAX_RESPONSE_BYTES = 64 * 1024 * 1024
+
+# Bytes buffered across all of a connection's unfinished responses
+MAX_PENDING_BYTES = 128 * 1024 * 1024
+
+# Unfinished responses are dropped after this long without a chunk (seconds).
+# Their commands have timed out by then.
+STALE_AFTER_SECONDS = 300
+
+
+class _Assembly:
+    """Chunks received so far for one response"""
+
+    def __init__(self, now: float):
+        self.parts: list = []
+        self.size = 0
+        self.updated = now
+
+
+class ResponseReassembler:
+    """
+    Rebuilds chunked command responses for one agent connection.
+
+    add() returns the response once its final chunk arrives. A sequence that
+    can't be completed (a gap, a size limit, bad JSON) yields an error
+    response instead, so the waiting command fails at once rather than
+    timing out; its remaining chunks are ignored.
+    """
+
+    def __init__(self, max_response_bytes: int = MAX_RESPONSE_BYTES,
+                 max_pending_bytes: int = MAX_PENDING_BYTES):
+        self.max_response_bytes = max_response_bytes
+        self.max_pending_bytes = max_pending_bytes
+        self._assemblies: Dict[str, _Assembly] = {}
+        self._failed: Dict[str, float] = {}  # correlation id -> when it failed
+        self._pending_bytes = 0
+
+    @property
+    def pending_bytes(self) -> int:
+        """Bytes buffered for unfinished responses"""
+        return self._pending_bytes
+
+    def add(self, message: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        """
+        Add a response_chunk message.
+
+        Returns:
+            The complete response message, an error response when the
+            sequence failed, or None while more chunks are expected
+        """
+        now = time.monotonic()
+        self._expire(now)
+
+        correlation_id = message.get("id")
+        chunk = message.get("payload")
+        if not correlation_id or not isinstance(chunk, dict):
+            logger.warning("Ignoring malformed response chunk")
+            return None
+        if correlation_id in self._failed:
+            if chunk.get("final"):
+                self._failed.pop(correlation_id, None)
+            return None
+
+        seq = chunk.get("seq")
+        data = chunk.get("data")
+        if not isinstance(data, str):
+            return self._fail(correlation_id, now, "response chunk without data", chunk)
+
+        assembly = self._assemblies.get(correlation_id)
+        if assembly is None:
+            assembly = self._assemblies[correlation_id] = _Assembly(now)
+        if seq != len(assembly.parts):
+            return self._fail(
+                correlation_id, now,
+                f"response chunk {seq} arrived after {len(assembly.parts)} chunk(s); a chunk was lost",
+                chunk,
+            )
+
+        size = len(data.encode("utf-8"))
+        if assembly.size + size > self.max_response_bytes:
+            return self._fail(
+                correlation_id, now,
+                f"response exceeds the {self.max_response_bytes} byte limit",
+                chunk,
+            )
+        if self._pending_bytes + size > self.max_pending_bytes:
+            return self._fail(
+                correlation_id, now,
+                f"too much response data buffered for this agent ({self._pending_bytes} bytes)",
+                chunk,
+            )
+
+        assembly.parts.append(data)
+        assembly.size += size
+        assembly.updated = now
+        self._pending_bytes += size
+
+        if not chunk.get("final"):
+            return None
+
+        self._discard(correlation_id)
+        if chunk.get("chunks") != len(assembly.parts) or chunk.get("size") != assembly.size:
+            return self._error_response(
+                correlation_id,
+                f"chunked response incomplete: got {len(assembly.parts)} chunk(s) of "
+                f"{assembly.size} bytes, agent sent {chunk.get('chunks')} of {chunk.get('size')}",
+            )
+        try:
+            response = json.loads("".join(assembly.parts))
+        except ValueError as e:
+            return self._error_response(correlation_id, f"chunked response is not valid JSON: {e}")
+        if not isinstance(response, dict) or response.get("id") != correlation_id:
+            return self._error_response(correlation_id, "chunked response does not match its command")
+        return response
+
+    def _fail(self, correlation_id: str, now: float, reason: str, chunk: Dict[str, Any]) -> Dict[str, Any]:
+        """Abandon a sequence, ignoring its remaining chunks"""
+        self._discard(correlation_id)
+        if not chunk.get("final"):
+            self._failed[correlation_id] = now
+        return self._error_response(correlation_id, reason)
+
+    def _discard(self, correlation_id: str):
+        assembly = self._assemblies.pop(correlation_id, None)
+        if assembly is not None:
+            self._pending_bytes -= assembly.size
+
+    def _expire(self, now: float):
+        """Drop sequences and failure markers that stopped receiving chunks"""
+        cutoff = now - STALE_AFTER_SECONDS
+        for correlation_id in [k for k, a in self._assemblies.items() if a.updated < cutoff]:
+            logger.warning(f"Dropping incomplete chunked response {correlation_id}: no chunk for {STALE_AFTER_SECONDS}s")
+            self._discard(correlation_id)
+        for correlation_id in [k for k, t in self._failed.items() if t < cutoff]:
+            self._failed.pop(correlation_id, None)
+
+    @staticmethod
+    def _error_response(correlation_id: str, error: str) -> Dict[str, Any]:
+        """A response failing the waiting command as an invalid response"""
+        logger.warning(f"Chunked response {correlation_id} failed: {error}")
+        return {
+            "type": "response",
+            "id": correlation_id,
+            "error": error,
+            "error_type": "invalid_response",
+        }
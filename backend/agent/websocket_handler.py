@@ -13,13 +13,16 @@ Protocol Flow:
 Message Types:
 - Agent → Backend: register, reconnect, stats, progress, error, heartbeat
 - Backend → Agent: auth_success, auth_error, collect_stats, update_container, self_update
+
+auth_success carries the negotiated proto_version and the optional features
+whose events the agent may send (see negotiate_protocol).
 """
 import asyncio
 import json
 import logging
 import time
-from datetime import datetime, timezone
-from typing import Optional
+from datetime import datetime, timedelta, timezone
+from typing import List, Optional
 
 from fastapi import WebSocket, WebSocketDisconnect
 from pydantic import ValidationError
@@ -27,7 +30,9 @@ from pydantic import ValidationError
 from agent.manager import AgentManager
 from agent.connection_manager import agent_connection_manager
 from agent.command_executor import get_agent_command_executor
+from agent.container_inventory import get_container_inventory
 from agent.models import AgentRegistrationRequest
+from agent.response_chunks import ResponseReassembler
 from database import (
     Agent,
     ContainerHttpHealthCheck,
@@ -37,9 +42,67 @@ from database import (
 from event_bus import Event, EventType, get_event_bus
 from event_logger import EventCategory, EventType as LogEventType, EventSeverity, EventContext
 from utils.keys import make_composite_key
+from utils.progress_schema import OPERATION_SELF_UPDATE, OPERATION_UPDATE, normalize_progress
+from utils.version import get_app_version
 
 logger = logging.getLogger(__name__)
 
+# Agent clock offsets beyond this are reported as drift (seconds)
+CLOCK_DRIFT_WARN_SECONDS = 2.0
+
+# How far back to reconcile agent update states after a reconnect. Older
+# outcomes have already timed out in AgentUpdateExecutor.
+UPDATE_RECONCILE_WINDOW = timedelta(hours=1)
+
+# Upper bound on the per-core CPU list accepted from an agent
+MAX_CPU_CORES = 1024
+
+# Protocol version this backend speaks. From 1.2 agents offer optional
+# features at registration and only send the events of those accepted here.
+PROTO_VERSION = "1.2"
+
+# Optional agent features whose events this backend handles. The event types
+# each feature adds are listed in the agent's internal/protocol/features.go.
+SUPPORTED_AGENT_FEATURES = frozenset({
+    "resource_events",   # resource_event
+    "inventory_deltas",  # container_inventory_delta
+    "response_chunks",   # response_chunk frames of large command responses
+    "progress_schema",   # progress events in the shared schema (utils/progress_schema.py)
+    "update_failures",   # update_failed
+})
+
+
+def _version_tuple(version) -> tuple:
+    """Parse a dotted protocol version; non-numeric parts count as 0."""
+    parts = []
+    for part in str(version or "").split("."):
+        try:
+            parts.append(int(part))
+        except ValueError:
+            parts.append(0)
+    while parts and parts[-1] == 0:
+        parts.pop()
+    return tuple(parts)
+
+
+def negotiate_protocol(agent_proto_version, agent_features) -> tuple:
+    """
+    Agree on a protocol version and feature set with an agent.
+
+    The version is the lower of the two sides'. Features are those the agent
+    offered that this backend handles; agents that predate negotiation offer
+    none and keep to the core event types.
+
+    Returns:
+        (proto_version, sorted list of accepted features)
+    """
+    version = PROTO_VERSION
+    if agent_proto_version and _version_tuple(agent_proto_version) < _version_tuple(PROTO_VERSION):
+        version = agent_proto_version
+    offered = agent_features if isinstance(agent_features, list) else []
+    features = sorted({f for f in offered if isinstance(f, str) and f in SUPPORTED_AGENT_FEATURES})
+    return version, features
+
 
 class AgentWebSocketHandler:
     """Handles WebSocket connections from agents"""
@@ -62,6 +125,19 @@ class AgentWebSocketHandler:
         self.agent_hostname: Optional[str] = None  # For event logging
         self.host_id: Optional[str] = None  # For mapping agent to host
         self.authenticated = False
+        # Seconds to add to agent timestamps to get backend time (None until measured)
+        self.clock_offset: Optional[float] = None
+        # Protocol version and optional features agreed at authentication
+        self.proto_version: Optional[str] = None
+        self.features: list = []
+        # Command responses the agent is sending in chunks
+        self.response_chunks = ResponseReassembler()
+        # Messages the agent has dropped from its outbound queue (last heartbeat)
+        self.outbound_dropped = 0
+        # Agent operations still running past their deadline (last heartbeat)
+        self.hung_operations = 0
+        # Post-authentication update reconciliation (kept so it isn't collected)
+        self._reconcile_task: Optional[asyncio.Task] = None
 
     def _truncate_container_id(self, container_id: Optional[str]) -> str:
         """
@@ -118,14 +194,39 @@ class AgentWebSocketHandler:
             # Store agent details for event logging
             self.host_id = auth_result.get("host_id")
             self.agent_hostname = auth_message.get("hostname") or self.agent_id
+            self._record_agent_clock(auth_message.get("agent_time"))
 
-            # Send success response
-            await self.websocket.send_json({
+            self.proto_version, self.features = negotiate_protocol(
+                auth_message.get("proto_version"), auth_message.get("features")
+            )
+            offered = auth_message.get("features")
+            offered = offered if isinstance(offered, list) else []
+            unsupported = sorted({f for f in offered if isinstance(f, str)} - set(self.features))
+            logger.info(
+                f"Agent {self.agent_hostname} negotiated protocol {self.proto_version} "
+                f"(features: {', '.join(self.features) or 'none'}; "
+                f"not supported by backend: {', '.join(unsupported) or 'none'})"
+            )
+
+            # Send success response (server_time lets the agent check drift too)
+            auth_response = {
                 "type": "auth_success",
                 "agent_id": self.agent_id,
                 "host_id": self.host_id,
-                "permanent_token": auth_result.get("permanent_token")
-            })
+                "permanent_token": auth_result.get("permanent_token"),
+                "server_time": datetime.now(timezone.utc).isoformat(),
+                "proto_version": self.proto_version,
+                "features": self.features,
+                # Lets the agent tell an outdated DockMon from a broken one
+                "server_version": get_app_version(),
+            }
+            # Lets the agent claim these IDs back after a database restore
+            if auth_result.get("identity_proof"):
+                auth_response["identity_proof"] = auth_result["identity_proof"]
+            # Tell the agent when the IDs it asked to keep were not available
+            if auth_result.get("identity_reassigned"):
+                auth_response["identity_reassigned"] = auth_result["identity_reassigned"]
+            await self.websocket.send_json(auth_response)
 
             # Register connection
             await agent_connection_manager.register_connection(
@@ -133,11 +234,20 @@ class AgentWebSocketHandler:
                 self.websocket
             )
 
+            # Inventory subscriptions are per connection; discovery resubscribes
+            get_container_inventory().drop(self.agent_id)
+
             logger.info(f"Agent {self.agent_id} authenticated successfully")
 
             # Sync health check configs to agent
             await self._sync_health_check_configs()
 
+            # Replay update progress lost while disconnected. Runs as a task:
+            # the response arrives through the message loop started below.
+            capabilities = auth_message.get("capabilities")
+            if isinstance(capabilities, dict) and capabilities.get("update_status"):
+                self._reconcile_task = asyncio.create_task(self._reconcile_update_status())
+
             # Emit HOST_CONNECTED event via EventBus
             if self.monitor and self.host_id:
                 try:
@@ -215,6 +325,7 @@ class AgentWebSocketHandler:
             # by the agent's current connection, so re-check is_connected here: a
             # reconnect that landed during the disconnect emit keeps its shells.
             if self.agent_id and not agent_connection_manager.is_connected(self.agent_id):
+                get_container_inventory().drop(self.agent_id)
                 try:
                     from agent.shell_manager import get_shell_manager
                     await get_shell_manager().close_sessions_for_agent(self.agent_id)
@@ -373,6 +484,7 @@ class AgentWebSocketHandler:
         - error: Operation error
         - heartbeat: Keep-alive ping
         - response / messages with correlation_id: Command responses
+        - response_chunk: Part of a large command response
 
         Args:
             message: Message dict from agent (must have 'type' field)
@@ -382,6 +494,14 @@ class AgentWebSocketHandler:
         # Note: Legacy protocol uses "id", new protocol uses "correlation_id"
         msg_type = message.get("type")
 
+        # Large responses arrive in chunks and are routed once complete
+        if msg_type == "response_chunk":
+            response = self.response_chunks.add(message)
+            if response is not None:
+                response["correlation_id"] = response.get("id")
+                get_agent_command_executor().handle_agent_response(response)
+            return
+
         if "correlation_id" in message or ("id" in message and msg_type == "response"):
             command_executor = get_agent_command_executor()
             # Normalize legacy "id" to "correlation_id" for command executor
@@ -403,6 +523,10 @@ class AgentWebSocketHandler:
             await self._handle_error(message)
 
         elif msg_type == "heartbeat":
+            self._record_agent_clock(message.get("agent_time"))
+            self._record_outbound_stats(message.get("outbound"))
+            self._record_operation_stats(message.get("operations"))
+            self._record_connection_stats(message.get("connection"))
             # Update last_seen_at (short-lived session)
             with self.db_manager.get_session() as session:
                 agent = session.query(Agent).filter_by(id=self.agent_id).first()
@@ -420,6 +544,15 @@ class AgentWebSocketHandler:
                 # Emit via EventBus: stores in database, triggers alerts, broadcasts to UI
                 await self._handle_container_event(payload)
 
+            elif event_type == "resource_event":
+                # Image/volume/network change for the host audit trail
+                self._handle_resource_event(payload)
+
+            elif event_type == "container_inventory_delta":
+                # Incremental container list changes for a subscribed inventory.
+                # A revision gap drops the cache; discovery resubscribes.
+                get_container_inventory().apply_delta(self.agent_id, payload)
+
             elif event_type == "container_stats":
                 # Real-time container stats
                 # Forward to stats system: in-memory buffer + WebSocket broadcast
@@ -445,6 +578,11 @@ class AgentWebSocketHandler:
                 # Must update database records with new ID
                 await self._handle_update_complete(payload)
 
+            elif event_type == "update_failed":
+                # Container update failed - carries the failed container's forensics
+                # Unblocks the waiting AgentUpdateExecutor with the outcome
+                await self._handle_update_failed(payload)
+
             elif event_type == "selfupdate_progress":
                 # Agent self-update progress
                 # Forward to UI for real-time progress display
@@ -471,6 +609,107 @@ class AgentWebSocketHandler:
         else:
             logger.warning(f"Unknown message type from agent {self.agent_id}: {msg_type}")
 
+    def _record_agent_clock(self, agent_time: Optional[str]):
+        """
+        Update the agent's clock offset from the time it reported.
+
+        The offset includes the one-way network delay, which is negligible
+        next to the drift this is meant to catch.
+        """
+        reported = self._parse_agent_time(agent_time)
+        if reported is None:
+            return
+
+        offset = (datetime.now(timezone.utc) - reported).total_seconds()
+        was_drifting = self.clock_offset is not None and abs(self.clock_offset) > CLOCK_DRIFT_WARN_SECONDS
+        self.clock_offset = offset
+
+        # Warn on the transition into drift, not on every heartbeat
+        if abs(offset) > CLOCK_DRIFT_WARN_SECONDS and not was_drifting:
+            logger.warning(
+                f"Clock drift on agent {self.agent_hostname or self.agent_id}: "
+                f"{-offset:+.1f}s from backend; event timestamps will be corrected"
+            )
+
+    def _record_outbound_stats(self, stats):
+        """
+        Log when the agent reports dropping messages from its outbound queue.
+
+        The counters are cumulative since the agent started; a lower value
+        than last time means the agent restarted.
+        """
+        if not isinstance(stats, dict):
+            return
+        dropped = stats.get("dropped")
+        if not isinstance(dropped, int):
+            return
+        if dropped > self.outbound_dropped:
+            logger.warning(
+                f"Agent {self.agent_hostname or self.agent_id} dropped "
+                f"{dropped - self.outbound_dropped} outbound message(s) under backpressure "
+                f"(total {dropped}, coalesced {stats.get('coalesced', 0)}, queued {stats.get('queued', 0)})"
+            )
+        self.outbound_dropped = dropped
+
+    def _record_operation_stats(self, stats):
+        """
+        Log when operations on the agent are hung past their deadline,
+        usually because its Docker daemon stopped responding.
+        """
+        if not isinstance(stats, dict):
+            return
+        hung = stats.get("hung")
+        if not isinstance(hung, int):
+            return
+        if hung > self.hung_operations:
+            logger.warning(
+                f"Agent {self.agent_hostname or self.agent_id} has {hung} operation(s) hung "
+                f"past their deadline (timed out since agent start: {stats.get('timed_out', 0)}); "
+                f"its Docker daemon may be unresponsive"
+            )
+        elif hung == 0 and self.hung_operations:
+            logger.info(f"Hung operations on agent {self.agent_hostname or self.agent_id} have finished")
+        self.hung_operations = hung
+
+    def _record_connection_stats(self, stats):
+        """
+        Store the agent's connection stats (reconnects, disconnect reason,
+        ping latency, traffic, queue depths) for the agent status endpoint,
+        and log why it reconnected the first time it reports a new reconnect.
+        """
+        if not isinstance(stats, dict):
+            return
+        previous = agent_connection_manager.get_connection_stats(self.agent_id)
+        reconnects = stats.get("reconnects")
+        if (isinstance(reconnects, int) and reconnects > 0 and previous is not None
+                and reconnects > previous.get("reconnects", 0)):
+            logger.warning(
+                f"Agent {self.agent_hostname or self.agent_id} reconnected "
+                f"({reconnects} reconnect(s), {stats.get('failed_attempts', 0)} failed attempt(s) since agent start); "
+                f"last disconnect: {stats.get('last_disconnect_reason') or 'unknown'}"
+            )
+        agent_connection_manager.record_connection_stats(self.agent_id, stats)
+
+    def _normalize_agent_timestamp(self, value) -> Optional[str]:
+        """Convert an agent timestamp to backend time using the measured offset."""
+        reported = self._parse_agent_time(value)
+        if reported is None or self.clock_offset is None:
+            return value
+        return (reported + timedelta(seconds=self.clock_offset)).isoformat()
+
+    @staticmethod
+    def _parse_agent_time(value) -> Optional[datetime]:
+        """Parse an RFC3339 timestamp from the agent; None if absent or invalid."""
+        if not isinstance(value, str) or not value:
+            return None
+        try:
+            parsed = datetime.fromisoformat(value.replace('Z', '+00:00'))
+        except ValueError:
+            return None
+        if parsed.tzinfo is None:
+            return None
+        return parsed
+
     async def _handle_system_stats(self, message: dict):
         """
         Handle system stats from agent.
@@ -500,12 +739,32 @@ class AgentWebSocketHandler:
                 mem=mem,
                 net=net
             )
+            self.monitor.stats_history.set_cpu_per_core(
+                host_id, self._parse_cpu_per_core(stats.get("cpu_per_core"))
+            )
 
             logger.debug(f"Stored system stats for agent {self.agent_id}: CPU={cpu:.1f}%, MEM={mem:.1f}%, NET={net:.0f} B/s")
 
         except Exception as e:
             logger.error(f"Error handling system stats from agent {self.agent_id}: {e}", exc_info=True)
 
+    @staticmethod
+    def _parse_cpu_per_core(value) -> Optional[List[float]]:
+        """
+        Validate the agent's per-core CPU list.
+
+        Returns None unless it's a non-empty list of numbers, each clamped to
+        0-100 so one bad sample can't skew the UI.
+        """
+        if not isinstance(value, list) or not value or len(value) > MAX_CPU_CORES:
+            return None
+        cores = []
+        for v in value:
+            if isinstance(v, bool) or not isinstance(v, (int, float)):
+                return None
+            cores.append(min(max(float(v), 0.0), 100.0))
+        return cores
+
     async def _handle_progress(self, message: dict):
         """
         Handle progress update from agent.
@@ -601,6 +860,10 @@ class AgentWebSocketHandler:
                 logger.warning(f"Container event missing container_id from agent {self.agent_id}")
                 return
 
+            # Agent clocks drift; report the event on the backend's clock
+            if "timestamp" in payload:
+                payload["timestamp"] = self._normalize_agent_timestamp(payload["timestamp"])
+
             # Map Docker actions to EventBus event types
             # Note: 'stop' is intentionally omitted - Docker emits both 'stop' and 'die' when
             # a container stops. We only process 'die' (which includes exit code) to avoid
@@ -689,11 +952,31 @@ class AgentWebSocketHandler:
         except Exception as e:
             logger.error(f"Error handling container event from agent {self.agent_id}: {e}", exc_info=True)
 
+    def _handle_resource_event(self, payload: dict):
+        """Log an image, volume or network event from the agent."""
+        if not self.monitor:
+            return
+
+        try:
+            self.monitor.event_logger.log_host_resource_event(
+                host_name=self.agent_hostname or self.agent_id,
+                host_id=self.host_id or self.agent_id,
+                resource_type=payload.get("type", ""),
+                action=payload.get("action", ""),
+                resource_name=payload.get("name") or payload.get("actor_id", ""),
+                resource_id=payload.get("actor_id"),
+                attributes=payload.get("attributes"),
+            )
+        except Exception as e:
+            logger.error(f"Error handling resource event from agent {self.agent_id}: {e}", exc_info=True)
+
     async def _handle_container_stats(self, payload: dict):
         """
         Handle real-time container stats from agent.
 
         Stores in in-memory buffer and broadcasts to UI for real-time graphs.
+        A payload with "state" marks the container paused (no metrics) or
+        running again instead of carrying a sample.
         """
         try:
             if not self.monitor:
@@ -712,6 +995,19 @@ class AgentWebSocketHandler:
             # Create composite key for stats storage (validates 12-char format)
             container_key = make_composite_key(self.host_id, container_id)
 
+            if not hasattr(self.monitor, 'agent_container_stats_cache'):
+                self.monitor.agent_container_stats_cache = {}
+            cached = self.monitor.agent_container_stats_cache.get(container_key)
+            state = payload.get("state")
+            if state in ("paused", "running"):
+                self._forward_container_stats(payload)
+                await self._handle_container_stats_state(container_key, container_id, state, payload)
+                return
+            if cached and cached.get("state") == "paused":
+                # Sampled just before the agent stopped the stream
+                return
+            self._forward_container_stats(payload)
+
             # Calculate network rate (bytes/sec) by comparing with previous reading
             current_time = time.time()
             net_rx = stats.get("network_rx", 0)
@@ -755,11 +1051,7 @@ class AgentWebSocketHandler:
             # This allows populate_container_stats() to access memory_usage, memory_limit, etc.
             # Add the calculated net_bytes_per_sec to the stats
             stats_with_rate = {**stats, 'net_bytes_per_sec': net_bytes_per_sec}
-            if hasattr(self.monitor, 'agent_container_stats_cache'):
-                self.monitor.agent_container_stats_cache[container_key] = stats_with_rate
-            else:
-                # Initialize cache if it doesn't exist
-                self.monitor.agent_container_stats_cache = {container_key: stats_with_rate}
+            self.monitor.agent_container_stats_cache[container_key] = stats_with_rate
 
             # Broadcast to UI clients subscribed to this container
             if hasattr(self.monitor, 'manager'):
@@ -775,6 +1067,48 @@ class AgentWebSocketHandler:
         except Exception as e:
             logger.error(f"Error handling container stats from agent {self.agent_id}: {e}", exc_info=True)
 
+    def _forward_container_stats(self, payload: dict):
+        """
+        Feed an agent's container stats sample into stats-service, so agent
+        hosts go through the same aggregation as hosts it collects itself.
+        """
+        if not self.host_id:
+            return
+        try:
+            from stats_client import get_stats_client
+            get_stats_client().queue_ingest_sample(self.host_id, payload)
+        except Exception as e:
+            logger.debug(f"Could not queue container stats for stats-service: {e}")
+
+    async def _handle_container_stats_state(self, container_key: str, container_id: str, state: str, payload: dict):
+        """
+        Freeze a paused container's last stats, or unfreeze them when it runs
+        again. The agent stops a paused container's stream, so its stats are
+        flagged paused rather than left showing stale or zero CPU.
+        """
+        cache = self.monitor.agent_container_stats_cache
+        previous = cache.get(container_key) or {}
+
+        if state == "paused":
+            stats = {**previous, **payload, 'cpu_percent': 0.0, 'net_bytes_per_sec': 0}
+            cache[container_key] = stats
+            # The network rate restarts from the first sample after unpausing
+            self.prev_network_stats.pop(container_key, None)
+        elif previous.get("state") == "paused":
+            stats = {k: v for k, v in previous.items() if k != "state"}
+            cache[container_key] = stats
+        else:
+            return
+
+        if hasattr(self.monitor, 'manager'):
+            await self.monitor.manager.broadcast({
+                "type": "container_stats",
+                "container_id": container_id,
+                "host_id": self.host_id or self.agent_id,
+                "stats": stats
+            })
+        logger.debug(f"Container {container_id} is {state} (agent {self.agent_id})")
+
     async def _sync_health_check_configs(self):
         """
         Send all health check configs for this host to the agent.
@@ -975,6 +1309,88 @@ class AgentWebSocketHandler:
         except Exception as e:
             logger.error(f"Error handling update progress: {e}", exc_info=True)
 
+    async def _reconcile_update_status(self):
+        """
+        Reconcile container updates with the agent's persisted update states.
+
+        Progress events sent while the backend was disconnected are lost, which
+        leaves the UI showing an update stuck at e.g. "pulling". The agent keeps
+        the latest state of each update; replaying it here unblocks any waiting
+        AgentUpdateExecutor with the final outcome and refreshes the UI.
+        """
+        try:
+            since = datetime.now(timezone.utc) - UPDATE_RECONCILE_WINDOW
+            result = await get_agent_command_executor().execute_command(
+                self.agent_id,
+                {
+                    "type": "command",
+                    "command": "get_update_status",
+                    "payload": {"since": since.isoformat()},
+                },
+                timeout=15.0,
+            )
+            if not result.success or not isinstance(result.response, dict):
+                logger.warning(f"Could not fetch update status from agent {self.agent_id}: {result.error}")
+                return
+
+            from updates.pending_updates import get_pending_updates_registry
+            registry = get_pending_updates_registry()
+            host_id = self.host_id or self.agent_id
+
+            states = [s for s in result.response.get("updates") or [] if isinstance(s, dict)]
+            for state in states:
+                container_id = self._truncate_container_id(state.get("container_id"))
+                status = state.get("status")
+                error = state.get("error")
+
+                if status == "running":
+                    await self._handle_update_progress({
+                        "container_id": container_id,
+                        "stage": state.get("stage"),
+                        "message": state.get("message"),
+                    })
+                elif status == "completed":
+                    new_container_id = self._truncate_container_id(state.get("new_container_id"))
+                    signaled = await registry.signal_complete(
+                        host_id=host_id,
+                        old_container_id=container_id,
+                        new_container_id=new_container_id,
+                        success=True,
+                    )
+                    # Nobody was waiting any more: at least clear the UI progress
+                    if not signaled and self.monitor and hasattr(self.monitor, 'manager'):
+                        await self.monitor.manager.broadcast({
+                            "type": "container_update_complete",
+                            "data": {
+                                "host_id": host_id,
+                                "old_container_id": container_id,
+                                "new_container_id": new_container_id,
+                                "container_name": state.get("container_name"),
+                            }
+                        })
+                elif status in ("failed", "rolled_back", "interrupted"):
+                    await registry.signal_complete(
+                        host_id=host_id,
+                        old_container_id=container_id,
+                        new_container_id=None,
+                        success=False,
+                        error=error,
+                        rolled_back=status == "rolled_back",
+                        failure=state.get("failure"),
+                    )
+                    await self._handle_update_progress({
+                        "container_id": container_id,
+                        "stage": "rollback" if status == "rolled_back" else "failed",
+                        "message": error,
+                        "error": error,
+                    })
+
+            if states:
+                logger.info(f"Reconciled {len(states)} update state(s) from agent {self.agent_id}")
+
+        except Exception as e:
+            logger.error(f"Error reconciling update status for agent {self.agent_id}: {e}", exc_info=True)
+
     async def _handle_update_layer_progress(self, payload: dict):
         """
         Handle layer-by-layer image pull progress from agent.
@@ -987,7 +1403,8 @@ class AgentWebSocketHandler:
                 logger.debug(f"WebSocket manager not available for agent {self.agent_id}")
                 return
 
-            container_id = self._truncate_container_id(payload.get("container_id"))
+            event = normalize_progress(payload, OPERATION_UPDATE)
+            container_id = self._truncate_container_id(event.get("container_id"))
 
             # Forward layer progress to UI with correct event type
             await self.monitor.manager.broadcast({
@@ -995,12 +1412,12 @@ class AgentWebSocketHandler:
                 "data": {
                     "host_id": self.host_id or self.agent_id,
                     "entity_id": container_id,
-                    "overall_progress": payload.get("overall_progress", 0),
-                    "layers": payload.get("layers", []),
-                    "total_layers": payload.get("total_layers", 0),
-                    "remaining_layers": payload.get("remaining_layers", 0),
-                    "summary": payload.get("summary", ""),
-                    "speed_mbps": payload.get("speed_mbps", 0.0),
+                    "overall_progress": event.get("stage_percent", 0),
+                    "layers": event.get("layers", []),
+                    "total_layers": event.get("total_layers", 0),
+                    "remaining_layers": event.get("remaining_layers", 0),
+                    "summary": event.get("message", ""),
+                    "speed_mbps": event.get("speed_mbps", 0.0),
                 }
             })
 
@@ -1019,9 +1436,10 @@ class AgentWebSocketHandler:
                 logger.debug(f"WebSocket manager not available for agent {self.agent_id}")
                 return
 
-            stage = payload.get("stage", "")
-            message = payload.get("message", "")
-            error = payload.get("error")
+            event = normalize_progress(payload, OPERATION_SELF_UPDATE)
+            stage = event.get("stage", "")
+            message = event.get("message", "")
+            error = event.get("error")
 
             # Broadcast to UI
             await self.monitor.manager.broadcast({
@@ -1032,6 +1450,11 @@ class AgentWebSocketHandler:
                     "stage": stage,
                     "message": message,
                     "error": error,
+                    # Binary download progress (native agents)
+                    "percent": event.get("stage_percent"),
+                    "downloaded": event.get("current_bytes"),
+                    "total": event.get("total_bytes"),
+                    "speed_mbps": event.get("speed_mbps"),
                 }
             })
 
@@ -1047,11 +1470,40 @@ class AgentWebSocketHandler:
                     details={"stage": stage, "agent_id": self.agent_id}
                 )
 
-            logger.info(f"Agent {self.agent_id} self-update progress: {stage} - {message}")
+            if "downloaded" in payload:
+                # Download progress arrives several times a second
+                logger.debug(f"Agent {self.agent_id} self-update progress: {stage} - {message}")
+            else:
+                logger.info(f"Agent {self.agent_id} self-update progress: {stage} - {message}")
 
         except Exception as e:
             logger.error(f"Error handling selfupdate progress: {e}", exc_info=True)
 
+    async def _handle_update_failed(self, payload: dict):
+        """
+        Handle update failure event from agent.
+
+        Signals the PendingUpdatesRegistry with the error, whether the old
+        container was restored and the forensics captured from the failed new
+        container. The UI already got the failure as an update_progress event.
+        """
+        try:
+            container_id = self._truncate_container_id(payload.get("container_id"))
+            failure = payload.get("failure")
+
+            from updates.pending_updates import get_pending_updates_registry
+            await get_pending_updates_registry().signal_complete(
+                host_id=self.host_id or self.agent_id,
+                old_container_id=container_id,
+                new_container_id=None,
+                success=False,
+                error=payload.get("error"),
+                rolled_back=bool(payload.get("rolled_back")),
+                failure=failure if isinstance(failure, dict) else None,
+            )
+        except Exception as e:
+            logger.error(f"Error handling update failure: {e}", exc_info=True)
+
     async def _handle_update_complete(self, payload: dict):
         """
         Handle update completion event from agent.
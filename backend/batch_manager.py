@@ -18,6 +18,7 @@ from updates.update_checker import get_update_checker, LOCAL_IMAGE_STATUS
 from updates.update_executor import get_update_executor
 from utils.keys import make_composite_key
 from utils.async_docker import async_docker_call
+from utils.service_headers import SOURCE_BATCH
 
 logger = logging.getLogger(__name__)
 
@@ -510,7 +511,9 @@ class BatchJobManager:
                 force_warn = params.get('force_warn', False) if params else False
 
                 executor = get_update_executor(self.db, self.monitor)
-                success = await executor.update_container(host_id, short_id, update_record, force=False, force_warn=force_warn)
+                success = await executor.update_container(
+                    host_id, short_id, update_record, force=False, force_warn=force_warn, source=SOURCE_BATCH,
+                )
 
                 if success:
                     message = 'Update completed successfully'
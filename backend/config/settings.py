@@ -275,6 +275,13 @@ class AppConfig:
     # Rate limiting
     RATE_LIMITS = RateLimitConfig.get_limits()
 
+    # Failure forensics for container updates: how many log lines of a new
+    # container that fails its health check are kept with the failure (0: none),
+    # and how many hours it is kept stopped for inspection instead of being
+    # removed (0: removed right away)
+    UPDATE_FAILURE_LOG_LINES = _safe_int('DOCKMON_UPDATE_FAILURE_LOG_LINES', 200, min_val=0, max_val=5000)
+    UPDATE_QUARANTINE_HOURS = _safe_int('DOCKMON_UPDATE_QUARANTINE_HOURS', 0, min_val=0, max_val=168)
+
     @classmethod
     def validate(cls):
         """
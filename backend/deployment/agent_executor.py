@@ -30,6 +30,8 @@ from database import (
     DatabaseManager,
 )
 from agent.command_executor import get_agent_command_executor, RetryPolicy
+from deployment.container_utils import get_service_container_ids
+from utils.progress_schema import OPERATION_DEPLOY, normalize_progress
 from utils.registry_credentials import get_all_registry_credentials
 
 logger = logging.getLogger(__name__)
@@ -451,8 +453,10 @@ class AgentDeploymentExecutor:
                 - deployment_id: Deployment composite ID
                 - stage: Current stage (starting, executing, completed, failed, waiting_for_health)
                 - message: Human-readable status message
+                - percent: Overall progress (agents sending the shared progress schema)
                 - services: Optional list of service statuses (Phase 3 fine-grained progress)
         """
+        payload = normalize_progress(payload, OPERATION_DEPLOY)
         deployment_id = payload.get("deployment_id")
         stage = payload.get("stage", "executing")
         message = payload.get("message", "Deploying...")
@@ -498,6 +502,8 @@ class AgentDeploymentExecutor:
             "failed": 100,
         }
         progress = progress_map.get(stage, 50)
+        if payload.get("percent"):
+            progress = payload["percent"]
 
         # If we have service-level progress, calculate more accurate progress
         if services:
@@ -709,11 +715,8 @@ class AgentDeploymentExecutor:
             # reused containers from previous deployments
             container_ids_to_link = []
             for service_name, service_result in services.items():
-                container_id = service_result.get("container_id", "")
-                if container_id:
-                    short_id = container_id[:12] if len(container_id) > 12 else container_id
-                    composite_key = f"{host_id}:{short_id}"
-                    container_ids_to_link.append(composite_key)
+                for short_id in get_service_container_ids(service_result):
+                    container_ids_to_link.append(f"{host_id}:{short_id}")
 
             if container_ids_to_link:
                 deleted = session.query(DeploymentMetadata).filter(
@@ -725,47 +728,47 @@ class AgentDeploymentExecutor:
             # Create new records with current container IDs
             utcnow = datetime.now(timezone.utc)
             for service_name, service_result in services.items():
-                container_id = service_result.get("container_id", "")
+                container_ids = get_service_container_ids(service_result)
                 container_name = service_result.get("container_name", "")
 
-                if not container_id:
+                if not container_ids:
                     logger.warning(f"Service {service_name} has no container_id")
                     continue
 
-                # Ensure container_id is SHORT format (12 chars)
-                short_id = container_id[:12] if len(container_id) > 12 else container_id
-                composite_key = f"{host_id}:{short_id}"
-
-                # Create DeploymentContainer record
-                link = DeploymentContainer(
-                    deployment_id=deployment_id,
-                    container_id=short_id,
-                    service_name=service_name,
-                    created_at=utcnow
-                )
-                session.add(link)
-
-                # Create DeploymentMetadata record
-                metadata = DeploymentMetadata(
-                    container_id=composite_key,
-                    host_id=host_id,
-                    deployment_id=deployment_id,
-                    is_managed=True,
-                    service_name=service_name,
-                )
-                session.add(metadata)
+                # One record per replica (scaled services have several)
+                for short_id in container_ids:
+                    composite_key = f"{host_id}:{short_id}"
 
-                logger.debug(
-                    f"Linked container {short_id} ({container_name}) "
-                    f"to deployment {deployment_id} as service {service_name}"
-                )
+                    # Create DeploymentContainer record
+                    link = DeploymentContainer(
+                        deployment_id=deployment_id,
+                        container_id=short_id,
+                        service_name=service_name,
+                        created_at=utcnow
+                    )
+                    session.add(link)
+
+                    # Create DeploymentMetadata record
+                    metadata = DeploymentMetadata(
+                        container_id=composite_key,
+                        host_id=host_id,
+                        deployment_id=deployment_id,
+                        is_managed=True,
+                        service_name=service_name,
+                    )
+                    session.add(metadata)
+
+                    logger.debug(
+                        f"Linked container {short_id} ({container_name}) "
+                        f"to deployment {deployment_id} as service {service_name}"
+                    )
 
             # Mark deployment as committed (containers exist in Docker)
             deployment.committed = True
 
             session.commit()
             logger.info(
-                f"Linked {len(services)} containers to deployment {deployment_id}"
+                f"Linked {len(container_ids_to_link)} containers to deployment {deployment_id}"
             )
 
     async def _update_deployment_status(
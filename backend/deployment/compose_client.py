@@ -21,6 +21,10 @@ from typing import Any, Awaitable, Callable, Dict, List, Optional
 
 import httpx
 
+from utils.progress_schema import OPERATION_DEPLOY, normalize_progress
+from utils.service_compat import COMPOSE_SERVICE, not_found_hint, record_component
+from utils.service_headers import dockmon_headers
+
 logger = logging.getLogger(__name__)
 
 # Default socket path - matches compose-service default
@@ -122,11 +126,16 @@ class ComposeClient:
             transport = httpx.HTTPTransport(uds=self.socket_path)
             with httpx.Client(transport=transport, timeout=timeout) as client:
                 response = client.get("http://localhost/health")
+                # A degraded service still reports its version
+                try:
+                    data = response.json()
+                except ValueError:
+                    data = None
+                if isinstance(data, dict):
+                    record_component(COMPOSE_SERVICE, data)
                 if response.status_code != 200:
                     return False
 
-                data = response.json()
-
                 if require_docker:
                     return data.get("status") == "ok" and data.get("docker_ok", False)
 
@@ -157,6 +166,9 @@ class ComposeClient:
         tls_key: Optional[str] = None,
         registry_credentials: Optional[List[Dict[str, str]]] = None,
         stacks_dir: Optional[str] = None,
+        host_id: Optional[str] = None,
+        user: Optional[str] = None,
+        source: Optional[str] = None,
     ) -> DeployResult:
         """
         Deploy a compose stack (JSON response, no streaming).
@@ -180,6 +192,10 @@ class ComposeClient:
             tls_key: TLS client key PEM
             registry_credentials: List of registry credentials
             stacks_dir: Persistent stacks directory (uses STACKS_DIR env or default)
+            host_id: DockMon host ID, sent so the deployment is announced on
+                the activity stream
+            user: Who asked for the deployment, for the project's history
+            source: Where it was asked for, e.g. "ui" or "api"
 
         Returns:
             DeployResult with deployment outcome
@@ -223,11 +239,13 @@ class ComposeClient:
                 response = await client.post(
                     "http://localhost/deploy",
                     json=request,
+                    headers=dockmon_headers(host_id, user, source),
                 )
 
                 if response.status_code != 200:
+                    hint = not_found_hint(COMPOSE_SERVICE) if response.status_code == 404 else ""
                     raise ComposeServiceError(
-                        f"Compose service error: HTTP {response.status_code}"
+                        f"Compose service error: HTTP {response.status_code}{hint}"
                     )
 
                 data = response.json()
@@ -260,6 +278,9 @@ class ComposeClient:
         tls_key: Optional[str] = None,
         registry_credentials: Optional[List[Dict[str, str]]] = None,
         stacks_dir: Optional[str] = None,
+        host_id: Optional[str] = None,
+        user: Optional[str] = None,
+        source: Optional[str] = None,
     ) -> DeployResult:
         """
         Deploy with SSE progress streaming.
@@ -316,7 +337,7 @@ class ComposeClient:
                             "POST",
                             "http://localhost/deploy",
                             json=request,
-                            headers={"Accept": "text/event-stream"},
+                            headers={"Accept": "text/event-stream", **dockmon_headers(host_id, user, source)},
                         ) as response:
                             logger.debug(f"SSE stream opened, status={response.status_code}")
                             event_type = None
@@ -336,9 +357,10 @@ class ComposeClient:
                                         continue
 
                                     if event_type == "progress":
+                                        data = normalize_progress(data, OPERATION_DEPLOY)
                                         event = ProgressEvent(
                                             stage=data.get("stage", ""),
-                                            progress=data.get("progress", 0),
+                                            progress=data.get("percent", 0),
                                             message=data.get("message", ""),
                                             service=data.get("service"),
                                             service_idx=data.get("service_idx"),
@@ -429,6 +451,68 @@ class ComposeClient:
 
         return request
 
+    async def prune_images(
+        self,
+        policy: Dict[str, Any],
+        host_id: Optional[str] = None,
+        docker_host: Optional[str] = None,
+        tls_ca_cert: Optional[str] = None,
+        tls_cert: Optional[str] = None,
+        tls_key: Optional[str] = None,
+        timeout: int = 600,
+    ) -> Dict[str, Any]:
+        """
+        Prune unused images on a direct host under a policy's safety rules.
+
+        Args:
+            policy: keep_last_tags and dry_run, see ImagePruneRequest
+            host_id: DockMon host ID, sent so the result is announced on
+                the activity stream
+            docker_host: Remote Docker host (empty for local)
+            tls_ca_cert: TLS CA certificate PEM
+            tls_cert: TLS client certificate PEM
+            tls_key: TLS client key PEM
+            timeout: Operation timeout in seconds
+
+        Returns:
+            The prune report: removed, kept and failed images with
+            removed_count and space_reclaimed
+        """
+        request: Dict[str, Any] = dict(policy)
+        if docker_host:
+            request["docker_host"] = docker_host
+        if tls_ca_cert:
+            request["tls_ca_cert"] = tls_ca_cert
+        if tls_cert:
+            request["tls_cert"] = tls_cert
+        if tls_key:
+            request["tls_key"] = tls_key
+        headers = dockmon_headers(host_id)
+
+        try:
+            transport = httpx.AsyncHTTPTransport(uds=self.socket_path)
+            async with httpx.AsyncClient(
+                transport=transport,
+                timeout=httpx.Timeout(connect=10.0, read=float(timeout + 60), write=10.0, pool=10.0),
+            ) as client:
+                response = await client.post(
+                    "http://localhost/images/prune",
+                    json=request,
+                    headers=headers,
+                )
+
+                if response.status_code != 200:
+                    hint = not_found_hint(COMPOSE_SERVICE) if response.status_code == 404 else ""
+                    raise ComposeServiceError(
+                        f"Compose service error: HTTP {response.status_code}: {response.text.strip()}{hint}"
+                    )
+                return response.json()
+
+        except httpx.ConnectError:
+            raise ComposeServiceUnavailable(
+                f"Cannot connect to compose service at {self.socket_path}"
+            )
+
     def _parse_result(self, data: Dict[str, Any]) -> DeployResult:
         """Parse JSON response into DeployResult."""
         error_msg = None
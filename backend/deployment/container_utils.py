@@ -118,3 +118,27 @@ def get_deployed_hosts_for_stack(containers, stack_name: str) -> List[HostInfo]:
         hosts.append(HostInfo(host_id=host_id, host_name=host_name))
 
     return hosts
+
+
+def get_service_container_ids(service_result: Dict) -> List[str]:
+    """
+    Get the short IDs of every container of a deployed service.
+
+    Deploy results list all replicas of a scaled service in container_ids;
+    results from older agents and compose-services only have container_id.
+
+    Args:
+        service_result: ServiceResult dict from a deploy result
+
+    Returns:
+        Short (12 char) container IDs, without duplicates
+    """
+    ids = service_result.get('container_ids') or [service_result.get('container_id')]
+    short_ids: List[str] = []
+    for container_id in ids:
+        if not container_id:
+            continue
+        short_id = container_id[:12]
+        if short_id not in short_ids:
+            short_ids.append(short_id)
+    return short_ids
@@ -17,6 +17,7 @@ from database import DatabaseManager
 from utils.async_docker import async_docker_call, async_containers_list
 from utils.container_health import wait_for_container_health
 from utils.image_pull_progress import ImagePullProgress
+from utils.log_timestamps import LOG_TIMESTAMPS_NONE, format_log_timestamps, parse_log_timestamps
 from utils.network_helpers import manually_connect_networks
 from utils.registry_credentials import get_registry_credentials
 
@@ -132,7 +133,9 @@ class HostConnector(ABC):
         self,
         container_id: str,
         tail: int = 100,
-        since: Optional[str] = None
+        since: Optional[str] = None,
+        timestamps: Optional[str] = None,
+        tz: Optional[str] = None
     ) -> str:
         """
         Get container logs.
@@ -141,6 +144,9 @@ class HostConnector(ABC):
             container_id: Container SHORT ID (12 chars)
             tail: Number of lines to return (default 100)
             since: Timestamp filter (ISO format)
+            timestamps: Line timestamps: utc, none, local or relative (see
+                utils.log_timestamps); omitted keeps the connector's default
+            tz: IANA time zone for local timestamps
 
         Returns:
             Container logs as string
@@ -399,7 +405,9 @@ class DirectDockerConnector(HostConnector):
         self,
         container_id: str,
         tail: int = 100,
-        since: Optional[str] = None
+        since: Optional[str] = None,
+        timestamps: Optional[str] = None,
+        tz: Optional[str] = None
     ) -> str:
         """Get container logs"""
         client = self._get_client()
@@ -408,9 +416,15 @@ class DirectDockerConnector(HostConnector):
         logs_kwargs = {'tail': tail}
         if since:
             logs_kwargs['since'] = since
+        if timestamps or tz:
+            mode, location = parse_log_timestamps(timestamps, tz)
+            logs_kwargs['timestamps'] = mode != LOG_TIMESTAMPS_NONE
 
         logs = await async_docker_call(container.logs, **logs_kwargs)
-        return logs.decode('utf-8') if isinstance(logs, bytes) else logs
+        logs = logs.decode('utf-8') if isinstance(logs, bytes) else logs
+        if timestamps or tz:
+            logs = format_log_timestamps(logs, mode, location)
+        return logs
 
     async def pull_image(
         self,
@@ -764,7 +778,9 @@ class AgentConnector(HostConnector):
         self,
         container_id: str,
         tail: int = 100,
-        since: Optional[str] = None
+        since: Optional[str] = None,
+        timestamps: Optional[str] = None,
+        tz: Optional[str] = None
     ) -> str:
         """Get container logs"""
         payload = {
@@ -774,6 +790,10 @@ class AgentConnector(HostConnector):
         }
         if since:
             payload["since"] = since
+        if timestamps:
+            payload["timestamps"] = timestamps
+        if tz:
+            payload["tz"] = tz
 
         command = {
             "type": "container_operation",
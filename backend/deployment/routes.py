@@ -28,6 +28,7 @@ from audit.audit_logger import AuditAction, log_stack_change
 from auth.utils import get_auditable_user_info
 from utils.keys import parse_composite_key
 from utils.env_files import is_safe_env_filename, parse_env_file_refs
+from utils.service_headers import requester_of
 from agent.command_executor import get_agent_command_executor, RetryPolicy
 from agent.manager import AgentManager
 from deployment.compose_client import ComposeClient, ComposeServiceError, ComposeServiceUnavailable
@@ -345,6 +346,9 @@ async def deploy_stack(
     # Generate transient deployment ID for progress tracking
     deployment_id = f"{request.host_id}:{request.stack_name}:{uuid.uuid4().hex[:8]}"
 
+    # Recorded in the project's deployment history by the compose service
+    requested_by, source = requester_of(current_user)
+
     # Get docker monitor for broadcasting
     docker_monitor = get_docker_monitor()
 
@@ -447,6 +451,9 @@ async def deploy_stack(
                     tls_cert=host_info.get('tls_cert'),
                     tls_key=host_info.get('tls_key'),
                     registry_credentials=host_info.get('registry_credentials'),
+                    host_id=request.host_id,
+                    user=requested_by,
+                    source=source,
                 )
 
                 if result.success or result.partial_success:
@@ -13,6 +13,7 @@ from sqlalchemy.orm import Session
 from database import DatabaseManager, Deployment, DockerHostDB, DeploymentMetadata, DeploymentContainer
 from utils.registry_credentials import get_all_registry_credentials
 from .compose_validator import ComposeValidator, ComposeValidationError
+from .container_utils import get_service_container_ids
 from .compose_client import (
     ComposeClient,
     ProgressEvent,
@@ -143,6 +144,8 @@ async def _execute_via_go_service(
             tls_cert=host_info.get('tls_cert'),
             tls_key=host_info.get('tls_key'),
             registry_credentials=host_info.get('registry_credentials'),
+            host_id=deployment.host_id,
+            user=deployment.created_by,
         )
 
         # Handle result
@@ -162,8 +165,7 @@ async def _execute_via_go_service(
                 # Collect container IDs for this deployment
                 container_ids_to_link = []
                 for service_name, service_info in result.services.items():
-                    container_id = service_info.get('container_id', '')[:12]
-                    if container_id:
+                    for container_id in get_service_container_ids(service_info):
                         composite_id = f"{deployment.host_id}:{container_id}"
                         container_ids_to_link.append(composite_id)
 
@@ -179,8 +181,7 @@ async def _execute_via_go_service(
 
                 # Now insert fresh metadata
                 for service_name, service_info in result.services.items():
-                    container_id = service_info.get('container_id', '')[:12]
-                    if container_id:
+                    for container_id in get_service_container_ids(service_info):
                         create_deployment_metadata(
                             session,
                             deployment.id,
@@ -430,6 +430,7 @@ class ContainerDiscovery:
         # Agent-based hosts - get container data from agent via WebSocket
         if host.connection_type == "agent":
             from agent.command_executor import get_agent_command_executor
+            from agent.container_inventory import get_container_inventory
             from database import Agent
 
             # Get agent ID
@@ -441,31 +442,43 @@ class ContainerDiscovery:
 
                 agent_id = agent.id
 
-            # Request container list from agent using command executor
+            # Prefer the agent's pushed inventory; otherwise request the list and
+            # subscribe so later cycles only receive deltas
             try:
-                executor = get_agent_command_executor()
-
-                # Use legacy command protocol (agent supports both legacy and new protocol)
-                command = {
-                    "type": "command",
-                    "command": "list_containers"
-                }
-
-                result = await executor.execute_command(
-                    agent_id,
-                    command,
-                    timeout=30.0
-                )
+                inventory = get_container_inventory()
+                docker_containers = inventory.get(agent_id)
 
-                if not result.success:
-                    logger.error(f"Failed to get containers from agent {agent_id[:8]}...: {result.error}")
-                    host.status = "offline"
-                    host.error = f"Agent error: {result.error}"
-                    return containers
+                if docker_containers is None:
+                    executor = get_agent_command_executor()
+
+                    # Use legacy command protocol (agent supports both legacy and new protocol)
+                    command = {
+                        "type": "command",
+                        "command": "list_containers",
+                        "payload": {"subscribe": True}
+                    }
+
+                    result = await executor.execute_command(
+                        agent_id,
+                        command,
+                        timeout=30.0
+                    )
 
-                # Parse container data from agent response
-                # Agent returns Docker API format: list of container objects
-                docker_containers = result.response if isinstance(result.response, list) else []
+                    if not result.success:
+                        logger.error(f"Failed to get containers from agent {agent_id[:8]}...: {result.error}")
+                        host.status = "offline"
+                        host.error = f"Agent error: {result.error}"
+                        return containers
+
+                    # Parse container data from agent response. Subscribing agents
+                    # return {"revision", "containers"}; older agents ignore the
+                    # payload and return the Docker API list of container objects.
+                    response = result.response
+                    if isinstance(response, dict) and isinstance(response.get("containers"), list):
+                        docker_containers = response["containers"]
+                        inventory.apply_snapshot(agent_id, response.get("revision", 0), docker_containers)
+                    else:
+                        docker_containers = response if isinstance(response, list) else []
 
                 host.status = "online"
                 host.container_count = len(docker_containers)
@@ -846,6 +846,7 @@ class DockerMonitor:
                         agent_id = agent.id
                         # Close the agent's WebSocket connection (creates its own session)
                         await agent_connection_manager.unregister_connection(agent_id)
+                        agent_connection_manager.forget_connection_stats(agent_id)
                         logger.info(f"Disconnected agent {agent_id[:8]}... for host {host_name}")
 
                         # Force-removed agents no longer tear down via their socket
@@ -1201,7 +1202,7 @@ class DockerMonitor:
                 logger.info(f"Updated system info for {host.name}: {os_version} / {platform_type} {docker_version}")
 
             # Re-register host with stats and event services (in case URL changed)
-            # Note: add_docker_host() automatically closes old client if it exists
+            # Note: add_docker_host() only replaces the client if the URL or TLS settings changed
             try:
                 import asyncio
                 stats_client = get_stats_client()
@@ -1211,7 +1212,7 @@ class DockerMonitor:
                         # Skip agent hosts - they use WebSocket for stats/events
                         # Agent hosts have url="agent://" which is not a valid Docker URL
                         if host.connection_type != "agent":
-                            # Re-register with stats service (automatically closes old client)
+                            # Re-register with stats service (replaces the client if its settings changed)
                             is_local = host.url.startswith("unix://")
                             await stats_client.add_docker_host(host.id, host.name, host.url, config.tls_ca, config.tls_cert, config.tls_key, host.num_cpus, host.total_memory, is_local)
                             logger.info(f"Re-registered {host.name} ({host.id[:8]}) with stats service")
@@ -1406,6 +1407,11 @@ class DockerMonitor:
         """Rename a specific container"""
         return await self.operations.rename_container(host_id, container_id, new_name)
 
+    async def adopt_into_stack(self, host_id: str, container_id: str, project: str, service: str,
+                               working_dir: str = None, config_files: str = None) -> bool:
+        """Adopt a standalone container into a compose stack"""
+        return await self.operations.adopt_into_stack(host_id, container_id, project, service, working_dir, config_files)
+
     async def delete_container(self, host_id: str, container_id: str, container_name: str, remove_volumes: bool = False) -> dict:
         """Delete a specific container"""
         return await self.operations.delete_container(host_id, container_id, container_name, remove_volumes)
@@ -1461,6 +1467,23 @@ class DockerMonitor:
     async def _handle_docker_event(self, event: dict):
         """Handle Docker events from Go service"""
         try:
+            # Image/volume/network changes only feed the host audit trail.
+            # Events without a type predate typed events and are container events.
+            event_kind = event.get('type') or 'container'
+            if event_kind != 'container':
+                host_id = event.get('host_id', '')
+                host = self.hosts.get(host_id)
+                self.event_logger.log_host_resource_event(
+                    host_name=host.name if host else host_id,
+                    host_id=host_id,
+                    resource_type=event_kind,
+                    action=event.get('action', ''),
+                    resource_name=event.get('actor_name') or event.get('actor_id', ''),
+                    resource_id=event.get('actor_id'),
+                    attributes=event.get('attributes'),
+                )
+                return
+
             action = event.get('action', '')
             container_id = event.get('container_id', '')
             container_name = event.get('container_name', '')
@@ -1655,7 +1678,15 @@ class DockerMonitor:
         # Note: streaming_containers is now managed by self.stats_manager
         stats_client = get_stats_client()
 
+        # Flag outdated Go services up front; endpoints they lack would
+        # otherwise only show up as 404s. The compose check blocks on a
+        # socket, so it runs off the event loop.
+        from deployment.compose_client import get_compose_client
+        await stats_client.check_version()
+        await asyncio.to_thread(get_compose_client().health_check, False)
+
         # Register all hosts with the stats and event services on startup
+        stats_payloads = []
         for host_id, host in self.hosts.items():
             # Skip agent hosts - they send stats via WebSocket, not Docker API
             # Agent hosts have url="agent://" which is not a valid Docker URL
@@ -1673,10 +1704,9 @@ class DockerMonitor:
                     num_cpus = db_host.num_cpus if db_host else None
                     total_memory = db_host.total_memory if db_host else None
 
-                # Register with stats service
+                # Stats service registration is batched below
                 is_local = host.url.startswith("unix://")
-                await stats_client.add_docker_host(host_id, host.name, host.url, tls_ca, tls_cert, tls_key, num_cpus, total_memory, is_local)
-                logger.info(f"Registered host {host.name} ({host_id[:8]}) with stats service")
+                stats_payloads.append(stats_client.docker_host_payload(host_id, host.name, host.url, tls_ca, tls_cert, tls_key, num_cpus, total_memory, is_local))
 
                 # Register with event service
                 await stats_client.add_event_host(host_id, host.name, host.url, tls_ca, tls_cert, tls_key)
@@ -1684,6 +1714,20 @@ class DockerMonitor:
             except Exception as e:
                 logger.error(f"Failed to register host {host_id} with services: {e}")
 
+        # Register with stats service in one request. Idempotent, so hosts the
+        # stats service already knows (e.g. after a backend-only restart) keep
+        # their active streams.
+        registered = await stats_client.bulk_add_docker_hosts(stats_payloads)
+        if registered is not None:
+            for host_id, result in registered.items():
+                logger.info(f"Registered host {host_id[:8]} with stats service ({result})")
+        else:
+            # Bulk request failed (e.g. a stats service without bulk_add);
+            # fall back to registering hosts one at a time
+            logger.warning(f"Bulk registration failed, registering {len(stats_payloads)} hosts individually")
+            for payload in stats_payloads:
+                await stats_client.add_docker_host_payload(payload)
+
         # Connect to event stream WebSocket
         try:
             await stats_client.connect_event_stream(self._handle_docker_event)
@@ -1854,6 +1898,9 @@ class DockerMonitor:
                                     "mem_bytes": total_mem_bytes,
                                     "net_bytes_per_sec": sparklines["net"][-1] if sparklines["net"] else 0
                                 }
+                                per_core = self.stats_history.get_cpu_per_core(host_id)
+                                if per_core:
+                                    host_metrics[host_id]["cpu_per_core"] = per_core
                                 continue  # Skip container aggregation for this host
 
                             # Local/mTLS hosts OR containerized agents (no host stats): Aggregate from container stats
@@ -6,7 +6,7 @@ Handles container start, stop, restart operations
 import asyncio
 import logging
 import time
-from typing import Dict
+from typing import Dict, Optional
 
 from docker import DockerClient
 from fastapi import HTTPException
@@ -694,6 +694,37 @@ class ContainerOperations:
             )
             raise HTTPException(status_code=500, detail="Failed to start container")
 
+    async def adopt_into_stack(
+        self,
+        host_id: str,
+        container_id: str,
+        project: str,
+        service: str,
+        working_dir: Optional[str] = None,
+        config_files: Optional[str] = None
+    ) -> bool:
+        """
+        Adopt a standalone container into a compose stack.
+
+        Only agent hosts can adopt: the agent recreates the container through
+        its update engine, which direct hosts don't have.
+
+        Raises:
+            HTTPException: 400 for hosts without an agent, or if the agent fails
+        """
+        agent_id = self.agent_manager.get_agent_for_host(host_id)
+        if not agent_id:
+            raise HTTPException(
+                status_code=400,
+                detail="Adopting containers into a stack requires an agent host"
+            )
+
+        logger.info(f"Routing adopt_into_stack for host {host_id} through agent {agent_id}")
+        return await self.agent_operations.adopt_into_stack(
+            host_id, container_id, project, service,
+            working_dir=working_dir, config_files=config_files
+        )
+
     async def delete_container(self, host_id: str, container_id: str, container_name: str, remove_volumes: bool = False) -> dict:
         """
         Delete a container permanently.
@@ -959,7 +990,8 @@ class ContainerOperations:
             )
             raise HTTPException(status_code=500, detail="Failed to delete container")
 
-    async def get_container_logs(self, host_id: str, container_id: str, tail: int = 100, since: str = None) -> dict:
+    async def get_container_logs(self, host_id: str, container_id: str, tail: int = 100, since: str = None,
+                                 timestamps: str = None, tz: str = None) -> dict:
         """
         Get container logs.
 
@@ -970,6 +1002,9 @@ class ContainerOperations:
             container_id: Container ID
             tail: Number of lines to retrieve (default: 100)
             since: ISO timestamp for getting logs since a specific time
+            timestamps: How line timestamps are rendered: utc (default), none,
+                local or relative
+            tz: IANA time zone for local timestamps (default: the host's)
 
         Returns:
             Dict with container_id, logs (list of {timestamp, log}), and last_timestamp
@@ -978,6 +1013,7 @@ class ContainerOperations:
             HTTPException: If host not found or operation fails
         """
         from utils.async_docker import async_docker_call
+        from utils.log_timestamps import LOG_TIMESTAMPS_NONE, format_log_timestamps, parse_log_timestamps
         from datetime import datetime, timezone, timedelta
 
         # Security constants (match main.py)
@@ -987,13 +1023,20 @@ class ContainerOperations:
         # Clamp tail to prevent DoS
         tail = max(1, min(tail, MAX_LOG_TAIL))
 
+        try:
+            mode, location = parse_log_timestamps(timestamps, tz)
+        except ValueError as e:
+            raise HTTPException(status_code=400, detail=str(e))
+
         # Check if host has an agent - route through agent if available (v2.2.0)
         agent_id = self.agent_manager.get_agent_for_host(host_id)
         if agent_id:
             logger.info(f"Routing get_container_logs for host {host_id} through agent {agent_id}")
             # Agent returns raw logs string, we need to parse it
-            raw_logs = await self.agent_operations.get_container_logs(host_id, container_id, tail)
-            return self._parse_logs_response(container_id, raw_logs)
+            raw_logs = await self.agent_operations.get_container_logs(
+                host_id, container_id, tail, timestamps=mode, tz=tz
+            )
+            return self._parse_logs_response(container_id, raw_logs, mode)
 
         # Legacy path: Direct Docker socket access
         if host_id not in self.clients:
@@ -1014,7 +1057,7 @@ class ContainerOperations:
 
             # Prepare log options
             log_kwargs = {
-                'timestamps': True,
+                'timestamps': mode != LOG_TIMESTAMPS_NONE,
                 'tail': tail
             }
 
@@ -1052,7 +1095,8 @@ class ContainerOperations:
             except asyncio.TimeoutError:
                 raise HTTPException(status_code=504, detail="Timeout fetching logs")
 
-            return self._parse_logs_response(container_id, logs)
+            logs = format_log_timestamps(logs, mode, location)
+            return self._parse_logs_response(container_id, logs, mode)
 
         except HTTPException:
             raise
@@ -1060,24 +1104,32 @@ class ContainerOperations:
             logger.error(f"Failed to get logs for {container_id}: {e}")
             raise HTTPException(status_code=500, detail="Failed to get container logs")
 
-    def _parse_logs_response(self, container_id: str, raw_logs: str) -> dict:
+    def _parse_logs_response(self, container_id: str, raw_logs: str, mode: str = "utc") -> dict:
         """
         Parse raw Docker logs into structured response.
 
         Args:
             container_id: Container ID
             raw_logs: Raw logs string from Docker
+            mode: Timestamps mode the logs were rendered with. Outside utc a
+                line's timestamp is kept as rendered (None if it has none).
 
         Returns:
             Dict with container_id, logs list, and last_timestamp
         """
         from datetime import datetime, timezone
+        from utils.log_timestamps import LOG_TIMESTAMPS_UTC, split_log_line
 
         parsed_logs = []
         for line in raw_logs.split('\n'):
             if not line.strip():
                 continue
 
+            if mode != LOG_TIMESTAMPS_UTC:
+                timestamp, log_text = split_log_line(line, mode)
+                parsed_logs.append({"timestamp": timestamp, "log": log_text})
+                continue
+
             try:
                 space_idx = line.find(' ')
                 if space_idx > 0:
@@ -141,6 +141,9 @@ class StatsHistoryBuffer:
         # host_id -> last update timestamp
         self._agent_fed_hosts: Dict[str, datetime] = {}
 
+        # Latest per-core CPU breakdown reported by an agent (not buffered)
+        self._cpu_per_core: Dict[str, List[float]] = {}
+
     def add_stats(self, host_id: str, cpu: float, mem: float, net: float,
                   memory_used_bytes: Optional[int] = None,
                   memory_limit_bytes: Optional[int] = None):
@@ -236,6 +239,7 @@ class StatsHistoryBuffer:
                 del self._history[host_id]
                 if host_id in self._last_raw:
                     del self._last_raw[host_id]
+                self._cpu_per_core.pop(host_id, None)
                 logger.debug(f"Cleaned up empty history for host {host_id[:8]}")
 
     def remove_host(self, host_id: str):
@@ -244,6 +248,7 @@ class StatsHistoryBuffer:
             del self._history[host_id]
         if host_id in self._last_raw:
             del self._last_raw[host_id]
+        self._cpu_per_core.pop(host_id, None)
         logger.debug(f"Removed stats history for host {host_id[:8]}")
 
     def get_stats_summary(self) -> dict:
@@ -257,6 +262,22 @@ class StatsHistoryBuffer:
             }
         }
 
+    def set_cpu_per_core(self, host_id: str, per_core: Optional[List[float]]):
+        """
+        Store the latest per-core CPU breakdown for a host.
+
+        Only the newest snapshot is kept; None clears it (the agent stopped
+        reporting one, e.g. HOST_CPU_PER_CORE=off).
+        """
+        if per_core:
+            self._cpu_per_core[host_id] = per_core
+        else:
+            self._cpu_per_core.pop(host_id, None)
+
+    def get_cpu_per_core(self, host_id: str) -> Optional[List[float]]:
+        """Latest per-core CPU breakdown for a host, or None if unknown"""
+        return self._cpu_per_core.get(host_id)
+
     def mark_agent_fed(self, host_id: str):
         """
         Mark that this host is receiving stats directly from an agent (systemd mode).
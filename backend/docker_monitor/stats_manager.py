@@ -13,6 +13,9 @@ from utils.keys import make_composite_key
 
 logger = logging.getLogger(__name__)
 
+# Container label naming the stats group the stats-service sums usage by
+STATS_GROUP_LABEL = "dockmon.group"
+
 
 class StatsManager:
     """Manages stats collection decisions based on settings and modal state"""
@@ -142,7 +145,8 @@ class StatsManager:
                     success = await stats_client.start_container_stream(
                         container.short_id,  # Docker API accepts short IDs
                         container.name,
-                        container.host_id
+                        container.host_id,
+                        group=(container.labels or {}).get(STATS_GROUP_LABEL, "").strip() or None,
                     )
                     # Only mark as streaming if the request succeeded
                     if success:
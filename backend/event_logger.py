@@ -38,6 +38,7 @@ class EventType(str, Enum):
     DISCONNECTION = "disconnection"
     HOST_ADDED = "host_added"
     HOST_REMOVED = "host_removed"
+    RESOURCE_CHANGE = "resource_change"  # Image/volume/network changes on a host
 
     # System events
     STARTUP = "startup"
@@ -741,6 +742,58 @@ class EventLogger:
             triggered_by=triggered_by
         )
 
+    # Past-tense wording for resource event titles; unknown actions are shown as-is
+    RESOURCE_ACTION_WORDS = {
+        'pull': 'pulled',
+        'push': 'pushed',
+        'delete': 'deleted',
+        'tag': 'tagged',
+        'untag': 'untagged',
+        'import': 'imported',
+        'load': 'loaded',
+        'prune': 'pruned',
+        'create': 'created',
+        'destroy': 'removed',
+        'remove': 'removed',
+    }
+
+    def log_host_resource_event(self,
+                                host_name: str,
+                                host_id: str,
+                                resource_type: str,
+                                action: str,
+                                resource_name: str,
+                                resource_id: Optional[str] = None,
+                                attributes: Optional[Dict[str, str]] = None):
+        """Log an image, volume or network change on a host (audit trail)"""
+        context = EventContext(
+            host_id=host_id,
+            host_name=host_name
+        )
+
+        if action == 'prune':
+            # Prune events describe the host, not a single resource
+            title = f"{resource_type.capitalize()}s pruned on {host_name}"
+        else:
+            verb = self.RESOURCE_ACTION_WORDS.get(action, action)
+            title = f"{resource_type.capitalize()} {resource_name} {verb} on {host_name}"
+
+        self.log_event(
+            category=EventCategory.HOST,
+            event_type=EventType.RESOURCE_CHANGE,
+            title=title,
+            severity=EventSeverity.INFO,
+            context=context,
+            triggered_by="docker",
+            details={
+                'resource_type': resource_type,
+                'action': action,
+                'resource_name': resource_name,
+                'resource_id': resource_id,
+                'attributes': attributes or {},
+            }
+        )
+
     def log_alert_rule_created(self,
                               rule_name: str,
                               rule_id: str,
@@ -69,7 +69,7 @@ from models.request_models import (
     AutoRestartRequest, DesiredStateRequest, AlertRuleCreate, AlertRuleUpdate,
     NotificationChannelCreate, NotificationChannelUpdate, EventLogFilter, BatchJobCreate,
     ContainerTagUpdate, HostTagUpdate, HttpHealthCheckConfig, GenerateTokenRequest,
-    RenameContainerRequest, CreateNetworkRequest
+    RenameContainerRequest, CreateNetworkRequest, ImagePruneRequest, AdoptIntoStackRequest
 )
 from audit.audit_logger import AuditAction, AuditEntityType, log_audit, log_container_action, log_host_change, log_settings_change, get_client_info
 from security.audit import security_audit
@@ -87,7 +87,10 @@ from utils.async_docker import async_docker_call, async_client_ping, async_clien
 from utils.base_path import get_base_path
 from utils.response_filtering import filter_container_env, filter_container_inspect_env, filter_ws_container_message
 from utils.host_ips import deserialize_host_ips
+from utils.service_headers import requester_of
 from utils.client_ip import get_client_ip_ws
+from utils.service_compat import get_components
+from utils.version import get_app_version
 from utils.networks import BUILTIN_NETWORKS, format_network, create_network_local
 from utils.timestamps import normalize_docker_timestamp
 import aiohttp
@@ -99,6 +102,8 @@ from agent.connection_manager import agent_connection_manager
 from packaging.version import parse as parse_version, InvalidVersion
 from deployment import routes as deployment_routes, DeploymentExecutor
 from deployment import stack_routes
+from deployment.compose_client import ComposeServiceError, ComposeServiceUnavailable, get_compose_client
+from deployment.stack_executor import _get_host_connection_info
 
 # Configure logging
 setup_logging()
@@ -560,12 +565,51 @@ async def health_check():
     payload = {
         "status": "healthy" if healthy else "unhealthy",
         "service": "dockmon-backend",
+        "version": get_app_version(),
         "subsystems": subsystems,
+        # Go service versions, flagged when older than this backend needs
+        "components": get_components(),
     }
     if not healthy:
         return JSONResponse(status_code=503, content=payload)
     return payload
 
+@app.get(
+    "/api/health/full",
+    tags=["system"],
+    dependencies=[Depends(require_capability("settings.view"))],
+)
+async def full_health_check():
+    """
+    System status: the backend's health plus the stats-service's
+    /health/full (compose-service and connected agents included).
+
+    An unreachable stats-service is reported in the document rather than
+    as an error, so a status page can still render it.
+    """
+    subsystems = {"event_logger": monitor.event_logger.is_healthy()}
+    try:
+        services = await get_stats_client().get_full_health()
+    except (StatsServiceClient.HistoryUpstreamError, aiohttp.ClientError) as e:
+        logger.warning(f"Full health check: stats-service unavailable: {e}")
+        services = {"status": "unreachable", "error": str(e)}
+    healthy = all(subsystems.values())
+    if not healthy or services.get("status") == "unreachable":
+        status_ = "unhealthy"
+    elif services.get("status") != "ok":
+        status_ = "degraded"
+    else:
+        status_ = "healthy"
+    return {
+        "status": status_,
+        "backend": {
+            "version": get_app_version(),
+            "subsystems": subsystems,
+            "components": get_components(),
+        },
+        "services": services,
+    }
+
 @app.get("/openapi.json", include_in_schema=False)
 async def openapi_json(current_user: dict = Depends(get_current_user)):
     """API schema (auth-gated to avoid unauthenticated reconnaissance)."""
@@ -1149,6 +1193,81 @@ async def get_container_stats_history(
         raise _map_history_upstream_error(e)
 
 
+@app.get(
+    "/api/stats/groups",
+    tags=["containers"],
+    dependencies=[Depends(require_capability("containers.view"))],
+)
+async def get_group_stats(group: Optional[str] = Query(None, max_length=255)):
+    """
+    Live usage summed per dockmon.group container label, across hosts and
+    compose projects. Proxy to stats-service GET /api/stats/groups.
+    """
+    try:
+        return await get_stats_client().get_group_stats(group=group)
+    except (StatsServiceClient.HistoryUpstreamError, aiohttp.ClientError) as e:
+        raise _map_history_upstream_error(e)
+
+
+STATS_EXPORT_FORMAT_PATTERN = r"^(csv|json)$"
+STATS_EXPORT_STEP_PATTERN = r"^(10s|1m|5m)$"
+
+
+def _stats_export_response(export) -> Response:
+    """Pass a stats-service export file through, download headers included."""
+    headers = {}
+    if export.content_disposition:
+        headers["Content-Disposition"] = export.content_disposition
+    return Response(content=export.body, media_type=export.content_type, headers=headers)
+
+
+@app.get(
+    "/api/hosts/{host_id}/stats/export",
+    tags=["hosts"],
+    dependencies=[Depends(require_capability("hosts.view"))],
+)
+async def export_host_stats(
+    host_id: str,
+    from_: int = Query(..., alias="from"),
+    to: int = Query(...),
+    format_: str = Query("csv", alias="format", pattern=STATS_EXPORT_FORMAT_PATTERN),
+    step: Optional[str] = Query(None, pattern=STATS_EXPORT_STEP_PATTERN),
+):
+    """Proxy to stats-service GET /api/stats/export as a CSV or JSON download."""
+    try:
+        export = await get_stats_client().export_stats(
+            host_id=host_id, from_=from_, to=to, format_=format_, step=step,
+        )
+    except (StatsServiceClient.HistoryUpstreamError, aiohttp.ClientError) as e:
+        raise _map_history_upstream_error(e)
+    return _stats_export_response(export)
+
+
+@app.get(
+    "/api/hosts/{host_id}/containers/{container_id}/stats/export",
+    tags=["containers"],
+    dependencies=[Depends(require_capability("containers.view"))],
+)
+async def export_container_stats(
+    host_id: str,
+    container_id: str,
+    from_: int = Query(..., alias="from"),
+    to: int = Query(...),
+    format_: str = Query("csv", alias="format", pattern=STATS_EXPORT_FORMAT_PATTERN),
+    step: Optional[str] = Query(None, pattern=STATS_EXPORT_STEP_PATTERN),
+):
+    """Proxy to stats-service GET /api/stats/export as a CSV or JSON download."""
+    container_id = normalize_container_id(container_id)
+    try:
+        export = await get_stats_client().export_stats(
+            host_id=host_id, container_id=container_id,
+            from_=from_, to=to, format_=format_, step=step,
+        )
+    except (StatsServiceClient.HistoryUpstreamError, aiohttp.ClientError) as e:
+        raise _map_history_upstream_error(e)
+    return _stats_export_response(export)
+
+
 def _live_window_num_points() -> int:
     """Points a live endpoint serves, derived from the configured window.
 
@@ -1174,12 +1293,18 @@ async def get_host_stats_live(host_id: str):
     bytes, windowed to live_chart_window_seconds. Reads the same buffer the
     broadcast samples, but the broadcast stays lean -- this is the only
     extended path. Unknown/offline host -> empty arrays (no stale data).
+    Agent hosts that report a per-core breakdown also get 'cpu_per_core',
+    the latest snapshot (one percentage per core).
     """
-    return monitor.stats_history.get_sparklines(
+    sparklines = monitor.stats_history.get_sparklines(
         host_id,
         num_points=_live_window_num_points(),
         include_extended=True,
     )
+    per_core = monitor.stats_history.get_cpu_per_core(host_id)
+    if per_core:
+        sparklines["cpu_per_core"] = per_core
+    return sparklines
 
 
 @app.get(
@@ -1324,14 +1449,15 @@ async def trigger_agent_update(host_id: str, request: Request, current_user: dic
         command_executor = get_agent_command_executor()
 
         # Construct self-update command
-        binary_url = f"https://github.com/darthnorse/dockmon/releases/download/agent-v{latest_version}/dockmon-agent-{agent_os}-{agent_arch}"
+        from updates.dockmon_update_checker import agent_binary_name
+        binary_url = f"https://github.com/darthnorse/dockmon/releases/download/agent-v{latest_version}/{agent_binary_name(agent_os, agent_arch)}"
 
         # Fetch checksum for security
         checksum = None
         try:
             from updates.dockmon_update_checker import get_dockmon_update_checker
             checker = get_dockmon_update_checker(monitor.db)
-            checksum = await checker.fetch_agent_checksum(latest_version, agent_arch)
+            checksum = await checker.fetch_agent_checksum(latest_version, agent_arch, agent_os)
         except Exception as e:
             logger.warning(f"Failed to fetch checksum: {e}")
 
@@ -1451,16 +1577,22 @@ async def list_host_images(host_id: str, current_user: dict = Depends(get_curren
 
 
 @app.post("/api/hosts/{host_id}/images/prune", tags=["hosts"], dependencies=[Depends(require_capability("containers.operate"))])
-async def prune_host_images(host_id: str, request: Request, current_user: dict = Depends(get_current_user)):
+async def prune_host_images(host_id: str, request: Request, body: Optional[ImagePruneRequest] = None, current_user: dict = Depends(get_current_user)):
     """
     Prune all unused images on a specific host.
 
-    Removes images that are not referenced by any container.
+    Removes images that are not referenced by any container. With a body,
+    the prune applies its safety rules (keep_last_tags, dry_run) and returns
+    a full report of removed, kept and failed images; agent hosts run it on
+    the agent, direct hosts through the compose service.
 
     Returns:
         - removed_count: Number of images removed
         - space_reclaimed: Bytes reclaimed
     """
+    if body is not None:
+        return await _prune_host_images_with_policy(host_id, body, request, current_user)
+
     # Check if host uses agent - route through agent if available
     agent_id = monitor.operations.agent_manager.get_agent_for_host(host_id)
     if agent_id:
@@ -1499,6 +1631,43 @@ async def prune_host_images(host_id: str, request: Request, current_user: dict =
         raise HTTPException(status_code=500, detail="Failed to prune images")
 
 
+async def _prune_host_images_with_policy(host_id: str, body: ImagePruneRequest, request: Request, current_user: dict):
+    """Run a policy-based image prune on the host's agent or, for direct hosts, the compose service."""
+    policy = body.to_policy()
+    agent_id = monitor.operations.agent_manager.get_agent_for_host(host_id)
+    if agent_id:
+        logger.info(f"Routing policy prune_images for host {host_id} through agent {agent_id}")
+        report = await monitor.operations.agent_operations.prune_images(host_id, policy=policy)
+        via = 'agent'
+    else:
+        if host_id not in monitor.clients:
+            raise HTTPException(status_code=404, detail="Host not found")
+        host_info = _get_host_connection_info(host_id)
+        try:
+            report = await get_compose_client().prune_images(
+                policy,
+                host_id=host_id,
+                docker_host=host_info.get('docker_host'),
+                tls_ca_cert=host_info.get('tls_ca_cert'),
+                tls_cert=host_info.get('tls_cert'),
+                tls_key=host_info.get('tls_key'),
+            )
+        except ComposeServiceUnavailable:
+            raise HTTPException(status_code=503, detail="Compose service is not available")
+        except ComposeServiceError as e:
+            logger.error(f"Error pruning images for host {host_id}: {e}")
+            raise HTTPException(status_code=502, detail="Failed to prune images")
+        via = 'compose'
+
+    if not body.dry_run:
+        _safe_audit(current_user, log_host_change, AuditAction.PRUNE, host_id, _get_host_name(host_id), request, details={
+            'resource': 'images', 'via': via, 'policy': policy,
+            'removed_count': report.get('removed_count', 0),
+            'space_reclaimed': report.get('space_reclaimed', 0),
+        })
+    return report
+
+
 @app.get("/api/hosts/{host_id}/networks", tags=["hosts"], dependencies=[Depends(require_capability("containers.view"))])
 async def list_host_networks(host_id: str, current_user: dict = Depends(get_current_user)):
     """
@@ -2005,6 +2174,18 @@ async def rename_container(host_id: str, container_id: str, body: RenameContaine
         _safe_audit(current_user, log_container_action, AuditAction.RENAME, host_id, container_id, _get_container_name(host_id, container_id), request, details={'new_name': body.name})
     return {"status": "success" if success else "failed"}
 
+@app.post("/api/hosts/{host_id}/containers/{container_id}/adopt", tags=["containers"], dependencies=[Depends(require_capability("containers.operate"))])
+async def adopt_container_into_stack(host_id: str, container_id: str, body: AdoptIntoStackRequest, request: Request, current_user: dict = Depends(get_current_user), rate_limit_check: bool = rate_limit_containers):
+    """Adopt a standalone container into a compose stack (agent hosts only).
+
+    The agent recreates the container in the background; this returns once it has started.
+    """
+    container_id = normalize_container_id(container_id)
+    success = await monitor.adopt_into_stack(host_id, container_id, body.project, body.service, body.working_dir, body.config_files)
+    if success:
+        _safe_audit(current_user, log_container_action, AuditAction.UPDATE, host_id, container_id, _get_container_name(host_id, container_id), request, details={'adopted_into': body.project, 'service': body.service})
+    return {"status": "started" if success else "failed"}
+
 @app.delete("/api/hosts/{host_id}/containers/{container_id}", tags=["containers"], dependencies=[Depends(require_capability("containers.operate"))])
 async def delete_container(
     host_id: str,
@@ -2052,12 +2233,15 @@ async def get_container_logs(
     container_id: str,
     tail: int = 100,
     since: Optional[str] = None,  # ISO timestamp for getting logs since a specific time
+    timestamps: Optional[str] = None,  # utc (default), none, local or relative
+    tz: Optional[str] = None,  # IANA time zone for local timestamps
     current_user: dict = Depends(get_current_user)
     # No rate limiting - authenticated users can poll logs freely
 ):
     """Get container logs - Portainer-style polling approach
 
     Routes through agent for agent-based hosts, direct Docker for others.
+    timestamps/tz render line timestamps the same way on both paths.
 
     Security:
     - tail parameter is clamped to MAX_LOG_TAIL to prevent DoS attacks
@@ -2067,7 +2251,7 @@ async def get_container_logs(
     container_id = normalize_container_id(container_id)
 
     # Delegate to operations (handles agent routing)
-    return await monitor.operations.get_container_logs(host_id, container_id, tail, since)
+    return await monitor.operations.get_container_logs(host_id, container_id, tail, since, timestamps, tz)
 
 @app.get("/api/hosts/{host_id}/containers/{container_id}/inspect", tags=["containers"], dependencies=[Depends(require_capability("containers.view"))])
 async def inspect_container(
@@ -2520,7 +2704,10 @@ async def execute_container_update(
 
     # Execute the update (validation passed or force=True)
     executor = get_update_executor(monitor.db, monitor)
-    success = await executor.update_container(host_id, short_id, update_record, force=force)
+    requested_by, source = requester_of(current_user)
+    success = await executor.update_container(
+        host_id, short_id, update_record, force=force, requested_by=requested_by, source=source,
+    )
 
     if success:
         _safe_audit(current_user, log_container_action, AuditAction.CONTAINER_UPDATE, host_id, short_id, container_name, request, details={'previous_image': update_record.current_image, 'new_image': update_record.latest_image})
@@ -5939,6 +6126,7 @@ async def get_agent_status(
                     "capabilities": json.loads(agent.capabilities) if agent.capabilities else {},
                     "status": agent.status,
                     "connected": agent_connection_manager.is_connected(agent.id),
+                    "connection_stats": agent_connection_manager.get_connection_stats(agent.id),
                     "last_seen_at": agent.last_seen_at.isoformat() + 'Z' if agent.last_seen_at else None,
                     "registered_at": agent.registered_at.isoformat() + 'Z' if agent.registered_at else None
                 }
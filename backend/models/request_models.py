@@ -603,6 +603,38 @@ class RenameContainerRequest(BaseModel):
         return v
 
 
+class AdoptIntoStackRequest(BaseModel):
+    """Request model for adopting a standalone container into a compose stack"""
+    project: str = Field(..., min_length=1, max_length=255)
+    service: str = Field(..., min_length=1, max_length=255)
+    working_dir: Optional[str] = Field(default=None, max_length=4096)
+    config_files: Optional[str] = Field(default=None, max_length=4096)
+
+    @field_validator('project')
+    @classmethod
+    def validate_project(cls, v: str) -> str:
+        """Validate the project name is one compose accepts."""
+        v = v.strip()
+        if not re.fullmatch(r'[a-z0-9][a-z0-9_-]*', v):
+            raise ValueError(
+                'Project name must start with a lowercase letter or digit '
+                'and contain only lowercase letters, digits, dashes, or underscores'
+            )
+        return v
+
+    @field_validator('service')
+    @classmethod
+    def validate_service(cls, v: str) -> str:
+        """Validate the service name is one compose accepts."""
+        v = v.strip()
+        if not re.fullmatch(r'[a-zA-Z0-9][a-zA-Z0-9_.-]*', v):
+            raise ValueError(
+                'Service name must start with an alphanumeric character '
+                'and contain only alphanumeric characters, underscores, periods, or hyphens'
+            )
+        return v
+
+
 # Drivers supported for per-host network creation. Only bridge is offered:
 # - overlay requires Swarm mode (which DockMon does not orchestrate) and would
 #   not provide real cross-host connectivity for standalone hosts anyway.
@@ -697,3 +729,35 @@ class CreateNetworkRequest(BaseModel):
             if gw not in net:
                 raise ValueError(f'Gateway {self.gateway} is not within subnet {self.subnet}')
         return self
+
+
+class ImagePruneRequest(BaseModel):
+    """Safety rules for a host image prune.
+
+    Images used by any container, running or stopped, are always kept.
+    keep_last_tags keeps the newest N tags of each listed repository, and
+    dry_run only reports what would be removed.
+    """
+    keep_last_tags: Dict[str, int] = Field(default_factory=dict, max_length=100)
+    dry_run: bool = Field(default=False)
+
+    @field_validator('keep_last_tags')
+    @classmethod
+    def validate_keep_last_tags(cls, v: Dict[str, int]) -> Dict[str, int]:
+        """Require repository names and keep at least one tag of each."""
+        cleaned = {}
+        for repo, count in v.items():
+            repo = repo.strip()
+            if not repo or len(repo) > 255:
+                raise ValueError('Repository names must be 1-255 characters')
+            if count < 1:
+                raise ValueError(f'keep_last_tags for {repo} must be at least 1')
+            cleaned[repo] = count
+        return cleaned
+
+    def to_policy(self) -> Dict[str, Any]:
+        """The policy as the agent and compose-service expect it."""
+        policy: Dict[str, Any] = {'dry_run': self.dry_run}
+        if self.keep_last_tags:
+            policy['keep_last_tags'] = self.keep_last_tags
+        return policy
@@ -5,14 +5,36 @@ import aiohttp
 import asyncio
 import logging
 import os
-from typing import Any, Dict, Optional, Callable
+from typing import Any, Awaitable, Dict, List, NamedTuple, Optional, Callable
 import json
 
+from utils.service_compat import STATS_SERVICE, not_found_hint, record_component
+
 logger = logging.getLogger(__name__)
 
 STATS_SERVICE_URL = "http://localhost:8081"
 TOKEN_FILE_PATH = "/app/data/stats-service-token"
 
+# Agent container stats are pushed to /api/stats/ingest in batches, at most
+# this often and this many samples per host (stats-service's batch limit)
+INGEST_FLUSH_INTERVAL = 2.0
+MAX_INGEST_BATCH_SAMPLES = 1000
+
+# Fields of the agent stats wire format that /api/stats/ingest accepts
+INGEST_SAMPLE_FIELDS = (
+    "container_id", "container_name", "group", "cpu_percent",
+    "memory_usage", "memory_limit", "memory_percent", "network_rx", "network_tx",
+    "disk_read", "disk_write", "memory_raw_usage", "memory_working_set", "state",
+)
+
+
+class StatsExport(NamedTuple):
+    """A stats export file as returned by the stats-service."""
+
+    body: bytes
+    content_type: str
+    content_disposition: str
+
 
 class StatsServiceClient:
     """Client for the Go stats service"""
@@ -26,6 +48,8 @@ class StatsServiceClient:
         self.event_callback: Optional[Callable] = None
         self._token_lock = asyncio.Lock()
         self._session_lock = asyncio.Lock()  # Prevent concurrent session creation
+        self._ingest_buffer: Dict[str, List[Dict[str, Any]]] = {}
+        self._ingest_task: Optional[asyncio.Task] = None
 
     async def _load_token(self) -> str:
         """Load auth token from file (with retry for startup race condition)"""
@@ -73,6 +97,9 @@ class StatsServiceClient:
 
     async def close(self):
         """Close the HTTP session and WebSocket connection"""
+        if self._ingest_task:
+            self._ingest_task.cancel()
+
         # Close WebSocket
         if self.ws_task:
             self.ws_task.cancel()
@@ -114,35 +141,93 @@ class StatsServiceClient:
                 return False
         return False
 
-    async def add_docker_host(self, host_id: str, host_name: str, host_address: str, tls_ca: str = None, tls_cert: str = None, tls_key: str = None, num_cpus: int = None, total_memory: int = None, is_local: bool = False) -> bool:
-        """Register a Docker host with the stats service"""
-        for attempt in range(2):
-            try:
-                session = await self._get_session()
-                payload = {
-                    "host_id": host_id,
-                    "host_name": host_name,
-                    "host_address": host_address
-                }
+    async def get_full_health(self) -> Dict[str, Any]:
+        """
+        Get the stats-service's /health/full document: its own health plus
+        the compose-service's and the connected agents'.
 
-                # Add TLS certificates if provided
-                if tls_ca and tls_cert and tls_key:
-                    payload["tls_ca_cert"] = tls_ca
-                    payload["tls_cert"] = tls_cert
-                    payload["tls_key"] = tls_key
+        Raises HistoryUpstreamError on non-2xx upstream responses.
+        """
+        return await self._get_history("/health/full", {}, "full health")
 
-                # Add num_cpus for proper host CPU aggregation
-                if num_cpus and num_cpus > 0:
-                    payload["num_cpus"] = num_cpus
+    async def check_version(self) -> Optional[str]:
+        """
+        Record the stats service's version from /health and check it is new
+        enough for this backend.
 
-                # Add Docker-visible memory for proper host memory aggregation
-                if total_memory and total_memory > 0:
-                    payload["total_memory"] = total_memory
+        Returns:
+            A "please update stats-service" warning, or None when compatible
+            or unreachable
+        """
+        try:
+            session = await self._get_session()
+            async with session.get(f"{self.base_url}/health") as resp:
+                if resp.status != 200:
+                    logger.warning(f"Stats service version check failed: {resp.status}")
+                    return None
+                return record_component(STATS_SERVICE, await resp.json())
+        except Exception as e:
+            logger.warning(f"Stats service version check failed: {e}")
+            return None
 
-                # Mark as local host for /host/proc reading (Issue #129)
-                if is_local:
-                    payload["is_local"] = True
+    @staticmethod
+    def docker_host_payload(host_id: str, host_name: str, host_address: str, tls_ca: str = None, tls_cert: str = None, tls_key: str = None, num_cpus: int = None, total_memory: int = None, is_local: bool = False, auto_discover: bool = False, include_labels: List[str] = None, exclude_labels: List[str] = None) -> Dict[str, Any]:
+        """
+        Build the registration payload for a Docker host.
 
+        With auto_discover the stats service starts and stops this host's
+        container streams itself (optionally narrowed by "key" / "key=value"
+        label filters), so start_container_stream() isn't needed for it.
+        """
+        payload = {
+            "host_id": host_id,
+            "host_name": host_name,
+            "host_address": host_address
+        }
+
+        # Add TLS certificates if provided
+        if tls_ca and tls_cert and tls_key:
+            payload["tls_ca_cert"] = tls_ca
+            payload["tls_cert"] = tls_cert
+            payload["tls_key"] = tls_key
+
+        # Add num_cpus for proper host CPU aggregation
+        if num_cpus and num_cpus > 0:
+            payload["num_cpus"] = num_cpus
+
+        # Add Docker-visible memory for proper host memory aggregation
+        if total_memory and total_memory > 0:
+            payload["total_memory"] = total_memory
+
+        # Mark as local host for /host/proc reading (Issue #129)
+        if is_local:
+            payload["is_local"] = True
+
+        if auto_discover:
+            payload["auto_discover"] = True
+            if include_labels:
+                payload["include_labels"] = include_labels
+            if exclude_labels:
+                payload["exclude_labels"] = exclude_labels
+
+        return payload
+
+    async def add_docker_host(self, host_id: str, host_name: str, host_address: str, tls_ca: str = None, tls_cert: str = None, tls_key: str = None, num_cpus: int = None, total_memory: int = None, is_local: bool = False, auto_discover: bool = False, include_labels: List[str] = None, exclude_labels: List[str] = None) -> bool:
+        """
+        Register a Docker host with the stats service.
+
+        Idempotent: if the host is already registered with the same address
+        and TLS settings, its client and active streams are kept.
+        """
+        payload = self.docker_host_payload(host_id, host_name, host_address, tls_ca, tls_cert, tls_key, num_cpus, total_memory, is_local, auto_discover, include_labels, exclude_labels)
+        return await self.add_docker_host_payload(payload)
+
+    async def add_docker_host_payload(self, payload: Dict[str, Any]) -> bool:
+        """Register a Docker host from a payload built with docker_host_payload()"""
+        host_id = payload["host_id"]
+        for attempt in range(2):
+            try:
+                session = await self._get_session()
                 async with session.post(
                     f"{self.base_url}/api/hosts/add",
                     json=payload
@@ -152,7 +237,8 @@ class StatsServiceClient:
                         await self._invalidate_auth()
                         continue
                     if resp.status == 200:
-                        logger.info(f"Registered host {host_id} with stats service")
+                        data = await resp.json()
+                        logger.info(f"Registered host {host_id} with stats service ({data.get('result', 'added')})")
                         return True
                     else:
                         logger.error(f"Failed to register host {host_id}: {resp.status}")
@@ -162,6 +248,51 @@ class StatsServiceClient:
                 return False
         return False
 
+    async def bulk_add_docker_hosts(self, payloads: List[Dict[str, Any]]) -> Optional[Dict[str, str]]:
+        """
+        Register several Docker hosts in one request.
+
+        Args:
+            payloads: Host payloads built with docker_host_payload()
+
+        Returns:
+            host_id -> "created" / "reused" / "replaced" for hosts that were
+            registered (failed hosts are logged and omitted), or None if the
+            request itself failed
+        """
+        if not payloads:
+            return {}
+
+        for attempt in range(2):
+            try:
+                session = await self._get_session()
+                async with session.post(
+                    f"{self.base_url}/api/hosts/bulk_add",
+                    json={"hosts": payloads}
+                ) as resp:
+                    if resp.status == 401 and attempt == 0:
+                        logger.warning("Stats service returned 401, refreshing token...")
+                        await self._invalidate_auth()
+                        continue
+                    if resp.status != 200:
+                        hint = not_found_hint(STATS_SERVICE) if resp.status == 404 else ""
+                        logger.error(f"Failed to bulk register {len(payloads)} hosts: {resp.status}{hint}")
+                        return None
+
+                    data = await resp.json()
+                    registered = {}
+                    for entry in data.get("results") or []:
+                        host_id = entry.get("host_id", "")
+                        if entry.get("error"):
+                            logger.error(f"Failed to register host {host_id} with stats service: {entry['error']}")
+                        else:
+                            registered[host_id] = entry.get("result", "")
+                    return registered
+            except Exception as e:
+                logger.error(f"Error bulk registering hosts with stats service: {e}")
+                return None
+        return None
+
     async def remove_docker_host(self, host_id: str) -> bool:
         """Remove a Docker host from the stats service"""
         for attempt in range(2):
@@ -190,18 +321,24 @@ class StatsServiceClient:
                 return False
         return False
 
-    async def start_container_stream(self, container_id: str, container_name: str, host_id: str) -> bool:
-        """Start stats streaming for a container"""
+    async def start_container_stream(self, container_id: str, container_name: str, host_id: str, group: Optional[str] = None) -> bool:
+        """
+        Start stats streaming for a container. group is its dockmon.group
+        label, which the stats service sums usage by (/api/stats/groups).
+        """
+        payload = {
+            "container_id": container_id,
+            "container_name": container_name,
+            "host_id": host_id
+        }
+        if group:
+            payload["group"] = group
         for attempt in range(2):
             try:
                 session = await self._get_session()
                 async with session.post(
                     f"{self.base_url}/api/streams/start",
-                    json={
-                        "container_id": container_id,
-                        "container_name": container_name,
-                        "host_id": host_id
-                    }
+                    json=payload
                 ) as resp:
                     if resp.status == 401 and attempt == 0:
                         logger.warning("Stats service returned 401, refreshing token...")
@@ -319,14 +456,16 @@ class StatsServiceClient:
         endpoint: str,
         params: Dict[str, str],
         log_label: str,
-    ) -> Dict[str, Any]:
+        read_body: Optional[Callable[[aiohttp.ClientResponse], Awaitable[Any]]] = None,
+    ) -> Any:
         """
         Shared GET helper for stats-service history endpoints.
 
         Implements the standard 401-retry pattern used elsewhere in this
-        client, then either returns the decoded JSON body or raises
-        HistoryUpstreamError with the upstream status and body so the
-        FastAPI proxy can map upstream errors to appropriate responses.
+        client, then either returns the decoded JSON body (or whatever
+        read_body makes of the response) or raises HistoryUpstreamError
+        with the upstream status and body so the FastAPI proxy can map
+        upstream errors to appropriate responses.
         """
         url = f"{self.base_url}{endpoint}"
         for attempt in range(2):
@@ -339,10 +478,13 @@ class StatsServiceClient:
                         continue
                     if resp.status != 200:
                         body = await resp.text()
+                        hint = not_found_hint(STATS_SERVICE) if resp.status == 404 else ""
                         logger.warning(
-                            f"stats-service {log_label} returned {resp.status}: {body}"
+                            f"stats-service {log_label} returned {resp.status}: {body}{hint}"
                         )
                         raise StatsServiceClient.HistoryUpstreamError(resp.status, body)
+                    if read_body is not None:
+                        return await read_body(resp)
                     return await resp.json()
             except aiohttp.ClientError as e:
                 logger.error(f"Failed to get {log_label} from stats service: {e}")
@@ -414,6 +556,51 @@ class StatsServiceClient:
             "/api/stats/history/container", params, "container stats history"
         )
 
+    async def get_group_stats(self, group: Optional[str] = None) -> List[Dict[str, Any]]:
+        """
+        Proxy to stats-service GET /api/stats/groups: usage summed per
+        dockmon.group label across hosts, optionally for one group.
+
+        Raises HistoryUpstreamError on non-2xx upstream responses.
+        """
+        params = {"group": group} if group else {}
+        return await self._get_history("/api/stats/groups", params, "group stats")
+
+    async def export_stats(
+        self,
+        from_: int,
+        to: int,
+        host_id: Optional[str] = None,
+        container_id: Optional[str] = None,
+        format_: str = "csv",
+        step: Optional[str] = None,
+    ) -> "StatsExport":
+        """
+        Proxy to stats-service GET /api/stats/export for a host, or for a
+        container when container_id is given.
+
+        Returns the file as-is so CSV passes through undecoded. Raises
+        HistoryUpstreamError on non-2xx upstream responses.
+        """
+        if container_id is not None:
+            params = {"container": f"{host_id}:{container_id}"}
+        else:
+            params = {"host": host_id}
+        params.update({"from": str(from_), "to": str(to), "format": format_})
+        if step is not None:
+            params["step"] = step
+
+        async def read_export(resp: aiohttp.ClientResponse) -> StatsExport:
+            return StatsExport(
+                body=await resp.read(),
+                content_type=resp.headers.get("Content-Type", "application/octet-stream"),
+                content_disposition=resp.headers.get("Content-Disposition", ""),
+            )
+
+        return await self._get_history(
+            "/api/stats/export", params, "stats export", read_body=read_export
+        )
+
     async def push_settings_update(
         self,
         stats_persistence_enabled: Optional[bool] = None,
@@ -472,6 +659,61 @@ class StatsServiceClient:
             logger.error(f"Failed to invalidate agent token in stats service: {e}")
             raise
 
+    async def ingest_stats(self, host_id: str, samples: List[Dict[str, Any]]) -> bool:
+        """Push normalized container stats samples for a host into
+        stats-service's cache (/api/stats/ingest), so they go through the
+        same aggregation as the stats it collects itself.
+
+        Each sample uses the agent wire format: container_id, container_name,
+        cpu_percent, memory_usage, memory_limit, memory_percent, network_rx,
+        network_tx, disk_read, disk_write, and optionally group or state.
+        """
+        if not samples:
+            return True
+        payload = {"host_id": host_id, "samples": samples}
+        for attempt in range(2):
+            try:
+                session = await self._get_session()
+                async with session.post(f"{self.base_url}/api/stats/ingest", json=payload) as resp:
+                    if resp.status == 401 and attempt == 0:
+                        await self._invalidate_auth()
+                        continue
+                    if resp.status == 200:
+                        return True
+                    logger.warning(f"Stats ingest for host {host_id[:8]} rejected: {resp.status} {await resp.text()}")
+                    return False
+            except aiohttp.ClientError as e:
+                logger.error(f"Failed to push stats to stats service: {e}")
+                return False
+        return False
+
+    def queue_ingest_sample(self, host_id: str, sample: Dict[str, Any]) -> None:
+        """Buffer an agent container stats sample for ingest_stats.
+
+        Samples are flushed every INGEST_FLUSH_INTERVAL seconds, one batch
+        per host. If stats-service falls behind, a host's oldest samples are
+        dropped.
+        """
+        filtered = {k: sample[k] for k in INGEST_SAMPLE_FIELDS if k in sample}
+        if not host_id or not filtered.get("container_id"):
+            return
+        samples = self._ingest_buffer.setdefault(host_id, [])
+        if len(samples) >= MAX_INGEST_BATCH_SAMPLES:
+            samples.pop(0)
+        samples.append(filtered)
+        if self._ingest_task is None or self._ingest_task.done():
+            self._ingest_task = asyncio.create_task(self._flush_ingest_later())
+
+    async def _flush_ingest_later(self) -> None:
+        await asyncio.sleep(INGEST_FLUSH_INTERVAL)
+        await self.flush_ingest()
+
+    async def flush_ingest(self) -> None:
+        """Push all buffered samples to stats-service"""
+        buffered, self._ingest_buffer = self._ingest_buffer, {}
+        for host_id, samples in buffered.items():
+            await self.ingest_stats(host_id, samples)
+
     # Event service methods
 
     async def add_event_host(self, host_id: str, host_name: str, host_address: str, tls_ca: str = None, tls_cert: str = None, tls_key: str = None) -> bool:
@@ -0,0 +1,102 @@
+"""
+Integration tests for GET /api/health/full.
+
+The stats-service's /health/full document is faked by patching
+get_stats_client, as in the stats history proxy tests, so the focus is on
+auth, wiring and how the overall status is derived.
+"""
+
+from unittest.mock import AsyncMock, MagicMock, patch
+
+import aiohttp
+import pytest
+from fastapi.testclient import TestClient
+
+from main import app
+
+
+@pytest.fixture
+def client():
+    return TestClient(app)
+
+
+@pytest.fixture
+def mock_stats_client():
+    with patch("main.get_stats_client") as mock_get:
+        client_mock = AsyncMock()
+        mock_get.return_value = client_mock
+        yield client_mock
+
+
+@pytest.fixture
+def healthy_monitor():
+    with patch("main.monitor") as monitor:
+        monitor.event_logger = MagicMock()
+        monitor.event_logger.is_healthy.return_value = True
+        yield monitor
+
+
+def _services(status="ok"):
+    return {
+        "status": status,
+        "checked_at": "2026-01-01T00:00:00Z",
+        "stats": {"status": "ok", "service": "dockmon-stats"},
+        "compose": {"status": "ok", "docker_ok": True, "latency_ms": 1.2},
+        "agents": {"connected": 0, "stale": 0, "agents": []},
+    }
+
+
+@pytest.mark.integration
+class TestFullHealth:
+    def test_requires_authentication(self, client):
+        resp = client.get("/api/health/full")
+        assert resp.status_code == 401
+
+    def test_healthy_when_all_services_ok(
+        self, client, test_api_key_write, mock_stats_client, healthy_monitor
+    ):
+        mock_stats_client.get_full_health = AsyncMock(return_value=_services())
+
+        resp = client.get(
+            "/api/health/full",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 200, resp.text
+        body = resp.json()
+        assert body["status"] == "healthy"
+        assert body["backend"]["subsystems"] == {"event_logger": True}
+        assert body["services"]["compose"]["status"] == "ok"
+
+    def test_degraded_services_degrade_the_system(
+        self, client, test_api_key_write, mock_stats_client, healthy_monitor
+    ):
+        mock_stats_client.get_full_health = AsyncMock(
+            return_value=_services("degraded")
+        )
+
+        resp = client.get(
+            "/api/health/full",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 200, resp.text
+        assert resp.json()["status"] == "degraded"
+
+    def test_unreachable_stats_service_is_reported(
+        self, client, test_api_key_write, mock_stats_client, healthy_monitor
+    ):
+        mock_stats_client.get_full_health = AsyncMock(
+            side_effect=aiohttp.ClientConnectionError("connection refused")
+        )
+
+        resp = client.get(
+            "/api/health/full",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 200, resp.text
+        body = resp.json()
+        assert body["status"] == "unhealthy"
+        assert body["services"]["status"] == "unreachable"
+        assert "connection refused" in body["services"]["error"]
@@ -4,6 +4,8 @@ Integration tests for the stats history proxy endpoints.
 These cover:
 - /api/hosts/{host_id}/stats/history
 - /api/hosts/{host_id}/containers/{container_id}/stats/history
+- /api/hosts/{host_id}/stats/export
+- /api/hosts/{host_id}/containers/{container_id}/stats/export
 
 The endpoints forward to the Go stats-service. Tests patch
 stats_client.get_stats_client so they run without the Go service
@@ -21,7 +23,7 @@ from fastapi.testclient import TestClient
 
 from database import GlobalSettings
 from main import app
-from stats_client import StatsServiceClient
+from stats_client import StatsExport, StatsServiceClient
 
 
 @pytest.fixture
@@ -422,6 +424,96 @@ class TestStatsClientHistoryMethod:
         svc._invalidate_auth.assert_not_awaited()
 
 
+@pytest.mark.integration
+class TestStatsExportProxy:
+    """Tests for the stats export download endpoints."""
+
+    def test_requires_authentication(self, client):
+        resp = client.get("/api/hosts/host-1/stats/export?from=100&to=200")
+        assert resp.status_code == 401
+
+    def test_missing_from_to_returns_422(self, client, test_api_key_write):
+        resp = client.get(
+            "/api/hosts/host-1/stats/export",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+        assert resp.status_code == 422
+
+    def test_invalid_format_and_step_return_422(self, client, test_api_key_write):
+        for query in ("format=xml", "step=7s"):
+            resp = client.get(
+                f"/api/hosts/host-1/stats/export?from=100&to=200&{query}",
+                headers={"Authorization": f"Bearer {test_api_key_write}"},
+            )
+            assert resp.status_code == 422, query
+
+    def test_host_csv_is_passed_through(
+        self, client, test_api_key_write, mock_stats_client
+    ):
+        mock_stats_client.export_stats = AsyncMock(
+            return_value=StatsExport(
+                body=b"timestamp,time,cpu_percent\n100,1970-01-01T00:01:40Z,1.5\n",
+                content_type="text/csv; charset=utf-8",
+                content_disposition='attachment; filename="stats-host-1-100-200.csv"',
+            )
+        )
+
+        resp = client.get(
+            "/api/hosts/host-1/stats/export?from=100&to=200&step=1m",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 200, resp.text
+        assert resp.headers["content-type"].startswith("text/csv")
+        assert "stats-host-1-100-200.csv" in resp.headers["content-disposition"]
+        assert resp.text.startswith("timestamp,time,cpu_percent")
+        mock_stats_client.export_stats.assert_called_once_with(
+            host_id="host-1", from_=100, to=200, format_="csv", step="1m",
+        )
+
+    def test_container_id_is_normalized(
+        self, client, test_api_key_write, mock_stats_client
+    ):
+        mock_stats_client.export_stats = AsyncMock(
+            return_value=StatsExport(
+                body=b'{"rows": []}',
+                content_type="application/json",
+                content_disposition="",
+            )
+        )
+        long_id = "abc123abc123" + "f" * 52
+
+        resp = client.get(
+            f"/api/hosts/host-1/containers/{long_id}/stats/export"
+            "?from=100&to=200&format=json",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 200, resp.text
+        assert resp.json() == {"rows": []}
+        mock_stats_client.export_stats.assert_called_once_with(
+            host_id="host-1", container_id="abc123abc123",
+            from_=100, to=200, format_="json", step=None,
+        )
+
+    def test_upstream_400_is_mirrored_as_400(
+        self, client, test_api_key_write, mock_stats_client
+    ):
+        mock_stats_client.export_stats = AsyncMock(
+            side_effect=StatsServiceClient.HistoryUpstreamError(
+                400, "to must be > from\n"
+            )
+        )
+
+        resp = client.get(
+            "/api/hosts/host-1/stats/export?from=200&to=100",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+
+        assert resp.status_code == 400, resp.text
+        assert "to must be > from" in resp.json()["detail"]
+
+
 @pytest.fixture
 def seed_global_settings(db_session):
     """
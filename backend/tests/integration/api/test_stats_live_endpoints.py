@@ -69,6 +69,19 @@ class TestHostStatsLive:
         assert len(body["timestamps"]) == 5
         assert all(isinstance(t, float) for t in body["timestamps"])
 
+    def test_includes_agent_cpu_per_core(
+        self, client, test_api_key_write, seeded_host_buffer
+    ):
+        main.monitor.stats_history.set_cpu_per_core(seeded_host_buffer, [25.0, 75.0])
+        resp = client.get(
+            f"/api/hosts/{seeded_host_buffer}/stats/live",
+            headers={"Authorization": f"Bearer {test_api_key_write}"},
+        )
+        assert resp.status_code == 200, resp.text
+        body = resp.json()
+        assert set(body.keys()) == EXTENDED_KEYS | {"cpu_per_core"}
+        assert body["cpu_per_core"] == [25.0, 75.0]
+
     def test_unknown_host_returns_empty_arrays(self, client, test_api_key_write):
         resp = client.get(
             "/api/hosts/does-not-exist/stats/live",
@@ -570,6 +570,27 @@ class TestContainerIdNormalization:
                 assert len(dc_call[1]["container_id"]) == 12
                 assert dc_call[1]["container_id"] == "abc123def456"
 
+    @pytest.mark.asyncio
+    async def test_link_containers_links_every_replica(self, executor):
+        """Should link all replicas listed in container_ids"""
+        deployment_id = "host-123:deploy-456"
+        services = {
+            "worker": {
+                "container_id": "bbbbbbbbbbbb",
+                "container_ids": ["aaaaaaaaaaaa", "bbbbbbbbbbbb"],
+                "container_name": "test_worker_2",
+                "status": "running",
+            },
+        }
+
+        with patch('deployment.agent_executor.DeploymentContainer') as mock_dc:
+            with patch('deployment.agent_executor.DeploymentMetadata') as mock_dm:
+                await executor._link_containers_to_deployment(deployment_id, services)
+
+                linked = [c[1]["container_id"] for c in mock_dc.call_args_list]
+                assert linked == ["aaaaaaaaaaaa", "bbbbbbbbbbbb"]
+                assert mock_dm.call_count == 2
+
 
 class TestPartialFailureHandling:
     """Test handling of partial deployment failures"""
@@ -995,3 +1016,22 @@ class TestFineGrainedProgress:
             # Progress should be 80 for waiting_for_health
             progress = call_args.kwargs.get("progress") or call_args[1].get("progress")
             assert progress == 80
+
+    @pytest.mark.asyncio
+    async def test_schema_percent_replaces_stage_estimate(self, executor):
+        """Should use the percent reported in the shared progress schema"""
+        payload = {
+            "schema": 1,
+            "operation": "deploy",
+            "deployment_id": "deploy-123",
+            "stage": "pulling_image",
+            "message": "Pulling web (2/3)",
+            "percent": 47,
+        }
+
+        with patch.object(executor, '_update_deployment_status', new_callable=AsyncMock) as mock_update:
+            await executor.handle_deploy_progress(payload)
+
+            call_args = mock_update.call_args
+            progress = call_args.kwargs.get("progress") or call_args[1].get("progress")
+            assert progress == 47
@@ -13,6 +13,7 @@ from deployment.container_utils import (
     get_container_compose_service,
     scan_deployed_stacks,
     get_deployed_hosts_for_stack,
+    get_service_container_ids,
 )
 
 
@@ -633,3 +634,24 @@ class TestGetDeployedHostsForStack:
         # Different case should not match
         result_wrong = get_deployed_hosts_for_stack(containers, "NGINX")
         assert len(result_wrong) == 0
+
+
+class TestGetServiceContainerIds:
+    """Tests for get_service_container_ids function."""
+
+    def test_all_replicas(self):
+        """Should return every replica of a scaled service."""
+        result = {
+            "container_id": "bbbbbbbbbbbb",
+            "container_ids": ["aaaaaaaaaaaa", "bbbbbbbbbbbb"],
+        }
+        assert get_service_container_ids(result) == ["aaaaaaaaaaaa", "bbbbbbbbbbbb"]
+
+    def test_legacy_result(self):
+        """Should fall back to container_id, shortened to 12 chars."""
+        result = {"container_id": "abc123def456789"}
+        assert get_service_container_ids(result) == ["abc123def456"]
+
+    def test_no_container(self):
+        """Should return nothing when the service has no container."""
+        assert get_service_container_ids({"status": "failed"}) == []
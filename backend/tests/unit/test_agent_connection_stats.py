@@ -0,0 +1,88 @@
+"""
+Unit tests for agent connection stats.
+
+The agent reports reconnects, the last disconnect reason, ping latency,
+traffic and queue depths in its heartbeat. The handler stores them for the
+agent status endpoint and logs when a new reconnect shows up.
+"""
+
+import logging
+
+import pytest
+from unittest.mock import MagicMock, patch
+
+from agent.connection_manager import AgentConnectionManager
+from agent.websocket_handler import AgentWebSocketHandler
+
+
+@pytest.fixture
+def handler():
+    """Handler with just the fields connection stats handling touches"""
+    h = AgentWebSocketHandler.__new__(AgentWebSocketHandler)
+    h.agent_id = "agent-1"
+    h.agent_hostname = "web-01"
+    return h
+
+
+STATS = {
+    "connects": 2,
+    "reconnects": 1,
+    "failed_attempts": 3,
+    "last_disconnect_reason": "read error: i/o timeout",
+    "ping_rtt_ms": 12.5,
+    "bytes_sent": 1000,
+    "bytes_received": 400,
+    "outbound_queued": 0,
+    "pending_commands": 1,
+}
+
+
+class TestConnectionStats:
+    """Test storing and logging agent connection stats"""
+
+    def test_stats_are_stored(self, handler):
+        """Stats from the heartbeat are kept for the status endpoint"""
+        manager = MagicMock()
+        manager.get_connection_stats.return_value = None
+        with patch("agent.websocket_handler.agent_connection_manager", manager):
+            handler._record_connection_stats(STATS)
+            handler._record_connection_stats("not a dict")
+
+        manager.record_connection_stats.assert_called_once_with("agent-1", STATS)
+
+    def test_new_reconnect_is_logged(self, handler, caplog):
+        """A reconnect count above the last report logs the disconnect reason"""
+        manager = MagicMock()
+        manager.get_connection_stats.return_value = {**STATS, "reconnects": 0}
+        with patch("agent.websocket_handler.agent_connection_manager", manager), \
+                caplog.at_level(logging.WARNING):
+            handler._record_connection_stats(STATS)
+
+        assert "read error: i/o timeout" in caplog.text
+
+    def test_unchanged_reconnects_not_logged(self, handler, caplog):
+        """Later heartbeats on the same connection don't log again"""
+        manager = MagicMock()
+        manager.get_connection_stats.return_value = STATS
+        with patch("agent.websocket_handler.agent_connection_manager", manager), \
+                caplog.at_level(logging.WARNING):
+            handler._record_connection_stats(STATS)
+
+        assert "reconnected" not in caplog.text
+
+
+class TestForgetConnectionStats:
+    """Test dropping connection stats when a host is removed"""
+
+    def test_forget_drops_only_that_agent(self):
+        """Removing a host forgets its agent's stats, not other agents'"""
+        manager = object.__new__(AgentConnectionManager)
+        manager.connection_stats = {}
+        manager.record_connection_stats("agent-1", STATS)
+        manager.record_connection_stats("agent-2", STATS)
+
+        manager.forget_connection_stats("agent-1")
+        manager.forget_connection_stats("unknown")
+
+        assert manager.get_connection_stats("agent-1") is None
+        assert manager.get_connection_stats("agent-2")["reconnects"] == 1
@@ -0,0 +1,87 @@
+"""
+Unit tests for the agent container inventory (container_inventory.py).
+
+Discovery subscribes to an agent's container list once and then applies
+pushed deltas; these tests cover snapshot/delta application and the
+resubscribe triggers (revision gap, reconnect, snapshot age).
+"""
+
+from unittest.mock import patch
+
+from agent import container_inventory
+from agent.container_inventory import ContainerInventory
+
+
+AGENT_ID = "agent-12345678"
+
+
+def _container(container_id, state="running"):
+    return {"Id": container_id, "Names": [f"/{container_id}"], "State": state}
+
+
+class TestContainerInventory:
+    """Test snapshot and delta handling"""
+
+    def test_unsubscribed_agent_has_no_inventory(self):
+        """Should return None so discovery requests a snapshot"""
+        inventory = ContainerInventory()
+
+        assert inventory.get(AGENT_ID) is None
+
+    def test_snapshot_then_delta(self):
+        """Should apply added/updated/removed on top of the snapshot"""
+        inventory = ContainerInventory()
+        inventory.apply_snapshot(AGENT_ID, 1, [_container("aaa"), _container("bbb")])
+
+        applied = inventory.apply_delta(AGENT_ID, {
+            "revision": 2,
+            "base_revision": 1,
+            "added": [_container("ccc")],
+            "updated": [_container("aaa", state="exited")],
+            "removed": ["bbb"],
+        })
+
+        assert applied is True
+        by_id = {c["Id"]: c for c in inventory.get(AGENT_ID)}
+        assert set(by_id) == {"aaa", "ccc"}
+        assert by_id["aaa"]["State"] == "exited"
+
+    def test_revision_gap_drops_inventory(self):
+        """Should drop the cache when a delta was missed"""
+        inventory = ContainerInventory()
+        inventory.apply_snapshot(AGENT_ID, 1, [_container("aaa")])
+
+        applied = inventory.apply_delta(AGENT_ID, {
+            "revision": 4,
+            "base_revision": 3,
+            "added": [_container("bbb")],
+        })
+
+        assert applied is False
+        assert inventory.get(AGENT_ID) is None
+
+    def test_delta_without_subscription_is_ignored(self):
+        """Should not create an inventory from a delta alone"""
+        inventory = ContainerInventory()
+
+        assert inventory.apply_delta(AGENT_ID, {"revision": 2, "base_revision": 1}) is False
+        assert inventory.get(AGENT_ID) is None
+
+    def test_drop_forgets_agent(self):
+        """Should forget the inventory when the agent reconnects"""
+        inventory = ContainerInventory()
+        inventory.apply_snapshot(AGENT_ID, 1, [_container("aaa")])
+
+        inventory.drop(AGENT_ID)
+
+        assert inventory.get(AGENT_ID) is None
+
+    def test_old_snapshot_expires(self):
+        """Should force a periodic resubscribe as a safety net"""
+        inventory = ContainerInventory()
+        with patch.object(container_inventory.time, "monotonic", return_value=1000.0):
+            inventory.apply_snapshot(AGENT_ID, 1, [_container("aaa")])
+
+        expired = 1000.0 + container_inventory.MAX_SNAPSHOT_AGE_SECONDS + 1
+        with patch.object(container_inventory.time, "monotonic", return_value=expired):
+            assert inventory.get(AGENT_ID) is None
@@ -289,6 +289,44 @@ class TestRemoveContainer:
             assert exc_info.value.status_code == 403
 
 
+class TestAdoptIntoStack:
+    """Test adopting a standalone container into a compose stack via agent"""
+
+    @pytest.mark.asyncio
+    async def test_adopt_sends_command(self, container_ops, mock_command_executor):
+        """Should send the adopt_into_stack command with the stack fields"""
+        mock_command_executor.execute_command.return_value = CommandResult(
+            status=CommandStatus.SUCCESS,
+            success=True,
+            response={"status": "adopt_into_stack_started"},
+            error=None
+        )
+
+        result = await container_ops.adopt_into_stack(
+            "host-123", "abc123", "web", "app", working_dir="/srv/web"
+        )
+
+        assert result is True
+        command = mock_command_executor.execute_command.call_args[0][1]
+        assert command["type"] == "command"
+        assert command["command"] == "adopt_into_stack"
+        assert command["payload"] == {
+            "container_id": "abc123",
+            "project": "web",
+            "service": "app",
+            "working_dir": "/srv/web",
+        }
+
+    @pytest.mark.asyncio
+    async def test_adopt_safety_check_dockmon(self, container_ops):
+        """Should prevent recreating DockMon itself"""
+        with patch.object(container_ops, '_is_dockmon_container', return_value=True):
+            with pytest.raises(HTTPException) as exc_info:
+                await container_ops.adopt_into_stack("host-123", "dockmon-container", "web", "app")
+
+            assert exc_info.value.status_code == 403
+
+
 class TestGetContainerLogs:
     """Test container log retrieval via agent"""
 
@@ -331,6 +369,26 @@ class TestGetContainerLogs:
 
         command = mock_command_executor.execute_command.call_args[0][1]
         assert command["payload"]["tail"] == 500
+        assert "timestamps" not in command["payload"]
+        assert "tz" not in command["payload"]
+
+    @pytest.mark.asyncio
+    async def test_get_logs_passes_timestamp_options(self, container_ops, mock_command_executor):
+        """Should forward the timestamps mode and time zone to the agent"""
+        mock_command_executor.execute_command.return_value = CommandResult(
+            status=CommandStatus.SUCCESS,
+            success=True,
+            response={"logs": ""},
+            error=None
+        )
+
+        await container_ops.get_container_logs(
+            "host-123", "abc123", tail=50, timestamps="local", tz="Europe/Berlin"
+        )
+
+        payload = mock_command_executor.execute_command.call_args[0][1]["payload"]
+        assert payload["timestamps"] == "local"
+        assert payload["tz"] == "Europe/Berlin"
 
 
 class TestInspectContainer:
@@ -0,0 +1,46 @@
+"""
+Unit tests for agent protocol negotiation.
+
+Agents offer optional features at registration; the backend accepts those
+whose events it handles and agrees on the lower protocol version.
+"""
+
+from agent.websocket_handler import PROTO_VERSION, negotiate_protocol
+
+
+class TestNegotiateProtocol:
+    """Test version and feature negotiation"""
+
+    def test_accepts_supported_features_only(self):
+        """Should keep features the backend handles and drop the rest"""
+        version, features = negotiate_protocol(
+            "1.2", ["volume_backup", "resource_events", "inventory_deltas", "diagnostics"]
+        )
+        assert version == "1.2"
+        assert features == ["inventory_deltas", "resource_events"]
+
+    def test_accepts_progress_schema(self):
+        """Should accept shared-schema progress events"""
+        _, features = negotiate_protocol("1.2", ["progress_schema", "diagnostics"])
+        assert features == ["progress_schema"]
+
+    def test_legacy_agent(self):
+        """Agents without negotiation get their own version and no features"""
+        version, features = negotiate_protocol("1.1", None)
+        assert version == "1.1"
+        assert features == []
+
+    def test_newer_agent_gets_backend_version(self):
+        """Should not agree on a version the backend doesn't speak"""
+        version, _ = negotiate_protocol("1.10", [])
+        assert version == PROTO_VERSION
+
+    def test_missing_version(self):
+        """Should fall back to the backend version when the agent sends none"""
+        version, _ = negotiate_protocol(None, ["resource_events"])
+        assert version == PROTO_VERSION
+
+    def test_ignores_malformed_features(self):
+        """Should ignore non-string and non-list feature offers"""
+        assert negotiate_protocol("1.2", "resource_events")[1] == []
+        assert negotiate_protocol("1.2", [{"x": 1}, "resource_events"])[1] == ["resource_events"]
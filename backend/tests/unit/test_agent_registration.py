@@ -13,7 +13,7 @@ from unittest.mock import patch, MagicMock
 from sqlalchemy import create_engine
 from sqlalchemy.orm import sessionmaker
 
-from agent.manager import AgentManager
+from agent.manager import AgentManager, issue_identity_proof
 from database import Base, RegistrationToken, Agent, DockerHostDB
 
 
@@ -514,3 +514,141 @@ class TestForceUniqueRegistration:
             assert result["success"] is False
             assert "FORCE_UNIQUE_REGISTRATION" in result["error"]
             assert "/var/lib/docker/engine-id" in result["error"]
+
+
+class TestPreviousIdentity:
+    """Tests for restoring an agent's IDs from previous_identity on re-registration."""
+
+    @pytest.fixture(autouse=True)
+    def encryption_key(self, tmp_path):
+        """Identity proofs are signed with a throwaway encryption key"""
+        with patch("utils.encryption.KEY_PATH", str(tmp_path / "encryption.key")):
+            yield
+
+    @staticmethod
+    def _proven(engine_id, agent_id=None, host_id=None):
+        """previous_identity with the proof the backend issues at registration"""
+        previous = {"agent_id": agent_id, "host_id": host_id}
+        previous["proof"] = issue_identity_proof(agent_id, host_id, engine_id)
+        return {k: v for k, v in previous.items() if v is not None}
+
+    def _register(self, manager, engine_id, previous=None, hostname=None):
+        token = manager.generate_registration_token(user_id=1)
+        data = {
+            "token": token.token,
+            "engine_id": engine_id,
+            "version": "2.2.0",
+            "proto_version": "1.0",
+            "capabilities": {},
+        }
+        if hostname:
+            data["hostname"] = hostname
+        if previous:
+            data["previous_identity"] = previous
+        return manager.register_agent(data)
+
+    def test_unknown_ids_are_reused(self, db_session, mock_db_manager):
+        """IDs the backend doesn't know (e.g. restored from an older backup) are kept."""
+        previous = self._proven(
+            "engine-restore",
+            agent_id="11111111-1111-1111-1111-111111111111",
+            host_id="22222222-2222-2222-2222-222222222222",
+        )
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            result = self._register(AgentManager(), "engine-restore", previous)
+
+        assert result["success"], result
+        assert result["agent_id"] == previous["agent_id"]
+        assert result["host_id"] == previous["host_id"]
+        assert "identity_reassigned" not in result
+        assert db_session.query(DockerHostDB).filter_by(id=previous["host_id"]).first() is not None
+
+    def test_orphaned_host_is_reattached(self, db_session, mock_db_manager):
+        """A host that lost its agent row is taken over, keeping its name."""
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            manager = AgentManager()
+            first = self._register(manager, "engine-orphan", hostname="nas")
+            db_session.delete(db_session.query(Agent).filter_by(id=first["agent_id"]).first())
+            db_session.commit()
+
+            result = self._register(
+                manager, "engine-orphan",
+                {"agent_id": first["agent_id"], "host_id": first["host_id"], "proof": first["identity_proof"]},
+                hostname="renamed",
+            )
+
+        assert result["success"], result
+        assert result["host_id"] == first["host_id"]
+        assert result["agent_id"] == first["agent_id"]
+        host = db_session.query(DockerHostDB).filter_by(id=first["host_id"]).first()
+        assert host.name == "nas"
+        assert host.agent.id == first["agent_id"]
+
+    def test_host_id_in_use_is_reassigned(self, db_session, mock_db_manager):
+        """A previous host ID owned by another host gets a new ID and is reported."""
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            manager = AgentManager()
+            other = self._register(manager, "engine-other", hostname="other")
+            result = self._register(
+                manager, "engine-mine",
+                self._proven("engine-mine", host_id=other["host_id"]),
+                hostname="mine",
+            )
+
+        assert result["success"], result
+        assert result["host_id"] != other["host_id"]
+        assert result["identity_reassigned"]["previous_host_id"] == other["host_id"]
+        assert "host ID" in result["identity_reassigned"]["reason"]
+
+    @pytest.mark.parametrize("previous", [
+        # No proof at all
+        {"agent_id": "11111111-1111-1111-1111-111111111111", "host_id": "22222222-2222-2222-2222-222222222222"},
+        # A garbage proof
+        {"host_id": "22222222-2222-2222-2222-222222222222", "proof": "bm90LWEtcHJvb2Y="},
+    ])
+    def test_unproven_ids_are_not_reused(self, db_session, mock_db_manager, previous):
+        """IDs without a valid proof from this backend get replaced and reported."""
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            result = self._register(AgentManager(), "engine-claim", previous)
+
+        assert result["success"], result
+        assert result["host_id"] != previous["host_id"]
+        assert result["agent_id"] != previous.get("agent_id")
+        assert result["identity_reassigned"]["reason"] == "previous identity could not be verified"
+
+    def test_proof_is_bound_to_engine_and_ids(self, db_session, mock_db_manager):
+        """A proof issued to one engine can't vouch for another, or for other IDs."""
+        host_id = "22222222-2222-2222-2222-222222222222"
+        other_engine = self._proven("engine-a", host_id=host_id)
+        swapped = {**self._proven("engine-b", host_id=host_id), "host_id": "33333333-3333-3333-3333-333333333333"}
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            manager = AgentManager()
+            stolen = self._register(manager, "engine-b", other_engine)
+            tampered = self._register(manager, "engine-c", swapped)
+
+        assert stolen["host_id"] != host_id
+        assert "identity_reassigned" in stolen
+        assert tampered["host_id"] != swapped["host_id"]
+        assert "identity_reassigned" in tampered
+
+    def test_registration_returns_identity_proof(self, db_session, mock_db_manager):
+        """A registration hands out a proof for the assigned IDs."""
+        from agent.manager import verify_identity_proof
+
+        with patch.object(AgentManager, '__init__', create_mock_init(mock_db_manager)):
+            result = self._register(AgentManager(), "engine-new")
+
+        assert result["success"], result
+        previous = {"agent_id": result["agent_id"], "host_id": result["host_id"], "proof": result["identity_proof"]}
+        assert verify_identity_proof(previous, "engine-new")
+        assert not verify_identity_proof(previous, "engine-other")
+
+    def test_previous_identity_requires_uuids(self):
+        """previous_identity only accepts UUIDs."""
+        from pydantic import ValidationError
+        from agent.models import AgentPreviousIdentity
+
+        AgentPreviousIdentity(agent_id="11111111-1111-1111-1111-111111111111")
+        AgentPreviousIdentity(agent_id="11111111-1111-1111-1111-111111111111", proof="gAAAAABk-_abc=")
+        with pytest.raises(ValidationError):
+            AgentPreviousIdentity(host_id="../../etc/passwd")
@@ -0,0 +1,133 @@
+"""
+Unit tests for chunked agent command responses.
+
+Agents split large responses into response_chunk messages under the
+command's id; the backend joins their data in seq order and routes the
+rebuilt response like any other, failing the command when a chunk is lost
+or the response grows past the size limit.
+"""
+
+import json
+from unittest.mock import MagicMock, patch
+
+import pytest
+
+from agent import response_chunks
+from agent.response_chunks import ResponseReassembler
+from agent.websocket_handler import AgentWebSocketHandler, negotiate_protocol
+
+
+def chunk_messages(response: dict, size: int) -> list:
+    """Split a response like the agent's protocol.ChunkResponse"""
+    data = json.dumps(response)
+    parts = [data[i:i + size] for i in range(0, len(data), size)]
+    messages = [
+        {"type": "response_chunk", "id": response["id"], "payload": {"seq": seq, "data": part}}
+        for seq, part in enumerate(parts)
+    ]
+    messages[-1]["payload"].update(final=True, chunks=len(parts), size=len(data.encode("utf-8")))
+    return messages
+
+
+RESPONSE = {"type": "response", "id": "cmd-1", "payload": [{"id": f"c{i}", "name": f"web-{i}"} for i in range(50)]}
+
+
+class TestResponseReassembler:
+    """Test rebuilding responses from their chunks"""
+
+    def test_reassembles_response(self):
+        """Should return the response once the final chunk arrives"""
+        r = ResponseReassembler()
+        messages = chunk_messages(RESPONSE, 100)
+        assert len(messages) > 2
+        for message in messages[:-1]:
+            assert r.add(message) is None
+        assert r.add(messages[-1]) == RESPONSE
+        assert r.pending_bytes == 0
+
+    def test_interleaved_responses(self):
+        """Should keep concurrent responses apart by id"""
+        other = dict(RESPONSE, id="cmd-2")
+        r = ResponseReassembler()
+        first, second = chunk_messages(RESPONSE, 200), chunk_messages(other, 200)
+        results = []
+        for a, b in zip(first, second):
+            results += [r.add(a), r.add(b)]
+        assert [x for x in results if x is not None] == [RESPONSE, other]
+
+    def test_lost_chunk_fails_command(self):
+        """Should answer with an error at the gap and ignore the rest"""
+        r = ResponseReassembler()
+        messages = chunk_messages(RESPONSE, 100)
+        r.add(messages[0])
+        error = r.add(messages[2])
+        assert error["id"] == "cmd-1"
+        assert error["error_type"] == "invalid_response"
+        assert "lost" in error["error"]
+        for message in messages[3:]:
+            assert r.add(message) is None
+        assert r.pending_bytes == 0
+
+    def test_response_size_limit(self):
+        """Should stop buffering a response past the size limit"""
+        r = ResponseReassembler(max_response_bytes=250)
+        results = [r.add(m) for m in chunk_messages(RESPONSE, 100)]
+        errors = [x for x in results if x is not None]
+        assert len(errors) == 1
+        assert "250 byte limit" in errors[0]["error"]
+        assert r.pending_bytes == 0
+
+    def test_pending_bytes_limit(self):
+        """Should cap the data buffered across a connection's responses"""
+        r = ResponseReassembler(max_pending_bytes=150)
+        r.add(chunk_messages(RESPONSE, 100)[0])
+        error = r.add(chunk_messages(dict(RESPONSE, id="cmd-2"), 100)[0])
+        assert error["id"] == "cmd-2"
+        assert "buffered" in error["error"]
+
+    def test_final_chunk_totals_checked(self):
+        """Should reject a sequence whose totals don't match the final chunk"""
+        r = ResponseReassembler()
+        messages = chunk_messages(RESPONSE, 100)
+        messages[-1]["payload"]["size"] += 1
+        results = [r.add(m) for m in messages]
+        assert "incomplete" in results[-1]["error"]
+
+    def test_stale_sequences_expire(self):
+        """Should drop a sequence that stopped receiving chunks"""
+        r = ResponseReassembler()
+        messages = chunk_messages(RESPONSE, 100)
+        with patch.object(response_chunks.time, "monotonic", return_value=1000.0):
+            r.add(messages[0])
+        assert r.pending_bytes > 0
+        later = 1000.0 + response_chunks.STALE_AFTER_SECONDS + 1
+        with patch.object(response_chunks.time, "monotonic", return_value=later):
+            r.add(chunk_messages(dict(RESPONSE, id="cmd-2"), 10_000)[0])
+        assert r.pending_bytes == 0
+
+    def test_malformed_chunk_ignored(self):
+        """Should ignore chunks without an id or payload"""
+        r = ResponseReassembler()
+        assert r.add({"type": "response_chunk", "payload": {"seq": 0, "data": "{}"}}) is None
+        assert r.add({"type": "response_chunk", "id": "cmd-1"}) is None
+
+
+class TestChunkRouting:
+    """Test the handler routing rebuilt responses to the command executor"""
+
+    @pytest.mark.asyncio
+    async def test_routes_rebuilt_response(self):
+        handler = AgentWebSocketHandler.__new__(AgentWebSocketHandler)
+        handler.agent_id = "agent-1"
+        handler.response_chunks = ResponseReassembler()
+        executor = MagicMock()
+        with patch("agent.websocket_handler.get_agent_command_executor", return_value=executor):
+            for message in chunk_messages(RESPONSE, 100):
+                await handler.handle_agent_message(message)
+        executor.handle_agent_response.assert_called_once()
+        routed = executor.handle_agent_response.call_args[0][0]
+        assert routed["correlation_id"] == "cmd-1"
+        assert routed["payload"] == RESPONSE["payload"]
+
+    def test_feature_negotiated(self):
+        assert "response_chunks" in negotiate_protocol("1.2", ["response_chunks"])[1]
@@ -633,6 +633,46 @@ class TestAgentPlatformDetection:
 
         assert "darwin-arm64" in binary_url
 
+    @pytest.mark.asyncio
+    async def test_handles_windows_amd64_platform(
+        self, agent_executor, agent_update_record, agent_context, mock_command_executor, mock_db
+    ):
+        """Should request the .exe asset for Windows service agents"""
+        mock_agent = MagicMock()
+        mock_agent.agent_os = "windows"
+        mock_agent.agent_arch = "amd64"
+
+        mock_session = MagicMock()
+        mock_session.query.return_value.filter_by.return_value.first.return_value = mock_agent
+        mock_db.get_session.return_value.__enter__ = MagicMock(return_value=mock_session)
+        mock_db.get_session.return_value.__exit__ = MagicMock(return_value=False)
+
+        mock_command_executor.execute_command.return_value = CommandResult(
+            status=CommandStatus.SUCCESS,
+            success=True,
+            response={},
+            error=None
+        )
+
+        agent_executor._wait_for_agent_reconnection = AsyncMock(return_value=True)
+        agent_executor._get_agent_version = AsyncMock(return_value="2.2.1")
+
+        async def progress_callback(stage, percent, message):
+            pass
+
+        await agent_executor.execute_self_update(
+            context=agent_context,
+            progress_callback=progress_callback,
+            update_record=agent_update_record,
+            agent_id="agent-456",
+        )
+
+        call_args = mock_command_executor.execute_command.call_args
+        command = call_args[0][1]
+        binary_url = command["payload"]["binary_url"]
+
+        assert binary_url.endswith("dockmon-agent-windows-amd64.exe")
+
     @pytest.mark.asyncio
     async def test_handles_linux_arm_platform(
         self, agent_executor, agent_update_record, agent_context, mock_command_executor, mock_db
@@ -0,0 +1,60 @@
+"""
+Unit tests for agent stats of paused containers.
+
+The agent stops a paused container's stats stream and sends a container_stats
+payload with state "paused" (no metrics), then "running" when it's unpaused.
+"""
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+
+from agent.websocket_handler import AgentWebSocketHandler
+
+
+@pytest.fixture
+def handler():
+    """Handler with just the state stats handling touches"""
+    h = AgentWebSocketHandler.__new__(AgentWebSocketHandler)
+    h.agent_id = "agent-1"
+    h.host_id = "host-1"
+    h.prev_network_stats = {}
+    h.monitor = MagicMock()
+    h.monitor.agent_container_stats_cache = {}
+    h.monitor.manager.broadcast = AsyncMock()
+    return h
+
+
+SAMPLE = {"container_id": "abcdef012345", "cpu_percent": 37.5, "memory_usage": 1024, "network_rx": 10, "network_tx": 20}
+KEY = "host-1:abcdef012345"
+
+
+class TestPausedContainerStats:
+    """Test freezing and unfreezing stats around a pause"""
+
+    @pytest.mark.asyncio
+    async def test_pause_freezes_last_sample(self, handler):
+        """The paused entry keeps memory, zeroes CPU and is flagged"""
+        await handler._handle_container_stats(SAMPLE)
+        await handler._handle_container_stats({"container_id": "abcdef012345", "state": "paused"})
+
+        stats = handler.monitor.agent_container_stats_cache[KEY]
+        assert stats["state"] == "paused"
+        assert stats["cpu_percent"] == 0.0
+        assert stats["memory_usage"] == 1024
+        assert handler.monitor.manager.broadcast.await_args[0][0]["stats"]["state"] == "paused"
+
+    @pytest.mark.asyncio
+    async def test_samples_ignored_until_running(self, handler):
+        """A late sample doesn't unfreeze the entry; the running marker does"""
+        handler.monitor.container_stats_history = MagicMock()
+        await handler._handle_container_stats(SAMPLE)
+        await handler._handle_container_stats({"container_id": "abcdef012345", "state": "paused"})
+        await handler._handle_container_stats({**SAMPLE, "cpu_percent": 12.0})
+
+        assert handler.monitor.agent_container_stats_cache[KEY]["state"] == "paused"
+        assert handler.monitor.container_stats_history.add_stats.call_count == 1
+
+        await handler._handle_container_stats({"container_id": "abcdef012345", "state": "running"})
+        assert "state" not in handler.monitor.agent_container_stats_cache[KEY]
+        await handler._handle_container_stats({**SAMPLE, "cpu_percent": 12.0})
+        assert handler.monitor.agent_container_stats_cache[KEY]["cpu_percent"] == 12.0
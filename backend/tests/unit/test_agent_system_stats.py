@@ -0,0 +1,60 @@
+"""
+Unit tests for agent host stats (system mode).
+
+A native agent sends host CPU/memory/network from /proc, optionally with a
+per-core CPU breakdown that the backend keeps for the UI.
+"""
+
+import pytest
+from unittest.mock import MagicMock
+
+from agent.websocket_handler import AgentWebSocketHandler
+from docker_monitor.stats_history import StatsHistoryBuffer
+
+
+@pytest.fixture
+def handler():
+    """Handler with a real stats history buffer"""
+    h = AgentWebSocketHandler.__new__(AgentWebSocketHandler)
+    h.agent_id = "agent-1"
+    h.host_id = "host-1"
+    h.monitor = MagicMock()
+    h.monitor.stats_history = StatsHistoryBuffer()
+    return h
+
+
+def _message(**extra):
+    return {"type": "stats", "stats": {"cpu_percent": 40.0, "mem_percent": 20.0, "net_bytes_per_sec": 5.0, **extra}}
+
+
+class TestSystemStats:
+    """Test storing agent host stats"""
+
+    @pytest.mark.asyncio
+    async def test_stores_cpu_per_core(self, handler):
+        """The per-core breakdown is kept for the broadcast and live API"""
+        await handler._handle_system_stats(_message(cpu_per_core=[12.5, 67.5]))
+
+        assert handler.monitor.stats_history.get_sparklines("host-1")["cpu"] == [40.0]
+        assert handler.monitor.stats_history.get_cpu_per_core("host-1") == [12.5, 67.5]
+
+    @pytest.mark.asyncio
+    async def test_missing_per_core_clears_previous(self, handler):
+        """An agent that stops sending the breakdown doesn't leave a stale one"""
+        await handler._handle_system_stats(_message(cpu_per_core=[12.5, 67.5]))
+        await handler._handle_system_stats(_message())
+
+        assert handler.monitor.stats_history.get_cpu_per_core("host-1") is None
+
+    @pytest.mark.parametrize("value, expected", [
+        ([0, 50, 100], [0.0, 50.0, 100.0]),
+        ([-5.0, 250.0], [0.0, 100.0]),
+        ([], None),
+        ("12,34", None),
+        ([1.0, "x"], None),
+        ([True], None),
+        ([1.0] * 2000, None),
+    ])
+    def test_parse_cpu_per_core(self, value, expected):
+        """Only a bounded list of numbers is accepted, values clamped to 0-100"""
+        assert AgentWebSocketHandler._parse_cpu_per_core(value) == expected
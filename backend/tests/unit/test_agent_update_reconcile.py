@@ -0,0 +1,152 @@
+"""
+Unit tests for reconciling agent update states after a reconnect.
+
+The agent persists the latest state of each container update; after
+authentication the backend fetches it with get_update_status so updates that
+progressed or finished while disconnected don't look stuck.
+"""
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock, patch
+
+from agent.command_executor import CommandResult, CommandStatus
+from agent.websocket_handler import AgentWebSocketHandler
+
+
+@pytest.fixture
+def handler():
+    """Handler with just the state the reconciliation touches"""
+    h = AgentWebSocketHandler.__new__(AgentWebSocketHandler)
+    h.agent_id = "agent-1"
+    h.host_id = "host-1"
+    h.monitor = MagicMock()
+    h.monitor.manager.broadcast = AsyncMock()
+    return h
+
+
+def _result(updates):
+    return CommandResult(
+        status=CommandStatus.SUCCESS,
+        success=True,
+        response={"updates": updates},
+        error=None,
+    )
+
+
+async def _reconcile(handler, result, signaled=True):
+    executor = MagicMock()
+    executor.execute_command = AsyncMock(return_value=result)
+    registry = MagicMock()
+    registry.signal_complete = AsyncMock(return_value=signaled)
+    with patch("agent.websocket_handler.get_agent_command_executor", return_value=executor), \
+         patch("updates.pending_updates.get_pending_updates_registry", return_value=registry):
+        await handler._reconcile_update_status()
+    return executor, registry
+
+
+class TestReconcileUpdateStatus:
+    """Test replaying persisted update states"""
+
+    @pytest.mark.asyncio
+    async def test_requests_recent_states(self, handler):
+        """Should ask the agent for states changed within the window"""
+        executor, _ = await _reconcile(handler, _result([]))
+        command = executor.execute_command.call_args[0][1]
+        assert command["command"] == "get_update_status"
+        assert "since" in command["payload"]
+
+    @pytest.mark.asyncio
+    async def test_completed_signals_waiting_executor(self, handler):
+        """A completed update unblocks the waiting executor"""
+        _, registry = await _reconcile(handler, _result([{
+            "container_id": "abcdef0123456789",
+            "status": "completed",
+            "new_container_id": "fedcba987654",
+        }]))
+        registry.signal_complete.assert_awaited_once_with(
+            host_id="host-1",
+            old_container_id="abcdef012345",
+            new_container_id="fedcba987654",
+            success=True,
+        )
+        handler.monitor.manager.broadcast.assert_not_awaited()
+
+    @pytest.mark.asyncio
+    async def test_completed_without_waiter_updates_ui(self, handler):
+        """With no executor waiting, the UI still gets the completion"""
+        await _reconcile(handler, _result([{
+            "container_id": "abcdef012345",
+            "status": "completed",
+            "new_container_id": "fedcba987654",
+        }]), signaled=False)
+        message = handler.monitor.manager.broadcast.call_args[0][0]
+        assert message["type"] == "container_update_complete"
+        assert message["data"]["new_container_id"] == "fedcba987654"
+
+    @pytest.mark.asyncio
+    async def test_failed_and_interrupted_report_failure(self, handler):
+        """Failed, rolled back and interrupted updates signal failure"""
+        _, registry = await _reconcile(handler, _result([
+            {"container_id": "aaa", "status": "rolled_back", "error": "unhealthy"},
+            {"container_id": "bbb", "status": "interrupted", "error": "agent stopped"},
+        ]))
+        assert registry.signal_complete.await_count == 2
+        assert all(c.kwargs["success"] is False for c in registry.signal_complete.await_args_list)
+        stages = [c[0][0]["data"]["stage"] for c in handler.monitor.manager.broadcast.call_args_list]
+        assert stages == ["rollback", "failed"]
+
+    @pytest.mark.asyncio
+    async def test_failed_passes_forensics(self, handler):
+        """A failed update hands its forensics and rollback to the executor"""
+        failure = {"exit_code": 2, "logs": "fatal: missing config\n"}
+        _, registry = await _reconcile(handler, _result([
+            {"container_id": "aaa", "status": "rolled_back", "error": "unhealthy", "failure": failure},
+        ]))
+        kwargs = registry.signal_complete.await_args.kwargs
+        assert kwargs["rolled_back"] is True
+        assert kwargs["failure"] == failure
+
+    @pytest.mark.asyncio
+    async def test_running_refreshes_progress(self, handler):
+        """A still-running update re-broadcasts its current stage"""
+        _, registry = await _reconcile(handler, _result([
+            {"container_id": "ccc", "status": "running", "stage": "health_check", "message": "Waiting"},
+        ]))
+        registry.signal_complete.assert_not_awaited()
+        message = handler.monitor.manager.broadcast.call_args[0][0]
+        assert message["type"] == "container_update_progress"
+        assert message["data"]["stage"] == "health_check"
+
+    @pytest.mark.asyncio
+    async def test_command_failure_is_ignored(self, handler):
+        """An agent error leaves everything untouched"""
+        failed = CommandResult(status=CommandStatus.ERROR, success=False, response=None, error="unknown command")
+        _, registry = await _reconcile(handler, failed)
+        registry.signal_complete.assert_not_awaited()
+
+
+class TestUpdateFailedEvent:
+    """Test the agent's live update_failed event"""
+
+    @pytest.mark.asyncio
+    async def test_signals_failure_with_forensics(self, handler):
+        """Should unblock the waiting executor with the error and forensics"""
+        registry = MagicMock()
+        registry.signal_complete = AsyncMock(return_value=True)
+        failure = {"exit_code": 1, "quarantine_name": "web-dockmon-quarantine-1"}
+        with patch("updates.pending_updates.get_pending_updates_registry", return_value=registry):
+            await handler._handle_update_failed({
+                "container_id": "abcdef0123456789",
+                "error": "unhealthy",
+                "rolled_back": True,
+                "failure": failure,
+            })
+        registry.signal_complete.assert_awaited_once_with(
+            host_id="host-1",
+            old_container_id="abcdef012345",
+            new_container_id=None,
+            success=False,
+            error="unhealthy",
+            rolled_back=True,
+            failure=failure,
+        )
@@ -266,6 +266,30 @@ class TestErrorCodeClassification:
         assert result.success is False
         assert result.error_code == CommandErrorCode.DOCKER_ERROR
 
+    @pytest.mark.asyncio
+    async def test_agent_timeout_error_code(self, executor, mock_connection_manager):
+        """Should set TIMEOUT error code when the agent reports a missed deadline"""
+        agent_id = "agent-123"
+        command = {"type": "operation"}
+
+        # Simulate the agent giving up on a command the Docker daemon never answered
+        async def simulate_agent_timeout():
+            await asyncio.sleep(0.05)
+            sent_command = mock_connection_manager.send_command.call_args[0][1]
+            executor.handle_agent_response({
+                "correlation_id": sent_command["correlation_id"],
+                "success": False,
+                "error": "operation timed out: prune_images did not finish within 10m0s",
+                "error_code": "TIMEOUT"
+            })
+
+        asyncio.create_task(simulate_agent_timeout())
+        result = await executor.execute_command(agent_id, command)
+
+        assert result.status == CommandStatus.ERROR
+        assert result.success is False
+        assert result.error_code == CommandErrorCode.TIMEOUT
+
     @pytest.mark.asyncio
     async def test_invalid_response_error_code(self, executor, mock_connection_manager):
         """Should set INVALID_RESPONSE error code for malformed agent responses"""
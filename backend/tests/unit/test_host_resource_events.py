@@ -0,0 +1,58 @@
+"""
+Unit tests for host resource events (image/volume/network audit trail).
+
+The stats service and agents forward image, volume and network events;
+EventLogger.log_host_resource_event turns them into host events.
+"""
+
+from unittest.mock import MagicMock, patch
+
+from event_logger import EventCategory, EventLogger, EventType
+
+
+def _make_logger():
+    db = MagicMock()
+    db.get_settings = MagicMock(return_value=None)
+    return EventLogger(db=db, websocket_manager=None)
+
+
+class TestLogHostResourceEvent:
+    """Test resource event titles and categorization"""
+
+    def test_image_pull(self):
+        """Should log an image pull as a host resource change"""
+        el = _make_logger()
+        with patch.object(el, "log_event") as log_event:
+            el.log_host_resource_event(
+                host_name="prod", host_id="host-1", resource_type="image",
+                action="pull", resource_name="nginx:latest", resource_id="nginx:latest",
+            )
+
+        kwargs = log_event.call_args.kwargs
+        assert kwargs["category"] == EventCategory.HOST
+        assert kwargs["event_type"] == EventType.RESOURCE_CHANGE
+        assert kwargs["title"] == "Image nginx:latest pulled on prod"
+        assert kwargs["context"].host_id == "host-1"
+        assert kwargs["details"]["resource_type"] == "image"
+
+    def test_volume_destroy(self):
+        """Should word destroy as removed"""
+        el = _make_logger()
+        with patch.object(el, "log_event") as log_event:
+            el.log_host_resource_event(
+                host_name="prod", host_id="host-1", resource_type="volume",
+                action="destroy", resource_name="pgdata",
+            )
+
+        assert log_event.call_args.kwargs["title"] == "Volume pgdata removed on prod"
+
+    def test_prune_has_no_resource_name(self):
+        """Should describe prune events per host"""
+        el = _make_logger()
+        with patch.object(el, "log_event") as log_event:
+            el.log_host_resource_event(
+                host_name="prod", host_id="host-1", resource_type="network",
+                action="prune", resource_name="",
+            )
+
+        assert log_event.call_args.kwargs["title"] == "Networks pruned on prod"
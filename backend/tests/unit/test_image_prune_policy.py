@@ -0,0 +1,94 @@
+"""
+Unit tests for policy-based image prunes.
+
+Pins the ImagePruneRequest validation and the prune_images command payload
+the Go agent parses: without a policy the payload stays empty (the daemon's
+own prune), with one the agent's full report is passed through, and agents that predate
+prune policies are refused rather than sent a policy they would ignore.
+"""
+
+import pytest
+from unittest.mock import AsyncMock, MagicMock
+from fastapi import HTTPException
+from pydantic import ValidationError
+
+from agent.container_operations import AgentContainerOperations
+from agent.command_executor import CommandStatus, CommandResult
+from models.request_models import ImagePruneRequest
+
+
+@pytest.mark.unit
+class TestImagePruneRequest:
+    def test_defaults(self):
+        req = ImagePruneRequest()
+        assert req.keep_last_tags == {}
+        assert req.to_policy() == {"dry_run": False}
+
+    def test_policy_with_kept_tags(self):
+        req = ImagePruneRequest(keep_last_tags={" ghcr.io/acme/api ": 3}, dry_run=True)
+        assert req.to_policy() == {"dry_run": True, "keep_last_tags": {"ghcr.io/acme/api": 3}}
+
+    @pytest.mark.parametrize("keep", [{"nginx": 0}, {"": 2}, {"a" * 256: 1}])
+    def test_invalid_kept_tags_rejected(self, keep):
+        with pytest.raises(ValidationError):
+            ImagePruneRequest(keep_last_tags=keep)
+
+
+def make_ops():
+    agent_manager = MagicMock()
+    agent_manager.get_agent_for_host.return_value = "agent-1"
+    agent_manager.agent_has_capability.return_value = True
+    command_executor = MagicMock()
+    command_executor.execute_command = AsyncMock()
+    ops = AgentContainerOperations(
+        command_executor=command_executor,
+        db=MagicMock(),
+        agent_manager=agent_manager,
+    )
+    return ops, command_executor
+
+
+@pytest.mark.unit
+class TestAgentPruneImages:
+    async def test_plain_prune_sends_empty_payload(self):
+        ops, executor = make_ops()
+        executor.execute_command.return_value = CommandResult(
+            status=CommandStatus.SUCCESS, success=True,
+            response={"removed_count": 2, "space_reclaimed": 100},
+        )
+
+        out = await ops.prune_images("host-1")
+
+        assert out == {"removed_count": 2, "space_reclaimed": 100}
+        assert executor.execute_command.call_args[0][1]["payload"] == {}
+
+    async def test_policy_prune_returns_report(self):
+        ops, executor = make_ops()
+        report = {
+            "dry_run": True, "removed": [{"id": "sha256:old"}], "kept": [],
+            "removed_count": 1, "space_reclaimed": 4096,
+        }
+        executor.execute_command.return_value = CommandResult(
+            status=CommandStatus.SUCCESS, success=True, response=report,
+        )
+        policy = {"dry_run": True, "keep_last_tags": {"nginx": 2}}
+
+        out = await ops.prune_images("host-1", policy=policy)
+
+        assert out == report
+        sent = executor.execute_command.call_args[0][1]
+        assert sent["command"] == "prune_images"
+        assert sent["payload"] == {"policy": policy}
+        ops.agent_manager.agent_has_capability.assert_called_once_with("host-1", "image_prune_policies")
+
+    async def test_policy_prune_rejected_on_old_agent(self):
+        """Old agents would ignore the policy and prune every unused image"""
+        ops, executor = make_ops()
+        ops.agent_manager.agent_has_capability.return_value = False
+
+        with pytest.raises(HTTPException) as exc:
+            await ops.prune_images("host-1", policy={"dry_run": True})
+
+        assert exc.value.status_code == 501
+        assert "too old" in exc.value.detail
+        executor.execute_command.assert_not_called()
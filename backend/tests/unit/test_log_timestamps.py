@@ -0,0 +1,71 @@
+"""
+Unit tests for log timestamp rendering (utils/log_timestamps.py).
+
+Direct hosts render get_logs timestamps here; agent hosts do the same in
+shared/docker/logtime.go, so the two must agree.
+"""
+
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from utils.log_timestamps import (
+    format_log_timestamps,
+    parse_log_timestamps,
+    split_log_line,
+)
+
+LOGS = "2026-03-01T10:00:00.123456789Z hello world\npartial line\n2026-03-01T09:55:00Z second\n"
+NOW = datetime(2026, 3, 1, 10, 0, 5, tzinfo=timezone.utc)
+
+
+class TestParseLogTimestamps:
+    def test_defaults_to_utc(self):
+        assert parse_log_timestamps(None, None) == ("utc", None)
+
+    def test_tz_without_mode_means_local(self):
+        mode, location = parse_log_timestamps(None, "Europe/Berlin")
+        assert mode == "local"
+        assert str(location) == "Europe/Berlin"
+
+    def test_local_without_tz_uses_backend_zone(self):
+        mode, location = parse_log_timestamps("LOCAL", None)
+        assert mode == "local" and location is not None
+
+    @pytest.mark.parametrize("mode, tz", [("sideways", None), ("local", "Mars/Olympus")])
+    def test_rejects_invalid(self, mode, tz):
+        with pytest.raises(ValueError):
+            parse_log_timestamps(mode, tz)
+
+
+class TestFormatLogTimestamps:
+    def test_local(self):
+        _, berlin = parse_log_timestamps("local", "Europe/Berlin")
+        out = format_log_timestamps(LOGS, "local", berlin, NOW).splitlines()
+        assert out[0] == "2026-03-01T11:00:00.123456+01:00 hello world"
+        assert out[1] == "partial line"
+        assert out[2] == "2026-03-01T10:55:00+01:00 second"
+
+    def test_relative(self):
+        out = format_log_timestamps(LOGS, "relative", None, NOW).splitlines()
+        assert out == ["4s ago hello world", "partial line", "5m ago second"]
+
+    def test_utc_passes_through(self):
+        assert format_log_timestamps(LOGS, "utc", None, NOW) == LOGS
+
+
+class TestSplitLogLine:
+    @pytest.mark.parametrize("line, mode, expected", [
+        ("2026-03-01T11:00:00+01:00 hello", "local", ("2026-03-01T11:00:00+01:00", "hello")),
+        ("5m ago hello there", "relative", ("5m ago", "hello there")),
+        ("now hello", "relative", ("now", "hello")),
+        ("2026-03-01T10:00:00Z hello", "none", (None, "2026-03-01T10:00:00Z hello")),
+        ("no timestamp here", "local", (None, "no timestamp here")),
+    ])
+    def test_split(self, line, mode, expected):
+        assert split_log_line(line, mode) == expected
+
+    def test_relative_matches_format_age(self):
+        ts = (NOW - timedelta(hours=3)).isoformat()
+        line = format_log_timestamps(f"{ts} x", "relative", None, NOW)
+        assert split_log_line(line, "relative") == ("3h ago", "x")
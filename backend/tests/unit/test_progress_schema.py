@@ -0,0 +1,62 @@
+"""
+Unit tests for the shared progress event schema.
+
+Agents and Go services send progress in one versioned schema; events from
+builds that predate it are mapped onto the same field names.
+"""
+
+from utils.progress_schema import (
+    OPERATION_DEPLOY,
+    OPERATION_SELF_UPDATE,
+    OPERATION_UPDATE,
+    normalize_progress,
+)
+
+
+class TestNormalizeProgress:
+    """Test mapping progress events onto the schema"""
+
+    def test_schema_event_unchanged(self):
+        """Events in the schema keep their fields, including percent"""
+        event = {
+            "schema": 1,
+            "operation": "self_update",
+            "stage": "download",
+            "message": "Downloaded 5.0 of 10.0 MB",
+            "percent": 30,
+            "stage_percent": 50,
+            "current_bytes": 5242880,
+        }
+        assert normalize_progress(event, OPERATION_SELF_UPDATE) == event
+
+    def test_legacy_layer_progress(self):
+        """Legacy pull progress maps overall_progress and summary"""
+        event = normalize_progress(
+            {"container_id": "abc", "overall_progress": 40, "summary": "Downloading 2 of 5 layers"},
+            OPERATION_UPDATE,
+        )
+        assert event["stage_percent"] == 40
+        assert event["message"] == "Downloading 2 of 5 layers"
+        assert event["operation"] == "update"
+        assert "overall_progress" not in event and "summary" not in event
+
+    def test_legacy_self_update_download(self):
+        """Legacy self-update percent was the download percentage"""
+        event = normalize_progress(
+            {"stage": "download", "percent": 75, "downloaded": 750, "total": 1000},
+            OPERATION_SELF_UPDATE,
+        )
+        assert event["stage_percent"] == 75
+        assert event["current_bytes"] == 750
+        assert event["total_bytes"] == 1000
+        assert "percent" not in event
+
+    def test_legacy_compose_progress(self):
+        """Legacy compose progress was the overall percentage"""
+        event = normalize_progress({"stage": "creating", "progress": 65}, OPERATION_DEPLOY)
+        assert event["percent"] == 65
+        assert "stage_percent" not in event
+
+    def test_malformed_payload(self):
+        """Non-dict payloads normalize to an empty legacy event"""
+        assert normalize_progress(None, OPERATION_UPDATE) == {"schema": 0, "operation": "update"}
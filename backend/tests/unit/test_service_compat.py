@@ -0,0 +1,77 @@
+"""
+Unit tests for Go service version skew detection.
+
+stats-service and compose-service report version and api_level in /health;
+the backend flags services older than its compatibility matrix requires.
+"""
+
+import pytest
+
+from utils import service_compat
+from utils.service_compat import (
+    COMPOSE_SERVICE,
+    REQUIRED_API_LEVELS,
+    STATS_SERVICE,
+    check_component,
+    get_components,
+    not_found_hint,
+    record_component,
+)
+
+
+@pytest.fixture(autouse=True)
+def _reset_components():
+    service_compat.reset()
+    yield
+    service_compat.reset()
+
+
+class TestCheckComponent:
+    """Test the compatibility matrix check"""
+
+    def test_current_service_is_compatible(self):
+        """A service at the required level passes"""
+        health = {"status": "ok", "version": "2.3.0", "api_level": REQUIRED_API_LEVELS[STATS_SERVICE]}
+        assert check_component(STATS_SERVICE, health) is None
+
+    def test_unversioned_service_is_outdated(self):
+        """Services that predate version reporting count as level 0"""
+        warning = check_component(STATS_SERVICE, {"status": "ok", "service": "dockmon-stats"})
+        assert warning is not None
+        assert "please update stats-service" in warning
+        assert "unknown version" in warning
+
+    def test_malformed_api_level(self):
+        """A non-integer api_level is treated as missing"""
+        assert check_component(COMPOSE_SERVICE, {"api_level": "7"}) is not None
+        assert check_component(COMPOSE_SERVICE, {"api_level": True}) is not None
+        assert check_component(COMPOSE_SERVICE, None) is not None
+
+
+class TestRecordComponent:
+    """Test remembering versions and explaining 404s"""
+
+    def test_outdated_service_explains_404s(self):
+        """404s from an outdated service carry the update hint"""
+        record_component(STATS_SERVICE, {"version": "2.0.0"})
+
+        components = get_components()
+        assert components[STATS_SERVICE]["compatible"] is False
+        assert components[STATS_SERVICE]["version"] == "2.0.0"
+        assert "please update stats-service" in not_found_hint(STATS_SERVICE)
+        assert not_found_hint(COMPOSE_SERVICE) == ""
+
+    def test_updated_service_clears_hint(self):
+        """Recording a compatible version replaces the outdated one"""
+        record_component(STATS_SERVICE, {"version": "2.0.0"})
+        assert record_component(STATS_SERVICE, {"version": "2.3.0", "api_level": REQUIRED_API_LEVELS[STATS_SERVICE]}) is None
+
+        assert get_components()[STATS_SERVICE]["compatible"] is True
+        assert not_found_hint(STATS_SERVICE) == ""
+
+    def test_logs_skew_once(self, caplog):
+        """The warning is logged when the version changes, not on every check"""
+        with caplog.at_level("WARNING", logger="utils.service_compat"):
+            record_component(COMPOSE_SERVICE, {"version": "2.0.0"})
+            record_component(COMPOSE_SERVICE, {"version": "2.0.0"})
+        assert sum("please update compose-service" in r.getMessage() for r in caplog.records) == 1
@@ -0,0 +1,28 @@
+"""
+Unit tests for the headers the backend sends to the compose service:
+the host a request is for and who asked for it.
+"""
+
+from utils.service_headers import SOURCE_API, SOURCE_UI, dockmon_headers, requester_of
+
+
+def test_host_header():
+    assert dockmon_headers("host-1") == {"X-DockMon-Host": "host-1"}
+
+
+def test_no_host_no_header():
+    assert dockmon_headers() == {}
+    assert dockmon_headers("") == {}
+
+
+def test_requester_headers():
+    headers = dockmon_headers("host-1", user="Zoë", source=SOURCE_UI)
+    assert headers["X-DockMon-User"] == "Zo?"
+    assert headers["X-DockMon-Source"] == "ui"
+
+
+def test_requester_of():
+    session = {"auth_type": "session", "user_id": 1, "username": "admin", "display_name": "Admin"}
+    assert requester_of(session) == ("Admin", SOURCE_UI)
+    api_key = {"auth_type": "api_key", "api_key_name": "ci", "created_by_user_id": 1}
+    assert requester_of(api_key) == ("API Key: ci", SOURCE_API)
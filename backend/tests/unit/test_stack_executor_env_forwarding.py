@@ -52,6 +52,8 @@ async def test_execute_stack_deployment_forwards_env_files_single():
     assert kwargs.get("env_files") == {".env": "IMAGE=nginx:alpine\n"}
     assert "environment" not in kwargs
     assert "env_file_content" not in kwargs
+    # Sent as X-DockMon-Host so the deployment shows on the activity stream
+    assert kwargs.get("host_id") == "host-1"
 
 
 async def test_execute_stack_deployment_forwards_env_files_map():
@@ -0,0 +1,78 @@
+"""
+Unit tests for batching agent container stats into stats-service's
+/api/stats/ingest.
+"""
+
+from unittest.mock import AsyncMock
+
+import pytest
+
+from stats_client import MAX_INGEST_BATCH_SAMPLES, StatsServiceClient
+
+
+def _sample(container_id="abc123def456", **extra):
+    return {
+        "container_id": container_id,
+        "container_name": "web",
+        "cpu_percent": 12.5,
+        "memory_usage": 1024,
+        "memory_limit": 4096,
+        "memory_percent": 25.0,
+        **extra,
+    }
+
+
+@pytest.mark.asyncio
+async def test_flush_sends_one_batch_per_host():
+    client = StatsServiceClient()
+    client.ingest_stats = AsyncMock(return_value=True)
+
+    client.queue_ingest_sample("host-1", _sample("c1"))
+    client.queue_ingest_sample("host-1", _sample("c2"))
+    client.queue_ingest_sample("host-2", _sample("c3"))
+    client._ingest_task.cancel()
+    await client.flush_ingest()
+
+    sent = {call.args[0]: call.args[1] for call in client.ingest_stats.await_args_list}
+    assert [s["container_id"] for s in sent["host-1"]] == ["c1", "c2"]
+    assert [s["container_id"] for s in sent["host-2"]] == ["c3"]
+    assert client._ingest_buffer == {}
+
+
+@pytest.mark.asyncio
+async def test_queue_keeps_only_ingest_fields():
+    client = StatsServiceClient()
+    client.ingest_stats = AsyncMock(return_value=True)
+
+    client.queue_ingest_sample("host-1", _sample(type="container_stats", net_bytes_per_sec=5, state="paused"))
+    client._ingest_task.cancel()
+    await client.flush_ingest()
+
+    sample = client.ingest_stats.await_args.args[1][0]
+    assert "type" not in sample
+    assert "net_bytes_per_sec" not in sample
+    assert sample["state"] == "paused"
+
+
+@pytest.mark.asyncio
+async def test_queue_ignores_samples_without_host_or_container():
+    client = StatsServiceClient()
+
+    client.queue_ingest_sample("", _sample())
+    client.queue_ingest_sample("host-1", _sample(container_id=""))
+
+    assert client._ingest_buffer == {}
+    assert client._ingest_task is None
+
+
+@pytest.mark.asyncio
+async def test_queue_drops_oldest_when_full():
+    client = StatsServiceClient()
+
+    for i in range(MAX_INGEST_BATCH_SAMPLES + 1):
+        client.queue_ingest_sample("host-1", _sample(f"c{i}"))
+    client._ingest_task.cancel()
+
+    samples = client._ingest_buffer["host-1"]
+    assert len(samples) == MAX_INGEST_BATCH_SAMPLES
+    assert samples[0]["container_id"] == "c1"
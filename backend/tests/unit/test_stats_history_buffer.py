@@ -219,3 +219,31 @@ class TestCleanupAgeTrim:
         buf.cleanup_old_data(max_age_seconds=600)
         assert "gone" not in buf._history
         assert buf.get_sparklines("gone") == {"cpu": [], "mem": [], "net": []}
+
+
+class TestCpuPerCore:
+    """The latest per-core snapshot lives beside the buffered series"""
+
+    def test_set_get_and_clear(self):
+        buf = StatsHistoryBuffer()
+        buf.set_cpu_per_core("h1", [10.0, 90.0])
+        assert buf.get_cpu_per_core("h1") == [10.0, 90.0]
+        buf.set_cpu_per_core("h1", None)
+        assert buf.get_cpu_per_core("h1") is None
+
+    def test_removed_with_host(self):
+        buf = StatsHistoryBuffer()
+        buf.add_stats("h1", cpu=1.0, mem=1.0, net=1.0)
+        buf.set_cpu_per_core("h1", [5.0])
+        buf.remove_host("h1")
+        assert buf.get_cpu_per_core("h1") is None
+
+    def test_dropped_when_history_ages_out(self):
+        buf = StatsHistoryBuffer()
+        buf.add_stats("gone", cpu=1.0, mem=1.0, net=1.0)
+        buf.set_cpu_per_core("gone", [5.0])
+        stale = datetime.now(timezone.utc) - timedelta(seconds=1000)
+        for point in buf._history["gone"]:
+            point.timestamp = stale
+        buf.cleanup_old_data(max_age_seconds=600)
+        assert buf.get_cpu_per_core("gone") is None
@@ -128,3 +128,23 @@ class TestUpdateEventEmitter:
 
             assert event.event_type == EventType.UPDATE_FAILED
             assert event.data['error_message'] == 'Container failed health check'
+            assert 'failure' not in event.data
+
+    @pytest.mark.asyncio
+    async def test_emit_failed_includes_forensics(self, emitter):
+        """Test that emit_failed carries the failed container's forensics."""
+        failure = {'exit_code': 2, 'logs': 'fatal: missing config\n', 'quarantine_name': 'web-dockmon-quarantine-1'}
+        with patch('updates.event_emitter.get_event_bus') as mock_get_bus:
+            mock_bus = AsyncMock()
+            mock_get_bus.return_value = mock_bus
+
+            await emitter.emit_failed(
+                host_id='host-123',
+                container_id='abc123def456',
+                container_name='test-container',
+                error_message='Container failed health check',
+                failure=failure,
+            )
+
+            event = mock_bus.emit.call_args[0][0]
+            assert event.data['failure'] == failure
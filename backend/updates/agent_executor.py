@@ -25,7 +25,7 @@ from database import (
 )
 from utils.keys import make_composite_key
 from agent.command_executor import CommandStatus
-from updates.types import UpdateContext, UpdateResult, ProgressCallback
+from updates.types import UpdateContext, UpdateResult, ProgressCallback, failure_forensics_options
 from updates.database_updater import update_container_records_after_update
 from updates.pending_updates import get_pending_updates_registry
 
@@ -142,6 +142,7 @@ class AgentUpdateExecutor:
                     "stop_timeout": 30,
                     "health_timeout": 120,
                     "registry_auth": registry_auth,
+                    **failure_forensics_options(),
                 }
             }
 
@@ -181,7 +182,12 @@ class AgentUpdateExecutor:
                 if not update_success:
                     error_msg = pending.error or "Agent update failed or timed out"
                     logger.error(f"Agent update failed for {context.container_name}: {error_msg}")
-                    return UpdateResult.failure_result(error_msg)
+                    return UpdateResult(
+                        success=False,
+                        error_message=error_msg,
+                        rollback_performed=pending.rolled_back,
+                        failure=pending.failure,
+                    )
 
                 # Get new container ID from the completion event
                 new_container_id = pending.new_container_id
@@ -287,14 +293,15 @@ class AgentUpdateExecutor:
                     logger.warning("Could not resolve 'latest' - failed to fetch from GitHub")
 
             # Agent releases use agent-v* tag pattern (e.g., agent-v1.0.0)
-            binary_url = f"https://github.com/darthnorse/dockmon/releases/download/agent-v{version}/dockmon-agent-{agent_os}-{agent_arch}"
+            from updates.dockmon_update_checker import agent_binary_name
+            binary_url = f"https://github.com/darthnorse/dockmon/releases/download/agent-v{version}/{agent_binary_name(agent_os, agent_arch)}"
 
             # Fetch checksum for binary verification (security)
             checksum = None
             try:
                 from updates.dockmon_update_checker import get_dockmon_update_checker
                 checker = get_dockmon_update_checker(self.db)
-                checksum = await checker.fetch_agent_checksum(version, agent_arch)
+                checksum = await checker.fetch_agent_checksum(version, agent_arch, agent_os)
                 if checksum:
                     logger.info(f"Fetched checksum for agent binary: {checksum[:16]}...")
                 else:
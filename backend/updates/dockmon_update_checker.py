@@ -41,6 +41,19 @@ def normalize_version(version: str) -> str:
     return normalized
 
 
+def agent_binary_name(agent_os: str, agent_arch: str) -> str:
+    """
+    Release asset name of the native agent binary for a platform.
+
+    Windows binaries carry an .exe suffix; the service manager won't start
+    an executable without one.
+    """
+    name = f"dockmon-agent-{agent_os}-{agent_arch}"
+    if agent_os == "windows":
+        name += ".exe"
+    return name
+
+
 class DockMonUpdateChecker:
     """Check GitHub for DockMon and Agent application updates"""
 
@@ -387,13 +400,14 @@ class DockMonUpdateChecker:
             # Fallback: return first match (GitHub returns newest first)
             return matching[0] if matching else None
 
-    async def fetch_agent_checksum(self, version: str, arch: str) -> Optional[str]:
+    async def fetch_agent_checksum(self, version: str, arch: str, agent_os: str = "linux") -> Optional[str]:
         """
         Fetch checksum for agent binary from release assets.
 
         Args:
             version: Agent version (e.g., '1.0.0')
             arch: Architecture ('amd64' or 'arm64')
+            agent_os: Operating system ('linux', 'darwin' or 'windows')
 
         Returns:
             SHA256 checksum string, or None if not found
@@ -418,11 +432,11 @@ class DockMonUpdateChecker:
                         parts = line.split()
                         if len(parts) >= 2:
                             checksum, filename = parts[0], parts[1]
-                            if f"linux-{arch}" in filename:
-                                logger.debug(f"Found checksum for {arch}: {checksum[:16]}...")
+                            if f"{agent_os}-{arch}" in filename:
+                                logger.debug(f"Found checksum for {agent_os}-{arch}: {checksum[:16]}...")
                                 return checksum
 
-                    logger.warning(f"No checksum found for linux-{arch} in {tag}")
+                    logger.warning(f"No checksum found for {agent_os}-{arch} in {tag}")
                     return None
 
         except Exception as e:
@@ -102,10 +102,21 @@ class UpdateEventEmitter:
         host_id: str,
         container_id: str,
         container_name: str,
-        error_message: str
+        error_message: str,
+        failure: Optional[dict] = None,
     ):
-        """Emit UPDATE_FAILED event."""
+        """
+        Emit UPDATE_FAILED event.
+
+        failure is the forensics captured from the failed new container
+        (exit code, last log lines, quarantine name), when there is any.
+        """
         try:
+            data = {
+                'error_message': error_message,
+            }
+            if failure:
+                data['failure'] = failure
             event_bus = get_event_bus(self.monitor)
             await event_bus.emit(Event(
                 event_type=BusEventType.UPDATE_FAILED,
@@ -114,9 +125,7 @@ class UpdateEventEmitter:
                 scope_name=container_name,
                 host_id=host_id,
                 host_name=self._get_host_name(host_id),
-                data={
-                    'error_message': error_message,
-                }
+                data=data,
             ))
         except Exception as e:
             logger.error(f"Error emitting update failed event: {e}")
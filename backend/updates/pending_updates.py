@@ -31,6 +31,9 @@ class PendingUpdate:
     new_container_id: Optional[str] = None
     success: bool = False
     error: Optional[str] = None
+    rolled_back: bool = False
+    # Forensics the agent captured from a new container that failed
+    failure: Optional[dict] = None
 
 
 class PendingUpdatesRegistry:
@@ -84,11 +87,14 @@ class PendingUpdatesRegistry:
         new_container_id: str,
         success: bool = True,
         error: Optional[str] = None,
+        rolled_back: bool = False,
+        failure: Optional[dict] = None,
     ) -> bool:
         """
         Signal that an update has completed.
 
-        Called by WebSocketHandler when update_complete event received.
+        Called by WebSocketHandler when an update_complete or update_failed
+        event is received.
         Returns True if a pending update was found and signaled.
         """
         key = self._make_key(host_id, old_container_id)
@@ -99,6 +105,8 @@ class PendingUpdatesRegistry:
                 pending.new_container_id = new_container_id[:12] if new_container_id else None
                 pending.success = success
                 pending.error = error
+                pending.rolled_back = rolled_back
+                pending.failure = failure
                 pending.completion_event.set()
                 logger.info(f"Signaled completion for {key}: success={success}, new_id={new_container_id[:12] if new_container_id else None}")
                 return True
@@ -49,6 +49,10 @@ class UpdateContext:
     force: bool = False
     force_warn: bool = False
 
+    # Who asked for the update, for the compose project's deployment history
+    requested_by: Optional[str] = None
+    source: Optional[str] = None
+
     # Optional metadata
     auth_config: Optional[Dict[str, str]] = None  # Registry credentials
 
@@ -79,6 +83,10 @@ class UpdateResult:
     # If update succeeded but dependent container recreation failed
     failed_dependents: Optional[List[str]] = None
 
+    # Forensics captured from a new container that failed its health check
+    # (state, last log lines, quarantine name), if the update service sent any
+    failure: Optional[Dict[str, Any]] = None
+
     @classmethod
     def success_result(
         cls,
@@ -102,6 +110,22 @@ class UpdateResult:
         )
 
 
+def failure_forensics_options() -> Dict[str, int]:
+    """
+    Failure forensics fields for an update request, from the
+    DOCKMON_UPDATE_FAILURE_LOG_LINES and DOCKMON_UPDATE_QUARANTINE_HOURS settings.
+
+    The update engine reads failure_log_lines 0 as "its default", so keeping
+    no logs is sent as -1.
+    """
+    from config.settings import AppConfig
+
+    return {
+        "failure_log_lines": AppConfig.UPDATE_FAILURE_LOG_LINES or -1,
+        "quarantine_hours": AppConfig.UPDATE_QUARANTINE_HOURS,
+    }
+
+
 # Type alias for progress callback
 # Signature: async def callback(stage: str, percent: int, message: str) -> None
 ProgressCallback = Callable[[str, int, str], Awaitable[None]]
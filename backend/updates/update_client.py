@@ -19,6 +19,9 @@ from typing import Any, Awaitable, Callable, Dict, List, Optional
 
 import httpx
 
+from utils.progress_schema import OPERATION_UPDATE, normalize_progress
+from utils.service_headers import dockmon_headers
+
 logger = logging.getLogger(__name__)
 
 # Default socket path - matches compose-service default
@@ -57,6 +60,7 @@ class UpdateResult:
     rolled_back: bool = False
     failed_dependents: Optional[List[str]] = None
     error: Optional[str] = None
+    failure: Optional[Dict[str, Any]] = None  # Forensics from a new container that failed
 
 
 @dataclass
@@ -117,6 +121,11 @@ class UpdateClient:
         tls_cert: Optional[str] = None,
         tls_key: Optional[str] = None,
         registry_auth: Optional[RegistryAuth] = None,
+        failure_log_lines: int = 0,
+        quarantine_hours: int = 0,
+        host_id: Optional[str] = None,
+        user: Optional[str] = None,
+        source: Optional[str] = None,
     ) -> UpdateResult:
         """
         Update a container (JSON response, no streaming).
@@ -132,6 +141,14 @@ class UpdateClient:
             tls_cert: TLS client certificate PEM
             tls_key: TLS client key PEM
             registry_auth: Registry authentication for private registries
+            failure_log_lines: Log lines kept from a new container that fails
+                (0: service default, negative: none)
+            quarantine_hours: Hours a failed new container is kept stopped
+                instead of removed (0: removed right away)
+            host_id: DockMon host ID, sent so the update is announced on the
+                activity stream
+            user: Who asked for the update, for the project's history
+            source: Where it was asked for, e.g. "ui" or "auto_update"
 
         Returns:
             UpdateResult with update outcome
@@ -143,6 +160,10 @@ class UpdateClient:
             "health_timeout": health_timeout,
             "timeout": timeout,
         }
+        if failure_log_lines:
+            request["failure_log_lines"] = failure_log_lines
+        if quarantine_hours:
+            request["quarantine_hours"] = quarantine_hours
 
         # Add remote connection info if provided
         if docker_host:
@@ -178,6 +199,7 @@ class UpdateClient:
                 response = await client.post(
                     "http://localhost/update",
                     json=request,
+                    headers=dockmon_headers(host_id, user, source),
                 )
 
                 if response.status_code != 200:
@@ -207,6 +229,11 @@ class UpdateClient:
         tls_cert: Optional[str] = None,
         tls_key: Optional[str] = None,
         registry_auth: Optional[RegistryAuth] = None,
+        failure_log_lines: int = 0,
+        quarantine_hours: int = 0,
+        host_id: Optional[str] = None,
+        user: Optional[str] = None,
+        source: Optional[str] = None,
     ) -> UpdateResult:
         """
         Update with SSE progress streaming.
@@ -228,6 +255,10 @@ class UpdateClient:
             "health_timeout": health_timeout,
             "timeout": timeout,
         }
+        if failure_log_lines:
+            request["failure_log_lines"] = failure_log_lines
+        if quarantine_hours:
+            request["quarantine_hours"] = quarantine_hours
 
         # Add remote connection info if provided
         if docker_host:
@@ -266,7 +297,7 @@ class UpdateClient:
                             "POST",
                             "http://localhost/update",
                             json=request,
-                            headers={"Accept": "text/event-stream"},
+                            headers={"Accept": "text/event-stream", **dockmon_headers(host_id, user, source)},
                         ) as response:
                             event_type = None
 
@@ -280,19 +311,21 @@ class UpdateClient:
                                     data = json.loads(data_str)
 
                                     if event_type == "progress":
+                                        data = normalize_progress(data, OPERATION_UPDATE)
                                         event = ProgressEvent(
                                             stage=data.get("stage", ""),
                                             message=data.get("message", ""),
-                                            progress=data.get("progress", 0),
+                                            progress=data.get("stage_percent", 0),
                                         )
                                         await progress_callback(event)
                                     elif event_type == "pull_progress":
                                         if pull_progress_callback:
+                                            data = normalize_progress(data, OPERATION_UPDATE)
                                             event = PullProgressEvent(
                                                 container_id=data.get("container_id", ""),
-                                                overall_progress=data.get("overall_progress", 0),
+                                                overall_progress=data.get("stage_percent", 0),
                                                 total_layers=data.get("total_layers", 0),
-                                                summary=data.get("summary", ""),
+                                                summary=data.get("message", ""),
                                                 speed_mbps=data.get("speed_mbps", 0.0),
                                                 layers=data.get("layers"),
                                             )
@@ -326,6 +359,7 @@ class UpdateClient:
             rolled_back=data.get("rolled_back", False),
             failed_dependents=data.get("failed_dependents"),
             error=data.get("error"),
+            failure=data.get("failure"),
         )
 
 
@@ -27,8 +27,9 @@ from database import (
 from utils.async_docker import async_docker_call
 from utils.keys import make_composite_key
 from utils.cache import CACHE_REGISTRY
+from utils.service_headers import SOURCE_AUTO_UPDATE
 from updates.container_validator import ContainerValidator, ValidationResult
-from updates.types import UpdateContext, UpdateResult
+from updates.types import UpdateContext, UpdateResult, failure_forensics_options
 from updates.agent_executor import AgentUpdateExecutor
 from updates.database_updater import update_container_records_after_update
 from updates.event_emitter import UpdateEventEmitter
@@ -228,7 +229,9 @@ class UpdateExecutor:
         container_id: str,
         update_record: ContainerUpdate,
         force: bool = False,
-        force_warn: bool = False
+        force_warn: bool = False,
+        requested_by: Optional[str] = None,
+        source: str = SOURCE_AUTO_UPDATE,
     ) -> bool:
         """
         Execute update for a single container.
@@ -241,6 +244,8 @@ class UpdateExecutor:
             update_record: ContainerUpdate database record
             force: If True, skip ALL validation
             force_warn: If True, allow WARN containers but still block BLOCK
+            requested_by: Who asked for the update (None for auto-updates)
+            source: Where it was asked for, e.g. "ui", "api" or "auto_update"
 
         Returns:
             True if successful, False otherwise
@@ -288,6 +293,8 @@ class UpdateExecutor:
                 update_record_id=update_record.id,
                 force=force,
                 force_warn=force_warn,
+                requested_by=requested_by,
+                source=source,
             )
 
             # Progress callback
@@ -351,7 +358,8 @@ class UpdateExecutor:
                 # Emit failure event
                 await self.event_emitter.emit_failed(
                     host_id, container_id, container_name,
-                    result.error_message or "Update failed"
+                    result.error_message or "Update failed",
+                    failure=result.failure,
                 )
 
                 if result.rollback_performed:
@@ -492,6 +500,10 @@ class UpdateExecutor:
                 tls_cert=tls_cert,
                 tls_key=tls_key,
                 registry_auth=registry_auth,
+                host_id=context.host_id,
+                user=context.requested_by,
+                source=context.source,
+                **failure_forensics_options(),
             )
 
             if result.success:
@@ -510,6 +522,7 @@ class UpdateExecutor:
                     success=False,
                     error_message=result.error or "Update failed",
                     rollback_performed=result.rolled_back,
+                    failure=result.failure,
                 )
 
         except UpdateServiceUnavailable as e:
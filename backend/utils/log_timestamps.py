@@ -0,0 +1,115 @@
+"""Timestamp rendering for retrieved container log lines.
+
+Mirrors the agent's get_logs options (shared/docker/logtime.go) so direct
+hosts render log timestamps the same way agent hosts do:
+
+- utc: Docker's RFC 3339 UTC timestamps (default)
+- none: no timestamps
+- local: converted to an IANA time zone (default: the backend's)
+- relative: the line's age, e.g. "5m ago"
+"""
+
+import re
+from datetime import datetime, timezone, tzinfo
+from typing import Optional, Tuple
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+LOG_TIMESTAMPS_UTC = "utc"
+LOG_TIMESTAMPS_NONE = "none"
+LOG_TIMESTAMPS_LOCAL = "local"
+LOG_TIMESTAMPS_RELATIVE = "relative"
+
+LOG_TIMESTAMP_MODES = (
+    LOG_TIMESTAMPS_UTC, LOG_TIMESTAMPS_NONE, LOG_TIMESTAMPS_LOCAL, LOG_TIMESTAMPS_RELATIVE,
+)
+
+# A relative timestamp as rendered by format_age, then the line
+_RELATIVE_RE = re.compile(r'^(now|\d+[smhd] ago) (.*)$', re.DOTALL)
+
+# Fractional seconds beyond microseconds, which datetime can't parse
+_NANOS_RE = re.compile(r'(\.\d{6})\d+')
+
+
+def parse_log_timestamps(mode: Optional[str], tz: Optional[str]) -> Tuple[str, Optional[tzinfo]]:
+    """Resolve a timestamps mode and IANA time zone name.
+
+    A time zone without a mode means local; local without a time zone uses
+    the backend's. Raises ValueError for an unknown mode or time zone.
+    """
+    mode = (mode or "").lower()
+    if not mode:
+        mode = LOG_TIMESTAMPS_LOCAL if tz else LOG_TIMESTAMPS_UTC
+    if mode not in LOG_TIMESTAMP_MODES:
+        raise ValueError(f"invalid timestamps mode {mode!r} (want utc, none, local or relative)")
+    if mode != LOG_TIMESTAMPS_LOCAL:
+        return mode, None
+    if not tz:
+        return mode, datetime.now().astimezone().tzinfo
+    try:
+        return mode, ZoneInfo(tz)
+    except (ZoneInfoNotFoundError, ValueError):
+        raise ValueError(f"invalid time zone {tz!r}") from None
+
+
+def parse_docker_timestamp(value: str) -> Optional[datetime]:
+    """Parse an RFC 3339 timestamp with up to nanosecond precision"""
+    try:
+        return datetime.fromisoformat(_NANOS_RE.sub(r'\1', value).replace('Z', '+00:00'))
+    except ValueError:
+        return None
+
+
+def format_age(seconds: float) -> str:
+    """How long ago a line was logged, in its largest unit"""
+    if seconds < 1:
+        return "now"
+    if seconds < 60:
+        return f"{int(seconds)}s ago"
+    if seconds < 3600:
+        return f"{int(seconds // 60)}m ago"
+    if seconds < 86400:
+        return f"{int(seconds // 3600)}h ago"
+    return f"{int(seconds // 86400)}d ago"
+
+
+def format_log_timestamps(logs: str, mode: str, location: Optional[tzinfo],
+                          now: Optional[datetime] = None) -> str:
+    """Rewrite the Docker timestamp at the start of each line of logs.
+
+    Logs must have been retrieved with timestamps. Lines without one (e.g.
+    partial TTY output) are left as they are; utc and none pass through.
+    """
+    if mode not in (LOG_TIMESTAMPS_LOCAL, LOG_TIMESTAMPS_RELATIVE):
+        return logs
+    now = now or datetime.now(timezone.utc)
+    lines = logs.splitlines(keepends=True)
+    for i, line in enumerate(lines):
+        space = line.find(' ')
+        if space < 0:
+            continue
+        ts = parse_docker_timestamp(line[:space])
+        if ts is None:
+            continue
+        if mode == LOG_TIMESTAMPS_LOCAL:
+            formatted = ts.astimezone(location).isoformat()
+        else:
+            formatted = format_age((now - ts).total_seconds())
+        lines[i] = formatted + line[space:]
+    return "".join(lines)
+
+
+def split_log_line(line: str, mode: str) -> Tuple[Optional[str], str]:
+    """Split a rendered log line into its timestamp and text.
+
+    Returns (None, line) when the line has no timestamp in the given mode.
+    """
+    if mode == LOG_TIMESTAMPS_NONE:
+        return None, line
+    if mode == LOG_TIMESTAMPS_RELATIVE:
+        match = _RELATIVE_RE.match(line)
+        if match:
+            return match.group(1), match.group(2)
+    space = line.find(' ')
+    if space > 0 and parse_docker_timestamp(line[:space]) is not None:
+        return line[:space], line[space + 1:]
+    return None, line
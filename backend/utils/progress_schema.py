@@ -0,0 +1,69 @@
+"""
+Shared progress event schema.
+
+Container updates, compose deployments and agent self-updates all report
+progress in one versioned schema (shared/progress in the Go services):
+stage, message, percent (whole operation), stage_percent (current stage),
+error, transfer fields (current_bytes, total_bytes, speed_mbps, layers) and
+the subject (container_id, deployment_id, service, ...).
+
+Agents and services built before the schema existed send per-emitter field
+names instead; normalize_progress() maps both onto the schema so consumers
+read a single set of keys.
+"""
+
+from typing import Any, Dict, Optional
+
+SCHEMA_VERSION = 1
+
+OPERATION_UPDATE = "update"
+OPERATION_DEPLOY = "deploy"
+OPERATION_SELF_UPDATE = "self_update"
+
+# Legacy field -> schema field, per operation. Self-update's "percent" was
+# the download percentage, which the schema calls stage_percent.
+_LEGACY_FIELDS: Dict[str, Dict[str, str]] = {
+    OPERATION_UPDATE: {
+        "progress": "stage_percent",
+        "overall_progress": "stage_percent",
+        "summary": "message",
+    },
+    OPERATION_DEPLOY: {
+        "progress": "percent",
+        "overall_progress": "stage_percent",
+    },
+    OPERATION_SELF_UPDATE: {
+        "percent": "stage_percent",
+        "downloaded": "current_bytes",
+        "total": "total_bytes",
+    },
+}
+
+
+def normalize_progress(payload: Optional[Dict[str, Any]], operation: str) -> Dict[str, Any]:
+    """
+    Map a progress event onto the shared schema.
+
+    Events that carry "schema" are returned as a copy; legacy events have
+    their per-emitter fields renamed.
+
+    Args:
+        payload: Progress event as received
+        operation: OPERATION_* the event belongs to (legacy events don't say)
+
+    Returns:
+        The event with schema field names
+    """
+    payload = payload if isinstance(payload, dict) else {}
+    event = dict(payload)
+    if isinstance(payload.get("schema"), int):
+        return event
+
+    event["schema"] = 0
+    event.setdefault("operation", operation)
+    for legacy, field in _LEGACY_FIELDS.get(operation, {}).items():
+        if legacy in payload:
+            event.pop(legacy, None)
+            if payload[legacy] is not None and not event.get(field):
+                event[field] = payload[legacy]
+    return event
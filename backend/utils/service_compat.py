@@ -0,0 +1,112 @@
+"""
+Version skew detection for DockMon's Go services.
+
+stats-service and compose-service report their build version and API level
+in /health. The API level is bumped whenever the backend starts relying on a
+new endpoint or request field, so a backend running against an outdated
+service binary can say "please update stats-service" instead of failing with
+unexplained 404s.
+"""
+
+import logging
+import threading
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+STATS_SERVICE = "stats-service"
+COMPOSE_SERVICE = "compose-service"
+
+# Minimum API level this backend needs from each Go service. Services that
+# predate version reporting send no api_level and count as level 0.
+REQUIRED_API_LEVELS: Dict[str, int] = {
+    STATS_SERVICE: 2,
+    COMPOSE_SERVICE: 2,
+}
+
+_lock = threading.Lock()
+_components: Dict[str, Dict[str, Any]] = {}
+
+
+def _api_level(health: Dict[str, Any]) -> int:
+    level = health.get("api_level")
+    if isinstance(level, bool) or not isinstance(level, int):
+        return 0
+    return level
+
+
+def check_component(service: str, health: Optional[Dict[str, Any]]) -> Optional[str]:
+    """
+    Compare a service's /health response against the compatibility matrix.
+
+    Returns:
+        A warning naming the service to update, or None if it's new enough
+    """
+    health = health if isinstance(health, dict) else {}
+    required = REQUIRED_API_LEVELS.get(service, 0)
+    level = _api_level(health)
+    if level >= required:
+        return None
+    version = health.get("version") or "unknown version"
+    return (
+        f"{service} {version} (API level {level}) is older than this DockMon "
+        f"backend requires (API level {required}); please update {service}"
+    )
+
+
+def record_component(service: str, health: Optional[Dict[str, Any]]) -> Optional[str]:
+    """
+    Remember the version a service reported in /health, logging once each
+    time it changes.
+
+    Returns:
+        The skew warning from check_component(), if any
+    """
+    warning = check_component(service, health)
+    health = health if isinstance(health, dict) else {}
+    entry: Dict[str, Any] = {
+        "version": health.get("version") or None,
+        "api_level": _api_level(health),
+        "required_api_level": REQUIRED_API_LEVELS.get(service, 0),
+        "compatible": warning is None,
+    }
+    if warning:
+        entry["warning"] = warning
+
+    with _lock:
+        previous = _components.get(service)
+        _components[service] = entry
+
+    if previous != entry:
+        if warning:
+            logger.warning(warning)
+        else:
+            logger.info(f"{service} {entry['version']} (API level {entry['api_level']}) is compatible")
+    return warning
+
+
+def get_components() -> Dict[str, Dict[str, Any]]:
+    """Versions of the Go services seen so far, keyed by service"""
+    with _lock:
+        return {service: dict(entry) for service, entry in _components.items()}
+
+
+def not_found_hint(service: str) -> str:
+    """
+    Explain a 404 from a service known to be outdated.
+
+    Returns:
+        " (<skew warning>)" to append to the error, or "" when the service
+        isn't known to be outdated
+    """
+    with _lock:
+        entry = _components.get(service)
+    if entry and not entry["compatible"]:
+        return f" ({entry['warning']})"
+    return ""
+
+
+def reset() -> None:
+    """Forget recorded versions (tests)"""
+    with _lock:
+        _components.clear()
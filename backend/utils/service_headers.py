@@ -0,0 +1,53 @@
+"""
+Request headers the backend sends to the Go compose service.
+
+The compose service has no database of its own, so the context it records
+travels in headers: X-DockMon-Host is the DockMon host a request is for
+(updates, deployments and image prunes are announced on the activity stream
+under it), X-DockMon-User and X-DockMon-Source say who asked, for the
+project's deployment history.
+"""
+
+from typing import Dict, Optional, Tuple
+
+# Requester sources, as shown in a project's deployment history
+SOURCE_UI = "ui"
+SOURCE_API = "api"
+SOURCE_BATCH = "batch"
+SOURCE_AUTO_UPDATE = "auto_update"
+
+# Longest header value sent; display names are user-controlled
+MAX_HEADER_VALUE = 255
+
+
+def _header_value(value: str) -> str:
+    """Header values must be ASCII: anything else is replaced with '?'"""
+    return value.encode("ascii", "replace").decode("ascii")[:MAX_HEADER_VALUE]
+
+
+def dockmon_headers(
+    host_id: Optional[str] = None,
+    user: Optional[str] = None,
+    source: Optional[str] = None,
+) -> Dict[str, str]:
+    """Headers identifying the host a compose service request is for and who asked for it"""
+    headers = {}
+    if host_id:
+        headers["X-DockMon-Host"] = host_id
+    if user:
+        headers["X-DockMon-User"] = _header_value(user)
+    if source:
+        headers["X-DockMon-Source"] = source
+    return headers
+
+
+def requester_of(current_user: dict) -> Tuple[str, str]:
+    """
+    The (user, source) of an API request: the user's display name, and
+    whether they came through an API key or a UI session.
+    """
+    from auth.utils import get_auditable_user_info
+
+    _, display_name = get_auditable_user_info(current_user)
+    source = SOURCE_API if current_user.get("auth_type") == "api_key" else SOURCE_UI
+    return display_name, source
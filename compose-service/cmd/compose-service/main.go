@@ -4,13 +4,22 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/darthnorse/dockmon-shared/debugserver"
+	"github.com/dockmon/compose-service/internal/envsets"
+	"github.com/dockmon/compose-service/internal/metrics"
 	"github.com/dockmon/compose-service/internal/server"
 	"github.com/sirupsen/logrus"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
 	// Initialize logger
 	log := logrus.New()
@@ -36,13 +45,90 @@ func main() {
 		socketPath = "/tmp/compose.sock"
 	}
 
+	// Optional directory for persisting async deployment jobs across restarts
+	jobsDir := os.Getenv("COMPOSE_JOBS_DIR")
+
+	// Optional directory for persisting per-project deployment history
+	historyDir := os.Getenv("COMPOSE_HISTORY_DIR")
+
+	// Optional directory of encrypted variable sets that deployments
+	// reference by name. Their key file must live outside the directory and
+	// is generated on first use.
+	envSetsDir := os.Getenv("COMPOSE_ENV_SETS_DIR")
+	var envSets *envsets.Store
+	if envSetsDir != "" {
+		if envSets, err = envsets.NewStore(envSetsDir, os.Getenv("COMPOSE_ENV_SETS_KEY_FILE")); err != nil {
+			log.WithError(err).Warn("Invalid COMPOSE_ENV_SETS_* settings, variable sets disabled")
+			envSets = nil
+		}
+	}
+
+	// Maximum simultaneous deployments (0 = unlimited)
+	maxConcurrent := server.DefaultMaxConcurrentDeployments
+	if v := os.Getenv("COMPOSE_MAX_CONCURRENT_DEPLOYMENTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxConcurrent = n
+		} else {
+			log.WithField("value", v).Warn("Invalid COMPOSE_MAX_CONCURRENT_DEPLOYMENTS, using default")
+		}
+	}
+
+	// Default host quota for deployments that don't send one (unset = no check)
+	quota, err := compose.ParseHostQuota(
+		os.Getenv("COMPOSE_QUOTA_MAX_MEMORY"),
+		os.Getenv("COMPOSE_QUOTA_MAX_CONTAINERS"),
+		os.Getenv("COMPOSE_QUOTA_MODE"),
+	)
+	if err != nil {
+		log.WithError(err).Warn("Invalid COMPOSE_QUOTA_* settings, host quota disabled")
+		quota = nil
+	}
+
+	// Activity events (updates, deployments) are posted to the stats-service
+	// event stream; an empty COMPOSE_STATS_SERVICE_URL turns them off
+	statsServiceURL, ok := os.LookupEnv("COMPOSE_STATS_SERVICE_URL")
+	if !ok {
+		statsServiceURL = "http://localhost:8081"
+	}
+	statsTokenFile := os.Getenv("COMPOSE_STATS_SERVICE_TOKEN_FILE")
+	if statsTokenFile == "" {
+		statsTokenFile = "/app/data/stats-service-token"
+	}
+
 	log.WithFields(logrus.Fields{
-		"socket":    socketPath,
-		"log_level": logLevel.String(),
+		"version":        version,
+		"api_level":      server.APILevel,
+		"socket":         socketPath,
+		"log_level":      logLevel.String(),
+		"jobs_dir":       jobsDir,
+		"history_dir":    historyDir,
+		"env_sets":       envSets != nil,
+		"max_concurrent": maxConcurrent,
+		"quota":          quota != nil,
+		"activity":       statsServiceURL != "",
 	}).Info("Compose service starting")
 
 	// Create server
-	srv := server.NewServer(socketPath, log)
+	srv := server.NewServer(socketPath, jobsDir, historyDir, maxConcurrent, quota, log)
+	srv.SetVersion(version)
+	srv.SetEnvSets(envSets)
+	srv.SetConfig(map[string]interface{}{
+		"socket_path":              socketPath,
+		"initial_log_level":        logLevel.String(),
+		"jobs_dir":                 jobsDir,
+		"history_dir":              historyDir,
+		"env_sets_dir":             envSetsDir,
+		"env_sets_key_file":        os.Getenv("COMPOSE_ENV_SETS_KEY_FILE"),
+		"max_concurrent":           maxConcurrent,
+		"quota":                    quota,
+		"stats_service_url":        statsServiceURL,
+		"stats_service_token_file": statsTokenFile,
+		"pprof_addr":               os.Getenv("COMPOSE_PPROF_ADDR"),
+		"pprof_allow_remote":       os.Getenv("PPROF_ALLOW_REMOTE"),
+	})
+	if statsServiceURL != "" {
+		srv.SetActivityPublisher(activity.NewPublisher(statsServiceURL, statsTokenFile, "compose", log))
+	}
 
 	// Context with cancellation for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -58,6 +144,19 @@ func main() {
 		cancel()
 	}()
 
+	// Optional pprof/expvar listener for profiling leaks on large installs
+	if pprofAddr := os.Getenv("COMPOSE_PPROF_ADDR"); pprofAddr != "" {
+		allowRemote, _ := strconv.ParseBool(os.Getenv("PPROF_ALLOW_REMOTE"))
+		debugserver.Publish("compose", func() interface{} {
+			return metrics.Global.Snapshot()
+		})
+		if addr, err := debugserver.Start(ctx, pprofAddr, allowRemote); err != nil {
+			log.WithError(err).Warn("Profiling endpoints disabled")
+		} else {
+			log.WithField("addr", addr.String()).Info("Profiling endpoints (pprof, expvar) listening")
+		}
+	}
+
 	// Start server
 	if err := srv.Start(ctx); err != nil {
 		log.WithError(err).Fatal("Server failed")
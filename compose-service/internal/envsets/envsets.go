@@ -0,0 +1,311 @@
+// Package envsets keeps named variable sets that deployments reference by
+// name (env_set), so credentials shared by several stacks are stored once
+// instead of being sent with every deploy. Each set is a file encrypted
+// with AES-256-GCM.
+package envsets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// setFileExt is the extension of encrypted set files
+	setFileExt = ".envset"
+	// MaxSetBytes bounds a set's variables (names and values)
+	MaxSetBytes = 1 << 20
+)
+
+var (
+	// setNamePattern matches set names, which are also file names
+	setNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+	// varNamePattern matches variable names compose can interpolate
+	varNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+var (
+	ErrNotFound = errors.New("variable set not found")
+	// ErrDisabled is returned by a nil Store
+	ErrDisabled = errors.New("variable sets are not configured (set COMPOSE_ENV_SETS_DIR and COMPOSE_ENV_SETS_KEY_FILE)")
+)
+
+// Set is a named group of variables
+type Set struct {
+	Name      string            `json:"name"`
+	Variables map[string]string `json:"variables"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Summary describes a set without its values
+type Summary struct {
+	Name      string    `json:"name"`
+	Variables []string  `json:"variables"` // Names only, sorted
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store reads and writes sets in a directory. All methods are safe to call
+// on a nil *Store (variable sets not configured) and return ErrDisabled.
+type Store struct {
+	mu   sync.Mutex
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewStore opens the sets in dir, encrypted with the base64 AES-256 key in
+// keyFile, which is generated if it doesn't exist. The key file is required
+// and must be outside dir, so a copy of the sets (e.g. a volume backup)
+// doesn't carry the key that decrypts them; keep it on another volume.
+func NewStore(dir, keyFile string) (*Store, error) {
+	if keyFile == "" {
+		return nil, errors.New("a variable sets key file is required")
+	}
+	if inside, err := isWithin(dir, keyFile); err != nil {
+		return nil, err
+	} else if inside {
+		return nil, fmt.Errorf("variable sets key file %s must be outside the sets directory %s", keyFile, dir)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create variable sets directory: %w", err)
+	}
+	key, err := loadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid variable sets key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, aead: aead}, nil
+}
+
+// loadKey reads a base64 32-byte key, creating a random one if the file
+// doesn't exist
+func loadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- configured key file
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create variable sets key directory: %w", err)
+		}
+		if err := writeFile(path, []byte(encoded)); err != nil {
+			return nil, fmt.Errorf("failed to write variable sets key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read variable sets key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("variable sets key in %s must be 32 bytes, base64 encoded", path)
+	}
+	return key, nil
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) (bool, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, nil
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))), nil
+}
+
+// ValidateName checks a set name
+func ValidateName(name string) error {
+	if !setNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable set name: %q", name)
+	}
+	return nil
+}
+
+// Get returns a set's variables
+func (s *Store) Get(name string) (map[string]string, error) {
+	if s == nil {
+		return nil, ErrDisabled
+	}
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, err := s.read(name)
+	if err != nil {
+		return nil, err
+	}
+	if set.Variables == nil {
+		set.Variables = map[string]string{}
+	}
+	return set.Variables, nil
+}
+
+// Put creates or replaces a set
+func (s *Store) Put(name string, variables map[string]string) (Summary, error) {
+	if s == nil {
+		return Summary{}, ErrDisabled
+	}
+	if err := ValidateName(name); err != nil {
+		return Summary{}, err
+	}
+	size := 0
+	for key, value := range variables {
+		if !varNamePattern.MatchString(key) {
+			return Summary{}, fmt.Errorf("invalid variable name: %q", key)
+		}
+		size += len(key) + len(value)
+	}
+	if size > MaxSetBytes {
+		return Summary{}, fmt.Errorf("variable set is larger than %d bytes", MaxSetBytes)
+	}
+
+	set := Set{Name: name, Variables: variables, UpdatedAt: time.Now().UTC()}
+	plaintext, err := json.Marshal(set)
+	if err != nil {
+		return Summary{}, err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Summary{}, err
+	}
+	// The name is authenticated so a file can't be passed off as another set
+	sealed := s.aead.Seal(nonce, nonce, plaintext, []byte(name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(name)
+	tmp := path + ".tmp"
+	_ = os.Remove(tmp)
+	if err := writeFile(tmp, sealed); err != nil {
+		return Summary{}, fmt.Errorf("failed to write variable set: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return Summary{}, fmt.Errorf("failed to write variable set: %w", err)
+	}
+	return summarize(set), nil
+}
+
+// Delete removes a set
+func (s *Store) Delete(name string) error {
+	if s == nil {
+		return ErrDisabled
+	}
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(name)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// List describes every set, sorted by name. Sets that can't be decrypted
+// (e.g. written with another key) are reported as errors.
+func (s *Store) List() ([]Summary, error) {
+	if s == nil {
+		return nil, ErrDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []Summary{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), setFileExt)
+		if !ok || entry.IsDir() || ValidateName(name) != nil {
+			continue
+		}
+		set, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summarize(*set))
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries, nil
+}
+
+// read decrypts a set; must hold mu
+func (s *Store) read(name string) (*Set, error) {
+	sealed, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("variable set %s is corrupt", name)
+	}
+	plaintext, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("variable set %s can't be decrypted with the configured key", name)
+	}
+	var set Set
+	if err := json.Unmarshal(plaintext, &set); err != nil {
+		return nil, fmt.Errorf("variable set %s is corrupt: %w", name, err)
+	}
+	return &set, nil
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+setFileExt)
+}
+
+func summarize(set Set) Summary {
+	names := make([]string, 0, len(set.Variables))
+	for name := range set.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return Summary{Name: set.Name, Variables: names, UpdatedAt: set.UpdatedAt}
+}
+
+// writeFile creates path with mode 0600, failing if it exists
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600) // #nosec G304 -- path is inside the sets directory
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,157 @@
+package envsets
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T) (*Store, string, string) {
+	t.Helper()
+	root := t.TempDir()
+	dir, keyFile := filepath.Join(root, "sets"), filepath.Join(root, "keys", "envsets.key")
+	s, err := NewStore(dir, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir, keyFile
+}
+
+func TestStore_RoundTrip(t *testing.T) {
+	s, dir, _ := newTestStore(t)
+
+	summary, err := s.Put("prod-db", map[string]string{"DB_USER": "app", "DB_PASSWORD": "hunter2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Name != "prod-db" || strings.Join(summary.Variables, ",") != "DB_PASSWORD,DB_USER" {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	vars, err := s.Get("prod-db")
+	if err != nil || vars["DB_PASSWORD"] != "hunter2" || vars["DB_USER"] != "app" {
+		t.Fatalf("Get = %v, %v", vars, err)
+	}
+
+	// Values are encrypted on disk
+	data, err := os.ReadFile(filepath.Join(dir, "prod-db"+setFileExt))
+	if err != nil || strings.Contains(string(data), "hunter2") {
+		t.Errorf("set file holds the plaintext value (err %v)", err)
+	}
+
+	list, err := s.List()
+	if err != nil || len(list) != 1 || list[0].Name != "prod-db" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+
+	if err := s.Delete("prod-db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("prod-db"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("prod-db"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete: %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Validation(t *testing.T) {
+	s, _, _ := newTestStore(t)
+
+	if _, err := s.Put("../etc", map[string]string{"A": "1"}); err == nil {
+		t.Error("accepted a path as set name")
+	}
+	if _, err := s.Put("ok", map[string]string{"not-a-var": "1"}); err == nil {
+		t.Error("accepted an invalid variable name")
+	}
+	if _, err := s.Put("big", map[string]string{"A": strings.Repeat("x", MaxSetBytes)}); err == nil {
+		t.Error("accepted a set over MaxSetBytes")
+	}
+}
+
+func TestStore_WrongKey(t *testing.T) {
+	s, dir, _ := newTestStore(t)
+	if _, err := s.Put("prod-db", map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewStore(dir, filepath.Join(t.TempDir(), "other.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get("prod-db"); err == nil || !strings.Contains(err.Error(), "can't be decrypted") {
+		t.Errorf("Get with another key: %v, want a decryption error", err)
+	}
+	if _, err := other.List(); err == nil {
+		t.Error("List with another key should fail")
+	}
+}
+
+func TestStore_NameBoundToCiphertext(t *testing.T) {
+	s, dir, _ := newTestStore(t)
+	if _, err := s.Put("staging", map[string]string{"A": "staging"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A set file copied under another name doesn't decrypt as that set
+	data, err := os.ReadFile(filepath.Join(dir, "staging"+setFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prod"+setFileExt), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("prod"); err == nil {
+		t.Error("a set was accepted under another name")
+	}
+}
+
+func TestNewStore_Key(t *testing.T) {
+	s, dir, keyFile := newTestStore(t)
+	if _, err := s.Put("prod-db", map[string]string{"A": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated key is a private, base64 AES-256 key that is reused
+	info, err := os.Stat(keyFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("key file: %v, %v", info, err)
+	}
+	data, _ := os.ReadFile(keyFile)
+	if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err != nil || len(key) != 32 {
+		t.Errorf("key file holds %q", data)
+	}
+	reopened, err := NewStore(dir, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars, err := reopened.Get("prod-db"); err != nil || vars["A"] != "1" {
+		t.Errorf("Get after reopening: %v, %v", vars, err)
+	}
+
+	// The key must be configured and kept away from the sets
+	if _, err := NewStore(dir, ""); err == nil {
+		t.Error("accepted a missing key file")
+	}
+	if _, err := NewStore(dir, filepath.Join(dir, ".key")); err == nil {
+		t.Error("accepted a key file inside the sets directory")
+	}
+	bad := filepath.Join(t.TempDir(), "bad.key")
+	os.WriteFile(bad, []byte("c2hvcnQ=\n"), 0600)
+	if _, err := NewStore(dir, bad); err == nil {
+		t.Error("accepted a key that isn't 32 bytes")
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	if _, err := s.Get("x"); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Get: %v", err)
+	}
+	if _, err := s.List(); !errors.Is(err, ErrDisabled) {
+		t.Errorf("List: %v", err)
+	}
+}
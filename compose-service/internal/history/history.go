@@ -0,0 +1,254 @@
+// Package history keeps a per-project record of deploy, down and update
+// requests and their outcomes, so the Stacks view can show a project's
+// recent deployments.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Entry statuses
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// MaxEntriesPerProject is how many entries are kept for each project; the
+// oldest are dropped first.
+const MaxEntriesPerProject = 50
+
+// projectNamePattern matches compose project names, which are also used
+// as file names
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Requester describes who asked for the operation, as reported by the
+// caller in the X-DockMon-User and X-DockMon-Source headers.
+type Requester struct {
+	User   string `json:"user,omitempty"`
+	Source string `json:"source,omitempty"` // e.g. "ui", "api", "auto_update"
+}
+
+// Entry is one request against a project. Credentials and compose content
+// are never stored; ComposeHash identifies the content that was deployed.
+type Entry struct {
+	DeploymentID string     `json:"deployment_id"`
+	ProjectName  string     `json:"project_name"`
+	Action       string     `json:"action"` // up, down, restart, rollback or update
+	ComposeHash  string     `json:"compose_hash,omitempty"`
+	Targets      []string   `json:"targets,omitempty"`      // Multi-target deployments
+	ContainerID  string     `json:"container_id,omitempty"` // Container updates
+	Image        string     `json:"image,omitempty"`        // Container updates
+	Requester    Requester  `json:"requester"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	DurationMS   int64      `json:"duration_ms,omitempty"`
+}
+
+// HashCompose returns the SHA-256 of compose content, hex encoded
+func HashCompose(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store holds each project's recent entries in memory and, when a directory
+// is configured, mirrors them to one file per project.
+type Store struct {
+	mu       sync.Mutex
+	projects map[string][]*Entry // Oldest first
+	dir      string
+	log      *logrus.Logger
+}
+
+// NewStore creates a history store. If dir is non-empty, history is
+// persisted there and loaded back. Entries still running when the service
+// stopped are marked failed, since nothing will finish them.
+func NewStore(dir string, log *logrus.Logger) (*Store, error) {
+	s := &Store{
+		projects: make(map[string][]*Entry),
+		dir:      dir,
+		log:      log,
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Start records a running entry. StartedAt and Status are set by the store.
+func (s *Store) Start(entry Entry) {
+	entry.StartedAt = time.Now().UTC()
+	entry.Status = StatusRunning
+	entry.FinishedAt = nil
+
+	s.mu.Lock()
+	list := append(s.projects[entry.ProjectName], &entry)
+	if len(list) > MaxEntriesPerProject {
+		list = list[len(list)-MaxEntriesPerProject:]
+	}
+	s.projects[entry.ProjectName] = list
+	snapshot := copyEntries(list)
+	s.mu.Unlock()
+
+	s.persist(entry.ProjectName, snapshot)
+}
+
+// Finish records the outcome of the project's latest running entry with the
+// deployment ID. It is a no-op if there is none, so a fallback call after
+// the outcome was already recorded is harmless.
+func (s *Store) Finish(projectName, deploymentID, status, errMsg string) {
+	s.mu.Lock()
+	list := s.projects[projectName]
+	var entry *Entry
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].DeploymentID == deploymentID && list[i].Status == StatusRunning {
+			entry = list[i]
+			break
+		}
+	}
+	if entry == nil {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now().UTC()
+	entry.Status = status
+	entry.Error = errMsg
+	entry.FinishedAt = &now
+	entry.DurationMS = now.Sub(entry.StartedAt).Milliseconds()
+	snapshot := copyEntries(list)
+	s.mu.Unlock()
+
+	s.persist(projectName, snapshot)
+}
+
+// List returns up to limit of the project's entries, newest first
+// (limit <= 0 returns all).
+func (s *Store) List(projectName string, limit int) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.projects[projectName]
+	if limit <= 0 || limit > len(list) {
+		limit = len(list)
+	}
+	result := make([]Entry, 0, limit)
+	for i := len(list) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, *list[i])
+	}
+	return result
+}
+
+func copyEntries(list []*Entry) []Entry {
+	c := make([]Entry, len(list))
+	for i, e := range list {
+		c[i] = *e
+	}
+	return c
+}
+
+// persist writes a project's entries to disk (temp file + rename). Projects
+// whose names aren't safe file names are kept in memory only.
+func (s *Store) persist(projectName string, entries []Entry) {
+	if s.dir == "" || !projectNamePattern.MatchString(projectName) {
+		return
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to encode project history")
+		return
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".history-*.tmp")
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to persist project history")
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		s.log.WithField("project_name", projectName).Warn("Failed to persist project history")
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, projectName+".json")); err != nil {
+		os.Remove(tmp.Name())
+		s.log.WithError(err).WithField("project_name", projectName).Warn("Failed to persist project history")
+	}
+}
+
+// load restores persisted history from disk.
+func (s *Store) load() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		projectName := strings.TrimSuffix(name, ".json")
+		if file.IsDir() || projectName == name || !projectNamePattern.MatchString(projectName) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			continue
+		}
+		var entries []Entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			s.log.WithField("file", name).Warn("Skipping unreadable history file")
+			continue
+		}
+
+		interrupted := false
+		list := make([]*Entry, 0, len(entries))
+		for i := range entries {
+			e := &entries[i]
+			if e.Status == StatusRunning {
+				now := time.Now().UTC()
+				e.Status = StatusFailed
+				e.Error = "interrupted by compose-service restart"
+				e.FinishedAt = &now
+				interrupted = true
+			}
+			list = append(list, e)
+		}
+		if len(list) > MaxEntriesPerProject {
+			list = list[len(list)-MaxEntriesPerProject:]
+		}
+		s.projects[projectName] = list
+		if interrupted {
+			s.persist(projectName, copyEntries(list))
+		}
+	}
+
+	s.log.WithField("projects", len(s.projects)).Info("Loaded deployment history")
+	return nil
+}
@@ -0,0 +1,124 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := NewStore(dir, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore_StartFinishList(t *testing.T) {
+	s := newTestStore(t, "")
+	s.Start(Entry{DeploymentID: "d1", ProjectName: "web", Action: "up", Requester: Requester{User: "admin", Source: "ui"}})
+	s.Start(Entry{DeploymentID: "d2", ProjectName: "web", Action: "down"})
+	s.Finish("web", "d1", StatusSucceeded, "")
+	s.Finish("web", "d2", StatusFailed, "network in use")
+
+	list := s.List("web", 0)
+	if len(list) != 2 || list[0].DeploymentID != "d2" || list[1].DeploymentID != "d1" {
+		t.Fatalf("List = %+v, want d2 then d1", list)
+	}
+	if list[0].Status != StatusFailed || list[0].Error != "network in use" || list[0].FinishedAt == nil {
+		t.Errorf("d2 = %+v, want failed with its error", list[0])
+	}
+	if list[1].Status != StatusSucceeded || list[1].Requester.User != "admin" || list[1].Requester.Source != "ui" {
+		t.Errorf("d1 = %+v, want succeeded with its requester", list[1])
+	}
+	if got := s.List("web", 1); len(got) != 1 || got[0].DeploymentID != "d2" {
+		t.Errorf("List(limit 1) = %+v, want only the newest", got)
+	}
+	if got := s.List("other", 0); len(got) != 0 {
+		t.Errorf("List(other) = %+v, want none", got)
+	}
+}
+
+func TestStore_FinishOnlyRunning(t *testing.T) {
+	s := newTestStore(t, "")
+	s.Start(Entry{DeploymentID: "d1", ProjectName: "web"})
+	s.Finish("web", "d1", StatusSucceeded, "")
+	// A fallback call after the outcome was recorded must not overwrite it
+	s.Finish("web", "d1", StatusFailed, "deployment ended without a result")
+	s.Finish("web", "unknown", StatusFailed, "")
+
+	list := s.List("web", 0)
+	if len(list) != 1 || list[0].Status != StatusSucceeded || list[0].Error != "" {
+		t.Errorf("List = %+v, want the first outcome kept", list)
+	}
+}
+
+func TestStore_TrimsOldestEntries(t *testing.T) {
+	s := newTestStore(t, "")
+	for i := 0; i < MaxEntriesPerProject+5; i++ {
+		s.Start(Entry{DeploymentID: fmt.Sprintf("d%d", i), ProjectName: "web"})
+	}
+
+	list := s.List("web", 0)
+	if len(list) != MaxEntriesPerProject {
+		t.Fatalf("kept %d entries, want %d", len(list), MaxEntriesPerProject)
+	}
+	if list[0].DeploymentID != fmt.Sprintf("d%d", MaxEntriesPerProject+4) || list[len(list)-1].DeploymentID != "d5" {
+		t.Errorf("kept %s..%s, want the newest %d", list[len(list)-1].DeploymentID, list[0].DeploymentID, MaxEntriesPerProject)
+	}
+}
+
+func TestStore_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStore(t, dir)
+	s.Start(Entry{DeploymentID: "d1", ProjectName: "web", ComposeHash: HashCompose("services: {}")})
+	s.Finish("web", "d1", StatusSucceeded, "")
+	s.Start(Entry{DeploymentID: "d2", ProjectName: "web"})
+	// Names that aren't safe file names stay in memory
+	s.Start(Entry{DeploymentID: "d3", ProjectName: "../etc"})
+
+	if _, err := os.Stat(filepath.Join(dir, "web.json")); err != nil {
+		t.Fatalf("history not persisted: %v", err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("files = %v, want only web.json", files)
+	}
+
+	// A restart marks the entry that was still running as failed
+	reloaded := newTestStore(t, dir)
+	list := reloaded.List("web", 0)
+	if len(list) != 2 {
+		t.Fatalf("reloaded %+v, want 2 entries", list)
+	}
+	if list[0].DeploymentID != "d2" || list[0].Status != StatusFailed || list[0].Error == "" || list[0].FinishedAt == nil {
+		t.Errorf("d2 = %+v, want failed as interrupted", list[0])
+	}
+	if list[1].Status != StatusSucceeded || list[1].ComposeHash != HashCompose("services: {}") {
+		t.Errorf("d1 = %+v, want it unchanged", list[1])
+	}
+
+	// The interruption is written back so it isn't re-derived on every start
+	data, err := os.ReadFile(filepath.Join(dir, "web.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onDisk []Entry
+	if err := json.Unmarshal(data, &onDisk); err != nil || onDisk[1].Status != StatusFailed {
+		t.Errorf("persisted %s, want d2 failed", data)
+	}
+}
+
+func TestHashCompose(t *testing.T) {
+	if HashCompose("") != "" {
+		t.Error("empty content should have no hash")
+	}
+	if h := HashCompose("services: {}"); len(h) != 64 || h != HashCompose("services: {}") || h == HashCompose("services: {web: {}}") {
+		t.Errorf("HashCompose = %q", h)
+	}
+}
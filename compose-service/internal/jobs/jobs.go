@@ -0,0 +1,358 @@
+// Package jobs tracks asynchronous deployments so callers can poll for
+// status or stream progress without holding an HTTP connection open for the
+// whole deployment.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/sirupsen/logrus"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Event types (match the SSE event names of the synchronous /deploy stream)
+const (
+	EventProgress = "progress"
+	EventComplete = "complete"
+)
+
+const (
+	// maxEventsPerJob bounds memory for chatty deployments. Once reached,
+	// further progress events are dropped; the complete event is always kept.
+	maxEventsPerJob = 1000
+	// finishedJobTTL is how long finished jobs remain retrievable.
+	finishedJobTTL = 24 * time.Hour
+	// maxJobs caps the store; the oldest finished jobs are pruned first.
+	maxJobs = 500
+	// interruptedError is the error of jobs that were running at a restart.
+	interruptedError = "interrupted by compose-service restart"
+)
+
+// Event is a single progress or completion event of a job. Seq is 1-based and
+// doubles as the SSE event id for resuming a stream.
+type Event struct {
+	Seq  int             `json:"seq"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Job is the externally visible state of an asynchronous deployment.
+// Deploy credentials are never stored; only progress and results are.
+type Job struct {
+	ID           string          `json:"job_id"`
+	DeploymentID string          `json:"deployment_id"`
+	ProjectName  string          `json:"project_name"`
+	Status       string          `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Events       []Event         `json:"events,omitempty"`
+
+	// changed is closed and replaced whenever the job changes, waking
+	// any streaming readers.
+	changed chan struct{}
+}
+
+// Finished reports whether the job has reached a terminal status.
+func (j *Job) Finished() bool {
+	return j.Status == StatusCompleted || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// Store holds jobs in memory and, when a directory is configured, mirrors
+// each job to disk so a restart doesn't lose deployment status.
+type Store struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+	dir  string
+	log  *logrus.Logger
+}
+
+// NewStore creates a job store. If dir is non-empty, jobs are persisted there
+// and previously persisted jobs are loaded. Jobs that were still running when
+// the service stopped are marked failed, since nothing will finish them.
+func NewStore(dir string, log *logrus.Logger) (*Store, error) {
+	s := &Store{
+		jobs: make(map[string]*Job),
+		dir:  dir,
+		log:  log,
+	}
+
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create registers a new queued job.
+func (s *Store) Create(deploymentID, projectName string) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	job := &Job{
+		ID:           id,
+		DeploymentID: deploymentID,
+		ProjectName:  projectName,
+		Status:       StatusQueued,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		changed:      make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.pruneLocked()
+	s.jobs[id] = job
+	snapshot := job.copyLocked()
+	s.mu.Unlock()
+
+	s.persist(snapshot)
+	return snapshot, nil
+}
+
+// SetRunning marks a job as running.
+func (s *Store) SetRunning(id string) {
+	s.update(id, func(job *Job) bool {
+		job.Status = StatusRunning
+		return true
+	})
+}
+
+// AddProgress appends a progress event. Progress is kept in memory only and
+// written to disk with the final result.
+func (s *Store) AddProgress(id string, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || len(job.Events) >= maxEventsPerJob {
+		return
+	}
+	job.appendEventLocked(EventProgress, data)
+}
+
+// Finish records the job's result and terminal status (StatusCompleted,
+// StatusFailed or StatusCancelled).
+func (s *Store) Finish(id, status string, result json.RawMessage, errMsg string) {
+	s.update(id, func(job *Job) bool {
+		job.Result = result
+		job.Error = errMsg
+		job.Status = status
+		job.appendEventLocked(EventComplete, result)
+		return true
+	})
+}
+
+// Get returns a snapshot of the job.
+func (s *Store) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.copyLocked(), true
+}
+
+// EventsAfter returns the job's events with Seq > after, whether the job is
+// finished, and a channel that is closed on the next change.
+func (s *Store) EventsAfter(id string, after int) (events []Event, finished bool, changed <-chan struct{}, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return nil, false, nil, false
+	}
+	for _, e := range job.Events {
+		if e.Seq > after {
+			events = append(events, e)
+		}
+	}
+	return events, job.Finished(), job.changed, true
+}
+
+// update applies fn to a job under lock and persists it if fn reports a change.
+func (s *Store) update(id string, fn func(job *Job) bool) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok || !fn(job) {
+		s.mu.Unlock()
+		return
+	}
+	job.touchLocked()
+	snapshot := job.copyLocked()
+	s.mu.Unlock()
+
+	s.persist(snapshot)
+}
+
+func (j *Job) appendEventLocked(eventType string, data json.RawMessage) {
+	j.Events = append(j.Events, Event{Seq: len(j.Events) + 1, Type: eventType, Data: data})
+	j.touchLocked()
+}
+
+// touchLocked bumps UpdatedAt and wakes streaming readers.
+func (j *Job) touchLocked() {
+	j.UpdatedAt = time.Now().UTC()
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *Job) copyLocked() *Job {
+	c := *j
+	c.Events = append([]Event(nil), j.Events...)
+	c.changed = nil
+	return &c
+}
+
+// pruneLocked removes expired finished jobs and, if the store is still full,
+// the oldest finished ones.
+func (s *Store) pruneLocked() {
+	now := time.Now()
+	var finished []*Job
+	for id, job := range s.jobs {
+		if !job.Finished() {
+			continue
+		}
+		if now.Sub(job.UpdatedAt) > finishedJobTTL {
+			s.removeLocked(id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+
+	if len(s.jobs) < maxJobs {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool { return finished[i].UpdatedAt.Before(finished[k].UpdatedAt) })
+	for _, job := range finished {
+		if len(s.jobs) < maxJobs {
+			break
+		}
+		s.removeLocked(job.ID)
+	}
+}
+
+func (s *Store) removeLocked(id string) {
+	delete(s.jobs, id)
+	if s.dir != "" {
+		os.Remove(s.jobPath(id))
+	}
+}
+
+func (s *Store) jobPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// persist writes a job snapshot to disk (temp file + rename).
+func (s *Store) persist(job *Job) {
+	if s.dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to encode job")
+		return
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".job-*.tmp")
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to persist job")
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		s.log.WithField("job_id", job.ID).Warn("Failed to persist job")
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.jobPath(job.ID)); err != nil {
+		os.Remove(tmp.Name())
+		s.log.WithError(err).WithField("job_id", job.ID).Warn("Failed to persist job")
+	}
+}
+
+// load restores persisted jobs from disk.
+func (s *Store) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read jobs directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID == "" {
+			s.log.WithField("file", name).Warn("Skipping unreadable job file")
+			continue
+		}
+		job.changed = make(chan struct{})
+
+		if !job.Finished() {
+			// End the event stream like any other job, so a resuming
+			// client still gets its complete event
+			result, _ := json.Marshal(compose.DeployResult{
+				DeploymentID: job.DeploymentID,
+				Success:      false,
+				Error:        compose.NewInternalError(interruptedError),
+			})
+			job.Status = StatusFailed
+			job.Error = interruptedError
+			job.Result = result
+			job.appendEventLocked(EventComplete, result)
+			s.persist(job.copyLocked())
+		}
+		s.jobs[job.ID] = &job
+	}
+
+	s.pruneLocked()
+	s.log.WithField("jobs", len(s.jobs)).Info("Loaded persisted deployment jobs")
+	return nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
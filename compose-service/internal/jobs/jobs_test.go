@@ -0,0 +1,193 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/sirupsen/logrus"
+)
+
+func newTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := NewStore(dir, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore_Lifecycle(t *testing.T) {
+	s := newTestStore(t, "")
+	job, err := s.Create("d1", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != StatusQueued || job.DeploymentID != "d1" || job.ProjectName != "web" {
+		t.Fatalf("Create = %+v, want queued d1/web", job)
+	}
+
+	s.SetRunning(job.ID)
+	for i := 0; i < maxEventsPerJob+10; i++ {
+		s.AddProgress(job.ID, json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)))
+	}
+	got, _ := s.Get(job.ID)
+	if got.Status != StatusRunning {
+		t.Errorf("status = %s, want running", got.Status)
+	}
+	if len(got.Events) != maxEventsPerJob {
+		t.Errorf("events = %d, want capped at %d", len(got.Events), maxEventsPerJob)
+	}
+
+	s.Finish(job.ID, StatusFailed, json.RawMessage(`{"success":false}`), "boom")
+	got, _ = s.Get(job.ID)
+	if got.Status != StatusFailed || got.Error != "boom" || string(got.Result) != `{"success":false}` {
+		t.Errorf("finished job = %+v, want failed with its result", got)
+	}
+	last := got.Events[len(got.Events)-1]
+	if len(got.Events) != maxEventsPerJob+1 || last.Type != EventComplete {
+		t.Errorf("events = %d ending in %q, want the complete event kept past the cap", len(got.Events), last.Type)
+	}
+
+	if _, ok := s.Get("unknown"); ok {
+		t.Error("Get(unknown) found a job")
+	}
+}
+
+func TestStore_EventsAfter(t *testing.T) {
+	s := newTestStore(t, "")
+	job, _ := s.Create("d1", "web")
+	s.AddProgress(job.ID, json.RawMessage(`{"n":1}`))
+	s.AddProgress(job.ID, json.RawMessage(`{"n":2}`))
+
+	events, finished, changed, ok := s.EventsAfter(job.ID, 1)
+	if !ok || finished {
+		t.Fatalf("EventsAfter: ok=%v finished=%v, want a running job", ok, finished)
+	}
+	if len(events) != 1 || events[0].Seq != 2 || events[0].Type != EventProgress {
+		t.Errorf("events after 1 = %+v, want only seq 2", events)
+	}
+
+	s.Finish(job.ID, StatusCompleted, json.RawMessage(`{}`), "")
+	select {
+	case <-changed:
+	default:
+		t.Error("changed channel not closed after Finish")
+	}
+
+	events, finished, _, _ = s.EventsAfter(job.ID, 0)
+	if !finished || len(events) != 3 {
+		t.Fatalf("EventsAfter(0) = %d events finished=%v, want 3 and finished", len(events), finished)
+	}
+	for i, e := range events {
+		if e.Seq != i+1 {
+			t.Errorf("event %d seq = %d, want %d", i, e.Seq, i+1)
+		}
+	}
+	if events[2].Type != EventComplete {
+		t.Errorf("last event = %q, want complete", events[2].Type)
+	}
+
+	if _, _, _, ok := s.EventsAfter("unknown", 0); ok {
+		t.Error("EventsAfter(unknown) found a job")
+	}
+}
+
+func TestStore_PruneExpired(t *testing.T) {
+	s := newTestStore(t, "")
+	old, _ := s.Create("d1", "web")
+	s.Finish(old.ID, StatusCompleted, nil, "")
+	running, _ := s.Create("d2", "web")
+
+	s.mu.Lock()
+	s.jobs[old.ID].UpdatedAt = time.Now().Add(-finishedJobTTL - time.Minute)
+	s.jobs[running.ID].UpdatedAt = time.Now().Add(-finishedJobTTL - time.Minute)
+	s.pruneLocked()
+	s.mu.Unlock()
+
+	if _, ok := s.Get(old.ID); ok {
+		t.Error("expired finished job was not pruned")
+	}
+	if _, ok := s.Get(running.ID); !ok {
+		t.Error("unfinished job was pruned")
+	}
+}
+
+func TestStore_PruneOldestWhenFull(t *testing.T) {
+	s := newTestStore(t, "")
+	running, _ := s.Create("running", "web")
+	var oldest string
+	for i := 1; i < maxJobs; i++ {
+		job, _ := s.Create(fmt.Sprintf("d%d", i), "web")
+		s.Finish(job.ID, StatusCompleted, nil, "")
+		if i == 1 {
+			oldest = job.ID
+		}
+	}
+	s.mu.Lock()
+	s.jobs[oldest].UpdatedAt = time.Now().Add(-time.Hour)
+	s.mu.Unlock()
+
+	if _, err := s.Create("new", "web"); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	count := len(s.jobs)
+	s.mu.Unlock()
+	if count != maxJobs {
+		t.Errorf("jobs = %d, want %d", count, maxJobs)
+	}
+	if _, ok := s.Get(oldest); ok {
+		t.Error("oldest finished job was not evicted")
+	}
+	if _, ok := s.Get(running.ID); !ok {
+		t.Error("unfinished job was evicted")
+	}
+}
+
+func TestStore_RestartRecovery(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestStore(t, dir)
+	done, _ := s.Create("d1", "web")
+	s.Finish(done.ID, StatusCompleted, json.RawMessage(`{"success":true}`), "")
+	interrupted, _ := s.Create("d2", "web")
+	s.SetRunning(interrupted.ID)
+	if _, err := os.Stat(filepath.Join(dir, interrupted.ID+".json")); err != nil {
+		t.Fatalf("job not persisted: %v", err)
+	}
+
+	restarted := newTestStore(t, dir)
+	got, ok := restarted.Get(done.ID)
+	if !ok || got.Status != StatusCompleted || len(got.Events) != 1 {
+		t.Errorf("finished job after restart = %+v, want it unchanged", got)
+	}
+
+	got, ok = restarted.Get(interrupted.ID)
+	if !ok {
+		t.Fatal("interrupted job was not loaded")
+	}
+	if got.Status != StatusFailed || got.Error != interruptedError {
+		t.Errorf("interrupted job = %s %q, want failed with %q", got.Status, got.Error, interruptedError)
+	}
+	events, finished, _, _ := restarted.EventsAfter(interrupted.ID, 0)
+	if !finished || len(events) != 1 || events[0].Type != EventComplete {
+		t.Fatalf("events = %+v finished=%v, want a single complete event", events, finished)
+	}
+	var result compose.DeployResult
+	if err := json.Unmarshal(events[0].Data, &result); err != nil {
+		t.Fatalf("complete event data: %v", err)
+	}
+	if result.DeploymentID != "d2" || result.Success || result.Error == nil || result.Error.Message != interruptedError {
+		t.Errorf("complete event result = %+v, want a failed d2 result", result)
+	}
+
+	// The recovered state is persisted, so a second restart sees the same
+	again := newTestStore(t, dir)
+	if got, _ := again.Get(interrupted.ID); got == nil || len(got.Events) != 1 {
+		t.Errorf("interrupted job after second restart = %+v, want one complete event", got)
+	}
+}
@@ -17,6 +17,11 @@ type Metrics struct {
 	PartialDeploys    int64
 	ActiveDeployments int32
 
+	// Concurrency limits (see server.deployLimiter)
+	MaxConcurrentDeploys int32
+	ProjectConflicts     int64 // Rejected: same project already deploying (409)
+	ThrottledDeploys     int64 // Rejected: global concurrency limit reached (429)
+
 	// Update metrics (container updates via /update endpoint)
 	TotalUpdates     int64
 	SuccessfulUpdates int64
@@ -52,6 +57,22 @@ func (m *Metrics) DecrementActiveUpdates() {
 	atomic.AddInt32(&m.ActiveUpdates, -1)
 }
 
+// SetMaxConcurrentDeployments records the configured deployment limit
+func (m *Metrics) SetMaxConcurrentDeployments(n int) {
+	atomic.StoreInt32(&m.MaxConcurrentDeploys, int32(n))
+}
+
+// RecordProjectConflict records a deployment rejected because its project
+// was already deploying
+func (m *Metrics) RecordProjectConflict() {
+	atomic.AddInt64(&m.ProjectConflicts, 1)
+}
+
+// RecordDeploymentThrottled records a deployment rejected by the global limit
+func (m *Metrics) RecordDeploymentThrottled() {
+	atomic.AddInt64(&m.ThrottledDeploys, 1)
+}
+
 // RecordUpdate records a completed container update
 func (m *Metrics) RecordUpdate(success bool) {
 	m.mu.Lock()
@@ -108,6 +129,10 @@ func (m *Metrics) Snapshot() map[string]interface{} {
 		"partial":              m.PartialDeploys,
 		"active":               atomic.LoadInt32(&m.ActiveDeployments),
 		"avg_duration_seconds": avgDuration,
+		// Concurrency limits
+		"max_concurrent":    atomic.LoadInt32(&m.MaxConcurrentDeploys),
+		"project_conflicts": atomic.LoadInt64(&m.ProjectConflicts),
+		"throttled":         atomic.LoadInt64(&m.ThrottledDeploys),
 		// Update metrics
 		"total_updates":      m.TotalUpdates,
 		"successful_updates": m.SuccessfulUpdates,
@@ -126,6 +151,8 @@ func (m *Metrics) Reset() {
 	m.FailedDeploys = 0
 	m.PartialDeploys = 0
 	atomic.StoreInt32(&m.ActiveDeployments, 0)
+	atomic.StoreInt64(&m.ProjectConflicts, 0)
+	atomic.StoreInt64(&m.ThrottledDeploys, 0)
 	m.TotalUpdates = 0
 	m.SuccessfulUpdates = 0
 	m.FailedUpdates = 0
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/darthnorse/dockmon-shared/update"
+)
+
+// hostIDFrom reads the DockMon host ID the backend sends in X-DockMon-Host.
+// Activity events are only published for requests that carry it.
+func hostIDFrom(r *http.Request) string {
+	return r.Header.Get("X-DockMon-Host")
+}
+
+// publishUpdateStarted announces a container update on the activity stream
+func (s *Server) publishUpdateStarted(hostID string, req UpdateHTTPRequest) {
+	s.activity.Publish(activity.Event{
+		Action:      activity.ActionUpdateStarted,
+		HostID:      hostID,
+		ContainerID: req.ContainerID,
+		Image:       req.NewImage,
+	})
+}
+
+// publishUpdateFinished announces a container update's outcome
+func (s *Server) publishUpdateFinished(hostID string, req UpdateHTTPRequest, result *update.UpdateResult) {
+	event := activity.Event{
+		Action:        activity.ActionUpdateCompleted,
+		HostID:        hostID,
+		ContainerID:   req.ContainerID,
+		ContainerName: result.ContainerName,
+		Image:         req.NewImage,
+	}
+	if result.Success {
+		event.Attributes = map[string]string{"new_container_id": result.NewContainerID}
+	} else {
+		event.Action = activity.ActionUpdateFailed
+		event.Message = result.Error
+		if result.RolledBack {
+			event.Attributes = map[string]string{"rolled_back": "true"}
+		}
+	}
+	s.activity.Publish(event)
+}
+
+// publishStackDeployed announces a stack brought up on a host. Failed
+// deployments and other actions (down, restart) are not announced.
+func (s *Server) publishStackDeployed(hostID string, req compose.DeployRequest, result *compose.DeployResult) {
+	if req.Action != "up" || !(result.Success || result.PartialSuccess) {
+		return
+	}
+	event := activity.Event{
+		Action: activity.ActionStackDeployed,
+		HostID: hostID,
+		Attributes: map[string]string{
+			"project_name":  req.ProjectName,
+			"deployment_id": req.DeploymentID,
+		},
+	}
+	if !result.Success {
+		event.Message = fmt.Sprintf("services failed: %s", strings.Join(result.FailedServices, ", "))
+	}
+	s.activity.Publish(event)
+}
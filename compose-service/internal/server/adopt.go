@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/sirupsen/logrus"
+)
+
+// AdoptHTTPRequest is the HTTP request body for /adopt
+type AdoptHTTPRequest struct {
+	ProjectName string `json:"project_name"`
+	// For remote hosts (mTLS)
+	DockerHost string `json:"docker_host,omitempty"`
+	TLSCACert  string `json:"tls_ca_cert,omitempty"`
+	TLSCert    string `json:"tls_cert,omitempty"`
+	TLSKey     string `json:"tls_key,omitempty"`
+	// TLS files or a Docker context instead of the fields above
+	sharedDocker.HostSource
+}
+
+// handleAdopt handles the /adopt endpoint: it rebuilds compose YAML and .env
+// content for a stack deployed outside DockMon (e.g. with the docker compose
+// CLI) from its running containers, so the stack can be imported.
+func (s *Server) handleAdopt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request
+	var req AdoptHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !resolveHostSource(w, req.HostSource, &req.DockerHost, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		return
+	}
+
+	// Validate required fields
+	if req.ProjectName == "" {
+		http.Error(w, "Missing required field: project_name", http.StatusBadRequest)
+		return
+	}
+
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer releaseClient()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	result, err := compose.AdoptProject(ctx, dockerClient, req.ProjectName)
+	if errors.Is(err, compose.ErrProjectNotFound) {
+		http.Error(w, fmt.Sprintf("%v: %s", err, req.ProjectName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.log.WithError(err).WithField("project_name", req.ProjectName).Error("Failed to adopt stack")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"project_name": req.ProjectName,
+		"services":     len(result.Services),
+		"warnings":     len(result.Warnings),
+	}).Info("Reconstructed compose file for existing stack")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.log.WithError(err).Error("Failed to encode adopt response")
+	}
+}
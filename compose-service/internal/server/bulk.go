@@ -0,0 +1,477 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/docker/docker/client"
+	"github.com/dockmon/compose-service/internal/history"
+	"github.com/dockmon/compose-service/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxBulkConcurrency caps how many projects a bulk operation handles at
+	// once. Each still takes a deploy limiter slot.
+	MaxBulkConcurrency = 4
+
+	// projectsListTimeout bounds POST /projects, including registry lookups
+	// when updates are checked
+	projectsListTimeout = 2 * time.Minute
+)
+
+// Per-project outcomes in BulkProjectResult.Status
+const (
+	BulkSucceeded = "succeeded"
+	BulkFailed    = "failed"
+	BulkSkipped   = "skipped"
+)
+
+// ProjectsHTTPRequest is the HTTP request body for POST /projects. It takes
+// the same connection and directory fields as /deploy.
+type ProjectsHTTPRequest struct {
+	compose.DeployRequest
+	// CheckUpdates asks the registries which projects run images that were
+	// re-pushed under the same tag, using registry_credentials
+	CheckUpdates bool `json:"check_updates,omitempty"`
+}
+
+// BulkHTTPRequest is the HTTP request body for POST /projects/bulk. It takes
+// the same connection, directory, registry, health and revision fields as
+// /deploy. deployment_id identifies the bulk operation, which can be
+// cancelled through /deploy/cancel; each project runs as
+// <deployment_id>-<project>.
+type BulkHTTPRequest struct {
+	compose.DeployRequest
+	Operation compose.BulkAction `json:"operation"`
+	// Projects to act on. Empty means every project on the host; for
+	// "update", every DockMon-deployed project with an image update.
+	Projects []string `json:"projects,omitempty"`
+	// EnvSets names the variable set each project was deployed with, which
+	// an update must be given again
+	EnvSets map[string]string `json:"env_sets,omitempty"`
+	// Concurrency is how many projects run at once (default 1, at most
+	// MaxBulkConcurrency)
+	Concurrency int `json:"concurrency,omitempty"`
+
+	envSetVars map[string]map[string]string // Resolved EnvSets
+}
+
+// BulkProjectResult is one project's outcome in a bulk operation
+type BulkProjectResult struct {
+	Project      string `json:"project"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Status       string `json:"status"`
+	Message      string `json:"message,omitempty"` // Why the project failed or was skipped
+	// Result is the redeployment's result ("update" only)
+	Result *compose.DeployResult `json:"result,omitempty"`
+}
+
+// BulkResult is the combined result of a bulk operation
+type BulkResult struct {
+	DeploymentID string              `json:"deployment_id"`
+	Operation    compose.BulkAction  `json:"operation"`
+	Success      bool                `json:"success"` // No project failed
+	Cancelled    bool                `json:"cancelled,omitempty"`
+	Projects     []BulkProjectResult `json:"projects"`
+	Failed       []string            `json:"failed,omitempty"`
+	// Error is set when the operation couldn't start, e.g. the host was
+	// unreachable
+	Error string `json:"error,omitempty"`
+}
+
+// handleListProjects handles POST /projects: it lists the compose projects on
+// a Docker host from their container labels, including ones deployed outside
+// DockMon, optionally with the image updates each could pick up.
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	var req ProjectsHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := req.ResolveHostSources(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dockerClient, releaseClient, err := s.createDockerClient(req.DeployRequest)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer releaseClient()
+
+	ctx, cancel := context.WithTimeout(r.Context(), projectsListTimeout)
+	defer cancel()
+
+	projects, err := compose.ListProjects(ctx, dockerClient, req.StacksDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.CheckUpdates {
+		if err := compose.CheckProjectUpdates(ctx, dockerClient, s.log, projects, req.RegistryCredentials); err != nil {
+			http.Error(w, fmt.Sprintf("Update check failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projects)
+}
+
+// handleBulk handles POST /projects/bulk: it stops, starts, or pulls and
+// redeploys several projects on one host, with a combined result. Supports
+// the same response modes as /deploy (SSE, JSON, ?async=true); progress
+// events carry the project they belong to.
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	var req BulkHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if req.DeploymentID == "" || req.Operation == "" {
+		http.Error(w, "Missing required fields: deployment_id, operation", http.StatusBadRequest)
+		return
+	}
+	if !req.Operation.Valid() {
+		http.Error(w, fmt.Sprintf("Unknown operation %q: expected stop, start or update", req.Operation), http.StatusBadRequest)
+		return
+	}
+	if len(req.Targets) > 0 {
+		http.Error(w, "Bulk operations run on a single host; targets are not supported", http.StatusBadRequest)
+		return
+	}
+	if req.Concurrency < 0 || req.Concurrency > MaxBulkConcurrency {
+		http.Error(w, fmt.Sprintf("concurrency must be between 1 and %d", MaxBulkConcurrency), http.StatusBadRequest)
+		return
+	}
+	for _, name := range req.Projects {
+		if err := compose.ValidateStackName(name); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid project %q: %v", name, err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := req.ResolveHostSources(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateSecrets(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Variable sets are resolved here so a missing one fails the request
+	// rather than one project halfway through
+	if req.Operation == compose.BulkUpdate && len(req.EnvSets) > 0 {
+		req.envSetVars = make(map[string]map[string]string, len(req.EnvSets))
+		for project, name := range req.EnvSets {
+			vars, err := s.envSets.Get(name)
+			if err != nil {
+				writeEnvSetError(w, err)
+				return
+			}
+			req.envSetVars[project] = vars
+		}
+	}
+	if req.Quota == nil {
+		req.Quota = s.quota
+	}
+
+	requester, hostID := requesterFrom(r), hostIDFrom(r)
+
+	// Per-project timeouts surface as failed project results, and each
+	// project holds its own limiter slot
+	d := deployRun{
+		deploymentID: req.DeploymentID,
+		parallel:     MaxBulkConcurrency,
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			result := s.runBulk(ctx, req, requester, hostID, onProgress)
+			var errMsg string
+			switch {
+			case result.Error != "":
+				errMsg = result.Error
+			case len(result.Failed) > 0:
+				errMsg = fmt.Sprintf("failed projects: %v", result.Failed)
+			}
+			return deployOutcome{
+				result: result,
+				status: jobStatus(result.Success, false, result.Cancelled),
+				errMsg: errMsg,
+			}
+		},
+	}
+
+	// Async mode returns a job id immediately instead of holding the connection
+	if r.URL.Query().Get("async") == "true" {
+		s.startAsyncJob(w, d)
+		return
+	}
+	if r.Header.Get("Accept") == "text/event-stream" {
+		s.streamSSE(w, r, d)
+		return
+	}
+
+	ctx, stop := s.withDeployCancel(r.Context(), req.DeploymentID)
+	defer stop()
+
+	result := s.runBulk(ctx, req, requester, hostID, nil)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.log.WithError(err).Error("Failed to encode bulk response")
+	}
+}
+
+// runBulk selects the projects a bulk operation applies to and runs it on
+// each, req.Concurrency at a time. Progress events are tagged with the
+// project; onProgress may be nil and must be safe for concurrent use.
+func (s *Server) runBulk(ctx context.Context, req BulkHTTPRequest, requester history.Requester, hostID string, onProgress compose.ProgressCallback) *BulkResult {
+	result := &BulkResult{DeploymentID: req.DeploymentID, Operation: req.Operation}
+	emit := func(event compose.ProgressEvent) {
+		if onProgress != nil {
+			onProgress(event)
+		}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"operation":     req.Operation,
+		"projects":      len(req.Projects),
+		"host_type":     compose.GetHostType(req.DeployRequest),
+	}).Info("Bulk operation started")
+
+	dockerClient, releaseClient, err := s.createDockerClient(req.DeployRequest)
+	if err != nil {
+		result.Error = fmt.Sprintf("Failed to create Docker client: %v", err)
+		emit(compose.ProgressEvent{Stage: compose.StageFailed, Progress: 100, Message: result.Error})
+		return result
+	}
+	defer releaseClient()
+
+	emit(compose.ProgressEvent{Stage: compose.StageValidating, Progress: 5, Message: "Listing projects..."})
+	projects, err := compose.ListProjects(ctx, dockerClient, req.StacksDir)
+	if err != nil {
+		result.Error = err.Error()
+		emit(compose.ProgressEvent{Stage: compose.StageFailed, Progress: 100, Message: result.Error})
+		return result
+	}
+	selected, skipped := selectBulkProjects(projects, req, compose.SelfProject(ctx, dockerClient))
+
+	if req.Operation == compose.BulkUpdate && len(selected) > 0 {
+		emit(compose.ProgressEvent{
+			Stage:    compose.StageValidating,
+			Progress: 10,
+			Message:  fmt.Sprintf("Checking %d project(s) for image updates...", len(selected)),
+		})
+		if err := compose.CheckProjectUpdates(ctx, dockerClient, s.log, selected, req.RegistryCredentials); err != nil {
+			result.Error = fmt.Sprintf("Update check failed: %v", err)
+			emit(compose.ProgressEvent{Stage: compose.StageFailed, Progress: 100, Message: result.Error})
+			return result
+		}
+		withUpdates := selected[:0]
+		for _, p := range selected {
+			if len(p.Updates) > 0 {
+				withUpdates = append(withUpdates, p)
+				continue
+			}
+			message := "images are up to date"
+			if len(p.UpdateErrors) > 0 {
+				message = "update check failed: " + strings.Join(p.UpdateErrors, "; ")
+			}
+			skipped = append(skipped, BulkProjectResult{Project: p.Name, Status: BulkSkipped, Message: message})
+		}
+		selected = withUpdates
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	results := make([]BulkProjectResult, len(selected))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(selected); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				results[idx] = s.runBulkProject(ctx, dockerClient, req, selected[idx].Name, requester, hostID, onProgress)
+			}
+		}()
+	}
+	for i := range selected {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	result.Projects = append(skipped, results...)
+	sort.Slice(result.Projects, func(i, j int) bool { return result.Projects[i].Project < result.Projects[j].Project })
+	for _, p := range result.Projects {
+		if p.Status == BulkFailed {
+			result.Failed = append(result.Failed, p.Project)
+		}
+	}
+	result.Cancelled = errors.Is(context.Cause(ctx), compose.ErrDeployCancelled)
+	result.Success = len(result.Failed) == 0 && !result.Cancelled
+
+	s.log.WithFields(logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"operation":     req.Operation,
+		"ran":           len(selected),
+		"skipped":       len(skipped),
+		"failed":        result.Failed,
+		"cancelled":     result.Cancelled,
+	}).Info("Bulk operation completed")
+	return result
+}
+
+// selectBulkProjects picks the listed projects the operation applies to,
+// reporting the others as skipped. selfProject is DockMon's own project,
+// which is never stopped or updated.
+func selectBulkProjects(projects []compose.ProjectSummary, req BulkHTTPRequest, selfProject string) (selected []compose.ProjectSummary, skipped []BulkProjectResult) {
+	candidates := projects
+	if len(req.Projects) > 0 {
+		byName := make(map[string]compose.ProjectSummary, len(projects))
+		for _, p := range projects {
+			byName[p.Name] = p
+		}
+		candidates = nil
+		seen := make(map[string]bool, len(req.Projects))
+		for _, name := range req.Projects {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			p, ok := byName[name]
+			if !ok {
+				skipped = append(skipped, BulkProjectResult{Project: name, Status: BulkSkipped, Message: "no containers of this project on the host"})
+				continue
+			}
+			candidates = append(candidates, p)
+		}
+	}
+
+	for _, p := range candidates {
+		if reason := compose.BulkSkipReason(req.Operation, p, selfProject); reason != "" {
+			skipped = append(skipped, BulkProjectResult{Project: p.Name, Status: BulkSkipped, Message: reason})
+			continue
+		}
+		selected = append(selected, p)
+	}
+	return selected, skipped
+}
+
+// runBulkProject runs the bulk operation on one project, holding the
+// project's deploy limiter slot. A project that is busy or that the
+// operation was cancelled before is skipped.
+func (s *Server) runBulkProject(ctx context.Context, dockerClient *client.Client, req BulkHTTPRequest, project string, requester history.Requester, hostID string, onProgress compose.ProgressCallback) BulkProjectResult {
+	preq := req.DeployRequest
+	preq.ProjectName = project
+	preq.DeploymentID = req.DeploymentID + "-" + project
+	preq.Action = "up"
+	preq.EnvSet = req.EnvSets[project]
+	preq.EnvSetVars = req.envSetVars[project]
+	out := BulkProjectResult{Project: project, DeploymentID: preq.DeploymentID}
+
+	if ctx.Err() != nil {
+		out.Status = BulkSkipped
+		out.Message = "bulk operation ended before the project started"
+		return out
+	}
+	releaseSlot, limitErr := s.limiter.acquire(preq)
+	if limitErr != nil {
+		out.Status = BulkSkipped
+		out.Message = limitErr.message
+		return out
+	}
+	defer releaseSlot()
+
+	emit := func(event compose.ProgressEvent) {
+		if onProgress != nil {
+			event.Project = project
+			onProgress(event)
+		}
+	}
+	var opts []compose.Option
+	if onProgress != nil {
+		opts = append(opts, compose.WithProgressCallback(emit))
+	}
+	svc := compose.NewService(dockerClient, s.log, opts...)
+
+	ctx, cancel := context.WithTimeout(ctx, deployTimeout(preq))
+	defer cancel()
+
+	var err error
+	switch req.Operation {
+	case compose.BulkStop:
+		emit(compose.ProgressEvent{Stage: compose.StageStopping, Progress: 50, Message: fmt.Sprintf("Stopping %s...", project)})
+		err = svc.StopProject(ctx, preq)
+	case compose.BulkStart:
+		emit(compose.ProgressEvent{Stage: compose.StageStarting, Progress: 50, Message: fmt.Sprintf("Starting %s...", project)})
+		err = svc.StartProject(ctx, preq)
+	case compose.BulkUpdate:
+		preq.PullImages = true
+		out.Result = s.redeployProject(ctx, svc, preq, requester, hostID)
+		if !out.Result.Success {
+			err = errors.New("redeployment failed")
+			if out.Result.Error != nil {
+				err = errors.New(out.Result.Error.Message)
+			}
+		}
+	}
+
+	if err != nil {
+		out.Status = BulkFailed
+		out.Message = err.Error()
+		if out.Result == nil {
+			emit(compose.ProgressEvent{Stage: compose.StageFailed, Progress: 100, Message: fmt.Sprintf("%s: %v", project, err)})
+		}
+		return out
+	}
+	out.Status = BulkSucceeded
+	if out.Result == nil {
+		emit(compose.ProgressEvent{Stage: compose.StageCompleted, Progress: 100, Message: fmt.Sprintf("%s %s", project, bulkDoneVerb(req.Operation))})
+	}
+	return out
+}
+
+// redeployProject pulls and redeploys one project of a bulk update,
+// recording it in the project's history like any deployment
+func (s *Server) redeployProject(ctx context.Context, svc *compose.Service, req compose.DeployRequest, requester history.Requester, hostID string) *compose.DeployResult {
+	startTime := time.Now()
+	metrics.Global.IncrementActive()
+	defer metrics.Global.DecrementActive()
+
+	s.history.Start(history.Entry{
+		DeploymentID: req.DeploymentID,
+		ProjectName:  req.ProjectName,
+		Action:       req.Action,
+		Requester:    requester,
+	})
+	if hostID != "" {
+		s.deployHosts.Store(req.DeploymentID, hostID)
+	}
+
+	result := svc.Redeploy(ctx, req)
+	metrics.Global.RecordDeployment(result.Success, result.PartialSuccess, time.Since(startTime))
+	s.finishDeployHistory(req, result)
+	return result
+}
+
+func bulkDoneVerb(op compose.BulkAction) string {
+	if op == compose.BulkStop {
+		return "stopped"
+	}
+	return "started"
+}
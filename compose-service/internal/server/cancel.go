@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/jobs"
+)
+
+// deployCancel is a running deployment's cancel function. It is stored by
+// pointer so a finishing deployment only unregisters its own entry.
+type deployCancel struct {
+	cancel context.CancelCauseFunc
+}
+
+// CancelDeployRequest is the HTTP request body for POST /deploy/cancel
+type CancelDeployRequest struct {
+	DeploymentID string `json:"deployment_id"`
+}
+
+// CancelDeployResponse is returned when a cancellation is accepted. The
+// cancelled result, including the cleanup, arrives as the deployment's
+// complete event.
+type CancelDeployResponse struct {
+	DeploymentID string `json:"deployment_id"`
+	JobID        string `json:"job_id,omitempty"`
+	Status       string `json:"status"` // Always "cancelling"
+}
+
+// withDeployCancel derives a context the deployment can be cancelled through
+// by its ID. stop unregisters it and releases the context.
+func (s *Server) withDeployCancel(parent context.Context, deploymentID string) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	entry := &deployCancel{cancel: cancel}
+	s.cancels.Store(deploymentID, entry)
+	return ctx, func() {
+		s.cancels.CompareAndDelete(deploymentID, entry)
+		cancel(context.Canceled)
+	}
+}
+
+// cancelDeploy cancels a running deployment, reporting whether one was found
+func (s *Server) cancelDeploy(deploymentID string) bool {
+	entry, ok := s.cancels.Load(deploymentID)
+	if !ok {
+		return false
+	}
+	entry.(*deployCancel).cancel(compose.ErrDeployCancelled)
+	s.log.WithField("deployment_id", deploymentID).Info("Deployment cancellation requested")
+	return true
+}
+
+// handleCancelJob handles DELETE /jobs/{id}, cancelling an async deployment
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if job.Finished() {
+		http.Error(w, fmt.Sprintf("Job already %s", job.Status), http.StatusConflict)
+		return
+	}
+	if !s.cancelDeploy(job.DeploymentID) {
+		// Finished between the lookup and the cancel
+		http.Error(w, "Job is no longer running", http.StatusConflict)
+		return
+	}
+
+	writeCancelAccepted(w, CancelDeployResponse{
+		DeploymentID: job.DeploymentID,
+		JobID:        job.ID,
+		Status:       "cancelling",
+	})
+}
+
+// handleCancelDeploy handles POST /deploy/cancel, cancelling a deployment
+// by ID whichever mode (SSE, blocking or async) it runs in
+func (s *Server) handleCancelDeploy(w http.ResponseWriter, r *http.Request) {
+	var req CancelDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.DeploymentID == "" {
+		http.Error(w, "Missing required field: deployment_id", http.StatusBadRequest)
+		return
+	}
+	if !s.cancelDeploy(req.DeploymentID) {
+		http.Error(w, "No running deployment with that ID", http.StatusNotFound)
+		return
+	}
+
+	writeCancelAccepted(w, CancelDeployResponse{
+		DeploymentID: req.DeploymentID,
+		Status:       "cancelling",
+	})
+}
+
+func writeCancelAccepted(w http.ResponseWriter, resp CancelDeployResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// jobStatus is the terminal job status for a deployment outcome
+func jobStatus(success, partial, cancelled bool) string {
+	switch {
+	case cancelled:
+		return jobs.StatusCancelled
+	case success || partial:
+		return jobs.StatusCompleted
+	default:
+		return jobs.StatusFailed
+	}
+}
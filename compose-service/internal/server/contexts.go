@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// maxContextImportBytes bounds the `docker context inspect` output accepted
+// by /docker-contexts/import
+const maxContextImportBytes = 1 << 20
+
+// handleImportDockerContexts handles POST /docker-contexts/import: it turns
+// the output of `docker context inspect` into host definitions whose
+// docker_host and tls_*_file fields can be used in deploy and update
+// requests. TLS material stays on disk and is referenced by path.
+func (s *Server) handleImportDockerContexts(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContextImportBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	contexts, err := sharedDocker.ParseDockerContexts(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"hosts": contexts})
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+)
+
+// sseKeepaliveInterval is how often an idle SSE stream gets a comment line,
+// so proxies don't time the connection out
+const sseKeepaliveInterval = 15 * time.Second
+
+// deployOutcome is what a deployment run reports: the result sent as the
+// complete event, and the status and error message recorded on its job.
+type deployOutcome struct {
+	result any
+	status string // See jobStatus
+	errMsg string
+}
+
+// deployRun describes a deployment handed to startAsyncJob or streamSSE,
+// which own its cancellation, timeout, progress delivery and release.
+type deployRun struct {
+	deploymentID string
+	projectName  string        // Recorded on the job
+	timeout      time.Duration // Bounds the run; 0 = none
+	parallel     int           // Deployments the run performs at once, sizing the SSE progress buffer
+	release      func()        // Called once the run returns; may be nil
+	// run performs the deployment. onProgress may be nil and is safe for
+	// concurrent use.
+	run func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome
+	// abort, if set, ends an SSE stream whose run timed out or whose client
+	// went away with the result it returns, instead of waiting for the run
+	// to unwind
+	abort func() any
+}
+
+// startAsyncJob queues d as a job and answers 202 with the job's location
+// right away. The run is detached from the request, since the caller
+// disconnects next, but bound by the server's lifetime so shutdown cancels
+// it, and it is cancellable through /deploy/cancel as soon as this returns.
+func (s *Server) startAsyncJob(w http.ResponseWriter, d deployRun) {
+	job, err := s.jobs.Create(d.deploymentID, d.projectName)
+	if err != nil {
+		if d.release != nil {
+			d.release()
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, stop := s.withDeployCancel(s.baseCtx, d.deploymentID)
+	go func() { // #nosec G118
+		if d.release != nil {
+			defer d.release()
+		}
+		defer stop()
+		if d.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.timeout)
+			defer cancel()
+		}
+
+		s.jobs.SetRunning(job.ID)
+		out := d.run(ctx, func(event compose.ProgressEvent) {
+			if data, err := json.Marshal(event.ToProgress(d.deploymentID)); err == nil {
+				s.jobs.AddProgress(job.ID, data)
+			}
+		})
+		data, _ := json.Marshal(out.result)
+		s.jobs.Finish(job.ID, out.status, data, out.errMsg)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(JobAcceptedResponse{
+		JobID:        job.ID,
+		DeploymentID: d.deploymentID,
+		Status:       job.Status,
+	})
+}
+
+// streamSSE runs d for the request, streaming its progress and result as
+// SSE. The run owns release: when the stream ends early (see
+// deployRun.abort) the handler returns before the deployment has unwound,
+// and the project stays locked until it has.
+func (s *Server) streamSSE(w http.ResponseWriter, r *http.Request, d deployRun) {
+	running := false
+	defer func() {
+		if !running && d.release != nil {
+			d.release()
+		}
+	}()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, stop := s.withDeployCancel(r.Context(), d.deploymentID)
+	defer stop()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	ticker := time.NewTicker(sseKeepaliveInterval)
+	defer ticker.Stop()
+
+	resultCh := make(chan any, 1)
+	progressCh := make(chan compose.ProgressEvent, 100*max(d.parallel, 1))
+
+	running = true
+	go func() {
+		if d.release != nil {
+			defer d.release()
+		}
+		out := d.run(ctx, func(event compose.ProgressEvent) {
+			select {
+			case progressCh <- event:
+			default:
+				// Channel full, skip event (better than blocking)
+			}
+		})
+		close(progressCh)
+		resultCh <- out.result
+	}()
+
+	// Without abort, timeouts and disconnects surface through the run's
+	// own result, so the stream only waits for it
+	var done <-chan struct{}
+	if d.abort != nil {
+		done = ctx.Done()
+	}
+	for {
+		select {
+		case event, ok := <-progressCh:
+			if !ok {
+				progressCh = nil // Closed, wait for result
+				continue
+			}
+			writeSSE(w, flusher, "progress", event.ToProgress(d.deploymentID))
+
+		case result := <-resultCh:
+			writeSSE(w, flusher, "complete", result)
+			return
+
+		case <-ticker.C:
+			// SSE keepalive (comment line - ignored by SSE parsers)
+			fmt.Fprintf(w, ": keepalive %d\n\n", time.Now().Unix())
+			flusher.Flush()
+
+		case <-done:
+			if errors.Is(context.Cause(ctx), compose.ErrDeployCancelled) {
+				// The run cleans up and returns the cancelled result
+				done = nil
+				continue
+			}
+			writeSSE(w, flusher, "complete", d.abort())
+			return
+		}
+	}
+}
+
+// writeSSE writes one SSE event with a JSON payload
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, event string, payload any) {
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	flusher.Flush()
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/jobs"
+	"github.com/sirupsen/logrus"
+)
+
+func TestStreamSSE_StreamsProgressThenResult(t *testing.T) {
+	s := &Server{log: logrus.New()}
+	var released atomic.Int32
+	d := deployRun{
+		deploymentID: "d1",
+		release:      func() { released.Add(1) },
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			onProgress(compose.ProgressEvent{Stage: compose.StageStarting, Progress: 50, Message: "halfway"})
+			return deployOutcome{result: map[string]bool{"success": true}}
+		},
+	}
+
+	w := httptest.NewRecorder()
+	s.streamSSE(w, httptest.NewRequest(http.MethodPost, "/deploy", nil), d)
+
+	body := w.Body.String()
+	progress := strings.Index(body, "event: progress\n")
+	complete := strings.Index(body, "event: complete\ndata: {\"success\":true}")
+	if progress < 0 || complete < progress {
+		t.Errorf("body = %q, want a progress event then the complete event", body)
+	}
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", w.Header().Get("Content-Type"))
+	}
+	if n := released.Load(); n != 1 {
+		t.Errorf("release called %d times, want once", n)
+	}
+}
+
+// A timed-out stream returns with the abort result, but the project stays
+// locked until the deployment itself has unwound
+func TestStreamSSE_AbortKeepsReleaseUntilRunEnds(t *testing.T) {
+	s := &Server{log: logrus.New()}
+	unblock := make(chan struct{})
+	released := make(chan struct{})
+	d := deployRun{
+		deploymentID: "d1",
+		timeout:      10 * time.Millisecond,
+		release:      func() { close(released) },
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			<-unblock
+			return deployOutcome{result: "late"}
+		},
+		abort: func() any { return "timed out" },
+	}
+
+	w := httptest.NewRecorder()
+	s.streamSSE(w, httptest.NewRequest(http.MethodPost, "/deploy", nil), d)
+
+	if !strings.Contains(w.Body.String(), "event: complete\ndata: \"timed out\"") {
+		t.Errorf("body = %q, want the abort result", w.Body.String())
+	}
+	select {
+	case <-released:
+		t.Fatal("released before the deployment finished")
+	default:
+	}
+
+	close(unblock)
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not released after the deployment finished")
+	}
+}
+
+func TestStartAsyncJob_RecordsOutcome(t *testing.T) {
+	store, err := jobs.NewStore("", logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{log: logrus.New(), jobs: store, baseCtx: context.Background()}
+	released := make(chan struct{})
+	d := deployRun{
+		deploymentID: "d1",
+		projectName:  "web",
+		release:      func() { close(released) },
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			onProgress(compose.ProgressEvent{Stage: compose.StageStarting, Progress: 50})
+			return deployOutcome{result: map[string]bool{"success": false}, status: jobs.StatusFailed, errMsg: "boom"}
+		},
+	}
+
+	w := httptest.NewRecorder()
+	s.startAsyncJob(w, d)
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(w.Header().Get("Location"), "/jobs/") {
+		t.Fatalf("response = %d Location %q, want 202 with the job location", w.Code, w.Header().Get("Location"))
+	}
+
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job never finished")
+	}
+	job, ok := store.Get(strings.TrimPrefix(w.Header().Get("Location"), "/jobs/"))
+	if !ok {
+		t.Fatal("job not found")
+	}
+	if job.Status != jobs.StatusFailed || job.Error != "boom" || job.ProjectName != "web" {
+		t.Errorf("job = %+v, want failed web job with its error", job)
+	}
+	if len(job.Events) != 2 || job.Events[0].Type != jobs.EventProgress || job.Events[1].Type != jobs.EventComplete {
+		t.Errorf("events = %+v, want progress then complete", job.Events)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/envsets"
+)
+
+// PutEnvSetRequest is the HTTP request body for PUT /env-sets/{name}
+type PutEnvSetRequest struct {
+	Variables map[string]string `json:"variables"`
+}
+
+// handleListEnvSets handles GET /env-sets. Only variable names are
+// returned; values never leave the service.
+func (s *Server) handleListEnvSets(w http.ResponseWriter, r *http.Request) {
+	summaries, err := s.envSets.List()
+	if err != nil {
+		writeEnvSetError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries)
+}
+
+// handlePutEnvSet handles PUT /env-sets/{name}, creating or replacing a set
+func (s *Server) handlePutEnvSet(w http.ResponseWriter, r *http.Request) {
+	var req PutEnvSetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*envsets.MaxSetBytes)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	summary, err := s.envSets.Put(r.PathValue("name"), req.Variables)
+	if err != nil {
+		writeEnvSetError(w, err)
+		return
+	}
+	s.log.WithField("env_set", summary.Name).Info("Variable set saved")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleDeleteEnvSet handles DELETE /env-sets/{name}
+func (s *Server) handleDeleteEnvSet(w http.ResponseWriter, r *http.Request) {
+	if err := s.envSets.Delete(r.PathValue("name")); err != nil {
+		writeEnvSetError(w, err)
+		return
+	}
+	s.log.WithField("env_set", r.PathValue("name")).Info("Variable set deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveEnvSet loads the variables of the set a request references
+func (s *Server) resolveEnvSet(req *compose.DeployRequest) error {
+	if req.EnvSet == "" {
+		return nil
+	}
+	vars, err := s.envSets.Get(req.EnvSet)
+	if err != nil {
+		return err
+	}
+	req.EnvSetVars = vars
+	return nil
+}
+
+func writeEnvSetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, envsets.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, envsets.ErrDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
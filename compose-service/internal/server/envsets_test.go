@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/envsets"
+	"github.com/sirupsen/logrus"
+)
+
+func envSetsMux(s *Server) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /env-sets", s.handleListEnvSets)
+	mux.HandleFunc("PUT /env-sets/{name}", s.handlePutEnvSet)
+	mux.HandleFunc("DELETE /env-sets/{name}", s.handleDeleteEnvSet)
+	return mux
+}
+
+func serve(mux *http.ServeMux, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestEnvSetHandlers(t *testing.T) {
+	root := t.TempDir()
+	store, err := envsets.NewStore(filepath.Join(root, "sets"), filepath.Join(root, "envsets.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{log: logrus.New(), envSets: store}
+	mux := envSetsMux(s)
+
+	rec := serve(mux, http.MethodPut, "/env-sets/prod-db", `{"variables":{"DB_PASSWORD":"hunter2"}}`)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "hunter2") {
+		t.Fatalf("PUT: %d %s, want 200 without the value", rec.Code, rec.Body)
+	}
+
+	rec = serve(mux, http.MethodGet, "/env-sets", "")
+	var summaries []envsets.Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summaries); err != nil || len(summaries) != 1 || summaries[0].Variables[0] != "DB_PASSWORD" {
+		t.Errorf("GET: %d %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Error("GET returned a variable value")
+	}
+
+	// Deployments referencing the set get its variables
+	req := compose.DeployRequest{EnvSet: "prod-db"}
+	if err := s.resolveEnvSet(&req); err != nil || req.EnvSetVars["DB_PASSWORD"] != "hunter2" {
+		t.Errorf("resolveEnvSet: %v, %v", req.EnvSetVars, err)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPut, "/env-sets/prod-db", `{"variables":`, http.StatusBadRequest},
+		{http.MethodPut, "/env-sets/prod-db", `{"variables":{"not-a-var":"1"}}`, http.StatusBadRequest},
+		{http.MethodPut, "/env-sets/.hidden", `{"variables":{"A":"1"}}`, http.StatusBadRequest},
+		{http.MethodDelete, "/env-sets/prod-db", "", http.StatusNoContent},
+		{http.MethodDelete, "/env-sets/prod-db", "", http.StatusNotFound},
+	} {
+		if rec := serve(mux, tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s %s: %d %s, want %d", tt.method, tt.path, tt.body, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	req = compose.DeployRequest{EnvSet: "prod-db"}
+	if err := s.resolveEnvSet(&req); err == nil {
+		t.Error("resolveEnvSet accepted a deleted set")
+	}
+}
+
+func TestEnvSetHandlers_Disabled(t *testing.T) {
+	mux := envSetsMux(&Server{log: logrus.New()})
+	if rec := serve(mux, http.MethodGet, "/env-sets", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET without a store: %d, want 503", rec.Code)
+	}
+	if rec := serve(mux, http.MethodPut, "/env-sets/x", `{"variables":{}}`); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("PUT without a store: %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/docker/docker/client"
+	"github.com/dockmon/compose-service/internal/history"
+)
+
+// defaultHistoryLimit is how many entries GET /projects/{name}/history
+// returns without ?limit
+const defaultHistoryLimit = 10
+
+// composeProjectLabel is the label compose puts on a project's containers
+const composeProjectLabel = "com.docker.compose.project"
+
+// requesterFrom reads the optional requester headers set by the backend
+func requesterFrom(r *http.Request) history.Requester {
+	return history.Requester{
+		User:   r.Header.Get("X-DockMon-User"),
+		Source: r.Header.Get("X-DockMon-Source"),
+	}
+}
+
+// startDeployHistory records a deployment as running
+func (s *Server) startDeployHistory(r *http.Request, req compose.DeployRequest) {
+	action := req.Action
+	if r.URL.Path == "/rollback" {
+		action = "rollback"
+	}
+	var targets []string
+	for _, target := range req.Targets {
+		targets = append(targets, target.Name)
+	}
+	s.history.Start(history.Entry{
+		DeploymentID: req.DeploymentID,
+		ProjectName:  req.ProjectName,
+		Action:       action,
+		ComposeHash:  history.HashCompose(req.ComposeYAML),
+		Targets:      targets,
+		Requester:    requesterFrom(r),
+	})
+	if hostID := hostIDFrom(r); hostID != "" {
+		s.deployHosts.Store(req.DeploymentID, hostID)
+	}
+}
+
+// finishDeployHistory records a deployment's outcome
+func (s *Server) finishDeployHistory(req compose.DeployRequest, result *compose.DeployResult) {
+	var errMsg string
+	if result.Error != nil {
+		errMsg = result.Error.Message
+	}
+	status := historyStatus(result.Success, result.PartialSuccess)
+	if result.Cancelled {
+		status = history.StatusCancelled
+	}
+	s.history.Finish(req.ProjectName, req.DeploymentID, status, errMsg)
+	if hostID, ok := s.deployHosts.LoadAndDelete(req.DeploymentID); ok {
+		s.publishStackDeployed(hostID.(string), req, result)
+	}
+}
+
+// finishMultiHistory records a multi-target deployment's outcome
+func (s *Server) finishMultiHistory(req compose.DeployRequest, result *compose.MultiDeployResult) {
+	var errMsg string
+	if len(result.FailedTargets) > 0 {
+		errMsg = fmt.Sprintf("deployment failed on: %v", result.FailedTargets)
+	}
+	status := historyStatus(result.Success, result.PartialSuccess)
+	if result.Cancelled {
+		status = history.StatusCancelled
+	}
+	s.history.Finish(req.ProjectName, req.DeploymentID, status, errMsg)
+
+	// Target names are the callers' host IDs
+	s.deployHosts.Delete(req.DeploymentID)
+	for name, targetResult := range result.Results {
+		s.publishStackDeployed(name, req, targetResult)
+	}
+}
+
+func historyStatus(success, partial bool) string {
+	switch {
+	case success:
+		return history.StatusSucceeded
+	case partial:
+		return history.StatusPartial
+	default:
+		return history.StatusFailed
+	}
+}
+
+// startUpdateHistory records a container update as running in the history of
+// the compose project the container belongs to. Returns empty strings, and
+// records nothing, for containers outside a compose project.
+func (s *Server) startUpdateHistory(ctx context.Context, cli client.APIClient, r *http.Request, req UpdateHTTPRequest) (projectName, id string) {
+	info, err := cli.ContainerInspect(ctx, req.ContainerID)
+	if err != nil || info.Config == nil || info.Config.Labels[composeProjectLabel] == "" {
+		return "", ""
+	}
+	projectName = info.Config.Labels[composeProjectLabel]
+	id = fmt.Sprintf("update-%d", time.Now().UnixNano())
+	s.history.Start(history.Entry{
+		DeploymentID: id,
+		ProjectName:  projectName,
+		Action:       "update",
+		ContainerID:  req.ContainerID,
+		Image:        req.NewImage,
+		Requester:    requesterFrom(r),
+	})
+	return projectName, id
+}
+
+// finishUpdateHistory records a container update's outcome
+func (s *Server) finishUpdateHistory(projectName, id string, result *update.UpdateResult) {
+	if id == "" {
+		return
+	}
+	s.history.Finish(projectName, id, historyStatus(result.Success, false), result.Error)
+}
+
+// handleProjectHistory handles GET /projects/{name}/history[?limit=N]
+func (s *Server) handleProjectHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.history.List(r.PathValue("name"), limit))
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dockmon/compose-service/internal/history"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHandleProjectHistory(t *testing.T) {
+	store, err := history.NewStore("", logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < defaultHistoryLimit+2; i++ {
+		store.Start(history.Entry{DeploymentID: fmt.Sprintf("d%d", i), ProjectName: "web", Action: "up"})
+	}
+	s := &Server{history: store}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects/{name}/history", s.handleProjectHistory)
+
+	get := func(path string) (int, []history.Entry) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var entries []history.Entry
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		return rec.Code, entries
+	}
+
+	if code, entries := get("/projects/web/history"); code != http.StatusOK || len(entries) != defaultHistoryLimit || entries[0].DeploymentID != fmt.Sprintf("d%d", defaultHistoryLimit+1) {
+		t.Errorf("default: status=%d entries=%d, want the newest %d", code, len(entries), defaultHistoryLimit)
+	}
+	if code, entries := get("/projects/web/history?limit=3"); code != http.StatusOK || len(entries) != 3 {
+		t.Errorf("limit=3: status=%d entries=%d", code, len(entries))
+	}
+	if code, entries := get("/projects/other/history"); code != http.StatusOK || entries == nil || len(entries) != 0 {
+		t.Errorf("unknown project: status=%d entries=%v, want an empty list", code, entries)
+	}
+	for _, limit := range []string{"0", "-1", "x"} {
+		if code, _ := get("/projects/web/history?limit=" + limit); code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status=%d, want 400", limit, code)
+		}
+	}
+}
+
+func TestRequesterFrom(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/deploy", nil)
+	r.Header.Set("X-DockMon-User", "admin")
+	r.Header.Set("X-DockMon-Source", "ui")
+	if got := requesterFrom(r); got.User != "admin" || got.Source != "ui" {
+		t.Errorf("requesterFrom = %+v", got)
+	}
+}
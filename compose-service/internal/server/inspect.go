@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// ImageInspectHTTPRequest is the HTTP request body for /image/inspect
+type ImageInspectHTTPRequest struct {
+	Image        string                `json:"image"`
+	RegistryAuth *registry.Credentials `json:"registry_auth,omitempty"`
+	// Platform as os/arch[/variant]; defaults to linux/amd64
+	Platform string `json:"platform,omitempty"`
+	// IncludeTags lists the repository's tags in the response
+	IncludeTags bool `json:"include_tags,omitempty"`
+}
+
+// ImageInspectHTTPResponse is the response for /image/inspect
+type ImageInspectHTTPResponse struct {
+	*registry.ImageInfo
+	CurrentTag    string   `json:"current_tag,omitempty"`
+	LatestVersion string   `json:"latest_version,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// handleImageInspect handles the /image/inspect endpoint: it looks up an
+// image's manifest digest, labels and (for version tags) the newest
+// available version straight from the registry, without pulling.
+func (s *Server) handleImageInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request
+	var req ImageInspectHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if req.Image == "" {
+		http.Error(w, "Missing required field: image", http.StatusBadRequest)
+		return
+	}
+	platform := registry.Platform{OS: "linux", Architecture: "amd64"}
+	if req.Platform != "" {
+		var err error
+		if platform, err = registry.ParsePlatform(req.Platform); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	client := registry.NewClient(req.RegistryAuth)
+	info, err := client.Inspect(ctx, req.Image, platform)
+	if err != nil {
+		s.log.WithError(err).WithField("image", req.Image).Warn("Failed to inspect image in registry")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp := ImageInspectHTTPResponse{
+		ImageInfo:  info,
+		CurrentTag: registry.Tag(req.Image),
+	}
+	if req.IncludeTags || registry.IsVersionTag(resp.CurrentTag) {
+		tags, err := client.ListTags(ctx, req.Image)
+		if err != nil {
+			// The manifest lookup succeeded, so report it without tags
+			s.log.WithError(err).WithField("image", req.Image).Warn("Failed to list image tags")
+		} else {
+			resp.LatestVersion = registry.LatestVersion(resp.CurrentTag, tags)
+			if req.IncludeTags {
+				resp.Tags = tags
+			}
+		}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"image":  req.Image,
+		"digest": info.Digest,
+	}).Debug("Inspected image in registry")
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.log.WithError(err).Error("Failed to encode image inspect response")
+	}
+}
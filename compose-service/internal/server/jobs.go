@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// JobAcceptedResponse is returned by POST /deploy?async=true
+type JobAcceptedResponse struct {
+	JobID        string `json:"job_id"`
+	DeploymentID string `json:"deployment_id"`
+	Status       string `json:"status"`
+}
+
+// handleGetJob handles GET /jobs/{id}
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleJobEvents handles GET /jobs/{id}/events, streaming the job's events
+// as SSE. Each event carries its sequence as the SSE id, so a client can
+// resume with Last-Event-ID (or ?after=N) from any point, including after
+// the job has finished.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+
+	after := 0
+	resumeFrom := r.Header.Get("Last-Event-ID")
+	if resumeFrom == "" {
+		resumeFrom = r.URL.Query().Get("after")
+	}
+	if resumeFrom != "" {
+		n, err := strconv.Atoi(resumeFrom)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid event id", http.StatusBadRequest)
+			return
+		}
+		after = n
+	}
+
+	if _, ok := s.jobs.Get(jobID); !ok {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	// Keepalive ticker - send comment every 15s to prevent connection timeout
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		events, finished, changed, ok := s.jobs.EventsAfter(jobID, after)
+		if !ok {
+			return // Job pruned while streaming
+		}
+
+		for _, event := range events {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, event.Data)
+			after = event.Seq
+		}
+		flusher.Flush()
+
+		if finished {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ticker.C:
+			// SSE keepalive (comment line - ignored by SSE parsers)
+			fmt.Fprintf(w, ": keepalive %d\n\n", time.Now().Unix())
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/metrics"
+)
+
+// DefaultMaxConcurrentDeployments caps simultaneous deployments across all
+// projects and hosts. 0 disables the cap.
+const DefaultMaxConcurrentDeployments = 4
+
+// deployLimiter serialises deployments per project and caps how many run at
+// once. Deployments are rejected rather than queued, so callers get an
+// immediate answer and decide themselves whether to retry.
+type deployLimiter struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	running       int               // Deployments holding a global slot
+	active        map[string]string // key: host+project, value: deployment ID holding it
+}
+
+// limitError is returned when a deployment can't start right now.
+type limitError struct {
+	status  int
+	message string
+}
+
+func (e *limitError) Error() string { return e.message }
+
+func newDeployLimiter(maxConcurrent int) *deployLimiter {
+	metrics.Global.SetMaxConcurrentDeployments(maxConcurrent)
+	return &deployLimiter{
+		maxConcurrent: maxConcurrent,
+		active:        make(map[string]string),
+	}
+}
+
+// projectKeys identify the project on each Docker host the request targets,
+// so equally named projects on different hosts don't block each other.
+func projectKeys(req compose.DeployRequest) []string {
+	if len(req.Targets) == 0 {
+		return []string{req.DockerHost + "|" + req.ProjectName}
+	}
+	keys := make([]string, len(req.Targets))
+	for i, t := range req.Targets {
+		keys[i] = t.DockerHost + "|" + req.ProjectName
+	}
+	return keys
+}
+
+// acquire reserves the project on every targeted host plus one global slot
+// (a multi-target request counts once). The returned release must be called
+// when the deployment finishes; extra calls are no-ops.
+func (l *deployLimiter) acquire(req compose.DeployRequest) (func(), *limitError) {
+	keys := projectKeys(req)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if holder, busy := l.active[key]; busy {
+			metrics.Global.RecordProjectConflict()
+			return nil, &limitError{
+				status:  http.StatusConflict,
+				message: fmt.Sprintf("project %s already has a deployment in progress (%s)", req.ProjectName, holder),
+			}
+		}
+	}
+
+	if l.maxConcurrent > 0 && l.running >= l.maxConcurrent {
+		metrics.Global.RecordDeploymentThrottled()
+		return nil, &limitError{
+			status:  http.StatusTooManyRequests,
+			message: fmt.Sprintf("too many concurrent deployments (limit %d)", l.maxConcurrent),
+		}
+	}
+
+	for _, key := range keys {
+		l.active[key] = req.DeploymentID
+	}
+	l.running++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			for _, key := range keys {
+				delete(l.active, key)
+			}
+			l.running--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// writeLimitError writes a rejection with a retry hint for throttled requests.
+func writeLimitError(w http.ResponseWriter, err *limitError) {
+	if err.status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "5")
+	}
+	http.Error(w, err.message, err.status)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+)
+
+func mustAcquire(t *testing.T, l *deployLimiter, req compose.DeployRequest) func() {
+	t.Helper()
+	release, err := l.acquire(req)
+	if err != nil {
+		t.Fatalf("acquire(%s) = %v, want a slot", req.DeploymentID, err)
+	}
+	return release
+}
+
+func TestDeployLimiter_ConflictSameHostAndProject(t *testing.T) {
+	l := newDeployLimiter(0)
+	release := mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d1", DockerHost: "tcp://a:2376", ProjectName: "web"})
+
+	_, err := l.acquire(compose.DeployRequest{DeploymentID: "d2", DockerHost: "tcp://a:2376", ProjectName: "web"})
+	if err == nil || err.status != http.StatusConflict {
+		t.Fatalf("second deploy of the project = %v, want 409", err)
+	}
+
+	release()
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d3", DockerHost: "tcp://a:2376", ProjectName: "web"})
+}
+
+func TestDeployLimiter_SameProjectOtherHost(t *testing.T) {
+	l := newDeployLimiter(0)
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d1", DockerHost: "tcp://a:2376", ProjectName: "web"})
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d2", DockerHost: "tcp://b:2376", ProjectName: "web"})
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d3", ProjectName: "web"})
+}
+
+func TestDeployLimiter_MultiTarget(t *testing.T) {
+	l := newDeployLimiter(2)
+	release := mustAcquire(t, l, compose.DeployRequest{
+		DeploymentID: "multi",
+		ProjectName:  "web",
+		Targets: []compose.DeployTarget{
+			{Name: "a", DockerHost: "tcp://a:2376"},
+			{Name: "b", DockerHost: "tcp://b:2376"},
+		},
+	})
+
+	for _, host := range []string{"tcp://a:2376", "tcp://b:2376"} {
+		_, err := l.acquire(compose.DeployRequest{DeploymentID: "single", DockerHost: host, ProjectName: "web"})
+		if err == nil || err.status != http.StatusConflict {
+			t.Errorf("deploy to %s = %v, want 409 while the multi-target deploy holds it", host, err)
+		}
+	}
+
+	// The multi-target deploy took one of the two global slots
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "other", DockerHost: "tcp://c:2376", ProjectName: "web"})
+
+	release()
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "single", DockerHost: "tcp://a:2376", ProjectName: "web"})
+}
+
+func TestDeployLimiter_MaxConcurrent(t *testing.T) {
+	l := newDeployLimiter(1)
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d1", ProjectName: "one"})
+
+	_, err := l.acquire(compose.DeployRequest{DeploymentID: "d2", ProjectName: "two"})
+	if err == nil || err.status != http.StatusTooManyRequests {
+		t.Fatalf("deploy over the limit = %v, want 429", err)
+	}
+
+	w := httptest.NewRecorder()
+	writeLimitError(w, err)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" {
+		t.Errorf("response = %d Retry-After %q, want 429 with Retry-After 5", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	w = httptest.NewRecorder()
+	writeLimitError(w, &limitError{status: http.StatusConflict, message: "busy"})
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") != "" {
+		t.Errorf("conflict response = %d Retry-After %q, want 409 without a retry hint", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestDeployLimiter_ZeroMeansUnlimited(t *testing.T) {
+	l := newDeployLimiter(0)
+	for _, project := range []string{"a", "b", "c", "d", "e", "f"} {
+		mustAcquire(t, l, compose.DeployRequest{DeploymentID: project, ProjectName: project})
+	}
+}
+
+func TestDeployLimiter_ReleaseTwiceFreesOneSlot(t *testing.T) {
+	l := newDeployLimiter(2)
+	release := mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d1", ProjectName: "one"})
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d2", ProjectName: "two"})
+
+	release()
+	release()
+
+	mustAcquire(t, l, compose.DeployRequest{DeploymentID: "d3", ProjectName: "three"})
+	if _, err := l.acquire(compose.DeployRequest{DeploymentID: "d4", ProjectName: "four"}); err == nil || err.status != http.StatusTooManyRequests {
+		t.Errorf("deploy after a double release = %v, want 429 (only one slot freed)", err)
+	}
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/sirupsen/logrus"
+)
+
+// LogDownloadHTTPRequest is the HTTP request body for /logs/download
+type LogDownloadHTTPRequest struct {
+	ContainerID string `json:"container_id"`
+	Since       string `json:"since,omitempty"` // RFC 3339, Unix timestamp or duration such as "2h"
+	Until       string `json:"until,omitempty"`
+	Timestamps  bool   `json:"timestamps,omitempty"`
+	MaxBytes    int64  `json:"max_bytes,omitempty"` // Uncompressed limit; default 50 MiB, at most 500 MiB
+	// For remote hosts (mTLS)
+	DockerHost string `json:"docker_host,omitempty"`
+	TLSCACert  string `json:"tls_ca_cert,omitempty"`
+	TLSCert    string `json:"tls_cert,omitempty"`
+	TLSKey     string `json:"tls_key,omitempty"`
+	// TLS files or a Docker context instead of the fields above
+	sharedDocker.HostSource
+}
+
+// handleLogDownload handles the /logs/download endpoint: it streams a
+// container's full (or time-bounded) log as a gzip file attachment. The
+// X-Log-Limit header carries the applied size limit; a truncated log ends
+// with a "[log truncated at N bytes]" line.
+func (s *Server) handleLogDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request
+	var req LogDownloadHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !resolveHostSource(w, req.HostSource, &req.DockerHost, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		return
+	}
+
+	// Validate required fields
+	if req.ContainerID == "" {
+		http.Error(w, "Missing required field: container_id", http.StatusBadRequest)
+		return
+	}
+	if req.MaxBytes < 0 || req.MaxBytes > sharedDocker.MaxLogDownloadMaxBytes {
+		http.Error(w, fmt.Sprintf("max_bytes must be between 0 and %d", sharedDocker.MaxLogDownloadMaxBytes), http.StatusBadRequest)
+		return
+	}
+
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer releaseClient()
+
+	// Headers go out with the first gzip bytes, so failures before any
+	// log is read can still be reported as an HTTP error
+	out := &lazyAttachmentWriter{w: w, containerID: req.ContainerID, maxBytes: sharedDocker.LogDownloadMaxBytes(req.MaxBytes)}
+	result, err := sharedDocker.WriteContainerLogs(r.Context(), dockerClient, req.ContainerID, sharedDocker.LogDownloadOptions{
+		Since:      req.Since,
+		Until:      req.Until,
+		Timestamps: req.Timestamps,
+		MaxBytes:   req.MaxBytes,
+	}, out)
+	if err != nil {
+		s.log.WithError(err).WithField("container_id", req.ContainerID).Error("Failed to download container logs")
+		if !out.started {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		return
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"container_id": result.ContainerID,
+		"log_bytes":    result.Bytes,
+		"truncated":    result.Truncated,
+	}).Info("Downloaded container logs")
+}
+
+// lazyAttachmentWriter writes the download headers just before the first
+// body bytes
+type lazyAttachmentWriter struct {
+	w           http.ResponseWriter
+	containerID string
+	maxBytes    int64
+	started     bool
+}
+
+func (l *lazyAttachmentWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.started = true
+		// Shorten full IDs the way the docker CLI does; names are kept
+		name := l.containerID
+		if len(name) == 64 {
+			name = name[:12]
+		}
+		filename := fmt.Sprintf("%s-%s.log.gz", name, time.Now().UTC().Format("20060102-150405"))
+		h := l.w.Header()
+		h.Set("Content-Type", "application/gzip")
+		h.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		h.Set("X-Log-Limit", fmt.Sprint(l.maxBytes))
+		l.w.WriteHeader(http.StatusOK)
+	}
+	return l.w.Write(p)
+}
+
+// ProjectLogsHTTPRequest is the HTTP request body for /logs/project
+type ProjectLogsHTTPRequest struct {
+	ProjectName string   `json:"project_name"`
+	Services    []string `json:"services,omitempty"` // All services when empty
+	Tail        string   `json:"tail,omitempty"`     // Lines per container to start from; default "all"
+	Since       string   `json:"since,omitempty"`
+	Follow      bool     `json:"follow,omitempty"`
+	Format      string   `json:"format,omitempty"` // "text" (default) or "json" for one JSON object per line
+	// For remote hosts (mTLS)
+	DockerHost string `json:"docker_host,omitempty"`
+	TLSCACert  string `json:"tls_ca_cert,omitempty"`
+	TLSCert    string `json:"tls_cert,omitempty"`
+	TLSKey     string `json:"tls_key,omitempty"`
+	// TLS files or a Docker context instead of the fields above
+	sharedDocker.HostSource
+}
+
+// handleProjectLogs handles the /logs/project endpoint: it streams the logs
+// of every container in a compose project, interleaved and prefixed with
+// the container's service name like docker compose logs. With follow the
+// response stays open until the client disconnects.
+func (s *Server) handleProjectLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request
+	var req ProjectLogsHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !resolveHostSource(w, req.HostSource, &req.DockerHost, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		return
+	}
+
+	// Validate required fields
+	if req.ProjectName == "" {
+		http.Error(w, "Missing required field: project_name", http.StatusBadRequest)
+		return
+	}
+	if req.Format == "" {
+		req.Format = "text"
+	}
+	if req.Format != "text" && req.Format != "json" {
+		http.Error(w, "format must be text or json", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer releaseClient()
+
+	// Headers go out with the first line, so a missing project is still a 404
+	started := false
+	width := 0
+	encoder := json.NewEncoder(w)
+	err = compose.FollowProjectLogs(r.Context(), dockerClient, req.ProjectName, compose.ProjectLogOptions{
+		Services: req.Services,
+		Tail:     req.Tail,
+		Since:    req.Since,
+		Follow:   req.Follow,
+	}, func(line compose.ProjectLogLine) error {
+		if !started {
+			started = true
+			if req.Format == "json" {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			} else {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+			w.WriteHeader(http.StatusOK)
+		}
+		var err error
+		if req.Format == "json" {
+			err = encoder.Encode(line)
+		} else {
+			// Widen the prefix column as longer names appear
+			width = max(width, len(line.Container))
+			_, err = fmt.Fprintln(w, line.Format(width))
+		}
+		flusher.Flush()
+		return err
+	})
+
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		if !started {
+			w.WriteHeader(http.StatusOK)
+		}
+	case errors.Is(err, compose.ErrProjectNotFound):
+		http.Error(w, fmt.Sprintf("%v: %s", err, req.ProjectName), http.StatusNotFound)
+	default:
+		s.log.WithError(err).WithField("project_name", req.ProjectName).Warn("Project log stream failed")
+		if !started {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	}
+}
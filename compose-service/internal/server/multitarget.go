@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/dockmon/compose-service/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// handleMultiDeploy runs a multi-target deployment in the mode the caller
+// asked for. release frees the limiter slot when the deployment finishes.
+func (s *Server) handleMultiDeploy(w http.ResponseWriter, r *http.Request, req compose.DeployRequest, release func()) {
+	// Per-target timeouts surface as failed target results
+	d := deployRun{
+		deploymentID: req.DeploymentID,
+		projectName:  req.ProjectName,
+		timeout:      deployTimeout(req),
+		parallel:     len(req.Targets),
+		release:      release,
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			result := s.deployToTargets(ctx, req, onProgress)
+			var errMsg string
+			if !result.Success {
+				errMsg = fmt.Sprintf("deployment failed on: %v", result.FailedTargets)
+			}
+			return deployOutcome{
+				result: result,
+				status: jobStatus(result.Success, result.PartialSuccess, result.Cancelled),
+				errMsg: errMsg,
+			}
+		},
+	}
+
+	// Async mode returns a job id immediately instead of holding the connection
+	if r.URL.Query().Get("async") == "true" {
+		s.startAsyncJob(w, d)
+		return
+	}
+	if r.Header.Get("Accept") == "text/event-stream" {
+		s.streamSSE(w, r, d)
+		return
+	}
+	defer release()
+
+	ctx, stop := s.withDeployCancel(r.Context(), req.DeploymentID)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, deployTimeout(req))
+	defer cancel()
+
+	result := s.deployToTargets(ctx, req, nil)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		s.log.WithError(err).Error("Failed to encode deploy response")
+	}
+}
+
+// deployToTargets deploys the project to every target in parallel. Progress
+// events are tagged with the target name; onProgress may be nil and must be
+// safe for concurrent use.
+func (s *Server) deployToTargets(ctx context.Context, req compose.DeployRequest, onProgress compose.ProgressCallback) *compose.MultiDeployResult {
+	s.log.WithFields(logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"project_name":  req.ProjectName,
+		"action":        req.Action,
+		"targets":       len(req.Targets),
+	}).Info("Multi-target deployment started")
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]*compose.DeployResult, len(req.Targets))
+	)
+
+	for _, target := range req.Targets {
+		wg.Add(1)
+		go func(target compose.DeployTarget) {
+			defer wg.Done()
+			result := s.deployTarget(ctx, req.ForTarget(target), target.Name, onProgress)
+			mu.Lock()
+			results[target.Name] = result
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	multi := compose.NewMultiDeployResult(req.DeploymentID, req.Action, results)
+	s.finishMultiHistory(req, multi)
+	s.log.WithFields(logrus.Fields{
+		"deployment_id":  req.DeploymentID,
+		"success":        multi.Success,
+		"partial":        multi.PartialSuccess,
+		"failed_targets": multi.FailedTargets,
+		"cancelled":      multi.Cancelled,
+	}).Info("Multi-target deployment completed")
+	return multi
+}
+
+// deployTarget runs one target of a multi-target deployment.
+func (s *Server) deployTarget(ctx context.Context, req compose.DeployRequest, targetName string, onProgress compose.ProgressCallback) *compose.DeployResult {
+	startTime := time.Now()
+	metrics.Global.IncrementActive()
+	defer metrics.Global.DecrementActive()
+
+	var result *compose.DeployResult
+	dockerClient, releaseClient, err := s.createDockerClient(req)
+	if err != nil {
+		s.log.WithError(err).WithField("target", targetName).Error("Failed to create Docker client")
+		result = &compose.DeployResult{
+			DeploymentID: req.DeploymentID,
+			Action:       req.Action,
+			Success:      false,
+			Error:        compose.NewDockerError(err.Error()),
+		}
+	} else {
+		defer releaseClient()
+
+		var opts []compose.Option
+		if onProgress != nil {
+			opts = append(opts, compose.WithProgressCallback(func(event compose.ProgressEvent) {
+				event.Target = targetName
+				onProgress(event)
+			}))
+		}
+		result = compose.NewService(dockerClient, s.log, opts...).Deploy(ctx, req)
+	}
+
+	// Record metrics
+	metrics.Global.RecordDeployment(result.Success, result.PartialSuccess, time.Since(startTime))
+	return result
+}
+
+// deployTimeout returns the request's operation timeout
+func deployTimeout(req compose.DeployRequest) time.Duration {
+	timeout := time.Duration(req.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Minute // Default
+	}
+	return timeout
+}
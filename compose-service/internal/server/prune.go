@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/sirupsen/logrus"
+)
+
+// imagePruneTimeout bounds a policy-based prune, like the agent's scheduled
+// prune runs
+const imagePruneTimeout = 10 * time.Minute
+
+// ImagePruneHTTPRequest is the HTTP request body for POST /images/prune,
+// the direct-host counterpart of the agent's scheduled prune_images job
+type ImagePruneHTTPRequest struct {
+	sharedDocker.ImagePrunePolicy
+	// ScheduleID is stamped on the activity event when DockMon's scheduler
+	// triggered the prune
+	ScheduleID string `json:"schedule_id,omitempty"`
+	// For remote hosts (mTLS)
+	DockerHost string `json:"docker_host,omitempty"`
+	TLSCACert  string `json:"tls_ca_cert,omitempty"`
+	TLSCert    string `json:"tls_cert,omitempty"`
+	TLSKey     string `json:"tls_key,omitempty"`
+	// TLS files or a Docker context instead of the fields above
+	sharedDocker.HostSource
+}
+
+// handleImagePrune handles POST /images/prune: it removes unused images
+// under the policy's safety rules, or with dry_run only reports what would
+// be removed, and returns the report. The result is announced on the
+// activity stream for requests that name their host.
+func (s *Server) handleImagePrune(w http.ResponseWriter, r *http.Request) {
+	var req ImagePruneHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !resolveHostSource(w, req.HostSource, &req.DockerHost, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		return
+	}
+	if err := req.ImagePrunePolicy.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create Docker client: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer releaseClient()
+
+	ctx, cancel := context.WithTimeout(r.Context(), imagePruneTimeout)
+	defer cancel()
+
+	report, err := sharedDocker.PruneImagesWithPolicy(ctx, dockerClient, req.ImagePrunePolicy)
+	if err != nil {
+		s.log.WithError(err).Error("Failed to prune images")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"dry_run":         report.DryRun,
+		"removed":         report.RemovedCount,
+		"failed":          len(report.Failed),
+		"space_reclaimed": report.SpaceReclaimed,
+	}).Info("Pruned images")
+
+	if hostID := hostIDFrom(r); hostID != "" {
+		event := activity.ImagesPruned(report.RemovedCount, len(report.Failed), report.SpaceReclaimed, report.DryRun)
+		event.HostID = hostID
+		if req.ScheduleID != "" {
+			event.Attributes[activity.AttrScheduleID] = req.ScheduleID
+		}
+		s.activity.Publish(event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.log.WithError(err).Error("Failed to encode image prune response")
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/darthnorse/dockmon-shared/compose"
+	"github.com/sirupsen/logrus"
+)
+
+// RollbackHTTPRequest is the HTTP request body for /rollback. It takes the
+// same connection, directory, and health fields as /deploy; compose content
+// comes from the stored revision instead of the request.
+type RollbackHTTPRequest struct {
+	compose.DeployRequest
+	// RevisionID selects the revision to restore. It is required: the most
+	// recent revision is the running deployment, so "latest" would only flip
+	// between the two newest revisions on repeated rollbacks.
+	RevisionID int `json:"revision_id"`
+}
+
+// handleRollback handles the /rollback endpoint: it redeploys a stored stack
+// revision with its images pinned to the recorded digests. Supports the same
+// response modes as /deploy (SSE, JSON, ?async=true).
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Parse request
+	var req RollbackHTTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if req.DeploymentID == "" || req.ProjectName == "" {
+		http.Error(w, "Missing required fields: deployment_id, project_name", http.StatusBadRequest)
+		return
+	}
+	if req.RevisionID <= 0 {
+		http.Error(w, "revision_id must be a positive revision ID", http.StatusBadRequest)
+		return
+	}
+	if err := req.ResolveHostSources(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rev, err := compose.LoadRevision(req.StacksDir, req.ProjectName, req.RevisionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"project_name":  req.ProjectName,
+		"revision":      rev.ID,
+	}).Info("Rolling back stack")
+
+	s.dispatchDeploy(w, r, compose.RollbackRequest(rev, req.DeployRequest))
+}
+
+// handleListRevisions handles GET /revisions?project_name=...[&stacks_dir=...]
+func (s *Server) handleListRevisions(w http.ResponseWriter, r *http.Request) {
+	projectName := r.URL.Query().Get("project_name")
+	if projectName == "" {
+		http.Error(w, "Missing required parameter: project_name", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := compose.ListRevisions(r.URL.Query().Get("stacks_dir"), projectName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
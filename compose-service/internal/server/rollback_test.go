@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleRollback_RequiresRevisionID(t *testing.T) {
+	s := &Server{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rollback", s.handleRollback)
+
+	for _, body := range []string{
+		`{"deployment_id":"d1","project_name":"web"}`,
+		`{"deployment_id":"d1","project_name":"web","revision_id":0}`,
+		`{"deployment_id":"d1","project_name":"web","revision_id":-1}`,
+	} {
+		if rec := serve(mux, http.MethodPost, "/rollback", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status=%d, want 400", body, rec.Code)
+		}
+	}
+}
@@ -7,12 +7,18 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/admin"
 	"github.com/darthnorse/dockmon-shared/compose"
 	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/darthnorse/dockmon-shared/update"
 	"github.com/docker/docker/client"
+	"github.com/dockmon/compose-service/internal/envsets"
+	"github.com/dockmon/compose-service/internal/history"
+	"github.com/dockmon/compose-service/internal/jobs"
 	"github.com/dockmon/compose-service/internal/metrics"
 	"github.com/sirupsen/logrus"
 )
@@ -22,6 +28,10 @@ const (
 	DefaultSocketPath = "/tmp/compose.sock"
 	// DefaultHealthTimeout is the timeout for health checks in seconds
 	DefaultHealthTimeout = 2
+	// APILevel is reported in /health so the backend can tell an outdated
+	// compose service from a broken one. Bump it whenever the backend
+	// starts relying on a new endpoint or request field.
+	APILevel = 2
 )
 
 // Server represents the compose HTTP server
@@ -32,10 +42,29 @@ type Server struct {
 	initialized bool
 	listener    net.Listener
 	httpServer  *http.Server
+	jobsDir     string              // Optional on-disk persistence for async deploy jobs
+	jobs        *jobs.Store         // Async deploy jobs
+	historyDir  string              // Optional on-disk persistence for project history
+	history     *history.Store      // Per-project deploy/update history
+	baseCtx     context.Context     // Server lifetime; parent of async jobs
+	limiter     *deployLimiter      // Per-project locking and global deploy cap
+	quota       *compose.HostQuota  // Default host quota for requests without one
+	clients     *sharedDocker.Pool  // Docker clients shared across requests
+	activity    *activity.Publisher // Optional: announces updates and deployments
+	envSets     *envsets.Store      // Optional: variable sets deployments reference by name
+	deployHosts sync.Map            // Deployment ID -> requesting host ID, for activity events
+	cancels     sync.Map            // Deployment ID -> *deployCancel of running deployments
+	version     string              // Build version reported in /health
+	logLevel    *admin.LogLevel     // Runtime log level, /admin/loglevel
+	config      interface{}         // Effective settings reported by /admin/config
 }
 
-// NewServer creates a new compose server
-func NewServer(socketPath string, log *logrus.Logger) *Server {
+// NewServer creates a new compose server. jobsDir and historyDir enable
+// on-disk persistence of async deployment jobs and per-project deployment
+// history; empty keeps them in memory only. maxConcurrent caps simultaneous
+// deployments (0 = unlimited). quota is the default host quota for
+// deployments that don't carry one (nil = no check).
+func NewServer(socketPath, jobsDir, historyDir string, maxConcurrent int, quota *compose.HostQuota, log *logrus.Logger) *Server {
 	if socketPath == "" {
 		socketPath = DefaultSocketPath
 	}
@@ -45,14 +74,64 @@ func NewServer(socketPath string, log *logrus.Logger) *Server {
 		log:         log,
 		startTime:   time.Now(),
 		initialized: true,
+		jobsDir:     jobsDir,
+		historyDir:  historyDir,
+		limiter:     newDeployLimiter(maxConcurrent),
+		quota:       quota,
+		clients:     sharedDocker.NewPool(0, 0),
+		version:     "dev",
+		logLevel:    admin.NewLogLevel(admin.Logrus(log), log.Warnf),
 	}
 }
 
+// SetVersion sets the build version reported in /health. Must be called
+// before Start.
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
+// SetActivityPublisher enables update and deployment events on the
+// stats-service activity stream. Must be called before Start.
+func (s *Server) SetActivityPublisher(p *activity.Publisher) {
+	s.activity = p
+}
+
+// SetConfig sets the settings /admin/config reports, with secrets redacted.
+// Must be called before Start.
+func (s *Server) SetConfig(config interface{}) {
+	s.config = config
+}
+
+// SetEnvSets enables variable sets (env_set in deploy requests). Must be
+// called before Start.
+func (s *Server) SetEnvSets(store *envsets.Store) {
+	s.envSets = store
+}
+
 // Start starts the HTTP server on the Unix socket
 func (s *Server) Start(ctx context.Context) error {
 	// Clean up stale temp files from previous crashes
 	compose.CleanupStaleFiles(s.log)
 
+	// Async deploy jobs (restores persisted jobs when a directory is configured)
+	jobStore, err := jobs.NewStore(s.jobsDir, s.log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize job store: %w", err)
+	}
+	s.jobs = jobStore
+
+	// Per-project deployment history (restored when a directory is configured)
+	historyStore, err := history.NewStore(s.historyDir, s.log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize history store: %w", err)
+	}
+	s.history = historyStore
+	s.baseCtx = ctx
+
+	// Health-check pooled Docker clients and close idle ones
+	go s.clients.Run(ctx)
+	go s.activity.Run(ctx)
+
 	// Remove existing socket file if it exists
 	if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove existing socket: %w", err)
@@ -75,7 +154,27 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/deploy", s.handleDeploy)
+	mux.HandleFunc("POST /deploy/cancel", s.handleCancelDeploy)
 	mux.HandleFunc("/update", s.handleUpdate)
+	mux.HandleFunc("/rollback", s.handleRollback)
+	mux.HandleFunc("/adopt", s.handleAdopt)
+	mux.HandleFunc("POST /docker-contexts/import", s.handleImportDockerContexts)
+	mux.HandleFunc("/image/inspect", s.handleImageInspect)
+	mux.HandleFunc("POST /images/prune", s.handleImagePrune)
+	mux.HandleFunc("/logs/download", s.handleLogDownload)
+	mux.HandleFunc("/logs/project", s.handleProjectLogs)
+	mux.HandleFunc("GET /revisions", s.handleListRevisions)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancelJob)
+	mux.HandleFunc("GET /jobs/{id}/events", s.handleJobEvents)
+	mux.HandleFunc("POST /projects", s.handleListProjects)
+	mux.HandleFunc("POST /projects/bulk", s.handleBulk)
+	mux.HandleFunc("GET /projects/{name}/history", s.handleProjectHistory)
+	mux.HandleFunc("GET /env-sets", s.handleListEnvSets)
+	mux.HandleFunc("PUT /env-sets/{name}", s.handlePutEnvSet)
+	mux.HandleFunc("DELETE /env-sets/{name}", s.handleDeleteEnvSet)
+	mux.HandleFunc("/admin/loglevel", s.logLevel.Handler())
+	mux.HandleFunc("GET /admin/config", admin.ConfigHandler(func() interface{} { return s.config }))
 
 	s.httpServer = &http.Server{
 		Handler:      mux,
@@ -118,6 +217,9 @@ func (s *Server) Stop() error {
 // HealthResponse represents the health check response
 type HealthResponse struct {
 	Status       string                 `json:"status"`          // "ok" or "degraded"
+	Service      string                 `json:"service"`         // Always "dockmon-compose"
+	Version      string                 `json:"version"`         // Build version, "dev" for local builds
+	APILevel     int                    `json:"api_level"`       // See APILevel
 	DockerOK     bool                   `json:"docker_ok"`       // Can connect to local Docker
 	ComposeReady bool                   `json:"compose_ready"`   // Compose SDK initialized
 	UptimeSecs   int64                  `json:"uptime_secs"`     // Seconds since startup
@@ -128,6 +230,9 @@ type HealthResponse struct {
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	resp := HealthResponse{
 		Status:       "ok",
+		Service:      "dockmon-compose",
+		Version:      s.version,
+		APILevel:     APILevel,
 		UptimeSecs:   int64(time.Since(s.startTime).Seconds()),
 		ComposeReady: s.initialized,
 	}
@@ -136,12 +241,12 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), DefaultHealthTimeout*time.Second)
 	defer cancel()
 
-	localClient, err := sharedDocker.CreateLocalClient()
+	localClient, releaseClient, err := s.clients.Get("", "", "", "")
 	if err != nil {
 		resp.Status = "degraded"
 		resp.DockerOK = false
 	} else {
-		defer localClient.Close()
+		defer releaseClient()
 		_, err = localClient.Ping(ctx)
 		resp.DockerOK = (err == nil)
 		if !resp.DockerOK {
@@ -159,7 +264,8 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// handleDeploy handles the /deploy endpoint with SSE streaming
+// handleDeploy handles the /deploy endpoint with SSE streaming, a blocking
+// JSON response, or (with ?async=true) a job that is polled via /jobs/{id}
 func (s *Server) handleDeploy(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -178,14 +284,96 @@ func (s *Server) handleDeploy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing required fields: deployment_id, project_name, compose_yaml", http.StatusBadRequest)
 		return
 	}
+	if err := req.ResolveHostSources(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateTargets(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateQuotas(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateSecrets(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.ValidateTemplate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.dispatchDeploy(w, r, req)
+}
+
+// dispatchDeploy runs a validated deployment in the mode the caller asked for
+// (async job, SSE, or blocking JSON), subject to the deploy limiter.
+func (s *Server) dispatchDeploy(w http.ResponseWriter, r *http.Request, req compose.DeployRequest) {
+	// Requests without a quota of their own get the service default
+	if req.Quota == nil {
+		req.Quota = s.quota
+	}
+	// Variable sets are resolved here so their values never travel with the
+	// request
+	if err := s.resolveEnvSet(&req); err != nil {
+		writeEnvSetError(w, err)
+		return
+	}
+
+	// Reject concurrent deploys of the same project and enforce the global cap
+	releaseSlot, limitErr := s.limiter.acquire(req)
+	if limitErr != nil {
+		s.log.WithFields(logrus.Fields{
+			"deployment_id": req.DeploymentID,
+			"project_name":  req.ProjectName,
+		}).Warn(limitErr.message)
+		writeLimitError(w, limitErr)
+		return
+	}
+
+	// Every path records the outcome before releasing; the fallback covers
+	// paths that end without a result
+	s.startDeployHistory(r, req)
+	release := func() {
+		s.history.Finish(req.ProjectName, req.DeploymentID, history.StatusFailed, "deployment ended without a result")
+		s.deployHosts.Delete(req.DeploymentID)
+		releaseSlot()
+	}
+
+	// Multi-target requests fan out to every host with a combined result
+	if len(req.Targets) > 0 {
+		s.handleMultiDeploy(w, r, req, release)
+		return
+	}
+
+	// Async mode returns a job id immediately instead of holding the connection
+	if r.URL.Query().Get("async") == "true" {
+		s.startAsyncJob(w, s.singleDeploy(req, "async", release))
+		return
+	}
 
 	// Check if client wants SSE
 	acceptHeader := r.Header.Get("Accept")
 	useSSE := acceptHeader == "text/event-stream"
 
 	if useSSE {
-		s.handleDeploySSE(w, r, req)
+		d := s.singleDeploy(req, "SSE", release)
+		// A timeout or disconnect ends the stream right away; the deploy
+		// unwinds in the background
+		d.abort = func() any {
+			errResp := &compose.DeployResult{
+				DeploymentID: req.DeploymentID,
+				Success:      false,
+				Error:        compose.NewInternalError("operation timeout"),
+			}
+			s.finishDeployHistory(req, errResp)
+			return errResp
+		}
+		s.streamSSE(w, r, d)
 	} else {
+		defer release()
 		s.handleDeployJSON(w, r, req)
 	}
 }
@@ -204,29 +392,34 @@ func (s *Server) handleDeployJSON(w http.ResponseWriter, r *http.Request, req co
 	}).Info("Deployment started")
 
 	// Create Docker client
-	dockerClient, err := s.createDockerClient(req)
+	dockerClient, releaseClient, err := s.createDockerClient(req)
 	if err != nil {
 		s.log.WithError(err).Error("Failed to create Docker client")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(compose.DeployResult{
+		result := &compose.DeployResult{
 			DeploymentID: req.DeploymentID,
 			Success:      false,
 			Error:        compose.NewDockerError(err.Error()),
-		})
+		}
+		s.finishDeployHistory(req, result)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(result)
 		return
 	}
-	defer dockerClient.Close()
+	defer releaseClient()
 
 	// Create compose service
 	svc := compose.NewService(dockerClient, s.log)
 
-	// Execute deployment
-	result := svc.Deploy(r.Context(), req)
+	// Execute deployment (cancellable through /deploy/cancel)
+	ctx, stop := s.withDeployCancel(r.Context(), req.DeploymentID)
+	defer stop()
+	result := svc.Deploy(ctx, req)
 
 	// Record metrics
 	duration := time.Since(startTime)
 	metrics.Global.RecordDeployment(result.Success, result.PartialSuccess, duration)
+	s.finishDeployHistory(req, result)
 
 	s.log.WithFields(logrus.Fields{
 		"deployment_id":   req.DeploymentID,
@@ -244,165 +437,101 @@ func (s *Server) handleDeployJSON(w http.ResponseWriter, r *http.Request, req co
 	}
 }
 
-// handleDeploySSE handles deployment with SSE streaming progress
-func (s *Server) handleDeploySSE(w http.ResponseWriter, r *http.Request, req compose.DeployRequest) {
+// singleDeploy describes a single-host deployment for startAsyncJob or
+// streamSSE. mode labels its log lines ("async", "SSE").
+func (s *Server) singleDeploy(req compose.DeployRequest, mode string, release func()) deployRun {
+	return deployRun{
+		deploymentID: req.DeploymentID,
+		projectName:  req.ProjectName,
+		timeout:      deployTimeout(req),
+		release:      release,
+		run: func(ctx context.Context, onProgress compose.ProgressCallback) deployOutcome {
+			result := s.runDeploy(ctx, req, mode, onProgress)
+			var errMsg string
+			if result.Error != nil {
+				errMsg = result.Error.Message
+			}
+			return deployOutcome{
+				result: result,
+				status: jobStatus(result.Success, result.PartialSuccess, result.Cancelled),
+				errMsg: errMsg,
+			}
+		},
+	}
+}
+
+// runDeploy executes a single-host deployment, reporting progress to
+// onProgress (may be nil) and recording metrics and history.
+func (s *Server) runDeploy(ctx context.Context, req compose.DeployRequest, mode string, onProgress compose.ProgressCallback) *compose.DeployResult {
 	startTime := time.Now()
 	metrics.Global.IncrementActive()
 	defer metrics.Global.DecrementActive()
 
-	// Determine operation timeout
-	timeout := time.Duration(req.Timeout) * time.Second
-	if timeout <= 0 {
-		timeout = 30 * time.Minute // Default
-	}
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), timeout)
-	defer cancel()
-
-	// SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "SSE not supported", http.StatusInternalServerError)
-		return
-	}
-
 	s.log.WithFields(logrus.Fields{
 		"deployment_id": req.DeploymentID,
 		"project_name":  req.ProjectName,
 		"action":        req.Action,
 		"host_type":     compose.GetHostType(req),
-	}).Info("Deployment started (SSE)")
+	}).Infof("Deployment started (%s)", mode)
 
-	// Create Docker client
-	dockerClient, err := s.createDockerClient(req)
+	var result *compose.DeployResult
+	dockerClient, releaseClient, err := s.createDockerClient(req)
 	if err != nil {
 		s.log.WithError(err).Error("Failed to create Docker client")
-		errResp := compose.DeployResult{
+		result = &compose.DeployResult{
 			DeploymentID: req.DeploymentID,
 			Success:      false,
 			Error:        compose.NewDockerError(err.Error()),
 		}
-		data, _ := json.Marshal(errResp)
-		fmt.Fprintf(w, "event: complete\ndata: %s\n\n", data)
-		flusher.Flush()
-		return
+		s.finishDeployHistory(req, result)
+		return result
 	}
-	defer dockerClient.Close()
-
-	// Keepalive ticker - send comment every 15s to prevent connection timeout
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	// Channel for deployment result
-	resultCh := make(chan *compose.DeployResult, 1)
-
-	// Progress channel for thread-safe writes
-	progressCh := make(chan compose.ProgressEvent, 100)
-
-	// Start deployment in goroutine
-	go func() {
-		// Create compose service with progress callback
-		svc := compose.NewService(dockerClient, s.log, compose.WithProgressCallback(
-			func(event compose.ProgressEvent) {
-				select {
-				case progressCh <- event:
-				default:
-					// Channel full, skip event (better than blocking)
-				}
-			},
-		))
-
-		result := svc.Deploy(ctx, req)
-		close(progressCh)
-		resultCh <- result
-	}()
+	defer releaseClient()
 
-	// Event loop
-	for {
-		select {
-		case event, ok := <-progressCh:
-			if !ok {
-				continue // Channel closed, wait for result
-			}
-			data, _ := json.Marshal(event)
-			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
-			flusher.Flush()
-
-		case result := <-resultCh:
-			// Record metrics
-			duration := time.Since(startTime)
-			metrics.Global.RecordDeployment(result.Success, result.PartialSuccess, duration)
-
-			s.log.WithFields(logrus.Fields{
-				"deployment_id":   req.DeploymentID,
-				"success":         result.Success,
-				"partial_success": result.PartialSuccess,
-				"duration_secs":   duration.Seconds(),
-				"service_count":   len(result.Services),
-				"failed_count":    len(result.FailedServices),
-			}).Info("Deployment completed (SSE)")
-
-			data, _ := json.Marshal(result)
-			fmt.Fprintf(w, "event: complete\ndata: %s\n\n", data)
-			flusher.Flush()
-			return
+	var opts []compose.Option
+	if onProgress != nil {
+		opts = append(opts, compose.WithProgressCallback(onProgress))
+	}
+	result = compose.NewService(dockerClient, s.log, opts...).Deploy(ctx, req)
 
-		case <-ticker.C:
-			// SSE keepalive (comment line - ignored by SSE parsers)
-			fmt.Fprintf(w, ": keepalive %d\n\n", time.Now().Unix())
-			flusher.Flush()
+	// Record metrics
+	duration := time.Since(startTime)
+	metrics.Global.RecordDeployment(result.Success, result.PartialSuccess, duration)
+	s.finishDeployHistory(req, result)
 
-		case <-ctx.Done():
-			// Timeout or client disconnect
-			errResp := compose.DeployResult{
-				DeploymentID: req.DeploymentID,
-				Success:      false,
-				Error:        compose.NewInternalError("operation timeout"),
-			}
-			data, _ := json.Marshal(errResp)
-			fmt.Fprintf(w, "event: complete\ndata: %s\n\n", data)
-			flusher.Flush()
-			return
-		}
-	}
+	s.log.WithFields(logrus.Fields{
+		"deployment_id":   req.DeploymentID,
+		"success":         result.Success,
+		"partial_success": result.PartialSuccess,
+		"duration_secs":   duration.Seconds(),
+		"service_count":   len(result.Services),
+		"failed_count":    len(result.FailedServices),
+		"cancelled":       result.Cancelled,
+	}).Infof("Deployment completed (%s)", mode)
+	return result
 }
 
-// createDockerClient creates a Docker client based on the request
-func (s *Server) createDockerClient(req compose.DeployRequest) (*client.Client, error) {
-	if req.DockerHost == "" {
-		// Local Docker socket
-		return sharedDocker.CreateLocalClient()
-	}
+// createDockerClient returns a pooled Docker client for the request and the
+// function that gives it back to the pool
+func (s *Server) createDockerClient(req compose.DeployRequest) (*client.Client, func(), error) {
+	return s.clients.Get(req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey)
+}
 
-	// Remote Docker with TLS
-	return sharedDocker.CreateRemoteClient(
-		req.DockerHost,
-		req.TLSCACert,
-		req.TLSCert,
-		req.TLSKey,
-	)
+// createDockerClientForUpdate returns a pooled Docker client for the update
+// endpoint and the function that gives it back to the pool
+func (s *Server) createDockerClientForUpdate(dockerHost, caCert, cert, key string) (*client.Client, func(), error) {
+	return s.clients.Get(dockerHost, caCert, cert, key)
 }
 
-// createDockerClientForUpdate creates a Docker client for the update endpoint
-func (s *Server) createDockerClientForUpdate(dockerHost, caCert, cert, key string) (*client.Client, error) {
-	if dockerHost == "" {
-		// Local Docker socket
-		return sharedDocker.CreateLocalClient()
+// resolveHostSource replaces a request's TLS file or Docker context
+// references with the inline host fields. On failure it writes a 400 and
+// returns false.
+func resolveHostSource(w http.ResponseWriter, src sharedDocker.HostSource, host, caCert, cert, key *string) bool {
+	if err := src.Resolve(host, caCert, cert, key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
 	}
-
-	// Remote Docker with TLS
-	return sharedDocker.CreateRemoteClient(
-		dockerHost,
-		caCert,
-		cert,
-		key,
-	)
+	return true
 }
 
 // UpdateHTTPRequest is the HTTP request body for /update endpoint
@@ -412,11 +541,25 @@ type UpdateHTTPRequest struct {
 	StopTimeout   int                  `json:"stop_timeout,omitempty"`
 	HealthTimeout int                  `json:"health_timeout,omitempty"`
 	RegistryAuth  *update.RegistryAuth `json:"registry_auth,omitempty"`
+	Hooks         *update.UpdateHooks  `json:"hooks,omitempty"`
+	// Signal/escalation for stopping the old container (see update.UpdateRequest)
+	StopStrategy *sharedDocker.StopStrategy `json:"stop_strategy,omitempty"`
+	// Health check tuning (see update.UpdateRequest)
+	GracePeriodSeconds *int                   `json:"grace_period_seconds,omitempty"`
+	ReadinessProbe     *update.ReadinessProbe `json:"readiness_probe,omitempty"`
+	SkipHealthCheck    bool                   `json:"skip_health_check,omitempty"`
+	// Failure forensics for a new container that fails (see update.UpdateRequest)
+	FailureLogLines int `json:"failure_log_lines,omitempty"`
+	QuarantineHours int `json:"quarantine_hours,omitempty"`
+	// Update even if the container's com.dockmon.update.* labels forbid it
+	Force bool `json:"force,omitempty"`
 	// For remote hosts (mTLS)
 	DockerHost string `json:"docker_host,omitempty"`
 	TLSCACert  string `json:"tls_ca_cert,omitempty"`
 	TLSCert    string `json:"tls_cert,omitempty"`
 	TLSKey     string `json:"tls_key,omitempty"`
+	// TLS files or a Docker context instead of the fields above
+	sharedDocker.HostSource
 	// Timeout for the entire operation
 	Timeout int `json:"timeout,omitempty"`
 }
@@ -434,6 +577,9 @@ func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
 		return
 	}
+	if !resolveHostSource(w, req.HostSource, &req.DockerHost, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		return
+	}
 
 	// Validate required fields
 	if req.ContainerID == "" || req.NewImage == "" {
@@ -464,7 +610,7 @@ func (s *Server) handleUpdateJSON(w http.ResponseWriter, r *http.Request, req Up
 	}).Info("Update started")
 
 	// Create Docker client
-	dockerClient, err := s.createDockerClientForUpdate(
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(
 		req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey,
 	)
 	if err != nil {
@@ -480,7 +626,7 @@ func (s *Server) handleUpdateJSON(w http.ResponseWriter, r *http.Request, req Up
 		}
 		return
 	}
-	defer dockerClient.Close()
+	defer releaseClient()
 
 	// Detach the update/rollback from the request context: a client disconnect
 	// mid-update must not cancel the rollback and strand the container.
@@ -491,6 +637,10 @@ func (s *Server) handleUpdateJSON(w http.ResponseWriter, r *http.Request, req Up
 	opCtx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	projectName, historyID := s.startUpdateHistory(opCtx, dockerClient, r, req)
+	hostID := hostIDFrom(r)
+	s.publishUpdateStarted(hostID, req)
+
 	// Detect runtime options (Podman, API version)
 	options := update.DetectOptions(opCtx, dockerClient, s.log)
 
@@ -504,12 +654,23 @@ func (s *Server) handleUpdateJSON(w http.ResponseWriter, r *http.Request, req Up
 		StopTimeout:   req.StopTimeout,
 		HealthTimeout: req.HealthTimeout,
 		RegistryAuth:  req.RegistryAuth,
+		Hooks:         req.Hooks,
+		StopStrategy:  req.StopStrategy,
+
+		GracePeriodSeconds: req.GracePeriodSeconds,
+		ReadinessProbe:     req.ReadinessProbe,
+		SkipHealthCheck:    req.SkipHealthCheck,
+		FailureLogLines:    req.FailureLogLines,
+		QuarantineHours:    req.QuarantineHours,
+		Force:              req.Force,
 	}
 	result := updater.Update(opCtx, updateReq)
 
 	// Record metrics
 	duration := time.Since(startTime)
 	metrics.Global.RecordUpdate(result.Success)
+	s.finishUpdateHistory(projectName, historyID, result)
+	s.publishUpdateFinished(hostID, req, result)
 
 	s.log.WithFields(logrus.Fields{
 		"container_id":   req.ContainerID,
@@ -561,7 +722,7 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 	}).Info("Update started (SSE)")
 
 	// Create Docker client
-	dockerClient, err := s.createDockerClientForUpdate(
+	dockerClient, releaseClient, err := s.createDockerClientForUpdate(
 		req.DockerHost, req.TLSCACert, req.TLSCert, req.TLSKey,
 	)
 	if err != nil {
@@ -578,6 +739,10 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 		return
 	}
 
+	projectName, historyID := s.startUpdateHistory(opCtx, dockerClient, r, req)
+	hostID := hostIDFrom(r)
+	s.publishUpdateStarted(hostID, req)
+
 	// Keepalive ticker - send comment every 15s to prevent connection timeout
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
@@ -593,7 +758,7 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 	// update can outlive this handler if the SSE client disconnects.
 	go func() {
 		defer opCancel()
-		defer dockerClient.Close()
+		defer releaseClient()
 
 		// Detect runtime options (Podman, API version)
 		options := update.DetectOptions(opCtx, dockerClient, s.log)
@@ -624,8 +789,19 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 			StopTimeout:   req.StopTimeout,
 			HealthTimeout: req.HealthTimeout,
 			RegistryAuth:  req.RegistryAuth,
+			Hooks:         req.Hooks,
+			StopStrategy:  req.StopStrategy,
+
+			GracePeriodSeconds: req.GracePeriodSeconds,
+			ReadinessProbe:     req.ReadinessProbe,
+			SkipHealthCheck:    req.SkipHealthCheck,
+			FailureLogLines:    req.FailureLogLines,
+			QuarantineHours:    req.QuarantineHours,
+			Force:              req.Force,
 		}
 		result := updater.Update(opCtx, updateReq)
+		s.finishUpdateHistory(projectName, historyID, result)
+		s.publishUpdateFinished(hostID, req, result)
 
 		close(progressCh)
 		close(pullProgressCh)
@@ -639,7 +815,7 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 			if !ok {
 				continue // Channel closed, wait for result
 			}
-			data, _ := json.Marshal(event)
+			data, _ := json.Marshal(event.ToProgress(req.ContainerID))
 			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
 			flusher.Flush()
 
@@ -647,7 +823,7 @@ func (s *Server) handleUpdateSSE(w http.ResponseWriter, r *http.Request, req Upd
 			if !ok {
 				continue // Channel closed, wait for result
 			}
-			data, _ := json.Marshal(event)
+			data, _ := json.Marshal(event.ToProgress())
 			fmt.Fprintf(w, "event: pull_progress\ndata: %s\n\n", data)
 			flusher.Flush()
 
@@ -0,0 +1,133 @@
+// Package activity defines DockMon-originated events (updates, self-updates,
+// stack deployments) that the services publish onto the stats-service event
+// bus, so WebSocket consumers see them alongside raw Docker events.
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// EventType is the DockerEvent type activity events are broadcast under
+const EventType = "dockmon"
+
+// Actions
+const (
+	ActionUpdateStarted     = "update_started"
+	ActionUpdateCompleted   = "update_completed"
+	ActionUpdateFailed      = "update_failed"
+	ActionSelfUpdateApplied = "self_update_applied"
+	ActionStackDeployed     = "stack_deployed"
+	ActionHostOffline       = "host_offline"
+	ActionHostOnline        = "host_online"
+	ActionImagesPruned      = "images_pruned"
+)
+
+// Attributes of host_offline and host_online events
+const (
+	AttrReason          = "reason"
+	AttrOfflineSince    = "offline_since"
+	AttrDowntimeSeconds = "downtime_seconds"
+)
+
+// Attributes of images_pruned events
+const (
+	AttrRemovedCount   = "removed_count"
+	AttrSpaceReclaimed = "space_reclaimed"
+	AttrFailedCount    = "failed_count"
+	AttrDryRun         = "dry_run"
+	AttrScheduleID     = "schedule_id"
+)
+
+var validActions = map[string]bool{
+	ActionUpdateStarted:     true,
+	ActionUpdateCompleted:   true,
+	ActionUpdateFailed:      true,
+	ActionSelfUpdateApplied: true,
+	ActionStackDeployed:     true,
+	ActionHostOffline:       true,
+	ActionHostOnline:        true,
+	ActionImagesPruned:      true,
+}
+
+// Event is one DockMon action. HostID is required on the HTTP publish
+// endpoint; events from agents get it from the agent's token instead.
+type Event struct {
+	Action        string            `json:"action"`
+	HostID        string            `json:"host_id,omitempty"`
+	ContainerID   string            `json:"container_id,omitempty"`
+	ContainerName string            `json:"container_name,omitempty"`
+	Image         string            `json:"image,omitempty"`
+	Source        string            `json:"source,omitempty"` // Publishing service, e.g. "agent", "compose"
+	Message       string            `json:"message,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Timestamp     time.Time         `json:"timestamp,omitempty"`
+}
+
+// HostOffline is the event for a Docker daemon that stopped answering at
+// since, e.g. because the host is shutting down or rebooting
+func HostOffline(reason string, since time.Time) Event {
+	return Event{
+		Action:  ActionHostOffline,
+		Message: "Docker daemon is unreachable: " + reason,
+		Attributes: map[string]string{
+			AttrReason:       reason,
+			AttrOfflineSince: since.UTC().Format(time.RFC3339),
+		},
+		Timestamp: since,
+	}
+}
+
+// HostOnline is the event for a Docker daemon answering again at now after
+// being offline since offlineSince
+func HostOnline(offlineSince, now time.Time) Event {
+	downtime := now.Sub(offlineSince).Round(time.Second)
+	return Event{
+		Action:  ActionHostOnline,
+		Message: "Docker daemon is reachable again after " + downtime.String(),
+		Attributes: map[string]string{
+			AttrOfflineSince:    offlineSince.UTC().Format(time.RFC3339),
+			AttrDowntimeSeconds: strconv.FormatInt(int64(downtime/time.Second), 10),
+		},
+		Timestamp: now,
+	}
+}
+
+// ImagesPruned is the event for a policy-based image prune that removed (or
+// in a dry run, would remove) removed images freeing reclaimed bytes, with
+// failed removals refused by the daemon
+func ImagesPruned(removed, failed int, reclaimed int64, dryRun bool) Event {
+	message := fmt.Sprintf("Removed %d image(s), reclaimed %s", removed, units.HumanSize(float64(reclaimed)))
+	if dryRun {
+		message = fmt.Sprintf("Dry run: would remove %d image(s), reclaiming %s", removed, units.HumanSize(float64(reclaimed)))
+	}
+	if failed > 0 {
+		message += fmt.Sprintf("; %d could not be removed", failed)
+	}
+	return Event{
+		Action:  ActionImagesPruned,
+		Message: message,
+		Attributes: map[string]string{
+			AttrRemovedCount:   strconv.Itoa(removed),
+			AttrSpaceReclaimed: strconv.FormatInt(reclaimed, 10),
+			AttrFailedCount:    strconv.Itoa(failed),
+			AttrDryRun:         strconv.FormatBool(dryRun),
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// Validate checks that the event has a known action
+func (e Event) Validate() error {
+	if e.Action == "" {
+		return errors.New("action is required")
+	}
+	if !validActions[e.Action] {
+		return errors.New("unknown action: " + e.Action)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package activity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PublishPath is the stats-service endpoint activity events are posted to
+const PublishPath = "/api/events/publish"
+
+const (
+	publishQueueSize = 64
+	publishTimeout   = 5 * time.Second
+)
+
+// Publisher posts activity events to the stats-service in the background.
+// Publishing never blocks: events are dropped when the queue is full or the
+// stats-service is unreachable, since the activity stream is best effort.
+// A nil *Publisher is valid and discards everything.
+type Publisher struct {
+	url       string
+	tokenFile string
+	source    string
+	client    *http.Client
+	queue     chan Event
+	log       *logrus.Logger
+}
+
+// NewPublisher creates a publisher for the stats-service at baseURL. The
+// bearer token is read from tokenFile on every send, so a rotated token is
+// picked up without a restart. source is stamped on events that don't set
+// one.
+func NewPublisher(baseURL, tokenFile, source string, log *logrus.Logger) *Publisher {
+	return &Publisher{
+		url:       strings.TrimRight(baseURL, "/") + PublishPath,
+		tokenFile: tokenFile,
+		source:    source,
+		client:    &http.Client{Timeout: publishTimeout},
+		queue:     make(chan Event, publishQueueSize),
+		log:       log,
+	}
+}
+
+// Publish queues an event. Events without a host ID are dropped, since the
+// stats-service can't attribute them.
+func (p *Publisher) Publish(event Event) {
+	if p == nil || event.HostID == "" {
+		return
+	}
+	if event.Source == "" {
+		event.Source = p.source
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	select {
+	case p.queue <- event:
+	default:
+		p.log.WithField("action", event.Action).Debug("Activity queue full, dropping event")
+	}
+}
+
+// Run sends queued events until ctx is cancelled
+func (p *Publisher) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-p.queue:
+			if err := p.send(ctx, event); err != nil {
+				p.log.WithError(err).WithField("action", event.Action).Debug("Failed to publish activity event")
+			}
+		}
+	}
+}
+
+func (p *Publisher) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.tokenFile != "" {
+		token, err := os.ReadFile(p.tokenFile)
+		if err != nil {
+			return fmt.Errorf("read token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stats-service returned %s", resp.Status)
+	}
+	return nil
+}
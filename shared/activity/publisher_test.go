@@ -0,0 +1,97 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEventValidate(t *testing.T) {
+	if err := (Event{Action: ActionStackDeployed}).Validate(); err != nil {
+		t.Errorf("valid event: %v", err)
+	}
+	for _, action := range []string{"", "start", "update"} {
+		if err := (Event{Action: action}).Validate(); err == nil {
+			t.Errorf("action %q should be rejected", action)
+		}
+	}
+}
+
+func TestHostOutageEvents(t *testing.T) {
+	since := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	offline := HostOffline("EOF", since)
+	if err := offline.Validate(); err != nil || offline.Attributes[AttrOfflineSince] != "2026-06-01T12:00:00Z" {
+		t.Errorf("offline = %+v, %v", offline, err)
+	}
+	online := HostOnline(since, since.Add(95*time.Second+300*time.Millisecond))
+	if err := online.Validate(); err != nil || online.Attributes[AttrDowntimeSeconds] != "95" {
+		t.Errorf("online = %+v, %v", online, err)
+	}
+}
+
+func TestImagesPrunedEvent(t *testing.T) {
+	e := ImagesPruned(3, 1, 2048, false)
+	if err := e.Validate(); err != nil || e.Attributes[AttrRemovedCount] != "3" || e.Attributes[AttrDryRun] != "false" {
+		t.Errorf("pruned = %+v, %v", e, err)
+	}
+	if e.Message != "Removed 3 image(s), reclaimed 2.048kB; 1 could not be removed" {
+		t.Errorf("message = %q", e.Message)
+	}
+	if dry := ImagesPruned(2, 0, 0, true); dry.Message != "Dry run: would remove 2 image(s), reclaiming 0B" {
+		t.Errorf("dry run message = %q", dry.Message)
+	}
+}
+
+func TestPublisherPostsEvents(t *testing.T) {
+	received := make(chan Event, 1)
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != PublishPath {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		received <- e
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("secret\n"), 0600)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	p := NewPublisher(srv.URL+"/", tokenFile, "compose", log)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Run(ctx)
+
+	// Events that can't be attributed to a host are dropped
+	p.Publish(Event{Action: ActionStackDeployed})
+	p.Publish(Event{Action: ActionStackDeployed, HostID: "host-1"})
+
+	select {
+	case e := <-received:
+		if e.HostID != "host-1" || e.Source != "compose" || e.Timestamp.IsZero() {
+			t.Errorf("received %+v", e)
+		}
+		if auth != "Bearer secret" {
+			t.Errorf("Authorization = %q", auth)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not posted")
+	}
+
+	// A nil publisher discards events
+	var nilPublisher *Publisher
+	nilPublisher.Publish(Event{Action: ActionStackDeployed, HostID: "host-1"})
+}
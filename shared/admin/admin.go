@@ -0,0 +1,306 @@
+// Package admin holds the runtime administration endpoints the Go services
+// share: changing the log level without a restart, so operators can capture
+// debug logs of an intermittent problem without losing the repro, and
+// reporting the effective configuration with secrets removed.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxLevelDuration caps how long a timed level change lasts
+const MaxLevelDuration = 24 * time.Hour
+
+// Redacted replaces secret configuration values
+const Redacted = "[REDACTED]"
+
+// LevelTarget is a logger whose level can change at runtime
+type LevelTarget interface {
+	Level() string
+	SetLevel(level string) error
+}
+
+// Logrus adapts a logrus logger, which accepts every logrus level name
+func Logrus(log *logrus.Logger) LevelTarget {
+	return logrusTarget{log}
+}
+
+type logrusTarget struct {
+	log *logrus.Logger
+}
+
+func (t logrusTarget) Level() string {
+	return t.log.GetLevel().String()
+}
+
+func (t logrusTarget) SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	t.log.SetLevel(parsed)
+	return nil
+}
+
+// LevelRequest is the body of POST /admin/loglevel
+type LevelRequest struct {
+	Level string `json:"level"`
+	// DurationSeconds reverts the change after this long; 0 keeps the new
+	// level until it is changed again
+	DurationSeconds int `json:"duration_seconds,omitempty"`
+}
+
+// LevelState is a service's current log level
+type LevelState struct {
+	Level string `json:"level"`
+	// Set while a timed change is in effect
+	RevertTo string     `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// LogLevel changes a logger's level at runtime, optionally only for a while
+type LogLevel struct {
+	target LevelTarget
+	logf   func(format string, args ...interface{})
+
+	mu       sync.Mutex
+	revertTo string // Level before the timed change, restored by timer
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+// NewLogLevel controls target's level. Changes are announced through logf,
+// which may be nil.
+func NewLogLevel(target LevelTarget, logf func(format string, args ...interface{})) *LogLevel {
+	if logf == nil {
+		logf = func(string, ...interface{}) {}
+	}
+	return &LogLevel{target: target, logf: logf}
+}
+
+// State returns the current level and any pending revert
+func (l *LogLevel) State() LevelState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stateLocked()
+}
+
+func (l *LogLevel) stateLocked() LevelState {
+	s := LevelState{Level: l.target.Level()}
+	if l.timer != nil {
+		at := l.revertAt
+		s.RevertTo = l.revertTo
+		s.RevertAt = &at
+	}
+	return s
+}
+
+// Set changes the level. A timed change reverts to the level in effect
+// before the first of any overlapping timed changes.
+func (l *LogLevel) Set(req LevelRequest) (LevelState, error) {
+	if req.DurationSeconds < 0 || time.Duration(req.DurationSeconds)*time.Second > MaxLevelDuration {
+		return LevelState{}, fmt.Errorf("duration_seconds must be between 0 and %d", int(MaxLevelDuration/time.Second))
+	}
+	level := strings.ToLower(strings.TrimSpace(req.Level))
+	if level == "" {
+		return LevelState{}, errors.New("level is required")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := l.target.Level()
+	if err := l.target.SetLevel(level); err != nil {
+		return LevelState{}, fmt.Errorf("invalid level %q: %w", req.Level, err)
+	}
+
+	revertTo := previous
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+		revertTo = l.revertTo
+	}
+	if req.DurationSeconds == 0 {
+		l.logf("Log level changed from %s to %s", previous, level)
+		return l.stateLocked(), nil
+	}
+
+	d := time.Duration(req.DurationSeconds) * time.Second
+	l.revertTo = revertTo
+	l.revertAt = time.Now().Add(d).UTC().Truncate(time.Second)
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() { l.revert(timer) })
+	l.timer = timer
+	l.logf("Log level changed from %s to %s for %v", previous, level, d)
+	return l.stateLocked(), nil
+}
+
+// revert restores the level from before a timed change, unless the change
+// was superseded since timer was started
+func (l *LogLevel) revert(timer *time.Timer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != timer {
+		return
+	}
+	l.timer = nil
+	current := l.target.Level()
+	if err := l.target.SetLevel(l.revertTo); err != nil {
+		return
+	}
+	l.logf("Log level reverted from %s to %s", current, l.revertTo)
+}
+
+// Handler serves the level: GET returns the LevelState, POST takes a
+// LevelRequest and returns the new state
+func (l *LogLevel) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, l.State())
+		case http.MethodPost:
+			var req LevelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON: " + err.Error()})
+				return
+			}
+			state, err := l.Set(req)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, state)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// ConfigHandler serves GET requests with the configuration config returns,
+// passed through Redact
+func ConfigHandler(config func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, Redact(config()))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Redact returns a struct's fields, or a map's entries, with secrets
+// removed: values named like a token, secret, password or key are replaced,
+// URLs keep only their scheme and host, and durations are written as
+// strings. Nested structs are redacted the same way. File paths are kept;
+// it's the files' contents that are secret.
+func Redact(cfg interface{}) map[string]interface{} {
+	v := reflect.ValueOf(cfg)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	out := make(map[string]interface{})
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() {
+				out[f.Name] = redactValue(f.Name, v.Field(i))
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			out[name] = redactValue(name, iter.Value())
+		}
+	}
+	return out
+}
+
+func redactValue(name string, v reflect.Value) interface{} {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case time.Time:
+		return value
+	case string:
+		switch {
+		case value != "" && isSecretName(name):
+			return Redacted
+		case strings.HasSuffix(normalizeName(name), "url"):
+			return RedactURL(value)
+		}
+		return value
+	}
+	if v.Kind() == reflect.Struct || (v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct) {
+		return Redact(v.Interface())
+	}
+	if isSecretName(name) && !v.IsZero() {
+		return Redacted
+	}
+	return v.Interface()
+}
+
+// isSecretName reports whether a field or key name holds a secret value
+func isSecretName(name string) bool {
+	n := normalizeName(name)
+	for _, suffix := range []string{"token", "secret", "password", "key", "credentials"} {
+		if strings.HasSuffix(n, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName lowercases name and drops separators, so Go field names and
+// snake_case keys compare alike
+func normalizeName(name string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+}
+
+// RedactURL keeps the scheme and host of raw and drops user info, path and
+// query, which often embed credentials
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return Redacted
+	}
+	out := u.Scheme + "://" + u.Host
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		out += "/" + Redacted
+	}
+	return out
+}
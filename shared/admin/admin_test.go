@@ -0,0 +1,183 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogLevel_Set(t *testing.T) {
+	log := logrus.New()
+	var announced []string
+	l := NewLogLevel(Logrus(log), func(format string, args ...interface{}) {
+		announced = append(announced, format)
+	})
+
+	s, err := l.Set(LevelRequest{Level: "DEBUG"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.GetLevel() != logrus.DebugLevel || s.Level != "debug" || s.RevertAt != nil {
+		t.Errorf("after setting debug: level %s, state %+v", log.GetLevel(), s)
+	}
+	if len(announced) != 1 {
+		t.Errorf("change announced %d times, want once", len(announced))
+	}
+
+	for _, req := range []LevelRequest{
+		{Level: "chatty"},
+		{Level: ""},
+		{Level: "info", DurationSeconds: -1},
+		{Level: "info", DurationSeconds: int(MaxLevelDuration/time.Second) + 1},
+	} {
+		if _, err := l.Set(req); err == nil {
+			t.Errorf("Set(%+v) succeeded", req)
+		}
+	}
+	if log.GetLevel() != logrus.DebugLevel {
+		t.Errorf("rejected request changed the level to %s", log.GetLevel())
+	}
+}
+
+func TestLogLevel_TimedChangeReverts(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+	l := NewLogLevel(Logrus(log), nil)
+
+	if _, err := l.Set(LevelRequest{Level: "info", DurationSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	// A second timed change still reverts to the level from before the first
+	s, err := l.Set(LevelRequest{Level: "debug", DurationSeconds: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != "debug" || s.RevertTo != "warning" || s.RevertAt == nil {
+		t.Fatalf("state = %+v, want debug reverting to warning", s)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for log.GetLevel() != logrus.WarnLevel {
+		if time.Now().After(deadline) {
+			t.Fatalf("level still %s after the change expired", log.GetLevel())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s := l.State(); s.RevertAt != nil {
+		t.Errorf("revert still pending after it ran: %+v", s)
+	}
+}
+
+func TestLogLevel_PermanentChangeCancelsRevert(t *testing.T) {
+	log := logrus.New()
+	l := NewLogLevel(Logrus(log), nil)
+	if _, err := l.Set(LevelRequest{Level: "debug", DurationSeconds: 60}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := l.Set(LevelRequest{Level: "trace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RevertAt != nil || s.Level != "trace" {
+		t.Errorf("state = %+v, want trace with no revert", s)
+	}
+}
+
+func TestLogLevel_Handler(t *testing.T) {
+	log := logrus.New()
+	h := NewLogLevel(Logrus(log), nil).Handler()
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/admin/loglevel", strings.NewReader(`{"level":"debug","duration_seconds":600}`)))
+	var s LevelState
+	if err := json.NewDecoder(w.Body).Decode(&s); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("POST: status %d, err %v", w.Code, err)
+	}
+	if s.Level != "debug" || s.RevertTo != "info" {
+		t.Errorf("POST state = %+v", s)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"level":"debug"`) {
+		t.Errorf("GET: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/admin/loglevel", strings.NewReader(`{"level":"loud"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid level") {
+		t.Errorf("bad level: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodDelete, "/admin/loglevel", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status %d", w.Code)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	type limits struct {
+		MaxConcurrent int
+		Cooldown      time.Duration
+	}
+	cfg := struct {
+		TokenFilePath string
+		APIToken      string
+		EmptyToken    string
+		WebhookURL    string
+		Interval      time.Duration
+		Limits        limits
+		internal      string
+	}{
+		TokenFilePath: "/app/data/token",
+		APIToken:      "s3cret",
+		WebhookURL:    "https://user:pw@hooks.example.com/path?key=abc",
+		Interval:      5 * time.Second,
+		Limits:        limits{MaxConcurrent: 3, Cooldown: time.Minute},
+		internal:      "hidden",
+	}
+
+	got := Redact(&cfg)
+	want := map[string]interface{}{
+		"TokenFilePath": "/app/data/token",
+		"APIToken":      Redacted,
+		"EmptyToken":    "",
+		"WebhookURL":    "https://hooks.example.com/" + Redacted,
+		"Interval":      "5s",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["internal"]; ok {
+		t.Error("unexported field included")
+	}
+	nested, ok := got["Limits"].(map[string]interface{})
+	if !ok || nested["MaxConcurrent"] != 3 || nested["Cooldown"] != "1m0s" {
+		t.Errorf("Limits = %#v", got["Limits"])
+	}
+
+	m := Redact(map[string]interface{}{"stats_service_url": "http://localhost:8081", "env_sets_key": "k", "max_concurrent": 2})
+	if m["stats_service_url"] != "http://localhost:8081" || m["env_sets_key"] != Redacted || m["max_concurrent"] != 2 {
+		t.Errorf("map = %v", m)
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	h := ConfigHandler(func() interface{} {
+		return map[string]interface{}{"secret": "x", "port": "8081"}
+	})
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	body := w.Body.String()
+	if w.Code != http.StatusOK || strings.Contains(body, `"x"`) || !strings.Contains(body, `"8081"`) {
+		t.Errorf("status %d, body %s", w.Code, body)
+	}
+}
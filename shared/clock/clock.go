@@ -0,0 +1,146 @@
+// Package clock measures how far remote hosts' clocks are from the local one,
+// so timestamps produced on a host (Docker events, agent messages) can be
+// normalized to the clock of the DockMon component that receives them.
+//
+// Offsets are defined as local minus remote: adding a host's offset to a
+// timestamp from that host yields local time.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// sampleWindow is how many recent samples are kept per host. The
+	// estimate uses the lowest-RTT sample in the window, the same filter NTP
+	// uses, since network delay only ever inflates the error.
+	sampleWindow = 8
+
+	// DriftWarnThreshold is the offset beyond which a host's clock is
+	// considered to be drifting rather than just subject to network jitter.
+	DriftWarnThreshold = 2 * time.Second
+)
+
+// Sample is one offset measurement.
+type Sample struct {
+	Offset time.Duration
+	RTT    time.Duration
+	At     time.Time
+}
+
+// FromExchange computes an offset from a request/response exchange: the
+// request was sent at sent (local), the remote read its clock at remote, and
+// the response arrived at received (local). The remote reading is assumed to
+// have happened halfway through the round trip.
+func FromExchange(sent, remote, received time.Time) Sample {
+	rtt := received.Sub(sent)
+	if rtt < 0 {
+		rtt = 0
+	}
+	midpoint := sent.Add(rtt / 2)
+	return Sample{Offset: midpoint.Sub(remote), RTT: rtt, At: received}
+}
+
+// FromOneWay computes an offset from a timestamp the remote sent unprompted.
+// The one-way network delay is unknown and counted as drift. Such samples
+// carry no RTT, so don't mix them with exchange samples for the same host.
+func FromOneWay(remote, received time.Time) Sample {
+	return Sample{Offset: received.Sub(remote), At: received}
+}
+
+// Estimate is a host's current offset.
+type Estimate struct {
+	Offset     time.Duration `json:"-"`
+	OffsetMs   int64         `json:"offset_ms"`
+	RTTMs      int64         `json:"rtt_ms"`
+	Samples    int           `json:"samples"`
+	MeasuredAt time.Time     `json:"measured_at"`
+	Drifting   bool          `json:"drifting"`
+}
+
+// Tracker keeps offset estimates per host. Safe for concurrent use.
+type Tracker struct {
+	mu    sync.RWMutex
+	hosts map[string][]Sample
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{hosts: make(map[string][]Sample)}
+}
+
+// Observe records a sample for a host and returns the updated estimate.
+func (t *Tracker) Observe(hostID string, s Sample) Estimate {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := append(t.hosts[hostID], s)
+	if len(samples) > sampleWindow {
+		samples = samples[len(samples)-sampleWindow:]
+	}
+	t.hosts[hostID] = samples
+	return estimate(samples)
+}
+
+// Offset returns a host's estimated offset. ok is false if the host has
+// never been measured, in which case timestamps are best left untouched.
+func (t *Tracker) Offset(hostID string) (offset time.Duration, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	samples := t.hosts[hostID]
+	if len(samples) == 0 {
+		return 0, false
+	}
+	return estimate(samples).Offset, true
+}
+
+// Normalize converts a timestamp from a host's clock to the local clock.
+// Unmeasured hosts' timestamps are returned unchanged.
+func (t *Tracker) Normalize(hostID string, ts time.Time) time.Time {
+	offset, ok := t.Offset(hostID)
+	if !ok {
+		return ts
+	}
+	return ts.Add(offset)
+}
+
+// Forget drops a host's samples (e.g. when the host is removed).
+func (t *Tracker) Forget(hostID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.hosts, hostID)
+}
+
+// Snapshot returns the current estimate for every measured host.
+func (t *Tracker) Snapshot() map[string]Estimate {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := make(map[string]Estimate, len(t.hosts))
+	for hostID, samples := range t.hosts {
+		if len(samples) > 0 {
+			out[hostID] = estimate(samples)
+		}
+	}
+	return out
+}
+
+// estimate picks the lowest-RTT sample, preferring the newest on ties.
+func estimate(samples []Sample) Estimate {
+	best := samples[0]
+	for _, s := range samples[1:] {
+		if s.RTT <= best.RTT {
+			best = s
+		}
+	}
+	return Estimate{
+		Offset:     best.Offset,
+		OffsetMs:   best.Offset.Milliseconds(),
+		RTTMs:      best.RTT.Milliseconds(),
+		Samples:    len(samples),
+		MeasuredAt: best.At,
+		Drifting:   best.Offset > DriftWarnThreshold || best.Offset < -DriftWarnThreshold,
+	}
+}
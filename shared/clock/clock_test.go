@@ -0,0 +1,94 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFromExchange(t *testing.T) {
+	// Remote is 5s behind; 200ms round trip
+	sent := base
+	received := base.Add(200 * time.Millisecond)
+	remote := base.Add(100*time.Millisecond - 5*time.Second)
+
+	s := FromExchange(sent, remote, received)
+
+	if s.Offset != 5*time.Second {
+		t.Errorf("Offset = %v, want 5s", s.Offset)
+	}
+	if s.RTT != 200*time.Millisecond {
+		t.Errorf("RTT = %v, want 200ms", s.RTT)
+	}
+}
+
+func TestFromOneWay(t *testing.T) {
+	s := FromOneWay(base.Add(3*time.Second), base)
+
+	if s.Offset != -3*time.Second {
+		t.Errorf("Offset = %v, want -3s", s.Offset)
+	}
+}
+
+func TestTracker_UsesLowestRTTSample(t *testing.T) {
+	tr := NewTracker()
+	tr.Observe("h1", Sample{Offset: 4 * time.Second, RTT: 900 * time.Millisecond, At: base})
+	tr.Observe("h1", Sample{Offset: 2 * time.Second, RTT: 10 * time.Millisecond, At: base})
+	est := tr.Observe("h1", Sample{Offset: 3 * time.Second, RTT: 500 * time.Millisecond, At: base})
+
+	if est.Offset != 2*time.Second {
+		t.Errorf("Offset = %v, want lowest-RTT sample 2s", est.Offset)
+	}
+	if est.Samples != 3 {
+		t.Errorf("Samples = %d, want 3", est.Samples)
+	}
+}
+
+func TestTracker_WindowDropsOldSamples(t *testing.T) {
+	tr := NewTracker()
+	tr.Observe("h1", Sample{Offset: time.Hour, RTT: 0})
+	for i := 0; i < sampleWindow; i++ {
+		tr.Observe("h1", Sample{Offset: time.Second, RTT: time.Millisecond})
+	}
+
+	if offset, _ := tr.Offset("h1"); offset != time.Second {
+		t.Errorf("Offset = %v, want stale sample evicted", offset)
+	}
+}
+
+func TestTracker_Normalize(t *testing.T) {
+	tr := NewTracker()
+	ts := base
+
+	if got := tr.Normalize("unknown", ts); !got.Equal(ts) {
+		t.Errorf("unmeasured host should be unchanged, got %v", got)
+	}
+
+	tr.Observe("h1", FromOneWay(base.Add(-10*time.Second), base))
+	if got := tr.Normalize("h1", ts); !got.Equal(base.Add(10 * time.Second)) {
+		t.Errorf("Normalize = %v, want +10s", got)
+	}
+
+	tr.Forget("h1")
+	if _, ok := tr.Offset("h1"); ok {
+		t.Error("Forget should drop the host")
+	}
+}
+
+func TestTracker_SnapshotFlagsDrift(t *testing.T) {
+	tr := NewTracker()
+	tr.Observe("ok", Sample{Offset: 500 * time.Millisecond})
+	tr.Observe("bad", Sample{Offset: -DriftWarnThreshold - time.Second})
+
+	snap := tr.Snapshot()
+	if snap["ok"].Drifting {
+		t.Error("500ms should not count as drift")
+	}
+	if !snap["bad"].Drifting {
+		t.Error("offset beyond threshold should count as drift")
+	}
+	if snap["bad"].OffsetMs != -3000 {
+		t.Errorf("OffsetMs = %d, want -3000", snap["bad"].OffsetMs)
+	}
+}
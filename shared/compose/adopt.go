@@ -0,0 +1,563 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// Compose labels set on every container created by compose
+const (
+	labelProject     = "com.docker.compose.project"
+	labelService     = "com.docker.compose.service"
+	labelNumber      = "com.docker.compose.container-number"
+	labelOneoff      = "com.docker.compose.oneoff"
+	labelDependsOn   = "com.docker.compose.depends_on"
+	labelWorkingDir  = "com.docker.compose.project.working_dir"
+	labelConfigFiles = "com.docker.compose.project.config_files"
+	labelPrefix      = "com.docker.compose."
+)
+
+// ErrProjectNotFound is returned by AdoptProject when no containers carry the
+// project's compose label.
+var ErrProjectNotFound = errors.New("no containers found for compose project")
+
+// AdoptResult is a best-effort compose model rebuilt from a running stack.
+// ComposeYAML and EnvFileContent can be passed straight to a DeployRequest.
+type AdoptResult struct {
+	ProjectName    string   `json:"project_name"`
+	ComposeYAML    string   `json:"compose_yaml"`
+	EnvFileContent string   `json:"env_file_content,omitempty"`
+	Services       []string `json:"services"`
+	// Where the stack was originally deployed from, as recorded by compose
+	WorkingDir  string   `json:"working_dir,omitempty"`
+	ConfigFiles []string `json:"config_files,omitempty"`
+	// Warnings lists settings that couldn't be reconstructed and need review
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// adoptedContainer is a container's inspect data plus the defaults of its
+// image, so settings inherited from the image can be left out of the model.
+type adoptedContainer struct {
+	inspect container.InspectResponse
+	image   imageDefaults
+}
+
+// imageDefaults holds the image config fields a container inherits
+type imageDefaults struct {
+	Env        []string
+	Cmd        []string
+	Entrypoint []string
+	WorkingDir string
+	User       string
+	Labels     map[string]string
+	Volumes    map[string]struct{}
+}
+
+// The compose model written out for an adopted stack. Field order follows the
+// usual hand-written compose file layout.
+type adoptedFile struct {
+	Services map[string]*adoptedService `yaml:"services"`
+	Networks map[string]adoptedResource `yaml:"networks,omitempty"`
+	Volumes  map[string]adoptedResource `yaml:"volumes,omitempty"`
+}
+
+type adoptedService struct {
+	Image         string                      `yaml:"image"`
+	ContainerName string                      `yaml:"container_name,omitempty"`
+	Entrypoint    []string                    `yaml:"entrypoint,omitempty"`
+	Command       []string                    `yaml:"command,omitempty"`
+	WorkingDir    string                      `yaml:"working_dir,omitempty"`
+	User          string                      `yaml:"user,omitempty"`
+	Environment   map[string]string           `yaml:"environment,omitempty"`
+	Ports         []string                    `yaml:"ports,omitempty"`
+	Volumes       []string                    `yaml:"volumes,omitempty"`
+	NetworkMode   string                      `yaml:"network_mode,omitempty"`
+	Networks      []string                    `yaml:"networks,omitempty"`
+	DependsOn     map[string]adoptedDependsOn `yaml:"depends_on,omitempty"`
+	Restart       string                      `yaml:"restart,omitempty"`
+	Privileged    bool                        `yaml:"privileged,omitempty"`
+	CapAdd        []string                    `yaml:"cap_add,omitempty"`
+	ExtraHosts    []string                    `yaml:"extra_hosts,omitempty"`
+	Labels        map[string]string           `yaml:"labels,omitempty"`
+	Scale         int                         `yaml:"scale,omitempty"`
+}
+
+type adoptedDependsOn struct {
+	Condition string `yaml:"condition"`
+}
+
+// adoptedResource is a top-level network or volume. Resources that don't
+// belong to the project are marked external and keep their real name as key.
+type adoptedResource struct {
+	External bool `yaml:"external,omitempty"`
+}
+
+// AdoptProject rebuilds a compose file for a stack deployed outside DockMon
+// (e.g. with the docker compose CLI), discovering its containers by the
+// com.docker.compose.project label. The result is best-effort: build
+// sections, configs/secrets and resource limits aren't recovered, and
+// anything skipped is listed in Warnings.
+func AdoptProject(ctx context.Context, dockerClient *client.Client, projectName string) (*AdoptResult, error) {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("label", fmt.Sprintf("%s=%s", labelProject, projectName))
+
+	summaries, err := dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filterArgs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	containers := make([]adoptedContainer, 0, len(summaries))
+	imageCache := make(map[string]imageDefaults)
+	for _, s := range summaries {
+		if s.Labels[labelOneoff] == "True" {
+			continue // `docker compose run` containers aren't part of the stack
+		}
+
+		inspect, err := dockerClient.ContainerInspect(ctx, s.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect container %s: %w", shortContainerID(s.ID), err)
+		}
+
+		defaults, ok := imageCache[inspect.Image]
+		if !ok {
+			// A missing image (e.g. removed after the container was created)
+			// only means inherited settings can't be told apart
+			if img, err := dockerClient.ImageInspect(ctx, inspect.Image); err == nil && img.Config != nil {
+				defaults = imageDefaults{
+					Env:        img.Config.Env,
+					Cmd:        img.Config.Cmd,
+					Entrypoint: img.Config.Entrypoint,
+					WorkingDir: img.Config.WorkingDir,
+					User:       img.Config.User,
+					Labels:     img.Config.Labels,
+					Volumes:    img.Config.Volumes,
+				}
+			}
+			imageCache[inspect.Image] = defaults
+		}
+
+		containers = append(containers, adoptedContainer{inspect: inspect, image: defaults})
+	}
+
+	if len(containers) == 0 {
+		return nil, ErrProjectNotFound
+	}
+
+	return buildAdoptResult(projectName, containers)
+}
+
+// buildAdoptResult turns the project's containers into a compose model. One
+// container per service is used as the template; extra replicas become scale.
+func buildAdoptResult(projectName string, containers []adoptedContainer) (*AdoptResult, error) {
+	result := &AdoptResult{ProjectName: projectName}
+	file := adoptedFile{
+		Services: make(map[string]*adoptedService),
+		Networks: make(map[string]adoptedResource),
+		Volumes:  make(map[string]adoptedResource),
+	}
+
+	// Group by service, ordered by container number so replica 1 is the template
+	byService := make(map[string][]adoptedContainer)
+	for _, c := range containers {
+		labels := containerLabels(c.inspect)
+		service := labels[labelService]
+		if service == "" {
+			continue
+		}
+		byService[service] = append(byService[service], c)
+
+		if result.WorkingDir == "" {
+			result.WorkingDir = labels[labelWorkingDir]
+		}
+		if result.ConfigFiles == nil && labels[labelConfigFiles] != "" {
+			result.ConfigFiles = strings.Split(labels[labelConfigFiles], ",")
+		}
+	}
+	if len(byService) == 0 {
+		return nil, ErrProjectNotFound
+	}
+
+	// Environment per service, split into .env entries afterwards
+	serviceEnv := make(map[string]map[string]string)
+
+	for service, replicas := range byService {
+		sort.Slice(replicas, func(i, j int) bool {
+			return containerNumber(replicas[i].inspect) < containerNumber(replicas[j].inspect)
+		})
+		svc, env, warnings := adoptService(projectName, service, replicas[0], file)
+		if len(replicas) > 1 {
+			svc.Scale = len(replicas)
+			svc.ContainerName = "" // Fixed names can't be scaled
+		}
+		file.Services[service] = svc
+		serviceEnv[service] = env
+		result.Services = append(result.Services, service)
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+	sort.Strings(result.Services)
+	sort.Strings(result.Warnings)
+
+	result.EnvFileContent = splitEnvironment(file.Services, serviceEnv)
+
+	out, err := yaml.Marshal(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode compose file: %w", err)
+	}
+	result.ComposeYAML = string(out)
+	return result, nil
+}
+
+// adoptService reconstructs a single service from its template container.
+// Top-level networks and volumes it references are added to file. Returns the
+// service's non-inherited environment separately.
+func adoptService(projectName, service string, c adoptedContainer, file adoptedFile) (*adoptedService, map[string]string, []string) {
+	inspect := c.inspect
+	cfg := inspect.Config
+	if cfg == nil {
+		cfg = &container.Config{}
+	}
+	var hostCfg container.HostConfig
+	if inspect.ContainerJSONBase != nil && inspect.HostConfig != nil {
+		hostCfg = *inspect.HostConfig
+	}
+
+	var warnings []string
+	svc := &adoptedService{Image: cfg.Image}
+
+	if name := strings.TrimPrefix(containerName(inspect), "/"); name != "" && !isDefaultContainerName(projectName, service, name) {
+		svc.ContainerName = name
+	}
+	if isBuiltImage(projectName, service, cfg.Image) {
+		warnings = append(warnings, fmt.Sprintf("service %s: image %s looks locally built; add its build section", service, cfg.Image))
+	}
+
+	// Command and entrypoint only when overridden. Compose resets the image's
+	// CMD when entrypoint is set, so keep the command alongside it.
+	entrypointChanged := !slices.Equal(cfg.Entrypoint, c.image.Entrypoint)
+	if entrypointChanged {
+		svc.Entrypoint = cfg.Entrypoint
+	}
+	if entrypointChanged || !slices.Equal(cfg.Cmd, c.image.Cmd) {
+		svc.Command = cfg.Cmd
+	}
+	if cfg.WorkingDir != c.image.WorkingDir {
+		svc.WorkingDir = cfg.WorkingDir
+	}
+	if cfg.User != c.image.User {
+		svc.User = cfg.User
+	}
+
+	env := make(map[string]string)
+	for _, entry := range cfg.Env {
+		if slices.Contains(c.image.Env, entry) {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "=")
+		env[key] = value
+	}
+
+	svc.Ports = adoptPorts(hostCfg)
+	svc.Volumes = adoptVolumes(projectName, inspect.Mounts, c.image.Volumes, file)
+	if len(hostCfg.Tmpfs) > 0 {
+		warnings = append(warnings, fmt.Sprintf("service %s: tmpfs mounts not reconstructed", service))
+	}
+	if len(hostCfg.Devices) > 0 {
+		warnings = append(warnings, fmt.Sprintf("service %s: device mappings not reconstructed", service))
+	}
+
+	mode := string(hostCfg.NetworkMode)
+	switch {
+	case mode == "host" || mode == "none" || strings.HasPrefix(mode, "container:") || strings.HasPrefix(mode, "service:"):
+		svc.NetworkMode = mode
+	case inspect.NetworkSettings != nil:
+		svc.Networks = adoptNetworks(projectName, inspect.NetworkSettings.Networks, file)
+	}
+
+	svc.DependsOn = parseDependsOn(containerLabels(inspect)[labelDependsOn])
+
+	switch hostCfg.RestartPolicy.Name {
+	case "", container.RestartPolicyDisabled:
+	case container.RestartPolicyOnFailure:
+		svc.Restart = "on-failure"
+		if n := hostCfg.RestartPolicy.MaximumRetryCount; n > 0 {
+			svc.Restart = fmt.Sprintf("on-failure:%d", n)
+		}
+	default:
+		svc.Restart = string(hostCfg.RestartPolicy.Name)
+	}
+
+	svc.Privileged = hostCfg.Privileged
+	svc.CapAdd = hostCfg.CapAdd
+	svc.ExtraHosts = hostCfg.ExtraHosts
+
+	labels := make(map[string]string)
+	for k, v := range cfg.Labels {
+		if strings.HasPrefix(k, labelPrefix) {
+			continue
+		}
+		if imageValue, ok := c.image.Labels[k]; ok && imageValue == v {
+			continue
+		}
+		labels[k] = v
+	}
+	if len(labels) > 0 {
+		svc.Labels = labels
+	}
+
+	return svc, env, warnings
+}
+
+// adoptPorts returns the published ports as compose short syntax
+// ([ip:]host:container[/proto]), sorted.
+func adoptPorts(hostCfg container.HostConfig) []string {
+	seen := make(map[string]bool)
+	var ports []string
+	for port, bindings := range hostCfg.PortBindings {
+		target := port.Port()
+		if port.Proto() != "" && port.Proto() != "tcp" {
+			target += "/" + port.Proto()
+		}
+		for _, b := range bindings {
+			spec := target
+			if b.HostPort != "" {
+				spec = b.HostPort + ":" + target
+			}
+			if b.HostIP != "" && b.HostIP != "0.0.0.0" && b.HostIP != "::" {
+				host := b.HostIP
+				if strings.Contains(host, ":") {
+					host = "[" + host + "]"
+				}
+				spec = host + ":" + spec
+			}
+			if !seen[spec] {
+				seen[spec] = true
+				ports = append(ports, spec)
+			}
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// adoptVolumes returns the container's mounts as compose short syntax. Project
+// volumes drop the project prefix; other named volumes are declared external.
+// Anonymous volumes created for the image's VOLUME entries are skipped.
+func adoptVolumes(projectName string, mounts []container.MountPoint, imageVolumes map[string]struct{}, file adoptedFile) []string {
+	var volumes []string
+	for _, m := range mounts {
+		var spec string
+		switch m.Type {
+		case mount.TypeBind:
+			spec = m.Source + ":" + m.Destination
+		case mount.TypeVolume:
+			if isAnonymousVolume(m.Name) {
+				if _, fromImage := imageVolumes[m.Destination]; fromImage {
+					continue
+				}
+				spec = m.Destination
+				break
+			}
+			name := m.Name
+			if local, ok := strings.CutPrefix(m.Name, projectName+"_"); ok {
+				name = local
+				file.Volumes[name] = adoptedResource{}
+			} else {
+				file.Volumes[name] = adoptedResource{External: true}
+			}
+			spec = name + ":" + m.Destination
+		default:
+			continue
+		}
+		if !m.RW && spec != m.Destination {
+			spec += ":ro"
+		}
+		volumes = append(volumes, spec)
+	}
+	sort.Strings(volumes)
+	return volumes
+}
+
+// adoptNetworks returns the service's networks. The project default network
+// is implied, so a service only on it gets no networks entry.
+func adoptNetworks(projectName string, networks map[string]*network.EndpointSettings, file adoptedFile) []string {
+	var names []string
+	onlyDefault := true
+	for network := range networks {
+		name := network
+		if network == projectName+"_default" {
+			name = "default"
+		} else if local, ok := strings.CutPrefix(network, projectName+"_"); ok {
+			name = local
+			file.Networks[name] = adoptedResource{}
+			onlyDefault = false
+		} else {
+			file.Networks[name] = adoptedResource{External: true}
+			onlyDefault = false
+		}
+		names = append(names, name)
+	}
+	if onlyDefault {
+		return nil
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseDependsOn parses compose's depends_on label
+// ("db:service_healthy:false,cache:service_started:false").
+func parseDependsOn(label string) map[string]adoptedDependsOn {
+	if label == "" {
+		return nil
+	}
+	deps := make(map[string]adoptedDependsOn)
+	for _, entry := range strings.Split(label, ",") {
+		parts := strings.Split(entry, ":")
+		if parts[0] == "" {
+			continue
+		}
+		condition := "service_started"
+		if len(parts) > 1 && parts[1] != "" {
+			condition = parts[1]
+		}
+		deps[parts[0]] = adoptedDependsOn{Condition: condition}
+	}
+	return deps
+}
+
+// dotenvSafe matches values that can be written unquoted to a .env file
+var dotenvSafe = regexp.MustCompile(`^[A-Za-z0-9_./:@,+=-]*$`)
+
+// splitEnvironment moves variables into a .env file and references them from
+// the services as ${KEY}. A variable stays inline (with $ escaped) if services
+// disagree on its value or the value can't be written to a .env file safely.
+// Returns the .env content.
+func splitEnvironment(services map[string]*adoptedService, serviceEnv map[string]map[string]string) string {
+	values := make(map[string]string)
+	conflicting := make(map[string]bool)
+	for _, env := range serviceEnv {
+		for k, v := range env {
+			if existing, ok := values[k]; ok && existing != v {
+				conflicting[k] = true
+			}
+			values[k] = v
+		}
+	}
+
+	dotenv := make(map[string]string)
+	for k, v := range values {
+		if conflicting[k] || strings.ContainsAny(v, "\n\r") {
+			continue
+		}
+		switch {
+		case dotenvSafe.MatchString(v):
+			dotenv[k] = v
+		case !strings.Contains(v, "'"):
+			dotenv[k] = "'" + v + "'" // Single quotes are literal in compose .env files
+		}
+	}
+
+	for service, env := range serviceEnv {
+		if len(env) == 0 {
+			continue
+		}
+		environment := make(map[string]string, len(env))
+		for k, v := range env {
+			if _, ok := dotenv[k]; ok {
+				environment[k] = "${" + k + "}"
+			} else {
+				environment[k] = strings.ReplaceAll(v, "$", "$$")
+			}
+		}
+		services[service].Environment = environment
+	}
+
+	keys := make([]string, 0, len(dotenv))
+	for k := range dotenv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + dotenv[k] + "\n")
+	}
+	return b.String()
+}
+
+// isDefaultContainerName reports whether name is one compose generates
+// (project-service-N, or project_service_N from compose v1).
+func isDefaultContainerName(projectName, service, name string) bool {
+	for _, sep := range []string{"-", "_"} {
+		if suffix, ok := strings.CutPrefix(name, projectName+sep+service+sep); ok {
+			if _, err := strconv.Atoi(suffix); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isBuiltImage reports whether image is the default name compose gives a
+// service built from source (project-service or project_service, untagged).
+func isBuiltImage(projectName, service, image string) bool {
+	image = strings.TrimSuffix(image, ":latest")
+	return image == projectName+"-"+service || image == projectName+"_"+service
+}
+
+// isAnonymousVolume reports whether a volume name is a generated 64-char hex ID
+func isAnonymousVolume(name string) bool {
+	if len(name) != 64 {
+		return false
+	}
+	for _, r := range name {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func containerLabels(inspect container.InspectResponse) map[string]string {
+	if inspect.Config == nil {
+		return nil
+	}
+	return inspect.Config.Labels
+}
+
+func containerName(inspect container.InspectResponse) string {
+	if inspect.ContainerJSONBase == nil {
+		return ""
+	}
+	return inspect.Name
+}
+
+func containerNumber(inspect container.InspectResponse) int {
+	n, err := strconv.Atoi(containerLabels(inspect)[labelNumber])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,223 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+func composeContainer(service, number, name string) container.InspectResponse {
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			Name:       "/" + name,
+			HostConfig: &container.HostConfig{},
+		},
+		Config: &container.Config{
+			Labels: map[string]string{
+				labelProject:     "myapp",
+				labelService:     service,
+				labelNumber:      number,
+				labelWorkingDir:  "/opt/myapp",
+				labelConfigFiles: "/opt/myapp/compose.yaml",
+			},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{"myapp_default": {}},
+		},
+	}
+}
+
+func decodeAdopted(t *testing.T, result *AdoptResult) adoptedFile {
+	t.Helper()
+	var file adoptedFile
+	if err := yaml.Unmarshal([]byte(result.ComposeYAML), &file); err != nil {
+		t.Fatalf("compose YAML doesn't parse: %v\n%s", err, result.ComposeYAML)
+	}
+	return file
+}
+
+func TestBuildAdoptResult(t *testing.T) {
+	web := composeContainer("web", "1", "myapp-web-1")
+	web.Config.Image = "nginx:1.27"
+	web.Config.Cmd = []string{"nginx", "-g", "daemon off;"}
+	web.Config.Env = []string{"PATH=/usr/bin", "API_KEY=abc123", "GREETING=hello world", "PRICE=$5", "NOTE=it's $5"}
+	web.Config.Labels["traefik.enable"] = "true"
+	web.Config.Labels["maintainer"] = "nginx"
+	web.Config.Labels[labelDependsOn] = "db:service_healthy:false"
+	web.HostConfig.RestartPolicy = container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}
+	web.HostConfig.PortBindings = nat.PortMap{
+		"80/tcp": {{HostPort: "8080"}, {HostIP: "::", HostPort: "8080"}},
+		"53/udp": {{HostIP: "127.0.0.1", HostPort: "5353"}},
+	}
+	web.Mounts = []container.MountPoint{
+		{Type: mount.TypeBind, Source: "/opt/myapp/html", Destination: "/usr/share/nginx/html", RW: false},
+		{Type: mount.TypeVolume, Name: strings.Repeat("a", 64), Destination: "/var/cache/nginx", RW: true},
+	}
+	web.NetworkSettings.Networks["myapp_frontend"] = &network.EndpointSettings{}
+	web.NetworkSettings.Networks["proxy"] = &network.EndpointSettings{}
+
+	db := composeContainer("db", "1", "postgres")
+	db.Config.Image = "postgres:16"
+	db.Config.Env = []string{"API_KEY=abc123", "PGDATA=/data"}
+	db.Mounts = []container.MountPoint{
+		{Type: mount.TypeVolume, Name: "myapp_dbdata", Destination: "/data", RW: true},
+		{Type: mount.TypeVolume, Name: "shared_backups", Destination: "/backups", RW: true},
+	}
+
+	containers := []adoptedContainer{
+		{
+			inspect: web,
+			image: imageDefaults{
+				Env:     []string{"PATH=/usr/bin"},
+				Cmd:     []string{"nginx", "-g", "daemon off;"},
+				Labels:  map[string]string{"maintainer": "nginx"},
+				Volumes: map[string]struct{}{"/var/cache/nginx": {}},
+			},
+		},
+		{inspect: db},
+	}
+
+	result, err := buildAdoptResult("myapp", containers)
+	if err != nil {
+		t.Fatalf("buildAdoptResult: %v", err)
+	}
+
+	if strings.Join(result.Services, ",") != "db,web" {
+		t.Errorf("Services = %v, want [db web]", result.Services)
+	}
+	if result.WorkingDir != "/opt/myapp" || len(result.ConfigFiles) != 1 {
+		t.Errorf("WorkingDir = %q, ConfigFiles = %v", result.WorkingDir, result.ConfigFiles)
+	}
+
+	file := decodeAdopted(t, result)
+	w := file.Services["web"]
+	if w.Image != "nginx:1.27" || w.ContainerName != "" || w.Command != nil {
+		t.Errorf("web image/name/command = %q/%q/%v; want defaults left out", w.Image, w.ContainerName, w.Command)
+	}
+	if w.Restart != "unless-stopped" {
+		t.Errorf("web restart = %q", w.Restart)
+	}
+	if strings.Join(w.Ports, ",") != "127.0.0.1:5353:53/udp,8080:80" {
+		t.Errorf("web ports = %v", w.Ports)
+	}
+	if strings.Join(w.Volumes, ",") != "/opt/myapp/html:/usr/share/nginx/html:ro" {
+		t.Errorf("web volumes = %v (image VOLUME should be skipped)", w.Volumes)
+	}
+	if strings.Join(w.Networks, ",") != "default,frontend,proxy" {
+		t.Errorf("web networks = %v", w.Networks)
+	}
+	if len(w.Labels) != 1 || w.Labels["traefik.enable"] != "true" {
+		t.Errorf("web labels = %v, want only traefik.enable", w.Labels)
+	}
+	if w.DependsOn["db"].Condition != "service_healthy" {
+		t.Errorf("web depends_on = %v", w.DependsOn)
+	}
+
+	d := file.Services["db"]
+	if d.ContainerName != "postgres" {
+		t.Errorf("db container_name = %q, want postgres", d.ContainerName)
+	}
+	if strings.Join(d.Volumes, ",") != "dbdata:/data,shared_backups:/backups" {
+		t.Errorf("db volumes = %v", d.Volumes)
+	}
+	if d.Networks != nil {
+		t.Errorf("db networks = %v, want none (default only)", d.Networks)
+	}
+
+	if file.Volumes["dbdata"].External || !file.Volumes["shared_backups"].External {
+		t.Errorf("top-level volumes = %v", file.Volumes)
+	}
+	if file.Networks["frontend"].External || !file.Networks["proxy"].External {
+		t.Errorf("top-level networks = %v", file.Networks)
+	}
+
+	// Shared and quotable values move to .env; the rest stay inline with $ escaped
+	wantEnv := "API_KEY=abc123\nGREETING='hello world'\nPGDATA=/data\nPRICE='$5'\n"
+	if result.EnvFileContent != wantEnv {
+		t.Errorf("EnvFileContent = %q, want %q", result.EnvFileContent, wantEnv)
+	}
+	if w.Environment["API_KEY"] != "${API_KEY}" || d.Environment["API_KEY"] != "${API_KEY}" {
+		t.Errorf("API_KEY not referenced from .env: web=%q db=%q", w.Environment["API_KEY"], d.Environment["API_KEY"])
+	}
+	if w.Environment["NOTE"] != "it's $$5" {
+		t.Errorf("NOTE = %q, want escaped inline value", w.Environment["NOTE"])
+	}
+	if _, ok := w.Environment["PATH"]; ok {
+		t.Error("image environment should be left out")
+	}
+}
+
+func TestBuildAdoptResultConflictingEnvStaysInline(t *testing.T) {
+	a := composeContainer("a", "1", "myapp-a-1")
+	a.Config.Env = []string{"MODE=primary"}
+	b := composeContainer("b", "1", "myapp-b-1")
+	b.Config.Env = []string{"MODE=replica"}
+
+	result, err := buildAdoptResult("myapp", []adoptedContainer{{inspect: a}, {inspect: b}})
+	if err != nil {
+		t.Fatalf("buildAdoptResult: %v", err)
+	}
+	if result.EnvFileContent != "" {
+		t.Errorf("EnvFileContent = %q, want empty", result.EnvFileContent)
+	}
+	file := decodeAdopted(t, result)
+	if file.Services["a"].Environment["MODE"] != "primary" || file.Services["b"].Environment["MODE"] != "replica" {
+		t.Errorf("MODE not kept inline per service: %+v", file)
+	}
+}
+
+func TestBuildAdoptResultReplicasAndWarnings(t *testing.T) {
+	var containers []adoptedContainer
+	for _, n := range []string{"2", "1", "3"} {
+		c := composeContainer("worker", n, "myapp-worker-"+n)
+		c.Config.Image = "myapp-worker"
+		c.Config.Cmd = []string{"work", "--id", n}
+		c.HostConfig.Tmpfs = map[string]string{"/tmp": ""}
+		containers = append(containers, adoptedContainer{inspect: c})
+	}
+
+	result, err := buildAdoptResult("myapp", containers)
+	if err != nil {
+		t.Fatalf("buildAdoptResult: %v", err)
+	}
+	w := decodeAdopted(t, result).Services["worker"]
+	if w.Scale != 3 {
+		t.Errorf("scale = %d, want 3", w.Scale)
+	}
+	if strings.Join(w.Command, " ") != "work --id 1" {
+		t.Errorf("command = %v, want replica 1's", w.Command)
+	}
+	if len(result.Warnings) != 2 {
+		t.Errorf("Warnings = %v, want build and tmpfs warnings", result.Warnings)
+	}
+}
+
+func TestBuildAdoptResultNoServices(t *testing.T) {
+	c := composeContainer("", "1", "x")
+	if _, err := buildAdoptResult("myapp", []adoptedContainer{{inspect: c}}); err != ErrProjectNotFound {
+		t.Errorf("err = %v, want ErrProjectNotFound", err)
+	}
+}
+
+func TestIsDefaultContainerName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"myapp-web-1", true},
+		{"myapp_web_2", true},
+		{"myapp-web-x", false},
+		{"web", false},
+		{"myapp-webapp-1", false},
+	}
+	for _, tt := range tests {
+		if got := isDefaultContainerName("myapp", "web", tt.name); got != tt.want {
+			t.Errorf("isDefaultContainerName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// =============================================================================
+// Bind Mount Path Checks
+// =============================================================================
+//
+// Docker creates a missing bind source as an empty root-owned directory, so a
+// typo or an unprepared host only shows up once the app misbehaves. Before
+// "up", the bind sources are checked on the target Docker host itself (which
+// may be remote) by a short-lived helper container with the host root mounted
+// at /host, and missing directories can be created with a chosen owner.
+
+const (
+	// DefaultBindCheckImage runs the bind path checks
+	DefaultBindCheckImage = "busybox:stable"
+	// bindCheckTimeout bounds the helper container, including an image pull
+	bindCheckTimeout = 2 * time.Minute
+	// bindCheckLabel marks helper containers
+	bindCheckLabel = "com.dockmon.helper"
+)
+
+// BindPathOptions enables the pre-deploy bind mount check (DeployRequest.BindPaths)
+type BindPathOptions struct {
+	// Create makes missing directory sources instead of failing. Sources that
+	// look like files (have an extension) are never created.
+	Create bool `json:"create,omitempty"`
+	// UID/GID own the created directories; when set, existing directories
+	// must also be writable by them.
+	UID *int `json:"uid,omitempty"`
+	GID *int `json:"gid,omitempty"`
+	// MinFreeMB fails the check if a source's filesystem has less space free
+	MinFreeMB int64 `json:"min_free_mb,omitempty"`
+	// HelperImage overrides DefaultBindCheckImage (needs sh, stat, df)
+	HelperImage string `json:"helper_image,omitempty"`
+}
+
+// BindPathStatus is the outcome of checking one bind source
+type BindPathStatus string
+
+const (
+	BindPathOK          BindPathStatus = "ok"
+	BindPathCreated     BindPathStatus = "created"
+	BindPathMissing     BindPathStatus = "missing"
+	BindPathNotWritable BindPathStatus = "not_writable"
+	BindPathLowSpace    BindPathStatus = "low_space"
+)
+
+// BindPathResult reports the check of one service's bind mount
+type BindPathResult struct {
+	Service string         `json:"service"`
+	Source  string         `json:"source"`
+	Target  string         `json:"target"`
+	Status  BindPathStatus `json:"status"`
+	Message string         `json:"message,omitempty"`
+}
+
+// Failed reports whether the bind mount would break the deployment
+func (r BindPathResult) Failed() bool {
+	return r.Status != BindPathOK && r.Status != BindPathCreated
+}
+
+// bindMount is one service's bind mount in the loaded project
+type bindMount struct {
+	service string
+	source  string
+	target  string
+}
+
+// bindPathInfo is what the helper reported for one source path
+type bindPathInfo struct {
+	created  bool
+	exists   bool
+	uid, gid int
+	mode     uint32
+	isDir    bool
+	availKB  int64 // -1 if unknown
+}
+
+// bindCheckScript stats each "s:<path>" argument and, for "c:<path>",
+// creates it first (chowning every new directory to $OWNER). Prints one
+// tab-separated line per path: path, created, available KB on the nearest
+// existing ancestor, then uid, gid, octal mode and file type (or "-" fields
+// and "missing").
+const bindCheckScript = `for arg in "$@"; do
+  op=${arg%%:*}; p=${arg#*:}; h="/host$p"; created=0
+  if [ "$op" = c ] && [ ! -e "$h" ]; then
+    top="$h"
+    while [ ! -e "$(dirname "$top")" ]; do top=$(dirname "$top"); done
+    if mkdir -p "$h" 2>/dev/null; then
+      created=1
+      if [ -n "$OWNER" ]; then chown -R "$OWNER" "$top"; fi
+    fi
+  fi
+  d="$h"; while [ ! -e "$d" ]; do d=$(dirname "$d"); done
+  avail=$(df -Pk "$d" | awk 'NR==2 {print $4}')
+  if [ -e "$h" ]; then info=$(stat -L -c '%u	%g	%a	%F' "$h"); else info='-	-	-	missing'; fi
+  printf '%s\t%s\t%s\t%s\n' "$p" "$created" "${avail:--}" "$info"
+done
+`
+
+// projectBindMounts lists the absolute bind sources of every service, sorted
+// by service and target.
+func projectBindMounts(project *types.Project) []bindMount {
+	var mounts []bindMount
+	for name, svc := range project.Services {
+		for _, vol := range svc.Volumes {
+			if vol.Type != types.VolumeTypeBind || !path.IsAbs(vol.Source) {
+				continue
+			}
+			mounts = append(mounts, bindMount{service: name, source: path.Clean(vol.Source), target: vol.Target})
+		}
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		if mounts[i].service != mounts[j].service {
+			return mounts[i].service < mounts[j].service
+		}
+		return mounts[i].target < mounts[j].target
+	})
+	return mounts
+}
+
+// looksLikeFile guesses whether a missing bind source is meant to be a file
+// (e.g. ./nginx.conf), which must not be created as a directory.
+func looksLikeFile(source string) bool {
+	base := path.Base(source)
+	return !strings.HasPrefix(base, ".") && path.Ext(base) != ""
+}
+
+// checkBindPaths checks the project's bind sources on the target Docker host.
+// Returns nil results when there is nothing to check or the host runs
+// Windows containers.
+func (s *Service) checkBindPaths(ctx context.Context, project *types.Project, opts BindPathOptions) ([]BindPathResult, error) {
+	mounts := projectBindMounts(project)
+	if len(mounts) == 0 || s.dockerClient == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bindCheckTimeout)
+	defer cancel()
+
+	info, err := s.dockerClient.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Docker host: %w", err)
+	}
+	if info.OSType == "windows" {
+		s.logInfo("Skipping bind path check on Windows Docker host", nil)
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var args []string
+	for _, m := range mounts {
+		if seen[m.source] || strings.ContainsAny(m.source, "\t\n") {
+			continue
+		}
+		seen[m.source] = true
+		op := "s"
+		if opts.Create && !looksLikeFile(m.source) {
+			op = "c"
+		}
+		args = append(args, op+":"+m.source)
+	}
+
+	output, err := runBindCheckHelper(ctx, s.dockerClient, opts, args)
+	if err != nil {
+		return nil, err
+	}
+	return evaluateBindPaths(mounts, parseBindCheckOutput(output), opts), nil
+}
+
+// runBindCheckHelper runs bindCheckScript in a throwaway container on the
+// Docker host and returns its stdout.
+func runBindCheckHelper(ctx context.Context, cli *client.Client, opts BindPathOptions, args []string) (string, error) {
+	helperImage := opts.HelperImage
+	if helperImage == "" {
+		helperImage = DefaultBindCheckImage
+	}
+	if _, err := cli.ImageInspect(ctx, helperImage); err != nil {
+		reader, err := cli.ImagePull(ctx, helperImage, image.PullOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to pull bind check image %s: %w", helperImage, err)
+		}
+		_, _ = io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+	// Read-only unless directories may be created
+	hostRoot := "/:/host:ro"
+	var env []string
+	if opts.Create {
+		hostRoot = "/:/host"
+		if owner := bindPathOwner(opts); owner != "" {
+			env = append(env, "OWNER="+owner)
+		}
+	}
+
+	resp, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:      helperImage,
+			Entrypoint: []string{"sh", "-c", bindCheckScript, "bindcheck"},
+			Cmd:        args,
+			Env:        env,
+			User:       "0:0",
+			Labels:     map[string]string{bindCheckLabel: "bind-check"},
+		},
+		&container.HostConfig{
+			Binds:       []string{hostRoot},
+			NetworkMode: "none",
+		},
+		nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create bind check container: %w", err)
+	}
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = cli.ContainerRemove(removeCtx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start bind check container: %w", err)
+	}
+
+	waitCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		return "", fmt.Errorf("bind check container failed: %w", err)
+	case status := <-waitCh:
+		exitCode = status.StatusCode
+	}
+
+	logs, err := cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to read bind check output: %w", err)
+	}
+	defer logs.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return "", fmt.Errorf("failed to read bind check output: %w", err)
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("bind check exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// bindPathOwner returns the chown argument for created directories
+func bindPathOwner(opts BindPathOptions) string {
+	switch {
+	case opts.UID != nil && opts.GID != nil:
+		return fmt.Sprintf("%d:%d", *opts.UID, *opts.GID)
+	case opts.UID != nil:
+		return strconv.Itoa(*opts.UID)
+	case opts.GID != nil:
+		return fmt.Sprintf(":%d", *opts.GID)
+	}
+	return ""
+}
+
+// parseBindCheckOutput parses bindCheckScript's output, keyed by path.
+// Malformed lines are skipped.
+func parseBindCheckOutput(output string) map[string]bindPathInfo {
+	infos := make(map[string]bindPathInfo)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		info := bindPathInfo{created: fields[1] == "1", availKB: -1}
+		if avail, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			info.availKB = avail
+		}
+		if fields[6] != "missing" {
+			info.exists = true
+			info.uid, _ = strconv.Atoi(fields[3])
+			info.gid, _ = strconv.Atoi(fields[4])
+			mode, _ := strconv.ParseUint(fields[5], 8, 32)
+			info.mode = uint32(mode)
+			info.isDir = fields[6] == "directory"
+		}
+		infos[fields[0]] = info
+	}
+	return infos
+}
+
+// evaluateBindPaths turns the helper's findings into per-mount results.
+func evaluateBindPaths(mounts []bindMount, infos map[string]bindPathInfo, opts BindPathOptions) []BindPathResult {
+	results := make([]BindPathResult, 0, len(mounts))
+	for _, m := range mounts {
+		result := BindPathResult{Service: m.service, Source: m.source, Target: m.target, Status: BindPathOK}
+		info, ok := infos[m.source]
+
+		switch {
+		case !ok:
+			result.Status = BindPathMissing
+			result.Message = "path could not be checked"
+		case !info.exists && opts.Create && looksLikeFile(m.source):
+			result.Status = BindPathMissing
+			result.Message = "file does not exist (only directories are created)"
+		case !info.exists:
+			result.Status = BindPathMissing
+			result.Message = "path does not exist on the Docker host"
+		case info.isDir && !writableBy(info, opts):
+			result.Status = BindPathNotWritable
+			result.Message = fmt.Sprintf("directory owned by %d:%d (mode %o) is not writable by %s",
+				info.uid, info.gid, info.mode, bindPathOwner(opts))
+		case opts.MinFreeMB > 0 && info.availKB >= 0 && info.availKB < opts.MinFreeMB*1024:
+			result.Status = BindPathLowSpace
+			result.Message = fmt.Sprintf("only %d MB free, need %d MB", info.availKB/1024, opts.MinFreeMB)
+		case info.created:
+			result.Status = BindPathCreated
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// writableBy reports whether the configured owner can write to a directory.
+// Without a configured UID/GID (or as root) any directory passes.
+func writableBy(info bindPathInfo, opts BindPathOptions) bool {
+	switch {
+	case opts.UID == nil && opts.GID == nil:
+		return true
+	case opts.UID != nil && *opts.UID == 0:
+		return true
+	case opts.UID != nil && *opts.UID == info.uid:
+		return info.mode&0o200 != 0
+	case opts.GID != nil && *opts.GID == info.gid:
+		return info.mode&0o020 != 0
+	}
+	return info.mode&0o002 != 0
+}
+
+// bindPathFailure summarizes failed bind mounts for the deploy error and
+// lists the affected services. Returns "" if none failed.
+func bindPathFailure(results []BindPathResult) (string, []string) {
+	var problems []string
+	var services []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if !r.Failed() {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("service %s: %s (%s): %s", r.Service, r.Source, r.Status, r.Message))
+		if !seen[r.Service] {
+			seen[r.Service] = true
+			services = append(services, r.Service)
+		}
+	}
+	if len(problems) == 0 {
+		return "", nil
+	}
+	return "Bind mount check failed: " + strings.Join(problems, "; "), services
+}
+
+// logBindPaths logs created directories so the change to the host is visible
+func (s *Service) logBindPaths(results []BindPathResult) {
+	for _, r := range results {
+		if r.Status == BindPathCreated {
+			s.logInfo("Created bind mount directory", logrus.Fields{"service": r.Service, "path": r.Source})
+		}
+	}
+}
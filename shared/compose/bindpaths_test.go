@@ -0,0 +1,138 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestProjectBindMounts(t *testing.T) {
+	project := &types.Project{Services: types.Services{
+		"web": {Name: "web", Volumes: []types.ServiceVolumeConfig{
+			{Type: types.VolumeTypeBind, Source: "/srv/web/", Target: "/data"},
+			{Type: types.VolumeTypeVolume, Source: "cache", Target: "/cache"},
+			{Type: types.VolumeTypeBind, Source: "relative", Target: "/x"},
+		}},
+		"db": {Name: "db", Volumes: []types.ServiceVolumeConfig{
+			{Type: types.VolumeTypeBind, Source: "/srv/db", Target: "/var/lib/db"},
+		}},
+	}}
+
+	mounts := projectBindMounts(project)
+	if len(mounts) != 2 {
+		t.Fatalf("mounts = %+v, want only the absolute bind sources", mounts)
+	}
+	if mounts[0].service != "db" || mounts[1].source != "/srv/web" {
+		t.Errorf("mounts = %+v, want sorted by service with cleaned sources", mounts)
+	}
+}
+
+func TestParseBindCheckOutput(t *testing.T) {
+	output := "/srv/data\t0\t2048\t1000\t1000\t755\tdirectory\n" +
+		"/srv/new\t1\t4096\t0\t0\t755\tdirectory\n" +
+		"/srv/missing\t0\t-\t-\t-\t-\tmissing\n" +
+		"garbage line\n"
+
+	infos := parseBindCheckOutput(output)
+	if len(infos) != 3 {
+		t.Fatalf("parsed %d entries, want 3", len(infos))
+	}
+	data := infos["/srv/data"]
+	if !data.exists || !data.isDir || data.uid != 1000 || data.mode != 0o755 || data.availKB != 2048 {
+		t.Errorf("/srv/data = %+v", data)
+	}
+	if !infos["/srv/new"].created {
+		t.Error("/srv/new should be marked created")
+	}
+	missing := infos["/srv/missing"]
+	if missing.exists || missing.availKB != -1 {
+		t.Errorf("/srv/missing = %+v", missing)
+	}
+}
+
+func TestEvaluateBindPaths(t *testing.T) {
+	mounts := []bindMount{
+		{service: "app", source: "/srv/ok", target: "/a"},
+		{service: "app", source: "/srv/new", target: "/b"},
+		{service: "app", source: "/srv/missing", target: "/c"},
+		{service: "proxy", source: "/srv/nginx.conf", target: "/etc/nginx/nginx.conf"},
+		{service: "db", source: "/srv/rootonly", target: "/d"},
+		{service: "db", source: "/srv/full", target: "/e"},
+	}
+	infos := map[string]bindPathInfo{
+		"/srv/ok":         {exists: true, isDir: true, uid: 1000, gid: 1000, mode: 0o755, availKB: 1 << 20},
+		"/srv/new":        {exists: true, created: true, isDir: true, uid: 1000, gid: 1000, mode: 0o755, availKB: 1 << 20},
+		"/srv/missing":    {availKB: 1 << 20},
+		"/srv/nginx.conf": {availKB: 1 << 20},
+		"/srv/rootonly":   {exists: true, isDir: true, uid: 0, gid: 0, mode: 0o755, availKB: 1 << 20},
+		"/srv/full":       {exists: true, isDir: true, uid: 1000, gid: 1000, mode: 0o755, availKB: 512},
+	}
+	opts := BindPathOptions{Create: true, UID: intPtr(1000), GID: intPtr(1000), MinFreeMB: 100}
+
+	results := evaluateBindPaths(mounts, infos, opts)
+	want := []BindPathStatus{BindPathOK, BindPathCreated, BindPathMissing, BindPathMissing, BindPathNotWritable, BindPathLowSpace}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("%s: status = %s, want %s (%s)", r.Source, r.Status, want[i], r.Message)
+		}
+	}
+	if !strings.Contains(results[3].Message, "only directories") {
+		t.Errorf("file source message = %q", results[3].Message)
+	}
+
+	msg, services := bindPathFailure(results)
+	if !strings.Contains(msg, "service app: /srv/missing") || strings.Join(services, ",") != "app,proxy,db" {
+		t.Errorf("failure = %q, services = %v", msg, services)
+	}
+	if msg, _ := bindPathFailure(results[:2]); msg != "" {
+		t.Errorf("ok/created results reported as failure: %q", msg)
+	}
+}
+
+func TestWritableBy(t *testing.T) {
+	dir := bindPathInfo{exists: true, isDir: true, uid: 1000, gid: 100, mode: 0o750}
+
+	tests := []struct {
+		name string
+		opts BindPathOptions
+		want bool
+	}{
+		{"no owner configured", BindPathOptions{}, true},
+		{"root", BindPathOptions{UID: intPtr(0)}, true},
+		{"owner", BindPathOptions{UID: intPtr(1000)}, true},
+		{"group without write", BindPathOptions{UID: intPtr(2000), GID: intPtr(100)}, false},
+		{"other", BindPathOptions{UID: intPtr(2000), GID: intPtr(200)}, false},
+	}
+	for _, tt := range tests {
+		if got := writableBy(dir, tt.opts); got != tt.want {
+			t.Errorf("%s: writableBy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBindPathOwner(t *testing.T) {
+	if got := bindPathOwner(BindPathOptions{UID: intPtr(1000), GID: intPtr(1000)}); got != "1000:1000" {
+		t.Errorf("uid+gid = %q", got)
+	}
+	if got := bindPathOwner(BindPathOptions{GID: intPtr(50)}); got != ":50" {
+		t.Errorf("gid only = %q", got)
+	}
+	if got := bindPathOwner(BindPathOptions{}); got != "" {
+		t.Errorf("none = %q", got)
+	}
+}
+
+func TestLooksLikeFile(t *testing.T) {
+	for source, want := range map[string]bool{
+		"/srv/nginx.conf": true,
+		"/srv/data":       false,
+		"/srv/.config":    false,
+	} {
+		if got := looksLikeFile(source); got != want {
+			t.Errorf("looksLikeFile(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
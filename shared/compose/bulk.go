@@ -0,0 +1,384 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/darthnorse/dockmon-shared/updatecheck"
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// =============================================================================
+// Host-wide Project Operations
+// =============================================================================
+//
+// Projects are found by the labels compose puts on their containers, so
+// stacks started with the docker compose CLI are listed alongside DockMon's.
+// Any project can be stopped and started; redeploying needs the compose
+// files DockMon keeps in the stack directory.
+
+// BulkAction is an operation applied to several projects at once
+type BulkAction string
+
+const (
+	BulkStop  BulkAction = "stop"
+	BulkStart BulkAction = "start"
+	// BulkUpdate pulls and redeploys projects whose images were re-pushed
+	// under the tags they run
+	BulkUpdate BulkAction = "update"
+)
+
+// Valid reports whether a is a known bulk action
+func (a BulkAction) Valid() bool {
+	switch a {
+	case BulkStop, BulkStart, BulkUpdate:
+		return true
+	}
+	return false
+}
+
+// Project statuses in ProjectSummary.Status
+const (
+	ProjectRunning = "running"
+	ProjectPartial = "partial" // Some containers running
+	ProjectStopped = "stopped"
+)
+
+// SelfProject returns the compose project of the container this process runs
+// in when that container is on cli's daemon, else "". DockMon's standard
+// install is a compose project, which bulk operations must not stop.
+func SelfProject(ctx context.Context, cli client.APIClient) string {
+	id := OwnContainerID()
+	if id == "" {
+		return ""
+	}
+	inspect, err := cli.ContainerInspect(ctx, id)
+	if err != nil || inspect.Config == nil {
+		return ""
+	}
+	return inspect.Config.Labels[labelProject]
+}
+
+// BulkSkipReason returns why action doesn't apply to p, or "" if it does.
+// selfProject is the project DockMon runs in (see SelfProject); it is never
+// stopped or redeployed, since that would take down the service doing it.
+func BulkSkipReason(action BulkAction, p ProjectSummary, selfProject string) string {
+	switch {
+	case selfProject != "" && p.Name == selfProject && action != BulkStart:
+		return "runs DockMon itself: stop or update it manually"
+	case action == BulkStop && p.Status == ProjectStopped:
+		return "already stopped"
+	case action == BulkStart && p.Status == ProjectRunning:
+		return "already running"
+	case action == BulkUpdate && !p.Managed:
+		return "not deployed by DockMon: its compose files aren't in the stack directory"
+	}
+	return ""
+}
+
+// ProjectContainer is one container of a listed project
+type ProjectContainer struct {
+	ID      string `json:"id"` // SHORT ID (12 chars)
+	Name    string `json:"name"`
+	Service string `json:"service"`
+	Image   string `json:"image"`
+	State   string `json:"state"`
+}
+
+// ProjectImageUpdate is a service whose image tag now points at a different
+// image in the registry, so pulling and redeploying picks up the new one
+type ProjectImageUpdate struct {
+	Service      string `json:"service"`
+	Image        string `json:"image"`
+	LocalDigest  string `json:"local_digest"`
+	RemoteDigest string `json:"remote_digest"`
+}
+
+// ProjectSummary is a compose project found on a Docker host
+type ProjectSummary struct {
+	Name        string             `json:"name"`
+	Status      string             `json:"status"`
+	Running     int                `json:"running"`
+	Services    []string           `json:"services"`
+	Containers  []ProjectContainer `json:"containers"`
+	WorkingDir  string             `json:"working_dir,omitempty"`
+	ConfigFiles []string           `json:"config_files,omitempty"`
+	// Managed is set when the stack directory holds the project's compose
+	// file, so it can be redeployed
+	Managed bool `json:"managed"`
+
+	// Set by CheckProjectUpdates
+	Updates      []ProjectImageUpdate `json:"updates,omitempty"`
+	UpdateErrors []string             `json:"update_errors,omitempty"` // Per image, e.g. registry failures
+}
+
+// ListProjects returns the compose projects on the host, sorted by name.
+// One-off containers (compose run) are left out. stacksDir is where DockMon
+// keeps stack files; empty means the default.
+func ListProjects(ctx context.Context, cli client.APIClient, stacksDir string) ([]ProjectSummary, error) {
+	if stacksDir == "" {
+		stacksDir = defaultStacksDir
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", api.ProjectLabel)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	byName := make(map[string]*ProjectSummary)
+	for _, c := range containers {
+		name := c.Labels[api.ProjectLabel]
+		if name == "" || strings.EqualFold(c.Labels[api.OneoffLabel], "true") {
+			continue
+		}
+		p, ok := byName[name]
+		if !ok {
+			p = &ProjectSummary{Name: name}
+			byName[name] = p
+		}
+		ctr := ProjectContainer{
+			ID:      shortContainerID(c.ID),
+			Service: c.Labels[api.ServiceLabel],
+			Image:   c.Image,
+			State:   c.State,
+		}
+		if len(c.Names) > 0 {
+			ctr.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		p.Containers = append(p.Containers, ctr)
+		if c.State == "running" {
+			p.Running++
+		}
+		if p.WorkingDir == "" {
+			p.WorkingDir = c.Labels[api.WorkingDirLabel]
+		}
+		if p.ConfigFiles == nil && c.Labels[api.ConfigFilesLabel] != "" {
+			p.ConfigFiles = strings.Split(c.Labels[api.ConfigFilesLabel], ",")
+		}
+	}
+
+	projects := make([]ProjectSummary, 0, len(byName))
+	for _, p := range byName {
+		services := make(map[string]bool)
+		for _, c := range p.Containers {
+			if c.Service != "" && !services[c.Service] {
+				services[c.Service] = true
+				p.Services = append(p.Services, c.Service)
+			}
+		}
+		sort.Strings(p.Services)
+		sort.Slice(p.Containers, func(i, j int) bool { return p.Containers[i].Name < p.Containers[j].Name })
+
+		switch p.Running {
+		case len(p.Containers):
+			p.Status = ProjectRunning
+		case 0:
+			p.Status = ProjectStopped
+		default:
+			p.Status = ProjectPartial
+		}
+		if stackDir, err := GetStackDir(stacksDir, p.Name); err == nil {
+			_, err := os.Stat(filepath.Join(stackDir, "docker-compose.yml"))
+			p.Managed = err == nil
+		}
+		projects = append(projects, *p)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// CheckProjectUpdates asks the registries whether the images the projects'
+// containers run were re-pushed under the same tag, filling in each
+// project's Updates and UpdateErrors. Only same-tag changes count: moving to
+// a newer release means editing the compose file. Containers pinned or
+// opted out of updates by their labels are skipped.
+func CheckProjectUpdates(ctx context.Context, cli *client.Client, log *logrus.Logger, projects []ProjectSummary, credentials []RegistryCredential) error {
+	opts := updatecheck.Options{Auth: updateCheckAuth(credentials)}
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query Docker host: %w", err)
+	}
+	opts.Platform = registry.Platform{OS: info.OSType, Architecture: registry.NormalizeArch(info.Architecture)}
+
+	results, err := updatecheck.NewChecker(cli, log).Check(ctx, opts)
+	if err != nil {
+		return err
+	}
+	applyUpdateResults(projects, results)
+	return nil
+}
+
+// applyUpdateResults matches update check results to the projects' containers
+func applyUpdateResults(projects []ProjectSummary, results []updatecheck.Result) {
+	type ref struct {
+		project int
+		service string
+	}
+	owners := make(map[string]ref)
+	for i, p := range projects {
+		for _, c := range p.Containers {
+			owners[c.ID] = ref{i, c.Service}
+		}
+	}
+
+	for _, r := range results {
+		owner, ok := owners[shortContainerID(r.ContainerID)]
+		if !ok {
+			continue
+		}
+		p := &projects[owner.project]
+		if r.Error != "" {
+			msg := fmt.Sprintf("%s: %s", r.Image, r.Error)
+			if !slices.Contains(p.UpdateErrors, msg) {
+				p.UpdateErrors = append(p.UpdateErrors, msg)
+			}
+			continue
+		}
+		if r.LocalDigest == "" || r.RemoteDigest == "" || r.LocalDigest == r.RemoteDigest {
+			continue
+		}
+		// Replicas of a service share one entry
+		if !slices.ContainsFunc(p.Updates, func(u ProjectImageUpdate) bool { return u.Service == owner.service }) {
+			p.Updates = append(p.Updates, ProjectImageUpdate{
+				Service:      owner.service,
+				Image:        r.Image,
+				LocalDigest:  r.LocalDigest,
+				RemoteDigest: r.RemoteDigest,
+			})
+		}
+	}
+}
+
+// updateCheckAuth keys registry credentials by registry host, as the update
+// checker expects
+func updateCheckAuth(credentials []RegistryCredential) map[string]registry.Credentials {
+	if len(credentials) == 0 {
+		return nil
+	}
+	auth := make(map[string]registry.Credentials, len(credentials))
+	for _, cred := range credentials {
+		host := strings.TrimPrefix(strings.TrimPrefix(cred.RegistryURL, "https://"), "http://")
+		host, _, _ = strings.Cut(host, "/")
+		if isDockerHub(host) {
+			host = "docker.io"
+		}
+		auth[host] = registry.Credentials{Username: cred.Username, Password: cred.Password}
+	}
+	return auth
+}
+
+// StopProject stops a project's containers, dependents first, like
+// `docker compose -p <project> stop`. The compose files aren't needed.
+func (s *Service) StopProject(ctx context.Context, req DeployRequest) error {
+	composeService, cli, tlsFiles, err := s.createComposeService(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create compose service: %w", err)
+	}
+	defer cli.Client().Close()
+	defer tlsFiles.Cleanup(s.log)
+
+	return composeService.Stop(ctx, req.ProjectName, api.StopOptions{})
+}
+
+// StartProject starts a project's stopped containers, dependencies first,
+// like `docker compose -p <project> start`. The compose files aren't needed.
+func (s *Service) StartProject(ctx context.Context, req DeployRequest) error {
+	composeService, cli, tlsFiles, err := s.createComposeService(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create compose service: %w", err)
+	}
+	defer cli.Client().Close()
+	defer tlsFiles.Cleanup(s.log)
+
+	return composeService.Start(ctx, req.ProjectName, api.StartOptions{})
+}
+
+// Redeploy runs "up" for a stack from the files of its last deployment in
+// the stack directory, e.g. with PullImages to pick up re-pushed images. The
+// request's compose content and env files are ignored and nothing in the
+// stack directory is rewritten. Secrets aren't kept on disk, so they must be
+// passed again; likewise the variable set (EnvSetVars) and profiles.
+func (s *Service) Redeploy(ctx context.Context, req DeployRequest) (result *DeployResult) {
+	req.Action = "up"
+	defer func() {
+		if result != nil {
+			result.Action = req.Action
+		}
+	}()
+
+	stacksDir := req.StacksDir
+	if stacksDir == "" {
+		stacksDir = defaultStacksDir
+	}
+
+	s.logInfo("Redeploying compose stack from its stack directory", logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"project_name":  req.ProjectName,
+		"stacks_dir":    stacksDir,
+	})
+	s.sendProgress(ProgressEvent{
+		Stage:    StageValidating,
+		Progress: 5,
+		Message:  "Validating deployment...",
+	})
+
+	stackDir, err := GetStackDir(stacksDir, req.ProjectName)
+	if err != nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Invalid stack: %v", err))
+	}
+	composeFile := filepath.Join(stackDir, "docker-compose.yml")
+	if _, err := os.Stat(composeFile); err != nil {
+		msg := fmt.Sprintf("Stack %s has no compose file in %s; it was not deployed by DockMon", req.ProjectName, stackDir)
+		failed := s.failResult(req.DeploymentID, msg)
+		failed.Error = NewValidationError(msg)
+		return failed
+	}
+	manifest, err := readComposeManifest(stackDir)
+	if err != nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to read compose manifest: %v", err))
+	}
+	for _, name := range manifest.Overrides {
+		if !SafeComposeFilename(name) {
+			return s.failResult(req.DeploymentID, fmt.Sprintf("Unsafe compose override in manifest: %q", name))
+		}
+	}
+	req.ComposeYAML = ""
+	req.ComposeFiles = nil
+	req.ComposeOverrides = manifest.Overrides
+	req.EnvFiles = nil
+	req.EnvFileContent = ""
+	req.Template = nil
+
+	if err := req.ValidateSecrets(); err != nil {
+		return s.failResult(req.DeploymentID, err.Error())
+	}
+	if req.EnvSet != "" && req.EnvSetVars == nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("variable set %q was not resolved; variable sets are only available through the compose service", req.EnvSet))
+	}
+
+	// The revision keeps the digests running now, so a bad image can be
+	// rolled back
+	if req.KeepRevisions >= 0 {
+		s.saveRevision(ctx, stacksDir, req)
+	}
+
+	before := s.projectContainerIDs(ctx, req.ProjectName)
+	result = s.runComposeUp(ctx, req, composeFile)
+	if !result.Success && errors.Is(context.Cause(ctx), ErrDeployCancelled) {
+		return s.cancelledResult(req, before)
+	}
+	return result
+}
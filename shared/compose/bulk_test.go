@@ -0,0 +1,166 @@
+package compose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/updatecheck"
+	"github.com/docker/docker/client"
+)
+
+func TestListProjects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/json") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[
+			{"Id":"aaaaaaaaaaaa1111","Names":["/web-app-1"],"Image":"nginx:1.27","State":"running",
+			 "Labels":{"com.docker.compose.project":"web","com.docker.compose.service":"app","com.docker.compose.oneoff":"False",
+			           "com.docker.compose.project.working_dir":"/stacks/web","com.docker.compose.project.config_files":"/stacks/web/docker-compose.yml"}},
+			{"Id":"bbbbbbbbbbbb2222","Names":["/web-db-1"],"Image":"postgres:16","State":"exited",
+			 "Labels":{"com.docker.compose.project":"web","com.docker.compose.service":"db","com.docker.compose.oneoff":"False"}},
+			{"Id":"cccccccccccc3333","Names":["/web-app-run-1"],"Image":"nginx:1.27","State":"running",
+			 "Labels":{"com.docker.compose.project":"web","com.docker.compose.service":"app","com.docker.compose.oneoff":"True"}},
+			{"Id":"dddddddddddd4444","Names":["/cli-worker-1"],"Image":"worker:latest","State":"running",
+			 "Labels":{"com.docker.compose.project":"cli","com.docker.compose.service":"worker"}}
+		]`))
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	stacksDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(stacksDir, "web"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stacksDir, "web", "docker-compose.yml"), []byte("services: {}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	projects, err := ListProjects(context.Background(), cli, stacksDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != 2 || projects[0].Name != "cli" || projects[1].Name != "web" {
+		t.Fatalf("projects = %+v", projects)
+	}
+	cliProject, web := projects[0], projects[1]
+	if cliProject.Status != ProjectRunning || cliProject.Managed {
+		t.Errorf("cli = %+v", cliProject)
+	}
+	if web.Status != ProjectPartial || web.Running != 1 || !web.Managed {
+		t.Errorf("web = %+v", web)
+	}
+	if !reflect.DeepEqual(web.Services, []string{"app", "db"}) || len(web.Containers) != 2 {
+		t.Errorf("web services = %v, containers = %+v (one-off container must be skipped)", web.Services, web.Containers)
+	}
+	if web.Containers[0].ID != "aaaaaaaaaaaa" || web.Containers[0].Name != "web-app-1" {
+		t.Errorf("web container = %+v", web.Containers[0])
+	}
+	if web.WorkingDir != "/stacks/web" || !reflect.DeepEqual(web.ConfigFiles, []string{"/stacks/web/docker-compose.yml"}) {
+		t.Errorf("web labels: working_dir %q, config_files %v", web.WorkingDir, web.ConfigFiles)
+	}
+}
+
+func TestBulkSkipReason(t *testing.T) {
+	running := ProjectSummary{Name: "web", Status: ProjectRunning, Managed: true}
+	stopped := ProjectSummary{Name: "web", Status: ProjectStopped, Managed: true}
+	self := ProjectSummary{Name: "dockmon", Status: ProjectRunning, Managed: true}
+	cliStack := ProjectSummary{Name: "cli", Status: ProjectRunning}
+
+	tests := []struct {
+		name    string
+		action  BulkAction
+		project ProjectSummary
+		skipped bool
+	}{
+		{"stop running", BulkStop, running, false},
+		{"stop stopped", BulkStop, stopped, true},
+		{"start stopped", BulkStart, stopped, false},
+		{"start running", BulkStart, running, true},
+		{"update managed", BulkUpdate, running, false},
+		{"update unmanaged", BulkUpdate, cliStack, true},
+		{"stop own project", BulkStop, self, true},
+		{"update own project", BulkUpdate, self, true},
+		{"start own project", BulkStart, ProjectSummary{Name: "dockmon", Status: ProjectPartial}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := BulkSkipReason(tt.action, tt.project, "dockmon")
+			if (reason != "") != tt.skipped {
+				t.Errorf("BulkSkipReason = %q, want skipped=%v", reason, tt.skipped)
+			}
+		})
+	}
+
+	if reason := BulkSkipReason(BulkStop, self, ""); reason != "" {
+		t.Errorf("without a known own project, %q was skipped: %s", self.Name, reason)
+	}
+}
+
+func TestApplyUpdateResults(t *testing.T) {
+	projects := []ProjectSummary{
+		{Name: "web", Containers: []ProjectContainer{
+			{ID: "aaaaaaaaaaaa", Service: "app"},
+			{ID: "aaaaaaaaaaab", Service: "app"},
+			{ID: "bbbbbbbbbbbb", Service: "db"},
+		}},
+		{Name: "cache", Containers: []ProjectContainer{{ID: "cccccccccccc", Service: "redis"}}},
+	}
+	results := []updatecheck.Result{
+		{ContainerID: "aaaaaaaaaaaa0000", Image: "app:latest", LocalDigest: "sha256:1", RemoteDigest: "sha256:2"},
+		{ContainerID: "aaaaaaaaaaab0000", Image: "app:latest", LocalDigest: "sha256:1", RemoteDigest: "sha256:2"},
+		{ContainerID: "bbbbbbbbbbbb0000", Image: "postgres:16", LocalDigest: "sha256:3", RemoteDigest: "sha256:3"},
+		{ContainerID: "cccccccccccc0000", Image: "redis:7", Error: "unauthorized"},
+		{ContainerID: "ffffffffffff0000", Image: "other:1", LocalDigest: "sha256:4", RemoteDigest: "sha256:5"},
+	}
+	applyUpdateResults(projects, results)
+
+	want := []ProjectImageUpdate{{Service: "app", Image: "app:latest", LocalDigest: "sha256:1", RemoteDigest: "sha256:2"}}
+	if !reflect.DeepEqual(projects[0].Updates, want) {
+		t.Errorf("web updates = %+v", projects[0].Updates)
+	}
+	if len(projects[1].Updates) != 0 || !reflect.DeepEqual(projects[1].UpdateErrors, []string{"redis:7: unauthorized"}) {
+		t.Errorf("cache = %+v", projects[1])
+	}
+}
+
+func TestUpdateCheckAuth(t *testing.T) {
+	auth := updateCheckAuth([]RegistryCredential{
+		{RegistryURL: "https://index.docker.io/v1/", Username: "hub"},
+		{RegistryURL: "https://ghcr.io/", Username: "gh"},
+		{RegistryURL: "registry.local:5000", Username: "local"},
+	})
+	for host, user := range map[string]string{"docker.io": "hub", "ghcr.io": "gh", "registry.local:5000": "local"} {
+		if auth[host].Username != user {
+			t.Errorf("auth[%q] = %+v, want user %s", host, auth[host], user)
+		}
+	}
+}
+
+func TestRedeployWithoutStackFiles(t *testing.T) {
+	s := newTestService()
+	result := s.Redeploy(context.Background(), DeployRequest{
+		DeploymentID: "d1",
+		ProjectName:  "cli",
+		StacksDir:    t.TempDir(),
+		ComposeYAML:  "services: {}",
+	})
+	if result.Success || result.Error == nil || result.Error.Category != ErrorCategoryValidation {
+		t.Fatalf("result = %+v", result)
+	}
+	if result.Action != "up" {
+		t.Errorf("Action = %q", result.Action)
+	}
+}
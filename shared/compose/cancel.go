@@ -0,0 +1,141 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrDeployCancelled is the cause callers cancel a deployment's context with
+// (context.WithCancelCause) to abort it. Unlike a timeout or a dropped
+// connection, it makes Deploy remove what the deployment had created.
+var ErrDeployCancelled = errors.New("deployment cancelled")
+
+// cancelCleanupTimeout bounds the cleanup after a cancelled deployment, which
+// runs detached from the already cancelled deployment context
+const cancelCleanupTimeout = 2 * time.Minute
+
+// CancelCleanup reports the best-effort cleanup after a cancelled deployment
+type CancelCleanup struct {
+	RemovedContainers []string `json:"removed_containers"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// projectContainerIDs returns the IDs of the project's containers, or nil if
+// they could not be listed
+func (s *Service) projectContainerIDs(ctx context.Context, projectName string) map[string]bool {
+	if s.dockerClient == nil {
+		return nil
+	}
+	ids, err := listProjectContainerIDs(ctx, s.dockerClient, projectName)
+	if err != nil {
+		s.logWarn("Failed to list project containers; a cancelled deployment will not be cleaned up", logrus.Fields{"error": err.Error()})
+		return nil
+	}
+	return ids
+}
+
+func listProjectContainerIDs(ctx context.Context, cli client.APIClient, projectName string) (map[string]bool, error) {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", api.ProjectLabel, projectName))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		ids[c.ID] = true
+	}
+	return ids, nil
+}
+
+// removeNewContainers force-removes the project's containers that are not in
+// before, i.e. the ones created since before was listed
+func removeNewContainers(ctx context.Context, cli client.APIClient, projectName string, before map[string]bool) ([]string, error) {
+	current, err := listProjectContainerIDs(ctx, cli, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project containers: %w", err)
+	}
+	removed := []string{}
+	var errs []error
+	for id := range current {
+		if before[id] {
+			continue
+		}
+		if err := cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove container %.12s: %w", id, err))
+			continue
+		}
+		removed = append(removed, id)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// cancelledResult builds the result of a deployment aborted with
+// ErrDeployCancelled. For an up it removes the containers the deployment
+// created, leaving ones that existed before alone, and takes the project
+// down entirely when nothing of it existed before. before is nil when the
+// project's containers could not be listed up front, which skips cleanup.
+func (s *Service) cancelledResult(req DeployRequest, before map[string]bool) *DeployResult {
+	s.logWarn("Deployment cancelled", logrus.Fields{
+		"deployment_id": req.DeploymentID,
+		"project_name":  req.ProjectName,
+	})
+	result := &DeployResult{
+		DeploymentID: req.DeploymentID,
+		Success:      false,
+		Cancelled:    true,
+		Error:        NewCancelledError("Deployment cancelled"),
+	}
+
+	message := "Deployment cancelled"
+	if before != nil {
+		result.Cleanup = s.cleanupCancelled(req, before)
+		message = fmt.Sprintf("Deployment cancelled, removed %d container(s) it created", len(result.Cleanup.RemovedContainers))
+	}
+	s.sendProgress(ProgressEvent{
+		Stage:    StageFailed,
+		Progress: 100,
+		Message:  message,
+	})
+	return result
+}
+
+func (s *Service) cleanupCancelled(req DeployRequest, before map[string]bool) *CancelCleanup {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelCleanupTimeout)
+	defer cancel()
+
+	cleanup := &CancelCleanup{RemovedContainers: []string{}}
+	removed, err := removeNewContainers(ctx, s.dockerClient, req.ProjectName, before)
+	if removed != nil {
+		cleanup.RemovedContainers = removed
+	}
+	if err == nil && len(before) == 0 {
+		// Nothing of the project ran before, so its networks go as well
+		err = s.downProject(ctx, req)
+	}
+	if err != nil {
+		s.logWarn("Cleanup after cancelled deployment incomplete", logrus.Fields{"error": err.Error()})
+		cleanup.Error = err.Error()
+	}
+	return cleanup
+}
+
+func (s *Service) downProject(ctx context.Context, req DeployRequest) error {
+	composeService, cli, tlsFiles, err := s.createComposeService(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to create compose service: %w", err)
+	}
+	defer cli.Client().Close()
+	defer tlsFiles.Cleanup(s.log)
+
+	return composeService.Down(ctx, req.ProjectName, api.DownOptions{RemoveOrphans: true})
+}
@@ -0,0 +1,95 @@
+package compose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// fakeCancelDaemon serves containers as the "app" project's and records
+// removals; removing "stuck" fails
+func fakeCancelDaemon(t *testing.T, containers string) (*[]string, client.APIClient) {
+	t.Helper()
+	var mu sync.Mutex
+	var removed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			if !strings.Contains(r.URL.Query().Get("filters"), "com.docker.compose.project=app") {
+				t.Errorf("containers listed without the project filter: %s", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(containers))
+		case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/containers/"):
+			id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			if r.URL.Query().Get("force") != "1" {
+				t.Errorf("container %s removed without force", id)
+			}
+			if id == "stuck" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"message":"removal already in progress"}`))
+				return
+			}
+			removed = append(removed, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return &removed, cli
+}
+
+func TestRemoveNewContainers(t *testing.T) {
+	removed, cli := fakeCancelDaemon(t, `[{"Id":"old"},{"Id":"new1"},{"Id":"new2"}]`)
+
+	got, err := removeNewContainers(context.Background(), cli, "app", map[string]bool{"old": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	sort.Strings(*removed)
+	if strings.Join(got, ",") != "new1,new2" || strings.Join(*removed, ",") != "new1,new2" {
+		t.Errorf("removed %v (reported %v), want only the containers created since", *removed, got)
+	}
+}
+
+func TestRemoveNewContainers_ReportsFailures(t *testing.T) {
+	_, cli := fakeCancelDaemon(t, `[{"Id":"new1"},{"Id":"stuck"}]`)
+
+	got, err := removeNewContainers(context.Background(), cli, "app", map[string]bool{})
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("err = %v, want the failed removal reported", err)
+	}
+	if strings.Join(got, ",") != "new1" {
+		t.Errorf("removed = %v, want the other container still removed", got)
+	}
+}
+
+func TestListProjectContainerIDs(t *testing.T) {
+	_, cli := fakeCancelDaemon(t, `[]`)
+
+	ids, err := listProjectContainerIDs(context.Background(), cli, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An empty project is known to be empty, unlike a failed listing (nil)
+	if ids == nil || len(ids) != 0 {
+		t.Errorf("ids = %#v, want an empty non-nil set", ids)
+	}
+}
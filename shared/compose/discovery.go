@@ -3,6 +3,8 @@ package compose
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/api/types"
@@ -12,76 +14,60 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// DiscoverContainers finds containers created by compose for a given project
+// DiscoverContainers finds containers created by compose for a given project.
+// Every container is inspected for its health, start time and image, so the
+// result can be recorded without another round trip. A scaled service lists
+// all replicas in ContainerIDs and reports the first unhealthy replica, or
+// replica 1 if all are healthy.
 func DiscoverContainers(
 	ctx context.Context,
 	dockerClient *client.Client,
 	projectName string,
 	log *logrus.Logger,
 ) (map[string]ServiceResult, error) {
-	// Filter by compose project label
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("label", fmt.Sprintf("com.docker.compose.project=%s", projectName))
-
-	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{
-		All:     true,
-		Filters: filterArgs,
-	})
+	containers, err := DiscoverContainersWithTypes(ctx, dockerClient, projectName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
 
-	services := make(map[string]ServiceResult)
-
+	// Group replicas by service, in container-number order
+	replicas := make(map[string][]types.Container)
 	for _, c := range containers {
-		serviceName := c.Labels["com.docker.compose.service"]
+		serviceName := c.Labels[labelService]
 		if serviceName == "" {
 			serviceName = "unknown"
 		}
+		replicas[serviceName] = append(replicas[serviceName], c)
+	}
 
-		containerName := ""
-		if len(c.Names) > 0 {
-			containerName = strings.TrimPrefix(c.Names[0], "/")
-		}
-
-		// CRITICAL: Always use short ID (12 chars) per CLAUDE.md
-		shortID := c.ID
-		if len(shortID) > 12 {
-			shortID = shortID[:12]
-		}
-
-		// Use c.Status which includes health info (e.g., "Up 9 minutes (unhealthy)")
-		// c.State only contains basic state like "running" without health details
-		status := c.Status
-		if status == "" {
-			status = c.State
-		}
+	services := make(map[string]ServiceResult)
+	digests := make(map[string]string) // Image ID -> repo digest
 
-		result := ServiceResult{
-			ContainerID:   shortID,
-			ContainerName: containerName,
-			Image:         c.Image,
-			Status:        status,
-		}
+	for serviceName, group := range replicas {
+		sort.SliceStable(group, func(i, j int) bool {
+			return replicaNumber(group[i]) < replicaNumber(group[j])
+		})
 
-		// For exited containers, inspect to get restart policy and exit code (Issue #110)
-		// This allows us to determine if exit 0 with restart:no/on-failure is acceptable
-		if c.State == "exited" {
-			inspect, err := dockerClient.ContainerInspect(ctx, c.ID)
-			if err == nil {
-				result.RestartPolicy = string(inspect.HostConfig.RestartPolicy.Name)
-				result.ExitCode = inspect.State.ExitCode
+		var result ServiceResult
+		var ids []string
+		for i, c := range group {
+			replica := discoverContainer(ctx, dockerClient, c, digests, log)
+			ids = append(ids, replica.ContainerID)
+			if i == 0 || (IsServiceResultHealthy(result) && !IsServiceResultHealthy(replica)) {
+				result = replica
 			}
 		}
-
+		result.ContainerIDs = ids
 		services[serviceName] = result
 
 		if log != nil {
 			log.WithFields(logrus.Fields{
 				"service":        serviceName,
-				"container_id":   shortID,
-				"name":           containerName,
-				"status":         status,
+				"container_id":   result.ContainerID,
+				"replicas":       len(ids),
+				"name":           result.ContainerName,
+				"status":         result.Status,
+				"health":         result.Health,
 				"restart_policy": result.RestartPolicy,
 				"exit_code":      result.ExitCode,
 			}).Debug("Discovered compose service")
@@ -91,6 +77,118 @@ func DiscoverContainers(
 	return services, nil
 }
 
+// discoverContainer builds the result for one container. If the inspect
+// fails, the result only has what the container list provides.
+func discoverContainer(
+	ctx context.Context,
+	dockerClient *client.Client,
+	c types.Container,
+	digests map[string]string,
+	log *logrus.Logger,
+) ServiceResult {
+	containerName := ""
+	if len(c.Names) > 0 {
+		containerName = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	// CRITICAL: Always use short ID (12 chars) per CLAUDE.md
+	shortID := shortContainerID(c.ID)
+
+	// Use c.Status which includes health info (e.g., "Up 9 minutes (unhealthy)")
+	// c.State only contains basic state like "running" without health details
+	status := c.Status
+	if status == "" {
+		status = c.State
+	}
+
+	result := ServiceResult{
+		ContainerID:   shortID,
+		ContainerName: containerName,
+		Image:         c.Image,
+		Status:        status,
+		Ports:         publishedPorts(c.Ports),
+		ImageID:       c.ImageID,
+	}
+
+	inspect, err := dockerClient.ContainerInspect(ctx, c.ID)
+	if err != nil {
+		if log != nil {
+			log.WithError(err).WithField("container_id", shortID).Debug("Failed to inspect discovered container")
+		}
+		return result
+	}
+	if inspect.ContainerJSONBase != nil {
+		if inspect.Image != "" {
+			result.ImageID = inspect.Image
+		}
+		if state := inspect.State; state != nil {
+			if state.Health != nil && state.Health.Status != container.NoHealthcheck {
+				result.Health = state.Health.Status
+			}
+			if state.StartedAt != "" && !strings.HasPrefix(state.StartedAt, "0001-") {
+				result.StartedAt = state.StartedAt
+			}
+			// For exited containers, record restart policy and exit code (Issue #110)
+			// This allows us to determine if exit 0 with restart:no/on-failure is acceptable
+			if c.State == "exited" {
+				result.ExitCode = state.ExitCode
+				if inspect.HostConfig != nil {
+					result.RestartPolicy = string(inspect.HostConfig.RestartPolicy.Name)
+				}
+			}
+		}
+	}
+	result.ImageDigest = imageDigest(ctx, dockerClient, result.ImageID, digests)
+	return result
+}
+
+// imageDigest returns the repo digest of an image, caching lookups in
+// digests. Images that were built locally and never pushed have none.
+func imageDigest(ctx context.Context, dockerClient *client.Client, imageID string, digests map[string]string) string {
+	if imageID == "" {
+		return ""
+	}
+	if digest, ok := digests[imageID]; ok {
+		return digest
+	}
+	digest := ""
+	if img, _, err := dockerClient.ImageInspectWithRaw(ctx, imageID); err == nil {
+		for _, ref := range img.RepoDigests {
+			if _, d, ok := strings.Cut(ref, "@"); ok {
+				digest = d
+				break
+			}
+		}
+	}
+	digests[imageID] = digest
+	return digest
+}
+
+// publishedPorts formats the ports bound on the host as "8080:80/tcp",
+// collapsing the IPv4 and IPv6 bindings of the same port
+func publishedPorts(ports []types.Port) []string {
+	seen := make(map[string]bool)
+	var published []string
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			continue
+		}
+		port := fmt.Sprintf("%d:%d/%s", p.PublicPort, p.PrivatePort, p.Type)
+		if !seen[port] {
+			seen[port] = true
+			published = append(published, port)
+		}
+	}
+	sort.Strings(published)
+	return published
+}
+
+// replicaNumber is the compose replica number, 0 if the label is missing
+func replicaNumber(c types.Container) int {
+	n, _ := strconv.Atoi(c.Labels[labelNumber])
+	return n
+}
+
 // DiscoverContainersWithTypes finds containers and returns full Docker types
 // Used when we need more container details than ServiceResult provides
 func DiscoverContainersWithTypes(
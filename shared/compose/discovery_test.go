@@ -0,0 +1,118 @@
+package compose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestDiscoverContainers(t *testing.T) {
+	var imageInspects atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			// Newest first, like the daemon
+			w.Write([]byte(`[
+				{"Id":"bbbbbbbbbbbb2222","Names":["/app-web-2"],"Image":"nginx:1.27","ImageID":"sha256:img1","State":"running","Status":"Up 1 minute (unhealthy)",
+				 "Labels":{"com.docker.compose.service":"web","com.docker.compose.container-number":"2"},"Ports":[]},
+				{"Id":"aaaaaaaaaaaa1111","Names":["/app-web-1"],"Image":"nginx:1.27","ImageID":"sha256:img1","State":"running","Status":"Up 1 minute (healthy)",
+				 "Labels":{"com.docker.compose.service":"web","com.docker.compose.container-number":"1"},
+				 "Ports":[{"IP":"0.0.0.0","PrivatePort":80,"PublicPort":8080,"Type":"tcp"},{"IP":"::","PrivatePort":80,"PublicPort":8080,"Type":"tcp"},{"PrivatePort":443,"Type":"tcp"}]},
+				{"Id":"cccccccccccc3333","Names":["/app-migrate-1"],"Image":"app:local","ImageID":"sha256:img2","State":"exited","Status":"Exited (0) 1 minute ago",
+				 "Labels":{"com.docker.compose.service":"migrate","com.docker.compose.container-number":"1"}}]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/aaaaaaaaaaaa1111/json"):
+			w.Write([]byte(`{"Id":"aaaaaaaaaaaa1111","Image":"sha256:img1","State":{"Status":"running","StartedAt":"2026-06-01T12:00:00.5Z","Health":{"Status":"healthy"}},"HostConfig":{"RestartPolicy":{"Name":"always"}}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/bbbbbbbbbbbb2222/json"):
+			w.Write([]byte(`{"Id":"bbbbbbbbbbbb2222","Image":"sha256:img1","State":{"Status":"running","StartedAt":"2026-06-01T12:00:01Z","Health":{"Status":"unhealthy"}},"HostConfig":{"RestartPolicy":{"Name":"always"}}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/cccccccccccc3333/json"):
+			w.Write([]byte(`{"Id":"cccccccccccc3333","Image":"sha256:img2","State":{"Status":"exited","ExitCode":0,"StartedAt":"2026-06-01T11:59:00Z"},"HostConfig":{"RestartPolicy":{"Name":"no"}}}`))
+		case strings.HasSuffix(r.URL.Path, "/images/sha256:img1/json"):
+			imageInspects.Add(1)
+			w.Write([]byte(`{"Id":"sha256:img1","RepoDigests":["nginx@sha256:abc"]}`))
+		case strings.HasSuffix(r.URL.Path, "/images/sha256:img2/json"):
+			imageInspects.Add(1)
+			w.Write([]byte(`{"Id":"sha256:img2","RepoDigests":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	services, err := DiscoverContainers(context.Background(), cli, "app", nil)
+	if err != nil {
+		t.Fatalf("DiscoverContainers: %v", err)
+	}
+
+	// The unhealthy replica represents the service; both replicas are listed
+	web := services["web"]
+	if web.ContainerID != "bbbbbbbbbbbb" || web.Health != "unhealthy" || IsServiceResultHealthy(web) {
+		t.Errorf("web = %+v", web)
+	}
+	if !reflect.DeepEqual(web.ContainerIDs, []string{"aaaaaaaaaaaa", "bbbbbbbbbbbb"}) {
+		t.Errorf("web ContainerIDs = %v", web.ContainerIDs)
+	}
+	if web.ImageID != "sha256:img1" || web.ImageDigest != "sha256:abc" || web.StartedAt != "2026-06-01T12:00:01Z" {
+		t.Errorf("web image/start = %+v", web)
+	}
+
+	migrate := services["migrate"]
+	if migrate.RestartPolicy != "no" || migrate.ExitCode != 0 || !IsServiceResultHealthy(migrate) {
+		t.Errorf("migrate = %+v", migrate)
+	}
+	if migrate.Health != "" || migrate.ImageDigest != "" || len(migrate.Ports) != 0 {
+		t.Errorf("migrate details = %+v", migrate)
+	}
+	if n := imageInspects.Load(); n != 2 {
+		t.Errorf("%d image inspects, want one per image", n)
+	}
+}
+
+func TestDiscoverContainers_InspectFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[{"Id":"dddddddddddd4444","Names":["/app-proxy-1"],"State":"running","Status":"Up 1 minute",
+				"Labels":{"com.docker.compose.service":"proxy"},
+				"Ports":[{"IP":"0.0.0.0","PrivatePort":443,"PublicPort":8443,"Type":"tcp"},{"IP":"::","PrivatePort":443,"PublicPort":8443,"Type":"tcp"},
+				{"IP":"0.0.0.0","PrivatePort":53,"PublicPort":53,"Type":"udp"},{"PrivatePort":9000,"Type":"tcp"}]}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	// The inspect fails, so only the list's details are reported
+	services, err := DiscoverContainers(context.Background(), cli, "app", nil)
+	if err != nil {
+		t.Fatalf("DiscoverContainers: %v", err)
+	}
+	proxy := services["proxy"]
+	if want := []string{"53:53/udp", "8443:443/tcp"}; !reflect.DeepEqual(proxy.Ports, want) {
+		t.Errorf("Ports = %v, want %v", proxy.Ports, want)
+	}
+	if proxy.ContainerID != "dddddddddddd" || proxy.Health != "" || proxy.StartedAt != "" {
+		t.Errorf("proxy = %+v", proxy)
+	}
+}
@@ -16,6 +16,7 @@ const (
 	ErrorCategoryHealth     ErrorCategory = "health"     // Health check failed/timeout
 	ErrorCategoryDocker     ErrorCategory = "docker"     // Docker daemon error
 	ErrorCategoryInternal   ErrorCategory = "internal"   // Unexpected Go service error
+	ErrorCategoryCancelled  ErrorCategory = "cancelled"  // Aborted by the caller
 )
 
 // ComposeError represents a structured error from compose operations
@@ -100,6 +101,15 @@ func NewInternalError(message string) *ComposeError {
 	}
 }
 
+// NewCancelledError creates a cancellation error
+func NewCancelledError(message string) *ComposeError {
+	return &ComposeError{
+		Category:  ErrorCategoryCancelled,
+		Message:   message,
+		Retryable: true,
+	}
+}
+
 // CategorizeError attempts to categorize an error based on its message
 func CategorizeError(err error) *ComposeError {
 	if err == nil {
@@ -0,0 +1,235 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// =============================================================================
+// External Resource Checks
+// =============================================================================
+//
+// Networks, volumes and configs declared `external: true` are never created by
+// compose, so one missing on the target host only fails "up" halfway through
+// with some services already started. They are looked up on the target
+// Docker host before anything is created, and missing external networks can
+// be created on request.
+
+// External resource kinds
+const (
+	ExternalNetwork = "network"
+	ExternalVolume  = "volume"
+	ExternalConfig  = "config"
+)
+
+// ExternalResourceStatus is the outcome of checking one external resource
+type ExternalResourceStatus string
+
+const (
+	ExternalResourceOK      ExternalResourceStatus = "ok"
+	ExternalResourceCreated ExternalResourceStatus = "created"
+	ExternalResourceMissing ExternalResourceStatus = "missing"
+)
+
+// ExternalResourceOptions controls the pre-deploy external resource check
+// (DeployRequest.ExternalResources). The check runs for every "up"; these
+// options only enable creating missing networks.
+type ExternalResourceOptions struct {
+	// CreateNetworks creates missing external networks instead of failing
+	CreateNetworks bool `json:"create_networks,omitempty"`
+	// Networks sets how a missing network is created, by network name.
+	// Unlisted networks are created with the bridge driver.
+	Networks map[string]ExternalNetworkSpec `json:"networks,omitempty"`
+}
+
+// ExternalNetworkSpec describes a network to create when it's missing
+type ExternalNetworkSpec struct {
+	Driver   string `json:"driver,omitempty"` // Default: bridge
+	Subnet   string `json:"subnet,omitempty"`
+	Gateway  string `json:"gateway,omitempty"`
+	Internal bool   `json:"internal,omitempty"`
+}
+
+// Validate checks the subnet and gateway are well-formed.
+func (s ExternalNetworkSpec) Validate() error {
+	if s.Subnet != "" {
+		if _, _, err := net.ParseCIDR(s.Subnet); err != nil {
+			return fmt.Errorf("invalid subnet %q", s.Subnet)
+		}
+	}
+	if s.Gateway != "" {
+		if s.Subnet == "" {
+			return fmt.Errorf("gateway requires a subnet")
+		}
+		if net.ParseIP(s.Gateway) == nil {
+			return fmt.Errorf("invalid gateway %q", s.Gateway)
+		}
+	}
+	return nil
+}
+
+// createOptions builds the network.CreateOptions for the spec
+func (s ExternalNetworkSpec) createOptions() network.CreateOptions {
+	opts := network.CreateOptions{Driver: s.Driver, Internal: s.Internal}
+	if opts.Driver == "" {
+		opts.Driver = "bridge"
+	}
+	if s.Subnet != "" {
+		opts.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: s.Subnet, Gateway: s.Gateway}}}
+	}
+	return opts
+}
+
+// ExternalResourceResult reports the check of one external resource
+type ExternalResourceResult struct {
+	Kind    string                 `json:"kind"`
+	Name    string                 `json:"name"`
+	Status  ExternalResourceStatus `json:"status"`
+	Message string                 `json:"message,omitempty"`
+}
+
+// externalResource is an external network/volume/config the project uses
+type externalResource struct {
+	kind string
+	name string
+}
+
+// projectExternalResources lists the project's external resources by the
+// name they have on the host, sorted by kind then name
+func projectExternalResources(project *types.Project) []externalResource {
+	var resources []externalResource
+	add := func(kind, key, name string) {
+		if name == "" {
+			name = key
+		}
+		resources = append(resources, externalResource{kind: kind, name: name})
+	}
+	for key, n := range project.Networks {
+		if n.External {
+			add(ExternalNetwork, key, n.Name)
+		}
+	}
+	for key, v := range project.Volumes {
+		if v.External {
+			add(ExternalVolume, key, v.Name)
+		}
+	}
+	for key, c := range project.Configs {
+		if c.External {
+			add(ExternalConfig, key, c.Name)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].kind != resources[j].kind {
+			return resources[i].kind < resources[j].kind
+		}
+		return resources[i].name < resources[j].name
+	})
+	return resources
+}
+
+// checkExternalResources looks up the project's external resources on the
+// target Docker host, creating missing networks if opts allow. Errors other
+// than "not found" abort the check.
+func (s *Service) checkExternalResources(ctx context.Context, project *types.Project, opts ExternalResourceOptions) ([]ExternalResourceResult, error) {
+	resources := projectExternalResources(project)
+	if len(resources) == 0 || s.dockerClient == nil {
+		return nil, nil
+	}
+	for name, spec := range opts.Networks {
+		if err := spec.Validate(); err != nil {
+			return nil, fmt.Errorf("external network %s: %w", name, err)
+		}
+	}
+
+	results := make([]ExternalResourceResult, 0, len(resources))
+	for _, r := range resources {
+		result, err := s.checkExternalResource(ctx, s.dockerClient, r, opts)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// checkExternalResource checks a single external resource
+func (s *Service) checkExternalResource(ctx context.Context, cli client.APIClient, r externalResource, opts ExternalResourceOptions) (ExternalResourceResult, error) {
+	result := ExternalResourceResult{Kind: r.kind, Name: r.name, Status: ExternalResourceOK}
+
+	var err error
+	switch r.kind {
+	case ExternalNetwork:
+		_, err = cli.NetworkInspect(ctx, r.name, network.InspectOptions{})
+	case ExternalVolume:
+		_, err = cli.VolumeInspect(ctx, r.name)
+	case ExternalConfig:
+		_, _, err = cli.ConfigInspectWithRaw(ctx, r.name)
+		// Configs live in the Swarm; a standalone engine can't have one
+		if err != nil && cerrdefs.IsUnavailable(err) {
+			result.Status = ExternalResourceMissing
+			result.Message = "external configs need a Swarm manager"
+			return result, nil
+		}
+	}
+	if err == nil {
+		return result, nil
+	}
+	if !cerrdefs.IsNotFound(err) {
+		return result, fmt.Errorf("failed to inspect external %s %s: %w", r.kind, r.name, err)
+	}
+
+	if r.kind != ExternalNetwork || !opts.CreateNetworks {
+		result.Status = ExternalResourceMissing
+		result.Message = "not found on the Docker host"
+		return result, nil
+	}
+
+	spec := opts.Networks[r.name]
+	createOpts := spec.createOptions()
+	if _, err := cli.NetworkCreate(ctx, r.name, createOpts); err != nil {
+		result.Status = ExternalResourceMissing
+		result.Message = fmt.Sprintf("could not be created: %v", err)
+		return result, nil
+	}
+	result.Status = ExternalResourceCreated
+	result.Message = fmt.Sprintf("created %s network %s", createOpts.Driver, r.name)
+	if spec.Subnet != "" {
+		result.Message += " (" + spec.Subnet + ")"
+	}
+	s.logInfo("Created missing external network", logrus.Fields{
+		"network": r.name,
+		"driver":  createOpts.Driver,
+		"subnet":  spec.Subnet,
+	})
+	s.sendProgress(ProgressEvent{
+		Stage:    StageCreatingNets,
+		Progress: 15,
+		Message:  fmt.Sprintf("Created external network %s", r.name),
+	})
+	return result, nil
+}
+
+// externalResourceFailure summarizes missing external resources as an error
+// message, or returns "" when everything exists
+func externalResourceFailure(results []ExternalResourceResult) string {
+	var missing []string
+	for _, r := range results {
+		if r.Status == ExternalResourceMissing {
+			missing = append(missing, fmt.Sprintf("%s %s (%s)", r.Kind, r.Name, r.Message))
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Missing external resources on the Docker host: %s", strings.Join(missing, "; "))
+}
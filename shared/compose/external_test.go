@@ -0,0 +1,160 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// fakeExternalDaemon knows network "proxy" and volume "data", and answers
+// config lookups like a standalone (non-Swarm) engine. Created networks are
+// recorded.
+func fakeExternalDaemon(t *testing.T, created map[string]network.CreateOptions) client.APIClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/networks/proxy"):
+			w.Write([]byte(`{"Id":"n1","Name":"proxy"}`))
+		case strings.HasSuffix(r.URL.Path, "/networks/create"):
+			var req struct {
+				Name string
+				network.CreateOptions
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decode create: %v", err)
+			}
+			created[req.Name] = req.CreateOptions
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"n2"}`))
+		case strings.HasSuffix(r.URL.Path, "/volumes/data"):
+			w.Write([]byte(`{"Name":"data"}`))
+		case strings.Contains(r.URL.Path, "/configs/"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"This node is not a swarm manager."}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func TestProjectExternalResources(t *testing.T) {
+	project := &types.Project{
+		Networks: types.Networks{
+			"default": {Name: "app_default"},
+			"proxy":   {Name: "proxy", External: true},
+			"edge":    {Name: "traefik_edge", External: true},
+		},
+		Volumes: types.Volumes{
+			"data":  {Name: "data", External: true},
+			"cache": {Name: "app_cache"},
+		},
+		Configs: types.Configs{
+			"site": {External: true},
+		},
+	}
+
+	got := projectExternalResources(project)
+	want := []externalResource{
+		{ExternalConfig, "site"},
+		{ExternalNetwork, "proxy"},
+		{ExternalNetwork, "traefik_edge"},
+		{ExternalVolume, "data"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("resources = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("resources[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCheckExternalResource(t *testing.T) {
+	created := make(map[string]network.CreateOptions)
+	cli := fakeExternalDaemon(t, created)
+	s := newTestService()
+	ctx := context.Background()
+
+	cases := []struct {
+		resource externalResource
+		opts     ExternalResourceOptions
+		want     ExternalResourceStatus
+	}{
+		{externalResource{ExternalNetwork, "proxy"}, ExternalResourceOptions{}, ExternalResourceOK},
+		{externalResource{ExternalNetwork, "missing"}, ExternalResourceOptions{}, ExternalResourceMissing},
+		{externalResource{ExternalVolume, "data"}, ExternalResourceOptions{}, ExternalResourceOK},
+		{externalResource{ExternalVolume, "gone"}, ExternalResourceOptions{CreateNetworks: true}, ExternalResourceMissing},
+		{externalResource{ExternalConfig, "site"}, ExternalResourceOptions{}, ExternalResourceMissing},
+		{externalResource{ExternalNetwork, "backend"}, ExternalResourceOptions{
+			CreateNetworks: true,
+			Networks:       map[string]ExternalNetworkSpec{"backend": {Driver: "macvlan", Subnet: "10.20.0.0/24", Gateway: "10.20.0.1"}},
+		}, ExternalResourceCreated},
+	}
+	for _, tc := range cases {
+		result, err := s.checkExternalResource(ctx, cli, tc.resource, tc.opts)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.resource.kind, tc.resource.name, err)
+		}
+		if result.Status != tc.want {
+			t.Errorf("%s %s: status = %s (%s), want %s", tc.resource.kind, tc.resource.name, result.Status, result.Message, tc.want)
+		}
+	}
+
+	opts, ok := created["backend"]
+	if !ok || opts.Driver != "macvlan" || opts.IPAM == nil || opts.IPAM.Config[0].Subnet != "10.20.0.0/24" || opts.IPAM.Config[0].Gateway != "10.20.0.1" {
+		t.Errorf("created networks = %+v", created)
+	}
+	if _, ok := created["missing"]; ok {
+		t.Error("network created without create_networks")
+	}
+}
+
+func TestExternalNetworkSpecValidate(t *testing.T) {
+	valid := []ExternalNetworkSpec{{}, {Driver: "overlay"}, {Subnet: "172.30.0.0/16", Gateway: "172.30.0.1"}, {Subnet: "fd00:30::/64"}}
+	for _, spec := range valid {
+		if err := spec.Validate(); err != nil {
+			t.Errorf("%+v: %v", spec, err)
+		}
+	}
+	invalid := []ExternalNetworkSpec{{Subnet: "172.30.0.0"}, {Gateway: "172.30.0.1"}, {Subnet: "172.30.0.0/16", Gateway: "nope"}}
+	for _, spec := range invalid {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("%+v: expected error", spec)
+		}
+	}
+
+	if opts := (ExternalNetworkSpec{}).createOptions(); opts.Driver != "bridge" || opts.IPAM != nil {
+		t.Errorf("default create options = %+v", opts)
+	}
+}
+
+func TestExternalResourceFailure(t *testing.T) {
+	if msg := externalResourceFailure([]ExternalResourceResult{{Kind: ExternalNetwork, Name: "proxy", Status: ExternalResourceOK}}); msg != "" {
+		t.Errorf("unexpected failure %q", msg)
+	}
+	msg := externalResourceFailure([]ExternalResourceResult{
+		{Kind: ExternalNetwork, Name: "proxy", Status: ExternalResourceCreated},
+		{Kind: ExternalVolume, Name: "data", Status: ExternalResourceMissing, Message: "not found on the Docker host"},
+	})
+	if !strings.Contains(msg, "volume data (not found on the Docker host)") || strings.Contains(msg, "proxy") {
+		t.Errorf("message = %q", msg)
+	}
+}
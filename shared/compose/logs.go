@@ -0,0 +1,296 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// maxLogLineBytes caps a single log line; longer lines are split
+const maxLogLineBytes = 64 * 1024
+
+// ProjectLogOptions selects what FollowProjectLogs streams
+type ProjectLogOptions struct {
+	Services []string // Only these services; all when empty
+	Tail     string   // Lines per container to start from: a number or "all" (default)
+	Since    string   // RFC 3339, Unix timestamp or duration such as "10m"
+	Follow   bool     // Keep streaming, attaching to containers as they start
+}
+
+// ProjectLogLine is one line of a container's log
+type ProjectLogLine struct {
+	Service     string    `json:"service"`
+	Container   string    `json:"container"` // Display name, e.g. "web-1"
+	ContainerID string    `json:"container_id"`
+	Stream      string    `json:"stream"` // "stdout" or "stderr"
+	Timestamp   time.Time `json:"timestamp"`
+	Line        string    `json:"line"`
+}
+
+// Format renders the line the way docker compose logs does, with the
+// container name padded to width and the timestamp in UTC
+func (l ProjectLogLine) Format(width int) string {
+	return fmt.Sprintf("%-*s | %s %s", width, l.Container, l.Timestamp.UTC().Format(time.RFC3339Nano), l.Line)
+}
+
+// FollowProjectLogs streams the logs of every container in a compose
+// project, interleaved in arrival order, calling emit once per line from the
+// calling goroutine. Without Follow it returns nil once every log has been
+// read; with Follow it runs until ctx is cancelled or emit fails, attaching
+// to containers that start (or restart) along the way.
+func FollowProjectLogs(ctx context.Context, dockerClient client.APIClient, projectName string, opts ProjectLogOptions, emit func(ProjectLogLine) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	projectFilter := filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", labelProject, projectName)))
+
+	// Subscribe before listing so a container starting in between is seen
+	var eventCh <-chan events.Message
+	var errCh <-chan error
+	if opts.Follow {
+		eventFilter := projectFilter.Clone()
+		eventFilter.Add("type", string(events.ContainerEventType))
+		eventFilter.Add("event", string(events.ActionStart))
+		// "start" can arrive while the previous run's stream is still
+		// closing; "restart" follows it and attaches then
+		eventFilter.Add("event", string(events.ActionRestart))
+		eventCh, errCh = dockerClient.Events(ctx, events.ListOptions{Filters: eventFilter})
+	}
+
+	summaries, err := dockerClient.ContainerList(ctx, container.ListOptions{All: true, Filters: projectFilter})
+	if err != nil {
+		return fmt.Errorf("failed to list project containers: %w", err)
+	}
+
+	f := &projectLogFollower{
+		client: dockerClient,
+		opts:   opts,
+		lines:  make(chan ProjectLogLine, 256),
+		active: make(map[string]bool),
+	}
+	// Streams stop on cancel; wait for them so none outlives the call
+	defer func() {
+		cancel()
+		f.wg.Wait()
+	}()
+
+	attached := 0
+	for _, c := range summaries {
+		if f.attach(ctx, c.ID, c.Labels, opts.Since) {
+			attached++
+		}
+	}
+	if attached == 0 && !opts.Follow {
+		return ErrProjectNotFound
+	}
+
+	// Without Follow nothing attaches later, so the streams are finished
+	// once the initial ones have ended
+	finished := make(chan struct{})
+	if !opts.Follow {
+		go func() {
+			f.wg.Wait()
+			close(finished)
+		}()
+	}
+
+	for {
+		select {
+		case line := <-f.lines:
+			if err := emit(line); err != nil {
+				return err
+			}
+		case <-finished:
+			// Every sender is done; drain what they left buffered
+			for {
+				select {
+				case line := <-f.lines:
+					if err := emit(line); err != nil {
+						return err
+					}
+				default:
+					return nil
+				}
+			}
+		case event := <-eventCh:
+			// Only stream what was logged since the start, so a restarted
+			// container doesn't repeat its earlier output
+			f.attach(ctx, event.Actor.ID, event.Actor.Attributes, eventSince(event))
+		case err := <-errCh:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("docker event stream failed: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// eventSince is the logs "since" value for an event's time, at full
+// precision so output from the same second before the event isn't repeated
+func eventSince(event events.Message) string {
+	if event.TimeNano == 0 {
+		return strconv.FormatInt(event.Time, 10)
+	}
+	return fmt.Sprintf("%d.%09d", event.TimeNano/int64(time.Second), event.TimeNano%int64(time.Second))
+}
+
+// projectLogFollower tracks the containers being streamed
+type projectLogFollower struct {
+	client client.APIClient
+	opts   ProjectLogOptions
+	lines  chan ProjectLogLine
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// attach starts streaming a container unless it is filtered out or
+// already streaming
+func (f *projectLogFollower) attach(ctx context.Context, id string, labels map[string]string, since string) bool {
+	service := labels[labelService]
+	if labels[labelOneoff] == "True" {
+		return false
+	}
+	if len(f.opts.Services) > 0 && !slices.Contains(f.opts.Services, service) {
+		return false
+	}
+
+	f.mu.Lock()
+	if f.active[id] {
+		f.mu.Unlock()
+		return false
+	}
+	f.active[id] = true
+	f.mu.Unlock()
+
+	name := service
+	if number := labels[labelNumber]; number != "" {
+		name += "-" + number
+	}
+	base := ProjectLogLine{Service: service, Container: name, ContainerID: id}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer func() {
+			f.mu.Lock()
+			delete(f.active, id)
+			f.mu.Unlock()
+		}()
+		f.stream(ctx, base, since)
+	}()
+	return true
+}
+
+// stream copies one container's log into f.lines until it ends. Errors end
+// the stream quietly: the container may have been removed mid-stream.
+func (f *projectLogFollower) stream(ctx context.Context, base ProjectLogLine, since string) {
+	info, err := f.client.ContainerInspect(ctx, base.ContainerID)
+	if err != nil {
+		return
+	}
+	tail := f.opts.Tail
+	if tail == "" {
+		tail = "all"
+	}
+	logs, err := f.client.ContainerLogs(ctx, base.ContainerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     f.opts.Follow,
+		Tail:       tail,
+		Since:      since,
+	})
+	if err != nil {
+		return
+	}
+	defer logs.Close()
+
+	stdout := &logLineWriter{ctx: ctx, base: base, stream: "stdout", lines: f.lines}
+	stderr := &logLineWriter{ctx: ctx, base: base, stream: "stderr", lines: f.lines}
+	// TTY containers return raw logs without multiplexing headers
+	if info.Config != nil && info.Config.Tty {
+		_, _ = io.Copy(stdout, logs)
+	} else {
+		_, _ = stdcopy.StdCopy(stdout, stderr, logs)
+	}
+	stdout.flush()
+	stderr.flush()
+}
+
+// logLineWriter splits a timestamped log stream into lines
+type logLineWriter struct {
+	ctx    context.Context
+	base   ProjectLogLine
+	stream string
+	lines  chan<- ProjectLogLine
+	buf    []byte
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) >= maxLogLineBytes {
+				i = maxLogLineBytes
+			} else {
+				break
+			}
+		}
+		if err := w.send(w.buf[:i]); err != nil {
+			return 0, err
+		}
+		if i < len(w.buf) && w.buf[i] == '\n' {
+			i++
+		}
+		w.buf = w.buf[i:]
+	}
+	return len(p), nil
+}
+
+func (w *logLineWriter) flush() {
+	if len(w.buf) > 0 {
+		_ = w.send(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *logLineWriter) send(raw []byte) error {
+	line := w.base
+	line.Stream = w.stream
+	line.Timestamp, line.Line = parseLogTimestamp(strings.TrimSuffix(string(raw), "\r"))
+	select {
+	case w.lines <- line:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// parseLogTimestamp splits the RFC 3339 timestamp Docker puts in front of
+// each line. Lines without one (e.g. the rest of a split long line) get the
+// current time.
+func parseLogTimestamp(line string) (time.Time, string) {
+	if ts, rest, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t.UTC(), rest
+		}
+	}
+	return time.Now().UTC(), line
+}
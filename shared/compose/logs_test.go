@@ -0,0 +1,134 @@
+package compose
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+)
+
+func muxFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// fakeProjectDaemon serves a project with a multiplexed "web" container and
+// a TTY "db" container
+func fakeProjectDaemon(t *testing.T) client.APIClient {
+	t.Helper()
+	web := append(muxFrame(1, "2024-05-01T10:00:00.000000001Z GET /\n"), muxFrame(2, "2024-05-01T10:00:01+02:00 warn\n")...)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			fmt.Fprintf(w, `[
+				{"Id":"web1","Labels":{%q:"app",%q:"web",%q:"1"}},
+				{"Id":"db1","Labels":{%q:"app",%q:"db",%q:"1"}},
+				{"Id":"run1","Labels":{%q:"app",%q:"web",%q:"True"}}
+			]`, labelProject, labelService, labelNumber, labelProject, labelService, labelNumber, labelProject, labelService, labelOneoff)
+		case strings.HasSuffix(r.URL.Path, "/containers/web1/json"):
+			w.Write([]byte(`{"Id":"web1","Config":{"Tty":false}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/db1/json"):
+			w.Write([]byte(`{"Id":"db1","Config":{"Tty":true}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/web1/logs"):
+			w.Write(web)
+		case strings.HasSuffix(r.URL.Path, "/containers/db1/logs"):
+			w.Write([]byte("2024-05-01T10:00:02Z ready\r\nno timestamp"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func collectProjectLogs(t *testing.T, opts ProjectLogOptions) []ProjectLogLine {
+	t.Helper()
+	var lines []ProjectLogLine
+	err := FollowProjectLogs(context.Background(), fakeProjectDaemon(t), "app", opts, func(line ProjectLogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FollowProjectLogs: %v", err)
+	}
+	// Containers stream concurrently; order within a container is kept
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Container > lines[j].Container })
+	return lines
+}
+
+func TestFollowProjectLogs(t *testing.T) {
+	lines := collectProjectLogs(t, ProjectLogOptions{})
+	if len(lines) != 4 {
+		t.Fatalf("lines = %+v, want 4", lines)
+	}
+
+	want := []struct{ container, stream, line, ts string }{
+		{"web-1", "stdout", "GET /", "2024-05-01T10:00:00.000000001Z"},
+		{"web-1", "stderr", "warn", "2024-05-01T08:00:01Z"},
+		{"db-1", "stdout", "ready", "2024-05-01T10:00:02Z"},
+		{"db-1", "stdout", "no timestamp", ""},
+	}
+	for i, w := range want {
+		got := lines[i]
+		if got.Container != w.container || got.Stream != w.stream || got.Line != w.line {
+			t.Errorf("line %d = %+v, want %+v", i, got, w)
+		}
+		if w.ts != "" && got.Timestamp.Format(time.RFC3339Nano) != w.ts {
+			t.Errorf("line %d timestamp = %s, want %s", i, got.Timestamp.Format(time.RFC3339Nano), w.ts)
+		}
+		if got.Timestamp.Location() != time.UTC {
+			t.Errorf("line %d timestamp not in UTC", i)
+		}
+	}
+
+	if got := lines[2].Format(5); got != "db-1  | 2024-05-01T10:00:02Z ready" {
+		t.Errorf("Format = %q", got)
+	}
+}
+
+func TestFollowProjectLogs_ServiceFilter(t *testing.T) {
+	lines := collectProjectLogs(t, ProjectLogOptions{Services: []string{"db"}})
+	for _, line := range lines {
+		if line.Service != "db" {
+			t.Errorf("line from filtered-out service: %+v", line)
+		}
+	}
+	if len(lines) != 2 {
+		t.Errorf("lines = %d, want 2", len(lines))
+	}
+}
+
+func TestFollowProjectLogs_NoContainers(t *testing.T) {
+	err := FollowProjectLogs(context.Background(), fakeProjectDaemon(t), "app", ProjectLogOptions{Services: []string{"cache"}}, func(ProjectLogLine) error { return nil })
+	if err != ErrProjectNotFound {
+		t.Errorf("err = %v, want ErrProjectNotFound", err)
+	}
+}
+
+func TestEventSince(t *testing.T) {
+	event := events.Message{Time: 1700000000, TimeNano: 1700000000_012345678}
+	if got := eventSince(event); got != "1700000000.012345678" {
+		t.Errorf("eventSince = %q, want nanosecond precision", got)
+	}
+	if got := eventSince(events.Message{Time: 1700000000}); got != "1700000000" {
+		t.Errorf("eventSince without TimeNano = %q", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"io"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -121,6 +122,42 @@ func parseMountInfoLine(line string) (root, mountPoint string, ok bool) {
 	return root, mountPoint, true
 }
 
+// mountinfoContainerID matches a container's directory on the host, e.g.
+// /var/lib/docker/containers/<id>/hostname or Podman's
+// .../overlay-containers/<id>/userdata/hostname
+var mountinfoContainerID = regexp.MustCompile(`containers/([0-9a-f]{64})/`)
+
+// OwnContainerID returns the ID of the container this process runs in, from
+// the mount of /etc/hostname, /etc/hosts or /etc/resolv.conf, whose source
+// lies in the container's directory on the host. Empty when not
+// containerized or /proc isn't readable.
+func OwnContainerID() string {
+	f, err := os.Open(procMountInfoPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return ownContainerIDFromReader(f)
+}
+
+func ownContainerIDFromReader(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		root, mountPoint, ok := parseMountInfoLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch mountPoint {
+		case "/etc/hostname", "/etc/hosts", "/etc/resolv.conf":
+			if m := mountinfoContainerID.FindStringSubmatch(root); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
 // unescapeOctal decodes the kernel's mountinfo path encoding. Per fs/seq_file.c,
 // the kernel escapes ' ', '\t', '\n', and '\\' as \040, \011, \012, \134.
 // Other bytes pass through unchanged. We accept any \NNN octal triplet to be
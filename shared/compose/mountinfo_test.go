@@ -223,3 +223,23 @@ func TestDiscoverHostPath_UnreadableFile(t *testing.T) {
 		t.Errorf("got %q, want input path", got)
 	}
 }
+
+func TestOwnContainerIDFromReader(t *testing.T) {
+	id := strings.Repeat("ab12", 16)
+	docker := "1 0 0:1 / / rw - overlay overlay rw\n" +
+		"520 510 259:1 /var/lib/docker/containers/" + id + "/hostname /etc/hostname rw,relatime - ext4 /dev/sda1 rw\n"
+	if got := ownContainerIDFromReader(strings.NewReader(docker)); got != id {
+		t.Errorf("docker: got %q, want %q", got, id)
+	}
+
+	podman := "600 590 0:50 /containers/storage/overlay-containers/" + id + "/userdata/resolv.conf /etc/resolv.conf rw - tmpfs tmpfs rw\n"
+	if got := ownContainerIDFromReader(strings.NewReader(podman)); got != id {
+		t.Errorf("podman: got %q, want %q", got, id)
+	}
+
+	bare := "22 1 259:1 / / rw,relatime - ext4 /dev/sda1 rw\n" +
+		"30 22 0:5 / /dev rw - devtmpfs devtmpfs rw\n"
+	if got := ownContainerIDFromReader(strings.NewReader(bare)); got != "" {
+		t.Errorf("not containerized: got %q, want empty", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package compose
+
+import "syscall"
+
+// oNoFollow makes OpenFile refuse to follow a symlink at the final path
+// component, so a planted link can't redirect writes out of the stack dir.
+const oNoFollow = syscall.O_NOFOLLOW
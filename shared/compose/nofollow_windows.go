@@ -0,0 +1,7 @@
+//go:build windows
+
+package compose
+
+// oNoFollow is a no-op on Windows, which has no O_NOFOLLOW. Creating
+// symlinks there requires elevated rights, so a planted link is unlikely.
+const oNoFollow = 0
@@ -0,0 +1,202 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+)
+
+// =============================================================================
+// Host Resource Quotas
+// =============================================================================
+//
+// A stack is checked against per-host limits before "up": the memory its
+// services reserve plus what running containers of other projects already
+// reserve, and the number of containers it adds to the host. Only
+// reservations count, since limits are routinely oversubscribed on purpose.
+
+// QuotaMode selects what happens when a deployment would exceed a quota
+type QuotaMode string
+
+const (
+	QuotaReject QuotaMode = "reject" // Fail the deployment (default)
+	QuotaWarn   QuotaMode = "warn"   // Deploy anyway, reporting the violation
+)
+
+// QuotaHostMemory as HostQuota.MaxMemoryReservation limits reservations to
+// the host's total memory
+const QuotaHostMemory int64 = -1
+
+// HostQuota limits what a deployment may use on its Docker host
+// (DeployRequest.Quota, or DeployTarget.Quota per target)
+type HostQuota struct {
+	// MaxMemoryReservation caps the total memory reservation of running
+	// containers, in bytes (0 = no limit). QuotaHostMemory uses the host's
+	// total memory (from SystemInfo), so reservations can't oversubscribe it.
+	MaxMemoryReservation int64 `json:"max_memory_reservation,omitempty"`
+	// MaxContainers caps the number of containers on the host (0 = no limit)
+	MaxContainers int       `json:"max_containers,omitempty"`
+	Mode          QuotaMode `json:"mode,omitempty"`
+}
+
+// Validate checks the quota mode and limits.
+func (q *HostQuota) Validate() error {
+	switch q.Mode {
+	case "", QuotaReject, QuotaWarn:
+	default:
+		return fmt.Errorf("invalid quota mode %q (must be reject or warn)", q.Mode)
+	}
+	if q.MaxMemoryReservation < QuotaHostMemory {
+		return fmt.Errorf("max_memory_reservation must be 0 or more bytes, or %d for the host's memory", QuotaHostMemory)
+	}
+	if q.MaxContainers < 0 {
+		return fmt.Errorf("max_containers must not be negative")
+	}
+	return nil
+}
+
+// ParseHostQuota builds a quota from configuration strings, e.g. environment
+// variables: maxMemory in bytes or with a unit ("8g", "512m", "host" for the
+// host's total memory), maxContainers as a number. Returns nil when all are
+// empty.
+func ParseHostQuota(maxMemory, maxContainers, mode string) (*HostQuota, error) {
+	if maxMemory == "" && maxContainers == "" && mode == "" {
+		return nil, nil
+	}
+	quota := &HostQuota{Mode: QuotaMode(strings.ToLower(mode))}
+	switch {
+	case maxMemory == "":
+	case strings.EqualFold(maxMemory, "host"):
+		quota.MaxMemoryReservation = QuotaHostMemory
+	default:
+		bytes, err := units.RAMInBytes(maxMemory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max memory %q: %w", maxMemory, err)
+		}
+		quota.MaxMemoryReservation = bytes
+	}
+	if maxContainers != "" {
+		n, err := strconv.Atoi(maxContainers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max containers %q", maxContainers)
+		}
+		quota.MaxContainers = n
+	}
+	if err := quota.Validate(); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// QuotaResult reports the projected usage of the host after the deployment
+type QuotaResult struct {
+	// Memory in bytes
+	ProjectedMemory int64 `json:"projected_memory_reservation"`
+	MemoryLimit     int64 `json:"memory_limit,omitempty"` // 0 = not checked
+	HostMemory      int64 `json:"host_memory,omitempty"`
+	// Containers on the host, counting the stack's own
+	ProjectedContainers int      `json:"projected_containers"`
+	ContainerLimit      int      `json:"container_limit,omitempty"` // 0 = not checked
+	Violations          []string `json:"violations,omitempty"`
+	Rejected            bool     `json:"rejected,omitempty"`
+}
+
+// projectDemand returns the memory reservation (bytes) and container count
+// of the project's services, with replicas
+func projectDemand(project *types.Project) (int64, int) {
+	var memory int64
+	containers := 0
+	for _, svc := range project.Services {
+		replicas := svc.GetScale()
+		containers += replicas
+		memory += serviceMemoryReservation(svc) * int64(replicas)
+	}
+	return memory, containers
+}
+
+// serviceMemoryReservation is a service's per-container memory reservation,
+// from deploy.resources.reservations or the legacy mem_reservation
+func serviceMemoryReservation(svc types.ServiceConfig) int64 {
+	if svc.Deploy != nil && svc.Deploy.Resources.Reservations != nil && svc.Deploy.Resources.Reservations.MemoryBytes > 0 {
+		return int64(svc.Deploy.Resources.Reservations.MemoryBytes)
+	}
+	return int64(svc.MemReservation)
+}
+
+// checkHostQuota projects the host's usage after deploying the project. The
+// project's existing containers are left out since "up" replaces them.
+func (s *Service) checkHostQuota(ctx context.Context, cli client.APIClient, project *types.Project, quota HostQuota) (*QuotaResult, error) {
+	if err := quota.Validate(); err != nil {
+		return nil, err
+	}
+
+	memory, containers := projectDemand(project)
+	result := &QuotaResult{
+		ProjectedMemory:     memory,
+		ProjectedContainers: containers,
+		ContainerLimit:      quota.MaxContainers,
+	}
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host info: %w", err)
+	}
+	result.HostMemory = info.MemTotal
+	switch {
+	case quota.MaxMemoryReservation > 0:
+		result.MemoryLimit = quota.MaxMemoryReservation
+	case quota.MaxMemoryReservation == QuotaHostMemory:
+		result.MemoryLimit = info.MemTotal
+	}
+
+	existing, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, c := range existing {
+		if c.Labels[api.ProjectLabel] == project.Name {
+			continue
+		}
+		result.ProjectedContainers++
+		if result.MemoryLimit == 0 || c.State != "running" {
+			continue
+		}
+		inspect, err := cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			// Removed since the list; it holds nothing
+			continue
+		}
+		if inspect.HostConfig != nil {
+			result.ProjectedMemory += inspect.HostConfig.MemoryReservation
+		}
+	}
+
+	// A stack that reserves nothing can't be what oversubscribes the host
+	if result.MemoryLimit > 0 && memory > 0 && result.ProjectedMemory > result.MemoryLimit {
+		result.Violations = append(result.Violations, fmt.Sprintf(
+			"memory reservation %s exceeds the limit of %s",
+			units.BytesSize(float64(result.ProjectedMemory)), units.BytesSize(float64(result.MemoryLimit))))
+	}
+	if quota.MaxContainers > 0 && result.ProjectedContainers > quota.MaxContainers {
+		result.Violations = append(result.Violations, fmt.Sprintf(
+			"%d containers exceed the limit of %d", result.ProjectedContainers, quota.MaxContainers))
+	}
+	result.Rejected = len(result.Violations) > 0 && quota.Mode != QuotaWarn
+	return result, nil
+}
+
+// quotaFailure summarizes quota violations as a message, or returns "" when
+// the deployment fits
+func quotaFailure(result *QuotaResult) string {
+	if result == nil || len(result.Violations) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Deployment would oversubscribe the host: %s", strings.Join(result.Violations, "; "))
+}
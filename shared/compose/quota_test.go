@@ -0,0 +1,147 @@
+package compose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/client"
+)
+
+const mib = 1024 * 1024
+
+// fakeQuotaDaemon is a 1 GiB host running "other" (256 MiB reserved) and a
+// container of the "app" project, with a stopped container next to them
+func fakeQuotaDaemon(t *testing.T) client.APIClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			w.Write([]byte(`{"MemTotal":1073741824,"Containers":3}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/json"):
+			w.Write([]byte(`[
+				{"Id":"other","State":"running","Labels":{}},
+				{"Id":"old","State":"exited","Labels":{}},
+				{"Id":"app1","State":"running","Labels":{"com.docker.compose.project":"app"}}
+			]`))
+		case strings.HasSuffix(r.URL.Path, "/containers/other/json"):
+			w.Write([]byte(`{"Id":"other","HostConfig":{"MemoryReservation":268435456}}`))
+		case strings.HasSuffix(r.URL.Path, "/containers/app1/json"):
+			t.Error("the project's own containers should not be inspected")
+			w.Write([]byte(`{"Id":"app1","HostConfig":{"MemoryReservation":1073741824}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func quotaProject(webReservation types.UnitBytes) *types.Project {
+	return &types.Project{
+		Name: "app",
+		Services: types.Services{
+			"web": {
+				Name:   "web",
+				Deploy: &types.DeployConfig{Replicas: intPtr(2), Resources: types.Resources{Reservations: &types.Resource{MemoryBytes: webReservation}}},
+			},
+			"db":    {Name: "db", MemReservation: 128 * mib},
+			"cache": {Name: "cache"},
+		},
+	}
+}
+
+func TestProjectDemand(t *testing.T) {
+	memory, containers := projectDemand(quotaProject(256 * mib))
+	if memory != (2*256+128)*mib {
+		t.Errorf("memory = %d MiB, want 640", memory/mib)
+	}
+	if containers != 4 {
+		t.Errorf("containers = %d, want 4", containers)
+	}
+}
+
+func TestCheckHostQuota(t *testing.T) {
+	cli := fakeQuotaDaemon(t)
+	s := newTestService()
+	ctx := context.Background()
+
+	// 640 MiB + 256 MiB of "other" fits the host's 1 GiB
+	result, err := s.checkHostQuota(ctx, cli, quotaProject(256*mib), HostQuota{MaxMemoryReservation: QuotaHostMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProjectedMemory != (640+256)*mib || result.MemoryLimit != 1024*mib || len(result.Violations) != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if result.ProjectedContainers != 6 {
+		t.Errorf("projected containers = %d, want 6", result.ProjectedContainers)
+	}
+
+	// 896 MiB + 256 MiB oversubscribes it
+	result, err = s.checkHostQuota(ctx, cli, quotaProject(384*mib), HostQuota{MaxMemoryReservation: QuotaHostMemory, MaxContainers: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Violations) != 2 || !result.Rejected {
+		t.Errorf("result = %+v, want memory and container violations", result)
+	}
+
+	result, err = s.checkHostQuota(ctx, cli, quotaProject(384*mib), HostQuota{MaxMemoryReservation: QuotaHostMemory, Mode: QuotaWarn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Violations) != 1 || result.Rejected {
+		t.Errorf("warn mode result = %+v", result)
+	}
+	if msg := quotaFailure(result); !strings.Contains(msg, "exceeds the limit of 1GiB") {
+		t.Errorf("message = %q", msg)
+	}
+
+	// Without a memory limit only the container count is checked
+	result, err = s.checkHostQuota(ctx, cli, quotaProject(384*mib), HostQuota{MaxContainers: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MemoryLimit != 0 || len(result.Violations) != 0 {
+		t.Errorf("unset memory limit result = %+v", result)
+	}
+}
+
+func TestParseHostQuota(t *testing.T) {
+	if q, err := ParseHostQuota("", "", ""); q != nil || err != nil {
+		t.Errorf("empty config = %+v, %v; want nil", q, err)
+	}
+
+	q, err := ParseHostQuota("8g", "50", "WARN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.MaxMemoryReservation != 8*1024*mib || q.MaxContainers != 50 || q.Mode != QuotaWarn {
+		t.Errorf("quota = %+v", q)
+	}
+
+	if q, _ := ParseHostQuota("host", "", ""); q == nil || q.MaxMemoryReservation != QuotaHostMemory {
+		t.Errorf("host memory quota = %+v", q)
+	}
+	if q, _ := ParseHostQuota("", "10", ""); q == nil || q.MaxMemoryReservation != 0 {
+		t.Errorf("container-only quota = %+v, want no memory limit", q)
+	}
+
+	for _, bad := range [][3]string{{"lots", "", ""}, {"-2", "", ""}, {"", "many", ""}, {"", "-2", ""}, {"", "", "ignore"}} {
+		if _, err := ParseHostQuota(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// runRestart stops the stack's services in reverse dependency order, then
+// brings them back up with compose up. Up starts services in dependency
+// order and waits for depends_on conditions (service_healthy,
+// service_completed_successfully) before starting their dependents, so
+// databases are ready before the apps that need them. Containers are kept,
+// unlike down followed by up.
+func (s *Service) runRestart(ctx context.Context, req DeployRequest, composeFile string) *DeployResult {
+	composeService, cli, tlsFiles, err := s.createComposeService(ctx, req)
+	if err != nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to create compose service: %v", err))
+	}
+	defer cli.Client().Close()
+	defer tlsFiles.Cleanup(s.log)
+
+	project, err := s.loadProject(ctx, projectComposeFiles(req, composeFile), req.ProjectName, req.Profiles, "", req.EnvSetVars)
+	if err != nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to load compose project: %v", err))
+	}
+
+	order := startOrder(project)
+	s.sendProgress(ProgressEvent{
+		Stage:     StageStarting,
+		Progress:  30,
+		Message:   fmt.Sprintf("Stopping services in reverse dependency order: %s", strings.Join(reversed(order), ", ")),
+		TotalSvcs: len(order),
+	})
+
+	if err := composeService.Stop(ctx, req.ProjectName, api.StopOptions{Project: project}); err != nil {
+		s.logError("Compose stop failed", err, nil)
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Compose stop failed: %v", err))
+	}
+
+	s.sendProgress(ProgressEvent{
+		Stage:     StageStarting,
+		Progress:  50,
+		Message:   fmt.Sprintf("Starting services in dependency order: %s", strings.Join(order, ", ")),
+		TotalSvcs: len(order),
+	})
+	return s.runComposeUp(ctx, req, composeFile)
+}
+
+// startOrder returns the project's services in the order compose starts
+// them: every service after the services it depends on, alphabetically
+// among services that are ready at the same time. Dependencies outside the
+// project are ignored; services in a cycle go last.
+func startOrder(project *types.Project) []string {
+	pending := make(map[string]int, len(project.Services))
+	dependents := make(map[string][]string)
+	for name := range project.Services {
+		pending[name] = 0
+	}
+	for name, svc := range project.Services {
+		for dep := range svc.DependsOn {
+			if _, ok := project.Services[dep]; !ok || dep == name {
+				continue
+			}
+			pending[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, n := range pending {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	order := make([]string, 0, len(pending))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		var next []string
+		for _, name := range ready {
+			order = append(order, name)
+			delete(pending, name)
+			for _, dependent := range dependents[name] {
+				if pending[dependent]--; pending[dependent] == 0 {
+					next = append(next, dependent)
+				}
+			}
+		}
+		ready = next
+	}
+
+	var cyclic []string
+	for name := range pending {
+		cyclic = append(cyclic, name)
+	}
+	sort.Strings(cyclic)
+	return append(order, cyclic...)
+}
+
+func reversed(names []string) []string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[len(names)-1-i] = name
+	}
+	return out
+}
+
+// watchStartOrder reports each service of the project as its first
+// container starts, and when it becomes healthy, until the returned stop
+// function is called
+func (s *Service) watchStartOrder(ctx context.Context, dockerClient client.APIClient, project *types.Project) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	total := len(project.Services)
+
+	eventFilter := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("label", fmt.Sprintf("%s=%s", labelProject, project.Name)),
+		filters.Arg("event", string(events.ActionStart)),
+		filters.Arg("event", string(events.ActionHealthStatus)),
+	)
+	// Since replays events from before the subscription is established
+	eventCh, errCh := dockerClient.Events(ctx, events.ListOptions{
+		Since:   strconv.FormatInt(time.Now().Unix(), 10),
+		Filters: eventFilter,
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		started := make(map[string]bool)
+		healthy := make(map[string]bool)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errCh:
+				if err != nil && ctx.Err() == nil {
+					s.logWarn("Stopped watching service start order", logrus.Fields{"error": err.Error()})
+				}
+				return
+			case msg := <-eventCh:
+				service := msg.Actor.Attributes[labelService]
+				if _, ok := project.Services[service]; !ok {
+					continue
+				}
+				switch {
+				case msg.Action == events.ActionStart && !started[service]:
+					started[service] = true
+					s.sendProgress(ProgressEvent{
+						Stage:      StageStarting,
+						Progress:   70 + 20*len(started)/total,
+						Message:    fmt.Sprintf("Started %s (%d/%d)", service, len(started), total),
+						Service:    service,
+						ServiceIdx: len(started),
+						TotalSvcs:  total,
+					})
+				case msg.Action == events.ActionHealthStatusHealthy && !healthy[service]:
+					healthy[service] = true
+					s.sendProgress(ProgressEvent{
+						Stage:     StageHealthCheck,
+						Progress:  70 + 20*len(started)/total,
+						Message:   fmt.Sprintf("%s is healthy", service),
+						Service:   service,
+						TotalSvcs: total,
+					})
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,64 @@
+package compose
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestStartOrder(t *testing.T) {
+	dependsOn := func(services ...string) types.DependsOnConfig {
+		deps := types.DependsOnConfig{}
+		for _, s := range services {
+			deps[s] = types.ServiceDependency{Condition: types.ServiceConditionHealthy}
+		}
+		return deps
+	}
+
+	tests := []struct {
+		name     string
+		services types.Services
+		want     []string
+	}{
+		{
+			name: "database before apps",
+			services: types.Services{
+				"web":    {Name: "web", DependsOn: dependsOn("api")},
+				"api":    {Name: "api", DependsOn: dependsOn("db", "cache")},
+				"db":     {Name: "db"},
+				"cache":  {Name: "cache"},
+				"worker": {Name: "worker", DependsOn: dependsOn("db")},
+			},
+			want: []string{"cache", "db", "api", "worker", "web"},
+		},
+		{
+			name: "dependency outside the project is ignored",
+			services: types.Services{
+				"app": {Name: "app", DependsOn: dependsOn("external")},
+			},
+			want: []string{"app"},
+		},
+		{
+			name: "cycle goes last",
+			services: types.Services{
+				"a":  {Name: "a", DependsOn: dependsOn("b")},
+				"b":  {Name: "b", DependsOn: dependsOn("a")},
+				"db": {Name: "db"},
+			},
+			want: []string{"db", "a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := startOrder(&types.Project{Services: tt.services})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("startOrder() = %v, want %v", got, tt.want)
+			}
+			if rev := reversed(got); len(got) > 0 && rev[0] != got[len(got)-1] {
+				t.Errorf("reversed(%v) = %v", got, rev)
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/compose/v2/pkg/api"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// =============================================================================
+// Stack Revisions
+// =============================================================================
+//
+// Before each "up", the stack's current compose files, the env files the
+// deployment is about to overwrite, and the digests of the images the
+// project is running are saved as a revision. A rollback redeploys a
+// revision with its images pinned to those digests.
+//
+// Directory structure: $STACKS_DIR/<project_name>/.revisions/<id>.json
+
+const (
+	// revisionsDirName is the per-stack directory holding revisions
+	revisionsDirName = ".revisions"
+	// DefaultKeepRevisions is how many revisions are kept per stack
+	DefaultKeepRevisions = 5
+	// revisionDirMode keeps revisions (which include env secrets) private
+	revisionDirMode os.FileMode = 0700
+)
+
+// Revision is a snapshot of a stack taken before a deployment changed it.
+type Revision struct {
+	ID           int               `json:"id"`
+	ProjectName  string            `json:"project_name"`
+	CreatedAt    time.Time         `json:"created_at"`
+	DeploymentID string            `json:"deployment_id"` // Deployment that replaced this state
+	ComposeYAML  string            `json:"compose_yaml"`
+	EnvFiles     map[string]string `json:"env_files,omitempty"`
+	ImageDigests map[string]string `json:"image_digests,omitempty"` // service -> image@sha256:... (or image ID)
+	// Extra compose files (included or overrides) and the override order
+	ComposeFiles     map[string]string `json:"compose_files,omitempty"`
+	ComposeOverrides []string          `json:"compose_overrides,omitempty"`
+}
+
+// RevisionSummary describes a revision without its file contents.
+type RevisionSummary struct {
+	ID           int               `json:"id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	DeploymentID string            `json:"deployment_id"`
+	ImageDigests map[string]string `json:"image_digests,omitempty"`
+}
+
+// keepRevisionsOrDefault resolves DeployRequest.KeepRevisions.
+func keepRevisionsOrDefault(keep int) int {
+	if keep == 0 {
+		return DefaultKeepRevisions
+	}
+	return keep
+}
+
+// revisionsDir returns a stack's revision directory. An empty stacksDir
+// means the default, matching Deploy.
+func revisionsDir(stacksDir, projectName string) (string, error) {
+	if stacksDir == "" {
+		stacksDir = defaultStacksDir
+	}
+	stackDir, err := GetStackDir(stacksDir, projectName)
+	if err != nil {
+		return "", fmt.Errorf("invalid stack: %w", err)
+	}
+	return filepath.Join(stackDir, revisionsDirName), nil
+}
+
+// snapshotStackFiles builds a revision from the stack's files on disk.
+// Returns nil if the stack has never been deployed. envNames are the env
+// files to capture (missing ones are skipped).
+func snapshotStackFiles(stacksDir, projectName string, envNames []string) (*Revision, error) {
+	stackDir, err := GetStackDir(stacksDir, projectName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack: %w", err)
+	}
+
+	composeYAML, err := os.ReadFile(filepath.Join(stackDir, "docker-compose.yml"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	rev := &Revision{
+		ProjectName: projectName,
+		CreatedAt:   time.Now().UTC(),
+		ComposeYAML: string(composeYAML),
+	}
+
+	manifest, err := readComposeManifest(stackDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Files {
+		if !SafeComposeFilename(name) {
+			continue
+		}
+		path, err := stackFilePath(stackDir, name, false)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if rev.ComposeFiles == nil {
+			rev.ComposeFiles = make(map[string]string)
+		}
+		rev.ComposeFiles[name] = string(content)
+	}
+	for _, name := range manifest.Overrides {
+		if _, ok := rev.ComposeFiles[name]; ok {
+			rev.ComposeOverrides = append(rev.ComposeOverrides, name)
+		}
+	}
+
+	for _, name := range envNames {
+		if !SafeEnvFilename(name) {
+			continue
+		}
+		bare := strings.TrimPrefix(name, "./")
+		path := filepath.Join(stackDir, bare)
+		// Never follow symlinks out of the stack dir (mirrors the O_NOFOLLOW write)
+		if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if rev.EnvFiles == nil {
+			rev.EnvFiles = make(map[string]string)
+		}
+		rev.EnvFiles[bare] = string(content)
+	}
+
+	return rev, nil
+}
+
+// SaveRevision stores rev under the next revision ID and prunes the oldest
+// revisions beyond keep. A negative keep disables revisions entirely.
+func SaveRevision(stacksDir, projectName string, rev *Revision, keep int) error {
+	if keep < 0 {
+		return nil
+	}
+	keep = keepRevisionsOrDefault(keep)
+
+	dir, err := revisionsDir(stacksDir, projectName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, revisionDirMode); err != nil {
+		return fmt.Errorf("failed to create revisions directory: %w", err)
+	}
+
+	ids, err := revisionIDs(dir)
+	if err != nil {
+		return err
+	}
+	rev.ID = 1
+	if len(ids) > 0 {
+		rev.ID = ids[len(ids)-1] + 1
+	}
+
+	data, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d.json", rev.ID))
+	if err := os.WriteFile(path, data, EnvFileMode); err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+
+	ids = append(ids, rev.ID)
+	for len(ids) > keep {
+		os.Remove(filepath.Join(dir, fmt.Sprintf("%d.json", ids[0])))
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// LoadRevision reads a revision. An id of 0 selects the most recent one.
+func LoadRevision(stacksDir, projectName string, id int) (*Revision, error) {
+	dir, err := revisionsDir(stacksDir, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		ids, err := revisionIDs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no revisions available for stack %s", projectName)
+		}
+		id = ids[len(ids)-1]
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.json", id)))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("revision %d not found for stack %s", id, projectName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision: %w", err)
+	}
+
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("failed to decode revision %d: %w", id, err)
+	}
+	return &rev, nil
+}
+
+// ListRevisions returns the stack's revisions, newest first.
+func ListRevisions(stacksDir, projectName string) ([]RevisionSummary, error) {
+	dir, err := revisionsDir(stacksDir, projectName)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := revisionIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]RevisionSummary, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		rev, err := LoadRevision(stacksDir, projectName, ids[i])
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, RevisionSummary{
+			ID:           rev.ID,
+			CreatedAt:    rev.CreatedAt,
+			DeploymentID: rev.DeploymentID,
+			ImageDigests: rev.ImageDigests,
+		})
+	}
+	return summaries, nil
+}
+
+// revisionIDs returns the IDs of stored revisions in ascending order.
+func revisionIDs(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revisions directory: %w", err)
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if id, err := strconv.Atoi(name); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// RollbackRequest turns a revision into a deployment request. base supplies
+// connection, directory, and health settings; compose content, env files,
+// and pinned images come from the revision.
+func RollbackRequest(rev *Revision, base DeployRequest) DeployRequest {
+	req := base
+	req.ProjectName = rev.ProjectName
+	req.Action = "up"
+	req.ComposeYAML = rev.ComposeYAML
+	req.ComposeFiles = rev.ComposeFiles
+	req.ComposeOverrides = rev.ComposeOverrides
+	req.EnvFiles = rev.EnvFiles
+	req.EnvFileContent = ""
+	req.PullImages = false // Pinned images are used as-is
+	req.ImageOverrides = rev.ImageDigests
+	req.Template = nil // Revisions hold the rendered files
+	return req
+}
+
+// envFilesToSnapshot lists the env files a deployment is about to write,
+// i.e. the ones whose previous content a revision must preserve.
+func envFilesToSnapshot(req DeployRequest) []string {
+	if len(req.EnvFiles) == 0 {
+		return []string{".env"}
+	}
+	names := make([]string, 0, len(req.EnvFiles))
+	for name := range req.EnvFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// projectImageDigests maps each running service of a project to the exact
+// image it runs: a repo digest when the image has one, otherwise its ID.
+func projectImageDigests(ctx context.Context, dockerClient *client.Client, projectName string) (map[string]string, error) {
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", api.ProjectLabel+"="+projectName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list project containers: %w", err)
+	}
+
+	digests := make(map[string]string)
+	for _, c := range containers {
+		service := c.Labels[api.ServiceLabel]
+		if service == "" || digests[service] != "" {
+			continue
+		}
+
+		ref := c.ImageID
+		if inspect, _, err := dockerClient.ImageInspectWithRaw(ctx, c.ImageID); err == nil {
+			if d := matchingRepoDigest(inspect.RepoDigests, c.Image); d != "" {
+				ref = d
+			}
+		}
+		if ref != "" {
+			digests[service] = ref
+		}
+	}
+	return digests, nil
+}
+
+// matchingRepoDigest picks the repo digest for the container's image
+// repository, falling back to the first digest.
+func matchingRepoDigest(repoDigests []string, image string) string {
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	repo := image
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	for _, d := range repoDigests {
+		if strings.HasPrefix(d, repo+"@") {
+			return d
+		}
+	}
+	return repoDigests[0]
+}
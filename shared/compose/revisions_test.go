@@ -0,0 +1,160 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotStackFilesNoPreviousDeploy(t *testing.T) {
+	rev, err := snapshotStackFiles(t.TempDir(), "myapp", []string{".env"})
+	if err != nil {
+		t.Fatalf("snapshotStackFiles: %v", err)
+	}
+	if rev != nil {
+		t.Errorf("expected nil revision for a never-deployed stack, got %+v", rev)
+	}
+}
+
+func TestSnapshotStackFilesCapturesComposeAndEnv(t *testing.T) {
+	stacks := t.TempDir()
+	if _, err := WriteStackComposeFile(stacks, "myapp", "services: {}\n"); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+	if err := WriteStackEnvFiles(stacks, "myapp", map[string]string{".env": "A=1", ".db.env": "PW=x"}); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	// Symlinked env files must not be captured
+	outside := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(outside, []byte("SECRET"), 0600)
+	os.Symlink(outside, filepath.Join(stacks, "myapp", ".link.env"))
+
+	rev, err := snapshotStackFiles(stacks, "myapp", []string{".env", "./.db.env", ".missing.env", ".link.env", "../escape"})
+	if err != nil {
+		t.Fatalf("snapshotStackFiles: %v", err)
+	}
+	if rev.ComposeYAML != "services: {}\n" {
+		t.Errorf("ComposeYAML = %q", rev.ComposeYAML)
+	}
+	want := map[string]string{".env": "A=1", ".db.env": "PW=x"}
+	if len(rev.EnvFiles) != len(want) {
+		t.Fatalf("EnvFiles = %v, want %v", rev.EnvFiles, want)
+	}
+	for name, content := range want {
+		if rev.EnvFiles[name] != content {
+			t.Errorf("EnvFiles[%s] = %q, want %q", name, rev.EnvFiles[name], content)
+		}
+	}
+}
+
+func TestSaveRevisionPrunesAndLoadsLatest(t *testing.T) {
+	stacks := t.TempDir()
+
+	for i := 0; i < 4; i++ {
+		rev := &Revision{ProjectName: "myapp", ComposeYAML: string(rune('a' + i))}
+		if err := SaveRevision(stacks, "myapp", rev, 2); err != nil {
+			t.Fatalf("SaveRevision: %v", err)
+		}
+		if rev.ID != i+1 {
+			t.Errorf("revision %d got ID %d", i, rev.ID)
+		}
+	}
+
+	summaries, err := ListRevisions(stacks, "myapp")
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].ID != 4 || summaries[1].ID != 3 {
+		t.Fatalf("summaries = %+v, want IDs 4, 3", summaries)
+	}
+
+	latest, err := LoadRevision(stacks, "myapp", 0)
+	if err != nil {
+		t.Fatalf("LoadRevision(latest): %v", err)
+	}
+	if latest.ID != 4 || latest.ComposeYAML != "d" {
+		t.Errorf("latest = %+v, want ID 4 / d", latest)
+	}
+
+	if _, err := LoadRevision(stacks, "myapp", 1); err == nil {
+		t.Error("expected pruned revision 1 to be gone")
+	}
+}
+
+func TestSaveRevisionDisabled(t *testing.T) {
+	stacks := t.TempDir()
+	if err := SaveRevision(stacks, "myapp", &Revision{ProjectName: "myapp"}, -1); err != nil {
+		t.Fatalf("SaveRevision: %v", err)
+	}
+	if _, err := LoadRevision(stacks, "myapp", 0); err == nil {
+		t.Error("expected no revisions when keep is negative")
+	}
+}
+
+func TestDeployInvalidRequestSavesNoRevision(t *testing.T) {
+	stacks := t.TempDir()
+	if _, err := WriteStackComposeFile(stacks, "myapp", "services: {}\n"); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+
+	result := NewService(nil, nil).Deploy(context.Background(), DeployRequest{
+		DeploymentID: "d1",
+		ProjectName:  "myapp",
+		Action:       "up",
+		StacksDir:    stacks,
+		ComposeYAML:  "services: {}\n",
+		EnvSet:       "prod",
+	})
+	if result.Success {
+		t.Fatal("expected the unresolved variable set to fail the deploy")
+	}
+	if _, err := LoadRevision(stacks, "myapp", 0); err == nil {
+		t.Error("an invalid deploy must not record a revision")
+	}
+}
+
+func TestRollbackRequest(t *testing.T) {
+	rev := &Revision{
+		ProjectName:  "myapp",
+		ComposeYAML:  "services: {}\n",
+		EnvFiles:     map[string]string{".env": "A=1"},
+		ImageDigests: map[string]string{"web": "nginx@sha256:abc"},
+	}
+	base := DeployRequest{
+		DeploymentID:   "rb-1",
+		DockerHost:     "tcp://h:2376",
+		EnvFileContent: "STALE=1",
+		PullImages:     true,
+	}
+
+	req := RollbackRequest(rev, base)
+
+	if req.Action != "up" || req.ProjectName != "myapp" || req.DeploymentID != "rb-1" || req.DockerHost != "tcp://h:2376" {
+		t.Errorf("unexpected request identity: %+v", req)
+	}
+	if req.EnvFileContent != "" || req.EnvFiles[".env"] != "A=1" {
+		t.Errorf("env not taken from revision: %+v / %q", req.EnvFiles, req.EnvFileContent)
+	}
+	if req.PullImages {
+		t.Error("rollback must not pull over pinned images")
+	}
+	if req.ImageOverrides["web"] != "nginx@sha256:abc" {
+		t.Errorf("ImageOverrides = %v", req.ImageOverrides)
+	}
+}
+
+func TestMatchingRepoDigest(t *testing.T) {
+	digests := []string{"mirror.local/nginx@sha256:111", "nginx@sha256:222"}
+
+	if got := matchingRepoDigest(digests, "nginx:1.27"); got != "nginx@sha256:222" {
+		t.Errorf("got %q, want nginx@sha256:222", got)
+	}
+	if got := matchingRepoDigest(digests, "registry:5000/other"); got != "mirror.local/nginx@sha256:111" {
+		t.Errorf("fallback got %q", got)
+	}
+	if got := matchingRepoDigest(nil, "nginx"); got != "" {
+		t.Errorf("no digests got %q", got)
+	}
+}
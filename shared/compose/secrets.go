@@ -0,0 +1,234 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxSecretSize matches the limit Docker puts on swarm secrets
+	MaxSecretSize = 500 * 1024
+	// secretsOverrideFile declares the written secrets to compose
+	secretsOverrideFile = "secrets.compose.yaml"
+)
+
+// secretNamePattern is what compose accepts as a top-level secret key
+var secretNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateSecrets checks secret names and sizes, and that the Docker host
+// can see the files: secrets are written locally, so they can't be used
+// with a remote (mTLS) Docker host, and need COMPOSE_SECRETS_DIR.
+func (r DeployRequest) ValidateSecrets() error {
+	if len(r.Secrets) == 0 {
+		return nil
+	}
+	if secretsBaseDir() == "" {
+		return fmt.Errorf("secrets require COMPOSE_SECRETS_DIR: an in-memory directory on the Docker host, mounted at the same path into the service's container")
+	}
+	if r.DockerHost != "" {
+		return fmt.Errorf("secrets require a local Docker engine")
+	}
+	for _, t := range r.Targets {
+		if t.DockerHost != "" {
+			return fmt.Errorf("target %s: secrets require a local Docker engine", t.Name)
+		}
+	}
+	for name, content := range r.Secrets {
+		if len(name) > 64 || !secretNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid secret name: %q", name)
+		}
+		if len(content) > MaxSecretSize {
+			return fmt.Errorf("secret %s is larger than %d bytes", name, MaxSecretSize)
+		}
+	}
+	return nil
+}
+
+// secretsBaseDir returns where secret files are written, COMPOSE_SECRETS_DIR,
+// or "" when unset. The Docker daemon bind-mounts the files by path, so the
+// directory must have the same path on the host as in the service's
+// container; a default such as the container's /dev/shm would not exist on
+// the host. It should be a tmpfs, so secrets stay in memory.
+func secretsBaseDir() string {
+	return os.Getenv("COMPOSE_SECRETS_DIR")
+}
+
+// SecretFiles are a deployment's secrets written to a private directory
+type SecretFiles struct {
+	Dir      string
+	Paths    map[string]string // Secret name -> file
+	Override string            // Compose file declaring the secrets
+}
+
+// WriteSecretFiles writes each secret to a 0600 file in a new 0700
+// directory, plus a compose override that declares them as file secrets so
+// services may reference secrets the compose file doesn't declare. Caller
+// must call Shred() when done.
+func WriteSecretFiles(secrets map[string]string) (*SecretFiles, error) {
+	base := secretsBaseDir()
+	if base == "" {
+		return nil, fmt.Errorf("COMPOSE_SECRETS_DIR is not set")
+	}
+	if err := os.MkdirAll(base, TempDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create secrets dir: %w", err)
+	}
+	dir, err := os.MkdirTemp(base, TempFilePrefix+"secrets-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secrets dir: %w", err)
+	}
+	files := &SecretFiles{Dir: dir, Paths: make(map[string]string, len(secrets))}
+
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var override strings.Builder
+	override.WriteString("secrets:\n")
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := writePrivateFile(path, secrets[name]); err != nil {
+			files.Shred(nil)
+			return nil, fmt.Errorf("failed to write secret %s: %w", name, err)
+		}
+		files.Paths[name] = path
+		// JSON strings are valid YAML double-quoted scalars
+		quotedName, _ := json.Marshal(name)
+		quotedPath, _ := json.Marshal(path)
+		fmt.Fprintf(&override, "  %s:\n    file: %s\n", quotedName, quotedPath)
+	}
+
+	files.Override = filepath.Join(dir, secretsOverrideFile)
+	if err := writePrivateFile(files.Override, override.String()); err != nil {
+		files.Shred(nil)
+		return nil, fmt.Errorf("failed to write secrets override: %w", err)
+	}
+	return files, nil
+}
+
+// writePrivateFile creates path with TempFileMode, failing if it exists
+func writePrivateFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, TempFileMode) // #nosec G304 -- path is inside our own secrets dir
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Apply points the project's secrets at the written files. A secret the
+// compose file declared differently (external, environment) is replaced,
+// and the override is dropped from the project's file list so it isn't
+// recorded in container labels after it has been shredded.
+func (f *SecretFiles) Apply(project *types.Project) {
+	if f == nil {
+		return
+	}
+	if project.Secrets == nil {
+		project.Secrets = types.Secrets{}
+	}
+	for name, path := range f.Paths {
+		secret := project.Secrets[name]
+		project.Secrets[name] = types.SecretConfig{Name: secret.Name, File: path, Labels: secret.Labels}
+	}
+	files := project.ComposeFiles[:0]
+	for _, file := range project.ComposeFiles {
+		if file != f.Override {
+			files = append(files, file)
+		}
+	}
+	project.ComposeFiles = files
+}
+
+// Shred overwrites every secret file with zeros before removing the
+// directory. Running containers keep the bind-mounted files they already
+// opened; a container restarted later needs a redeploy to get them back.
+func (f *SecretFiles) Shred(log *logrus.Logger) {
+	if f == nil || f.Dir == "" {
+		return
+	}
+	if err := shredDir(f.Dir); err != nil && log != nil {
+		log.WithError(err).WithField("path", f.Dir).Warn("Failed to shred secrets")
+	}
+}
+
+// shredDir zeroes and removes every file in dir, then dir itself
+func shredDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := shredFile(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0) // #nosec G304 -- path is inside our own secrets dir
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.Write(make([]byte, info.Size()))
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// cleanupStaleSecrets shreds secret directories left behind by a crash
+func cleanupStaleSecrets(log *logrus.Logger) int {
+	base := secretsBaseDir()
+	if base == "" {
+		return 0
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return 0
+	}
+	cleaned := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), TempFilePrefix+"secrets-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) <= StaleFileThreshold {
+			continue
+		}
+		if err := shredDir(filepath.Join(base, entry.Name())); err != nil {
+			if log != nil {
+				log.WithError(err).WithField("path", entry.Name()).Warn("Failed to shred stale secrets")
+			}
+			continue
+		}
+		cleaned++
+	}
+	return cleaned
+}
@@ -0,0 +1,89 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSecrets(t *testing.T) {
+	t.Setenv("COMPOSE_SECRETS_DIR", t.TempDir())
+	ok := DeployRequest{Secrets: map[string]string{"db_password": "hunter2", "api.key": "k"}}
+	if err := ok.ValidateSecrets(); err != nil {
+		t.Errorf("valid secrets rejected: %v", err)
+	}
+
+	bad := []DeployRequest{
+		{Secrets: map[string]string{"../escape": "x"}},
+		{Secrets: map[string]string{"big": strings.Repeat("x", MaxSecretSize+1)}},
+		{Secrets: map[string]string{"db_password": "x"}, DockerHost: "tcp://10.0.0.5:2376"},
+		{Secrets: map[string]string{"db_password": "x"}, Targets: []DeployTarget{{Name: "remote", DockerHost: "tcp://10.0.0.5:2376"}}},
+	}
+	for i, req := range bad {
+		if err := req.ValidateSecrets(); err == nil {
+			t.Errorf("request %d: invalid secrets accepted", i)
+		}
+	}
+
+	t.Setenv("COMPOSE_SECRETS_DIR", "")
+	if err := ok.ValidateSecrets(); err == nil || !strings.Contains(err.Error(), "COMPOSE_SECRETS_DIR") {
+		t.Errorf("secrets without COMPOSE_SECRETS_DIR: err = %v", err)
+	}
+	if err := (DeployRequest{}).ValidateSecrets(); err != nil {
+		t.Errorf("no secrets without COMPOSE_SECRETS_DIR: %v", err)
+	}
+	if _, err := WriteSecretFiles(ok.Secrets); err == nil {
+		t.Error("WriteSecretFiles without COMPOSE_SECRETS_DIR succeeded")
+	}
+}
+
+func TestSecretFilesLoadAndShred(t *testing.T) {
+	t.Setenv("COMPOSE_SECRETS_DIR", t.TempDir())
+
+	stacks := t.TempDir()
+	main, err := WriteStackComposeFile(stacks, "p", `services:
+  web:
+    image: nginx
+    secrets: [db_password, api_key]
+secrets:
+  db_password:
+    external: true
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := WriteSecretFiles(map[string]string{"db_password": "hunter2", "api_key": "k"})
+	if err != nil {
+		t.Fatalf("WriteSecretFiles: %v", err)
+	}
+	path := files.Paths["db_password"]
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != TempFileMode {
+		t.Fatalf("secret file: %v, mode %v", err, info.Mode().Perm())
+	}
+
+	project, err := newTestService().loadProject(context.Background(), []string{main, files.Override}, "p", nil, "", nil)
+	if err != nil {
+		t.Fatalf("loadProject: %v", err)
+	}
+	files.Apply(project)
+	if got := project.Secrets["db_password"]; got.File != path || bool(got.External) {
+		t.Errorf("db_password = %+v, want file %s", got, path)
+	}
+	if got := project.Secrets["api_key"].File; got != files.Paths["api_key"] {
+		t.Errorf("undeclared api_key file = %q", got)
+	}
+	for _, f := range project.ComposeFiles {
+		if f == files.Override {
+			t.Error("secrets override left in the project's compose files")
+		}
+	}
+
+	files.Shred(nil)
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("secrets dir still exists after Shred: %v", err)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -181,6 +183,33 @@ func (s *Service) Deploy(ctx context.Context, req DeployRequest) (result *Deploy
 		Message:  "Validating deployment...",
 	})
 
+	if err := req.ValidateComposeFiles(); err != nil {
+		return s.failResult(req.DeploymentID, err.Error())
+	}
+	if err := req.ValidateSecrets(); err != nil {
+		return s.failResult(req.DeploymentID, err.Error())
+	}
+	if req.EnvSet != "" && req.EnvSetVars == nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("variable set %q was not resolved; variable sets are only available through the compose service", req.EnvSet))
+	}
+
+	// Render templated compose files for this host before they're written
+	if req.Template != nil {
+		if err := s.renderTemplates(ctx, &req); err != nil {
+			msg := fmt.Sprintf("Failed to render compose template: %v", err)
+			failed := s.failResult(req.DeploymentID, msg)
+			failed.Error = NewValidationError(msg)
+			return failed
+		}
+	}
+
+	// Snapshot the stack's current state once the request is valid and
+	// before "up" replaces it, so it can be rolled back. Best-effort: a
+	// failed snapshot never blocks a deploy.
+	if req.Action == "up" && req.KeepRevisions >= 0 {
+		s.saveRevision(ctx, stacksDir, req)
+	}
+
 	// Write compose file to persistent stack directory
 	// This allows relative bind mounts (./data) to persist across redeployments
 	composeFile, err := WriteStackComposeFile(stacksDir, req.ProjectName, req.ComposeYAML)
@@ -188,6 +217,12 @@ func (s *Service) Deploy(ctx context.Context, req DeployRequest) (result *Deploy
 		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to write compose file: %v", err))
 	}
 
+	// Included and override files live next to the main file so relative
+	// include: paths resolve against the stack dir
+	if err := WriteStackComposeFiles(stacksDir, req.ProjectName, req.ComposeFiles, req.ComposeOverrides); err != nil {
+		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to write compose files: %v", err))
+	}
+
 	// Write env files to the stack dir before loading the project so compose-go
 	// can resolve env_file: references. EnvFiles (the full map) wins; fall back
 	// to the legacy single EnvFileContent for older callers.
@@ -220,39 +255,29 @@ func (s *Service) Deploy(ctx context.Context, req DeployRequest) (result *Deploy
 		}()
 	}
 
+	// Remember the project's containers so a cancelled up can remove just
+	// the ones it created
+	var before map[string]bool
+	if req.Action == "up" {
+		before = s.projectContainerIDs(ctx, req.ProjectName)
+	}
+
 	switch req.Action {
 	case "up":
-		return s.runComposeUp(ctx, req, composeFile)
+		result = s.runComposeUp(ctx, req, composeFile)
 	case "down":
-		return s.runComposeDown(ctx, req, composeFile)
+		result = s.runComposeDown(ctx, req, composeFile)
 	case "restart":
-		return s.runRestart(ctx, req, composeFile)
+		result = s.runRestart(ctx, req, composeFile)
 	default:
 		return s.failResult(req.DeploymentID, fmt.Sprintf("Unknown action: %s", req.Action))
 	}
-}
-
-// runRestart performs a compose restart by stopping then starting services
-func (s *Service) runRestart(ctx context.Context, req DeployRequest, composeFile string) *DeployResult {
-	s.sendProgress(ProgressEvent{
-		Stage:    StageStarting,
-		Progress: 30,
-		Message:  "Stopping services...",
-	})
 
-	downReq := req
-	downReq.RemoveVolumes = false
-	downResult := s.runComposeDown(ctx, downReq, composeFile)
-	if !downResult.Success {
-		return downResult
+	// A deployment that finished before the cancel landed keeps its result
+	if !result.Success && errors.Is(context.Cause(ctx), ErrDeployCancelled) {
+		return s.cancelledResult(req, before)
 	}
-
-	s.sendProgress(ProgressEvent{
-		Stage:    StageStarting,
-		Progress: 50,
-		Message:  "Starting services...",
-	})
-	return s.runComposeUp(ctx, req, composeFile)
+	return result
 }
 
 // Teardown removes a compose stack
@@ -339,16 +364,26 @@ func (s *Service) createComposeService(ctx context.Context, req DeployRequest) (
 	return composeService, cli, tlsFiles, nil
 }
 
-// loadProject loads a compose project from file content.
-// Environment variables are loaded from .env file in the working directory
-// (written by WriteEnvFile before this is called).
+// projectComposeFiles returns the main compose file followed by its overrides
+func projectComposeFiles(req DeployRequest, composeFile string) []string {
+	composeFiles := []string{composeFile}
+	for _, name := range req.ComposeOverrides {
+		composeFiles = append(composeFiles, filepath.Join(filepath.Dir(composeFile), filepath.FromSlash(name)))
+	}
+	return composeFiles
+}
+
+// loadProject loads a compose project from its files: the main compose file
+// first, then any overrides in merge order. Environment variables are loaded
+// from .env file in the working directory (written by WriteEnvFile before
+// this is called), over baseEnv (the request's variable set).
 //
 // When hostWorkingDir is set (containerized deployments with HOST_STACKS_DIR),
 // the project is loaded using the container-internal working directory so that
 // env_file paths resolve correctly inside the container. Bind mount sources are
 // then rewritten to host paths in a post-processing step.
-func (s *Service) loadProject(ctx context.Context, composeFile, projectName string, profiles []string, hostWorkingDir string) (*types.Project, error) {
-	workingDir := filepath.Dir(composeFile)
+func (s *Service) loadProject(ctx context.Context, composeFiles []string, projectName string, profiles []string, hostWorkingDir string, baseEnv map[string]string) (*types.Project, error) {
+	workingDir := filepath.Dir(composeFiles[0])
 	envFile := filepath.Join(workingDir, ".env")
 
 	opts := []cli.ProjectOptionsFn{
@@ -357,6 +392,21 @@ func (s *Service) loadProject(ctx context.Context, composeFile, projectName stri
 		cli.WithProfiles(profiles),
 	}
 
+	// Variable set entries go first so the .env file's override them
+	if len(baseEnv) > 0 {
+		names := make([]string, 0, len(baseEnv))
+		for name := range baseEnv {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		setVars := make([]string, 0, len(names))
+		for _, name := range names {
+			setVars = append(setVars, name+"="+baseEnv[name])
+		}
+		s.logInfo("Loaded env vars from variable set", logrus.Fields{"count": len(setVars)})
+		opts = append(opts, cli.WithEnv(setVars))
+	}
+
 	// Load .env file manually and pass via WithEnv for reliable interpolation
 	// This bypasses compose-go's WithDotEnv which can have issues with file loading
 	if _, err := os.Stat(envFile); err == nil {
@@ -376,7 +426,7 @@ func (s *Service) loadProject(ctx context.Context, composeFile, projectName stri
 	}
 
 	projectOpts, err := cli.NewProjectOptions(
-		[]string{composeFile},
+		composeFiles,
 		opts...,
 	)
 	if err != nil {
@@ -501,6 +551,50 @@ func (s *Service) applyComposeLabels(project *types.Project) {
 	}
 }
 
+// applyImageOverrides replaces service images with pinned references
+func (s *Service) applyImageOverrides(project *types.Project, overrides map[string]string) {
+	for name, image := range overrides {
+		svc, ok := project.Services[name]
+		if !ok || image == "" {
+			continue
+		}
+		svc.Image = image
+		project.Services[name] = svc
+	}
+}
+
+// saveRevision records the stack's current compose file, the env files the
+// deployment will overwrite, and the running image digests as a revision.
+func (s *Service) saveRevision(ctx context.Context, stacksDir string, req DeployRequest) {
+	rev, err := snapshotStackFiles(stacksDir, req.ProjectName, envFilesToSnapshot(req))
+	if err != nil {
+		s.logWarn("Failed to snapshot stack revision", logrus.Fields{"error": err.Error()})
+		return
+	}
+	if rev == nil {
+		return // First deployment, nothing to roll back to
+	}
+	rev.DeploymentID = req.DeploymentID
+
+	if s.dockerClient != nil {
+		digests, err := projectImageDigests(ctx, s.dockerClient, req.ProjectName)
+		if err != nil {
+			s.logWarn("Failed to record image digests for revision", logrus.Fields{"error": err.Error()})
+		} else {
+			rev.ImageDigests = digests
+		}
+	}
+
+	if err := SaveRevision(stacksDir, req.ProjectName, rev, req.KeepRevisions); err != nil {
+		s.logWarn("Failed to save stack revision", logrus.Fields{"error": err.Error()})
+		return
+	}
+	s.logInfo("Saved stack revision", logrus.Fields{
+		"project_name": req.ProjectName,
+		"revision":     rev.ID,
+	})
+}
+
 // pullProjectImages pulls images with progress reporting
 func (s *Service) pullProjectImages(ctx context.Context, imageNames []string, credentials []RegistryCredential) error {
 	pullMsg := fmt.Sprintf("Pulling %d image(s)...", len(imageNames))
@@ -567,7 +661,7 @@ func (s *Service) waitForHealthyServices(ctx context.Context, composeService api
 	return result
 }
 
-func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFile string) *DeployResult {
+func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFile string) (result *DeployResult) {
 	s.sendProgress(ProgressEvent{
 		Stage:    StageParsing,
 		Progress: 10,
@@ -604,10 +698,22 @@ func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFi
 		})
 	}
 
-	project, err := s.loadProject(ctx, composeFile, req.ProjectName, req.Profiles, hostWorkingDir)
+	// Secrets are written just for this up and shredded when it returns
+	composeFiles := projectComposeFiles(req, composeFile)
+	var secretFiles *SecretFiles
+	if len(req.Secrets) > 0 {
+		if secretFiles, err = WriteSecretFiles(req.Secrets); err != nil {
+			return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to write secrets: %v", err))
+		}
+		defer secretFiles.Shred(s.log)
+		composeFiles = append(composeFiles, secretFiles.Override)
+	}
+
+	project, err := s.loadProject(ctx, composeFiles, req.ProjectName, req.Profiles, hostWorkingDir, req.EnvSetVars)
 	if err != nil {
 		return s.failResult(req.DeploymentID, fmt.Sprintf("Failed to load compose project: %v", err))
 	}
+	secretFiles.Apply(project)
 
 	// Defensive check: if we attempted a rewrite but a bind source is still
 	// rooted at the container-internal stacks dir, the Docker daemon will
@@ -624,6 +730,97 @@ func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFi
 	}
 
 	project = project.WithoutUnnecessaryResources()
+
+	// External networks/volumes/configs must exist before compose creates
+	// anything, or "up" fails with some services already started
+	if resources := projectExternalResources(project); len(resources) > 0 {
+		s.sendProgress(ProgressEvent{
+			Stage:    StageValidating,
+			Progress: 15,
+			Message:  fmt.Sprintf("Checking %d external resource(s)...", len(resources)),
+		})
+		var opts ExternalResourceOptions
+		if req.ExternalResources != nil {
+			opts = *req.ExternalResources
+		}
+		externalResults, err := s.checkExternalResources(ctx, project, opts)
+		if err != nil {
+			return s.failResult(req.DeploymentID, fmt.Sprintf("External resource check failed: %v", err))
+		}
+		if msg := externalResourceFailure(externalResults); msg != "" {
+			failed := s.failResult(req.DeploymentID, msg)
+			failed.Error = NewValidationError(msg)
+			failed.ExternalResources = externalResults
+			return failed
+		}
+		defer func() {
+			if result != nil {
+				result.ExternalResources = externalResults
+			}
+		}()
+	}
+
+	// Project the host's memory reservation and container count with this
+	// stack deployed, rejecting (or warning about) oversubscription
+	if req.Quota != nil && s.dockerClient != nil {
+		s.sendProgress(ProgressEvent{
+			Stage:    StageValidating,
+			Progress: 18,
+			Message:  "Checking host resource quota...",
+		})
+		quotaResult, err := s.checkHostQuota(ctx, s.dockerClient, project, *req.Quota)
+		if err != nil {
+			return s.failResult(req.DeploymentID, fmt.Sprintf("Quota check failed: %v", err))
+		}
+		if msg := quotaFailure(quotaResult); msg != "" {
+			if quotaResult.Rejected {
+				failed := s.failResult(req.DeploymentID, msg)
+				failed.Error = NewValidationError(msg)
+				failed.Quota = quotaResult
+				return failed
+			}
+			s.logWarn(msg, logrus.Fields{"project_name": req.ProjectName})
+			s.sendProgress(ProgressEvent{
+				Stage:    StageValidating,
+				Progress: 18,
+				Message:  "Warning: " + msg,
+			})
+		}
+		defer func() {
+			if result != nil {
+				result.Quota = quotaResult
+			}
+		}()
+	}
+
+	// Check bind sources on the Docker host before anything is created, so a
+	// missing path fails here instead of becoming a root-owned empty directory
+	if req.BindPaths != nil {
+		s.sendProgress(ProgressEvent{
+			Stage:    StageValidating,
+			Progress: 20,
+			Message:  "Checking bind mount paths...",
+		})
+		bindResults, err := s.checkBindPaths(ctx, project, *req.BindPaths)
+		if err != nil {
+			return s.failResult(req.DeploymentID, fmt.Sprintf("Bind mount check failed: %v", err))
+		}
+		s.logBindPaths(bindResults)
+		if msg, services := bindPathFailure(bindResults); msg != "" {
+			failed := s.failResult(req.DeploymentID, msg)
+			failed.Error = NewValidationError(msg)
+			failed.FailedServices = services
+			failed.BindPaths = bindResults
+			return failed
+		}
+		defer func() {
+			if result != nil {
+				result.BindPaths = bindResults
+			}
+		}()
+	}
+
+	s.applyImageOverrides(project, req.ImageOverrides)
 	s.applyComposeLabels(project)
 	serviceNames, imageNames := collectServiceInfo(project)
 
@@ -681,12 +878,18 @@ func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFi
 
 	// Detect whether this project already had containers before we start, so a
 	// failed redeploy doesn't tear down previously-running services.
-	preExisting, discErr := DiscoverContainers(ctx, s.dockerClient, req.ProjectName, s.log)
+	preExisting, discErr := DiscoverContainersWithTypes(ctx, s.dockerClient, req.ProjectName)
 	if discErr != nil {
 		s.logWarn("Failed to check for pre-existing containers before up", logrus.Fields{"error": discErr.Error()})
 	}
 	hadExistingContainers := len(preExisting) > 0
 
+	// A restart reports each service as it comes up in dependency order
+	if req.Action == "restart" {
+		stopWatch := s.watchStartOrder(ctx, cli.Client(), project)
+		defer stopWatch()
+	}
+
 	if err := composeService.Up(ctx, project, upOpts); err != nil {
 		s.logError("Compose up failed", err, nil)
 		if hadExistingContainers {
@@ -723,7 +926,7 @@ func (s *Service) runComposeUp(ctx context.Context, req DeployRequest, composeFi
 		}
 	}
 
-	result := AnalyzeServiceStatus(req.DeploymentID, services, s.log)
+	result = AnalyzeServiceStatus(req.DeploymentID, services, s.log)
 
 	if result.Success {
 		runningCount := countHealthyServices(result.Services)
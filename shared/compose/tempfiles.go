@@ -1,11 +1,12 @@
 package compose
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -176,6 +177,8 @@ func CleanupStaleFiles(log *logrus.Logger) {
 		}
 	}
 
+	cleaned += cleanupStaleSecrets(log)
+
 	if cleaned > 0 && log != nil {
 		log.WithField("count", cleaned).Info("Cleaned up stale temp files from previous run")
 	}
@@ -291,6 +294,130 @@ func WriteStackComposeFile(stacksDir, projectName, content string) (string, erro
 	return composePath, nil
 }
 
+// composeManifestName records a stack's extra compose files and override
+// order, so revisions can capture them
+const composeManifestName = ".compose-files.json"
+
+// composeManifest is the content of composeManifestName
+type composeManifest struct {
+	Files     []string `json:"files"`
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+// SafeComposeFilename reports whether name is a relative path safe to write
+// inside a stack directory: no traversal, not absolute, no hidden components
+// (which keeps .env files, revisions and the manifest out of reach), and not
+// the main docker-compose.yml.
+func SafeComposeFilename(name string) bool {
+	if name == "" || strings.ContainsAny(name, "\\\x00") || strings.HasPrefix(name, "/") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return name != "docker-compose.yml"
+}
+
+// WriteStackComposeFiles writes extra compose files into the stack directory
+// (which must already exist) and records them with their override order.
+// Paths are validated up front; directories along the way must not be
+// symlinks. Files from earlier deployments that aren't listed are left in
+// place but dropped from the manifest.
+func WriteStackComposeFiles(stacksDir, projectName string, files map[string]string, overrides []string) error {
+	stackDir, err := GetStackDir(stacksDir, projectName)
+	if err != nil {
+		return fmt.Errorf("invalid stack: %w", err)
+	}
+	manifestPath := filepath.Join(stackDir, composeManifestName)
+
+	if len(files) == 0 {
+		if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove compose manifest: %w", err)
+		}
+		return nil
+	}
+
+	for name := range files {
+		if !SafeComposeFilename(name) {
+			return fmt.Errorf("unsafe compose filename: %q", name)
+		}
+	}
+
+	manifest := composeManifest{Overrides: overrides}
+	for name, content := range files {
+		fpath, err := stackFilePath(stackDir, name, true)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|oNoFollow, StackFileMode)
+		if err != nil {
+			return fmt.Errorf("failed to write compose file %q: %w", name, err)
+		}
+		if _, werr := f.Write([]byte(content)); werr != nil {
+			f.Close()
+			return fmt.Errorf("failed to write compose file %q: %w", name, werr)
+		}
+		if cerr := f.Close(); cerr != nil {
+			return fmt.Errorf("failed to write compose file %q: %w", name, cerr)
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+	sort.Strings(manifest.Files)
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(manifestPath, data, StackFileMode); err != nil {
+		return fmt.Errorf("failed to write compose manifest: %w", err)
+	}
+	return nil
+}
+
+// readComposeManifest returns the stack's extra compose files, or an empty
+// manifest if the stack only uses docker-compose.yml.
+func readComposeManifest(stackDir string) (composeManifest, error) {
+	var manifest composeManifest
+	data, err := os.ReadFile(filepath.Join(stackDir, composeManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid compose manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// stackFilePath resolves a validated relative path inside stackDir, refusing
+// symlinked directories along the way. With create, missing directories are
+// created.
+func stackFilePath(stackDir, name string, create bool) (string, error) {
+	parts := strings.Split(name, "/")
+	dir := stackDir
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) && create {
+			if err := os.Mkdir(dir, StackDirMode); err != nil {
+				return "", fmt.Errorf("failed to create directory for %q: %w", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to stat directory for %q: %w", name, err)
+		}
+		if !info.IsDir() { // Lstat: symlinks aren't directories
+			return "", fmt.Errorf("refusing to use %q: %s is not a plain directory", name, dir)
+		}
+	}
+	return filepath.Join(dir, parts[len(parts)-1]), nil
+}
+
 // WriteStackEnvFile writes .env content to a stack directory.
 // The stack directory must already exist (call WriteStackComposeFile first).
 // If envContent is empty, removes any existing .env file.
@@ -366,7 +493,7 @@ func WriteStackEnvFiles(stacksDir, projectName string, files map[string]string)
 	for name, content := range files {
 		bare := strings.TrimPrefix(name, "./")
 		fpath := filepath.Join(stackDir, bare)
-		f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, EnvFileMode)
+		f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|oNoFollow, EnvFileMode)
 		if err != nil {
 			return fmt.Errorf("failed to write env file %q: %w", bare, err)
 		}
@@ -0,0 +1,188 @@
+package compose
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeComposeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"compose.prod.yaml", true},
+		{"db/compose.yaml", true},
+		{"", false},
+		{"docker-compose.yml", false},
+		{"/etc/compose.yaml", false},
+		{"../other/compose.yaml", false},
+		{"db/../../x.yaml", false},
+		{"./compose.yaml", false},
+		{".env", false},
+		{".revisions/1.json", false},
+		{"db//compose.yaml", false},
+		{"db\\compose.yaml", false},
+	}
+	for _, tt := range tests {
+		if got := SafeComposeFilename(tt.name); got != tt.want {
+			t.Errorf("SafeComposeFilename(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateComposeFiles(t *testing.T) {
+	req := DeployRequest{
+		ComposeFiles:     map[string]string{"compose.prod.yaml": "", "db/compose.yaml": ""},
+		ComposeOverrides: []string{"compose.prod.yaml"},
+	}
+	if err := req.ValidateComposeFiles(); err != nil {
+		t.Errorf("valid request rejected: %v", err)
+	}
+
+	req.ComposeOverrides = []string{"missing.yaml"}
+	if err := req.ValidateComposeFiles(); err == nil {
+		t.Error("expected error for override not in compose_files")
+	}
+
+	req.ComposeOverrides = []string{"compose.prod.yaml", "compose.prod.yaml"}
+	if err := req.ValidateComposeFiles(); err == nil {
+		t.Error("expected error for duplicate override")
+	}
+
+	req = DeployRequest{ComposeFiles: map[string]string{"../escape.yaml": ""}}
+	if err := req.ValidateComposeFiles(); err == nil {
+		t.Error("expected error for unsafe filename")
+	}
+}
+
+func TestWriteStackComposeFilesAndManifest(t *testing.T) {
+	stacks := t.TempDir()
+	if _, err := WriteStackComposeFile(stacks, "p", "include:\n  - db/compose.yaml\n"); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"db/compose.yaml":   "services:\n  db:\n    image: postgres\n",
+		"compose.prod.yaml": "services:\n  db:\n    restart: always\n",
+	}
+	if err := WriteStackComposeFiles(stacks, "p", files, []string{"compose.prod.yaml"}); err != nil {
+		t.Fatalf("WriteStackComposeFiles: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(stacks, "p", "db", "compose.yaml"))
+	if err != nil || string(got) != files["db/compose.yaml"] {
+		t.Errorf("db/compose.yaml = %q, %v", got, err)
+	}
+
+	manifest, err := readComposeManifest(filepath.Join(stacks, "p"))
+	if err != nil {
+		t.Fatalf("readComposeManifest: %v", err)
+	}
+	if len(manifest.Files) != 2 || len(manifest.Overrides) != 1 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// A later single-file deployment drops the manifest
+	if err := WriteStackComposeFiles(stacks, "p", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(stacks, "p", composeManifestName)); !os.IsNotExist(err) {
+		t.Errorf("manifest still present after single-file deployment: %v", err)
+	}
+}
+
+func TestWriteStackComposeFilesRejectsSymlinkedDir(t *testing.T) {
+	stacks := t.TempDir()
+	if _, err := WriteStackComposeFile(stacks, "p", "services: {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(stacks, "p", "db")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	err := WriteStackComposeFiles(stacks, "p", map[string]string{"db/compose.yaml": "services: {}\n"}, nil)
+	if err == nil {
+		t.Fatal("expected error writing through a symlinked directory")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "compose.yaml")); !os.IsNotExist(err) {
+		t.Error("compose file was written outside the stack dir")
+	}
+}
+
+func TestSnapshotCapturesComposeFilesForRollback(t *testing.T) {
+	stacks := t.TempDir()
+	if _, err := WriteStackComposeFile(stacks, "p", "services: {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"compose.prod.yaml": "services: {}\n", "db/compose.yaml": "services: {}\n"}
+	if err := WriteStackComposeFiles(stacks, "p", files, []string{"compose.prod.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rev, err := snapshotStackFiles(stacks, "p", nil)
+	if err != nil {
+		t.Fatalf("snapshotStackFiles: %v", err)
+	}
+	if len(rev.ComposeFiles) != 2 || len(rev.ComposeOverrides) != 1 || rev.ComposeOverrides[0] != "compose.prod.yaml" {
+		t.Fatalf("revision compose files = %v, overrides = %v", rev.ComposeFiles, rev.ComposeOverrides)
+	}
+
+	req := RollbackRequest(rev, DeployRequest{})
+	if len(req.ComposeFiles) != 2 || len(req.ComposeOverrides) != 1 {
+		t.Errorf("rollback request compose files = %v, overrides = %v", req.ComposeFiles, req.ComposeOverrides)
+	}
+}
+
+func TestLoadProjectIncludeAndOverride(t *testing.T) {
+	stacks := t.TempDir()
+	main, err := WriteStackComposeFile(stacks, "p", "include:\n  - db/compose.yaml\nservices:\n  web:\n    image: nginx\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"db/compose.yaml":   "services:\n  db:\n    image: postgres:16\n",
+		"compose.prod.yaml": "services:\n  web:\n    image: nginx:1.27\n",
+	}
+	if err := WriteStackComposeFiles(stacks, "p", files, []string{"compose.prod.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := newTestService().loadProject(context.Background(),
+		[]string{main, filepath.Join(stacks, "p", "compose.prod.yaml")}, "p", nil, "", nil)
+	if err != nil {
+		t.Fatalf("loadProject: %v", err)
+	}
+	if _, ok := project.Services["db"]; !ok {
+		t.Error("included service db missing")
+	}
+	if got := project.Services["web"].Image; got != "nginx:1.27" {
+		t.Errorf("web image = %q, want override nginx:1.27", got)
+	}
+}
+
+func TestLoadProjectVariableSet(t *testing.T) {
+	stacks := t.TempDir()
+	main, err := WriteStackComposeFile(stacks, "p", "services:\n  db:\n    image: postgres:${PG_VERSION}\n    environment:\n      POSTGRES_PASSWORD: ${DB_PASSWORD}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteStackEnvFile(stacks, "p", "PG_VERSION=17\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stack's .env wins over the variable set
+	project, err := newTestService().loadProject(context.Background(), []string{main}, "p", nil, "",
+		map[string]string{"PG_VERSION": "16", "DB_PASSWORD": "hunter2"})
+	if err != nil {
+		t.Fatalf("loadProject: %v", err)
+	}
+	db := project.Services["db"]
+	if db.Image != "postgres:17" {
+		t.Errorf("image = %q, want .env's postgres:17", db.Image)
+	}
+	if got := db.Environment["POSTGRES_PASSWORD"]; got == nil || *got != "hunter2" {
+		t.Errorf("POSTGRES_PASSWORD = %v, want the variable set's", got)
+	}
+}
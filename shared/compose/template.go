@@ -0,0 +1,347 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/sirupsen/logrus"
+)
+
+// =============================================================================
+// Compose Templates
+// =============================================================================
+//
+// One stack definition can serve hosts that differ in architecture, address
+// or role by rendering its compose files as Go templates before they are
+// parsed. Templates see the request's values and facts about the target
+// Docker host:
+//
+//	image: myapp:{{ .Host.Arch }}
+//	environment:
+//	  PUBLIC_URL: http://{{ .Host.IP }}:{{ .Values.port | default 8080 }}
+//	{{- if eq .Values.tier "prod" }}
+//	  LOG_LEVEL: warn
+//	{{- end }}
+//
+// Rendering is opt-in (DeployRequest.Template) because compose files often
+// hold template syntax meant for other tools, such as Traefik labels or
+// `docker inspect --format` health checks; such stacks can change the
+// delimiters. Templates can't read files or the environment: besides the
+// text/template builtins, only the string helpers in templateFuncs exist.
+
+const (
+	// MaxTemplateOutput bounds one rendered compose file, so a template
+	// can't loop its way to an unbounded file
+	MaxTemplateOutput = 4 << 20
+
+	// hostFactsTimeout bounds the Docker info query for .Host
+	hostFactsTimeout = 10 * time.Second
+
+	// missingValue is what text/template prints for unset values
+	missingValue = "<no value>"
+)
+
+var errTemplateTooLarge = fmt.Errorf("rendered file exceeds %d bytes", MaxTemplateOutput)
+
+// TemplateOptions enables rendering the compose files as templates
+// (DeployRequest.Template)
+type TemplateOptions struct {
+	// Values are the request's variables, as .Values in templates
+	Values map[string]interface{} `json:"values,omitempty"`
+	// Strict fails on references to unset values instead of rendering them
+	// empty
+	Strict bool `json:"strict,omitempty"`
+	// Delimiters replaces "{{" and "}}", e.g. ["[[", "]]"]
+	Delimiters []string `json:"delimiters,omitempty"`
+	// HostIP is the target host's address as .Host.IP. Without it the
+	// address comes from DockerHost, or from swarm, and is empty for the
+	// local socket. Multi-target requests set DeployTarget.HostIP instead.
+	HostIP string `json:"host_ip,omitempty"`
+}
+
+// Validate checks the delimiters and host IP.
+func (o *TemplateOptions) Validate() error {
+	if len(o.Delimiters) != 0 && (len(o.Delimiters) != 2 || o.Delimiters[0] == "" || o.Delimiters[1] == "") {
+		return errors.New("template delimiters must be a left and a right delimiter")
+	}
+	if o.HostIP != "" && net.ParseIP(o.HostIP) == nil {
+		return fmt.Errorf("invalid template host_ip %q", o.HostIP)
+	}
+	return nil
+}
+
+// TemplateHost describes the target Docker host, as .Host in templates
+type TemplateHost struct {
+	Hostname      string            `json:"hostname"`
+	IP            string            `json:"ip,omitempty"`
+	OS            string            `json:"os"`   // "linux" or "windows"
+	Arch          string            `json:"arch"` // Image platform name: "amd64", "arm64", "arm", ...
+	CPUs          int               `json:"cpus"`
+	MemoryBytes   int64             `json:"memory_bytes"`
+	DockerVersion string            `json:"docker_version"`
+	Labels        map[string]string `json:"labels,omitempty"` // Docker daemon labels
+}
+
+// TemplateData is what compose templates are executed with
+type TemplateData struct {
+	Project string
+	Values  map[string]interface{}
+	Host    TemplateHost
+}
+
+// templateFuncs are the functions templates get besides the builtins.
+// Argument order suits pipelines: {{ .Values.name | default "app" | quote }}.
+var templateFuncs = template.FuncMap{
+	"default": func(def, value interface{}) interface{} {
+		if isEmptyValue(value) {
+			return def
+		}
+		return value
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if isEmptyValue(value) {
+			return nil, errors.New(message)
+		}
+		return value, nil
+	},
+	// quote writes a double-quoted YAML string, escaping as needed
+	"quote": func(value interface{}) string {
+		out, _ := json.Marshal(fmt.Sprint(value))
+		return string(out)
+	},
+	"lower":     strings.ToLower,
+	"upper":     strings.ToUpper,
+	"trim":      strings.TrimSpace,
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":     func(sep, s string) []string { return strings.Split(s, sep) },
+	"join": func(sep string, list interface{}) (string, error) {
+		v := reflect.ValueOf(list)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return "", fmt.Errorf("join: expected a list, got %T", list)
+		}
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(parts, sep), nil
+	},
+}
+
+// isEmptyValue reports whether value is unset, or the zero value of its type
+func isEmptyValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// parseTemplate parses one compose file as a template
+func parseTemplate(name, text string, opts TemplateOptions) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(templateFuncs)
+	if len(opts.Delimiters) == 2 {
+		tmpl = tmpl.Delims(opts.Delimiters[0], opts.Delimiters[1])
+	}
+	if opts.Strict {
+		tmpl = tmpl.Option("missingkey=error")
+	} else {
+		tmpl = tmpl.Option("missingkey=zero")
+	}
+	return tmpl.Parse(text)
+}
+
+// RenderTemplate renders one compose file with data. Unless opts.Strict is
+// set, unset values render empty.
+func RenderTemplate(name, text string, opts TemplateOptions, data TemplateData) (string, error) {
+	tmpl, err := parseTemplate(name, text, opts)
+	if err != nil {
+		return "", err
+	}
+	out := &limitedBuffer{limit: MaxTemplateOutput}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+	rendered := out.String()
+	if !opts.Strict {
+		rendered = strings.ReplaceAll(rendered, missingValue, "")
+	}
+	return rendered, nil
+}
+
+// limitedBuffer fails writes past limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errTemplateTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// ValidateTemplate checks the template options and that every compose file
+// parses as a template, so syntax errors are caught before a deployment is
+// queued. Nothing is rendered.
+func (r DeployRequest) ValidateTemplate() error {
+	if r.Template == nil {
+		return nil
+	}
+	if err := r.Template.Validate(); err != nil {
+		return err
+	}
+	for _, t := range r.Targets {
+		if t.HostIP != "" && net.ParseIP(t.HostIP) == nil {
+			return fmt.Errorf("target %s: invalid host_ip %q", t.Name, t.HostIP)
+		}
+	}
+	if _, err := parseTemplate("docker-compose.yml", r.ComposeYAML, *r.Template); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(r.ComposeFiles) {
+		if _, err := parseTemplate(name, r.ComposeFiles[name], *r.Template); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderTemplates replaces the request's compose files with their rendered
+// form, using the facts of the service's Docker host
+func (s *Service) renderTemplates(ctx context.Context, req *DeployRequest) error {
+	if err := req.Template.Validate(); err != nil {
+		return err
+	}
+	host, err := s.hostFacts(ctx, req)
+	if err != nil {
+		return err
+	}
+	data := TemplateData{
+		Project: req.ProjectName,
+		Values:  req.Template.Values,
+		Host:    host,
+	}
+	if data.Values == nil {
+		data.Values = map[string]interface{}{}
+	}
+
+	composeYAML, err := RenderTemplate("docker-compose.yml", req.ComposeYAML, *req.Template, data)
+	if err != nil {
+		return err
+	}
+	var files map[string]string
+	if len(req.ComposeFiles) > 0 {
+		files = make(map[string]string, len(req.ComposeFiles))
+		for _, name := range sortedKeys(req.ComposeFiles) {
+			if files[name], err = RenderTemplate(name, req.ComposeFiles[name], *req.Template, data); err != nil {
+				return err
+			}
+		}
+	}
+	req.ComposeYAML = composeYAML
+	req.ComposeFiles = files
+
+	s.logInfo("Rendered compose templates", logrus.Fields{
+		"project_name": req.ProjectName,
+		"files":        1 + len(files),
+		"host":         host.Hostname,
+		"arch":         host.Arch,
+	})
+	return nil
+}
+
+// hostFacts describes the service's Docker host for templates
+func (s *Service) hostFacts(ctx context.Context, req *DeployRequest) (TemplateHost, error) {
+	host := TemplateHost{IP: req.Template.HostIP}
+	if host.IP == "" {
+		host.IP = dockerHostIP(ctx, req.DockerHost)
+	}
+	if s.dockerClient == nil {
+		return host, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hostFactsTimeout)
+	defer cancel()
+	info, err := s.dockerClient.Info(ctx)
+	if err != nil {
+		return host, fmt.Errorf("failed to query Docker host: %w", err)
+	}
+	host.Hostname = info.Name
+	host.OS = info.OSType
+	host.Arch = registry.NormalizeArch(info.Architecture)
+	host.CPUs = info.NCPU
+	host.MemoryBytes = info.MemTotal
+	host.DockerVersion = info.ServerVersion
+	if len(info.Labels) > 0 {
+		host.Labels = make(map[string]string, len(info.Labels))
+		for _, label := range info.Labels {
+			key, value, _ := strings.Cut(label, "=")
+			host.Labels[key] = value
+		}
+	}
+	if host.IP == "" {
+		host.IP = info.Swarm.NodeAddr
+	}
+	return host, nil
+}
+
+// dockerHostIP returns the address of a remote Docker host ("tcp://...",
+// "ssh://..."), resolving host names. Empty for the local socket or when the
+// name doesn't resolve.
+func dockerHostIP(ctx context.Context, dockerHost string) string {
+	if dockerHost == "" {
+		return ""
+	}
+	u, err := url.Parse(dockerHost)
+	if err != nil || u.Scheme == "unix" || u.Scheme == "npipe" {
+		return ""
+	}
+	name := u.Hostname()
+	if name == "" || net.ParseIP(name) != nil {
+		return name
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hostFactsTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	// Prefer IPv4, like the addresses agents report
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			return addr
+		}
+	}
+	return addrs[0]
+}
+
+// sortedKeys returns a map's keys in order, so errors are reported for the
+// same file every time
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
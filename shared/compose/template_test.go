@@ -0,0 +1,151 @@
+package compose
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	data := TemplateData{
+		Project: "web",
+		Values:  map[string]interface{}{"tier": "prod", "port": float64(8443), "tags": []interface{}{"a", "b"}},
+		Host:    TemplateHost{Hostname: "pi", IP: "10.0.0.5", Arch: "arm64"},
+	}
+	text := `services:
+  {{ .Project }}:
+    image: app:{{ .Host.Arch }}
+    environment:
+      URL: {{ printf "http://%s:%v" .Host.IP .Values.port | quote }}
+      NAME: {{ .Values.name | default "app" | upper }}
+      EMPTY: "{{ .Values.missing }}"
+      TAGS: {{ join "," .Values.tags }}
+{{- if eq .Values.tier "prod" }}
+    restart: always
+{{- end }}
+`
+	got, err := RenderTemplate("docker-compose.yml", text, TemplateOptions{}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"  web:\n",
+		"image: app:arm64\n",
+		`URL: "http://10.0.0.5:8443"`,
+		"NAME: APP\n",
+		`EMPTY: ""`,
+		"TAGS: a,b\n",
+		"TAGS: a,b\n    restart: always\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered file lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderTemplateStrictAndRequired(t *testing.T) {
+	data := TemplateData{Values: map[string]interface{}{}}
+	if _, err := RenderTemplate("f", "x: {{ .Values.missing }}", TemplateOptions{Strict: true}, data); err == nil {
+		t.Error("strict mode rendered an unset value")
+	}
+	_, err := RenderTemplate("f", `x: {{ .Values.domain | required "domain is required" }}`, TemplateOptions{}, data)
+	if err == nil || !strings.Contains(err.Error(), "domain is required") {
+		t.Errorf("required: err = %v", err)
+	}
+}
+
+func TestRenderTemplateDelimitersAndLimit(t *testing.T) {
+	data := TemplateData{Values: map[string]interface{}{"v": "1"}}
+	text := `test: ["CMD", "docker", "inspect", "--format", "{{.State.Health}}"]` + "\nversion: [[ .Values.v ]]"
+	got, err := RenderTemplate("f", text, TemplateOptions{Delimiters: []string{"[[", "]]"}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "{{.State.Health}}") || !strings.Contains(got, "version: 1") {
+		t.Errorf("rendered = %q", got)
+	}
+
+	_, err = RenderTemplate("f", `{{ range .Values.many }}{{ range $.Values.many }}xxxxxxxxxxxxxxxxxxxx{{ end }}{{ end }}`, TemplateOptions{},
+		TemplateData{Values: map[string]interface{}{"many": make([]int, 1000)}})
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("oversized output: err = %v", err)
+	}
+}
+
+func TestDeployRequestValidateTemplate(t *testing.T) {
+	req := DeployRequest{
+		ComposeYAML:  "services: {}",
+		ComposeFiles: map[string]string{"db.yaml": "image: {{ .Values.db "},
+	}
+	if err := req.ValidateTemplate(); err != nil {
+		t.Errorf("untemplated request: %v", err)
+	}
+	req.Template = &TemplateOptions{}
+	if err := req.ValidateTemplate(); err == nil || !strings.Contains(err.Error(), "db.yaml") {
+		t.Errorf("syntax error: err = %v", err)
+	}
+	req.ComposeFiles = nil
+	for _, opts := range []TemplateOptions{
+		{Delimiters: []string{"[["}},
+		{HostIP: "not-an-ip"},
+	} {
+		req.Template = &opts
+		if err := req.ValidateTemplate(); err == nil {
+			t.Errorf("ValidateTemplate accepted %+v", opts)
+		}
+	}
+}
+
+func TestDeployRequestForTargetTemplate(t *testing.T) {
+	req := DeployRequest{Template: &TemplateOptions{HostIP: "10.0.0.1", Values: map[string]interface{}{"a": 1}}}
+	got := req.ForTarget(DeployTarget{Name: "b", DockerHost: "tcp://10.0.0.2:2376", HostIP: "10.0.0.2"})
+	if got.Template.HostIP != "10.0.0.2" || got.Template.Values["a"] != 1 {
+		t.Errorf("target template = %+v", got.Template)
+	}
+	if req.Template.HostIP != "10.0.0.1" {
+		t.Error("ForTarget changed the request's template options")
+	}
+	if got := req.ForTarget(DeployTarget{Name: "c"}); got.Template.HostIP != "" {
+		t.Errorf("target without host_ip got %q", got.Template.HostIP)
+	}
+}
+
+func TestRenderTemplatesHostFacts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/info") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"Name":"node1","OSType":"linux","Architecture":"aarch64","NCPU":4,"MemTotal":1024,"ServerVersion":"27.1.0","Labels":["gpu=true"]}`))
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	s := newTestService()
+	s.dockerClient = cli
+	req := DeployRequest{
+		ProjectName:  "app",
+		DockerHost:   "tcp://192.168.1.20:2376",
+		ComposeYAML:  "# {{ .Host.Hostname }} {{ .Host.Arch }} {{ .Host.IP }} {{ .Host.CPUs }} {{ .Host.Labels.gpu }}",
+		ComposeFiles: map[string]string{"extra.yaml": "# {{ .Values.v }}"},
+		Template:     &TemplateOptions{Values: map[string]interface{}{"v": "x"}},
+	}
+	if err := s.renderTemplates(context.Background(), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ComposeYAML != "# node1 arm64 192.168.1.20 4 true" {
+		t.Errorf("ComposeYAML = %q", req.ComposeYAML)
+	}
+	if req.ComposeFiles["extra.yaml"] != "# x" {
+		t.Errorf("extra.yaml = %q", req.ComposeFiles["extra.yaml"])
+	}
+}
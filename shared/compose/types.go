@@ -2,6 +2,16 @@
 // shared between the compose-service and agent.
 package compose
 
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/progress"
+)
+
 // DeployRequest is sent from the caller (Python backend or agent) to execute a compose deployment
 type DeployRequest struct {
 	// Deployment identification
@@ -17,6 +27,25 @@ type DeployRequest struct {
 	// precedence over EnvFileContent when non-empty.
 	EnvFiles map[string]string `json:"env_files,omitempty"`
 	Profiles []string          `json:"profiles,omitempty"`
+	// ComposeFiles holds additional compose files by path relative to the
+	// stack dir (e.g. "compose.prod.yaml", "db/compose.yaml"). They are written
+	// next to docker-compose.yml so include: entries resolve.
+	ComposeFiles map[string]string `json:"compose_files,omitempty"`
+	// ComposeOverrides lists ComposeFiles entries merged over ComposeYAML in
+	// order, like repeated `docker compose -f`. Files not listed are only
+	// reachable through include:.
+	ComposeOverrides []string `json:"compose_overrides,omitempty"`
+	// Secrets maps a compose secret name to its content. For "up" each is
+	// written to an in-memory 0600 file, declared as a file secret, and
+	// shredded once the services have started, so secrets never sit on the
+	// host as static files. Local Docker engines only.
+	Secrets map[string]string `json:"secrets,omitempty"`
+	// EnvSet names a variable set kept by the compose service, so shared
+	// credentials needn't be sent with every deploy. Its variables are
+	// merged under the stack's .env for interpolation (.env wins). The
+	// compose service resolves it into EnvSetVars; other callers can't.
+	EnvSet     string            `json:"env_set,omitempty"`
+	EnvSetVars map[string]string `json:"-"`
 
 	// Action
 	Action        string `json:"action"`                   // "up", "down", "restart"
@@ -26,6 +55,31 @@ type DeployRequest struct {
 	ForceRecreate bool `json:"force_recreate,omitempty"` // Force recreate containers even if unchanged
 	PullImages    bool `json:"pull_images,omitempty"`    // Pull images before starting
 
+	// Revisions (for "up" action). The stack's previous state is saved as a
+	// revision before each up. 0 keeps DefaultKeepRevisions; negative disables.
+	KeepRevisions int `json:"keep_revisions,omitempty"`
+	// ImageOverrides pins service images (service name -> image reference),
+	// e.g. to the exact digests recorded in a revision when rolling back.
+	ImageOverrides map[string]string `json:"image_overrides,omitempty"`
+
+	// BindPaths checks (and optionally creates) bind mount sources on the
+	// Docker host before "up". Nil skips the check.
+	BindPaths *BindPathOptions `json:"bind_paths,omitempty"`
+
+	// ExternalResources controls the pre-deploy check that external networks,
+	// volumes and configs exist on the Docker host. The check always runs for
+	// "up"; this only enables creating missing networks.
+	ExternalResources *ExternalResourceOptions `json:"external_resources,omitempty"`
+
+	// Quota limits the stack's projected memory reservation and container
+	// count on the Docker host before "up". Nil skips the check.
+	Quota *HostQuota `json:"quota,omitempty"`
+
+	// Template renders ComposeYAML and ComposeFiles as Go templates, with
+	// the given values and the target host's facts, before they are parsed.
+	// Nil deploys them as they are.
+	Template *TemplateOptions `json:"template,omitempty"`
+
 	// Health check options
 	WaitForHealthy bool `json:"wait_for_healthy,omitempty"`
 	HealthTimeout  int  `json:"health_timeout,omitempty"` // seconds, default 60
@@ -52,9 +106,149 @@ type DeployRequest struct {
 	TLSCACert  string `json:"tls_ca_cert,omitempty"` // PEM content
 	TLSCert    string `json:"tls_cert,omitempty"`    // PEM content
 	TLSKey     string `json:"tls_key,omitempty"`     // PEM content
+	// TLS files or a Docker context instead of the fields above; see
+	// ResolveHostSources
+	sharedDocker.HostSource
 
 	// Registry authentication
 	RegistryCredentials []RegistryCredential `json:"registry_credentials,omitempty"`
+
+	// Multi-target mode: deploy the same project to every target in parallel.
+	// When set, DockerHost and TLS fields above are ignored.
+	Targets []DeployTarget `json:"targets,omitempty"`
+}
+
+// DeployTarget is one Docker host of a multi-target deployment.
+// Empty DockerHost means the local socket.
+type DeployTarget struct {
+	Name       string `json:"name"` // Caller-chosen ID (e.g. host ID), unique per request
+	DockerHost string `json:"docker_host,omitempty"`
+	TLSCACert  string `json:"tls_ca_cert,omitempty"`
+	TLSCert    string `json:"tls_cert,omitempty"`
+	TLSKey     string `json:"tls_key,omitempty"`
+	sharedDocker.HostSource
+	// Quota overrides DeployRequest.Quota for this host
+	Quota *HostQuota `json:"quota,omitempty"`
+	// HostIP is the host's address in compose templates; see
+	// TemplateOptions.HostIP
+	HostIP string `json:"host_ip,omitempty"`
+}
+
+// targetsDirName holds per-target stack dirs of multi-target deployments
+const targetsDirName = ".targets"
+
+// ForTarget returns a single-host copy of a multi-target request. Remote
+// targets get their own stack directory ($STACKS_DIR/.targets/<name>) so
+// parallel deployments never write the same files; the local target keeps
+// the regular stack directory so host bind-mount resolution still applies.
+func (r DeployRequest) ForTarget(t DeployTarget) DeployRequest {
+	req := r
+	req.Targets = nil
+	req.DockerHost = t.DockerHost
+	req.TLSCACert = t.TLSCACert
+	req.TLSCert = t.TLSCert
+	req.TLSKey = t.TLSKey
+	if t.Quota != nil {
+		req.Quota = t.Quota
+	}
+	if req.Template != nil {
+		tmpl := *req.Template
+		tmpl.HostIP = t.HostIP
+		req.Template = &tmpl
+	}
+
+	if t.DockerHost != "" {
+		stacksDir := r.StacksDir
+		if stacksDir == "" {
+			stacksDir = defaultStacksDir
+		}
+		req.StacksDir = filepath.Join(stacksDir, targetsDirName, t.Name)
+	}
+	return req
+}
+
+// agentHostScheme is the placeholder URL scheme of agent-connected hosts
+const agentHostScheme = "agent://"
+
+// ValidateTargets checks multi-target names are safe and unique, that every
+// target is a Docker host and that no Docker host is targeted twice.
+func (r DeployRequest) ValidateTargets() error {
+	names := make(map[string]bool, len(r.Targets))
+	hosts := make(map[string]bool, len(r.Targets))
+	for i, t := range r.Targets {
+		if err := ValidateStackName(t.Name); err != nil {
+			return fmt.Errorf("target %d: invalid name: %w", i, err)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate target name: %s", t.Name)
+		}
+		// Agent hosts deploy through their own agent, not a Docker client
+		if strings.HasPrefix(t.DockerHost, agentHostScheme) {
+			return fmt.Errorf("target %s: agent hosts are not supported in multi-target deployments; deploy to them individually", t.Name)
+		}
+		if hosts[t.DockerHost] {
+			return fmt.Errorf("target %s: docker host targeted more than once", t.Name)
+		}
+		names[t.Name] = true
+		hosts[t.DockerHost] = true
+	}
+	return nil
+}
+
+// ResolveHostSources replaces the TLS file and Docker context references of
+// the request and its targets with the inline host and PEM fields they
+// stand for, then clears them. Call it once, before ValidateTargets.
+func (r *DeployRequest) ResolveHostSources() error {
+	if err := r.HostSource.Resolve(&r.DockerHost, &r.TLSCACert, &r.TLSCert, &r.TLSKey); err != nil {
+		return err
+	}
+	r.HostSource = sharedDocker.HostSource{}
+	for i := range r.Targets {
+		t := &r.Targets[i]
+		if err := t.HostSource.Resolve(&t.DockerHost, &t.TLSCACert, &t.TLSCert, &t.TLSKey); err != nil {
+			return fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		t.HostSource = sharedDocker.HostSource{}
+	}
+	return nil
+}
+
+// ValidateQuotas checks the request's and every target's host quota.
+func (r DeployRequest) ValidateQuotas() error {
+	if r.Quota != nil {
+		if err := r.Quota.Validate(); err != nil {
+			return fmt.Errorf("quota: %w", err)
+		}
+	}
+	for _, t := range r.Targets {
+		if t.Quota != nil {
+			if err := t.Quota.Validate(); err != nil {
+				return fmt.Errorf("target %s: quota: %w", t.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateComposeFiles checks extra compose file paths are safe to write into
+// the stack dir and that every override names one of them.
+func (r DeployRequest) ValidateComposeFiles() error {
+	for name := range r.ComposeFiles {
+		if !SafeComposeFilename(name) {
+			return fmt.Errorf("unsafe compose filename: %q", name)
+		}
+	}
+	seen := make(map[string]bool, len(r.ComposeOverrides))
+	for _, name := range r.ComposeOverrides {
+		if _, ok := r.ComposeFiles[name]; !ok {
+			return fmt.Errorf("compose override %q is not in compose_files", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("compose override %q listed more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
 }
 
 // RegistryCredential holds credentials for a Docker registry.
@@ -74,6 +268,55 @@ type DeployResult struct {
 	Services       map[string]ServiceResult `json:"services,omitempty"`
 	FailedServices []string                 `json:"failed_services,omitempty"`
 	Error          *ComposeError            `json:"error,omitempty"`
+	// BindPaths holds the pre-deploy bind mount check (if requested)
+	BindPaths []BindPathResult `json:"bind_paths,omitempty"`
+	// ExternalResources holds the pre-deploy external resource check
+	ExternalResources []ExternalResourceResult `json:"external_resources,omitempty"`
+	// Quota holds the pre-deploy host quota check (if requested)
+	Quota *QuotaResult `json:"quota,omitempty"`
+	// Cancelled is set when the caller aborted the deployment; Cleanup
+	// reports what was removed afterwards
+	Cancelled bool           `json:"cancelled,omitempty"`
+	Cleanup   *CancelCleanup `json:"cleanup,omitempty"`
+}
+
+// MultiDeployResult combines the per-target results of a multi-target
+// deployment. Success means every target succeeded; PartialSuccess means at
+// least one did.
+type MultiDeployResult struct {
+	DeploymentID   string                   `json:"deployment_id"`
+	Action         string                   `json:"action"`
+	Success        bool                     `json:"success"`
+	PartialSuccess bool                     `json:"partial_success,omitempty"`
+	Results        map[string]*DeployResult `json:"results"` // key: target name
+	FailedTargets  []string                 `json:"failed_targets,omitempty"`
+	Cancelled      bool                     `json:"cancelled,omitempty"` // Any target was cancelled
+}
+
+// NewMultiDeployResult combines per-target results.
+func NewMultiDeployResult(deploymentID, action string, results map[string]*DeployResult) *MultiDeployResult {
+	multi := &MultiDeployResult{
+		DeploymentID: deploymentID,
+		Action:       action,
+		Results:      results,
+	}
+	for name, result := range results {
+		if result.Success || result.PartialSuccess {
+			multi.PartialSuccess = true
+		}
+		if !result.Success {
+			multi.FailedTargets = append(multi.FailedTargets, name)
+		}
+		if result.Cancelled {
+			multi.Cancelled = true
+		}
+	}
+	sort.Strings(multi.FailedTargets)
+	multi.Success = len(results) > 0 && len(multi.FailedTargets) == 0
+	if multi.Success {
+		multi.PartialSuccess = false
+	}
+	return multi
 }
 
 // ServiceResult contains info about a deployed service
@@ -88,31 +331,41 @@ type ServiceResult struct {
 	RestartPolicy string `json:"restart_policy,omitempty"`
 	// ExitCode from the container (only set for exited containers)
 	ExitCode int `json:"exit_code,omitempty"`
+
+	// Details gathered by inspecting the container, so callers can record
+	// the deployment without inspecting it again
+	ContainerIDs []string `json:"container_ids,omitempty"` // SHORT IDs of every replica
+	Health       string   `json:"health,omitempty"`        // healthy, unhealthy or starting; empty without a healthcheck
+	Ports        []string `json:"ports,omitempty"`         // Published ports, e.g. "8080:80/tcp"
+	ImageID      string   `json:"image_id,omitempty"`
+	ImageDigest  string   `json:"image_digest,omitempty"` // Repo digest (sha256:...), empty for local builds
+	StartedAt    string   `json:"started_at,omitempty"`   // RFC 3339, empty if never started
 }
 
 // ProgressStage represents the current stage of deployment
-type ProgressStage string
+type ProgressStage = progress.Stage
 
 const (
-	StageValidating     ProgressStage = "validating"       // 5%
-	StageParsing        ProgressStage = "parsing"          // 10%
-	StageCreatingNets   ProgressStage = "creating_networks" // 15%
-	StageCreatingVols   ProgressStage = "creating_volumes" // 20%
-	StagePullingImage   ProgressStage = "pulling_image"    // 25-60% (per-service)
-	StageCreating       ProgressStage = "creating"         // 60-80% (per-service)
-	StageStarting       ProgressStage = "starting"         // 80-90% (per-service)
-	StageHealthCheck    ProgressStage = "health_check"     // 90-95%
-	StageCompleted      ProgressStage = "completed"        // 100%
-	StageFailed         ProgressStage = "failed"           // 100%
+	StageValidating   = progress.StageValidating       // 5%
+	StageParsing      = progress.StageParsing          // 10%
+	StageCreatingNets = progress.StageCreatingNetworks // 15%
+	StageCreatingVols = progress.StageCreatingVolumes  // 20%
+	StagePullingImage = progress.StagePullingImage     // 25-60% (per-service)
+	StageCreating     = progress.StageCreating         // 60-80% (per-service)
+	StageStarting     = progress.StageStarting         // 80-90% (per-service)
+	StageStopping     = progress.StageStopping         // Bulk project stops
+	StageHealthCheck  = progress.StageHealthCheck      // 90-95%
+	StageCompleted    = progress.StageCompleted        // 100%
+	StageFailed       = progress.StageFailed           // 100%
 )
 
 // Legacy stage constants for backward compatibility with agent
 const (
-	DeployStageStarting      = "starting"
-	DeployStageExecuting     = "executing"
-	DeployStageWaitingHealth = "waiting_for_health"
-	DeployStageCompleted     = "completed"
-	DeployStageFailed        = "failed"
+	DeployStageStarting      = string(progress.StageStarting)
+	DeployStageExecuting     = string(progress.StageExecuting)
+	DeployStageWaitingHealth = string(progress.StageWaitingHealth)
+	DeployStageCompleted     = string(progress.StageCompleted)
+	DeployStageFailed        = string(progress.StageFailed)
 )
 
 // ProgressEvent represents a progress update during deployment
@@ -123,6 +376,8 @@ type ProgressEvent struct {
 	Service    string        `json:"service,omitempty"`     // Current service (for per-service stages)
 	ServiceIdx int           `json:"service_idx,omitempty"` // 1-based index
 	TotalSvcs  int           `json:"total_services,omitempty"`
+	Target     string        `json:"target,omitempty"`  // Target name (multi-target deployments only)
+	Project    string        `json:"project,omitempty"` // Project name (bulk operations only)
 
 	// Layer-level pull progress (matches existing ImagePullProgress format)
 	Layers         []LayerProgress `json:"layers,omitempty"`           // Per-layer status
@@ -131,15 +386,30 @@ type ProgressEvent struct {
 	OverallPercent int             `json:"overall_progress,omitempty"` // Bytes-based overall %
 }
 
-// LayerProgress tracks download progress for a single image layer
-type LayerProgress struct {
-	ID      string `json:"id"`      // Layer short ID
-	Status  string `json:"status"`  // Downloading, Extracting, Pull complete, Already exists
-	Current int64  `json:"current"` // Bytes downloaded
-	Total   int64  `json:"total"`   // Total bytes
-	Percent int    `json:"percent"` // 0-100
+// ToProgress converts the event to the shared progress schema. Failure
+// messages are reported as the error.
+func (e ProgressEvent) ToProgress(deploymentID string) progress.Event {
+	event := progress.New(progress.OperationDeploy, e.Stage, e.Message)
+	event.DeploymentID = deploymentID
+	event.Percent = e.Progress
+	event.StagePercent = e.OverallPercent
+	event.Target = e.Target
+	event.Project = e.Project
+	event.Service = e.Service
+	event.ServiceIndex = e.ServiceIdx
+	event.ServiceCount = e.TotalSvcs
+	event.SpeedMbps = e.SpeedMbps
+	event.Layers = e.Layers
+	event.TotalLayers = e.TotalLayers
+	if e.Stage == StageFailed {
+		event.Error = e.Message
+	}
+	return event
 }
 
+// LayerProgress tracks download progress for a single image layer
+type LayerProgress = progress.Layer
+
 // ProgressCallback is called during deployment to report progress
 type ProgressCallback func(event ProgressEvent)
 
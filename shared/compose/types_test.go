@@ -0,0 +1,182 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/progress"
+)
+
+func TestDeployRequestForTarget(t *testing.T) {
+	req := DeployRequest{
+		DeploymentID: "d1",
+		ProjectName:  "monitoring",
+		DockerHost:   "tcp://ignored:2376",
+		Targets:      []DeployTarget{{Name: "a"}, {Name: "b"}},
+	}
+
+	req.StacksDir = "/data/stacks"
+
+	got := req.ForTarget(DeployTarget{Name: "b", DockerHost: "tcp://b:2376", TLSCert: "cert"})
+
+	if got.DockerHost != "tcp://b:2376" || got.TLSCert != "cert" {
+		t.Errorf("connection not taken from target: %+v", got)
+	}
+	if got.StacksDir != "/data/stacks/.targets/b" {
+		t.Errorf("remote target StacksDir = %q, want per-target dir", got.StacksDir)
+	}
+	if local := req.ForTarget(DeployTarget{Name: "local"}); local.StacksDir != "/data/stacks" || local.DockerHost != "" {
+		t.Errorf("local target = %q / %q, want regular stacks dir and local socket", local.StacksDir, local.DockerHost)
+	}
+	if got.Targets != nil {
+		t.Errorf("Targets = %v, want nil on single-target copy", got.Targets)
+	}
+	if got.ProjectName != "monitoring" || got.DeploymentID != "d1" {
+		t.Errorf("project fields not preserved: %+v", got)
+	}
+	if len(req.Targets) != 2 {
+		t.Error("ForTarget mutated the original request")
+	}
+}
+
+func TestDeployRequestResolveHostSources(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CERT_PATH", dir)
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyFile, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	req := DeployRequest{
+		DockerHost: "tcp://a:2376",
+		HostSource: sharedDocker.HostSource{TLSKeyFile: keyFile},
+		Targets: []DeployTarget{
+			{Name: "b", DockerHost: "tcp://b:2376", HostSource: sharedDocker.HostSource{TLSKeyFile: keyFile}},
+		},
+	}
+	if err := req.ResolveHostSources(); err != nil {
+		t.Fatal(err)
+	}
+	if req.TLSKey != "key" || req.Targets[0].TLSKey != "key" {
+		t.Errorf("keys not loaded: request %q, target %q", req.TLSKey, req.Targets[0].TLSKey)
+	}
+	if !req.HostSource.IsZero() || !req.Targets[0].HostSource.IsZero() {
+		t.Error("references not cleared after resolving")
+	}
+
+	bad := DeployRequest{Targets: []DeployTarget{{Name: "c", HostSource: sharedDocker.HostSource{TLSKeyFile: "relative.pem"}}}}
+	if err := bad.ResolveHostSources(); err == nil || !strings.Contains(err.Error(), "target c") {
+		t.Errorf("err = %v, want the failing target named", err)
+	}
+}
+
+func TestDeployRequestForTargetQuota(t *testing.T) {
+	req := DeployRequest{Quota: &HostQuota{MaxContainers: 10}}
+
+	if got := req.ForTarget(DeployTarget{Name: "a"}); got.Quota == nil || got.Quota.MaxContainers != 10 {
+		t.Errorf("target without quota = %+v, want request quota", got.Quota)
+	}
+	own := &HostQuota{MaxContainers: 3}
+	if got := req.ForTarget(DeployTarget{Name: "b", Quota: own}); got.Quota != own {
+		t.Errorf("target quota = %+v, want the target's own", got.Quota)
+	}
+
+	req.Targets = []DeployTarget{{Name: "c", Quota: &HostQuota{Mode: "maybe"}}}
+	if err := req.ValidateQuotas(); err == nil {
+		t.Error("expected invalid target quota mode to fail validation")
+	}
+}
+
+func TestDeployRequestValidateTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []DeployTarget
+		wantErr bool
+	}{
+		{"valid", []DeployTarget{{Name: "a"}, {Name: "b", DockerHost: "tcp://b:2376"}}, false},
+		{"missing name", []DeployTarget{{DockerHost: "tcp://b:2376"}}, true},
+		{"duplicate name", []DeployTarget{{Name: "a"}, {Name: "a", DockerHost: "tcp://b:2376"}}, true},
+		{"unsafe name", []DeployTarget{{Name: "../a", DockerHost: "tcp://b:2376"}}, true},
+		{"same host twice", []DeployTarget{{Name: "a", DockerHost: "tcp://b:2376"}, {Name: "b", DockerHost: "tcp://b:2376"}}, true},
+		{"two local targets", []DeployTarget{{Name: "a"}, {Name: "b"}}, true},
+		{"agent host", []DeployTarget{{Name: "a"}, {Name: "b", DockerHost: "agent://"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DeployRequest{Targets: tt.targets}.ValidateTargets()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewMultiDeployResult(t *testing.T) {
+	allOK := NewMultiDeployResult("d1", "up", map[string]*DeployResult{
+		"a": {Success: true},
+		"b": {Success: true},
+	})
+	if !allOK.Success || allOK.PartialSuccess || len(allOK.FailedTargets) != 0 {
+		t.Errorf("all succeeded: %+v", allOK)
+	}
+
+	mixed := NewMultiDeployResult("d1", "up", map[string]*DeployResult{
+		"a": {Success: true},
+		"c": {Success: false},
+		"b": {Success: false},
+	})
+	if mixed.Success || !mixed.PartialSuccess {
+		t.Errorf("mixed: success=%v partial=%v", mixed.Success, mixed.PartialSuccess)
+	}
+	if len(mixed.FailedTargets) != 2 || mixed.FailedTargets[0] != "b" || mixed.FailedTargets[1] != "c" {
+		t.Errorf("FailedTargets = %v, want sorted [b c]", mixed.FailedTargets)
+	}
+
+	allFailed := NewMultiDeployResult("d1", "up", map[string]*DeployResult{"a": {Success: false}})
+	if allFailed.Success || allFailed.PartialSuccess {
+		t.Errorf("all failed: %+v", allFailed)
+	}
+	if allFailed.Cancelled || mixed.Cancelled {
+		t.Error("Cancelled set without a cancelled target")
+	}
+
+	cancelled := NewMultiDeployResult("d1", "up", map[string]*DeployResult{
+		"a": {Success: true},
+		"b": {Success: false, Cancelled: true},
+	})
+	if !cancelled.Cancelled || !cancelled.PartialSuccess {
+		t.Errorf("one target cancelled: %+v", cancelled)
+	}
+}
+
+func TestProgressEventToProgress(t *testing.T) {
+	event := ProgressEvent{
+		Stage:          StagePullingImage,
+		Progress:       40,
+		Message:        "Pulling web",
+		Service:        "web",
+		ServiceIdx:     1,
+		TotalSvcs:      2,
+		Target:         "edge",
+		OverallPercent: 75,
+	}.ToProgress("d1")
+
+	if event.Schema != progress.SchemaVersion || event.Operation != progress.OperationDeploy || event.DeploymentID != "d1" {
+		t.Errorf("envelope = %+v", event)
+	}
+	if event.Percent != 40 || event.StagePercent != 75 || event.ServiceIndex != 1 || event.ServiceCount != 2 || event.Target != "edge" {
+		t.Errorf("fields = %+v", event)
+	}
+	if event.Error != "" {
+		t.Errorf("Error = %q for a progress event", event.Error)
+	}
+
+	failed := ProgressEvent{Stage: StageFailed, Message: "pull access denied"}.ToProgress("d1")
+	if failed.Error != "pull access denied" {
+		t.Errorf("failed Error = %q", failed.Error)
+	}
+}
@@ -0,0 +1,103 @@
+// Package cpustat reads per-core CPU times from /proc/stat, so host views
+// can show one core pegged while the average across cores looks low.
+package cpustat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Times are one CPU's cumulative times from a /proc/stat "cpuN" line, in
+// clock ticks
+type Times struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// Total returns all time spent
+func (t Times) Total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// IdleTime returns the time spent idle, iowait included
+func (t Times) IdleTime() uint64 {
+	return t.Idle + t.IOWait
+}
+
+// Percent returns the CPU's busy percentage between two readings, 0 if
+// no time passed or the counters went backwards (CPU hot-unplugged)
+func Percent(prev, cur Times) float64 {
+	if cur.Total() <= prev.Total() || cur.IdleTime() < prev.IdleTime() {
+		return 0
+	}
+	total := cur.Total() - prev.Total()
+	idle := cur.IdleTime() - prev.IdleTime()
+	if idle > total {
+		return 0
+	}
+	return float64(total-idle) / float64(total) * 100
+}
+
+// PerCore returns each core's busy percentage between two readings. Cores
+// are matched by position; nil when the core count changed in between.
+func PerCore(prev, cur []Times) []float64 {
+	if len(prev) == 0 || len(prev) != len(cur) {
+		return nil
+	}
+	result := make([]float64, len(cur))
+	for i := range cur {
+		result[i] = Percent(prev[i], cur[i])
+	}
+	return result
+}
+
+// ReadPerCore reads the per-core lines of a /proc/stat file
+func ReadPerCore(path string) ([]Times, error) {
+	f, err := os.Open(path) // #nosec G304 -- /proc/stat or its host mount
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePerCore(f)
+}
+
+// ParsePerCore parses the "cpuN" lines of /proc/stat content, ordered by
+// core number. The aggregate "cpu" line is skipped.
+func ParsePerCore(r io.Reader) ([]Times, error) {
+	var cores []Times
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0][3:]); err != nil {
+			continue
+		}
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("invalid %s line in /proc/stat: insufficient fields", fields[0])
+		}
+		values := make([]uint64, 8)
+		for i := 1; i < len(fields) && i <= 8; i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q in /proc/stat", fields[0], fields[i])
+			}
+			values[i-1] = v
+		}
+		cores = append(cores, Times{
+			User: values[0], Nice: values[1], System: values[2], Idle: values[3],
+			IOWait: values[4], IRQ: values[5], SoftIRQ: values[6], Steal: values[7],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("no per-core lines in /proc/stat")
+	}
+	return cores, nil
+}
@@ -0,0 +1,52 @@
+package cpustat
+
+import (
+	"strings"
+	"testing"
+)
+
+const procStatBefore = `cpu  400 0 100 1500 0 0 0 0 0 0
+cpu0 100 0 0 900 0 0 0 0 0 0
+cpu1 300 0 100 600 0 0 0 0 0 0
+intr 12345
+ctxt 67890
+`
+
+const procStatAfter = `cpu  1390 0 110 1510 0 0 0 0 0 0
+cpu0 105 0 5 990 0 0 0 0 0 0
+cpu1 1285 0 105 610 0 0 0 0 0 0
+intr 12399
+`
+
+func TestPerCore(t *testing.T) {
+	before, err := ParsePerCore(strings.NewReader(procStatBefore))
+	if err != nil {
+		t.Fatalf("ParsePerCore: %v", err)
+	}
+	after, err := ParsePerCore(strings.NewReader(procStatAfter))
+	if err != nil {
+		t.Fatalf("ParsePerCore: %v", err)
+	}
+	if len(before) != 2 || before[1].User != 300 || before[1].System != 100 {
+		t.Fatalf("parsed %+v", before)
+	}
+
+	// cpu0 is mostly idle while cpu1 is pegged
+	got := PerCore(before, after)
+	if len(got) != 2 || got[0] != 10 || got[1] != 99 {
+		t.Errorf("PerCore = %v, want [10 99]", got)
+	}
+
+	if PerCore(before, after[:1]) != nil {
+		t.Error("PerCore matched readings with different core counts")
+	}
+}
+
+func TestParsePerCore_Invalid(t *testing.T) {
+	if _, err := ParsePerCore(strings.NewReader("cpu  1 2 3 4 5 6 7\n")); err == nil {
+		t.Error("accepted /proc/stat without per-core lines")
+	}
+	if _, err := ParsePerCore(strings.NewReader("cpu0 1 2 3\n")); err == nil {
+		t.Error("accepted a truncated cpu0 line")
+	}
+}
@@ -0,0 +1,129 @@
+// Package debugserver serves net/http/pprof profiles and expvar runtime
+// metrics on a separate, opt-in listener, so maintainers can profile
+// goroutine leaks and memory growth on installations that report them.
+//
+// The listener only binds to loopback unless remote access is explicitly
+// allowed: profiles expose stack traces and command lines and have no
+// authentication.
+package debugserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shutdownTimeout bounds the listener's graceful shutdown
+const shutdownTimeout = 5 * time.Second
+
+var (
+	started     = time.Now()
+	publishOnce sync.Once
+)
+
+// publishRuntime adds runtime figures beyond expvar's default cmdline and
+// memstats. expvar panics on duplicate names, so this runs once per process.
+func publishRuntime() {
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() interface{} {
+			return runtime.NumGoroutine()
+		}))
+		expvar.Publish("uptime_seconds", expvar.Func(func() interface{} {
+			return int64(time.Since(started).Seconds())
+		}))
+		expvar.Publish("go_version", expvar.Func(func() interface{} {
+			return runtime.Version()
+		}))
+	})
+}
+
+// Publish exposes a service-specific value under /debug/vars. fn is called
+// on every request, so it should return a cheap snapshot.
+func Publish(name string, fn func() interface{}) {
+	expvar.Publish(name, expvar.Func(fn))
+}
+
+// ResolveAddr normalizes a listen address. A bare port ("6060" or ":6060")
+// binds to 127.0.0.1; any other host must be a loopback address unless
+// allowRemote is set.
+func ResolveAddr(addr string, allowRemote bool) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return "", errors.New("empty listen address")
+	}
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if port == "" {
+		return "", fmt.Errorf("invalid listen address %q: missing port", addr)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !allowRemote && !isLoopback(host) {
+		return "", fmt.Errorf("listen address %q is not loopback; allow remote access explicitly to expose profiling endpoints", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Handler serves /debug/pprof/ and /debug/vars
+func Handler() http.Handler {
+	publishRuntime()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// Start resolves addr, binds it and serves Handler until ctx is done. Bind
+// errors are returned; the returned address is the one actually bound.
+func Start(ctx context.Context, addr string, allowRemote bool) (net.Addr, error) {
+	resolved, err := ResolveAddr(addr, allowRemote)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", resolved, err)
+	}
+
+	srv := &http.Server{
+		Handler:           Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		// No WriteTimeout: CPU profiles and traces stream for ?seconds=N
+	}
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	return ln.Addr(), nil
+}
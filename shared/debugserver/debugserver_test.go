@@ -0,0 +1,89 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveAddr(t *testing.T) {
+	tests := []struct {
+		addr        string
+		allowRemote bool
+		want        string
+		wantErr     bool
+	}{
+		{addr: "6060", want: "127.0.0.1:6060"},
+		{addr: ":6060", want: "127.0.0.1:6060"},
+		{addr: "localhost:6060", want: "localhost:6060"},
+		{addr: "[::1]:6060", want: "[::1]:6060"},
+		{addr: "0.0.0.0:6060", wantErr: true},
+		{addr: "0.0.0.0:6060", allowRemote: true, want: "0.0.0.0:6060"},
+		{addr: "10.0.0.5:6060", wantErr: true},
+		{addr: "127.0.0.1:", wantErr: true},
+		{addr: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveAddr(tt.addr, tt.allowRemote)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveAddr(%q, %v) error = %v, wantErr %v", tt.addr, tt.allowRemote, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolveAddr(%q, %v) = %q, want %q", tt.addr, tt.allowRemote, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_ServesVarsAndPprof(t *testing.T) {
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vars map[string]json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&vars)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode /debug/vars: %v", err)
+	}
+	for _, key := range []string{"goroutines", "uptime_seconds", "memstats"} {
+		if _, ok := vars[key]; !ok {
+			t.Errorf("/debug/vars missing %q", key)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(body) == 0 {
+		t.Errorf("goroutine profile status=%d len=%d", resp.StatusCode, len(body))
+	}
+}
+
+func TestStart_BindsLoopbackAndStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, err := Start(ctx, "127.0.0.1:0", false)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + addr.String() + "/debug/vars")
+	if err != nil {
+		t.Fatalf("GET /debug/vars: %v", err)
+	}
+	resp.Body.Close()
+	cancel()
+
+	if _, err := Start(context.Background(), "0.0.0.0:0", false); err == nil {
+		t.Error("non-loopback address should be rejected without allowRemote")
+	}
+}
@@ -0,0 +1,158 @@
+package docker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// diskIOFromBlkio sums read/write bytes from the blkio stats. cgroup v1
+// hosts report ops as "Read"/"Write"; Docker's cgroup v2 conversion of
+// io.stat reports them as "read"/"write".
+func diskIOFromBlkio(stat *container.StatsResponse) (read, write uint64) {
+	for _, bio := range stat.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(bio.Op) {
+		case "read":
+			read += bio.Value
+		case "write":
+			write += bio.Value
+		}
+	}
+	return read, write
+}
+
+// ParseIOStat sums rbytes and wbytes across all devices in a cgroup v2
+// io.stat file, whose lines look like
+// "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0".
+func ParseIOStat(r io.Reader) (read, write uint64, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, kv := range fields[1:] {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok || (key != "rbytes" && key != "wbytes") {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid io.stat value %q: %w", kv, err)
+			}
+			if key == "rbytes" {
+				read += n
+			} else {
+				write += n
+			}
+		}
+	}
+	return read, write, scanner.Err()
+}
+
+// cgroupIOStatPatterns locate a container's io.stat under the cgroup v2
+// mount: systemd cgroup driver (Docker, rootful Podman), then cgroupfs driver
+var cgroupIOStatPatterns = []string{
+	"system.slice/docker-%s*.scope/io.stat",
+	"machine.slice/libpod-%s*.scope/io.stat",
+	"docker/%s*/io.stat",
+}
+
+// CgroupIOReader reads container disk I/O straight from cgroup v2 io.stat
+// files, for hosts where the Docker API leaves BlkioStats empty. It only
+// works for containers on the same host as the reader.
+type CgroupIOReader struct {
+	cgroupRoot string
+
+	mu    sync.Mutex
+	paths map[string]string // container ID -> io.stat path, once found
+}
+
+// NewCgroupIOReader returns a reader for the first of sysRoots ("/sys",
+// "/host/sys", ...) with a cgroup v2 hierarchy mounted, or nil if none has.
+func NewCgroupIOReader(sysRoots ...string) *CgroupIOReader {
+	for _, root := range sysRoots {
+		cgroupRoot := filepath.Join(root, "fs", "cgroup")
+		if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+			return &CgroupIOReader{cgroupRoot: cgroupRoot, paths: make(map[string]string)}
+		}
+	}
+	return nil
+}
+
+// errNoIOStat means no io.stat was found for the container
+var errNoIOStat = errors.New("no cgroup io.stat for container")
+
+// Read returns a container's cumulative disk read/write bytes. containerID
+// may be the full ID or a prefix of it.
+func (r *CgroupIOReader) Read(containerID string) (read, write uint64, err error) {
+	path := r.statPath(containerID)
+	if path == "" {
+		return 0, 0, errNoIOStat
+	}
+	f, err := os.Open(path) // #nosec G304 -- path is built from the cgroup root and a container ID
+	if err != nil {
+		// The container may have been recreated; look it up again next time
+		r.mu.Lock()
+		delete(r.paths, containerID)
+		r.mu.Unlock()
+		return 0, 0, err
+	}
+	defer f.Close()
+	return ParseIOStat(f)
+}
+
+// statPath finds the container's io.stat path, caching it once found. A
+// container that has none yet, e.g. while it is still starting, is looked
+// up again on the next read.
+func (r *CgroupIOReader) statPath(containerID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if path, ok := r.paths[containerID]; ok {
+		return path
+	}
+	var path string
+	// IDs are hex; anything else would turn into a glob pattern
+	if containerID != "" && strings.Trim(containerID, "0123456789abcdef") == "" {
+		for _, pattern := range cgroupIOStatPatterns {
+			matches, _ := filepath.Glob(filepath.Join(r.cgroupRoot, fmt.Sprintf(pattern, containerID)))
+			if len(matches) == 1 {
+				path = matches[0]
+				break
+			}
+		}
+	}
+	if path != "" {
+		r.paths[containerID] = path
+	}
+	return path
+}
+
+// FillDiskIO sets result's disk counters from io.stat when the Docker API
+// reported no blkio entries. A nil reader leaves result untouched.
+func (r *CgroupIOReader) FillDiskIO(result *StatsResult, stat *container.StatsResponse, containerID string) {
+	if r == nil || len(stat.BlkioStats.IoServiceBytesRecursive) > 0 {
+		return
+	}
+	if read, write, err := r.Read(containerID); err == nil {
+		result.DiskRead, result.DiskWrite = read, write
+	}
+}
+
+// Forget drops the cached io.stat location for a container that went away
+func (r *CgroupIOReader) Forget(containerID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.paths, containerID)
+}
@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestDiskIOFromBlkio(t *testing.T) {
+	stat := &container.StatsResponse{}
+	stat.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{
+		// cgroup v1 style
+		{Major: 8, Minor: 0, Op: "Read", Value: 100},
+		{Major: 8, Minor: 0, Op: "Write", Value: 200},
+		{Major: 8, Minor: 0, Op: "Total", Value: 300},
+		// cgroup v2 style, as converted from io.stat by Docker
+		{Major: 259, Minor: 0, Op: "read", Value: 1000},
+		{Major: 259, Minor: 0, Op: "write", Value: 2000},
+	}
+	read, write := diskIOFromBlkio(stat)
+	if read != 1100 || write != 2200 {
+		t.Errorf("read=%d write=%d, want 1100/2200", read, write)
+	}
+}
+
+func TestParseIOStat(t *testing.T) {
+	data := "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0\n" +
+		"259:0 rbytes=100 wbytes=200 rios=1 wios=2 dbytes=0 dios=0\n" +
+		"\n"
+	read, write, err := ParseIOStat(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 1459300 || write != 314773704 {
+		t.Errorf("read=%d write=%d", read, write)
+	}
+
+	if _, _, err := ParseIOStat(strings.NewReader("8:0 rbytes=lots")); err == nil {
+		t.Error("expected error for invalid value")
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCgroupIOReader(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	sysRoot := t.TempDir()
+	cgroupRoot := filepath.Join(sysRoot, "fs", "cgroup")
+	writeFile(t, filepath.Join(cgroupRoot, "cgroup.controllers"), "cpu io memory\n")
+	writeFile(t, filepath.Join(cgroupRoot, "system.slice", "docker-"+id+".scope", "io.stat"),
+		"8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n")
+
+	if NewCgroupIOReader(t.TempDir()) != nil {
+		t.Fatal("expected nil reader without a cgroup v2 mount")
+	}
+	r := NewCgroupIOReader("/nonexistent", sysRoot)
+	if r == nil {
+		t.Fatal("expected reader for cgroup v2 root")
+	}
+
+	// Full and short IDs both resolve
+	for _, cid := range []string{id, id[:12]} {
+		read, write, err := r.Read(cid)
+		if err != nil || read != 4096 || write != 8192 {
+			t.Errorf("Read(%s) = %d, %d, %v", cid, read, write, err)
+		}
+	}
+	if _, _, err := r.Read("fedcba987654"); err == nil {
+		t.Error("expected error for unknown container")
+	}
+	// A container whose cgroup shows up later is found then
+	writeFile(t, filepath.Join(cgroupRoot, "system.slice", "docker-fedcba987654.scope", "io.stat"),
+		"8:0 rbytes=1 wbytes=2 rios=1 wios=1 dbytes=0 dios=0\n")
+	if read, _, err := r.Read("fedcba987654"); err != nil || read != 1 {
+		t.Errorf("Read after the cgroup appeared = %d, %v", read, err)
+	}
+	if _, _, err := r.Read("../../etc"); err == nil {
+		t.Error("expected error for non-hex ID")
+	}
+
+	// FillDiskIO only applies when the API reported no blkio entries
+	result := &StatsResult{}
+	r.FillDiskIO(result, &container.StatsResponse{}, id)
+	if result.DiskRead != 4096 || result.DiskWrite != 8192 {
+		t.Errorf("FillDiskIO: %+v", result)
+	}
+	stat := &container.StatsResponse{}
+	stat.BlkioStats.IoServiceBytesRecursive = []container.BlkioStatEntry{{Op: "read", Value: 1}}
+	result = &StatsResult{DiskRead: 1}
+	r.FillDiskIO(result, stat, id)
+	if result.DiskRead != 1 {
+		t.Errorf("FillDiskIO overrode API stats: %+v", result)
+	}
+
+	var nilReader *CgroupIOReader
+	nilReader.FillDiskIO(result, &container.StatsResponse{}, id)
+	nilReader.Forget(id)
+}
+
+func TestCgroupIOReader_CgroupfsDriver(t *testing.T) {
+	const id = "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+	sysRoot := t.TempDir()
+	cgroupRoot := filepath.Join(sysRoot, "fs", "cgroup")
+	writeFile(t, filepath.Join(cgroupRoot, "cgroup.controllers"), "io\n")
+	writeFile(t, filepath.Join(cgroupRoot, "docker", id, "io.stat"), "8:16 rbytes=1 wbytes=2 rios=1 wios=1 dbytes=0 dios=0\n")
+
+	read, write, err := NewCgroupIOReader(sysRoot).Read(id[:12])
+	if err != nil || read != 1 || write != 2 {
+		t.Errorf("Read = %d, %d, %v", read, write, err)
+	}
+}
@@ -0,0 +1,206 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// MaxDiffChanges caps the filesystem changes returned by DiffContainer; a
+// database or cache container can change far more files than are useful to
+// show
+const MaxDiffChanges = 1000
+
+// Filesystem change kinds
+const (
+	ChangeModified = "modified"
+	ChangeAdded    = "added"
+	ChangeDeleted  = "deleted"
+)
+
+// ContainerDiff is what changed in a container relative to its image: files
+// written to its writable layer and configuration set when it was created.
+type ContainerDiff struct {
+	ContainerID string       `json:"container_id"`
+	Image       string       `json:"image"`
+	Changes     []FileChange `json:"changes"`
+	// TotalChanges counts all changes; Changes is truncated to
+	// MaxDiffChanges
+	TotalChanges int         `json:"total_changes"`
+	Config       ConfigDrift `json:"config"`
+	// ConfigError is set when the image is gone, so config drift can't be
+	// computed
+	ConfigError string `json:"config_error,omitempty"`
+}
+
+// FileChange is one path changed in the container's writable layer
+type FileChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// ValueChange is a setting that differs from the image default
+type ValueChange struct {
+	Image     string `json:"image"`
+	Container string `json:"container"`
+}
+
+// ListChange is a command setting that differs from the image default
+type ListChange struct {
+	Image     []string `json:"image"`
+	Container []string `json:"container"`
+}
+
+// ConfigDrift lists container settings that override the image defaults.
+// Env and labels only report additions and changes: Docker merges the
+// image's values into the container, so nothing can be removed.
+type ConfigDrift struct {
+	EnvAdded      map[string]string      `json:"env_added,omitempty"`
+	EnvChanged    map[string]ValueChange `json:"env_changed,omitempty"`
+	Entrypoint    *ListChange            `json:"entrypoint,omitempty"`
+	Cmd           *ListChange            `json:"cmd,omitempty"`
+	User          *ValueChange           `json:"user,omitempty"`
+	WorkingDir    *ValueChange           `json:"working_dir,omitempty"`
+	StopSignal    *ValueChange           `json:"stop_signal,omitempty"`
+	LabelsChanged map[string]ValueChange `json:"labels_changed,omitempty"`
+}
+
+// ImageConfig is the subset of an image's config that containers inherit
+type ImageConfig struct {
+	Env        []string
+	Entrypoint []string
+	Cmd        []string
+	User       string
+	WorkingDir string
+	StopSignal string
+	Labels     map[string]string
+}
+
+// DiffContainer returns a container's filesystem changes and its config
+// drift from its image, for troubleshooting.
+func DiffContainer(ctx context.Context, cli client.APIClient, containerID string) (*ContainerDiff, error) {
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	changes, err := cli.ContainerDiff(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff container: %w", err)
+	}
+
+	diff := &ContainerDiff{
+		ContainerID:  inspect.ID,
+		TotalChanges: len(changes),
+		Changes:      fileChanges(changes),
+	}
+	if inspect.Config != nil {
+		diff.Image = inspect.Config.Image
+	}
+
+	img, err := cli.ImageInspect(ctx, inspect.Image)
+	switch {
+	case err != nil:
+		diff.ConfigError = fmt.Sprintf("image %s not available: %v", inspect.Image, err)
+	case img.Config == nil || inspect.Config == nil:
+		diff.ConfigError = "image or container config missing"
+	default:
+		diff.Config = ConfigDriftFrom(inspect.Config, ImageConfig{
+			Env:        img.Config.Env,
+			Entrypoint: img.Config.Entrypoint,
+			Cmd:        img.Config.Cmd,
+			User:       img.Config.User,
+			WorkingDir: img.Config.WorkingDir,
+			StopSignal: img.Config.StopSignal,
+			Labels:     img.Config.Labels,
+		})
+	}
+	return diff, nil
+}
+
+// fileChanges converts Docker's changes, sorted by path and capped at
+// MaxDiffChanges
+func fileChanges(changes []container.FilesystemChange) []FileChange {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	if len(changes) > MaxDiffChanges {
+		changes = changes[:MaxDiffChanges]
+	}
+	out := make([]FileChange, 0, len(changes))
+	for _, change := range changes {
+		kind := ChangeModified
+		switch change.Kind {
+		case container.ChangeAdd:
+			kind = ChangeAdded
+		case container.ChangeDelete:
+			kind = ChangeDeleted
+		}
+		out = append(out, FileChange{Path: change.Path, Kind: kind})
+	}
+	return out
+}
+
+// ConfigDriftFrom compares a container's config with its image's defaults
+func ConfigDriftFrom(cfg *container.Config, img ImageConfig) ConfigDrift {
+	var drift ConfigDrift
+
+	imageEnv := envMap(img.Env)
+	for name, value := range envMap(cfg.Env) {
+		imageValue, inherited := imageEnv[name]
+		switch {
+		case !inherited:
+			if drift.EnvAdded == nil {
+				drift.EnvAdded = make(map[string]string)
+			}
+			drift.EnvAdded[name] = value
+		case imageValue != value:
+			if drift.EnvChanged == nil {
+				drift.EnvChanged = make(map[string]ValueChange)
+			}
+			drift.EnvChanged[name] = ValueChange{Image: imageValue, Container: value}
+		}
+	}
+
+	if !slices.Equal(cfg.Entrypoint, img.Entrypoint) {
+		drift.Entrypoint = &ListChange{Image: img.Entrypoint, Container: cfg.Entrypoint}
+	}
+	if !slices.Equal(cfg.Cmd, img.Cmd) {
+		drift.Cmd = &ListChange{Image: img.Cmd, Container: cfg.Cmd}
+	}
+	drift.User = valueChange(img.User, cfg.User)
+	drift.WorkingDir = valueChange(img.WorkingDir, cfg.WorkingDir)
+	// An unset stop signal means the image's (or SIGTERM) applies
+	if cfg.StopSignal != "" {
+		drift.StopSignal = valueChange(img.StopSignal, cfg.StopSignal)
+	}
+
+	for name, imageValue := range img.Labels {
+		if value, ok := cfg.Labels[name]; ok && value != imageValue {
+			if drift.LabelsChanged == nil {
+				drift.LabelsChanged = make(map[string]ValueChange)
+			}
+			drift.LabelsChanged[name] = ValueChange{Image: imageValue, Container: value}
+		}
+	}
+	return drift
+}
+
+func valueChange(image, container string) *ValueChange {
+	if image == container {
+		return nil
+	}
+	return &ValueChange{Image: image, Container: container}
+}
+
+// envMap splits KEY=value entries; a bare KEY has an empty value
+func envMap(env []string) map[string]string {
+	m := make(map[string]string, len(env))
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		m[name] = value
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+func TestConfigDriftFrom(t *testing.T) {
+	drift := ConfigDriftFrom(&container.Config{
+		Env:        []string{"PATH=/usr/bin", "MODE=debug", "EXTRA=1"},
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Cmd:        []string{"serve"},
+		User:       "1000",
+		WorkingDir: "/app",
+		Labels:     map[string]string{"version": "2", "com.docker.compose.project": "web"},
+	}, ImageConfig{
+		Env:        []string{"PATH=/usr/bin", "MODE=prod"},
+		Entrypoint: []string{"/entrypoint.sh"},
+		Cmd:        []string{"serve"},
+		WorkingDir: "/app",
+		StopSignal: "SIGQUIT",
+		Labels:     map[string]string{"version": "1"},
+	})
+
+	if len(drift.EnvAdded) != 1 || drift.EnvAdded["EXTRA"] != "1" {
+		t.Errorf("EnvAdded = %v", drift.EnvAdded)
+	}
+	if change := drift.EnvChanged["MODE"]; len(drift.EnvChanged) != 1 || change.Image != "prod" || change.Container != "debug" {
+		t.Errorf("EnvChanged = %v", drift.EnvChanged)
+	}
+	if drift.Entrypoint == nil || drift.Entrypoint.Container[0] != "/bin/sh" {
+		t.Errorf("Entrypoint = %+v", drift.Entrypoint)
+	}
+	if drift.Cmd != nil || drift.WorkingDir != nil || drift.StopSignal != nil {
+		t.Errorf("unchanged settings reported: cmd %+v, working dir %+v, stop signal %+v", drift.Cmd, drift.WorkingDir, drift.StopSignal)
+	}
+	if drift.User == nil || drift.User.Image != "" || drift.User.Container != "1000" {
+		t.Errorf("User = %+v", drift.User)
+	}
+	if len(drift.LabelsChanged) != 1 || drift.LabelsChanged["version"].Container != "2" {
+		t.Errorf("LabelsChanged = %v", drift.LabelsChanged)
+	}
+}
+
+func TestDiffContainer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			fmt.Fprint(w, `{"Id":"abc","Image":"sha256:img","Config":{"Image":"nginx","Env":["A=1"]}}`)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/changes"):
+			fmt.Fprint(w, `[{"Path":"/var/log","Kind":0},{"Path":"/tmp/x","Kind":1},{"Path":"/etc/old","Kind":2}]`)
+		case strings.HasSuffix(r.URL.Path, "/images/sha256:img/json"):
+			http.Error(w, `{"message":"no such image"}`, http.StatusNotFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithVersion("1.41"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	diff, err := DiffContainer(context.Background(), cli, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileChange{{"/etc/old", ChangeDeleted}, {"/tmp/x", ChangeAdded}, {"/var/log", ChangeModified}}
+	if diff.TotalChanges != 3 || fmt.Sprint(diff.Changes) != fmt.Sprint(want) {
+		t.Errorf("changes = %v (total %d), want %v", diff.Changes, diff.TotalChanges, want)
+	}
+	if diff.Image != "nginx" || diff.ConfigError == "" {
+		t.Errorf("missing image should be reported, got image %q, config error %q", diff.Image, diff.ConfigError)
+	}
+}
@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types/events"
+)
+
+// auditedResourceActions lists the image, volume and network events forwarded
+// for the host audit trail. Network connect/disconnect is left out: it fires
+// on every container start and stop, which the container events already
+// cover.
+var auditedResourceActions = map[events.Type]map[events.Action]bool{
+	events.ImageEventType: {
+		events.ActionPull:   true,
+		events.ActionPush:   true,
+		events.ActionDelete: true,
+		events.ActionTag:    true,
+		events.ActionUnTag:  true,
+		events.ActionImport: true,
+		events.ActionLoad:   true,
+		events.ActionPrune:  true,
+	},
+	events.VolumeEventType: {
+		events.ActionCreate:  true,
+		events.ActionDestroy: true,
+		events.ActionPrune:   true,
+	},
+	events.NetworkEventType: {
+		events.ActionCreate:  true,
+		events.ActionDestroy: true,
+		events.ActionRemove:  true,
+		events.ActionPrune:   true,
+	},
+}
+
+// IsAuditedResourceEvent reports whether an image, volume or network event
+// should be forwarded to DockMon.
+func IsAuditedResourceEvent(event events.Message) bool {
+	return auditedResourceActions[event.Type][event.Action]
+}
+
+// ResourceEventName returns a readable name for the event's subject: the
+// image reference, volume name or network name. Falls back to the actor ID.
+func ResourceEventName(event events.Message) string {
+	if name := event.Actor.Attributes["name"]; name != "" {
+		return name
+	}
+	return event.Actor.ID
+}
@@ -0,0 +1,42 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestIsAuditedResourceEvent(t *testing.T) {
+	tests := []struct {
+		eventType events.Type
+		action    events.Action
+		want      bool
+	}{
+		{events.ImageEventType, events.ActionPull, true},
+		{events.ImageEventType, events.ActionDelete, true},
+		{events.VolumeEventType, events.ActionCreate, true},
+		{events.VolumeEventType, events.ActionMount, false},
+		{events.NetworkEventType, events.ActionDestroy, true},
+		{events.NetworkEventType, events.ActionConnect, false},
+		{events.ContainerEventType, events.ActionStart, false},
+	}
+
+	for _, tt := range tests {
+		event := events.Message{Type: tt.eventType, Action: tt.action}
+		if got := IsAuditedResourceEvent(event); got != tt.want {
+			t.Errorf("IsAuditedResourceEvent(%s %s) = %v, want %v", tt.eventType, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestResourceEventName(t *testing.T) {
+	named := events.Message{Actor: events.Actor{ID: "sha256:abc", Attributes: map[string]string{"name": "nginx:latest"}}}
+	if got := ResourceEventName(named); got != "nginx:latest" {
+		t.Errorf("ResourceEventName = %q, want nginx:latest", got)
+	}
+
+	volume := events.Message{Actor: events.Actor{ID: "pgdata"}}
+	if got := ResourceEventName(volume); got != "pgdata" {
+		t.Errorf("ResourceEventName = %q, want pgdata", got)
+	}
+}
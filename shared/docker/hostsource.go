@@ -0,0 +1,299 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxTLSFileSize bounds a TLS file read from disk; PEM certificates and keys
+// are a few KiB
+const maxTLSFileSize = 64 * 1024
+
+// contextEndpoint is the Docker endpoint of a Docker CLI context
+const contextEndpoint = "docker"
+
+// contextNamePattern matches Docker CLI context names
+var contextNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.+-]+$`)
+
+// HostSource references a Docker host's connection settings instead of
+// carrying them inline: PEM files on the service's filesystem, or a Docker
+// CLI context. Host requests embed it next to their address and tls_*
+// fields, so key material doesn't have to travel (or get logged) as JSON.
+type HostSource struct {
+	// TLS*File are absolute paths under DOCKER_CERT_PATH or the Docker CLI
+	// config directory
+	TLSCACertFile string `json:"tls_ca_cert_file,omitempty"`
+	TLSCertFile   string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile    string `json:"tls_key_file,omitempty"`
+	// DockerContext names a context in the Docker CLI config directory
+	// (see DockerConfigDir) that supplies the address and TLS files
+	DockerContext string `json:"docker_context,omitempty"`
+}
+
+// IsZero reports whether the source references nothing.
+func (s HostSource) IsZero() bool {
+	return s == HostSource{}
+}
+
+// HostSourceError is a Resolve failure, naming the request field at fault
+type HostSourceError struct {
+	Field string // e.g. "tls_key_file" or "docker_context"
+	Err   error
+}
+
+func (e *HostSourceError) Error() string { return e.Field + ": " + e.Err.Error() }
+func (e *HostSourceError) Unwrap() error { return e.Err }
+
+// Resolve replaces the references with inline values: each file's content
+// goes into its PEM field, and a context supplies the host address and its
+// TLS material. A reference can't be combined with the inline value it
+// replaces. Without references Resolve changes nothing. Errors are
+// *HostSourceError.
+func (s HostSource) Resolve(host, caCert, cert, key *string) error {
+	if s.DockerContext != "" {
+		contextErr := func(err error) error { return &HostSourceError{Field: "docker_context", Err: err} }
+		if *host != "" || *caCert != "" || *cert != "" || *key != "" ||
+			s.TLSCACertFile != "" || s.TLSCertFile != "" || s.TLSKeyFile != "" {
+			return contextErr(errors.New("can't be combined with a host address or TLS settings"))
+		}
+		dc, err := LoadDockerContext(DockerConfigDir(), s.DockerContext)
+		if err != nil {
+			return contextErr(err)
+		}
+		if dc.SkipTLSVerify {
+			return contextErr(fmt.Errorf("context %q skips TLS verification, which is not supported", dc.Name))
+		}
+		*host = dc.Host
+		if err := dc.HostSource.Resolve(host, caCert, cert, key); err != nil {
+			return contextErr(err)
+		}
+		return nil
+	}
+
+	files := []struct {
+		field string
+		path  string
+		value *string
+	}{
+		{"tls_ca_cert", s.TLSCACertFile, caCert},
+		{"tls_cert", s.TLSCertFile, cert},
+		{"tls_key", s.TLSKeyFile, key},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if *f.value != "" {
+			return &HostSourceError{Field: f.field + "_file", Err: fmt.Errorf("can't be set together with %s", f.field)}
+		}
+		content, err := readTLSFile(f.path)
+		if err != nil {
+			return &HostSourceError{Field: f.field + "_file", Err: err}
+		}
+		*f.value = content
+	}
+	return nil
+}
+
+// readTLSFile reads a PEM file from DOCKER_CERT_PATH or the Docker CLI
+// config directory, so a request can't have the service read arbitrary
+// files. Errors name the path but never the content.
+func readTLSFile(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%q is not an absolute path", path)
+	}
+	// Symlinks are resolved first so a link can't point out of the roots
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !inTLSFileRoots(resolved) {
+		return "", fmt.Errorf("%s is outside DOCKER_CERT_PATH and the Docker config directory", path)
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxTLSFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(data) > maxTLSFileSize {
+		return "", fmt.Errorf("%s is larger than %d bytes", path, maxTLSFileSize)
+	}
+	return string(data), nil
+}
+
+// inTLSFileRoots reports whether path lies in DOCKER_CERT_PATH or the
+// Docker CLI config directory
+func inTLSFileRoots(path string) bool {
+	roots := []string{DockerConfigDir()}
+	if certPath := os.Getenv("DOCKER_CERT_PATH"); certPath != "" {
+		roots = append(roots, certPath)
+	}
+	for _, root := range roots {
+		root, err := filepath.EvalSymlinks(root)
+		if err != nil || !filepath.IsAbs(root) {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DockerContext is a Docker CLI context as a host definition. Its TLS
+// material is referenced by path, never copied, so a definition can be
+// passed on as a host request as is.
+type DockerContext struct {
+	Name          string `json:"name"`
+	Description   string `json:"description,omitempty"`
+	Host          string `json:"host"` // Empty for the local socket
+	SkipTLSVerify bool   `json:"skip_tls_verify,omitempty"`
+	HostSource
+	// Warnings explains TLS material the context lists but that isn't
+	// readable here, e.g. for contexts inspected on another machine
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// contextMetadata is the part of a context's meta.json, and of an entry of
+// `docker context inspect`, that describes the Docker endpoint
+type contextMetadata struct {
+	Name     string `json:"Name"`
+	Metadata struct {
+		Description string `json:"Description"`
+	} `json:"Metadata"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// contextInspect is an entry of `docker context inspect`
+type contextInspect struct {
+	contextMetadata
+	TLSMaterial map[string][]string `json:"TLSMaterial"`
+	Storage     struct {
+		TLSPath string `json:"TLSPath"`
+	} `json:"Storage"`
+}
+
+// DockerConfigDir is the Docker CLI config directory contexts are read
+// from: $DOCKER_CONFIG, else ~/.docker.
+func DockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// LoadDockerContext reads a context from the Docker CLI context store in
+// configDir. The built-in "default" context is the local socket.
+func LoadDockerContext(configDir, name string) (*DockerContext, error) {
+	if name == "default" {
+		return &DockerContext{Name: name}, nil
+	}
+	if !contextNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid docker context name %q", name)
+	}
+
+	// The CLI stores each context under the SHA-256 of its name
+	sum := sha256.Sum256([]byte(name))
+	digest := hex.EncodeToString(sum[:])
+	data, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", digest, "meta.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("docker context %q not found in %s", name, configDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker context %q: %w", name, err)
+	}
+	var meta contextMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse docker context %q: %w", name, err)
+	}
+
+	tlsDir := filepath.Join(configDir, "contexts", "tls", digest)
+	return newDockerContext(meta, tlsDir, []string{"ca.pem", "cert.pem", "key.pem"}, false)
+}
+
+// ParseDockerContexts turns `docker context inspect` output into host
+// definitions. TLS files are looked up at each context's storage path;
+// listed files missing here are reported as warnings.
+func ParseDockerContexts(data []byte) ([]DockerContext, error) {
+	var entries []contextInspect
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("not docker context inspect output: %w", err)
+	}
+
+	contexts := make([]DockerContext, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "" {
+			return nil, errors.New("docker context without a name")
+		}
+		dc, err := newDockerContext(entry.contextMetadata, entry.Storage.TLSPath, entry.TLSMaterial[contextEndpoint], true)
+		if err != nil {
+			return nil, err
+		}
+		contexts = append(contexts, *dc)
+	}
+	return contexts, nil
+}
+
+// newDockerContext builds a host definition from context metadata and the
+// TLS files under tlsDir/docker. Missing listed files are skipped, or
+// reported as warnings when warnMissing is set.
+func newDockerContext(meta contextMetadata, tlsDir string, material []string, warnMissing bool) (*DockerContext, error) {
+	endpoint, ok := meta.Endpoints[contextEndpoint]
+	if !ok {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", meta.Name)
+	}
+	dc := &DockerContext{
+		Name:          meta.Name,
+		Description:   meta.Metadata.Description,
+		Host:          endpoint.Host,
+		SkipTLSVerify: endpoint.SkipTLSVerify,
+	}
+
+	files := map[string]*string{
+		"ca.pem":   &dc.TLSCACertFile,
+		"cert.pem": &dc.TLSCertFile,
+		"key.pem":  &dc.TLSKeyFile,
+	}
+	for _, name := range material {
+		field, ok := files[name]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(tlsDir, contextEndpoint, name)
+		if tlsDir == "" || !filepath.IsAbs(path) {
+			if warnMissing {
+				dc.Warnings = append(dc.Warnings, fmt.Sprintf("%s: no TLS storage path", name))
+			}
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			if warnMissing {
+				dc.Warnings = append(dc.Warnings, fmt.Sprintf("%s not readable at %s", name, path))
+			}
+			continue
+		}
+		*field = path
+	}
+	return dc, nil
+}
@@ -0,0 +1,190 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// Reasons an image is kept by PlanImagePrune
+const (
+	KeepReasonInUse    = "in_use"    // Referenced by a container, running or stopped
+	KeepReasonLastTags = "last_tags" // Among the newest tags of a KeepLastTags repository
+)
+
+// ImagePrunePolicy holds the safety rules of a policy-based image prune.
+// Images referenced by any container, including stopped ones, are always
+// kept so they can be started again.
+type ImagePrunePolicy struct {
+	// KeepLastTags keeps the images of the newest N tags of each repository
+	// (by image creation time), e.g. {"ghcr.io/acme/api": 3} for rollbacks
+	KeepLastTags map[string]int `json:"keep_last_tags,omitempty"`
+	// DryRun reports what would be removed without removing anything
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Validate checks the repository names and counts.
+func (p *ImagePrunePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for repo, n := range p.KeepLastTags {
+		if _, err := reference.ParseNormalizedNamed(repo); err != nil {
+			return fmt.Errorf("keep_last_tags: invalid repository %q", repo)
+		}
+		if n < 1 {
+			return fmt.Errorf("keep_last_tags: %s must keep at least one tag", repo)
+		}
+	}
+	return nil
+}
+
+// PrunedImage is an image in an ImagePruneReport
+type PrunedImage struct {
+	ID     string   `json:"id"`
+	Tags   []string `json:"tags"`
+	Size   int64    `json:"size"`
+	Reason string   `json:"reason,omitempty"` // Why a kept image was kept
+	Error  string   `json:"error,omitempty"`  // Why a removal failed
+}
+
+// ImagePruneReport is the outcome of a policy-based prune. In a dry run
+// Removed lists the images that would be removed and SpaceReclaimed what
+// that would free.
+type ImagePruneReport struct {
+	DryRun         bool          `json:"dry_run"`
+	Removed        []PrunedImage `json:"removed"`
+	Kept           []PrunedImage `json:"kept"`
+	Failed         []PrunedImage `json:"failed,omitempty"`
+	RemovedCount   int           `json:"removed_count"`
+	SpaceReclaimed int64         `json:"space_reclaimed"`
+}
+
+// PlanImagePrune decides which images a prune removes under policy. The
+// report lists every image, as removed or kept, without touching the daemon.
+func PlanImagePrune(images []image.Summary, containers []container.Summary, policy ImagePrunePolicy) *ImagePruneReport {
+	inUse := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+	lastTags := newestTagImages(images, policy.KeepLastTags)
+
+	report := &ImagePruneReport{DryRun: policy.DryRun, Removed: []PrunedImage{}, Kept: []PrunedImage{}}
+	for _, img := range images {
+		pruned := PrunedImage{ID: img.ID, Tags: img.RepoTags, Size: img.Size}
+		if pruned.Tags == nil {
+			pruned.Tags = []string{}
+		}
+		switch {
+		case inUse[img.ID]:
+			pruned.Reason = KeepReasonInUse
+		case lastTags[img.ID]:
+			pruned.Reason = KeepReasonLastTags
+		}
+		if pruned.Reason != "" {
+			report.Kept = append(report.Kept, pruned)
+			continue
+		}
+		report.Removed = append(report.Removed, pruned)
+		report.RemovedCount++
+		report.SpaceReclaimed += img.Size
+	}
+	return report
+}
+
+// newestTagImages returns the IDs of the images carrying the newest keep[repo]
+// tags of each repository. Repositories match by their normalized name, so
+// "nginx" covers "docker.io/library/nginx:1.27".
+func newestTagImages(images []image.Summary, keep map[string]int) map[string]bool {
+	ids := make(map[string]bool)
+	if len(keep) == 0 {
+		return ids
+	}
+	limits := make(map[string]int, len(keep))
+	for repo, n := range keep {
+		if named, err := reference.ParseNormalizedNamed(repo); err == nil {
+			limits[named.Name()] = n
+		}
+	}
+
+	type taggedImage struct {
+		id      string
+		created int64
+	}
+	byRepo := make(map[string][]taggedImage)
+	for _, img := range images {
+		for _, tag := range img.RepoTags {
+			named, err := reference.ParseNormalizedNamed(tag)
+			if err != nil {
+				continue
+			}
+			if _, ok := limits[named.Name()]; ok {
+				byRepo[named.Name()] = append(byRepo[named.Name()], taggedImage{img.ID, img.Created})
+			}
+		}
+	}
+	for repo, tagged := range byRepo {
+		sort.SliceStable(tagged, func(i, j int) bool { return tagged[i].created > tagged[j].created })
+		for i := 0; i < len(tagged) && i < limits[repo]; i++ {
+			ids[tagged[i].id] = true
+		}
+	}
+	return ids
+}
+
+// PruneImagesWithPolicy plans a prune under policy and, unless it is a dry
+// run, removes the planned images. Tagged images are removed tag by tag
+// without force, so the daemon still refuses an image a container started
+// using after the plan was made; such failures are reported per image
+// rather than failing the prune.
+func PruneImagesWithPolicy(ctx context.Context, cli client.APIClient, policy ImagePrunePolicy) (*ImagePruneReport, error) {
+	images, err := cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	report := PlanImagePrune(images, containers, policy)
+	if policy.DryRun {
+		return report, nil
+	}
+
+	planned := report.Removed
+	report.Removed = []PrunedImage{}
+	report.RemovedCount = 0
+	report.SpaceReclaimed = 0
+	for _, img := range planned {
+		if err := removeImageRefs(ctx, cli, img); err != nil {
+			img.Error = err.Error()
+			report.Failed = append(report.Failed, img)
+			continue
+		}
+		report.Removed = append(report.Removed, img)
+		report.RemovedCount++
+		report.SpaceReclaimed += img.Size
+	}
+	return report, nil
+}
+
+// removeImageRefs untags each of the image's tags, which deletes the image
+// with the last one, or removes an untagged image by ID
+func removeImageRefs(ctx context.Context, cli client.APIClient, img PrunedImage) error {
+	refs := img.Tags
+	if len(refs) == 0 {
+		refs = []string{img.ID}
+	}
+	for _, ref := range refs {
+		if _, err := cli.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+func pruneFixtures() ([]image.Summary, []container.Summary) {
+	images := []image.Summary{
+		{ID: "sha256:web", RepoTags: []string{"nginx:1.27"}, Created: 100, Size: 10},
+		{ID: "sha256:stopped", RepoTags: []string{"redis:7"}, Created: 100, Size: 20},
+		{ID: "sha256:api3", RepoTags: []string{"ghcr.io/acme/api:3"}, Created: 300, Size: 30},
+		{ID: "sha256:api2", RepoTags: []string{"ghcr.io/acme/api:2"}, Created: 200, Size: 40},
+		{ID: "sha256:api1", RepoTags: []string{"ghcr.io/acme/api:1"}, Created: 100, Size: 50},
+		{ID: "sha256:nginx-old", RepoTags: []string{"nginx:1.25", "nginx:stable"}, Created: 50, Size: 60},
+		{ID: "sha256:dangling", Created: 10, Size: 70},
+	}
+	containers := []container.Summary{
+		{ID: "c1", ImageID: "sha256:web", State: "running"},
+		{ID: "c2", ImageID: "sha256:stopped", State: "exited"},
+	}
+	return images, containers
+}
+
+func prunedIDs(images []PrunedImage) []string {
+	ids := make([]string, len(images))
+	for i, img := range images {
+		ids[i] = img.ID
+	}
+	return ids
+}
+
+func TestPlanImagePrune(t *testing.T) {
+	images, containers := pruneFixtures()
+	report := PlanImagePrune(images, containers, ImagePrunePolicy{
+		KeepLastTags: map[string]int{"ghcr.io/acme/api": 2},
+		DryRun:       true,
+	})
+
+	if got := strings.Join(prunedIDs(report.Removed), ","); got != "sha256:api1,sha256:nginx-old,sha256:dangling" {
+		t.Errorf("removed = %s", got)
+	}
+	if report.RemovedCount != 3 || report.SpaceReclaimed != 180 || !report.DryRun {
+		t.Errorf("report = %+v, want 3 images and 180 bytes in a dry run", report)
+	}
+	reasons := make(map[string]string)
+	for _, img := range report.Kept {
+		reasons[img.ID] = img.Reason
+	}
+	want := map[string]string{
+		"sha256:web":     KeepReasonInUse,
+		"sha256:stopped": KeepReasonInUse,
+		"sha256:api3":    KeepReasonLastTags,
+		"sha256:api2":    KeepReasonLastTags,
+	}
+	for id, reason := range want {
+		if reasons[id] != reason {
+			t.Errorf("%s kept as %q, want %q", id, reasons[id], reason)
+		}
+	}
+	if len(report.Kept) != len(want) {
+		t.Errorf("kept = %v", prunedIDs(report.Kept))
+	}
+}
+
+func TestPlanImagePrune_NormalizedRepositories(t *testing.T) {
+	images, containers := pruneFixtures()
+	report := PlanImagePrune(images, containers, ImagePrunePolicy{
+		KeepLastTags: map[string]int{"docker.io/library/nginx": 1},
+	})
+	// nginx:1.27 is the newest tag and in use; it still counts as the one kept
+	for _, img := range report.Removed {
+		if img.ID == "sha256:nginx-old" {
+			return
+		}
+	}
+	t.Errorf("removed = %v, want the older nginx image pruned", prunedIDs(report.Removed))
+}
+
+func TestImagePrunePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *ImagePrunePolicy
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"empty", &ImagePrunePolicy{}, false},
+		{"keep tags", &ImagePrunePolicy{KeepLastTags: map[string]int{"nginx": 3}}, false},
+		{"zero tags", &ImagePrunePolicy{KeepLastTags: map[string]int{"nginx": 0}}, true},
+		{"bad repository", &ImagePrunePolicy{KeepLastTags: map[string]int{"Not A Repo": 1}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// newFakePruneDaemon serves the prune fixtures and records image removals.
+// Removing "redis:7" fails as if a container had started using it.
+func newFakePruneDaemon(t *testing.T) (*[]string, client.APIClient) {
+	t.Helper()
+	var mu sync.Mutex
+	var removed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/images/json"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[
+				{"Id":"sha256:web","RepoTags":["nginx:1.27"],"Created":100,"Size":10},
+				{"Id":"sha256:old","RepoTags":["nginx:1.25","nginx:stable"],"Created":50,"Size":60},
+				{"Id":"sha256:redis","RepoTags":["redis:7"],"Created":40,"Size":20},
+				{"Id":"sha256:dangling","RepoTags":null,"Created":10,"Size":70}]`)
+		case strings.HasSuffix(path, "/containers/json"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"Id":"c1","ImageID":"sha256:web","State":"running"}]`)
+		case r.Method == http.MethodDelete && strings.Contains(path, "/images/"):
+			ref := path[strings.Index(path, "/images/")+len("/images/"):]
+			if ref == "redis:7" {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"message":"conflict: container c2 is using its referenced image"}`)
+				return
+			}
+			removed = append(removed, ref)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `[{"Untagged":%q}]`, ref)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost(tcpAddress(srv)), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return &removed, cli
+}
+
+func TestPruneImagesWithPolicy(t *testing.T) {
+	removed, cli := newFakePruneDaemon(t)
+
+	report, err := PruneImagesWithPolicy(context.Background(), cli, ImagePrunePolicy{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*removed) != 0 || report.RemovedCount != 3 {
+		t.Fatalf("dry run removed %v and reported %d images, want nothing removed and 3 planned", *removed, report.RemovedCount)
+	}
+
+	report, err = PruneImagesWithPolicy(context.Background(), cli, ImagePrunePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*removed, ","); got != "nginx:1.25,nginx:stable,sha256:dangling" {
+		t.Errorf("removed refs = %s, want each tag then the untagged image by ID", got)
+	}
+	if report.RemovedCount != 2 || report.SpaceReclaimed != 130 {
+		t.Errorf("report = %+v, want 2 images and 130 bytes removed", report)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].ID != "sha256:redis" || !strings.Contains(report.Failed[0].Error, "conflict") {
+		t.Errorf("failed = %+v, want the redis image refused", report.Failed)
+	}
+}
@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Log download size limits, applied to the uncompressed log. Requests with no
+// limit get DefaultLogDownloadMaxBytes; larger limits are capped at
+// MaxLogDownloadMaxBytes.
+const (
+	DefaultLogDownloadMaxBytes int64 = 50 << 20
+	MaxLogDownloadMaxBytes     int64 = 500 << 20
+)
+
+// LogDownloadOptions selects the part of a container log to download. Since
+// and Until take anything `docker logs` accepts: RFC 3339 timestamps, Unix
+// timestamps or relative durations such as "2h".
+type LogDownloadOptions struct {
+	Since      string
+	Until      string
+	Timestamps bool
+	MaxBytes   int64
+}
+
+// LogDownloadResult describes a finished log download
+type LogDownloadResult struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+	Bytes         int64  `json:"bytes"` // Uncompressed log bytes written
+	Truncated     bool   `json:"truncated"`
+}
+
+// errLogLimit stops the copy once the size limit is reached
+var errLogLimit = errors.New("log size limit reached")
+
+// LogDownloadMaxBytes resolves a requested limit: zero or negative means
+// the default, and anything above the maximum is capped
+func LogDownloadMaxBytes(requested int64) int64 {
+	switch {
+	case requested <= 0:
+		return DefaultLogDownloadMaxBytes
+	case requested > MaxLogDownloadMaxBytes:
+		return MaxLogDownloadMaxBytes
+	}
+	return requested
+}
+
+// WriteContainerLogs writes a container's stdout and stderr to w as a gzip
+// stream, interleaved in the order Docker returns them. Once MaxBytes of log
+// have been written the download stops and a truncation notice is appended.
+func WriteContainerLogs(ctx context.Context, cli client.APIClient, containerID string, opts LogDownloadOptions, w io.Writer) (*LogDownloadResult, error) {
+	// TTY containers return raw logs without multiplexing headers
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	result := &LogDownloadResult{
+		ContainerID:   info.ID,
+		ContainerName: strings.TrimPrefix(info.Name, "/"),
+	}
+
+	logs, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get logs: %w", err)
+	}
+	defer logs.Close()
+
+	maxBytes := LogDownloadMaxBytes(opts.MaxBytes)
+	gz := gzip.NewWriter(w)
+	limited := &limitWriter{w: gz, remaining: maxBytes}
+
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(limited, logs)
+	} else {
+		_, err = stdcopy.StdCopy(limited, limited, logs)
+	}
+	result.Bytes = maxBytes - limited.remaining
+	if errors.Is(err, errLogLimit) {
+		result.Truncated = true
+		if _, err = fmt.Fprintf(gz, "\n[log truncated at %d bytes]\n", maxBytes); err != nil {
+			return nil, fmt.Errorf("failed to write logs: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write logs: %w", err)
+	}
+	return result, nil
+}
+
+// limitWriter passes writes through until remaining reaches zero, writing
+// as much of the final chunk as fits before failing with errLogLimit
+type limitWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= l.remaining {
+		n, err := l.w.Write(p)
+		l.remaining -= int64(n)
+		return n, err
+	}
+	n, err := l.w.Write(p[:l.remaining])
+	l.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, errLogLimit
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+)
+
+// muxFrame encodes payload the way Docker multiplexes non-TTY logs
+func muxFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+// fakeLogDaemon serves inspect and logs for a single container
+func fakeLogDaemon(t *testing.T, tty bool, logs []byte) client.APIClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"Id":"abc123","Name":"/web","Config":{"Tty":%t}}`, tty)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/logs"):
+			// The client turns relative durations into timestamps
+			if r.URL.Query().Get("since") == "" {
+				http.Error(w, `{"message":"since not passed"}`, http.StatusBadRequest)
+				return
+			}
+			w.Write(logs)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost(tcpAddress(srv)), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	return string(out)
+}
+
+func TestWriteContainerLogs_Multiplexed(t *testing.T) {
+	logs := append(muxFrame(1, "out one\n"), muxFrame(2, "err one\n")...)
+	logs = append(logs, muxFrame(1, "out two\n")...)
+	cli := fakeLogDaemon(t, false, logs)
+
+	var buf bytes.Buffer
+	result, err := WriteContainerLogs(context.Background(), cli, "abc", LogDownloadOptions{Since: "1h"}, &buf)
+	if err != nil {
+		t.Fatalf("WriteContainerLogs: %v", err)
+	}
+	if got, want := gunzip(t, buf.Bytes()), "out one\nerr one\nout two\n"; got != want {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+	if result.ContainerID != "abc123" || result.ContainerName != "web" || result.Bytes != 24 || result.Truncated {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestWriteContainerLogs_Truncated(t *testing.T) {
+	cli := fakeLogDaemon(t, true, []byte(strings.Repeat("x", 100)))
+
+	var buf bytes.Buffer
+	result, err := WriteContainerLogs(context.Background(), cli, "abc", LogDownloadOptions{Since: "1h", MaxBytes: 10}, &buf)
+	if err != nil {
+		t.Fatalf("WriteContainerLogs: %v", err)
+	}
+	if !result.Truncated || result.Bytes != 10 {
+		t.Errorf("result = %+v, want truncated at 10 bytes", result)
+	}
+	if got, want := gunzip(t, buf.Bytes()), "xxxxxxxxxx\n[log truncated at 10 bytes]\n"; got != want {
+		t.Errorf("logs = %q, want %q", got, want)
+	}
+}
+
+func TestLogDownloadMaxBytes(t *testing.T) {
+	tests := []struct{ in, want int64 }{
+		{0, DefaultLogDownloadMaxBytes},
+		{-1, DefaultLogDownloadMaxBytes},
+		{1024, 1024},
+		{MaxLogDownloadMaxBytes + 1, MaxLogDownloadMaxBytes},
+	}
+	for _, tt := range tests {
+		if got := LogDownloadMaxBytes(tt.in); got != tt.want {
+			t.Errorf("LogDownloadMaxBytes(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
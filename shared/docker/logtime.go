@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timestamp modes for retrieved log lines
+const (
+	LogTimestampsUTC      = "utc"      // Docker's RFC 3339 UTC timestamps (default)
+	LogTimestampsNone     = "none"     // No timestamps
+	LogTimestampsLocal    = "local"    // Converted to a time zone
+	LogTimestampsRelative = "relative" // Age of the line, e.g. "5m ago"
+)
+
+// LogTimestamps selects how timestamps of retrieved log lines are rendered,
+// so callers get them ready to display instead of reparsing each line. The
+// zero value keeps Docker's UTC timestamps.
+type LogTimestamps struct {
+	Mode     string
+	Location *time.Location // For LogTimestampsLocal
+}
+
+// ParseLogTimestamps resolves a mode and an IANA time zone name. A time
+// zone without a mode means local; local without a time zone uses the
+// host's.
+func ParseLogTimestamps(mode, tz string) (LogTimestamps, error) {
+	mode = strings.ToLower(mode)
+	if mode == "" && tz != "" {
+		mode = LogTimestampsLocal
+	}
+	switch mode {
+	case "", LogTimestampsUTC:
+		return LogTimestamps{Mode: LogTimestampsUTC}, nil
+	case LogTimestampsNone, LogTimestampsRelative:
+		return LogTimestamps{Mode: mode}, nil
+	case LogTimestampsLocal:
+		loc := time.Local
+		if tz != "" {
+			var err error
+			if loc, err = time.LoadLocation(tz); err != nil {
+				return LogTimestamps{}, fmt.Errorf("invalid time zone %q: %w", tz, err)
+			}
+		}
+		return LogTimestamps{Mode: mode, Location: loc}, nil
+	}
+	return LogTimestamps{}, fmt.Errorf("invalid timestamps mode %q (want utc, none, local or relative)", mode)
+}
+
+// Docker reports whether Docker should prefix lines with timestamps
+func (t LogTimestamps) Docker() bool {
+	return t.Mode != LogTimestampsNone
+}
+
+// Format rewrites the Docker timestamp at the start of each line of logs,
+// which must have been retrieved with Docker() timestamps. Lines without
+// one (e.g. partial TTY output) are left as they are.
+func (t LogTimestamps) Format(logs string, now time.Time) string {
+	if t.Mode != LogTimestampsLocal && t.Mode != LogTimestampsRelative {
+		return logs
+	}
+	lines := strings.SplitAfter(logs, "\n")
+	for i, line := range lines {
+		space := strings.IndexByte(line, ' ')
+		if space < 0 {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, line[:space])
+		if err != nil {
+			continue
+		}
+		var formatted string
+		if t.Mode == LogTimestampsLocal {
+			formatted = ts.In(t.Location).Format(time.RFC3339Nano)
+		} else {
+			formatted = formatAge(now.Sub(ts))
+		}
+		lines[i] = formatted + line[space:]
+	}
+	return strings.Join(lines, "")
+}
+
+// formatAge renders how long ago a line was logged in its largest unit
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Second:
+		return "now"
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age/time.Second))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age/time.Hour))
+	}
+	return fmt.Sprintf("%dd ago", int(age/(24*time.Hour)))
+}
@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLogTimestamps(t *testing.T) {
+	if ts, err := ParseLogTimestamps("", ""); err != nil || ts.Mode != LogTimestampsUTC || !ts.Docker() {
+		t.Errorf("default = %+v, %v", ts, err)
+	}
+	if ts, err := ParseLogTimestamps("", "Europe/Berlin"); err != nil || ts.Mode != LogTimestampsLocal || ts.Location.String() != "Europe/Berlin" {
+		t.Errorf("tz only = %+v, %v", ts, err)
+	}
+	if ts, err := ParseLogTimestamps("None", ""); err != nil || ts.Docker() {
+		t.Errorf("none = %+v, %v", ts, err)
+	}
+	for _, bad := range [][2]string{{"iso", ""}, {"local", "Mars/Olympus"}} {
+		if _, err := ParseLogTimestamps(bad[0], bad[1]); err == nil {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+}
+
+func TestLogTimestampsFormat(t *testing.T) {
+	logs := "2026-06-01T12:00:00.123456789Z GET / 200\n" +
+		"  continued without timestamp\n" +
+		"2026-06-01T11:57:30Z GET /health 200\n"
+	now := time.Date(2026, 6, 1, 12, 0, 5, 0, time.UTC)
+
+	local, _ := ParseLogTimestamps(LogTimestampsLocal, "Europe/Berlin")
+	want := "2026-06-01T14:00:00.123456789+02:00 GET / 200\n" +
+		"  continued without timestamp\n" +
+		"2026-06-01T13:57:30+02:00 GET /health 200\n"
+	if got := local.Format(logs, now); got != want {
+		t.Errorf("local:\n%s", got)
+	}
+
+	relative, _ := ParseLogTimestamps(LogTimestampsRelative, "")
+	want = "4s ago GET / 200\n" +
+		"  continued without timestamp\n" +
+		"2m ago GET /health 200\n"
+	if got := relative.Format(logs, now); got != want {
+		t.Errorf("relative:\n%s", got)
+	}
+
+	if got := (LogTimestamps{}).Format(logs, now); got != logs {
+		t.Errorf("utc changed the logs:\n%s", got)
+	}
+}
@@ -0,0 +1,382 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Network tests answer "why can't my app reach the database" from where the
+// app actually is: a helper container joins the target container's network
+// namespace, so it shares its interfaces, routes, DNS configuration and
+// /etc/hosts, and each check runs there as an exec. The target itself is
+// never exec'd into, so images without a shell or network tools work too.
+
+// Network test check types
+const (
+	NetCheckDNS  = "dns"  // Resolve Host through the container's DNS servers (not /etc/hosts)
+	NetCheckTCP  = "tcp"  // Connect to Host:Port
+	NetCheckHTTP = "http" // GET URL and report the status
+)
+
+const (
+	// DefaultNetTestImage runs the checks. It needs nslookup, nc and wget,
+	// and for https URLs a wget that speaks TLS: Alpine's busybox wget uses
+	// its ssl_client, plain busybox images have none and fail every https
+	// check.
+	DefaultNetTestImage = "alpine:3"
+	// MaxNetChecks caps the checks in one request
+	MaxNetChecks = 20
+
+	// netTestPullTimeout bounds pulling the helper image
+	netTestPullTimeout = 2 * time.Minute
+	// netCheckGrace is added to a check's timeout before the exec is
+	// abandoned, so the tool's own timeout usually reports first
+	netCheckGrace = 2 * time.Second
+	// maxNetCheckOutput caps the output kept per check
+	maxNetCheckOutput = 4 << 10
+	// netTestHelperLabel marks helper containers
+	netTestHelperLabel = "com.dockmon.helper"
+)
+
+// httpStatusPattern finds the status in wget's "HTTP/1.1 200 OK" lines
+var httpStatusPattern = regexp.MustCompile(`HTTP/[0-9.]+ ([0-9]{3})`)
+
+// NetCheck is one connectivity check
+type NetCheck struct {
+	Type string `json:"type"`
+	Host string `json:"host,omitempty"` // dns and tcp
+	Port int    `json:"port,omitempty"` // tcp
+	URL  string `json:"url,omitempty"`  // http
+	// ExpectStatus is the HTTP status that passes; 0 passes anything below 400
+	ExpectStatus int `json:"expect_status,omitempty"`
+}
+
+// NetTestRequest asks for checks run from a container's network namespace
+type NetTestRequest struct {
+	ContainerID    string     `json:"container_id"`
+	Checks         []NetCheck `json:"checks"`
+	TimeoutSeconds int        `json:"timeout_seconds,omitempty"` // Per check; default 5, at most 30
+	HelperImage    string     `json:"helper_image,omitempty"`    // Default DefaultNetTestImage, whose tools it must provide
+}
+
+// NetCheckResult is the outcome of one check. A failed check is a result,
+// not an error.
+type NetCheckResult struct {
+	NetCheck
+	OK        bool     `json:"ok"`
+	LatencyMS float64  `json:"latency_ms"`          // Includes a few ms of exec overhead
+	Addresses []string `json:"addresses,omitempty"` // dns
+	Status    int      `json:"status,omitempty"`    // http
+	Error     string   `json:"error,omitempty"`
+	Output    string   `json:"output,omitempty"` // The tool's output, when the check failed
+}
+
+// NetTestResult holds every check's outcome
+type NetTestResult struct {
+	ContainerID   string           `json:"container_id"`
+	ContainerName string           `json:"container_name"`
+	NetworkMode   string           `json:"network_mode"`
+	Networks      []string         `json:"networks,omitempty"`
+	OK            bool             `json:"ok"` // Every check passed
+	Checks        []NetCheckResult `json:"checks"`
+}
+
+// Validate checks the request before anything is started
+func (r *NetTestRequest) Validate() error {
+	if r.ContainerID == "" {
+		return fmt.Errorf("container_id is required")
+	}
+	if len(r.Checks) == 0 || len(r.Checks) > MaxNetChecks {
+		return fmt.Errorf("between 1 and %d checks are required", MaxNetChecks)
+	}
+	if timeout := time.Duration(r.TimeoutSeconds) * time.Second; timeout < 0 || timeout > MaxProbeTimeout {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", int(MaxProbeTimeout/time.Second))
+	}
+	for i, c := range r.Checks {
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("check %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c NetCheck) validate() error {
+	switch c.Type {
+	case NetCheckDNS, NetCheckTCP:
+		// Hosts become tool arguments, so they must not look like options
+		if c.Host == "" || strings.HasPrefix(c.Host, "-") || strings.ContainsAny(c.Host, " \t\r\n/") {
+			return fmt.Errorf("invalid host %q", c.Host)
+		}
+		if c.Type == NetCheckTCP && (c.Port < 1 || c.Port > 65535) {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+	case NetCheckHTTP:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url %q: must be an http or https URL", c.URL)
+		}
+		if c.ExpectStatus != 0 && (c.ExpectStatus < 100 || c.ExpectStatus > 599) {
+			return fmt.Errorf("expect_status must be an HTTP status")
+		}
+	default:
+		return fmt.Errorf("invalid type %q (must be dns, tcp or http)", c.Type)
+	}
+	return nil
+}
+
+// command is the helper command running the check
+func (c NetCheck) command(timeout time.Duration) []string {
+	secs := strconv.Itoa(int(timeout / time.Second))
+	switch c.Type {
+	case NetCheckDNS:
+		return []string{"nslookup", c.Host}
+	case NetCheckTCP:
+		return []string{"nc", "-z", "-w", secs, c.Host, strconv.Itoa(c.Port)}
+	}
+	return []string{"wget", "-q", "-S", "-O", "/dev/null", "-T", secs, c.URL}
+}
+
+// RunNetTest runs the request's checks from inside the container's network
+// namespace. Only problems with the request or the helper fail; checks that
+// fail are reported in the result.
+func RunNetTest(ctx context.Context, cli client.APIClient, req NetTestRequest) (*NetTestResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	target, err := cli.ContainerInspect(ctx, req.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if target.State == nil || !target.State.Running {
+		return nil, fmt.Errorf("container %s is not running", strings.TrimPrefix(target.Name, "/"))
+	}
+	result := &NetTestResult{
+		ContainerID:   target.ID,
+		ContainerName: strings.TrimPrefix(target.Name, "/"),
+		OK:            true,
+	}
+	if target.HostConfig != nil {
+		result.NetworkMode = string(target.HostConfig.NetworkMode)
+	}
+	if target.NetworkSettings != nil {
+		for name := range target.NetworkSettings.Networks {
+			result.Networks = append(result.Networks, name)
+		}
+		sort.Strings(result.Networks)
+	}
+
+	helperID, err := startNetTestHelper(ctx, cli, target.ID, req.HelperImage, timeout, len(req.Checks))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = cli.ContainerRemove(removeCtx, helperID, container.RemoveOptions{Force: true})
+	}()
+
+	for _, check := range req.Checks {
+		r := runNetCheck(ctx, cli, helperID, check, timeout)
+		result.OK = result.OK && r.OK
+		result.Checks = append(result.Checks, r)
+	}
+	return result, nil
+}
+
+// startNetTestHelper starts an idle helper in the target's network
+// namespace. It exits on its own once every check could have timed out, in
+// case removing it fails.
+func startNetTestHelper(ctx context.Context, cli client.APIClient, targetID, helperImage string, timeout time.Duration, checks int) (string, error) {
+	if helperImage == "" {
+		helperImage = DefaultNetTestImage
+	}
+	if _, err := cli.ImageInspect(ctx, helperImage); err != nil {
+		pullCtx, cancel := context.WithTimeout(ctx, netTestPullTimeout)
+		reader, err := cli.ImagePull(pullCtx, helperImage, image.PullOptions{})
+		if err != nil {
+			cancel()
+			return "", fmt.Errorf("failed to pull network test image %s: %w", helperImage, err)
+		}
+		_, _ = io.Copy(io.Discard, reader)
+		reader.Close()
+		cancel()
+	}
+
+	lifetime := time.Duration(checks)*(timeout+netCheckGrace) + time.Minute
+	resp, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:      helperImage,
+			Entrypoint: []string{"sleep", strconv.Itoa(int(lifetime / time.Second))},
+			Labels:     map[string]string{netTestHelperLabel: "net-test"},
+		},
+		&container.HostConfig{
+			NetworkMode:    container.NetworkMode("container:" + targetID),
+			CapDrop:        []string{"ALL"},
+			SecurityOpt:    []string{"no-new-privileges"},
+			ReadonlyRootfs: true,
+			LogConfig:      container.LogConfig{Type: "none"},
+		},
+		nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create network test container: %w", err)
+	}
+	if err := cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		_ = cli.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+		return "", fmt.Errorf("failed to start network test container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// runNetCheck runs one check as an exec in the helper
+func runNetCheck(ctx context.Context, cli client.APIClient, helperID string, check NetCheck, timeout time.Duration) NetCheckResult {
+	result := NetCheckResult{NetCheck: check}
+	ctx, cancel := context.WithTimeout(ctx, timeout+netCheckGrace)
+	defer cancel()
+
+	start := time.Now()
+	output, exitCode, err := execCommand(ctx, cli, helperID, check.command(timeout))
+	result.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Error = err.Error()
+		result.Output = output
+		return result
+	}
+
+	switch check.Type {
+	case NetCheckDNS:
+		result.Addresses = parseNslookup(output)
+		result.OK = len(result.Addresses) > 0
+		if !result.OK {
+			result.Error = "name did not resolve"
+		}
+	case NetCheckTCP:
+		result.OK = exitCode == 0
+		if !result.OK {
+			result.Error = fmt.Sprintf("connection to %s failed", net.JoinHostPort(check.Host, strconv.Itoa(check.Port)))
+		}
+	case NetCheckHTTP:
+		if m := httpStatusPattern.FindAllStringSubmatch(output, -1); len(m) > 0 {
+			// The last status follows any redirects
+			result.Status, _ = strconv.Atoi(m[len(m)-1][1])
+		}
+		switch {
+		case result.Status == 0:
+			result.Error = "no HTTP response"
+		case check.ExpectStatus != 0 && result.Status != check.ExpectStatus:
+			result.Error = fmt.Sprintf("status %d, expected %d", result.Status, check.ExpectStatus)
+		case check.ExpectStatus == 0 && result.Status >= 400:
+			result.Error = fmt.Sprintf("status %d", result.Status)
+		default:
+			result.OK = true
+		}
+	}
+	if !result.OK {
+		result.Output = output
+	}
+	return result
+}
+
+// execCommand runs cmd in containerID, returning its combined output and
+// exit code. The exec is abandoned when ctx ends.
+func execCommand(ctx context.Context, cli client.APIClient, containerID string, cmd []string) (string, int, error) {
+	execResp, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to create exec: %w", err)
+	}
+	attach, err := cli.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+	defer attach.Close()
+
+	output := &cappedOutput{limit: maxNetCheckOutput}
+	copyDone := make(chan struct{})
+	go func() {
+		_, _ = stdcopy.StdCopy(output, output, attach.Reader)
+		close(copyDone)
+	}()
+	select {
+	case <-copyDone:
+	case <-ctx.Done():
+		// Closing the connection unblocks StdCopy; Docker can't kill an exec,
+		// but it goes away with the helper
+		attach.Close()
+		<-copyDone
+		return output.String(), -1, fmt.Errorf("timed out")
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return output.String(), -1, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return output.String(), inspect.ExitCode, nil
+}
+
+// parseNslookup returns the answer addresses in nslookup output, skipping
+// the DNS server's address printed before the first "Name:" line. Handles
+// both "Address: 10.0.0.2" and the older "Address 1: 10.0.0.2 name".
+func parseNslookup(output string) []string {
+	var addrs []string
+	answers := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Name:") {
+			answers = true
+			continue
+		}
+		if !answers || !strings.HasPrefix(line, "Address") {
+			continue
+		}
+		_, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if fields := strings.Fields(value); len(fields) > 0 && net.ParseIP(fields[0]) != nil {
+			addrs = append(addrs, fields[0])
+		}
+	}
+	return addrs
+}
+
+// cappedOutput keeps the first limit bytes written and drops the rest
+type cappedOutput struct {
+	buf   strings.Builder
+	limit int
+}
+
+func (w *cappedOutput) Write(p []byte) (int, error) {
+	if remaining := w.limit - w.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			w.buf.Write(p[:remaining])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (w *cappedOutput) String() string {
+	return w.buf.String()
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+func TestNetTestRequestValidate(t *testing.T) {
+	valid := NetTestRequest{ContainerID: "abc", Checks: []NetCheck{
+		{Type: NetCheckDNS, Host: "db"},
+		{Type: NetCheckTCP, Host: "db", Port: 5432},
+		{Type: NetCheckHTTP, URL: "http://api:8080/health", ExpectStatus: 204},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+
+	for name, check := range map[string]NetCheck{
+		"unknown type":    {Type: "icmp", Host: "db"},
+		"missing host":    {Type: NetCheckDNS},
+		"option-like":     {Type: NetCheckDNS, Host: "-debug"},
+		"host with space": {Type: NetCheckTCP, Host: "db 1", Port: 80},
+		"port 0":          {Type: NetCheckTCP, Host: "db"},
+		"port too large":  {Type: NetCheckTCP, Host: "db", Port: 70000},
+		"ftp url":         {Type: NetCheckHTTP, URL: "ftp://files/x"},
+		"bad status":      {Type: NetCheckHTTP, URL: "http://api", ExpectStatus: 42},
+	} {
+		req := NetTestRequest{ContainerID: "abc", Checks: []NetCheck{check}}
+		if err := req.Validate(); err == nil {
+			t.Errorf("%s: accepted %+v", name, check)
+		}
+	}
+
+	for _, req := range []NetTestRequest{
+		{Checks: valid.Checks},
+		{ContainerID: "abc"},
+		{ContainerID: "abc", Checks: make([]NetCheck, MaxNetChecks+1)},
+		{ContainerID: "abc", Checks: valid.Checks, TimeoutSeconds: 31},
+	} {
+		if err := req.Validate(); err == nil {
+			t.Errorf("accepted %+v", req)
+		}
+	}
+}
+
+func TestNetCheckCommand(t *testing.T) {
+	timeout := 3 * time.Second
+	for _, tt := range []struct {
+		check NetCheck
+		want  []string
+	}{
+		{NetCheck{Type: NetCheckDNS, Host: "db"}, []string{"nslookup", "db"}},
+		{NetCheck{Type: NetCheckTCP, Host: "db", Port: 5432}, []string{"nc", "-z", "-w", "3", "db", "5432"}},
+		{NetCheck{Type: NetCheckHTTP, URL: "http://api/health"}, []string{"wget", "-q", "-S", "-O", "/dev/null", "-T", "3", "http://api/health"}},
+	} {
+		if got := tt.check.command(timeout); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("command(%+v) = %q, want %q", tt.check, got, tt.want)
+		}
+	}
+}
+
+func TestParseNslookup(t *testing.T) {
+	current := `Server:		127.0.0.11
+Address:	127.0.0.11:53
+
+Non-authoritative answer:
+Name:	db
+Address: 172.18.0.2
+
+Non-authoritative answer:
+Name:	db
+Address: fd00::2
+`
+	if got := parseNslookup(current); !reflect.DeepEqual(got, []string{"172.18.0.2", "fd00::2"}) {
+		t.Errorf("current format: %q", got)
+	}
+
+	older := `Server:    127.0.0.11
+Address 1: 127.0.0.11
+
+Name:      db
+Address 1: 172.18.0.2 app_db_1.app_default
+`
+	if got := parseNslookup(older); !reflect.DeepEqual(got, []string{"172.18.0.2"}) {
+		t.Errorf("older format: %q", got)
+	}
+
+	nxdomain := `Server:		127.0.0.11
+Address:	127.0.0.11:53
+
+** server can't find nope: NXDOMAIN
+`
+	if got := parseNslookup(nxdomain); len(got) != 0 {
+		t.Errorf("NXDOMAIN: %q", got)
+	}
+}
+
+func TestRunNetTest_StoppedContainer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/containers/web/json") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Id":"web","Name":"/web","State":{"Running":false,"Status":"exited"}}`))
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost(tcpAddress(srv)), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	req := NetTestRequest{ContainerID: "web", Checks: []NetCheck{{Type: NetCheckDNS, Host: "db"}}}
+	if _, err := RunNetTest(context.Background(), cli, req); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("err = %v, want not running", err)
+	}
+}
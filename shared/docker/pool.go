@@ -0,0 +1,307 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Pool defaults
+const (
+	// DefaultPoolIdleTTL is how long an unused client is kept before it is
+	// closed
+	DefaultPoolIdleTTL = 10 * time.Minute
+	// DefaultPoolCheckInterval is how often pooled clients are pinged
+	DefaultPoolCheckInterval = 30 * time.Second
+
+	// poolPingTimeout bounds each health check Ping
+	poolPingTimeout = 5 * time.Second
+)
+
+// ErrPoolClosed is returned by Acquire after the pool has been closed
+var ErrPoolClosed = errors.New("docker client pool is closed")
+
+// ClientFactory creates a new Docker client for a pool entry
+type ClientFactory func() (*client.Client, error)
+
+// Pool shares Docker clients between callers, keyed by host and TLS
+// material (see PoolKey). Clients are reference counted through Leases:
+// unused clients are closed after the idle TTL, and clients that fail a
+// health check Ping are retired and recreated on next use.
+type Pool struct {
+	mu            sync.Mutex
+	entries       map[string]*poolEntry
+	idleTTL       time.Duration
+	checkInterval time.Duration
+	closed        bool
+	clientOpts    []client.Opt // Extra options for clients AcquireHost creates
+
+	now func() time.Time // Injectable clock for tests
+}
+
+// poolEntry is one pooled client. A retired entry is no longer handed out;
+// it is closed once its last lease lets go.
+type poolEntry struct {
+	key      string
+	cli      *client.Client
+	factory  ClientFactory
+	refs     int
+	lastUsed time.Time
+	retired  bool
+}
+
+// NewPool creates a client pool. Zero durations use DefaultPoolIdleTTL and
+// DefaultPoolCheckInterval.
+func NewPool(idleTTL, checkInterval time.Duration) *Pool {
+	if idleTTL <= 0 {
+		idleTTL = DefaultPoolIdleTTL
+	}
+	if checkInterval <= 0 {
+		checkInterval = DefaultPoolCheckInterval
+	}
+	return &Pool{
+		entries:       make(map[string]*poolEntry),
+		idleTTL:       idleTTL,
+		checkInterval: checkInterval,
+		now:           time.Now,
+	}
+}
+
+// PoolKey identifies a connection: the host address plus a fingerprint of
+// its TLS material, so the PEM data itself isn't kept as a map key.
+func PoolKey(hostAddress, caCertPEM, certPEM, keyPEM string) string {
+	h := sha256.New()
+	for _, part := range []string{caCertPEM, certPEM, keyPEM} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hostAddress + "#" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Acquire returns a lease on the pooled client for key, creating it with
+// factory if there is none. The caller must Release the lease.
+func (p *Pool) Acquire(key string, factory ClientFactory) (*Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, err := p.getLocked(key, factory)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{pool: p, entry: entry}, nil
+}
+
+// SetClientOptions sets extra options (e.g. a wrapping transport) for the
+// clients AcquireHost creates from now on. Clients already pooled keep the
+// options they were created with.
+func (p *Pool) SetClientOptions(opts ...client.Opt) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clientOpts = opts
+}
+
+// AcquireHost leases a client for a Docker host: the local daemon (from the
+// environment) when hostAddress is empty, otherwise a remote host using TLS
+// when all three PEM values are set.
+func (p *Pool) AcquireHost(hostAddress, caCertPEM, certPEM, keyPEM string) (*Lease, error) {
+	p.mu.Lock()
+	opts := p.clientOpts
+	p.mu.Unlock()
+
+	if hostAddress == "" {
+		return p.Acquire(PoolKey("", "", "", ""), func() (*client.Client, error) {
+			return CreateLocalClient(opts...)
+		})
+	}
+	return p.Acquire(PoolKey(hostAddress, caCertPEM, certPEM, keyPEM), func() (*client.Client, error) {
+		return CreateRemoteClient(hostAddress, caCertPEM, certPEM, keyPEM, opts...)
+	})
+}
+
+// Get is a shorthand for short-lived use: it leases the client for a host
+// and returns it with the function that releases it.
+func (p *Pool) Get(hostAddress, caCertPEM, certPEM, keyPEM string) (*client.Client, func(), error) {
+	lease, err := p.AcquireHost(hostAddress, caCertPEM, certPEM, keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lease.Client(), lease.Release, nil
+}
+
+// getLocked returns the live entry for key with a reference taken, creating
+// it if needed. Must be called with p.mu held.
+func (p *Pool) getLocked(key string, factory ClientFactory) (*poolEntry, error) {
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	entry, ok := p.entries[key]
+	if !ok {
+		// Creating a client doesn't connect, so this is cheap enough to do
+		// under the lock and keeps concurrent callers from racing
+		cli, err := factory()
+		if err != nil {
+			return nil, err
+		}
+		entry = &poolEntry{key: key, cli: cli, factory: factory}
+		p.entries[key] = entry
+	}
+	entry.refs++
+	entry.lastUsed = p.now()
+	return entry, nil
+}
+
+// releaseLocked drops a reference, closing the client if the entry was
+// retired and this was the last one. Must be called with p.mu held.
+func (p *Pool) releaseLocked(entry *poolEntry) {
+	entry.refs--
+	entry.lastUsed = p.now()
+	if entry.retired && entry.refs <= 0 {
+		entry.cli.Close()
+	}
+}
+
+// retireLocked stops handing out an entry, closing it now if unused. Must
+// be called with p.mu held.
+func (p *Pool) retireLocked(entry *poolEntry) {
+	if p.entries[entry.key] == entry {
+		delete(p.entries, entry.key)
+	}
+	entry.retired = true
+	if entry.refs <= 0 {
+		entry.cli.Close()
+	}
+}
+
+// Invalidate retires the client for key, so the next use creates a new one.
+// Callers holding a lease move to the new client the next time they call
+// Lease.Client.
+func (p *Pool) Invalidate(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.entries[key]; ok {
+		p.retireLocked(entry)
+	}
+}
+
+// Len returns the number of live clients in the pool
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// Run health-checks and evicts pooled clients every check interval until
+// ctx is cancelled.
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.check(ctx)
+		}
+	}
+}
+
+// check closes clients that have been unused for the idle TTL and pings the
+// rest, retiring any that don't answer.
+func (p *Pool) check(ctx context.Context) {
+	p.mu.Lock()
+	now := p.now()
+	var live []*poolEntry
+	for _, entry := range p.entries {
+		if entry.refs <= 0 && now.Sub(entry.lastUsed) >= p.idleTTL {
+			p.retireLocked(entry)
+			continue
+		}
+		live = append(live, entry)
+	}
+	p.mu.Unlock()
+
+	// Ping concurrently so one slow host doesn't delay the others
+	failed := make([]bool, len(live))
+	var wg sync.WaitGroup
+	for i, entry := range live {
+		wg.Add(1)
+		go func(i int, cli *client.Client) {
+			defer wg.Done()
+			pingCtx, cancel := context.WithTimeout(ctx, poolPingTimeout)
+			defer cancel()
+			if _, err := cli.Ping(pingCtx); err != nil && ctx.Err() == nil {
+				failed[i] = true
+			}
+		}(i, entry.cli)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, entry := range live {
+		if failed[i] && !entry.retired {
+			p.retireLocked(entry)
+		}
+	}
+}
+
+// Close closes every pooled client. Leases still held keep working against
+// a closed client until released; further Acquires fail.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, entry := range p.entries {
+		entry.retired = true
+		entry.cli.Close()
+	}
+	p.entries = make(map[string]*poolEntry)
+	p.closed = true
+}
+
+// Lease is a hold on a pooled client. Long-lived holders should call Client
+// for each use rather than keeping the returned client, so they pick up a
+// replacement after a failed health check.
+type Lease struct {
+	pool     *Pool
+	mu       sync.Mutex
+	entry    *poolEntry
+	released bool
+}
+
+// Client returns the lease's client. If it has been retired, the lease moves
+// to a fresh client for the same key; if that can't be created the old one
+// is returned, and the move is retried on the next call.
+func (l *Lease) Client() *client.Client {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p := l.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if l.entry.retired && !l.released && !p.closed {
+		if entry, err := p.getLocked(l.entry.key, l.entry.factory); err == nil {
+			p.releaseLocked(l.entry)
+			l.entry = entry
+		}
+	}
+	return l.entry.cli
+}
+
+// Release gives the client back to the pool. Safe to call more than once.
+func (l *Lease) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return
+	}
+	l.released = true
+
+	l.pool.mu.Lock()
+	defer l.pool.mu.Unlock()
+	l.pool.releaseLocked(l.entry)
+}
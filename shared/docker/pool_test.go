@@ -0,0 +1,127 @@
+package docker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// newTestPool returns a pool whose clock is advanced by the returned func
+func newTestPool() (*Pool, func(time.Duration)) {
+	p := NewPool(time.Minute, time.Hour)
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	return p, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestPoolSharesClientsByKey(t *testing.T) {
+	p, _ := newTestPool()
+	defer p.Close()
+
+	a, err := p.AcquireHost("tcp://127.0.0.1:1", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := p.AcquireHost("tcp://127.0.0.1:1", "", "", "")
+	other, _ := p.AcquireHost("tcp://127.0.0.1:2", "", "", "")
+	defer a.Release()
+	defer b.Release()
+	defer other.Release()
+
+	if a.Client() != b.Client() {
+		t.Error("same host should share a client")
+	}
+	if a.Client() == other.Client() || p.Len() != 2 {
+		t.Errorf("different hosts should get separate clients, pool has %d", p.Len())
+	}
+	if PoolKey("tcp://h:2376", "ca", "cert", "key") == PoolKey("tcp://h:2376", "ca", "cert", "other") {
+		t.Error("TLS material should be part of the key")
+	}
+}
+
+func TestPoolEvictsIdleClients(t *testing.T) {
+	p, advance := newTestPool()
+	defer p.Close()
+
+	// A held client is never idle, but one that doesn't answer is retired
+	lease, _ := p.AcquireHost("tcp://127.0.0.1:1", "", "", "")
+	advance(2 * time.Minute)
+	p.check(context.Background())
+	if p.Len() != 0 || !lease.entry.retired {
+		t.Fatalf("pool has %d clients after a failed ping", p.Len())
+	}
+	lease.Release()
+
+	srv := fakeDaemon(t, 200)
+	lease, _ = p.AcquireHost(tcpAddress(srv), "", "", "")
+	lease.Release()
+	p.check(context.Background())
+	if p.Len() != 1 {
+		t.Fatal("healthy client used recently should be kept")
+	}
+	advance(time.Minute)
+	p.check(context.Background())
+	if p.Len() != 0 {
+		t.Error("client idle for the TTL should be evicted")
+	}
+}
+
+func TestLeaseMovesToNewClientAfterFailure(t *testing.T) {
+	p, _ := newTestPool()
+	defer p.Close()
+
+	srv := fakeDaemon(t, 200)
+	lease, _ := p.AcquireHost(tcpAddress(srv), "", "", "")
+	defer lease.Release()
+	first := lease.Client()
+
+	p.check(context.Background())
+	if lease.Client() != first {
+		t.Fatal("healthy client should be kept")
+	}
+
+	srv.Close()
+	p.check(context.Background())
+	replacement := lease.Client()
+	if replacement == first {
+		t.Fatal("lease should move to a new client after a failed ping")
+	}
+	if p.Len() != 1 || lease.Client() != replacement {
+		t.Errorf("pool has %d clients, want the replacement only", p.Len())
+	}
+
+	// The retired entry was closed once the lease moved off it
+	lease.Release()
+	lease.Release()
+	if p.entries[lease.entry.key].refs != 0 {
+		t.Errorf("refs = %d after release", p.entries[lease.entry.key].refs)
+	}
+}
+
+func TestPoolAppliesClientOptions(t *testing.T) {
+	p, _ := newTestPool()
+	defer p.Close()
+
+	applied := 0
+	p.SetClientOptions(func(*client.Client) error {
+		applied++
+		return nil
+	})
+	local, _ := p.AcquireHost("", "", "", "")
+	remote, _ := p.AcquireHost("tcp://127.0.0.1:1", "", "", "")
+	defer local.Release()
+	defer remote.Release()
+	if applied != 2 {
+		t.Errorf("options applied to %d clients, want 2", applied)
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p, _ := newTestPool()
+	p.Close()
+	if _, err := p.AcquireHost("", "", "", ""); err != ErrPoolClosed {
+		t.Errorf("Acquire after Close: err = %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Probe timeouts: DefaultProbeTimeout applies when none is given, and
+// callers taking a timeout from a request should cap it at MaxProbeTimeout
+const (
+	DefaultProbeTimeout = 5 * time.Second
+	MaxProbeTimeout     = 30 * time.Second
+)
+
+// Stages at which a probe can fail
+const (
+	ProbeStageClient = "client" // Bad address or TLS material
+	ProbeStagePing   = "ping"   // Daemon unreachable or TLS handshake failed
+	ProbeStageInfo   = "info"   // Reachable, but /info failed
+)
+
+// ProbeResult is the outcome of a reachability probe. A host is Reachable
+// once it answers the ping; Info-derived fields may still be empty if the
+// later /info call fails.
+type ProbeResult struct {
+	Reachable     bool    `json:"reachable"`
+	LatencyMS     float64 `json:"latency_ms,omitempty"` // Round trip of the ping
+	APIVersion    string  `json:"api_version,omitempty"`
+	ServerVersion string  `json:"server_version,omitempty"`
+	EngineID      string  `json:"engine_id,omitempty"`
+	Name          string  `json:"name,omitempty"` // Daemon hostname
+	OSType        string  `json:"os_type,omitempty"`
+	Stage         string  `json:"stage,omitempty"` // Where the probe failed
+	Error         string  `json:"error,omitempty"`
+}
+
+// ProbeHost builds a short-lived client for hostAddress with the given TLS
+// material and probes it. The client is closed before returning.
+func ProbeHost(ctx context.Context, hostAddress, caCertPEM, certPEM, keyPEM string, timeout time.Duration) *ProbeResult {
+	cli, err := CreateRemoteClient(hostAddress, caCertPEM, certPEM, keyPEM)
+	if err != nil {
+		return &ProbeResult{Stage: ProbeStageClient, Error: err.Error()}
+	}
+	defer cli.Close()
+	return Probe(ctx, cli, timeout)
+}
+
+// Probe pings the daemon behind cli and reads its identity, all within
+// timeout (DefaultProbeTimeout if zero)
+func Probe(ctx context.Context, cli client.APIClient, timeout time.Duration) *ProbeResult {
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := &ProbeResult{}
+	start := time.Now()
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		result.Stage = ProbeStagePing
+		result.Error = err.Error()
+		return result
+	}
+	result.Reachable = true
+	result.LatencyMS = RoundToDecimal(float64(time.Since(start).Microseconds())/1000, 1)
+	result.APIVersion = ping.APIVersion
+	result.OSType = ping.OSType
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		result.Stage = ProbeStageInfo
+		result.Error = err.Error()
+		return result
+	}
+	result.EngineID = info.ID
+	result.ServerVersion = info.ServerVersion
+	result.Name = info.Name
+	if result.OSType == "" {
+		result.OSType = info.OSType
+	}
+	return result
+}
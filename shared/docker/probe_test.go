@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDaemon answers the two endpoints a probe uses
+func fakeDaemon(t *testing.T, infoStatus int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Header().Set("Api-Version", "1.45")
+			w.Header().Set("Ostype", "linux")
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			if infoStatus != http.StatusOK {
+				http.Error(w, `{"message":"boom"}`, infoStatus)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ID":"ENGINE-1","ServerVersion":"27.1.0","Name":"box"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func tcpAddress(srv *httptest.Server) string {
+	return "tcp://" + strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestProbeHost_Reachable(t *testing.T) {
+	srv := fakeDaemon(t, http.StatusOK)
+
+	result := ProbeHost(context.Background(), tcpAddress(srv), "", "", "", time.Second)
+	if !result.Reachable || result.Error != "" {
+		t.Fatalf("result = %+v, want reachable", result)
+	}
+	if result.APIVersion != "1.45" || result.EngineID != "ENGINE-1" || result.ServerVersion != "27.1.0" || result.Name != "box" || result.OSType != "linux" {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestProbeHost_InfoFails(t *testing.T) {
+	srv := fakeDaemon(t, http.StatusInternalServerError)
+
+	result := ProbeHost(context.Background(), tcpAddress(srv), "", "", "", time.Second)
+	if !result.Reachable || result.Stage != ProbeStageInfo || result.Error == "" {
+		t.Errorf("result = %+v, want reachable with an info error", result)
+	}
+}
+
+func TestProbeHost_Unreachable(t *testing.T) {
+	srv := fakeDaemon(t, http.StatusOK)
+	addr := tcpAddress(srv)
+	srv.Close()
+
+	result := ProbeHost(context.Background(), addr, "", "", "", time.Second)
+	if result.Reachable || result.Stage != ProbeStagePing || result.Error == "" {
+		t.Errorf("result = %+v, want a ping failure", result)
+	}
+}
+
+func TestProbeHost_BadTLS(t *testing.T) {
+	result := ProbeHost(context.Background(), "tcp://127.0.0.1:2376", "not a cert", "not a cert", "not a key", time.Second)
+	if result.Reachable || result.Stage != ProbeStageClient {
+		t.Errorf("result = %+v, want a client failure", result)
+	}
+}
@@ -1,32 +1,87 @@
 package docker
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/docker/docker/api/types/container"
 )
 
+// MemoryMode selects which memory figure StatsResult.MemoryUsage reports
+type MemoryMode string
+
+const (
+	// MemoryModeWorkingSet reports usage without reclaimable page cache,
+	// like cAdvisor and Kubernetes. This is the default.
+	MemoryModeWorkingSet MemoryMode = "working_set"
+	// MemoryModeRaw reports the cgroup's raw usage, page cache included
+	MemoryModeRaw MemoryMode = "raw"
+)
+
+// ParseMemoryMode validates a memory mode name; "" yields MemoryModeWorkingSet
+func ParseMemoryMode(s string) (MemoryMode, error) {
+	switch m := MemoryMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return MemoryModeWorkingSet, nil
+	case MemoryModeWorkingSet, MemoryModeRaw:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown memory mode %q (want working_set or raw)", s)
+	}
+}
+
+// LabelGroup puts a container in a stats group, whose usage the
+// stats-service sums across hosts and compose projects
+const LabelGroup = "dockmon.group"
+
+// ContainerGroup returns the stats group a container's labels name, or ""
+func ContainerGroup(labels map[string]string) string {
+	return strings.TrimSpace(labels[LabelGroup])
+}
+
 // StatsResult contains calculated container statistics
 type StatsResult struct {
 	CPUPercent    float64
-	MemoryUsage   uint64  // Working set memory (excludes reclaimable cache)
+	MemoryUsage   uint64  // Per the MemoryMode; working set by default
 	MemoryLimit   uint64
 	MemoryPercent float64
 	NetworkRx     uint64
 	NetworkTx     uint64
 	DiskRead      uint64
 	DiskWrite     uint64
+
+	// Both memory figures, whichever mode MemoryUsage follows
+	MemoryRawUsage   uint64 // Raw cgroup usage, page cache included
+	MemoryWorkingSet uint64 // Usage excluding reclaimable cache
+
+	// PerCPUPercent is the container's use of each core, 100 being one
+	// core fully busy. Only cgroups v1 reports it; nil elsewhere.
+	PerCPUPercent []float64
 }
 
 // CalculateStats processes raw Docker stats and returns calculated metrics
 // This is the proven, battle-tested logic from stats-service
 func CalculateStats(stat *container.StatsResponse) *StatsResult {
+	return CalculateStatsWithMode(stat, MemoryModeWorkingSet)
+}
+
+// CalculateStatsWithMode is CalculateStats with MemoryUsage and
+// MemoryPercent following mode
+func CalculateStatsWithMode(stat *container.StatsResponse, mode MemoryMode) *StatsResult {
 	result := &StatsResult{}
 
 	// Calculate CPU percentage
 	result.CPUPercent = calculateCPUPercent(stat)
+	result.PerCPUPercent = calculatePerCPUPercent(stat)
 
 	// Calculate memory stats - working set (excludes reclaimable cache)
 	// This matches what Kubernetes, cAdvisor, and Proxmox report
-	result.MemoryUsage = calculateWorkingSetMemory(stat)
+	result.MemoryWorkingSet = calculateWorkingSetMemory(stat)
+	result.MemoryRawUsage = stat.MemoryStats.Usage
+	result.MemoryUsage = result.MemoryWorkingSet
+	if mode == MemoryModeRaw {
+		result.MemoryUsage = result.MemoryRawUsage
+	}
 	result.MemoryLimit = stat.MemoryStats.Limit
 
 	if result.MemoryLimit > 0 {
@@ -39,14 +94,8 @@ func CalculateStats(stat *container.StatsResponse) *StatsResult {
 		result.NetworkTx += net.TxBytes
 	}
 
-	// Disk I/O stats
-	for _, bio := range stat.BlkioStats.IoServiceBytesRecursive {
-		if bio.Op == "Read" {
-			result.DiskRead += bio.Value
-		} else if bio.Op == "Write" {
-			result.DiskWrite += bio.Value
-		}
-	}
+	// Disk I/O stats (see CgroupIOReader for hosts that report none)
+	result.DiskRead, result.DiskWrite = diskIOFromBlkio(stat)
 
 	return result
 }
@@ -74,6 +123,30 @@ func calculateCPUPercent(stat *container.StatsResponse) float64 {
 	return 0.0
 }
 
+// calculatePerCPUPercent splits the CPU usage by core from PercpuUsage.
+// The system delta covers every online core, so one core's capacity is an
+// nth of it.
+func calculatePerCPUPercent(stat *container.StatsResponse) []float64 {
+	cur := stat.CPUStats.CPUUsage.PercpuUsage
+	prev := stat.PreCPUStats.CPUUsage.PercpuUsage
+	systemDelta := float64(stat.CPUStats.SystemUsage) - float64(stat.PreCPUStats.SystemUsage)
+	if len(cur) == 0 || len(cur) != len(prev) || systemDelta <= 0 {
+		return nil
+	}
+	numCPUs := len(cur)
+	if stat.CPUStats.OnlineCPUs > 0 {
+		numCPUs = int(stat.CPUStats.OnlineCPUs)
+	}
+	perCore := systemDelta / float64(numCPUs)
+	result := make([]float64, len(cur))
+	for i := range cur {
+		if cur[i] > prev[i] {
+			result[i] = float64(cur[i]-prev[i]) / perCore * 100.0
+		}
+	}
+	return result
+}
+
 // calculateWorkingSetMemory calculates working set memory (actual usage excluding reclaimable cache)
 // Supports both cgroups v1 and v2
 //
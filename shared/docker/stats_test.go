@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestParseMemoryMode(t *testing.T) {
+	for in, want := range map[string]MemoryMode{"": MemoryModeWorkingSet, "working_set": MemoryModeWorkingSet, " RAW ": MemoryModeRaw} {
+		if got, err := ParseMemoryMode(in); err != nil || got != want {
+			t.Errorf("ParseMemoryMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMemoryMode("rss"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestCalculateStatsWithMode_Memory(t *testing.T) {
+	const mib = 1024 * 1024
+	v1 := &container.StatsResponse{}
+	v1.MemoryStats.Usage = 600 * mib
+	v1.MemoryStats.Limit = 1000 * mib
+	v1.MemoryStats.Stats = map[string]uint64{"inactive_file": 200 * mib}
+
+	v2 := &container.StatsResponse{}
+	v2.MemoryStats.Usage = 600 * mib
+	v2.MemoryStats.Limit = 1000 * mib
+	v2.MemoryStats.Stats = map[string]uint64{"anon": 250 * mib, "active_file": 50 * mib, "inactive_file": 300 * mib}
+
+	tests := []struct {
+		name        string
+		stat        *container.StatsResponse
+		mode        MemoryMode
+		wantUsage   uint64
+		wantPercent float64
+		wantWorking uint64
+	}{
+		{"cgroup v1 working set", v1, MemoryModeWorkingSet, 400 * mib, 40, 400 * mib},
+		{"cgroup v1 raw", v1, MemoryModeRaw, 600 * mib, 60, 400 * mib},
+		{"cgroup v2 working set", v2, MemoryModeWorkingSet, 300 * mib, 30, 300 * mib},
+		{"cgroup v2 raw", v2, MemoryModeRaw, 600 * mib, 60, 300 * mib},
+	}
+	for _, tt := range tests {
+		got := CalculateStatsWithMode(tt.stat, tt.mode)
+		if got.MemoryUsage != tt.wantUsage || got.MemoryPercent != tt.wantPercent {
+			t.Errorf("%s: usage=%d percent=%v, want %d/%v", tt.name, got.MemoryUsage, got.MemoryPercent, tt.wantUsage, tt.wantPercent)
+		}
+		if got.MemoryRawUsage != 600*mib || got.MemoryWorkingSet != tt.wantWorking {
+			t.Errorf("%s: raw=%d working=%d", tt.name, got.MemoryRawUsage, got.MemoryWorkingSet)
+		}
+	}
+
+	if got := CalculateStats(v1); got.MemoryUsage != 400*mib {
+		t.Errorf("CalculateStats default usage=%d, want working set", got.MemoryUsage)
+	}
+}
+
+func TestCalculateStats_PerCPU(t *testing.T) {
+	stat := &container.StatsResponse{}
+	stat.PreCPUStats.SystemUsage = 1000
+	stat.CPUStats.SystemUsage = 5000 // 2 cores: 2000 each
+	stat.PreCPUStats.CPUUsage.PercpuUsage = []uint64{100, 100}
+	stat.CPUStats.CPUUsage.PercpuUsage = []uint64{2100, 600}
+
+	got := CalculateStats(stat).PerCPUPercent
+	if len(got) != 2 || got[0] != 100 || got[1] != 25 {
+		t.Errorf("PerCPUPercent = %v, want [100 25]", got)
+	}
+
+	// cgroups v2 reports no per-core usage
+	stat.CPUStats.CPUUsage.PercpuUsage = nil
+	if got := CalculateStats(stat).PerCPUPercent; got != nil {
+		t.Errorf("PerCPUPercent = %v without PercpuUsage, want nil", got)
+	}
+}
+
+func TestContainerGroup(t *testing.T) {
+	for _, tc := range []struct {
+		labels map[string]string
+		want   string
+	}{
+		{map[string]string{LabelGroup: " media "}, "media"},
+		{map[string]string{"com.example": "x"}, ""},
+		{map[string]string{LabelGroup: ""}, ""},
+		{nil, ""},
+	} {
+		if got := ContainerGroup(tc.labels); got != tc.want {
+			t.Errorf("ContainerGroup(%v) = %q, want %q", tc.labels, got, tc.want)
+		}
+	}
+}
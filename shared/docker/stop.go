@@ -0,0 +1,224 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+const (
+	defaultStopSignal = "SIGTERM"
+	killSignal        = "SIGKILL"
+
+	// killWait bounds the wait for a container to exit after SIGKILL
+	killWait = 10 * time.Second
+)
+
+// signalPattern accepts signal names with or without the SIG prefix
+// (SIGINT, INT, SIGRTMIN+3) and signal numbers
+var signalPattern = regexp.MustCompile(`^([A-Z][A-Z0-9+-]*|[0-9]+)$`)
+
+// StopStep is one escalation step: send Signal, then wait up to WaitSeconds
+// for the container to exit before the next step
+type StopStep struct {
+	Signal      string `json:"signal"`
+	WaitSeconds int    `json:"wait_seconds,omitempty"`
+}
+
+// StopStrategy controls how a container is stopped. Empty fields fall back
+// to the container's own StopSignal/StopTimeout (set from the image or at
+// create), then to SIGTERM and the caller's default timeout.
+//
+// Without escalation the daemon sends Signal and kills the container after
+// the timeout, like `docker stop`. With escalation each step's signal is sent
+// in turn while the container is still running, and SIGKILL ends the chain,
+// e.g. SIGTERM, then SIGINT after 20s, then SIGKILL after 10s more.
+type StopStrategy struct {
+	Signal         string     `json:"signal,omitempty"`
+	TimeoutSeconds *int       `json:"timeout_seconds,omitempty"`
+	Escalation     []StopStep `json:"escalation,omitempty"`
+}
+
+// Validate checks signal names and timeouts.
+func (s *StopStrategy) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Signal != "" && !signalPattern.MatchString(s.Signal) {
+		return fmt.Errorf("invalid stop signal %q", s.Signal)
+	}
+	if s.TimeoutSeconds != nil && *s.TimeoutSeconds < 0 {
+		return fmt.Errorf("stop timeout must not be negative")
+	}
+	for i, step := range s.Escalation {
+		if !signalPattern.MatchString(step.Signal) {
+			return fmt.Errorf("escalation step %d: invalid signal %q", i+1, step.Signal)
+		}
+		if step.WaitSeconds < 0 {
+			return fmt.Errorf("escalation step %d: wait_seconds must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// WithTimeout returns the strategy with TimeoutSeconds set to seconds unless
+// it sets its own. Callers use it for a timeout the user gave explicitly,
+// which must win over the container's StopTimeout; a default timeout is
+// passed to StopContainer instead. Negative seconds return s unchanged.
+func (s *StopStrategy) WithTimeout(seconds int) *StopStrategy {
+	if seconds < 0 || (s != nil && s.TimeoutSeconds != nil) {
+		return s
+	}
+	var out StopStrategy
+	if s != nil {
+		out = *s
+	}
+	out.TimeoutSeconds = &seconds
+	return &out
+}
+
+// stopStep is a resolved step of an escalation chain
+type stopStep struct {
+	signal string
+	wait   time.Duration
+}
+
+// resolve returns the first signal and its timeout in seconds for a container
+// whose config sets stopSignal and stopTimeout (either may be unset). The
+// timeout is the strategy's (an explicit request value, see WithTimeout),
+// else the container's StopTimeout, else defaultTimeout.
+func (s *StopStrategy) resolve(stopSignal string, stopTimeout *int, defaultTimeout int) (string, int) {
+	signal := defaultStopSignal
+	if stopSignal != "" {
+		signal = stopSignal
+	}
+	timeout := defaultTimeout
+	if stopTimeout != nil {
+		timeout = *stopTimeout
+	}
+	if s != nil {
+		if s.Signal != "" {
+			signal = s.Signal
+		}
+		if s.TimeoutSeconds != nil {
+			timeout = *s.TimeoutSeconds
+		}
+	}
+	return signal, timeout
+}
+
+// escalationSteps is the full chain starting with the resolved first signal,
+// ending with SIGKILL
+func (s *StopStrategy) escalationSteps(signal string, timeout int) []stopStep {
+	steps := []stopStep{{signal: signal, wait: time.Duration(timeout) * time.Second}}
+	for _, step := range s.Escalation {
+		steps = append(steps, stopStep{signal: step.Signal, wait: time.Duration(step.WaitSeconds) * time.Second})
+	}
+	if last := steps[len(steps)-1].signal; !isKillSignal(last) {
+		steps = append(steps, stopStep{signal: killSignal, wait: killWait})
+	}
+	return steps
+}
+
+// MaxStopDuration returns the longest StopContainer can take with the
+// strategy for a container whose config sets stopTimeout (nil if unset):
+// the resolved timeout plus the wait after SIGKILL, or the sum of the
+// escalation chain's waits. Negative when the timeout is -1, which waits
+// for the container indefinitely.
+func (s *StopStrategy) MaxStopDuration(stopTimeout *int, defaultTimeout int) time.Duration {
+	_, timeout := s.resolve("", stopTimeout, defaultTimeout)
+	if timeout < 0 {
+		return -1
+	}
+	if s == nil || len(s.Escalation) == 0 {
+		return time.Duration(timeout)*time.Second + killWait
+	}
+	var total time.Duration
+	for _, step := range s.escalationSteps("", timeout) {
+		total += step.wait
+	}
+	return total
+}
+
+func isKillSignal(signal string) bool {
+	return signal == killSignal || signal == "KILL" || signal == "9"
+}
+
+// StopContainer stops a container using strategy (nil for the container's own
+// settings). defaultTimeout, in seconds, applies when neither the strategy nor
+// the container sets a timeout. Stopping an already stopped container is not
+// an error.
+func StopContainer(ctx context.Context, cli client.APIClient, containerID string, strategy *StopStrategy, defaultTimeout int) error {
+	if err := strategy.Validate(); err != nil {
+		return err
+	}
+
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	var stopSignal string
+	var stopTimeout *int
+	if inspect.Config != nil {
+		stopSignal = inspect.Config.StopSignal
+		stopTimeout = inspect.Config.StopTimeout
+	}
+	signal, timeout := strategy.resolve(stopSignal, stopTimeout, defaultTimeout)
+
+	// A single signal is what `docker stop` does; let the daemon run it
+	if strategy == nil || len(strategy.Escalation) == 0 {
+		return cli.ContainerStop(ctx, containerID, container.StopOptions{Signal: signal, Timeout: &timeout})
+	}
+
+	if inspect.State == nil || !inspect.State.Running {
+		return nil
+	}
+	steps := strategy.escalationSteps(signal, timeout)
+	for _, step := range steps {
+		if err := cli.ContainerKill(ctx, containerID, step.signal); err != nil {
+			// The container exiting between steps is success
+			if exited, _ := waitNotRunning(ctx, cli, containerID, 0); exited {
+				return nil
+			}
+			return fmt.Errorf("failed to send %s: %w", step.signal, err)
+		}
+		exited, err := waitNotRunning(ctx, cli, containerID, step.wait)
+		if err != nil {
+			return err
+		}
+		if exited {
+			return nil
+		}
+	}
+	return fmt.Errorf("container did not stop after %s", steps[len(steps)-1].signal)
+}
+
+// waitNotRunning waits up to wait for the container to exit
+func waitNotRunning(ctx context.Context, cli client.APIClient, containerID string, wait time.Duration) (bool, error) {
+	if wait > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		defer cancel()
+		waitCh, errCh := cli.ContainerWait(waitCtx, containerID, container.WaitConditionNotRunning)
+		select {
+		case <-waitCh:
+			return true, nil
+		case err := <-errCh:
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			if waitCtx.Err() == nil {
+				return false, fmt.Errorf("failed to wait for container: %w", err)
+			}
+			// Timed out; fall through to check the state
+		}
+	}
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return inspect.State == nil || !inspect.State.Running, nil
+}
@@ -0,0 +1,195 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// fakeStopDaemon serves a running container "abc" configured with SIGQUIT
+// and a 5s stop timeout, which exits once it receives exitOn
+type fakeStopDaemon struct {
+	mu      sync.Mutex
+	exitOn  string
+	running bool
+	kills   []string
+	stops   []string // "signal/t" query of each stop call
+}
+
+func newFakeStopDaemon(t *testing.T, exitOn string) (*fakeStopDaemon, client.APIClient) {
+	t.Helper()
+	d := &fakeStopDaemon{exitOn: exitOn, running: true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/json"):
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"Id":"abc","State":{"Running":%t},"Config":{"StopSignal":"SIGQUIT","StopTimeout":5}}`, d.running)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/stop"):
+			d.stops = append(d.stops, r.URL.Query().Get("signal")+"/"+r.URL.Query().Get("t"))
+			d.running = false
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/containers/abc/kill"):
+			signal := r.URL.Query().Get("signal")
+			d.kills = append(d.kills, signal)
+			if signal == d.exitOn {
+				d.running = false
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost(tcpAddress(srv)), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return d, cli
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestStopContainer_HonorsContainerSettings(t *testing.T) {
+	d, cli := newFakeStopDaemon(t, "")
+	if err := StopContainer(context.Background(), cli, "abc", nil, 30); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.stops) != 1 || d.stops[0] != "SIGQUIT/5" {
+		t.Errorf("stops = %v, want the container's SIGQUIT and 5s", d.stops)
+	}
+}
+
+func TestStopContainer_CustomSignal(t *testing.T) {
+	d, cli := newFakeStopDaemon(t, "")
+	strategy := &StopStrategy{Signal: "SIGINT", TimeoutSeconds: intPtr(60)}
+	if err := StopContainer(context.Background(), cli, "abc", strategy, 30); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.stops) != 1 || d.stops[0] != "SIGINT/60" {
+		t.Errorf("stops = %v, want SIGINT/60", d.stops)
+	}
+}
+
+func TestStopContainer_Escalation(t *testing.T) {
+	d, cli := newFakeStopDaemon(t, "SIGINT")
+	strategy := &StopStrategy{
+		Signal:         "SIGTERM",
+		TimeoutSeconds: intPtr(0),
+		Escalation:     []StopStep{{Signal: "SIGINT"}},
+	}
+	if err := StopContainer(context.Background(), cli, "abc", strategy, 30); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(d.kills, ",") != "SIGTERM,SIGINT" {
+		t.Errorf("kills = %v, want SIGTERM then SIGINT and no SIGKILL", d.kills)
+	}
+	if len(d.stops) != 0 {
+		t.Errorf("escalation should not use the stop endpoint, got %v", d.stops)
+	}
+}
+
+func TestStopContainer_ExplicitTimeoutWins(t *testing.T) {
+	d, cli := newFakeStopDaemon(t, "")
+	var strategy *StopStrategy
+	if err := StopContainer(context.Background(), cli, "abc", strategy.WithTimeout(60), 30); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.stops) != 1 || d.stops[0] != "SIGQUIT/60" {
+		t.Errorf("stops = %v, want the explicit 60s over the container's 5s", d.stops)
+	}
+}
+
+func TestStopStrategy_TimeoutPrecedence(t *testing.T) {
+	var none *StopStrategy
+	tests := []struct {
+		name        string
+		strategy    *StopStrategy
+		stopTimeout *int
+		want        int
+	}{
+		{"default", none, nil, 30},
+		{"container", none, intPtr(5), 5},
+		{"explicit request", none.WithTimeout(60), intPtr(5), 60},
+		{"explicit zero", none.WithTimeout(0), intPtr(5), 0},
+		{"strategy over request", (&StopStrategy{TimeoutSeconds: intPtr(90)}).WithTimeout(60), intPtr(5), 90},
+		{"negative is unset", none.WithTimeout(-1), intPtr(5), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := tt.strategy.resolve("", tt.stopTimeout, 30); got != tt.want {
+				t.Errorf("timeout = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	s := &StopStrategy{Signal: "SIGINT"}
+	if s.WithTimeout(60) == s || s.TimeoutSeconds != nil {
+		t.Error("WithTimeout must not modify the strategy it is called on")
+	}
+}
+
+func TestStopStrategy_MaxStopDuration(t *testing.T) {
+	var none *StopStrategy
+	if got := none.MaxStopDuration(intPtr(120), 10); got != 120*time.Second+killWait {
+		t.Errorf("container StopTimeout: got %s", got)
+	}
+	if got := none.MaxStopDuration(nil, 10); got != 10*time.Second+killWait {
+		t.Errorf("default: got %s", got)
+	}
+	s := &StopStrategy{TimeoutSeconds: intPtr(20), Escalation: []StopStep{{Signal: "SIGINT", WaitSeconds: 30}}}
+	if got := s.MaxStopDuration(intPtr(120), 10); got != 50*time.Second+killWait {
+		t.Errorf("escalation: got %s, want 20s + 30s + the SIGKILL wait", got)
+	}
+	if got := none.MaxStopDuration(intPtr(-1), 10); got >= 0 {
+		t.Errorf("unbounded stop: got %s, want negative", got)
+	}
+}
+
+func TestStopStrategy_EscalationEndsWithKill(t *testing.T) {
+	s := &StopStrategy{Escalation: []StopStep{{Signal: "SIGINT", WaitSeconds: 10}}}
+	steps := s.escalationSteps("SIGTERM", 20)
+	if len(steps) != 3 || steps[0].signal != "SIGTERM" || steps[1].signal != "SIGINT" || steps[2].signal != killSignal {
+		t.Fatalf("steps = %+v", steps)
+	}
+
+	s = &StopStrategy{Escalation: []StopStep{{Signal: "KILL"}}}
+	if steps := s.escalationSteps("SIGTERM", 20); len(steps) != 2 {
+		t.Errorf("explicit KILL should end the chain, got %+v", steps)
+	}
+}
+
+func TestStopStrategy_Validate(t *testing.T) {
+	cases := []struct {
+		name    string
+		s       *StopStrategy
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"empty", &StopStrategy{}, false},
+		{"named", &StopStrategy{Signal: "SIGINT"}, false},
+		{"short name", &StopStrategy{Signal: "INT"}, false},
+		{"realtime", &StopStrategy{Signal: "SIGRTMIN+3"}, false},
+		{"number", &StopStrategy{Signal: "2"}, false},
+		{"lowercase", &StopStrategy{Signal: "sigint"}, true},
+		{"injection", &StopStrategy{Signal: "SIGINT; rm"}, true},
+		{"negative timeout", &StopStrategy{TimeoutSeconds: intPtr(-1)}, true},
+		{"bad step", &StopStrategy{Escalation: []StopStep{{Signal: ""}}}, true},
+		{"negative wait", &StopStrategy{Escalation: []StopStep{{Signal: "SIGKILL", WaitSeconds: -1}}}, true},
+	}
+	for _, tc := range cases {
+		if err := tc.s.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
@@ -66,17 +66,20 @@ func createTLSOption(caCertPEM, certPEM, keyPEM string) (client.Opt, error) {
 	return client.WithHTTPClient(httpClient), nil
 }
 
-// CreateLocalClient creates a Docker client for local socket
-func CreateLocalClient() (*client.Client, error) {
-	return client.NewClientWithOpts(
+// CreateLocalClient creates a Docker client for local socket. Extra options
+// are applied after the defaults.
+func CreateLocalClient(extra ...client.Opt) (*client.Client, error) {
+	clientOpts := []client.Opt{
 		client.FromEnv,
 		client.WithAPIVersionNegotiation(),
-	)
+	}
+	return client.NewClientWithOpts(append(clientOpts, extra...)...)
 }
 
 // CreateRemoteClient creates a Docker client for a remote host
 // If TLS credentials are provided, they will be used. Otherwise, plain TCP.
-func CreateRemoteClient(hostAddress, caCertPEM, certPEM, keyPEM string) (*client.Client, error) {
+// Extra options are applied last, after the TLS transport is set up.
+func CreateRemoteClient(hostAddress, caCertPEM, certPEM, keyPEM string, extra ...client.Opt) (*client.Client, error) {
 	clientOpts := []client.Opt{
 		client.WithHost(hostAddress),
 		client.WithAPIVersionNegotiation(),
@@ -91,5 +94,5 @@ func CreateRemoteClient(hostAddress, caCertPEM, certPEM, keyPEM string) (*client
 		clientOpts = append(clientOpts, tlsOpt)
 	}
 
-	return client.NewClientWithOpts(clientOpts...)
+	return client.NewClientWithOpts(append(clientOpts, extra...)...)
 }
@@ -0,0 +1,242 @@
+// Package maintenance tracks hosts in maintenance mode. During a planned
+// window, such as a host reboot, containers stopping and starting is
+// expected: DockMon pauses its restart watchdogs, stops forwarding that
+// lifecycle noise and tags every other event, so the window doesn't raise a
+// storm of alerts or look like an incident afterwards.
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDuration is used when a window is enabled without a duration
+	DefaultDuration = time.Hour
+	// MaxDuration bounds a window, so a forgotten toggle can't mute a host
+	// indefinitely
+	MaxDuration = 24 * time.Hour
+)
+
+// Window is an active maintenance window. It ends at Until unless disabled
+// earlier.
+type Window struct {
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+	// Muted counts lifecycle events not forwarded during the window
+	Muted int `json:"muted_events"`
+}
+
+// ValidateDuration checks a requested window length; 0 means DefaultDuration.
+func ValidateDuration(d time.Duration) error {
+	if d < 0 || d > MaxDuration {
+		return fmt.Errorf("duration must be between 0 and %s", MaxDuration)
+	}
+	return nil
+}
+
+// IsExpectedNoise reports whether a container event action is expected while
+// a host is in maintenance: containers stopping, starting and settling their
+// health status. OOM kills and destroys are still real events.
+func IsExpectedNoise(action string) bool {
+	if strings.HasPrefix(action, "health_status") {
+		return true
+	}
+	switch action {
+	case "start", "restart", "stop", "die", "kill":
+		return true
+	}
+	return false
+}
+
+// Mode is one host's maintenance state. The zero value is not in
+// maintenance; all methods are safe for concurrent use.
+type Mode struct {
+	mu     sync.Mutex
+	window *Window
+	now    func() time.Time
+}
+
+func (m *Mode) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// activeLocked returns the window if one is active, ending it once expired.
+// Caller must hold m.mu.
+func (m *Mode) activeLocked() *Window {
+	if m.window != nil && !m.clock().Before(m.window.Until) {
+		m.window = nil
+	}
+	return m.window
+}
+
+// Enable starts (or extends) a window lasting duration from now. The muted
+// event count carries over when an active window is extended.
+func (m *Mode) Enable(reason string, duration time.Duration) Window {
+	if duration <= 0 {
+		duration = DefaultDuration
+	}
+	duration = min(duration, MaxDuration)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.clock()
+	w := Window{Reason: reason, Since: now.UTC(), Until: now.Add(duration).UTC()}
+	if prev := m.activeLocked(); prev != nil {
+		w.Since, w.Muted = prev.Since, prev.Muted
+	}
+	m.window = &w
+	return w
+}
+
+// Restore reinstates a persisted window, e.g. after the agent restarts
+// during a host reboot. An expired window is ignored.
+func (m *Mode) Restore(w Window) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.clock().Before(w.Until) {
+		return false
+	}
+	m.window = &w
+	return true
+}
+
+// Disable ends the window, returning it and whether one was active
+func (m *Mode) Disable() (Window, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.activeLocked()
+	m.window = nil
+	if w == nil {
+		return Window{}, false
+	}
+	return *w, true
+}
+
+// Active returns the current window, if any
+func (m *Mode) Active() (Window, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if w := m.activeLocked(); w != nil {
+		return *w, true
+	}
+	return Window{}, false
+}
+
+// Filter decides how to handle an event with the given container action:
+// tag is true during a window, and forward is false for expected lifecycle
+// noise, which is then counted as muted. Pass "" for non-container events,
+// which are always forwarded.
+func (m *Mode) Filter(action string) (tag, forward bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.activeLocked()
+	if w == nil {
+		return false, true
+	}
+	if action != "" && IsExpectedNoise(action) {
+		w.Muted++
+		return true, false
+	}
+	return true, true
+}
+
+// Tracker holds the maintenance state of many hosts
+type Tracker struct {
+	mu    sync.Mutex
+	hosts map[string]*Mode
+	now   func() time.Time
+}
+
+// NewTracker returns a tracker with no host in maintenance
+func NewTracker() *Tracker {
+	return &Tracker{hosts: make(map[string]*Mode)}
+}
+
+func (t *Tracker) mode(hostID string, create bool) *Mode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.hosts[hostID]
+	if !ok && create {
+		m = &Mode{now: t.now}
+		t.hosts[hostID] = m
+	}
+	return m
+}
+
+// Enable starts or extends a host's window
+func (t *Tracker) Enable(hostID, reason string, duration time.Duration) Window {
+	return t.mode(hostID, true).Enable(reason, duration)
+}
+
+// Restore reinstates a host's persisted window, e.g. after a restart. An
+// expired window is ignored.
+func (t *Tracker) Restore(hostID string, w Window) bool {
+	if t.mode(hostID, true).Restore(w) {
+		return true
+	}
+	t.mu.Lock()
+	delete(t.hosts, hostID)
+	t.mu.Unlock()
+	return false
+}
+
+// Expire forgets hosts whose window has run out and returns their IDs
+func (t *Tracker) Expire() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var expired []string
+	for hostID, m := range t.hosts {
+		if _, ok := m.Active(); !ok {
+			delete(t.hosts, hostID)
+			expired = append(expired, hostID)
+		}
+	}
+	return expired
+}
+
+// Disable ends a host's window
+func (t *Tracker) Disable(hostID string) (Window, bool) {
+	m := t.mode(hostID, false)
+	if m == nil {
+		return Window{}, false
+	}
+	w, ok := m.Disable()
+	t.mu.Lock()
+	delete(t.hosts, hostID)
+	t.mu.Unlock()
+	return w, ok
+}
+
+// Filter applies Mode.Filter for a host
+func (t *Tracker) Filter(hostID, action string) (tag, forward bool) {
+	m := t.mode(hostID, false)
+	if m == nil {
+		return false, true
+	}
+	return m.Filter(action)
+}
+
+// Snapshot returns the active windows by host ID
+func (t *Tracker) Snapshot() map[string]Window {
+	t.mu.Lock()
+	modes := make(map[string]*Mode, len(t.hosts))
+	for hostID, m := range t.hosts {
+		modes[hostID] = m
+	}
+	t.mu.Unlock()
+
+	result := make(map[string]Window, len(modes))
+	for hostID, m := range modes {
+		if w, ok := m.Active(); ok {
+			result[hostID] = w
+		}
+	}
+	return result
+}
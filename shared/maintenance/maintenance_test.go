@@ -0,0 +1,107 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsExpectedNoise(t *testing.T) {
+	for _, action := range []string{"start", "restart", "stop", "die", "kill", "health_status: unhealthy"} {
+		if !IsExpectedNoise(action) {
+			t.Errorf("IsExpectedNoise(%q) = false", action)
+		}
+	}
+	for _, action := range []string{"oom", "destroy", "create", "update", ""} {
+		if IsExpectedNoise(action) {
+			t.Errorf("IsExpectedNoise(%q) = true", action)
+		}
+	}
+}
+
+func TestMode_WindowLifecycle(t *testing.T) {
+	now := time.Date(2026, 5, 1, 22, 0, 0, 0, time.UTC)
+	m := &Mode{now: func() time.Time { return now }}
+
+	if tag, forward := m.Filter("die"); tag || !forward {
+		t.Errorf("Filter outside a window = %v, %v", tag, forward)
+	}
+
+	w := m.Enable("kernel upgrade", 0)
+	if !w.Until.Equal(now.Add(DefaultDuration)) {
+		t.Errorf("Until = %v, want the default duration", w.Until)
+	}
+	if tag, forward := m.Filter("die"); !tag || forward {
+		t.Errorf("Filter(die) in a window = %v, %v, want muted", tag, forward)
+	}
+	if tag, forward := m.Filter("oom"); !tag || !forward {
+		t.Errorf("Filter(oom) in a window = %v, %v, want tagged", tag, forward)
+	}
+	if tag, forward := m.Filter(""); !tag || !forward {
+		t.Errorf("Filter(\"\") in a window = %v, %v, want tagged", tag, forward)
+	}
+
+	// Extending keeps the start and the muted count
+	now = now.Add(30 * time.Minute)
+	w = m.Enable("kernel upgrade", 48*time.Hour)
+	if w.Muted != 1 || !w.Since.Equal(now.Add(-30*time.Minute)) || !w.Until.Equal(now.Add(MaxDuration)) {
+		t.Errorf("extended window = %+v", w)
+	}
+
+	// Expiry ends the window without a Disable
+	now = now.Add(MaxDuration)
+	if _, ok := m.Active(); ok {
+		t.Error("window still active after it expired")
+	}
+	if m.Restore(w) {
+		t.Error("Restore accepted an expired window")
+	}
+}
+
+func TestTracker_PerHost(t *testing.T) {
+	tr := NewTracker()
+	tr.Enable("h1", "reboot", time.Hour)
+
+	if _, forward := tr.Filter("h1", "stop"); forward {
+		t.Error("h1 stop forwarded during maintenance")
+	}
+	if tag, forward := tr.Filter("h2", "stop"); tag || !forward {
+		t.Error("h2 affected by h1's window")
+	}
+	if snap := tr.Snapshot(); len(snap) != 1 || snap["h1"].Muted != 1 {
+		t.Errorf("Snapshot() = %+v", snap)
+	}
+
+	if w, ok := tr.Disable("h1"); !ok || w.Reason != "reboot" {
+		t.Errorf("Disable = %+v, %v", w, ok)
+	}
+	if _, ok := tr.Disable("h1"); ok {
+		t.Error("second Disable reported an active window")
+	}
+	if len(tr.Snapshot()) != 0 {
+		t.Error("Snapshot not empty after Disable")
+	}
+}
+
+func TestTracker_RestoreAndExpire(t *testing.T) {
+	now := time.Date(2026, 5, 1, 22, 0, 0, 0, time.UTC)
+	tr := NewTracker()
+	tr.now = func() time.Time { return now }
+
+	if !tr.Restore("h1", Window{Reason: "reboot", Since: now, Until: now.Add(time.Hour)}) {
+		t.Fatal("Restore rejected an active window")
+	}
+	if tr.Restore("h2", Window{Since: now.Add(-2 * time.Hour), Until: now.Add(-time.Hour)}) {
+		t.Error("Restore accepted an expired window")
+	}
+	if expired := tr.Expire(); len(expired) != 0 {
+		t.Errorf("Expire() = %v while h1's window is active", expired)
+	}
+
+	now = now.Add(time.Hour)
+	if expired := tr.Expire(); len(expired) != 1 || expired[0] != "h1" {
+		t.Errorf("Expire() = %v, want [h1]", expired)
+	}
+	if expired := tr.Expire(); len(expired) != 0 {
+		t.Errorf("second Expire() = %v, want none", expired)
+	}
+}
@@ -0,0 +1,157 @@
+// Package progress defines the progress event schema shared by container
+// updates, compose deployments and agent self-updates, so every emitter
+// sends the same fields and frontends need a single renderer.
+package progress
+
+// SchemaVersion is sent in every event. It is bumped when a field changes
+// meaning; adding fields doesn't bump it.
+const SchemaVersion = 1
+
+// Operation is the kind of long-running operation an event belongs to
+type Operation string
+
+const (
+	OperationUpdate     Operation = "update"
+	OperationDeploy     Operation = "deploy"
+	OperationSelfUpdate Operation = "self_update"
+)
+
+// Stage is a step of an operation. Values are the stage names each emitter
+// sent before this schema existed, so existing stage labels keep working.
+type Stage string
+
+// Stages shared by several operations
+const (
+	StageCreating    Stage = "creating"
+	StageStarting    Stage = "starting"
+	StageHealthCheck Stage = "health_check"
+	StageCompleted   Stage = "completed"
+	StageFailed      Stage = "failed"
+)
+
+// Container update stages
+const (
+	StagePlanning    Stage = "planning" // Group updates only
+	StagePreflight   Stage = "preflight"
+	StagePulling     Stage = "pulling"
+	StageConfiguring Stage = "configuring"
+	StagePreHook     Stage = "pre_update_hook"
+	StageBackup      Stage = "backup"
+	StagePostHook    Stage = "post_update_hook"
+	StageDependents  Stage = "dependents"
+	StageCleanup     Stage = "cleanup"
+	StageRollback    Stage = "rollback"
+)
+
+// Compose deployment stages
+const (
+	StageValidating       Stage = "validating"
+	StageParsing          Stage = "parsing"
+	StageCreatingNetworks Stage = "creating_networks"
+	StageCreatingVolumes  Stage = "creating_volumes"
+	StagePullingImage     Stage = "pulling_image"
+	StageStopping         Stage = "stopping"           // Bulk project stops
+	StageExecuting        Stage = "executing"          // Legacy agent stage
+	StageWaitingHealth    Stage = "waiting_for_health" // Legacy agent stage
+)
+
+// Agent self-update stages
+const (
+	StagePrepare  Stage = "prepare"
+	StageDownload Stage = "download"
+	StageVerify   Stage = "verify"
+	StageChmod    Stage = "chmod"
+	StagePull     Stage = "pull"
+	StageInspect  Stage = "inspect"
+	StageCreate   Stage = "create"
+	StageStart    Stage = "start"
+	StageHealth   Stage = "health"
+	StageComplete Stage = "complete"
+)
+
+// Terminal reports whether no further events follow this stage
+func (s Stage) Terminal() bool {
+	switch s {
+	case StageCompleted, StageFailed, StageComplete:
+		return true
+	}
+	return false
+}
+
+// Layer is the download progress of one image layer
+type Layer struct {
+	ID      string `json:"id"`      // Layer short ID
+	Status  string `json:"status"`  // Downloading, Extracting, Pull complete, Already exists
+	Current int64  `json:"current"` // Bytes downloaded
+	Total   int64  `json:"total"`   // Total bytes
+	Percent int    `json:"percent"` // 0-100
+}
+
+// Event is one progress report. Only Schema, Operation, Stage and Message
+// are always set; the rest depend on the operation and stage.
+type Event struct {
+	Schema    int       `json:"schema"`
+	Operation Operation `json:"operation"`
+	Stage     Stage     `json:"stage"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error,omitempty"`  // Set on failure
+	Output    string    `json:"output,omitempty"` // Captured command output (hook stages)
+
+	// Percent covers the whole operation, StagePercent the current stage
+	// (e.g. bytes of an image pull or binary download). Both are 0-100 and
+	// omitted when unknown.
+	Percent      int `json:"percent,omitempty"`
+	StagePercent int `json:"stage_percent,omitempty"`
+
+	// What the event is about
+	ContainerID  string `json:"container_id,omitempty"`
+	DeploymentID string `json:"deployment_id,omitempty"`
+	Target       string `json:"target,omitempty"`      // Multi-target deployments
+	Project      string `json:"project,omitempty"`     // Operations across several projects
+	Service      string `json:"service,omitempty"`     // Compose service
+	ServiceIndex int    `json:"service_idx,omitempty"` // 1-based
+	ServiceCount int    `json:"total_services,omitempty"`
+
+	// Transfers (image pulls, binary downloads)
+	CurrentBytes    int64   `json:"current_bytes,omitempty"`
+	TotalBytes      int64   `json:"total_bytes,omitempty"`
+	SpeedMbps       float64 `json:"speed_mbps,omitempty"` // MB/s
+	Layers          []Layer `json:"layers,omitempty"`
+	TotalLayers     int     `json:"total_layers,omitempty"`
+	RemainingLayers int     `json:"remaining_layers,omitempty"` // Layers not sent (truncated for network efficiency)
+}
+
+// New returns an event of the current schema version
+func New(op Operation, stage Stage, message string) Event {
+	return Event{
+		Schema:    SchemaVersion,
+		Operation: op,
+		Stage:     stage,
+		Message:   message,
+	}
+}
+
+// Failed returns a failure event for the stage that failed
+func Failed(op Operation, stage Stage, err error) Event {
+	event := New(op, stage, "Error occurred")
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// Percentage returns current as a 0-100 share of total, or 0 if the total
+// is unknown
+func Percentage(current, total int64) int {
+	if total <= 0 {
+		return 0
+	}
+	percent := int(current * 100 / total)
+	if percent > 100 {
+		percent = 100
+	}
+	if percent < 0 {
+		percent = 0
+	}
+	return percent
+}
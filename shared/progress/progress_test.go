@@ -0,0 +1,69 @@
+package progress
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvent_JSON(t *testing.T) {
+	event := New(OperationUpdate, StagePulling, "Pulling image")
+	event.ContainerID = "abc123def456"
+	event.StagePercent = 40
+	event.SpeedMbps = 12.5
+	event.Layers = []Layer{{ID: "a1", Status: "Downloading", Current: 40, Total: 100, Percent: 40}}
+	event.TotalLayers = 3
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"schema":        float64(SchemaVersion),
+		"operation":     "update",
+		"stage":         "pulling",
+		"message":       "Pulling image",
+		"container_id":  "abc123def456",
+		"stage_percent": float64(40),
+		"speed_mbps":    12.5,
+		"total_layers":  float64(3),
+		"layers": []interface{}{map[string]interface{}{
+			"id": "a1", "status": "Downloading", "current": float64(40), "total": float64(100), "percent": float64(40),
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	event := Failed(OperationSelfUpdate, StageDownload, errors.New("connection reset"))
+	if event.Schema != SchemaVersion || event.Stage != StageDownload || event.Error != "connection reset" {
+		t.Errorf("Failed = %+v", event)
+	}
+	if !StageFailed.Terminal() || !StageComplete.Terminal() || StageDownload.Terminal() {
+		t.Error("Terminal misreports stages")
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	tests := []struct {
+		current, total int64
+		want           int
+	}{
+		{50, 200, 25},
+		{10, 0, 0},
+		{10, -1, 0},
+		{300, 200, 100},
+	}
+	for _, tt := range tests {
+		if got := Percentage(tt.current, tt.total); got != tt.want {
+			t.Errorf("Percentage(%d, %d) = %d, want %d", tt.current, tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,513 @@
+// Package registry talks to container registries over the OCI distribution
+// (v2) API, so DockMon can look up remote tags, digests and labels for an
+// image without pulling it.
+//
+// It is used by the update preflight (estimating download size), the agent's
+// check_updates command, the updatecheck policy evaluation and the
+// compose-service /image/inspect endpoint.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+)
+
+// Manifest media types accepted from registries
+const (
+	MediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	maxResponseSize = 4 * 1024 * 1024
+	requestTimeout  = 30 * time.Second
+
+	// maxTagPages bounds tag list pagination for repositories with huge
+	// numbers of tags
+	maxTagPages = 20
+)
+
+// Credentials authenticate against a registry (basic auth, exchanged for a
+// bearer token when the registry asks for one).
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Platform identifies one image in a multi-platform index.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// ParsePlatform parses "os/arch[/variant]", normalizing the architecture.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid platform %q (expected os/arch[/variant])", s)
+	}
+	p := Platform{OS: parts[0], Architecture: NormalizeArch(parts[1])}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// Layer is one compressed layer of an image manifest. DiffID is the
+// uncompressed layer ID Docker records locally (from the image config).
+type Layer struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+	DiffID string `json:"diff_id,omitempty"`
+}
+
+// ImageInfo describes an image as the registry serves it.
+type ImageInfo struct {
+	// Reference is the normalized name:tag (or name@digest) that was inspected
+	Reference string `json:"reference"`
+	// Digest is the top-level manifest digest, the value Docker records in
+	// RepoDigests after a pull
+	Digest    string `json:"digest"`
+	MediaType string `json:"media_type"`
+	// Platforms lists what a multi-platform index offers; PlatformDigest is
+	// the manifest chosen for the requested platform
+	Platforms      []Platform `json:"platforms,omitempty"`
+	PlatformDigest string     `json:"platform_digest,omitempty"`
+
+	Created string            `json:"created,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Layers  []Layer           `json:"layers"`
+	// Size is the compressed download size (layers plus config)
+	Size int64 `json:"size"`
+}
+
+// manifest covers both single-platform manifests and indexes
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Config    struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest   string   `json:"digest"`
+		Platform Platform `json:"platform"`
+	} `json:"manifests"`
+}
+
+// imageConfig is the part of the config blob DockMon reports
+type imageConfig struct {
+	Created string `json:"created"`
+	Config  struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// repository is a parsed image reference split into what the v2 API needs
+type repository struct {
+	host string
+	path string
+	ref  string // tag or digest
+	name reference.Named
+}
+
+// Client fetches manifests, config blobs and tag lists. Bearer tokens are
+// cached per repository for the life of the client, so a Client must not be
+// shared between goroutines.
+type Client struct {
+	http   *http.Client
+	creds  *Credentials
+	tokens map[string]string
+}
+
+// NewClient creates a registry client. creds may be nil for anonymous pulls.
+func NewClient(creds *Credentials) *Client {
+	return &Client{
+		http:   &http.Client{Timeout: requestTimeout},
+		creds:  creds,
+		tokens: make(map[string]string),
+	}
+}
+
+// Inspect fetches the manifest and config for imageRef. For multi-platform
+// images the manifest matching platform is measured.
+func (c *Client) Inspect(ctx context.Context, imageRef string, platform Platform) (*ImageInfo, error) {
+	repo, err := parseRepository(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	top, digest, err := c.fetchManifest(ctx, repo, repo.ref)
+	if err != nil {
+		return nil, err
+	}
+	info := &ImageInfo{
+		Reference: repo.name.String(),
+		Digest:    digest,
+		MediaType: top.MediaType,
+	}
+
+	m := top
+	if len(top.Manifests) > 0 {
+		for _, entry := range top.Manifests {
+			// Attestation manifests are listed as unknown/unknown
+			if entry.Platform.OS != "unknown" {
+				info.Platforms = append(info.Platforms, entry.Platform)
+			}
+		}
+		if info.PlatformDigest, err = selectPlatformManifest(top, platform); err != nil {
+			return nil, err
+		}
+		if m, _, err = c.fetchManifest(ctx, repo, info.PlatformDigest); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.Layers) == 0 {
+		return nil, fmt.Errorf("manifest for %s lists no layers", imageRef)
+	}
+
+	var cfg imageConfig
+	if m.Config.Digest != "" {
+		if err := c.fetchJSON(ctx, repo, "blobs/"+m.Config.Digest, "", &cfg); err != nil {
+			return nil, fmt.Errorf("failed to fetch image config: %w", err)
+		}
+	}
+	info.Created = cfg.Created
+	info.Labels = cfg.Config.Labels
+
+	info.Size = m.Config.Size
+	for i, l := range m.Layers {
+		layer := Layer{Digest: l.Digest, Size: l.Size}
+		if i < len(cfg.RootFS.DiffIDs) {
+			layer.DiffID = cfg.RootFS.DiffIDs[i]
+		}
+		info.Layers = append(info.Layers, layer)
+		info.Size += l.Size
+	}
+	return info, nil
+}
+
+// ListTags returns every tag of imageRef's repository (the tag or digest in
+// imageRef itself is ignored), following pagination links.
+func (c *Client) ListTags(ctx context.Context, imageRef string) ([]string, error) {
+	repo, err := parseRepository(imageRef)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	next := "tags/list?n=1000"
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		resp, err := c.do(ctx, repo, next, "")
+		if err != nil {
+			return nil, err
+		}
+		err = decodeResponse(resp, &body)
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, body.Tags...)
+		next = nextPagePath(link, repo.path)
+	}
+	return tags, nil
+}
+
+// DownloadSize returns the compressed size of the layers a pull of info
+// would download, skipping layers whose DiffIDs are already present locally.
+func DownloadSize(info *ImageInfo, localDiffIDs map[string]bool) int64 {
+	var size int64
+	for _, l := range info.Layers {
+		if l.DiffID != "" && localDiffIDs[l.DiffID] {
+			continue
+		}
+		size += l.Size
+	}
+	return size
+}
+
+func parseRepository(imageRef string) (*repository, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+	named = reference.TagNameOnly(named)
+
+	repo := &repository{
+		host: reference.Domain(named),
+		path: reference.Path(named),
+		name: named,
+	}
+	if repo.host == "docker.io" {
+		repo.host = "registry-1.docker.io"
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		repo.ref = digested.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		repo.ref = tagged.Tag()
+	}
+	return repo, nil
+}
+
+// fetchManifest returns the parsed manifest and its digest
+func (c *Client) fetchManifest(ctx context.Context, repo *repository, ref string) (*manifest, string, error) {
+	accept := strings.Join([]string{MediaTypeOCIIndex, MediaTypeDockerList, MediaTypeOCIManifest, MediaTypeDockerManifest}, ", ")
+	resp, err := c.do(ctx, repo, "manifests/"+ref, accept)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("registry returned %s for %s:%s", resp.Status, repo.path, ref)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, "", fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(raw)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return &m, digest, nil
+}
+
+func (c *Client) fetchJSON(ctx context.Context, repo *repository, path, accept string, out interface{}) error {
+	resp, err := c.do(ctx, repo, path, accept)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returned %s for %s", resp.Status, resp.Request.URL.Path)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode registry response: %w", err)
+	}
+	return nil
+}
+
+// do performs a GET against the repository, answering one bearer challenge
+func (c *Client) do(ctx context.Context, repo *repository, path, accept string) (*http.Response, error) {
+	endpoint := fmt.Sprintf("https://%s/v2/%s/%s", repo.host, repo.path, path)
+
+	resp, err := c.get(ctx, endpoint, accept, c.tokens[repo.path])
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || c.tokens[repo.path] != "" {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	token, err := c.authenticate(ctx, challenge, repo.path)
+	if err != nil {
+		return nil, err
+	}
+	c.tokens[repo.path] = token
+	return c.get(ctx, endpoint, accept, token)
+}
+
+func (c *Client) get(ctx context.Context, endpoint, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.creds != nil && c.creds.Username != "":
+		req.SetBasicAuth(c.creds.Username, c.creds.Password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// authenticate exchanges a bearer challenge for a pull token
+func (c *Client) authenticate(ctx context.Context, challenge, repoPath string) (string, error) {
+	params := parseBearerChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry requires authentication (%s)", challenge)
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %s: %w", realm, err)
+	}
+	q := tokenURL.Query()
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + repoPath + ":pull"
+	}
+	q.Set("scope", scope)
+	tokenURL.RawQuery = q.Encode()
+
+	resp, err := c.get(ctx, tokenURL.String(), "", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("registry returned an empty token")
+}
+
+// parseBearerChallenge parses `Bearer realm="...",service="...",scope="..."`
+func parseBearerChallenge(header string) map[string]string {
+	params := make(map[string]string)
+	scheme, rest, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return params
+	}
+	for rest != "" {
+		var key, value string
+		key, rest, ok = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return params
+}
+
+// nextPagePath turns a `Link: </v2/repo/tags/list?last=x&n=1000>; rel="next"`
+// header into the path relative to the repository, or "" on the last page
+func nextPagePath(link, repoPath string) string {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+	u, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+	prefix := "/v2/" + repoPath + "/"
+	if !strings.HasPrefix(u.Path, prefix) {
+		return ""
+	}
+	next := strings.TrimPrefix(u.Path, prefix)
+	if u.RawQuery != "" {
+		next += "?" + u.RawQuery
+	}
+	return next
+}
+
+// selectPlatformManifest picks the index entry matching platform, preferring
+// an exact variant match
+func selectPlatformManifest(index *manifest, platform Platform) (string, error) {
+	arch := NormalizeArch(platform.Architecture)
+	fallback := ""
+	for _, m := range index.Manifests {
+		if m.Platform.OS != platform.OS || NormalizeArch(m.Platform.Architecture) != arch {
+			continue
+		}
+		if platform.Variant == "" || m.Platform.Variant == platform.Variant {
+			return m.Digest, nil
+		}
+		if fallback == "" {
+			fallback = m.Digest
+		}
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no manifest for platform %s/%s", platform.OS, arch)
+}
+
+// NormalizeArch maps the kernel architecture names Docker's Info reports to
+// OCI names.
+func NormalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armhf", "armv7l":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	}
+	return arch
+}
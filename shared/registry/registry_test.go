@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseBearerChallenge(t *testing.T) {
+	got := parseBearerChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	if got := parseBearerChallenge(`Basic realm="registry"`); len(got) != 0 {
+		t.Errorf("basic challenge parsed as bearer: %v", got)
+	}
+	if got := parseBearerChallenge(`Bearer realm=https://r/token, service=reg`); got["realm"] != "https://r/token" || got["service"] != "reg" {
+		t.Errorf("unquoted params = %v", got)
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("linux/aarch64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != (Platform{OS: "linux", Architecture: "arm64"}) {
+		t.Errorf("got %+v", p)
+	}
+	if p, err := ParsePlatform("linux/arm/v7"); err != nil || p.Variant != "v7" || p.String() != "linux/arm/v7" {
+		t.Errorf("got %+v, %v", p, err)
+	}
+	for _, bad := range []string{"", "linux", "linux/", "a/b/c/d"} {
+		if _, err := ParsePlatform(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestSelectPlatformManifest(t *testing.T) {
+	var index manifest
+	if err := json.Unmarshal([]byte(`{"manifests":[
+		{"digest":"sha256:amd","platform":{"os":"linux","architecture":"amd64"}},
+		{"digest":"sha256:v6","platform":{"os":"linux","architecture":"arm","variant":"v6"}},
+		{"digest":"sha256:v7","platform":{"os":"linux","architecture":"arm","variant":"v7"}},
+		{"digest":"sha256:win","platform":{"os":"windows","architecture":"amd64"}}
+	]}`), &index); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		platform Platform
+		want     string
+	}{
+		{Platform{OS: "linux", Architecture: "x86_64"}, "sha256:amd"},
+		{Platform{OS: "windows", Architecture: "x86_64"}, "sha256:win"},
+		{Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, "sha256:v7"},
+		{Platform{OS: "linux", Architecture: "armv7l"}, "sha256:v6"},
+	}
+	for _, tt := range tests {
+		got, err := selectPlatformManifest(&index, tt.platform)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.platform, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%+v: got %s, want %s", tt.platform, got, tt.want)
+		}
+	}
+
+	if _, err := selectPlatformManifest(&index, Platform{OS: "linux", Architecture: "aarch64"}); err == nil {
+		t.Error("expected error for missing arm64 manifest")
+	}
+}
+
+func TestNextPagePath(t *testing.T) {
+	got := nextPagePath(`</v2/team/app/tags/list?last=1.9&n=1000>; rel="next"`, "team/app")
+	if got != "tags/list?last=1.9&n=1000" {
+		t.Errorf("got %q", got)
+	}
+	if got := nextPagePath("", "team/app"); got != "" {
+		t.Errorf("empty link gave %q", got)
+	}
+	if got := nextPagePath(`</v2/other/repo/tags/list?last=x>; rel="next"`, "team/app"); got != "" {
+		t.Errorf("foreign repo link gave %q", got)
+	}
+}
+
+// newTestRegistry serves a multi-arch image and a paginated tag list behind
+// a bearer token
+func newTestRegistry(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			user, pass, _ := r.BasicAuth()
+			if user != "bob" || pass != "hunter2" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				t.Errorf("token scope = %q", r.URL.Query().Get("scope"))
+			}
+			_, _ = w.Write([]byte(`{"token":"secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/1.0":
+			if !strings.Contains(r.Header.Get("Accept"), MediaTypeOCIIndex) {
+				t.Errorf("Accept = %q", r.Header.Get("Accept"))
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:index")
+			_, _ = w.Write([]byte(`{"mediaType":"` + MediaTypeOCIIndex + `","manifests":[
+				{"digest":"sha256:arm","platform":{"os":"linux","architecture":"arm64"}},
+				{"digest":"sha256:amd","platform":{"os":"linux","architecture":"amd64"}},
+				{"digest":"sha256:att","platform":{"os":"unknown","architecture":"unknown"}}]}`))
+		case "/v2/team/app/manifests/sha256:amd":
+			_, _ = w.Write([]byte(`{"mediaType":"` + MediaTypeOCIManifest + `",
+				"config":{"digest":"sha256:cfg","size":10},
+				"layers":[{"digest":"sha256:l1","size":1000},{"digest":"sha256:l2","size":200}]}`))
+		case "/v2/team/app/blobs/sha256:cfg":
+			_, _ = w.Write([]byte(`{"created":"2026-01-01T00:00:00Z",
+				"config":{"Labels":{"org.opencontainers.image.version":"1.0"}},
+				"rootfs":{"diff_ids":["sha256:d1","sha256:d2"]}}`))
+		case "/v2/team/app/tags/list":
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/team/app/tags/list?last=1.1&n=1000>; rel="next"`)
+				_, _ = w.Write([]byte(`{"tags":["1.0","1.1"]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"tags":["1.2","latest"]}`))
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(srv *httptest.Server) *Client {
+	c := NewClient(&Credentials{Username: "bob", Password: "hunter2"})
+	c.http = srv.Client()
+	return c
+}
+
+func TestInspect(t *testing.T) {
+	srv := newTestRegistry(t)
+	imageRef := strings.TrimPrefix(srv.URL, "https://") + "/team/app:1.0"
+
+	info, err := newTestClient(srv).Inspect(context.Background(), imageRef, Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Digest != "sha256:index" || info.PlatformDigest != "sha256:amd" {
+		t.Errorf("digest = %s, platform digest = %s", info.Digest, info.PlatformDigest)
+	}
+	if len(info.Platforms) != 2 {
+		t.Errorf("platforms = %v, want attestation entry skipped", info.Platforms)
+	}
+	if info.Size != 1210 {
+		t.Errorf("size = %d, want 1210", info.Size)
+	}
+	if info.Labels["org.opencontainers.image.version"] != "1.0" || info.Created == "" {
+		t.Errorf("labels = %v, created = %q", info.Labels, info.Created)
+	}
+	if len(info.Layers) != 2 || info.Layers[0].DiffID != "sha256:d1" {
+		t.Errorf("layers = %+v", info.Layers)
+	}
+
+	// The base layer is shared with an image already on the host
+	if got := DownloadSize(info, map[string]bool{"sha256:d1": true}); got != 200 {
+		t.Errorf("download size with shared layer = %d, want 200", got)
+	}
+}
+
+func TestInspect_NotFound(t *testing.T) {
+	srv := newTestRegistry(t)
+	imageRef := strings.TrimPrefix(srv.URL, "https://") + "/team/app:missing"
+	if _, err := newTestClient(srv).Inspect(context.Background(), imageRef, Platform{OS: "linux", Architecture: "amd64"}); err == nil {
+		t.Error("expected error for unknown tag")
+	}
+}
+
+func TestInspect_BadCredentials(t *testing.T) {
+	srv := newTestRegistry(t)
+	c := NewClient(&Credentials{Username: "bob", Password: "wrong"})
+	c.http = srv.Client()
+	imageRef := strings.TrimPrefix(srv.URL, "https://") + "/team/app:1.0"
+	if _, err := c.Inspect(context.Background(), imageRef, Platform{OS: "linux", Architecture: "amd64"}); err == nil {
+		t.Error("expected authentication error")
+	}
+}
+
+func TestListTags(t *testing.T) {
+	srv := newTestRegistry(t)
+	imageRef := strings.TrimPrefix(srv.URL, "https://") + "/team/app:1.0"
+
+	tags, err := newTestClient(srv).ListTags(context.Background(), imageRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "1.0,1.1,1.2,latest" {
+		t.Errorf("tags = %v", tags)
+	}
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// versionTagPattern splits tags like "v1.2.3-alpine" into prefix, numeric
+// components and suffix
+var versionTagPattern = regexp.MustCompile(`^(v?)(\d+(?:\.\d+)*)(-.+)?$`)
+
+type versionTag struct {
+	prefix string
+	nums   []int
+	suffix string
+}
+
+func parseVersionTag(tag string) (versionTag, bool) {
+	m := versionTagPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return versionTag{}, false
+	}
+	v := versionTag{prefix: m[1], suffix: m[3]}
+	for _, part := range strings.Split(m[2], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return versionTag{}, false
+		}
+		v.nums = append(v.nums, n)
+	}
+	return v, true
+}
+
+// IsVersionTag reports whether tag looks like a version ("1.2", "v3.0.1",
+// "2.4-alpine") that LatestVersion can compare.
+func IsVersionTag(tag string) bool {
+	_, ok := parseVersionTag(tag)
+	return ok
+}
+
+// sameShape reports whether two tags belong to the same release line:
+// same "v" prefix, same number of components and same variant suffix, so
+// "1.2-alpine" is only compared with other "X.Y-alpine" tags
+func (v versionTag) sameShape(o versionTag) bool {
+	return v.prefix == o.prefix && len(v.nums) == len(o.nums) && v.suffix == o.suffix
+}
+
+func (v versionTag) newerThan(o versionTag) bool {
+	for i := range v.nums {
+		if v.nums[i] != o.nums[i] {
+			return v.nums[i] > o.nums[i]
+		}
+	}
+	return false
+}
+
+// hasPrefix reports whether v and o agree on their first n components
+func (v versionTag) hasPrefix(o versionTag, n int) bool {
+	for i := 0; i < n && i < len(v.nums); i++ {
+		if v.nums[i] != o.nums[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LatestVersion returns the newest tag in tags with the same shape as
+// current (see sameShape) that is newer than it. It returns "" when current
+// isn't a version tag (e.g. "latest") or nothing newer exists.
+func LatestVersion(current string, tags []string) string {
+	return LatestVersionWithin(current, tags, 0)
+}
+
+// LatestVersionWithin is LatestVersion restricted to tags that keep the
+// first keep components of current, so keep=1 stays on the same major
+// version and keep=2 on the same minor. A keep covering every component of
+// current never finds anything newer.
+func LatestVersionWithin(current string, tags []string, keep int) string {
+	cur, ok := parseVersionTag(current)
+	if !ok {
+		return ""
+	}
+	best, bestTag := cur, ""
+	for _, tag := range tags {
+		v, ok := parseVersionTag(tag)
+		if !ok || !v.sameShape(cur) || !v.hasPrefix(cur, keep) || !v.newerThan(best) {
+			continue
+		}
+		best, bestTag = v, tag
+	}
+	return bestTag
+}
+
+// Tag returns the tag of imageRef ("latest" when it has none), or "" for
+// digest references.
+func Tag(imageRef string) string {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return ""
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return ""
+	}
+	if tagged, ok := reference.TagNameOnly(named).(reference.Tagged); ok {
+		return tagged.Tag()
+	}
+	return ""
+}
+
+// LocalDigest returns the manifest digest recorded for imageRef's
+// repository in an image's RepoDigests (e.g. "nginx@sha256:..."), or "" if
+// the image wasn't pulled from that repository.
+func LocalDigest(repoDigests []string, imageRef string) string {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return ""
+	}
+	for _, rd := range repoDigests {
+		digested, err := reference.ParseNormalizedNamed(rd)
+		if err != nil || digested.Name() != named.Name() {
+			continue
+		}
+		if d, ok := digested.(reference.Digested); ok {
+			return d.Digest().String()
+		}
+	}
+	return ""
+}
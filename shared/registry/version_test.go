@@ -0,0 +1,92 @@
+package registry
+
+import "testing"
+
+func TestLatestVersion(t *testing.T) {
+	tags := []string{"latest", "1.2.3", "1.2.10", "1.3.0", "1.3.0-rc1", "1.4.0-alpine", "2.0", "v1.5.0", "1.10.0-alpine"}
+
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{"1.2.3", "1.3.0"},
+		{"1.3.0", ""},
+		{"1.2.3-alpine", "1.10.0-alpine"},
+		{"1.9", "2.0"},
+		{"v1.0.0", "v1.5.0"},
+		{"latest", ""},
+		{"stable", ""},
+	}
+	for _, tt := range tests {
+		if got := LatestVersion(tt.current, tags); got != tt.want {
+			t.Errorf("LatestVersion(%q) = %q, want %q", tt.current, got, tt.want)
+		}
+	}
+}
+
+func TestLatestVersionWithin(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.9", "1.3.0", "1.4.1", "2.0.0", "1.5", "2.1"}
+
+	tests := []struct {
+		current string
+		keep    int
+		want    string
+	}{
+		{"1.2.3", 2, "1.2.9"},
+		{"1.2.3", 1, "1.4.1"},
+		{"1.2.3", 0, "2.0.0"},
+		{"1.2.9", 2, ""},
+		{"1.5", 1, ""},
+		{"1.5", 2, ""},
+		{"1.5", 0, "2.1"},
+		{"latest", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := LatestVersionWithin(tt.current, tags, tt.keep); got != tt.want {
+			t.Errorf("LatestVersionWithin(%q, %d) = %q, want %q", tt.current, tt.keep, got, tt.want)
+		}
+	}
+}
+
+func TestIsVersionTag(t *testing.T) {
+	for _, tag := range []string{"1", "1.2", "v3.0.1", "2.4-alpine"} {
+		if !IsVersionTag(tag) {
+			t.Errorf("%q: expected version tag", tag)
+		}
+	}
+	for _, tag := range []string{"", "latest", "stable", "1.x", "v", "bookworm-1.2"} {
+		if IsVersionTag(tag) {
+			t.Errorf("%q: unexpected version tag", tag)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                       "latest",
+		"nginx:1.27":                  "1.27",
+		"registry.local:5000/app:2.0": "2.0",
+		"nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef": "",
+		"Invalid Ref": "",
+	}
+	for ref, want := range tests {
+		if got := Tag(ref); got != want {
+			t.Errorf("Tag(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestLocalDigest(t *testing.T) {
+	const d = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	repoDigests := []string{"mirror.local/nginx@" + d, "nginx@" + d}
+
+	if got := LocalDigest(repoDigests, "nginx:1.27"); got != d {
+		t.Errorf("got %q", got)
+	}
+	if got := LocalDigest(repoDigests, "docker.io/library/nginx:latest"); got != d {
+		t.Errorf("normalized name: got %q", got)
+	}
+	if got := LocalDigest(repoDigests, "other/app:1.0"); got != "" {
+		t.Errorf("unrelated repo: got %q", got)
+	}
+}
@@ -30,6 +30,7 @@ func ExtractConfig(
 	newImageLabels map[string]string,
 	oldImageEnv []string,
 	isPodman bool,
+	isWindows bool,
 ) (*ExtractedConfig, error) {
 
 	// STRUCT COPY - preserves ALL fields including DeviceRequests, Healthcheck, Tmpfs, etc.
@@ -39,8 +40,14 @@ func ExtractConfig(
 	// STRUCT COPY - preserves ALL fields including DeviceRequests, Resources, etc.
 	newHostConfig := *inspect.HostConfig
 
-	// Apply Podman compatibility fixes
-	if isPodman {
+	// Apply platform compatibility fixes. Linux-only HostConfig mutations
+	// (Podman's cgroup conversions) never apply to Windows containers.
+	if isWindows {
+		if err := validateWindowsHostConfig(&newHostConfig); err != nil {
+			return nil, err
+		}
+		applyWindowsFixes(log, &newHostConfig)
+	} else if isPodman {
 		applyPodmanFixes(log, &newHostConfig)
 	}
 
@@ -74,7 +81,7 @@ func ExtractConfig(
 	newConfig.Env = ExtractUserEnv(log, newConfig.Env, oldImageEnv)
 
 	// Extract network configuration
-	primaryNetConfig, additionalNetworks := extractNetworkConfig(log, inspect)
+	primaryNetConfig, additionalNetworks := extractNetworkConfig(log, inspect, isWindows)
 
 	containerName := strings.TrimPrefix(inspect.Name, "/")
 
@@ -219,6 +226,7 @@ func ExtractUserEnv(
 func extractNetworkConfig(
 	log *logrus.Logger,
 	inspect *types.ContainerJSON,
+	isWindows bool,
 ) (*network.NetworkingConfig, map[string]*network.EndpointSettings) {
 
 	if inspect.NetworkSettings == nil || inspect.NetworkSettings.Networks == nil {
@@ -233,10 +241,11 @@ func extractNetworkConfig(
 		return nil, nil
 	}
 
-	// Filter to custom networks only (exclude bridge, host, none)
+	// Filter to custom networks only (exclude bridge, host, none; nat on Windows)
+	builtin := builtinNetworks(isWindows)
 	customNetworks := make(map[string]*network.EndpointSettings)
 	for name, data := range networks {
-		if name != "bridge" && name != "host" && name != "none" {
+		if !builtin[name] {
 			customNetworks[name] = data
 		}
 	}
@@ -248,7 +257,7 @@ func extractNetworkConfig(
 	// Determine primary network
 	primaryNetwork := networkMode
 	if primaryNetwork == "" || primaryNetwork == "default" {
-		primaryNetwork = "bridge"
+		primaryNetwork = defaultNetwork(isWindows)
 	}
 	// If NetworkMode doesn't match a network name, use first custom network
 	if _, exists := customNetworks[primaryNetwork]; !exists && len(customNetworks) > 0 {
@@ -267,7 +276,9 @@ func extractNetworkConfig(
 		if networkName == primaryNetwork {
 			hasConfig := endpointConfig.IPAMConfig != nil ||
 				len(endpointConfig.Aliases) > 0 ||
-				len(endpointConfig.Links) > 0
+				len(endpointConfig.Links) > 0 ||
+				len(endpointConfig.DriverOpts) > 0 ||
+				endpointConfig.GwPriority != 0
 
 			if hasConfig {
 				primaryNetConfig = &network.NetworkingConfig{
@@ -275,7 +286,7 @@ func extractNetworkConfig(
 						networkName: endpointConfig,
 					},
 				}
-				log.Debugf("Primary network %s has static config (IP/aliases/links)", networkName)
+				log.Debugf("Primary network %s has static config (IP/aliases/links/driver opts)", networkName)
 			}
 		} else {
 			additionalNetworks[networkName] = endpointConfig
@@ -292,10 +303,12 @@ func extractNetworkConfig(
 }
 
 // buildEndpointConfig creates an EndpointSettings with user-configured values only.
+// Operational data (assigned addresses, gateways, prefix lengths) is left out so
+// the daemon assigns it again from the network's IPv4 and IPv6 pools.
 func buildEndpointConfig(data *network.EndpointSettings) *network.EndpointSettings {
 	endpoint := &network.EndpointSettings{}
 
-	// Extract IPAM config (static IPs)
+	// Extract IPAM config (static IPv4/IPv6 addresses and --link-local-ip)
 	if data.IPAMConfig != nil {
 		ipam := &network.EndpointIPAMConfig{}
 		if data.IPAMConfig.IPv4Address != "" {
@@ -304,11 +317,21 @@ func buildEndpointConfig(data *network.EndpointSettings) *network.EndpointSettin
 		if data.IPAMConfig.IPv6Address != "" {
 			ipam.IPv6Address = data.IPAMConfig.IPv6Address
 		}
-		if ipam.IPv4Address != "" || ipam.IPv6Address != "" {
+		if len(data.IPAMConfig.LinkLocalIPs) > 0 {
+			ipam.LinkLocalIPs = data.IPAMConfig.LinkLocalIPs
+		}
+		if ipam.IPv4Address != "" || ipam.IPv6Address != "" || len(ipam.LinkLocalIPs) > 0 {
 			endpoint.IPAMConfig = ipam
 		}
 	}
 
+	// Per-network driver options (e.g. IPv6 sysctls such as accept_ra) and
+	// which network provides the default IPv4/IPv6 gateway
+	if len(data.DriverOpts) > 0 {
+		endpoint.DriverOpts = data.DriverOpts
+	}
+	endpoint.GwPriority = data.GwPriority
+
 	// Filter aliases - remove auto-generated short ID (12 chars)
 	if len(data.Aliases) > 0 {
 		var userAliases []string
@@ -344,6 +367,24 @@ func GetImageLabels(ctx context.Context, cli *client.Client, imageRef string) (m
 	return img.Config.Labels, nil
 }
 
+// inPlaceImage returns the image to recreate c from without changing what it
+// runs: its configured reference while that still resolves to c's image, so
+// the new container keeps a tag for update checks, otherwise the image ID.
+func inPlaceImage(ctx context.Context, cli *client.Client, c *types.ContainerJSON) string {
+	var refID string
+	if img, _, err := cli.ImageInspectWithRaw(ctx, c.Config.Image); err == nil {
+		refID = img.ID
+	}
+	return pickInPlaceImage(c.Config.Image, refID, c.Image)
+}
+
+func pickInPlaceImage(ref, refID, imageID string) string {
+	if imageID == "" || refID == imageID {
+		return ref
+	}
+	return imageID
+}
+
 // GetImageEnv returns the env vars defined in an image's ENV directives.
 func GetImageEnv(ctx context.Context, cli *client.Client, imageRef string) ([]string, error) {
 	img, _, err := cli.ImageInspectWithRaw(ctx, imageRef)
@@ -536,7 +536,7 @@ func TestExtractNetworkConfig_BridgeMode(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	if primaryNet != nil {
 		t.Error("Expected nil primary network config for bridge mode")
@@ -561,7 +561,7 @@ func TestExtractNetworkConfig_HostMode(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	if primaryNet != nil {
 		t.Error("Expected nil primary network config for host mode")
@@ -586,7 +586,7 @@ func TestExtractNetworkConfig_ContainerMode(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	if primaryNet != nil {
 		t.Error("Expected nil primary network config for container mode")
@@ -618,7 +618,7 @@ func TestExtractNetworkConfig_SingleCustomNetworkNoConfig(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	// Simple network connection - no manual config needed (no static IP or aliases)
 	if primaryNet != nil {
@@ -654,7 +654,7 @@ func TestExtractNetworkConfig_SingleCustomNetworkWithStaticIP(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	// Manual connection required for static IP
 	if primaryNet == nil {
@@ -676,6 +676,45 @@ func TestExtractNetworkConfig_SingleCustomNetworkWithStaticIP(t *testing.T) {
 	}
 }
 
+func TestExtractNetworkConfig_DualStackGatewayPriority(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	inspect := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{
+				NetworkMode: "dualstack",
+			},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"dualstack": {
+					NetworkID:           "abc123",
+					IPAddress:           "172.20.0.5",
+					GlobalIPv6Address:   "fd00:20::5",
+					GlobalIPv6PrefixLen: 64,
+					IPv6Gateway:         "fd00:20::1",
+					GwPriority:          100,
+				},
+			},
+		},
+	}
+
+	primaryNet, _ := extractNetworkConfig(log, inspect, false)
+
+	// Dynamic addresses alone don't need a config, but the gateway priority does
+	if primaryNet == nil {
+		t.Fatal("Expected primary network config for gateway priority")
+	}
+	endpoint := primaryNet.EndpointsConfig["dualstack"]
+	if endpoint == nil || endpoint.GwPriority != 100 {
+		t.Fatalf("Expected GwPriority=100, got %+v", endpoint)
+	}
+	if endpoint.IPAMConfig != nil || endpoint.IPv6Gateway != "" {
+		t.Errorf("Expected daemon-assigned IPv6 settings to be dropped, got %+v", endpoint)
+	}
+}
+
 func TestExtractNetworkConfig_SingleCustomNetworkWithAliases(t *testing.T) {
 	log := logrus.New()
 	log.SetLevel(logrus.ErrorLevel)
@@ -697,7 +736,7 @@ func TestExtractNetworkConfig_SingleCustomNetworkWithAliases(t *testing.T) {
 		},
 	}
 
-	primaryNet, _ := extractNetworkConfig(log, inspect)
+	primaryNet, _ := extractNetworkConfig(log, inspect, false)
 
 	// Manual connection required for aliases
 	if primaryNet == nil {
@@ -745,7 +784,7 @@ func TestExtractNetworkConfig_MultipleNetworks(t *testing.T) {
 		},
 	}
 
-	primaryNet, additionalNets := extractNetworkConfig(log, inspect)
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, false)
 
 	// Primary network should have config
 	if primaryNet == nil {
@@ -777,7 +816,7 @@ func TestExtractNetworkConfig_EmptyNetworkSettings(t *testing.T) {
 		NetworkSettings: nil,
 	}
 
-	primaryNet, _ := extractNetworkConfig(log, inspect)
+	primaryNet, _ := extractNetworkConfig(log, inspect, false)
 
 	if primaryNet != nil {
 		t.Error("Expected nil primary network config for nil NetworkSettings")
@@ -826,6 +865,78 @@ func TestBuildEndpointConfig_WithIPv6(t *testing.T) {
 	}
 }
 
+func TestBuildEndpointConfig_IPv6OnlyStatic(t *testing.T) {
+	data := &network.EndpointSettings{
+		IPAMConfig: &network.EndpointIPAMConfig{
+			IPv6Address: "fd00:dead:beef::10",
+		},
+	}
+
+	result := buildEndpointConfig(data)
+
+	if result.IPAMConfig == nil || result.IPAMConfig.IPv6Address != "fd00:dead:beef::10" {
+		t.Fatalf("Expected IPv6-only static address to be preserved, got %+v", result.IPAMConfig)
+	}
+	if result.IPAMConfig.IPv4Address != "" {
+		t.Errorf("Expected no IPv4 address, got %s", result.IPAMConfig.IPv4Address)
+	}
+}
+
+func TestBuildEndpointConfig_PreservesLinkLocalIPs(t *testing.T) {
+	data := &network.EndpointSettings{
+		IPAMConfig: &network.EndpointIPAMConfig{
+			LinkLocalIPs: []string{"169.254.10.10", "fe80::10"},
+		},
+	}
+
+	result := buildEndpointConfig(data)
+
+	if result.IPAMConfig == nil {
+		t.Fatal("Expected IPAMConfig for link-local only config")
+	}
+	if len(result.IPAMConfig.LinkLocalIPs) != 2 {
+		t.Errorf("Expected 2 link-local IPs, got %v", result.IPAMConfig.LinkLocalIPs)
+	}
+}
+
+func TestBuildEndpointConfig_DropsOperationalIPv6(t *testing.T) {
+	// Daemon-assigned addresses, gateway and prefix are re-assigned from the
+	// network's IPAM config on create and must not be pinned
+	data := &network.EndpointSettings{
+		IPAddress:           "172.18.0.5",
+		IPPrefixLen:         16,
+		Gateway:             "172.18.0.1",
+		GlobalIPv6Address:   "fd00::5",
+		GlobalIPv6PrefixLen: 64,
+		IPv6Gateway:         "fd00::1",
+	}
+
+	result := buildEndpointConfig(data)
+
+	if result.IPAMConfig != nil {
+		t.Errorf("Expected nil IPAMConfig for dynamic addresses, got %+v", result.IPAMConfig)
+	}
+	if result.GlobalIPv6Address != "" || result.IPv6Gateway != "" || result.GlobalIPv6PrefixLen != 0 {
+		t.Errorf("Expected operational IPv6 data to be dropped, got %+v", result)
+	}
+}
+
+func TestBuildEndpointConfig_PreservesDriverOptsAndGwPriority(t *testing.T) {
+	data := &network.EndpointSettings{
+		DriverOpts: map[string]string{"com.docker.network.endpoint.sysctls": "net.ipv6.conf.IFNAME.accept_ra=2"},
+		GwPriority: 10,
+	}
+
+	result := buildEndpointConfig(data)
+
+	if result.DriverOpts["com.docker.network.endpoint.sysctls"] != "net.ipv6.conf.IFNAME.accept_ra=2" {
+		t.Errorf("Expected driver opts to be preserved, got %v", result.DriverOpts)
+	}
+	if result.GwPriority != 10 {
+		t.Errorf("Expected GwPriority=10, got %d", result.GwPriority)
+	}
+}
+
 func TestBuildEndpointConfig_FiltersAutoGeneratedAliases(t *testing.T) {
 	data := &network.EndpointSettings{
 		Aliases: []string{"web", "frontend", "abc123def456"}, // Last is 12-char auto-generated
@@ -865,3 +976,21 @@ func TestBuildEndpointConfig_EmptyConfig(t *testing.T) {
 		t.Error("Expected nil Links for empty data")
 	}
 }
+
+func TestPickInPlaceImage(t *testing.T) {
+	tests := []struct {
+		name, ref, refID, imageID, want string
+	}{
+		{"tag unchanged", "nginx:latest", "sha256:aaa", "sha256:aaa", "nginx:latest"},
+		{"tag moved", "nginx:latest", "sha256:bbb", "sha256:aaa", "sha256:aaa"},
+		{"tag gone", "nginx:latest", "", "sha256:aaa", "sha256:aaa"},
+		{"image ID unknown", "nginx:latest", "", "", "nginx:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickInPlaceImage(tt.ref, tt.refID, tt.imageID); got != tt.want {
+				t.Errorf("pickInPlaceImage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
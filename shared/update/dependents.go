@@ -6,14 +6,29 @@ import (
 	"strings"
 	"time"
 
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 )
 
-// FindDependentContainers finds all containers that depend on the given container
-// via network_mode: container:X
+// LabelDependsOn declares containers (comma-separated names or IDs) that a
+// container must be recreated with, e.g. dockmon.depends_on=gluetun. It covers
+// sidecars on normal networks that still need a fresh start with their parent.
+const LabelDependsOn = "dockmon.depends_on"
+
+// Ways a container can depend on another
+const (
+	DependencyNetworkMode = "network_mode" // network_mode: container:X
+	DependencyLabel       = "label"        // dockmon.depends_on label
+	DependencyCompose     = "compose"      // compose depends_on with restart: true
+)
+
+// FindDependentContainers finds all containers that depend on the given
+// container, via network_mode: container:X, the dockmon.depends_on label or
+// compose depends_on entries marked restart: true
 func FindDependentContainers(
 	ctx context.Context,
 	cli *client.Client,
@@ -42,36 +57,88 @@ func FindDependentContainers(
 			continue
 		}
 
+		// Check if this container depends on our parent
+		dependency := dependencyOn(&inspect, parentContainer, parentName, parentID)
+		if dependency == "" {
+			continue
+		}
+
+		imageName := inspect.Config.Image
+		if imageName == "" && len(inspect.Image) > 0 {
+			imageName = inspect.Image
+		}
+
 		networkMode := string(inspect.HostConfig.NetworkMode)
+		depName := strings.TrimPrefix(inspect.Name, "/")
+		log.Infof("Found dependent container: %s (%s, network_mode: %s)", depName, dependency, networkMode)
 
-		// Check if this container depends on our parent
-		isDependent := networkMode == fmt.Sprintf("container:%s", parentName) ||
-			networkMode == fmt.Sprintf("container:%s", parentID) ||
-			networkMode == fmt.Sprintf("container:%s", parentContainer.ID)
-
-		if isDependent {
-			imageName := inspect.Config.Image
-			if imageName == "" && len(inspect.Image) > 0 {
-				imageName = inspect.Image
+		dependents = append(dependents, DependentContainer{
+			Container:      inspect,
+			Name:           depName,
+			ID:             truncateID(inspect.ID),
+			Image:          imageName,
+			OldNetworkMode: networkMode,
+			Dependency:     dependency,
+		})
+	}
+
+	return dependents, nil
+}
+
+// dependencyOn reports how a container depends on the parent, or "" if it
+// doesn't. network_mode wins since it also requires rewiring the container.
+func dependencyOn(inspect, parent *types.ContainerJSON, parentName, parentID string) string {
+	if inspect.ContainerJSONBase == nil {
+		return ""
+	}
+	parentRefs := []string{parentName, parentID, parent.ID}
+
+	if inspect.HostConfig != nil {
+		networkMode := string(inspect.HostConfig.NetworkMode)
+		for _, ref := range parentRefs {
+			if ref != "" && networkMode == "container:"+ref {
+				return DependencyNetworkMode
 			}
+		}
+	}
 
-			depName := strings.TrimPrefix(inspect.Name, "/")
-			log.Infof("Found dependent container: %s (network_mode: %s)", depName, networkMode)
+	if inspect.Config == nil {
+		return ""
+	}
+	labels := inspect.Config.Labels
 
-			dependents = append(dependents, DependentContainer{
-				Container:      inspect,
-				Name:           depName,
-				ID:             truncateID(inspect.ID),
-				Image:          imageName,
-				OldNetworkMode: networkMode,
-			})
+	for _, dep := range strings.Split(labels[LabelDependsOn], ",") {
+		dep = strings.TrimPrefix(strings.TrimSpace(dep), "/")
+		if dep == "" {
+			continue
+		}
+		for _, ref := range parentRefs {
+			if ref != "" && (dep == ref || (len(dep) >= 12 && strings.HasPrefix(parent.ID, dep))) {
+				return DependencyLabel
+			}
 		}
 	}
 
-	return dependents, nil
+	// Compose records depends_on as "service:condition:restart,..."
+	if parent.Config == nil {
+		return ""
+	}
+	project := labels[composeProjectLabel]
+	service := parent.Config.Labels[composeServiceLabel]
+	if project == "" || service == "" || parent.Config.Labels[composeProjectLabel] != project {
+		return ""
+	}
+	for _, entry := range strings.Split(labels[composeDependsOnLabel], ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) >= 3 && parts[0] == service && parts[2] == "true" {
+			return DependencyCompose
+		}
+	}
+	return ""
 }
 
-// RecreateDependentContainers recreates all dependent containers with updated network_mode.
+// RecreateDependentContainers recreates all dependent containers, pointing
+// network_mode dependents at the new parent.
 // Returns list of container names that failed to recreate.
 func RecreateDependentContainers(
 	ctx context.Context,
@@ -81,11 +148,12 @@ func RecreateDependentContainers(
 	newParentID string,
 	stopTimeout int,
 	isPodman bool,
+	isWindows bool,
 ) []string {
 	var failed []string
 
 	for _, dep := range dependents {
-		if err := recreateDependentContainer(ctx, cli, log, dep, newParentID, stopTimeout, isPodman); err != nil {
+		if err := recreateDependentContainer(ctx, cli, log, dep, newParentID, stopTimeout, isPodman, isWindows); err != nil {
 			log.WithError(err).Errorf("Failed to recreate dependent container %s", dep.Name)
 			failed = append(failed, dep.Name)
 		}
@@ -94,7 +162,8 @@ func RecreateDependentContainers(
 	return failed
 }
 
-// recreateDependentContainer recreates a single dependent container with updated network_mode.
+// recreateDependentContainer recreates a single dependent container, with
+// updated network_mode if it shares the parent's network stack.
 func recreateDependentContainer(
 	ctx context.Context,
 	cli *client.Client,
@@ -103,6 +172,7 @@ func recreateDependentContainer(
 	newParentID string,
 	stopTimeout int,
 	isPodman bool,
+	isWindows bool,
 ) error {
 	log.Infof("Recreating dependent container: %s", dep.Name)
 
@@ -112,20 +182,25 @@ func recreateDependentContainer(
 	emptyLabels := make(map[string]string)
 
 	// Extract config from dependent container
-	extractedConfig, err := ExtractConfig(ctx, cli, log, &dep.Container, dep.Image, emptyLabels, emptyLabels, nil, isPodman)
+	extractedConfig, err := ExtractConfig(ctx, cli, log, &dep.Container, dep.Image, emptyLabels, emptyLabels, nil, isPodman, isWindows)
 	if err != nil {
 		return fmt.Errorf("failed to extract config: %w", err)
 	}
 
-	// Update NetworkMode to point to new parent
-	oldNetworkMode := string(extractedConfig.HostConfig.NetworkMode)
-	extractedConfig.HostConfig.NetworkMode = container.NetworkMode(fmt.Sprintf("container:%s", newParentID))
-	log.Infof("Updated NetworkMode: %s -> container:%s", oldNetworkMode, truncateID(newParentID))
+	// Update NetworkMode to point to new parent. Other dependents keep their
+	// own networks, which are set at creation.
+	var networkingConfig *network.NetworkingConfig
+	if dep.Dependency == DependencyNetworkMode || dep.Dependency == "" {
+		oldNetworkMode := string(extractedConfig.HostConfig.NetworkMode)
+		extractedConfig.HostConfig.NetworkMode = container.NetworkMode(fmt.Sprintf("container:%s", newParentID))
+		log.Infof("Updated NetworkMode: %s -> container:%s", oldNetworkMode, truncateID(newParentID))
+	} else {
+		networkingConfig = extractedConfig.NetworkingConfig
+	}
 
-	// Stop dependent container
+	// Stop dependent container with its own StopSignal/StopTimeout
 	log.Debugf("Stopping dependent container: %s", dep.Name)
-	stopTimeoutInt := stopTimeout
-	if err := cli.ContainerStop(ctx, dep.Container.ID, container.StopOptions{Timeout: &stopTimeoutInt}); err != nil {
+	if err := sharedDocker.StopContainer(ctx, cli, dep.Container.ID, nil, stopTimeout); err != nil {
 		// Try kill if stop fails
 		cli.ContainerKill(ctx, dep.Container.ID, "SIGKILL")
 	}
@@ -141,7 +216,7 @@ func recreateDependentContainer(
 		ctx,
 		extractedConfig.Config,
 		extractedConfig.HostConfig,
-		nil, // NetworkingConfig not needed for network_mode: container:X
+		networkingConfig, // Not needed for network_mode: container:X
 		nil,
 		dep.Name,
 	)
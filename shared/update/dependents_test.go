@@ -325,6 +325,61 @@ func TestFindDependentContainers_NoDependents(t *testing.T) {
 	}
 }
 
+func TestDependencyOn(t *testing.T) {
+	parentID := "abc123def45678901234567890123456789012345678901234567890123456"
+	parent := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: parentID, Name: "/gluetun"},
+		Config: &container.Config{Labels: map[string]string{
+			composeProjectLabel: "media",
+			composeServiceLabel: "vpn",
+		}},
+	}
+
+	dep := func(networkMode string, labels map[string]string) *types.ContainerJSON {
+		return &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:         "def456",
+				Name:       "/sidecar",
+				HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(networkMode)},
+			},
+			Config: &container.Config{Labels: labels},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		container *types.ContainerJSON
+		want      string
+	}{
+		{"network mode by name", dep("container:gluetun", nil), DependencyNetworkMode},
+		{"network mode by full ID", dep("container:"+parentID, nil), DependencyNetworkMode},
+		{"label by name", dep("bridge", map[string]string{LabelDependsOn: "db, gluetun"}), DependencyLabel},
+		{"label by ID prefix", dep("bridge", map[string]string{LabelDependsOn: parentID[:16]}), DependencyLabel},
+		{"label for another container", dep("bridge", map[string]string{LabelDependsOn: "db"}), ""},
+		{"compose restart", dep("media_default", map[string]string{
+			composeProjectLabel:   "media",
+			composeDependsOnLabel: "db:service_started:false,vpn:service_healthy:true",
+		}), DependencyCompose},
+		{"compose without restart", dep("media_default", map[string]string{
+			composeProjectLabel:   "media",
+			composeDependsOnLabel: "vpn:service_healthy:false",
+		}), ""},
+		{"compose in another project", dep("other_default", map[string]string{
+			composeProjectLabel:   "other",
+			composeDependsOnLabel: "vpn:service_healthy:true",
+		}), ""},
+		{"independent", dep("bridge", nil), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dependencyOn(tt.container, parent, "gluetun", parentID[:12]); got != tt.want {
+				t.Errorf("dependencyOn() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Test helper that allows injecting mock client
 // =============================================================================
@@ -359,11 +414,9 @@ func findDependentContainersWithClient(
 		networkMode := string(inspect.HostConfig.NetworkMode)
 
 		// Check all forms of dependency
-		isDependent := networkMode == "container:"+parentName ||
-			networkMode == "container:"+parentID ||
-			networkMode == "container:"+parentContainer.ID
+		dependency := dependencyOn(&inspect, parentContainer, parentName, parentID)
 
-		if isDependent {
+		if dependency != "" {
 			imageName := ""
 			if inspect.Config != nil {
 				imageName = inspect.Config.Image
@@ -380,6 +433,7 @@ func findDependentContainersWithClient(
 				ID:             truncateID(inspect.ID),
 				Image:          imageName,
 				OldNetworkMode: networkMode,
+				Dependency:     dependency,
 			})
 		}
 	}
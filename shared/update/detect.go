@@ -26,6 +26,17 @@ func DetectOptions(ctx context.Context, cli *client.Client, log *logrus.Logger)
 		log.Info("Detected Podman runtime - will apply compatibility fixes")
 	}
 
+	// Detect Windows containers (Docker Desktop/Engine in Windows mode)
+	isWindows, err := detectWindows(ctx, cli)
+	if err != nil {
+		log.WithError(err).Warn("Failed to detect container OS, assuming Linux")
+	}
+	options.IsWindows = isWindows
+
+	if isWindows {
+		log.Info("Detected Windows containers - Linux-only configuration will be skipped")
+	}
+
 	// Detect API version for networking_config support
 	supportsNetworkingConfig, err := detectNetworkingConfigSupport(ctx, cli)
 	if err != nil {
@@ -70,6 +81,15 @@ func detectPodman(ctx context.Context, cli *client.Client) (bool, error) {
 	return false, nil
 }
 
+// detectWindows returns true if the daemon runs Windows containers.
+func detectWindows(ctx context.Context, cli *client.Client) (bool, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get Docker info: %w", err)
+	}
+	return info.OSType == osWindows, nil
+}
+
 // detectNetworkingConfigSupport returns true if API >= 1.44 (can set network at creation).
 func detectNetworkingConfigSupport(ctx context.Context, cli *client.Client) (bool, error) {
 	apiVersion, err := getAPIVersion(ctx, cli)
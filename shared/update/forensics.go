@@ -0,0 +1,267 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// Failure forensics limits
+const (
+	// DefaultFailureLogLines is how many log lines of a failed new container
+	// are kept when the request doesn't say
+	DefaultFailureLogLines = 200
+	// MaxFailureLogLines caps UpdateRequest.FailureLogLines
+	MaxFailureLogLines = 5000
+	// MaxQuarantineHours caps UpdateRequest.QuarantineHours (one week)
+	MaxQuarantineHours = 168
+
+	// failureLogMaxBytes bounds the captured log; the oldest part goes first
+	failureLogMaxBytes = 256 << 10
+	// forensicsTimeout bounds the capture so a hung daemon can't hold up the
+	// rollback
+	forensicsTimeout = 30 * time.Second
+	// quarantineInfix separates a quarantined container's original name from
+	// the Unix time it expires at
+	quarantineInfix = "-dockmon-quarantine-"
+)
+
+// quarantinePattern matches quarantined container names, capturing the expiry
+var quarantinePattern = regexp.MustCompile(regexp.QuoteMeta(quarantineInfix) + `(\d+)$`)
+
+// FailureForensics is captured from a new container that failed its health
+// check or post-update hooks, before the rollback removes it, so the failure
+// can still be diagnosed afterwards.
+type FailureForensics struct {
+	ContainerID  string            `json:"container_id"`
+	Status       string            `json:"status"` // State when the check failed, e.g. "running" or "exited"
+	ExitCode     int               `json:"exit_code"`
+	OOMKilled    bool              `json:"oom_killed,omitempty"`
+	Error        string            `json:"error,omitempty"` // Docker's own state error, e.g. a missing entrypoint
+	RestartCount int               `json:"restart_count"`
+	StartedAt    string            `json:"started_at,omitempty"`
+	FinishedAt   string            `json:"finished_at,omitempty"`
+	Health       *container.Health `json:"health,omitempty"` // Recent health check runs and their output
+
+	Logs          string `json:"logs,omitempty"` // Last log lines, stdout and stderr interleaved, with timestamps
+	LogsTruncated bool   `json:"logs_truncated,omitempty"`
+
+	// Inspect is the full inspect output, with environment values redacted
+	Inspect *container.InspectResponse `json:"inspect,omitempty"`
+
+	// QuarantineName is set when the failed container was kept, stopped,
+	// instead of being removed. It is removed by the first update on the host
+	// after QuarantineUntil.
+	QuarantineName  string     `json:"quarantine_name,omitempty"`
+	QuarantineUntil *time.Time `json:"quarantine_until,omitempty"`
+
+	// CaptureErrors lists the parts that couldn't be captured
+	CaptureErrors []string `json:"capture_errors,omitempty"`
+}
+
+// validateForensicsOptions checks the request's failure capture settings
+func validateForensicsOptions(req UpdateRequest) error {
+	if req.FailureLogLines > MaxFailureLogLines {
+		return fmt.Errorf("failure_log_lines must be at most %d", MaxFailureLogLines)
+	}
+	if req.QuarantineHours < 0 || req.QuarantineHours > MaxQuarantineHours {
+		return fmt.Errorf("quarantine_hours must be between 0 and %d", MaxQuarantineHours)
+	}
+	return nil
+}
+
+// failureLogLines resolves the request's log line count: zero means the
+// default and a negative value captures no logs
+func failureLogLines(req UpdateRequest) int {
+	switch {
+	case req.FailureLogLines == 0:
+		return DefaultFailureLogLines
+	case req.FailureLogLines < 0:
+		return 0
+	}
+	return req.FailureLogLines
+}
+
+// CaptureFailure records the state, inspect output and last logLines log
+// lines of a failed container. Parts that fail are listed in CaptureErrors;
+// the capture itself never fails.
+func CaptureFailure(ctx context.Context, cli client.APIClient, containerID string, logLines int) *FailureForensics {
+	ctx, cancel := context.WithTimeout(ctx, forensicsTimeout)
+	defer cancel()
+
+	f := &FailureForensics{ContainerID: truncateID(containerID)}
+
+	info, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		f.CaptureErrors = append(f.CaptureErrors, fmt.Sprintf("inspect: %v", err))
+	} else {
+		if state := info.State; state != nil {
+			f.Status = state.Status
+			f.ExitCode = state.ExitCode
+			f.OOMKilled = state.OOMKilled
+			f.Error = state.Error
+			f.StartedAt = state.StartedAt
+			f.FinishedAt = state.FinishedAt
+			f.Health = state.Health
+		}
+		f.RestartCount = info.RestartCount
+		redactEnv(info.Config)
+		f.Inspect = &info
+	}
+
+	if logLines > 0 {
+		tty := err == nil && info.Config != nil && info.Config.Tty
+		logs, truncated, err := tailLogs(ctx, cli, containerID, logLines, tty)
+		if err != nil {
+			f.CaptureErrors = append(f.CaptureErrors, fmt.Sprintf("logs: %v", err))
+		}
+		f.Logs = logs
+		f.LogsTruncated = truncated
+	}
+	return f
+}
+
+// tailLogs reads a container's last lines of log, keeping at most
+// failureLogMaxBytes of the most recent output
+func tailLogs(ctx context.Context, cli client.APIClient, containerID string, lines int, tty bool) (string, bool, error) {
+	logs, err := cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	defer logs.Close()
+
+	var buf bytes.Buffer
+	if tty {
+		_, err = io.Copy(&buf, logs)
+	} else {
+		_, err = stdcopy.StdCopy(&buf, &buf, logs)
+	}
+	out := buf.Bytes()
+	truncated := len(out) > failureLogMaxBytes
+	if truncated {
+		out = out[len(out)-failureLogMaxBytes:]
+	}
+	return string(out), truncated, err
+}
+
+// redactEnv keeps the names of the container's environment variables but
+// not their values, which often hold credentials
+func redactEnv(cfg *container.Config) {
+	if cfg == nil {
+		return
+	}
+	for i, kv := range cfg.Env {
+		if name, _, ok := strings.Cut(kv, "="); ok {
+			cfg.Env[i] = name + "=<redacted>"
+		}
+	}
+}
+
+// quarantineName is the name a failed container is kept under until expiry
+func quarantineName(containerName string, expiry time.Time) string {
+	return fmt.Sprintf("%s%s%d", containerName, quarantineInfix, expiry.Unix())
+}
+
+// Quarantine keeps a stopped, failed container under a quarantine name
+// instead of removing it, with its restart policy cleared so a daemon restart
+// doesn't bring it back. On success it records the name and expiry in f.
+//
+// Compose-managed containers are refused: the renamed container would keep
+// its project and service labels, and compose would count it as one of the
+// project's containers on the next up or down.
+func Quarantine(ctx context.Context, cli client.APIClient, log *logrus.Logger, containerID, containerName string, hours int, f *FailureForensics) error {
+	inspect, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+	if inspect.Config != nil && inspect.Config.Labels[composeProjectLabel] != "" {
+		return fmt.Errorf("container belongs to compose project %q and can't be quarantined", inspect.Config.Labels[composeProjectLabel])
+	}
+
+	if _, err := cli.ContainerUpdate(ctx, containerID, container.UpdateConfig{
+		RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyDisabled},
+	}); err != nil {
+		return fmt.Errorf("failed to clear restart policy: %w", err)
+	}
+
+	until := time.Now().Add(time.Duration(hours) * time.Hour).UTC().Truncate(time.Second)
+	name := quarantineName(containerName, until)
+	if err := cli.ContainerRename(ctx, containerID, name); err != nil {
+		return fmt.Errorf("failed to rename container to %s: %w", name, err)
+	}
+
+	log.Warnf("Kept failed container %s as %s until %s", truncateID(containerID), name, until.Format(time.RFC3339))
+	f.QuarantineName = name
+	f.QuarantineUntil = &until
+	return nil
+}
+
+// RemoveExpiredQuarantines removes quarantined containers whose time is up,
+// returning the names removed
+func RemoveExpiredQuarantines(ctx context.Context, cli client.APIClient, log *logrus.Logger, now time.Time) []string {
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", quarantineInfix)),
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to list quarantined containers")
+		return nil
+	}
+
+	var removed []string
+	for _, c := range containers {
+		for _, name := range c.Names {
+			name = strings.TrimPrefix(name, "/")
+			m := quarantinePattern.FindStringSubmatch(name)
+			if m == nil {
+				continue
+			}
+			expiry, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil || now.Before(time.Unix(expiry, 0)) {
+				break
+			}
+			if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+				log.WithError(err).Warnf("Failed to remove expired quarantined container %s", name)
+				break
+			}
+			log.Infof("Removed expired quarantined container %s", name)
+			removed = append(removed, name)
+			break
+		}
+	}
+	return removed
+}
+
+// discardFailed captures a failed new container, stops it and then removes
+// it or, when the request asks for it, quarantines it
+func (u *Updater) discardFailed(ctx context.Context, req UpdateRequest, containerID, containerName string) *FailureForensics {
+	f := CaptureFailure(ctx, u.cli, containerID, failureLogLines(req))
+	u.stopContainer(ctx, containerID, req)
+
+	if req.QuarantineHours > 0 {
+		err := Quarantine(ctx, u.cli, u.log, containerID, containerName, req.QuarantineHours, f)
+		if err == nil {
+			return f
+		}
+		u.log.WithError(err).Warn("Failed to quarantine failed container, removing it")
+		f.CaptureErrors = append(f.CaptureErrors, fmt.Sprintf("quarantine: %v", err))
+	}
+	u.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
+	return f
+}
@@ -0,0 +1,170 @@
+package update
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeForensicsDaemon serves a failed container "abc123" and a compose-managed
+// container "svc456", and records the calls that change containers
+func fakeForensicsDaemon(t *testing.T, list string) (*[]string, client.APIClient) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path := r.URL.Path[strings.Index(r.URL.Path, "/containers"):]
+		switch {
+		case r.Method == http.MethodGet && path == "/containers/abc123/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"abc123","Name":"/web","RestartCount":3,
+				"State":{"Status":"exited","ExitCode":2,"Error":"","FinishedAt":"2026-01-01T00:00:00Z",
+					"Health":{"Status":"unhealthy","FailingStreak":3,"Log":[{"ExitCode":1,"Output":"connection refused"}]}},
+				"Config":{"Tty":false,"Env":["DB_PASSWORD=hunter2","PATH=/usr/bin"]}}`))
+		case r.Method == http.MethodGet && path == "/containers/svc456/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"svc456","Name":"/media-web-1","State":{"Status":"exited"},
+				"Config":{"Labels":{"com.docker.compose.project":"media","com.docker.compose.service":"web"}}}`))
+		case r.Method == http.MethodGet && path == "/containers/abc123/logs":
+			if r.URL.Query().Get("tail") != "50" || r.URL.Query().Get("timestamps") != "1" {
+				t.Errorf("logs requested with %s", r.URL.RawQuery)
+			}
+			stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte("starting\n"))
+			stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte("fatal: missing config\n"))
+		case r.Method == http.MethodGet && path == "/containers/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(list))
+		case r.Method == http.MethodPost || r.Method == http.MethodDelete:
+			body, _ := io.ReadAll(r.Body)
+			calls = append(calls, r.Method+" "+path+"?"+r.URL.RawQuery+" "+string(body))
+			if strings.HasSuffix(path, "/update") {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"Warnings":[]}`))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cli.Close() })
+	return &calls, cli
+}
+
+func TestCaptureFailure(t *testing.T) {
+	_, cli := fakeForensicsDaemon(t, `[]`)
+
+	f := CaptureFailure(context.Background(), cli, "abc123", 50)
+	if len(f.CaptureErrors) != 0 {
+		t.Fatalf("capture errors: %v", f.CaptureErrors)
+	}
+	if f.Status != "exited" || f.ExitCode != 2 || f.RestartCount != 3 || f.Health == nil || f.Health.FailingStreak != 3 {
+		t.Errorf("state = %+v", f)
+	}
+	if f.Logs != "starting\nfatal: missing config\n" {
+		t.Errorf("logs = %q", f.Logs)
+	}
+	if f.Inspect == nil || strings.Join(f.Inspect.Config.Env, ",") != "DB_PASSWORD=<redacted>,PATH=<redacted>" {
+		t.Errorf("inspect env not redacted: %+v", f.Inspect)
+	}
+
+	// A container that can't be inspected is still reported
+	f = CaptureFailure(context.Background(), cli, "gone", 0)
+	if len(f.CaptureErrors) != 1 || f.Logs != "" {
+		t.Errorf("capture of a missing container = %+v", f)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	calls, cli := fakeForensicsDaemon(t, `[]`)
+	f := &FailureForensics{}
+
+	if err := Quarantine(context.Background(), cli, logrus.New(), "abc123", "web", 24, f); err != nil {
+		t.Fatal(err)
+	}
+	if f.QuarantineUntil == nil || time.Until(*f.QuarantineUntil) < 23*time.Hour {
+		t.Errorf("quarantine until %v, want a day from now", f.QuarantineUntil)
+	}
+	if f.QuarantineName != quarantineName("web", *f.QuarantineUntil) {
+		t.Errorf("quarantine name = %q", f.QuarantineName)
+	}
+	if len(*calls) != 2 ||
+		!strings.Contains((*calls)[0], "/update") || !strings.Contains((*calls)[0], `"Name":"no"`) ||
+		!strings.Contains((*calls)[1], "/rename?name="+f.QuarantineName) {
+		t.Errorf("calls = %v, want the restart policy cleared before the rename", *calls)
+	}
+}
+
+func TestQuarantine_RefusesComposeContainers(t *testing.T) {
+	calls, cli := fakeForensicsDaemon(t, `[]`)
+	f := &FailureForensics{}
+
+	err := Quarantine(context.Background(), cli, logrus.New(), "svc456", "media-web-1", 24, f)
+	if err == nil || !strings.Contains(err.Error(), `"media"`) {
+		t.Fatalf("err = %v, want a compose project refusal", err)
+	}
+	if len(*calls) != 0 || f.QuarantineName != "" {
+		t.Errorf("calls = %v, quarantine name = %q, want the container left alone", *calls, f.QuarantineName)
+	}
+}
+
+func TestRemoveExpiredQuarantines(t *testing.T) {
+	now := time.Now()
+	expired := quarantineName("web", now.Add(-time.Minute))
+	current := quarantineName("api", now.Add(time.Hour))
+	calls, cli := fakeForensicsDaemon(t, `[
+		{"Id":"old","Names":["/`+expired+`"]},
+		{"Id":"new","Names":["/`+current+`"]},
+		{"Id":"other","Names":["/web-dockmon-quarantine-notes"]}
+	]`)
+
+	removed := RemoveExpiredQuarantines(context.Background(), cli, logrus.New(), now)
+	if strings.Join(removed, ",") != expired {
+		t.Errorf("removed %v, want only %s", removed, expired)
+	}
+	if len(*calls) != 1 || !strings.HasPrefix((*calls)[0], "DELETE /containers/old?") {
+		t.Errorf("calls = %v", *calls)
+	}
+}
+
+func TestValidateForensicsOptions(t *testing.T) {
+	tests := []struct {
+		req     UpdateRequest
+		wantErr bool
+		lines   int
+	}{
+		{UpdateRequest{}, false, DefaultFailureLogLines},
+		{UpdateRequest{FailureLogLines: -1}, false, 0},
+		{UpdateRequest{FailureLogLines: 20, QuarantineHours: 48}, false, 20},
+		{UpdateRequest{FailureLogLines: MaxFailureLogLines + 1}, true, 0},
+		{UpdateRequest{QuarantineHours: MaxQuarantineHours + 1}, true, 0},
+		{UpdateRequest{QuarantineHours: -1}, true, 0},
+	}
+	for _, tt := range tests {
+		err := validateForensicsOptions(tt.req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateForensicsOptions(%+v) = %v", tt.req, err)
+		}
+		if err == nil && failureLogLines(tt.req) != tt.lines {
+			t.Errorf("failureLogLines(%+v) = %d, want %d", tt.req, failureLogLines(tt.req), tt.lines)
+		}
+	}
+}
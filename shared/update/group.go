@@ -0,0 +1,451 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/progress"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// Compose labels used to derive update order from an existing stack.
+const (
+	composeProjectLabel   = "com.docker.compose.project"
+	composeServiceLabel   = "com.docker.compose.service"
+	composeDependsOnLabel = "com.docker.compose.depends_on"
+)
+
+// Group plan statuses.
+const (
+	GroupStatusPending    = "pending"
+	GroupStatusUpdating   = "updating"
+	GroupStatusUpdated    = "updated"
+	GroupStatusFailed     = "failed"
+	GroupStatusRolledBack = "rolled_back"
+	GroupStatusSkipped    = "skipped"
+)
+
+// Group-level stages (per-member stages reuse the single-update stage names).
+const (
+	StageGroupPlanning = string(progress.StagePlanning)
+)
+
+// GroupMember is one container in a group update.
+type GroupMember struct {
+	ContainerID  string        `json:"container_id"`
+	NewImage     string        `json:"new_image"`
+	DependsOn    []string      `json:"depends_on,omitempty"` // Names/IDs of other members to update first
+	RegistryAuth *RegistryAuth `json:"registry_auth,omitempty"`
+	Hooks        *UpdateHooks  `json:"hooks,omitempty"`
+
+	StopStrategy *sharedDocker.StopStrategy `json:"stop_strategy,omitempty"`
+
+	// Health check tuning, as in UpdateRequest
+	GracePeriodSeconds *int            `json:"grace_period_seconds,omitempty"`
+	ReadinessProbe     *ReadinessProbe `json:"readiness_probe,omitempty"`
+	SkipHealthCheck    bool            `json:"skip_health_check,omitempty"`
+}
+
+// GroupUpdateRequest updates several containers sequentially as one unit.
+//
+// Unless ExplicitOrder is set, members are ordered so that dependencies come
+// first. Dependencies are the union of each member's DependsOn, its
+// network_mode: container:X parent, its dockmon.depends_on label and its
+// compose depends_on label. Links to containers outside the group are ignored.
+//
+// If any member fails, every member already updated is rolled back to its
+// backup (newest first) and the remaining members are skipped.
+type GroupUpdateRequest struct {
+	GroupID       string        `json:"group_id,omitempty"`
+	Members       []GroupMember `json:"containers"`
+	ExplicitOrder bool          `json:"explicit_order,omitempty"`
+	StopTimeout   int           `json:"stop_timeout,omitempty"`   // Default: 30s
+	HealthTimeout int           `json:"health_timeout,omitempty"` // Default: 120s
+	Force         bool          `json:"force,omitempty"`          // Ignore com.dockmon.update.* opt-out labels
+}
+
+// GroupPlanEntry is the per-member state of a group update.
+type GroupPlanEntry struct {
+	ContainerID    string `json:"container_id"`
+	ContainerName  string `json:"container_name"`
+	NewImage       string `json:"new_image"`
+	Status         string `json:"status"`
+	NewContainerID string `json:"new_container_id,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// GroupProgressEvent reports progress of a group update with the full plan
+// attached, so consumers can render the whole group from any single event.
+// ContainerID is set for per-member stages.
+type GroupProgressEvent struct {
+	progress.Event
+	GroupID    string           `json:"group_id"`
+	Step       int              `json:"step"` // 1-based member index, 0 while planning
+	TotalSteps int              `json:"total_steps"`
+	Plan       []GroupPlanEntry `json:"plan"`
+}
+
+// GroupProgressCallback is called during a group update to report progress.
+type GroupProgressCallback func(event GroupProgressEvent)
+
+// GroupUpdateResult contains the outcome of a group update.
+type GroupUpdateResult struct {
+	GroupID    string           `json:"group_id"`
+	Success    bool             `json:"success"`
+	RolledBack bool             `json:"rolled_back,omitempty"`
+	Plan       []GroupPlanEntry `json:"plan"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// groupNode is the dependency view of a member used for ordering.
+type groupNode struct {
+	ID        string   // Full container ID
+	Name      string   // Container name without leading slash
+	Project   string   // Compose project label
+	Service   string   // Compose service label
+	DependsOn []string // Raw references (names, IDs, or compose service names)
+}
+
+// UpdateGroup updates the request's members one at a time with
+// rollback-all-on-failure semantics.
+func (u *Updater) UpdateGroup(ctx context.Context, req GroupUpdateRequest) *GroupUpdateResult {
+	result := &GroupUpdateResult{GroupID: req.GroupID}
+
+	if len(req.Members) == 0 {
+		result.Error = "no containers in group"
+		return result
+	}
+
+	// Inspect every member up front so ordering and naming use live state
+	nodes := make([]groupNode, len(req.Members))
+	for i, m := range req.Members {
+		if m.ContainerID == "" {
+			result.Error = fmt.Sprintf("container %d: container_id is required", i)
+			return result
+		}
+		healthTimeout := req.HealthTimeout
+		if healthTimeout == 0 {
+			healthTimeout = 120
+		}
+		if err := validateHealthOptions(UpdateRequest{
+			HealthTimeout:      healthTimeout,
+			GracePeriodSeconds: m.GracePeriodSeconds,
+			ReadinessProbe:     m.ReadinessProbe,
+		}); err != nil {
+			result.Error = fmt.Sprintf("container %s: %v", truncateID(m.ContainerID), err)
+			return result
+		}
+		inspect, err := u.cli.ContainerInspect(ctx, m.ContainerID)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to inspect container %s: %v", truncateID(m.ContainerID), err)
+			return result
+		}
+		if !req.Force {
+			// Refuse the whole group before any member is touched
+			if err := checkUpdateLabels(inspect.Config.Labels, m.NewImage); err != nil {
+				result.Error = fmt.Sprintf("%s: %v", strings.TrimPrefix(inspect.Name, "/"), err)
+				return result
+			}
+		}
+		nodes[i] = groupNodeFromInspect(inspect.ID, inspect.Name, inspect.Config.Labels, string(inspect.HostConfig.NetworkMode), m.DependsOn)
+	}
+
+	order := make([]int, len(nodes))
+	for i := range order {
+		order[i] = i
+	}
+	if !req.ExplicitOrder {
+		var err error
+		if order, err = orderGroupNodes(nodes); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	plan := make([]GroupPlanEntry, len(order))
+	members := make([]GroupMember, len(order))
+	for pos, idx := range order {
+		members[pos] = req.Members[idx]
+		plan[pos] = GroupPlanEntry{
+			ContainerID:   truncateID(nodes[idx].ID),
+			ContainerName: nodes[idx].Name,
+			NewImage:      req.Members[idx].NewImage,
+			Status:        GroupStatusPending,
+		}
+	}
+	result.Plan = plan
+
+	names := make([]string, len(plan))
+	for i, entry := range plan {
+		names[i] = entry.ContainerName
+	}
+	u.log.WithFields(logrus.Fields{
+		"group_id": req.GroupID,
+		"order":    names,
+	}).Info("Starting group update")
+	u.sendGroupProgress(req.GroupID, 0, plan, "", StageGroupPlanning, fmt.Sprintf("Update order: %s", strings.Join(names, " -> ")), "")
+
+	var updated []*UpdateResult
+	for pos, member := range members {
+		step := pos + 1
+		plan[pos].Status = GroupStatusUpdating
+
+		memberUpdater := u.withMemberProgress(req.GroupID, step, plan, plan[pos].ContainerID)
+		memberResult := memberUpdater.Update(ctx, UpdateRequest{
+			ContainerID:   currentMemberID(ctx, u.cli, plan[pos].ContainerName, member.ContainerID),
+			NewImage:      member.NewImage,
+			StopTimeout:   req.StopTimeout,
+			HealthTimeout: req.HealthTimeout,
+			RegistryAuth:  member.RegistryAuth,
+			Hooks:         member.Hooks,
+			StopStrategy:  member.StopStrategy,
+			KeepBackup:    true,
+			Force:         true, // Labels were checked for the whole group above
+
+			GracePeriodSeconds: member.GracePeriodSeconds,
+			ReadinessProbe:     member.ReadinessProbe,
+			SkipHealthCheck:    member.SkipHealthCheck,
+		})
+
+		if !memberResult.Success {
+			plan[pos].Status = GroupStatusFailed
+			plan[pos].Error = memberResult.Error
+			for rest := pos + 1; rest < len(plan); rest++ {
+				plan[rest].Status = GroupStatusSkipped
+			}
+
+			result.Error = fmt.Sprintf("%s failed: %s", plan[pos].ContainerName, memberResult.Error)
+			result.RolledBack = u.rollbackGroup(ctx, req, members, plan, updated)
+			u.sendGroupProgress(req.GroupID, step, plan, plan[pos].ContainerID, StageFailed, result.Error, "")
+			return result
+		}
+
+		plan[pos].Status = GroupStatusUpdated
+		plan[pos].NewContainerID = memberResult.NewContainerID
+		updated = append(updated, memberResult)
+	}
+
+	// Every member succeeded - the backups are no longer needed
+	for _, r := range updated {
+		RemoveBackup(ctx, u.cli, u.log, r.backupName)
+	}
+
+	result.Success = true
+	u.sendGroupProgress(req.GroupID, len(plan), plan, "", StageCompleted, fmt.Sprintf("Updated %d container(s)", len(plan)), "")
+	return result
+}
+
+// currentMemberID returns the ID a member's container has now. Updating an
+// earlier member recreates its network_mode dependents under new IDs, so
+// members are looked up by name, falling back to the planned ID.
+func currentMemberID(ctx context.Context, cli *client.Client, name, plannedID string) string {
+	if id, err := GetContainerByName(ctx, cli, name); err == nil && id != "" {
+		return id
+	}
+	return plannedID
+}
+
+// rollbackGroup restores every already-updated member from its backup,
+// newest first. Returns true only if every restore succeeded.
+func (u *Updater) rollbackGroup(ctx context.Context, req GroupUpdateRequest, members []GroupMember, plan []GroupPlanEntry, updated []*UpdateResult) bool {
+	stopTimeout := req.StopTimeout
+	if stopTimeout == 0 {
+		stopTimeout = 30
+	}
+	stopStrategy := func(m GroupMember) *sharedDocker.StopStrategy {
+		if req.StopTimeout > 0 {
+			return m.StopStrategy.WithTimeout(req.StopTimeout)
+		}
+		return m.StopStrategy
+	}
+
+	allRestored := true
+	for i := len(updated) - 1; i >= 0; i-- {
+		r := updated[i]
+		step := i + 1
+		u.sendGroupProgress(req.GroupID, step, plan, plan[i].ContainerID, StageRollback,
+			fmt.Sprintf("Rolling back %s", r.ContainerName), "")
+
+		// Dependents were re-pointed at the new container; find them before it goes away
+		newInspect, err := u.cli.ContainerInspect(ctx, r.newContainerFull)
+		var dependents []DependentContainer
+		if err == nil {
+			dependents, _ = FindDependentContainers(ctx, u.cli, u.log, &newInspect, r.ContainerName, r.newContainerFull)
+		}
+
+		sharedDocker.StopContainer(ctx, u.cli, r.newContainerFull, stopStrategy(members[i]), stopTimeout)
+		u.cli.ContainerRemove(ctx, r.newContainerFull, container.RemoveOptions{Force: true})
+
+		if err := RestoreBackup(ctx, u.cli, u.log, r.backupName, r.ContainerName, r.wasRunning); err != nil {
+			allRestored = false
+			plan[i].Status = GroupStatusFailed
+			plan[i].Error = fmt.Sprintf("rollback failed: %v", err)
+			continue
+		}
+
+		plan[i].Status = GroupStatusRolledBack
+		plan[i].NewContainerID = ""
+
+		if len(dependents) > 0 {
+			restoredID, err := GetContainerByName(ctx, u.cli, r.ContainerName)
+			if err == nil && restoredID != "" {
+				if failed := RecreateDependentContainers(ctx, u.cli, u.log, dependents, restoredID, stopTimeout, u.options.IsPodman, u.options.IsWindows); len(failed) > 0 {
+					u.log.Warnf("Failed to re-point dependents after rollback of %s: %v", r.ContainerName, failed)
+				}
+			}
+		}
+	}
+
+	return allRestored
+}
+
+// withMemberProgress returns an Updater whose stage progress is also
+// reported as group progress for the member at step.
+func (u *Updater) withMemberProgress(groupID string, step int, plan []GroupPlanEntry, containerID string) *Updater {
+	options := u.options
+	onProgress := u.options.OnProgress
+	options.OnProgress = func(event ProgressEvent) {
+		if onProgress != nil {
+			onProgress(event)
+		}
+		u.sendGroupProgress(groupID, step, plan, containerID, event.Stage, event.Message, event.Output)
+	}
+	return NewUpdater(u.cli, u.log, options)
+}
+
+// sendGroupProgress sends a group progress event if callback is registered.
+func (u *Updater) sendGroupProgress(groupID string, step int, plan []GroupPlanEntry, containerID, stage, message, output string) {
+	if u.options.OnGroupProgress == nil {
+		return
+	}
+	snapshot := make([]GroupPlanEntry, len(plan))
+	copy(snapshot, plan)
+	event := progress.New(progress.OperationUpdate, progress.Stage(stage), message)
+	event.ContainerID = containerID
+	event.Output = output
+	// Members before the current step are done
+	switch {
+	case stage == StageCompleted:
+		event.Percent = 100
+	case len(plan) > 0:
+		event.Percent = progress.Percentage(int64(step-1), int64(len(plan)))
+	}
+	u.options.OnGroupProgress(GroupProgressEvent{
+		Event:      event,
+		GroupID:    groupID,
+		Step:       step,
+		TotalSteps: len(plan),
+		Plan:       snapshot,
+	})
+}
+
+// groupNodeFromInspect collects a member's dependency references from its
+// explicit depends_on, network_mode parent, dockmon.depends_on label and
+// compose depends_on label.
+func groupNodeFromInspect(id, name string, labels map[string]string, networkMode string, explicit []string) groupNode {
+	node := groupNode{
+		ID:      id,
+		Name:    strings.TrimPrefix(name, "/"),
+		Project: labels[composeProjectLabel],
+		Service: labels[composeServiceLabel],
+	}
+
+	node.DependsOn = append(node.DependsOn, explicit...)
+
+	if parent, ok := strings.CutPrefix(networkMode, "container:"); ok && parent != "" {
+		node.DependsOn = append(node.DependsOn, parent)
+	}
+
+	for _, dep := range strings.Split(labels[LabelDependsOn], ",") {
+		if dep = strings.TrimSpace(dep); dep != "" {
+			node.DependsOn = append(node.DependsOn, dep)
+		}
+	}
+
+	// Format: "db:service_healthy:false,redis:service_started:true"
+	for _, dep := range strings.Split(labels[composeDependsOnLabel], ",") {
+		if service, _, _ := strings.Cut(strings.TrimSpace(dep), ":"); service != "" {
+			node.DependsOn = append(node.DependsOn, service)
+		}
+	}
+
+	return node
+}
+
+// resolveGroupRef maps a dependency reference to a node index. References
+// match by full ID, ID prefix (12+ chars), container name, or compose service
+// name within the same project.
+func resolveGroupRef(nodes []groupNode, from int, ref string) (int, bool) {
+	ref = strings.TrimPrefix(ref, "/")
+	for i, n := range nodes {
+		if i == from {
+			continue
+		}
+		if ref == n.ID || ref == n.Name || (len(ref) >= 12 && strings.HasPrefix(n.ID, ref)) {
+			return i, true
+		}
+		if n.Service != "" && ref == n.Service && n.Project == nodes[from].Project {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// orderGroupNodes returns node indices with dependencies first. Among nodes
+// whose dependencies are satisfied, request order is preserved.
+func orderGroupNodes(nodes []groupNode) ([]int, error) {
+	inDegree := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+
+	for i, n := range nodes {
+		seen := make(map[int]bool)
+		for _, ref := range n.DependsOn {
+			dep, ok := resolveGroupRef(nodes, i, ref)
+			if !ok || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			inDegree[i]++
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+
+	var ready []int
+	for i := range nodes {
+		if inDegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	order := make([]int, 0, len(nodes))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		next := ready[0]
+		ready = ready[1:]
+		order = append(order, next)
+
+		for _, d := range dependents[next] {
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) != len(nodes) {
+		var cyclic []string
+		for i, n := range nodes {
+			if inDegree[i] > 0 {
+				cyclic = append(cyclic, n.Name)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between containers: %s", strings.Join(cyclic, ", "))
+	}
+
+	return order, nil
+}
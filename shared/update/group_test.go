@@ -0,0 +1,200 @@
+package update
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/progress"
+	"github.com/docker/docker/client"
+)
+
+func TestGroupNodeFromInspect(t *testing.T) {
+	labels := map[string]string{
+		composeProjectLabel:   "stack",
+		composeServiceLabel:   "web",
+		composeDependsOnLabel: "db:service_healthy:false, redis:service_started:true",
+		LabelDependsOn:        "proxy",
+	}
+
+	node := groupNodeFromInspect("abc123", "/stack-web-1", labels, "container:vpn", []string{"cache"})
+
+	if node.Name != "stack-web-1" {
+		t.Errorf("Name = %q, want stack-web-1", node.Name)
+	}
+	if node.Project != "stack" || node.Service != "web" {
+		t.Errorf("Project/Service = %q/%q, want stack/web", node.Project, node.Service)
+	}
+	want := []string{"cache", "vpn", "proxy", "db", "redis"}
+	if !reflect.DeepEqual(node.DependsOn, want) {
+		t.Errorf("DependsOn = %v, want %v", node.DependsOn, want)
+	}
+}
+
+func TestGroupNodeFromInspectNoDependencies(t *testing.T) {
+	node := groupNodeFromInspect("abc123", "/standalone", nil, "bridge", nil)
+
+	if len(node.DependsOn) != 0 {
+		t.Errorf("DependsOn = %v, want none", node.DependsOn)
+	}
+}
+
+func TestOrderGroupNodes(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []groupNode
+		want  []int
+	}{
+		{
+			name: "no dependencies keeps request order",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "a"},
+				{ID: "bbbbbbbbbbbb1", Name: "b"},
+				{ID: "cccccccccccc1", Name: "c"},
+			},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "explicit name dependency",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "app", DependsOn: []string{"db"}},
+				{ID: "bbbbbbbbbbbb1", Name: "db"},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "network_mode parent by ID prefix",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "torrent", DependsOn: []string{"bbbbbbbbbbbb"}},
+				{ID: "bbbbbbbbbbbb1", Name: "vpn"},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "compose service within same project",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "stack-web-1", Project: "stack", Service: "web", DependsOn: []string{"api"}},
+				{ID: "bbbbbbbbbbbb1", Name: "stack-api-1", Project: "stack", Service: "api", DependsOn: []string{"db"}},
+				{ID: "cccccccccccc1", Name: "stack-db-1", Project: "stack", Service: "db"},
+			},
+			want: []int{2, 1, 0},
+		},
+		{
+			name: "compose service in another project is ignored",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "one-web-1", Project: "one", Service: "web", DependsOn: []string{"db"}},
+				{ID: "bbbbbbbbbbbb1", Name: "two-db-1", Project: "two", Service: "db"},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "dependency outside group is ignored",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "app", DependsOn: []string{"postgres"}},
+				{ID: "bbbbbbbbbbbb1", Name: "worker"},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "independent members keep relative order",
+			nodes: []groupNode{
+				{ID: "aaaaaaaaaaaa1", Name: "x"},
+				{ID: "bbbbbbbbbbbb1", Name: "app", DependsOn: []string{"db", "db"}},
+				{ID: "cccccccccccc1", Name: "y"},
+				{ID: "dddddddddddd1", Name: "db"},
+			},
+			want: []int{0, 2, 3, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := orderGroupNodes(tt.nodes)
+			if err != nil {
+				t.Fatalf("orderGroupNodes() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderGroupNodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurrentMemberID(t *testing.T) {
+	// qbittorrent was recreated under a new ID when its network_mode parent
+	// was updated earlier in the group
+	_, cli := fakeForensicsDaemon(t, `[{"Id":"new456","Names":["/qbittorrent"]}]`)
+	if got := currentMemberID(context.Background(), cli.(*client.Client), "qbittorrent", "old123"); got != "new456" {
+		t.Errorf("currentMemberID = %q, want new456", got)
+	}
+
+	_, cli = fakeForensicsDaemon(t, `[]`)
+	if got := currentMemberID(context.Background(), cli.(*client.Client), "qbittorrent", "old123"); got != "old123" {
+		t.Errorf("currentMemberID without a match = %q, want the planned ID", got)
+	}
+}
+
+func TestOrderGroupNodesCycle(t *testing.T) {
+	nodes := []groupNode{
+		{ID: "aaaaaaaaaaaa1", Name: "a", DependsOn: []string{"b"}},
+		{ID: "bbbbbbbbbbbb1", Name: "b", DependsOn: []string{"a"}},
+		{ID: "cccccccccccc1", Name: "c"},
+	}
+
+	if _, err := orderGroupNodes(nodes); err == nil {
+		t.Error("expected cycle error, got nil")
+	}
+}
+
+func TestSendGroupProgress(t *testing.T) {
+	var events []GroupProgressEvent
+	u := &Updater{options: UpdaterOptions{OnGroupProgress: func(event GroupProgressEvent) {
+		events = append(events, event)
+	}}}
+	plan := []GroupPlanEntry{{ContainerID: "a"}, {ContainerID: "b"}}
+
+	u.sendGroupProgress("g1", 0, plan, "", StageGroupPlanning, "Update order: a -> b", "")
+	u.sendGroupProgress("g1", 2, plan, "b", StagePulling, "Pulling", "")
+	u.sendGroupProgress("g1", 2, plan, "", StageCompleted, "Updated 2 container(s)", "")
+
+	for i, want := range []int{0, 50, 100} {
+		if events[i].Percent != want {
+			t.Errorf("event %d (%s) Percent = %d, want %d", i, events[i].Stage, events[i].Percent, want)
+		}
+	}
+	if events[1].ContainerID != "b" || events[1].Schema != progress.SchemaVersion || events[1].Operation != progress.OperationUpdate {
+		t.Errorf("member event = %+v", events[1].Event)
+	}
+}
+
+func TestPullProgressEventToProgress(t *testing.T) {
+	event := PullProgressEvent{
+		ContainerID:     "abc123",
+		OverallProgress: 60,
+		Layers:          []*LayerProgress{{ID: "l1", Percent: 60}, nil},
+		TotalLayers:     5,
+		RemainingLayers: 4,
+		Summary:         "Downloading 1 of 5 layers",
+		SpeedMbps:       3.5,
+	}.ToProgress()
+
+	if event.Stage != progress.StagePulling || event.StagePercent != 60 || event.Message != "Downloading 1 of 5 layers" {
+		t.Errorf("event = %+v", event)
+	}
+	if len(event.Layers) != 1 || event.Layers[0].ID != "l1" || event.RemainingLayers != 4 {
+		t.Errorf("layers = %+v", event.Layers)
+	}
+}
+
+func TestUpdateGroupValidatesMemberHealthOptions(t *testing.T) {
+	u := &Updater{}
+	result := u.UpdateGroup(context.Background(), GroupUpdateRequest{Members: []GroupMember{{
+		ContainerID:    "abc123def456",
+		NewImage:       "nginx:latest",
+		ReadinessProbe: &ReadinessProbe{Type: "tcp", Address: "db:99999"},
+	}}})
+	if result.Success || !strings.Contains(result.Error, "host:port") {
+		t.Errorf("UpdateGroup() = %+v, want a readiness probe error before touching containers", result)
+	}
+}
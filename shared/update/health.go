@@ -29,32 +29,58 @@ func isExitAcceptable(restartPolicy string, exitCode int) bool {
 	}
 }
 
-// WaitForHealthy waits for a container to become healthy or timeout.
+// HealthCheckOptions tunes WaitForHealthy.
+type HealthCheckOptions struct {
+	Timeout     time.Duration
+	GracePeriod time.Duration   // "unhealthy" is treated like "starting" until this has passed
+	Readiness   *ReadinessProbe // Polled instead of the stability wait when there is no HEALTHCHECK
+}
+
+// DefaultGracePeriod returns min(30s, 50% of timeout), which handles both
+// short and long timeouts gracefully. This matches Python backend behavior
+// in utils/container_health.py.
+func DefaultGracePeriod(timeout time.Duration) time.Duration {
+	return min(timeout/2, 30*time.Second)
+}
+
+// WaitForHealthy waits for a container to become healthy or timeout, using
+// the default grace period and no readiness probe.
+func WaitForHealthy(
+	ctx context.Context,
+	cli *client.Client,
+	log *logrus.Logger,
+	containerID string,
+	timeout int,
+) error {
+	t := time.Duration(timeout) * time.Second
+	return WaitForHealthyWithOptions(ctx, cli, log, containerID, HealthCheckOptions{
+		Timeout:     t,
+		GracePeriod: DefaultGracePeriod(t),
+	})
+}
+
+// WaitForHealthyWithOptions waits for a container to become healthy or timeout.
 // This function matches the Python backend's health check logic:
 // 1. If container has Docker HEALTHCHECK: Poll for "healthy" status
-//   - Grace period: min(30s, 50% of timeout) treats "unhealthy" like "starting"
+//   - Grace period (default min(30s, 50% of timeout)) treats "unhealthy" like "starting"
 //   - After grace period: "unhealthy" triggers rollback
 //
-// 2. If no health check: Wait 3s for stability, verify still running
-func WaitForHealthy(
+// 2. If no health check and a readiness probe is set: poll the probe until it
+// succeeds, verifying the container keeps running
+//
+// 3. If no health check: Wait 3s for stability, verify still running
+func WaitForHealthyWithOptions(
 	ctx context.Context,
 	cli *client.Client,
 	log *logrus.Logger,
 	containerID string,
-	timeout int,
+	opts HealthCheckOptions,
 ) error {
 	startTime := time.Now()
-	deadline := startTime.Add(time.Duration(timeout) * time.Second)
+	deadline := startTime.Add(opts.Timeout)
 	checkInterval := 2 * time.Second
-
-	// Grace period for containers with health checks: allow "unhealthy" status during startup
-	// Use min(30s, 50% of timeout) to handle both short and long timeouts gracefully
-	// This matches Python backend behavior in utils/container_health.py
-	gracePeriodSeconds := float64(timeout) * 0.5
-	if gracePeriodSeconds > 30 {
-		gracePeriodSeconds = 30
-	}
-	gracePeriod := time.Duration(gracePeriodSeconds) * time.Second
+	gracePeriod := opts.GracePeriod
+	var probeErr error
 
 	for {
 		select {
@@ -64,7 +90,10 @@ func WaitForHealthy(
 		}
 
 		if time.Now().After(deadline) {
-			return fmt.Errorf("health check timeout after %ds", timeout)
+			if probeErr != nil {
+				return fmt.Errorf("readiness probe did not succeed within %s: %w", opts.Timeout, probeErr)
+			}
+			return fmt.Errorf("health check timeout after %ds", int(opts.Timeout.Seconds()))
 		}
 
 		inspect, err := cli.ContainerInspect(ctx, containerID)
@@ -85,6 +114,21 @@ func WaitForHealthy(
 			return fmt.Errorf("container stopped unexpectedly (exit code: %d)", exitCode)
 		}
 
+		// Without a health check, a readiness probe decides
+		if inspect.State.Health == nil && opts.Readiness != nil {
+			if probeErr = opts.Readiness.check(ctx, containerIP(inspect)); probeErr == nil {
+				log.Info("Readiness probe succeeded, considering healthy")
+				return nil
+			}
+			log.WithError(probeErr).Debug("Readiness probe not ready yet, waiting...")
+			select {
+			case <-time.After(opts.Readiness.interval()):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
 		// If no health check defined, wait 3 seconds and assume healthy
 		// (matches Python backend behavior)
 		if inspect.State.Health == nil {
@@ -144,3 +188,40 @@ func WaitForHealthy(
 		}
 	}
 }
+
+// validateHealthOptions checks the request's health check tuning
+func validateHealthOptions(req UpdateRequest) error {
+	if req.GracePeriodSeconds != nil && (*req.GracePeriodSeconds < 0 || *req.GracePeriodSeconds > req.HealthTimeout) {
+		return fmt.Errorf("grace_period_seconds must be between 0 and health_timeout (%ds)", req.HealthTimeout)
+	}
+	if req.ReadinessProbe != nil {
+		if err := req.ReadinessProbe.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForHealthy runs the health check configured by the request
+func (u *Updater) waitForHealthy(ctx context.Context, req UpdateRequest, containerID string) error {
+	timeout := time.Duration(req.HealthTimeout) * time.Second
+	opts := HealthCheckOptions{
+		Timeout:     timeout,
+		GracePeriod: DefaultGracePeriod(timeout),
+		Readiness:   req.ReadinessProbe,
+	}
+	if req.GracePeriodSeconds != nil {
+		opts.GracePeriod = time.Duration(*req.GracePeriodSeconds) * time.Second
+	}
+
+	if probe := req.ReadinessProbe; probe != nil {
+		target := probe.URL
+		if probe.Type == ReadinessProbeTCP {
+			target = probe.Address
+		}
+		u.sendProgress(StageHealthCheck, fmt.Sprintf("Waiting for container to be ready (%s %s)", probe.Type, target))
+	} else {
+		u.sendProgress(StageHealthCheck, "Waiting for container to be healthy")
+	}
+	return WaitForHealthyWithOptions(ctx, u.cli, u.log, containerID, opts)
+}
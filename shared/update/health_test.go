@@ -1,6 +1,18 @@
 package update
 
-import "testing"
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
 
 func TestIsExitAcceptable(t *testing.T) {
 	tests := []struct {
@@ -42,3 +54,118 @@ func TestIsExitAcceptable(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultGracePeriod(t *testing.T) {
+	if got := DefaultGracePeriod(20 * time.Second); got != 10*time.Second {
+		t.Errorf("DefaultGracePeriod(20s) = %s, want 10s", got)
+	}
+	if got := DefaultGracePeriod(120 * time.Second); got != 30*time.Second {
+		t.Errorf("DefaultGracePeriod(120s) = %s, want 30s", got)
+	}
+}
+
+func TestValidateHealthOptions(t *testing.T) {
+	grace := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		req     UpdateRequest
+		wantErr string
+	}{
+		{"defaults", UpdateRequest{HealthTimeout: 120}, ""},
+		{"zero grace", UpdateRequest{HealthTimeout: 120, GracePeriodSeconds: grace(0)}, ""},
+		{"grace beyond timeout", UpdateRequest{HealthTimeout: 60, GracePeriodSeconds: grace(90)}, "grace_period_seconds"},
+		{"negative grace", UpdateRequest{HealthTimeout: 60, GracePeriodSeconds: grace(-1)}, "grace_period_seconds"},
+		{"http probe", UpdateRequest{HealthTimeout: 60, ReadinessProbe: &ReadinessProbe{Type: "http", URL: "http://localhost:8080/health"}}, ""},
+		{"http probe without scheme", UpdateRequest{HealthTimeout: 60, ReadinessProbe: &ReadinessProbe{Type: "http", URL: "localhost:8080"}}, "http(s) URL"},
+		{"tcp probe port", UpdateRequest{HealthTimeout: 60, ReadinessProbe: &ReadinessProbe{Type: "tcp", Address: "5432"}}, ""},
+		{"tcp probe bad port", UpdateRequest{HealthTimeout: 60, ReadinessProbe: &ReadinessProbe{Type: "tcp", Address: "db:99999"}}, "host:port"},
+		{"unknown probe", UpdateRequest{HealthTimeout: 60, ReadinessProbe: &ReadinessProbe{Type: "exec"}}, "http or tcp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHealthOptions(tt.req)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadinessProbeTCPAddress(t *testing.T) {
+	for addr, want := range map[string]string{
+		"8080":       "127.0.0.1:8080",
+		":8080":      "127.0.0.1:8080",
+		"db:5432":    "db:5432",
+		"[::1]:6379": "[::1]:6379",
+	} {
+		got, err := (&ReadinessProbe{Type: "tcp", Address: addr}).tcpAddress("")
+		if err != nil || got != want {
+			t.Errorf("tcpAddress(%q) = %q, %v; want %q", addr, got, err, want)
+		}
+	}
+
+	// A bare port goes to the container's IP when it has one
+	for addr, want := range map[string]string{
+		"8080":    "172.18.0.5:8080",
+		":8080":   "172.18.0.5:8080",
+		"db:5432": "db:5432",
+	} {
+		got, err := (&ReadinessProbe{Type: "tcp", Address: addr}).tcpAddress("172.18.0.5")
+		if err != nil || got != want {
+			t.Errorf("tcpAddress(%q) with container IP = %q, %v; want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestContainerIP(t *testing.T) {
+	inspect := container.InspectResponse{NetworkSettings: &container.NetworkSettings{
+		Networks: map[string]*network.EndpointSettings{
+			"zeta":  {IPAddress: "10.0.0.9"},
+			"alpha": {IPAddress: ""},
+			"beta":  {IPAddress: "172.18.0.5"},
+		},
+	}}
+	if got := containerIP(inspect); got != "172.18.0.5" {
+		t.Errorf("containerIP() = %q, want the first network with an address", got)
+	}
+	if got := containerIP(container.InspectResponse{}); got != "" {
+		t.Errorf("containerIP() without networks = %q, want empty", got)
+	}
+}
+
+func TestReadinessProbeCheck(t *testing.T) {
+	var ready atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	probe := &ReadinessProbe{Type: "http", URL: srv.URL}
+	if err := probe.check(context.Background(), ""); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("check() = %v, want a 503 error", err)
+	}
+	ready.Store(true)
+	if err := probe.check(context.Background(), ""); err != nil {
+		t.Errorf("check() = %v, want success", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	probe = &ReadinessProbe{Type: "tcp", Address: addr}
+	if err := probe.check(context.Background(), ""); err != nil {
+		t.Errorf("tcp check() = %v, want success", err)
+	}
+	ln.Close()
+	if err := probe.check(context.Background(), ""); err == nil {
+		t.Error("tcp check() succeeded against a closed port")
+	}
+}
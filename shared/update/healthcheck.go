@@ -0,0 +1,60 @@
+package update
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// HealthcheckOverride injects or replaces a container's HEALTHCHECK when it is
+// recreated. Durations are in seconds; zero leaves Docker's default in place.
+//
+// Test follows Docker's HEALTHCHECK format:
+//   - ["NONE"]                         disables the image's health check
+//   - ["CMD", "curl", "-f", "http://localhost/"]
+//   - ["CMD-SHELL", "curl -f http://localhost/ || exit 1"]
+type HealthcheckOverride struct {
+	Test        []string `json:"test"`
+	Interval    int      `json:"interval,omitempty"`
+	Timeout     int      `json:"timeout,omitempty"`
+	StartPeriod int      `json:"start_period,omitempty"`
+	Retries     int      `json:"retries,omitempty"`
+}
+
+// Validate checks the override is something Docker will accept.
+func (h *HealthcheckOverride) Validate() error {
+	if len(h.Test) == 0 {
+		return fmt.Errorf("healthcheck test is required")
+	}
+
+	switch h.Test[0] {
+	case "NONE":
+		if len(h.Test) != 1 {
+			return fmt.Errorf("healthcheck test NONE takes no arguments")
+		}
+	case "CMD", "CMD-SHELL":
+		if len(h.Test) < 2 {
+			return fmt.Errorf("healthcheck test %s requires a command", h.Test[0])
+		}
+	default:
+		return fmt.Errorf("healthcheck test must start with NONE, CMD or CMD-SHELL, got %q", h.Test[0])
+	}
+
+	if h.Interval < 0 || h.Timeout < 0 || h.StartPeriod < 0 || h.Retries < 0 {
+		return fmt.Errorf("healthcheck interval, timeout, start_period and retries must not be negative")
+	}
+
+	return nil
+}
+
+// toHealthConfig converts the override to Docker's HealthConfig.
+func (h *HealthcheckOverride) toHealthConfig() *container.HealthConfig {
+	return &container.HealthConfig{
+		Test:        append([]string(nil), h.Test...),
+		Interval:    time.Duration(h.Interval) * time.Second,
+		Timeout:     time.Duration(h.Timeout) * time.Second,
+		StartPeriod: time.Duration(h.StartPeriod) * time.Second,
+		Retries:     h.Retries,
+	}
+}
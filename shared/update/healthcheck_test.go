@@ -0,0 +1,74 @@
+package update
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthcheckOverrideValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hc      HealthcheckOverride
+		wantErr bool
+	}{
+		{"cmd-shell", HealthcheckOverride{Test: []string{"CMD-SHELL", "curl -f http://localhost/ || exit 1"}}, false},
+		{"cmd exec form", HealthcheckOverride{Test: []string{"CMD", "pg_isready", "-U", "postgres"}}, false},
+		{"none", HealthcheckOverride{Test: []string{"NONE"}}, false},
+		{"empty test", HealthcheckOverride{}, true},
+		{"cmd without command", HealthcheckOverride{Test: []string{"CMD"}}, true},
+		{"none with args", HealthcheckOverride{Test: []string{"NONE", "true"}}, true},
+		{"bare command", HealthcheckOverride{Test: []string{"curl -f http://localhost/"}}, true},
+		{"negative retries", HealthcheckOverride{Test: []string{"CMD", "true"}, Retries: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hc.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthcheckOverrideToHealthConfig(t *testing.T) {
+	hc := HealthcheckOverride{
+		Test:        []string{"CMD-SHELL", "wget -q --spider http://localhost:8080/health"},
+		Interval:    30,
+		Timeout:     5,
+		StartPeriod: 60,
+		Retries:     3,
+	}
+
+	cfg := hc.toHealthConfig()
+
+	if cfg.Interval != 30*time.Second {
+		t.Errorf("Interval = %v, want 30s", cfg.Interval)
+	}
+	if cfg.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", cfg.Timeout)
+	}
+	if cfg.StartPeriod != 60*time.Second {
+		t.Errorf("StartPeriod = %v, want 60s", cfg.StartPeriod)
+	}
+	if cfg.Retries != 3 {
+		t.Errorf("Retries = %d, want 3", cfg.Retries)
+	}
+	if len(cfg.Test) != 2 || cfg.Test[0] != "CMD-SHELL" {
+		t.Errorf("Test = %v, want CMD-SHELL form", cfg.Test)
+	}
+
+	// The returned config must not alias the request slice
+	cfg.Test[1] = "mutated"
+	if hc.Test[1] == "mutated" {
+		t.Error("toHealthConfig() aliased the override's Test slice")
+	}
+}
+
+func TestHealthcheckOverrideZeroDurationsUseDockerDefaults(t *testing.T) {
+	cfg := (&HealthcheckOverride{Test: []string{"CMD", "true"}}).toHealthConfig()
+
+	if cfg.Interval != 0 || cfg.Timeout != 0 || cfg.StartPeriod != 0 || cfg.Retries != 0 {
+		t.Errorf("expected zero values so Docker applies defaults, got %+v", cfg)
+	}
+}
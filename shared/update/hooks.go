@@ -0,0 +1,204 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultHookTimeout bounds a hook when the request doesn't set one.
+	defaultHookTimeout = 60
+	// maxHookOutput caps captured stdout/stderr per hook so a chatty command
+	// can't balloon progress events.
+	maxHookOutput = 16 * 1024
+)
+
+// UpdateHook is a command exec'd inside a container at a fixed point of an update.
+type UpdateHook struct {
+	Command         []string `json:"command"`                     // Exec form, e.g. ["sh", "-c", "pg_dumpall > /backup/pre.sql"]
+	Container       string   `json:"container,omitempty"`         // Target name/ID; default: the container being updated
+	User            string   `json:"user,omitempty"`              // Optional exec user
+	Timeout         int      `json:"timeout,omitempty"`           // Seconds, default: 60
+	ContinueOnError bool     `json:"continue_on_error,omitempty"` // Log and carry on instead of failing the update
+}
+
+// UpdateHooks groups the hooks run around an update.
+//   - PreUpdate runs before the container is stopped. A failure aborts the
+//     update with nothing changed.
+//   - PostUpdate runs after the new container passes its health check. A
+//     failure rolls back to the backup like a failed health check.
+//
+// Hooks exec into a running container, so a hook aimed at a container that
+// is stopped fails like any other hook error.
+type UpdateHooks struct {
+	PreUpdate  []UpdateHook `json:"pre_update,omitempty"`
+	PostUpdate []UpdateHook `json:"post_update,omitempty"`
+}
+
+// Validate checks every hook has a command and sane timeout.
+func (h *UpdateHooks) Validate() error {
+	for i, hook := range h.PreUpdate {
+		if err := hook.validate(); err != nil {
+			return fmt.Errorf("pre_update hook %d: %w", i, err)
+		}
+	}
+	for i, hook := range h.PostUpdate {
+		if err := hook.validate(); err != nil {
+			return fmt.Errorf("post_update hook %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (h UpdateHook) validate() error {
+	if len(h.Command) == 0 {
+		return fmt.Errorf("command is required")
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// HookResult is the outcome of a single hook execution.
+type HookResult struct {
+	ExitCode int
+	Output   string
+}
+
+// RunHook execs a hook inside targetID and waits for it to finish or time out.
+// Stdout and stderr are captured together (capped at maxHookOutput). A non-zero
+// exit code is returned as an error alongside the result so callers can still
+// report the output.
+func RunHook(
+	ctx context.Context,
+	cli *client.Client,
+	log *logrus.Logger,
+	targetID string,
+	hook UpdateHook,
+) (*HookResult, error) {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+	hookCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	log.WithFields(logrus.Fields{
+		"container": truncateID(targetID),
+		"command":   hook.Command,
+	}).Info("Running update hook")
+
+	execResp, err := cli.ContainerExecCreate(hookCtx, targetID, container.ExecOptions{
+		Cmd:          hook.Command,
+		User:         hook.User,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hook exec: %w", err)
+	}
+
+	attach, err := cli.ContainerExecAttach(hookCtx, execResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to hook exec: %w", err)
+	}
+	defer attach.Close()
+
+	output := &cappedBuffer{limit: maxHookOutput}
+	copyDone := make(chan error, 1)
+	go func() {
+		_, copyErr := stdcopy.StdCopy(output, output, attach.Reader)
+		copyDone <- copyErr
+	}()
+
+	select {
+	case <-copyDone:
+	case <-hookCtx.Done():
+		// Closing the hijacked connection unblocks StdCopy; the exec itself
+		// keeps running in the container since Docker has no exec kill API.
+		attach.Close()
+		<-copyDone
+		return &HookResult{ExitCode: -1, Output: output.String()},
+			fmt.Errorf("hook timed out after %ds", timeout)
+	}
+
+	inspect, err := cli.ContainerExecInspect(hookCtx, execResp.ID)
+	if err != nil {
+		return &HookResult{ExitCode: -1, Output: output.String()},
+			fmt.Errorf("failed to inspect hook exec: %w", err)
+	}
+
+	result := &HookResult{ExitCode: inspect.ExitCode, Output: output.String()}
+	if inspect.ExitCode != 0 {
+		return result, fmt.Errorf("hook exited with code %d", inspect.ExitCode)
+	}
+	return result, nil
+}
+
+// cappedBuffer keeps at most limit bytes and silently drops the rest.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	// Report the full length so StdCopy doesn't treat the cap as a short write
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (output truncated)"
+	}
+	return b.buf.String()
+}
+
+// runHooks executes hooks in order, reporting each one's output on stage.
+// defaultTarget is used for hooks that don't name a container.
+func (u *Updater) runHooks(ctx context.Context, stage string, hooks []UpdateHook, defaultTarget string) error {
+	for i, hook := range hooks {
+		target := hook.Container
+		if target == "" {
+			target = defaultTarget
+		}
+
+		u.sendProgress(stage, fmt.Sprintf("Running hook %d/%d: %v", i+1, len(hooks), hook.Command))
+
+		result, err := RunHook(ctx, u.cli, u.log, target, hook)
+		output := ""
+		if result != nil {
+			output = result.Output
+		}
+
+		if err != nil {
+			u.sendProgressWithOutput(stage, fmt.Sprintf("Hook %d/%d failed: %v", i+1, len(hooks), err), output)
+			if hook.ContinueOnError {
+				u.log.WithError(err).Warnf("Update hook %d failed, continuing (continue_on_error)", i+1)
+				continue
+			}
+			return fmt.Errorf("hook %d (%v) failed: %w", i+1, hook.Command, err)
+		}
+
+		u.sendProgressWithOutput(stage, fmt.Sprintf("Hook %d/%d completed", i+1, len(hooks)), output)
+	}
+	return nil
+}
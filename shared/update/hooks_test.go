@@ -0,0 +1,81 @@
+package update
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateHooksValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		hooks   UpdateHooks
+		wantErr string
+	}{
+		{
+			name: "valid pre and post",
+			hooks: UpdateHooks{
+				PreUpdate:  []UpdateHook{{Command: []string{"sh", "-c", "sync"}}},
+				PostUpdate: []UpdateHook{{Command: []string{"php", "artisan", "migrate"}, Timeout: 300}},
+			},
+		},
+		{
+			name:    "pre hook without command",
+			hooks:   UpdateHooks{PreUpdate: []UpdateHook{{Container: "db"}}},
+			wantErr: "pre_update hook 0",
+		},
+		{
+			name: "post hook with negative timeout",
+			hooks: UpdateHooks{PostUpdate: []UpdateHook{
+				{Command: []string{"true"}},
+				{Command: []string{"true"}, Timeout: -5},
+			}},
+			wantErr: "post_update hook 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.hooks.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 8}
+
+	n, err := b.Write([]byte("hello "))
+	if err != nil || n != 6 {
+		t.Fatalf("Write() = %d, %v", n, err)
+	}
+	// Must report the full length even when truncating so StdCopy keeps going
+	n, err = b.Write([]byte("world"))
+	if err != nil || n != 5 {
+		t.Fatalf("Write() = %d, %v, want full length", n, err)
+	}
+
+	got := b.String()
+	if !strings.HasPrefix(got, "hello wo") {
+		t.Errorf("String() = %q, want prefix %q", got, "hello wo")
+	}
+	if !strings.Contains(got, "output truncated") {
+		t.Errorf("String() = %q, want truncation marker", got)
+	}
+}
+
+func TestCappedBufferUnderLimit(t *testing.T) {
+	b := &cappedBuffer{limit: 64}
+	b.Write([]byte("migrations applied"))
+
+	if got := b.String(); got != "migrations applied" {
+		t.Errorf("String() = %q, want untruncated output", got)
+	}
+}
@@ -123,7 +123,7 @@ func TestIntegration_VolumePassthrough(t *testing.T) {
 	}
 
 	// Extract config
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -218,7 +218,7 @@ func TestIntegration_StaticIPPreservation(t *testing.T) {
 	}
 
 	// Extract config
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -299,7 +299,7 @@ func TestIntegration_NetworkModeContainerResolution(t *testing.T) {
 	}
 
 	// Extract config - should resolve ID to name
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -381,7 +381,7 @@ func TestIntegration_NetworkModeContainerClearsPorts(t *testing.T) {
 	}
 
 	// Extract config - should clear port bindings for container:X network mode
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -458,7 +458,7 @@ func TestIntegration_FullUpdateWorkflow(t *testing.T) {
 	}
 
 	// Extract config for recreation with new image
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.19", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.19", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -576,7 +576,7 @@ func TestIntegration_ImageDefaultEnvStrippedOnUpdate(t *testing.T) {
 	// APP_VERSION (or absence of it) takes effect on recreate.
 	oldImageEnv := []string{"APP_VERSION=v3.0.0"}
 
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.18", nil, nil, oldImageEnv, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.18", nil, nil, oldImageEnv, false, false)
 	if err != nil {
 		t.Fatalf("ExtractConfig failed: %v", err)
 	}
@@ -602,7 +602,7 @@ func TestIntegration_ImageDefaultEnvStrippedOnUpdate(t *testing.T) {
 
 	// Sanity check: with oldImageEnv=nil (graceful degradation path),
 	// behavior must be the same as before the fix - APP_VERSION survives.
-	extractedNoFilter, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.18", nil, nil, nil, false)
+	extractedNoFilter, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:3.18", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("ExtractConfig (nil oldImageEnv) failed: %v", err)
 	}
@@ -668,7 +668,7 @@ func TestIntegration_PortBindingsPreservation(t *testing.T) {
 	}
 
 	// Extract config
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "nginx:alpine", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "nginx:alpine", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -761,7 +761,7 @@ func TestIntegration_MultipleNetworks(t *testing.T) {
 	}
 
 	// Extract config
-	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false)
+	extracted, err := ExtractConfig(ctx, cli, log, &inspect, "alpine:latest", nil, nil, nil, false, false)
 	if err != nil {
 		t.Fatalf("Failed to extract config: %v", err)
 	}
@@ -1262,3 +1262,78 @@ func TestIntegration_ContainerRestartAlwaysExits(t *testing.T) {
 
 	t.Log("Container with restart:always behavior verified: OK")
 }
+
+// =============================================================================
+// Integration Test: Group update of a network_mode parent and its child
+// Updating the parent recreates the child under a new ID; the child must
+// still be updated rather than failing the group with "no such container".
+// =============================================================================
+
+func TestIntegration_GroupNetworkModeParentAndChild(t *testing.T) {
+	cli := getDockerClient(t)
+	ctx := context.Background()
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	pullImage(t, cli, "alpine:3.18")
+	pullImage(t, cli, "alpine:3.19")
+
+	suffix := time.Now().Unix()
+	parentName := fmt.Sprintf("dockmon-test-group-parent-%d", suffix)
+	childName := fmt.Sprintf("dockmon-test-group-child-%d", suffix)
+
+	parentResp, err := cli.ContainerCreate(ctx,
+		&container.Config{Image: "alpine:3.18", Cmd: []string{"sleep", "300"}},
+		nil, nil, nil, parentName,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create parent container: %v", err)
+	}
+	defer func() { removeContainer(cli, parentName) }()
+	if err := cli.ContainerStart(ctx, parentResp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start parent container: %v", err)
+	}
+
+	childResp, err := cli.ContainerCreate(ctx,
+		&container.Config{Image: "alpine:3.18", Cmd: []string{"sleep", "300"}},
+		&container.HostConfig{NetworkMode: container.NetworkMode("container:" + parentResp.ID)},
+		nil, nil, childName,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create child container: %v", err)
+	}
+	defer func() { removeContainer(cli, childName) }()
+	if err := cli.ContainerStart(ctx, childResp.ID, container.StartOptions{}); err != nil {
+		t.Fatalf("Failed to start child container: %v", err)
+	}
+
+	// Child listed first: ordering must put the parent first
+	updater := NewUpdater(cli, log, UpdaterOptions{})
+	result := updater.UpdateGroup(ctx, GroupUpdateRequest{
+		Members: []GroupMember{
+			{ContainerID: childResp.ID, NewImage: "alpine:3.19"},
+			{ContainerID: parentResp.ID, NewImage: "alpine:3.19"},
+		},
+		StopTimeout:   5,
+		HealthTimeout: 10,
+	})
+	if !result.Success {
+		t.Fatalf("Group update failed: %s (plan %+v)", result.Error, result.Plan)
+	}
+
+	for _, name := range []string{parentName, childName} {
+		id, err := GetContainerByName(ctx, cli, name)
+		if err != nil || id == "" {
+			t.Fatalf("Container %s missing after update: %v", name, err)
+		}
+		inspect, err := cli.ContainerInspect(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to inspect %s: %v", name, err)
+		}
+		if inspect.Config.Image != "alpine:3.19" {
+			t.Errorf("%s image = %s, want alpine:3.19", name, inspect.Config.Image)
+		}
+	}
+
+	t.Log("Group update of network_mode parent and child: OK")
+}
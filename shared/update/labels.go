@@ -0,0 +1,120 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+)
+
+// Labels a container can carry to restrict updates. They share the
+// com.dockmon.update.* namespace with the update check's policy label, and
+// watchtower's opt-out label is honored so existing setups keep working.
+const (
+	// LabelUpdatePolicy is the update policy label read by update checks
+	// (see updatecheck). Its "pin" value also refuses every image update.
+	LabelUpdatePolicy = "com.dockmon.update.policy"
+	// LabelUpdatePin restricts updates to tags matching a version pattern,
+	// e.g. "1.2.x" allows 1.2.7 but not 1.3.0
+	LabelUpdatePin = "com.dockmon.update.pin"
+	// LabelWatchtowerEnable set to "false" is treated like a "pin" policy
+	LabelWatchtowerEnable = "com.centurylinklabs.watchtower.enable"
+)
+
+// ErrUpdateRefused is returned (wrapped) when a container's labels forbid
+// an update and the request isn't forced
+var ErrUpdateRefused = errors.New("update refused by container label")
+
+// LabelPolicy is the update policy set by a container's opt-out labels
+type LabelPolicy struct {
+	Never  bool   `json:"never,omitempty"`
+	Pin    string `json:"pin,omitempty"`
+	Source string `json:"source,omitempty"` // Label that disabled updates
+}
+
+// IsZero reports whether the labels place no restriction on updates
+func (p LabelPolicy) IsZero() bool {
+	return !p.Never && p.Pin == ""
+}
+
+// ParseLabelPolicy reads the opt-out labels. An invalid pin is an error
+// rather than silently allowing updates; other policy values are validated
+// by the update check that interprets them.
+func ParseLabelPolicy(labels map[string]string) (LabelPolicy, error) {
+	var p LabelPolicy
+	if strings.EqualFold(strings.TrimSpace(labels[LabelUpdatePolicy]), "pin") {
+		p.Never, p.Source = true, LabelUpdatePolicy
+	}
+	if !p.Never && strings.EqualFold(strings.TrimSpace(labels[LabelWatchtowerEnable]), "false") {
+		p.Never, p.Source = true, LabelWatchtowerEnable
+	}
+	if v := strings.TrimSpace(labels[LabelUpdatePin]); v != "" {
+		if err := validatePin(v); err != nil {
+			return LabelPolicy{}, fmt.Errorf("invalid %s label: %w", LabelUpdatePin, err)
+		}
+		p.Pin = v
+	}
+	return p, nil
+}
+
+// Allows checks an update to newImage against the policy. An empty newImage
+// recreates the container from its current image and is always allowed.
+func (p LabelPolicy) Allows(newImage string) error {
+	if newImage == "" {
+		return nil
+	}
+	if p.Never {
+		return fmt.Errorf("%w: %s disables updates", ErrUpdateRefused, p.Source)
+	}
+	if p.Pin != "" {
+		if tag := registry.Tag(newImage); !MatchesPin(p.Pin, tag) {
+			return fmt.Errorf("%w: %s=%s does not allow %s", ErrUpdateRefused, LabelUpdatePin, p.Pin, newImage)
+		}
+	}
+	return nil
+}
+
+// checkUpdateLabels refuses an update that the container's labels forbid.
+// Callers skip it when the request is forced.
+func checkUpdateLabels(labels map[string]string, newImage string) error {
+	policy, err := ParseLabelPolicy(labels)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpdateRefused, err)
+	}
+	return policy.Allows(newImage)
+}
+
+// MatchesPin reports whether tag matches a pin pattern. Components are
+// compared in order; "x", "X" and "*" match any value, and a trailing
+// wildcard also matches deeper components ("1.x" matches 1.4 and 1.4.2).
+// A leading "v" is ignored on both sides.
+func MatchesPin(pattern, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	want := strings.Split(strings.TrimPrefix(pattern, "v"), ".")
+	got := strings.Split(strings.TrimPrefix(tag, "v"), ".")
+	if len(got) < len(want) {
+		return false
+	}
+	for i, w := range want {
+		if !isPinWildcard(w) && w != got[i] {
+			return false
+		}
+	}
+	return len(got) == len(want) || isPinWildcard(want[len(want)-1])
+}
+
+func validatePin(pattern string) error {
+	for _, part := range strings.Split(strings.TrimPrefix(pattern, "v"), ".") {
+		if part == "" {
+			return fmt.Errorf("pin %q has an empty version component", pattern)
+		}
+	}
+	return nil
+}
+
+func isPinWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
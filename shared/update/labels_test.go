@@ -0,0 +1,73 @@
+package update
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLabelPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		want    LabelPolicy
+		wantErr bool
+	}{
+		{"none", nil, LabelPolicy{}, false},
+		{"policy pin", map[string]string{LabelUpdatePolicy: "Pin"}, LabelPolicy{Never: true, Source: LabelUpdatePolicy}, false},
+		{"policy latest", map[string]string{LabelUpdatePolicy: "latest"}, LabelPolicy{}, false},
+		{"watchtower", map[string]string{LabelWatchtowerEnable: "false"}, LabelPolicy{Never: true, Source: LabelWatchtowerEnable}, false},
+		{"pin", map[string]string{LabelUpdatePin: " 1.2.x "}, LabelPolicy{Pin: "1.2.x"}, false},
+		{"bad pin", map[string]string{LabelUpdatePin: "1..x"}, LabelPolicy{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabelPolicy(tt.labels)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: ParseLabelPolicy = %+v, %v; want %+v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchesPin(t *testing.T) {
+	tests := []struct {
+		pattern, tag string
+		want         bool
+	}{
+		{"1.2.x", "1.2.7", true},
+		{"1.2.x", "v1.2.7", true},
+		{"1.2.x", "1.3.0", false},
+		{"1.x", "1.4.2", true},
+		{"1.2.*", "1.2.5-alpine", true},
+		{"1.2", "1.2", true},
+		{"1.2", "1.2.1", false},
+		{"1.2.x", "1.2", false},
+		{"1.2.x", "latest", false},
+		{"1.2.x", "", false},
+	}
+	for _, tt := range tests {
+		if got := MatchesPin(tt.pattern, tt.tag); got != tt.want {
+			t.Errorf("MatchesPin(%q, %q) = %v, want %v", tt.pattern, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestCheckUpdateLabels(t *testing.T) {
+	pinned := map[string]string{LabelUpdatePin: "1.2.x"}
+	if err := checkUpdateLabels(pinned, "nginx:1.2.9"); err != nil {
+		t.Errorf("update within pin refused: %v", err)
+	}
+	if err := checkUpdateLabels(pinned, "nginx:1.3.0"); !errors.Is(err, ErrUpdateRefused) {
+		t.Errorf("update outside pin: err = %v, want ErrUpdateRefused", err)
+	}
+
+	never := map[string]string{LabelUpdatePolicy: "pin"}
+	if err := checkUpdateLabels(never, "nginx:1.2.9"); !errors.Is(err, ErrUpdateRefused) {
+		t.Errorf("update of opted-out container: err = %v, want ErrUpdateRefused", err)
+	}
+	// Recreating from the current image isn't an update
+	if err := checkUpdateLabels(never, ""); err != nil {
+		t.Errorf("in-place recreate refused: %v", err)
+	}
+	if err := checkUpdateLabels(map[string]string{LabelUpdatePin: "1..x"}, "nginx:1.2.9"); !errors.Is(err, ErrUpdateRefused) {
+		t.Errorf("invalid label: err = %v, want ErrUpdateRefused", err)
+	}
+}
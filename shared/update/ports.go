@@ -0,0 +1,128 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+// PortConflictError reports that a host port the container publishes is
+// already bound by another container, so the recreated container couldn't
+// start.
+type PortConflictError struct {
+	HostIP        string // Empty when the binding is on all interfaces
+	HostPort      int
+	Proto         string
+	ContainerID   string
+	ContainerName string
+}
+
+func (e *PortConflictError) Error() string {
+	port := fmt.Sprintf("%d/%s", e.HostPort, e.Proto)
+	if e.HostIP != "" {
+		port = net.JoinHostPort(e.HostIP, port)
+	}
+	return fmt.Sprintf("port %s in use by container %s (%s)", port, e.ContainerName, truncateID(e.ContainerID))
+}
+
+// publishedPort is a single host port the new container will bind
+type publishedPort struct {
+	hostIP string
+	port   int
+	proto  string
+}
+
+// publishedPorts expands the fixed host ports of a port map. Bindings without
+// a host port get an ephemeral one from the daemon and can't conflict.
+func publishedPorts(bindings nat.PortMap) []publishedPort {
+	var ports []publishedPort
+	for containerPort, hostBindings := range bindings {
+		proto := containerPort.Proto()
+		for _, b := range hostBindings {
+			if b.HostPort == "" {
+				continue
+			}
+			start, end, err := nat.ParsePortRangeToInt(b.HostPort)
+			if err != nil || start == 0 {
+				continue
+			}
+			for p := start; p <= end; p++ {
+				ports = append(ports, publishedPort{hostIP: b.HostIP, port: p, proto: proto})
+			}
+		}
+	}
+	return ports
+}
+
+// hostIPsOverlap reports whether two bindings of the same port would collide.
+// An empty address binds every interface of both families; 0.0.0.0 and ::
+// every interface of their own family.
+func hostIPsOverlap(a, b string) bool {
+	if a == "" || b == "" {
+		return true
+	}
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	if (ipA.To4() == nil) != (ipB.To4() == nil) {
+		return false
+	}
+	return ipA.IsUnspecified() || ipB.IsUnspecified() || ipA.Equal(ipB)
+}
+
+// findPortConflict returns the first running container, other than the one
+// being updated, holding a host port the new container publishes
+func findPortConflict(ports []publishedPort, containers []container.Summary, containerID string) *PortConflictError {
+	for _, c := range containers {
+		if c.ID == containerID || (containerID != "" && strings.HasPrefix(c.ID, containerID)) {
+			continue
+		}
+		for _, held := range c.Ports {
+			for _, want := range ports {
+				if int(held.PublicPort) != want.port || held.Type != want.proto || !hostIPsOverlap(held.IP, want.hostIP) {
+					continue
+				}
+				name := c.ID
+				if len(c.Names) > 0 {
+					name = strings.TrimPrefix(c.Names[0], "/")
+				}
+				return &PortConflictError{
+					HostIP:        want.hostIP,
+					HostPort:      want.port,
+					Proto:         want.proto,
+					ContainerID:   c.ID,
+					ContainerName: name,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPortConflicts fails an update whose published host ports are held by
+// another container, before the old container is stopped. Failures to list
+// containers are logged and let the update continue.
+func (u *Updater) checkPortConflicts(ctx context.Context, containerID string, hostConfig *container.HostConfig) error {
+	if hostConfig == nil || hostConfig.NetworkMode.IsHost() || hostConfig.NetworkMode.IsContainer() {
+		return nil
+	}
+	ports := publishedPorts(hostConfig.PortBindings)
+	if len(ports) == 0 {
+		return nil
+	}
+
+	containers, err := u.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		u.log.WithError(err).Warn("Port conflict check skipped: failed to list containers")
+		return nil
+	}
+	if conflict := findPortConflict(ports, containers, containerID); conflict != nil {
+		return conflict
+	}
+	return nil
+}
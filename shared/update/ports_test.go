@@ -0,0 +1,81 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestPublishedPorts(t *testing.T) {
+	ports := publishedPorts(nat.PortMap{
+		"80/tcp":   {{HostPort: "8080"}},
+		"53/udp":   {{HostIP: "127.0.0.1", HostPort: "5353"}},
+		"9000/tcp": {{HostPort: ""}},          // Ephemeral
+		"7000/tcp": {{HostPort: "7000-7002"}}, // Range
+	})
+
+	if len(ports) != 5 {
+		t.Fatalf("published ports = %+v, want 5", ports)
+	}
+	got := make(map[publishedPort]bool)
+	for _, p := range ports {
+		got[p] = true
+	}
+	for _, want := range []publishedPort{
+		{port: 8080, proto: "tcp"},
+		{hostIP: "127.0.0.1", port: 5353, proto: "udp"},
+		{port: 7000, proto: "tcp"},
+		{port: 7002, proto: "tcp"},
+	} {
+		if !got[want] {
+			t.Errorf("missing %+v", want)
+		}
+	}
+}
+
+func TestHostIPsOverlap(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "127.0.0.1", true},
+		{"0.0.0.0", "192.168.1.5", true},
+		{"::", "::1", true},
+		{"0.0.0.0", "::", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "192.168.1.5", false},
+		{"::1", "127.0.0.1", false},
+	}
+	for _, tc := range cases {
+		if got := hostIPsOverlap(tc.a, tc.b); got != tc.want {
+			t.Errorf("hostIPsOverlap(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestFindPortConflict(t *testing.T) {
+	ports := []publishedPort{{port: 8080, proto: "tcp"}}
+	containers := []container.Summary{
+		// The container being updated holds its own ports
+		{ID: "self0123456789", Names: []string{"/web"}, Ports: []container.Port{{IP: "0.0.0.0", PublicPort: 8080, Type: "tcp"}}},
+		// Same number, other protocol
+		{ID: "udp0123456789", Names: []string{"/dns"}, Ports: []container.Port{{IP: "0.0.0.0", PublicPort: 8080, Type: "udp"}}},
+		{ID: "grab0123456789", Names: []string{"/squatter"}, Ports: []container.Port{{IP: "::", PublicPort: 8080, Type: "tcp"}}},
+	}
+
+	conflict := findPortConflict(ports, containers, "self01234567")
+	if conflict == nil {
+		t.Fatal("expected a conflict")
+	}
+	if conflict.ContainerName != "squatter" || conflict.HostPort != 8080 || conflict.Proto != "tcp" {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	if msg := conflict.Error(); msg != "port 8080/tcp in use by container squatter (grab01234567)" {
+		t.Errorf("message = %q", msg)
+	}
+
+	if c := findPortConflict(ports, containers[:2], "self01234567"); c != nil {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+}
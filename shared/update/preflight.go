@@ -0,0 +1,264 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+)
+
+const (
+	// DefaultMinFreeSpace is the headroom kept free on the Docker root
+	// filesystem on top of the estimated image size
+	DefaultMinFreeSpace int64 = 1 << 30
+
+	// DefaultPreflightImage runs `df` against the Docker root directory
+	DefaultPreflightImage = "busybox:stable"
+
+	// imageExpansionFactor approximates how much compressed layers grow once
+	// extracted (typical gzip ratio for image layers)
+	imageExpansionFactor = 2.5
+
+	preflightLabel   = "com.dockmon.helper"
+	preflightTimeout = 2 * time.Minute
+)
+
+// DiskSpaceError reports that an update was aborted before pulling because
+// the Docker root filesystem lacks headroom.
+type DiskSpaceError struct {
+	Path        string
+	Free        int64
+	Needed      int64 // estimated image size plus margin
+	ImageSize   int64 // 0 when the registry couldn't be queried
+	Reclaimable int64
+}
+
+func (e *DiskSpaceError) Error() string {
+	msg := fmt.Sprintf("insufficient disk space on %s: %s free, need %s",
+		e.Path, units.HumanSize(float64(e.Free)), units.HumanSize(float64(e.Needed)))
+	if e.ImageSize > 0 {
+		msg += fmt.Sprintf(" (~%s for the new image plus %s margin)",
+			units.HumanSize(float64(e.ImageSize)), units.HumanSize(float64(e.Needed-e.ImageSize)))
+	}
+	if e.Reclaimable > 0 {
+		msg += fmt.Sprintf("; about %s can be reclaimed by pruning unused images and build cache",
+			units.HumanSize(float64(e.Reclaimable)))
+	}
+	return msg
+}
+
+// preflightDiskSpace aborts an update whose image pull would likely fill the
+// Docker root filesystem. Failures to measure are logged and let the update
+// continue, so the check never blocks updates on hosts it can't inspect.
+// Nothing is measured when the new image is already on the host (pinned by
+// digest and present, or every layer local), since the pull adds nothing.
+func (u *Updater) preflightDiskSpace(ctx context.Context, req UpdateRequest, containerID string) error {
+	margin := u.options.MinFreeSpace
+	if margin < 0 {
+		return nil
+	}
+	if margin == 0 {
+		margin = DefaultMinFreeSpace
+	}
+	if u.options.IsWindows {
+		u.log.Debug("Skipping disk space preflight on Windows containers")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	// A digest reference that resolves locally is exactly the image wanted
+	local, localErr := u.cli.ImageInspect(ctx, req.NewImage)
+	if localErr == nil && strings.Contains(req.NewImage, "@") {
+		u.log.Debug("Disk space preflight skipped: image already present")
+		return nil
+	}
+
+	info, err := u.cli.Info(ctx)
+	if err != nil {
+		u.log.WithError(err).Warn("Disk space preflight skipped: failed to query Docker info")
+		return nil
+	}
+
+	// Layers of the image being replaced, and of any local copy of the new
+	// tag, are already on disk
+	localDiffIDs := make(map[string]bool)
+	if localErr == nil {
+		for _, id := range local.RootFS.Layers {
+			localDiffIDs[id] = true
+		}
+	}
+	if current, err := u.cli.ContainerInspect(ctx, containerID); err == nil {
+		if img, err := u.cli.ImageInspect(ctx, current.Image); err == nil {
+			for _, id := range img.RootFS.Layers {
+				localDiffIDs[id] = true
+			}
+		}
+	}
+
+	var creds *registry.Credentials
+	if req.RegistryAuth != nil {
+		creds = &registry.Credentials{Username: req.RegistryAuth.Username, Password: req.RegistryAuth.Password}
+	}
+	platform := registry.Platform{OS: info.OSType, Architecture: registry.NormalizeArch(info.Architecture)}
+	var imageSize int64
+	if remote, err := registry.NewClient(creds).Inspect(ctx, req.NewImage, platform); err != nil {
+		u.log.WithError(err).Warn("Could not estimate image size from registry, checking margin only")
+	} else {
+		download := registry.DownloadSize(remote, localDiffIDs)
+		if download == 0 && len(remote.Layers) > 0 {
+			u.log.Debug("Disk space preflight skipped: all image layers already present")
+			return nil
+		}
+		imageSize = int64(float64(download) * imageExpansionFactor)
+	}
+
+	free, err := u.dockerRootFree(ctx, info.DockerRootDir)
+	if err != nil {
+		u.log.WithError(err).Warn("Disk space preflight skipped: failed to measure free space")
+		return nil
+	}
+
+	if err := checkHeadroom(free, imageSize, margin); err != nil {
+		err.Path = info.DockerRootDir
+		err.Reclaimable = u.reclaimableSpace(ctx)
+		return err
+	}
+
+	u.log.WithField("free", units.HumanSize(float64(free))).
+		WithField("image_estimate", units.HumanSize(float64(imageSize))).
+		Debug("Disk space preflight passed")
+	return nil
+}
+
+// checkHeadroom returns a DiskSpaceError when free space can't hold the
+// image plus margin
+func checkHeadroom(free, imageSize, margin int64) *DiskSpaceError {
+	needed := imageSize + margin
+	if free >= needed {
+		return nil
+	}
+	return &DiskSpaceError{Free: free, Needed: needed, ImageSize: imageSize}
+}
+
+// reclaimableSpace sums unused images and build cache from Docker's disk
+// usage report, for the hint in the preflight error
+func (u *Updater) reclaimableSpace(ctx context.Context) int64 {
+	usage, err := u.cli.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.ImageObject, types.BuildCacheObject},
+	})
+	if err != nil {
+		u.log.WithError(err).Debug("Failed to query Docker disk usage")
+		return 0
+	}
+
+	var total int64
+	for _, img := range usage.Images {
+		if img != nil && img.Containers == 0 {
+			total += img.Size - img.SharedSize
+		}
+	}
+	for _, cache := range usage.BuildCache {
+		if cache != nil && !cache.InUse && !cache.Shared {
+			total += cache.Size
+		}
+	}
+	return total
+}
+
+// dockerRootFree measures free space on the Docker root filesystem from a
+// throwaway container, so it works for remote daemons too
+func (u *Updater) dockerRootFree(ctx context.Context, rootDir string) (int64, error) {
+	if rootDir == "" {
+		return 0, fmt.Errorf("daemon did not report its root directory")
+	}
+	helperImage := u.options.PreflightImage
+	if helperImage == "" {
+		helperImage = DefaultPreflightImage
+	}
+	if _, err := u.cli.ImageInspect(ctx, helperImage); err != nil {
+		reader, err := u.cli.ImagePull(ctx, helperImage, image.PullOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to pull preflight image %s: %w", helperImage, err)
+		}
+		_, _ = io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+	resp, err := u.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:  helperImage,
+			Cmd:    []string{"df", "-Pk", "/docker-root"},
+			Labels: map[string]string{preflightLabel: "disk-preflight"},
+		},
+		&container.HostConfig{
+			Binds:       []string{rootDir + ":/docker-root:ro"},
+			NetworkMode: "none",
+		},
+		nil, nil, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create preflight container: %w", err)
+	}
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = u.cli.ContainerRemove(removeCtx, resp.ID, container.RemoveOptions{Force: true})
+	}()
+
+	if err := u.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return 0, fmt.Errorf("failed to start preflight container: %w", err)
+	}
+
+	waitCh, errCh := u.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		return 0, fmt.Errorf("preflight container failed: %w", err)
+	case status := <-waitCh:
+		exitCode = status.StatusCode
+	}
+
+	logs, err := u.cli.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read preflight output: %w", err)
+	}
+	defer logs.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return 0, fmt.Errorf("failed to read preflight output: %w", err)
+	}
+	if exitCode != 0 {
+		return 0, fmt.Errorf("df exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return parseDfAvailable(stdout.String())
+}
+
+// parseDfAvailable reads the available column from POSIX `df -Pk` output.
+// Fields are taken from the right since filesystem names may contain spaces.
+func parseDfAvailable(out string) (int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 6 {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+	kb, err := strconv.ParseInt(fields[len(fields)-3], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output: %q", out)
+	}
+	return kb * 1024, nil
+}
@@ -0,0 +1,109 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseDfAvailable(t *testing.T) {
+	out := "Filesystem           1024-blocks    Used Available Capacity Mounted on\n" +
+		"/dev/sda1               51474912 40000000   9000000  82% /docker-root\n"
+	got, err := parseDfAvailable(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 9000000*1024 {
+		t.Errorf("available = %d", got)
+	}
+
+	// Device names with spaces shift the left-hand columns only
+	out = "Filesystem 1024-blocks Used Available Capacity Mounted on\n" +
+		"my disk 100 40 60 40% /docker-root\n"
+	if got, err := parseDfAvailable(out); err != nil || got != 60*1024 {
+		t.Errorf("available = %d, err = %v", got, err)
+	}
+
+	for _, bad := range []string{"", "Filesystem 1024-blocks Used Available Capacity Mounted on", "header\nnot enough fields"} {
+		if _, err := parseDfAvailable(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestCheckHeadroom(t *testing.T) {
+	const gb = int64(1 << 30)
+
+	if err := checkHeadroom(5*gb, 2*gb, gb); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkHeadroom(3*gb, 2*gb, gb); err != nil {
+		t.Errorf("exact fit should pass: %v", err)
+	}
+
+	err := checkHeadroom(2*gb, 2*gb, gb)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if err.Needed != 3*gb || err.Free != 2*gb || err.ImageSize != 2*gb {
+		t.Errorf("error fields = %+v", err)
+	}
+}
+
+func TestDiskSpaceErrorMessage(t *testing.T) {
+	err := &DiskSpaceError{
+		Path:        "/var/lib/docker",
+		Free:        500 * 1000 * 1000,
+		Needed:      1500 * 1000 * 1000,
+		ImageSize:   500 * 1000 * 1000,
+		Reclaimable: 3 * 1000 * 1000 * 1000,
+	}
+	msg := err.Error()
+	for _, want := range []string{"/var/lib/docker", "500MB free", "need 1.5GB", "~500MB for the new image", "1GB margin", "3GB can be reclaimed"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q missing %q", msg, want)
+		}
+	}
+
+	// Without an image estimate or reclaimable space only the basics remain
+	msg = (&DiskSpaceError{Path: "/d", Free: 1, Needed: 2}).Error()
+	if strings.Contains(msg, "new image") || strings.Contains(msg, "reclaimed") {
+		t.Errorf("unexpected detail in %q", msg)
+	}
+}
+
+// TestPreflightDiskSpace_SkipsPresentDigest verifies a digest-pinned image
+// that's already local skips the measurement (no helper container)
+func TestPreflightDiskSpace_SkipsPresentDigest(t *testing.T) {
+	const ref = "nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/"+ref+"/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"Id":"sha256:abc","RootFS":{"Type":"layers","Layers":["sha256:l1"]}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	u := NewUpdater(cli, logrus.New(), UpdaterOptions{})
+	if err := u.preflightDiskSpace(context.Background(), UpdateRequest{NewImage: ref}, "abc123"); err != nil {
+		t.Fatalf("preflight: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("daemon calls = %v, want only the image inspect", calls)
+	}
+}
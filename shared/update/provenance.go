@@ -0,0 +1,76 @@
+package update
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/client"
+)
+
+// Provenance labels stamped on every container DockMon creates during an
+// update, so the exact image bytes running (and the ones they replaced) can
+// be audited and rolled back to without relying on mutable tags.
+const (
+	// LabelImageDigest is the digest of the image the container runs
+	LabelImageDigest = "dockmon.image-digest"
+	// LabelUpdatedFrom is the digest of the image the container ran before
+	LabelUpdatedFrom = "dockmon.updated-from"
+)
+
+// resolveImageDigest returns the immutable reference for an image: the repo
+// digest (repo@sha256:...) matching name's repository when the image came
+// from a registry, otherwise its local image ID. ref is what gets inspected
+// (a tag or an image ID). Returns "" if the image can't be inspected.
+func resolveImageDigest(ctx context.Context, cli *client.Client, ref, name string) string {
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return ""
+	}
+	if digest := repoDigestFor(inspect.RepoDigests, name); digest != "" {
+		return digest
+	}
+	return inspect.ID
+}
+
+// repoDigestFor picks the repo digest belonging to ref's repository, falling
+// back to the first one (the same image pulled under another name).
+func repoDigestFor(repoDigests []string, ref string) string {
+	if len(repoDigests) == 0 {
+		return ""
+	}
+	repo := ref
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo = repo[:i]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	for _, digest := range repoDigests {
+		if strings.HasPrefix(digest, repo+"@") {
+			return digest
+		}
+	}
+	return repoDigests[0]
+}
+
+// applyProvenanceLabels records the new and previous image digests in the
+// container labels. Labels inherited from the old container are dropped when
+// a digest is unknown so they never describe the wrong image; an in-place
+// recreate (same digest) keeps the inherited updated-from history.
+func applyProvenanceLabels(labels map[string]string, digest, previous string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if digest == "" {
+		delete(labels, LabelImageDigest)
+		delete(labels, LabelUpdatedFrom)
+		return labels
+	}
+	labels[LabelImageDigest] = digest
+	switch {
+	case previous == "":
+		delete(labels, LabelUpdatedFrom)
+	case previous != digest:
+		labels[LabelUpdatedFrom] = previous
+	}
+	return labels
+}
@@ -0,0 +1,86 @@
+package update
+
+import "testing"
+
+func TestRepoDigestFor(t *testing.T) {
+	digests := []string{
+		"mirror.local/nginx@sha256:aaa",
+		"nginx@sha256:bbb",
+	}
+
+	tests := []struct {
+		name    string
+		digests []string
+		ref     string
+		want    string
+	}{
+		{"matches tag repo", digests, "nginx:1.27", "nginx@sha256:bbb"},
+		{"matches untagged repo", digests, "nginx", "nginx@sha256:bbb"},
+		{"matches digest ref", digests, "mirror.local/nginx@sha256:aaa", "mirror.local/nginx@sha256:aaa"},
+		{"registry port is not a tag", []string{"reg:5000/app@sha256:ccc"}, "reg:5000/app", "reg:5000/app@sha256:ccc"},
+		{"falls back to first", digests, "other:latest", "mirror.local/nginx@sha256:aaa"},
+		{"local image has none", nil, "local:dev", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repoDigestFor(tt.digests, tt.ref); got != tt.want {
+				t.Errorf("repoDigestFor(%v, %q) = %q, want %q", tt.digests, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyProvenanceLabels_RecordsDigests(t *testing.T) {
+	labels := applyProvenanceLabels(map[string]string{"app": "web"}, "nginx@sha256:new", "nginx@sha256:old")
+
+	if labels[LabelImageDigest] != "nginx@sha256:new" {
+		t.Errorf("image digest label = %q", labels[LabelImageDigest])
+	}
+	if labels[LabelUpdatedFrom] != "nginx@sha256:old" {
+		t.Errorf("updated-from label = %q", labels[LabelUpdatedFrom])
+	}
+	if labels["app"] != "web" {
+		t.Error("user label should be preserved")
+	}
+}
+
+func TestApplyProvenanceLabels_NilLabels(t *testing.T) {
+	labels := applyProvenanceLabels(nil, "sha256:abc", "")
+
+	if labels[LabelImageDigest] != "sha256:abc" {
+		t.Errorf("image digest label = %q", labels[LabelImageDigest])
+	}
+	if _, ok := labels[LabelUpdatedFrom]; ok {
+		t.Error("updated-from should not be set without a previous digest")
+	}
+}
+
+func TestApplyProvenanceLabels_RecreateKeepsHistory(t *testing.T) {
+	inherited := map[string]string{
+		LabelImageDigest: "nginx@sha256:new",
+		LabelUpdatedFrom: "nginx@sha256:old",
+	}
+
+	labels := applyProvenanceLabels(inherited, "nginx@sha256:new", "nginx@sha256:new")
+
+	if labels[LabelUpdatedFrom] != "nginx@sha256:old" {
+		t.Errorf("updated-from = %q, want inherited history kept", labels[LabelUpdatedFrom])
+	}
+}
+
+func TestApplyProvenanceLabels_UnknownDigestDropsStaleLabels(t *testing.T) {
+	inherited := map[string]string{
+		LabelImageDigest: "nginx@sha256:old",
+		LabelUpdatedFrom: "nginx@sha256:older",
+	}
+
+	labels := applyProvenanceLabels(inherited, "", "nginx@sha256:old")
+
+	if _, ok := labels[LabelImageDigest]; ok {
+		t.Error("stale image digest label should be removed")
+	}
+	if _, ok := labels[LabelUpdatedFrom]; ok {
+		t.Error("stale updated-from label should be removed")
+	}
+}
@@ -0,0 +1,142 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Readiness probe types
+const (
+	ReadinessProbeHTTP = "http"
+	ReadinessProbeTCP  = "tcp"
+)
+
+// readinessAttemptTimeout bounds a single probe attempt
+const readinessAttemptTimeout = 5 * time.Second
+
+// ReadinessProbe is polled from the host after the new container starts, for
+// images without a HEALTHCHECK. The update waits until the probe succeeds,
+// up to the health timeout.
+//
+//   - http: GET URL until it answers with a status below 400
+//   - tcp:  dial Address ("host:port", or just a port on the container's IP)
+type ReadinessProbe struct {
+	Type            string `json:"type"`
+	URL             string `json:"url,omitempty"`
+	Address         string `json:"address,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"` // Default: 2s
+}
+
+// Validate checks the probe is complete and well-formed.
+func (p *ReadinessProbe) Validate() error {
+	if p.IntervalSeconds < 0 {
+		return fmt.Errorf("readiness probe interval_seconds must not be negative")
+	}
+
+	switch p.Type {
+	case ReadinessProbeHTTP:
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("readiness probe url must be an http(s) URL, got %q", p.URL)
+		}
+	case ReadinessProbeTCP:
+		if _, err := p.tcpAddress(""); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("readiness probe type must be http or tcp, got %q", p.Type)
+	}
+	return nil
+}
+
+// interval returns the delay between probe attempts
+func (p *ReadinessProbe) interval() time.Duration {
+	if p.IntervalSeconds > 0 {
+		return time.Duration(p.IntervalSeconds) * time.Second
+	}
+	return 2 * time.Second
+}
+
+// tcpAddress resolves Address to host:port. A bare port is dialed on
+// containerIP, or localhost when that is empty (e.g. host networking).
+func (p *ReadinessProbe) tcpAddress(containerIP string) (string, error) {
+	host, port, err := net.SplitHostPort(p.Address)
+	if err != nil {
+		// Bare port
+		host, port = "", p.Address
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("readiness probe address must be host:port or a port, got %q", p.Address)
+	}
+	if host == "" {
+		host = containerIP
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// containerIP returns the container's address on its first network (by
+// name), or "" when it has none
+func containerIP(inspect container.InspectResponse) string {
+	if inspect.NetworkSettings == nil {
+		return ""
+	}
+	names := make([]string, 0, len(inspect.NetworkSettings.Networks))
+	for name := range inspect.NetworkSettings.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ep := inspect.NetworkSettings.Networks[name]; ep != nil && ep.IPAddress != "" {
+			return ep.IPAddress
+		}
+	}
+	return inspect.NetworkSettings.IPAddress
+}
+
+// check makes a single probe attempt. containerIP is where a bare tcp port
+// is dialed.
+func (p *ReadinessProbe) check(ctx context.Context, containerIP string) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessAttemptTimeout)
+	defer cancel()
+
+	switch p.Type {
+	case ReadinessProbeHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %d", p.URL, resp.StatusCode)
+		}
+		return nil
+	case ReadinessProbeTCP:
+		addr, err := p.tcpAddress(containerIP)
+		if err != nil {
+			return err
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	default:
+		return fmt.Errorf("unknown readiness probe type %q", p.Type)
+	}
+}
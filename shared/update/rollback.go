@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"time"
 
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
@@ -13,6 +14,7 @@ import (
 )
 
 // CreateBackup stops the container and renames it to a backup name.
+// stopStrategy may be nil to stop with the container's own settings.
 // Returns the backup name for later restoration or cleanup.
 func CreateBackup(
 	ctx context.Context,
@@ -20,14 +22,14 @@ func CreateBackup(
 	log *logrus.Logger,
 	containerID string,
 	containerName string,
+	stopStrategy *sharedDocker.StopStrategy,
 	stopTimeout int,
 ) (string, error) {
 	backupName := fmt.Sprintf("%s-dockmon-backup-%d", containerName, time.Now().Unix())
 
 	// Stop container gracefully
 	log.Debugf("Stopping container %s", truncateID(containerID))
-	stopTimeoutInt := stopTimeout
-	if err := cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &stopTimeoutInt}); err != nil {
+	if err := sharedDocker.StopContainer(ctx, cli, containerID, stopStrategy, stopTimeout); err != nil {
 		log.WithError(err).Warn("Failed to stop container gracefully, continuing with rename")
 	}
 
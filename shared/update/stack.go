@@ -0,0 +1,90 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Compose labels written when a container is adopted into a stack
+const (
+	composeNumberLabel      = "com.docker.compose.container-number"
+	composeOneoffLabel      = "com.docker.compose.oneoff"
+	composeWorkingDirLabel  = "com.docker.compose.project.working_dir"
+	composeConfigFilesLabel = "com.docker.compose.project.config_files"
+)
+
+// Names compose accepts for projects and services
+var (
+	composeProjectPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	composeServicePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// StackAdoption folds a standalone container into a compose stack: the
+// container is recreated with the stack's compose labels, so DockMon (and
+// the compose CLI) treat it as the stack's service from then on. Networks
+// and everything else are kept as they are; the stack's next deploy brings
+// them in line with its compose file.
+type StackAdoption struct {
+	Project string `json:"project"`
+	Service string `json:"service"`
+	// Where the stack's compose file lives, as compose records it
+	WorkingDir  string `json:"working_dir,omitempty"`
+	ConfigFiles string `json:"config_files,omitempty"` // Comma-separated paths
+}
+
+// Validate checks the project and service names are ones compose accepts
+func (s *StackAdoption) Validate() error {
+	if !composeProjectPattern.MatchString(s.Project) {
+		return fmt.Errorf("invalid project name %q: use lowercase letters, digits, dashes and underscores", s.Project)
+	}
+	if !composeServicePattern.MatchString(s.Service) {
+		return fmt.Errorf("invalid service name %q", s.Service)
+	}
+	return nil
+}
+
+// checkAdoptable fails if the container already belongs to a compose project
+// or the stack already runs a container for the service
+func (s *StackAdoption) checkAdoptable(ctx context.Context, cli client.APIClient, current *container.InspectResponse) error {
+	if project := current.Config.Labels[composeProjectLabel]; project != "" {
+		return fmt.Errorf("container already belongs to compose project %q", project)
+	}
+
+	existing, err := cli.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", composeProjectLabel+"="+s.Project),
+			filters.Arg("label", composeServiceLabel+"="+s.Service),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list stack containers: %w", err)
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("stack %s already has a container for service %s (%s)", s.Project, s.Service, truncateID(existing[0].ID))
+	}
+	return nil
+}
+
+// apply writes the compose labels into a container's labels
+func (s *StackAdoption) apply(labels map[string]string) map[string]string {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[composeProjectLabel] = s.Project
+	labels[composeServiceLabel] = s.Service
+	labels[composeNumberLabel] = "1"
+	labels[composeOneoffLabel] = "False"
+	if s.WorkingDir != "" {
+		labels[composeWorkingDirLabel] = s.WorkingDir
+	}
+	if s.ConfigFiles != "" {
+		labels[composeConfigFilesLabel] = s.ConfigFiles
+	}
+	return labels
+}
@@ -0,0 +1,89 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+func TestStackAdoptionValidate(t *testing.T) {
+	tests := []struct {
+		adoption StackAdoption
+		wantErr  bool
+	}{
+		{StackAdoption{Project: "media", Service: "plex"}, false},
+		{StackAdoption{Project: "my_stack-2", Service: "Web.1"}, false},
+		{StackAdoption{Project: "Media", Service: "plex"}, true},
+		{StackAdoption{Project: "", Service: "plex"}, true},
+		{StackAdoption{Project: "media", Service: ""}, true},
+		{StackAdoption{Project: "media", Service: "-plex"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.adoption.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, wantErr %v", tt.adoption, err, tt.wantErr)
+		}
+	}
+}
+
+func TestStackAdoptionApply(t *testing.T) {
+	s := &StackAdoption{Project: "media", Service: "plex", WorkingDir: "/opt/media"}
+	labels := s.apply(map[string]string{"com.dockmon.update.pin": "1.x"})
+
+	want := map[string]string{
+		"com.dockmon.update.pin":                 "1.x",
+		"com.docker.compose.project":             "media",
+		"com.docker.compose.service":             "plex",
+		"com.docker.compose.container-number":    "1",
+		"com.docker.compose.oneoff":              "False",
+		"com.docker.compose.project.working_dir": "/opt/media",
+	}
+	if len(labels) != len(want) {
+		t.Errorf("labels = %v, want %v", labels, want)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, labels[k], v)
+		}
+	}
+
+	if labels := s.apply(nil); labels["com.docker.compose.project"] != "media" {
+		t.Errorf("apply(nil) = %v", labels)
+	}
+}
+
+func TestStackAdoptionCheckAdoptable(t *testing.T) {
+	// The stack "media" already runs "db"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Query().Get("filters"), "com.docker.compose.service=db") {
+			w.Write([]byte(`[{"Id":"0123456789abcdef"}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(srv.URL, "http://")), client.WithVersion("1.45"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	standalone := &container.InspectResponse{Config: &container.Config{Labels: map[string]string{}}}
+	composed := &container.InspectResponse{Config: &container.Config{Labels: map[string]string{"com.docker.compose.project": "other"}}}
+
+	ctx := context.Background()
+	if err := (&StackAdoption{Project: "media", Service: "plex"}).checkAdoptable(ctx, cli, standalone); err != nil {
+		t.Errorf("new service: %v", err)
+	}
+	if err := (&StackAdoption{Project: "media", Service: "db"}).checkAdoptable(ctx, cli, standalone); err == nil || !strings.Contains(err.Error(), "0123456789ab") {
+		t.Errorf("taken service: err = %v", err)
+	}
+	if err := (&StackAdoption{Project: "media", Service: "plex"}).checkAdoptable(ctx, cli, composed); err == nil || !strings.Contains(err.Error(), `"other"`) {
+		t.Errorf("container of another project: err = %v", err)
+	}
+}
@@ -7,18 +7,55 @@
 package update
 
 import (
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/progress"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 )
 
 // UpdateRequest contains all parameters for a container update.
+// An empty NewImage recreates the container from its current image without
+// pulling, which is how configuration-only changes (e.g. Healthcheck) are applied.
 type UpdateRequest struct {
-	ContainerID   string        `json:"container_id"`
-	NewImage      string        `json:"new_image"`
-	StopTimeout   int           `json:"stop_timeout,omitempty"`   // Default: 30s
-	HealthTimeout int           `json:"health_timeout,omitempty"` // Default: 120s
-	RegistryAuth  *RegistryAuth `json:"registry_auth,omitempty"`  // Optional registry credentials
+	ContainerID   string               `json:"container_id"`
+	NewImage      string               `json:"new_image"`
+	StopTimeout   int                  `json:"stop_timeout,omitempty"`   // Default: 30s
+	HealthTimeout int                  `json:"health_timeout,omitempty"` // Default: 120s
+	RegistryAuth  *RegistryAuth        `json:"registry_auth,omitempty"`  // Optional registry credentials
+	Healthcheck   *HealthcheckOverride `json:"healthcheck,omitempty"`    // Optional HEALTHCHECK override
+	Hooks         *UpdateHooks         `json:"hooks,omitempty"`          // Optional pre/post-update commands
+	AdoptInto     *StackAdoption       `json:"adopt_into,omitempty"`     // Optional compose stack to fold the container into
+
+	// StopStrategy overrides how the old container is stopped (signal and
+	// escalation). An unset timeout falls back to an explicit StopTimeout,
+	// then the container's StopTimeout, then 30s; an unset signal to the
+	// container's StopSignal, then SIGTERM.
+	StopStrategy *sharedDocker.StopStrategy `json:"stop_strategy,omitempty"`
+
+	// Health check tuning. GracePeriodSeconds overrides the default
+	// min(30s, 50% of HealthTimeout) during which "unhealthy" is tolerated;
+	// ReadinessProbe is polled from the host for images without a
+	// HEALTHCHECK; SkipHealthCheck accepts the container once it starts.
+	GracePeriodSeconds *int            `json:"grace_period_seconds,omitempty"`
+	ReadinessProbe     *ReadinessProbe `json:"readiness_probe,omitempty"`
+	SkipHealthCheck    bool            `json:"skip_health_check,omitempty"`
+
+	// Failure forensics, for a new container that fails its health check
+	// or post-update hooks. FailureLogLines is how many of its last log
+	// lines go into the result (0: DefaultFailureLogLines, negative: none).
+	// QuarantineHours keeps it stopped under a quarantine name for that
+	// long instead of removing it (0: removed right away).
+	FailureLogLines int `json:"failure_log_lines,omitempty"`
+	QuarantineHours int `json:"quarantine_hours,omitempty"`
+
+	// Force updates a container even when its com.dockmon.update.policy or
+	// com.dockmon.update.pin label forbids it
+	Force bool `json:"force,omitempty"`
+
+	// KeepBackup leaves the backup container in place on success so a caller
+	// (UpdateGroup) can still roll back. The caller owns its cleanup.
+	KeepBackup bool `json:"-"`
 }
 
 // RegistryAuth contains credentials for authenticating with a Docker registry.
@@ -36,6 +73,21 @@ type UpdateResult struct {
 	RolledBack       bool     `json:"rolled_back,omitempty"`
 	FailedDependents []string `json:"failed_dependents,omitempty"`
 	Error            string   `json:"error,omitempty"`
+
+	// Provenance: immutable references (repo@sha256:... or image ID) of the
+	// image now running and the one it replaced. Also stored as container
+	// labels (LabelImageDigest, LabelUpdatedFrom).
+	ImageDigest         string `json:"image_digest,omitempty"`
+	PreviousImageDigest string `json:"previous_image_digest,omitempty"`
+
+	// Failure is what was captured from the new container before a
+	// rollback removed (or quarantined) it
+	Failure *FailureForensics `json:"failure,omitempty"`
+
+	// Rollback bookkeeping for UpdateGroup (only set on success)
+	backupName       string
+	newContainerFull string
+	wasRunning       bool
 }
 
 // ProgressEvent represents an update progress event for streaming.
@@ -43,16 +95,11 @@ type ProgressEvent struct {
 	Stage    string `json:"stage"`
 	Message  string `json:"message"`
 	Progress int    `json:"progress,omitempty"` // 0-100 for stages that support it
+	Output   string `json:"output,omitempty"`   // Captured command output (hook stages)
 }
 
 // LayerProgress represents progress for a single image layer during pull.
-type LayerProgress struct {
-	ID      string `json:"id"`
-	Status  string `json:"status"`
-	Current int64  `json:"current"`
-	Total   int64  `json:"total"`
-	Percent int    `json:"percent"`
-}
+type LayerProgress = progress.Layer
 
 // PullProgressEvent contains detailed layer progress during image pull.
 type PullProgressEvent struct {
@@ -65,19 +112,49 @@ type PullProgressEvent struct {
 	SpeedMbps       float64          `json:"speed_mbps,omitempty"`
 }
 
+// ToProgress converts the event to the shared progress schema.
+func (e ProgressEvent) ToProgress(containerID string) progress.Event {
+	event := progress.New(progress.OperationUpdate, progress.Stage(e.Stage), e.Message)
+	event.ContainerID = containerID
+	event.StagePercent = e.Progress
+	event.Output = e.Output
+	return event
+}
+
+// ToProgress converts the event to the shared progress schema, with the
+// summary as the message.
+func (e PullProgressEvent) ToProgress() progress.Event {
+	event := progress.New(progress.OperationUpdate, progress.StagePulling, e.Summary)
+	event.ContainerID = e.ContainerID
+	event.StagePercent = e.OverallProgress
+	event.SpeedMbps = e.SpeedMbps
+	event.TotalLayers = e.TotalLayers
+	event.RemainingLayers = e.RemainingLayers
+	event.Layers = make([]progress.Layer, 0, len(e.Layers))
+	for _, layer := range e.Layers {
+		if layer != nil {
+			event.Layers = append(event.Layers, *layer)
+		}
+	}
+	return event
+}
+
 // Update stage constants (aligned with Python backend for compatibility).
 const (
-	StagePulling     = "pulling"
-	StageConfiguring = "configuring"
-	StageBackup      = "backup"
-	StageCreating    = "creating"
-	StageStarting    = "starting"
-	StageHealthCheck = "health_check"
-	StageDependents  = "dependents"
-	StageCleanup     = "cleanup"
-	StageCompleted   = "completed"
-	StageFailed      = "failed"
-	StageRollback    = "rollback"
+	StagePreflight   = string(progress.StagePreflight)
+	StagePulling     = string(progress.StagePulling)
+	StageConfiguring = string(progress.StageConfiguring)
+	StagePreHook     = string(progress.StagePreHook)
+	StageBackup      = string(progress.StageBackup)
+	StageCreating    = string(progress.StageCreating)
+	StageStarting    = string(progress.StageStarting)
+	StageHealthCheck = string(progress.StageHealthCheck)
+	StagePostHook    = string(progress.StagePostHook)
+	StageDependents  = string(progress.StageDependents)
+	StageCleanup     = string(progress.StageCleanup)
+	StageCompleted   = string(progress.StageCompleted)
+	StageFailed      = string(progress.StageFailed)
+	StageRollback    = string(progress.StageRollback)
 )
 
 // ExtractedConfig holds the extracted container configuration for recreation.
@@ -89,14 +166,15 @@ type ExtractedConfig struct {
 	ContainerName    string
 }
 
-// DependentContainer holds info about a container that depends on another
-// via network_mode: container:X
+// DependentContainer holds info about a container that must be recreated
+// along with another, e.g. via network_mode: container:X
 type DependentContainer struct {
 	Container      types.ContainerJSON
 	Name           string
 	ID             string
 	Image          string
 	OldNetworkMode string
+	Dependency     string // DependencyNetworkMode, DependencyLabel or DependencyCompose
 }
 
 // ProgressCallback is called during update to report progress.
@@ -111,8 +189,17 @@ type UpdaterOptions struct {
 	OnProgress ProgressCallback
 	// OnPullProgress is called for detailed pull layer progress
 	OnPullProgress PullProgressCallback
+	// OnGroupProgress is called for group update progress (UpdateGroup only)
+	OnGroupProgress GroupProgressCallback
 	// IsPodman indicates if the Docker daemon is actually Podman
 	IsPodman bool
+	// IsWindows indicates the daemon runs Windows containers (Info.OSType)
+	IsWindows bool
 	// SupportsNetworkingConfig indicates if API >= 1.44 (can set network at creation)
 	SupportsNetworkingConfig bool
+	// MinFreeSpace is the headroom (bytes) the disk space preflight keeps on
+	// top of the new image. 0 uses DefaultMinFreeSpace; negative disables it.
+	MinFreeSpace int64
+	// PreflightImage runs df for the preflight (default DefaultPreflightImage)
+	PreflightImage string
 }
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	sharedDocker "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
@@ -45,19 +46,66 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		"new_image":    newImage,
 	}).Info("Starting container update")
 
-	// Default timeouts
-	if req.StopTimeout == 0 {
+	// Default timeouts. An explicit stop timeout wins over the container's
+	// own StopTimeout; the default doesn't.
+	if req.StopTimeout > 0 {
+		req.StopStrategy = req.StopStrategy.WithTimeout(req.StopTimeout)
+	} else {
 		req.StopTimeout = 30
 	}
 	if req.HealthTimeout == 0 {
 		req.HealthTimeout = 120
 	}
 
-	// Step 1: Pull new image with layer progress
-	u.sendProgress(StagePulling, fmt.Sprintf("Pulling image %s", newImage))
+	if req.Healthcheck != nil {
+		if err := req.Healthcheck.Validate(); err != nil {
+			return u.failResult(containerID, StageConfiguring, err)
+		}
+	}
+	if req.Hooks != nil {
+		if err := req.Hooks.Validate(); err != nil {
+			return u.failResult(containerID, StageConfiguring, err)
+		}
+	}
+	if req.AdoptInto != nil {
+		if err := req.AdoptInto.Validate(); err != nil {
+			return u.failResult(containerID, StageConfiguring, err)
+		}
+	}
+	if err := validateHealthOptions(req); err != nil {
+		return u.failResult(containerID, StageConfiguring, err)
+	}
+	if err := validateForensicsOptions(req); err != nil {
+		return u.failResult(containerID, StageConfiguring, err)
+	}
+	if err := req.StopStrategy.Validate(); err != nil {
+		return u.failResult(containerID, StageConfiguring, err)
+	}
 
-	if err := u.pullImageWithProgress(ctx, req); err != nil {
-		return u.failResult(containerID, StagePulling, err)
+	// Opt-out labels are checked before anything is pulled or stopped
+	if newImage != "" && !req.Force {
+		current, err := u.cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return u.failResult(containerID, StagePreflight, fmt.Errorf("failed to inspect container: %w", err))
+		}
+		if err := checkUpdateLabels(current.Config.Labels, newImage); err != nil {
+			return u.failResult(containerID, StagePreflight, err)
+		}
+	}
+
+	// Step 1: Pull new image with layer progress (skipped for in-place recreate).
+	// Check disk space first so a full disk fails before anything is touched.
+	if newImage != "" {
+		u.sendProgress(StagePreflight, "Checking disk space")
+		if err := u.preflightDiskSpace(ctx, req, containerID); err != nil {
+			return u.failResult(containerID, StagePreflight, err)
+		}
+
+		u.sendProgress(StagePulling, fmt.Sprintf("Pulling image %s", newImage))
+
+		if err := u.pullImageWithProgress(ctx, req); err != nil {
+			return u.failResult(containerID, StagePulling, err)
+		}
 	}
 
 	// Step 2: Inspect container to get configuration
@@ -67,6 +115,21 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		return u.failResult(containerID, StageConfiguring, fmt.Errorf("failed to inspect container: %w", err))
 	}
 
+	// In-place recreate keeps the image the container runs, by ID if its tag
+	// has since moved to another image
+	imageRef := newImage
+	if newImage == "" {
+		imageRef = oldContainer.Config.Image
+		newImage = inPlaceImage(ctx, u.cli, &oldContainer)
+		u.log.Infof("Recreating container with current image %s", newImage)
+	}
+
+	if req.AdoptInto != nil {
+		if err := req.AdoptInto.checkAdoptable(ctx, u.cli, &oldContainer); err != nil {
+			return u.failResult(containerID, StagePreflight, err)
+		}
+	}
+
 	// Capture original running state to restore after update
 	// See: https://github.com/darthnorse/dockmon/issues/90
 	wasRunning := oldContainer.State.Running
@@ -91,6 +154,12 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		u.log.WithError(err).Warn("Failed to get old image env, continuing without env filtering")
 	}
 
+	// A Linux image can't replace a Windows container (or vice versa); fail
+	// before anything is stopped
+	if err := checkImagePlatform(ctx, u.cli, newImage, oldContainer.Platform); err != nil {
+		return u.failResult(containerID, StageConfiguring, err)
+	}
+
 	// Step 4: Find dependent containers BEFORE we stop the parent
 	containerName := strings.TrimPrefix(oldContainer.Name, "/")
 	dependentContainers, err := FindDependentContainers(ctx, u.cli, u.log, &oldContainer, containerName, containerID)
@@ -98,19 +167,60 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		u.log.WithError(err).Warn("Failed to find dependent containers, continuing")
 	}
 	if len(dependentContainers) > 0 {
-		u.log.Infof("Found %d dependent container(s) using network_mode: container:%s",
+		u.log.Infof("Found %d dependent container(s) of %s",
 			len(dependentContainers), containerName)
 	}
 
 	// Step 5: Extract and transform config using struct copy
-	extractedConfig, err := ExtractConfig(ctx, u.cli, u.log, &oldContainer, newImage, oldImageLabels, newImageLabels, oldImageEnv, u.options.IsPodman)
+	extractedConfig, err := ExtractConfig(ctx, u.cli, u.log, &oldContainer, newImage, oldImageLabels, newImageLabels, oldImageEnv, u.options.IsPodman, u.options.IsWindows)
 	if err != nil {
 		return u.failResult(containerID, StageConfiguring, err)
 	}
 
+	// Step 5a: Record provenance. The old image is resolved by ID since the
+	// pull may have retargeted its tag; the new one by the ref we'll create from.
+	previousDigest := resolveImageDigest(ctx, u.cli, oldContainer.Image, oldContainer.Config.Image)
+	if previousDigest == "" {
+		previousDigest = oldContainer.Config.Labels[LabelImageDigest]
+	}
+	imageDigest := resolveImageDigest(ctx, u.cli, newImage, imageRef)
+	if imageDigest == "" {
+		u.log.Warnf("Could not resolve digest for %s, provenance labels not recorded", newImage)
+	}
+	extractedConfig.Config.Labels = applyProvenanceLabels(extractedConfig.Config.Labels, imageDigest, previousDigest)
+
+	if req.Healthcheck != nil {
+		extractedConfig.Config.Healthcheck = req.Healthcheck.toHealthConfig()
+		u.log.WithField("test", req.Healthcheck.Test).Info("Applying healthcheck override")
+	}
+	if req.AdoptInto != nil {
+		extractedConfig.Config.Labels = req.AdoptInto.apply(extractedConfig.Config.Labels)
+		u.log.WithFields(logrus.Fields{
+			"project": req.AdoptInto.Project,
+			"service": req.AdoptInto.Service,
+		}).Info("Adopting container into compose stack")
+	}
+
+	// Step 5b: Fail before stopping anything if another container has taken
+	// one of the host ports the new container publishes
+	if err := u.checkPortConflicts(ctx, containerID, extractedConfig.HostConfig); err != nil {
+		return u.failResult(containerID, StagePreflight, err)
+	}
+
+	// Step 5c: Pre-update hooks run against the still-running old container
+	if req.Hooks != nil && len(req.Hooks.PreUpdate) > 0 {
+		if err := u.runHooks(ctx, StagePreHook, req.Hooks.PreUpdate, containerID); err != nil {
+			return u.failResult(containerID, StagePreHook, err)
+		}
+	}
+
+	// Step 5d: Failed containers kept from earlier updates go once their
+	// quarantine is up
+	RemoveExpiredQuarantines(ctx, u.cli, u.log, time.Now())
+
 	// Step 6: Create backup (stop + rename)
 	u.sendProgress(StageBackup, "Stopping container and creating backup")
-	backupName, err := CreateBackup(ctx, u.cli, u.log, containerID, containerName, req.StopTimeout)
+	backupName, err := CreateBackup(ctx, u.cli, u.log, containerID, containerName, req.StopStrategy, req.StopTimeout)
 	if err != nil {
 		return u.failResult(containerID, StageBackup, err)
 	}
@@ -183,22 +293,34 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 	}
 
 	// Step 9: Health check
-	u.sendProgress(StageHealthCheck, "Waiting for container to be healthy")
-	if err := WaitForHealthy(ctx, u.cli, u.log, newContainerID, req.HealthTimeout); err != nil {
+	if req.SkipHealthCheck {
+		u.sendProgress(StageHealthCheck, "Health check skipped")
+	} else if err := u.waitForHealthy(ctx, req, newContainerID); err != nil {
 		u.log.WithError(err).Warn("Health check failed, rolling back")
-		stopTimeout := req.StopTimeout
-		u.cli.ContainerStop(ctx, newContainerID, container.StopOptions{Timeout: &stopTimeout})
-		u.cli.ContainerRemove(ctx, newContainerID, container.RemoveOptions{Force: true})
+		forensics := u.discardFailed(ctx, req, newContainerID, containerName)
 		restoreErr := RestoreBackup(ctx, u.cli, u.log, backupName, containerName, wasRunning)
-		return u.failResultRolledBack(containerID, StageHealthCheck, fmt.Errorf("health check failed: %w", err), restoreErr)
+		result := u.failResultRolledBack(containerID, StageHealthCheck, fmt.Errorf("health check failed: %w", err), restoreErr)
+		result.Failure = forensics
+		return result
+	}
+
+	// Step 9b: Post-update hooks (e.g. migrations) against the healthy new container
+	if req.Hooks != nil && len(req.Hooks.PostUpdate) > 0 {
+		if err := u.runHooks(ctx, StagePostHook, req.Hooks.PostUpdate, newContainerID); err != nil {
+			u.log.WithError(err).Warn("Post-update hook failed, rolling back")
+			forensics := u.discardFailed(ctx, req, newContainerID, containerName)
+			restoreErr := RestoreBackup(ctx, u.cli, u.log, backupName, containerName, wasRunning)
+			result := u.failResultRolledBack(containerID, StagePostHook, err, restoreErr)
+			result.Failure = forensics
+			return result
+		}
 	}
 
 	// Step 10: Restore original stopped state if container wasn't running before update
 	// This ensures stopped containers remain stopped after update (Issue #90)
 	if !wasRunning {
 		u.log.Info("Container was stopped before update, restoring stopped state")
-		stopTimeout := req.StopTimeout
-		if err := u.cli.ContainerStop(ctx, newContainerID, container.StopOptions{Timeout: &stopTimeout}); err != nil {
+		if err := u.stopContainer(ctx, newContainerID, req); err != nil {
 			u.log.WithError(err).Warn("Failed to stop container after update (was originally stopped)")
 			// Continue anyway - update succeeded, just state restoration failed
 		}
@@ -210,24 +332,32 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		u.sendProgress(StageDependents,
 			fmt.Sprintf("Recreating %d dependent container(s)", len(dependentContainers)))
 
-		failedDeps = RecreateDependentContainers(ctx, u.cli, u.log, dependentContainers, newContainerID, req.StopTimeout, u.options.IsPodman)
+		failedDeps = RecreateDependentContainers(ctx, u.cli, u.log, dependentContainers, newContainerID, req.StopTimeout, u.options.IsPodman, u.options.IsWindows)
 		if len(failedDeps) > 0 {
 			u.log.Warnf("Failed to recreate dependent containers: %v", failedDeps)
 			// Note: We continue despite failures - main container update succeeded
 		}
 	}
 
-	// Step 12: Cleanup backup (success path)
-	u.sendProgress(StageCleanup, "Removing backup container")
-	RemoveBackup(ctx, u.cli, u.log, backupName)
+	// Step 12: Cleanup backup (success path). Group updates keep it until
+	// every member has succeeded so the whole group can be rolled back.
+	if !req.KeepBackup {
+		u.sendProgress(StageCleanup, "Removing backup container")
+		RemoveBackup(ctx, u.cli, u.log, backupName)
+	}
 
 	// Success!
 	result := &UpdateResult{
-		Success:          true,
-		OldContainerID:   truncateID(containerID),
-		NewContainerID:   truncateID(newContainerID),
-		ContainerName:    containerName,
-		FailedDependents: failedDeps,
+		Success:             true,
+		OldContainerID:      truncateID(containerID),
+		NewContainerID:      truncateID(newContainerID),
+		ContainerName:       containerName,
+		FailedDependents:    failedDeps,
+		ImageDigest:         imageDigest,
+		PreviousImageDigest: previousDigest,
+		backupName:          backupName,
+		newContainerFull:    newContainerID,
+		wasRunning:          wasRunning,
 	}
 
 	u.sendProgress(StageCompleted, fmt.Sprintf("Update complete, new container: %s", truncateID(newContainerID)))
@@ -236,6 +366,7 @@ func (u *Updater) Update(ctx context.Context, req UpdateRequest) *UpdateResult {
 		"old_container": truncateID(containerID),
 		"new_container": truncateID(newContainerID),
 		"name":          containerName,
+		"image_digest":  imageDigest,
 	}).Info("Container update completed successfully")
 
 	return result
@@ -483,6 +614,11 @@ func sortLayersByPriority(layers []*LayerProgress) {
 	}
 }
 
+// stopContainer stops a container with the request's stop strategy
+func (u *Updater) stopContainer(ctx context.Context, containerID string, req UpdateRequest) error {
+	return sharedDocker.StopContainer(ctx, u.cli, containerID, req.StopStrategy, req.StopTimeout)
+}
+
 // sendProgress sends a progress event if callback is registered.
 func (u *Updater) sendProgress(stage, message string) {
 	if u.options.OnProgress != nil {
@@ -493,6 +629,17 @@ func (u *Updater) sendProgress(stage, message string) {
 	}
 }
 
+// sendProgressWithOutput sends a progress event carrying captured command output.
+func (u *Updater) sendProgressWithOutput(stage, message, output string) {
+	if u.options.OnProgress != nil {
+		u.options.OnProgress(ProgressEvent{
+			Stage:   stage,
+			Message: message,
+			Output:  output,
+		})
+	}
+}
+
 // failResult creates a failed result.
 func (u *Updater) failResult(containerID, stage string, err error) *UpdateResult {
 	u.sendProgress(StageFailed, err.Error())
@@ -0,0 +1,100 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// osWindows is the OSType/Platform reported for Windows containers
+	osWindows = "windows"
+	// windowsDefaultNetwork is the Windows counterpart of the "bridge" network
+	windowsDefaultNetwork = "nat"
+)
+
+// builtinNetworks returns the daemon-managed networks that are recreated
+// implicitly and must not be reconnected by name.
+func builtinNetworks(isWindows bool) map[string]bool {
+	if isWindows {
+		return map[string]bool{windowsDefaultNetwork: true, "none": true}
+	}
+	return map[string]bool{"bridge": true, "host": true, "none": true}
+}
+
+// defaultNetwork returns the network a container joins when NetworkMode is
+// empty or "default".
+func defaultNetwork(isWindows bool) string {
+	if isWindows {
+		return windowsDefaultNetwork
+	}
+	return "bridge"
+}
+
+// applyWindowsFixes clears Linux-only settings from HostConfig. Windows
+// daemons reject several of these outright, and the rest are meaningless
+// without cgroups.
+func applyWindowsFixes(log *logrus.Logger, hostConfig *container.HostConfig) {
+	r := &hostConfig.Resources
+
+	r.CPURealtimePeriod = 0
+	r.CPURealtimeRuntime = 0
+	r.CpusetCpus = ""
+	r.CpusetMems = ""
+	r.KernelMemory = 0
+	r.MemoryReservation = 0
+	r.MemorySwap = 0
+	r.MemorySwappiness = nil
+	r.OomKillDisable = nil
+	r.PidsLimit = nil
+	r.BlkioWeight = 0
+	r.BlkioWeightDevice = nil
+	r.BlkioDeviceReadBps = nil
+	r.BlkioDeviceWriteBps = nil
+	r.BlkioDeviceReadIOps = nil
+	r.BlkioDeviceWriteIOps = nil
+	r.CgroupParent = ""
+
+	hostConfig.CgroupnsMode = ""
+	hostConfig.ShmSize = 0
+
+	log.Debug("Cleared Linux-only HostConfig settings for Windows container")
+}
+
+// validateWindowsHostConfig rejects configurations the Windows daemon can't
+// recreate, before the original container is stopped.
+func validateWindowsHostConfig(hostConfig *container.HostConfig) error {
+	if hostConfig.NetworkMode.IsContainer() && hostConfig.Isolation.IsHyperV() {
+		return fmt.Errorf("network_mode %s is not supported for Hyper-V isolated Windows containers", hostConfig.NetworkMode)
+	}
+	if hostConfig.NetworkMode.IsHost() {
+		return fmt.Errorf("network_mode host is not supported for Windows containers")
+	}
+	return nil
+}
+
+// checkImagePlatform fails when the image can't run on the container's
+// platform, e.g. a Linux tag pulled for a Windows container. Unknown
+// platforms are allowed through.
+func checkImagePlatform(ctx context.Context, cli *client.Client, imageRef, containerPlatform string) error {
+	img, _, err := cli.ImageInspectWithRaw(ctx, imageRef)
+	if err != nil {
+		return nil // Reported by the create step if the image is really missing
+	}
+	return imagePlatformError(imageRef, img.Os, containerPlatform)
+}
+
+// imagePlatformError is the testable core of checkImagePlatform.
+func imagePlatformError(imageRef, imageOS, containerPlatform string) error {
+	if imageOS == "" || containerPlatform == "" {
+		return nil
+	}
+	if !strings.EqualFold(imageOS, containerPlatform) {
+		return fmt.Errorf("image %s is built for %s but the container runs on %s", imageRef, imageOS, containerPlatform)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package update
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/sirupsen/logrus"
+)
+
+func TestApplyWindowsFixes_ClearsLinuxOnlySettings(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	swappiness := int64(60)
+	pids := int64(100)
+	hostConfig := &container.HostConfig{
+		CgroupnsMode: "private",
+		ShmSize:      64 << 20,
+		Isolation:    "hyperv",
+		Resources: container.Resources{
+			NanoCPUs:         2000000000,
+			Memory:           512 << 20,
+			MemorySwappiness: &swappiness,
+			PidsLimit:        &pids,
+			CpusetCpus:       "0-1",
+			BlkioWeight:      500,
+		},
+	}
+
+	applyWindowsFixes(log, hostConfig)
+
+	if hostConfig.MemorySwappiness != nil || hostConfig.PidsLimit != nil {
+		t.Error("Expected MemorySwappiness and PidsLimit to be cleared")
+	}
+	if hostConfig.CpusetCpus != "" || hostConfig.BlkioWeight != 0 {
+		t.Error("Expected CpusetCpus and BlkioWeight to be cleared")
+	}
+	if hostConfig.CgroupnsMode != "" || hostConfig.ShmSize != 0 {
+		t.Error("Expected CgroupnsMode and ShmSize to be cleared")
+	}
+
+	// Settings Windows supports are preserved
+	if hostConfig.NanoCPUs != 2000000000 || hostConfig.Memory != 512<<20 {
+		t.Error("Expected NanoCPUs and Memory to be preserved")
+	}
+	if hostConfig.Isolation != "hyperv" {
+		t.Errorf("Expected Isolation=hyperv, got %q", hostConfig.Isolation)
+	}
+}
+
+func TestValidateWindowsHostConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		networkMode container.NetworkMode
+		isolation   container.Isolation
+		wantErr     bool
+	}{
+		{"nat", "nat", "process", false},
+		{"shared network, process isolation", "container:sidecar", "process", false},
+		{"shared network, hyper-v isolation", "container:sidecar", "hyperv", true},
+		{"host network", "host", "process", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWindowsHostConfig(&container.HostConfig{
+				NetworkMode: tt.networkMode,
+				Isolation:   tt.isolation,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateWindowsHostConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractNetworkConfig_WindowsNatIsBuiltin(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	inspect := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{
+				NetworkMode: "default",
+			},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"nat": {},
+			},
+		},
+	}
+
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, true)
+
+	if primaryNet != nil || additionalNets != nil {
+		t.Error("Expected nat network to be treated as the built-in default")
+	}
+}
+
+func TestExtractNetworkConfig_WindowsTransparentNetwork(t *testing.T) {
+	log := logrus.New()
+	log.SetLevel(logrus.ErrorLevel)
+
+	inspect := &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			HostConfig: &container.HostConfig{
+				NetworkMode: "lan",
+			},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"lan": {IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "192.168.1.50"}},
+				"nat": {},
+			},
+		},
+	}
+
+	primaryNet, additionalNets := extractNetworkConfig(log, inspect, true)
+
+	if primaryNet == nil || primaryNet.EndpointsConfig["lan"] == nil {
+		t.Fatal("Expected lan as the primary network with its static IP")
+	}
+	if additionalNets != nil {
+		t.Errorf("Expected no additional networks, got %v", additionalNets)
+	}
+}
+
+func TestImagePlatformError(t *testing.T) {
+	if err := imagePlatformError("app:2", "windows", "windows"); err != nil {
+		t.Errorf("Matching platform should pass, got %v", err)
+	}
+	if err := imagePlatformError("app:2", "", "windows"); err != nil {
+		t.Errorf("Unknown image platform should pass, got %v", err)
+	}
+
+	err := imagePlatformError("app:2", "linux", "windows")
+	if err == nil || !strings.Contains(err.Error(), "built for linux") {
+		t.Errorf("Expected platform mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package updatecheck
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentLookups bounds parallel registry lookups so a host with many
+// images doesn't trip registry rate limits in one burst
+const maxConcurrentLookups = 4
+
+// Where a container's policy came from
+const (
+	PolicySourceRequest = "request"
+	PolicySourceLabel   = "label"
+	PolicySourceDefault = "default"
+)
+
+// Options configures a check.
+type Options struct {
+	// Policies overrides the policy per container, keyed by container ID,
+	// short ID or name. Containers not listed use their PolicyLabel.
+	Policies map[string]Policy
+	// Auth holds registry credentials keyed by registry host as written in
+	// image references ("docker.io" for Docker Hub, "ghcr.io", ...).
+	Auth map[string]registry.Credentials
+	// Platform selects the manifest compared for multi-arch images
+	Platform registry.Platform
+}
+
+// Result reports one container's update eligibility.
+type Result struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name"`
+	Image         string `json:"image"`
+	Policy        Policy `json:"policy"`
+	PolicySource  string `json:"policy_source"`
+	CurrentTag    string `json:"current_tag,omitempty"`
+	LocalDigest   string `json:"local_digest,omitempty"`
+	RemoteDigest  string `json:"remote_digest,omitempty"`
+	LatestVersion string `json:"latest_version,omitempty"` // Newest release regardless of policy
+	TargetImage   string `json:"target_image,omitempty"`   // Image to update to when an update is eligible
+	// UpdateLabels is set when the container's opt-out labels restrict
+	// updates; the update engine refuses updates outside them unless forced
+	UpdateLabels *update.LabelPolicy `json:"update_labels,omitempty"`
+	Decision
+	Error string `json:"error,omitempty"`
+}
+
+// remoteImage is what the registry returned for one image reference
+type remoteImage struct {
+	digest string
+	tags   []string
+	err    error
+}
+
+// Checker evaluates containers' update policies against their registries.
+type Checker struct {
+	cli *client.Client
+	log *logrus.Logger
+}
+
+// NewChecker creates a new Checker.
+func NewChecker(cli *client.Client, log *logrus.Logger) *Checker {
+	return &Checker{
+		cli: cli,
+		log: log,
+	}
+}
+
+// Check evaluates every container on the host, running or not. Containers
+// created from a bare image ID are skipped since there is no registry to ask.
+// Registry failures are reported per container in Result.Error.
+func (c *Checker) Check(ctx context.Context, opts Options) ([]Result, error) {
+	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	var imageIDs []string             // parallel to results
+	needTags := make(map[string]bool) // image ref -> some container follows versions
+	for _, ctr := range containers {
+		if ctr.Image == "" || strings.HasPrefix(ctr.Image, "sha256:") {
+			continue
+		}
+		name := ""
+		if len(ctr.Names) > 0 {
+			name = strings.TrimPrefix(ctr.Names[0], "/")
+		}
+		result := Result{
+			ContainerID:   ctr.ID,
+			ContainerName: name,
+			Image:         ctr.Image,
+			CurrentTag:    registry.Tag(ctr.Image),
+		}
+		policy, source, err := resolvePolicy(opts.Policies, ctr.ID, name, ctr.Labels)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			imageIDs = append(imageIDs, ctr.ImageID)
+			continue
+		}
+		result.Policy, result.PolicySource = policy, source
+		labels, err := update.ParseLabelPolicy(ctr.Labels)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			imageIDs = append(imageIDs, ctr.ImageID)
+			continue
+		}
+		if !labels.IsZero() {
+			result.UpdateLabels = &labels
+		}
+		if policy != PolicyPin && !labels.Never {
+			needTags[ctr.Image] = needTags[ctr.Image] || (policy.followsVersions() && registry.IsVersionTag(result.CurrentTag))
+		}
+		results = append(results, result)
+		imageIDs = append(imageIDs, ctr.ImageID)
+	}
+
+	remotes := c.lookupRemotes(ctx, needTags, opts)
+	localDigests := make(map[string]string) // image ID -> repo digest for that image ref
+
+	for i := range results {
+		r := &results[i]
+		if r.Error != "" {
+			continue
+		}
+		if r.Policy == PolicyPin {
+			r.Decision = Decision{Reason: ReasonPinned}
+			continue
+		}
+		if r.UpdateLabels != nil && r.UpdateLabels.Never {
+			r.Decision = Decision{Reason: ReasonOptedOut}
+			continue
+		}
+
+		key := imageIDs[i] + "|" + r.Image
+		digest, ok := localDigests[key]
+		if !ok {
+			if img, err := c.cli.ImageInspect(ctx, imageIDs[i]); err == nil {
+				digest = registry.LocalDigest(img.RepoDigests, r.Image)
+			}
+			localDigests[key] = digest
+		}
+		r.LocalDigest = digest
+
+		remote := remotes[r.Image]
+		if remote.err != nil {
+			r.Error = remote.err.Error()
+			r.Decision = Decision{Reason: ReasonRegistryFailed}
+			continue
+		}
+		r.RemoteDigest = remote.digest
+		r.LatestVersion = registry.LatestVersion(r.CurrentTag, remote.tags)
+		r.Decision = evaluateWithLabels(r.Policy, r.UpdateLabels, r.CurrentTag, r.LocalDigest, r.RemoteDigest, remote.tags)
+		if r.UpdateAvailable {
+			if r.TargetImage, err = WithTag(r.Image, r.TargetTag); err != nil {
+				r.Error = err.Error()
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ContainerName < results[j].ContainerName })
+	c.log.WithField("containers", len(results)).Debug("Container update policy check completed")
+	return results, nil
+}
+
+// evaluateWithLabels is Evaluate restricted to the tags a
+// com.dockmon.update.pin label allows, so the newest release within the pin
+// is picked
+func evaluateWithLabels(policy Policy, labels *update.LabelPolicy, currentTag, localDigest, remoteDigest string, tags []string) Decision {
+	if labels == nil || labels.Pin == "" {
+		return Evaluate(policy, currentTag, localDigest, remoteDigest, tags)
+	}
+	allowed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if update.MatchesPin(labels.Pin, tag) {
+			allowed = append(allowed, tag)
+		}
+	}
+	d := Evaluate(policy, currentTag, localDigest, remoteDigest, allowed)
+	if d.UpdateAvailable && !update.MatchesPin(labels.Pin, d.TargetTag) {
+		return Decision{Reason: ReasonOutsidePin}
+	}
+	return d
+}
+
+// lookupRemotes resolves each image reference once, listing tags only for
+// images with a container that may move to another release
+func (c *Checker) lookupRemotes(ctx context.Context, images map[string]bool, opts Options) map[string]remoteImage {
+	var mu sync.Mutex
+	remotes := make(map[string]remoteImage, len(images))
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentLookups)
+	for ref, withTags := range images {
+		g.Go(func() error {
+			rc := registry.NewClient(credentialsFor(opts.Auth, ref))
+			var remote remoteImage
+			if info, err := rc.Inspect(gctx, ref, opts.Platform); err != nil {
+				remote.err = err
+			} else {
+				remote.digest = info.Digest
+			}
+			if remote.err == nil && withTags {
+				tags, err := rc.ListTags(gctx, ref)
+				if err != nil {
+					// The digest is still usable for same-tag updates
+					c.log.WithError(err).WithField("image", ref).Debug("Failed to list image tags")
+				}
+				remote.tags = tags
+			}
+			mu.Lock()
+			remotes[ref] = remote
+			mu.Unlock()
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return remotes
+}
+
+// resolvePolicy picks a container's policy: an explicit override by ID,
+// short ID or name, then its PolicyLabel, then PolicyDigest.
+func resolvePolicy(overrides map[string]Policy, id, name string, labels map[string]string) (Policy, string, error) {
+	keys := []string{id, name}
+	if len(id) > 12 {
+		keys = append(keys, id[:12])
+	}
+	for _, key := range keys {
+		if p, ok := overrides[key]; ok && key != "" {
+			policy, err := ParsePolicy(string(p))
+			return policy, PolicySourceRequest, err
+		}
+	}
+	if v, ok := labels[PolicyLabel]; ok {
+		policy, err := ParsePolicy(v)
+		return policy, PolicySourceLabel, err
+	}
+	return PolicyDigest, PolicySourceDefault, nil
+}
+
+// credentialsFor returns the credentials for imageRef's registry, if any
+func credentialsFor(auth map[string]registry.Credentials, imageRef string) *registry.Credentials {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return nil
+	}
+	if creds, ok := auth[reference.Domain(named)]; ok {
+		return &creds
+	}
+	return nil
+}
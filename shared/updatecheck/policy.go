@@ -0,0 +1,128 @@
+// Package updatecheck decides which containers have an update they are
+// allowed to take. Each container carries an update policy (follow patch
+// releases, follow minor releases, pin the current digest, or follow the
+// newest release) and a check resolves its image against the registry and
+// reports only updates eligible under that policy, instead of flagging any
+// digest difference.
+//
+// It is used by the agent's check_container_updates command.
+package updatecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/darthnorse/dockmon-shared/update"
+	"github.com/distribution/reference"
+)
+
+// PolicyLabel is the container label that sets a container's update policy
+// when the caller doesn't supply one.
+const PolicyLabel = update.LabelUpdatePolicy
+
+// Policy controls which remote changes count as an update for a container.
+type Policy string
+
+const (
+	// PolicyDigest only follows new pushes of the container's current tag.
+	// It is the default and matches a plain digest comparison.
+	PolicyDigest Policy = "digest"
+	// PolicyPatch follows the current tag plus newer X.Y.* releases.
+	PolicyPatch Policy = "patch"
+	// PolicyMinor follows the current tag plus newer X.*.* releases.
+	PolicyMinor Policy = "minor"
+	// PolicyLatest follows the current tag plus any newer release of the
+	// same shape, including major versions.
+	PolicyLatest Policy = "latest"
+	// PolicyPin never reports an update; the running digest is kept.
+	PolicyPin Policy = "pin"
+)
+
+// ParsePolicy validates a policy name; "" yields PolicyDigest.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyDigest, nil
+	case PolicyDigest, PolicyPatch, PolicyMinor, PolicyLatest, PolicyPin:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown update policy %q (want digest, patch, minor, latest or pin)", s)
+	}
+}
+
+// keep returns how many leading version components the policy holds fixed,
+// and false when the policy never moves to another tag.
+func (p Policy) keep() (int, bool) {
+	switch p {
+	case PolicyPatch:
+		return 2, true
+	case PolicyMinor:
+		return 1, true
+	case PolicyLatest:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// followsVersions reports whether the policy needs the repository's tags.
+func (p Policy) followsVersions() bool {
+	_, ok := p.keep()
+	return ok
+}
+
+// Reasons reported in Decision.Reason
+const (
+	ReasonPinned         = "pinned"
+	ReasonNewerVersion   = "newer_version"
+	ReasonDigestChanged  = "digest_changed"
+	ReasonUpToDate       = "up_to_date"
+	ReasonNoLocalDigest  = "no_local_digest"
+	ReasonRegistryFailed = "registry_error"
+	ReasonOptedOut       = "opted_out"   // Labels refuse updates, e.g. watchtower.enable=false
+	ReasonOutsidePin     = "outside_pin" // Update exists but not within com.dockmon.update.pin
+)
+
+// Decision is the outcome of evaluating one container against its policy.
+type Decision struct {
+	UpdateAvailable bool   `json:"update_available"`
+	TargetTag       string `json:"target_tag,omitempty"` // Tag to update to; the current tag for digest changes
+	Reason          string `json:"reason"`
+}
+
+// Evaluate applies policy to a container running currentTag at localDigest
+// when the registry serves remoteDigest for that tag and lists tags. A newer
+// release allowed by the policy wins over a re-push of the current tag.
+func Evaluate(policy Policy, currentTag, localDigest, remoteDigest string, tags []string) Decision {
+	if policy == PolicyPin {
+		return Decision{Reason: ReasonPinned}
+	}
+	if keep, ok := policy.keep(); ok {
+		if v := registry.LatestVersionWithin(currentTag, tags, keep); v != "" {
+			return Decision{UpdateAvailable: true, TargetTag: v, Reason: ReasonNewerVersion}
+		}
+	}
+	// Images built locally have no repo digest to compare against
+	if localDigest == "" {
+		return Decision{Reason: ReasonNoLocalDigest}
+	}
+	if remoteDigest != "" && localDigest != remoteDigest {
+		return Decision{UpdateAvailable: true, TargetTag: currentTag, Reason: ReasonDigestChanged}
+	}
+	return Decision{Reason: ReasonUpToDate}
+}
+
+// WithTag returns imageRef retagged to tag, e.g. ("nginx:1.26.1", "1.26.2")
+// gives "nginx:1.26.2".
+func WithTag(imageRef, tag string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return "", err
+	}
+	tagged, err := reference.WithTag(reference.TrimNamed(named), tag)
+	if err != nil {
+		return "", err
+	}
+	return reference.FamiliarString(tagged), nil
+}
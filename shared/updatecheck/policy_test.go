@@ -0,0 +1,136 @@
+package updatecheck
+
+import (
+	"testing"
+
+	"github.com/darthnorse/dockmon-shared/registry"
+	"github.com/darthnorse/dockmon-shared/update"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := map[string]Policy{
+		"":        PolicyDigest,
+		"digest":  PolicyDigest,
+		" Minor ": PolicyMinor,
+		"patch":   PolicyPatch,
+		"latest":  PolicyLatest,
+		"pin":     PolicyPin,
+	}
+	for in, want := range tests {
+		got, err := ParsePolicy(in)
+		if err != nil || got != want {
+			t.Errorf("ParsePolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParsePolicy("major"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.4", "1.3.0", "2.0.0", "latest"}
+	const local, remote = "sha256:aaa", "sha256:bbb"
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		tag        string
+		local      string
+		remote     string
+		wantUpdate bool
+		wantTarget string
+		wantReason string
+	}{
+		{"patch moves within minor", PolicyPatch, "1.2.3", local, local, true, "1.2.4", ReasonNewerVersion},
+		{"minor moves within major", PolicyMinor, "1.2.3", local, local, true, "1.3.0", ReasonNewerVersion},
+		{"latest crosses majors", PolicyLatest, "1.2.3", local, local, true, "2.0.0", ReasonNewerVersion},
+		{"digest ignores releases", PolicyDigest, "1.2.3", local, local, false, "", ReasonUpToDate},
+		{"digest follows re-push", PolicyDigest, "1.2.3", local, remote, true, "1.2.3", ReasonDigestChanged},
+		{"pin ignores everything", PolicyPin, "1.2.3", local, remote, false, "", ReasonPinned},
+		{"patch falls back to digest", PolicyPatch, "2.0.0", local, remote, true, "2.0.0", ReasonDigestChanged},
+		{"latest tag uses digest", PolicyLatest, "latest", local, remote, true, "latest", ReasonDigestChanged},
+		{"locally built image", PolicyDigest, "latest", "", remote, false, "", ReasonNoLocalDigest},
+		{"up to date", PolicyMinor, "2.0.0", remote, remote, false, "", ReasonUpToDate},
+	}
+	for _, tt := range tests {
+		got := Evaluate(tt.policy, tt.tag, tt.local, tt.remote, tags)
+		if got.UpdateAvailable != tt.wantUpdate || got.TargetTag != tt.wantTarget || got.Reason != tt.wantReason {
+			t.Errorf("%s: got %+v", tt.name, got)
+		}
+	}
+}
+
+func TestEvaluateWithLabels(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.4", "1.3.0", "2.0.0"}
+	pin := &update.LabelPolicy{Pin: "1.2.x"}
+
+	d := evaluateWithLabels(PolicyLatest, pin, "1.2.3", "sha256:aaa", "sha256:aaa", tags)
+	if !d.UpdateAvailable || d.TargetTag != "1.2.4" {
+		t.Errorf("latest within 1.2.x = %+v, want update to 1.2.4", d)
+	}
+
+	d = evaluateWithLabels(PolicyLatest, pin, "1.3.0", "sha256:aaa", "sha256:bbb", tags)
+	if d.UpdateAvailable || d.Reason != ReasonOutsidePin {
+		t.Errorf("re-push of a tag outside the pin = %+v, want %s", d, ReasonOutsidePin)
+	}
+
+	d = evaluateWithLabels(PolicyLatest, nil, "1.2.3", "sha256:aaa", "sha256:aaa", tags)
+	if d.TargetTag != "2.0.0" {
+		t.Errorf("without labels = %+v, want 2.0.0", d)
+	}
+}
+
+func TestWithTag(t *testing.T) {
+	tests := map[string]string{
+		"nginx:1.26.1":                "nginx:1.26.2",
+		"nginx":                       "nginx:1.26.2",
+		"registry.local:5000/app:1.0": "registry.local:5000/app:1.26.2",
+	}
+	for in, want := range tests {
+		got, err := WithTag(in, "1.26.2")
+		if err != nil || got != want {
+			t.Errorf("WithTag(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestResolvePolicy(t *testing.T) {
+	const id = "0123456789abcdef0123"
+	labels := map[string]string{PolicyLabel: "minor"}
+
+	p, src, err := resolvePolicy(map[string]Policy{"web": PolicyPin}, id, "web", labels)
+	if err != nil || p != PolicyPin || src != PolicySourceRequest {
+		t.Errorf("override by name: %q %q %v", p, src, err)
+	}
+	p, src, _ = resolvePolicy(map[string]Policy{"0123456789ab": PolicyPatch}, id, "web", labels)
+	if p != PolicyPatch || src != PolicySourceRequest {
+		t.Errorf("override by short ID: %q %q", p, src)
+	}
+	p, src, _ = resolvePolicy(nil, id, "web", labels)
+	if p != PolicyMinor || src != PolicySourceLabel {
+		t.Errorf("label: %q %q", p, src)
+	}
+	p, src, _ = resolvePolicy(nil, id, "web", nil)
+	if p != PolicyDigest || src != PolicySourceDefault {
+		t.Errorf("default: %q %q", p, src)
+	}
+	if _, _, err := resolvePolicy(nil, id, "web", map[string]string{PolicyLabel: "sometimes"}); err == nil {
+		t.Error("expected error for invalid label")
+	}
+}
+
+func TestCredentialsFor(t *testing.T) {
+	auth := map[string]registry.Credentials{
+		"docker.io": {Username: "hub"},
+		"ghcr.io":   {Username: "gh"},
+	}
+	if c := credentialsFor(auth, "nginx:latest"); c == nil || c.Username != "hub" {
+		t.Errorf("docker hub: %+v", c)
+	}
+	if c := credentialsFor(auth, "ghcr.io/team/app:1.0"); c == nil || c.Username != "gh" {
+		t.Errorf("ghcr: %+v", c)
+	}
+	if c := credentialsFor(auth, "quay.io/team/app:1.0"); c != nil {
+		t.Errorf("unknown registry: %+v", c)
+	}
+}
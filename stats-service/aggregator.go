@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
@@ -21,8 +23,19 @@ type Aggregator struct {
 	cache             *StatsCache
 	streamManager     streamManagerIface
 	aggregateInterval time.Duration
+	intervals         intervalUpdates // Interval changes from reloads
 	hostProcReader    *HostProcReader
+	perCoreMode       string               // HOST_CPU_PER_CORE, one of the PerCore* modes
 	cascade           *persistence.Cascade // optional; nil disables persistence ingest
+
+	// counters keeps each host's network totals monotonic across container
+	// restarts. Only touched from the aggregation goroutine.
+	counters map[string]*hostCounters // key: hostID
+
+	// lastAggregate is when the previous pass ran; containers that stopped
+	// since then have their final sample ingested. Only touched from the
+	// aggregation goroutine.
+	lastAggregate time.Time
 }
 
 // NewAggregator creates a new aggregator
@@ -36,10 +49,51 @@ func NewAggregator(cache *StatsCache, streamManager *StreamManager, interval tim
 		cache:             cache,
 		streamManager:     streamManager,
 		aggregateInterval: interval,
+		intervals:         newIntervalUpdates(),
 		hostProcReader:    hostProcReader,
+		perCoreMode:       PerCoreAuto,
+	}
+}
+
+// Sources of the per-core CPU breakdown in HostStats (HOST_CPU_PER_CORE)
+const (
+	// PerCoreAuto uses /host/proc for the local host and container
+	// PercpuUsage for the others
+	PerCoreAuto = "auto"
+	// PerCoreProc only reports hosts whose /proc is mounted
+	PerCoreProc = "proc"
+	// PerCoreContainers sums the containers' PercpuUsage on every host.
+	// It misses work outside containers and needs cgroups v1.
+	PerCoreContainers = "containers"
+	// PerCoreOff reports no breakdown
+	PerCoreOff = "off"
+)
+
+// parsePerCoreMode validates a HOST_CPU_PER_CORE value; "" is PerCoreAuto
+func parsePerCoreMode(s string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case "":
+		return PerCoreAuto, nil
+	case PerCoreAuto, PerCoreProc, PerCoreContainers, PerCoreOff:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown per-core mode %q (want auto, proc, containers or off)", s)
 	}
 }
 
+// SetPerCoreMode sets where the per-core CPU breakdown comes from. Call
+// before Start.
+func (a *Aggregator) SetPerCoreMode(mode string) {
+	a.perCoreMode = mode
+	a.hostProcReader.SetPerCore(mode == PerCoreAuto || mode == PerCoreProc)
+}
+
+// SetInterval changes the aggregation interval, taking effect from the next
+// tick. Safe to call while the aggregator runs.
+func (a *Aggregator) SetInterval(d time.Duration) {
+	a.intervals.set(d)
+}
+
 // SetCascade enables persistence ingest. Pass nil to disable.
 //
 // Startup-ordering contract: callers MUST invoke SetCascade BEFORE
@@ -67,6 +121,10 @@ func (a *Aggregator) Start(ctx context.Context) {
 			return
 		case <-ticker.C:
 			a.aggregate()
+		case d := <-a.intervals:
+			a.aggregateInterval = d
+			ticker.Reset(d)
+			log.Printf("Aggregation interval changed to %v", d)
 		}
 	}
 }
@@ -74,13 +132,30 @@ func (a *Aggregator) Start(ctx context.Context) {
 // aggregate calculates host-level stats from container stats
 func (a *Aggregator) aggregate() {
 	containerStats := a.cache.GetAllContainerStats()
+	since := a.lastAggregate
+	a.lastAggregate = time.Now()
 
-	// Group containers by host
+	// Group running containers by host. Stopped containers only contribute
+	// their final sample to history, once.
 	hostContainers := make(map[string][]*ContainerStats)
+	var stopped []*ContainerStats
 	for _, stats := range containerStats {
+		if stats.Stopped {
+			if stats.StoppedAt != nil && stats.StoppedAt.After(since) {
+				stopped = append(stopped, stats)
+			}
+			continue
+		}
 		hostContainers[stats.HostID] = append(hostContainers[stats.HostID], stats)
 	}
 
+	// Drop counter state for hosts that no longer have any containers
+	for hostID := range a.counters {
+		if _, ok := hostContainers[hostID]; !ok {
+			delete(a.counters, hostID)
+		}
+	}
+
 	for hostID, containers := range hostContainers {
 		hostStats := a.aggregateHostStats(hostID, containers)
 
@@ -124,45 +199,51 @@ func (a *Aggregator) aggregate() {
 			}
 		}
 	}
+
+	// Final samples of containers that stopped since the last pass, so a
+	// short-lived container still leaves a point in its history
+	if a.cascade != nil && settingsProvider.PersistEnabled() {
+		now := time.Now()
+		for _, cs := range stopped {
+			compositeID := cs.HostID + ":" + cs.ContainerID
+			a.cascade.Ingest(compositeID, false, now, sampleFromContainerStats(cs))
+		}
+	}
 }
 
 // aggregateHostStats aggregates stats for a single host
 func (a *Aggregator) aggregateHostStats(hostID string, containers []*ContainerStats) *HostStats {
-	var (
-		totalNetRx      uint64
-		totalNetTx      uint64
-		validContainers int
-	)
-
-	const maxUint64 = ^uint64(0)
+	var validContainers int
 
 	// Only count containers updated in the last 30 seconds
-	cutoff := time.Now().Add(-30 * time.Second)
-
-	// Always aggregate container stats for network and container count
+	now := time.Now()
+	cutoff := now.Add(-30 * time.Second)
+
+	// Always aggregate container stats for network and container count.
+	// Container counters reset on restart, so the host totals are built
+	// from per-container deltas rather than summed directly.
+	rx := make(map[string]uint64, len(containers))
+	tx := make(map[string]uint64, len(containers))
 	for _, stats := range containers {
 		if stats.LastUpdate.Before(cutoff) {
 			continue // Skip stale stats
 		}
-
-		// Check for overflow before adding network bytes
-		if maxUint64-totalNetRx < stats.NetworkRx {
-			log.Printf("Warning: Network RX overflow prevented for host %s", truncateID(hostID, 8))
-			totalNetRx = maxUint64 // Cap at max instead of wrapping
-		} else {
-			totalNetRx += stats.NetworkRx
-		}
-
-		if maxUint64-totalNetTx < stats.NetworkTx {
-			log.Printf("Warning: Network TX overflow prevented for host %s", truncateID(hostID, 8))
-			totalNetTx = maxUint64
-		} else {
-			totalNetTx += stats.NetworkTx
-		}
-
+		rx[stats.ContainerID] = stats.NetworkRx
+		tx[stats.ContainerID] = stats.NetworkTx
 		validContainers++
 	}
 
+	if a.counters == nil {
+		a.counters = make(map[string]*hostCounters)
+	}
+	counters, ok := a.counters[hostID]
+	if !ok {
+		counters = &hostCounters{}
+		a.counters[hostID] = counters
+	}
+	quality := counters.observe(now, rx, tx)
+	totalNetRx, totalNetTx := counters.rx.total, counters.tx.total
+
 	// Check if we can use actual host stats from /host/proc (Issue #129)
 	// This provides accurate CPU/memory when /proc is mounted as /host/proc:ro
 	if a.cache.IsHostLocal(hostID) && a.hostProcReader.IsAvailable() {
@@ -171,16 +252,26 @@ func (a *Aggregator) aggregateHostStats(hostID string, containers []*ContainerSt
 			cpuPercent := dockerpkg.RoundToDecimal(hostProcStats.CPUPercent, 1)
 			memPercent := dockerpkg.RoundToDecimal(hostProcStats.MemoryPercent, 1)
 
-			return &HostStats{
-				HostID:           hostID,
-				CPUPercent:       cpuPercent,
-				MemoryPercent:    memPercent,
-				MemoryUsedBytes:  hostProcStats.MemoryUsedBytes,
-				MemoryLimitBytes: hostProcStats.MemoryTotalBytes,
-				NetworkRxBytes:   totalNetRx,
-				NetworkTxBytes:   totalNetTx,
-				ContainerCount:   validContainers,
+			stats := &HostStats{
+				HostID:             hostID,
+				CPUPercent:         cpuPercent,
+				MemoryPercent:      memPercent,
+				MemoryUsedBytes:    hostProcStats.MemoryUsedBytes,
+				MemoryLimitBytes:   hostProcStats.MemoryTotalBytes,
+				NetworkRxBytes:     totalNetRx,
+				NetworkTxBytes:     totalNetTx,
+				ContainerCount:     validContainers,
+				AggregationQuality: quality,
 			}
+			switch a.perCoreMode {
+			case PerCoreAuto, PerCoreProc:
+				if perCore := roundPerCore(hostProcStats.CPUPerCore); perCore != nil {
+					stats.CPUPerCore, stats.CPUPerCoreSource = perCore, PerCoreProc
+				}
+			case PerCoreContainers:
+				stats.CPUPerCore, stats.CPUPerCoreSource = a.containerPerCore(containers, cutoff)
+			}
+			return stats
 		}
 		// Fall through to container aggregation if /host/proc read failed
 	}
@@ -188,8 +279,9 @@ func (a *Aggregator) aggregateHostStats(hostID string, containers []*ContainerSt
 	// Fallback: Aggregate CPU/memory from container stats
 	if len(containers) == 0 {
 		return &HostStats{
-			HostID:         hostID,
-			ContainerCount: 0,
+			HostID:             hostID,
+			ContainerCount:     0,
+			AggregationQuality: quality,
 		}
 	}
 
@@ -215,9 +307,15 @@ func (a *Aggregator) aggregateHostStats(hostID string, containers []*ContainerSt
 	// For example, a container using 100% of one core on a 4-core system reports ~100%.
 	// To get accurate host CPU, we sum all container CPU and divide by number of CPUs.
 	// This gives us the percentage of total host CPU capacity being used.
-	numCPUs := a.cache.GetHostNumCPUs(hostID)
-	if numCPUs > 0 {
+	if numCPUs, ok := a.cache.LookupHostNumCPUs(hostID); ok {
 		cpuPercent = totalCPU / float64(numCPUs)
+		// Summed container CPU can overshoot when containers restart
+		// mid-interval (their first sample covers more than one interval).
+		// Without a core count there's no ceiling to clamp to.
+		if cpuPercent > 100 {
+			cpuPercent = 100
+			quality = AggregationQualityClamped
+		}
 	} else {
 		cpuPercent = totalCPU // Fallback if numCPUs not set
 	}
@@ -234,16 +332,61 @@ func (a *Aggregator) aggregateHostStats(hostID string, containers []*ContainerSt
 	cpuPercent = dockerpkg.RoundToDecimal(cpuPercent, 1)
 	memPercent = dockerpkg.RoundToDecimal(memPercent, 1)
 
-	return &HostStats{
-		HostID:           hostID,
-		CPUPercent:       cpuPercent,
-		MemoryPercent:    memPercent,
-		MemoryUsedBytes:  totalMemUsage,
-		MemoryLimitBytes: hostMemLimit,
-		NetworkRxBytes:   totalNetRx,
-		NetworkTxBytes:   totalNetTx,
-		ContainerCount:   validContainers,
+	stats := &HostStats{
+		HostID:             hostID,
+		CPUPercent:         cpuPercent,
+		MemoryPercent:      memPercent,
+		MemoryUsedBytes:    totalMemUsage,
+		MemoryLimitBytes:   hostMemLimit,
+		NetworkRxBytes:     totalNetRx,
+		NetworkTxBytes:     totalNetTx,
+		ContainerCount:     validContainers,
+		AggregationQuality: quality,
+	}
+	if a.perCoreMode == PerCoreAuto || a.perCoreMode == PerCoreContainers {
+		stats.CPUPerCore, stats.CPUPerCoreSource = a.containerPerCore(containers, cutoff)
+	}
+	return stats
+}
+
+// containerPerCore sums the fresh containers' per-core usage. Containers
+// without PercpuUsage (cgroups v2) are skipped, so a host with none gets
+// no breakdown rather than all zeros.
+func (a *Aggregator) containerPerCore(containers []*ContainerStats, cutoff time.Time) ([]float64, string) {
+	var perCore []float64
+	for _, stats := range containers {
+		if stats.LastUpdate.Before(cutoff) || len(stats.PerCPUPercent) == 0 {
+			continue
+		}
+		for len(perCore) < len(stats.PerCPUPercent) {
+			perCore = append(perCore, 0)
+		}
+		for i, pct := range stats.PerCPUPercent {
+			perCore[i] += pct
+		}
+	}
+	if perCore == nil {
+		return nil, ""
+	}
+	for i := range perCore {
+		if perCore[i] > 100 {
+			perCore[i] = 100
+		}
+	}
+	return roundPerCore(perCore), PerCoreContainers
+}
+
+// roundPerCore rounds each core's percentage to 1 decimal place like the
+// other CPU figures. Returns nil for an empty breakdown.
+func roundPerCore(perCore []float64) []float64 {
+	if len(perCore) == 0 {
+		return nil
+	}
+	rounded := make([]float64, len(perCore))
+	for i, pct := range perCore {
+		rounded[i] = dockerpkg.RoundToDecimal(pct, 1)
 	}
+	return rounded
 }
 
 // sampleFromHostStats builds a persistence.Sample from aggregated HostStats.
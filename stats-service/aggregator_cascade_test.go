@@ -175,3 +175,37 @@ func TestAggregator_HostNetBpsSumsContainerRates(t *testing.T) {
 			gotHostNetBps, want)
 	}
 }
+
+func TestAggregator_IngestsStoppedContainerFinalSample(t *testing.T) {
+	on := true
+	settingsProvider.ApplyPartialUpdate(&on, nil, nil)
+	t.Cleanup(func() {
+		off := false
+		settingsProvider.ApplyPartialUpdate(&off, nil, nil)
+	})
+
+	cache := NewStatsCache()
+	cache.SetStoppedRetention(time.Minute)
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "host-1", CPUPercent: 80})
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "def456def456", HostID: "host-1", CPUPercent: 10})
+
+	tiers := persistence.ComputeTiers(500)
+	cascade := persistence.NewCascade(tiers, make(chan persistence.WriteJob, 64))
+	agg := &Aggregator{
+		cache:             cache,
+		streamManager:     stubStreamManager{},
+		aggregateInterval: time.Second,
+		hostProcReader:    NewHostProcReader(),
+		cascade:           cascade,
+		lastAggregate:     time.Now().Add(-time.Second),
+	}
+
+	cache.MarkContainerStopped("abc123abc123", "host-1", "die", nil)
+	agg.aggregate()
+	if got := cascade.StateSize(); got != 3 {
+		t.Errorf("cascade state size=%d, want 3 (2 containers + 1 host)", got)
+	}
+	if hs, _ := cache.GetHostStats("host-1"); hs == nil || hs.ContainerCount != 1 || hs.CPUPercent != 10 {
+		t.Errorf("host stats = %+v, want only the running container", hs)
+	}
+}
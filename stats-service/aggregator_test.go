@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestAggregator(cache *StatsCache) *Aggregator {
+	return &Aggregator{
+		cache:             cache,
+		streamManager:     stubStreamManager{},
+		aggregateInterval: time.Second,
+		hostProcReader:    NewHostProcReader(),
+		perCoreMode:       PerCoreAuto,
+	}
+}
+
+// TestAggregator_HostNetworkSurvivesContainerRestart verifies a container
+// restart (counters back near zero) doesn't make the host totals drop
+func TestAggregator_HostNetworkSurvivesContainerRestart(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+	web := &ContainerStats{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 5000, NetworkTx: 1000, LastUpdate: now}
+	db := &ContainerStats{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", NetworkRx: 2000, NetworkTx: 2000, LastUpdate: now}
+
+	got := agg.aggregateHostStats("host-1", []*ContainerStats{web, db})
+	if got.NetworkRxBytes != 7000 || got.NetworkTxBytes != 3000 {
+		t.Fatalf("initial rx=%d tx=%d, want 7000/3000", got.NetworkRxBytes, got.NetworkTxBytes)
+	}
+	if got.AggregationQuality != AggregationQualityOK {
+		t.Errorf("initial quality=%q, want ok", got.AggregationQuality)
+	}
+
+	// web restarts: its counters start over
+	web = &ContainerStats{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 100, NetworkTx: 50, LastUpdate: now}
+	db = &ContainerStats{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", NetworkRx: 2500, NetworkTx: 2000, LastUpdate: now}
+	got = agg.aggregateHostStats("host-1", []*ContainerStats{web, db})
+	if got.NetworkRxBytes != 7600 || got.NetworkTxBytes != 3050 {
+		t.Errorf("after restart rx=%d tx=%d, want 7600/3050", got.NetworkRxBytes, got.NetworkTxBytes)
+	}
+	if got.AggregationQuality != AggregationQualityCounterReset {
+		t.Errorf("quality=%q, want counter_reset", got.AggregationQuality)
+	}
+}
+
+// TestAggregator_HostRestartResetsAllCounters covers a Docker host reboot:
+// every container comes back with fresh counters
+func TestAggregator_HostRestartResetsAllCounters(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+	ids := []string{"aaaaaaaaaaaa", "bbbbbbbbbbbb", "cccccccccccc"}
+	round := func(rx uint64) *HostStats {
+		var cs []*ContainerStats
+		for _, id := range ids {
+			cs = append(cs, &ContainerStats{ContainerID: id, HostID: "host-1", NetworkRx: rx, LastUpdate: now})
+		}
+		return agg.aggregateHostStats("host-1", cs)
+	}
+
+	before := round(1_000_000).NetworkRxBytes
+	after := round(10)
+	if after.NetworkRxBytes != before+30 {
+		t.Errorf("rx after reboot=%d, want %d", after.NetworkRxBytes, before+30)
+	}
+	if after.AggregationQuality != AggregationQualityCounterReset {
+		t.Errorf("quality=%q, want counter_reset", after.AggregationQuality)
+	}
+}
+
+// TestAggregator_HostsAggregateIndependently verifies counter state is kept
+// per host, even when container IDs collide across hosts
+func TestAggregator_HostsAggregateIndependently(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+	agg.aggregateHostStats("host-1", []*ContainerStats{{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 1000, LastUpdate: now}})
+	agg.aggregateHostStats("host-2", []*ContainerStats{{ContainerID: "aaaaaaaaaaaa", HostID: "host-2", NetworkRx: 50, LastUpdate: now}})
+
+	got := agg.aggregateHostStats("host-1", []*ContainerStats{{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 1100, LastUpdate: now}})
+	if got.NetworkRxBytes != 1100 || got.AggregationQuality != AggregationQualityOK {
+		t.Errorf("host-1 rx=%d quality=%q, want 1100/ok", got.NetworkRxBytes, got.AggregationQuality)
+	}
+}
+
+// TestAggregator_StaleContainerRejoinsWithoutSpike verifies a container that
+// went stale and came back doesn't add its whole counter at once
+func TestAggregator_StaleContainerRejoinsWithoutSpike(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+	fresh := &ContainerStats{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 100, LastUpdate: now}
+	flaky := &ContainerStats{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", NetworkRx: 100, LastUpdate: now.Add(-time.Minute)}
+
+	if got := agg.aggregateHostStats("host-1", []*ContainerStats{fresh, flaky}); got.NetworkRxBytes != 100 {
+		t.Fatalf("rx=%d, want 100 (stale container excluded)", got.NetworkRxBytes)
+	}
+	flaky = &ContainerStats{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", NetworkRx: 900_000, LastUpdate: now}
+	if got := agg.aggregateHostStats("host-1", []*ContainerStats{fresh, flaky}); got.NetworkRxBytes != 100 {
+		t.Errorf("rx=%d, want 100 (rejoining container only sets a baseline)", got.NetworkRxBytes)
+	}
+}
+
+// TestAggregator_ClampsSummedCPU verifies the container-sum CPU fallback
+// never reports more than 100% for the host
+func TestAggregator_ClampsSummedCPU(t *testing.T) {
+	cache := NewStatsCache()
+	cache.SetHostNumCPUs("host-1", 2)
+	agg := newTestAggregator(cache)
+	now := time.Now()
+
+	got := agg.aggregateHostStats("host-1", []*ContainerStats{
+		{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", CPUPercent: 180, LastUpdate: now},
+		{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", CPUPercent: 90, LastUpdate: now},
+	})
+	if got.CPUPercent != 100 || got.AggregationQuality != AggregationQualityClamped {
+		t.Errorf("cpu=%v quality=%q, want 100/clamped", got.CPUPercent, got.AggregationQuality)
+	}
+}
+
+// TestAggregator_NoClampWithoutCoreCount verifies the summed CPU isn't
+// clamped (or flagged) while the host's core count is unknown
+func TestAggregator_NoClampWithoutCoreCount(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+
+	got := agg.aggregateHostStats("host-1", []*ContainerStats{
+		{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", CPUPercent: 180, LastUpdate: now},
+		{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", CPUPercent: 90, LastUpdate: now},
+	})
+	if got.CPUPercent != 270 || got.AggregationQuality == AggregationQualityClamped {
+		t.Errorf("cpu=%v quality=%q, want 270 unclamped", got.CPUPercent, got.AggregationQuality)
+	}
+}
+
+// TestAggregator_SumsContainerPerCore verifies the per-core breakdown built
+// from container PercpuUsage, and that "off" suppresses it
+func TestAggregator_SumsContainerPerCore(t *testing.T) {
+	agg := newTestAggregator(NewStatsCache())
+	now := time.Now()
+	containers := []*ContainerStats{
+		{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", PerCPUPercent: []float64{80.04, 5}, LastUpdate: now},
+		{ContainerID: "bbbbbbbbbbbb", HostID: "host-1", PerCPUPercent: []float64{40, 2.5}, LastUpdate: now},
+		// cgroups v2: no per-core data
+		{ContainerID: "cccccccccccc", HostID: "host-1", CPUPercent: 50, LastUpdate: now},
+	}
+
+	got := agg.aggregateHostStats("host-1", containers)
+	if !reflect.DeepEqual(got.CPUPerCore, []float64{100, 7.5}) || got.CPUPerCoreSource != PerCoreContainers {
+		t.Errorf("per core = %v from %q, want [100 7.5] from containers", got.CPUPerCore, got.CPUPerCoreSource)
+	}
+
+	agg.SetPerCoreMode(PerCoreOff)
+	if got := agg.aggregateHostStats("host-1", containers); got.CPUPerCore != nil {
+		t.Errorf("per core = %v with mode off", got.CPUPerCore)
+	}
+
+	if _, err := parsePerCoreMode("bogus"); err == nil {
+		t.Error("accepted an unknown per-core mode")
+	}
+}
+
+// TestAggregator_DropsCountersForRemovedHosts verifies per-host counter
+// state doesn't outlive the host's containers
+func TestAggregator_DropsCountersForRemovedHosts(t *testing.T) {
+	cache := NewStatsCache()
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "aaaaaaaaaaaa", HostID: "host-1", NetworkRx: 10})
+	agg := newTestAggregator(cache)
+
+	agg.aggregate()
+	if _, ok := agg.counters["host-1"]; !ok {
+		t.Fatal("expected counter state for host-1")
+	}
+	cache.RemoveHostStats("host-1")
+	agg.aggregate()
+	if _, ok := agg.counters["host-1"]; ok {
+		t.Error("counter state for removed host should be dropped")
+	}
+}
+
+// TestAggregator_PerCoreOffSkipsProcRead verifies HOST_CPU_PER_CORE=off (and
+// containers) stops the /proc per-core read rather than discarding its result
+func TestAggregator_PerCoreOffSkipsProcRead(t *testing.T) {
+	dir := t.TempDir()
+	stat := "cpu  200 0 200 1600 0 0 0 0\ncpu0 100 0 100 800 0 0 0 0\ncpu1 100 0 100 800 0 0 0 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		mode string
+		read bool
+	}{
+		{PerCoreAuto, true},
+		{PerCoreProc, true},
+		{PerCoreContainers, false},
+		{PerCoreOff, false},
+	} {
+		agg := newTestAggregator(NewStatsCache())
+		agg.hostProcReader = &HostProcReader{procPath: dir, available: true}
+		agg.SetPerCoreMode(tc.mode)
+
+		agg.hostProcReader.getPerCorePercent()
+		agg.hostProcReader.mu.RLock()
+		read := agg.hostProcReader.lastCores != nil
+		agg.hostProcReader.mu.RUnlock()
+		if read != tc.read {
+			t.Errorf("mode %s: per-core counters read=%v, want %v", tc.mode, read, tc.read)
+		}
+	}
+}
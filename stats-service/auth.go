@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenScope is what a bearer token may access. Each endpoint declares the
+// scope it requires; the admin token satisfies every scope.
+type tokenScope string
+
+const (
+	scopeAdmin     tokenScope = "admin"       // Every endpoint (the Python backend)
+	scopeStatsRead tokenScope = "stats:read"  // Current and historical stats, read-only
+	scopeEvents    tokenScope = "events:read" // Recent events and the event stream
+)
+
+// allows reports whether a token with this scope may call an endpoint
+// requiring the given scope
+func (s tokenScope) allows(required tokenScope) bool {
+	return s == scopeAdmin || s == required
+}
+
+// scopedToken is a generated token and the file it is published in
+type scopedToken struct {
+	scope tokenScope
+	token string
+	path  string
+
+	// expires is set on a rotated-out token, which is still accepted
+	// until then so callers have time to re-read the file
+	expires time.Time
+}
+
+// tokenSet maps the tokens generated at startup to their scopes
+type tokenSet struct {
+	mu      sync.RWMutex
+	tokens  []scopedToken
+	retired []scopedToken // Replaced by rotate, valid until they expire
+}
+
+// newTokenSet generates one token per scope. Scopes with an empty path are
+// skipped, so a deployment can opt out of publishing a credential.
+func newTokenSet(paths map[tokenScope]string) (*tokenSet, error) {
+	ts := &tokenSet{}
+	for _, scope := range []tokenScope{scopeAdmin, scopeStatsRead, scopeEvents} {
+		path := paths[scope]
+		if path == "" {
+			continue
+		}
+		token, err := generateToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s token: %w", scope, err)
+		}
+		ts.tokens = append(ts.tokens, scopedToken{scope: scope, token: token, path: path})
+	}
+	return ts, nil
+}
+
+// write publishes every token to its file
+func (ts *tokenSet) write() error {
+	for _, t := range ts.currentTokens() {
+		if err := writeTokenSecurely(t.path, t.token); err != nil {
+			return fmt.Errorf("failed to write %s token file: %w", t.scope, err)
+		}
+	}
+	return nil
+}
+
+// remove deletes the token files at shutdown
+func (ts *tokenSet) remove() {
+	for _, t := range ts.currentTokens() {
+		if err := os.Remove(t.path); err != nil {
+			log.Printf("Warning: Failed to remove %s token file: %v", t.scope, err)
+		}
+	}
+}
+
+// scopeOf returns the scope of token. Every configured token is compared in
+// constant time, so timing doesn't reveal which one (if any) matched.
+func (ts *tokenSet) scopeOf(token string) (tokenScope, bool) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	var found tokenScope
+	for _, t := range ts.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) == 1 {
+			found = t.scope
+		}
+	}
+	now := time.Now()
+	for _, t := range ts.retired {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.token)) == 1 && now.Before(t.expires) {
+			found = t.scope
+		}
+	}
+	return found, found != ""
+}
+
+// rotate replaces every token with a new one and publishes it. The old
+// tokens stay valid for overlap, so clients holding them (the backend, the
+// compose service) keep working until they re-read the files; WebSocket
+// connections already authenticated are not affected either way.
+func (ts *tokenSet) rotate(overlap time.Duration) error {
+	next := make([]scopedToken, 0, len(ts.tokens))
+	for _, t := range ts.currentTokens() {
+		token, err := generateToken()
+		if err != nil {
+			return fmt.Errorf("failed to generate %s token: %w", t.scope, err)
+		}
+		next = append(next, scopedToken{scope: t.scope, token: token, path: t.path})
+	}
+
+	ts.mu.Lock()
+	now := time.Now()
+	retired := ts.retired[:0]
+	for _, t := range ts.retired {
+		if now.Before(t.expires) {
+			retired = append(retired, t)
+		}
+	}
+	if overlap > 0 {
+		for _, t := range ts.tokens {
+			t.expires = now.Add(overlap)
+			retired = append(retired, t)
+		}
+	}
+	ts.retired = retired
+	ts.tokens = next
+	ts.mu.Unlock()
+
+	// The new tokens are accepted before the files are written, so a
+	// client reading a file early is never rejected
+	if err := ts.write(); err != nil {
+		return err
+	}
+	log.Printf("Rotated auth tokens (previous tokens valid for %v)", overlap)
+	return nil
+}
+
+// currentTokens returns a copy of the tokens in use
+func (ts *tokenSet) currentTokens() []scopedToken {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return slices.Clone(ts.tokens)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// authMiddleware validates the Bearer token and checks that its scope grants
+// the scope the endpoint requires
+func authMiddleware(tokens *tokenSet, required tokenScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := tokens.scopeOf(bearerToken(r))
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			log.Printf("Unauthorized request from %s to %s", r.RemoteAddr, r.URL.Path)
+			return
+		}
+		if !scope.allows(required) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			log.Printf("Forbidden request from %s to %s (token scope %s, requires %s)", r.RemoteAddr, r.URL.Path, scope, required)
+			return
+		}
+
+		next(w, r)
+	}
+}
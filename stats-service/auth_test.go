@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func makeTokenSetFixture(t *testing.T) *tokenSet {
+	t.Helper()
+	dir := t.TempDir()
+	ts, err := newTokenSet(map[tokenScope]string{
+		scopeAdmin:     filepath.Join(dir, "admin"),
+		scopeStatsRead: filepath.Join(dir, "stats"),
+		scopeEvents:    filepath.Join(dir, "events"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func tokenFor(ts *tokenSet, scope tokenScope) string {
+	for _, t := range ts.tokens {
+		if t.scope == scope {
+			return t.token
+		}
+	}
+	return ""
+}
+
+func TestTokenSet_ScopeOf(t *testing.T) {
+	ts := makeTokenSetFixture(t)
+
+	for _, scope := range []tokenScope{scopeAdmin, scopeStatsRead, scopeEvents} {
+		got, ok := ts.scopeOf(tokenFor(ts, scope))
+		if !ok || got != scope {
+			t.Errorf("scopeOf(%s token) = %q, %v", scope, got, ok)
+		}
+	}
+	if _, ok := ts.scopeOf(""); ok {
+		t.Error("empty token should not match")
+	}
+	if _, ok := ts.scopeOf("not-a-token"); ok {
+		t.Error("unknown token should not match")
+	}
+}
+
+func TestTokenSet_SkipsEmptyPath(t *testing.T) {
+	ts, err := newTokenSet(map[tokenScope]string{scopeAdmin: filepath.Join(t.TempDir(), "admin")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.tokens) != 1 || ts.tokens[0].scope != scopeAdmin {
+		t.Errorf("tokens = %+v, want only admin", ts.tokens)
+	}
+}
+
+func TestTokenSet_WriteAndRemove(t *testing.T) {
+	ts := makeTokenSetFixture(t)
+	if err := ts.write(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tok := range ts.tokens {
+		data, err := os.ReadFile(tok.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tok.token {
+			t.Errorf("%s file holds %q, want its token", tok.scope, data)
+		}
+	}
+
+	ts.remove()
+	for _, tok := range ts.tokens {
+		if _, err := os.Stat(tok.path); !os.IsNotExist(err) {
+			t.Errorf("%s file still exists after remove", tok.scope)
+		}
+	}
+}
+
+func TestTokenSet_RotateKeepsOldTokensForOverlap(t *testing.T) {
+	ts := makeTokenSetFixture(t)
+	oldAdmin, oldStats := tokenFor(ts, scopeAdmin), tokenFor(ts, scopeStatsRead)
+
+	if err := ts.rotate(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	newAdmin := tokenFor(ts, scopeAdmin)
+	if newAdmin == oldAdmin {
+		t.Fatal("admin token not replaced")
+	}
+	data, err := os.ReadFile(ts.tokens[0].path)
+	if err != nil || string(data) != ts.tokens[0].token {
+		t.Errorf("token file not updated: %q, %v", data, err)
+	}
+	for _, tc := range []struct {
+		token string
+		want  tokenScope
+	}{{newAdmin, scopeAdmin}, {oldAdmin, scopeAdmin}, {oldStats, scopeStatsRead}} {
+		if got, ok := ts.scopeOf(tc.token); !ok || got != tc.want {
+			t.Errorf("scopeOf = %q, %v; want %s during the overlap", got, ok, tc.want)
+		}
+	}
+
+	// Without an overlap the previous tokens stop working at once, and
+	// expired ones are dropped
+	ts.retired[0].expires = time.Now().Add(-time.Second)
+	if _, ok := ts.scopeOf(ts.retired[0].token); ok {
+		t.Error("expired token still accepted")
+	}
+	if err := ts.rotate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ts.scopeOf(newAdmin); ok {
+		t.Error("token rotated out without overlap still accepted")
+	}
+	if len(ts.retired) != 2 {
+		t.Errorf("retired = %d tokens, want the 2 unexpired from the first rotation", len(ts.retired))
+	}
+}
+
+func TestAuthMiddleware_Scopes(t *testing.T) {
+	ts := makeTokenSetFixture(t)
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	cases := []struct {
+		name     string
+		auth     string
+		required tokenScope
+		want     int
+	}{
+		{"missing header", "", scopeStatsRead, http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + tokenFor(ts, scopeAdmin), scopeStatsRead, http.StatusUnauthorized},
+		{"unknown token", "Bearer nope", scopeStatsRead, http.StatusUnauthorized},
+		{"stats token on stats", "Bearer " + tokenFor(ts, scopeStatsRead), scopeStatsRead, http.StatusOK},
+		{"stats token on events", "Bearer " + tokenFor(ts, scopeStatsRead), scopeEvents, http.StatusForbidden},
+		{"stats token on admin", "Bearer " + tokenFor(ts, scopeStatsRead), scopeAdmin, http.StatusForbidden},
+		{"events token on events", "Bearer " + tokenFor(ts, scopeEvents), scopeEvents, http.StatusOK},
+		{"events token on stats", "Bearer " + tokenFor(ts, scopeEvents), scopeStatsRead, http.StatusForbidden},
+		{"admin token on stats", "Bearer " + tokenFor(ts, scopeAdmin), scopeStatsRead, http.StatusOK},
+		{"admin token on events", "Bearer " + tokenFor(ts, scopeAdmin), scopeEvents, http.StatusOK},
+		{"admin token on admin", "Bearer " + tokenFor(ts, scopeAdmin), scopeAdmin, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/stats/hosts", nil)
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rr := httptest.NewRecorder()
+			authMiddleware(ts, tc.required, ok)(rr, req)
+			if rr.Code != tc.want {
+				t.Errorf("status = %d, want %d", rr.Code, tc.want)
+			}
+		})
+	}
+}
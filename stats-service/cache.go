@@ -2,8 +2,11 @@ package main
 
 import (
 	"math"
+	"strings"
 	"sync"
 	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
 )
 
 // ContainerStats holds real-time stats for a single container
@@ -11,6 +14,7 @@ type ContainerStats struct {
 	ContainerID    string    `json:"container_id"`
 	ContainerName  string    `json:"container_name"`
 	HostID         string    `json:"host_id"`
+	Group          string    `json:"group,omitempty"` // dockmon.group label
 	CPUPercent     float64   `json:"cpu_percent"`
 	MemoryUsage    uint64    `json:"memory_usage"`
 	MemoryLimit    uint64    `json:"memory_limit"`
@@ -21,6 +25,54 @@ type ContainerStats struct {
 	DiskRead       uint64    `json:"disk_read"`
 	DiskWrite      uint64    `json:"disk_write"`
 	LastUpdate     time.Time `json:"last_update"`
+
+	// Both memory figures behind MemoryUsage (zero from agents that predate
+	// them); see dockerpkg.MemoryMode
+	MemoryRawUsage   uint64 `json:"memory_raw_usage,omitempty"`
+	MemoryWorkingSet uint64 `json:"memory_working_set,omitempty"`
+
+	// PerCPUPercent is the container's use of each core (cgroups v1 only)
+	PerCPUPercent []float64 `json:"per_cpu_percent,omitempty"`
+
+	// Set once the container has stopped: the entry is then its final
+	// sample, kept for the stopped-container retention period. ExitEvent is
+	// the event that ended it ("die" or "oom") when one was seen.
+	Stopped   bool       `json:"stopped,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	ExitEvent string     `json:"exit_event,omitempty"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+
+	// State is "paused" while the container is paused: its stream is
+	// stopped and the entry is its last sample, frozen, with CPU and the
+	// network rate zeroed since a paused container uses neither. Samples
+	// are ignored until it's unpaused.
+	State    string     `json:"state,omitempty"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+}
+
+const (
+	// containerStatePaused is ContainerStats.State for a paused container
+	containerStatePaused = "paused"
+	// pausedRetention is how long a paused container's entry is kept
+	// without word of it being unpaused or stopped
+	pausedRetention = time.Hour
+)
+
+// withMemoryMode returns a copy of the stats whose MemoryUsage and
+// MemoryPercent follow mode. Stats without both figures are returned as-is.
+func (s ContainerStats) withMemoryMode(mode dockerpkg.MemoryMode) *ContainerStats {
+	usage := s.MemoryWorkingSet
+	if mode == dockerpkg.MemoryModeRaw {
+		usage = s.MemoryRawUsage
+	}
+	if usage == 0 || usage == s.MemoryUsage {
+		return &s
+	}
+	s.MemoryUsage = usage
+	if s.MemoryLimit > 0 {
+		s.MemoryPercent = dockerpkg.RoundToDecimal(float64(usage)/float64(s.MemoryLimit)*100, 1)
+	}
+	return &s
 }
 
 // HostStats holds aggregated stats for a host
@@ -34,6 +86,18 @@ type HostStats struct {
 	NetworkTxBytes   uint64    `json:"network_tx_bytes"`
 	ContainerCount   int       `json:"container_count"`
 	LastUpdate       time.Time `json:"last_update"`
+
+	// AggregationQuality flags intervals where container counter resets or
+	// clamping shaped the numbers (see AggregationQualityOK and friends).
+	// Empty for hosts whose stats don't come from the aggregator.
+	AggregationQuality string `json:"aggregation_quality,omitempty"`
+
+	// CPUPerCore is each core's busy percentage, so one pegged core shows
+	// even when CPUPercent averages it away. CPUPerCoreSource says where it
+	// came from (PerCoreProc or PerCoreContainers); both are empty when
+	// no source is available or HOST_CPU_PER_CORE is off.
+	CPUPerCore       []float64 `json:"cpu_per_core,omitempty"`
+	CPUPerCoreSource string    `json:"cpu_per_core_source,omitempty"`
 }
 
 // networkBaseline tracks previous network values for rate calculation
@@ -51,6 +115,11 @@ type StatsCache struct {
 	hostNumCPUs    map[string]int              // key: hostID -> number of CPUs on host
 	hostMemory     map[string]uint64           // key: hostID -> total memory available to Docker
 	localHosts     map[string]bool             // key: hostID -> true if local host
+	groups         map[string]string           // key: composite key -> dockmon.group label
+
+	// stoppedRetention is how long a stopped container's final sample is
+	// kept; zero removes it as soon as the container stops
+	stoppedRetention time.Duration
 }
 
 // NewStatsCache creates a new stats cache
@@ -62,9 +131,19 @@ func NewStatsCache() *StatsCache {
 		hostNumCPUs:    make(map[string]int),
 		hostMemory:     make(map[string]uint64),
 		localHosts:     make(map[string]bool),
+		groups:         make(map[string]string),
 	}
 }
 
+// SetStoppedRetention sets how long stopped containers' final samples are
+// kept, so short-lived and crash-looping containers stay visible between
+// samples. Zero removes them immediately.
+func (c *StatsCache) SetStoppedRetention(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stoppedRetention = d
+}
+
 // SetHostNumCPUs stores the number of CPUs for a host
 func (c *StatsCache) SetHostNumCPUs(hostID string, numCPUs int) {
 	c.mu.Lock()
@@ -74,14 +153,21 @@ func (c *StatsCache) SetHostNumCPUs(hostID string, numCPUs int) {
 
 // GetHostNumCPUs retrieves the number of CPUs for a host (returns 1 if not set)
 func (c *StatsCache) GetHostNumCPUs(hostID string) int {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if numCPUs, ok := c.hostNumCPUs[hostID]; ok && numCPUs > 0 {
+	if numCPUs, ok := c.LookupHostNumCPUs(hostID); ok {
 		return numCPUs
 	}
 	return 1 // Default to 1 to avoid division by zero
 }
 
+// LookupHostNumCPUs retrieves the number of CPUs for a host and whether it
+// is known
+func (c *StatsCache) LookupHostNumCPUs(hostID string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	numCPUs, ok := c.hostNumCPUs[hostID]
+	return numCPUs, ok && numCPUs > 0
+}
+
 // SetHostMemory stores the total memory available to Docker for a host.
 func (c *StatsCache) SetHostMemory(hostID string, totalMemory uint64) {
 	c.mu.Lock()
@@ -110,6 +196,27 @@ func (c *StatsCache) IsHostLocal(hostID string) bool {
 	return c.localHosts[hostID]
 }
 
+// SetContainerGroup records a container's stats group (its dockmon.group
+// label) for samples from streams that don't carry it; "" clears it. Kept
+// until the container is removed, so it survives stream restarts.
+func (c *StatsCache) SetContainerGroup(containerID, hostID, group string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compositeKey := hostID + ":" + containerID
+	if group == "" {
+		delete(c.groups, compositeKey)
+	} else {
+		c.groups[compositeKey] = group
+	}
+	if stats, ok := c.containerStats[compositeKey]; ok && stats.Group != group {
+		// Replace rather than modify: readers may hold the old pointer
+		grouped := *stats
+		grouped.Group = group
+		c.containerStats[compositeKey] = &grouped
+	}
+}
+
 // UpdateContainerStats updates stats for a container and calculates network rate
 func (c *StatsCache) UpdateContainerStats(stats *ContainerStats) {
 	c.mu.Lock()
@@ -121,6 +228,15 @@ func (c *StatsCache) UpdateContainerStats(stats *ContainerStats) {
 	// Use composite key to support containers with duplicate IDs on different hosts
 	compositeKey := stats.HostID + ":" + stats.ContainerID
 
+	// A sample read just before the stream was stopped mustn't unfreeze a
+	// paused container
+	if prev, ok := c.containerStats[compositeKey]; ok && prev.State == containerStatePaused {
+		return
+	}
+	if stats.Group == "" {
+		stats.Group = c.groups[compositeKey]
+	}
+
 	// Calculate network rate (bytes per second)
 	currentTotal := stats.NetworkRx + stats.NetworkTx
 
@@ -204,7 +320,8 @@ func (c *StatsCache) GetAllContainerStats() map[string]*ContainerStats {
 	return result
 }
 
-// RemoveContainerStats removes stats for a container (when it stops)
+// RemoveContainerStats removes stats for a container immediately. Stopped
+// containers go through MarkContainerStopped instead.
 func (c *StatsCache) RemoveContainerStats(containerID, hostID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -212,6 +329,103 @@ func (c *StatsCache) RemoveContainerStats(containerID, hostID string) {
 	compositeKey := hostID + ":" + containerID
 	delete(c.containerStats, compositeKey)
 	delete(c.lastNetStats, compositeKey)
+	delete(c.groups, compositeKey)
+}
+
+// MarkContainerStopped keeps a container's last sample as its final one,
+// tagged with the exit event and code when known (exitEvent may be empty).
+// The entry is removed after the stopped retention period, or right away if
+// there is none. Called for both the stop of the stream and the container's
+// die/oom events, in either order; an oom isn't overwritten by the die that
+// follows it.
+func (c *StatsCache) MarkContainerStopped(containerID, hostID, exitEvent string, exitCode *int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compositeKey := hostID + ":" + containerID
+	stats, ok := c.containerStats[compositeKey]
+	if !ok {
+		return
+	}
+	// A paused container's stream is stopped, but the container isn't
+	if stats.State == containerStatePaused && exitEvent == "" {
+		return
+	}
+	if c.stoppedRetention <= 0 {
+		delete(c.containerStats, compositeKey)
+		delete(c.lastNetStats, compositeKey)
+		return
+	}
+
+	// Replace rather than modify: readers may hold the old pointer
+	final := *stats
+	if !final.Stopped {
+		now := time.Now()
+		final.Stopped = true
+		final.StoppedAt = &now
+		final.NetBytesPerSec = 0
+	}
+	final.State, final.PausedAt = "", nil
+	if exitEvent != "" && final.ExitEvent != "oom" {
+		final.ExitEvent = exitEvent
+	}
+	if exitCode != nil {
+		code := *exitCode
+		final.ExitCode = &code
+	}
+	c.containerStats[compositeKey] = &final
+	// A restart starts new counters
+	delete(c.lastNetStats, compositeKey)
+}
+
+// MarkContainerPaused freezes a container's last sample and flags it
+// paused, so it isn't shown as an idle running container. A container
+// paused before its first sample gets an entry with no metrics.
+func (c *StatsCache) MarkContainerPaused(containerID, containerName, hostID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	compositeKey := hostID + ":" + containerID
+	paused := ContainerStats{
+		ContainerID:   containerID,
+		ContainerName: containerName,
+		HostID:        hostID,
+		Group:         c.groups[compositeKey],
+		LastUpdate:    now,
+	}
+	if stats, ok := c.containerStats[compositeKey]; ok {
+		if stats.Stopped || stats.State == containerStatePaused {
+			return
+		}
+		// Replace rather than modify: readers may hold the old pointer
+		paused = *stats
+	}
+	paused.State = containerStatePaused
+	paused.PausedAt = &now
+	paused.CPUPercent = 0
+	paused.PerCPUPercent = nil
+	paused.NetBytesPerSec = 0
+	c.containerStats[compositeKey] = &paused
+	// The network rate restarts from the first sample after unpausing
+	delete(c.lastNetStats, compositeKey)
+}
+
+// MarkContainerUnpaused clears a container's paused flag, so its samples
+// are taken again
+func (c *StatsCache) MarkContainerUnpaused(containerID, hostID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	compositeKey := hostID + ":" + containerID
+	stats, ok := c.containerStats[compositeKey]
+	if !ok || stats.State != containerStatePaused {
+		return
+	}
+	running := *stats
+	running.State, running.PausedAt = "", nil
+	running.LastUpdate = time.Now()
+	c.containerStats[compositeKey] = &running
 }
 
 // UpdateHostStats updates aggregated stats for a host
@@ -270,6 +484,11 @@ func (c *StatsCache) RemoveHostStats(hostID string) {
 			delete(c.lastNetStats, id)
 		}
 	}
+	for id := range c.groups {
+		if strings.HasPrefix(id, hostID+":") {
+			delete(c.groups, id)
+		}
+	}
 }
 
 // CleanStaleStats removes stats older than maxAge
@@ -279,8 +498,24 @@ func (c *StatsCache) CleanStaleStats(maxAge time.Duration) {
 
 	now := time.Now()
 
-	// Clean container stats and corresponding network baselines
+	// Clean container stats and corresponding network baselines. Stopped
+	// containers are kept for the stopped retention period instead, and
+	// paused ones, which get no samples, while they're paused.
 	for id, stats := range c.containerStats {
+		if stats.State == containerStatePaused && stats.PausedAt != nil {
+			if now.Sub(*stats.PausedAt) > pausedRetention {
+				delete(c.containerStats, id)
+				delete(c.lastNetStats, id)
+			}
+			continue
+		}
+		if stats.Stopped && stats.StoppedAt != nil {
+			if now.Sub(*stats.StoppedAt) > c.stoppedRetention {
+				delete(c.containerStats, id)
+				delete(c.lastNetStats, id)
+			}
+			continue
+		}
 		if now.Sub(stats.LastUpdate) > maxAge {
 			delete(c.containerStats, id)
 			delete(c.lastNetStats, id) // Clean up network baseline to prevent memory leak
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+)
+
+func TestContainerStats_WithMemoryMode(t *testing.T) {
+	cs := ContainerStats{
+		MemoryUsage:      400,
+		MemoryLimit:      1000,
+		MemoryPercent:    40,
+		MemoryRawUsage:   600,
+		MemoryWorkingSet: 400,
+	}
+
+	raw := cs.withMemoryMode(dockerpkg.MemoryModeRaw)
+	if raw.MemoryUsage != 600 || raw.MemoryPercent != 60 {
+		t.Errorf("raw usage=%d percent=%v, want 600/60", raw.MemoryUsage, raw.MemoryPercent)
+	}
+	if cs.MemoryUsage != 400 {
+		t.Error("withMemoryMode must not modify the receiver")
+	}
+	if ws := raw.withMemoryMode(dockerpkg.MemoryModeWorkingSet); ws.MemoryUsage != 400 || ws.MemoryPercent != 40 {
+		t.Errorf("working set usage=%d percent=%v, want 400/40", ws.MemoryUsage, ws.MemoryPercent)
+	}
+
+	// Stats from agents without both figures are left alone
+	legacy := ContainerStats{MemoryUsage: 400, MemoryLimit: 1000, MemoryPercent: 40}
+	if got := legacy.withMemoryMode(dockerpkg.MemoryModeRaw); got.MemoryUsage != 400 || got.MemoryPercent != 40 {
+		t.Errorf("legacy stats changed: %+v", got)
+	}
+}
+
+func TestStatsCache_MarkContainerStoppedKeepsFinalSample(t *testing.T) {
+	cache := NewStatsCache()
+	cache.SetStoppedRetention(time.Minute)
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "h1", CPUPercent: 93.5})
+
+	code := 137
+	cache.MarkContainerStopped("abc123abc123", "h1", "oom", nil)
+	cache.MarkContainerStopped("abc123abc123", "h1", "die", &code)
+	// The stream stopping afterwards doesn't reset the stop time or the tag
+	cache.MarkContainerStopped("abc123abc123", "h1", "", nil)
+
+	final, ok := cache.GetContainerStats("abc123abc123", "h1")
+	if !ok || !final.Stopped || final.StoppedAt == nil || final.CPUPercent != 93.5 {
+		t.Fatalf("final sample = %+v", final)
+	}
+	if final.ExitEvent != "oom" || final.ExitCode == nil || *final.ExitCode != 137 {
+		t.Errorf("exit = %q %v, want oom with code 137", final.ExitEvent, final.ExitCode)
+	}
+
+	// Stale stats are cleaned; the stopped container waits out its retention
+	cache.CleanStaleStats(0)
+	if _, ok := cache.GetContainerStats("abc123abc123", "h1"); !ok {
+		t.Fatal("stopped container removed before its retention")
+	}
+	stoppedAt := final.StoppedAt.Add(-2 * time.Minute)
+	final.StoppedAt = &stoppedAt
+	cache.CleanStaleStats(0)
+	if _, ok := cache.GetContainerStats("abc123abc123", "h1"); ok {
+		t.Error("stopped container kept past its retention")
+	}
+}
+
+func TestStatsCache_MarkContainerStoppedWithoutRetention(t *testing.T) {
+	cache := NewStatsCache()
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "h1"})
+	cache.MarkContainerStopped("abc123abc123", "h1", "die", nil)
+	if _, ok := cache.GetContainerStats("abc123abc123", "h1"); ok {
+		t.Error("without retention a stopped container should be removed")
+	}
+}
+
+func TestStatsCache_PausedContainer(t *testing.T) {
+	cache := NewStatsCache()
+	cache.SetStoppedRetention(time.Minute)
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "h1", CPUPercent: 42, MemoryUsage: 1 << 20})
+
+	cache.MarkContainerPaused("abc123abc123", "web", "h1")
+	// Neither a late sample nor the stream stopping changes the frozen entry
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "h1", CPUPercent: 42})
+	cache.MarkContainerStopped("abc123abc123", "h1", "", nil)
+
+	paused, ok := cache.GetContainerStats("abc123abc123", "h1")
+	if !ok || paused.State != containerStatePaused || paused.PausedAt == nil || paused.Stopped {
+		t.Fatalf("paused entry = %+v", paused)
+	}
+	if paused.CPUPercent != 0 || paused.MemoryUsage != 1<<20 {
+		t.Errorf("paused metrics: cpu=%v memory=%d, want 0 and the last sample's", paused.CPUPercent, paused.MemoryUsage)
+	}
+
+	// Paused containers get no samples, but aren't cleaned as stale
+	cache.CleanStaleStats(0)
+	if _, ok := cache.GetContainerStats("abc123abc123", "h1"); !ok {
+		t.Fatal("paused container removed as stale")
+	}
+
+	cache.MarkContainerUnpaused("abc123abc123", "h1")
+	cache.UpdateContainerStats(&ContainerStats{ContainerID: "abc123abc123", HostID: "h1", CPUPercent: 7})
+	if running, _ := cache.GetContainerStats("abc123abc123", "h1"); running.State != "" || running.PausedAt != nil || running.CPUPercent != 7 {
+		t.Errorf("after unpause = %+v", running)
+	}
+
+	// Killing a paused container stops it
+	cache.MarkContainerPaused("abc123abc123", "web", "h1")
+	cache.MarkContainerStopped("abc123abc123", "h1", "die", nil)
+	if final, _ := cache.GetContainerStats("abc123abc123", "h1"); !final.Stopped || final.State != "" {
+		t.Errorf("paused then killed = %+v", final)
+	}
+
+	// A container paused before its first sample is still flagged
+	cache.MarkContainerPaused("def456def456", "db", "h1")
+	if paused, ok := cache.GetContainerStats("def456def456", "h1"); !ok || paused.State != containerStatePaused || paused.ContainerName != "db" {
+		t.Errorf("paused without samples = %+v", paused)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import "time"
+
+// maxContainerNetRate caps a single container's plausible network throughput
+// when turning counter samples into deltas. It matches the per-container
+// outlier cap in StatsCache.UpdateContainerStats (10 GB/s).
+const maxContainerNetRate = float64(10 * 1024 * 1024 * 1024)
+
+// Aggregation quality values reported in HostStats.AggregationQuality, so
+// the UI can tell a real traffic spike from an aggregation artifact.
+const (
+	// AggregationQualityOK means every container counter advanced normally
+	AggregationQualityOK = "ok"
+	// AggregationQualityCounterReset means at least one container's counters
+	// went backwards (restart or recreate); only traffic since the reset
+	// was added to the host totals
+	AggregationQualityCounterReset = "counter_reset"
+	// AggregationQualityClamped means an implausible delta or percentage was
+	// capped; the host values for this interval are a lower bound
+	AggregationQualityClamped = "clamped"
+)
+
+// monotonicCounter turns per-container cumulative counters, which reset
+// whenever a container restarts, into a host total that never goes
+// backwards. A container's delta since the previous sample is added to the
+// total; a counter lower than before counts as a reset, and containers that
+// disappear keep what they already contributed.
+type monotonicCounter struct {
+	total  uint64
+	last   map[string]uint64 // key: container ID -> previous counter value
+	seeded bool
+}
+
+// counterObservation reports what observe had to correct
+type counterObservation struct {
+	reset   bool
+	clamped bool
+}
+
+// observe folds one round of container counter samples into the total.
+// elapsed bounds the plausible delta per container (see maxContainerNetRate);
+// zero disables the bound.
+//
+// The first round seeds the total with the sum of the current counters, so
+// host totals start where the raw sum would. After that, a container seen
+// for the first time only sets its baseline: its earlier traffic predates
+// the previous sample and would otherwise show up as a one-interval spike.
+func (m *monotonicCounter) observe(samples map[string]uint64, elapsed time.Duration) counterObservation {
+	var obs counterObservation
+	if m.last == nil {
+		m.last = make(map[string]uint64, len(samples))
+	}
+
+	maxDelta := uint64(maxContainerNetRate * elapsed.Seconds())
+	for id, cur := range samples {
+		prev, known := m.last[id]
+		m.last[id] = cur
+
+		var delta uint64
+		switch {
+		case !m.seeded:
+			delta = cur
+		case !known:
+			continue
+		case cur < prev:
+			// Counter reset: everything counted since the restart is new
+			delta = cur
+			obs.reset = true
+		default:
+			delta = cur - prev
+		}
+
+		if m.seeded && elapsed > 0 && delta > maxDelta {
+			delta = maxDelta
+			obs.clamped = true
+		}
+		m.add(delta)
+	}
+
+	// Forget containers that went away or went stale; if they come back
+	// they start from a fresh baseline
+	for id := range m.last {
+		if _, ok := samples[id]; !ok {
+			delete(m.last, id)
+		}
+	}
+	m.seeded = true
+	return obs
+}
+
+// add increases the total, saturating instead of wrapping on overflow
+func (m *monotonicCounter) add(delta uint64) {
+	const maxUint64 = ^uint64(0)
+	if maxUint64-m.total < delta {
+		m.total = maxUint64
+		return
+	}
+	m.total += delta
+}
+
+// hostCounters holds the monotonic network totals for one host
+type hostCounters struct {
+	rx, tx   monotonicCounter
+	observed time.Time // when the previous round was folded in
+}
+
+// observe folds the containers' current rx/tx counters into the host totals
+// and returns the aggregation quality for this round
+func (h *hostCounters) observe(now time.Time, rx, tx map[string]uint64) string {
+	var elapsed time.Duration
+	if !h.observed.IsZero() {
+		elapsed = now.Sub(h.observed)
+	}
+	h.observed = now
+
+	rxObs := h.rx.observe(rx, elapsed)
+	txObs := h.tx.observe(tx, elapsed)
+	switch {
+	case rxObs.clamped || txObs.clamped:
+		return AggregationQualityClamped
+	case rxObs.reset || txObs.reset:
+		return AggregationQualityCounterReset
+	default:
+		return AggregationQualityOK
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonotonicCounter_SeedsThenAddsDeltas(t *testing.T) {
+	var m monotonicCounter
+	m.observe(map[string]uint64{"a": 1000, "b": 500}, 0)
+	if m.total != 1500 {
+		t.Fatalf("seed total=%d, want 1500", m.total)
+	}
+	obs := m.observe(map[string]uint64{"a": 1200, "b": 800}, 10*time.Second)
+	if m.total != 2000 || obs.reset || obs.clamped {
+		t.Errorf("total=%d obs=%+v, want 2000 with no corrections", m.total, obs)
+	}
+}
+
+func TestMonotonicCounter_ResetCountsTrafficSinceRestart(t *testing.T) {
+	var m monotonicCounter
+	m.observe(map[string]uint64{"a": 10_000}, 0)
+	obs := m.observe(map[string]uint64{"a": 300}, 10*time.Second)
+	if !obs.reset {
+		t.Error("expected counter reset to be reported")
+	}
+	if m.total != 10_300 {
+		t.Errorf("total=%d, want 10300 (never goes backwards)", m.total)
+	}
+}
+
+func TestMonotonicCounter_NewAndVanishedContainers(t *testing.T) {
+	var m monotonicCounter
+	m.observe(map[string]uint64{"a": 1000}, 0)
+
+	// A container appearing later only sets its baseline
+	m.observe(map[string]uint64{"a": 1100, "b": 50_000}, 10*time.Second)
+	if m.total != 1100 {
+		t.Fatalf("total=%d, want 1100 (new container must not spike)", m.total)
+	}
+	m.observe(map[string]uint64{"a": 1100, "b": 50_100}, 10*time.Second)
+	if m.total != 1200 {
+		t.Fatalf("total=%d, want 1200", m.total)
+	}
+
+	// A container going away keeps its contribution
+	m.observe(map[string]uint64{"a": 1150}, 10*time.Second)
+	if m.total != 1250 {
+		t.Fatalf("total=%d, want 1250", m.total)
+	}
+	if _, ok := m.last["b"]; ok {
+		t.Error("vanished container baseline should be dropped")
+	}
+}
+
+func TestMonotonicCounter_ClampsImplausibleDelta(t *testing.T) {
+	var m monotonicCounter
+	m.observe(map[string]uint64{"a": 0}, 0)
+	obs := m.observe(map[string]uint64{"a": ^uint64(0) / 2}, time.Second)
+	if !obs.clamped {
+		t.Error("expected clamped delta")
+	}
+	if m.total != uint64(maxContainerNetRate) {
+		t.Errorf("total=%d, want %d", m.total, uint64(maxContainerNetRate))
+	}
+}
+
+func TestMonotonicCounter_SaturatesOnOverflow(t *testing.T) {
+	m := monotonicCounter{total: ^uint64(0) - 10}
+	m.add(100)
+	if m.total != ^uint64(0) {
+		t.Errorf("total=%d, want saturated max", m.total)
+	}
+}
+
+func TestHostCounters_Quality(t *testing.T) {
+	var h hostCounters
+	now := time.Now()
+	if q := h.observe(now, map[string]uint64{"a": 100}, map[string]uint64{"a": 100}); q != AggregationQualityOK {
+		t.Errorf("first round quality=%q, want ok", q)
+	}
+	now = now.Add(10 * time.Second)
+	if q := h.observe(now, map[string]uint64{"a": 5}, map[string]uint64{"a": 200}); q != AggregationQualityCounterReset {
+		t.Errorf("quality=%q, want counter_reset", q)
+	}
+	now = now.Add(time.Second)
+	if q := h.observe(now, map[string]uint64{"a": 5}, map[string]uint64{"a": 200 + 2*uint64(maxContainerNetRate)}); q != AggregationQualityClamped {
+		t.Errorf("quality=%q, want clamped", q)
+	}
+}
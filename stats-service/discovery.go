@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// discoveryReconcileInterval re-lists running containers periodically to
+// catch anything the event stream missed (e.g. while reconnecting)
+const discoveryReconcileInterval = 60 * time.Second
+
+// DiscoveryConfig selects which containers an auto-discovering host streams.
+// Selectors are "key" (label present) or "key=value". A container is streamed
+// if it matches any include selector (or there are none) and no exclude
+// selector.
+type DiscoveryConfig struct {
+	IncludeLabels []string `json:"include_labels,omitempty"`
+	ExcludeLabels []string `json:"exclude_labels,omitempty"`
+}
+
+// labelSelector is one parsed include/exclude entry.
+type labelSelector struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+func (s labelSelector) matches(labels map[string]string) bool {
+	v, ok := labels[s.key]
+	if !ok {
+		return false
+	}
+	return !s.hasValue || v == s.value
+}
+
+// labelFilter is the compiled form of a DiscoveryConfig.
+type labelFilter struct {
+	include []labelSelector
+	exclude []labelSelector
+}
+
+// newLabelFilter validates and compiles a discovery config.
+func newLabelFilter(cfg DiscoveryConfig) (*labelFilter, error) {
+	include, err := parseLabelSelectors(cfg.IncludeLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include_labels: %v", err)
+	}
+	exclude, err := parseLabelSelectors(cfg.ExcludeLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude_labels: %v", err)
+	}
+	return &labelFilter{include: include, exclude: exclude}, nil
+}
+
+func parseLabelSelectors(raw []string) ([]labelSelector, error) {
+	selectors := make([]labelSelector, 0, len(raw))
+	for _, entry := range raw {
+		key, value, hasValue := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("empty label key in %q", entry)
+		}
+		selectors = append(selectors, labelSelector{key: key, value: value, hasValue: hasValue})
+	}
+	return selectors, nil
+}
+
+// matches reports whether a container with these labels should be streamed.
+func (f *labelFilter) matches(labels map[string]string) bool {
+	for _, s := range f.exclude {
+		if s.matches(labels) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, s := range f.include {
+		if s.matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerDiscovery runs stats streams for hosts registered with
+// auto_discover: instead of Python calling /api/streams/start per container,
+// the service lists running containers itself and follows the host's Docker
+// events to start and stop streams. Streams it didn't start are left alone.
+type ContainerDiscovery struct {
+	ctx     context.Context
+	streams *StreamManager
+	mu      sync.Mutex
+	hosts   map[string]*discoveryHost // key: hostID
+}
+
+// discoveryHost is the discovery loop for a single host.
+type discoveryHost struct {
+	hostID string
+	config DiscoveryConfig
+	filter *labelFilter
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// owned holds the short IDs of the containers whose streams this loop
+	// started. Only touched by the loop goroutine.
+	owned map[string]bool
+}
+
+// NewContainerDiscovery creates a discovery manager. Streams it starts are
+// bound to ctx.
+func NewContainerDiscovery(ctx context.Context, streams *StreamManager) *ContainerDiscovery {
+	return &ContainerDiscovery{
+		ctx:     ctx,
+		streams: streams,
+		hosts:   make(map[string]*discoveryHost),
+	}
+}
+
+// Enable starts auto-discovery for a host, or restarts it if the filters
+// changed. Enabling again with the same filters is a no-op.
+func (d *ContainerDiscovery) Enable(hostID string, cfg DiscoveryConfig) error {
+	filter, err := newLabelFilter(cfg)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.hosts[hostID]; ok {
+		if slices.Equal(existing.config.IncludeLabels, cfg.IncludeLabels) &&
+			slices.Equal(existing.config.ExcludeLabels, cfg.ExcludeLabels) {
+			return nil
+		}
+		existing.stop()
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx) // #nosec G118
+	host := &discoveryHost{
+		hostID: hostID,
+		config: cfg,
+		filter: filter,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		owned:  make(map[string]bool),
+	}
+	d.hosts[hostID] = host
+	go d.run(ctx, host)
+
+	log.Printf("Enabled container auto-discovery for host %s (%s)", d.streams.getHostName(hostID), truncateID(hostID, 8))
+	return nil
+}
+
+// Disable stops auto-discovery for a host and the streams it started.
+func (d *ContainerDiscovery) Disable(hostID string) {
+	d.mu.Lock()
+	host, ok := d.hosts[hostID]
+	delete(d.hosts, hostID)
+	d.mu.Unlock()
+
+	if ok {
+		host.stop()
+		log.Printf("Disabled container auto-discovery for host %s (%s)", d.streams.getHostName(hostID), truncateID(hostID, 8))
+	}
+}
+
+// StopAll stops discovery on every host.
+func (d *ContainerDiscovery) StopAll() {
+	d.mu.Lock()
+	hosts := d.hosts
+	d.hosts = make(map[string]*discoveryHost)
+	d.mu.Unlock()
+
+	for _, host := range hosts {
+		host.stop()
+	}
+}
+
+// GetHostCount returns the number of hosts with auto-discovery enabled
+func (d *ContainerDiscovery) GetHostCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.hosts)
+}
+
+// stop cancels the loop and waits for it to stop its streams.
+func (h *discoveryHost) stop() {
+	h.cancel()
+	<-h.done
+}
+
+// run keeps the host's streams in line with its running containers until
+// ctx is cancelled, reconnecting with backoff when the event stream fails.
+func (d *ContainerDiscovery) run(ctx context.Context, host *discoveryHost) {
+	defer close(host.done)
+	defer func() {
+		for id := range host.owned {
+			d.streams.StopStream(id, host.hostID)
+		}
+	}()
+
+	backoff := time.Second
+	maxBackoff := 30 * time.Second
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Re-read the client each time: it is replaced if the host's
+		// connection settings change
+		cli, ok := d.streams.getClient(host.hostID)
+		if !ok {
+			if !sleepCtx(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		connected, err := d.follow(ctx, cli, host)
+		if connected {
+			// Reset backoff once a subscription got through
+			backoff = time.Second
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Container discovery for host %s (%s) failed: %v (retrying in %v)",
+				d.streams.getHostName(host.hostID), truncateID(host.hostID, 8), err, backoff)
+			if !sleepCtx(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}
+}
+
+// follow subscribes to container events, reconciles, and applies events
+// until the stream fails or ctx is cancelled. connected reports whether the
+// initial reconcile succeeded.
+func (d *ContainerDiscovery) follow(ctx context.Context, cli *client.Client, host *discoveryHost) (connected bool, err error) {
+	eventFilters := filters.NewArgs()
+	eventFilters.Add("type", "container")
+	eventFilters.Add("event", "start")
+	eventFilters.Add("event", "die")
+	eventFilters.Add("event", "destroy")
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribe before listing so nothing between the two is missed
+	eventsChan, errChan := cli.Events(streamCtx, events.ListOptions{Filters: eventFilters})
+
+	if err := d.reconcile(ctx, cli, host); err != nil {
+		return false, err
+	}
+
+	ticker := time.NewTicker(discoveryReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, nil
+		case <-ticker.C:
+			if err := d.reconcile(ctx, cli, host); err != nil {
+				return true, err
+			}
+		case err := <-errChan:
+			if err == nil {
+				err = fmt.Errorf("event stream closed")
+			}
+			return true, err
+		case event := <-eventsChan:
+			d.applyEvent(host, event)
+		}
+	}
+}
+
+// reconcile lists running containers and starts/stops owned streams to match.
+func (d *ContainerDiscovery) reconcile(ctx context.Context, cli *client.Client, host *discoveryHost) error {
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var running []container.Summary
+	err := d.streams.limits.Call(listCtx, host.hostID, func() error {
+		var err error
+		running, err = cli.ContainerList(listCtx, container.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Ensure every wanted stream, not just new ones: StartStream is a no-op
+	// for running streams and restores any that were stopped externally
+	wanted, stop := planDiscovery(running, host.owned, host.filter)
+	debugf("Container discovery for host %s: %d running, %d wanted, %d to stop",
+		truncateID(host.hostID, 8), len(running), len(wanted), len(stop))
+	for _, c := range wanted {
+		d.startOwned(host, truncateID(c.ID, 12), containerName(c.Names), dockerpkg.ContainerGroup(c.Labels))
+	}
+	for _, id := range stop {
+		d.stopOwned(host, id)
+	}
+	return nil
+}
+
+// applyEvent starts or stops a stream for a single container event. Docker
+// includes the container's labels in the event attributes.
+func (d *ContainerDiscovery) applyEvent(host *discoveryHost, event events.Message) {
+	id := truncateID(event.Actor.ID, 12)
+
+	switch event.Action {
+	case events.ActionStart:
+		if host.filter.matches(event.Actor.Attributes) {
+			d.startOwned(host, id, event.Actor.Attributes["name"], dockerpkg.ContainerGroup(event.Actor.Attributes))
+		}
+	case events.ActionDie, events.ActionDestroy:
+		if host.owned[id] {
+			d.stopOwned(host, id)
+		}
+	}
+}
+
+func (d *ContainerDiscovery) startOwned(host *discoveryHost, id, name, group string) {
+	d.streams.cache.SetContainerGroup(id, host.hostID, group)
+	if err := d.streams.StartStream(d.ctx, id, name, host.hostID); err != nil {
+		log.Printf("Container discovery: failed to start stream for %s: %v", id, err)
+		return
+	}
+	host.owned[id] = true
+}
+
+func (d *ContainerDiscovery) stopOwned(host *discoveryHost, id string) {
+	d.streams.StopStream(id, host.hostID)
+	delete(host.owned, id)
+}
+
+// planDiscovery compares the running containers against the owned streams.
+// Returns the containers that should be streamed and the owned short IDs
+// that should no longer be.
+func planDiscovery(running []container.Summary, owned map[string]bool, filter *labelFilter) (wanted []container.Summary, stop []string) {
+	wantedIDs := make(map[string]bool, len(running))
+	for _, c := range running {
+		if !filter.matches(c.Labels) {
+			continue
+		}
+		wantedIDs[truncateID(c.ID, 12)] = true
+		wanted = append(wanted, c)
+	}
+	for id := range owned {
+		if !wantedIDs[id] {
+			stop = append(stop, id)
+		}
+	}
+	slices.Sort(stop)
+	return wanted, stop
+}
+
+// containerName returns a container's primary name without the leading slash
+func containerName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(names[0], "/")
+}
+
+// sleepCtx waits for d or until ctx is cancelled. Returns false if cancelled.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
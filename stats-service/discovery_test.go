@@ -0,0 +1,93 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestLabelFilter_Matches(t *testing.T) {
+	filter, err := newLabelFilter(DiscoveryConfig{
+		IncludeLabels: []string{"com.docker.compose.project=web", "dockmon.monitor"},
+		ExcludeLabels: []string{"dockmon.monitor=false"},
+	})
+	if err != nil {
+		t.Fatalf("newLabelFilter: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"include by key=value", map[string]string{"com.docker.compose.project": "web"}, true},
+		{"include value mismatch", map[string]string{"com.docker.compose.project": "db"}, false},
+		{"include by key only", map[string]string{"dockmon.monitor": "yes"}, true},
+		{"exclude wins over include", map[string]string{"com.docker.compose.project": "web", "dockmon.monitor": "false"}, false},
+		{"no labels", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.matches(tt.labels); got != tt.want {
+				t.Errorf("matches(%v) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelFilter_EmptyIncludeMatchesAll(t *testing.T) {
+	filter, err := newLabelFilter(DiscoveryConfig{ExcludeLabels: []string{"skip"}})
+	if err != nil {
+		t.Fatalf("newLabelFilter: %v", err)
+	}
+	if !filter.matches(map[string]string{"app": "x"}) {
+		t.Error("Expected unlabeled-by-exclude container to match")
+	}
+	if filter.matches(map[string]string{"skip": ""}) {
+		t.Error("Expected excluded container not to match")
+	}
+}
+
+func TestNewLabelFilter_RejectsEmptyKey(t *testing.T) {
+	if _, err := newLabelFilter(DiscoveryConfig{IncludeLabels: []string{"=web"}}); err == nil {
+		t.Error("Expected error for empty label key")
+	}
+}
+
+func TestPlanDiscovery(t *testing.T) {
+	filter, _ := newLabelFilter(DiscoveryConfig{ExcludeLabels: []string{"dockmon.monitor=false"}})
+
+	running := []container.Summary{
+		{ID: "aaaaaaaaaaaa1111", Names: []string{"/web"}},
+		{ID: "bbbbbbbbbbbb2222", Names: []string{"/db"}},
+		{ID: "cccccccccccc3333", Names: []string{"/ignored"}, Labels: map[string]string{"dockmon.monitor": "false"}},
+	}
+	owned := map[string]bool{
+		"aaaaaaaaaaaa": true, // still running
+		"dddddddddddd": true, // gone
+		"cccccccccccc": true, // now excluded
+	}
+
+	wanted, stop := planDiscovery(running, owned, filter)
+
+	var wantedIDs []string
+	for _, c := range wanted {
+		wantedIDs = append(wantedIDs, truncateID(c.ID, 12))
+	}
+	if !slices.Equal(wantedIDs, []string{"aaaaaaaaaaaa", "bbbbbbbbbbbb"}) {
+		t.Errorf("wanted = %v", wantedIDs)
+	}
+	if !slices.Equal(stop, []string{"cccccccccccc", "dddddddddddd"}) {
+		t.Errorf("stop = %v", stop)
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	if got := containerName([]string{"/web-1"}); got != "web-1" {
+		t.Errorf("containerName = %q, want web-1", got)
+	}
+	if got := containerName(nil); got != "" {
+		t.Errorf("containerName(nil) = %q, want empty", got)
+	}
+}
@@ -3,33 +3,160 @@ package main
 import (
 	"encoding/json"
 	"log"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// eventWriteTimeout bounds a single broadcast write so one stalled client can't
-// hold the per-connection lock and freeze event delivery to everyone else.
-const eventWriteTimeout = 5 * time.Second
+const (
+	// eventWriteTimeout bounds a single write so one stalled client can't
+	// hold its writer forever.
+	eventWriteTimeout = 5 * time.Second
 
-// EventBroadcaster manages WebSocket connections and broadcasts events
+	// eventPongWait is how long a client may stay silent (no pong or other
+	// frame) before it is considered dead.
+	eventPongWait = 60 * time.Second
+
+	// eventPingPeriod must be shorter than eventPongWait so a healthy client
+	// always has a ping to answer before its read deadline expires.
+	eventPingPeriod = (eventPongWait * 9) / 10
+
+	// eventSendQueueSize is the per-connection backlog. When full, the oldest
+	// queued event is dropped to make room for the newest.
+	eventSendQueueSize = 256
+
+	// eventEvictAfterDrops evicts a client once this many events in a row
+	// were dropped for it without a successful write in between.
+	eventEvictAfterDrops = 512
+
+	// eventMaxReadBytes caps client->server frames; clients only send pongs,
+	// close frames and small subscribe messages on this socket.
+	eventMaxReadBytes = 4096
+)
+
+// eventClient is a single WebSocket consumer with its own send queue.
+// Only its writer goroutine writes to conn.
+type eventClient struct {
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	queueMu          sync.Mutex // Serialises drop-oldest enqueues from concurrent broadcasts
+	consecutiveDrops int        // Guarded by queueMu
+
+	replayedThrough uint64 // Live events at or below this cursor were already replayed
+
+	filter atomic.Pointer[eventFilter] // nil = every event (no subscription yet)
+}
+
+func newEventClient(conn *websocket.Conn, queueSize int) *eventClient {
+	return &eventClient{
+		conn: conn,
+		send: make(chan []byte, queueSize),
+		done: make(chan struct{}),
+	}
+}
+
+// enqueue queues data without blocking. If the queue is full the oldest
+// message is dropped. Returns whether a message was dropped and the number
+// of consecutive drops so far.
+func (c *eventClient) enqueue(data []byte) (dropped bool, consecutive int) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	select {
+	case c.send <- data:
+		return false, c.consecutiveDrops
+	default:
+	}
+
+	// Queue full: drop the oldest and retry. The writer may have drained a
+	// slot meanwhile, in which case nothing is dropped.
+	select {
+	case <-c.send:
+		dropped = true
+	default:
+	}
+	select {
+	case c.send <- data:
+	default:
+		dropped = true
+	}
+
+	if dropped {
+		c.consecutiveDrops++
+	}
+	return dropped, c.consecutiveDrops
+}
+
+// markDelivered resets the consecutive drop counter after a successful write.
+func (c *eventClient) markDelivered() {
+	c.queueMu.Lock()
+	c.consecutiveDrops = 0
+	c.queueMu.Unlock()
+}
+
+// close stops the writer and closes the socket. Safe to call repeatedly.
+func (c *eventClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// EventBroadcasterStats are the counters exposed under /debug/stats.
+type EventBroadcasterStats struct {
+	Connections       int    `json:"connections"`
+	EventsSent        uint64 `json:"events_sent"`
+	EventsDropped     uint64 `json:"events_dropped"`
+	ClientsEvicted    uint64 `json:"clients_evicted"`
+	HeartbeatTimeouts uint64 `json:"heartbeat_timeouts"`
+	WriteErrors       uint64 `json:"write_errors"`
+}
+
+// EventBroadcaster manages WebSocket connections and broadcasts events.
+// Each connection has a bounded send queue drained by its own writer, so a
+// slow consumer never blocks delivery to the others.
 type EventBroadcaster struct {
 	mu             sync.RWMutex
-	connections    map[*websocket.Conn]*sync.Mutex // Each connection has its own write mutex
+	connections    map[*websocket.Conn]*eventClient
 	maxConnections int
+
+	eventsSent        atomic.Uint64
+	eventsDropped     atomic.Uint64
+	clientsEvicted    atomic.Uint64
+	heartbeatTimeouts atomic.Uint64
+	writeErrors       atomic.Uint64
 }
 
 // NewEventBroadcaster creates a new event broadcaster
 func NewEventBroadcaster() *EventBroadcaster {
 	return &EventBroadcaster{
-		connections:    make(map[*websocket.Conn]*sync.Mutex),
+		connections:    make(map[*websocket.Conn]*eventClient),
 		maxConnections: 100, // Limit to 100 concurrent WebSocket connections
 	}
 }
 
-// AddConnection registers a new WebSocket connection
+// ReplayFunc returns the events to replay to a new connection and the cursor
+// they are current through.
+type ReplayFunc func() (events []DockerEvent, cursor uint64)
+
+// AddConnection registers a new WebSocket connection and starts its writer.
+// The caller must run ReadLoop for the connection so pongs are processed.
 func (eb *EventBroadcaster) AddConnection(conn *websocket.Conn) error {
+	return eb.AddConnectionWithReplay(conn, nil)
+}
+
+// AddConnectionWithReplay registers a connection like AddConnection, first
+// queueing the events returned by replay followed by a replay_complete marker.
+// Broadcasts are held off while replay runs, so nothing is missed between
+// replay and live mode, and live events already replayed are skipped.
+func (eb *EventBroadcaster) AddConnectionWithReplay(conn *websocket.Conn, replay ReplayFunc) error {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
@@ -39,20 +166,156 @@ func (eb *EventBroadcaster) AddConnection(conn *websocket.Conn) error {
 		return &websocket.CloseError{Code: websocket.ClosePolicyViolation, Text: "Connection limit reached"}
 	}
 
-	eb.connections[conn] = &sync.Mutex{} // Create a dedicated mutex for this connection
+	var client *eventClient
+	if replay == nil {
+		client = newEventClient(conn, eventSendQueueSize)
+	} else {
+		events, cursor := replay()
+		// Size the queue so the replay never triggers drop-oldest
+		client = newEventClient(conn, eventSendQueueSize+len(events)+1)
+		client.replayedThrough = cursor
+		for _, event := range events {
+			if data, err := json.Marshal(event); err == nil {
+				client.send <- data
+			}
+		}
+		marker, _ := json.Marshal(DockerEvent{
+			Action:     "replay_complete",
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			Attributes: map[string]string{"replayed": strconv.Itoa(len(events))},
+			Seq:        cursor,
+		})
+		client.send <- marker
+	}
+
+	eb.connections[conn] = client
+	go eb.writeLoop(client)
+
 	log.Printf("WebSocket connected to events. Total connections: %d", len(eb.connections))
 	return nil
 }
 
-// RemoveConnection unregisters a WebSocket connection
+// RemoveConnection unregisters a WebSocket connection and closes it
 func (eb *EventBroadcaster) RemoveConnection(conn *websocket.Conn) {
 	eb.mu.Lock()
-	defer eb.mu.Unlock()
+	client, exists := eb.connections[conn]
 	delete(eb.connections, conn)
-	log.Printf("WebSocket disconnected from events. Total connections: %d", len(eb.connections))
+	remaining := len(eb.connections)
+	eb.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	client.close()
+	log.Printf("WebSocket disconnected from events. Total connections: %d", remaining)
 }
 
-// Broadcast sends an event to all connected WebSocket clients
+// ReadLoop consumes client frames until the connection fails or the client
+// misses heartbeats, then unregisters it. Blocks; run it per connection.
+func (eb *EventBroadcaster) ReadLoop(conn *websocket.Conn) {
+	defer eb.RemoveConnection(conn)
+
+	conn.SetReadLimit(eventMaxReadBytes)
+	conn.SetReadDeadline(time.Now().Add(eventPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(eventPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+				eb.heartbeatTimeouts.Add(1)
+				log.Printf("Event WebSocket missed heartbeat, closing")
+			}
+			return
+		}
+		// Any client frame proves liveness, not just pongs
+		conn.SetReadDeadline(time.Now().Add(eventPongWait))
+		eb.handleClientMessage(conn, data)
+	}
+}
+
+// handleClientMessage applies subscribe messages to the connection's filter
+// and acknowledges them (or reports why they were rejected).
+func (eb *EventBroadcaster) handleClientMessage(conn *websocket.Conn, data []byte) {
+	eb.mu.RLock()
+	client := eb.connections[conn]
+	eb.mu.RUnlock()
+	if client == nil {
+		return
+	}
+
+	sub, ok, err := parseEventSubscription(data)
+	if err == nil && !ok {
+		return // Not a subscription; nothing else is accepted from clients
+	}
+
+	var filter *eventFilter
+	if err == nil {
+		filter, err = newEventFilter(sub)
+	}
+	if err != nil {
+		eb.sendControlEvent(client, "subscription_error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	client.filter.Store(filter)
+	eb.sendControlEvent(client, "subscribed", map[string]string{
+		"hosts":                strings.Join(sub.Hosts, ","),
+		"types":                strings.Join(sub.Types, ","),
+		"actions":              strings.Join(sub.Actions, ","),
+		"container_name_regex": sub.ContainerNameRegex,
+	})
+}
+
+// sendControlEvent queues a server-generated event (not a Docker event) for
+// a single client.
+func (eb *EventBroadcaster) sendControlEvent(client *eventClient, action string, attributes map[string]string) {
+	data, err := json.Marshal(DockerEvent{
+		Action:     action,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Attributes: attributes,
+	})
+	if err != nil {
+		return
+	}
+	client.enqueue(data)
+}
+
+// writeLoop drains the client's queue and sends periodic pings.
+func (eb *EventBroadcaster) writeLoop(client *eventClient) {
+	ticker := time.NewTicker(eventPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+
+		case data := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("Error sending event to WebSocket: %v", err)
+				eb.writeErrors.Add(1)
+				eb.RemoveConnection(client.conn)
+				return
+			}
+			client.markDelivered()
+			eb.eventsSent.Add(1)
+
+		case <-ticker.C:
+			if err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventWriteTimeout)); err != nil {
+				eb.writeErrors.Add(1)
+				eb.RemoveConnection(client.conn)
+				return
+			}
+		}
+	}
+}
+
+// Broadcast queues an event for all connected WebSocket clients. It never
+// blocks on network I/O.
 func (eb *EventBroadcaster) Broadcast(event DockerEvent) {
 	// Marshal event to JSON
 	data, err := json.Marshal(event)
@@ -61,51 +324,40 @@ func (eb *EventBroadcaster) Broadcast(event DockerEvent) {
 		return
 	}
 
-	// Track dead connections
-	var deadConnections []*websocket.Conn
-
-	// Get snapshot of connections with their mutexes
 	eb.mu.RLock()
-	connMutexes := make(map[*websocket.Conn]*sync.Mutex, len(eb.connections))
-	for conn, mu := range eb.connections {
-		connMutexes[conn] = mu
+	clients := make([]*eventClient, 0, len(eb.connections))
+	for _, client := range eb.connections {
+		clients = append(clients, client)
 	}
 	eb.mu.RUnlock()
 
-	// Send to all connections (with per-connection write lock)
-	for conn, mu := range connMutexes {
-		mu.Lock()
-		err := conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
-		if err == nil {
-			err = conn.WriteMessage(websocket.TextMessage, data)
+	var slowClients []*eventClient
+	for _, client := range clients {
+		if event.Seq != 0 && event.Seq <= client.replayedThrough {
+			continue // Already delivered during replay
 		}
-		mu.Unlock()
-
-		if err != nil {
-			log.Printf("Error sending event to WebSocket: %v", err)
-			deadConnections = append(deadConnections, conn)
+		if !client.filter.Load().matches(event) {
+			continue
 		}
-	}
-
-	// Clean up dead connections
-	if len(deadConnections) > 0 {
-		// Remove from map first (fast, under lock)
-		eb.mu.Lock()
-		var connectionsToClose []*websocket.Conn
-		for _, conn := range deadConnections {
-			// Only delete if connection still exists in map
-			if _, exists := eb.connections[conn]; exists {
-				delete(eb.connections, conn)
-				connectionsToClose = append(connectionsToClose, conn)
-			}
+		dropped, consecutive := client.enqueue(data)
+		if !dropped {
+			continue
 		}
-		eb.mu.Unlock()
-
-		// Close connections outside lock (slow, can block)
-		for _, conn := range connectionsToClose {
-			conn.Close()
+		eb.eventsDropped.Add(1)
+		if consecutive >= eventEvictAfterDrops {
+			slowClients = append(slowClients, client)
 		}
 	}
+
+	// Evict consumers that can't keep up
+	for _, client := range slowClients {
+		log.Printf("Evicting slow event WebSocket consumer after %d dropped events", eventEvictAfterDrops)
+		eb.clientsEvicted.Add(1)
+		client.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Consumer too slow"),
+			time.Now().Add(time.Second))
+		eb.RemoveConnection(client.conn)
+	}
 }
 
 // GetConnectionCount returns the number of active WebSocket connections
@@ -115,19 +367,31 @@ func (eb *EventBroadcaster) GetConnectionCount() int {
 	return len(eb.connections)
 }
 
+// GetStats returns the broadcaster's delivery counters
+func (eb *EventBroadcaster) GetStats() EventBroadcasterStats {
+	return EventBroadcasterStats{
+		Connections:       eb.GetConnectionCount(),
+		EventsSent:        eb.eventsSent.Load(),
+		EventsDropped:     eb.eventsDropped.Load(),
+		ClientsEvicted:    eb.clientsEvicted.Load(),
+		HeartbeatTimeouts: eb.heartbeatTimeouts.Load(),
+		WriteErrors:       eb.writeErrors.Load(),
+	}
+}
+
 // CloseAll closes all WebSocket connections
 func (eb *EventBroadcaster) CloseAll() {
 	eb.mu.Lock()
-	var connectionsToClose []*websocket.Conn
-	for conn := range eb.connections {
-		connectionsToClose = append(connectionsToClose, conn)
+	var clientsToClose []*eventClient
+	for _, client := range eb.connections {
+		clientsToClose = append(clientsToClose, client)
 	}
-	eb.connections = make(map[*websocket.Conn]*sync.Mutex)
+	eb.connections = make(map[*websocket.Conn]*eventClient)
 	eb.mu.Unlock()
 
 	// Close connections outside lock (can block on network I/O)
-	for _, conn := range connectionsToClose {
-		conn.Close()
+	for _, client := range clientsToClose {
+		client.close()
 	}
 
 	log.Println("Closed all event WebSocket connections")
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEventClientEnqueueDropsOldest(t *testing.T) {
+	client := newEventClient(nil, 2)
+
+	for _, msg := range []string{"a", "b"} {
+		if dropped, _ := client.enqueue([]byte(msg)); dropped {
+			t.Fatalf("enqueue(%q) dropped with free queue space", msg)
+		}
+	}
+
+	dropped, consecutive := client.enqueue([]byte("c"))
+	if !dropped || consecutive != 1 {
+		t.Fatalf("enqueue on full queue = (%v, %d), want (true, 1)", dropped, consecutive)
+	}
+
+	// Oldest ("a") must be gone; order of the rest preserved
+	if got := string(<-client.send); got != "b" {
+		t.Errorf("first queued = %q, want b", got)
+	}
+	if got := string(<-client.send); got != "c" {
+		t.Errorf("second queued = %q, want c", got)
+	}
+}
+
+func TestEventClientMarkDeliveredResetsDrops(t *testing.T) {
+	client := newEventClient(nil, 1)
+	client.enqueue([]byte("a"))
+	client.enqueue([]byte("b"))
+	client.enqueue([]byte("c"))
+
+	if client.consecutiveDrops != 2 {
+		t.Fatalf("consecutiveDrops = %d, want 2", client.consecutiveDrops)
+	}
+
+	client.markDelivered()
+	if client.consecutiveDrops != 0 {
+		t.Errorf("consecutiveDrops after delivery = %d, want 0", client.consecutiveDrops)
+	}
+}
+
+// startBroadcasterServer serves /ws/events-style connections backed by eb.
+func startBroadcasterServer(t *testing.T, eb *EventBroadcaster) *websocket.Conn {
+	t.Helper()
+	return startReplayServer(t, eb, nil)
+}
+
+// startReplayServer is startBroadcasterServer with replay on connect.
+func startReplayServer(t *testing.T, eb *EventBroadcaster, replay ReplayFunc) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if err := eb.AddConnectionWithReplay(conn, replay); err != nil {
+			conn.Close()
+			return
+		}
+		go eb.ReadLoop(conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitForConnections(t *testing.T, eb *EventBroadcaster, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for eb.GetConnectionCount() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection count = %d, want %d", eb.GetConnectionCount(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventBroadcasterDeliversEvents(t *testing.T) {
+	eb := NewEventBroadcaster()
+	defer eb.CloseAll()
+	conn := startBroadcasterServer(t, eb)
+	waitForConnections(t, eb, 1)
+
+	eb.Broadcast(DockerEvent{Action: "start", ContainerID: "abc123", HostID: "h1"})
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var event DockerEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if event.Action != "start" || event.ContainerID != "abc123" {
+		t.Errorf("got %+v, want start/abc123", event)
+	}
+
+	stats := eb.GetStats()
+	if stats.Connections != 1 || stats.EventsSent != 1 || stats.EventsDropped != 0 {
+		t.Errorf("stats = %+v, want 1 connection / 1 sent / 0 dropped", stats)
+	}
+}
+
+func TestEventBroadcasterEvictsSlowConsumer(t *testing.T) {
+	eb := NewEventBroadcaster()
+	defer eb.CloseAll()
+	startBroadcasterServer(t, eb)
+	waitForConnections(t, eb, 1)
+
+	// Stop the writer so nothing drains the queue, simulating a stalled client
+	eb.mu.RLock()
+	for _, client := range eb.connections {
+		client.closeOnce.Do(func() { close(client.done) })
+	}
+	eb.mu.RUnlock()
+
+	for i := 0; i < eventSendQueueSize+eventEvictAfterDrops; i++ {
+		eb.Broadcast(DockerEvent{Action: "die", ContainerID: "abc123"})
+	}
+
+	stats := eb.GetStats()
+	if stats.ClientsEvicted != 1 {
+		t.Errorf("ClientsEvicted = %d, want 1", stats.ClientsEvicted)
+	}
+	if stats.EventsDropped != eventEvictAfterDrops {
+		t.Errorf("EventsDropped = %d, want %d", stats.EventsDropped, eventEvictAfterDrops)
+	}
+	if stats.Connections != 0 {
+		t.Errorf("Connections = %d, want 0 after eviction", stats.Connections)
+	}
+}
+
+func TestEventBroadcasterReplaysBeforeLive(t *testing.T) {
+	ec := NewEventCache(10)
+	ec.AddEvent("h1", DockerEvent{Action: "create"})
+	ec.AddEvent("h1", DockerEvent{Action: "start"})
+	replayed := ec.AddEvent("h1", DockerEvent{Action: "health_status"})
+
+	eb := NewEventBroadcaster()
+	defer eb.CloseAll()
+	conn := startReplayServer(t, eb, func() ([]DockerEvent, uint64) {
+		return ec.GetEventsSince(1, time.Time{})
+	})
+	waitForConnections(t, eb, 1)
+
+	// A live broadcast of an already-replayed event must not be delivered twice
+	eb.Broadcast(replayed)
+	eb.Broadcast(ec.AddEvent("h1", DockerEvent{Action: "die"}))
+
+	var actions []string
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(actions) < 4 {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read after %v: %v", actions, err)
+		}
+		var event DockerEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		actions = append(actions, event.Action)
+		if event.Action == "replay_complete" && (event.Seq != 3 || event.Attributes["replayed"] != "2") {
+			t.Errorf("marker = %+v, want seq 3 / replayed 2", event)
+		}
+	}
+
+	want := []string{"start", "health_status", "replay_complete", "die"}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("actions = %v, want %v", actions, want)
+		}
+	}
+}
+
+func TestEventBroadcasterAppliesSubscription(t *testing.T) {
+	eb := NewEventBroadcaster()
+	defer eb.CloseAll()
+	conn := startBroadcasterServer(t, eb)
+	waitForConnections(t, eb, 1)
+
+	if err := conn.WriteJSON(EventSubscription{Type: "subscribe", Hosts: []string{"h2"}}); err != nil {
+		t.Fatalf("write subscribe: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack DockerEvent
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("read ack: %v", err)
+	}
+	if ack.Action != "subscribed" || ack.Attributes["hosts"] != "h2" {
+		t.Fatalf("ack = %+v, want subscribed for h2", ack)
+	}
+
+	eb.Broadcast(DockerEvent{Action: "start", HostID: "h1"})
+	eb.Broadcast(DockerEvent{Action: "stop", HostID: "h2"})
+
+	var event DockerEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if event.HostID != "h2" || event.Action != "stop" {
+		t.Errorf("got %+v, want only the h2 event", event)
+	}
+}
@@ -1,41 +1,181 @@
 package main
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
-// EventCache stores recent events for each host (ring buffer)
+// MaxEventCacheSize caps any per-host cache size, so a typo in
+// EVENT_CACHE_HOST_SIZES or the limits endpoint can't exhaust memory.
+const MaxEventCacheSize = 100000
+
+// EventCache stores recent events for each host (ring buffer). Each host has
+// its own size limit, so a noisy host can't evict a quiet host's events.
 type EventCache struct {
-	mu       sync.RWMutex
-	events   map[string][]DockerEvent // key: hostID, value: ring buffer of events
-	maxSize  int                      // maximum events to keep per host
+	mu        sync.RWMutex
+	events    map[string][]DockerEvent // key: hostID, value: ring buffer of events
+	maxSize   int                      // default maximum events to keep per host
+	hostSizes map[string]int           // per-host overrides of maxSize
+	evicted   map[string]uint64        // events dropped per host to stay within its limit
+	lastSeq   uint64                   // sequence of the most recently added event
+}
+
+// EventCacheHostStats describes one host's slice of the event cache
+type EventCacheHostStats struct {
+	Events      int    `json:"events"`
+	Limit       int    `json:"limit"`
+	Evicted     uint64 `json:"evicted"`
+	OldestEvent string `json:"oldest_event,omitempty"`
 }
 
 // NewEventCache creates a new event cache
 func NewEventCache(maxSize int) *EventCache {
 	return &EventCache{
-		events:  make(map[string][]DockerEvent),
-		maxSize: maxSize,
+		events:    make(map[string][]DockerEvent),
+		maxSize:   maxSize,
+		hostSizes: make(map[string]int),
+		evicted:   make(map[string]uint64),
+	}
+}
+
+// limitLocked returns the size limit for a host. Caller must hold ec.mu.
+func (ec *EventCache) limitLocked(hostID string) int {
+	if size, ok := ec.hostSizes[hostID]; ok {
+		return size
+	}
+	return ec.maxSize
+}
+
+// trimLocked drops a host's oldest events beyond its limit, counting them
+// as evicted. Caller must hold ec.mu for writing.
+func (ec *EventCache) trimLocked(hostID string) {
+	events := ec.events[hostID]
+	limit := ec.limitLocked(hostID)
+	if len(events) <= limit {
+		return
 	}
+	dropped := len(events) - limit
+	ec.evicted[hostID] += uint64(dropped)
+	// Copy rather than reslice so a shrunk limit releases the old array
+	trimmed := make([]DockerEvent, limit)
+	copy(trimmed, events[dropped:])
+	ec.events[hostID] = trimmed
 }
 
-// AddEvent adds an event to the cache for a specific host
-func (ec *EventCache) AddEvent(hostID string, event DockerEvent) {
+// SetDefaultSize changes the limit for hosts without an override, trimming
+// cached events that no longer fit.
+func (ec *EventCache) SetDefaultSize(size int) {
 	ec.mu.Lock()
 	defer ec.mu.Unlock()
+	ec.maxSize = size
+	for hostID := range ec.events {
+		ec.trimLocked(hostID)
+	}
+}
+
+// SetHostSize overrides the limit for one host. A size of 0 removes the
+// override so the host falls back to the default.
+func (ec *EventCache) SetHostSize(hostID string, size int) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if size <= 0 {
+		delete(ec.hostSizes, hostID)
+	} else {
+		ec.hostSizes[hostID] = size
+	}
+	if _, exists := ec.events[hostID]; exists {
+		ec.trimLocked(hostID)
+	}
+}
+
+// Limits returns the default size and a copy of the per-host overrides
+func (ec *EventCache) Limits() (defaultSize int, hostSizes map[string]int) {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	hostSizes = make(map[string]int, len(ec.hostSizes))
+	for hostID, size := range ec.hostSizes {
+		hostSizes[hostID] = size
+	}
+	return ec.maxSize, hostSizes
+}
+
+// AddEvent adds an event to the cache for a specific host. The event is
+// stamped with the next sequence number (its replay cursor) and returned.
+func (ec *EventCache) AddEvent(hostID string, event DockerEvent) DockerEvent {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	ec.lastSeq++
+	event.Seq = ec.lastSeq
 
 	// Initialize slice if needed
 	if _, exists := ec.events[hostID]; !exists {
-		ec.events[hostID] = make([]DockerEvent, 0, ec.maxSize)
+		ec.events[hostID] = make([]DockerEvent, 0, ec.limitLocked(hostID))
 	}
 
 	// Add event
 	ec.events[hostID] = append(ec.events[hostID], event)
 
-	// Trim if over max size (keep most recent)
-	if len(ec.events[hostID]) > ec.maxSize {
-		ec.events[hostID] = ec.events[hostID][len(ec.events[hostID])-ec.maxSize:]
+	// Trim if over the host's limit (keep most recent)
+	if limit := ec.limitLocked(hostID); len(ec.events[hostID]) > limit {
+		dropped := len(ec.events[hostID]) - limit
+		ec.evicted[hostID] += uint64(dropped)
+		ec.events[hostID] = ec.events[hostID][dropped:]
 	}
+
+	return event
+}
+
+// LastSeq returns the sequence number of the most recently added event
+func (ec *EventCache) LastSeq() uint64 {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	return ec.lastSeq
+}
+
+// GetEventsSince returns cached events from all hosts that are newer than the
+// given cursor (afterSeq) and, if since is non-zero, not older than since.
+// Events are ordered by sequence, i.e. the order they were received. Also
+// returns the cache's current cursor, taken atomically with the snapshot.
+func (ec *EventCache) GetEventsSince(afterSeq uint64, since time.Time) ([]DockerEvent, uint64) {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+
+	result := []DockerEvent{}
+	for _, events := range ec.events {
+		for _, event := range events {
+			if event.Seq <= afterSeq {
+				continue
+			}
+			if !since.IsZero() {
+				ts, err := time.Parse(time.RFC3339, event.Timestamp)
+				if err != nil || ts.Before(since) {
+					continue
+				}
+			}
+			result = append(result, event)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Seq < result[j].Seq })
+	return result, ec.lastSeq
+}
+
+// parseReplaySince parses the ?since= value of /ws/events. A plain integer is
+// a cursor (an event's seq); anything else must be an RFC3339 timestamp.
+func parseReplaySince(value string) (afterSeq uint64, since time.Time, err error) {
+	if seq, parseErr := strconv.ParseUint(value, 10, 64); parseErr == nil {
+		return seq, time.Time{}, nil
+	}
+	since, err = time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("since must be an event cursor or RFC3339 timestamp")
+	}
+	return 0, since, nil
 }
 
 // GetRecentEvents returns recent events for a specific host
@@ -86,11 +226,13 @@ func (ec *EventCache) GetAllRecentEvents(limit int) map[string][]DockerEvent {
 	return result
 }
 
-// ClearHost removes all cached events for a specific host
+// ClearHost removes all cached events for a specific host. Its size
+// override is kept so the limit survives the host being re-added.
 func (ec *EventCache) ClearHost(hostID string) {
 	ec.mu.Lock()
 	defer ec.mu.Unlock()
 	delete(ec.events, hostID)
+	delete(ec.evicted, hostID)
 }
 
 // GetStats returns cache statistics
@@ -104,3 +246,47 @@ func (ec *EventCache) GetStats() (hostCount int, totalEvents int) {
 	}
 	return
 }
+
+// HostStats returns per-host event counts, limits, eviction counters and the
+// timestamp of the oldest cached event
+func (ec *EventCache) HostStats() map[string]EventCacheHostStats {
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+
+	result := make(map[string]EventCacheHostStats, len(ec.events))
+	for hostID, events := range ec.events {
+		stats := EventCacheHostStats{
+			Events:  len(events),
+			Limit:   ec.limitLocked(hostID),
+			Evicted: ec.evicted[hostID],
+		}
+		if len(events) > 0 {
+			stats.OldestEvent = events[0].Timestamp
+		}
+		result[hostID] = stats
+	}
+	return result
+}
+
+// parseEventCacheHostSizes parses EVENT_CACHE_HOST_SIZES, a comma-separated
+// list of host_id=size pairs
+func parseEventCacheHostSizes(value string) (map[string]int, error) {
+	sizes := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		hostID, sizeStr, ok := strings.Cut(entry, "=")
+		hostID = strings.TrimSpace(hostID)
+		if !ok || hostID == "" {
+			return nil, fmt.Errorf("invalid entry %q: want host_id=size", entry)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(sizeStr))
+		if err != nil || size < 1 || size > MaxEventCacheSize {
+			return nil, fmt.Errorf("invalid size for host %s: must be between 1 and %d", hostID, MaxEventCacheSize)
+		}
+		sizes[hostID] = size
+	}
+	return sizes, nil
+}
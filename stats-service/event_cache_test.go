@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventCacheAssignsSequence(t *testing.T) {
+	ec := NewEventCache(10)
+
+	first := ec.AddEvent("h1", DockerEvent{Action: "start"})
+	second := ec.AddEvent("h2", DockerEvent{Action: "stop"})
+
+	if first.Seq != 1 || second.Seq != 2 {
+		t.Errorf("seqs = %d, %d, want 1, 2", first.Seq, second.Seq)
+	}
+	if ec.LastSeq() != 2 {
+		t.Errorf("LastSeq() = %d, want 2", ec.LastSeq())
+	}
+}
+
+func TestEventCacheGetEventsSince(t *testing.T) {
+	ec := NewEventCache(10)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ec.AddEvent("h1", DockerEvent{Action: "create", Timestamp: base.Format(time.RFC3339)})
+	ec.AddEvent("h2", DockerEvent{Action: "start", Timestamp: base.Add(time.Minute).Format(time.RFC3339)})
+	ec.AddEvent("h1", DockerEvent{Action: "die", Timestamp: base.Add(2 * time.Minute).Format(time.RFC3339)})
+
+	events, cursor := ec.GetEventsSince(1, time.Time{})
+	if cursor != 3 {
+		t.Errorf("cursor = %d, want 3", cursor)
+	}
+	if len(events) != 2 || events[0].Action != "start" || events[1].Action != "die" {
+		t.Errorf("events after cursor 1 = %+v, want start, die across hosts in order", events)
+	}
+
+	events, _ = ec.GetEventsSince(0, base.Add(2*time.Minute))
+	if len(events) != 1 || events[0].Action != "die" {
+		t.Errorf("events since timestamp = %+v, want only die", events)
+	}
+
+	events, _ = ec.GetEventsSince(3, time.Time{})
+	if len(events) != 0 {
+		t.Errorf("events after latest cursor = %+v, want none", events)
+	}
+}
+
+func TestEventCachePerHostLimits(t *testing.T) {
+	ec := NewEventCache(3)
+	ec.SetHostSize("quiet", 5)
+
+	for i := 0; i < 10; i++ {
+		ec.AddEvent("noisy", DockerEvent{Action: "exec_start", Timestamp: strconv.Itoa(i)})
+	}
+	for i := 0; i < 4; i++ {
+		ec.AddEvent("quiet", DockerEvent{Action: "start"})
+	}
+
+	stats := ec.HostStats()
+	if got := stats["noisy"]; got.Events != 3 || got.Limit != 3 || got.Evicted != 7 || got.OldestEvent != "7" {
+		t.Errorf("noisy stats = %+v, want 3 events, limit 3, 7 evicted, oldest 7", got)
+	}
+	if got := stats["quiet"]; got.Events != 4 || got.Limit != 5 || got.Evicted != 0 {
+		t.Errorf("quiet stats = %+v, want 4 events, limit 5, none evicted", got)
+	}
+
+	// Shrinking a limit trims at once and counts the dropped events
+	ec.SetHostSize("quiet", 2)
+	if got := ec.HostStats()["quiet"]; got.Events != 2 || got.Evicted != 2 {
+		t.Errorf("quiet after shrink = %+v, want 2 events, 2 evicted", got)
+	}
+
+	// Removing the override falls back to the default
+	ec.SetHostSize("quiet", 0)
+	ec.SetDefaultSize(1)
+	if got := ec.HostStats()["quiet"]; got.Events != 1 || got.Limit != 1 || got.Evicted != 3 {
+		t.Errorf("quiet after reset = %+v, want 1 event, limit 1, 3 evicted", got)
+	}
+	if _, hostSizes := ec.Limits(); len(hostSizes) != 0 {
+		t.Errorf("overrides = %v, want none", hostSizes)
+	}
+}
+
+func TestParseEventCacheHostSizes(t *testing.T) {
+	sizes, err := parseEventCacheHostSizes(" h1=500, h2 = 50 ,")
+	if err != nil || len(sizes) != 2 || sizes["h1"] != 500 || sizes["h2"] != 50 {
+		t.Errorf("parseEventCacheHostSizes = %v, %v", sizes, err)
+	}
+
+	for _, value := range []string{"h1", "=5", "h1=0", "h1=lots"} {
+		if _, err := parseEventCacheHostSizes(value); err == nil {
+			t.Errorf("parseEventCacheHostSizes(%q) accepted an invalid entry", value)
+		}
+	}
+}
+
+func TestParseReplaySince(t *testing.T) {
+	seq, since, err := parseReplaySince("42")
+	if err != nil || seq != 42 || !since.IsZero() {
+		t.Errorf("parseReplaySince(42) = %d, %v, %v", seq, since, err)
+	}
+
+	seq, since, err = parseReplaySince("2025-01-01T12:00:00Z")
+	if err != nil || seq != 0 || !since.Equal(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseReplaySince(RFC3339) = %d, %v, %v", seq, since, err)
+	}
+
+	if _, _, err := parseReplaySince("yesterday"); err == nil {
+		t.Error("expected error for invalid since value")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxContainerNameRegexLen bounds the client-supplied pattern size.
+const maxContainerNameRegexLen = 256
+
+// EventSubscription is the message a /ws/events client sends to narrow the
+// events it receives. Empty fields match everything; a new subscription
+// replaces the previous one.
+type EventSubscription struct {
+	Type               string   `json:"type"` // "subscribe"
+	Hosts              []string `json:"hosts,omitempty"`
+	Types              []string `json:"types,omitempty"` // container, image, volume, network, dockmon
+	Actions            []string `json:"actions,omitempty"`
+	ContainerNameRegex string   `json:"container_name_regex,omitempty"`
+}
+
+// eventFilter is the compiled form of an EventSubscription.
+type eventFilter struct {
+	hosts   map[string]bool
+	types   map[string]bool
+	actions map[string]bool
+	nameRe  *regexp.Regexp
+}
+
+// newEventFilter validates and compiles a subscription.
+func newEventFilter(sub EventSubscription) (*eventFilter, error) {
+	f := &eventFilter{}
+
+	if len(sub.Hosts) > 0 {
+		f.hosts = make(map[string]bool, len(sub.Hosts))
+		for _, h := range sub.Hosts {
+			f.hosts[h] = true
+		}
+	}
+
+	if len(sub.Types) > 0 {
+		f.types = make(map[string]bool, len(sub.Types))
+		for _, t := range sub.Types {
+			f.types[t] = true
+		}
+	}
+
+	if len(sub.Actions) > 0 {
+		f.actions = make(map[string]bool, len(sub.Actions))
+		for _, a := range sub.Actions {
+			f.actions[a] = true
+		}
+	}
+
+	if sub.ContainerNameRegex != "" {
+		if len(sub.ContainerNameRegex) > maxContainerNameRegexLen {
+			return nil, fmt.Errorf("container_name_regex exceeds %d characters", maxContainerNameRegexLen)
+		}
+		re, err := regexp.Compile(sub.ContainerNameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid container_name_regex: %v", err)
+		}
+		f.nameRe = re
+	}
+
+	return f, nil
+}
+
+// matches reports whether the event passes the filter. Actions match
+// exactly or by their base name, so "health_status" also matches
+// "health_status: healthy".
+func (f *eventFilter) matches(event DockerEvent) bool {
+	if f == nil {
+		return true
+	}
+	if f.hosts != nil && !f.hosts[event.HostID] {
+		return false
+	}
+	if f.types != nil && !f.types[eventType(event)] {
+		return false
+	}
+	if f.actions != nil {
+		base, _, _ := strings.Cut(event.Action, ":")
+		if !f.actions[event.Action] && !f.actions[base] {
+			return false
+		}
+	}
+	if f.nameRe != nil && !f.nameRe.MatchString(event.ContainerName) {
+		return false
+	}
+	return true
+}
+
+// eventType returns the event's Docker object type. Events without one are
+// container events.
+func eventType(event DockerEvent) string {
+	if event.Type == "" {
+		return "container"
+	}
+	return event.Type
+}
+
+// parseEventSubscription decodes a client message. Returns ok=false for
+// messages that are not subscriptions so they can be ignored.
+func parseEventSubscription(data []byte) (sub EventSubscription, ok bool, err error) {
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return sub, false, fmt.Errorf("invalid message: %v", err)
+	}
+	if sub.Type != "subscribe" {
+		return sub, false, nil
+	}
+	return sub, true, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestEventFilterMatches(t *testing.T) {
+	event := DockerEvent{Action: "health_status: unhealthy", HostID: "h1", ContainerName: "web-1"}
+
+	tests := []struct {
+		name string
+		sub  EventSubscription
+		want bool
+	}{
+		{"empty subscription matches all", EventSubscription{}, true},
+		{"host match", EventSubscription{Hosts: []string{"h2", "h1"}}, true},
+		{"host mismatch", EventSubscription{Hosts: []string{"h2"}}, false},
+		{"exact action", EventSubscription{Actions: []string{"health_status: unhealthy"}}, true},
+		{"base action", EventSubscription{Actions: []string{"health_status"}}, true},
+		{"action mismatch", EventSubscription{Actions: []string{"die"}}, false},
+		{"name regex match", EventSubscription{ContainerNameRegex: "^web-"}, true},
+		{"name regex mismatch", EventSubscription{ContainerNameRegex: "^db-"}, false},
+		{"all criteria", EventSubscription{Hosts: []string{"h1"}, Actions: []string{"health_status"}, ContainerNameRegex: "web"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newEventFilter(tt.sub)
+			if err != nil {
+				t.Fatalf("newEventFilter() error = %v", err)
+			}
+			if got := f.matches(event); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventFilterMatchesTypes(t *testing.T) {
+	f, err := newEventFilter(EventSubscription{Types: []string{"container", "volume"}})
+	if err != nil {
+		t.Fatalf("newEventFilter() error = %v", err)
+	}
+
+	if !f.matches(DockerEvent{Action: "start"}) {
+		t.Error("untyped event should match as a container event")
+	}
+	if !f.matches(DockerEvent{Type: "volume", Action: "create"}) {
+		t.Error("volume event should match")
+	}
+	if f.matches(DockerEvent{Type: "image", Action: "pull"}) {
+		t.Error("image event should not match")
+	}
+}
+
+func TestNilEventFilterMatchesAll(t *testing.T) {
+	var f *eventFilter
+	if !f.matches(DockerEvent{Action: "start"}) {
+		t.Error("nil filter should match every event")
+	}
+}
+
+func TestNewEventFilterRejectsBadRegex(t *testing.T) {
+	if _, err := newEventFilter(EventSubscription{ContainerNameRegex: "web-("}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestParseEventSubscription(t *testing.T) {
+	sub, ok, err := parseEventSubscription([]byte(`{"type":"subscribe","hosts":["h1"],"actions":["die"]}`))
+	if err != nil || !ok {
+		t.Fatalf("parseEventSubscription() = ok %v, err %v", ok, err)
+	}
+	if len(sub.Hosts) != 1 || sub.Hosts[0] != "h1" || len(sub.Actions) != 1 {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+
+	if _, ok, err := parseEventSubscription([]byte(`{"type":"ping"}`)); ok || err != nil {
+		t.Errorf("non-subscribe message: ok %v, err %v, want ignored", ok, err)
+	}
+
+	if _, _, err := parseEventSubscription([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
@@ -2,23 +2,26 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"fmt"
 	"log"
-	"net"
-	"net/http"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/clock"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
+	"github.com/docker/docker/api/types/system"
 )
 
-// DockerEvent represents a Docker container event
+// DockerEvent represents a Docker event. Type is "container" for container
+// events; image, volume and network events carry their subject in
+// ActorID/ActorName instead of the container fields. DockMon's own actions
+// (see PublishActivity) use type "dockmon".
 type DockerEvent struct {
+	Type          string            `json:"type,omitempty"`
 	Action        string            `json:"action"`
 	ContainerID   string            `json:"container_id"`
 	ContainerName string            `json:"container_name"`
@@ -26,6 +29,10 @@ type DockerEvent struct {
 	HostID        string            `json:"host_id"`
 	Timestamp     string            `json:"timestamp"`
 	Attributes    map[string]string `json:"attributes"`
+	ActorID       string            `json:"actor_id,omitempty"`
+	ActorName     string            `json:"actor_name,omitempty"`
+	Maintenance   bool              `json:"maintenance,omitempty"` // Host was in maintenance mode
+	Seq           uint64            `json:"seq,omitempty"` // Replay cursor, assigned by EventCache
 }
 
 // EventManager manages Docker event streams for multiple hosts
@@ -35,105 +42,104 @@ type EventManager struct {
 	hostNames    map[string]string       // key: hostID, value: host name (for logging)
 	broadcaster  *EventBroadcaster
 	eventCache   *EventCache
+	markers      *ContainerMarkers
+	stats        *StatsCache // Tags stopped containers' final samples with their exit
+	streams      *StreamManager // Stops paused containers' stats streams
+	clocks       *clock.Tracker // Per-host clock offsets for timestamp normalization
+	pool         *dockerpkg.Pool // Shared with the stream manager
+	maintenance  *maintenance.Tracker // Hosts whose lifecycle noise is muted
+
+	// Last muted event per container and kind, replayed when the host's
+	// maintenance window ends. Key: hostID.
+	mutedMu sync.Mutex
+	muted   map[string]map[string]DockerEvent
 }
 
 // eventStream represents a single Docker host event stream
 type eventStream struct {
 	hostID    string
 	hostAddr  string
-	client    *client.Client
+	lease     *dockerpkg.Lease
 	ctx       context.Context
 	cancel    context.CancelFunc
 	active    bool
+	// When the host's daemon stopped answering; zero while it's online.
+	// Only touched by streamEvents.
+	offlineSince time.Time
 }
 
-// createEventTLSOption creates a Docker client TLS option from PEM-encoded certificates
-func createEventTLSOption(caCertPEM, certPEM, keyPEM string) (client.Opt, error) {
-	// Parse CA certificate
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM([]byte(caCertPEM)) {
-		return nil, fmt.Errorf("failed to parse CA certificate")
-	}
+// clockMeasureInterval is how often each host's clock offset is re-sampled
+const clockMeasureInterval = 5 * time.Minute
 
-	// Parse client certificate and key
-	clientCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse client certificate/key: %v", err)
+// NewEventManager creates a new event manager that takes its Docker clients
+// from pool
+func NewEventManager(broadcaster *EventBroadcaster, cache *EventCache, clocks *clock.Tracker, pool *dockerpkg.Pool) *EventManager {
+	return &EventManager{
+		hosts:       make(map[string]*eventStream),
+		hostNames:   make(map[string]string),
+		broadcaster: broadcaster,
+		eventCache:  cache,
+		clocks:      clocks,
+		pool:        pool,
 	}
+}
 
-	// Create TLS config
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{clientCert},
-		RootCAs:      caCertPool,
-		MinVersion:   tls.VersionTLS12,
-	}
+// SetMarkers sets the store that records container lifecycle events for
+// the stats history. Must be called before any host is added.
+func (em *EventManager) SetMarkers(markers *ContainerMarkers) {
+	em.markers = markers
+}
 
-	// Create HTTP client with TLS transport and timeouts
-	// Note: No overall Timeout set because Docker API streaming operations (stats, events)
-	// are long-running connections that should not be killed by a timeout
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second, // Connection establishment timeout
-				KeepAlive: 30 * time.Second, // TCP keepalive interval
-			}).DialContext,
-			TLSClientConfig:       tlsConfig,
-			TLSHandshakeTimeout:   10 * time.Second,
-			IdleConnTimeout:       90 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-		},
+// SetStatsCache sets the stats cache whose entries are tagged with the exit
+// event when a container dies or runs out of memory. Must be called before
+// any host is added.
+func (em *EventManager) SetStatsCache(cache *StatsCache) {
+	em.stats = cache
+}
+
+// hostLimits returns the stream manager's per-host request limiters, or nil
+// (no limits) without one
+func (em *EventManager) hostLimits() *HostLimits {
+	if em.streams == nil {
+		return nil
 	}
+	return em.streams.limits
+}
 
-	return client.WithHTTPClient(httpClient), nil
+// SetStreams sets the stream manager whose streams are stopped while their
+// container is paused and restarted when it's unpaused. Must be called
+// before any host is added.
+func (em *EventManager) SetStreams(streams *StreamManager) {
+	em.streams = streams
 }
 
-// NewEventManager creates a new event manager
-func NewEventManager(broadcaster *EventBroadcaster, cache *EventCache) *EventManager {
-	return &EventManager{
-		hosts:       make(map[string]*eventStream),
-		hostNames:   make(map[string]string),
-		broadcaster: broadcaster,
-		eventCache:  cache,
+// SetMaintenance sets the tracker of hosts in maintenance mode. Their
+// expected stop/start events update the stats state but aren't cached or
+// broadcast, and every other event is tagged. Must be called before any
+// host is added.
+func (em *EventManager) SetMaintenance(tracker *maintenance.Tracker) {
+	em.maintenance = tracker
+}
+
+// maintenanceFilter applies the host's maintenance window, if any, to an
+// event with the given container action ("" for other events)
+func (em *EventManager) maintenanceFilter(hostID, action string) (tag, forward bool) {
+	if em.maintenance == nil {
+		return false, true
 	}
+	return em.maintenance.Filter(hostID, action)
 }
 
 // AddHost starts monitoring Docker events for a host
 func (em *EventManager) AddHost(hostID, hostName, hostAddress, tlsCACert, tlsCert, tlsKey string) error {
-	// Create Docker client FIRST (before acquiring lock or stopping old stream)
-	var dockerClient *client.Client
-	var err error
-
-	// Check if it's a local Unix socket (Docker or Podman)
-	isLocalSocket := hostAddress == "" ||
-		hostAddress == "unix:///var/run/docker.sock" ||
-		hostAddress == "unix:///var/run/podman/podman.sock" ||
-		strings.HasPrefix(hostAddress, "unix:///run/user/")
-
-	if isLocalSocket {
-		// Local Docker/Podman socket - use FromEnv to auto-detect
-		dockerClient, err = client.NewClientWithOpts(
-			client.FromEnv,
-			client.WithAPIVersionNegotiation(),
-		)
-	} else {
-		// Remote Docker host - check if TLS is needed
-		clientOpts := []client.Opt{
-			client.WithHost(hostAddress),
-			client.WithAPIVersionNegotiation(),
-		}
-
-		// If TLS certificates provided, configure TLS
-		if tlsCACert != "" && tlsCert != "" && tlsKey != "" {
-			tlsOpt, err := createEventTLSOption(tlsCACert, tlsCert, tlsKey)
-			if err != nil {
-				return fmt.Errorf("failed to create TLS config: %v", err)
-			}
-			clientOpts = append(clientOpts, tlsOpt)
-		}
-
-		dockerClient, err = client.NewClientWithOpts(clientOpts...)
+	// Lease the host's Docker client FIRST (before acquiring lock or stopping
+	// old stream). Local Docker/Podman sockets are auto-detected from the
+	// environment.
+	clientAddress := hostAddress
+	if isLocalSocket(clientAddress) {
+		clientAddress = ""
 	}
-
+	lease, err := em.pool.AcquireHost(clientAddress, tlsCACert, tlsCert, tlsKey)
 	if err != nil {
 		return err
 	}
@@ -151,8 +157,8 @@ func (em *EventManager) AddHost(hostID, hostName, hostAddress, tlsCACert, tlsCer
 		log.Printf("Stopping existing event monitoring for host %s (%s) to update", oldHostName, truncateID(hostID, 8))
 		stream.cancel()
 		stream.active = false
-		if stream.client != nil {
-			stream.client.Close()
+		if stream.lease != nil {
+			stream.lease.Release()
 		}
 	}
 
@@ -162,7 +168,7 @@ func (em *EventManager) AddHost(hostID, hostName, hostAddress, tlsCACert, tlsCer
 	stream := &eventStream{
 		hostID:   hostID,
 		hostAddr: hostAddress,
-		client:   dockerClient,
+		lease:    lease,
 		ctx:      ctx,
 		cancel:   cancel,
 		active:   true,
@@ -190,12 +196,14 @@ func (em *EventManager) RemoveHost(hostID string) {
 		}
 		stream.cancel()
 		stream.active = false
-		if stream.client != nil {
-			stream.client.Close()
+		if stream.lease != nil {
+			stream.lease.Release()
 		}
 
 		// Clear cached events for this host
 		em.eventCache.ClearHost(hostID)
+		em.takeMuted(hostID)
+		em.clocks.Forget(hostID)
 		delete(em.hosts, hostID)
 		delete(em.hostNames, hostID)
 		log.Printf("Stopped event monitoring for host %s (%s)", hostName, truncateID(hostID, 8))
@@ -214,8 +222,8 @@ func (em *EventManager) StopAll() {
 		}
 		stream.cancel()
 		stream.active = false
-		if stream.client != nil {
-			stream.client.Close()
+		if stream.lease != nil {
+			stream.lease.Release()
 		}
 		log.Printf("Stopped event monitoring for host %s (%s)", hostName, truncateID(hostID, 8))
 	}
@@ -261,22 +269,52 @@ func (em *EventManager) streamEvents(stream *eventStream) {
 		default:
 		}
 
-		// Listen to container events only
+		// An offline host is polled until its daemon answers again
+		if !stream.offlineSince.IsZero() {
+			if err := em.daemonReachable(stream); err != nil {
+				select {
+				case <-stream.ctx.Done():
+				case <-time.After(daemonPollInterval):
+				}
+				continue
+			}
+			em.hostOnline(stream, hostName)
+			backoff = time.Second
+		}
+
+		// Container events plus image/volume/network changes for the audit trail
 		eventFilters := filters.NewArgs()
-		eventFilters.Add("type", "container")
+		eventFilters.Add("type", string(events.ContainerEventType))
+		eventFilters.Add("type", string(events.ImageEventType))
+		eventFilters.Add("type", string(events.VolumeEventType))
+		eventFilters.Add("type", string(events.NetworkEventType))
 
 		eventOptions := events.ListOptions{
 			Filters: eventFilters,
 		}
 
-		eventsChan, errChan := stream.client.Events(stream.ctx, eventOptions)
+		eventsChan, errChan := stream.lease.Client().Events(stream.ctx, eventOptions)
+
+		// Sample the host clock on every (re)connect and periodically after
+		em.measureClock(stream, hostName)
+		clockTicker := time.NewTicker(clockMeasureInterval)
 
 		for {
 			select {
 			case <-stream.ctx.Done():
+				clockTicker.Stop()
 				return
 
+			case <-clockTicker.C:
+				em.measureClock(stream, hostName)
+
 			case err := <-errChan:
+				if err != nil && stream.ctx.Err() == nil && em.daemonReachable(stream) != nil {
+					// The daemon went away with the stream (shutdown or reboot)
+					clockTicker.Stop()
+					em.hostOffline(stream, hostName, err)
+					goto reconnect
+				}
 				if err != nil {
 					log.Printf("Event stream error for host %s (%s): %v (retrying in %v)", hostName, truncateID(stream.hostID, 8), err, backoff)
 					time.Sleep(backoff)
@@ -284,6 +322,7 @@ func (em *EventManager) streamEvents(stream *eventStream) {
 					backoff = min(backoff*2, maxBackoff)
 					// Mark that we need to reconnect (will reset backoff on successful event)
 					receivedSuccessfulEvent = false
+					clockTicker.Stop()
 					goto reconnect
 				}
 
@@ -313,6 +352,11 @@ func (em *EventManager) streamEvents(stream *eventStream) {
 
 // processEvent converts Docker event to our format and broadcasts it
 func (em *EventManager) processEvent(hostID string, event events.Message) {
+	if event.Type != events.ContainerEventType {
+		em.processResourceEvent(hostID, event)
+		return
+	}
+
 	// Extract container info
 	// IMPORTANT: Use short ID (12 chars) to match database and polling loop format
 	// Docker events contain full ID (64 chars), but we standardize on short ID
@@ -332,12 +376,13 @@ func (em *EventManager) processEvent(hostID string, event events.Message) {
 
 	// Create our event
 	dockerEvent := DockerEvent{
+		Type:          string(event.Type),
 		Action:        string(event.Action),
 		ContainerID:   containerID,
 		ContainerName: containerName,
 		Image:         image,
 		HostID:        hostID,
-		Timestamp:     time.Unix(event.Time, 0).Format(time.RFC3339),
+		Timestamp:     em.clocks.Normalize(hostID, eventTime(event)).Format(time.RFC3339),
 		Attributes:    event.Actor.Attributes,
 	}
 
@@ -358,13 +403,154 @@ func (em *EventManager) processEvent(hostID string, event events.Message) {
 			truncateID(hostID, 8))
 	}
 
-	// Add to cache
-	em.eventCache.AddEvent(hostID, dockerEvent)
+	if em.stats != nil && (action == "die" || action == "oom") {
+		var exitCode *int
+		if code, err := strconv.Atoi(event.Actor.Attributes["exitCode"]); err == nil {
+			exitCode = &code
+		}
+		em.stats.MarkContainerStopped(containerID, hostID, action, exitCode)
+	}
+	if em.streams != nil {
+		switch action {
+		case "pause":
+			em.streams.PauseStream(containerID, containerName, hostID)
+		case "unpause":
+			if err := em.streams.ResumeStream(containerID, hostID); err != nil {
+				log.Printf("Failed to resume stats stream for %s: %v", containerID, err)
+			}
+		}
+	}
+
+	// Planned maintenance: expected stop/start noise goes no further
+	tag, forward := em.maintenanceFilter(hostID, action)
+	if !forward {
+		em.holdMuted(dockerEvent)
+		return
+	}
+	dockerEvent.Maintenance = tag
+
+	// Add to cache (assigns the event's replay cursor)
+	dockerEvent = em.eventCache.AddEvent(hostID, dockerEvent)
+	if em.markers != nil {
+		em.markers.Record(dockerEvent)
+	}
 
 	// Broadcast to all WebSocket clients
 	em.broadcaster.Broadcast(dockerEvent)
 }
 
+// processResourceEvent broadcasts the image, volume and network events kept
+// for the host audit trail and drops the rest (e.g. network connect).
+func (em *EventManager) processResourceEvent(hostID string, event events.Message) {
+	if !dockerpkg.IsAuditedResourceEvent(event) {
+		return
+	}
+
+	dockerEvent := DockerEvent{
+		Type:       string(event.Type),
+		Action:     string(event.Action),
+		HostID:     hostID,
+		Timestamp:  em.clocks.Normalize(hostID, eventTime(event)).Format(time.RFC3339),
+		Attributes: event.Actor.Attributes,
+		ActorID:    event.Actor.ID,
+		ActorName:  dockerpkg.ResourceEventName(event),
+	}
+
+	em.mu.RLock()
+	hostName := em.hostNames[hostID]
+	em.mu.RUnlock()
+	if hostName == "" {
+		hostName = truncateID(hostID, 8)
+	}
+	log.Printf("Event: %s %s - %s on host %s (%s)", dockerEvent.Type, dockerEvent.Action, dockerEvent.ActorName, hostName, truncateID(hostID, 8))
+
+	dockerEvent.Maintenance, _ = em.maintenanceFilter(hostID, "")
+	dockerEvent = em.eventCache.AddEvent(hostID, dockerEvent)
+	em.broadcaster.Broadcast(dockerEvent)
+}
+
+// PublishActivity broadcasts a DockMon activity event for hostID alongside
+// the host's Docker events and returns it as cached. The event's own source
+// and message are carried in the attributes.
+func (em *EventManager) PublishActivity(hostID string, event activity.Event) DockerEvent {
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	attrs := make(map[string]string, len(event.Attributes)+2)
+	for k, v := range event.Attributes {
+		attrs[k] = v
+	}
+	if event.Source != "" {
+		attrs["source"] = event.Source
+	}
+	if event.Message != "" {
+		attrs["message"] = event.Message
+	}
+
+	dockerEvent := DockerEvent{
+		Type:          activity.EventType,
+		Action:        event.Action,
+		ContainerID:   truncateID(event.ContainerID, 12),
+		ContainerName: event.ContainerName,
+		Image:         event.Image,
+		HostID:        hostID,
+		Timestamp:     timestamp.UTC().Format(time.RFC3339),
+		Attributes:    attrs,
+	}
+
+	log.Printf("Activity: %s - %s on host %s (source %s)",
+		dockerEvent.Action, dockerEvent.ContainerName, truncateID(hostID, 8), event.Source)
+
+	dockerEvent.Maintenance, _ = em.maintenanceFilter(hostID, "")
+	dockerEvent = em.eventCache.AddEvent(hostID, dockerEvent)
+	em.broadcaster.Broadcast(dockerEvent)
+	return dockerEvent
+}
+
+// eventTime returns when an event happened, by the host's clock
+func eventTime(event events.Message) time.Time {
+	if event.TimeNano != 0 {
+		return time.Unix(0, event.TimeNano)
+	}
+	return time.Unix(event.Time, 0)
+}
+
+// measureClock samples the host's clock through the Docker info endpoint so
+// its event timestamps can be normalized to ours. Failures are logged and
+// leave the previous estimate in place.
+func (em *EventManager) measureClock(stream *eventStream, hostName string) {
+	ctx, cancel := context.WithTimeout(stream.ctx, 10*time.Second)
+	defer cancel()
+
+	var info system.Info
+	var sent, received time.Time
+	err := em.hostLimits().Call(ctx, stream.hostID, func() error {
+		var err error
+		sent = time.Now()
+		info, err = stream.lease.Client().Info(ctx)
+		received = time.Now()
+		return err
+	})
+	if err != nil {
+		if stream.ctx.Err() == nil {
+			log.Printf("Clock check failed for host %s (%s): %v", hostName, truncateID(stream.hostID, 8), err)
+		}
+		return
+	}
+	remote, err := time.Parse(time.RFC3339Nano, info.SystemTime)
+	if err != nil {
+		log.Printf("Clock check for host %s (%s): unparseable system time %q", hostName, truncateID(stream.hostID, 8), info.SystemTime)
+		return
+	}
+
+	est := em.clocks.Observe(stream.hostID, clock.FromExchange(sent, remote, received))
+	if est.Drifting {
+		log.Printf("Clock drift on host %s (%s): %dms from local clock; event timestamps will be corrected",
+			hostName, truncateID(stream.hostID, 8), est.OffsetMs)
+	}
+}
+
 // isExecEvent checks if the event is an exec_* event (noisy)
 func isExecEvent(action string) bool {
 	return len(action) > 5 && action[:5] == "exec_"
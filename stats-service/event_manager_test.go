@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/clock"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestProcessEvent_ResourceEvents(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+
+	em.processEvent("h1", events.Message{
+		Type:   events.ImageEventType,
+		Action: events.ActionPull,
+		Actor:  events.Actor{ID: "nginx:latest", Attributes: map[string]string{"name": "nginx:latest"}},
+		Time:   time.Now().Unix(),
+	})
+	// Network connects accompany every container start and are dropped
+	em.processEvent("h1", events.Message{
+		Type:   events.NetworkEventType,
+		Action: events.ActionConnect,
+		Actor:  events.Actor{ID: "net1", Attributes: map[string]string{"name": "bridge"}},
+		Time:   time.Now().Unix(),
+	})
+
+	got := cache.GetRecentEvents("h1", 10)
+	if len(got) != 1 {
+		t.Fatalf("cached %d events, want 1", len(got))
+	}
+	if got[0].Type != "image" || got[0].Action != "pull" || got[0].ActorName != "nginx:latest" {
+		t.Errorf("unexpected event %+v", got[0])
+	}
+	if got[0].ContainerID != "" {
+		t.Errorf("resource event should not carry a container ID, got %q", got[0].ContainerID)
+	}
+}
+
+func TestProcessEvent_ContainerEventIsTyped(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+
+	em.processEvent("h1", events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionStart,
+		Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"name": "web"}},
+		Time:   time.Now().Unix(),
+	})
+
+	got := cache.GetRecentEvents("h1", 10)
+	if len(got) != 1 || got[0].Type != "container" || got[0].ContainerID != "0123456789ab" {
+		t.Errorf("unexpected events %+v", got)
+	}
+}
+
+func TestProcessEvent_PauseAndUnpause(t *testing.T) {
+	stats := NewStatsCache()
+	em := NewEventManager(NewEventBroadcaster(), NewEventCache(10), clock.NewTracker(), dockerpkg.NewPool(0, 0))
+	em.SetStatsCache(stats)
+	em.SetStreams(NewStreamManager(stats, dockerpkg.NewPool(0, 0)))
+	stats.UpdateContainerStats(&ContainerStats{ContainerID: "0123456789ab", ContainerName: "web", HostID: "h1", CPUPercent: 55})
+
+	event := func(action events.Action) events.Message {
+		return events.Message{
+			Type:   events.ContainerEventType,
+			Action: action,
+			Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"name": "web"}},
+			Time:   time.Now().Unix(),
+		}
+	}
+
+	em.processEvent("h1", event(events.ActionPause))
+	if s, _ := stats.GetContainerStats("0123456789ab", "h1"); s.State != containerStatePaused || s.CPUPercent != 0 {
+		t.Fatalf("after pause = %+v", s)
+	}
+	em.processEvent("h1", event(events.ActionUnPause))
+	if s, _ := stats.GetContainerStats("0123456789ab", "h1"); s.State != "" {
+		t.Errorf("after unpause = %+v", s)
+	}
+}
+
+func TestPublishActivity(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+
+	em.PublishActivity("h1", activity.Event{
+		Action:        activity.ActionUpdateCompleted,
+		ContainerID:   "0123456789abcdef",
+		ContainerName: "web",
+		Image:         "nginx:1.27",
+		Source:        "compose",
+		Attributes:    map[string]string{"new_container_id": "fedcba987654"},
+	})
+
+	got := cache.GetRecentEvents("h1", 10)
+	if len(got) != 1 {
+		t.Fatalf("cached %d events, want 1", len(got))
+	}
+	e := got[0]
+	if e.Type != activity.EventType || e.Action != "update_completed" || e.ContainerID != "0123456789ab" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e.Attributes["source"] != "compose" || e.Attributes["new_container_id"] != "fedcba987654" {
+		t.Errorf("attributes = %v", e.Attributes)
+	}
+	if _, err := time.Parse(time.RFC3339, e.Timestamp); err != nil {
+		t.Errorf("timestamp %q: %v", e.Timestamp, err)
+	}
+}
+
+func TestHostOfflineAndOnline(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+	streams := NewStreamManager(NewStatsCache(), dockerpkg.NewPool(0, 0))
+	em.SetStreams(streams)
+	stream := &eventStream{hostID: "h1"}
+
+	em.hostOffline(stream, "web-01", errors.New("unexpected EOF"))
+	back := streams.hostOutage("h1")
+	if back == nil || stream.offlineSince.IsZero() {
+		t.Fatal("host not marked offline")
+	}
+
+	stream.offlineSince = stream.offlineSince.Add(-90 * time.Second)
+	em.hostOnline(stream, "web-01")
+	select {
+	case <-back:
+	default:
+		t.Error("streams waiting on the host weren't woken")
+	}
+	if streams.hostOutage("h1") != nil || !stream.offlineSince.IsZero() {
+		t.Error("host still marked offline")
+	}
+
+	got := cache.GetRecentEvents("h1", 10)
+	if len(got) != 2 || got[0].Action != activity.ActionHostOffline || got[1].Action != activity.ActionHostOnline {
+		t.Fatalf("events = %+v", got)
+	}
+	if got[0].Attributes[activity.AttrReason] != "unexpected EOF" || got[1].Attributes[activity.AttrDowntimeSeconds] != "90" {
+		t.Errorf("attributes = %v, %v", got[0].Attributes, got[1].Attributes)
+	}
+}
+
+func TestProcessEvent_Maintenance(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+	tracker := maintenance.NewTracker()
+	em.SetMaintenance(tracker)
+	tracker.Enable("h1", "kernel upgrade", time.Hour)
+
+	containerEvent := func(host string, action events.Action) {
+		em.processEvent(host, events.Message{
+			Type:   events.ContainerEventType,
+			Action: action,
+			Actor:  events.Actor{ID: "0123456789abcdef", Attributes: map[string]string{"name": "web"}},
+			Time:   time.Now().Unix(),
+		})
+	}
+	containerEvent("h1", events.ActionDie)
+	containerEvent("h1", events.ActionStart)
+	containerEvent("h1", events.ActionOOM)
+	containerEvent("h2", events.ActionDie)
+
+	got := cache.GetRecentEvents("h1", 10)
+	if len(got) != 1 || got[0].Action != "oom" || !got[0].Maintenance {
+		t.Errorf("h1 events = %+v, want only a tagged oom", got)
+	}
+	if got := cache.GetRecentEvents("h2", 10); len(got) != 1 || got[0].Maintenance {
+		t.Errorf("h2 events = %+v, want an untagged die", got)
+	}
+	if w := tracker.Snapshot()["h1"]; w.Muted != 2 {
+		t.Errorf("muted = %d, want 2", w.Muted)
+	}
+
+	tracker.Disable("h1")
+	containerEvent("h1", events.ActionStop)
+	if got := cache.GetRecentEvents("h1", 10); len(got) != 2 {
+		t.Errorf("cached %d events after maintenance ended, want 2", len(got))
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dockmon/stats-service/persistence"
+)
+
+const (
+	// markerRetention matches the largest history tier window, so any range
+	// the history API can serve is covered
+	markerRetention = 30 * 24 * time.Hour
+	// maxMarkersPerContainer bounds the markers kept for a crash-looping container
+	maxMarkersPerContainer = 500
+	// markerSweepInterval is how often markers of containers that went quiet
+	// are dropped
+	markerSweepInterval = time.Hour
+)
+
+// markerActions are the container lifecycle events worth marking on a graph.
+// kill and stop are left out: they are always followed by die.
+var markerActions = map[string]bool{
+	"start":   true,
+	"die":     true,
+	"restart": true,
+	"oom":     true,
+	"update":  true,
+}
+
+// ContainerMarkers keeps recent lifecycle events per container, keyed like
+// the stats history ("hostID:shortID"), so history responses can mark where
+// a container restarted, ran out of memory or was updated. Markers live in
+// memory only and start empty after a restart of the service.
+type ContainerMarkers struct {
+	mu        sync.RWMutex
+	markers   map[string][]persistence.HistoryEvent // Ordered by timestamp
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewContainerMarkers creates an empty marker store
+func NewContainerMarkers() *ContainerMarkers {
+	return &ContainerMarkers{
+		markers: make(map[string][]persistence.HistoryEvent),
+		now:     time.Now,
+	}
+}
+
+// Record stores a marker for a container event. Other event types and
+// actions are ignored.
+func (m *ContainerMarkers) Record(event DockerEvent) {
+	if event.Type != "container" || event.ContainerID == "" || !markerActions[event.Action] {
+		return
+	}
+	ts, err := time.Parse(time.RFC3339, event.Timestamp)
+	if err != nil {
+		return
+	}
+	marker := persistence.HistoryEvent{Timestamp: ts.Unix(), Action: event.Action}
+	if event.Action == "die" {
+		if code, err := strconv.Atoi(event.Attributes["exitCode"]); err == nil {
+			marker.ExitCode = &code
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	cutoff := now.Add(-markerRetention).Unix()
+	if marker.Timestamp < cutoff {
+		return
+	}
+
+	key := event.HostID + ":" + event.ContainerID
+	list := append(m.markers[key], marker)
+	// Events normally arrive in order; keep the list sorted when they don't
+	if n := len(list); n > 1 && list[n-1].Timestamp < list[n-2].Timestamp {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Timestamp < list[j].Timestamp })
+	}
+	m.markers[key] = trimMarkers(list, cutoff)
+
+	if now.Sub(m.lastSweep) >= markerSweepInterval {
+		m.lastSweep = now
+		for k, l := range m.markers {
+			if l = trimMarkers(l, cutoff); len(l) == 0 {
+				delete(m.markers, k)
+			} else {
+				m.markers[k] = l
+			}
+		}
+	}
+}
+
+// trimMarkers drops markers older than cutoff and keeps at most
+// maxMarkersPerContainer of the rest
+func trimMarkers(list []persistence.HistoryEvent, cutoff int64) []persistence.HistoryEvent {
+	i := sort.Search(len(list), func(i int) bool { return list[i].Timestamp >= cutoff })
+	if len(list)-i > maxMarkersPerContainer {
+		i = len(list) - maxMarkersPerContainer
+	}
+	return list[i:]
+}
+
+// Between returns the container's markers with from <= timestamp < to
+func (m *ContainerMarkers) Between(containerID string, from, to int64) []persistence.HistoryEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []persistence.HistoryEvent
+	for _, marker := range m.markers[containerID] {
+		if marker.Timestamp >= from && marker.Timestamp < to {
+			result = append(result, marker)
+		}
+	}
+	return result
+}
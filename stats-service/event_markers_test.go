@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestContainerMarkersRetention(t *testing.T) {
+	m := NewContainerMarkers()
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	record := func(container string, ts time.Time) {
+		m.Record(DockerEvent{Type: "container", Action: "restart", HostID: "h1", ContainerID: container,
+			Timestamp: ts.Format(time.RFC3339)})
+	}
+
+	record("old", now.Add(-markerRetention-time.Minute))
+	if len(m.markers) != 0 {
+		t.Errorf("kept a marker older than the retention: %+v", m.markers)
+	}
+
+	for i := 0; i < maxMarkersPerContainer+10; i++ {
+		record("loop", now.Add(time.Duration(i)*time.Second))
+	}
+	got := m.Between("h1:loop", 0, now.Add(time.Hour).Unix())
+	if len(got) != maxMarkersPerContainer || got[0].Timestamp != now.Add(10*time.Second).Unix() {
+		t.Errorf("kept %d markers, want the latest %d", len(got), maxMarkersPerContainer)
+	}
+
+	// A container that went quiet is dropped by the next sweep
+	record("quiet", now)
+	now = now.Add(markerRetention + markerSweepInterval)
+	record("loop", now)
+	if _, ok := m.markers["h1:quiet"]; ok {
+		t.Error("sweep kept markers of a quiet container")
+	}
+	if got := m.Between("h1:loop", 0, now.Add(time.Hour).Unix()); len(got) != 1 {
+		t.Errorf("loop markers after sweep = %d, want 1", len(got))
+	}
+}
+
+func TestContainerMarkersIgnoresOtherEvents(t *testing.T) {
+	m := NewContainerMarkers()
+	ts := time.Now().Format(time.RFC3339)
+	m.Record(DockerEvent{Type: "image", Action: "pull", HostID: "h1", Timestamp: ts})
+	m.Record(DockerEvent{Type: "container", Action: "exec_start", HostID: "h1", ContainerID: "c", Timestamp: ts})
+	m.Record(DockerEvent{Type: "container", Action: "update", HostID: "h1", ContainerID: "c", Timestamp: "bad"})
+	if len(m.markers) != 0 {
+		t.Errorf("markers = %+v, want none", m.markers)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dockmon/stats-service/persistence"
+)
+
+// exportSteps are the wall-clock aligned resolutions exports are
+// downsampled to. Tier intervals are fractions of the tier window (7.2s at
+// the default points_per_view), which is awkward in a spreadsheet, so an
+// export is re-bucketed to the finest step that isn't finer than the data.
+// Tiers coarser than the last step are exported at their own interval.
+var exportSteps = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// exportResponse is the JSON export. Unlike HistoryResponse it is
+// row-major and has no gap fill, which suits external tooling better than
+// chart columns.
+type exportResponse struct {
+	Tier            string      `json:"tier"`
+	IntervalSeconds int64       `json:"interval_seconds"`
+	From            int64       `json:"from"`
+	To              int64       `json:"to"`
+	Rows            []exportRow `json:"rows"`
+}
+
+type exportRow struct {
+	Timestamp      int64    `json:"timestamp"`
+	CPU            *float64 `json:"cpu_percent"`
+	MemPercent     *float64 `json:"memory_percent"`
+	MemUsed        *int64   `json:"memory_used_bytes"`
+	MemLimit       *int64   `json:"memory_limit_bytes"`
+	NetBps         *float64 `json:"network_bps"`
+	ContainerCount *int     `json:"container_count,omitempty"`
+}
+
+// ServeExport handles GET /api/stats/export. Exactly one of container
+// (the composite host:container ID) or host selects the series; from and
+// to are required. format is csv (default) or json, and step optionally
+// picks a coarser resolution from exportSteps.
+func (h *HistoryHandler) ServeExport(w http.ResponseWriter, r *http.Request) {
+	if !isGet(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	containerID, hostID := q.Get("container"), q.Get("host")
+	if (containerID == "") == (hostID == "") {
+		http.Error(w, "exactly one of container or host required", http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseFromTo(q.Get("from"), q.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return
+	}
+	var step time.Duration
+	if s := q.Get("step"); s != "" {
+		step, err = time.ParseDuration(s)
+		if err != nil || !slices.Contains(exportSteps, step) {
+			http.Error(w, fmt.Sprintf("invalid step %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+	if len(h.tiers) == 0 {
+		http.Error(w, "no history tiers", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Older buckets of the finer tiers have been trimmed, so the data comes
+	// from the finest tier that still reaches back to from
+	tier := persistence.SelectTier(h.tiers, time.Since(time.Unix(from, 0)))
+	step = exportStep(tier, step)
+
+	var rows []persistence.HistoryRow
+	id := containerID
+	if containerID != "" {
+		rows, err = h.db.QueryContainerHistory(r.Context(), containerID, tier.Name, from, to)
+	} else {
+		id = hostID
+		rows, err = h.db.QueryHostHistory(r.Context(), hostID, tier.Name, from, to)
+	}
+	if err != nil {
+		log.Printf("export %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	rows = persistence.Downsample(rows, step)
+
+	interval := tier.Interval
+	if step > 0 {
+		interval = step
+	}
+	filename := fmt.Sprintf("stats-%s-%d-%d.%s", strings.ReplaceAll(id, ":", "-"), from, to, format)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if format == "json" {
+		writeExportJSON(w, tier, interval, from, to, rows)
+	} else {
+		writeExportCSV(w, rows, hostID != "")
+	}
+}
+
+// exportStep returns the step to downsample the tier's rows to: the
+// requested one, or the finest export step that isn't finer than the tier.
+// Zero means the tier's own interval.
+func exportStep(tier persistence.Tier, requested time.Duration) time.Duration {
+	for _, s := range exportSteps {
+		if s >= tier.Interval && s >= requested {
+			return s
+		}
+	}
+	return 0
+}
+
+func writeExportJSON(w http.ResponseWriter, tier persistence.Tier, interval time.Duration, from, to int64, rows []persistence.HistoryRow) {
+	resp := exportResponse{
+		Tier:            tier.Name,
+		IntervalSeconds: int64(math.Round(interval.Seconds())),
+		From:            from,
+		To:              to,
+		Rows:            make([]exportRow, 0, len(rows)),
+	}
+	for _, r := range rows {
+		resp.Rows = append(resp.Rows, exportRow{
+			Timestamp:      r.Timestamp,
+			CPU:            r.CPU,
+			MemPercent:     r.MemPercent,
+			MemUsed:        r.MemUsed,
+			MemLimit:       r.MemLimit,
+			NetBps:         r.NetBps,
+			ContainerCount: r.ContainerCount,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// writeExportCSV writes one row per bucket with a header. Missing values
+// are empty cells; time is RFC 3339 UTC for spreadsheets next to the unix
+// timestamp for scripts.
+func writeExportCSV(w http.ResponseWriter, rows []persistence.HistoryRow, host bool) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	header := []string{"timestamp", "time", "cpu_percent", "memory_percent",
+		"memory_used_bytes", "memory_limit_bytes", "network_bps"}
+	if host {
+		header = append(header, "container_count")
+	}
+	_ = cw.Write(header)
+	for _, r := range rows {
+		rec := []string{
+			strconv.FormatInt(r.Timestamp, 10),
+			time.Unix(r.Timestamp, 0).UTC().Format(time.RFC3339),
+			csvFloat(r.CPU),
+			csvFloat(r.MemPercent),
+			csvInt(r.MemUsed),
+			csvInt(r.MemLimit),
+			csvFloat(r.NetBps),
+		}
+		if host {
+			if r.ContainerCount != nil {
+				rec = append(rec, strconv.Itoa(*r.ContainerCount))
+			} else {
+				rec = append(rec, "")
+			}
+		}
+		_ = cw.Write(rec)
+	}
+	cw.Flush()
+}
+
+func csvFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func csvInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
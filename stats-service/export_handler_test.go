@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dockmon/stats-service/persistence"
+)
+
+// makeExportFixture stores four recent 1h-tier buckets for a container,
+// two per 10s step, and returns the first step's start
+func makeExportFixture(t *testing.T) (*persistence.DB, *HistoryHandler, int64) {
+	t.Helper()
+	db, h := makeHandlerFixture(t)
+	base := time.Now().Add(-time.Minute).Truncate(10 * time.Second).Unix()
+	for i, off := range []int64{0, 5, 10, 15} {
+		if _, err := db.Write().Exec(`INSERT INTO container_stats_history
+			(container_id, host_id, timestamp, resolution, cpu_percent, memory_usage, memory_limit)
+			VALUES (?,?,?,?,?,?,?)`,
+			"h1:abc123abc123", "h1", base+off, "1h",
+			float64(10*(i+1)), int64(100*(i+1)), int64(1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, h, base
+}
+
+func TestExport_CSVDownsamplesToStep(t *testing.T) {
+	_, h, base := makeExportFixture(t)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf(
+		"/api/stats/export?container=h1:abc123abc123&from=%d&to=%d&format=csv", base, base+30), nil)
+	w := httptest.NewRecorder()
+	h.ServeExport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type=%q, want text/csv", ct)
+	}
+	want := fmt.Sprintf("stats-h1-abc123abc123-%d-%d.csv", base, base+30)
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, want) {
+		t.Errorf("Content-Disposition=%q, want filename %s", cd, want)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records=%v, want header and two 10s rows", records)
+	}
+	if records[0][0] != "timestamp" || len(records[0]) != 7 {
+		t.Errorf("header=%v, want container columns", records[0])
+	}
+	row := records[1]
+	if row[0] != fmt.Sprint(base) || row[2] != "15" || row[4] != "150" || row[5] != "1000" {
+		t.Errorf("row=%v, want the first step averaged", row)
+	}
+	if row[6] != "" {
+		t.Errorf("network=%q, want empty for missing values", row[6])
+	}
+}
+
+func TestExport_JSONWithCoarserStep(t *testing.T) {
+	_, h, base := makeExportFixture(t)
+
+	req := httptest.NewRequest("GET", fmt.Sprintf(
+		"/api/stats/export?container=h1:abc123abc123&from=%d&to=%d&format=json&step=5m", base, base+30), nil)
+	w := httptest.NewRecorder()
+	h.ServeExport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var resp exportResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Tier != "1h" || resp.IntervalSeconds != 300 {
+		t.Errorf("tier=%q interval=%d, want 1h at 300s", resp.Tier, resp.IntervalSeconds)
+	}
+	total := 0
+	for _, r := range resp.Rows {
+		if r.Timestamp%300 != 0 {
+			t.Errorf("timestamp %d not on a 5m boundary", r.Timestamp)
+		}
+		total++
+	}
+	if total == 0 || total > 2 {
+		t.Errorf("rows=%d, want the samples merged into at most two 5m buckets", total)
+	}
+}
+
+func TestExport_HostIncludesContainerCount(t *testing.T) {
+	db, h, base := makeExportFixture(t)
+	if _, err := db.Write().Exec(`INSERT INTO host_stats_history
+		(host_id, timestamp, resolution, cpu_percent, memory_percent, container_count)
+		VALUES ('h1', ?, '1h', 50.0, 60.0, 4)`, base); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf(
+		"/api/stats/export?host=h1&from=%d&to=%d", base, base+30), nil)
+	w := httptest.NewRecorder()
+	h.ServeExport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0][7] != "container_count" || records[1][7] != "4" {
+		t.Errorf("records=%v, want one host row with container_count 4", records)
+	}
+}
+
+func TestExport_InvalidParams(t *testing.T) {
+	_, h := makeHandlerFixture(t)
+
+	for name, query := range map[string]string{
+		"no series":     "from=1&to=2",
+		"both series":   "container=h1:abc123abc123&host=h1&from=1&to=2",
+		"missing range": "container=h1:abc123abc123",
+		"bad format":    "container=h1:abc123abc123&from=1&to=2&format=xml",
+		"bad step":      "container=h1:abc123abc123&from=1&to=2&step=7s",
+	} {
+		req := httptest.NewRequest("GET", "/api/stats/export?"+query, nil)
+		w := httptest.NewRecorder()
+		h.ServeExport(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status=%d, want 400", name, w.Code)
+		}
+	}
+}
+
+func TestExportStep(t *testing.T) {
+	tiers := persistence.ComputeTiers(500)
+	for _, tc := range []struct {
+		tier      int
+		requested time.Duration
+		want      time.Duration
+	}{
+		{0, 0, 10 * time.Second},               // 7.2s
+		{1, 0, time.Minute},                    // 57.6s
+		{2, 0, 5 * time.Minute},                // 172.8s
+		{3, 0, 0},                              // 1209.6s, coarser than every step
+		{0, time.Minute, time.Minute},          // coarser on request
+		{2, 10 * time.Second, 5 * time.Minute}, // never finer than the data
+	} {
+		if got := exportStep(tiers[tc.tier], tc.requested); got != tc.want {
+			t.Errorf("tier %s, step %v: got %v, want %v", tiers[tc.tier].Name, tc.requested, got, tc.want)
+		}
+	}
+}
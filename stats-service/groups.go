@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// groupFreshness is how recent a container's sample must be to count
+// towards its group, as for host aggregation
+const groupFreshness = 30 * time.Second
+
+// GroupStats is the summed usage of the running containers sharing a
+// dockmon.group label, across hosts and compose projects. CPU is the sum of
+// the containers' percentages; memory percent is of their summed limits.
+type GroupStats struct {
+	Group          string   `json:"group"`
+	ContainerCount int      `json:"container_count"`
+	HostCount      int      `json:"host_count"`
+	CPUPercent     float64  `json:"cpu_percent"`
+	MemoryUsage    uint64   `json:"memory_usage"`
+	MemoryLimit    uint64   `json:"memory_limit"`
+	MemoryPercent  float64  `json:"memory_percent"`
+	NetBytesPerSec float64  `json:"net_bytes_per_sec"`
+	Containers     []string `json:"containers"` // Composite host:container IDs
+	Hosts          []string `json:"hosts"`
+}
+
+// aggregateGroups sums the grouped containers' latest samples per group,
+// sorted by group name. Stopped and stale containers are left out; paused
+// ones count, as they still hold their memory.
+func aggregateGroups(containers map[string]*ContainerStats, now time.Time) []GroupStats {
+	cutoff := now.Add(-groupFreshness)
+	byName := make(map[string]*GroupStats)
+	hosts := make(map[string]map[string]bool)
+	for key, cs := range containers {
+		if cs.Group == "" || cs.Stopped {
+			continue
+		}
+		if cs.State != containerStatePaused && cs.LastUpdate.Before(cutoff) {
+			continue
+		}
+		g, ok := byName[cs.Group]
+		if !ok {
+			g = &GroupStats{Group: cs.Group}
+			byName[cs.Group] = g
+			hosts[cs.Group] = make(map[string]bool)
+		}
+		g.ContainerCount++
+		g.CPUPercent += cs.CPUPercent
+		g.MemoryUsage += cs.MemoryUsage
+		g.MemoryLimit += cs.MemoryLimit
+		g.NetBytesPerSec += cs.NetBytesPerSec
+		g.Containers = append(g.Containers, key)
+		hosts[cs.Group][cs.HostID] = true
+	}
+
+	groups := make([]GroupStats, 0, len(byName))
+	for name, g := range byName {
+		for hostID := range hosts[name] {
+			g.Hosts = append(g.Hosts, hostID)
+		}
+		g.HostCount = len(g.Hosts)
+		sort.Strings(g.Hosts)
+		sort.Strings(g.Containers)
+		g.CPUPercent = dockerpkg.RoundToDecimal(g.CPUPercent, 1)
+		if g.MemoryLimit > 0 {
+			g.MemoryPercent = dockerpkg.RoundToDecimal(float64(g.MemoryUsage)/float64(g.MemoryLimit)*100, 1)
+		}
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateGroups(t *testing.T) {
+	now := time.Now()
+	stale := now.Add(-time.Minute)
+	containers := map[string]*ContainerStats{
+		"h1:plex": {ContainerID: "plex", HostID: "h1", Group: "media", CPUPercent: 10.25,
+			MemoryUsage: 300, MemoryLimit: 1000, NetBytesPerSec: 50, LastUpdate: now},
+		"h2:sonarr": {ContainerID: "sonarr", HostID: "h2", Group: "media", CPUPercent: 5.1,
+			MemoryUsage: 100, MemoryLimit: 1000, NetBytesPerSec: 25, LastUpdate: now},
+		"h2:jellyfin": {ContainerID: "jellyfin", HostID: "h2", Group: "media",
+			MemoryUsage: 100, MemoryLimit: 1000, State: containerStatePaused, LastUpdate: stale},
+		"h1:postgres": {ContainerID: "postgres", HostID: "h1", Group: "databases", CPUPercent: 2,
+			MemoryUsage: 50, LastUpdate: now},
+		"h1:old":     {ContainerID: "old", HostID: "h1", Group: "media", CPUPercent: 99, LastUpdate: stale},
+		"h1:stopped": {ContainerID: "stopped", HostID: "h1", Group: "media", CPUPercent: 99, LastUpdate: now, Stopped: true},
+		"h1:nginx":   {ContainerID: "nginx", HostID: "h1", CPUPercent: 99, LastUpdate: now},
+	}
+
+	groups := aggregateGroups(containers, now)
+	if len(groups) != 2 || groups[0].Group != "databases" || groups[1].Group != "media" {
+		t.Fatalf("groups = %+v, want databases and media", groups)
+	}
+
+	db := groups[0]
+	if db.ContainerCount != 1 || db.MemoryPercent != 0 {
+		t.Errorf("databases = %+v, want one container with no memory percent (no limit)", db)
+	}
+
+	media := groups[1]
+	if media.ContainerCount != 3 || media.HostCount != 2 {
+		t.Errorf("media counts = %d containers on %d hosts, want 3 on 2 (stale and stopped left out)",
+			media.ContainerCount, media.HostCount)
+	}
+	if media.CPUPercent != 15.4 || media.MemoryUsage != 500 || media.MemoryLimit != 3000 || media.NetBytesPerSec != 75 {
+		t.Errorf("media sums = %+v", media)
+	}
+	if media.MemoryPercent != 16.7 {
+		t.Errorf("media memory percent = %v, want 16.7", media.MemoryPercent)
+	}
+	want := []string{"h1:plex", "h2:jellyfin", "h2:sonarr"}
+	for i, id := range want {
+		if media.Containers[i] != id {
+			t.Errorf("media containers = %v, want %v", media.Containers, want)
+			break
+		}
+	}
+}
+
+func TestStatsCache_ContainerGroup(t *testing.T) {
+	c := NewStatsCache()
+	c.UpdateContainerStats(&ContainerStats{ContainerID: "abc", HostID: "h1"})
+
+	// Setting the group tags the cached sample and the ones that follow
+	c.SetContainerGroup("abc", "h1", "media")
+	if cs, _ := c.GetContainerStats("abc", "h1"); cs.Group != "media" {
+		t.Errorf("cached group = %q, want media", cs.Group)
+	}
+	c.UpdateContainerStats(&ContainerStats{ContainerID: "abc", HostID: "h1"})
+	if cs, _ := c.GetContainerStats("abc", "h1"); cs.Group != "media" {
+		t.Errorf("group after sample = %q, want media", cs.Group)
+	}
+
+	// Agent samples carry their own group
+	c.UpdateContainerStats(&ContainerStats{ContainerID: "def", HostID: "h2", Group: "databases"})
+	if cs, _ := c.GetContainerStats("def", "h2"); cs.Group != "databases" {
+		t.Errorf("agent group = %q, want databases", cs.Group)
+	}
+
+	c.RemoveHostStats("h1")
+	c.UpdateContainerStats(&ContainerStats{ContainerID: "abc", HostID: "h1"})
+	if cs, _ := c.GetContainerStats("abc", "h1"); cs.Group != "" {
+		t.Errorf("group after host removal = %q, want none", cs.Group)
+	}
+}
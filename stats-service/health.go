@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// composeHealthTimeout bounds the compose-service probe in /health/full
+const composeHealthTimeout = 3 * time.Second
+
+// agentHeartbeatStale is how long an agent's ingest connection may go
+// without a message before /health/full flags it. Agents send a clock
+// heartbeat every minute, so this allows two to go missing.
+const agentHeartbeatStale = 3 * time.Minute
+
+// ComposeHealth is the compose-service's part of /health/full
+type ComposeHealth struct {
+	Status    string  `json:"status"` // ok, degraded or unreachable
+	Version   string  `json:"version,omitempty"`
+	APILevel  int     `json:"api_level,omitempty"`
+	DockerOK  bool    `json:"docker_ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// probeCompose asks the compose-service for its /health over its Unix socket
+func probeCompose(ctx context.Context, socketPath string) ComposeHealth {
+	ctx, cancel := context.WithTimeout(ctx, composeHealthTimeout)
+	defer cancel()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://compose/health", nil)
+	if err != nil {
+		return ComposeHealth{Status: "unreachable", Error: err.Error()}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ComposeHealth{Status: "unreachable", Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	health := ComposeHealth{LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+	var body struct {
+		Status   string `json:"status"`
+		Version  string `json:"version"`
+		APILevel int    `json:"api_level"`
+		DockerOK bool   `json:"docker_ok"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		health.Status = "unreachable"
+		health.Error = fmt.Sprintf("health returned %d", resp.StatusCode)
+		return health
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		health.Status = "unreachable"
+		health.Error = fmt.Sprintf("decode health: %v", err)
+		return health
+	}
+	health.Status = body.Status
+	health.Version = body.Version
+	health.APILevel = body.APILevel
+	health.DockerOK = body.DockerOK
+	return health
+}
+
+// AgentHealth is one agent's ingest connection in /health/full
+type AgentHealth struct {
+	HostID              string    `json:"host_id"`
+	ConnectedSince      time.Time `json:"connected_since"`
+	LastHeartbeat       time.Time `json:"last_heartbeat"`
+	HeartbeatAgeSeconds float64   `json:"heartbeat_age_seconds"`
+	Stale               bool      `json:"stale"`
+}
+
+// AgentsHealth summarizes the connected agents in /health/full
+type AgentsHealth struct {
+	Connected int           `json:"connected"`
+	Stale     int           `json:"stale"`
+	Agents    []AgentHealth `json:"agents"`
+}
+
+// AgentRegistry tracks agents' ingest connections and when each was last
+// heard from. Safe for concurrent use; a nil registry ignores updates.
+type AgentRegistry struct {
+	mu     sync.Mutex
+	agents map[string]*agentPresence
+	now    func() time.Time
+}
+
+type agentPresence struct {
+	conns          int // An agent briefly has two while reconnecting
+	connectedSince time.Time
+	lastSeen       time.Time
+}
+
+// NewAgentRegistry creates an empty registry.
+func NewAgentRegistry() *AgentRegistry {
+	return &AgentRegistry{agents: make(map[string]*agentPresence), now: time.Now}
+}
+
+// Connected records a new ingest connection for a host
+func (r *AgentRegistry) Connected(hostID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	a, ok := r.agents[hostID]
+	if !ok {
+		a = &agentPresence{connectedSince: now}
+		r.agents[hostID] = a
+	}
+	a.conns++
+	a.lastSeen = now
+}
+
+// Seen records a message from a host's agent
+func (r *AgentRegistry) Seen(hostID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if a, ok := r.agents[hostID]; ok {
+		a.lastSeen = r.now()
+	}
+}
+
+// Disconnected records the end of an ingest connection for a host
+func (r *AgentRegistry) Disconnected(hostID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.agents[hostID]
+	if !ok {
+		return
+	}
+	if a.conns--; a.conns <= 0 {
+		delete(r.agents, hostID)
+	}
+}
+
+// Snapshot returns the connected agents, sorted by host ID
+func (r *AgentRegistry) Snapshot() AgentsHealth {
+	health := AgentsHealth{Agents: []AgentHealth{}}
+	if r == nil {
+		return health
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for hostID, a := range r.agents {
+		age := now.Sub(a.lastSeen)
+		agent := AgentHealth{
+			HostID:              hostID,
+			ConnectedSince:      a.connectedSince.UTC(),
+			LastHeartbeat:       a.lastSeen.UTC(),
+			HeartbeatAgeSeconds: age.Round(time.Millisecond).Seconds(),
+			Stale:               age > agentHeartbeatStale,
+		}
+		if agent.Stale {
+			health.Stale++
+		}
+		health.Agents = append(health.Agents, agent)
+	}
+	health.Connected = len(health.Agents)
+	sort.Slice(health.Agents, func(i, j int) bool {
+		return health.Agents[i].HostID < health.Agents[j].HostID
+	})
+	return health
+}
+
+// FullHealth is the /health/full document: the stats-service's own health
+// plus the compose-service's and the connected agents', for the backend's
+// system status page. Status is "ok" when everything is, else "degraded".
+type FullHealth struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Stats     map[string]interface{} `json:"stats"`
+	Compose   ComposeHealth          `json:"compose"`
+	Agents    AgentsHealth           `json:"agents"`
+}
+
+// buildFullHealth assembles /health/full around the stats-service's /health
+// body
+func buildFullHealth(ctx context.Context, stats map[string]interface{}, composeSocket string, agents *AgentRegistry) FullHealth {
+	health := FullHealth{
+		Status:    "ok",
+		CheckedAt: time.Now().UTC(),
+		Stats:     stats,
+		Compose:   probeCompose(ctx, composeSocket),
+		Agents:    agents.Snapshot(),
+	}
+	if health.Compose.Status != "ok" || health.Agents.Stale > 0 {
+		health.Status = "degraded"
+	}
+	return health
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAgentRegistry_HeartbeatAges(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	r := NewAgentRegistry()
+	r.now = func() time.Time { return now }
+
+	r.Connected("h2")
+	r.Connected("h1")
+	now = now.Add(4 * time.Minute)
+	r.Seen("h1")
+	now = now.Add(30 * time.Second)
+
+	s := r.Snapshot()
+	if s.Connected != 2 || s.Stale != 1 {
+		t.Fatalf("connected=%d stale=%d, want 2 and 1", s.Connected, s.Stale)
+	}
+	h1, h2 := s.Agents[0], s.Agents[1]
+	if h1.HostID != "h1" || h1.HeartbeatAgeSeconds != 30 || h1.Stale {
+		t.Errorf("h1=%+v, want a 30s old heartbeat", h1)
+	}
+	if h2.HostID != "h2" || h2.HeartbeatAgeSeconds != 270 || !h2.Stale {
+		t.Errorf("h2=%+v, want a stale 270s old heartbeat", h2)
+	}
+}
+
+func TestAgentRegistry_OverlappingConnections(t *testing.T) {
+	r := NewAgentRegistry()
+	// A reconnecting agent's new connection can arrive before the old one
+	// is torn down
+	r.Connected("h1")
+	r.Connected("h1")
+	r.Disconnected("h1")
+	if s := r.Snapshot(); s.Connected != 1 {
+		t.Fatalf("connected=%d, want the agent still connected", s.Connected)
+	}
+	r.Disconnected("h1")
+	if s := r.Snapshot(); s.Connected != 0 || s.Agents == nil {
+		t.Errorf("snapshot=%+v, want no agents (as an empty list)", s)
+	}
+
+	var nilRegistry *AgentRegistry
+	nilRegistry.Connected("h1")
+	nilRegistry.Seen("h1")
+	nilRegistry.Disconnected("h1")
+}
+
+func serveComposeHealth(t *testing.T, body string) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "compose.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return socket
+}
+
+func TestBuildFullHealth(t *testing.T) {
+	socket := serveComposeHealth(t,
+		`{"status":"ok","service":"dockmon-compose","version":"2.1.0","api_level":3,"docker_ok":true}`)
+	stats := map[string]interface{}{"status": "ok"}
+
+	h := buildFullHealth(context.Background(), stats, socket, NewAgentRegistry())
+	if h.Status != "ok" {
+		t.Errorf("status=%q, want ok", h.Status)
+	}
+	if h.Compose.Status != "ok" || h.Compose.Version != "2.1.0" || h.Compose.APILevel != 3 || !h.Compose.DockerOK {
+		t.Errorf("compose=%+v, want the compose-service's health", h.Compose)
+	}
+
+	agents := NewAgentRegistry()
+	start := time.Now()
+	agents.now = func() time.Time { return start }
+	agents.Connected("h1")
+	agents.now = func() time.Time { return start.Add(time.Hour) }
+	if h := buildFullHealth(context.Background(), stats, socket, agents); h.Status != "degraded" {
+		t.Errorf("status=%q, want degraded with a stale agent", h.Status)
+	}
+}
+
+func TestBuildFullHealth_ComposeDown(t *testing.T) {
+	h := buildFullHealth(context.Background(), nil,
+		filepath.Join(t.TempDir(), "missing.sock"), nil)
+	if h.Status != "degraded" || h.Compose.Status != "unreachable" || h.Compose.Error == "" {
+		t.Errorf("health=%+v, want degraded with compose unreachable", h)
+	}
+
+	socket := serveComposeHealth(t, `{"status":"degraded","docker_ok":false}`)
+	h = buildFullHealth(context.Background(), nil, socket, nil)
+	if h.Status != "degraded" || h.Compose.Status != "degraded" {
+		t.Errorf("health=%+v, want the compose-service's degraded status", h)
+	}
+}
@@ -15,13 +15,14 @@ import (
 
 // HistoryHandler serves GET /api/stats/history/{container,host}.
 type HistoryHandler struct {
-	db    *persistence.DB
-	tiers []persistence.Tier
+	db      *persistence.DB
+	tiers   []persistence.Tier
+	markers *ContainerMarkers // Lifecycle events for container history; may be nil
 }
 
 // NewHistoryHandler builds a HistoryHandler.
-func NewHistoryHandler(db *persistence.DB, tiers []persistence.Tier) *HistoryHandler {
-	return &HistoryHandler{db: db, tiers: tiers}
+func NewHistoryHandler(db *persistence.DB, tiers []persistence.Tier, markers *ContainerMarkers) *HistoryHandler {
+	return &HistoryHandler{db: db, tiers: tiers, markers: markers}
 }
 
 type historyParams struct {
@@ -153,7 +154,22 @@ func (h *HistoryHandler) ServeContainer(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, persistence.FillGaps(rows, p.tier, p.from, p.to))
+	resp := persistence.FillGaps(rows, p.tier, p.from, p.to)
+	h.annotate(&resp, containerID, p.tier)
+	writeJSON(w, resp)
+}
+
+// annotate adds the container's lifecycle events within the response's
+// buckets, each snapped to the bucket it falls into
+func (h *HistoryHandler) annotate(resp *persistence.HistoryResponse, containerID string, tier persistence.Tier) {
+	if h.markers == nil || len(resp.Timestamps) == 0 {
+		return
+	}
+	end := time.Unix(resp.To, 0).Add(tier.Interval).Unix()
+	for _, e := range h.markers.Between(containerID, resp.From, end) {
+		e.Bucket = time.Unix(e.Timestamp, 0).Truncate(tier.Interval).Unix()
+		resp.Events = append(resp.Events, e)
+	}
 }
 
 // ServeHost handles GET /api/stats/history/host.
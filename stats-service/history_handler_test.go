@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dockmon/stats-service/persistence"
 )
@@ -30,7 +31,7 @@ func makeHandlerFixture(t *testing.T) (*persistence.DB, *HistoryHandler) {
 			t.Fatal(err)
 		}
 	}
-	return db, NewHistoryHandler(db, persistence.ComputeTiers(500))
+	return db, NewHistoryHandler(db, persistence.ComputeTiers(500), nil)
 }
 
 func TestHistoryHandler_RangeOnly(t *testing.T) {
@@ -267,7 +268,7 @@ func TestHistoryHandler_ContainerMemPercent(t *testing.T) {
 		float64(50), int64(1024), int64(8192)); err != nil {
 		t.Fatal(err)
 	}
-	h := NewHistoryHandler(db, persistence.ComputeTiers(500))
+	h := NewHistoryHandler(db, persistence.ComputeTiers(500), nil)
 
 	// Window ≈ 72s spans five 7.2s buckets centered on 1_000_008; the
 	// auto-tier path picks the 1h tier (the smallest whose window ≥ 72s).
@@ -320,3 +321,54 @@ func TestHistoryHandler_RejectsNonGet(t *testing.T) {
 		t.Errorf("Allow=%q, want GET", allow)
 	}
 }
+
+func TestHistoryHandler_ContainerEvents(t *testing.T) {
+	db, _ := makeHandlerFixture(t)
+	tiers := persistence.ComputeTiers(500)
+	markers := NewContainerMarkers()
+	markers.now = func() time.Time { return time.Unix(1_000_100, 0) }
+	at := func(ts int64) string { return time.Unix(ts, 0).UTC().Format(time.RFC3339) }
+	for _, e := range []DockerEvent{
+		{Type: "container", Action: "start", HostID: "h1", ContainerID: "abc123abc123", Timestamp: at(999_000)},
+		{Type: "container", Action: "oom", HostID: "h1", ContainerID: "abc123abc123", Timestamp: at(1_000_009)},
+		{Type: "container", Action: "kill", HostID: "h1", ContainerID: "abc123abc123", Timestamp: at(1_000_009)},
+		{Type: "container", Action: "die", HostID: "h1", ContainerID: "abc123abc123", Timestamp: at(1_000_010),
+			Attributes: map[string]string{"exitCode": "137"}},
+		{Type: "container", Action: "restart", HostID: "h2", ContainerID: "abc123abc123", Timestamp: at(1_000_011)},
+	} {
+		markers.Record(e)
+	}
+	h := NewHistoryHandler(db, tiers, markers)
+
+	req := httptest.NewRequest("GET",
+		"/api/stats/history/container?host_id=h1&container_id=h1:abc123abc123"+
+			"&from=1000000&to=1000028", nil)
+	w := httptest.NewRecorder()
+	h.ServeContainer(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var resp persistence.HistoryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Events) != 2 {
+		t.Fatalf("events=%+v, want oom and die", resp.Events)
+	}
+	oom, die := resp.Events[0], resp.Events[1]
+	if oom.Action != "oom" || die.Action != "die" || die.ExitCode == nil || *die.ExitCode != 137 {
+		t.Errorf("events=%+v", resp.Events)
+	}
+	wantBucket := time.Unix(1_000_010, 0).Truncate(tiers[0].Interval).Unix()
+	if die.Bucket != wantBucket {
+		t.Errorf("bucket=%d, want %d", die.Bucket, wantBucket)
+	}
+	found := false
+	for _, ts := range resp.Timestamps {
+		found = found || ts == die.Bucket
+	}
+	if !found {
+		t.Errorf("bucket %d is not one of the response timestamps %v", die.Bucket, resp.Timestamps)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+)
+
+const (
+	// daemonPingTimeout bounds the ping that tells a daemon that went away
+	// (host shutting down or rebooting) from an event stream that broke
+	daemonPingTimeout = 5 * time.Second
+	// daemonPollInterval is how often an offline host's daemon is pinged
+	daemonPollInterval = 5 * time.Second
+)
+
+// daemonReachable pings the host's Docker daemon
+func (em *EventManager) daemonReachable(stream *eventStream) error {
+	ctx, cancel := context.WithTimeout(stream.ctx, daemonPingTimeout)
+	defer cancel()
+	_, err := stream.lease.Client().Ping(ctx)
+	return err
+}
+
+// hostOffline marks the host offline after its event stream ended with err
+// and its daemon stopped answering: a host_offline event is published and
+// its stats streams wait quietly until it's back
+func (em *EventManager) hostOffline(stream *eventStream, hostName string, err error) {
+	stream.offlineSince = time.Now()
+	log.Printf("Host %s (%s) is offline: Docker daemon unreachable after event stream error: %v",
+		hostName, truncateID(stream.hostID, 8), err)
+	if em.streams != nil {
+		em.streams.SetHostOffline(stream.hostID, true)
+	}
+	event := activity.HostOffline(err.Error(), stream.offlineSince)
+	event.Source = "stats-service"
+	em.PublishActivity(stream.hostID, event)
+}
+
+// hostOnline marks the host online again, publishing a host_online event
+// with the downtime
+func (em *EventManager) hostOnline(stream *eventStream, hostName string) {
+	now := time.Now()
+	log.Printf("Host %s (%s) is back online after %s",
+		hostName, truncateID(stream.hostID, 8), now.Sub(stream.offlineSince).Round(time.Second))
+	event := activity.HostOnline(stream.offlineSince, now)
+	event.Source = "stats-service"
+	stream.offlineSince = time.Time{}
+	if em.streams != nil {
+		em.streams.SetHostOffline(stream.hostID, false)
+	}
+	em.PublishActivity(stream.hostID, event)
+}
+
+// SetHostOffline records whether a host's Docker daemon is down. Streams
+// of an offline host stop logging errors and wait for it to come back
+// instead of retrying.
+func (sm *StreamManager) SetHostOffline(hostID string, offline bool) {
+	sm.outagesMu.Lock()
+	defer sm.outagesMu.Unlock()
+	back, down := sm.outages[hostID]
+	switch {
+	case offline && !down:
+		if sm.outages == nil {
+			sm.outages = make(map[string]chan struct{})
+		}
+		sm.outages[hostID] = make(chan struct{})
+	case !offline && down:
+		close(back)
+		delete(sm.outages, hostID)
+	}
+}
+
+// hostOutage returns a channel closed when the host comes back online, or
+// nil if it's online
+func (sm *StreamManager) hostOutage(hostID string) <-chan struct{} {
+	sm.outagesMu.Lock()
+	defer sm.outagesMu.Unlock()
+	if back, down := sm.outages[hostID]; down {
+		return back
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Request limit defaults
+const (
+	defaultMaxHostRequests = 10
+	defaultBreakerTimeouts = 5
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// errHostDegraded is returned instead of calling a host whose circuit
+// breaker is open
+var errHostDegraded = errors.New("docker host degraded after repeated timeouts, not sending requests")
+
+// HostLimitConfig bounds the Docker API requests sent to each host
+type HostLimitConfig struct {
+	MaxConcurrent   int           // In-flight requests per host; more wait in a queue
+	BreakerTimeouts int           // Consecutive timeouts that mark a host degraded
+	BreakerCooldown time.Duration // How long a degraded host is left alone before a probe
+}
+
+// HostLimitStats is a host's request limiter state, as reported by /health
+type HostLimitStats struct {
+	HostID              string     `json:"host_id"`
+	InFlight            int        `json:"in_flight"`
+	Queued              int        `json:"queued"`
+	MaxConcurrent       int        `json:"max_concurrent"`
+	Degraded            bool       `json:"degraded"`
+	DegradedUntil       *time.Time `json:"degraded_until,omitempty"`
+	ConsecutiveTimeouts int        `json:"consecutive_timeouts"`
+	Timeouts            uint64     `json:"timeouts"`
+	Trips               uint64     `json:"trips"`    // Times the breaker opened
+	Rejected            uint64     `json:"rejected"` // Requests not sent while degraded
+}
+
+// HostLimits bounds the concurrent Docker API requests per host, so a slow
+// host can't pile up hundreds of in-flight calls, and stops calling a host
+// for a cooldown once it has timed out repeatedly. After the cooldown a
+// single probe request goes through: if it answers the host is healthy
+// again, if it times out the host stays degraded for another cooldown.
+type HostLimits struct {
+	cfg      HostLimitConfig
+	mu       sync.Mutex
+	limiters map[string]*hostLimiter // hostID -> limiter
+	names    func(hostID string) string
+	now      func() time.Time // Injectable clock for tests
+}
+
+// hostLimiter is one host's semaphore and circuit breaker
+type hostLimiter struct {
+	slots chan struct{}
+
+	mu                  sync.Mutex
+	queued              int
+	consecutiveTimeouts int
+	degradedUntil       time.Time // Zero while the breaker is closed
+	probing             bool      // A probe is in flight after the cooldown
+	timeouts            uint64
+	trips               uint64
+	rejected            uint64
+}
+
+// NewHostLimits creates the per-host limiters. Zero config values use the
+// defaults; names resolves host IDs for logging and may be nil.
+func NewHostLimits(cfg HostLimitConfig, names func(hostID string) string) *HostLimits {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultMaxHostRequests
+	}
+	if cfg.BreakerTimeouts <= 0 {
+		cfg.BreakerTimeouts = defaultBreakerTimeouts
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
+	}
+	if names == nil {
+		names = func(string) string { return "" }
+	}
+	return &HostLimits{
+		cfg:      cfg,
+		limiters: make(map[string]*hostLimiter),
+		names:    names,
+		now:      time.Now,
+	}
+}
+
+func (hl *HostLimits) limiter(hostID string) *hostLimiter {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	l, ok := hl.limiters[hostID]
+	if !ok {
+		l = &hostLimiter{slots: make(chan struct{}, hl.cfg.MaxConcurrent)}
+		hl.limiters[hostID] = l
+	}
+	return l
+}
+
+// Forget drops a removed host's limiter
+func (hl *HostLimits) Forget(hostID string) {
+	if hl == nil {
+		return
+	}
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	delete(hl.limiters, hostID)
+}
+
+// Call runs a Docker API call for a host once one of the host's request
+// slots is free, holding the slot until call returns. For a streaming call,
+// call should return once the stream is open. It returns errHostDegraded
+// without calling while the host's breaker is open, and ctx's error if ctx
+// ends while waiting for a slot. A nil HostLimits calls right away.
+func (hl *HostLimits) Call(ctx context.Context, hostID string, call func() error) error {
+	if hl == nil {
+		return call()
+	}
+	l := hl.limiter(hostID)
+
+	probe, err := hl.admit(l)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.queued++
+	l.mu.Unlock()
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.queued--
+		if probe {
+			// The probe never went out; let the next request probe
+			l.probing = false
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	l.queued--
+	l.mu.Unlock()
+
+	err = call()
+	<-l.slots
+
+	hl.record(hostID, l, probe, err, ctx.Err() == context.Canceled)
+	return err
+}
+
+// admit checks the host's breaker, reporting whether the request is the
+// probe after a cooldown
+func (hl *HostLimits) admit(l *hostLimiter) (probe bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.degradedUntil.IsZero() {
+		return false, nil
+	}
+	if l.probing || hl.now().Before(l.degradedUntil) {
+		l.rejected++
+		return false, errHostDegraded
+	}
+	l.probing = true
+	return true, nil
+}
+
+// record updates the breaker with a call's outcome. Calls the caller
+// cancelled say nothing about the host.
+func (hl *HostLimits) record(hostID string, l *hostLimiter, probe bool, err error, cancelled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if probe {
+		l.probing = false
+	}
+
+	switch {
+	case cancelled:
+		return
+	case isTimeout(err):
+		l.timeouts++
+		l.consecutiveTimeouts++
+		if !probe && (!l.degradedUntil.IsZero() || l.consecutiveTimeouts < hl.cfg.BreakerTimeouts) {
+			return
+		}
+		if l.degradedUntil.IsZero() {
+			l.trips++
+			log.Printf("Docker host %s (%s) degraded after %d consecutive timeouts, pausing requests for %v",
+				hl.names(hostID), truncateID(hostID, 8), l.consecutiveTimeouts, hl.cfg.BreakerCooldown)
+		}
+		l.degradedUntil = hl.now().Add(hl.cfg.BreakerCooldown)
+	default:
+		l.consecutiveTimeouts = 0
+		if !l.degradedUntil.IsZero() {
+			l.degradedUntil = time.Time{}
+			log.Printf("Docker host %s (%s) is responding again", hl.names(hostID), truncateID(hostID, 8))
+		}
+	}
+}
+
+// isTimeout reports whether a Docker call failed by running out of time,
+// as opposed to being refused or answered with an error
+func isTimeout(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errStreamOpenTimeout) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Stats returns every host's limiter state, ordered by host ID
+func (hl *HostLimits) Stats() []HostLimitStats {
+	hl.mu.Lock()
+	ids := make([]string, 0, len(hl.limiters))
+	limiters := make(map[string]*hostLimiter, len(hl.limiters))
+	for id, l := range hl.limiters {
+		ids = append(ids, id)
+		limiters[id] = l
+	}
+	hl.mu.Unlock()
+	sort.Strings(ids)
+
+	now := hl.now()
+	stats := make([]HostLimitStats, 0, len(ids))
+	for _, id := range ids {
+		l := limiters[id]
+		l.mu.Lock()
+		s := HostLimitStats{
+			HostID:              id,
+			InFlight:            len(l.slots),
+			Queued:              l.queued,
+			MaxConcurrent:       cap(l.slots),
+			Degraded:            !l.degradedUntil.IsZero(),
+			ConsecutiveTimeouts: l.consecutiveTimeouts,
+			Timeouts:            l.timeouts,
+			Trips:               l.trips,
+			Rejected:            l.rejected,
+		}
+		if l.degradedUntil.After(now) {
+			until := l.degradedUntil
+			s.DegradedUntil = &until
+		}
+		l.mu.Unlock()
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostLimits_BoundsConcurrentCalls(t *testing.T) {
+	hl := NewHostLimits(HostLimitConfig{MaxConcurrent: 2}, nil)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hl.Call(context.Background(), "host-1", func() error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+	}
+
+	<-started
+	<-started
+	waitFor(t, func() bool {
+		s := hl.Stats()
+		return len(s) == 1 && s[0].InFlight == 2 && s[0].Queued == 1
+	})
+	select {
+	case <-started:
+		t.Fatal("third call ran past the limit")
+	default:
+	}
+
+	// Other hosts have slots of their own
+	if err := hl.Call(context.Background(), "host-2", func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	close(release)
+	wg.Wait()
+	if s := hl.Stats()[0]; s.InFlight != 0 || s.Queued != 0 {
+		t.Errorf("after the calls: %+v", s)
+	}
+}
+
+func TestHostLimits_QueuedCallGivesUpWithContext(t *testing.T) {
+	hl := NewHostLimits(HostLimitConfig{MaxConcurrent: 1}, nil)
+	release := make(chan struct{})
+	go hl.Call(context.Background(), "host-1", func() error { <-release; return nil })
+	defer close(release)
+	waitFor(t, func() bool {
+		s := hl.Stats()
+		return len(s) == 1 && s[0].InFlight == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	called := false
+	err := hl.Call(ctx, "host-1", func() error { called = true; return nil })
+	if !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Errorf("err = %v, called = %v; want the wait to end with the context", err, called)
+	}
+	if s := hl.Stats()[0]; s.Queued != 0 || s.Timeouts != 0 {
+		t.Errorf("waiting for a slot was counted against the host: %+v", s)
+	}
+}
+
+func TestHostLimits_CircuitBreaker(t *testing.T) {
+	hl := NewHostLimits(HostLimitConfig{BreakerTimeouts: 3, BreakerCooldown: time.Minute}, nil)
+	now := time.Now()
+	hl.now = func() time.Time { return now }
+	ctx := context.Background()
+	timeout := func() error { return context.DeadlineExceeded }
+	ok := func() error { return nil }
+
+	for i := 0; i < 2; i++ {
+		hl.Call(ctx, "host-1", timeout)
+	}
+	// A success in between starts the count over
+	hl.Call(ctx, "host-1", ok)
+	for i := 0; i < 2; i++ {
+		hl.Call(ctx, "host-1", timeout)
+	}
+	if hl.Stats()[0].Degraded {
+		t.Fatal("degraded before 3 consecutive timeouts")
+	}
+
+	hl.Call(ctx, "host-1", timeout)
+	s := hl.Stats()[0]
+	if !s.Degraded || s.Trips != 1 || s.DegradedUntil == nil {
+		t.Fatalf("after 3 consecutive timeouts: %+v", s)
+	}
+
+	called := false
+	if err := hl.Call(ctx, "host-1", func() error { called = true; return nil }); !errors.Is(err, errHostDegraded) || called {
+		t.Errorf("degraded host was called: err = %v, called = %v", err, called)
+	}
+
+	// After the cooldown one probe goes out; a timeout keeps the host degraded
+	now = now.Add(time.Minute)
+	if err := hl.Call(ctx, "host-1", timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("probe: err = %v, want it sent", err)
+	}
+	if err := hl.Call(ctx, "host-1", ok); !errors.Is(err, errHostDegraded) {
+		t.Errorf("call after a failed probe: err = %v, want another cooldown", err)
+	}
+
+	// A probe that answers closes the breaker
+	now = now.Add(time.Minute)
+	if err := hl.Call(ctx, "host-1", ok); err != nil {
+		t.Fatal(err)
+	}
+	s = hl.Stats()[0]
+	if s.Degraded || s.ConsecutiveTimeouts != 0 || s.Trips != 1 || s.Rejected != 2 || s.Timeouts != 6 {
+		t.Errorf("after a good probe: %+v", s)
+	}
+}
+
+func TestHostLimits_CancelledCallsDontCount(t *testing.T) {
+	hl := NewHostLimits(HostLimitConfig{BreakerTimeouts: 1}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	hl.Call(ctx, "host-1", func() error {
+		cancel()
+		return context.Canceled
+	})
+	if s := hl.Stats()[0]; s.Degraded || s.Timeouts != 0 {
+		t.Errorf("cancelled call counted as a timeout: %+v", s)
+	}
+
+	// Refused connections aren't timeouts either
+	hl.Call(context.Background(), "host-1", func() error { return errors.New("connection refused") })
+	if s := hl.Stats()[0]; s.Degraded {
+		t.Errorf("error response tripped the breaker: %+v", s)
+	}
+}
+
+func TestHostLimits_Nil(t *testing.T) {
+	var hl *HostLimits
+	called := false
+	if err := hl.Call(context.Background(), "host-1", func() error { called = true; return nil }); err != nil || !called {
+		t.Errorf("nil limits: err = %v, called = %v", err, called)
+	}
+	hl.Forget("host-1")
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/darthnorse/dockmon-shared/cpustat"
 )
 
 // HostProcReader reads actual host CPU/memory stats from /host/proc
@@ -16,9 +18,11 @@ import (
 type HostProcReader struct {
 	procPath    string
 	available   bool
+	skipPerCore bool // set when HOST_CPU_PER_CORE doesn't use /proc
 	mu          sync.RWMutex
 	lastCPU     cpuTimes
 	lastCPUTime time.Time
+	lastCores   []cpustat.Times
 }
 
 // cpuTimes holds CPU time values from /proc/stat
@@ -49,6 +53,7 @@ type HostProcStats struct {
 	MemoryUsedBytes  uint64
 	MemoryTotalBytes uint64
 	MemoryPercent    float64
+	CPUPerCore       []float64 // nil until two readings were taken
 }
 
 // NewHostProcReader creates a new reader, checking if /host/proc is available
@@ -65,6 +70,17 @@ func NewHostProcReader() *HostProcReader {
 	}
 }
 
+// SetPerCore turns the per-core breakdown on or off. With it off, GetStats
+// leaves CPUPerCore nil without reading the per-core counters.
+func (h *HostProcReader) SetPerCore(enabled bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.skipPerCore = !enabled
+	if !enabled {
+		h.lastCores = nil
+	}
+}
+
 // IsAvailable returns true if /host/proc is mounted and readable
 func (h *HostProcReader) IsAvailable() bool {
 	return h.available
@@ -91,9 +107,30 @@ func (h *HostProcReader) GetStats() (*HostProcStats, error) {
 		MemoryUsedBytes:  memUsed,
 		MemoryTotalBytes: memTotal,
 		MemoryPercent:    memPercent,
+		CPUPerCore:       h.getPerCorePercent(),
 	}, nil
 }
 
+// getPerCorePercent calculates each core's usage since the previous call.
+// Failures only cost the breakdown, not the host stats.
+func (h *HostProcReader) getPerCorePercent() []float64 {
+	h.mu.RLock()
+	skip := h.skipPerCore
+	h.mu.RUnlock()
+	if skip {
+		return nil
+	}
+	cores, err := cpustat.ReadPerCore(h.procPath + "/stat")
+	if err != nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	perCore := cpustat.PerCore(h.lastCores, cores)
+	h.lastCores = cores
+	return perCore
+}
+
 // getCPUPercent calculates CPU usage percentage from /proc/stat
 func (h *HostProcReader) getCPUPercent() (float64, error) {
 	h.mu.Lock()
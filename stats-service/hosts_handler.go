@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// HostsHandler registers Docker hosts pushed from Python. Registration is
+// idempotent: Python re-sends every host on startup and after reconnects,
+// and a host whose connection settings are unchanged keeps its client and
+// running streams.
+type HostsHandler struct {
+	streams   *StreamManager
+	cache     *StatsCache
+	discovery *ContainerDiscovery
+}
+
+type hostAddRequest struct {
+	HostID      string `json:"host_id"`
+	HostName    string `json:"host_name"`
+	HostAddress string `json:"host_address"`
+	TLSCACert   string `json:"tls_ca_cert,omitempty"`
+	TLSCert     string `json:"tls_cert,omitempty"`
+	TLSKey      string `json:"tls_key,omitempty"`
+	// Alternatives to the inline address and PEM fields above, resolved
+	// into them during validation
+	dockerpkg.HostSource
+	NumCPUs     int    `json:"num_cpus,omitempty"`
+	TotalMemory uint64 `json:"total_memory,omitempty"`
+	IsLocal     bool   `json:"is_local,omitempty"`
+
+	// AutoDiscover makes the service start and stop this host's streams
+	// itself (see ContainerDiscovery) instead of waiting for
+	// /api/streams/start. The label filters only apply with AutoDiscover.
+	AutoDiscover bool `json:"auto_discover,omitempty"`
+	DiscoveryConfig
+}
+
+type hostAddResult struct {
+	HostID string        `json:"host_id"`
+	Result HostAddResult `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Fields []fieldError  `json:"fields,omitempty"` // Set when validation failed
+}
+
+// ServeAdd handles POST /api/hosts/add for a single host.
+func (h *HostsHandler) ServeAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req hostAddRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	result, err := h.addHost(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "added", "result": string(result)})
+}
+
+// ServeBulkAdd handles POST /api/hosts/bulk_add. Each host is registered
+// independently; a failure for one host is reported in its result entry and
+// doesn't abort the rest.
+func (h *HostsHandler) ServeBulkAdd(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Hosts []hostAddRequest `json:"hosts"`
+	}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	results := make([]hostAddResult, 0, len(req.Hosts))
+	for i := range req.Hosts {
+		host := &req.Hosts[i]
+		entry := hostAddResult{HostID: host.HostID}
+
+		if errs := host.validate(); len(errs) > 0 {
+			entry.Error = errs.Error()
+			entry.Fields = errs
+		} else if result, err := h.addHost(host); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Result = result
+		}
+		results = append(results, entry)
+	}
+
+	jsonResponse(w, map[string]interface{}{"results": results})
+}
+
+// ServeTest handles POST /api/hosts/test: it pings the Docker daemon at an
+// address with the given TLS material, without registering anything, so a
+// host can be checked before it is added or when it shows offline. An
+// unreachable host is a 200 with reachable=false; only a bad request fails.
+func (h *HostsHandler) ServeTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req hostTestRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	result := dockerpkg.ProbeHost(r.Context(), req.HostAddress, req.TLSCACert, req.TLSCert, req.TLSKey, timeout)
+	if !result.Reachable {
+		log.Printf("Host test for %s failed at %s: %s", req.HostAddress, result.Stage, result.Error)
+	}
+	jsonResponse(w, result)
+}
+
+// ServeImportContexts handles POST /api/hosts/import_contexts: it turns the
+// output of `docker context inspect` into host definitions the caller can
+// register through /api/hosts/add. TLS material stays on disk and is
+// referenced by path, so the response carries no key material.
+func (h *HostsHandler) ServeImportContexts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeValidationError(w, http.StatusRequestEntityTooLarge, validationErrors{{Message: errBodyTooLarge}})
+		return
+	}
+	contexts, err := dockerpkg.ParseDockerContexts(data)
+	if err != nil {
+		writeValidationError(w, http.StatusBadRequest, validationErrors{{Message: err.Error()}})
+		return
+	}
+	jsonResponse(w, map[string]interface{}{"hosts": contexts})
+}
+
+// addHost registers the host's client and refreshes its cached metadata.
+func (h *HostsHandler) addHost(req *hostAddRequest) (HostAddResult, error) {
+	result, err := h.streams.AddDockerHost(req.HostID, req.HostName, req.HostAddress, req.TLSCACert, req.TLSCert, req.TLSKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Store number of CPUs for host CPU aggregation
+	if req.NumCPUs > 0 {
+		h.cache.SetHostNumCPUs(req.HostID, req.NumCPUs)
+	}
+
+	// Store Docker-visible host memory for host memory aggregation
+	if req.TotalMemory > 0 {
+		h.cache.SetHostMemory(req.HostID, req.TotalMemory)
+	}
+
+	// Mark host as local for /host/proc reading
+	if req.IsLocal {
+		h.cache.SetHostLocal(req.HostID, true)
+	}
+
+	// Switching auto-discovery off hands the host's streams back to Python
+	if req.AutoDiscover {
+		if err := h.discovery.Enable(req.HostID, req.DiscoveryConfig); err != nil {
+			return "", err
+		}
+	} else {
+		h.discovery.Disable(req.HostID)
+	}
+
+	return result, nil
+}
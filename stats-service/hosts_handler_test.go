@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// Clients are created lazily by the Docker SDK, so an unreachable address is
+// enough to exercise registration without a daemon.
+const testHostAddress = "tcp://127.0.0.1:1"
+
+func newTestHostsHandler() *HostsHandler {
+	cache := NewStatsCache()
+	streams := NewStreamManager(cache, dockerpkg.NewPool(0, 0))
+	return &HostsHandler{streams: streams, cache: cache, discovery: NewContainerDiscovery(context.Background(), streams)}
+}
+
+func postHostAdd(t *testing.T, h *HostsHandler, body string) map[string]string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/hosts/add", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeAdd(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+func TestHostsHandler_AddReportsCreatedReusedReplaced(t *testing.T) {
+	h := newTestHostsHandler()
+	defer h.streams.StopAllStreams()
+
+	body := `{"host_id":"h1","host_name":"one","host_address":"` + testHostAddress + `"}`
+	if got := postHostAdd(t, h, body)["result"]; got != string(HostCreated) {
+		t.Errorf("first add result=%q, want created", got)
+	}
+
+	h.streams.clientsMu.RLock()
+	first := h.streams.clients["h1"]
+	h.streams.clientsMu.RUnlock()
+
+	renamed := `{"host_id":"h1","host_name":"renamed","host_address":"` + testHostAddress + `"}`
+	if got := postHostAdd(t, h, renamed)["result"]; got != string(HostReused) {
+		t.Errorf("repeat add result=%q, want reused", got)
+	}
+	h.streams.clientsMu.RLock()
+	if h.streams.clients["h1"] != first {
+		t.Error("reused host should keep its existing client")
+	}
+	h.streams.clientsMu.RUnlock()
+	if name := h.streams.getHostName("h1"); name != "renamed" {
+		t.Errorf("host name=%q, want renamed", name)
+	}
+
+	moved := `{"host_id":"h1","host_name":"renamed","host_address":"tcp://127.0.0.1:2"}`
+	if got := postHostAdd(t, h, moved)["result"]; got != string(HostReplaced) {
+		t.Errorf("changed address result=%q, want replaced", got)
+	}
+}
+
+func TestHostsHandler_BulkAddReportsPerHost(t *testing.T) {
+	h := newTestHostsHandler()
+	defer h.streams.StopAllStreams()
+
+	body := `{"hosts":[
+		{"host_id":"h1","host_name":"one","host_address":"` + testHostAddress + `","num_cpus":4},
+		{"host_id":"h2","host_name":"two"},
+		{"host_id":"h1","host_name":"one","host_address":"` + testHostAddress + `"}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/hosts/bulk_add", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeBulkAdd(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Results []hostAddResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("results=%d, want 3", len(resp.Results))
+	}
+	if resp.Results[0].Result != HostCreated {
+		t.Errorf("h1 result=%q, want created", resp.Results[0].Result)
+	}
+	if resp.Results[1].Error == "" || resp.Results[1].Result != "" {
+		t.Errorf("h2 should fail validation, got %+v", resp.Results[1])
+	}
+	if resp.Results[2].Result != HostReused {
+		t.Errorf("repeated h1 result=%q, want reused", resp.Results[2].Result)
+	}
+}
+
+func TestHostsHandler_AutoDiscoverToggle(t *testing.T) {
+	h := newTestHostsHandler()
+	defer h.streams.StopAllStreams()
+	defer h.discovery.StopAll()
+
+	body := `{"host_id":"h1","host_name":"one","host_address":"` + testHostAddress + `","auto_discover":true,"include_labels":["app=web"]}`
+	postHostAdd(t, h, body)
+	if h.discovery.GetHostCount() != 1 {
+		t.Fatalf("discovery hosts=%d, want 1", h.discovery.GetHostCount())
+	}
+
+	// Re-adding without auto_discover hands streams back to Python
+	postHostAdd(t, h, `{"host_id":"h1","host_name":"one","host_address":"`+testHostAddress+`"}`)
+	if h.discovery.GetHostCount() != 0 {
+		t.Errorf("discovery hosts=%d, want 0", h.discovery.GetHostCount())
+	}
+}
+
+func TestHostsHandler_AddRejectsBadDiscoveryFilter(t *testing.T) {
+	h := newTestHostsHandler()
+	body := `{"host_id":"h1","host_name":"one","host_address":"` + testHostAddress + `","auto_discover":true,"exclude_labels":["=x"]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/hosts/add", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeAdd(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status=%d, want 400", w.Code)
+	}
+}
+
+func TestHostsHandler_BulkAddRejectsBadJSON(t *testing.T) {
+	h := newTestHostsHandler()
+	req := httptest.NewRequest(http.MethodPost, "/api/hosts/bulk_add", bytes.NewBufferString("{nonsense"))
+	w := httptest.NewRecorder()
+	h.ServeBulkAdd(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status=%d, want 400", w.Code)
+	}
+}
+
+func TestHostsHandler_ImportContexts(t *testing.T) {
+	h := newTestHostsHandler()
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/hosts/import_contexts", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		h.ServeImportContexts(w, req)
+		return w
+	}
+
+	w := post(`[{"Name":"nas","Metadata":{"Description":"NAS"},"Endpoints":{"docker":{"Host":"tcp://10.0.0.5:2376","SkipTLSVerify":false}},"TLSMaterial":{},"Storage":{}}]`)
+	var resp struct {
+		Hosts []dockerpkg.DockerContext `json:"hosts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if len(resp.Hosts) != 1 || resp.Hosts[0].Name != "nas" || resp.Hosts[0].Host != "tcp://10.0.0.5:2376" {
+		t.Errorf("hosts = %+v", resp.Hosts)
+	}
+
+	if w := post(`{"hosts":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("non-inspect body: status=%d, want 400", w.Code)
+	}
+}
+
+func TestHostsHandler_TestReportsReachability(t *testing.T) {
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Header().Set("Api-Version", "1.45")
+			w.Write([]byte("OK"))
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			w.Write([]byte(`{"ID":"ENGINE-1","ServerVersion":"27.1.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer daemon.Close()
+
+	h := newTestHostsHandler()
+	probe := func(body string) (int, dockerpkg.ProbeResult) {
+		req := httptest.NewRequest(http.MethodPost, "/api/hosts/test", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		h.ServeTest(w, req)
+		var result dockerpkg.ProbeResult
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	addr := "tcp://" + strings.TrimPrefix(daemon.URL, "http://")
+	code, result := probe(`{"host_address":"` + addr + `","timeout_seconds":2}`)
+	if code != http.StatusOK || !result.Reachable || result.EngineID != "ENGINE-1" || result.APIVersion != "1.45" {
+		t.Errorf("reachable host: status=%d result=%+v", code, result)
+	}
+
+	code, result = probe(`{"host_address":"` + testHostAddress + `","timeout_seconds":2}`)
+	if code != http.StatusOK || result.Reachable || result.Stage != dockerpkg.ProbeStagePing {
+		t.Errorf("unreachable host: status=%d result=%+v", code, result)
+	}
+
+	if code, _ = probe(`{"host_address":"` + addr + `","timeout_seconds":600}`); code != http.StatusBadRequest {
+		t.Errorf("oversized timeout: status=%d, want 400", code)
+	}
+
+	// Nothing is registered
+	h.streams.clientsMu.RLock()
+	defer h.streams.clientsMu.RUnlock()
+	if len(h.streams.clients) != 0 {
+		t.Errorf("test registered %d clients", len(h.streams.clients))
+	}
+}
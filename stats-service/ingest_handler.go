@@ -2,10 +2,14 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
+	"github.com/darthnorse/dockmon-shared/clock"
 	"github.com/dockmon/stats-service/persistence"
 	"github.com/gorilla/websocket"
 )
@@ -16,29 +20,47 @@ import (
 // frame (gorilla/websocket's default is unlimited).
 const maxIngestMessageBytes = 16 * 1024
 
-// IngestHandler accepts WebSocket connections from agents and feeds the
-// existing StatsCache. The host_id is bound from agent token validation
+// IngestHandler accepts WebSocket connections from agents, and batches of
+// samples over HTTP, and feeds the existing StatsCache. The host_id is bound from agent token validation
 // at upgrade time, NEVER from the message body — so a compromised agent
-// cannot spoof which host it belongs to. See spec §10.
+// cannot spoof which host it belongs to. See spec §10. Only the admin token
+// may name the host itself, on the HTTP batch endpoint.
 type IngestHandler struct {
-	db       *persistence.DB
+	db       *persistence.DB // Nil without persistence: agent tokens can't be checked
+	tokens   *tokenSet       // Stats-service tokens, for the HTTP batch endpoint
 	cache    *StatsCache
+	clocks   *clock.Tracker // Optional: records agent clock heartbeats
+	events   *EventManager  // Optional: broadcasts agent activity events
+	agents   *AgentRegistry // Optional: tracks connected agents for /health/full
 	upgrader websocket.Upgrader
 }
 
 // agentStatsMsg is the wire format. Deliberately does NOT include host_id
 // so a malicious client cannot smuggle it past the trusted-from-auth binding.
+// Type "clock" marks a clock heartbeat carrying only AgentTime, and type
+// "activity" a DockMon activity event carrying only Activity; anything else
+// is a stats sample, or with State set, a container being paused ("paused",
+// no metrics) or unpaused ("running").
 type agentStatsMsg struct {
-	ContainerID   string  `json:"container_id"`
-	ContainerName string  `json:"container_name"`
-	CPUPercent    float64 `json:"cpu_percent"`
-	MemoryUsage   uint64  `json:"memory_usage"`
-	MemoryLimit   uint64  `json:"memory_limit"`
-	MemoryPercent float64 `json:"memory_percent"`
-	NetworkRx     uint64  `json:"network_rx"`
-	NetworkTx     uint64  `json:"network_tx"`
-	DiskRead      uint64  `json:"disk_read"`
-	DiskWrite     uint64  `json:"disk_write"`
+	Type          string          `json:"type,omitempty"`
+	AgentTime     string          `json:"agent_time,omitempty"`
+	Activity      *activity.Event `json:"activity,omitempty"`
+	ContainerID   string          `json:"container_id"`
+	ContainerName string          `json:"container_name"`
+	Group         string          `json:"group,omitempty"` // dockmon.group label
+	CPUPercent    float64         `json:"cpu_percent"`
+	MemoryUsage   uint64          `json:"memory_usage"`
+	MemoryLimit   uint64          `json:"memory_limit"`
+	MemoryPercent float64         `json:"memory_percent"`
+	NetworkRx     uint64          `json:"network_rx"`
+	NetworkTx     uint64          `json:"network_tx"`
+	DiskRead      uint64          `json:"disk_read"`
+	DiskWrite     uint64          `json:"disk_write"`
+
+	MemoryRawUsage   uint64 `json:"memory_raw_usage,omitempty"`
+	MemoryWorkingSet uint64 `json:"memory_working_set,omitempty"`
+
+	State string `json:"state,omitempty"`
 }
 
 // HandleWebSocket authenticates the agent via its permanent UUID token,
@@ -95,6 +117,8 @@ func (h *IngestHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 	defer close(watcherDone)
 
 	log.Printf("Agent ingest: connected for host %s", truncateID(hostID, 8))
+	h.agents.Connected(hostID)
+	defer h.agents.Disconnected(hostID)
 
 	for {
 		var msg agentStatsMsg
@@ -103,31 +127,204 @@ func (h *IngestHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request)
 				truncateID(hostID, 8), err)
 			return
 		}
-		// Drop empty container IDs so we don't pollute the cache with
-		// a blank composite key.
-		if msg.ContainerID == "" {
+		h.agents.Seen(hostID)
+		if msg.Type == "clock" {
+			h.observeClock(hostID, msg.AgentTime)
+			continue
+		}
+		if msg.Type == "activity" {
+			h.publishActivity(hostID, msg.Activity)
 			continue
 		}
-		// Normalize container ID at the boundary (CLAUDE.md defense-in-depth).
-		// UpdateContainerStats sets LastUpdate internally.
-		cid := msg.ContainerID
-		if len(cid) > 12 {
-			cid = cid[:12]
+		if errs := validateStatsSample(&msg, ""); len(errs) > 0 {
+			log.Printf("Agent ingest: dropping stats sample from host %s: %v",
+				truncateID(hostID, 8), errs)
+			continue
+		}
+		h.applySample(hostID, &msg)
+	}
+}
+
+// applySample feeds one stats sample or pause marker into the StatsCache.
+// hostID comes from authentication, never from the sample itself.
+func (h *IngestHandler) applySample(hostID string, msg *agentStatsMsg) {
+	// Drop empty container IDs so we don't pollute the cache with
+	// a blank composite key.
+	if msg.ContainerID == "" {
+		return
+	}
+	// Normalize container ID at the boundary (CLAUDE.md defense-in-depth).
+	// UpdateContainerStats sets LastUpdate internally.
+	cid := msg.ContainerID
+	if len(cid) > 12 {
+		cid = cid[:12]
+	}
+	switch msg.State {
+	case containerStatePaused:
+		h.cache.MarkContainerPaused(cid, msg.ContainerName, hostID)
+		return
+	case "running":
+		h.cache.MarkContainerUnpaused(cid, hostID)
+		return
+	}
+	h.cache.UpdateContainerStats(&ContainerStats{
+		ContainerID:   cid,
+		ContainerName: msg.ContainerName,
+		HostID:        hostID, // FROM AUTH, NOT MSG BODY
+		Group:         msg.Group,
+		CPUPercent:    msg.CPUPercent,
+		MemoryUsage:   msg.MemoryUsage,
+		MemoryLimit:   msg.MemoryLimit,
+		MemoryPercent: msg.MemoryPercent,
+		NetworkRx:     msg.NetworkRx,
+		NetworkTx:     msg.NetworkTx,
+		DiskRead:      msg.DiskRead,
+		DiskWrite:     msg.DiskWrite,
+
+		MemoryRawUsage:   msg.MemoryRawUsage,
+		MemoryWorkingSet: msg.MemoryWorkingSet,
+	})
+}
+
+// maxIngestBatchSamples bounds the samples in one /api/stats/ingest request
+const maxIngestBatchSamples = 1000
+
+// ingestBatchRequest is the body of /api/stats/ingest. HostID is required
+// from the backend and must be empty or match the token's host from an
+// agent.
+type ingestBatchRequest struct {
+	HostID  string          `json:"host_id,omitempty"`
+	Samples []agentStatsMsg `json:"samples"`
+}
+
+func (req *ingestBatchRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.maxLength("host_id", req.HostID, maxIDLength)
+	switch {
+	case len(req.Samples) == 0:
+		errs.add("samples", "is required")
+	case len(req.Samples) > maxIngestBatchSamples:
+		errs.add("samples", "must have at most %d entries (got %d)", maxIngestBatchSamples, len(req.Samples))
+	}
+	for i, s := range req.Samples {
+		field := fmt.Sprintf("samples[%d]", i)
+		if s.Type != "" {
+			errs.add(field+".type", "only stats samples are accepted here, send %q messages over the WebSocket", s.Type)
+			continue
 		}
-		h.cache.UpdateContainerStats(&ContainerStats{
-			ContainerID:   cid,
-			ContainerName: msg.ContainerName,
-			HostID:        hostID, // FROM AUTH, NOT MSG BODY
-			CPUPercent:    msg.CPUPercent,
-			MemoryUsage:   msg.MemoryUsage,
-			MemoryLimit:   msg.MemoryLimit,
-			MemoryPercent: msg.MemoryPercent,
-			NetworkRx:     msg.NetworkRx,
-			NetworkTx:     msg.NetworkTx,
-			DiskRead:      msg.DiskRead,
-			DiskWrite:     msg.DiskWrite,
-		})
+		errs = append(errs, validateStatsSample(&req.Samples[i], field+".")...)
+	}
+	return errs
+}
+
+// ServeBatch accepts a batch of normalized stats samples over HTTP and feeds
+// them into the same StatsCache as the WebSocket, so agent hosts whose stats
+// reach DockMon some other way go through the one pipeline. The Python
+// backend calls it with the admin token and names the host in the body; an
+// agent calls it with its own token, which binds the host as on the
+// WebSocket.
+func (h *IngestHandler) ServeBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tokenHost, status := h.authenticateBatch(r)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	var req ingestBatchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	hostID := req.HostID
+	switch {
+	case tokenHost == "" && hostID == "":
+		writeValidationError(w, http.StatusBadRequest, validationErrors{{Field: "host_id", Message: "is required"}})
+		return
+	case tokenHost != "" && hostID != "" && hostID != tokenHost:
+		log.Printf("Agent ingest: agent for host %s sent stats for host %s, rejecting",
+			truncateID(tokenHost, 8), truncateID(hostID, 8))
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case tokenHost != "":
+		hostID = tokenHost
+		h.agents.Seen(hostID)
+	}
+
+	debugf("Stats ingest: %d samples for host %s", len(req.Samples), truncateID(hostID, 8))
+	for i := range req.Samples {
+		h.applySample(hostID, &req.Samples[i])
+	}
+	jsonResponse(w, map[string]int{"accepted": len(req.Samples)})
+}
+
+// authenticateBatch checks a batch request's Bearer token: the admin token
+// may push for any host, an agent token only for its own. It returns the
+// agent's host (empty for the admin token) and the HTTP status to fail with,
+// or http.StatusOK.
+func (h *IngestHandler) authenticateBatch(r *http.Request) (string, int) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", http.StatusUnauthorized
+	}
+	if scope, ok := h.tokens.scopeOf(token); ok {
+		if !scope.allows(scopeAdmin) {
+			return "", http.StatusForbidden
+		}
+		return "", http.StatusOK
+	}
+	if h.db == nil {
+		return "", http.StatusUnauthorized
+	}
+	hostID, err := h.db.ValidateAgentToken(r.Context(), token)
+	if err != nil {
+		if !errors.Is(err, persistence.ErrInvalidAgentToken) {
+			log.Printf("Agent ingest: token validate error: %v", err)
+			return "", http.StatusInternalServerError
+		}
+		return "", http.StatusUnauthorized
+	}
+	return hostID, http.StatusOK
+}
+
+// observeClock records an agent's clock heartbeat. Stats samples themselves
+// are stamped on receipt, so they are already on our clock; the offset is
+// kept for diagnostics and for anything that does carry agent timestamps.
+func (h *IngestHandler) observeClock(hostID, agentTime string) {
+	if h.clocks == nil {
+		return
+	}
+	remote, err := time.Parse(time.RFC3339Nano, agentTime)
+	if err != nil {
+		return
+	}
+	prev, measured := h.clocks.Offset(hostID)
+	est := h.clocks.Observe(hostID, clock.FromOneWay(remote, time.Now()))
+	// Log on the transition into drift rather than on every heartbeat
+	wasDrifting := measured && (prev > clock.DriftWarnThreshold || prev < -clock.DriftWarnThreshold)
+	if est.Drifting && !wasDrifting {
+		log.Printf("Agent ingest: clock drift on host %s: %dms from local clock",
+			truncateID(hostID, 8), est.OffsetMs)
+	}
+}
+
+// publishActivity broadcasts an agent's activity event. As with stats, the
+// host comes from the agent's token, and the source is always "agent".
+func (h *IngestHandler) publishActivity(hostID string, event *activity.Event) {
+	if h.events == nil || event == nil {
+		return
+	}
+	if errs := validateActivity(*event); len(errs) > 0 {
+		log.Printf("Agent ingest: dropping activity event from host %s: %v",
+			truncateID(hostID, 8), errs)
+		return
 	}
+	event.HostID = hostID
+	event.Source = "agent"
+	h.events.PublishActivity(hostID, *event)
 }
 
 // extractAgentToken pulls a Bearer token from the Authorization header or
@@ -11,6 +11,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/activity"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/dockmon/stats-service/persistence"
 	"github.com/gorilla/websocket"
 )
@@ -172,6 +174,31 @@ func TestIngestHandler_HostIDFromAuthNotMessage(t *testing.T) {
 	}
 }
 
+func TestIngestHandler_ActivityHostFromAuth(t *testing.T) {
+	events := NewEventCache(10)
+	h := &IngestHandler{events: NewEventManager(NewEventBroadcaster(), events, nil, dockerpkg.NewPool(0, 0))}
+
+	h.publishActivity("host-1", &activity.Event{
+		Action:  activity.ActionSelfUpdateApplied,
+		HostID:  "host-2",
+		Source:  "compose",
+		Message: "agent updated to 1.4.0",
+	})
+	h.publishActivity("host-1", &activity.Event{Action: "reboot"})
+	h.publishActivity("host-1", &activity.Event{
+		Action:  activity.ActionUpdateFailed,
+		Message: strings.Repeat("x", maxMessageLength+1),
+	})
+
+	if got := events.GetRecentEvents("host-2", 10); len(got) != 0 {
+		t.Errorf("agent spoofed host_id: %+v", got)
+	}
+	got := events.GetRecentEvents("host-1", 10)
+	if len(got) != 1 || got[0].Action != "self_update_applied" || got[0].Attributes["source"] != "agent" {
+		t.Errorf("events = %+v, want one self_update_applied from the agent", got)
+	}
+}
+
 func TestIngestHandler_NormalizesLongContainerID(t *testing.T) {
 	cache, db, h := makeIngestFixture(t)
 	if _, err := db.Write().Exec(
@@ -215,6 +242,111 @@ func TestIngestHandler_NormalizesLongContainerID(t *testing.T) {
 	t.Errorf("expected normalized 12-char container ID in cache")
 }
 
+func TestIngestHandler_DropsInvalidSamples(t *testing.T) {
+	cache, db, h := makeIngestFixture(t)
+	if _, err := db.Write().Exec(
+		`INSERT INTO docker_hosts (id,name) VALUES ('host-1','h1')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Write().Exec(
+		`INSERT INTO agents (id, host_id) VALUES ('tok1','host-1')`); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/stats/ingest"
+	header := http.Header{"Authorization": {"Bearer tok1"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Same limits as the HTTP batch; the valid sample after it still lands
+	for _, msg := range []map[string]interface{}{
+		{"container_id": "bigbigbigbig", "group": strings.Repeat("g", maxNameLength+1)},
+		{"container_id": "okokokokokok", "group": "web"},
+	} {
+		data, _ := json.Marshal(msg)
+		_ = conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		ids := make(map[string]bool)
+		for _, s := range cache.GetAllContainerStats() {
+			ids[s.ContainerID] = true
+		}
+		if ids["okokokokokok"] {
+			if ids["bigbigbigbig"] {
+				t.Error("sample with an oversized group was accepted")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected the valid sample in cache")
+}
+
+func TestIngestHandler_PauseMarkers(t *testing.T) {
+	cache, db, h := makeIngestFixture(t)
+	if _, err := db.Write().Exec(
+		`INSERT INTO docker_hosts (id,name) VALUES ('host-1','h1')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Write().Exec(
+		`INSERT INTO agents (id, host_id) VALUES ('tok1','host-1')`); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/stats/ingest"
+	header := http.Header{"Authorization": {"Bearer tok1"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	waitForState := func(want string, cpu float64) {
+		t.Helper()
+		deadline := time.Now().Add(500 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			if s, ok := cache.GetContainerStats("abc123abc123", "host-1"); ok && s.State == want && s.CPUPercent == cpu {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		s, _ := cache.GetContainerStats("abc123abc123", "host-1")
+		t.Fatalf("container stats = %+v, want state %q and cpu %v", s, want, cpu)
+	}
+
+	// A sample, the pause marker, then a sample sent before the agent
+	// stopped its stream, which is ignored
+	for _, msg := range []map[string]interface{}{
+		{"container_id": "abc123abc123", "cpu_percent": 12.5},
+		{"container_id": "abc123abc123", "container_name": "web", "state": "paused"},
+		{"container_id": "abc123abc123", "cpu_percent": 3.0},
+	} {
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForState(containerStatePaused, 0)
+
+	for _, msg := range []map[string]interface{}{
+		{"container_id": "abc123abc123", "state": "running"},
+		{"container_id": "abc123abc123", "cpu_percent": 8.0},
+	} {
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForState("", 8)
+}
+
 // TestIngestHandler_ContextCancellationReturnsHandler verifies that when the
 // request context is cancelled (e.g. on server shutdown) the handler
 // goroutine unblocks from its ReadJSON loop and returns in a timely manner.
@@ -283,6 +415,105 @@ func TestIngestHandler_ContextCancellationReturnsHandler(t *testing.T) {
 	}
 }
 
+func postIngestBatch(h *IngestHandler, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/stats/ingest", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeBatch(w, req)
+	return w
+}
+
+func TestIngestHandler_BatchFromBackend(t *testing.T) {
+	cache, _, h := makeIngestFixture(t)
+	h.tokens = &tokenSet{tokens: []scopedToken{
+		{scope: scopeAdmin, token: "admin-tok"},
+		{scope: scopeStatsRead, token: "read-tok"},
+	}}
+	body := `{"host_id":"host-1","samples":[
+		{"container_id":"abc123abc123def","container_name":"nginx","cpu_percent":42,"memory_usage":1024,"memory_limit":8192},
+		{"container_id":"fff111fff111","container_name":"db","state":"paused"}]}`
+
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "read-tok": http.StatusForbidden} {
+		if w := postIngestBatch(h, token, body); w.Code != want {
+			t.Errorf("token %q: status=%d, want %d", token, w.Code, want)
+		}
+	}
+	if len(cache.GetAllContainerStats()) != 0 {
+		t.Fatal("rejected batch reached the cache")
+	}
+
+	w := postIngestBatch(h, "admin-tok", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	stats := cache.GetAllContainerStats()
+	s, ok := stats["host-1:abc123abc123"]
+	if !ok || s.CPUPercent != 42 || s.ContainerName != "nginx" {
+		t.Errorf("sample not cached under host-1 with the short ID: %+v", stats)
+	}
+	if p, ok := stats["host-1:fff111fff111"]; !ok || p.State != containerStatePaused {
+		t.Errorf("pause marker not applied: %+v", p)
+	}
+
+	// The backend has to say which host the samples are for
+	w = postIngestBatch(h, "admin-tok", `{"samples":[{"container_id":"abc123abc123"}]}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"host_id"`) {
+		t.Errorf("missing host_id: status=%d body=%s", w.Code, w.Body.String())
+	}
+}
+
+func TestIngestHandler_BatchFromAgentBindsHost(t *testing.T) {
+	cache, db, h := makeIngestFixture(t)
+	h.tokens = &tokenSet{}
+	if _, err := db.Write().Exec(
+		`INSERT INTO docker_hosts (id,name) VALUES ('host-1','h1'),('host-2','h2')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Write().Exec(
+		`INSERT INTO agents (id, host_id) VALUES ('agent-tok','host-1')`); err != nil {
+		t.Fatal(err)
+	}
+
+	w := postIngestBatch(h, "agent-tok", `{"host_id":"host-2","samples":[{"container_id":"abc123abc123"}]}`)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("agent pushing for another host: status=%d, want 403", w.Code)
+	}
+
+	w = postIngestBatch(h, "agent-tok", `{"samples":[{"container_id":"abc123abc123","cpu_percent":7}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if s, ok := cache.GetAllContainerStats()["host-1:abc123abc123"]; !ok || s.CPUPercent != 7 {
+		t.Errorf("sample not cached under the agent's host")
+	}
+}
+
+func TestIngestBatchRequest_Validate(t *testing.T) {
+	cases := map[string]struct {
+		req   ingestBatchRequest
+		field string
+	}{
+		"no samples":     {ingestBatchRequest{HostID: "h"}, "samples"},
+		"too many":       {ingestBatchRequest{Samples: make([]agentStatsMsg, maxIngestBatchSamples+1)}, "samples"},
+		"no container":   {ingestBatchRequest{Samples: []agentStatsMsg{{CPUPercent: 1}}}, "samples[0].container_id"},
+		"clock message":  {ingestBatchRequest{Samples: []agentStatsMsg{{Type: "clock"}}}, "samples[0].type"},
+		"unknown state":  {ingestBatchRequest{Samples: []agentStatsMsg{{ContainerID: "c", State: "exited"}}}, "samples[0].state"},
+		"long host name": {ingestBatchRequest{HostID: strings.Repeat("h", maxIDLength+1), Samples: []agentStatsMsg{{ContainerID: "c"}}}, "host_id"},
+	}
+	for name, tc := range cases {
+		errs := tc.req.validate()
+		if len(errs) == 0 || errs[0].Field != tc.field {
+			t.Errorf("%s: errors = %v, want one for %s", name, errs, tc.field)
+		}
+	}
+	ok := ingestBatchRequest{Samples: []agentStatsMsg{{ContainerID: "c", State: "running"}}}
+	if errs := ok.validate(); len(errs) != 0 {
+		t.Errorf("valid request rejected: %v", errs)
+	}
+}
+
 func TestInvalidateHandler_EvictsCachedToken(t *testing.T) {
 	path := persistence.MakeFixtureDBForTest(t)
 	db, err := persistence.Open(path)
@@ -102,7 +102,7 @@ func TestIntegration_AgentToHistoryRoundTrip(t *testing.T) {
 			CheckOrigin: func(*http.Request) bool { return true },
 		},
 	}
-	history := NewHistoryHandler(db, tiers)
+	history := NewHistoryHandler(db, tiers, nil)
 	mux.HandleFunc("/api/stats/ws/ingest", ingest.HandleWebSocket)
 	mux.HandleFunc("/api/stats/history/container", history.ServeContainer)
 
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// logLevel gates the stats-service's debug logging. Everything else is
+// logged unconditionally through the standard logger, so the only levels
+// are "info" and "debug". Changed at runtime through /admin/loglevel.
+type logLevel struct {
+	debug atomic.Bool
+}
+
+// serviceLogLevel is set from LOG_LEVEL at startup
+var serviceLogLevel = &logLevel{}
+
+func (l *logLevel) Level() string {
+	if l.debug.Load() {
+		return "debug"
+	}
+	return "info"
+}
+
+func (l *logLevel) SetLevel(level string) error {
+	switch strings.ToLower(level) {
+	case "debug":
+		l.debug.Store(true)
+	case "info":
+		l.debug.Store(false)
+	default:
+		return fmt.Errorf("stats-service supports debug and info")
+	}
+	return nil
+}
+
+// debugf logs only while the level is debug
+func debugf(format string, args ...interface{}) {
+	if serviceLogLevel.debug.Load() {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestLogLevel(t *testing.T) {
+	l := &logLevel{}
+	if l.Level() != "info" {
+		t.Errorf("default level = %s, want info", l.Level())
+	}
+	if err := l.SetLevel("DEBUG"); err != nil || l.Level() != "debug" {
+		t.Errorf("SetLevel(DEBUG): err = %v, level = %s", err, l.Level())
+	}
+	if err := l.SetLevel("trace"); err == nil || l.Level() != "debug" {
+		t.Errorf("SetLevel(trace): err = %v, level = %s; want rejected", err, l.Level())
+	}
+	if err := l.SetLevel("info"); err != nil || l.Level() != "info" {
+		t.Errorf("SetLevel(info): err = %v, level = %s", err, l.Level())
+	}
+}
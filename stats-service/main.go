@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"crypto/rand"
-	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -12,30 +11,84 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/darthnorse/dockmon-shared/admin"
+	"github.com/darthnorse/dockmon-shared/clock"
+	"github.com/darthnorse/dockmon-shared/debugserver"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
 	"github.com/dockmon/stats-service/persistence"
 	"github.com/gorilla/websocket"
 )
 
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// apiLevel is reported in /health so the backend can tell an outdated stats
+// service from a broken one. Bump it whenever the backend starts relying on
+// a new endpoint or request field.
+const apiLevel = 2
+
 // Configuration with environment variable support
 var config = struct {
 	TokenFilePath       string
+	StatsTokenFilePath  string
+	EventsTokenFilePath string
 	Port                string
 	AggregationInterval time.Duration
 	EventCacheSize      int
+	EventCacheHostSizes string
 	MaxRequestBodySize  int64
 	AllowedOrigins      string
+	AllowPrivateOrigins bool
+	MemoryMode          string
+	HostCPUPerCore      string
+	PprofAddr           string
+	PprofAllowRemote    bool
+	StoppedRetention    time.Duration
+	ComposeSocketPath   string
+	HostLimits          HostLimitConfig
+	LogLevel            string
+	CleanupInterval     time.Duration
+	ConfigFile          string
+	MaintenanceFilePath string
 }{
 	TokenFilePath:       getEnv("TOKEN_FILE_PATH", "/app/data/stats-service-token"),
+	StatsTokenFilePath:  getEnv("STATS_TOKEN_FILE_PATH", "/app/data/stats-service-token-stats"),
+	EventsTokenFilePath: getEnv("EVENTS_TOKEN_FILE_PATH", "/app/data/stats-service-token-events"),
 	Port:                getEnv("STATS_SERVICE_PORT", "8081"),
 	AggregationInterval: getEnvDuration("AGGREGATION_INTERVAL", "1s"),
 	EventCacheSize:      getEnvInt("EVENT_CACHE_SIZE", 100),
+	EventCacheHostSizes: getEnv("EVENT_CACHE_HOST_SIZES", ""),          // host_id=size,... overrides
 	MaxRequestBodySize:  getEnvInt64("MAX_REQUEST_BODY_SIZE", 1048576), // 1MB default
+	MemoryMode:          getEnv("STATS_MEMORY_MODE", string(dockerpkg.MemoryModeWorkingSet)),
+	HostCPUPerCore:      getEnv("HOST_CPU_PER_CORE", PerCoreAuto), // auto, proc, containers or off
+	PprofAddr:           getEnv("STATS_PPROF_ADDR", ""),           // empty = profiling endpoints disabled
+	PprofAllowRemote:    getEnvBool("PPROF_ALLOW_REMOTE", false),
+	// How long a stopped container's final sample stays in the cache (0 = drop at once)
+	StoppedRetention: getEnvDuration("STOPPED_CONTAINER_RETENTION", "5m"),
+	// compose-service socket, probed by /health/full
+	ComposeSocketPath: getEnv("COMPOSE_SOCKET_PATH", "/tmp/compose.sock"),
+	// Per-host Docker API request limit and timeout circuit breaker
+	HostLimits: HostLimitConfig{
+		MaxConcurrent:   getEnvInt("DOCKER_MAX_CONCURRENT_REQUESTS", defaultMaxHostRequests),
+		BreakerTimeouts: getEnvInt("DOCKER_BREAKER_TIMEOUTS", defaultBreakerTimeouts),
+		BreakerCooldown: getEnvDuration("DOCKER_BREAKER_COOLDOWN", defaultBreakerCooldown.String()),
+	},
+	// "debug" adds per-sample and discovery detail; changeable at runtime
+	LogLevel: getEnv("LOG_LEVEL", "info"),
+	// How often, and after how long without a sample, stale stats are dropped
+	CleanupInterval: getEnvDuration("STATS_CLEANUP_INTERVAL", "60s"),
+	// Optional KEY=VALUE file of settings re-read on SIGHUP, see reload.go
+	ConfigFile: getEnv("STATS_CONFIG_FILE", ""),
+	// Hosts' maintenance windows, kept across restarts
+	MaintenanceFilePath: getEnv("MAINTENANCE_FILE_PATH", "/app/data/stats-service-maintenance.json"),
+	// Allow WebSocket origins on private, loopback and link-local IPs (LAN access)
+	AllowPrivateOrigins: getEnvBool("ALLOW_PRIVATE_NETWORK_ORIGINS", false),
 	AllowedOrigins: getEnv("ALLOWED_ORIGINS",
 		"http://localhost:8080,http://localhost:3000,http://localhost,http://127.0.0.1:8080,http://127.0.0.1:3000,http://127.0.0.1,"+
 			"https://localhost:8080,https://localhost:3000,https://localhost,https://127.0.0.1:8080,https://127.0.0.1:3000,https://127.0.0.1"),
@@ -69,6 +122,16 @@ func getEnvInt64(key string, fallback int64) int64 {
 	return fallback
 }
 
+// getEnvBool gets boolean environment variable with fallback
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolVal, err := strconv.ParseBool(value); err == nil {
+			return boolVal
+		}
+	}
+	return fallback
+}
+
 // getEnvDuration gets duration environment variable with fallback
 func getEnvDuration(key string, fallback string) time.Duration {
 	value := getEnv(key, fallback)
@@ -166,67 +229,112 @@ func jsonResponse(w http.ResponseWriter, data interface{}) {
 	}
 }
 
-// authMiddleware validates the Bearer token using constant-time comparison
-func authMiddleware(token string, next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get Authorization header
-		authHeader := r.Header.Get("Authorization")
-		expectedAuth := "Bearer " + token
-
-		// Use constant-time comparison to prevent timing attacks
-		// Check length first (still constant-time for the comparison itself)
-		if len(authHeader) != len(expectedAuth) {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			log.Printf("Unauthorized request from %s to %s (length mismatch)", r.RemoteAddr, r.URL.Path)
-			return
-		}
-
-		// Constant-time comparison of the full auth header
-		if subtle.ConstantTimeCompare([]byte(authHeader), []byte(expectedAuth)) != 1 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			log.Printf("Unauthorized request from %s to %s", r.RemoteAddr, r.URL.Path)
-			return
-		}
-
-		next(w, r)
-	}
-}
-
 func main() {
-	log.Println("Starting DockMon Stats Service...")
-
-	// Generate random token
-	token, err := generateToken()
+	log.Printf("Starting DockMon Stats Service %s (API level %d)...", version, apiLevel)
+
+	// Generate one random token per scope. The admin token goes to
+	// TOKEN_FILE_PATH for the Python backend; the read-only stats and events
+	// tokens are for callers such as the frontend proxy that shouldn't be
+	// able to add hosts or change settings.
+	tokens, err := newTokenSet(map[tokenScope]string{
+		scopeAdmin:     config.TokenFilePath,
+		scopeStatsRead: config.StatsTokenFilePath,
+		scopeEvents:    config.EventsTokenFilePath,
+	})
 	if err != nil {
-		log.Fatalf("Failed to generate token: %v", err)
+		log.Fatalf("Failed to generate tokens: %v", err)
 	}
 
-	// Write token to file for Python backend using secure atomic write
-	if err := writeTokenSecurely(config.TokenFilePath, token); err != nil {
-		log.Fatalf("Failed to write token file: %v", err)
+	// Write tokens to their files using secure atomic writes
+	if err := tokens.write(); err != nil {
+		log.Fatalf("Failed to write token files: %v", err)
 	}
-	log.Printf("Generated temporary auth token for stats service")
+	log.Printf("Generated temporary auth tokens for stats service")
 	log.Printf("Configuration: port=%s, aggregation=%v, cache_size=%d",
 		config.Port, config.AggregationInterval, config.EventCacheSize)
+	if err := serviceLogLevel.SetLevel(config.LogLevel); err != nil {
+		log.Printf("Warning: invalid LOG_LEVEL %q (%v), using info", config.LogLevel, err)
+	}
 
 	// Create stats cache
 	cache := NewStatsCache()
 
+	// One pooled Docker client per host, shared by stats and event streams,
+	// health-checked in the background
+	clientPool := dockerpkg.NewPool(0, 0)
+
 	// Create stream manager
-	streamManager := NewStreamManager(cache)
+	streamManager := NewStreamManager(cache, clientPool)
+	memoryMode, err := dockerpkg.ParseMemoryMode(config.MemoryMode)
+	if err != nil {
+		log.Printf("Warning: %v, using %s", err, dockerpkg.MemoryModeWorkingSet)
+		memoryMode = dockerpkg.MemoryModeWorkingSet
+	}
+	streamManager.SetMemoryMode(memoryMode)
+
+	// Origins allowed to open the events WebSocket (exact, wildcard or CIDR)
+	originPolicy, err := ParseOriginPolicy(config.AllowedOrigins, config.AllowPrivateOrigins)
+	if err != nil {
+		log.Printf("Warning: ignoring ALLOWED_ORIGINS entries: %v", err)
+	}
 
 	// Create aggregator with configured interval
 	aggregator := NewAggregator(cache, streamManager, config.AggregationInterval)
+	perCoreMode, err := parsePerCoreMode(config.HostCPUPerCore)
+	if err != nil {
+		log.Printf("Warning: %v, using %s", err, PerCoreAuto)
+		perCoreMode = PerCoreAuto
+	}
+	aggregator.SetPerCoreMode(perCoreMode)
+
+	// Origins, intervals and tokens can change without a restart, through
+	// /admin/reload or by editing STATS_CONFIG_FILE and sending SIGHUP
+	cleanupIntervals := newIntervalUpdates()
+	reloader := NewReloader(originPolicy, aggregator, cleanupIntervals, tokens, config.ConfigFile)
+	if config.ConfigFile != "" {
+		if err := reloader.ReloadFile(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 
 	// Create event management components with configured cache size
 	eventCache := NewEventCache(config.EventCacheSize)
+	if hostSizes, err := parseEventCacheHostSizes(config.EventCacheHostSizes); err != nil {
+		log.Printf("Warning: ignoring EVENT_CACHE_HOST_SIZES: %v", err)
+	} else {
+		for hostID, size := range hostSizes {
+			eventCache.SetHostSize(hostID, size)
+		}
+	}
 	eventBroadcaster := NewEventBroadcaster()
-	eventManager := NewEventManager(eventBroadcaster, eventCache)
+	// Per-host clock offsets, kept per source: Docker info round trips drive
+	// event normalization, agent heartbeats are one-way and diagnostic only
+	dockerClocks := clock.NewTracker()
+	agentClocks := clock.NewTracker()
+	agentRegistry := NewAgentRegistry()
+	eventManager := NewEventManager(eventBroadcaster, eventCache, dockerClocks, clientPool)
+	// Lifecycle events marked on container stats history graphs
+	containerMarkers := NewContainerMarkers()
+	eventManager.SetMarkers(containerMarkers)
+	// Stopped containers' final samples, tagged with their exit
+	cache.SetStoppedRetention(config.StoppedRetention)
+	eventManager.SetStatsCache(cache)
+	// Paused containers' streams stop until they're unpaused
+	eventManager.SetStreams(streamManager)
+	// Hosts in maintenance mode, whose planned restarts aren't alerted on
+	hostMaintenance := NewHostMaintenance(eventManager, config.MaintenanceFilePath)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	streamManager.SetHostLimits(config.HostLimits)
+	go clientPool.Run(ctx)
+	go hostMaintenance.Run(ctx)
+
+	// Create container auto-discovery for hosts registered with auto_discover
+	discovery := NewContainerDiscovery(ctx, streamManager)
+
 	// Open persistence DB. dockmon.db lives at the same path Python uses;
 	// the bind-mount makes it available at /app/data/dockmon.db inside both
 	// containers. The schema is owned by Alembic; we verify on open and fall
@@ -294,18 +402,60 @@ func main() {
 	// Start aggregator
 	go aggregator.Start(ctx)
 
-	// Start cleanup routine (remove stale stats every 60 seconds)
-	// Hardcoded at 60s - generous enough to handle network hiccups while
-	// cleaning up stopped containers/disconnected hosts promptly
-	go func() {
-		ticker := time.NewTicker(60 * time.Second)
+	// Optional pprof/expvar listener for profiling leaks on large installs
+	if config.PprofAddr != "" {
+		debugserver.Publish("stats", func() interface{} {
+			containerCount, hostCount := cache.GetStats()
+			return map[string]interface{}{
+				"streams":    streamManager.GetStreamCount(),
+				"containers": containerCount,
+				"hosts":      hostCount,
+			}
+		})
+		if addr, err := debugserver.Start(ctx, config.PprofAddr, config.PprofAllowRemote); err != nil {
+			log.Printf("Warning: profiling endpoints disabled: %v", err)
+		} else {
+			log.Printf("Profiling endpoints (pprof, expvar) listening on %s", addr)
+		}
+	}
+
+	// Start cleanup routine (remove stale stats every 60 seconds by default)
+	// 60s is generous enough to handle network hiccups while cleaning up
+	// stopped containers/disconnected hosts promptly
+	// The clamped value goes back into config so /admin/config and reload
+	// comparisons see the interval actually in use.
+	if config.CleanupInterval < minCleanupInterval {
+		log.Printf("Warning: STATS_CLEANUP_INTERVAL %v is below %v, using %v", config.CleanupInterval, minCleanupInterval, minCleanupInterval)
+		config.CleanupInterval = minCleanupInterval
+	}
+	cleanupInterval := config.CleanupInterval
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				cache.CleanStaleStats(60 * time.Second)
+				cache.CleanStaleStats(interval)
+			case interval = <-cleanupIntervals:
+				ticker.Reset(interval)
+			}
+		}
+	}(cleanupInterval)
+
+	// Re-read STATS_CONFIG_FILE on SIGHUP
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				if err := reloader.ReloadFile(); err != nil {
+					log.Printf("SIGHUP: reload failed: %v", err)
+				}
 			}
 		}
 	}()
@@ -313,28 +463,46 @@ func main() {
 	// Create HTTP server
 	mux := http.NewServeMux()
 
-	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	// Health check endpoint. Per-host details (which list host IDs) are only
+	// in the authenticated /health/full.
+	health := func(perHost bool) map[string]interface{} {
 		_, totalEvents := eventCache.GetStats()
-		jsonResponse(w, map[string]interface{}{
+		status := map[string]interface{}{
 			"status":            "ok",
 			"service":           "dockmon-stats",
+			"version":           version,
+			"api_level":         apiLevel,
 			"stats_streams":     streamManager.GetStreamCount(),
+			"discovery_hosts":   discovery.GetHostCount(),
 			"event_hosts":       eventManager.GetActiveHosts(),
 			"event_connections": eventBroadcaster.GetConnectionCount(),
 			"cached_events":     totalEvents,
-		})
+		}
+		if perHost {
+			status["event_cache"] = eventCache.HostStats()
+			status["docker_requests"] = streamManager.HostLimits().Stats()
+		}
+		return status
+	}
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, health(false))
 	})
 
+	// System-wide health (compose-service and agents too) for the backend's
+	// status page - PROTECTED, since it lists hosts
+	mux.HandleFunc("/health/full", authMiddleware(tokens, scopeStatsRead, func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, buildFullHealth(r.Context(), health(true), config.ComposeSocketPath, agentRegistry))
+	}))
+
 	// Get all host stats (main endpoint for Python backend) - PROTECTED
-	mux.HandleFunc("/api/stats/hosts", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/stats/hosts", authMiddleware(tokens, scopeStatsRead, func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		hostStats := cache.GetAllHostStats()
 		json.NewEncoder(w).Encode(hostStats)
 	}))
 
 	// Get stats for a specific host - PROTECTED
-	mux.HandleFunc("/api/stats/host/", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/stats/host/", authMiddleware(tokens, scopeStatsRead, func(w http.ResponseWriter, r *http.Request) {
 		hostID := r.URL.Path[len("/api/stats/host/"):]
 		if hostID == "" {
 			http.Error(w, "host_id required", http.StatusBadRequest)
@@ -351,21 +519,44 @@ func main() {
 	}))
 
 	// Get all container stats (for debugging) - PROTECTED
-	mux.HandleFunc("/api/stats/containers", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
+	// ?memory=raw|working_set overrides STATS_MEMORY_MODE for this call
+	mux.HandleFunc("/api/stats/containers", authMiddleware(tokens, scopeStatsRead, func(w http.ResponseWriter, r *http.Request) {
 		containerStats := cache.GetAllContainerStats()
+		if m := r.URL.Query().Get("memory"); m != "" {
+			mode, err := dockerpkg.ParseMemoryMode(m)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for k, cs := range containerStats {
+				containerStats[k] = cs.withMemoryMode(mode)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(containerStats)
 	}))
 
+	// Stats summed per dockmon.group label across hosts - PROTECTED
+	// ?group=name narrows the list to one group
+	mux.HandleFunc("/api/stats/groups", authMiddleware(tokens, scopeStatsRead, func(w http.ResponseWriter, r *http.Request) {
+		groups := aggregateGroups(cache.GetAllContainerStats(), time.Now())
+		if name := r.URL.Query().Get("group"); name != "" {
+			groups = slices.DeleteFunc(groups, func(g GroupStats) bool { return g.Group != name })
+		}
+		jsonResponse(w, groups)
+	}))
+
 	// Historical stats endpoints (PROTECTED). Reuses persistTiers computed
 	// above so the handler sees the same tier definitions the cascade/writer
 	// are feeding into the DB.
 	if persistDB != nil {
-		historyHandler := NewHistoryHandler(persistDB, persistTiers)
+		historyHandler := NewHistoryHandler(persistDB, persistTiers, containerMarkers)
 		mux.HandleFunc("/api/stats/history/container",
-			authMiddleware(token, historyHandler.ServeContainer))
+			authMiddleware(tokens, scopeStatsRead, historyHandler.ServeContainer))
 		mux.HandleFunc("/api/stats/history/host",
-			authMiddleware(token, historyHandler.ServeHost))
+			authMiddleware(tokens, scopeStatsRead, historyHandler.ServeHost))
+		mux.HandleFunc("/api/stats/export",
+			authMiddleware(tokens, scopeStatsRead, historyHandler.ServeExport))
 	}
 
 	// Hot-reload of stats settings pushed from Python. Registered
@@ -373,55 +564,54 @@ func main() {
 	// false to true doesn't get a 404 — the flag lives on settingsProvider
 	// and is consulted by the ingest path without a restart.
 	settingsHandler := &SettingsHandler{provider: settingsProvider}
-	mux.HandleFunc("/api/settings", authMiddleware(token, settingsHandler.ServeHTTP))
+	mux.HandleFunc("/api/settings", authMiddleware(tokens, scopeAdmin, settingsHandler.ServeHTTP))
 
-	// Agent ingest WebSocket endpoint. Remote agents push container stats
-	// directly into the same StatsCache that local and mTLS-remote stats
-	// feed into. Requires the persistence DB for agent token validation.
+	// Agent ingest endpoints. Remote agents push container stats directly
+	// into the same StatsCache that local and mTLS-remote stats feed into.
 	//
-	// NOT wrapped in authMiddleware: auth is per-WebSocket via the agent's
-	// permanent UUID token, validated inside HandleWebSocket itself.
+	// NOT wrapped in authMiddleware: the WebSocket authenticates with the
+	// agent's permanent UUID token, and the HTTP batch endpoint takes either
+	// that or the admin token, both checked inside the handler.
+	ingestHandler := &IngestHandler{
+		db:     persistDB,
+		tokens: tokens,
+		cache:  cache,
+		clocks: agentClocks,
+		events: eventManager,
+		agents: agentRegistry,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+	// Batches of normalized samples, e.g. from the Python backend for agent
+	// hosts it still collects stats for itself. Registered unconditionally;
+	// without the persistence DB only the admin token is accepted.
+	mux.HandleFunc("/api/stats/ingest", limitRequestBody(ingestHandler.ServeBatch))
+
+	// The WebSocket requires the persistence DB for agent token validation
 	if persistDB != nil {
-		ingestHandler := &IngestHandler{
-			db:    persistDB,
-			cache: cache,
-			upgrader: websocket.Upgrader{
-				CheckOrigin: func(r *http.Request) bool { return true },
-			},
-		}
 		mux.HandleFunc("/api/stats/ws/ingest", ingestHandler.HandleWebSocket)
 
 		// Agent token invalidation. Python posts here after deleting an
 		// agent row so stats-service evicts the cached token instead of
 		// honouring it for up to the 5-minute cache TTL.
 		invalidateHandler := &InvalidateHandler{db: persistDB}
-		mux.HandleFunc("/api/agents/invalidate", authMiddleware(token, invalidateHandler.ServeHTTP))
+		mux.HandleFunc("/api/agents/invalidate", authMiddleware(tokens, scopeAdmin, invalidateHandler.ServeHTTP))
 	}
 
 	// Start stream for a container (called by Python backend) - PROTECTED
-	mux.HandleFunc("/api/streams/start", authMiddleware(token, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/streams/start", authMiddleware(tokens, scopeAdmin, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			ContainerID   string `json:"container_id"`
-			ContainerName string `json:"container_name"`
-			HostID        string `json:"host_id"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.ContainerID == "" || req.HostID == "" {
-			http.Error(w, "container_id and host_id are required", http.StatusBadRequest)
+		var req streamRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
+		cache.SetContainerGroup(req.ContainerID, req.HostID, req.Group)
 		if err := streamManager.StartStream(ctx, req.ContainerID, req.ContainerName, req.HostID); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -432,25 +622,14 @@ func main() {
 	})))
 
 	// Stop stream for a container - PROTECTED
-	mux.HandleFunc("/api/streams/stop", authMiddleware(token, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/streams/stop", authMiddleware(tokens, scopeAdmin, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			ContainerID string `json:"container_id"`
-			HostID      string `json:"host_id"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.ContainerID == "" || req.HostID == "" {
-			http.Error(w, "container_id and host_id are required", http.StatusBadRequest)
+		var req streamRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -460,127 +639,124 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"status": "stopped"})
 	})))
 
-	// Add Docker host - PROTECTED
-	mux.HandleFunc("/api/hosts/add", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	// Add Docker hosts - PROTECTED. Idempotent: re-adding a host with
+	// unchanged connection settings keeps its client and active streams.
+	hostsHandler := &HostsHandler{streams: streamManager, cache: cache, discovery: discovery}
+	mux.HandleFunc("/api/hosts/add", authMiddleware(tokens, scopeAdmin, hostsHandler.ServeAdd))
+	mux.HandleFunc("/api/hosts/bulk_add", authMiddleware(tokens, scopeAdmin, limitRequestBody(hostsHandler.ServeBulkAdd)))
+	mux.HandleFunc("/api/hosts/test", authMiddleware(tokens, scopeAdmin, limitRequestBody(hostsHandler.ServeTest)))
+	mux.HandleFunc("/api/hosts/import_contexts", authMiddleware(tokens, scopeAdmin, limitRequestBody(hostsHandler.ServeImportContexts)))
+
+	// Remove Docker host - PROTECTED
+	mux.HandleFunc("/api/hosts/remove", authMiddleware(tokens, scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			HostID      string `json:"host_id"`
-			HostName    string `json:"host_name"`
-			HostAddress string `json:"host_address"`
-			TLSCACert   string `json:"tls_ca_cert,omitempty"`
-			TLSCert     string `json:"tls_cert,omitempty"`
-			TLSKey      string `json:"tls_key,omitempty"`
-			NumCPUs     int    `json:"num_cpus,omitempty"`
-			TotalMemory uint64 `json:"total_memory,omitempty"`
-			IsLocal     bool   `json:"is_local,omitempty"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req hostRemoveRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate required fields
-		if req.HostID == "" || req.HostName == "" || req.HostAddress == "" {
-			http.Error(w, "host_id, host_name, and host_address are required", http.StatusBadRequest)
-			return
+		discovery.Disable(req.HostID)
+		streamManager.RemoveDockerHost(req.HostID)
+		if cascade != nil {
+			cascade.RemoveHost(req.HostID)
 		}
 
-		if err := streamManager.AddDockerHost(req.HostID, req.HostName, req.HostAddress, req.TLSCACert, req.TLSCert, req.TLSKey); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
+	}))
+
+	// Debug endpoint - PROTECTED
+	mux.HandleFunc("/debug/stats", authMiddleware(tokens, scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		containerCount, hostCount := cache.GetStats()
+		jsonResponse(w, map[string]interface{}{
+			"streams":     streamManager.GetStreamCount(),
+			"containers":  containerCount,
+			"hosts":       hostCount,
+			"events":      eventBroadcaster.GetStats(),
+			"event_cache": eventCache.HostStats(),
+			"clocks": map[string]interface{}{
+				"docker": dockerClocks.Snapshot(),
+				"agent":  agentClocks.Snapshot(),
+			},
+			"maintenance": hostMaintenance.Snapshot(),
+		})
+	}))
+
+	// Runtime log level and effective configuration, for capturing debug
+	// logs of an intermittent issue without a restart - PROTECTED
+	logLevelControl := admin.NewLogLevel(serviceLogLevel, log.Printf)
+	mux.HandleFunc("/admin/loglevel", authMiddleware(tokens, scopeAdmin, limitRequestBody(logLevelControl.Handler())))
+	mux.HandleFunc("/admin/config", authMiddleware(tokens, scopeAdmin, admin.ConfigHandler(reloader.Config)))
+	mux.HandleFunc("/admin/reload", authMiddleware(tokens, scopeAdmin, limitRequestBody(reloader.ServeHTTP)))
+
+	// Adjust per-host event cache limits at runtime - PROTECTED
+	mux.HandleFunc("/api/events/cache/limits", authMiddleware(tokens, scopeAdmin, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Store number of CPUs for host CPU aggregation
-		if req.NumCPUs > 0 {
-			cache.SetHostNumCPUs(req.HostID, req.NumCPUs)
+		var req eventCacheLimitsRequest
+		if !decodeRequest(w, r, &req) {
+			return
 		}
 
-		// Store Docker-visible host memory for host memory aggregation
-		if req.TotalMemory > 0 {
-			cache.SetHostMemory(req.HostID, req.TotalMemory)
+		if req.DefaultSize != nil {
+			eventCache.SetDefaultSize(*req.DefaultSize)
 		}
-
-		// Mark host as local for /host/proc reading
-		if req.IsLocal {
-			cache.SetHostLocal(req.HostID, true)
+		for hostID, size := range req.HostSizes {
+			eventCache.SetHostSize(hostID, size)
 		}
 
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "added"})
-	}))
+		defaultSize, hostSizes := eventCache.Limits()
+		jsonResponse(w, map[string]interface{}{
+			"default_size": defaultSize,
+			"host_sizes":   hostSizes,
+			"hosts":        eventCache.HostStats(),
+		})
+	})))
 
-	// Remove Docker host - PROTECTED
-	mux.HandleFunc("/api/hosts/remove", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	// Start or end a host's maintenance window - PROTECTED
+	mux.HandleFunc("/api/hosts/maintenance", authMiddleware(tokens, scopeAdmin, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			HostID string `json:"host_id"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var req hostMaintenanceRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
-		// Validate required fields
-		if req.HostID == "" {
-			http.Error(w, "host_id is required", http.StatusBadRequest)
+		if !req.Enabled {
+			window, wasActive := hostMaintenance.Disable(req.HostID)
+			if wasActive {
+				log.Printf("Maintenance mode ended for host %s (%d events muted)", truncateID(req.HostID, 8), window.Muted)
+			}
+			jsonResponse(w, map[string]interface{}{"host_id": req.HostID, "enabled": false})
 			return
 		}
 
-		streamManager.RemoveDockerHost(req.HostID)
-		if cascade != nil {
-			cascade.RemoveHost(req.HostID)
-		}
-
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"status": "removed"})
-	}))
-
-	// Debug endpoint - PROTECTED
-	mux.HandleFunc("/debug/stats", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
-		containerCount, hostCount := cache.GetStats()
-		jsonResponse(w, map[string]interface{}{
-			"streams":    streamManager.GetStreamCount(),
-			"containers": containerCount,
-			"hosts":      hostCount,
-		})
-	}))
+		window := hostMaintenance.Enable(req.HostID, req.Reason, time.Duration(req.DurationSeconds)*time.Second)
+		log.Printf("Maintenance mode started for host %s until %s", truncateID(req.HostID, 8), window.Until.Format(time.RFC3339))
+		jsonResponse(w, map[string]interface{}{"host_id": req.HostID, "enabled": true, "window": window})
+	})))
 
 	// === Event Monitoring Endpoints ===
 
 	// Start monitoring events for a host - PROTECTED
-	mux.HandleFunc("/api/events/hosts/add", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/events/hosts/add", authMiddleware(tokens, scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			HostID      string `json:"host_id"`
-			HostName    string `json:"host_name"`
-			HostAddress string `json:"host_address"`
-			TLSCACert   string `json:"tls_ca_cert,omitempty"`
-			TLSCert     string `json:"tls_cert,omitempty"`
-			TLSKey      string `json:"tls_key,omitempty"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.HostID == "" || req.HostName == "" || req.HostAddress == "" {
-			http.Error(w, "host_id, host_name, and host_address are required", http.StatusBadRequest)
+		var req eventHostAddRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -594,24 +770,14 @@ func main() {
 	}))
 
 	// Stop monitoring events for a host - PROTECTED
-	mux.HandleFunc("/api/events/hosts/remove", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/events/hosts/remove", authMiddleware(tokens, scopeAdmin, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req struct {
-			HostID string `json:"host_id"`
-		}
-
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		// Validate required fields
-		if req.HostID == "" {
-			http.Error(w, "host_id is required", http.StatusBadRequest)
+		var req hostRemoveRequest
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -621,8 +787,24 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{"status": "stopped"})
 	}))
 
+	// Publish a DockMon activity event (updates, deployments) onto the event
+	// stream - PROTECTED
+	mux.HandleFunc("/api/events/publish", authMiddleware(tokens, scopeAdmin, limitRequestBody(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req activityPublishRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		jsonResponse(w, eventManager.PublishActivity(req.HostID, req.Event))
+	})))
+
 	// Get recent events - PROTECTED
-	mux.HandleFunc("/api/events/recent", authMiddleware(token, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/api/events/recent", authMiddleware(tokens, scopeEvents, func(w http.ResponseWriter, r *http.Request) {
 		hostID := r.URL.Query().Get("host_id")
 
 		var events interface{}
@@ -642,33 +824,40 @@ func main() {
 		// Validate token from query parameter or header using constant-time
 		// comparison to prevent timing attacks.
 		tokenParam := r.URL.Query().Get("token")
-		authHeader := r.Header.Get("Authorization")
-
-		validToken := subtle.ConstantTimeCompare([]byte(tokenParam), []byte(token)) == 1 ||
-			subtle.ConstantTimeCompare([]byte(authHeader), []byte("Bearer "+token)) == 1
+		if tokenParam == "" {
+			tokenParam = bearerToken(r)
+		}
 
+		scope, validToken := tokens.scopeOf(tokenParam)
 		if !validToken {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			log.Printf("Unauthorized WebSocket connection attempt from %s", r.RemoteAddr)
 			return
 		}
+		if !scope.allows(scopeEvents) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			log.Printf("Forbidden WebSocket connection attempt from %s (token scope %s)", r.RemoteAddr, scope)
+			return
+		}
+
+		// Optional replay of cached events (?since=<cursor|RFC3339>) before
+		// switching to live mode. Validated before the upgrade so a bad value
+		// gets a plain 400.
+		var replay ReplayFunc
+		if sinceParam := r.URL.Query().Get("since"); sinceParam != "" {
+			afterSeq, since, err := parseReplaySince(sinceParam)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			replay = func() ([]DockerEvent, uint64) {
+				return eventCache.GetEventsSince(afterSeq, since)
+			}
+		}
 
 		// Upgrade to WebSocket
 		upgrader := websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				origin := r.Header.Get("Origin")
-				if origin == "" {
-					return true // Allow same-origin requests
-				}
-				// Check against configured allowed origins
-				allowedOrigins := strings.Split(config.AllowedOrigins, ",")
-				for _, allowed := range allowedOrigins {
-					if strings.TrimSpace(allowed) == origin {
-						return true
-					}
-				}
-				return false
-			},
+			CheckOrigin: reloader.CheckOrigin,
 		}
 
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -678,29 +867,17 @@ func main() {
 		}
 
 		// Register connection
-		if err := eventBroadcaster.AddConnection(conn); err != nil {
+		if err := eventBroadcaster.AddConnectionWithReplay(conn, replay); err != nil {
 			log.Printf("Failed to register connection: %v", err)
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Connection limit reached"))
 			conn.Close()
 			return
 		}
 
-		// Read loop detects client disconnect. Server shutdown is handled
-		// separately by EventBroadcaster.CloseAll, which closes every conn
-		// and forces ReadMessage to error out here.
-		go func() {
-			defer func() {
-				eventBroadcaster.RemoveConnection(conn)
-				conn.Close()
-			}()
-
-			for {
-				_, _, err := conn.ReadMessage()
-				if err != nil {
-					break
-				}
-			}
-		}()
+		// Read loop detects client disconnect and missed heartbeats. Server
+		// shutdown is handled separately by EventBroadcaster.CloseAll, which
+		// closes every conn and forces ReadMessage to error out.
+		go eventBroadcaster.ReadLoop(conn)
 	})
 
 	// Create server with configured port. Bind to loopback only: nginx and the
@@ -737,6 +914,9 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	// Stop auto-discovery before the streams it manages
+	discovery.StopAll()
+
 	// Stop all stats streams
 	streamManager.StopAllStreams()
 
@@ -756,12 +936,9 @@ func main() {
 	// strict cancel → Wait → Close order.
 	cancel()
 
-	// Clean up token file
-	if err := os.Remove(config.TokenFilePath); err != nil {
-		log.Printf("Warning: Failed to remove token file: %v", err)
-	} else {
-		log.Println("Removed token file")
-	}
+	// Clean up token files
+	tokens.remove()
+	log.Println("Removed token files")
 
 	log.Println("Stats service stopped")
 }
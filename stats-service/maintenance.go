@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/maintenance"
+)
+
+// maintenanceExpiryInterval is how often windows that ran out are ended
+const maintenanceExpiryInterval = 30 * time.Second
+
+// HostMaintenance owns the hosts' maintenance windows. Windows are persisted,
+// since the host reboot a window covers often restarts the stats service
+// too, and when a window ends, by request or expiry, the containers whose
+// events were muted are resynced.
+type HostMaintenance struct {
+	tracker *maintenance.Tracker
+	events  *EventManager
+	path    string
+
+	saveMu sync.Mutex // Serializes writes to path
+}
+
+// NewHostMaintenance creates the maintenance state for events, restoring
+// windows persisted at path (empty = not persisted)
+func NewHostMaintenance(events *EventManager, path string) *HostMaintenance {
+	m := &HostMaintenance{
+		tracker: maintenance.NewTracker(),
+		events:  events,
+		path:    path,
+	}
+	events.SetMaintenance(m.tracker)
+	m.load()
+	return m
+}
+
+// Enable starts or extends a host's window
+func (m *HostMaintenance) Enable(hostID, reason string, duration time.Duration) maintenance.Window {
+	w := m.tracker.Enable(hostID, reason, duration)
+	m.save()
+	return w
+}
+
+// Disable ends a host's window and resyncs its muted containers
+func (m *HostMaintenance) Disable(hostID string) (maintenance.Window, bool) {
+	w, ok := m.tracker.Disable(hostID)
+	m.events.ResyncMuted(hostID)
+	m.save()
+	return w, ok
+}
+
+// Snapshot returns the active windows by host ID
+func (m *HostMaintenance) Snapshot() map[string]maintenance.Window {
+	return m.tracker.Snapshot()
+}
+
+// Run ends expired windows until ctx is done
+func (m *HostMaintenance) Run(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.expire()
+		}
+	}
+}
+
+func (m *HostMaintenance) expire() {
+	expired := m.tracker.Expire()
+	for _, hostID := range expired {
+		log.Printf("Maintenance mode expired for host %s", truncateID(hostID, 8))
+		m.events.ResyncMuted(hostID)
+	}
+	if len(expired) > 0 {
+		m.save()
+	}
+}
+
+// load restores windows persisted before a restart
+func (m *HostMaintenance) load() {
+	if m.path == "" {
+		return
+	}
+	data, err := os.ReadFile(m.path) // #nosec G304 -- path comes from the service's configuration
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Warning: failed to read maintenance file: %v", err)
+		}
+		return
+	}
+	var windows map[string]maintenance.Window
+	if err := json.Unmarshal(data, &windows); err != nil {
+		log.Printf("Warning: maintenance file is corrupt; ignoring: %v", err)
+		return
+	}
+	for hostID, w := range windows {
+		if m.tracker.Restore(hostID, w) {
+			log.Printf("Host %s is in maintenance mode until %s", truncateID(hostID, 8), w.Until.Format(time.RFC3339))
+		}
+	}
+}
+
+// save persists the active windows. A failure only costs the windows on the
+// next restart, so it is logged rather than returned.
+func (m *HostMaintenance) save() {
+	if m.path == "" {
+		return
+	}
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	if err := writeMaintenanceFile(m.path, m.tracker.Snapshot()); err != nil {
+		log.Printf("Warning: failed to persist maintenance windows: %v", err)
+	}
+}
+
+func writeMaintenanceFile(path string, windows map[string]maintenance.Window) error {
+	if len(windows) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(windows)
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance windows: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write maintenance file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace maintenance file: %w", err)
+	}
+	return nil
+}
+
+// mutedKey groups muted events so only the latest lifecycle change and the
+// latest health status of each container are kept
+func mutedKey(event DockerEvent) string {
+	if strings.HasPrefix(event.Action, "health_status") {
+		return event.ContainerID + "|health"
+	}
+	return event.ContainerID + "|state"
+}
+
+// holdMuted keeps a muted event so its container can be resynced when the
+// host's window ends
+func (em *EventManager) holdMuted(event DockerEvent) {
+	em.mutedMu.Lock()
+	defer em.mutedMu.Unlock()
+	if em.muted == nil {
+		em.muted = make(map[string]map[string]DockerEvent)
+	}
+	if em.muted[event.HostID] == nil {
+		em.muted[event.HostID] = make(map[string]DockerEvent)
+	}
+	em.muted[event.HostID][mutedKey(event)] = event
+}
+
+// takeMuted removes and returns the host's held events
+func (em *EventManager) takeMuted(hostID string) map[string]DockerEvent {
+	em.mutedMu.Lock()
+	defer em.mutedMu.Unlock()
+	held := em.muted[hostID]
+	delete(em.muted, hostID)
+	return held
+}
+
+// ResyncMuted publishes the last muted event of each container on the host,
+// tagged as maintenance, so consumers end the window with every container's
+// final state instead of the one it had when the window started
+func (em *EventManager) ResyncMuted(hostID string) {
+	held := em.takeMuted(hostID)
+	if len(held) == 0 {
+		return
+	}
+	events := make([]DockerEvent, 0, len(held))
+	for _, event := range held {
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+
+	log.Printf("Resyncing %d container state(s) muted during maintenance on host %s", len(events), truncateID(hostID, 8))
+	for _, event := range events {
+		event.Maintenance = true
+		event = em.eventCache.AddEvent(hostID, event)
+		em.broadcaster.Broadcast(event)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/clock"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestHostMaintenance_PersistsWindows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance.json")
+	em := NewEventManager(NewEventBroadcaster(), NewEventCache(10), clock.NewTracker(), dockerpkg.NewPool(0, 0))
+	m := NewHostMaintenance(em, path)
+	m.Enable("h1", "kernel upgrade", time.Hour)
+
+	// A restarted service picks the window back up
+	restarted := NewHostMaintenance(NewEventManager(NewEventBroadcaster(), NewEventCache(10), clock.NewTracker(), dockerpkg.NewPool(0, 0)), path)
+	if w, ok := restarted.Snapshot()["h1"]; !ok || w.Reason != "kernel upgrade" {
+		t.Fatalf("restored windows = %+v, want h1's", restarted.Snapshot())
+	}
+
+	restarted.Disable("h1")
+	if len(NewHostMaintenance(NewEventManager(NewEventBroadcaster(), NewEventCache(10), clock.NewTracker(), dockerpkg.NewPool(0, 0)), path).Snapshot()) != 0 {
+		t.Error("a disabled window was restored")
+	}
+}
+
+func TestHostMaintenance_ResyncsMutedContainers(t *testing.T) {
+	cache := NewEventCache(10)
+	em := NewEventManager(NewEventBroadcaster(), cache, clock.NewTracker(), dockerpkg.NewPool(0, 0))
+	m := NewHostMaintenance(em, "")
+	m.Enable("h1", "reboot", time.Hour)
+
+	containerEvent := func(id string, action events.Action) {
+		em.processEvent("h1", events.Message{
+			Type:   events.ContainerEventType,
+			Action: action,
+			Actor:  events.Actor{ID: id, Attributes: map[string]string{"name": "web"}},
+			Time:   time.Now().Unix(),
+		})
+	}
+	containerEvent("0123456789abcdef", events.ActionStop)
+	containerEvent("0123456789abcdef", events.ActionDie)
+	containerEvent("0123456789abcdef", events.ActionStart)
+	containerEvent("fedcba9876543210", events.ActionDie)
+	if got := cache.GetRecentEvents("h1", 10); len(got) != 0 {
+		t.Fatalf("cached %d events during maintenance, want none", len(got))
+	}
+
+	m.Disable("h1")
+	got := cache.GetRecentEvents("h1", 10)
+	final := make(map[string]string)
+	for _, e := range got {
+		if !e.Maintenance {
+			t.Errorf("resynced event %+v not tagged as maintenance", e)
+		}
+		final[e.ContainerID] = e.Action
+	}
+	if len(got) != 2 || final["0123456789ab"] != "start" || final["fedcba987654"] != "die" {
+		t.Errorf("resynced events = %+v, want each container's last state", got)
+	}
+}
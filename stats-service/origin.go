@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// OriginPolicy decides which browser origins may open the events WebSocket.
+// ALLOWED_ORIGINS entries are one of:
+//
+//	https://dockmon.example.com:8443  exact origin
+//	https://*.example.com             wildcard host (one or more labels); the
+//	                                  port must match, ":*" allows any port
+//	192.168.1.0/24                    CIDR: any scheme and port on an IP host
+//
+// With allowPrivate, any origin whose host is a loopback, link-local or
+// private IP address is allowed too.
+type OriginPolicy struct {
+	exact        map[string]bool
+	wildcards    []originPattern
+	prefixes     []netip.Prefix
+	allowPrivate bool
+}
+
+// originPattern is a parsed wildcard entry. suffix includes the leading dot.
+type originPattern struct {
+	scheme string
+	suffix string
+	port   string // "" = default port only, "*" = any port
+}
+
+// ParseOriginPolicy parses a comma-separated ALLOWED_ORIGINS value. Invalid
+// entries are skipped and reported in the error; the returned policy is
+// always usable.
+func ParseOriginPolicy(spec string, allowPrivate bool) (*OriginPolicy, error) {
+	p := &OriginPolicy{exact: make(map[string]bool), allowPrivate: allowPrivate}
+	var errs []error
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if err := p.add(entry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return p, errors.Join(errs...)
+}
+
+// add parses one ALLOWED_ORIGINS entry into the policy
+func (p *OriginPolicy) add(entry string) error {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		p.prefixes = append(p.prefixes, prefix.Masked())
+		return nil
+	}
+
+	scheme, rest, ok := strings.Cut(entry, "://")
+	if !ok || scheme == "" || rest == "" {
+		return fmt.Errorf("invalid allowed origin %q: expected scheme://host[:port] or a CIDR", entry)
+	}
+	scheme = strings.ToLower(scheme)
+	if !strings.Contains(rest, "*") {
+		p.exact[scheme+"://"+strings.ToLower(rest)] = true
+		return nil
+	}
+
+	host, port := rest, ""
+	if i := strings.LastIndex(rest, ":"); i >= 0 {
+		host, port = rest[:i], rest[i+1:]
+		if port == "" {
+			return fmt.Errorf("invalid allowed origin %q: empty port", entry)
+		}
+	}
+	if !strings.HasPrefix(host, "*.") || strings.Contains(host[2:], "*") || len(host) < 3 {
+		return fmt.Errorf("invalid allowed origin %q: a wildcard must be the leading label, as in https://*.example.com", entry)
+	}
+	if port != "*" && strings.Contains(port, "*") {
+		return fmt.Errorf("invalid allowed origin %q: invalid port", entry)
+	}
+	p.wildcards = append(p.wildcards, originPattern{
+		scheme: scheme,
+		suffix: strings.ToLower(host[1:]),
+		port:   port,
+	})
+	return nil
+}
+
+// Allowed reports whether the origin may connect. Empty origins (non-browser
+// clients) are allowed.
+func (p *OriginPolicy) Allowed(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.exact[origin] {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	host, port := u.Hostname(), u.Port()
+
+	for _, w := range p.wildcards {
+		if w.scheme == u.Scheme && strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) &&
+			(w.port == "*" || w.port == port) {
+			return true
+		}
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return p.allowPrivate && (addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast())
+}
+
+// CheckOrigin adapts the policy to websocket.Upgrader.CheckOrigin
+func (p *OriginPolicy) CheckOrigin(r *http.Request) bool {
+	return p.Allowed(r.Header.Get("Origin"))
+}
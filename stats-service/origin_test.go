@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestOriginPolicy(t *testing.T) {
+	p, err := ParseOriginPolicy("http://localhost:8080, https://*.example.com, https://*.lab.test:*, 10.1.0.0/16", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:8080", true},
+		{"HTTP://LocalHost:8080", true},
+		{"http://localhost:3000", false},
+		{"https://dockmon.example.com", true},
+		{"https://a.b.example.com", true},
+		{"https://example.com", false},
+		{"https://dockmon.example.com:8443", false},
+		{"http://dockmon.example.com", false},
+		{"https://evilexample.com", false},
+		{"https://dockmon.example.com.evil.io", false},
+		{"https://nas.lab.test:8443", true},
+		{"http://10.1.4.20:8080", true},
+		{"https://10.1.4.20", true},
+		{"http://10.2.0.1", false},
+		{"http://192.168.1.10", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestOriginPolicyPrivateNetworks(t *testing.T) {
+	p, err := ParseOriginPolicy("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for origin, want := range map[string]bool{
+		"http://192.168.1.10:8080": true,
+		"https://172.16.0.5":       true,
+		"http://127.0.0.1":         true,
+		"http://[fd00::1]:8080":    true,
+		"http://[::ffff:10.0.0.1]": true,
+		"http://169.254.1.1":       true,
+		"http://8.8.8.8":           false,
+		"https://dockmon.lan":      false,
+	} {
+		if got := p.Allowed(origin); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestParseOriginPolicyErrors(t *testing.T) {
+	for _, spec := range []string{"localhost:8080", "https://dockmon.*.com", "https://*", "https://*.example.com:", "https://*.example.com:8*", "10.0.0.0/33"} {
+		if _, err := ParseOriginPolicy(spec, false); err == nil {
+			t.Errorf("ParseOriginPolicy(%q) succeeded, want error", spec)
+		}
+	}
+
+	// Valid entries next to an invalid one still apply
+	p, err := ParseOriginPolicy("localhost:8080,http://localhost:8080", false)
+	if err == nil || !p.Allowed("http://localhost:8080") {
+		t.Errorf("err = %v, want the invalid entry reported and the valid one kept", err)
+	}
+}
@@ -22,6 +22,18 @@ type HistoryResponse struct {
 	MemLimitBytes   []*int64   `json:"memory_limit_bytes,omitempty"`
 	NetBps          []*float64 `json:"net_bps"`
 	ContainerCount  []*int     `json:"container_count,omitempty"`
+	// Events are lifecycle events of the container within the window, for
+	// marking them on graphs. Only set for container history.
+	Events []HistoryEvent `json:"events,omitempty"`
+}
+
+// HistoryEvent is a container lifecycle event (start, die, restart, oom,
+// update). Bucket is the timestamp of the bucket it falls into.
+type HistoryEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Bucket    int64  `json:"bucket"`
+	Action    string `json:"action"`
+	ExitCode  *int   `json:"exit_code,omitempty"`
 }
 
 // SelectTier picks the smallest tier whose Window is at least the given duration.
@@ -90,3 +102,75 @@ func FillGaps(rows []HistoryRow, tier Tier, from, to time.Time) HistoryResponse
 	}
 	return res
 }
+
+// Downsample merges ascending rows into step-sized buckets, averaging the
+// measurements present in each. Memory limit and container count are
+// snapshots, so the last value wins, as in the cascade. A step of zero or
+// less returns rows unchanged.
+func Downsample(rows []HistoryRow, step time.Duration) []HistoryRow {
+	if step <= 0 || len(rows) == 0 {
+		return rows
+	}
+	var (
+		out                []HistoryRow
+		cur                HistoryRow
+		cpu, mem, used, nb mean
+	)
+	flush := func() {
+		cur.CPU = cpu.value()
+		cur.MemPercent = mem.value()
+		if v := used.value(); v != nil {
+			u := int64(math.Round(*v))
+			cur.MemUsed = &u
+		}
+		cur.NetBps = nb.value()
+		out = append(out, cur)
+	}
+	for i, r := range rows {
+		bucket := time.Unix(r.Timestamp, 0).Truncate(step).Unix()
+		if i == 0 || bucket != cur.Timestamp {
+			if i > 0 {
+				flush()
+			}
+			cur = HistoryRow{Timestamp: bucket}
+			cpu, mem, used, nb = mean{}, mean{}, mean{}, mean{}
+		}
+		cpu.add(r.CPU)
+		mem.add(r.MemPercent)
+		if r.MemUsed != nil {
+			v := float64(*r.MemUsed)
+			used.add(&v)
+		}
+		nb.add(r.NetBps)
+		if r.MemLimit != nil {
+			cur.MemLimit = r.MemLimit
+		}
+		if r.ContainerCount != nil {
+			cur.ContainerCount = r.ContainerCount
+		}
+	}
+	flush()
+	return out
+}
+
+// mean averages the non-nil values added to it
+type mean struct {
+	sum float64
+	n   int
+}
+
+func (m *mean) add(v *float64) {
+	if v != nil {
+		m.sum += *v
+		m.n++
+	}
+}
+
+// value returns the average, or nil if nothing was added
+func (m *mean) value() *float64 {
+	if m.n == 0 {
+		return nil
+	}
+	v := m.sum / float64(m.n)
+	return &v
+}
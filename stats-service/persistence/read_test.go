@@ -94,4 +94,44 @@ func TestFillGaps_SubSecondInterval(t *testing.T) {
 	}
 }
 
+func TestDownsample_AveragesIntoSteps(t *testing.T) {
+	limit1, limit2 := int64(1000), int64(2000)
+	used1, used2 := int64(100), int64(201)
+	rows := []HistoryRow{
+		{Timestamp: 100, CPU: ptrF64(10), MemUsed: &used1, MemLimit: &limit1},
+		{Timestamp: 105, CPU: ptrF64(20), MemUsed: &used2, MemLimit: &limit2},
+		{Timestamp: 109, CPU: nil, NetBps: ptrF64(5)},
+		{Timestamp: 112, CPU: ptrF64(7)},
+	}
+	got := Downsample(rows, 10*time.Second)
+	if len(got) != 2 {
+		t.Fatalf("len=%d, want 2", len(got))
+	}
+	if got[0].Timestamp != 100 || got[1].Timestamp != 110 {
+		t.Errorf("timestamps=%d,%d, want 100,110", got[0].Timestamp, got[1].Timestamp)
+	}
+	if got[0].CPU == nil || *got[0].CPU != 15 {
+		t.Errorf("cpu=%v, want 15 (nil samples skipped)", got[0].CPU)
+	}
+	if got[0].MemUsed == nil || *got[0].MemUsed != 151 {
+		t.Errorf("mem used=%v, want 151", got[0].MemUsed)
+	}
+	if got[0].MemLimit == nil || *got[0].MemLimit != 2000 {
+		t.Errorf("mem limit=%v, want last value 2000", got[0].MemLimit)
+	}
+	if got[0].NetBps == nil || *got[0].NetBps != 5 {
+		t.Errorf("net=%v, want 5", got[0].NetBps)
+	}
+	if got[1].MemPercent != nil || got[1].MemLimit != nil {
+		t.Errorf("bucket without samples should stay nil, got %+v", got[1])
+	}
+}
+
+func TestDownsample_NoStep(t *testing.T) {
+	rows := []HistoryRow{{Timestamp: 1}, {Timestamp: 2}}
+	if got := Downsample(rows, 0); len(got) != 2 {
+		t.Errorf("len=%d, want rows unchanged", len(got))
+	}
+}
+
 func ptrF64(v float64) *float64 { return &v }
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reload limits
+const (
+	defaultTokenOverlap = time.Minute
+	maxTokenOverlap     = time.Hour
+
+	minAggregationInterval = 100 * time.Millisecond
+	maxAggregationInterval = 5 * time.Minute
+	minCleanupInterval     = 10 * time.Second
+	maxCleanupInterval     = time.Hour
+)
+
+// intervalUpdates delivers a running loop's new ticker interval. Only the
+// latest pending change is kept.
+type intervalUpdates chan time.Duration
+
+func newIntervalUpdates() intervalUpdates {
+	return make(intervalUpdates, 1)
+}
+
+func (u intervalUpdates) set(d time.Duration) {
+	for {
+		select {
+		case u <- d:
+			return
+		default:
+			// Replace the change the loop hasn't picked up yet
+			select {
+			case <-u:
+			default:
+			}
+		}
+	}
+}
+
+// reloadRequest is the body of /admin/reload, and what a reload of the
+// config file amounts to. Omitted fields are left as they are.
+type reloadRequest struct {
+	AllowedOrigins      *string `json:"allowed_origins,omitempty"`
+	AllowPrivateOrigins *bool   `json:"allow_private_origins,omitempty"`
+	AggregationInterval string  `json:"aggregation_interval,omitempty"` // Go duration, e.g. "2s"
+	CleanupInterval     string  `json:"cleanup_interval,omitempty"`
+	RotateTokens        bool    `json:"rotate_tokens,omitempty"`
+	// How long the replaced tokens stay valid (default 60)
+	TokenOverlapSeconds *int `json:"token_overlap_seconds,omitempty"`
+
+	// Set by validate
+	aggregation time.Duration
+	cleanup     time.Duration
+}
+
+func (req *reloadRequest) validate() validationErrors {
+	var errs validationErrors
+	if req.AllowedOrigins != nil {
+		errs.maxLength("allowed_origins", *req.AllowedOrigins, maxPEMLength)
+		// Unlike at startup, bad entries reject the reload rather than being
+		// skipped, so the caller finds out
+		if _, err := ParseOriginPolicy(*req.AllowedOrigins, false); err != nil {
+			errs.add("allowed_origins", "%v", err)
+		}
+	}
+	req.aggregation = errs.interval("aggregation_interval", req.AggregationInterval, minAggregationInterval, maxAggregationInterval)
+	req.cleanup = errs.interval("cleanup_interval", req.CleanupInterval, minCleanupInterval, maxCleanupInterval)
+	if req.TokenOverlapSeconds != nil {
+		if max := int(maxTokenOverlap / time.Second); *req.TokenOverlapSeconds < 0 || *req.TokenOverlapSeconds > max {
+			errs.add("token_overlap_seconds", "must be between 0 and %d", max)
+		} else if !req.RotateTokens {
+			errs.add("token_overlap_seconds", "only applies with rotate_tokens")
+		}
+	}
+	return errs
+}
+
+// interval parses an optional duration within bounds; 0 when value is empty
+// or invalid
+func (v *validationErrors) interval(field, value string, lo, hi time.Duration) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		v.add(field, "must be a duration such as \"2s\" or \"1m\"")
+		return 0
+	}
+	if d < lo || d > hi {
+		v.add(field, "must be between %v and %v", lo, hi)
+		return 0
+	}
+	return d
+}
+
+// Reloader applies configuration changes while the service runs, so
+// changing the allowed origins or intervals, or rotating the tokens, doesn't
+// drop every stats stream and WebSocket consumer with a restart. Changes
+// come from /admin/reload, or from re-reading the config file on SIGHUP.
+type Reloader struct {
+	mu         sync.Mutex // Serializes reloads and guards config
+	origins    atomic.Pointer[OriginPolicy]
+	aggregator *Aggregator
+	cleanup    intervalUpdates
+	tokens     *tokenSet
+	configFile string // STATS_CONFIG_FILE; empty disables file reloads
+}
+
+// NewReloader starts from the policy and intervals in effect at startup
+func NewReloader(origins *OriginPolicy, aggregator *Aggregator, cleanup intervalUpdates, tokens *tokenSet, configFile string) *Reloader {
+	rl := &Reloader{
+		aggregator: aggregator,
+		cleanup:    cleanup,
+		tokens:     tokens,
+		configFile: configFile,
+	}
+	rl.origins.Store(origins)
+	return rl
+}
+
+// CheckOrigin checks a WebSocket origin against the current policy
+func (rl *Reloader) CheckOrigin(r *http.Request) bool {
+	return rl.origins.Load().CheckOrigin(r)
+}
+
+// Config returns the service configuration with reloaded values applied
+func (rl *Reloader) Config() interface{} {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	c := config
+	return c
+}
+
+// apply makes a validated request's changes, returning what changed
+func (rl *Reloader) apply(req *reloadRequest) ([]string, error) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	var changed []string
+	if req.AllowedOrigins != nil || req.AllowPrivateOrigins != nil {
+		allowed, allowPrivate := config.AllowedOrigins, config.AllowPrivateOrigins
+		if req.AllowedOrigins != nil {
+			allowed = *req.AllowedOrigins
+		}
+		if req.AllowPrivateOrigins != nil {
+			allowPrivate = *req.AllowPrivateOrigins
+		}
+		policy, err := ParseOriginPolicy(allowed, allowPrivate)
+		if err != nil {
+			return nil, err
+		}
+		rl.origins.Store(policy)
+		config.AllowedOrigins, config.AllowPrivateOrigins = allowed, allowPrivate
+		changed = append(changed, "allowed_origins")
+	}
+	if req.aggregation > 0 && req.aggregation != config.AggregationInterval {
+		rl.aggregator.SetInterval(req.aggregation)
+		config.AggregationInterval = req.aggregation
+		changed = append(changed, "aggregation_interval")
+	}
+	if req.cleanup > 0 && req.cleanup != config.CleanupInterval {
+		rl.cleanup.set(req.cleanup)
+		config.CleanupInterval = req.cleanup
+		changed = append(changed, "cleanup_interval")
+	}
+	if req.RotateTokens {
+		overlap := defaultTokenOverlap
+		if req.TokenOverlapSeconds != nil {
+			overlap = time.Duration(*req.TokenOverlapSeconds) * time.Second
+		}
+		if err := rl.tokens.rotate(overlap); err != nil {
+			return changed, err
+		}
+		changed = append(changed, "tokens")
+	}
+	if len(changed) > 0 {
+		log.Printf("Reloaded configuration: %s", strings.Join(changed, ", "))
+	}
+	return changed, nil
+}
+
+// ServeHTTP handles POST /admin/reload
+func (rl *Reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req reloadRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	changed, err := rl.apply(&req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	jsonResponse(w, map[string]interface{}{"changed": changed})
+}
+
+// ReloadFile re-reads the config file and applies it. Settings missing from
+// the file keep their current values.
+func (rl *Reloader) ReloadFile() error {
+	if rl.configFile == "" {
+		return errors.New("no config file to reload (STATS_CONFIG_FILE is not set)")
+	}
+	req, err := parseConfigFile(rl.configFile)
+	if err != nil {
+		return err
+	}
+	if errs := req.validate(); len(errs) > 0 {
+		return fmt.Errorf("%s: %w", rl.configFile, errs)
+	}
+	_, err = rl.apply(req)
+	return err
+}
+
+// parseConfigFile reads the reloadable settings from a file of KEY=VALUE
+// lines, using the same names as the environment variables. Blank lines and
+// lines starting with # are skipped, and values may be quoted.
+func parseConfigFile(path string) (*reloadRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	req := &reloadRequest{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+
+		switch key {
+		case "ALLOWED_ORIGINS":
+			req.AllowedOrigins = &value
+		case "ALLOW_PRIVATE_NETWORK_ORIGINS":
+			allow, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s must be true or false", path, lineNo, key)
+			}
+			req.AllowPrivateOrigins = &allow
+		case "AGGREGATION_INTERVAL":
+			req.AggregationInterval = value
+		case "STATS_CLEANUP_INTERVAL":
+			req.CleanupInterval = value
+		default:
+			log.Printf("Warning: %s:%d: %s can't be changed without a restart, ignoring it", path, lineNo, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return req, nil
+}
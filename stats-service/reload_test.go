@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/admin"
+)
+
+// withConfig restores the global config after a test that reloads it
+func withConfig(t *testing.T) {
+	t.Helper()
+	saved := config
+	t.Cleanup(func() { config = saved })
+}
+
+func makeReloaderFixture(t *testing.T, configFile string) (*Reloader, intervalUpdates) {
+	t.Helper()
+	withConfig(t)
+	config.AllowedOrigins = "https://dockmon.example.com"
+	config.AllowPrivateOrigins = false
+	config.AggregationInterval = time.Second
+	config.CleanupInterval = time.Minute
+	policy, err := ParseOriginPolicy(config.AllowedOrigins, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := newIntervalUpdates()
+	agg := NewAggregator(NewStatsCache(), nil, time.Second)
+	return NewReloader(policy, agg, cleanup, makeTokenSetFixture(t), configFile), cleanup
+}
+
+func originRequest(origin string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/ws/events", nil)
+	r.Header.Set("Origin", origin)
+	return r
+}
+
+func TestReloader_Apply(t *testing.T) {
+	rl, cleanup := makeReloaderFixture(t, "")
+	if rl.CheckOrigin(originRequest("https://new.example.com")) {
+		t.Fatal("origin allowed before the reload")
+	}
+
+	origins := "https://new.example.com"
+	req := &reloadRequest{AllowedOrigins: &origins, AggregationInterval: "2s", CleanupInterval: "30s"}
+	if errs := req.validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	changed, err := rl.apply(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(changed, ",") != "allowed_origins,aggregation_interval,cleanup_interval" {
+		t.Errorf("changed = %v", changed)
+	}
+	if !rl.CheckOrigin(originRequest("https://new.example.com")) || rl.CheckOrigin(originRequest("https://dockmon.example.com")) {
+		t.Error("origin policy not replaced")
+	}
+	if d := <-rl.aggregator.intervals; d != 2*time.Second {
+		t.Errorf("aggregator interval = %v, want 2s", d)
+	}
+	if d := <-cleanup; d != 30*time.Second {
+		t.Errorf("cleanup interval = %v, want 30s", d)
+	}
+	if reported := admin.Redact(rl.Config()); reported["AllowedOrigins"] != origins || reported["AggregationInterval"] != "2s" {
+		t.Errorf("reported config not updated: %v", reported)
+	}
+
+	// Unchanged values are not reapplied
+	if changed, _ := rl.apply(&reloadRequest{aggregation: 2 * time.Second}); len(changed) != 0 {
+		t.Errorf("changed = %v, want nothing", changed)
+	}
+}
+
+func TestReloadRequest_Validate(t *testing.T) {
+	bad := "https://*bad"
+	overlap := 30
+	tooLong := 7200
+	cases := map[string]struct {
+		req   reloadRequest
+		field string
+	}{
+		"origin":            {reloadRequest{AllowedOrigins: &bad}, "allowed_origins"},
+		"aggregation":       {reloadRequest{AggregationInterval: "soon"}, "aggregation_interval"},
+		"aggregation range": {reloadRequest{AggregationInterval: "1ms"}, "aggregation_interval"},
+		"cleanup range":     {reloadRequest{CleanupInterval: "1s"}, "cleanup_interval"},
+		"overlap alone":     {reloadRequest{TokenOverlapSeconds: &overlap}, "token_overlap_seconds"},
+		"overlap range":     {reloadRequest{RotateTokens: true, TokenOverlapSeconds: &tooLong}, "token_overlap_seconds"},
+	}
+	for name, tc := range cases {
+		errs := tc.req.validate()
+		if len(errs) != 1 || errs[0].Field != tc.field {
+			t.Errorf("%s: errors = %v, want one for %s", name, errs, tc.field)
+		}
+	}
+}
+
+func TestReloader_ServeHTTPRotatesTokens(t *testing.T) {
+	rl, _ := makeReloaderFixture(t, "")
+	old := tokenFor(rl.tokens, scopeAdmin)
+
+	w := httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload",
+		strings.NewReader(`{"rotate_tokens":true,"token_overlap_seconds":5}`)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"tokens"`) {
+		t.Fatalf("status=%d body=%s", w.Code, w.Body.String())
+	}
+	if tokenFor(rl.tokens, scopeAdmin) == old {
+		t.Error("tokens not rotated")
+	}
+	if _, ok := rl.tokens.scopeOf(old); !ok {
+		t.Error("old token rejected during the overlap")
+	}
+
+	w = httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/reload", strings.NewReader(`{"cleanup_interval":"forever"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid reload: status=%d, want 400", w.Code)
+	}
+}
+
+func TestReloader_ReloadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.env")
+	rl, _ := makeReloaderFixture(t, path)
+
+	if err := rl.ReloadFile(); err == nil {
+		t.Error("missing file reloaded")
+	}
+
+	content := `# Reloaded on SIGHUP
+ALLOWED_ORIGINS="https://a.example.com,https://*.lan.example.com"
+ALLOW_PRIVATE_NETWORK_ORIGINS=true
+AGGREGATION_INTERVAL='3s'
+STATS_SERVICE_PORT=9999
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.ReloadFile(); err != nil {
+		t.Fatal(err)
+	}
+	if !rl.CheckOrigin(originRequest("https://x.lan.example.com")) || !rl.CheckOrigin(originRequest("http://192.168.1.5:8080")) {
+		t.Error("origins from the file not applied")
+	}
+	if config.AggregationInterval != 3*time.Second || config.CleanupInterval != time.Minute || config.Port == "9999" {
+		t.Errorf("config after reload: aggregation %v, cleanup %v, port %s", config.AggregationInterval, config.CleanupInterval, config.Port)
+	}
+
+	if err := os.WriteFile(path, []byte("AGGREGATION_INTERVAL=fast\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.ReloadFile(); err == nil || config.AggregationInterval != 3*time.Second {
+		t.Errorf("invalid file: err = %v, aggregation %v", err, config.AggregationInterval)
+	}
+}
+
+func TestIntervalUpdates_KeepsLatest(t *testing.T) {
+	u := newIntervalUpdates()
+	u.set(time.Second)
+	u.set(2 * time.Second)
+	if d := <-u; d != 2*time.Second {
+		t.Errorf("got %v, want the latest change", d)
+	}
+}
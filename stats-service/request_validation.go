@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/darthnorse/dockmon-shared/activity"
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+	"github.com/darthnorse/dockmon-shared/maintenance"
+)
+
+// Field limits for request bodies. Generous, but small enough that a bad
+// payload is rejected before it reaches the Docker SDK or the caches.
+const (
+	maxIDLength      = 255
+	maxNameLength    = 255
+	maxAddressLength = 2048
+	maxPEMLength     = 64 * 1024
+	maxLabelEntries  = 100
+	maxMessageLength = 4096
+)
+
+// fieldError describes one invalid field. Field is the JSON name, empty
+// when the problem is the body as a whole.
+type fieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// validationErrors collects every problem in a request so the caller can
+// fix them in one round trip.
+type validationErrors []fieldError
+
+func (v *validationErrors) add(field, format string, args ...interface{}) {
+	*v = append(*v, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v validationErrors) Error() string {
+	parts := make([]string, 0, len(v))
+	for _, e := range v {
+		if e.Field == "" {
+			parts = append(parts, e.Message)
+		} else {
+			parts = append(parts, e.Field+": "+e.Message)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// errorResponse is the JSON body of a rejected request
+type errorResponse struct {
+	Error  string       `json:"error"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+// validator is implemented by request bodies that check their own fields
+type validator interface {
+	validate() validationErrors
+}
+
+// decodeRequest decodes r's JSON body into dst and validates it. On failure
+// it writes a structured error response and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if errs := decodeJSON(r.Body, dst); len(errs) > 0 {
+		status := http.StatusBadRequest
+		if errs[0].Message == errBodyTooLarge {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeValidationError(w, status, errs)
+		return false
+	}
+	if v, ok := dst.(validator); ok {
+		if errs := v.validate(); len(errs) > 0 {
+			writeValidationError(w, http.StatusBadRequest, errs)
+			return false
+		}
+	}
+	return true
+}
+
+const errBodyTooLarge = "request body too large"
+
+// decodeJSON turns decoder errors into field errors: type mismatches name
+// the field, syntax errors give the byte offset.
+func decodeJSON(body io.Reader, dst interface{}) validationErrors {
+	var errs validationErrors
+	err := json.NewDecoder(body).Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		errs.add("", "request body is required")
+	case errors.As(err, &maxBytesErr):
+		errs.add("", errBodyTooLarge)
+	case errors.As(err, &syntaxErr):
+		errs.add("", "invalid JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		errs.add("", "invalid JSON: unexpected end of body")
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			errs.add("", "request body must be a JSON %s", typeErr.Type.Kind())
+		} else {
+			errs.add(field, "must be a %s, got %s", jsonKind(typeErr.Type.Kind().String()), typeErr.Value)
+		}
+	default:
+		errs.add("", "invalid JSON: %v", err)
+	}
+	return errs
+}
+
+// jsonKind names a Go kind the way a JSON client would read it
+func jsonKind(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "bool":
+		return "boolean"
+	case kind == "slice":
+		return "array"
+	case kind == "struct", kind == "map":
+		return "object"
+	}
+	return kind
+}
+
+func writeValidationError(w http.ResponseWriter, status int, errs validationErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: "invalid request", Fields: errs})
+}
+
+// required checks that value is set and within max bytes
+func (v *validationErrors) required(field, value string, max int) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+		return
+	}
+	v.maxLength(field, value, max)
+}
+
+func (v *validationErrors) maxLength(field, value string, max int) {
+	if len(value) > max {
+		v.add(field, "must be at most %d bytes (got %d)", max, len(value))
+	}
+}
+
+// Default ports for a tcp:// address without one, as the Docker CLI uses
+const (
+	defaultDockerPort    = "2375"
+	defaultDockerTLSPort = "2376"
+)
+
+// hostAddress checks a Docker host address: unix:// or npipe:// with a
+// path, tcp:// with a host, or http(s):// with a host. A tcp:// address
+// without a port gets the Docker default, 2376 with TLS and 2375 without.
+func (v *validationErrors) hostAddress(field string, address *string, useTLS bool) {
+	value := *address
+	v.required(field, value, maxAddressLength)
+	if strings.TrimSpace(value) == "" || len(value) > maxAddressLength {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.add(field, "is not a valid address: %v", err)
+		return
+	}
+	switch u.Scheme {
+	case "unix", "npipe":
+		if u.Path == "" {
+			v.add(field, "%s:// address needs a socket path", u.Scheme)
+		}
+	case "tcp":
+		if u.Port() == "" && u.Hostname() != "" && !strings.HasSuffix(u.Host, ":") {
+			port := defaultDockerPort
+			if useTLS {
+				port = defaultDockerTLSPort
+			}
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+			*address = u.String()
+			return
+		}
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil || host == "" || port == "" {
+			v.add(field, "tcp:// address needs a host, e.g. tcp://192.168.1.10:2376")
+		}
+	case "http", "https":
+		if u.Hostname() == "" {
+			v.add(field, "%s:// address needs a host", u.Scheme)
+		}
+	case "":
+		v.add(field, "needs a scheme, e.g. tcp://host:2376 or unix:///var/run/docker.sock")
+	default:
+		v.add(field, "unsupported scheme %q (use tcp, unix, npipe, http or https)", u.Scheme)
+	}
+}
+
+// pemBlocks checks that value, if set, is PEM with at least one block whose
+// type satisfies want
+func (v *validationErrors) pemBlocks(field, value, expected string, want func(blockType string) bool) {
+	if value == "" {
+		return
+	}
+	if len(value) > maxPEMLength {
+		v.maxLength(field, value, maxPEMLength)
+		return
+	}
+	rest := []byte(value)
+	found := false
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if want(block.Type) {
+			found = true
+		}
+	}
+	if !found {
+		v.add(field, "must be a PEM-encoded %s", expected)
+	}
+}
+
+// tls checks the TLS fields of a host request. The client certificate and
+// key are only used together, so one without the other is rejected.
+func (v *validationErrors) tls(caCert, cert, key string) {
+	isCert := func(t string) bool { return t == "CERTIFICATE" }
+	v.pemBlocks("tls_ca_cert", caCert, "certificate", isCert)
+	v.pemBlocks("tls_cert", cert, "certificate", isCert)
+	v.pemBlocks("tls_key", key, "private key", func(t string) bool { return strings.HasSuffix(t, "PRIVATE KEY") })
+	switch {
+	case cert != "" && key == "":
+		v.add("tls_key", "is required when tls_cert is set")
+	case key != "" && cert == "":
+		v.add("tls_cert", "is required when tls_key is set")
+	}
+}
+
+// hostSource resolves file and Docker context references into the inline
+// host fields so they are checked like inline values. Reports whether that
+// worked; the inline fields are only worth checking if it did.
+func (v *validationErrors) hostSource(src dockerpkg.HostSource, address, caCert, cert, key *string) bool {
+	err := src.Resolve(address, caCert, cert, key)
+	var srcErr *dockerpkg.HostSourceError
+	switch {
+	case errors.As(err, &srcErr):
+		v.add(srcErr.Field, "%v", srcErr.Err)
+	case err != nil:
+		v.add("", "%v", err)
+	}
+	return err == nil
+}
+
+func (v *validationErrors) labels(field string, entries []string) {
+	if len(entries) > maxLabelEntries {
+		v.add(field, "must have at most %d entries (got %d)", maxLabelEntries, len(entries))
+		return
+	}
+	if _, err := parseLabelSelectors(entries); err != nil {
+		v.add(field, "%v", err)
+	}
+}
+
+func (req *hostAddRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.required("host_id", req.HostID, maxIDLength)
+	errs.required("host_name", req.HostName, maxNameLength)
+	if errs.hostSource(req.HostSource, &req.HostAddress, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		errs.hostAddress("host_address", &req.HostAddress, req.TLSCACert != "" || req.TLSCert != "")
+		errs.tls(req.TLSCACert, req.TLSCert, req.TLSKey)
+	}
+	if req.NumCPUs < 0 {
+		errs.add("num_cpus", "must not be negative")
+	}
+	if req.AutoDiscover {
+		errs.labels("include_labels", req.IncludeLabels)
+		errs.labels("exclude_labels", req.ExcludeLabels)
+	}
+	return errs
+}
+
+// hostRemoveRequest is the body of /api/hosts/remove and /api/events/hosts/remove
+type hostRemoveRequest struct {
+	HostID string `json:"host_id"`
+}
+
+func (req *hostRemoveRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.required("host_id", req.HostID, maxIDLength)
+	return errs
+}
+
+// eventHostAddRequest is the body of /api/events/hosts/add
+type eventHostAddRequest struct {
+	HostID      string `json:"host_id"`
+	HostName    string `json:"host_name"`
+	HostAddress string `json:"host_address"`
+	TLSCACert   string `json:"tls_ca_cert,omitempty"`
+	TLSCert     string `json:"tls_cert,omitempty"`
+	TLSKey      string `json:"tls_key,omitempty"`
+	dockerpkg.HostSource
+}
+
+func (req *eventHostAddRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.required("host_id", req.HostID, maxIDLength)
+	errs.required("host_name", req.HostName, maxNameLength)
+	if errs.hostSource(req.HostSource, &req.HostAddress, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		errs.hostAddress("host_address", &req.HostAddress, req.TLSCACert != "" || req.TLSCert != "")
+		errs.tls(req.TLSCACert, req.TLSCert, req.TLSKey)
+	}
+	return errs
+}
+
+// hostTestRequest is the body of /api/hosts/test
+type hostTestRequest struct {
+	HostAddress    string `json:"host_address"`
+	TLSCACert      string `json:"tls_ca_cert,omitempty"`
+	TLSCert        string `json:"tls_cert,omitempty"`
+	TLSKey         string `json:"tls_key,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	dockerpkg.HostSource
+}
+
+func (req *hostTestRequest) validate() validationErrors {
+	var errs validationErrors
+	if errs.hostSource(req.HostSource, &req.HostAddress, &req.TLSCACert, &req.TLSCert, &req.TLSKey) {
+		errs.hostAddress("host_address", &req.HostAddress, req.TLSCACert != "" || req.TLSCert != "")
+		errs.tls(req.TLSCACert, req.TLSCert, req.TLSKey)
+	}
+	if max := int(dockerpkg.MaxProbeTimeout / time.Second); req.TimeoutSeconds < 0 || req.TimeoutSeconds > max {
+		errs.add("timeout_seconds", "must be between 0 and %d", max)
+	}
+	return errs
+}
+
+// streamRequest is the body of /api/streams/start and /api/streams/stop
+type streamRequest struct {
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+	HostID        string `json:"host_id"`
+	Group         string `json:"group,omitempty"` // dockmon.group label, start only
+}
+
+func (req *streamRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.required("container_id", req.ContainerID, maxIDLength)
+	errs.maxLength("container_name", req.ContainerName, maxNameLength)
+	errs.maxLength("group", req.Group, maxNameLength)
+	errs.required("host_id", req.HostID, maxIDLength)
+	return errs
+}
+
+// eventCacheLimitsRequest is the body of /api/events/cache/limits. A host
+// size of 0 removes that host's override.
+type eventCacheLimitsRequest struct {
+	DefaultSize *int           `json:"default_size,omitempty"`
+	HostSizes   map[string]int `json:"host_sizes,omitempty"`
+}
+
+func (req *eventCacheLimitsRequest) validate() validationErrors {
+	var errs validationErrors
+	if req.DefaultSize != nil && (*req.DefaultSize < 1 || *req.DefaultSize > MaxEventCacheSize) {
+		errs.add("default_size", "must be between 1 and %d", MaxEventCacheSize)
+	}
+	if len(req.HostSizes) > maxLabelEntries {
+		errs.add("host_sizes", "must have at most %d entries (got %d)", maxLabelEntries, len(req.HostSizes))
+	}
+	for hostID, size := range req.HostSizes {
+		if strings.TrimSpace(hostID) == "" {
+			errs.add("host_sizes", "host IDs must not be empty")
+			continue
+		}
+		errs.maxLength("host_sizes", hostID, maxIDLength)
+		if size < 0 || size > MaxEventCacheSize {
+			errs.add("host_sizes."+hostID, "must be between 0 and %d", MaxEventCacheSize)
+		}
+	}
+	if req.DefaultSize == nil && len(req.HostSizes) == 0 {
+		errs.add("", "default_size or host_sizes is required")
+	}
+	return errs
+}
+
+// hostMaintenanceRequest is the body of /api/hosts/maintenance. A duration
+// of 0 means maintenance.DefaultDuration.
+type hostMaintenanceRequest struct {
+	HostID          string `json:"host_id"`
+	Enabled         bool   `json:"enabled"`
+	Reason          string `json:"reason,omitempty"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+}
+
+func (req *hostMaintenanceRequest) validate() validationErrors {
+	var errs validationErrors
+	errs.required("host_id", req.HostID, maxIDLength)
+	errs.maxLength("reason", req.Reason, maxNameLength)
+	if err := maintenance.ValidateDuration(time.Duration(req.DurationSeconds) * time.Second); err != nil {
+		errs.add("duration_seconds", "%v", err)
+	}
+	return errs
+}
+
+// activityPublishRequest is the body of /api/events/publish
+type activityPublishRequest struct {
+	activity.Event
+}
+
+func (req *activityPublishRequest) validate() validationErrors {
+	errs := validateActivity(req.Event)
+	errs.required("host_id", req.HostID, maxIDLength)
+	return errs
+}
+
+// validateStatsSample checks an agent stats sample from either ingest path.
+// prefix is prepended to field names (e.g. "samples[3].").
+func validateStatsSample(s *agentStatsMsg, prefix string) validationErrors {
+	var errs validationErrors
+	errs.required(prefix+"container_id", s.ContainerID, maxIDLength)
+	errs.maxLength(prefix+"container_name", s.ContainerName, maxNameLength)
+	errs.maxLength(prefix+"group", s.Group, maxNameLength)
+	switch s.State {
+	case "", containerStatePaused, "running":
+	default:
+		errs.add(prefix+"state", "must be %q or \"running\"", containerStatePaused)
+	}
+	return errs
+}
+
+// validateActivity checks an activity event's action and field sizes, for
+// events published over HTTP and events ingested from agents alike
+func validateActivity(e activity.Event) validationErrors {
+	var errs validationErrors
+	if err := e.Validate(); err != nil {
+		errs.add("action", "%v", err)
+	}
+	errs.maxLength("host_id", e.HostID, maxIDLength)
+	errs.maxLength("container_id", e.ContainerID, maxIDLength)
+	errs.maxLength("container_name", e.ContainerName, maxNameLength)
+	errs.maxLength("image", e.Image, maxAddressLength)
+	errs.maxLength("source", e.Source, maxNameLength)
+	errs.maxLength("message", e.Message, maxMessageLength)
+	if len(e.Attributes) > maxLabelEntries {
+		errs.add("attributes", "must have at most %d entries (got %d)", maxLabelEntries, len(e.Attributes))
+		return errs
+	}
+	for key, value := range e.Attributes {
+		errs.maxLength("attributes", key, maxNameLength)
+		errs.maxLength("attributes."+key, value, maxMessageLength)
+	}
+	return errs
+}
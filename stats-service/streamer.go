@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,65 +23,169 @@ type ContainerInfo struct {
 	ID     string
 	Name   string
 	HostID string
+
+	// The stream's parent context, and whether the stream is stopped
+	// because the container is paused; ResumeStream restarts it
+	parent context.Context
+	paused bool
+}
+
+// hostClient holds a host's Docker client. When the host's connection
+// settings change it is superseded by a new holder and replaced is closed,
+// which tells streams reading through it to move to the new client.
+type hostClient struct {
+	lease       *dockerpkg.Lease
+	fingerprint string // Connection settings, see hostConfigFingerprint
+	replaced    chan struct{}
+}
+
+func newHostClient(lease *dockerpkg.Lease, fingerprint string) *hostClient {
+	return &hostClient{lease: lease, fingerprint: fingerprint, replaced: make(chan struct{})}
 }
 
 // StreamManager manages persistent stats streams for all containers
 type StreamManager struct {
 	cache      *StatsCache
-	clients    map[string]*client.Client // hostID -> Docker client
+	clients    map[string]*hostClient // hostID -> current Docker client
 	clientsMu  sync.RWMutex
+	pool       *dockerpkg.Pool // Shared with the event manager
 	hostNames  map[string]string // hostID -> host name (for logging)
 	hostNamesMu sync.RWMutex
 	streams    map[string]context.CancelFunc // composite key (hostID:containerID) -> cancel function
 	streamsMu  sync.RWMutex
 	containers map[string]*ContainerInfo // composite key (hostID:containerID) -> info
 	containersMu sync.RWMutex
+	// hostID -> closed when the host's daemon is back, see SetHostOffline
+	outages    map[string]chan struct{}
+	outagesMu  sync.Mutex
+
+	// memoryMode picks the memory figure reported as MemoryUsage; set once
+	// at startup via SetMemoryMode, before any host is added
+	memoryMode dockerpkg.MemoryMode
+
+	// ioReader reads disk I/O for local-host containers from cgroup v2
+	// io.stat when Docker reports none; nil unless /host/sys is mounted
+	ioReader *dockerpkg.CgroupIOReader
+
+	// limits bounds the Docker API requests per host; shared with the
+	// event manager and discovery
+	limits *HostLimits
 }
 
-// NewStreamManager creates a new stream manager
-func NewStreamManager(cache *StatsCache) *StreamManager {
-	return &StreamManager{
+// NewStreamManager creates a new stream manager that takes its Docker
+// clients from pool, so hosts shared with the event manager use a single
+// client
+func NewStreamManager(cache *StatsCache, pool *dockerpkg.Pool) *StreamManager {
+	ioReader := dockerpkg.NewCgroupIOReader("/host/sys")
+	if ioReader != nil {
+		log.Println("Host /sys mounted at /host/sys - reading cgroup v2 io.stat for local disk I/O")
+	}
+
+	sm := &StreamManager{
 		cache:      cache,
-		clients:    make(map[string]*client.Client),
+		clients:    make(map[string]*hostClient),
+		pool:       pool,
 		hostNames:  make(map[string]string),
 		streams:    make(map[string]context.CancelFunc),
 		containers: make(map[string]*ContainerInfo),
+		ioReader:   ioReader,
 	}
+	sm.limits = NewHostLimits(HostLimitConfig{}, sm.getHostName)
+	return sm
+}
+
+// SetHostLimits replaces the default per-host request limits. Must be
+// called before any host is added.
+func (sm *StreamManager) SetHostLimits(cfg HostLimitConfig) {
+	sm.limits = NewHostLimits(cfg, sm.getHostName)
 }
 
-// AddDockerHost adds a Docker host client
-func (sm *StreamManager) AddDockerHost(hostID, hostName, hostAddress, tlsCACert, tlsCert, tlsKey string) error {
-	// Create Docker client for this host FIRST (before acquiring lock)
-	var cli *client.Client
-	var err error
+// HostLimits returns the per-host Docker request limiters
+func (sm *StreamManager) HostLimits() *HostLimits {
+	return sm.limits
+}
+
+// SetMemoryMode selects whether MemoryUsage reports working-set or raw
+// memory. Both figures are always cached; this only picks the headline one.
+// Must be called before hosts are added, as streams read it unlocked.
+func (sm *StreamManager) SetMemoryMode(mode dockerpkg.MemoryMode) {
+	sm.memoryMode = mode
+}
 
-	// Check if it's a local Unix socket (Docker or Podman)
-	isLocalSocket := hostAddress == "" ||
+// isLocalSocket reports whether a host address is the local Docker or
+// Podman socket, which is reached through the environment instead
+func isLocalSocket(hostAddress string) bool {
+	return hostAddress == "" ||
 		hostAddress == "unix:///var/run/docker.sock" ||
 		hostAddress == "unix:///var/run/podman/podman.sock" ||
 		strings.HasPrefix(hostAddress, "unix:///run/user/")
+}
 
-	if isLocalSocket {
-		// Local Docker/Podman socket - use FromEnv to auto-detect
-		cli, err = client.NewClientWithOpts(
-			client.FromEnv,
-			client.WithAPIVersionNegotiation(),
-		)
-	} else {
-		// Remote Docker host - use shared package with TLS support
-		cli, err = dockerpkg.CreateRemoteClient(hostAddress, tlsCACert, tlsCert, tlsKey)
+// HostAddResult reports what AddDockerHost did with the host's client
+type HostAddResult string
+
+const (
+	// HostCreated means the host was new
+	HostCreated HostAddResult = "created"
+	// HostReused means the host was already registered with the same
+	// connection settings; its client and active streams were kept
+	HostReused HostAddResult = "reused"
+	// HostReplaced means the connection settings changed and the client was
+	// swapped; active streams move to the new client without a gap
+	HostReplaced HostAddResult = "replaced"
+)
+
+// hostConfigFingerprint identifies a host's connection settings so a repeated
+// add with identical settings can keep the existing client.
+func hostConfigFingerprint(hostAddress, tlsCACert, tlsCert, tlsKey string) string {
+	h := sha256.New()
+	for _, part := range []string{hostAddress, tlsCACert, tlsCert, tlsKey} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reuseDockerHost keeps the existing client if the host is already
+// registered with the same settings, refreshing only its name.
+func (sm *StreamManager) reuseDockerHost(hostID, hostName, fingerprint string) bool {
+	sm.clientsMu.RLock()
+	existing, exists := sm.clients[hostID]
+	same := exists && existing.fingerprint == fingerprint
+	sm.clientsMu.RUnlock()
+	if !same {
+		return false
+	}
+
+	sm.hostNamesMu.Lock()
+	sm.hostNames[hostID] = hostName
+	sm.hostNamesMu.Unlock()
+	return true
+}
+
+// AddDockerHost adds a Docker host client. Idempotent: re-adding a host with
+// unchanged connection settings keeps its client and active streams.
+func (sm *StreamManager) AddDockerHost(hostID, hostName, hostAddress, tlsCACert, tlsCert, tlsKey string) (HostAddResult, error) {
+	fingerprint := hostConfigFingerprint(hostAddress, tlsCACert, tlsCert, tlsKey)
+	if sm.reuseDockerHost(hostID, hostName, fingerprint) {
+		return HostReused, nil
 	}
 
+	// Lease the host's Docker client FIRST (before acquiring lock). Local
+	// Docker/Podman sockets are auto-detected from the environment.
+	if isLocalSocket(hostAddress) {
+		hostAddress = ""
+	}
+	lease, err := sm.pool.AcquireHost(hostAddress, tlsCACert, tlsCert, tlsKey)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	// Track whether client was successfully stored to prevent leak
-	clientStored := false
+	// Track whether the lease was successfully stored to prevent leak
+	leaseStored := false
 	defer func() {
-		if !clientStored && cli != nil {
-			cli.Close()
-			log.Printf("Cleaned up unstored Docker client for host %s", truncateID(hostID, 8))
+		if !leaseStored {
+			lease.Release()
 		}
 	}()
 
@@ -86,29 +193,46 @@ func (sm *StreamManager) AddDockerHost(hostID, hostName, hostAddress, tlsCACert,
 	sm.clientsMu.Lock()
 	defer sm.clientsMu.Unlock()
 
-	// Close existing client if it exists (only after new one succeeds)
-	if existingClient, exists := sm.clients[hostID]; exists {
-		existingClient.Close()
-		log.Printf("Closed existing Docker client for host %s (%s)", hostName, truncateID(hostID, 8))
+	// A concurrent add with the same settings won the race; keep its client
+	result := HostCreated
+	existing, exists := sm.clients[hostID]
+	if exists {
+		if existing.fingerprint == fingerprint {
+			return HostReused, nil
+		}
+		result = HostReplaced
 	}
 
-	sm.clients[hostID] = cli
-	clientStored = true // Mark as successfully stored
+	sm.clients[hostID] = newHostClient(lease, fingerprint)
+	leaseStored = true // Mark as successfully stored
+
+	if exists {
+		// Streams on the old client open a stream on the new one before
+		// closing theirs. Releasing the old lease doesn't cut them off: the
+		// pool only closes a released client once it has been idle for its
+		// TTL (or fails a health check).
+		close(existing.replaced)
+		existing.lease.Release()
+		log.Printf("Released existing Docker client for host %s (%s)", hostName, truncateID(hostID, 8))
+	}
 
 	// Store host name for logging
 	sm.hostNamesMu.Lock()
 	sm.hostNames[hostID] = hostName
 	sm.hostNamesMu.Unlock()
 
-	log.Printf("Added Docker host: %s (%s) at %s", hostName, truncateID(hostID, 8), hostAddress)
+	log.Printf("Added Docker host: %s (%s) at %s (%s)", hostName, truncateID(hostID, 8), hostAddress, result)
 
-	// Initialize host stats with zero values so the host appears immediately in the UI
-	sm.cache.UpdateHostStats(&HostStats{
-		HostID:         hostID,
-		ContainerCount: 0,
-	})
+	// Initialize host stats with zero values so a new host appears immediately
+	// in the UI; a replaced host keeps its current stats
+	if result == HostCreated {
+		sm.cache.UpdateHostStats(&HostStats{
+			HostID:         hostID,
+			ContainerCount: 0,
+		})
+	}
 
-	return nil
+	return result, nil
 }
 
 // RemoveDockerHost removes a Docker host client and stops all its streams
@@ -133,13 +257,14 @@ func (sm *StreamManager) RemoveDockerHost(hostID string) {
 		}
 	}
 
-	// Now close and remove the Docker client
+	// Now release and remove the Docker client
 	sm.clientsMu.Lock()
 	defer sm.clientsMu.Unlock()
-	if cli, exists := sm.clients[hostID]; exists {
+	if host, exists := sm.clients[hostID]; exists {
 		hostName := sm.getHostName(hostID)
-		cli.Close()
+		host.lease.Release()
 		delete(sm.clients, hostID)
+		sm.limits.Forget(hostID)
 		log.Printf("Removed Docker host: %s (%s)", hostName, truncateID(hostID, 8))
 	}
 
@@ -147,6 +272,7 @@ func (sm *StreamManager) RemoveDockerHost(hostID string) {
 	sm.hostNamesMu.Lock()
 	delete(sm.hostNames, hostID)
 	sm.hostNamesMu.Unlock()
+	sm.SetHostOffline(hostID, false)
 
 	// Remove all stats for this host from cache
 	sm.cache.RemoveHostStats(hostID)
@@ -192,9 +318,14 @@ func (sm *StreamManager) StartStream(ctx context.Context, containerID, container
 		ID:     containerID,
 		Name:   containerName,
 		HostID: hostID,
+		parent: ctx,
 	}
 	sm.containersMu.Unlock()
 
+	// Streams are only started for running containers, so a pause flag
+	// left by a missed unpause event is stale
+	sm.cache.MarkContainerUnpaused(containerID, hostID)
+
 	// Start streaming goroutine (no locks held)
 	go sm.streamStats(streamCtx, containerID, containerName, hostID)
 
@@ -220,12 +351,62 @@ func (sm *StreamManager) StopStream(containerID, hostID string) {
 	defer sm.containersMu.Unlock()
 	delete(sm.containers, compositeKey)
 
-	// Remove from cache
-	sm.cache.RemoveContainerStats(containerID, hostID)
+	// Keep the last sample as the container's final one (removed after
+	// the stopped retention period)
+	sm.cache.MarkContainerStopped(containerID, hostID, "", nil)
+	sm.ioReader.Forget(containerID)
 
 	log.Printf("Stopped stats stream for container %s", truncateID(containerID, 12))
 }
 
+// PauseStream stops a paused container's stream, which would only repeat
+// its last figures, and flags its cached sample paused. The container is
+// remembered so ResumeStream can restart the stream.
+func (sm *StreamManager) PauseStream(containerID, containerName, hostID string) {
+	compositeKey := fmt.Sprintf("%s:%s", hostID, containerID)
+
+	sm.streamsMu.Lock()
+	cancel, exists := sm.streams[compositeKey]
+	if exists {
+		cancel()
+		delete(sm.streams, compositeKey)
+	}
+	sm.streamsMu.Unlock()
+
+	sm.cache.MarkContainerPaused(containerID, containerName, hostID)
+	if !exists {
+		return
+	}
+
+	sm.containersMu.Lock()
+	if info, ok := sm.containers[compositeKey]; ok {
+		info.paused = true
+	}
+	sm.containersMu.Unlock()
+	sm.ioReader.Forget(containerID)
+
+	log.Printf("Paused stats stream for container %s", truncateID(containerID, 12))
+}
+
+// ResumeStream clears an unpaused container's paused flag and restarts
+// its stream if PauseStream stopped one
+func (sm *StreamManager) ResumeStream(containerID, hostID string) error {
+	compositeKey := fmt.Sprintf("%s:%s", hostID, containerID)
+
+	sm.containersMu.Lock()
+	info, ok := sm.containers[compositeKey]
+	if ok && info.paused {
+		delete(sm.containers, compositeKey)
+	}
+	sm.containersMu.Unlock()
+
+	sm.cache.MarkContainerUnpaused(containerID, hostID)
+	if !ok || !info.paused {
+		return nil
+	}
+	return sm.StartStream(info.parent, containerID, info.Name, hostID)
+}
+
 // streamStats maintains a persistent stats stream for a single container
 func (sm *StreamManager) streamStats(ctx context.Context, containerID, containerName, hostID string) {
 	defer func() {
@@ -238,74 +419,202 @@ func (sm *StreamManager) streamStats(ctx context.Context, containerID, container
 	backoff := time.Second
 	maxBackoff := 30 * time.Second
 
+	// A stream already opened on a replaced host's new client
+	var next *hostStream
+
 	for {
 		select {
 		case <-ctx.Done():
+			if next != nil {
+				next.body.Close()
+			}
 			return
 		default:
 		}
 
-		// Get current Docker client (may have changed if host was updated)
-		sm.clientsMu.RLock()
-		cli, ok := sm.clients[hostID]
-		sm.clientsMu.RUnlock() // Manual unlock needed - we're in a loop
+		stream := next
+		next = nil
+		if stream == nil {
+			// Get current Docker client (may have changed if host was updated or
+			// its client was recreated after a failed health check)
+			host, ok := sm.getHostClient(hostID)
+
+			if !ok {
+				hostName := sm.getHostName(hostID)
+				log.Printf("No Docker client for host %s (%s) (container %s), retrying in %v", hostName, truncateID(hostID, 8), truncateID(containerID, 12), backoff)
+				time.Sleep(backoff)
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
 
-		if !ok {
-			hostName := sm.getHostName(hostID)
-			log.Printf("No Docker client for host %s (%s) (container %s), retrying in %v", hostName, truncateID(hostID, 8), truncateID(containerID, 12), backoff)
-			time.Sleep(backoff)
-			backoff = min(backoff*2, maxBackoff)
-			continue
+			// Open stats stream
+			var err error
+			stream, err = sm.openHostStream(ctx, hostID, host, containerID)
+			if err != nil {
+				// While the daemon is down, wait for it instead of logging
+				// an error for every container
+				if back := sm.hostOutage(hostID); back != nil {
+					select {
+					case <-ctx.Done():
+					case <-back:
+					}
+					backoff = time.Second
+					continue
+				}
+				// A degraded host is left alone quietly until its cooldown
+				// has passed
+				if errors.Is(err, errHostDegraded) {
+					time.Sleep(backoff)
+					backoff = min(backoff*2, maxBackoff)
+					continue
+				}
+				log.Printf("Error opening stats stream for %s: %v (retrying in %v)", truncateID(containerID, 12), err, backoff)
+				time.Sleep(backoff)
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
 		}
 
-		// Open stats stream
-		stats, err := cli.ContainerStats(ctx, containerID, true) // stream=true
-		if err != nil {
-			log.Printf("Error opening stats stream for %s: %v (retrying in %v)", truncateID(containerID, 12), err, backoff)
-			time.Sleep(backoff)
-			backoff = min(backoff*2, maxBackoff)
+		// Reset backoff on successful connection
+		backoff = time.Second
+
+		if next = sm.readStats(ctx, stream, containerID, containerName, hostID); next != nil {
+			log.Printf("Stats stream for %s moved to the new client for host %s", truncateID(containerID, 12), sm.getHostName(hostID))
 			continue
 		}
 
-		// Reset backoff on successful connection
-		backoff = time.Second
+		// Brief pause before reconnecting
+		time.Sleep(time.Second)
+	}
+}
 
-		// Read stats from stream
-		decoder := json.NewDecoder(stats.Body)
+// hostStream is a stats stream and the host client it was opened on
+type hostStream struct {
+	host *hostClient
+	body io.ReadCloser
+}
 
-		for {
-			select {
-			case <-ctx.Done():
+// streamOpenTimeout bounds opening a stats stream; once open, the stream
+// runs until its context ends
+const streamOpenTimeout = 30 * time.Second
+
+// errStreamOpenTimeout is the cause a stats stream's open is aborted with
+var errStreamOpenTimeout = errors.New("timed out opening stats stream")
+
+// openHostStream opens a container's stats stream through the host's request
+// limiter, which it occupies only until the stream is open
+func (sm *StreamManager) openHostStream(ctx context.Context, hostID string, host *hostClient, containerID string) (*hostStream, error) {
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	var stream *hostStream
+	err := sm.limits.Call(ctx, hostID, func() error {
+		timer := time.AfterFunc(streamOpenTimeout, func() { cancel(errStreamOpenTimeout) })
+		stats, err := host.lease.Client().ContainerStats(streamCtx, containerID, true) // stream=true
+		timer.Stop()
+		if cause := context.Cause(streamCtx); errors.Is(cause, errStreamOpenTimeout) {
+			if err == nil {
 				stats.Body.Close()
-				return
-			default:
 			}
+			return cause
+		}
+		if err != nil {
+			return err
+		}
+		stream = &hostStream{host: host, body: cancelOnClose{ReadCloser: stats.Body, cancel: cancel}}
+		return nil
+	})
+	if err != nil {
+		cancel(context.Canceled)
+		return nil, err
+	}
+	return stream, nil
+}
 
-			var stat container.StatsResponse
-			if err := decoder.Decode(&stat); err != nil {
-				stats.Body.Close()
-				if err == io.EOF || err == context.Canceled {
+// cancelOnClose releases a stream's context when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel(context.Canceled)
+	return err
+}
+
+// readStats caches samples from stream until it ends. If the host's client
+// is replaced meanwhile, a stream is opened on the new client before this
+// one is closed and it is returned, so samples keep flowing without going
+// through the reconnect backoff.
+func (sm *StreamManager) readStats(ctx context.Context, stream *hostStream, containerID, containerName, hostID string) *hostStream {
+	done := make(chan struct{})
+	next := make(chan *hostStream, 1)
+	go func() {
+		defer close(next)
+		select {
+		case <-done:
+			return
+		case <-stream.host.replaced:
+		}
+		if host, ok := sm.getHostClient(hostID); ok {
+			replacement, err := sm.openHostStream(ctx, hostID, host, containerID)
+			if err != nil {
+				log.Printf("Error opening stats stream for %s on the new client: %v", truncateID(containerID, 12), err)
+			} else {
+				next <- replacement
+			}
+		}
+		stream.body.Close()
+	}()
+
+	// Read stats from stream
+	decoder := json.NewDecoder(stream.body)
+	for {
+		var stat container.StatsResponse
+		if err := decoder.Decode(&stat); err != nil {
+			select {
+			case <-stream.host.replaced:
+				// Closed to move to the new client
+			default:
+				if sm.hostOutage(hostID) != nil {
+					// Host is down; its event stream reported it
+				} else if err == io.EOF || err == context.Canceled {
 					log.Printf("Stats stream ended for %s", truncateID(containerID, 12))
-				} else {
+				} else if ctx.Err() == nil {
 					log.Printf("Error decoding stats for %s: %v", truncateID(containerID, 12), err)
 				}
-				break // Break inner loop, will retry in outer loop
 			}
-
-			// Calculate and cache stats
-			sm.processStats(&stat, containerID, containerName, hostID)
+			break // Break loop, will retry in streamStats
 		}
 
-		// Brief pause before reconnecting
-		time.Sleep(time.Second)
+		// Calculate and cache stats
+		sm.processStats(&stat, containerID, containerName, hostID)
 	}
+	stream.body.Close()
+
+	// Wait for a replacement in progress; nil if there is none
+	close(done)
+	return <-next
 }
 
 // processStats calculates metrics from raw Docker stats
 // Now uses shared package for consistent calculation across all hosts
 func (sm *StreamManager) processStats(stat *container.StatsResponse, containerID, containerName, hostID string) {
+	// A stopped container's stream yields empty samples (no read time);
+	// caching one would overwrite the container's final sample with zeros
+	if stat.Read.IsZero() {
+		return
+	}
+
 	// Use shared package for all stats calculations
-	result := dockerpkg.CalculateStats(stat)
+	result := dockerpkg.CalculateStatsWithMode(stat, sm.memoryMode)
+	debugf("Stats for %s: cpu=%.2f%% (cpu delta %d, system delta %d, online cpus %d) mem=%d/%d",
+		truncateID(containerID, 12), result.CPUPercent,
+		stat.CPUStats.CPUUsage.TotalUsage-stat.PreCPUStats.CPUUsage.TotalUsage,
+		stat.CPUStats.SystemUsage-stat.PreCPUStats.SystemUsage, stat.CPUStats.OnlineCPUs,
+		result.MemoryUsage, result.MemoryLimit)
+	if sm.cache.IsHostLocal(hostID) {
+		sm.ioReader.FillDiskIO(result, stat, containerID)
+	}
 
 	// Update cache with calculated stats
 	sm.cache.UpdateContainerStats(&ContainerStats{
@@ -320,6 +629,10 @@ func (sm *StreamManager) processStats(stat *container.StatsResponse, containerID
 		NetworkTx:     result.NetworkTx,
 		DiskRead:      result.DiskRead,
 		DiskWrite:     result.DiskWrite,
+
+		MemoryRawUsage:   result.MemoryRawUsage,
+		MemoryWorkingSet: result.MemoryWorkingSet,
+		PerCPUPercent:    roundPerCore(result.PerCPUPercent),
 	})
 }
 
@@ -341,6 +654,23 @@ func (sm *StreamManager) getHostName(hostID string) string {
 	return truncateID(hostID, 8) // Fallback to short ID
 }
 
+// getClient returns the host's current Docker client
+func (sm *StreamManager) getClient(hostID string) (*client.Client, bool) {
+	host, ok := sm.getHostClient(hostID)
+	if !ok {
+		return nil, false
+	}
+	return host.lease.Client(), true
+}
+
+// getHostClient returns the host's current client holder
+func (sm *StreamManager) getHostClient(hostID string) (*hostClient, bool) {
+	sm.clientsMu.RLock()
+	defer sm.clientsMu.RUnlock()
+	host, ok := sm.clients[hostID]
+	return host, ok
+}
+
 // HasHost checks if a Docker host is registered
 func (sm *StreamManager) HasHost(hostID string) bool {
 	sm.clientsMu.RLock()
@@ -360,14 +690,14 @@ func (sm *StreamManager) StopAllStreams() {
 	sm.streams = make(map[string]context.CancelFunc)
 	sm.streamsMu.Unlock()
 
-	// Close all Docker clients
+	// Release all Docker clients
 	sm.clientsMu.Lock()
-	for hostID, cli := range sm.clients {
+	for hostID, host := range sm.clients {
 		hostName := sm.getHostName(hostID)
-		cli.Close()
-		log.Printf("Closed Docker client for host %s (%s)", hostName, truncateID(hostID, 8))
+		host.lease.Release()
+		log.Printf("Released Docker client for host %s (%s)", hostName, truncateID(hostID, 8))
 	}
-	sm.clients = make(map[string]*client.Client)
+	sm.clients = make(map[string]*hostClient)
 	sm.clientsMu.Unlock()
 
 	// Clear all host names
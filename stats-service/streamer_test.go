@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dockerpkg "github.com/darthnorse/dockmon-shared/docker"
+)
+
+// fakeStatsDaemon streams a stats sample for container "c1" every 20ms,
+// with memory usage set to usage so samples show which daemon sent them.
+// closed counts streams whose client went away.
+func fakeStatsDaemon(t *testing.T, usage int) (addr string, closed *atomic.Int32) {
+	t.Helper()
+	closed = &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "1.41")
+		if !strings.HasSuffix(r.URL.Path, "/containers/c1/stats") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			fmt.Fprintf(w, `{"read":%q,"memory_stats":{"usage":%d,"limit":1000}}`+"\n", time.Now().Format(time.RFC3339Nano), usage)
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				closed.Add(1)
+				return
+			case <-ticker.C:
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "tcp://" + srv.Listener.Addr().String(), closed
+}
+
+// waitForUsage waits for a cached sample with the given memory usage
+func waitForUsage(t *testing.T, cache *StatsCache, usage uint64, within time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for time.Now().Before(deadline) {
+		if stats, ok := cache.GetContainerStats("c1", "h1"); ok && stats.MemoryUsage == usage {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no sample with memory usage %d within %v", usage, within)
+}
+
+func TestStreamManager_ReplacedHostKeepsStreaming(t *testing.T) {
+	oldAddr, oldClosed := fakeStatsDaemon(t, 100)
+	newAddr, _ := fakeStatsDaemon(t, 200)
+
+	cache := NewStatsCache()
+	sm := NewStreamManager(cache, dockerpkg.NewPool(0, 0))
+	defer sm.StopAllStreams()
+
+	if _, err := sm.AddDockerHost("h1", "one", oldAddr, "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.StartStream(context.Background(), "c1", "web", "h1"); err != nil {
+		t.Fatal(err)
+	}
+	waitForUsage(t, cache, 100, 5*time.Second)
+
+	if result, err := sm.AddDockerHost("h1", "one", newAddr, "", "", ""); err != nil || result != HostReplaced {
+		t.Fatalf("replace: result %q, err %v", result, err)
+	}
+	// Well inside the one-second reconnect pause a restarted stream would take
+	waitForUsage(t, cache, 200, 500*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for oldClosed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if oldClosed.Load() != 1 {
+		t.Error("stream on the old client should be closed after moving over")
+	}
+}
@@ -17,6 +17,8 @@ export type HistoricalRange = Exclude<TimeRange, 'live'>
  * Nulls in the data arrays represent missing buckets (chart gaps).
  * `memory_used_bytes` and `memory_limit_bytes` are optional companion columns
  * for richer memory labels; `container_count` is host-only.
+ * `events` (container-only) lists lifecycle events inside the window, each
+ * snapped to the bucket timestamp it falls into, for marking graphs.
  */
 export interface StatsHistoryResponse {
   tier: HistoricalRange
@@ -32,6 +34,15 @@ export interface StatsHistoryResponse {
   memory_used_bytes?: (number | null)[]
   memory_limit_bytes?: (number | null)[]
   container_count?: (number | null)[]
+  events?: StatsHistoryEvent[]
+}
+
+/** A container lifecycle event marked on history graphs. */
+export interface StatsHistoryEvent {
+  timestamp: number
+  bucket: number
+  action: 'start' | 'die' | 'restart' | 'oom' | 'update'
+  exit_code?: number
 }
 
 /**